@@ -10,10 +10,10 @@ import (
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/exp/slog"
 	"golang.org/x/oauth2"
 
 	"github.com/gorilla/websocket"
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/logic/pro/netcache"
 	"github.com/gravitl/netmaker/models"
@@ -72,21 +72,22 @@ func getCurrentAuthFunctions() map[string]interface{} {
 func InitializeAuthProvider() string {
 	var functions = getCurrentAuthFunctions()
 	if functions == nil {
+		auth_provider = nil
 		return ""
 	}
 	var _, err = fetchPassValue(logic.RandomString(64))
 	if err != nil {
-		logger.Log(0, err.Error())
+		slog.Error(err.Error())
 		return ""
 	}
 	var authInfo = servercfg.GetAuthProviderInfo()
 	var serverConn = servercfg.GetAPIHost()
 	if strings.Contains(serverConn, "localhost") || strings.Contains(serverConn, "127.0.0.1") {
 		serverConn = "http://" + serverConn
-		logger.Log(1, "localhost OAuth detected, proceeding with insecure http redirect: (", serverConn, ")")
+		slog.Info("localhost OAuth detected, proceeding with insecure http redirect", "server_conn", serverConn)
 	} else {
 		serverConn = "https://" + serverConn
-		logger.Log(1, "external OAuth detected, proceeding with https redirect: ("+serverConn+")")
+		slog.Info("external OAuth detected, proceeding with https redirect: (" + serverConn + ")")
 	}
 
 	if authInfo[0] == "oidc" {
@@ -114,13 +115,13 @@ func HandleAuthCallback(w http.ResponseWriter, r *http.Request) {
 	if err == nil || errors.Is(err, netcache.ErrExpired) {
 		switch len(state) {
 		case node_signin_length:
-			logger.Log(1, "proceeding with host SSO callback")
+			slog.InfoCtx(r.Context(), "proceeding with host SSO callback")
 			HandleHostSSOCallback(w, r)
 		case headless_signin_length:
-			logger.Log(1, "proceeding with headless SSO callback")
+			slog.InfoCtx(r.Context(), "proceeding with headless SSO callback")
 			HandleHeadlessSSOCallback(w, r)
 		default:
-			logger.Log(1, "invalid state length: ", fmt.Sprintf("%d", len(state)))
+			slog.ErrorCtx(r.Context(), "invalid state length", "count", len(state))
 		}
 	} else { // handle normal login
 		functions[handle_callback].(func(http.ResponseWriter, *http.Request))(w, r)
@@ -165,11 +166,11 @@ func IsOauthUser(user *models.User) error {
 func HandleHeadlessSSO(w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		logger.Log(0, "error during connection upgrade for headless sign-in:", err.Error())
+		slog.ErrorCtx(r.Context(), "error during connection upgrade for headless sign-in", "error", err)
 		return
 	}
 	if conn == nil {
-		logger.Log(0, "failed to establish web-socket connection during headless sign-in")
+		slog.ErrorCtx(r.Context(), "failed to establish web-socket connection during headless sign-in")
 		return
 	}
 	defer conn.Close()
@@ -177,7 +178,7 @@ func HandleHeadlessSSO(w http.ResponseWriter, r *http.Request) {
 	req := &netcache.CValue{User: "", Pass: ""}
 	stateStr := logic.RandomString(headless_signin_length)
 	if err = netcache.Set(stateStr, req); err != nil {
-		logger.Log(0, "Failed to process sso request -", err.Error())
+		slog.ErrorCtx(r.Context(), "Failed to process sso request", "error", err)
 		return
 	}
 
@@ -188,13 +189,13 @@ func HandleHeadlessSSO(w http.ResponseWriter, r *http.Request) {
 
 	if auth_provider == nil {
 		if err = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")); err != nil {
-			logger.Log(0, "error during message writing:", err.Error())
+			slog.ErrorCtx(r.Context(), "error during message writing", "error", err)
 		}
 		return
 	}
 	redirectUrl = fmt.Sprintf("https://%s/api/oauth/register/%s", servercfg.GetAPIConnString(), stateStr)
 	if err = conn.WriteMessage(websocket.TextMessage, []byte(redirectUrl)); err != nil {
-		logger.Log(0, "error during message writing:", err.Error())
+		slog.ErrorCtx(r.Context(), "error during message writing", "error", err)
 	}
 
 	go func() {
@@ -202,13 +203,13 @@ func HandleHeadlessSSO(w http.ResponseWriter, r *http.Request) {
 			cachedReq, err := netcache.Get(stateStr)
 			if err != nil {
 				if strings.Contains(err.Error(), "expired") {
-					logger.Log(0, "timeout occurred while waiting for SSO")
+					slog.InfoCtx(r.Context(), "timeout occurred while waiting for SSO")
 					timeout <- true
 					break
 				}
 				continue
 			} else if cachedReq.Pass != "" {
-				logger.Log(0, "SSO process completed for user ", cachedReq.User)
+				slog.InfoCtx(r.Context(), "SSO process completed for user", "user", cachedReq.User)
 				answer <- cachedReq.Pass
 				break
 			}
@@ -219,19 +220,19 @@ func HandleHeadlessSSO(w http.ResponseWriter, r *http.Request) {
 	select {
 	case result := <-answer:
 		if err = conn.WriteMessage(websocket.TextMessage, []byte(result)); err != nil {
-			logger.Log(0, "Error during message writing:", err.Error())
+			slog.ErrorCtx(r.Context(), "Error during message writing", "error", err)
 		}
 	case <-timeout:
-		logger.Log(0, "Authentication server time out for headless SSO login")
+		slog.InfoCtx(r.Context(), "Authentication server time out for headless SSO login")
 		if err = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")); err != nil {
-			logger.Log(0, "Error during message writing:", err.Error())
+			slog.ErrorCtx(r.Context(), "Error during message writing", "error", err)
 		}
 	}
 	if err = netcache.Del(stateStr); err != nil {
-		logger.Log(0, "failed to remove SSO cache entry", err.Error())
+		slog.ErrorCtx(r.Context(), "failed to remove SSO cache entry", "error", err)
 	}
 	if err = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")); err != nil {
-		logger.Log(0, "write close:", err.Error())
+		slog.ErrorCtx(r.Context(), "write close", "error", err)
 	}
 }
 
@@ -240,7 +241,7 @@ func HandleHeadlessSSO(w http.ResponseWriter, r *http.Request) {
 func addUser(email string) error {
 	var hasAdmin, err = logic.HasAdmin()
 	if err != nil {
-		logger.Log(1, "error checking for existence of admin user during OAuth login for", email, "; user not added")
+		slog.Error("error checking for existence of admin user during OAuth login, user not added", "email", email)
 		return err
 	} // generate random password to adapt to current model
 	var newPass, fetchErr = fetchPassValue("")
@@ -253,17 +254,17 @@ func addUser(email string) error {
 	}
 	if !hasAdmin { // must be first attempt, create an admin
 		if err = logic.CreateAdmin(&newUser); err != nil {
-			logger.Log(1, "error creating admin from user,", email, "; user not added")
+			slog.Error("error creating admin from user, user not added", "email", email)
 		} else {
-			logger.Log(1, "admin created from user,", email, "; was first user added")
+			slog.Info("admin created from user, was first user added", "email", email)
 		}
 	} else { // otherwise add to db as admin..?
 		// TODO: add ability to add users with preemptive permissions
 		newUser.IsAdmin = false
 		if err = logic.CreateUser(&newUser); err != nil {
-			logger.Log(1, "error creating user,", email, "; user not added")
+			slog.Error("error creating user, user not added", "email", email)
 		} else {
-			logger.Log(0, "user created from ", email)
+			slog.Info("user created", "email", email)
 		}
 	}
 	return nil
@@ -294,7 +295,7 @@ func fetchPassValue(newValue string) (string, error) {
 
 	var b64CurrentValue, b64Err = base64.StdEncoding.DecodeString(newValueHolder.Value)
 	if b64Err != nil {
-		logger.Log(0, "could not decode pass")
+		slog.Error("could not decode pass")
 		return "", nil
 	}
 	return string(b64CurrentValue), nil
@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/logic/pro/netcache"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	device_signin_length = 48
+	// device_signin_timeout - how long a device sign in can be approved for, as long as netcache keeps it
+	device_signin_timeout = 5 * time.Minute
+	// device_default_interval - seconds between polls when the provider doesn't say
+	device_default_interval = 5
+	device_grant_type       = "urn:ietf:params:oauth:grant-type:device_code"
+)
+
+// ErrDeviceFlowUnsupported - device sign in needs an OIDC provider with a device authorization endpoint
+var ErrDeviceFlowUnsupported = errors.New("device sign in requires an OIDC provider that supports the device flow")
+
+// deviceAuthResponse - the answer of the provider's device authorization endpoint
+type deviceAuthResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+	Error                   string `json:"error"`
+}
+
+// deviceTokenResponse - the answer of the provider's token endpoint to a device code
+type deviceTokenResponse struct {
+	IDToken string `json:"id_token"`
+	Error   string `json:"error"`
+}
+
+// swagger:route POST /api/v1/rac/device rac startRACDeviceAuth
+//
+// Starts an OIDC device sign in for a remote access client, the user approves it on the identity provider
+// with the user code while the client polls the session. No Netmaker password is needed.
+//
+//	Schemes: https
+//
+//	Responses:
+//		200: racDeviceAuthResponse
+func HandleRACDeviceAuth(w http.ResponseWriter, r *http.Request) {
+	if auth_provider == nil || oidc_device_auth_url == "" {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(ErrDeviceFlowUnsupported, "badrequest"))
+		return
+	}
+	form := url.Values{
+		"client_id": {auth_provider.ClientID},
+		"scope":     {strings.Join(auth_provider.Scopes, " ")},
+	}
+	var res deviceAuthResponse
+	if err := postDeviceForm(r.Context(), oidc_device_auth_url, form, &res); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to start device sign in", "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	if res.Error != "" || res.DeviceCode == "" {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(fmt.Errorf("identity provider refused the device sign in: %s", res.Error), "internal"))
+		return
+	}
+	session := logic.RandomString(device_signin_length)
+	if err := netcache.Set(session, &netcache.CValue{Value: res.DeviceCode}); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	expiresIn := int(device_signin_timeout.Seconds())
+	if res.ExpiresIn > 0 && res.ExpiresIn < expiresIn {
+		expiresIn = res.ExpiresIn
+	}
+	if res.Interval == 0 {
+		res.Interval = device_default_interval
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.RACDeviceAuth{
+		Session:                 session,
+		UserCode:                res.UserCode,
+		VerificationURI:         res.VerificationURI,
+		VerificationURIComplete: res.VerificationURIComplete,
+		ExpiresIn:               expiresIn,
+		Interval:                res.Interval,
+	})
+}
+
+// swagger:route POST /api/v1/rac/device/{session} rac pollRACDeviceAuth
+//
+// Polls a device sign in. Answers 202 with the status authorization_pending or slow_down until the user
+// approved it, then the Netmaker token of the user, who is added on their first sign in.
+//
+//	Schemes: https
+//
+//	Responses:
+//		200: racDeviceTokenResponse
+func HandleRACDeviceToken(w http.ResponseWriter, r *http.Request) {
+	if auth_provider == nil || oidc_verifier == nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(ErrDeviceFlowUnsupported, "badrequest"))
+		return
+	}
+	session := mux.Vars(r)["session"]
+	cached, err := netcache.Get(session)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("device sign in not found or expired"), "notfound"))
+		return
+	}
+	if cached.Pass != "" {
+		writeDeviceToken(w, http.StatusOK, models.RACDeviceToken{Status: "approved", Token: cached.Pass, User: cached.User})
+		return
+	}
+	form := url.Values{
+		"grant_type":  {device_grant_type},
+		"device_code": {cached.Value},
+		"client_id":   {auth_provider.ClientID},
+	}
+	if auth_provider.ClientSecret != "" {
+		form.Set("client_secret", auth_provider.ClientSecret)
+	}
+	var res deviceTokenResponse
+	if err = postDeviceForm(r.Context(), auth_provider.Endpoint.TokenURL, form, &res); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to poll device sign in", "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	switch res.Error {
+	case "":
+	case "authorization_pending", "slow_down":
+		writeDeviceToken(w, http.StatusAccepted, models.RACDeviceToken{Status: res.Error})
+		return
+	default:
+		netcache.Del(session)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(fmt.Errorf("device sign in failed: %s", res.Error), "unauthorized"))
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), OIDC_TIMEOUT)
+	defer cancel()
+	idToken, err := oidc_verifier.Verify(ctx, res.IDToken)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(fmt.Errorf("failed to verify id token: %w", err), "unauthorized"))
+		return
+	}
+	var content OAuthUser
+	if err = idToken.Claims(&content); err != nil || content.Email == "" {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("id token has no email"), "unauthorized"))
+		return
+	}
+	if _, err = logic.GetUser(content.Email); err != nil { // user must not exist, so try to make one
+		if err = addUser(content.Email); err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+			return
+		}
+	}
+	pass, err := fetchPassValue("")
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	jwt, err := logic.VerifyAuthRequest(models.UserAuthParams{UserName: content.Email, Password: pass})
+	logic.RecordAuthEvent(content.Email, r, "oidc-device", err == nil)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "could not issue token for device sign in", "email", content.Email, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "unauthorized"))
+		return
+	}
+	// later polls of the session get the same token
+	cached.User, cached.Pass = content.Email, jwt
+	if err = netcache.Set(session, cached); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to cache device sign in", "email", content.Email, "error", err)
+	}
+	slog.InfoCtx(r.Context(), "completed device sign in", "email", content.Email)
+	writeDeviceToken(w, http.StatusOK, models.RACDeviceToken{Status: "approved", Token: jwt, User: content.Email})
+}
+
+// == private ==
+
+// postDeviceForm - posts a form to an endpoint of the provider and decodes its answer, error statuses included
+func postDeviceForm(ctx context.Context, endpoint string, form url.Values, out any) error {
+	ctx, cancel := context.WithTimeout(ctx, OIDC_TIMEOUT)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if err = json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("unexpected answer from identity provider, status %d: %w", res.StatusCode, err)
+	}
+	return nil
+}
+
+func writeDeviceToken(w http.ResponseWriter, status int, token models.RACDeviceToken) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(token)
+}
@@ -7,10 +7,10 @@ import (
 	"io"
 	"net/http"
 
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/github"
 )
@@ -56,7 +56,7 @@ func handleGithubCallback(w http.ResponseWriter, r *http.Request) {
 	var rState, rCode = getStateAndCode(r)
 	var content, err = getGithubUserInfo(rState, rCode)
 	if err != nil {
-		logger.Log(1, "error when getting user info from github:", err.Error())
+		slog.ErrorCtx(r.Context(), "error when getting user info from github", "error", err)
 		handleOauthNotConfigured(w)
 		return
 	}
@@ -77,12 +77,13 @@ func handleGithubCallback(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var jwt, jwtErr = logic.VerifyAuthRequest(authRequest)
+	logic.RecordAuthEvent(authRequest.UserName, r, "github", jwtErr == nil)
 	if jwtErr != nil {
-		logger.Log(1, "could not parse jwt for user", authRequest.UserName)
+		slog.ErrorCtx(r.Context(), "could not parse jwt for user", "user_name", authRequest.UserName)
 		return
 	}
 
-	logger.Log(1, "completed github OAuth sigin in for", content.Login)
+	slog.InfoCtx(r.Context(), "completed github OAuth sigin in", "login", content.Login)
 	http.Redirect(w, r, servercfg.GetFrontendURL()+"/login?login="+jwt+"&user="+content.Login, http.StatusPermanentRedirect)
 }
 
@@ -8,10 +8,10 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 )
@@ -58,7 +58,7 @@ func handleGoogleCallback(w http.ResponseWriter, r *http.Request) {
 
 	var content, err = getGoogleUserInfo(rState, rCode)
 	if err != nil {
-		logger.Log(1, "error when getting user info from google:", err.Error())
+		slog.ErrorCtx(r.Context(), "error when getting user info from google", "error", err)
 		handleOauthNotConfigured(w)
 		return
 	}
@@ -79,12 +79,13 @@ func handleGoogleCallback(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var jwt, jwtErr = logic.VerifyAuthRequest(authRequest)
+	logic.RecordAuthEvent(authRequest.UserName, r, "google", jwtErr == nil)
 	if jwtErr != nil {
-		logger.Log(1, "could not parse jwt for user", authRequest.UserName)
+		slog.ErrorCtx(r.Context(), "could not parse jwt for user", "user_name", authRequest.UserName)
 		return
 	}
 
-	logger.Log(1, "completed google OAuth sigin in for", content.Email)
+	slog.InfoCtx(r.Context(), "completed google OAuth sigin in", "email", content.Email)
 	http.Redirect(w, r, fmt.Sprintf("%s/login?login=%s&user=%s", servercfg.GetFrontendURL(), jwt, content.Email), http.StatusPermanentRedirect)
 }
 
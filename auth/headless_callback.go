@@ -5,10 +5,10 @@ import (
 	"fmt"
 	"net/http"
 
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/logic/pro/netcache"
 	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
 )
 
 // HandleHeadlessSSOCallback - handle OAuth callback for headless logins such as Netmaker CLI
@@ -17,14 +17,14 @@ func HandleHeadlessSSOCallback(w http.ResponseWriter, r *http.Request) {
 	if functions == nil {
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte("bad conf"))
-		logger.Log(0, "Missing Oauth config in HandleHeadlessSSOCallback")
+		slog.InfoCtx(r.Context(), "Missing Oauth config in HandleHeadlessSSOCallback")
 		return
 	}
 	state, code := getStateAndCode(r)
 
 	userClaims, err := functions[get_user_info].(func(string, string) (*OAuthUser, error))(state, code)
 	if err != nil {
-		logger.Log(0, "error when getting user info from callback:", err.Error())
+		slog.ErrorCtx(r.Context(), "error when getting user info from callback", "error", err)
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte("Failed to retrieve OAuth user claims"))
 		return
@@ -33,7 +33,7 @@ func HandleHeadlessSSOCallback(w http.ResponseWriter, r *http.Request) {
 	if code == "" || state == "" {
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte("Wrong params"))
-		logger.Log(0, "Missing params in HandleHeadlessSSOCallback")
+		slog.InfoCtx(r.Context(), "Missing params in HandleHeadlessSSOCallback")
 		return
 	}
 
@@ -43,7 +43,7 @@ func HandleHeadlessSSOCallback(w http.ResponseWriter, r *http.Request) {
 	// retrieve machinekey from state cache
 	reqKeyIf, machineKeyFoundErr := netcache.Get(state)
 	if machineKeyFoundErr != nil {
-		logger.Log(0, "requested machine state key expired before authorisation completed -", machineKeyFoundErr.Error())
+		slog.ErrorCtx(r.Context(), "requested machine state key expired before authorisation completed", "error", machineKeyFoundErr)
 		response := returnErrTemplate("", "requested machine state key expired before authorisation completed", state, reqKeyIf)
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write(response)
@@ -53,7 +53,7 @@ func HandleHeadlessSSOCallback(w http.ResponseWriter, r *http.Request) {
 	_, err = logic.GetUser(userClaims.getUserName())
 	if err != nil { // user must not exists, so try to make one
 		if err = addUser(userClaims.getUserName()); err != nil {
-			logger.Log(1, "could not create new user: ", userClaims.getUserName())
+			slog.ErrorCtx(r.Context(), "could not create new user", "get_user_name", userClaims.getUserName())
 			return
 		}
 	}
@@ -65,12 +65,13 @@ func HandleHeadlessSSOCallback(w http.ResponseWriter, r *http.Request) {
 		UserName: userClaims.getUserName(),
 		Password: newPass,
 	})
+	logic.RecordAuthEvent(userClaims.getUserName(), r, "headless sso", jwtErr == nil)
 	if jwtErr != nil {
-		logger.Log(1, "could not parse jwt for user", userClaims.getUserName())
+		slog.ErrorCtx(r.Context(), "could not parse jwt for user", "get_user_name", userClaims.getUserName())
 		return
 	}
 
-	logger.Log(1, "headless SSO login by user:", userClaims.getUserName())
+	slog.InfoCtx(r.Context(), "headless SSO login by user", "get_user_name", userClaims.getUserName())
 
 	// Send OK to user in the browser
 	var response bytes.Buffer
@@ -78,7 +79,7 @@ func HandleHeadlessSSOCallback(w http.ResponseWriter, r *http.Request) {
 		User: userClaims.getUserName(),
 		Verb: "Authenticated",
 	}); err != nil {
-		logger.Log(0, "Could not render SSO callback template ", err.Error())
+		slog.ErrorCtx(r.Context(), "Could not render SSO callback template", "error", err)
 		response := returnErrTemplate(userClaims.getUserName(), "Could not render SSO callback template", state, reqKeyIf)
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write(response)
@@ -88,6 +89,6 @@ func HandleHeadlessSSOCallback(w http.ResponseWriter, r *http.Request) {
 	}
 	reqKeyIf.Pass = fmt.Sprintf("JWT: %s", jwt)
 	if err = netcache.Set(state, reqKeyIf); err != nil {
-		logger.Log(0, "failed to set netcache for user", reqKeyIf.User, "-", err.Error())
+		slog.ErrorCtx(r.Context(), "failed to set netcache for user", "user", reqKeyIf.User, "error", err)
 	}
 }
@@ -8,13 +8,13 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/logic/hostactions"
 	"github.com/gravitl/netmaker/logic/pro/netcache"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/mq"
 	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
 )
 
 // SessionHandler - called by the HTTP router when user
@@ -27,17 +27,17 @@ func SessionHandler(conn *websocket.Conn) {
 	// If reached here we have a session from user to handle...
 	messageType, message, err := conn.ReadMessage()
 	if err != nil {
-		logger.Log(0, "Error during message reading:", err.Error())
+		slog.Error("Error during message reading", "error", err)
 		return
 	}
 
 	var registerMessage models.RegisterMsg
 	if err = json.Unmarshal(message, &registerMessage); err != nil {
-		logger.Log(0, "Failed to unmarshall data err=", err.Error())
+		slog.Error("Failed to unmarshall data", "error", err)
 		return
 	}
 	if registerMessage.RegisterHost.ID == uuid.Nil {
-		logger.Log(0, "invalid host registration attempted")
+		slog.Error("invalid host registration attempted")
 		return
 	}
 
@@ -49,13 +49,13 @@ func SessionHandler(conn *websocket.Conn) {
 	req.Pass = ""
 	req.User = registerMessage.User
 	if len(req.User) > 0 && len(registerMessage.Password) == 0 {
-		logger.Log(0, "invalid host registration attempted")
+		slog.Error("invalid host registration attempted")
 		return
 	}
 	// Add any extra parameter provided in the configuration to the Authorize Endpoint request??
 	stateStr := logic.RandomString(node_signin_length)
 	if err := netcache.Set(stateStr, req); err != nil {
-		logger.Log(0, "Failed to process sso request -", err.Error())
+		slog.Error("Failed to process sso request", "error", err)
 		return
 	}
 	// Wait for the user to finish his auth flow...
@@ -65,12 +65,12 @@ func SessionHandler(conn *websocket.Conn) {
 	defer close(timeout)
 
 	if len(registerMessage.User) > 0 { // handle basic auth
-		logger.Log(0, "user registration attempted with host:", registerMessage.RegisterHost.Name, "user:", registerMessage.User)
+		slog.Info("user registration attempted with host user", "register_host_name", registerMessage.RegisterHost.Name, "user", registerMessage.User)
 
 		if !servercfg.IsBasicAuthEnabled() {
 			err = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
 			if err != nil {
-				logger.Log(0, "error during message writing:", err.Error())
+				slog.Error("error during message writing", "error", err)
 			}
 		}
 		_, err := logic.VerifyAuthRequest(models.UserAuthParams{
@@ -80,29 +80,29 @@ func SessionHandler(conn *websocket.Conn) {
 		if err != nil {
 			err = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
 			if err != nil {
-				logger.Log(0, "error during message writing:", err.Error())
+				slog.Error("error during message writing", "error", err)
 			}
 			return
 		}
 		req.Pass = req.Host.ID.String()
 
 		if err = netcache.Set(stateStr, req); err != nil { // give the user's host access in the DB
-			logger.Log(0, "machine failed to complete join on network,", registerMessage.Network, "-", err.Error())
+			slog.Error("machine failed to complete join on network", "network", registerMessage.Network, "error", err)
 			return
 		}
 	} else { // handle SSO / OAuth
 		if auth_provider == nil {
 			err = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
 			if err != nil {
-				logger.Log(0, "error during message writing:", err.Error())
+				slog.Error("error during message writing", "error", err)
 			}
 			return
 		}
-		logger.Log(0, "user registration attempted with host:", registerMessage.RegisterHost.Name, "via SSO")
+		slog.Info("user registration attempted with host via SSO", "register_host_name", registerMessage.RegisterHost.Name)
 		redirectUrl = fmt.Sprintf("https://%s/api/oauth/register/%s", servercfg.GetAPIConnString(), stateStr)
 		err = conn.WriteMessage(messageType, []byte(redirectUrl))
 		if err != nil {
-			logger.Log(0, "error during message writing:", err.Error())
+			slog.Error("error during message writing", "error", err)
 		}
 	}
 
@@ -111,13 +111,13 @@ func SessionHandler(conn *websocket.Conn) {
 			cachedReq, err := netcache.Get(stateStr)
 			if err != nil {
 				if strings.Contains(err.Error(), "expired") {
-					logger.Log(1, "timeout occurred while waiting for SSO registration")
+					slog.Info("timeout occurred while waiting for SSO registration")
 					timeout <- true
 					break
 				}
 				continue
 			} else if len(cachedReq.User) > 0 {
-				logger.Log(0, "host SSO process completed for user", cachedReq.User)
+				slog.Info("host SSO process completed for user", "user", cachedReq.User)
 				answer <- *cachedReq
 				break
 			}
@@ -132,11 +132,11 @@ func SessionHandler(conn *websocket.Conn) {
 		if !logic.HostExists(&result.Host) { // check if host already exists, add if not
 			if servercfg.GetBrokerType() == servercfg.EmqxBrokerType {
 				if err := mq.CreateEmqxUser(result.Host.ID.String(), result.Host.HostPass, false); err != nil {
-					logger.Log(0, "failed to create host credentials for EMQX: ", err.Error())
+					slog.Error("failed to create host credentials for EMQX", "error", err)
 					return
 				}
 				if err := mq.CreateHostACL(result.Host.ID.String(), servercfg.GetServerInfo().Server); err != nil {
-					logger.Log(0, "failed to add host ACL rules to EMQX: ", err.Error())
+					slog.Error("failed to add host ACL rules to EMQX", "error", err)
 					return
 				}
 			}
@@ -174,7 +174,7 @@ func SessionHandler(conn *websocket.Conn) {
 				if len(result.User) > 0 {
 					_, err := isUserIsAllowed(result.User, newNet, false)
 					if err != nil {
-						logger.Log(0, "unauthorized user", result.User, "attempted to register to network", newNet)
+						slog.Info("unauthorized user attempted to register to network", "user", result.User, "new_net", newNet)
 						handleHostRegErr(conn, err)
 						return
 					}
@@ -200,38 +200,39 @@ func SessionHandler(conn *websocket.Conn) {
 			return
 		}
 		if err = conn.WriteMessage(messageType, reponseData); err != nil {
-			logger.Log(0, "error during message writing:", err.Error())
+			slog.Error("error during message writing", "error", err)
 		}
-		go CheckNetRegAndHostUpdate(netsToAdd[:], &result.Host)
+		go CheckNetRegAndHostUpdate(netsToAdd[:], &result.Host, nil)
 	case <-timeout: // the read from req.answerCh has timed out
 		if err = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")); err != nil {
-			logger.Log(0, "error during timeout message writing:", err.Error())
+			slog.Error("error during timeout message writing", "error", err)
 		}
 	}
 	// The entry is not needed anymore, but we will let the producer to close it to avoid panic cases
 	if err = netcache.Del(stateStr); err != nil {
-		logger.Log(0, "failed to remove node SSO cache entry", err.Error())
+		slog.Error("failed to remove node SSO cache entry", "error", err)
 	}
 	// Cleanly close the connection by sending a close message and then
 	// waiting (with timeout) for the server to close the connection.
 	if err = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")); err != nil {
-		logger.Log(0, "write close:", err.Error())
+		slog.Error("write close", "error", err)
 		return
 	}
 }
 
-// CheckNetRegAndHostUpdate - run through networks and send a host update
-func CheckNetRegAndHostUpdate(networks []string, h *models.Host) {
+// CheckNetRegAndHostUpdate - run through networks and send a host update,
+// the nodes added take the tags and ephemeral class of key if any
+func CheckNetRegAndHostUpdate(networks []string, h *models.Host, key *models.EnrollmentKey) {
 	// publish host update through MQ
 	for i := range networks {
 		network := networks[i]
 		if ok, _ := logic.NetworkExists(network); ok {
-			newNode, err := logic.UpdateHostNetwork(h, network, true)
+			newNode, err := logic.AddHostToNetwork(h, network, key)
 			if err != nil {
-				logger.Log(0, "failed to add host to network:", h.ID.String(), h.Name, network, err.Error())
+				slog.Error("failed to add host to network", "h_id", h.ID.String(), "h_name", h.Name, "network", network, "error", err)
 				continue
 			}
-			logger.Log(1, "added new node", newNode.ID.String(), "to host", h.Name)
+			slog.Info("added new node to host", "new_node_id", newNode.ID.String(), "h_name", h.Name)
 			hostactions.AddAction(models.HostUpdate{
 				Action: models.JoinHostToNetwork,
 				Host:   *h,
@@ -244,8 +245,8 @@ func CheckNetRegAndHostUpdate(networks []string, h *models.Host) {
 			Action: models.RequestAck,
 			Host:   *h,
 		})
-		if err := mq.PublishPeerUpdate(); err != nil {
-			logger.Log(0, "failed to publish peer update during registration -", err.Error())
+		if err := mq.PublishHostPeerUpdate(h); err != nil {
+			slog.Error("failed to publish peer update during registration", "error", err)
 		}
 	}
 }
@@ -253,6 +254,6 @@ func CheckNetRegAndHostUpdate(networks []string, h *models.Host) {
 func handleHostRegErr(conn *websocket.Conn, err error) {
 	_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
 	if err != nil {
-		logger.Log(0, "error during host registration via auth:", err.Error())
+		slog.Error("error during host registration via auth", "error", err)
 	}
 }
@@ -7,10 +7,10 @@ import (
 	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
 	"golang.org/x/oauth2"
 )
 
@@ -26,6 +26,9 @@ var oidc_functions = map[string]interface{}{
 
 var oidc_verifier *oidc.IDTokenVerifier
 
+// oidc_device_auth_url - the device authorization endpoint of the provider, empty when it has none
+var oidc_device_auth_url string
+
 // == handle OIDC authentication here ==
 
 func initOIDC(redirectURL string, clientID string, clientSecret string, issuer string) {
@@ -34,10 +37,18 @@ func initOIDC(redirectURL string, clientID string, clientSecret string, issuer s
 
 	provider, err := oidc.NewProvider(ctx, issuer)
 	if err != nil {
-		logger.Log(1, "error when initializing OIDC provider with issuer \""+issuer+"\"", err.Error())
+		slog.Error("error when initializing OIDC provider with issuer \""+issuer+"\"", "error", err)
 		return
 	}
 
+	// the device flow of remote access clients is only offered when the provider supports it
+	var deviceClaims struct {
+		DeviceAuthURL string `json:"device_authorization_endpoint"`
+	}
+	if err = provider.Claims(&deviceClaims); err == nil {
+		oidc_device_auth_url = deviceClaims.DeviceAuthURL
+	}
+
 	oidc_verifier = provider.Verifier(&oidc.Config{ClientID: clientID})
 	auth_provider = &oauth2.Config{
 		ClientID:     clientID,
@@ -69,7 +80,7 @@ func handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
 
 	var content, err = getOIDCUserInfo(rState, rCode)
 	if err != nil {
-		logger.Log(1, "error when getting user info from callback:", err.Error())
+		slog.ErrorCtx(r.Context(), "error when getting user info from callback", "error", err)
 		handleOauthNotConfigured(w)
 		return
 	}
@@ -90,19 +101,20 @@ func handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var jwt, jwtErr = logic.VerifyAuthRequest(authRequest)
+	logic.RecordAuthEvent(authRequest.UserName, r, "oidc", jwtErr == nil)
 	if jwtErr != nil {
-		logger.Log(1, "could not parse jwt for user", authRequest.UserName, jwtErr.Error())
+		slog.ErrorCtx(r.Context(), "could not parse jwt for user", "user_name", authRequest.UserName, "error", jwtErr)
 		return
 	}
 
-	logger.Log(1, "completed OIDC OAuth signin in for", content.Email)
+	slog.InfoCtx(r.Context(), "completed OIDC OAuth signin in", "email", content.Email)
 	http.Redirect(w, r, servercfg.GetFrontendURL()+"/login?login="+jwt+"&user="+content.Email, http.StatusPermanentRedirect)
 }
 
 func getOIDCUserInfo(state string, code string) (u *OAuthUser, e error) {
 	oauth_state_string, isValid := logic.IsStateValid(state)
-	logger.Log(3, "using oauth state string:,", oauth_state_string)
-	logger.Log(3, "            state string:,", state)
+	slog.Debug("using oauth state string", "oauth_state_string", oauth_state_string)
+	slog.Debug("state string", "state", state)
 	if (!isValid || state != oauth_state_string) && !isStateCached(state) {
 		return nil, fmt.Errorf("invalid oauth state")
 	}
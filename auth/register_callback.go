@@ -7,12 +7,12 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/logic/pro"
 	"github.com/gravitl/netmaker/logic/pro/netcache"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/models/promodels"
+	"golang.org/x/exp/slog"
 )
 
 var (
@@ -31,7 +31,7 @@ func HandleHostSSOCallback(w http.ResponseWriter, r *http.Request) {
 	if functions == nil {
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte("bad conf"))
-		logger.Log(0, "Missing Oauth config in HandleNodeSSOCallback")
+		slog.InfoCtx(r.Context(), "Missing Oauth config in HandleNodeSSOCallback")
 		return
 	}
 
@@ -39,7 +39,7 @@ func HandleHostSSOCallback(w http.ResponseWriter, r *http.Request) {
 
 	var userClaims, err = functions[get_user_info].(func(string, string) (*OAuthUser, error))(state, code)
 	if err != nil {
-		logger.Log(0, "error when getting user info from callback:", err.Error())
+		slog.ErrorCtx(r.Context(), "error when getting user info from callback", "error", err)
 		handleOauthNotConfigured(w)
 		return
 	}
@@ -47,7 +47,7 @@ func HandleHostSSOCallback(w http.ResponseWriter, r *http.Request) {
 	if code == "" || state == "" {
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte("Wrong params"))
-		logger.Log(0, "Missing params in HandleSSOCallback")
+		slog.InfoCtx(r.Context(), "Missing params in HandleSSOCallback")
 		return
 	}
 
@@ -57,7 +57,7 @@ func HandleHostSSOCallback(w http.ResponseWriter, r *http.Request) {
 	// retrieve machinekey from state cache
 	reqKeyIf, machineKeyFoundErr := netcache.Get(state)
 	if machineKeyFoundErr != nil {
-		logger.Log(0, "requested machine state key expired before authorisation completed -", machineKeyFoundErr.Error())
+		slog.ErrorCtx(r.Context(), "requested machine state key expired before authorisation completed", "error", machineKeyFoundErr)
 		reqKeyIf = &netcache.CValue{
 			Network:    "invalid",
 			Value:      state,
@@ -71,7 +71,7 @@ func HandleHostSSOCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	logger.Log(1, "registering host for user:", userClaims.getUserName(), reqKeyIf.Host.Name, reqKeyIf.Host.ID.String())
+	slog.InfoCtx(r.Context(), "registering host for user", "get_user_name", userClaims.getUserName(), "host_name", reqKeyIf.Host.Name, "host_id", reqKeyIf.Host.ID.String())
 
 	// Send OK to user in the browser
 	var response bytes.Buffer
@@ -79,7 +79,7 @@ func HandleHostSSOCallback(w http.ResponseWriter, r *http.Request) {
 		User: userClaims.getUserName(),
 		Verb: "Authenticated",
 	}); err != nil {
-		logger.Log(0, "Could not render SSO callback template ", err.Error())
+		slog.ErrorCtx(r.Context(), "Could not render SSO callback template", "error", err)
 		response := returnErrTemplate(reqKeyIf.User, "Could not render SSO callback template", state, reqKeyIf)
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write(response)
@@ -90,7 +90,7 @@ func HandleHostSSOCallback(w http.ResponseWriter, r *http.Request) {
 
 	reqKeyIf.User = userClaims.getUserName() // set the cached registering hosts' user
 	if err = netcache.Set(state, reqKeyIf); err != nil {
-		logger.Log(0, "machine failed to complete join on network,", reqKeyIf.Network, "-", err.Error())
+		slog.ErrorCtx(r.Context(), "machine failed to complete join on network", "network", reqKeyIf.Network, "error", err)
 		return
 	}
 }
@@ -101,7 +101,7 @@ func setNetcache(ncache *netcache.CValue, state string) error {
 	}
 	var err error
 	if err = netcache.Set(state, ncache); err != nil {
-		logger.Log(0, "machine failed to complete join on network,", ncache.Network, "-", err.Error())
+		slog.Error("machine failed to complete join on network", "network", ncache.Network, "error", err)
 	}
 	return err
 }
@@ -155,31 +155,31 @@ func isUserIsAllowed(username, network string, shouldAddUser bool) (*models.User
 	user, err := logic.GetUser(username)
 	if err != nil && shouldAddUser { // user must not exist, so try to make one
 		if err = addUser(username); err != nil {
-			logger.Log(0, "failed to add user", username, "during a node SSO network join on network", network)
+			slog.Error("failed to add user during a node SSO network join on network", "username", username, "network", network)
 			// response := returnErrTemplate(user.UserName, "failed to add user", state, reqKeyIf)
 			// w.WriteHeader(http.StatusInternalServerError)
 			// w.Write(response)
 			return nil, fmt.Errorf("failed to add user to system")
 		}
-		logger.Log(0, "user", username, "was added during a node SSO network join on network", network)
+		slog.Info("user was added during a node SSO network join on network", "username", username, "network", network)
 		user, _ = logic.GetUser(username)
 	}
 
 	if !user.IsAdmin { // perform check to see if user is allowed to join a node to network
 		netUser, err := pro.GetNetworkUser(network, promodels.NetworkUserID(user.UserName))
 		if err != nil {
-			logger.Log(0, "failed to get net user details for user", user.UserName, "during node SSO")
+			slog.Error("failed to get net user details for user during node SSO", "user_name", user.UserName)
 			return nil, fmt.Errorf("failed to verify network user")
 		}
 		if netUser.AccessLevel != pro.NET_ADMIN { // if user is a net admin on network, good to go
 			// otherwise, check if they have node access + haven't reached node limit on network
 			if netUser.AccessLevel == pro.NODE_ACCESS {
 				if len(netUser.Nodes) >= netUser.NodeLimit {
-					logger.Log(0, "user", user.UserName, "has reached their node limit on network", network)
+					slog.Info("user has reached their node limit on network", "user_name", user.UserName, "network", network)
 					return nil, fmt.Errorf("user node limit exceeded")
 				}
 			} else {
-				logger.Log(0, "user", user.UserName, "attempted to access network", network, "via node SSO")
+				slog.Info("user attempted to access network via node SSO", "user_name", user.UserName, "network", network)
 				return nil, fmt.Errorf("network user not allowed")
 			}
 		}
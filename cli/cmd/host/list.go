@@ -1,20 +1,38 @@
 package host
 
 import (
+	"strconv"
+
 	"github.com/gravitl/netmaker/cli/functions"
 	"github.com/spf13/cobra"
 )
 
+var watch bool
+
 var hostListCmd = &cobra.Command{
 	Use:   "list",
 	Args:  cobra.NoArgs,
 	Short: "List all hosts",
-	Long:  `List all hosts`,
+	Long:  `List all hosts, or keep streaming host changes after listing them with --watch`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if !watch {
+			functions.PrettyPrint(functions.GetHosts())
+			return
+		}
+		// take the version before listing so no change in between is missed
+		version := functions.WatchHosts("").ResourceVersion
 		functions.PrettyPrint(functions.GetHosts())
+		for {
+			res := functions.WatchHosts(strconv.FormatInt(version, 10))
+			for _, event := range res.Events {
+				functions.PrettyPrint(event)
+			}
+			version = res.ResourceVersion
+		}
 	},
 }
 
 func init() {
+	hostListCmd.Flags().BoolVar(&watch, "watch", false, "Stream host changes after listing")
 	rootCmd.AddCommand(hostListCmd)
 }
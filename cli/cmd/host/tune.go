@@ -0,0 +1,55 @@
+package host
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gravitl/netmaker/cli/functions"
+	"github.com/gravitl/netmaker/models"
+	"github.com/spf13/cobra"
+)
+
+var (
+	tuneListenPort int
+	tuneStun       string
+	peerKeepalives []string
+)
+
+var hostTuneCmd = &cobra.Command{
+	Use:   "tune HostID",
+	Args:  cobra.ExactArgs(1),
+	Short: "Get or set the WireGuard settings of a host",
+	Long: `Get the listen port, STUN setting and per peer keepalives of a host, or set them when flags are given.
+The changes are pushed to the host, no need to edit its netclient config`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if cmd.Flags().NFlag() == 0 {
+			functions.PrettyPrint(functions.GetHostTuning(args[0]))
+			return
+		}
+		tuning := &models.HostTuning{
+			ListenPort: tuneListenPort,
+			Stun:       tuneStun,
+		}
+		if cmd.Flags().Changed("keepalive") {
+			tuning.PeerKeepalives = make(map[string]int)
+			for _, keepalive := range peerKeepalives {
+				peerHostID, seconds, found := strings.Cut(keepalive, "=")
+				value, err := strconv.Atoi(seconds)
+				if !found || err != nil {
+					log.Fatalf("invalid keepalive %q, expected PEER_HOST_ID=SECONDS", keepalive)
+				}
+				tuning.PeerKeepalives[peerHostID] = value
+			}
+		}
+		functions.PrettyPrint(functions.TuneHost(args[0], tuning))
+	},
+}
+
+func init() {
+	hostTuneCmd.Flags().IntVar(&tuneListenPort, "listen_port", 0, "WireGuard listen port of the host")
+	hostTuneCmd.Flags().StringVar(&tuneStun, "stun", "", "Discover the public endpoint through STUN (yes, no or default)")
+	hostTuneCmd.Flags().StringSliceVar(&peerKeepalives, "keepalive", nil,
+		"Keepalive for a peer host as PEER_HOST_ID=SECONDS, repeatable; pass an empty value to clear them all")
+	rootCmd.AddCommand(hostTuneCmd)
+}
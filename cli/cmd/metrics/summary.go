@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"github.com/gravitl/netmaker/cli/functions"
+	"github.com/spf13/cobra"
+)
+
+var metricsSummaryCmd = &cobra.Command{
+	Use:   "summary [NETWORK NAME]",
+	Args:  cobra.MaximumNArgs(1),
+	Short: "Retrieve aggregated metrics",
+	Long:  `Retrieve latency percentiles, the worst links and gateway load of a network, or of all networks`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 1 {
+			functions.PrettyPrint(functions.GetNetworkMetricsSummary(args[0]))
+			return
+		}
+		functions.PrettyPrint(functions.GetMetricsSummaries())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(metricsSummaryCmd)
+}
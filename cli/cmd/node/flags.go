@@ -18,4 +18,5 @@ var (
 	defaultACL             bool
 	dnsOn                  bool
 	disconnect             bool
+	watch                  bool
 )
@@ -1,12 +1,12 @@
 package node
 
 import (
+	"fmt"
 	"os"
 	"strconv"
 
 	"github.com/gravitl/netmaker/cli/cmd/commons"
 	"github.com/gravitl/netmaker/cli/functions"
-	"github.com/gravitl/netmaker/models"
 	"github.com/guumaster/tablewriter"
 	"github.com/spf13/cobra"
 )
@@ -16,14 +16,18 @@ var nodeListCmd = &cobra.Command{
 	Use:   "list",
 	Args:  cobra.NoArgs,
 	Short: "List all nodes",
-	Long:  `List all nodes`,
+	Long:  `List all nodes, or keep streaming node changes after listing them with --watch`,
 	Run: func(cmd *cobra.Command, args []string) {
-		var data []models.ApiNode
+		var networks []string
 		if networkName != "" {
-			data = *functions.GetNodes(networkName)
-		} else {
-			data = *functions.GetNodes()
+			networks = append(networks, networkName)
 		}
+		// take the version before listing so no change in between is missed
+		var version int64
+		if watch {
+			version = functions.WatchNodes("", networks...).ResourceVersion
+		}
+		data := *functions.GetNodes(networks...)
 		switch commons.OutputFormat {
 		case commons.JsonOutput:
 			functions.PrettyPrint(data)
@@ -46,10 +50,22 @@ var nodeListCmd = &cobra.Command{
 			}
 			table.Render()
 		}
+		for watch {
+			res := functions.WatchNodes(strconv.FormatInt(version, 10), networks...)
+			for _, event := range res.Events {
+				if commons.OutputFormat == commons.JsonOutput {
+					functions.PrettyPrint(event)
+				} else {
+					fmt.Println(event.Type, event.ID)
+				}
+			}
+			version = res.ResourceVersion
+		}
 	},
 }
 
 func init() {
 	nodeListCmd.Flags().StringVar(&networkName, "network", "", "Network name specifier")
+	nodeListCmd.Flags().BoolVar(&watch, "watch", false, "Stream node changes after listing")
 	rootCmd.AddCommand(nodeListCmd)
 }
@@ -26,6 +26,16 @@ func UpdateHost(hostID string, body *models.ApiHost) *models.ApiHost {
 	return request[models.ApiHost](http.MethodPut, "/api/hosts/"+hostID, body)
 }
 
+// GetHostTuning - fetch the WireGuard settings of a host
+func GetHostTuning(hostID string) *models.HostTuning {
+	return request[models.HostTuning](http.MethodGet, "/api/hosts/"+hostID+"/tuning", nil)
+}
+
+// TuneHost - set the WireGuard settings of a host
+func TuneHost(hostID string, body *models.HostTuning) *models.HostTuning {
+	return request[models.HostTuning](http.MethodPut, "/api/hosts/"+hostID+"/tuning", body)
+}
+
 // AddHostToNetwork - add a network to host
 func AddHostToNetwork(hostID, network string) *hostNetworksUpdatePayload {
 	return request[hostNetworksUpdatePayload](http.MethodPost, "/api/hosts/"+hostID+"/networks/"+network, nil)
@@ -58,3 +68,8 @@ func RefreshKeys(hostID string) any {
 	return request[any](http.MethodPut, fmt.Sprintf("/api/hosts/%s/keys", hostID), nil)
 
 }
+
+// WatchHosts - waits for host changes after resourceVersion, an empty resourceVersion returns the current one
+func WatchHosts(resourceVersion string) *models.WatchResponse {
+	return request[models.WatchResponse](http.MethodGet, watchRoute("/api/hosts", resourceVersion), nil)
+}
@@ -188,3 +188,11 @@ func get(route string) string {
 	}
 	return string(bodyBytes)
 }
+
+func watchRoute(route, resourceVersion string) string {
+	route += "?watch=true"
+	if resourceVersion != "" {
+		route += "&resourceVersion=" + resourceVersion
+	}
+	return route
+}
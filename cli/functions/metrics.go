@@ -26,3 +26,13 @@ func GetAllMetrics() *models.NetworkMetrics {
 func GetNetworkExtMetrics(networkName string) *map[string]models.Metric {
 	return request[map[string]models.Metric](http.MethodGet, "/api/metrics-ext/"+networkName, nil)
 }
+
+// GetNetworkMetricsSummary - fetch the aggregated metrics of a network
+func GetNetworkMetricsSummary(networkName string) *models.NetworkMetricsSummary {
+	return request[models.NetworkMetricsSummary](http.MethodGet, "/api/metrics/summary/"+networkName, nil)
+}
+
+// GetMetricsSummaries - fetch the aggregated metrics of all networks
+func GetMetricsSummaries() *[]models.NetworkMetricsSummary {
+	return request[[]models.NetworkMetricsSummary](http.MethodGet, "/api/metrics/summary", nil)
+}
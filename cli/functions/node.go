@@ -57,3 +57,12 @@ func DeleteIngress(networkName, nodeID string) *models.ApiNode {
 func UncordonNode(networkName, nodeID string) *string {
 	return request[string](http.MethodPost, fmt.Sprintf("/api/nodes/%s/%s/approve", networkName, nodeID), nil)
 }
+
+// WatchNodes - waits for node changes after resourceVersion, an empty resourceVersion returns the current one
+func WatchNodes(resourceVersion string, networkName ...string) *models.WatchResponse {
+	route := "/api/nodes"
+	if len(networkName) == 1 {
+		route += "/" + networkName[0]
+	}
+	return request[models.WatchResponse](http.MethodGet, watchRoute(route, resourceVersion), nil)
+}
@@ -88,6 +88,90 @@ type ServerConfig struct {
 	EgressesLimit              int    `yaml:"egresses_limit"`
 	DeployedByOperator         bool   `yaml:"deployed_by_operator"`
 	Environment                string `yaml:"environment"`
+	FlowCollector              string `yaml:"flow_collector"`
+	FlowExportProtocol         string `yaml:"flow_export_protocol"`
+	OTLPEndpoint               string `yaml:"otlp_endpoint"`
+	OTLPInsecure               bool   `yaml:"otlp_insecure"`
+	TracingSampleRatio         string `yaml:"tracing_sample_ratio"`
+	LogFormat                  string `yaml:"log_format"`
+	LogLevels                  string `yaml:"log_levels"`
+	EtcdEndpoints              string `yaml:"etcd_endpoints"`
+	EtcdUsername               string `yaml:"etcd_username"`
+	EtcdPassword               string `yaml:"etcd_password"`
+	EncryptionKeyURI           string `yaml:"encryption_key_uri"`
+	PrevEncryptionKeyURI       string `yaml:"prev_encryption_key_uri"`
+	ACMEEmail                  string `yaml:"acme_email"`
+	ACMEDomains                string `yaml:"acme_domains"`
+	ACMEChallenge              string `yaml:"acme_challenge"`
+	ACMEDNSProvider            string `yaml:"acme_dns_provider"`
+	ACMECADirectory            string `yaml:"acme_ca_directory"`
+	ACMECertDir                string `yaml:"acme_cert_dir"`
+	DefaultMTU                 int    `yaml:"default_mtu"`
+	RateLimit                  string `yaml:"rate_limit"`
+	RateLimitBurst             int    `yaml:"rate_limit_burst"`
+	SMTPHost                   string `yaml:"smtp_host"`
+	SMTPPort                   int    `yaml:"smtp_port"`
+	SMTPUsername               string `yaml:"smtp_username"`
+	SMTPPassword               string `yaml:"smtp_password"`
+	SMTPFrom                   string `yaml:"smtp_from"`
+	ShutdownGracePeriod        int    `yaml:"shutdown_grace_period"`
+	ShutdownDrainDelay         int    `yaml:"shutdown_drain_delay"`
+	CloudEnrollmentAudience    string `yaml:"cloud_enrollment_audience"`
+	AWSIdentityCertFile        string `yaml:"aws_identity_cert_file"`
+	SIEMAddress                string `yaml:"siem_address"`
+	SIEMProtocol               string `yaml:"siem_protocol"`
+	SIEMFormat                 string `yaml:"siem_format"`
+	SIEMCACert                 string `yaml:"siem_ca_cert"`
+	PublishWorkers             int    `yaml:"publish_workers"`
+	MQSharedGroup              string `yaml:"mq_shared_group"`
+	MQPartitions               int    `yaml:"mq_partitions"`
+	MQPartition                int    `yaml:"mq_partition"`
+	Profiling                  string `yaml:"profiling"`
+	MigrationBackupDir         string `yaml:"migration_backup_dir"`
+	RecoveryAccess             string `yaml:"recovery_access"`
+	ChangeApproval             string `yaml:"change_approval"`
+	ChangeApprovalExpiry       int    `yaml:"change_approval_expiry"`
+	TrashRetention             string `yaml:"trash_retention"`
+	// RACClientTTL - hours the ext client config of a remote access client lasts before it must be renewed
+	RACClientTTL string `yaml:"rac_client_ttl"`
+	// ExtClientKeyTTL - hours the keys of user owned ext clients stay valid before they must be renewed, unset never expires them
+	ExtClientKeyTTL string `yaml:"extclient_key_ttl"`
+	// GeoIPFile - csv of cidr,country code lines the source policies of users look countries up in
+	GeoIPFile string `yaml:"geoip_file"`
+	// SecurityAlertWebhook - url security alerts are posted to
+	SecurityAlertWebhook string `yaml:"security_alert_webhook"`
+	// HostAuthMode - token for host auth tokens, or certificate for client certificates issued by the server's host CA
+	HostAuthMode string `yaml:"host_auth_mode"`
+	// HostCertHeader - header a TLS terminating proxy forwards the verified client certificate in, URL escaped PEM,
+	// only read from the TRUSTED_PROXIES
+	HostCertHeader string `yaml:"host_cert_header"`
+	// APIAllowlist - comma separated source cidrs users may use the management api from, empty allows any
+	APIAllowlist string `yaml:"api_allowlist"`
+	// TrustedProxies - comma separated cidrs of the reverse proxies whose X-Forwarded-For is trusted, empty trusts none
+	TrustedProxies string `yaml:"trusted_proxies"`
+	// ContentSecurityPolicy - Content-Security-Policy header of API responses, without frame-ancestors
+	ContentSecurityPolicy string `yaml:"content_security_policy"`
+	// FrameAncestors - comma separated origins allowed to embed the dashboard in a frame, empty allows none
+	FrameAncestors string `yaml:"frame_ancestors"`
+	// LoginNotifications - on to email users about logins from new devices and new ext clients
+	LoginNotifications string `yaml:"login_notifications"`
+	// EmailVerification - on to keep users created through the api pending until they verify their email
+	EmailVerification string `yaml:"email_verification"`
+	// OPAURL - decision endpoint of an Open Policy Agent policy consulted on every authorized API request, empty disables it
+	OPAURL string `yaml:"opa_url"`
+	// OPAToken - bearer token sent to the Open Policy Agent endpoint
+	OPAToken string `yaml:"opa_token"`
+	// OPAFailOpen - on to let API requests through when the Open Policy Agent endpoint can't be reached
+	OPAFailOpen string `yaml:"opa_fail_open"`
+	// OPABreakGlass - on to let super admins through when the Open Policy Agent endpoint can't be reached and fails closed,
+	// so the endpoint or the settings can be fixed; read from the environment and config only, never from stored settings
+	OPABreakGlass string `yaml:"opa_break_glass"`
+	// ExtensionsDir - directory of Go plugins loaded as server extensions on startup, empty loads none
+	ExtensionsDir string `yaml:"extensions_dir"`
+	// JobIntervals - comma separated name=duration pairs overriding how often background jobs run, eg. trash_purge=30m
+	JobIntervals string `yaml:"job_intervals"`
+	// DriftAutoCorrect - on to have hosts whose applied config drifted from the expected one pull it again
+	DriftAutoCorrect string `yaml:"drift_auto_correct"`
 }
 
 // SQLConfig - Generic SQL Config
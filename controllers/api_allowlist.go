@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+func apiAllowlistHandlers(r *mux.Router) {
+	r.HandleFunc("/api/users/{username}/apiallowlist", logic.SecurityCheck(true, http.HandlerFunc(getAPIAllowlist))).Methods(http.MethodGet)
+	r.HandleFunc("/api/users/{username}/apiallowlist", logic.SecurityCheck(true, http.HandlerFunc(setAPIAllowlist))).Methods(http.MethodPut)
+	r.HandleFunc("/api/users/{username}/apiallowlist", logic.SecurityCheck(true, http.HandlerFunc(deleteAPIAllowlist))).Methods(http.MethodDelete)
+}
+
+// swagger:route GET /api/users/{username}/apiallowlist user getAPIAllowlist
+//
+// Get the source cidrs a user may use the management api from.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: apiAllowlistResponse
+func getAPIAllowlist(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	allowlist, err := logic.GetAPIAllowlist(username)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("user has no api allowlist"), "notfound"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnJSONResponse(w, r, allowlist)
+}
+
+// swagger:route PUT /api/users/{username}/apiallowlist user setAPIAllowlist
+//
+// Restrict the source cidrs a user may use the management api from, on top of the server's allowlist.
+// Hosts are not affected. A user recovered with the break-glass token skips the allowlists for an hour.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: apiAllowlistResponse
+func setAPIAllowlist(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	if _, err := logic.GetUser(username); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	var allowlist models.APIAllowlist
+	if err := json.NewDecoder(r.Body).Decode(&allowlist); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	allowlist.User = username
+	if username == r.Header.Get("user") && !logic.IsAPISourceAllowed(allowlist.CIDRs, r) {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(logic.ErrAllowlistLockout, "badrequest"))
+		return
+	}
+	if err := logic.SetAPIAllowlist(&allowlist); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to set api allowlist of user", "user", r.Header.Get("user"), "username", username, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "set api allowlist of user", "user", r.Header.Get("user"), "username", username)
+	logic.ReturnJSONResponse(w, r, allowlist)
+}
+
+// swagger:route DELETE /api/users/{username}/apiallowlist user deleteAPIAllowlist
+//
+// Let a user use the management api from anywhere the server's allowlist permits.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: successResponse
+func deleteAPIAllowlist(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	if err := logic.DeleteAPIAllowlist(username); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "deleted api allowlist of user", "user", r.Header.Get("user"), "username", username)
+	logic.ReturnSuccessResponse(w, r, "deleted api allowlist of "+username)
+}
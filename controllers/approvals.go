@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/logic/acls"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
+)
+
+func approvalHandlers(r *mux.Router) {
+	r.HandleFunc("/api/approvals", logic.SecurityCheck(true, http.HandlerFunc(getApprovalRequests))).Methods(http.MethodGet)
+	r.HandleFunc("/api/approvals/{id}/approve", logic.SecurityCheck(true, http.HandlerFunc(approveRequest))).Methods(http.MethodPost)
+	r.HandleFunc("/api/approvals/{id}/reject", logic.SecurityCheck(true, http.HandlerFunc(rejectRequest))).Methods(http.MethodPost)
+}
+
+// swagger:route GET /api/approvals approvals getApprovalRequests
+//
+// List the sensitive operations requested while change approval is on, newest first.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: approvalRequestsResponse
+func getApprovalRequests(w http.ResponseWriter, r *http.Request) {
+	requests, err := logic.GetApprovalRequests(r.Header.Get("tenant"))
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnJSONResponse(w, r, requests)
+}
+
+// swagger:route POST /api/approvals/{id}/approve approvals approveRequest
+//
+// Approve and apply a sensitive operation requested by another admin.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: approvalRequestResponse
+func approveRequest(w http.ResponseWriter, r *http.Request) {
+	decideApprovalRequest(w, r, true)
+}
+
+// swagger:route POST /api/approvals/{id}/reject approvals rejectRequest
+//
+// Reject a sensitive operation, the admin who requested it may reject it to withdraw it.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: approvalRequestResponse
+func rejectRequest(w http.ResponseWriter, r *http.Request) {
+	decideApprovalRequest(w, r, false)
+}
+
+func decideApprovalRequest(w http.ResponseWriter, r *http.Request, approve bool) {
+	request, err := logic.GetApprovalRequest(mux.Vars(r)["id"])
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("approval request not found"), "notfound"))
+		return
+	}
+	tenant := r.Header.Get("tenant")
+	if tenant != "" && request.Tenant != tenant {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(logic.ErrTenantMismatch, "forbidden"))
+		return
+	}
+	if approve && request.Operation == models.ApprovalGrantSuperAdmin && tenant != "" {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("only a superadmin can approve another superadmin"), "forbidden"))
+		return
+	}
+	user := r.Header.Get("user")
+	request, err = logic.DecideApprovalRequest(request.ID, user, approve)
+	if err != nil {
+		if errors.Is(err, logic.ErrSelfApproval) || errors.Is(err, logic.ErrApprovalDecided) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "forbidden"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "decided approval request", "user", user, "id", request.ID, "operation", request.Operation, "target", request.Target, "requested_by", request.RequestedBy, "status", request.Status)
+	if approve {
+		if err = applyApprovalRequest(&request); err != nil {
+			slog.ErrorCtx(r.Context(), "failed to apply approved operation", "id", request.ID, "operation", request.Operation, "target", request.Target, "error", err)
+			if saveErr := logic.SetApprovalError(&request, err); saveErr != nil {
+				slog.ErrorCtx(r.Context(), "failed to record approval error", "id", request.ID, "error", saveErr)
+			}
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+	}
+	logic.ReturnJSONResponse(w, r, request)
+}
+
+// holdForApproval - when change approval is on, stores a sensitive operation for another admin to approve
+// and answers with 202, true if it was held
+func holdForApproval(w http.ResponseWriter, r *http.Request, request *models.ApprovalRequest) bool {
+	if !servercfg.IsChangeApprovalEnabled() {
+		return false
+	}
+	request.RequestedBy = r.Header.Get("user")
+	request.Tenant = r.Header.Get("tenant")
+	if err := logic.RequestApproval(request); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return true
+	}
+	slog.InfoCtx(r.Context(), "operation held for approval", "user", request.RequestedBy, "id", request.ID, "operation", request.Operation, "target", request.Target)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(request)
+	return true
+}
+
+// applyApprovalRequest - applies an approved operation as the admin who requested it
+func applyApprovalRequest(request *models.ApprovalRequest) error {
+	switch request.Operation {
+	case models.ApprovalDeleteNetwork:
+		return deleteNetworkToTrash(request.Target, request.RequestedBy)
+	case models.ApprovalDenyAllACL:
+		var change acls.ACLContainer
+		if err := json.Unmarshal(request.Payload, &change); err != nil {
+			return err
+		}
+		_, err := saveNetworkACL(request.Target, request.RequestedBy, change)
+		return err
+	case models.ApprovalGrantSuperAdmin:
+		user, err := logic.GetUser(request.Target)
+		if err != nil {
+			return err
+		}
+		if user.Tenant != "" {
+			return fmt.Errorf("user %s belongs to tenant %s", user.UserName, user.Tenant)
+		}
+		_, err = logic.UpdateUser(&models.User{IsAdmin: true}, user)
+		return err
+	}
+	return fmt.Errorf("unknown operation %s", request.Operation)
+}
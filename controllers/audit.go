@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+// defaultAuditPeriod - how far back the audit log is read when no from date is given
+const defaultAuditPeriod = 30 * 24 * time.Hour
+
+func auditHandlers(r *mux.Router) {
+	r.HandleFunc("/api/audit", logic.SecurityCheck(true, http.HandlerFunc(getAuditLogs))).Methods(http.MethodGet)
+	r.HandleFunc("/api/export/{resource}", logic.SecurityCheck(true, http.HandlerFunc(getExport))).Methods(http.MethodGet)
+}
+
+// swagger:route GET /api/audit audit getAuditLogs
+//
+// Lists the changes users made through the api between from and to (YYYY-MM-DD), the last 30 days by default.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: auditLogsResponse
+func getAuditLogs(w http.ResponseWriter, r *http.Request) {
+	from, to, err := auditPeriod(r)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	entries, err := logic.GetAuditLogs(from, to, r.Header.Get("tenant"))
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to fetch audit logs", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	writeList(w, r, entries)
+}
+
+// swagger:route GET /api/export/{resource} audit getExport
+//
+// Exports networks, nodes, acls, users or the audit log as compliance evidence.
+// Accepts format (json or csv) and, for the audit log, from and to (YYYY-MM-DD).
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: exportResponse
+func getExport(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("invalid format, must be json or csv"), "badrequest"))
+		return
+	}
+	from, to, err := auditPeriod(r)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	export, err := logic.GetExport(mux.Vars(r)["resource"], r.Header.Get("tenant"), from, to)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to export", "user", r.Header.Get("user"), "vars", mux.Vars(r)["resource"], "error", err)
+		if errors.Is(err, logic.ErrInvalidExport) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "exported", "user", r.Header.Get("user"), "resource", export.Resource)
+	if format == "csv" {
+		writeExportCSV(w, &export)
+		return
+	}
+	logic.ReturnJSONResponse(w, r, export)
+}
+
+// auditPeriod - the from and to query dates of an audit log request, to covers the whole day
+func auditPeriod(r *http.Request) (time.Time, time.Time, error) {
+	query := r.URL.Query()
+	to := time.Now().UTC()
+	if v := query.Get("to"); v != "" {
+		day, err := time.Parse(logger.TimeFormatDay, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		to = day.Add(24*time.Hour - time.Nanosecond)
+	}
+	from := to.Add(-defaultAuditPeriod)
+	if v := query.Get("from"); v != "" {
+		day, err := time.Parse(logger.TimeFormatDay, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		from = day
+	}
+	return from, to, nil
+}
+
+// writeExportCSV - writes the export rows as a csv attachment
+func writeExportCSV(w http.ResponseWriter, export *models.Export) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+export.Resource+"-"+export.GeneratedAt.Format(logger.TimeFormatDay)+".csv\"")
+	w.WriteHeader(http.StatusOK)
+	out := csv.NewWriter(w)
+	out.Write(export.Columns)
+	for _, row := range export.Rows {
+		record := make([]string, len(export.Columns))
+		for i, column := range export.Columns {
+			record[i] = row[column]
+		}
+		out.Write(record)
+	}
+	out.Flush()
+}
@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/mq"
+	"golang.org/x/exp/slog"
+)
+
+func bandwidthHandlers(r *mux.Router) {
+	r.HandleFunc("/api/nodes/{network}/{nodeid}/bandwidth", logic.SecurityCheck(true, http.HandlerFunc(setNodeBandwidthLimit))).Methods(http.MethodPut)
+	r.HandleFunc("/api/extclients/{network}/{clientid}/bandwidth", logic.SecurityCheck(true, http.HandlerFunc(setExtClientBandwidthLimit))).Methods(http.MethodPut)
+}
+
+// swagger:route PUT /api/nodes/{network}/{nodeid}/bandwidth nodes setNodeBandwidthLimit
+//
+// Limit the bandwidth of a node, shaped by its host. Zero limits remove it.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: nodeResponse
+func setNodeBandwidthLimit(w http.ResponseWriter, r *http.Request) {
+	var params = mux.Vars(r)
+	nodeid := params["nodeid"]
+	netid := params["network"]
+	if _, err := validateParams(nodeid, netid); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	var limit models.BandwidthLimit
+	if err := json.NewDecoder(r.Body).Decode(&limit); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	node, err := logic.SetNodeBandwidthLimit(nodeid, &limit)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to update bandwidth limit", "user", r.Header.Get("user"), "node", nodeid, "network", netid, "error", err)
+		if errors.Is(err, logic.ErrInvalidBandwidthLimit) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "updated bandwidth limit", "user", r.Header.Get("user"), "node", nodeid, "network", netid,
+		"upload_kbps", limit.UploadKbps, "download_kbps", limit.DownloadKbps)
+	logic.ReturnJSONResponse(w, r, node.ConvertToAPINode())
+	go publishBandwidthLimits(r, node.HostID.String())
+}
+
+// swagger:route PUT /api/extclients/{network}/{clientid}/bandwidth ext_client setExtClientBandwidthLimit
+//
+// Limit the bandwidth of an ext client, shaped by its ingress gateway. Zero limits remove it.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: extClientResponse
+func setExtClientBandwidthLimit(w http.ResponseWriter, r *http.Request) {
+	var params = mux.Vars(r)
+	clientid := params["clientid"]
+	netid := params["network"]
+	var limit models.BandwidthLimit
+	if err := json.NewDecoder(r.Body).Decode(&limit); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	client, err := logic.SetExtClientBandwidthLimit(clientid, netid, &limit)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to update bandwidth limit", "user", r.Header.Get("user"), "extclient", clientid, "network", netid, "error", err)
+		if errors.Is(err, logic.ErrInvalidBandwidthLimit) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "updated bandwidth limit", "user", r.Header.Get("user"), "extclient", clientid, "network", netid,
+		"upload_kbps", limit.UploadKbps, "download_kbps", limit.DownloadKbps)
+	logic.ReturnJSONResponse(w, r, client)
+	go func() {
+		gateway, err := logic.GetNodeByID(client.IngressGatewayID)
+		if err != nil {
+			return
+		}
+		publishBandwidthLimits(r, gateway.HostID.String())
+	}()
+}
+
+// publishBandwidthLimits - sends the host that shapes a changed limit its peer update
+func publishBandwidthLimits(r *http.Request, hostID string) {
+	host, err := logic.GetHost(hostID)
+	if err != nil {
+		return
+	}
+	allNodes, err := logic.GetAllNodes()
+	if err != nil {
+		return
+	}
+	if err = mq.PublishSingleHostPeerUpdate(host, allNodes, nil, nil); err != nil {
+		slog.WarnCtx(r.Context(), "failed to publish bandwidth limits", "host", host.ID, "error", err)
+	}
+}
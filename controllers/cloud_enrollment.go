@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+func cloudEnrollmentHandlers(r *mux.Router) {
+	r.HandleFunc("/api/v1/cloud-enrollment", logic.SecurityCheck(true, http.HandlerFunc(getCloudEnrollmentRules))).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/cloud-enrollment", logic.SecurityCheck(true, http.HandlerFunc(createCloudEnrollmentRule))).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/cloud-enrollment/{ruleid}", logic.SecurityCheck(true, http.HandlerFunc(deleteCloudEnrollmentRule))).Methods(http.MethodDelete)
+	r.HandleFunc("/api/v1/host/register-cloud", http.HandlerFunc(handleCloudHostRegister)).Methods(http.MethodPost)
+}
+
+// swagger:route GET /api/v1/cloud-enrollment cloudEnrollment getCloudEnrollmentRules
+//
+// Lists the cloud accounts allowed to enroll instances.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: cloudEnrollmentRulesResponse
+func getCloudEnrollmentRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := logic.GetCloudEnrollmentRules()
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to fetch cloud enrollment rules", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	if tenant := r.Header.Get("tenant"); tenant != "" {
+		tenantRules := []models.CloudEnrollmentRule{}
+		for _, rule := range rules {
+			if rule.Tenant == tenant {
+				tenantRules = append(tenantRules, rule)
+			}
+		}
+		rules = tenantRules
+	}
+	writeList(w, r, rules)
+}
+
+// swagger:route POST /api/v1/cloud-enrollment cloudEnrollment createCloudEnrollmentRule
+//
+// Allows instances of an AWS account, GCP project or Azure subscription to join a network
+// by presenting their signed instance identity, tagging their nodes with the rule's tags.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: cloudEnrollmentRuleResponse
+func createCloudEnrollmentRule(w http.ResponseWriter, r *http.Request) {
+	var rule models.CloudEnrollmentRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		slog.ErrorCtx(r.Context(), "error decoding request body", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	// tenant admins always create rules within their own tenant
+	if tenant := r.Header.Get("tenant"); tenant != "" {
+		rule.Tenant = tenant
+	}
+	if err := logic.CreateCloudEnrollmentRule(&rule); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to create cloud enrollment rule", "user", r.Header.Get("user"), "network", rule.Network, "error", err)
+		var validationErrs validator.ValidationErrors
+		switch {
+		case errors.As(err, &validationErrs), errors.Is(err, logic.ErrTenantMismatch), database.IsEmptyRecord(err):
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		default:
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		}
+		return
+	}
+	slog.InfoCtx(r.Context(), "created cloud enrollment rule", "user", r.Header.Get("user"), "provider", rule.Provider, "account", rule.Account, "network", rule.Network)
+	logic.ReturnJSONResponse(w, r, rule)
+}
+
+// swagger:route DELETE /api/v1/cloud-enrollment/{ruleid} cloudEnrollment deleteCloudEnrollmentRule
+//
+// Stops a cloud account from enrolling instances, hosts already enrolled stay.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: successResponse
+func deleteCloudEnrollmentRule(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["ruleid"]
+	rule, err := logic.GetCloudEnrollmentRule(id)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	if tenant := r.Header.Get("tenant"); tenant != "" && rule.Tenant != tenant {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(logic.ErrTenantMismatch, "forbidden"))
+		return
+	}
+	if err = logic.DeleteCloudEnrollmentRule(id); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to delete cloud enrollment rule", "user", r.Header.Get("user"), "rule", id, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "deleted cloud enrollment rule", "user", r.Header.Get("user"), "rule", id)
+	logic.ReturnSuccessResponse(w, r, "deleted cloud enrollment rule "+id)
+}
+
+// swagger:route POST /api/v1/host/register-cloud enrollmentKeys handleCloudHostRegister
+//
+// Registers a Netclient on a cloud instance by its signed instance identity instead of an enrollment key,
+// joining it to the networks mapped to its cloud account.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: handleHostRegisterResponse
+func handleCloudHostRegister(w http.ResponseWriter, r *http.Request) {
+	var req models.CloudRegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.ErrorCtx(r.Context(), "error decoding request body", "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	identity, err := logic.VerifyCloudIdentity(&req)
+	if err != nil {
+		slog.WarnCtx(r.Context(), "rejected cloud registration", "provider", req.Provider, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "unauthorized"))
+		return
+	}
+	enrollmentKey, err := logic.CloudEnrollmentKey(identity)
+	if err != nil {
+		slog.WarnCtx(r.Context(), "rejected cloud registration", "provider", identity.Provider, "account", identity.Account, "instance", identity.InstanceID, "error", err)
+		if errors.Is(err, logic.ErrNoCloudEnrollmentRule) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "forbidden"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "received cloud registration", "provider", identity.Provider, "account", identity.Account, "instance", identity.InstanceID)
+	registerHost(w, r, enrollmentKey, &req.Host, func() error {
+		return logic.ClaimCloudInstance(identity, req.Host.ID.String())
+	})
+}
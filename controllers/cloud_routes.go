@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/logic/cloudroutes"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+func cloudRouteHandlers(r *mux.Router) {
+	r.HandleFunc("/api/networks/{networkname}/cloudroutes", logic.SecurityCheck(true, http.HandlerFunc(getCloudRoutes))).Methods(http.MethodGet)
+	r.HandleFunc("/api/networks/{networkname}/cloudroutes", logic.SecurityCheck(true, http.HandlerFunc(setCloudRoutes))).Methods(http.MethodPut)
+	r.HandleFunc("/api/networks/{networkname}/cloudroutes", logic.SecurityCheck(true, http.HandlerFunc(deleteCloudRoutes))).Methods(http.MethodDelete)
+}
+
+// swagger:route GET /api/networks/{networkname}/cloudroutes networks getCloudRoutes
+//
+// Gets the cloud route tables of a network and the routes programmed into them, without the credentials.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: cloudRoutesResponse
+func getCloudRoutes(w http.ResponseWriter, r *http.Request) {
+	netname := mux.Vars(r)["networkname"]
+	cfg, err := logic.GetCloudRoutes(netname)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+			return
+		}
+		slog.ErrorCtx(r.Context(), "failed to fetch cloud routes of network", "user", r.Header.Get("user"), "netname", netname, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	cfg.Credentials = nil
+	logic.ReturnJSONResponse(w, r, cfg)
+}
+
+// swagger:route PUT /api/networks/{networkname}/cloudroutes networks setCloudRoutes
+//
+// Sets the AWS, GCP or Azure route tables that route the network's range to its egress gateway,
+// so cloud subnets advertised by the gateway route back into the mesh. Credentials can be left out to keep the current ones.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: cloudRoutesResponse
+func setCloudRoutes(w http.ResponseWriter, r *http.Request) {
+	netname := mux.Vars(r)["networkname"]
+	var cfg models.CloudRoutes
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		slog.ErrorCtx(r.Context(), "error decoding request body", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	cfg.Network = netname
+	if err := logic.SetCloudRoutes(&cfg); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to set cloud routes", "user", r.Header.Get("user"), "network", netname, "error", err)
+		var validationErrs validator.ValidationErrors
+		if errors.As(err, &validationErrs) || database.IsEmptyRecord(err) || errors.Is(err, cloudroutes.ErrMissingCredentials) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "set cloud routes", "user", r.Header.Get("user"), "network", netname, "provider", cfg.Provider)
+	getCloudRoutes(w, r)
+}
+
+// swagger:route DELETE /api/networks/{networkname}/cloudroutes networks deleteCloudRoutes
+//
+// Removes the routes programmed into the network's cloud route tables and forgets the tables.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: successResponse
+func deleteCloudRoutes(w http.ResponseWriter, r *http.Request) {
+	netname := mux.Vars(r)["networkname"]
+	if err := logic.DeleteCloudRoutes(netname); err != nil {
+		if database.IsEmptyRecord(err) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+			return
+		}
+		slog.ErrorCtx(r.Context(), "failed to delete cloud routes", "user", r.Header.Get("user"), "network", netname, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "deleted cloud routes", "user", r.Header.Get("user"), "network", netname)
+	logic.ReturnSuccessResponse(w, r, "deleted cloud routes of network "+netname)
+}
+
+// syncCloudRoutes - reprograms the cloud route tables of a network after its egress gateways change
+func syncCloudRoutes(network string) {
+	if err := logic.SyncCloudRoutes(network); err != nil {
+		slog.Error("failed to sync cloud routes", "network", network, "error", err)
+	}
+}
@@ -2,16 +2,19 @@ package controller
 
 import (
 	"context"
-	"fmt"
+	"crypto/tls"
 	"net/http"
-	"strings"
 	"sync"
 	"time"
 
-	"github.com/gorilla/handlers"
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/servercfg"
+	"github.com/gravitl/netmaker/serverctl"
+	"github.com/gravitl/netmaker/tracing"
+	"golang.org/x/exp/slog"
 )
 
 // HttpMiddlewares - middleware functions for REST interactions
@@ -31,6 +34,57 @@ var HttpHandlers = []interface{}{
 	hostHandlers,
 	enrollmentKeyHandlers,
 	legacyHandlers,
+	flowHandlers,
+	healthHandlers,
+	tenantHandlers,
+	reportHandlers,
+	scheduledReportHandlers,
+	notificationHandlers,
+	ipamHandlers,
+	zoneHandlers,
+	policyHandlers,
+	lockdownHandlers,
+	cloudEnrollmentHandlers,
+	cloudRouteHandlers,
+	externalDNSHandlers,
+	endpointHandlers,
+	probeHandlers,
+	auditHandlers,
+	sidecarHandlers,
+	staticPeerHandlers,
+	profilingHandlers,
+	tailnetHandlers,
+	bandwidthHandlers,
+	qosHandlers,
+	maintenanceHandlers,
+	approvalHandlers,
+	trashHandlers,
+	networkBundleHandlers,
+	egressHealthHandlers,
+	racHandlers,
+	sourcePolicyHandlers,
+	securityEventHandlers,
+	hostCertHandlers,
+	pkiHandlers,
+	apiAllowlistHandlers,
+	networkFeatureHandlers,
+	extClientAccessHandlers,
+	joinConfigHandlers,
+	signingKeyHandlers,
+	rolloutHandlers,
+}
+
+// requestIDMiddleware - tags every request with an id, reusing the caller's X-Request-ID if set,
+// so log records of a request can be correlated
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if id == "" {
+			id = uuid.NewString()
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(logger.WithRequestID(r.Context(), id)))
+	})
 }
 
 // HandleRESTRequests - handles the rest requests
@@ -39,12 +93,7 @@ func HandleRESTRequests(wg *sync.WaitGroup, ctx context.Context) {
 
 	r := mux.NewRouter()
 
-	// Currently allowed dev origin is all. Should change in prod
-	// should consider analyzing the allowed methods further
-	headersOk := handlers.AllowedHeaders([]string{"Access-Control-Allow-Origin", "X-Requested-With", "Content-Type", "authorization"})
-	originsOk := handlers.AllowedOrigins(strings.Split(servercfg.GetAllowedOrigin(), ","))
-	methodsOk := handlers.AllowedMethods([]string{http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete})
-
+	r.Use(requestIDMiddleware, tracing.Middleware, rateLimitMiddleware)
 	for _, middleware := range HttpMiddlewares {
 		r.Use(middleware)
 	}
@@ -52,26 +101,46 @@ func HandleRESTRequests(wg *sync.WaitGroup, ctx context.Context) {
 	for _, handler := range HttpHandlers {
 		handler.(func(*mux.Router))(r)
 	}
+	// extensions get a path of their own so they can't take over the routes of the server
+	for name, extension := range logic.RouteExtensions() {
+		extension.Routes(r.PathPrefix("/api/extensions/" + name).Subrouter())
+	}
 
 	port := servercfg.GetAPIPort()
 
-	srv := &http.Server{Addr: ":" + port, Handler: handlers.CORS(originsOk, headersOk, methodsOk)(r)}
+	srv := &http.Server{Addr: ":" + port, Handler: corsMiddleware(r)}
 	go func() {
-		err := srv.ListenAndServe()
+		var err error
+		if servercfg.IsACMEEnabled() {
+			srv.TLSConfig = &tls.Config{GetCertificate: serverctl.GetACMECertificate}
+			if servercfg.IsHostCertAuth() {
+				// hosts authenticate with certificates issued by the host CA, verified per request
+				srv.TLSConfig.ClientAuth = tls.RequestClientCert
+			}
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
 		if err != nil {
-			logger.Log(0, err.Error())
+			slog.ErrorCtx(ctx, err.Error())
 		}
 	}()
-	logger.Log(0, "REST Server successfully started on port ", port, " (REST)")
+	slog.InfoCtx(ctx, "REST Server successfully started", "port", port)
 
 	// Block main routine until a signal is received
 	// As long as user doesn't press CTRL+C a message is not passed and our main routine keeps running
 	<-ctx.Done()
-	// After receiving CTRL+C Properly stop the server
-	logger.Log(0, "Stopping the REST server...")
-	if err := srv.Shutdown(context.TODO()); err != nil {
-		logger.Log(0, "REST shutdown error occurred -", err.Error())
+	// After receiving CTRL+C stop reporting ready and keep serving until load balancers have noticed,
+	// then properly stop the server, letting in-flight requests finish within the grace period
+	logic.SetDraining()
+	drainDelay := servercfg.GetShutdownDrainDelay()
+	slog.InfoCtx(ctx, "Draining the REST server...", "drain_delay", drainDelay.String())
+	time.Sleep(drainDelay)
+	slog.InfoCtx(ctx, "Stopping the REST server...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), servercfg.GetShutdownGracePeriod())
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.ErrorCtx(ctx, "REST shutdown error occurred", "error", err)
 	}
-	logger.Log(0, "REST Server closed.")
-	logger.DumpFile(fmt.Sprintf("data/netmaker.log.%s", time.Now().Format(logger.TimeFormatDay)))
+	slog.InfoCtx(ctx, "REST Server closed.")
 }
@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/servercfg"
+)
+
+// corsMiddleware - answers CORS preflights and sets the CORS and security headers of every response,
+// reading the settings on every request so they can be changed at runtime
+func corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setSecurityHeaders(w)
+		cors := servercfg.GetCORSSettings()
+		origin := r.Header.Get("Origin")
+		allowed := logic.IsOriginAllowed(cors.AllowedOrigins, origin)
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			method := r.Header.Get("Access-Control-Request-Method")
+			if !allowed || !containsFold(cors.AllowedMethods, method) || !headersAllowed(cors.AllowedHeaders, r.Header.Get("Access-Control-Request-Headers")) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			setAllowOrigin(w, cors.AllowedOrigins, cors.AllowCredentials, origin)
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(cors.AllowedMethods, ", "))
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(cors.AllowedHeaders, ", "))
+			if cors.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(cors.MaxAge))
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if allowed {
+			setAllowOrigin(w, cors.AllowedOrigins, cors.AllowCredentials, origin)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setSecurityHeaders - sets the content security policy, framing, referrer and HSTS headers
+func setSecurityHeaders(w http.ResponseWriter) {
+	headers := servercfg.GetSecurityHeaders()
+	ancestors := "'none'"
+	if len(headers.FrameAncestors) > 0 {
+		ancestors = strings.Join(headers.FrameAncestors, " ")
+	}
+	w.Header().Set("Content-Security-Policy", strings.TrimSuffix(headers.ContentSecurityPolicy, ";")+"; frame-ancestors "+ancestors)
+	if ancestors == "'none'" {
+		w.Header().Set("X-Frame-Options", "DENY")
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Referrer-Policy", headers.ReferrerPolicy)
+	if headers.HSTSMaxAge > 0 {
+		w.Header().Set("Strict-Transport-Security", "max-age="+strconv.Itoa(headers.HSTSMaxAge))
+	}
+}
+
+// setAllowOrigin - echoes the origin back, or * when any origin is allowed without credentials
+func setAllowOrigin(w http.ResponseWriter, allowedOrigins []string, credentials bool, origin string) {
+	if !credentials && containsFold(allowedOrigins, "*") {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	w.Header().Add("Vary", "Origin")
+	if credentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// headersAllowed - whether every header of a preflight's Access-Control-Request-Headers is allowed
+func headersAllowed(allowed []string, requested string) bool {
+	for _, header := range strings.Split(requested, ",") {
+		if header = strings.TrimSpace(header); header != "" && !containsFold(allowed, header) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsFold(list []string, value string) bool {
+	for _, entry := range list {
+		if strings.EqualFold(entry, value) {
+			return true
+		}
+	}
+	return false
+}
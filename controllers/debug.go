@@ -9,16 +9,16 @@ import (
 	"os"
 	"os/signal"
 
-	"github.com/gravitl/netmaker/logger"
+	"golang.org/x/exp/slog"
 )
 
 func init() {
 	srv := &http.Server{Addr: "0.0.0.0:6060", Handler: nil}
 	go func() {
-		logger.Log(0, "Debug mode active")
+		slog.Info("Debug mode active")
 		err := srv.ListenAndServe()
 		if err != nil {
-			logger.Log(0, err.Error())
+			slog.Error(err.Error())
 		}
 		c := make(chan os.Signal)
 
@@ -7,17 +7,18 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/gravitl/netmaker/database"
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/mq"
 	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
 )
 
 func dnsHandlers(r *mux.Router) {
 
 	r.HandleFunc("/api/dns", logic.SecurityCheck(true, http.HandlerFunc(getAllDNS))).Methods(http.MethodGet)
 	r.HandleFunc("/api/dns/adm/{network}/nodes", logic.SecurityCheck(false, http.HandlerFunc(getNodeDNS))).Methods(http.MethodGet)
+	r.HandleFunc("/api/dns/adm/{network}/extclients", logic.SecurityCheck(false, http.HandlerFunc(getExtClientDNS))).Methods(http.MethodGet)
 	r.HandleFunc("/api/dns/adm/{network}/custom", logic.SecurityCheck(false, http.HandlerFunc(getCustomDNS))).Methods(http.MethodGet)
 	r.HandleFunc("/api/dns/adm/{network}", logic.SecurityCheck(false, http.HandlerFunc(getDNS))).Methods(http.MethodGet)
 	r.HandleFunc("/api/dns/{network}", logic.SecurityCheck(false, http.HandlerFunc(createDNS))).Methods(http.MethodPost)
@@ -42,13 +43,33 @@ func getNodeDNS(w http.ResponseWriter, r *http.Request) {
 	network := params["network"]
 	dns, err := logic.GetNodeDNS(network)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("failed to get node DNS entries for network [%s]: %v", network, err))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("failed to get node DNS entries for network [%s]: %v", network, err), "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(dns)
+	logic.ReturnJSONResponse(w, r, dns)
+}
+
+// swagger:route GET /api/dns/adm/{network}/extclients dns getExtClientDNS
+//
+// Gets the DNS entries of the enabled ext clients of a network, named in its clients zone.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+func getExtClientDNS(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+
+	network := mux.Vars(r)["network"]
+	dns, err := logic.GetExtClientDNS(network)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("failed to get ext client DNS entries for network [%s]: %v", network, err), "user", r.Header.Get("user"))
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnJSONResponse(w, r, dns)
 }
 
 // swagger:route GET /api/dns dns getAllDNS
@@ -66,13 +87,22 @@ func getAllDNS(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	dns, err := logic.GetAllDNS()
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"), "failed to get all DNS entries: ", err.Error())
+		slog.ErrorCtx(r.Context(), "failed to get all DNS entries", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
+	if r.Header.Get("tenant") != "" {
+		networks, _ := getHeaderNetworks(r)
+		tenantDNS := []models.DNSEntry{}
+		for _, entry := range dns {
+			if logic.StringSliceContains(networks, entry.Network) {
+				tenantDNS = append(tenantDNS, entry)
+			}
+		}
+		dns = tenantDNS
+	}
 	logic.SortDNSEntrys(dns[:])
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(dns)
+	logic.ReturnJSONResponse(w, r, dns)
 }
 
 // swagger:route GET /api/dns/adm/{network}/custom dns getCustomDNS
@@ -95,13 +125,11 @@ func getCustomDNS(w http.ResponseWriter, r *http.Request) {
 	network := params["network"]
 	dns, err := logic.GetCustomDNS(network)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("failed to get custom DNS entries for network [%s]: %v", network, err.Error()))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("failed to get custom DNS entries for network [%s]: %v", network, err.Error()), "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(dns)
+	logic.ReturnJSONResponse(w, r, dns)
 }
 
 // swagger:route GET /api/dns/adm/{network} dns getDNS
@@ -124,13 +152,11 @@ func getDNS(w http.ResponseWriter, r *http.Request) {
 	network := params["network"]
 	dns, err := logic.GetDNS(network)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("failed to get all DNS entries for network [%s]: %v", network, err.Error()))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("failed to get all DNS entries for network [%s]: %v", network, err.Error()), "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(dns)
+	logic.ReturnJSONResponse(w, r, dns)
 }
 
 // swagger:route POST /api/dns/{network} dns createDNS
@@ -155,41 +181,36 @@ func createDNS(w http.ResponseWriter, r *http.Request) {
 
 	err := logic.ValidateDNSCreate(entry)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("invalid DNS entry %+v: %v", entry, err))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("invalid DNS entry %+v: %v", entry, err), "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
 
 	entry, err = logic.CreateDNS(entry)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("Failed to create DNS entry %+v: %v", entry, err))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("Failed to create DNS entry %+v: %v", entry, err), "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
 	err = logic.SetDNS()
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("Failed to set DNS entries on file: %v", err))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("Failed to set DNS entries on file: %v", err), "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
-	logger.Log(1, "new DNS record added:", entry.Name)
+	slog.InfoCtx(r.Context(), "new DNS record added", "entry_name", entry.Name)
 	if servercfg.IsMessageQueueBackend() {
 		go func() {
-			if err = mq.PublishPeerUpdate(); err != nil {
-				logger.Log(0, "failed to publish peer update after ACL update on", entry.Network)
+			if err = mq.PublishNetworkPeerUpdate(entry.Network); err != nil {
+				slog.ErrorCtx(r.Context(), "failed to publish peer update after ACL update", "network", entry.Network)
 			}
 			if err := mq.PublishCustomDNS(&entry); err != nil {
-				logger.Log(0, "error publishing custom dns", err.Error())
+				slog.ErrorCtx(r.Context(), "error publishing custom dns", "error", err)
 			}
 		}()
 	}
-	logger.Log(2, r.Header.Get("user"),
-		fmt.Sprintf("DNS entry is set: %+v", entry))
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(entry)
+	slog.DebugCtx(r.Context(), fmt.Sprintf("DNS entry is set: %+v", entry), "user", r.Header.Get("user"))
+	logic.ReturnJSONResponse(w, r, entry)
 }
 
 // swagger:route DELETE /api/dns/{network}/{domain} dns deleteDNS
@@ -214,15 +235,14 @@ func deleteDNS(w http.ResponseWriter, r *http.Request) {
 	err := logic.DeleteDNS(params["domain"], params["network"])
 
 	if err != nil {
-		logger.Log(0, "failed to delete dns entry: ", entrytext)
+		slog.ErrorCtx(r.Context(), "failed to delete dns entry", "entrytext", entrytext)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
-	logger.Log(1, "deleted dns entry: ", entrytext)
+	slog.InfoCtx(r.Context(), "deleted dns entry", "entrytext", entrytext)
 	err = logic.SetDNS()
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("Failed to set DNS entries on file: %v", err))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("Failed to set DNS entries on file: %v", err), "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
@@ -233,7 +253,7 @@ func deleteDNS(w http.ResponseWriter, r *http.Request) {
 			Name:   entrytext,
 		}
 		if err := mq.PublishDNSUpdate(params["network"], dns); err != nil {
-			logger.Log(0, "failed to publish dns update", err.Error())
+			slog.ErrorCtx(r.Context(), "failed to publish dns update", "error", err)
 		}
 	}()
 
@@ -273,11 +293,10 @@ func pushDNS(w http.ResponseWriter, r *http.Request) {
 	err := logic.SetDNS()
 
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("Failed to set DNS entries on file: %v", err))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("Failed to set DNS entries on file: %v", err), "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
-	logger.Log(1, r.Header.Get("user"), "pushed DNS updates to nameserver")
+	slog.InfoCtx(r.Context(), "pushed DNS updates to nameserver", "user", r.Header.Get("user"))
 	json.NewEncoder(w).Encode("DNS Pushed to CoreDNS")
 }
@@ -128,6 +128,25 @@ func TestGetCustomDNS(t *testing.T) {
 	})
 }
 
+func TestGetExtClientDNS(t *testing.T) {
+	deleteAllDNS(t)
+	deleteAllNetworks()
+	createNet()
+	enabled := models.ExtClient{ClientID: "laptop-alice", Network: "skynet", Address: "10.0.0.20", Enabled: true}
+	disabled := models.ExtClient{ClientID: "laptop-bob", Network: "skynet", Address: "10.0.0.21"}
+	assert.Nil(t, logic.SaveExtClient(&enabled))
+	assert.Nil(t, logic.SaveExtClient(&disabled))
+	defer func() {
+		logic.DeleteExtClient("skynet", enabled.ClientID)
+		logic.DeleteExtClient("skynet", disabled.ClientID)
+	}()
+	dns, err := logic.GetExtClientDNS("skynet")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(dns))
+	assert.Equal(t, "laptop-alice.clients", dns[0].Name)
+	assert.Equal(t, "10.0.0.20", dns[0].Address)
+}
+
 func TestGetDNSEntryNum(t *testing.T) {
 	deleteAllDNS(t)
 	deleteAllNetworks()
@@ -224,6 +224,652 @@ type nodeSliceResponse struct {
 	Nodes []models.LegacyNode `json:"nodes"`
 }
 
+// swagger:response cacheStatsResponse
+type cacheStatsResponse struct {
+	// Cache stats
+	// in: body
+	Stats []models.CacheStats `json:"stats"`
+}
+
+// swagger:response certificateStatusResponse
+type certificateStatusResponse struct {
+	// Certificate status
+	// in: body
+	Status models.CertificateStatus `json:"status"`
+}
+
+// swagger:response serverSettingsResponse
+type serverSettingsResponse struct {
+	// Server settings
+	// in: body
+	Settings models.ServerSettings `json:"settings"`
+}
+
+// swagger:response healthReportResponse
+type healthReportResponse struct {
+	// Health report
+	// in: body
+	Report models.HealthReport `json:"report"`
+}
+
+// swagger:response tenantResponse
+type tenantResponse struct {
+	// Tenant
+	// in: body
+	Tenant models.Tenant `json:"tenant"`
+}
+
+// swagger:response tenantsResponse
+type tenantsResponse struct {
+	// Tenants
+	// in: body
+	Tenants []models.Tenant `json:"tenants"`
+}
+
+// swagger:response usageReportResponse
+type usageReportResponse struct {
+	// Usage report
+	// in: body
+	Report models.UsageReport `json:"report"`
+}
+
+// swagger:response cloudEnrollmentRuleResponse
+type cloudEnrollmentRuleResponse struct {
+	// Cloud enrollment rule
+	// in: body
+	Rule models.CloudEnrollmentRule `json:"rule"`
+}
+
+// swagger:response cloudEnrollmentRulesResponse
+type cloudEnrollmentRulesResponse struct {
+	// Cloud enrollment rules
+	// in: body
+	Rules []models.CloudEnrollmentRule `json:"rules"`
+}
+
+// swagger:response cloudRoutesResponse
+type cloudRoutesResponse struct {
+	// Cloud route tables of a network
+	// in: body
+	CloudRoutes models.CloudRoutes `json:"cloud_routes"`
+}
+
+// swagger:response externalDNSProviderResponse
+type externalDNSProviderResponse struct {
+	// External dns provider
+	// in: body
+	Provider models.ExternalDNSProvider `json:"provider"`
+}
+
+// swagger:response externalDNSProvidersResponse
+type externalDNSProvidersResponse struct {
+	// External dns providers
+	// in: body
+	Providers []models.ExternalDNSProvider `json:"providers"`
+}
+
+// swagger:response externalDNSRecordResponse
+type externalDNSRecordResponse struct {
+	// External dns record
+	// in: body
+	Record models.ExternalDNSRecord `json:"record"`
+}
+
+// swagger:response externalDNSRecordsResponse
+type externalDNSRecordsResponse struct {
+	// External dns records
+	// in: body
+	Records []models.ExternalDNSRecord `json:"records"`
+}
+
+// swagger:response hostTuningResponse
+type hostTuningResponse struct {
+	// Host WireGuard settings
+	// in: body
+	Tuning models.HostTuning `json:"tuning"`
+}
+
+// swagger:response hostNatReportResponse
+type hostNatReportResponse struct {
+	// Host NAT report
+	// in: body
+	Report models.HostNatReport `json:"report"`
+}
+
+// swagger:response endpointSelectionsResponse
+type endpointSelectionsResponse struct {
+	// Endpoints a host uses for its peer hosts
+	// in: body
+	Selections []models.EndpointSelection `json:"selections"`
+}
+
+// swagger:response endpointObservationsResponse
+type endpointObservationsResponse struct {
+	// Endpoints a node was seen reaching its peers through
+	// in: body
+	Observations []models.EndpointObservation `json:"observations"`
+}
+
+// swagger:response probeResponse
+type probeResponse struct {
+	// in: body
+	Probe models.Probe `json:"probe"`
+}
+
+// swagger:response probesResponse
+type probesResponse struct {
+	// in: body
+	Probes []models.Probe `json:"probes"`
+}
+
+// swagger:response probeStatusResponse
+type probeStatusResponse struct {
+	// Probe with its recent results and alert state
+	// in: body
+	Status models.ProbeStatus `json:"status"`
+}
+
+// swagger:response auditLogsResponse
+type auditLogsResponse struct {
+	// Changes users made through the api
+	// in: body
+	Entries []models.AuditEntry `json:"entries"`
+}
+
+// swagger:response exportResponse
+type exportResponse struct {
+	// in: body
+	Export models.Export `json:"export"`
+}
+
+// swagger:response networkEventsResponse
+type networkEventsResponse struct {
+	// A page of a network's events
+	// in: body
+	Page models.NetworkEventPage `json:"page"`
+}
+
+// swagger:response sidecarRegistrationResponse
+type sidecarRegistrationResponse struct {
+	// A sidecar's lease and wireguard config
+	// in: body
+	Registration models.SidecarRegistration `json:"registration"`
+}
+
+// swagger:parameters registerSidecar renewSidecarLease
+type sidecarRequestBodyParam struct {
+	// Sidecar Request
+	// in: body
+	Request models.SidecarRequest `json:"sidecar_request"`
+}
+
+// swagger:response staticPeerResponse
+type staticPeerResponse struct {
+	// in: body
+	StaticPeer models.StaticPeer `json:"static_peer"`
+}
+
+// swagger:response staticPeersResponse
+type staticPeersResponse struct {
+	// in: body
+	StaticPeers []models.StaticPeer `json:"static_peers"`
+}
+
+// swagger:parameters createStaticPeer
+type staticPeerBodyParam struct {
+	// Static Peer
+	// in: body
+	StaticPeer models.StaticPeer `json:"static_peer"`
+}
+
+// swagger:parameters getStaticPeerConfig getGatewayDeviceConfig
+type deviceConfigFormatParam struct {
+	// Config format: wireguard, routeros, opnsense or pfsense
+	// in: query
+	Format string `json:"format"`
+}
+
+// swagger:response nodeUptimeResponse
+type nodeUptimeResponse struct {
+	// A node's status, uptime windows and transitions
+	// in: body
+	Uptime models.NodeUptime `json:"uptime"`
+}
+
+// swagger:parameters getNodeUptime
+type nodeUptimeWindowsParam struct {
+	// Comma separated uptime windows such as 24h,7d,30d
+	// in: query
+	Windows string `json:"windows"`
+}
+
+// swagger:response hostPublishStatusResponse
+type hostPublishStatusResponse struct {
+	// Host publish status
+	// in: body
+	Status models.HostPublishStatus `json:"status"`
+}
+
+// swagger:response publishQueueStatusResponse
+type publishQueueStatusResponse struct {
+	// Publish queue status
+	// in: body
+	Status models.PublishQueueStatus `json:"status"`
+}
+
+// swagger:response runtimeStatsResponse
+type runtimeStatsResponse struct {
+	// Runtime stats
+	// in: body
+	Stats models.RuntimeStats `json:"stats"`
+}
+
+// swagger:response configValidationResponse
+type configValidationResponse struct {
+	// Config validation
+	// in: body
+	Validation models.ConfigValidation `json:"validation"`
+}
+
+// swagger:response wireGuardImportResponse
+type wireGuardImportResponse struct {
+	// WireGuard import result
+	// in: body
+	Result models.WireGuardImportResult `json:"result"`
+}
+
+// swagger:response userImportResponse
+type userImportResponse struct {
+	// User import result
+	// in: body
+	Result models.UserImportResult `json:"result"`
+}
+
+// swagger:response tailnetImportResponse
+type tailnetImportResponse struct {
+	// Tailnet import report
+	// in: body
+	Result models.TailnetImportResult `json:"result"`
+}
+
+// swagger:response migrationResponse
+type migrationResponse struct {
+	// Migrated host, nodes and per node results
+	// in: body
+	Response models.MigrationResponse `json:"response"`
+}
+
+// swagger:parameters setNodeBandwidthLimit setExtClientBandwidthLimit
+type bandwidthLimitBodyParam struct {
+	// Bandwidth Limit
+	// in: body
+	BandwidthLimit models.BandwidthLimit `json:"bandwidth_limit"`
+}
+
+// swagger:parameters setNetworkQoS
+type qosPolicyBodyParam struct {
+	// QoS Policy
+	// in: body
+	QoSPolicy models.QoSPolicy `json:"qos_policy"`
+}
+
+// swagger:response maintenanceStatusResponse
+type maintenanceStatusResponse struct {
+	// Maintenance Status
+	// in: body
+	MaintenanceStatus models.MaintenanceStatus `json:"maintenance_status"`
+}
+
+// swagger:response maintenanceWindowResponse
+type maintenanceWindowResponse struct {
+	// Maintenance Window
+	// in: body
+	MaintenanceWindow models.MaintenanceWindow `json:"maintenance_window"`
+}
+
+// swagger:response pendingChangesResponse
+type pendingChangesResponse struct {
+	// Pending Changes
+	// in: body
+	PendingChanges []models.PendingChange `json:"pending_changes"`
+}
+
+// swagger:response approvalRequestsResponse
+type approvalRequestsResponse struct {
+	// Approval Requests
+	// in: body
+	ApprovalRequests []models.ApprovalRequest `json:"approval_requests"`
+}
+
+// swagger:response approvalRequestResponse
+type approvalRequestResponse struct {
+	// Approval Request
+	// in: body
+	ApprovalRequest models.ApprovalRequest `json:"approval_request"`
+}
+
+// swagger:response trashResponse
+type trashResponse struct {
+	// Trash
+	// in: body
+	Trash []models.TrashedResource `json:"trash"`
+}
+
+// swagger:response dryRunResponse
+type dryRunResponse struct {
+	// Dry Run Result
+	// in: body
+	DryRunResult models.DryRunResult `json:"dry_run_result"`
+}
+
+// swagger:response networkBundleResponse
+type networkBundleResponse struct {
+	// Network Bundle
+	// in: body
+	NetworkBundle models.NetworkBundle `json:"network_bundle"`
+}
+
+// swagger:response networkBundleImportResponse
+type networkBundleImportResponse struct {
+	// Network Bundle Import
+	// in: body
+	NetworkBundleImport models.NetworkBundleImport `json:"network_bundle_import"`
+}
+
+// swagger:response egressHealthResponse
+type egressHealthResponse struct {
+	// Egress Range Health
+	// in: body
+	Health []models.EgressRangeHealth `json:"health"`
+}
+
+// swagger:response racDeviceAuthResponse
+type racDeviceAuthResponse struct {
+	// RAC Device Auth
+	// in: body
+	DeviceAuth models.RACDeviceAuth `json:"device_auth"`
+}
+
+// swagger:response racDeviceTokenResponse
+type racDeviceTokenResponse struct {
+	// RAC Device Token
+	// in: body
+	DeviceToken models.RACDeviceToken `json:"device_token"`
+}
+
+// swagger:response racClientConfigResponse
+type racClientConfigResponse struct {
+	// RAC Client Config
+	// in: body
+	ClientConfig models.RACClientConfig `json:"client_config"`
+}
+
+// swagger:response extClientCredentialsResponse
+type extClientCredentialsResponse struct {
+	// Ext Client Credentials
+	// in: body
+	Credentials models.ExtClientCredentials `json:"credentials"`
+}
+
+// swagger:response userActivityResponse
+type userActivityResponse struct {
+	// User Activity
+	// in: body
+	Activity models.UserActivity `json:"activity"`
+}
+
+// swagger:response sourcePolicyResponse
+type sourcePolicyResponse struct {
+	// Source Policy
+	// in: body
+	Policy models.SourcePolicy `json:"policy"`
+}
+
+// swagger:response securityEventsResponse
+type securityEventsResponse struct {
+	// Security Events
+	// in: body
+	Events []models.SecurityEvent `json:"events"`
+}
+
+// swagger:response issuedCertificateResponse
+type issuedCertificateResponse struct {
+	// Issued Certificate
+	// in: body
+	Certificate models.IssuedCertificate `json:"certificate"`
+}
+
+// swagger:response pkiCertificateResponse
+type pkiCertificateResponse struct {
+	// PKI Certificate
+	// in: body
+	Certificate models.PKICertificate `json:"certificate"`
+}
+
+// swagger:response pkiCertificatesResponse
+type pkiCertificatesResponse struct {
+	// PKI Certificates
+	// in: body
+	Certificates []models.PKICertificate `json:"certificates"`
+}
+
+// swagger:response apiAllowlistResponse
+type apiAllowlistResponse struct {
+	// API Allowlist
+	// in: body
+	Allowlist models.APIAllowlist `json:"allowlist"`
+}
+
+// swagger:response userSecurityResponse
+type userSecurityResponse struct {
+	// User Security
+	// in: body
+	Security models.UserSecurity `json:"security"`
+}
+
+// swagger:response networkFeaturesResponse
+type networkFeaturesResponse struct {
+	// Network Features
+	// in: body
+	Features models.NetworkFeatures `json:"features"`
+}
+
+// swagger:response nodeSuggestionsResponse
+type nodeSuggestionsResponse struct {
+	// Node Suggestions
+	// in: body
+	Suggestions []models.NodeSuggestion `json:"suggestions"`
+}
+
+// swagger:response scheduledReportsResponse
+type scheduledReportsResponse struct {
+	// Scheduled Reports
+	// in: body
+	Reports []models.ScheduledReport `json:"reports"`
+}
+
+// swagger:response scheduledReportResponse
+type scheduledReportResponse struct {
+	// Scheduled Report
+	// in: body
+	Report models.ScheduledReport `json:"report"`
+}
+
+// swagger:response renderedReportResponse
+type renderedReportResponse struct {
+	// Rendered Report
+	// in: body
+	Report models.RenderedReport `json:"report"`
+}
+
+// swagger:response notificationChannelsResponse
+type notificationChannelsResponse struct {
+	// Notification Channels
+	// in: body
+	Channels []models.NotificationChannel `json:"channels"`
+}
+
+// swagger:response notificationChannelResponse
+type notificationChannelResponse struct {
+	// Notification Channel
+	// in: body
+	Channel models.NotificationChannel `json:"channel"`
+}
+
+// swagger:response networkIPAMResponse
+type networkIPAMResponse struct {
+	// Network IPAM
+	// in: body
+	IPAM models.NetworkIPAM `json:"ipam"`
+}
+
+// swagger:response addressConflictsResponse
+type addressConflictsResponse struct {
+	// Address Conflicts
+	// in: body
+	Conflicts []models.IPAMConflict `json:"conflicts"`
+}
+
+// swagger:response addressReassignmentsResponse
+type addressReassignmentsResponse struct {
+	// Address Reassignments
+	// in: body
+	Reassignments []models.IPAMReassignment `json:"reassignments"`
+}
+
+// swagger:response consistencyReportResponse
+type consistencyReportResponse struct {
+	// Consistency Report
+	// in: body
+	Report models.ConsistencyReport `json:"report"`
+}
+
+// swagger:response hostResourceMetricsResponse
+type hostResourceMetricsResponse struct {
+	// Host Resource Metrics
+	// in: body
+	Metrics models.HostResourceMetrics `json:"metrics"`
+}
+
+// swagger:response extClientSessionsResponse
+type extClientSessionsResponse struct {
+	// Ext Client Sessions
+	// in: body
+	Sessions []models.ExtClientSession `json:"sessions"`
+}
+
+// swagger:response zoneCompileResultResponse
+type zoneCompileResultResponse struct {
+	// Zone Compile Result
+	// in: body
+	Result models.ZoneCompileResult `json:"result"`
+}
+
+// swagger:response policyPlanResponse
+type policyPlanResponse struct {
+	// Policy Plan
+	// in: body
+	Plan models.PolicyPlan `json:"plan"`
+}
+
+// swagger:response networkLockdownResponse
+type networkLockdownResponse struct {
+	// Network Lockdown
+	// in: body
+	Lockdown models.NetworkLockdown `json:"lockdown"`
+}
+
+// swagger:response hostQuarantineResponse
+type hostQuarantineResponse struct {
+	// Host Quarantine
+	// in: body
+	Quarantine models.HostQuarantine `json:"quarantine"`
+}
+
+// swagger:response hostQuarantinesResponse
+type hostQuarantinesResponse struct {
+	// Host Quarantines
+	// in: body
+	Quarantines []models.HostQuarantine `json:"quarantines"`
+}
+
+// swagger:response hostDriftsResponse
+type hostDriftsResponse struct {
+	// Host Drifts
+	// in: body
+	Drifts []models.HostDrift `json:"drifts"`
+}
+
+// swagger:response hostDriftResponse
+type hostDriftResponse struct {
+	// Host Drift
+	// in: body
+	Drift models.HostDrift `json:"drift"`
+}
+
+// swagger:response rolloutsResponse
+type rolloutsResponse struct {
+	// Rollouts
+	// in: body
+	Rollouts []models.Rollout `json:"rollouts"`
+}
+
+// swagger:response rolloutResponse
+type rolloutResponse struct {
+	// Rollout
+	// in: body
+	Rollout models.Rollout `json:"rollout"`
+}
+
+// swagger:response joinConfigResponse
+type joinConfigResponse struct {
+	// Join Config
+	// in: body
+	JoinConfig models.JoinConfig `json:"join_config"`
+}
+
+// swagger:response extensionsResponse
+type extensionsResponse struct {
+	// Extensions
+	// in: body
+	Extensions []models.ExtensionInfo `json:"extensions"`
+}
+
+// swagger:response jobsResponse
+type jobsResponse struct {
+	// Jobs
+	// in: body
+	Jobs []models.JobStatus `json:"jobs"`
+}
+
+// swagger:response jobResponse
+type jobResponse struct {
+	// Job
+	// in: body
+	Job models.JobStatus `json:"job"`
+}
+
+// swagger:response signingKeysResponse
+type signingKeysResponse struct {
+	// Signing Keys
+	// in: body
+	SigningKeys []models.SigningKey `json:"signing_keys"`
+}
+
+// swagger:response signingKeyResponse
+type signingKeyResponse struct {
+	// Signing Key
+	// in: body
+	SigningKey models.SigningKey `json:"signing_key"`
+}
+
+// swagger:response logLevelsResponse
+type logLevelsResponse struct {
+	// Log levels by component
+	// in: body
+	Levels map[string]string `json:"levels"`
+}
+
 // swagger:response nodeResponse
 type nodeResponse struct {
 	// Node
@@ -238,6 +884,13 @@ type nodeBodyParam struct {
 	Node models.LegacyNode `json:"node"`
 }
 
+// swagger:parameters renameNode
+type nodeNameBodyParam struct {
+	// Node Name
+	// in: body
+	NodeName models.NodeNameRequest `json:"node_name"`
+}
+
 // swagger:parameters createRelay
 type relayRequestBodyParam struct {
 	// Relay Request
@@ -0,0 +1,22 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+// isDryRun - checks a request only asks what its change would do, dryrun is accepted like on the imports
+func isDryRun(r *http.Request) bool {
+	query := r.URL.Query()
+	return query.Get("dryRun") == "true" || query.Get("dryrun") == "true"
+}
+
+// writeDryRun - answers a dry run with what its change would do
+func writeDryRun(w http.ResponseWriter, r *http.Request, result models.DryRunResult) {
+	result.DryRun = true
+	slog.InfoCtx(r.Context(), "dry run", "user", r.Header.Get("user"), "method", r.Method, "path", r.URL.Path, "peer_updates", len(result.PeerUpdates))
+	logic.ReturnJSONResponse(w, r, result)
+}
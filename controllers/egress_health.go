@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logic"
+	"golang.org/x/exp/slog"
+)
+
+func egressHealthHandlers(r *mux.Router) {
+	r.HandleFunc("/api/networks/{networkname}/egresshealth", logic.SecurityCheck(true, http.HandlerFunc(getEgressHealth))).Methods(http.MethodGet)
+}
+
+// swagger:route GET /api/networks/{networkname}/egresshealth networks getEgressHealth
+//
+// Lists the health of the egress ranges of a network that have health checks, reported by their gateways.
+// An unhealthy range is routed through another healthy gateway of the network serving it, when there is one.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: egressHealthResponse
+func getEgressHealth(w http.ResponseWriter, r *http.Request) {
+	netname := mux.Vars(r)["networkname"]
+	network, err := logic.GetNetwork(netname)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	if tenant := r.Header.Get("tenant"); tenant != "" && network.Tenant != tenant {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(logic.ErrTenantMismatch, "forbidden"))
+		return
+	}
+	health, err := logic.GetEgressHealth(netname)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to fetch egress health", "user", r.Header.Get("user"), "network", netname, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	writeList(w, r, health)
+}
@@ -0,0 +1,173 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/mq"
+	"golang.org/x/exp/slog"
+)
+
+func endpointHandlers(r *mux.Router) {
+	r.HandleFunc("/api/hosts/{hostid}/endpoints", logic.SecurityCheck(true, http.HandlerFunc(getHostEndpoints))).Methods(http.MethodGet)
+	r.HandleFunc("/api/hosts/{hostid}/endpoints/{peerhostid}", logic.SecurityCheck(true, http.HandlerFunc(setHostEndpoint))).Methods(http.MethodPut)
+	r.HandleFunc("/api/hosts/{hostid}/endpoints/{peerhostid}", logic.SecurityCheck(true, http.HandlerFunc(deleteHostEndpoint))).Methods(http.MethodDelete)
+	r.HandleFunc("/api/nodes/{network}/{nodeid}/endpoints", logic.SecurityCheck(true, http.HandlerFunc(getNodeEndpointObservations))).Methods(http.MethodGet)
+}
+
+// swagger:route GET /api/hosts/{hostid}/endpoints hosts getHostEndpoints
+//
+// Lists the endpoint a host uses for each of its peer hosts, whether they share a LAN,
+// and the scored candidates the endpoint was picked from.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: endpointSelectionsResponse
+func getHostEndpoints(w http.ResponseWriter, r *http.Request) {
+	hostID := mux.Vars(r)["hostid"]
+	host, err := logic.GetHost(hostID)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	selections, err := logic.GetEndpointSelections(host)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to fetch endpoints of host", "user", r.Header.Get("user"), "host_id", hostID, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	writeList(w, r, selections)
+}
+
+// swagger:route PUT /api/hosts/{hostid}/endpoints/{peerhostid} hosts setHostEndpoint
+//
+// Pins the endpoint (ip:port) a host uses for a peer host instead of the selected one, or the kind of
+// endpoint to use, eg. local to route a pair over their LAN or public4 to keep it off a LAN detected by mistake,
+// or the address family preferred between them, auto, ipv6 or ipv4, instead of the network's.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: successResponse
+func setHostEndpoint(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	host, peerHost, ok := getEndpointHosts(w, r, vars["hostid"], vars["peerhostid"])
+	if !ok {
+		return
+	}
+	var req models.EndpointOverrideRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.ErrorCtx(r.Context(), "error decoding request body", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	if err := logic.SetEndpointOverride(host, peerHost, req); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to set endpoint override", "user", r.Header.Get("user"), "host", host.ID, "peer", peerHost.ID, "error", err)
+		if errors.Is(err, logic.ErrInvalidEndpoint) || errors.Is(err, logic.ErrNotAPeer) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "set endpoint override", "user", r.Header.Get("user"), "host", host.ID, "peer", peerHost.ID, "endpoint", req.Endpoint, "kind", req.Kind, "family", req.Family)
+	go publishHostEndpoints(host)
+	logic.ReturnSuccessResponse(w, r, "set endpoint of "+peerHost.Name+" for host "+host.Name)
+}
+
+// swagger:route DELETE /api/hosts/{hostid}/endpoints/{peerhostid} hosts deleteHostEndpoint
+//
+// Removes a pinned endpoint so the host goes back to using the selected endpoint for the peer host.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: successResponse
+func deleteHostEndpoint(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	host, peerHost, ok := getEndpointHosts(w, r, vars["hostid"], vars["peerhostid"])
+	if !ok {
+		return
+	}
+	if err := logic.DeleteEndpointOverride(host.ID.String(), peerHost.ID.String()); err != nil {
+		if database.IsEmptyRecord(err) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+			return
+		}
+		slog.ErrorCtx(r.Context(), "failed to delete endpoint override", "user", r.Header.Get("user"), "host", host.ID, "peer", peerHost.ID, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "deleted endpoint override", "user", r.Header.Get("user"), "host", host.ID, "peer", peerHost.ID)
+	go publishHostEndpoints(host)
+	logic.ReturnSuccessResponse(w, r, "deleted endpoint of "+peerHost.Name+" for host "+host.Name)
+}
+
+// getEndpointHosts - loads the hosts of an endpoint override, writing the error response when either is missing
+func getEndpointHosts(w http.ResponseWriter, r *http.Request, hostID, peerHostID string) (*models.Host, *models.Host, bool) {
+	host, err := logic.GetHost(hostID)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return nil, nil, false
+	}
+	peerHost, err := logic.GetHost(peerHostID)
+	if err != nil || !logic.HostsArePeers(host, peerHost) {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(logic.ErrNotAPeer, "notfound"))
+		return nil, nil, false
+	}
+	return host, peerHost, true
+}
+
+// publishHostEndpoints - sends a host its peers with the endpoints now selected for them
+func publishHostEndpoints(host *models.Host) {
+	allNodes, err := logic.GetAllNodes()
+	if err != nil {
+		return
+	}
+	if err := mq.PublishSingleHostPeerUpdate(host, allNodes, nil, nil); err != nil {
+		slog.Warn("failed to publish peer endpoints", "host", host.ID, "error", err)
+	}
+}
+
+// swagger:route GET /api/nodes/{network}/{nodeid}/endpoints nodes getNodeEndpointObservations
+//
+// Lists the endpoint a node last handshaked with each of its peers through, as its host reported it,
+// next to the endpoint the server selected, and how often it changed.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: endpointObservationsResponse
+func getNodeEndpointObservations(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	node, err := validateParams(vars["nodeid"], vars["network"])
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	observations, err := logic.GetEndpointObservations(&node)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to fetch endpoint observations of node", "user", r.Header.Get("user"), "node_id", node.ID.String(), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	writeList(w, r, observations)
+}
@@ -2,23 +2,25 @@ package controller
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/gravitl/netmaker/auth"
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/mq"
 	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
 )
 
 func enrollmentKeyHandlers(r *mux.Router) {
 	r.HandleFunc("/api/v1/enrollment-keys", logic.SecurityCheck(true, http.HandlerFunc(createEnrollmentKey))).Methods(http.MethodPost)
 	r.HandleFunc("/api/v1/enrollment-keys", logic.SecurityCheck(false, http.HandlerFunc(getEnrollmentKeys))).Methods(http.MethodGet)
 	r.HandleFunc("/api/v1/enrollment-keys/{keyID}", logic.SecurityCheck(true, http.HandlerFunc(deleteEnrollmentKey))).Methods(http.MethodDelete)
+	r.HandleFunc("/api/v1/enrollment-keys/{keyID}/rotate", logic.SecurityCheck(true, http.HandlerFunc(rotateEnrollmentKey))).Methods(http.MethodPost)
 	r.HandleFunc("/api/v1/host/register/{token}", http.HandlerFunc(handleHostRegister)).Methods(http.MethodPost)
 }
 
@@ -36,7 +38,7 @@ func enrollmentKeyHandlers(r *mux.Router) {
 func getEnrollmentKeys(w http.ResponseWriter, r *http.Request) {
 	keys, err := logic.GetAllEnrollmentKeys()
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"), "failed to fetch enrollment keys: ", err.Error())
+		slog.ErrorCtx(r.Context(), "failed to fetch enrollment keys", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
@@ -44,7 +46,7 @@ func getEnrollmentKeys(w http.ResponseWriter, r *http.Request) {
 	// regular user flow
 	user, err := logic.GetUser(r.Header.Get("user"))
 	if err != nil && !isMasterAdmin {
-		logger.Log(0, r.Header.Get("user"), "failed to fetch user: ", err.Error())
+		slog.ErrorCtx(r.Context(), "failed to fetch user", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
@@ -54,22 +56,27 @@ func getEnrollmentKeys(w http.ResponseWriter, r *http.Request) {
 		if !isMasterAdmin && !logic.UserHasNetworksAccess(key.Networks, user) {
 			continue
 		}
+		if !isMasterAdmin && user.Tenant != "" && key.Tenant != user.Tenant {
+			continue
+		}
 		if err = logic.Tokenize(key, servercfg.GetAPIHost()); err != nil {
-			logger.Log(0, r.Header.Get("user"), "failed to get token values for keys:", err.Error())
+			slog.ErrorCtx(r.Context(), "failed to get token values for keys", "user", r.Header.Get("user"), "error", err)
 			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 			return
 		}
 		ret = append(ret, key)
 	}
 	// return JSON/API formatted keys
-	logger.Log(2, r.Header.Get("user"), "fetched enrollment keys")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(ret)
+	slog.DebugCtx(r.Context(), "fetched enrollment keys", "user", r.Header.Get("user"))
+	writeList(w, r, ret)
 }
 
 // swagger:route DELETE /api/v1/enrollment-keys/{keyID} enrollmentKeys deleteEnrollmentKey
 //
-// Deletes an EnrollmentKey from Netmaker server.
+// Deletes an EnrollmentKey from Netmaker server. A key revoked as compromised takes a cascade,
+// quarantine or flag, for the hosts enrolled with it to be quarantined or flagged with a security alert each.
+// Hosts that registered before hosts recorded their enrollment key are left out of the cascade, those in the key's
+// networks are listed as unattributed for review.
 //
 //			Schemes: https
 //
@@ -81,16 +88,75 @@ func getEnrollmentKeys(w http.ResponseWriter, r *http.Request) {
 func deleteEnrollmentKey(w http.ResponseWriter, r *http.Request) {
 	var params = mux.Vars(r)
 	keyID := params["keyID"]
+	if cascade := r.URL.Query().Get("cascade"); cascade != "" {
+		revokeEnrollmentKey(w, r, keyID, cascade)
+		return
+	}
 	err := logic.DeleteEnrollmentKey(keyID)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"), "failed to remove enrollment key: ", err.Error())
+		slog.ErrorCtx(r.Context(), "failed to remove enrollment key", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
-	logger.Log(2, r.Header.Get("user"), "deleted enrollment key", keyID)
+	slog.DebugCtx(r.Context(), "deleted enrollment key", "user", r.Header.Get("user"), "key_id", keyID)
 	w.WriteHeader(http.StatusOK)
 }
 
+func revokeEnrollmentKey(w http.ResponseWriter, r *http.Request, keyID, cascade string) {
+	revocation, err := logic.RevokeEnrollmentKey(keyID, r.Header.Get("user"), cascade)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to revoke enrollment key", "user", r.Header.Get("user"), "error", err)
+		switch {
+		case errors.Is(err, logic.EnrollmentErrors.InvalidCascade):
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		case errors.Is(err, logic.EnrollmentErrors.NoKeyFound):
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		default:
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		}
+		return
+	}
+	if len(revocation.Quarantined) > 0 {
+		if err := mq.PublishPeerUpdate(); err != nil {
+			slog.ErrorCtx(r.Context(), "failed to publish peer update after revoking enrollment key", "fingerprint", revocation.Fingerprint, "error", err)
+		}
+	}
+	slog.InfoCtx(r.Context(), "revoked enrollment key", "user", r.Header.Get("user"), "fingerprint", revocation.Fingerprint, "cascade", cascade, "count", len(revocation.Hosts))
+	logic.ReturnJSONResponse(w, r, revocation)
+}
+
+// swagger:route POST /api/v1/enrollment-keys/{keyID}/rotate enrollmentKeys rotateEnrollmentKey
+//
+// Replaces the value of an EnrollmentKey, keeping its config; the old value and token stop working.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: createEnrollmentKeyResponse
+func rotateEnrollmentKey(w http.ResponseWriter, r *http.Request) {
+	keyID := mux.Vars(r)["keyID"]
+	key, err := logic.RotateEnrollmentKey(keyID)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to rotate enrollment key", "user", r.Header.Get("user"), "error", err)
+		if errors.Is(err, logic.EnrollmentErrors.NoKeyFound) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	if err = logic.Tokenize(key, servercfg.GetAPIHost()); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to rotate enrollment key", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "rotated enrollment key", "user", r.Header.Get("user"), "fingerprint", key.Fingerprint)
+	logic.ReturnJSONResponse(w, r, key)
+}
+
 // swagger:route POST /api/v1/enrollment-keys enrollmentKeys createEnrollmentKey
 //
 // Creates an EnrollmentKey for hosts to use on Netmaker server.
@@ -108,8 +174,7 @@ func createEnrollmentKey(w http.ResponseWriter, r *http.Request) {
 
 	err := json.NewDecoder(r.Body).Decode(&enrollmentKeyBody)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"), "error decoding request body: ",
-			err.Error())
+		slog.ErrorCtx(r.Context(), "error decoding request body", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
@@ -118,21 +183,57 @@ func createEnrollmentKey(w http.ResponseWriter, r *http.Request) {
 		newTime = time.Unix(enrollmentKeyBody.Expiration, 0)
 	}
 
-	newEnrollmentKey, err := logic.CreateEnrollmentKey(enrollmentKeyBody.UsesRemaining, newTime, enrollmentKeyBody.Networks, enrollmentKeyBody.Tags, enrollmentKeyBody.Unlimited)
+	// tenant admins always create keys within their own tenant
+	if tenant := r.Header.Get("tenant"); tenant != "" {
+		enrollmentKeyBody.Tenant = tenant
+	} else if enrollmentKeyBody.Tenant != "" {
+		if _, err = logic.GetTenant(enrollmentKeyBody.Tenant); err != nil {
+			err = fmt.Errorf("unknown tenant %s", enrollmentKeyBody.Tenant)
+			slog.ErrorCtx(r.Context(), "failed to create enrollment key", "user", r.Header.Get("user"), "error", err)
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+	}
+	newEnrollmentKey, err := logic.CreateTenantEnrollmentKey(enrollmentKeyBody.Tenant, enrollmentKeyBody.UsesRemaining, newTime, enrollmentKeyBody.Networks, enrollmentKeyBody.Tags, enrollmentKeyBody.Unlimited)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"), "failed to create enrollment key:", err.Error())
+		slog.ErrorCtx(r.Context(), "failed to create enrollment key", "user", r.Header.Get("user"), "error", err)
+		if errors.Is(err, logic.ErrTenantMismatch) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
 
+	if enrollmentKeyBody.Ephemeral {
+		if err = logic.SetEnrollmentKeyEphemeral(newEnrollmentKey, time.Duration(enrollmentKeyBody.EphemeralTTL)*time.Second); err != nil {
+			slog.ErrorCtx(r.Context(), "failed to create enrollment key", "user", r.Header.Get("user"), "error", err)
+			if errors.Is(err, logic.ErrInvalidEphemeralTTL) {
+				logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+				return
+			}
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+			return
+		}
+	}
+	if enrollmentKeyBody.JoinConfig != nil {
+		if err = logic.SetEnrollmentKeyJoinConfig(newEnrollmentKey, enrollmentKeyBody.JoinConfig); err != nil {
+			slog.ErrorCtx(r.Context(), "failed to create enrollment key", "user", r.Header.Get("user"), "error", err)
+			if errors.Is(err, logic.ErrInvalidJoinConfig) {
+				logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+				return
+			}
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+			return
+		}
+	}
 	if err = logic.Tokenize(newEnrollmentKey, servercfg.GetAPIHost()); err != nil {
-		logger.Log(0, r.Header.Get("user"), "failed to create enrollment key:", err.Error())
+		slog.ErrorCtx(r.Context(), "failed to create enrollment key", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
-	logger.Log(2, r.Header.Get("user"), "created enrollment key")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(newEnrollmentKey)
+	slog.DebugCtx(r.Context(), "created enrollment key", "user", r.Header.Get("user"))
+	logic.ReturnJSONResponse(w, r, newEnrollmentKey)
 }
 
 // swagger:route POST /api/v1/enrollment-keys/{token} enrollmentKeys handleHostRegister
@@ -149,33 +250,44 @@ func createEnrollmentKey(w http.ResponseWriter, r *http.Request) {
 func handleHostRegister(w http.ResponseWriter, r *http.Request) {
 	var params = mux.Vars(r)
 	token := params["token"]
-	logger.Log(0, "received registration attempt with token", token)
+	slog.InfoCtx(r.Context(), "received registration attempt with token", "token", token)
 	// check if token exists
 	enrollmentKey, err := logic.DeTokenize(token)
 	if err != nil {
-		logger.Log(0, "invalid enrollment key used", token, err.Error())
+		slog.ErrorCtx(r.Context(), "invalid enrollment key used", "token", token, "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
 	// get the host
 	var newHost models.Host
 	if err = json.NewDecoder(r.Body).Decode(&newHost); err != nil {
-		logger.Log(0, r.Header.Get("user"), "error decoding request body: ",
-			err.Error())
+		slog.ErrorCtx(r.Context(), "error decoding request body", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
+	registerHost(w, r, enrollmentKey, &newHost, func() error {
+		if !logic.TryToUseEnrollmentKey(enrollmentKey) {
+			return errors.New("invalid enrollment key")
+		}
+		return nil
+	})
+}
+
+// registerHost - registers a host and joins it to the networks of the enrollment key,
+// useKey is called to spend the key once the host is checked
+func registerHost(w http.ResponseWriter, r *http.Request, enrollmentKey *models.EnrollmentKey, newHost *models.Host, useKey func() error) {
+	var err error
 	hostExists := false
 	// re-register host with turn just in case.
 	if servercfg.IsUsingTurn() {
 		err = logic.RegisterHostWithTurn(newHost.ID.String(), newHost.HostPass)
 		if err != nil {
-			logger.Log(0, "failed to register host with turn server: ", err.Error())
+			slog.ErrorCtx(r.Context(), "failed to register host with turn server", "error", err)
 		}
 	}
 	// check if host already exists
-	if hostExists = logic.HostExists(&newHost); hostExists && len(enrollmentKey.Networks) == 0 {
-		logger.Log(0, "host", newHost.ID.String(), newHost.Name, "attempted to re-register with no networks")
+	if hostExists = logic.HostExists(newHost); hostExists && len(enrollmentKey.Networks) == 0 {
+		slog.InfoCtx(r.Context(), "host attempted to re-register with no networks", "new_host_id", newHost.ID.String(), "new_host_name", newHost.Name)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(fmt.Errorf("host already exists"), "badrequest"))
 		return
 	}
@@ -185,40 +297,54 @@ func handleHostRegister(w http.ResponseWriter, r *http.Request) {
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
+	if err := logic.CheckHostQuarantine(newHost, r); err != nil {
+		slog.ErrorCtx(r.Context(), "host refused registration", "new_host_id", newHost.ID.String(), "new_host_name", newHost.Name, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "forbidden"))
+		return
+	}
 	if newHost.TrafficKeyPublic == nil && newHost.OS != models.OS_Types.IoT {
 		err := fmt.Errorf("missing traffic key")
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
+	// in certificate auth mode the host's certificate replaces its token
+	certRequest := newHost.CertificateRequest
+	newHost.CertificateRequest = ""
+	if servercfg.IsHostCertAuth() && certRequest == "" {
+		err := fmt.Errorf("missing certificate request")
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
 	key, keyErr := logic.RetrievePublicTrafficKey()
 	if keyErr != nil {
-		logger.Log(0, "error retrieving key:", keyErr.Error())
+		slog.ErrorCtx(r.Context(), "error retrieving key", "error", keyErr)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
 	// use the token
-	if ok := logic.TryToUseEnrollmentKey(enrollmentKey); !ok {
-		logger.Log(0, "host", newHost.ID.String(), newHost.Name, "failed registration")
-		logic.ReturnErrorResponse(w, r, logic.FormatError(fmt.Errorf("invalid enrollment key"), "badrequest"))
+	if err := useKey(); err != nil {
+		slog.ErrorCtx(r.Context(), "host failed registration", "new_host_id", newHost.ID.String(), "new_host_name", newHost.Name, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
 	hostPass := newHost.HostPass
 	if !hostExists {
 		// register host
-		logic.CheckHostPorts(&newHost)
+		newHost.EnrolledWith = logic.EnrollmentKeyFingerprint(enrollmentKey)
+		logic.CheckHostPorts(newHost)
 		// create EMQX credentials and ACLs for host
 		if servercfg.GetBrokerType() == servercfg.EmqxBrokerType {
 			if err := mq.CreateEmqxUser(newHost.ID.String(), newHost.HostPass, false); err != nil {
-				logger.Log(0, "failed to create host credentials for EMQX: ", err.Error())
+				slog.ErrorCtx(r.Context(), "failed to create host credentials for EMQX", "error", err)
 				return
 			}
 			if err := mq.CreateHostACL(newHost.ID.String(), servercfg.GetServerInfo().Server); err != nil {
-				logger.Log(0, "failed to add host ACL rules to EMQX: ", err.Error())
+				slog.ErrorCtx(r.Context(), "failed to add host ACL rules to EMQX", "error", err)
 				return
 			}
 		}
-		if err = logic.CreateHost(&newHost); err != nil {
-			logger.Log(0, "host", newHost.ID.String(), newHost.Name, "failed registration -", err.Error())
+		if err = logic.CreateHost(newHost); err != nil {
+			slog.ErrorCtx(r.Context(), "host failed registration", "new_host_id", newHost.ID.String(), "new_host_name", newHost.Name, "error", err)
 			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 			return
 		}
@@ -242,13 +368,29 @@ func handleHostRegister(w http.ResponseWriter, r *http.Request) {
 		server.MQUserName = newHost.ID.String()
 		server.MQPassword = hostPass
 	}
+	signingKeys, err := logic.GetSigningKeys()
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "error retrieving signing keys", "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
 	response := models.RegisterResponse{
 		ServerConf:    server,
-		RequestedHost: newHost,
+		RequestedHost: *newHost,
+		JoinConfigs:   logic.GetJoinConfigs(enrollmentKey.Networks, enrollmentKey),
+		SigningKeys:   signingKeys,
 	}
-	logger.Log(0, newHost.Name, newHost.ID.String(), "registered with Netmaker")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(&response)
+	if servercfg.IsHostCertAuth() {
+		cert, err := logic.IssueHostCertificate(newHost, certRequest)
+		if err != nil {
+			slog.ErrorCtx(r.Context(), "host failed to issue certificate", "new_host_id", newHost.ID.String(), "new_host_name", newHost.Name, "error", err)
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		response.HostCertificate = &cert
+	}
+	slog.InfoCtx(r.Context(), "registered with Netmaker", "new_host_name", newHost.Name, "new_host_id", newHost.ID.String())
+	logic.ReturnJSONResponse(w, r, &response)
 	// notify host of changes, peer and node updates
-	go auth.CheckNetRegAndHostUpdate(enrollmentKey.Networks, &newHost)
+	go auth.CheckNetRegAndHostUpdate(enrollmentKey.Networks, newHost, enrollmentKey)
 }
@@ -7,6 +7,8 @@ import (
 	"net"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/gravitl/netmaker/database"
@@ -16,6 +18,7 @@ import (
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/models/promodels"
 	"github.com/gravitl/netmaker/mq"
+	"github.com/gravitl/netmaker/servercfg"
 	"github.com/skip2/go-qrcode"
 	"golang.org/x/exp/slog"
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
@@ -26,10 +29,12 @@ func extClientHandlers(r *mux.Router) {
 	r.HandleFunc("/api/extclients", logic.SecurityCheck(false, http.HandlerFunc(getAllExtClients))).Methods(http.MethodGet)
 	r.HandleFunc("/api/extclients/{network}", logic.SecurityCheck(false, http.HandlerFunc(getNetworkExtClients))).Methods(http.MethodGet)
 	r.HandleFunc("/api/extclients/{network}/{clientid}", logic.SecurityCheck(false, http.HandlerFunc(getExtClient))).Methods(http.MethodGet)
+	r.HandleFunc("/api/extclients/{network}/{clientid}/sessions", logic.NetUserSecurityCheck(false, true, http.HandlerFunc(getExtClientSessions))).Methods(http.MethodGet)
 	r.HandleFunc("/api/extclients/{network}/{clientid}/{type}", logic.NetUserSecurityCheck(false, true, http.HandlerFunc(getExtClientConf))).Methods(http.MethodGet)
 	r.HandleFunc("/api/extclients/{network}/{clientid}", logic.NetUserSecurityCheck(false, true, http.HandlerFunc(updateExtClient))).Methods(http.MethodPut)
 	r.HandleFunc("/api/extclients/{network}/{clientid}", logic.NetUserSecurityCheck(false, true, http.HandlerFunc(deleteExtClient))).Methods(http.MethodDelete)
 	r.HandleFunc("/api/extclients/{network}/{nodeid}", logic.NetUserSecurityCheck(false, true, checkFreeTierLimits(limitChoiceMachines, http.HandlerFunc(createExtClient)))).Methods(http.MethodPost)
+	r.HandleFunc("/api/extclients/{network}/{clientid}/renew", logic.NetUserSecurityCheck(false, true, http.HandlerFunc(renewExtClientKey))).Methods(http.MethodPost)
 }
 
 func checkIngressExists(nodeID string) bool {
@@ -61,15 +66,13 @@ func getNetworkExtClients(w http.ResponseWriter, r *http.Request) {
 	network := params["network"]
 	extclients, err := logic.GetNetworkExtClients(network)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("failed to get ext clients for network [%s]: %v", network, err))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("failed to get ext clients for network [%s]: %v", network, err), "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
 
 	//Returns all the extclients in JSON format
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(extclients)
+	logic.ReturnJSONResponse(w, r, extclients)
 }
 
 // swagger:route GET /api/extclients ext_client getAllExtClients
@@ -94,8 +97,7 @@ func getAllExtClients(w http.ResponseWriter, r *http.Request) {
 	networksSlice := []string{}
 	marshalErr := json.Unmarshal([]byte(headerNetworks), &networksSlice)
 	if marshalErr != nil {
-		logger.Log(0, "error unmarshalling networks: ",
-			marshalErr.Error())
+		slog.ErrorCtx(r.Context(), "error unmarshalling networks", "error", marshalErr)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(marshalErr, "internal"))
 		return
 	}
@@ -104,7 +106,7 @@ func getAllExtClients(w http.ResponseWriter, r *http.Request) {
 	if len(networksSlice) > 0 && networksSlice[0] == logic.ALL_NETWORK_ACCESS {
 		clients, err = logic.GetAllExtClients()
 		if err != nil && !database.IsEmptyRecord(err) {
-			logger.Log(0, "failed to get all extclients: ", err.Error())
+			slog.ErrorCtx(r.Context(), "failed to get all extclients", "error", err)
 			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 			return
 		}
@@ -119,8 +121,7 @@ func getAllExtClients(w http.ResponseWriter, r *http.Request) {
 
 	//Return all the extclients in JSON format
 	logic.SortExtClient(clients[:])
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(clients)
+	writeList(w, r, clients)
 }
 
 // swagger:route GET /api/extclients/{network}/{clientid} ext_client getExtClient
@@ -144,19 +145,60 @@ func getExtClient(w http.ResponseWriter, r *http.Request) {
 	network := params["network"]
 	client, err := logic.GetExtClient(clientid, network)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"), fmt.Sprintf("failed to get extclient for [%s] on network [%s]: %v",
-			clientid, network, err))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("failed to get extclient for [%s] on network [%s]: %v",
+			clientid, network, err), "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(client)
+	logic.ReturnJSONResponse(w, r, client)
+}
+
+// swagger:route GET /api/extclients/{network}/{clientid}/sessions ext_client getExtClientSessions
+//
+// Get the connections of an extclient to its gateways, from the first to the last handshake each gateway saw,
+// with the source ip and bytes sent and received. Covers the last 30 days unless from and to (YYYY-MM-DD) are given.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: extClientSessionsResponse
+func getExtClientSessions(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	query := r.URL.Query()
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -30)
+	var err error
+	if v := query.Get("from"); v != "" {
+		if from, err = time.Parse(logger.TimeFormatDay, v); err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if to, err = time.Parse(logger.TimeFormatDay, v); err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		// the whole of the last day
+		to = to.AddDate(0, 0, 1).Add(-time.Second)
+	}
+	sessions, err := logic.GetExtClientSessions(params["network"], params["clientid"], from, to)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to get sessions of extclient", "user", r.Header.Get("user"), "clientid", params["clientid"], "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnJSONResponse(w, r, sessions)
 }
 
 // swagger:route GET /api/extclients/{network}/{clientid}/{type} ext_client getExtClientConf
 //
 // Get an individual extclient.
+// The type qr or file renders its wireguard config, gateways lists its endpoint candidates.
 //
 //			Schemes: https
 //
@@ -174,32 +216,77 @@ func getExtClientConf(w http.ResponseWriter, r *http.Request) {
 	networkid := params["network"]
 	client, err := logic.GetExtClient(clientid, networkid)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"), fmt.Sprintf("failed to get extclient for [%s] on network [%s]: %v",
-			clientid, networkid, err))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("failed to get extclient for [%s] on network [%s]: %v",
+			clientid, networkid, err), "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
 
-	gwnode, err := logic.GetNodeByID(client.IngressGatewayID)
+	if params["type"] == "gateways" {
+		gateways, err := logic.GetExtClientGateways(&client)
+		if err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+			return
+		}
+		logic.ReturnJSONResponse(w, r, gateways)
+		return
+	}
+
+	config, err := extClientConfig(&client)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("failed to get ingress gateway node [%s] info: %v", client.IngressGatewayID, err))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("failed to render config of extclient [%s] on network [%s]: %v", clientid, networkid, err), "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
+
+	if params["type"] == "qr" {
+		bytes, err := qrcode.Encode(config, qrcode.Medium, 220)
+		if err != nil {
+			slog.ErrorCtx(r.Context(), "failed to encode qr code", "user", r.Header.Get("user"), "error", err)
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.WriteHeader(http.StatusOK)
+		_, err = w.Write(bytes)
+		if err != nil {
+			slog.ErrorCtx(r.Context(), "response writer error (qr)", "user", r.Header.Get("user"), "error", err)
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+			return
+		}
+		return
+	}
+
+	if params["type"] == "file" {
+		name := client.ClientID + ".conf"
+		w.Header().Set("Content-Type", "application/config")
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+name+"\"")
+		w.WriteHeader(http.StatusOK)
+		_, err := fmt.Fprint(w, config)
+		if err != nil {
+			slog.ErrorCtx(r.Context(), "response writer error (file)", "user", r.Header.Get("user"), "error", err)
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		}
+		return
+	}
+	slog.DebugCtx(r.Context(), "retrieved ext client config", "user", r.Header.Get("user"))
+	logic.ReturnJSONResponse(w, r, client)
+}
+
+// extClientConfig - renders the wireguard config of an ext client, peered with its ingress gateway
+func extClientConfig(client *models.ExtClient) (string, error) {
+	gwnode, err := logic.GetNodeByID(client.IngressGatewayID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get ingress gateway node [%s] info: %w", client.IngressGatewayID, err)
+	}
 	host, err := logic.GetHost(gwnode.HostID.String())
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("failed to get host for ingress gateway node [%s] info: %v", client.IngressGatewayID, err))
-		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
-		return
+		return "", fmt.Errorf("failed to get host for ingress gateway node [%s] info: %w", client.IngressGatewayID, err)
 	}
 
 	network, err := logic.GetParentNetwork(client.Network)
 	if err != nil {
-		logger.Log(1, r.Header.Get("user"), "Could not retrieve Ingress Gateway Network", client.Network)
-		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
-		return
+		return "", fmt.Errorf("could not retrieve ingress gateway network %s: %w", client.Network, err)
 	}
 
 	addrString := client.Address
@@ -217,11 +304,15 @@ func getExtClientConf(w http.ResponseWriter, r *http.Request) {
 	if network.DefaultKeepalive != 0 {
 		keepalive = "PersistentKeepalive = " + strconv.Itoa(int(network.DefaultKeepalive))
 	}
-	gwendpoint := ""
-	if host.EndpointIP.To4() == nil {
-		gwendpoint = fmt.Sprintf("[%s]:%d", host.EndpointIP.String(), host.ListenPort)
-	} else {
-		gwendpoint = fmt.Sprintf("%s:%d", host.EndpointIP.String(), host.ListenPort)
+	gwendpoint := logic.ExtClientGatewayEndpoint(host)
+	// secondary gateways are listed for clients that switch to them when the primary is unreachable
+	failoverPeers := ""
+	if gateways, err := logic.GetExtClientGateways(client); err == nil {
+		for _, gateway := range gateways.Gateways {
+			if !gateway.Primary {
+				failoverPeers += fmt.Sprintf("# FailoverPeer = %s@%s\n", gateway.PublicKey, gateway.Endpoint)
+			}
+		}
 	}
 	newAllowedIPs := network.AddressRange
 	if newAllowedIPs != "" && network.AddressRange6 != "" {
@@ -230,11 +321,14 @@ func getExtClientConf(w http.ResponseWriter, r *http.Request) {
 	if network.AddressRange6 != "" {
 		newAllowedIPs += network.AddressRange6
 	}
-	if egressGatewayRanges, err := logic.GetEgressRangesOnNetwork(&client); err == nil {
+	if egressGatewayRanges, err := logic.GetEgressRangesOnNetwork(client); err == nil {
 		for _, egressGatewayRange := range egressGatewayRanges {
 			newAllowedIPs += "," + egressGatewayRange
 		}
 	}
+	if len(client.AllowedRanges) > 0 {
+		newAllowedIPs = strings.Join(client.AllowedRanges, ",")
+	}
 	defaultDNS := ""
 	if client.DNS != "" {
 		defaultDNS = "DNS = " + client.DNS
@@ -242,11 +336,11 @@ func getExtClientConf(w http.ResponseWriter, r *http.Request) {
 		defaultDNS = "DNS = " + gwnode.IngressDNS
 	}
 
-	defaultMTU := 1420
+	defaultMTU := servercfg.GetDefaultMTU()
 	if host.MTU != 0 {
 		defaultMTU = host.MTU
 	}
-	config := fmt.Sprintf(`[Interface]
+	return fmt.Sprintf(`[Interface]
 Address = %s
 PrivateKey = %s
 MTU = %d
@@ -257,7 +351,7 @@ PublicKey = %s
 AllowedIPs = %s
 Endpoint = %s
 %s
-
+%s
 `, addrString,
 		client.PrivateKey,
 		defaultMTU,
@@ -265,41 +359,8 @@ Endpoint = %s
 		host.PublicKey,
 		newAllowedIPs,
 		gwendpoint,
-		keepalive)
-
-	if params["type"] == "qr" {
-		bytes, err := qrcode.Encode(config, qrcode.Medium, 220)
-		if err != nil {
-			logger.Log(1, r.Header.Get("user"), "failed to encode qr code: ", err.Error())
-			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
-			return
-		}
-		w.Header().Set("Content-Type", "image/png")
-		w.WriteHeader(http.StatusOK)
-		_, err = w.Write(bytes)
-		if err != nil {
-			logger.Log(1, r.Header.Get("user"), "response writer error (qr) ", err.Error())
-			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
-			return
-		}
-		return
-	}
-
-	if params["type"] == "file" {
-		name := client.ClientID + ".conf"
-		w.Header().Set("Content-Type", "application/config")
-		w.Header().Set("Content-Disposition", "attachment; filename=\""+name+"\"")
-		w.WriteHeader(http.StatusOK)
-		_, err := fmt.Fprint(w, config)
-		if err != nil {
-			logger.Log(1, r.Header.Get("user"), "response writer error (file) ", err.Error())
-			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
-		}
-		return
-	}
-	logger.Log(2, r.Header.Get("user"), "retrieved ext client config")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(client)
+		keepalive,
+		failoverPeers), nil
 }
 
 // swagger:route POST /api/extclients/{network}/{nodeid} ext_client createExtClient
@@ -319,7 +380,7 @@ func createExtClient(w http.ResponseWriter, r *http.Request) {
 	ingressExists := checkIngressExists(nodeid)
 	if !ingressExists {
 		err := errors.New("ingress does not exist")
-		slog.Error("failed to create extclient", "user", r.Header.Get("user"), "error", err)
+		slog.ErrorCtx(r.Context(), "failed to create extclient", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
@@ -339,16 +400,22 @@ func createExtClient(w http.ResponseWriter, r *http.Request) {
 	extclient.IngressGatewayID = nodeid
 	node, err := logic.GetNodeByID(nodeid)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("failed to get ingress gateway node [%s] info: %v", nodeid, err))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("failed to get ingress gateway node [%s] info: %v", nodeid, err), "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
 	extclient.Network = node.Network
+	if err := logic.CheckNetworkFeature(node.Network, models.NetworkFeatureExtClients); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "forbidden"))
+		return
+	}
+	if err := logic.SetExtClientSecondaryGateways(&extclient, customExtClient.SecondaryGatewayIDs); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
 	host, err := logic.GetHost(node.HostID.String())
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("failed to get ingress gateway host for node [%s] info: %v", nodeid, err))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("failed to get ingress gateway host for node [%s] info: %v", nodeid, err), "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
@@ -361,13 +428,18 @@ func createExtClient(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := logic.SetClientDefaultACLs(&extclient); err != nil {
-		slog.Error("failed to set default acls for extclient", "user", r.Header.Get("user"), "network", node.Network, "error", err)
+		slog.ErrorCtx(r.Context(), "failed to set default acls for extclient", "user", r.Header.Get("user"), "network", node.Network, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	if err := logic.ApplyExtClientAccess(&extclient, &node); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to apply access of extclient", "user", r.Header.Get("user"), "network", node.Network, "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
 
 	if err = logic.CreateExtClient(&extclient); err != nil {
-		slog.Error("failed to create extclient", "user", r.Header.Get("user"), "network", node.Network, "error", err)
+		slog.ErrorCtx(r.Context(), "failed to create extclient", "user", r.Header.Get("user"), "network", node.Network, "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
@@ -376,30 +448,39 @@ func createExtClient(w http.ResponseWriter, r *http.Request) {
 	if r.Header.Get("ismaster") != "yes" {
 		userID := r.Header.Get("user")
 		if isAdmin, err = checkProClientAccess(userID, extclient.ClientID, &parentNetwork); err != nil {
-			slog.Error("pro client access check failed", "user", userID, "network", node.Network, "error", err)
+			slog.ErrorCtx(r.Context(), "pro client access check failed", "user", userID, "network", node.Network, "error", err)
 			logic.DeleteExtClient(node.Network, extclient.ClientID)
 			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 			return
 		}
 		if !isAdmin {
 			if err = pro.AssociateNetworkUserClient(userID, node.Network, extclient.ClientID); err != nil {
-				logger.Log(0, "failed to associate client", extclient.ClientID, "to user", userID)
+				slog.ErrorCtx(r.Context(), "failed to associate client to user", "client_id", extclient.ClientID, "user_id", userID)
 			}
 			extclient.OwnerID = userID
+			logic.SetExtClientKeyExpiry(&extclient)
 			if err := logic.SaveExtClient(&extclient); err != nil {
-				logger.Log(0, "failed to add owner id", userID, "to client", extclient.ClientID)
+				slog.ErrorCtx(r.Context(), "failed to add owner id to client", "user_id", userID, "client_id", extclient.ClientID)
 			}
 		}
 	}
 
-	slog.Info("created extclient", "user", r.Header.Get("user"), "network", node.Network, "clientid", extclient.ClientID)
+	slog.InfoCtx(r.Context(), "created extclient", "user", r.Header.Get("user"), "network", node.Network, "clientid", extclient.ClientID)
+	if r.Header.Get("ismaster") != "yes" {
+		logic.RecordExtClientEnrollment(r.Header.Get("user"), &extclient, r)
+	}
 	w.WriteHeader(http.StatusOK)
 	go func() {
-		if err := mq.PublishPeerUpdate(); err != nil {
-			logger.Log(1, "error setting ext peers on "+nodeid+": "+err.Error())
+		if err := mq.PublishNetworkPeerUpdate(extclient.Network); err != nil {
+			slog.ErrorCtx(r.Context(), "error setting ext peers on "+nodeid+": "+err.Error())
+		}
+		if extclient.Enabled {
+			if err := mq.PublishExtCLientDNS(&extclient); err != nil {
+				slog.ErrorCtx(r.Context(), "error publishing extclient dns", "error", err)
+			}
 		}
-		if err := mq.PublishExtCLientDNS(&extclient); err != nil {
-			logger.Log(1, "error publishing extclient dns", err.Error())
+		if servercfg.IsDNSMode() {
+			logic.SetDNS()
 		}
 	}()
 }
@@ -425,15 +506,14 @@ func updateExtClient(w http.ResponseWriter, r *http.Request) {
 	var sendPeerUpdate bool
 	err := json.NewDecoder(r.Body).Decode(&update)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"), "error decoding request body: ",
-			err.Error())
+		slog.ErrorCtx(r.Context(), "error decoding request body", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
 	clientid := params["clientid"]
 	oldExtClient, err := logic.GetExtClientByName(clientid)
 	if err != nil {
-		slog.Error("failed to retrieve extclient", "user", r.Header.Get("user"), "id", clientid, "error", err)
+		slog.ErrorCtx(r.Context(), "failed to retrieve extclient", "user", r.Header.Get("user"), "id", clientid, "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
@@ -462,10 +542,10 @@ func updateExtClient(w http.ResponseWriter, r *http.Request) {
 	}
 	if changedID && oldExtClient.OwnerID != "" {
 		if err := pro.DissociateNetworkUserClient(oldExtClient.OwnerID, oldExtClient.Network, oldExtClient.ClientID); err != nil {
-			logger.Log(0, "failed to dissociate client", oldExtClient.ClientID, "from user", oldExtClient.OwnerID)
+			slog.ErrorCtx(r.Context(), "failed to dissociate client from user", "client_id", oldExtClient.ClientID, "owner_id", oldExtClient.OwnerID)
 		}
 		if err := pro.AssociateNetworkUserClient(oldExtClient.OwnerID, oldExtClient.Network, update.ClientID); err != nil {
-			logger.Log(0, "failed to associate client", update.ClientID, "to user", oldExtClient.OwnerID)
+			slog.ErrorCtx(r.Context(), "failed to associate client to user", "client_id", update.ClientID, "owner_id", oldExtClient.OwnerID)
 		}
 	}
 	if len(update.DeniedACLs) != len(oldExtClient.DeniedACLs) {
@@ -478,39 +558,161 @@ func updateExtClient(w http.ResponseWriter, r *http.Request) {
 		sendPeerUpdate = true
 	}
 	newclient := logic.UpdateExtClient(&oldExtClient, &update)
+	var droppedGateways []string
+	if update.SecondaryGatewayIDs != nil {
+		if err := logic.SetExtClientSecondaryGateways(&newclient, update.SecondaryGatewayIDs); err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		droppedGateways = logic.StringDifference(oldExtClient.SecondaryGatewayIDs, newclient.SecondaryGatewayIDs)
+	}
 	if err := logic.DeleteExtClient(oldExtClient.Network, oldExtClient.ClientID); err != nil {
 
-		slog.Error("failed to delete ext client", "user", r.Header.Get("user"), "id", oldExtClient.ClientID, "network", oldExtClient.Network, "error", err)
+		slog.ErrorCtx(r.Context(), "failed to delete ext client", "user", r.Header.Get("user"), "id", oldExtClient.ClientID, "network", oldExtClient.Network, "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
 	if err := logic.SaveExtClient(&newclient); err != nil {
-		slog.Error("failed to save ext client", "user", r.Header.Get("user"), "id", newclient.ClientID, "network", newclient.Network, "error", err)
+		slog.ErrorCtx(r.Context(), "failed to save ext client", "user", r.Header.Get("user"), "id", newclient.ClientID, "network", newclient.Network, "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
-	logger.Log(0, r.Header.Get("user"), "updated ext client", update.ClientID)
-	if sendPeerUpdate { // need to send a peer update to the ingress node as enablement of one of it's clients has changed
+	slog.InfoCtx(r.Context(), "updated ext client", "user", r.Header.Get("user"), "client_id", update.ClientID)
+	if newclient.PublicKey != oldExtClient.PublicKey {
+		logic.RevokeExtClientKey(&oldExtClient, "rotated")
+	} else if !newclient.Enabled && oldExtClient.Enabled {
+		logic.RevokeExtClientKey(&oldExtClient, "disabled")
+	}
+	if update.SecondaryGatewayIDs != nil {
+		go publishExtClientGateways(&newclient, droppedGateways)
+	} else if sendPeerUpdate { // need to send a peer update to the ingress node as enablement of one of it's clients has changed
 		if ingressNode, err := logic.GetNodeByID(newclient.IngressGatewayID); err == nil {
-			if err = mq.PublishPeerUpdate(); err != nil {
-				logger.Log(1, "error setting ext peers on", ingressNode.ID.String(), ":", err.Error())
+			if err = mq.PublishNodePeerUpdate(&ingressNode); err != nil {
+				slog.ErrorCtx(r.Context(), "error setting ext peers", "ingress_node_id", ingressNode.ID.String(), "error", err)
 			}
 		}
 	}
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(newclient)
-	if changedID {
-		go func() {
+	logic.ReturnJSONResponse(w, r, newclient)
+	go func() {
+		// a disabled client is revoked, its name is only resolved while it's enabled
+		switch {
+		case newclient.Enabled && !oldExtClient.Enabled:
+			if err := mq.PublishExtCLientDNS(&newclient); err != nil {
+				slog.ErrorCtx(r.Context(), "error publishing extclient dns", "error", err)
+			}
+		case !newclient.Enabled && oldExtClient.Enabled:
+			if err := mq.PublishDeleteExtClientDNS(&oldExtClient); err != nil {
+				slog.ErrorCtx(r.Context(), "error publishing dns update for disabled extclient", "error", err)
+			}
+		case changedID:
 			if err := mq.PublishExtClientDNSUpdate(oldExtClient, newclient, oldExtClient.Network); err != nil {
-				logger.Log(1, "error pubishing dns update for extcient update", err.Error())
+				slog.ErrorCtx(r.Context(), "error pubishing dns update for extcient update", "error", err)
 			}
-		}()
+		}
+		if servercfg.IsDNSMode() {
+			logic.SetDNS()
+		}
+	}()
+}
+
+// publishExtClientGateways - peers an ext client with its gateways after they changed,
+// removing it from the secondaries it was dropped from
+func publishExtClientGateways(client *models.ExtClient, dropped []string) {
+	for _, id := range dropped {
+		gateway, err := logic.GetNodeByID(id)
+		if err != nil {
+			continue
+		}
+		host, err := logic.GetHost(gateway.HostID.String())
+		if err != nil {
+			continue
+		}
+		nodes, err := logic.GetAllNodes()
+		if err != nil {
+			return
+		}
+		if err = mq.PublishSingleHostPeerUpdate(host, nodes, nil, []models.ExtClient{*client}); err != nil {
+			slog.Error("failed to remove ext client from its dropped gateway", "client", client.ClientID, "gateway", id, "error", err)
+		}
+	}
+	if err := mq.PublishNetworkPeerUpdate(client.Network); err != nil {
+		slog.Error("failed to publish peer update after ext client gateways changed", "client", client.ClientID, "error", err)
 	}
 }
 
+// swagger:route POST /api/extclients/{network}/{clientid}/renew ext_client renewExtClientKey
+//
+// Rotates the short lived key of an ext client before it expires, to the given public key or a generated pair.
+// Only the client's owner or an admin can renew it, so a revoked user's clients stop working once their key expires.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: extClientCredentialsResponse
+func renewExtClientKey(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	clientid, network := params["clientid"], params["network"]
+	var request models.ExtClientKeyRenewal
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	if request.PublicKey != "" {
+		if _, err := wgtypes.ParseKey(request.PublicKey); err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(errInvalidExtClientPubKey, "badrequest"))
+			return
+		}
+	}
+	client, err := logic.GetExtClient(clientid, network)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	if r.Header.Get("ismaster") != "yes" {
+		if _, doesOwn := doesUserOwnClient(r.Header.Get("user"), clientid, network); !doesOwn {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("user not permitted"), "forbidden"))
+			return
+		}
+	}
+	oldClient := client
+	if err := logic.RenewExtClientKey(&client, request.PublicKey); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to renew ext client key", "user", r.Header.Get("user"), "clientid", clientid, "network", network, "error", err)
+		if errors.Is(err, logic.ErrNotShortLivedKey) || errors.Is(err, logic.ErrSameExtClientKey) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	config, err := extClientConfig(&client)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "renewed ext client key", "user", r.Header.Get("user"), "clientid", clientid, "network", network)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.ExtClientCredentials{
+		Client:     client,
+		Config:     config,
+		KeyExpiry:  time.Unix(client.KeyExpiry, 0).UTC(),
+		RenewAfter: logic.ExtClientKeyRenewAfter(&client).UTC(),
+	})
+	go func() {
+		// the gateways replace the old key with the renewed one
+		if err := mq.PublishDeletedClientPeerUpdate(&oldClient); err != nil {
+			slog.Error("failed to publish peer update for renewed ext client key", "clientid", clientid, "error", err)
+		}
+	}()
+}
+
 // swagger:route DELETE /api/extclients/{network}/{clientid} ext_client deleteExtClient
 //
 // Delete an individual extclient.
+// It can be restored from the trash until its retention is over.
 //
 //			Schemes: https
 //
@@ -530,15 +732,13 @@ func deleteExtClient(w http.ResponseWriter, r *http.Request) {
 	extclient, err := logic.GetExtClient(clientid, network)
 	if err != nil {
 		err = errors.New("Could not delete extclient " + params["clientid"])
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("failed to delete extclient [%s],network [%s]: %v", clientid, network, err))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("failed to delete extclient [%s],network [%s]: %v", clientid, network, err), "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
 	ingressnode, err := logic.GetNodeByID(extclient.IngressGatewayID)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("failed to get ingress gateway node [%s] info: %v", extclient.IngressGatewayID, err))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("failed to get ingress gateway node [%s] info: %v", extclient.IngressGatewayID, err), "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
@@ -555,7 +755,7 @@ func deleteExtClient(w http.ResponseWriter, r *http.Request) {
 
 	if extclient.OwnerID != "" {
 		if err = pro.DissociateNetworkUserClient(extclient.OwnerID, extclient.Network, extclient.ClientID); err != nil {
-			logger.Log(0, "failed to dissociate client", extclient.ClientID, "from user", extclient.OwnerID)
+			slog.ErrorCtx(r.Context(), "failed to dissociate client from user", "client_id", extclient.ClientID, "owner_id", extclient.OwnerID)
 		}
 	}
 
@@ -563,24 +763,29 @@ func deleteExtClient(w http.ResponseWriter, r *http.Request) {
 
 	err = logic.DeleteExtClient(params["network"], params["clientid"])
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("failed to delete extclient [%s],network [%s]: %v", clientid, network, err))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("failed to delete extclient [%s],network [%s]: %v", clientid, network, err), "user", r.Header.Get("user"))
 		err = errors.New("Could not delete extclient " + params["clientid"])
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
+	logic.RevokeExtClientKey(&extclient, "deleted")
+	if err = logic.TrashExtClient(&extclient, r.Header.Get("user")); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to trash deleted extclient", "clientid", clientid, "error", err)
+	}
 
 	go func() {
 		if err := mq.PublishDeletedClientPeerUpdate(&extclient); err != nil {
-			logger.Log(1, "error setting ext peers on "+ingressnode.ID.String()+": "+err.Error())
+			slog.ErrorCtx(r.Context(), "error setting ext peers on "+ingressnode.ID.String()+": "+err.Error())
 		}
 		if err = mq.PublishDeleteExtClientDNS(&extclient); err != nil {
-			logger.Log(1, "error publishing dns update for extclient deletion", err.Error())
+			slog.ErrorCtx(r.Context(), "error publishing dns update for extclient deletion", "error", err)
+		}
+		if servercfg.IsDNSMode() {
+			logic.SetDNS()
 		}
 	}()
 
-	logger.Log(0, r.Header.Get("user"),
-		"Deleted extclient client", params["clientid"], "from network", params["network"])
+	slog.InfoCtx(r.Context(), "Deleted extclient client from network", "user", r.Header.Get("user"), "clientid", params["clientid"], "network", params["network"])
 	logic.ReturnSuccessResponse(w, r, params["clientid"]+" deleted.")
 }
 
@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+func extClientAccessHandlers(r *mux.Router) {
+	r.HandleFunc("/api/networks/{networkname}/extclientaccess", logic.SecurityCheck(true, http.HandlerFunc(setNetworkExtClientAccess))).Methods(http.MethodPut)
+	r.HandleFunc("/api/nodes/{network}/{nodeid}/extclientaccess", logic.SecurityCheck(true, http.HandlerFunc(setGatewayExtClientAccess))).Methods(http.MethodPut)
+	r.HandleFunc("/api/nodes/{network}/{nodeid}/extclientaccess", logic.SecurityCheck(true, http.HandlerFunc(deleteGatewayExtClientAccess))).Methods(http.MethodDelete)
+}
+
+// swagger:route PUT /api/networks/{networkname}/extclientaccess networks setNetworkExtClientAccess
+//
+// Set the access given to new ext clients of a network and the named policies they can be given.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: networkBodyResponse
+func setNetworkExtClientAccess(w http.ResponseWriter, r *http.Request) {
+	netID := mux.Vars(r)["networkname"]
+	network, err := logic.GetNetwork(netID)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	if tenant := r.Header.Get("tenant"); tenant != "" && network.Tenant != tenant {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(logic.ErrTenantMismatch, "forbidden"))
+		return
+	}
+	var settings models.NetworkExtClientAccess
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	network, err = logic.SetNetworkExtClientAccess(netID, &settings)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to update ext client access", "user", r.Header.Get("user"), "network", netID, "error", err)
+		if errors.Is(err, logic.ErrInvalidExtClientAccess) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "updated ext client access", "user", r.Header.Get("user"), "network", netID)
+	logic.ReturnJSONResponse(w, r, network)
+}
+
+// swagger:route PUT /api/nodes/{network}/{nodeid}/extclientaccess nodes setGatewayExtClientAccess
+//
+// Set the access given to new ext clients of an ingress gateway, instead of the network's default.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: nodeResponse
+func setGatewayExtClientAccess(w http.ResponseWriter, r *http.Request) {
+	var access models.ExtClientAccess
+	if err := json.NewDecoder(r.Body).Decode(&access); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	updateGatewayExtClientAccess(w, r, &access)
+}
+
+// swagger:route DELETE /api/nodes/{network}/{nodeid}/extclientaccess nodes deleteGatewayExtClientAccess
+//
+// Give new ext clients of an ingress gateway the network's default access.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: nodeResponse
+func deleteGatewayExtClientAccess(w http.ResponseWriter, r *http.Request) {
+	updateGatewayExtClientAccess(w, r, nil)
+}
+
+func updateGatewayExtClientAccess(w http.ResponseWriter, r *http.Request, access *models.ExtClientAccess) {
+	var params = mux.Vars(r)
+	nodeid := params["nodeid"]
+	netid := params["network"]
+	if _, err := validateParams(nodeid, netid); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	node, err := logic.SetGatewayExtClientAccess(nodeid, access)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to update ext client access", "user", r.Header.Get("user"), "node", nodeid, "network", netid, "error", err)
+		if errors.Is(err, logic.ErrInvalidExtClientAccess) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "updated ext client access", "user", r.Header.Get("user"), "node", nodeid, "network", netid, "default", access == nil)
+	logic.ReturnJSONResponse(w, r, node.ConvertToAPINode())
+}
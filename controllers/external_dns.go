@@ -0,0 +1,189 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+func externalDNSHandlers(r *mux.Router) {
+	r.HandleFunc("/api/v1/dns-providers", logic.SuperAdminCheck(http.HandlerFunc(getExternalDNSProviders))).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/dns-providers", logic.SuperAdminCheck(http.HandlerFunc(createExternalDNSProvider))).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/dns-providers/{providerid}", logic.SuperAdminCheck(http.HandlerFunc(deleteExternalDNSProvider))).Methods(http.MethodDelete)
+	r.HandleFunc("/api/v1/dns-records", logic.SuperAdminCheck(http.HandlerFunc(getExternalDNSRecords))).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/dns-records", logic.SuperAdminCheck(http.HandlerFunc(createExternalDNSRecord))).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/dns-records/{name}", logic.SuperAdminCheck(http.HandlerFunc(deleteExternalDNSRecord))).Methods(http.MethodDelete)
+}
+
+// swagger:route GET /api/v1/dns-providers dns getExternalDNSProviders
+//
+// Lists the external DNS zones records can be published to, without their credentials.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: externalDNSProvidersResponse
+func getExternalDNSProviders(w http.ResponseWriter, r *http.Request) {
+	providers, err := logic.GetExternalDNSProviders()
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to fetch dns providers", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	for i := range providers {
+		providers[i].Credentials = nil
+	}
+	writeList(w, r, providers)
+}
+
+// swagger:route POST /api/v1/dns-providers dns createExternalDNSProvider
+//
+// Adds a Route53, Cloudflare or Google Cloud DNS zone that records can be published to.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: externalDNSProviderResponse
+func createExternalDNSProvider(w http.ResponseWriter, r *http.Request) {
+	var provider models.ExternalDNSProvider
+	if err := json.NewDecoder(r.Body).Decode(&provider); err != nil {
+		slog.ErrorCtx(r.Context(), "error decoding request body", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	if err := logic.CreateExternalDNSProvider(&provider); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to create dns provider", "user", r.Header.Get("user"), "zone", provider.Zone, "error", err)
+		var validationErrs validator.ValidationErrors
+		if errors.As(err, &validationErrs) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "created dns provider", "user", r.Header.Get("user"), "type", provider.Type, "zone", provider.Zone)
+	provider.Credentials = nil
+	logic.ReturnJSONResponse(w, r, provider)
+}
+
+// swagger:route DELETE /api/v1/dns-providers/{providerid} dns deleteExternalDNSProvider
+//
+// Removes an external DNS zone that no longer has records published to it.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: successResponse
+func deleteExternalDNSProvider(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["providerid"]
+	if err := logic.DeleteExternalDNSProvider(id); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to delete dns provider", "user", r.Header.Get("user"), "provider", id, "error", err)
+		switch {
+		case database.IsEmptyRecord(err):
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		case errors.Is(err, logic.ErrDNSProviderInUse):
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "conflict"))
+		default:
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		}
+		return
+	}
+	slog.InfoCtx(r.Context(), "deleted dns provider", "user", r.Header.Get("user"), "provider", id)
+	logic.ReturnSuccessResponse(w, r, "deleted dns provider "+id)
+}
+
+// swagger:route GET /api/v1/dns-records dns getExternalDNSRecords
+//
+// Lists the records published to external DNS zones and the addresses they were last published with.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: externalDNSRecordsResponse
+func getExternalDNSRecords(w http.ResponseWriter, r *http.Request) {
+	records, err := logic.GetExternalDNSRecords()
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to fetch dns records", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	writeList(w, r, records)
+}
+
+// swagger:route POST /api/v1/dns-records dns createExternalDNSRecord
+//
+// Publishes the public endpoint of a node's host under a name in an external DNS zone, eg. gw1.company.com,
+// updating the record whenever the endpoint changes.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: externalDNSRecordResponse
+func createExternalDNSRecord(w http.ResponseWriter, r *http.Request) {
+	var record models.ExternalDNSRecord
+	if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+		slog.ErrorCtx(r.Context(), "error decoding request body", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	if err := logic.CreateExternalDNSRecord(&record); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to create dns record", "user", r.Header.Get("user"), "name", record.Name, "error", err)
+		var validationErrs validator.ValidationErrors
+		if errors.As(err, &validationErrs) || errors.Is(err, logic.ErrRecordOutsideZone) || database.IsEmptyRecord(err) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "created dns record", "user", r.Header.Get("user"), "name", record.Name, "node", record.NodeID)
+	logic.ReturnJSONResponse(w, r, record)
+}
+
+// swagger:route DELETE /api/v1/dns-records/{name} dns deleteExternalDNSRecord
+//
+// Removes a record from its external DNS zone.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: successResponse
+func deleteExternalDNSRecord(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if err := logic.DeleteExternalDNSRecord(name); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to delete dns record", "user", r.Header.Get("user"), "name", name, "error", err)
+		if database.IsEmptyRecord(err) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "deleted dns record", "user", r.Header.Get("user"), "name", name)
+	logic.ReturnSuccessResponse(w, r, "deleted dns record "+name)
+}
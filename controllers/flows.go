@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/mq"
+	"golang.org/x/exp/slog"
+)
+
+func flowHandlers(r *mux.Router) {
+	r.HandleFunc("/api/flows/{network}", logic.SecurityCheck(false, http.HandlerFunc(getNetworkFlows))).Methods(http.MethodGet)
+	r.HandleFunc("/api/nodes/{network}/{nodeid}/flowexport", logic.SecurityCheck(false, http.HandlerFunc(enableFlowExport))).Methods(http.MethodPost)
+	r.HandleFunc("/api/nodes/{network}/{nodeid}/flowexport", logic.SecurityCheck(false, http.HandlerFunc(disableFlowExport))).Methods(http.MethodDelete)
+}
+
+// swagger:route GET /api/flows/{network} flows getNetworkFlows
+//
+// Lists recent flows traversing the gateways of a network.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: flowsResponse
+func getNetworkFlows(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var params = mux.Vars(r)
+	network := params["network"]
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	flows, err := logic.GetRecentFlows(network, r.URL.Query().Get("nodeid"), limit)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to fetch flows for network", "user", r.Header.Get("user"), "network", network, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.DebugCtx(r.Context(), "fetched flows for network", "user", r.Header.Get("user"), "network", network)
+	logic.ReturnJSONResponse(w, r, flows)
+}
+
+// swagger:route POST /api/nodes/{network}/{nodeid}/flowexport nodes enableFlowExport
+//
+// Enable flow export on a gateway.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: nodeResponse
+func enableFlowExport(w http.ResponseWriter, r *http.Request) {
+	setFlowExport(w, r, true)
+}
+
+// swagger:route DELETE /api/nodes/{network}/{nodeid}/flowexport nodes disableFlowExport
+//
+// Disable flow export on a gateway.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: nodeResponse
+func disableFlowExport(w http.ResponseWriter, r *http.Request) {
+	setFlowExport(w, r, false)
+}
+
+func setFlowExport(w http.ResponseWriter, r *http.Request, enable bool) {
+	w.Header().Set("Content-Type", "application/json")
+	var params = mux.Vars(r)
+	nodeid := params["nodeid"]
+	netid := params["network"]
+	if _, err := validateParams(nodeid, netid); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	node, err := logic.SetFlowExport(nodeid, enable)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to update flow export", "user", r.Header.Get("user"), "node", nodeid, "network", netid, "error", err)
+		if errors.Is(err, logic.ErrFlowExportNotGateway) || errors.Is(err, logic.ErrNoFlowCollector) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "updated flow export", "user", r.Header.Get("user"), "node", nodeid, "network", netid, "enabled", enable)
+	logic.ReturnJSONResponse(w, r, node.ConvertToAPINode())
+
+	go func() {
+		host, err := logic.GetHost(node.HostID.String())
+		if err != nil {
+			return
+		}
+		allNodes, err := logic.GetAllNodes()
+		if err != nil {
+			return
+		}
+		if err = mq.PublishSingleHostPeerUpdate(host, allNodes, nil, nil); err != nil {
+			slog.WarnCtx(r.Context(), "failed to publish flow export config", "host", host.ID, "error", err)
+		}
+	}()
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/mq"
+	"github.com/gravitl/netmaker/servercfg"
+)
+
+func healthHandlers(r *mux.Router) {
+	r.HandleFunc("/healthz", http.HandlerFunc(getHealthz)).Methods(http.MethodGet)
+	r.HandleFunc("/readyz", http.HandlerFunc(getReadyz)).Methods(http.MethodGet)
+}
+
+// swagger:route GET /healthz server getHealthz
+//
+// Liveness probe, checks the server's background workers are running.
+//
+//	Schemes: https
+//
+//	Responses:
+//		200: healthReportResponse
+//		503: healthReportResponse
+func getHealthz(w http.ResponseWriter, r *http.Request) {
+	writeHealthReport(w, logic.CheckWorkers())
+}
+
+// swagger:route GET /readyz server getReadyz
+//
+// Readiness probe, checks the server isn't shutting down and can reach its database, broker and DNS.
+//
+//	Schemes: https
+//
+//	Responses:
+//		200: healthReportResponse
+//		503: healthReportResponse
+func getReadyz(w http.ResponseWriter, r *http.Request) {
+	checks := []models.HealthCheck{healthCheck("draining", checkDraining()), healthCheck("database", checkDatabase())}
+	if servercfg.IsMessageQueueBackend() {
+		checks = append(checks, healthCheck("broker", checkBroker()))
+	}
+	if servercfg.IsDNSMode() {
+		checks = append(checks, healthCheck("dns", logic.CheckDNS()))
+	}
+	writeHealthReport(w, checks)
+}
+
+func checkDraining() error {
+	if logic.IsDraining() {
+		return errors.New("server is shutting down")
+	}
+	return nil
+}
+
+func checkDatabase() error {
+	if !database.IsConnected() {
+		return errors.New("database is not connected")
+	}
+	return nil
+}
+
+func checkBroker() error {
+	if !mq.IsConnected() {
+		return errors.New("broker is not connected")
+	}
+	return nil
+}
+
+func healthCheck(name string, err error) models.HealthCheck {
+	check := models.HealthCheck{Name: name, Healthy: err == nil}
+	if err != nil {
+		check.Error = err.Error()
+	}
+	return check
+}
+
+// writeHealthReport - responds with the checks, failing with 503 if any of them is unhealthy
+func writeHealthReport(w http.ResponseWriter, checks []models.HealthCheck) {
+	report := models.HealthReport{Healthy: true, Checks: checks}
+	for _, check := range checks {
+		if !check.Healthy {
+			report.Healthy = false
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if report.Healthy {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(&report)
+}
@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthProbes(t *testing.T) {
+	probe := func(handler http.HandlerFunc) (int, models.HealthReport) {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		var report models.HealthReport
+		assert.Nil(t, json.NewDecoder(w.Body).Decode(&report))
+		return w.Code, report
+	}
+	checked := func(report models.HealthReport, name string) models.HealthCheck {
+		for _, check := range report.Checks {
+			if check.Name == name {
+				return check
+			}
+		}
+		t.Fatalf("%s wasn't checked", name)
+		return models.HealthCheck{}
+	}
+
+	t.Run("Healthz", func(t *testing.T) {
+		logic.WorkerHeartbeat("test-worker", time.Minute)
+		defer logic.StopWorker("test-worker")
+		_, report := probe(getHealthz)
+		assert.True(t, checked(report, "test-worker").Healthy)
+	})
+	t.Run("Readyz", func(t *testing.T) {
+		_, report := probe(getReadyz)
+		assert.True(t, checked(report, "database").Healthy)
+		assert.True(t, checked(report, "draining").Healthy)
+	})
+	t.Run("Draining", func(t *testing.T) {
+		// draining can't be undone, nothing else in the package reads it
+		logic.SetDraining()
+		code, report := probe(getReadyz)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.False(t, report.Healthy)
+		assert.Equal(t, "server is shutting down", checked(report, "draining").Error)
+	})
+}
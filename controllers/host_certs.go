@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
+)
+
+func hostCertHandlers(r *mux.Router) {
+	r.HandleFunc("/api/v1/host/ca", http.HandlerFunc(getHostCA)).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/host/crl", http.HandlerFunc(getHostCRL)).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/host/{hostid}/certificate", Authorize(true, false, "host", http.HandlerFunc(renewHostCertificate))).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/host/{hostid}/certificate", logic.SecurityCheck(true, http.HandlerFunc(revokeHostCertificates))).Methods(http.MethodDelete)
+}
+
+// swagger:route GET /api/v1/host/ca hosts getHostCA
+//
+// Get the PEM certificate of the CA that issues host client certificates, for the broker to trust.
+//
+//	Schemes: https
+//
+//	Responses:
+//		200: byteArrayResponse
+func getHostCA(w http.ResponseWriter, r *http.Request) {
+	ca, err := logic.GetCA()
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	w.WriteHeader(http.StatusOK)
+	w.Write(ca)
+}
+
+// swagger:route GET /api/v1/host/crl hosts getHostCRL
+//
+// Get the PEM revocation list of revoked host client certificates, for the broker to refuse them.
+//
+//	Schemes: https
+//
+//	Responses:
+//		200: byteArrayResponse
+func getHostCRL(w http.ResponseWriter, r *http.Request) {
+	crl, err := logic.GetCRL()
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/pkix-crl")
+	w.WriteHeader(http.StatusOK)
+	w.Write(crl)
+}
+
+// swagger:route POST /api/v1/host/{hostid}/certificate hosts renewHostCertificate
+//
+// Issues a host a new client certificate from a certificate request, hosts renew their own before it expires.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: issuedCertificateResponse
+func renewHostCertificate(w http.ResponseWriter, r *http.Request) {
+	if !servercfg.IsHostCertAuth() {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("host certificate auth is not enabled"), "badrequest"))
+		return
+	}
+	hostID := mux.Vars(r)["hostid"]
+	if caller := r.Header.Get(hostIDHeader); caller != "" && caller != hostID {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("hosts can only renew their own certificate"), "forbidden"))
+		return
+	}
+	host, err := logic.GetHost(hostID)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	var request models.HostCertificateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	cert, err := logic.IssueHostCertificate(host, request.CertificateRequest)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "issued certificate to host", "serial", cert.Serial, "host_name", host.Name, "host_id", hostID)
+	logic.ReturnJSONResponse(w, r, cert)
+}
+
+// swagger:route DELETE /api/v1/host/{hostid}/certificate hosts revokeHostCertificates
+//
+// Revokes every client certificate of a host, it has to register again to get a new one.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: successResponse
+func revokeHostCertificates(w http.ResponseWriter, r *http.Request) {
+	hostID := mux.Vars(r)["hostid"]
+	if err := logic.RevokeHostCertificates(hostID, "revoked by "+r.Header.Get("user")); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "revoked the certificates of host", "user", r.Header.Get("user"), "host_id", hostID)
+	logic.ReturnSuccessResponse(w, r, "revoked the certificates of host "+hostID)
+}
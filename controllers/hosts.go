@@ -5,9 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
-	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/database"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/mq"
@@ -18,8 +20,18 @@ import (
 
 func hostHandlers(r *mux.Router) {
 	r.HandleFunc("/api/hosts", logic.SecurityCheck(false, http.HandlerFunc(getHosts))).Methods(http.MethodGet)
-	r.HandleFunc("/api/hosts/keys", logic.SecurityCheck(true, http.HandlerFunc(updateAllKeys))).Methods(http.MethodPut)
+	r.HandleFunc("/api/hosts/keys", logic.SuperAdminCheck(http.HandlerFunc(updateAllKeys))).Methods(http.MethodPut)
+	r.HandleFunc("/api/hosts/quarantines", logic.SuperAdminCheck(http.HandlerFunc(getHostQuarantines))).Methods(http.MethodGet)
+	r.HandleFunc("/api/hosts/drift", logic.SuperAdminCheck(http.HandlerFunc(getDriftedHosts))).Methods(http.MethodGet)
+	r.HandleFunc("/api/hosts/{hostid}/drift", logic.SecurityCheck(true, http.HandlerFunc(getHostDrift))).Methods(http.MethodGet)
+	r.HandleFunc("/api/hosts/{hostid}/quarantine", logic.SecurityCheck(true, http.HandlerFunc(quarantineHost))).Methods(http.MethodPost)
+	r.HandleFunc("/api/hosts/{hostid}/quarantine", logic.SecurityCheck(true, http.HandlerFunc(releaseHostQuarantine))).Methods(http.MethodDelete)
 	r.HandleFunc("/api/hosts/{hostid}/keys", logic.SecurityCheck(true, http.HandlerFunc(updateKeys))).Methods(http.MethodPut)
+	r.HandleFunc("/api/hosts/{hostid}/tuning", logic.SecurityCheck(true, http.HandlerFunc(getHostTuning))).Methods(http.MethodGet)
+	r.HandleFunc("/api/hosts/{hostid}/tuning", logic.SecurityCheck(true, http.HandlerFunc(tuneHost))).Methods(http.MethodPut)
+	r.HandleFunc("/api/hosts/{hostid}/nat", logic.SecurityCheck(true, http.HandlerFunc(getHostNat))).Methods(http.MethodGet)
+	r.HandleFunc("/api/hosts/{hostid}/metrics", logic.SecurityCheck(true, http.HandlerFunc(getHostResourceMetrics))).Methods(http.MethodGet)
+	r.HandleFunc("/api/hosts/{hostid}/publishstatus", logic.SecurityCheck(true, http.HandlerFunc(getHostPublishStatus))).Methods(http.MethodGet)
 	r.HandleFunc("/api/hosts/{hostid}/sync", logic.SecurityCheck(true, http.HandlerFunc(syncHost))).Methods(http.MethodPost)
 	r.HandleFunc("/api/hosts/{hostid}", logic.SecurityCheck(true, http.HandlerFunc(updateHost))).Methods(http.MethodPut)
 	r.HandleFunc("/api/hosts/{hostid}", logic.SecurityCheck(true, http.HandlerFunc(deleteHost))).Methods(http.MethodDelete)
@@ -33,7 +45,8 @@ func hostHandlers(r *mux.Router) {
 
 // swagger:route GET /api/hosts hosts getHosts
 //
-// Lists all hosts.
+// Lists all hosts. Accepts fields to pick the fields returned, and watch=true with
+// resourceVersion (from the X-Resource-Version header) to wait for host changes instead.
 //
 //			Schemes: https
 //
@@ -43,17 +56,31 @@ func hostHandlers(r *mux.Router) {
 //			Responses:
 //				200: getHostsSliceResponse
 func getHosts(w http.ResponseWriter, r *http.Request) {
+	if isWatch(r) {
+		watchHosts(w, r)
+		return
+	}
+	version := logic.CurrentResourceVersion()
 	currentHosts, err := logic.GetAllHosts()
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"), "failed to fetch hosts: ", err.Error())
+		slog.ErrorCtx(r.Context(), "failed to fetch hosts", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
+	if tenant := r.Header.Get("tenant"); tenant != "" {
+		tenantHosts := []models.Host{}
+		for i := range currentHosts {
+			if logic.HostInTenant(&currentHosts[i], tenant) {
+				tenantHosts = append(tenantHosts, currentHosts[i])
+			}
+		}
+		currentHosts = tenantHosts
+	}
 	apiHosts := logic.GetAllHostsAPI(currentHosts[:])
-	logger.Log(2, r.Header.Get("user"), "fetched all hosts")
+	slog.DebugCtx(r.Context(), "fetched all hosts", "user", r.Header.Get("user"))
 	logic.SortApiHosts(apiHosts[:])
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(apiHosts)
+	setResourceVersion(w, version)
+	writeList(w, r, apiHosts)
 }
 
 // swagger:route GET /api/v1/host pull pullHost
@@ -71,25 +98,25 @@ func pull(w http.ResponseWriter, r *http.Request) {
 
 	hostID := r.Header.Get(hostIDHeader) // return JSON/API formatted keys
 	if len(hostID) == 0 {
-		logger.Log(0, "no host authorized to pull")
+		slog.InfoCtx(r.Context(), "no host authorized to pull")
 		logic.ReturnErrorResponse(w, r, logic.FormatError(fmt.Errorf("no host authorized to pull"), "internal"))
 		return
 	}
 	host, err := logic.GetHost(hostID)
 	if err != nil {
-		logger.Log(0, "no host found during pull", hostID)
+		slog.InfoCtx(r.Context(), "no host found during pull", "host_id", hostID)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
 	allNodes, err := logic.GetAllNodes()
 	if err != nil {
-		logger.Log(0, "could not pull peers for host", hostID)
+		slog.ErrorCtx(r.Context(), "could not pull peers for host", "host_id", hostID)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
 	hPU, err := logic.GetPeerUpdateForHost("", host, allNodes, nil, nil)
 	if err != nil {
-		logger.Log(0, "could not pull peers for host", hostID)
+		slog.ErrorCtx(r.Context(), "could not pull peers for host", "host_id", hostID)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
@@ -99,23 +126,30 @@ func pull(w http.ResponseWriter, r *http.Request) {
 	}
 	key, keyErr := logic.RetrievePublicTrafficKey()
 	if keyErr != nil {
-		logger.Log(0, "error retrieving key:", keyErr.Error())
+		slog.ErrorCtx(r.Context(), "error retrieving key", "error", keyErr)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
 
 	serverConf.TrafficKey = key
+	signingKeys, err := logic.GetSigningKeys()
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "error retrieving signing keys", "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
 	response := models.HostPull{
 		Host:         *host,
 		Nodes:        logic.GetHostNodes(host),
 		ServerConfig: serverConf,
 		Peers:        hPU.Peers,
 		PeerIDs:      hPU.PeerIDs,
+		JoinConfigs:  logic.GetHostJoinConfigs(host),
+		SigningKeys:  signingKeys,
 	}
 
-	logger.Log(1, hostID, "completed a pull")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(&response)
+	slog.InfoCtx(r.Context(), "completed a pull", "host_id", hostID)
+	logic.ReturnJSONResponse(w, r, &response)
 }
 
 // swagger:route PUT /api/hosts/{hostid} hosts updateHost
@@ -133,7 +167,7 @@ func updateHost(w http.ResponseWriter, r *http.Request) {
 	var newHostData models.ApiHost
 	err := json.NewDecoder(r.Body).Decode(&newHostData)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"), "failed to update a host:", err.Error())
+		slog.ErrorCtx(r.Context(), "failed to update a host", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
@@ -141,16 +175,25 @@ func updateHost(w http.ResponseWriter, r *http.Request) {
 	// confirm host exists
 	currHost, err := logic.GetHost(newHostData.ID)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"), "failed to update a host:", err.Error())
+		slog.ErrorCtx(r.Context(), "failed to update a host", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
 
 	newHost := newHostData.ConvertAPIHostToNMHost(currHost)
+	if err := logic.ValidateHostEndpoints(newHost.Endpoints); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to update a host", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
 
 	logic.UpdateHost(newHost, currHost) // update the in memory struct values
 	if err = logic.UpsertHost(newHost); err != nil {
-		logger.Log(0, r.Header.Get("user"), "failed to update a host:", err.Error())
+		slog.ErrorCtx(r.Context(), "failed to update a host", "user", r.Header.Get("user"), "error", err)
+		if errors.Is(err, logic.ErrStaleRevision) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "conflict"))
+			return
+		}
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
@@ -159,31 +202,31 @@ func updateHost(w http.ResponseWriter, r *http.Request) {
 		Action: models.UpdateHost,
 		Host:   *newHost,
 	}); err != nil {
-		logger.Log(0, r.Header.Get("user"), "failed to send host update: ", currHost.ID.String(), err.Error())
+		slog.ErrorCtx(r.Context(), "failed to send host update", "user", r.Header.Get("user"), "host_id", currHost.ID.String(), "error", err)
 	}
 	go func() {
-		if err := mq.PublishPeerUpdate(); err != nil {
-			logger.Log(0, "fail to publish peer update: ", err.Error())
+		if err := mq.PublishHostPeerUpdate(newHost); err != nil {
+			slog.ErrorCtx(r.Context(), "fail to publish peer update", "error", err)
 		}
 		if newHost.Name != currHost.Name {
-			networks := logic.GetHostNetworks(currHost.ID.String())
+			// nodes with names of their own keep them
+			networks := logic.GetHostNamedNetworks(currHost.ID.String())
 			if err := mq.PublishHostDNSUpdate(currHost, newHost, networks); err != nil {
 				var dnsError *models.DNSError
 				if errors.Is(err, dnsError) {
 					for _, message := range err.(models.DNSError).ErrorStrings {
-						logger.Log(0, message)
+						slog.ErrorCtx(r.Context(), message)
 					}
 				} else {
-					logger.Log(0, err.Error())
+					slog.ErrorCtx(r.Context(), err.Error())
 				}
 			}
 		}
 	}()
 
 	apiHostData := newHost.ConvertNMHostToAPI()
-	logger.Log(2, r.Header.Get("user"), "updated host", newHost.ID.String())
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(apiHostData)
+	slog.DebugCtx(r.Context(), "updated host", "user", r.Header.Get("user"), "new_host_id", newHost.ID.String())
+	logic.ReturnJSONResponse(w, r, apiHostData)
 }
 
 // swagger:route DELETE /api/hosts/{hostid} hosts deleteHost
@@ -205,12 +248,12 @@ func deleteHost(w http.ResponseWriter, r *http.Request) {
 	// confirm host exists
 	currHost, err := logic.GetHost(hostid)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"), "failed to delete a host:", err.Error())
+		slog.ErrorCtx(r.Context(), "failed to delete a host", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
 	if err = logic.RemoveHost(currHost, forceDelete); err != nil {
-		logger.Log(0, r.Header.Get("user"), "failed to delete a host:", err.Error())
+		slog.ErrorCtx(r.Context(), "failed to delete a host", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
@@ -218,13 +261,12 @@ func deleteHost(w http.ResponseWriter, r *http.Request) {
 		Action: models.DeleteHost,
 		Host:   *currHost,
 	}); err != nil {
-		logger.Log(0, r.Header.Get("user"), "failed to send delete host update: ", currHost.ID.String(), err.Error())
+		slog.ErrorCtx(r.Context(), "failed to send delete host update", "user", r.Header.Get("user"), "host_id", currHost.ID.String(), "error", err)
 	}
 
 	apiHostData := currHost.ConvertNMHostToAPI()
-	logger.Log(2, r.Header.Get("user"), "removed host", currHost.Name)
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(apiHostData)
+	slog.DebugCtx(r.Context(), "removed host", "user", r.Header.Get("user"), "host_name", currHost.Name)
+	logic.ReturnJSONResponse(w, r, apiHostData)
 }
 
 // swagger:route POST /api/hosts/{hostid}/networks/{network} hosts addHostToNetwork
@@ -250,27 +292,27 @@ func addHostToNetwork(w http.ResponseWriter, r *http.Request) {
 	// confirm host exists
 	currHost, err := logic.GetHost(hostid)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"), "failed to find host:", hostid, err.Error())
+		slog.ErrorCtx(r.Context(), "failed to find host", "user", r.Header.Get("user"), "hostid", hostid, "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
 
 	newNode, err := logic.UpdateHostNetwork(currHost, network, true)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"), "failed to add host to network:", hostid, network, err.Error())
+		slog.ErrorCtx(r.Context(), "failed to add host to network", "user", r.Header.Get("user"), "hostid", hostid, "network", network, "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
-	logger.Log(1, "added new node", newNode.ID.String(), "to host", currHost.Name)
+	slog.InfoCtx(r.Context(), "added new node to host", "new_node_id", newNode.ID.String(), "host_name", currHost.Name)
 	go func() {
 		mq.HostUpdate(&models.HostUpdate{
 			Action: models.JoinHostToNetwork,
 			Host:   *currHost,
 			Node:   *newNode,
 		})
-		mq.PublishPeerUpdate()
+		mq.PublishNodePeerUpdate(newNode)
 	}()
-	logger.Log(2, r.Header.Get("user"), fmt.Sprintf("added host %s to network %s", currHost.Name, network))
+	slog.DebugCtx(r.Context(), fmt.Sprintf("added host %s to network %s", currHost.Name, network), "user", r.Header.Get("user"))
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -298,14 +340,14 @@ func deleteHostFromNetwork(w http.ResponseWriter, r *http.Request) {
 	// confirm host exists
 	currHost, err := logic.GetHost(hostid)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"), "failed to find host:", err.Error())
+		slog.ErrorCtx(r.Context(), "failed to find host", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
 
 	node, err := logic.UpdateHostNetwork(currHost, network, false)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"), "failed to remove host from network:", hostid, network, err.Error())
+		slog.ErrorCtx(r.Context(), "failed to remove host from network", "user", r.Header.Get("user"), "hostid", hostid, "network", network, "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
@@ -328,7 +370,7 @@ func deleteHostFromNetwork(w http.ResponseWriter, r *http.Request) {
 		// unset all the relayed nodes
 		logic.SetRelayedNodes(false, node.ID.String(), node.RelayedNodes)
 	}
-	logger.Log(1, "deleting node", node.ID.String(), "from host", currHost.Name)
+	slog.InfoCtx(r.Context(), "deleting node from host", "node_id", node.ID.String(), "host_name", currHost.Name)
 	if err := logic.DeleteNode(node, forceDelete); err != nil {
 		logic.ReturnErrorResponse(w, r, logic.FormatError(fmt.Errorf("failed to delete node"), "internal"))
 		return
@@ -340,13 +382,13 @@ func deleteHostFromNetwork(w http.ResponseWriter, r *http.Request) {
 	go func() { // notify of peer change
 		err = mq.PublishDeletedNodePeerUpdate(node)
 		if err != nil {
-			logger.Log(1, "error publishing peer update ", err.Error())
+			slog.ErrorCtx(r.Context(), "error publishing peer update", "error", err)
 		}
 		if err := mq.PublishDNSDelete(node, currHost); err != nil {
-			logger.Log(1, "error publishing dns update", err.Error())
+			slog.ErrorCtx(r.Context(), "error publishing dns update", "error", err)
 		}
 	}()
-	logger.Log(2, r.Header.Get("user"), fmt.Sprintf("removed host %s from network %s", currHost.Name, network))
+	slog.DebugCtx(r.Context(), fmt.Sprintf("removed host %s from network %s", currHost.Name, network), "user", r.Header.Get("user"))
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -366,6 +408,10 @@ func authenticateHost(response http.ResponseWriter, request *http.Request) {
 	var errorResponse = models.ErrorResponse{
 		Code: http.StatusInternalServerError, Message: "W1R3: It's not you it's me.",
 	}
+	if servercfg.IsHostCertAuth() {
+		logic.ReturnErrorResponse(response, request, logic.FormatError(errors.New("hosts authenticate with client certificates"), "unauthorized"))
+		return
+	}
 
 	decoder := json.NewDecoder(request.Body)
 	decoderErr := decoder.Decode(&authRequest)
@@ -374,20 +420,19 @@ func authenticateHost(response http.ResponseWriter, request *http.Request) {
 	if decoderErr != nil {
 		errorResponse.Code = http.StatusBadRequest
 		errorResponse.Message = decoderErr.Error()
-		logger.Log(0, request.Header.Get("user"), "error decoding request body: ",
-			decoderErr.Error())
+		slog.ErrorCtx(request.Context(), "error decoding request body", "user", request.Header.Get("user"), "error", decoderErr)
 		logic.ReturnErrorResponse(response, request, errorResponse)
 		return
 	}
 	errorResponse.Code = http.StatusBadRequest
 	if authRequest.ID == "" {
 		errorResponse.Message = "W1R3: ID can't be empty"
-		logger.Log(0, request.Header.Get("user"), errorResponse.Message)
+		slog.ErrorCtx(request.Context(), errorResponse.Message, "user", request.Header.Get("user"))
 		logic.ReturnErrorResponse(response, request, errorResponse)
 		return
 	} else if authRequest.Password == "" {
 		errorResponse.Message = "W1R3: Password can't be empty"
-		logger.Log(0, request.Header.Get("user"), errorResponse.Message)
+		slog.ErrorCtx(request.Context(), errorResponse.Message, "user", request.Header.Get("user"))
 		logic.ReturnErrorResponse(response, request, errorResponse)
 		return
 	}
@@ -395,17 +440,19 @@ func authenticateHost(response http.ResponseWriter, request *http.Request) {
 	if err != nil {
 		errorResponse.Code = http.StatusBadRequest
 		errorResponse.Message = err.Error()
-		logger.Log(0, request.Header.Get("user"),
-			"error retrieving host: ", err.Error())
+		slog.ErrorCtx(request.Context(), "error retrieving host", "user", request.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(response, request, errorResponse)
 		return
 	}
+	if err := logic.CheckHostQuarantine(host, request); err != nil {
+		logic.ReturnErrorResponse(response, request, logic.FormatError(err, "unauthorized"))
+		return
+	}
 	err = bcrypt.CompareHashAndPassword([]byte(host.HostPass), []byte(authRequest.Password))
 	if err != nil {
 		errorResponse.Code = http.StatusUnauthorized
 		errorResponse.Message = "unauthorized"
-		logger.Log(0, request.Header.Get("user"),
-			"error validating user password: ", err.Error())
+		slog.ErrorCtx(request.Context(), "error validating user password", "user", request.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(response, request, errorResponse)
 		return
 	}
@@ -414,8 +461,7 @@ func authenticateHost(response http.ResponseWriter, request *http.Request) {
 	if tokenString == "" {
 		errorResponse.Code = http.StatusUnauthorized
 		errorResponse.Message = "unauthorized"
-		logger.Log(0, request.Header.Get("user"),
-			fmt.Sprintf("%s: %v", errorResponse.Message, err))
+		slog.InfoCtx(request.Context(), fmt.Sprintf("%s: %v", errorResponse.Message, err), "user", request.Header.Get("user"))
 		logic.ReturnErrorResponse(response, request, errorResponse)
 		return
 	}
@@ -433,8 +479,7 @@ func authenticateHost(response http.ResponseWriter, request *http.Request) {
 	if jsonError != nil {
 		errorResponse.Code = http.StatusBadRequest
 		errorResponse.Message = err.Error()
-		logger.Log(0, request.Header.Get("user"),
-			"error marshalling resp: ", err.Error())
+		slog.ErrorCtx(request.Context(), "error marshalling resp", "user", request.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(response, request, errorResponse)
 		return
 	}
@@ -460,7 +505,7 @@ func signalPeer(w http.ResponseWriter, r *http.Request) {
 	// confirm host exists
 	_, err := logic.GetHost(hostid)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"), "failed to get host:", err.Error())
+		slog.ErrorCtx(r.Context(), "failed to get host", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
@@ -468,13 +513,13 @@ func signalPeer(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewDecoder(r.Body).Decode(&signal)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"), "error decoding request body: ", err.Error())
+		slog.ErrorCtx(r.Context(), "error decoding request body", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
 	if signal.ToHostPubKey == "" || signal.TurnRelayEndpoint == "" {
 		msg := "insufficient data to signal peer"
-		logger.Log(0, r.Header.Get("user"), msg)
+		slog.ErrorCtx(r.Context(), msg, "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New(msg), "badrequest"))
 		return
 	}
@@ -505,13 +550,13 @@ func signalPeer(w http.ResponseWriter, r *http.Request) {
 		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("failed to signal, peer not found"), "badrequest"))
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(signal)
+	logic.ReturnJSONResponse(w, r, signal)
 }
 
 // swagger:route POST /api/hosts/keys host updateAllKeys
 //
 // Update keys for a network.
+// With dryRun=true, returns the hosts that would rotate their keys and those held for a maintenance window.
 //
 //			Schemes: https
 //
@@ -527,26 +572,70 @@ func updateAllKeys(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		errorResponse.Code = http.StatusBadRequest
 		errorResponse.Message = err.Error()
-		logger.Log(0, r.Header.Get("user"),
-			"error retrieving hosts ", err.Error())
+		slog.ErrorCtx(r.Context(), "error retrieving hosts", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, errorResponse)
 		return
 	}
+	emergency := r.URL.Query().Get("emergency") == "true"
+	user := r.Header.Get("user")
+	if isDryRun(r) {
+		writeDryRun(w, r, dryRunAllKeys(hosts, emergency))
+		return
+	}
 	go func() {
 		hostUpdate := models.HostUpdate{}
 		hostUpdate.Action = models.UpdateKeys
+		now := time.Now().UTC()
 		for _, host := range hosts {
+			host := host
+			if !emergency && !logic.HostInMaintenanceWindow(&host, now) {
+				// rotated in the next maintenance window of the host
+				if err := logic.QueuePendingChange(&models.PendingChange{HostID: host.ID.String(), Action: models.UpdateKeys, User: user}); err != nil {
+					slog.ErrorCtx(r.Context(), "failed to queue key update", "host_id", host.ID.String(), "error", err)
+				}
+				continue
+			}
 			hostUpdate.Host = host
-			logger.Log(2, "updating host", host.ID.String(), " for a key update")
+			slog.DebugCtx(r.Context(), "updating host for a key update", "host_id", host.ID.String())
 			if err = mq.HostUpdate(&hostUpdate); err != nil {
-				logger.Log(0, "failed to send update to node during a network wide key update", host.ID.String(), err.Error())
+				slog.ErrorCtx(r.Context(), "failed to send update to node during a network wide key update", "host_id", host.ID.String(), "error", err)
 			}
 		}
 	}()
-	logger.Log(2, r.Header.Get("user"), "updated keys for all hosts")
+	slog.DebugCtx(r.Context(), "updated keys for all hosts", "user", r.Header.Get("user"))
 	w.WriteHeader(http.StatusOK)
 }
 
+// dryRunAllKeys - the hosts that would rotate their keys now, those held for a maintenance window,
+// and the hosts sharing a network with the rotated ones that would get a peer update
+func dryRunAllKeys(hosts []models.Host, emergency bool) models.DryRunResult {
+	rotated, held := []string{}, []string{}
+	nodes := []*models.Node{}
+	now := time.Now().UTC()
+	for i := range hosts {
+		host := &hosts[i]
+		if !emergency && !logic.HostInMaintenanceWindow(host, now) {
+			held = append(held, host.ID.String())
+			continue
+		}
+		rotated = append(rotated, host.ID.String())
+		for _, nodeID := range host.Nodes {
+			if node, err := logic.GetNodeByID(nodeID); err == nil {
+				nodes = append(nodes, &node)
+			}
+		}
+	}
+	result := models.DryRunResult{
+		HostUpdates: logic.DryRunHosts(rotated),
+		Held:        logic.DryRunHosts(held),
+		PeerUpdates: []models.DryRunHost{},
+	}
+	if len(nodes) > 0 {
+		result.PeerUpdates = logic.DryRunHosts(logic.GetAffectedHosts(nodes...))
+	}
+	return result
+}
+
 // swagger:route POST /api/hosts/{hostid}keys host updateKeys
 //
 // Update keys for a network.
@@ -565,24 +654,26 @@ func updateKeys(w http.ResponseWriter, r *http.Request) {
 	hostid := params["hostid"]
 	host, err := logic.GetHost(hostid)
 	if err != nil {
-		logger.Log(0, "failed to retrieve host", hostid, err.Error())
+		slog.ErrorCtx(r.Context(), "failed to retrieve host", "hostid", hostid, "error", err)
 		errorResponse.Code = http.StatusBadRequest
 		errorResponse.Message = err.Error()
-		logger.Log(0, r.Header.Get("user"),
-			"error retrieving hosts ", err.Error())
+		slog.ErrorCtx(r.Context(), "error retrieving hosts", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, errorResponse)
 		return
 	}
+	if holdForMaintenance(w, r, &models.PendingChange{Action: models.UpdateKeys}, host) {
+		return
+	}
 	go func() {
 		hostUpdate := models.HostUpdate{
 			Action: models.UpdateKeys,
 			Host:   *host,
 		}
 		if err = mq.HostUpdate(&hostUpdate); err != nil {
-			logger.Log(0, "failed to send host key update", host.ID.String(), err.Error())
+			slog.ErrorCtx(r.Context(), "failed to send host key update", "host_id", host.ID.String(), "error", err)
 		}
 	}()
-	logger.Log(2, r.Header.Get("user"), "updated key on host", host.Name)
+	slog.DebugCtx(r.Context(), "updated key on host", "user", r.Header.Get("user"), "host_name", host.Name)
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -605,7 +696,7 @@ func syncHost(w http.ResponseWriter, r *http.Request) {
 
 	host, err := logic.GetHost(hostId)
 	if err != nil {
-		slog.Error("failed to retrieve host", "user", r.Header.Get("user"), "error", err)
+		slog.ErrorCtx(r.Context(), "failed to retrieve host", "user", r.Header.Get("user"), "error", err)
 		errorResponse.Code = http.StatusBadRequest
 		errorResponse.Message = err.Error()
 		logic.ReturnErrorResponse(w, r, errorResponse)
@@ -618,10 +709,311 @@ func syncHost(w http.ResponseWriter, r *http.Request) {
 			Host:   *host,
 		}
 		if err = mq.HostUpdate(&hostUpdate); err != nil {
-			slog.Error("failed to send host pull request", "host", host.ID.String(), "error", err)
+			slog.ErrorCtx(r.Context(), "failed to send host pull request", "host", host.ID.String(), "error", err)
 		}
 	}()
 
-	slog.Info("requested host pull", "user", r.Header.Get("user"), "host", host.ID)
+	slog.InfoCtx(r.Context(), "requested host pull", "user", r.Header.Get("user"), "host", host.ID)
 	w.WriteHeader(http.StatusOK)
 }
+
+// swagger:route GET /api/hosts/{hostid}/tuning hosts getHostTuning
+//
+// Gets the listen port, STUN setting and per peer keepalives of a host.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: hostTuningResponse
+func getHostTuning(w http.ResponseWriter, r *http.Request) {
+	host, err := logic.GetHost(mux.Vars(r)["hostid"])
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	logic.ReturnJSONResponse(w, r, logic.GetHostTuning(host))
+}
+
+// swagger:route PUT /api/hosts/{hostid}/tuning hosts tuneHost
+//
+// Sets the WireGuard listen port, STUN setting and per peer keepalives (in seconds, by peer host id) of a host
+// and pushes them to the host and its peers, so netclient config files don't have to be edited on each machine.
+// Settings left out are kept, an empty peer_keepalives removes all keepalive overrides.
+// Outside the host's maintenance windows the settings are held until the next one unless emergency=true.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: hostTuningResponse
+func tuneHost(w http.ResponseWriter, r *http.Request) {
+	host, err := logic.GetHost(mux.Vars(r)["hostid"])
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	var tuning models.HostTuning
+	if err := json.NewDecoder(r.Body).Decode(&tuning); err != nil {
+		slog.ErrorCtx(r.Context(), "error decoding request body", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	if err := logic.ValidateHostTuning(host, &tuning); err != nil {
+		var validationErrs validator.ValidationErrors
+		if errors.As(err, &validationErrs) || errors.Is(err, logic.ErrNotAPeer) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	if holdForMaintenance(w, r, &models.PendingChange{Action: models.UpdateHost, Tuning: &tuning}, host) {
+		return
+	}
+	if err := logic.TuneHost(host, &tuning); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to tune host", "user", r.Header.Get("user"), "host", host.ID, "error", err)
+		var validationErrs validator.ValidationErrors
+		if errors.As(err, &validationErrs) || errors.Is(err, logic.ErrNotAPeer) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "tuned host", "user", r.Header.Get("user"), "host", host.ID, "listenport", host.ListenPort, "stun", host.Stun)
+	go func() {
+		if err := mq.HostUpdate(&models.HostUpdate{
+			Action: models.UpdateHost,
+			Host:   *host,
+		}); err != nil {
+			slog.Error("failed to send host update", "host", host.ID, "error", err)
+		}
+		// peers need the new listen port, the host its keepalives
+		if err := mq.PublishHostPeerUpdate(host); err != nil {
+			slog.Error("failed to publish peer update", "error", err)
+		}
+	}()
+	logic.ReturnJSONResponse(w, r, logic.GetHostTuning(host))
+}
+
+// swagger:route GET /api/hosts/{hostid}/nat hosts getHostNat
+//
+// Reports a host's NAT type and detected public endpoint, and whether hole punching to each peer
+// worked as of the host's last check-in, with relay_recommended set when it failed with both ends behind NAT.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: hostNatReportResponse
+func getHostNat(w http.ResponseWriter, r *http.Request) {
+	host, err := logic.GetHost(mux.Vars(r)["hostid"])
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	report, err := logic.GetHostNatReport(host)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to get nat report of host", "user", r.Header.Get("user"), "host_id", host.ID.String(), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnJSONResponse(w, r, report)
+}
+
+// swagger:route GET /api/hosts/{hostid}/metrics hosts getHostResourceMetrics
+//
+// Get the cpu, memory and wireguard interface error samples a host checked in with over a window (default 24h),
+// with their peaks and whether the host looks saturated.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: hostResourceMetricsResponse
+func getHostResourceMetrics(w http.ResponseWriter, r *http.Request) {
+	host, err := logic.GetHost(mux.Vars(r)["hostid"])
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	metrics, err := logic.GetHostResourceMetrics(host.ID.String(), r.URL.Query().Get("window"))
+	if err != nil {
+		if errors.Is(err, logic.ErrInvalidUptimeWindow) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		slog.ErrorCtx(r.Context(), "failed to get resource metrics of host", "user", r.Header.Get("user"), "host_id", host.ID.String(), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnJSONResponse(w, r, metrics)
+}
+
+// swagger:route GET /api/hosts/{hostid}/publishstatus hosts getHostPublishStatus
+//
+// Reports whether the latest peer update of a host reached the broker, or is still queued and how often it failed.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: hostPublishStatusResponse
+func getHostPublishStatus(w http.ResponseWriter, r *http.Request) {
+	host, err := logic.GetHost(mux.Vars(r)["hostid"])
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	logic.ReturnJSONResponse(w, r, mq.GetHostPublishStatus(host.ID.String()))
+}
+
+// swagger:route GET /api/hosts/drift hosts getDriftedHosts
+//
+// Lists the hosts whose applied WireGuard and firewall config drifted from the one expected, the longest drifted first.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: hostDriftsResponse
+func getDriftedHosts(w http.ResponseWriter, r *http.Request) {
+	drifted, err := logic.GetDriftedHosts()
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to get drifted hosts", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnJSONResponse(w, r, drifted)
+}
+
+// swagger:route GET /api/hosts/{hostid}/drift hosts getHostDrift
+//
+// Get how the config a host applied compares to the one expected, as of its last check-in.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: hostDriftResponse
+func getHostDrift(w http.ResponseWriter, r *http.Request) {
+	hostID := mux.Vars(r)["hostid"]
+	drift, err := logic.GetHostDrift(hostID)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("host has not checked in with a config hash"), "notfound"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnJSONResponse(w, r, drift)
+}
+
+// swagger:route GET /api/hosts/quarantines hosts getHostQuarantines
+//
+// Lists the hosts quarantined as compromised, the latest first, including those deleted since.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: hostQuarantinesResponse
+func getHostQuarantines(w http.ResponseWriter, r *http.Request) {
+	quarantines, err := logic.GetHostQuarantines()
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to get host quarantines", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnJSONResponse(w, r, quarantines)
+}
+
+// swagger:route POST /api/hosts/{hostid}/quarantine hosts quarantineHost
+//
+// Quarantine a compromised host: its nodes are disconnected from every peer, its key is revoked and its id, key
+// and machine are refused on registration and authentication. The host is kept for forensics and a security alert is raised.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: hostQuarantineResponse
+func quarantineHost(w http.ResponseWriter, r *http.Request) {
+	hostID := mux.Vars(r)["hostid"]
+	var request models.HostQuarantineRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	quarantine, err := logic.QuarantineHost(hostID, r.Header.Get("user"), &request)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to quarantine host", "user", r.Header.Get("user"), "host", hostID, "error", err)
+		switch {
+		case errors.Is(err, logic.ErrHostQuarantined):
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "conflict"))
+		case database.IsEmptyRecord(err):
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		default:
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		}
+		return
+	}
+	// peers drop the host before the response
+	if err := mq.PublishPeerUpdate(); err != nil {
+		slog.Error("failed to publish peer update after quarantining host", "host", hostID, "error", err)
+	}
+	slog.InfoCtx(r.Context(), "quarantined host", "user", r.Header.Get("user"), "host", hostID, "reason", request.Reason)
+	logic.ReturnJSONResponse(w, r, quarantine)
+}
+
+// swagger:route DELETE /api/hosts/{hostid}/quarantine hosts releaseHostQuarantine
+//
+// Release a host from quarantine, connecting the nodes the quarantine disconnected.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: hostQuarantineResponse
+func releaseHostQuarantine(w http.ResponseWriter, r *http.Request) {
+	hostID := mux.Vars(r)["hostid"]
+	quarantine, err := logic.ReleaseHostQuarantine(hostID, r.Header.Get("user"))
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to release host from quarantine", "user", r.Header.Get("user"), "host", hostID, "error", err)
+		if errors.Is(err, logic.ErrHostNotQuarantined) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	go func() {
+		if err := mq.PublishPeerUpdate(); err != nil {
+			slog.Warn("failed to publish peer update after releasing host", "host", hostID, "error", err)
+		}
+	}()
+	slog.InfoCtx(r.Context(), "released host from quarantine", "user", r.Header.Get("user"), "host", hostID)
+	logic.ReturnJSONResponse(w, r, quarantine)
+}
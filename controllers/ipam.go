@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/mq"
+	"golang.org/x/exp/slog"
+)
+
+func ipamHandlers(r *mux.Router) {
+	r.HandleFunc("/api/networks/{networkname}/ipam", logic.SecurityCheck(true, http.HandlerFunc(getNetworkIPAM))).Methods(http.MethodGet)
+	r.HandleFunc("/api/networks/{networkname}/ipam/conflicts", logic.SecurityCheck(true, http.HandlerFunc(getAddressConflicts))).Methods(http.MethodGet)
+	r.HandleFunc("/api/networks/{networkname}/ipam/conflicts/repair", logic.SecurityCheck(true, http.HandlerFunc(repairAddressConflicts))).Methods(http.MethodPost)
+}
+
+// swagger:route GET /api/networks/{networkname}/ipam networks getNetworkIPAM
+//
+// Get the used and free addresses of a network's ranges, the largest free block of each, the addresses held
+// by nodes, ext clients and static peers, and warnings for the ranges nearing exhaustion.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: networkIPAMResponse
+func getNetworkIPAM(w http.ResponseWriter, r *http.Request) {
+	netID := mux.Vars(r)["networkname"]
+	if !ipamNetworkInTenant(w, r, netID) {
+		return
+	}
+	ipam, err := logic.GetNetworkIPAM(netID)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to get address utilization", "user", r.Header.Get("user"), "network", netID, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnJSONResponse(w, r, ipam)
+}
+
+// swagger:route GET /api/networks/{networkname}/ipam/conflicts networks getAddressConflicts
+//
+// Get the addresses held by more than one node or ext client of a network, and those outside its ranges.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: addressConflictsResponse
+func getAddressConflicts(w http.ResponseWriter, r *http.Request) {
+	netID := mux.Vars(r)["networkname"]
+	if !ipamNetworkInTenant(w, r, netID) {
+		return
+	}
+	conflicts, err := logic.GetAddressConflicts(netID)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to get address conflicts", "user", r.Header.Get("user"), "network", netID, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	writeList(w, r, conflicts)
+}
+
+// swagger:route POST /api/networks/{networkname}/ipam/conflicts/repair networks repairAddressConflicts
+//
+// Give new addresses to the nodes and ext clients with out of range addresses, and to every holder of a duplicate
+// address but the first. Reassigned ext clients need their config downloaded again.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: addressReassignmentsResponse
+func repairAddressConflicts(w http.ResponseWriter, r *http.Request) {
+	netID := mux.Vars(r)["networkname"]
+	if !ipamNetworkInTenant(w, r, netID) {
+		return
+	}
+	reassignments, err := logic.RepairAddressConflicts(netID)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to repair address conflicts", "user", r.Header.Get("user"), "network", netID, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "repaired address conflicts", "user", r.Header.Get("user"), "network", netID, "reassigned", len(reassignments))
+	if len(reassignments) > 0 {
+		go func() {
+			for _, reassignment := range reassignments {
+				if reassignment.Error != "" || reassignment.Holder.Kind != models.IPAMReservationNodes {
+					continue
+				}
+				if node, err := logic.GetNodeByID(reassignment.Holder.ID); err == nil {
+					if err := mq.NodeUpdate(&node); err != nil {
+						slog.Error("failed to publish node update", "node", reassignment.Holder.ID, "error", err)
+					}
+				}
+			}
+			if err := mq.PublishPeerUpdate(); err != nil {
+				slog.Error("failed to publish peer update after repairing addresses", "network", netID, "error", err)
+			}
+		}()
+	}
+	logic.ReturnJSONResponse(w, r, reassignments)
+}
+
+// ipamNetworkInTenant - checks the network of a request exists and a tenant admin's request is for a network of
+// their tenant, writing the error response when not
+func ipamNetworkInTenant(w http.ResponseWriter, r *http.Request, netID string) bool {
+	network, err := logic.GetNetwork(netID)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return false
+	}
+	if tenant := r.Header.Get("tenant"); tenant != "" && network.Tenant != tenant {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(logic.ErrTenantMismatch, "forbidden"))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,197 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/mq"
+	"golang.org/x/exp/slog"
+)
+
+func joinConfigHandlers(r *mux.Router) {
+	r.HandleFunc("/api/networks/{networkname}/joinconfig", logic.SecurityCheck(true, http.HandlerFunc(getNetworkJoinConfig))).Methods(http.MethodGet)
+	r.HandleFunc("/api/networks/{networkname}/joinconfig", logic.SecurityCheck(true, http.HandlerFunc(setNetworkJoinConfig))).Methods(http.MethodPut)
+	r.HandleFunc("/api/networks/{networkname}/joinconfig", logic.SecurityCheck(true, http.HandlerFunc(deleteNetworkJoinConfig))).Methods(http.MethodDelete)
+	r.HandleFunc("/api/v1/enrollment-keys/{keyID}/joinconfig", logic.SecurityCheck(true, http.HandlerFunc(setEnrollmentKeyJoinConfig))).Methods(http.MethodPut)
+	r.HandleFunc("/api/v1/enrollment-keys/{keyID}/joinconfig", logic.SecurityCheck(true, http.HandlerFunc(deleteEnrollmentKeyJoinConfig))).Methods(http.MethodDelete)
+}
+
+// swagger:route GET /api/networks/{networkname}/joinconfig networks getNetworkJoinConfig
+//
+// Get the join config of a network: routes, sysctls, DNS search domains and values netclient applies after joining.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: joinConfigResponse
+func getNetworkJoinConfig(w http.ResponseWriter, r *http.Request) {
+	netID := mux.Vars(r)["networkname"]
+	if !ipamNetworkInTenant(w, r, netID) {
+		return
+	}
+	network, err := logic.GetNetwork(netID)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	config := network.JoinConfig
+	if config == nil {
+		config = &models.JoinConfig{}
+	}
+	logic.ReturnJSONResponse(w, r, config)
+}
+
+// swagger:route PUT /api/networks/{networkname}/joinconfig networks setNetworkJoinConfig
+//
+// Set the join config of a network with a new version, the hosts of the network are asked to pull it.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: joinConfigResponse
+func setNetworkJoinConfig(w http.ResponseWriter, r *http.Request) {
+	var config models.JoinConfig
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	updateNetworkJoinConfig(w, r, &config)
+}
+
+// swagger:route DELETE /api/networks/{networkname}/joinconfig networks deleteNetworkJoinConfig
+//
+// Remove the join config of a network, the hosts of the network are asked to pull.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: joinConfigResponse
+func deleteNetworkJoinConfig(w http.ResponseWriter, r *http.Request) {
+	updateNetworkJoinConfig(w, r, nil)
+}
+
+func updateNetworkJoinConfig(w http.ResponseWriter, r *http.Request, config *models.JoinConfig) {
+	netID := mux.Vars(r)["networkname"]
+	if !ipamNetworkInTenant(w, r, netID) {
+		return
+	}
+	config, err := logic.SetNetworkJoinConfig(netID, config)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to update join config", "user", r.Header.Get("user"), "network", netID, "error", err)
+		if errors.Is(err, logic.ErrInvalidJoinConfig) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	if config == nil {
+		config = &models.JoinConfig{}
+	}
+	slog.InfoCtx(r.Context(), "updated join config", "user", r.Header.Get("user"), "network", netID, "version", config.Version)
+	logic.ReturnJSONResponse(w, r, config)
+	go func() {
+		nodes, err := logic.GetNetworkNodes(netID)
+		if err != nil {
+			return
+		}
+		hosts := []models.Host{}
+		seen := map[string]bool{}
+		for _, node := range nodes {
+			if seen[node.HostID.String()] {
+				continue
+			}
+			seen[node.HostID.String()] = true
+			if host, err := logic.GetHost(node.HostID.String()); err == nil {
+				hosts = append(hosts, *host)
+			}
+		}
+		requestJoinConfigPulls(hosts)
+	}()
+}
+
+// swagger:route PUT /api/v1/enrollment-keys/{keyID}/joinconfig enrollmentKeys setEnrollmentKeyJoinConfig
+//
+// Set the join config of an EnrollmentKey with a new version, applied on top of that of its networks;
+// the hosts enrolled with the key are asked to pull it.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: createEnrollmentKeyResponse
+func setEnrollmentKeyJoinConfig(w http.ResponseWriter, r *http.Request) {
+	var config models.JoinConfig
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	updateEnrollmentKeyJoinConfig(w, r, &config)
+}
+
+// swagger:route DELETE /api/v1/enrollment-keys/{keyID}/joinconfig enrollmentKeys deleteEnrollmentKeyJoinConfig
+//
+// Remove the join config of an EnrollmentKey, the hosts enrolled with the key are asked to pull.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: createEnrollmentKeyResponse
+func deleteEnrollmentKeyJoinConfig(w http.ResponseWriter, r *http.Request) {
+	updateEnrollmentKeyJoinConfig(w, r, nil)
+}
+
+func updateEnrollmentKeyJoinConfig(w http.ResponseWriter, r *http.Request, config *models.JoinConfig) {
+	keyID := mux.Vars(r)["keyID"]
+	key, err := logic.GetEnrollmentKey(keyID)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	if err = logic.SetEnrollmentKeyJoinConfig(key, config); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to update enrollment key join config", "user", r.Header.Get("user"), "error", err)
+		if errors.Is(err, logic.ErrInvalidJoinConfig) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	fingerprint := logic.EnrollmentKeyFingerprint(key)
+	slog.InfoCtx(r.Context(), "updated enrollment key join config", "user", r.Header.Get("user"), "key", fingerprint)
+	logic.ReturnJSONResponse(w, r, key)
+	go func() {
+		hosts, err := logic.GetEnrollmentKeyHosts(fingerprint)
+		if err != nil {
+			return
+		}
+		requestJoinConfigPulls(hosts)
+	}()
+}
+
+// requestJoinConfigPulls - asks hosts to pull, getting the join configs that changed
+func requestJoinConfigPulls(hosts []models.Host) {
+	for i := range hosts {
+		if err := mq.HostUpdate(&models.HostUpdate{Action: models.RequestPull, Host: hosts[i]}); err != nil {
+			slog.Warn("failed to request pull for join config", "host", hosts[i].ID, "error", err)
+		}
+	}
+}
@@ -4,12 +4,12 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
+	"golang.org/x/exp/slog"
 )
 
 func legacyHandlers(r *mux.Router) {
-	r.HandleFunc("/api/v1/legacy/nodes", logic.SecurityCheck(true, http.HandlerFunc(wipeLegacyNodes))).Methods(http.MethodDelete)
+	r.HandleFunc("/api/v1/legacy/nodes", logic.SuperAdminCheck(http.HandlerFunc(wipeLegacyNodes))).Methods(http.MethodDelete)
 }
 
 // swagger:route DELETE /api/v1/legacy/nodes nodes wipeLegacyNodes
@@ -28,8 +28,8 @@ func wipeLegacyNodes(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	if err := logic.RemoveAllLegacyNodes(); err != nil {
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
-		logger.Log(0, "error occurred when removing legacy nodes", err.Error())
+		slog.ErrorCtx(r.Context(), "error occurred when removing legacy nodes", "error", err)
 	}
-	logger.Log(0, r.Header.Get("user"), "wiped legacy nodes")
+	slog.InfoCtx(r.Context(), "wiped legacy nodes", "user", r.Header.Get("user"))
 	logic.ReturnSuccessResponse(w, r, "wiped all legacy nodes")
 }
@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/mq"
+	"golang.org/x/exp/slog"
+)
+
+func lockdownHandlers(r *mux.Router) {
+	r.HandleFunc("/api/networks/{networkname}/lockdown", logic.SecurityCheck(true, http.HandlerFunc(getNetworkLockdown))).Methods(http.MethodGet)
+	r.HandleFunc("/api/networks/{networkname}/lockdown", logic.SecurityCheck(true, http.HandlerFunc(lockdownNetwork))).Methods(http.MethodPost)
+	r.HandleFunc("/api/networks/{networkname}/unlock", logic.SecurityCheck(true, http.HandlerFunc(unlockNetwork))).Methods(http.MethodPost)
+}
+
+// swagger:route GET /api/networks/{networkname}/lockdown networks getNetworkLockdown
+//
+// Get the lockdown of a network, not found when it isn't locked down.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: networkLockdownResponse
+func getNetworkLockdown(w http.ResponseWriter, r *http.Request) {
+	netID := mux.Vars(r)["networkname"]
+	if !ipamNetworkInTenant(w, r, netID) {
+		return
+	}
+	lockdown, err := logic.GetNetworkLockdown(netID)
+	if err != nil {
+		if errors.Is(err, logic.ErrNetworkNotLocked) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	lockdown.ACLs = nil
+	logic.ReturnJSONResponse(w, r, lockdown)
+}
+
+// swagger:route POST /api/networks/{networkname}/lockdown networks lockdownNetwork
+//
+// Lock a network down for incident response: every pair of its nodes is denied, its ext clients are disabled and
+// nodes joining are denied, sparing the node and ext client ids of the allowlist; peers are sent the update at once.
+// The ACLs and clients as they were are restored on unlock.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: networkLockdownResponse
+func lockdownNetwork(w http.ResponseWriter, r *http.Request) {
+	netID := mux.Vars(r)["networkname"]
+	if !ipamNetworkInTenant(w, r, netID) {
+		return
+	}
+	var request models.NetworkLockdownRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	lockdown, err := logic.LockdownNetwork(netID, r.Header.Get("user"), &request)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to lock network down", "user", r.Header.Get("user"), "network", netID, "error", err)
+		switch {
+		case errors.Is(err, logic.ErrInvalidLockdown):
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		case errors.Is(err, logic.ErrNetworkLocked):
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "conflict"))
+		default:
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		}
+		return
+	}
+	publishLockdownUpdate(netID)
+	slog.InfoCtx(r.Context(), "locked network down", "user", r.Header.Get("user"), "network", netID, "reason", request.Reason)
+	lockdown.ACLs = nil
+	logic.ReturnJSONResponse(w, r, lockdown)
+}
+
+// swagger:route POST /api/networks/{networkname}/unlock networks unlockNetwork
+//
+// End the lockdown of a network, restoring its default ACL, the ACLs of its nodes and the ext clients it disabled.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: networkLockdownResponse
+func unlockNetwork(w http.ResponseWriter, r *http.Request) {
+	netID := mux.Vars(r)["networkname"]
+	if !ipamNetworkInTenant(w, r, netID) {
+		return
+	}
+	lockdown, err := logic.UnlockNetwork(netID, r.Header.Get("user"))
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to unlock network", "user", r.Header.Get("user"), "network", netID, "error", err)
+		if errors.Is(err, logic.ErrNetworkNotLocked) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	publishLockdownUpdate(netID)
+	slog.InfoCtx(r.Context(), "unlocked network", "user", r.Header.Get("user"), "network", netID)
+	lockdown.ACLs = nil
+	logic.ReturnJSONResponse(w, r, lockdown)
+}
+
+// publishLockdownUpdate - sends the peers of a network their update right away rather than in the background,
+// a lockdown should take effect before the response
+func publishLockdownUpdate(netID string) {
+	if err := mq.PublishNetworkPeerUpdate(netID); err != nil {
+		slog.Error("failed to publish peer update after lockdown change", "network", netID, "error", err)
+	}
+}
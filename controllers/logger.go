@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
@@ -8,10 +9,13 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
+	"golang.org/x/exp/slog"
 )
 
 func loggerHandlers(r *mux.Router) {
-	r.HandleFunc("/api/logs", logic.SecurityCheck(true, http.HandlerFunc(getLogs))).Methods(http.MethodGet)
+	r.HandleFunc("/api/logs", logic.SuperAdminCheck(http.HandlerFunc(getLogs))).Methods(http.MethodGet)
+	r.HandleFunc("/api/logs/levels", logic.SuperAdminCheck(http.HandlerFunc(getLogLevels))).Methods(http.MethodGet)
+	r.HandleFunc("/api/logs/levels", logic.SuperAdminCheck(http.HandlerFunc(updateLogLevels))).Methods(http.MethodPut)
 }
 
 func getLogs(w http.ResponseWriter, r *http.Request) {
@@ -21,3 +25,59 @@ func getLogs(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(logger.Retrieve(currentFilePath)))
 }
+
+// swagger:route GET /api/logs/levels logger getLogLevels
+//
+// Get the log level of every component.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: logLevelsResponse
+func getLogLevels(w http.ResponseWriter, r *http.Request) {
+	logic.ReturnJSONResponse(w, r, logger.GetLevels())
+}
+
+// swagger:route PUT /api/logs/levels logger updateLogLevels
+//
+// Change the log level of components at runtime, an empty level resets a component to the default level.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: logLevelsResponse
+func updateLogLevels(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var update map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	parsed := make(map[string]slog.Level, len(update))
+	for component, name := range update {
+		if name == "" {
+			continue
+		}
+		level, err := logger.ParseLevel(name)
+		if err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(fmt.Errorf("invalid level for %s: %w", component, err), "badrequest"))
+			return
+		}
+		parsed[component] = level
+	}
+	for component, name := range update {
+		if name == "" {
+			logger.ResetLevel(component)
+			continue
+		}
+		logger.SetLevel(component, parsed[component])
+	}
+	slog.InfoCtx(r.Context(), "updated log levels", "user", r.Header.Get("user"), "levels", update)
+	logic.ReturnJSONResponse(w, r, logger.GetLevels())
+}
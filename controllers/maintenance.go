@@ -0,0 +1,184 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+func maintenanceHandlers(r *mux.Router) {
+	r.HandleFunc("/api/networks/{networkname}/maintenance", logic.SecurityCheck(true, http.HandlerFunc(getMaintenanceStatus))).Methods(http.MethodGet)
+	r.HandleFunc("/api/networks/{networkname}/maintenance", logic.SecurityCheck(true, http.HandlerFunc(createMaintenanceWindow))).Methods(http.MethodPost)
+	r.HandleFunc("/api/networks/{networkname}/maintenance/{windowid}", logic.SecurityCheck(true, http.HandlerFunc(deleteMaintenanceWindow))).Methods(http.MethodDelete)
+	r.HandleFunc("/api/maintenance/pending", logic.SecurityCheck(true, http.HandlerFunc(getPendingChanges))).Methods(http.MethodGet)
+	r.HandleFunc("/api/maintenance/pending/{id}", logic.SecurityCheck(true, http.HandlerFunc(cancelPendingChange))).Methods(http.MethodDelete)
+}
+
+// swagger:route GET /api/networks/{networkname}/maintenance networks getMaintenanceStatus
+//
+// Get the maintenance windows of a network, whether one is open and when the next one opens.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: maintenanceStatusResponse
+func getMaintenanceStatus(w http.ResponseWriter, r *http.Request) {
+	network := mux.Vars(r)["networkname"]
+	if _, err := logic.GetNetwork(network); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	status, err := logic.GetMaintenanceStatus(network, time.Now().UTC())
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnJSONResponse(w, r, status)
+}
+
+// swagger:route POST /api/networks/{networkname}/maintenance networks createMaintenanceWindow
+//
+// Add a weekly maintenance window to a network. Key rotations and host tuning are held until every network
+// of the host is in a window, unless sent with emergency=true. Networks without windows are always open.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: maintenanceWindowResponse
+func createMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	var window models.MaintenanceWindow
+	if err := json.NewDecoder(r.Body).Decode(&window); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	window.Network = mux.Vars(r)["networkname"]
+	if err := logic.CreateMaintenanceWindow(&window); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to create maintenance window", "user", r.Header.Get("user"), "network", window.Network, "error", err)
+		if errors.Is(err, logic.ErrInvalidMaintenanceWindow) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "created maintenance window", "user", r.Header.Get("user"), "network", window.Network, "window", window.Name)
+	logic.ReturnJSONResponse(w, r, window)
+}
+
+// swagger:route DELETE /api/networks/{networkname}/maintenance/{windowid} networks deleteMaintenanceWindow
+//
+// Remove a maintenance window of a network.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: successResponse
+func deleteMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	window, err := logic.GetMaintenanceWindow(params["windowid"])
+	if err != nil || window.Network != params["networkname"] {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("maintenance window not found"), "notfound"))
+		return
+	}
+	if err = logic.DeleteMaintenanceWindow(window.ID); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "deleted maintenance window", "user", r.Header.Get("user"), "network", window.Network, "window", window.Name)
+	logic.ReturnSuccessResponse(w, r, "deleted maintenance window "+window.Name)
+}
+
+// swagger:route GET /api/maintenance/pending networks getPendingChanges
+//
+// List the changes waiting for a maintenance window, those of the tenant's hosts for tenant admins.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: pendingChangesResponse
+func getPendingChanges(w http.ResponseWriter, r *http.Request) {
+	changes, err := logic.GetPendingChanges()
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	if tenant := r.Header.Get("tenant"); tenant != "" {
+		filtered := []models.PendingChange{}
+		for i := range changes {
+			if logic.PendingChangeInTenant(&changes[i], tenant) {
+				filtered = append(filtered, changes[i])
+			}
+		}
+		changes = filtered
+	}
+	logic.ReturnJSONResponse(w, r, changes)
+}
+
+// swagger:route DELETE /api/maintenance/pending/{id} networks cancelPendingChange
+//
+// Drop a change waiting for a maintenance window.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: successResponse
+func cancelPendingChange(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if tenant := r.Header.Get("tenant"); tenant != "" {
+		change, err := logic.GetPendingChange(id)
+		if err != nil || !logic.PendingChangeInTenant(&change, tenant) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("pending change not found"), "notfound"))
+			return
+		}
+	}
+	if err := logic.DeletePendingChange(id); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "cancelled pending change", "user", r.Header.Get("user"), "id", id)
+	logic.ReturnSuccessResponse(w, r, "cancelled pending change "+id)
+}
+
+// holdForMaintenance - queues a disruptive change to a host outside its maintenance windows and answers
+// with 202, true if it was held, emergency=true applies it right away
+func holdForMaintenance(w http.ResponseWriter, r *http.Request, change *models.PendingChange, host *models.Host) bool {
+	if r.URL.Query().Get("emergency") == "true" {
+		slog.WarnCtx(r.Context(), "maintenance windows overridden", "user", r.Header.Get("user"), "host", host.ID, "action", change.Action)
+		return false
+	}
+	if logic.HostInMaintenanceWindow(host, time.Now().UTC()) {
+		return false
+	}
+	change.HostID = host.ID.String()
+	change.User = r.Header.Get("user")
+	if err := logic.QueuePendingChange(change); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return true
+	}
+	slog.InfoCtx(r.Context(), "change held until the next maintenance window", "user", change.User, "host", host.ID, "action", change.Action)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(change)
+	return true
+}
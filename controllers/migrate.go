@@ -2,26 +2,22 @@ package controller
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net"
 	"net/http"
-	"time"
+	"strings"
 
-	"github.com/google/uuid"
-	"github.com/gravitl/netmaker/database"
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/mq"
 	"github.com/gravitl/netmaker/servercfg"
-	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/exp/slog"
-	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 )
 
 // swagger:route PUT /api/v1/nodes/migrate nodes migrateNode
 //
-// Used to migrate a legacy node.
+// Used to migrate a legacy node. Every legacy node of the host is checked before anything is created,
+// then the host and its nodes are created together. The results tell which legacy nodes were migrated.
 //
 //			Schemes: https
 //
@@ -29,185 +25,97 @@ import (
 //	  		oauth
 //
 //			Responses:
-//				200: nodeJoinResponse
+//				200: migrationResponse
 func migrate(w http.ResponseWriter, r *http.Request) {
 	data := models.MigrationData{}
-	host := models.Host{}
-	node := models.Node{}
-	nodes := []models.Node{}
-	server := models.ServerConfig{}
 	err := json.NewDecoder(r.Body).Decode(&data)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"), "error decoding request body: ", err.Error())
+		slog.ErrorCtx(r.Context(), "error decoding request body", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
-	for i, legacy := range data.LegacyNodes {
-		record, err := database.FetchRecord(database.NODES_TABLE_NAME, legacy.ID)
-		if err != nil {
-			slog.Error("legacy node not found", "error", err)
-			logic.ReturnErrorResponse(w, r, logic.FormatError(fmt.Errorf("legacy node not found %w", err), "badrequest"))
-			return
-		}
-		var legacyNode models.LegacyNode
-		if err = json.Unmarshal([]byte(record), &legacyNode); err != nil {
-			slog.Error("decoding legacy node", "errror", err)
-			logic.ReturnErrorResponse(w, r, logic.FormatError(fmt.Errorf("decode legacy node %w", err), "badrequest"))
-			return
-		}
-		if err := bcrypt.CompareHashAndPassword([]byte(legacyNode.Password), []byte(legacy.Password)); err != nil {
-			slog.Error("legacy node invalid password", "error", err)
-			logic.ReturnErrorResponse(w, r, logic.FormatError(fmt.Errorf("invalid password %w", err), "unauthorized"))
-			return
-		}
-		if i == 0 {
-			host, node = convertLegacyHostNode(legacy)
-			host.Name = data.HostName
-			host.HostPass = data.Password
-			host.OS = data.OS
-			if err := logic.CreateHost(&host); err != nil {
-				slog.Error("create host", "error", err)
-				logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
-				return
-			}
-			server = servercfg.GetServerInfo()
-			if servercfg.GetBrokerType() == servercfg.EmqxBrokerType {
-				server.MQUserName = host.ID.String()
-			}
-			key, keyErr := logic.RetrievePublicTrafficKey()
-			if keyErr != nil {
-				slog.Error("retrieving traffickey", "error", err)
-				logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
-				return
+	host, nodes, results, err := logic.MigrateLegacyHost(&data)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to migrate legacy nodes", "error", err, "results", results)
+		if errors.Is(err, logic.ErrNoLegacyNodesMigrated) {
+			err = fmt.Errorf("%w: %s", err, legacyMigrationErrors(results))
+			errType := "badrequest"
+			if allLegacyPasswordsInvalid(results) {
+				errType = "unauthorized"
 			}
-			server.TrafficKey = key
-		} else {
-			node = convertLegacyNode(legacyNode, host.ID)
-		}
-		if err := logic.UpsertNode(&node); err != nil {
-			slog.Error("update node", "error", err)
-			continue
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, errType))
+			return
 		}
-		host.Nodes = append(host.Nodes, node.ID.String())
-
-		nodes = append(nodes, node)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
 	}
-	if err := logic.UpsertHost(&host); err != nil {
-		slog.Error("save host", "error", err)
+	server := servercfg.GetServerInfo()
+	if servercfg.GetBrokerType() == servercfg.EmqxBrokerType {
+		server.MQUserName = host.ID.String()
 	}
-	go mq.PublishPeerUpdate()
-	response := models.HostPull{
-		Host:         host,
-		Nodes:        nodes,
-		ServerConfig: server,
+	key, keyErr := logic.RetrievePublicTrafficKey()
+	if keyErr != nil {
+		slog.ErrorCtx(r.Context(), "retrieving traffickey", "error", keyErr)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(keyErr, "internal"))
+		return
+	}
+	server.TrafficKey = key
+	go mq.PublishHostPeerUpdate(&host)
+	response := models.MigrationResponse{
+		HostPull: models.HostPull{
+			Host:         host,
+			Nodes:        nodes,
+			ServerConfig: server,
+		},
+		Results: results,
 	}
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(&response)
+	logic.ReturnJSONResponse(w, r, &response)
 
-	slog.Info("migrated nodes")
-	// check for gateways
-	for _, node := range data.LegacyNodes {
+	slog.InfoCtx(r.Context(), "migrated nodes", "host", host.ID, "nodes", len(nodes), "requested", len(data.LegacyNodes))
+	// check for gateways of the migrated nodes
+	for i, node := range data.LegacyNodes {
+		if !results[i].Migrated {
+			continue
+		}
 		if node.IsEgressGateway == "yes" {
 			egressGateway := models.EgressGatewayRequest{
 				NodeID:     node.ID,
+				NetID:      node.Network,
 				Ranges:     node.EgressGatewayRanges,
 				NatEnabled: node.EgressGatewayNatEnabled,
 			}
 			if _, err := logic.CreateEgressGateway(egressGateway); err != nil {
-				logger.Log(0, "error creating egress gateway for node", node.ID, err.Error())
+				slog.ErrorCtx(r.Context(), "error creating egress gateway for node", "node_id", node.ID, "error", err)
 			}
 		}
 		if node.IsIngressGateway == "yes" {
 			ingressGateway := models.IngressRequest{}
 			ingressNode, err := logic.CreateIngressGateway(node.Network, node.ID, ingressGateway)
 			if err != nil {
-				logger.Log(0, "error creating ingress gateway for node", node.ID, err.Error())
+				slog.ErrorCtx(r.Context(), "error creating ingress gateway for node", "node_id", node.ID, "error", err)
 			}
 			runUpdates(&ingressNode, true)
 		}
 	}
 }
 
-func convertLegacyHostNode(legacy models.LegacyNode) (models.Host, models.Node) {
-	//convert host
-	host := models.Host{}
-	host.ID = uuid.New()
-	host.IPForwarding = models.ParseBool(legacy.IPForwarding)
-	host.AutoUpdate = servercfg.AutoUpdateEnabled()
-	host.Interface = "netmaker"
-	host.ListenPort = int(legacy.ListenPort)
-	host.MTU = int(legacy.MTU)
-	host.PublicKey, _ = wgtypes.ParseKey(legacy.PublicKey)
-	host.MacAddress = net.HardwareAddr(legacy.MacAddress)
-	host.TrafficKeyPublic = legacy.TrafficKeys.Mine
-	host.Nodes = append([]string{}, legacy.ID)
-	host.Interfaces = legacy.Interfaces
-	//host.DefaultInterface = legacy.Defaul
-	host.EndpointIP = net.ParseIP(legacy.Endpoint)
-	host.IsDocker = models.ParseBool(legacy.IsDocker)
-	host.IsK8S = models.ParseBool(legacy.IsK8S)
-	host.IsStatic = models.ParseBool(legacy.IsStatic)
-	node := convertLegacyNode(legacy, host.ID)
-	return host, node
-}
-
-func convertLegacyNode(legacy models.LegacyNode, hostID uuid.UUID) models.Node {
-	//convert node
-	node := models.Node{}
-	node.ID, _ = uuid.Parse(legacy.ID)
-	node.HostID = hostID
-	node.Network = legacy.Network
-	valid4 := true
-	valid6 := true
-	_, cidr4, err := net.ParseCIDR(legacy.NetworkSettings.AddressRange)
-	if err != nil {
-		valid4 = false
-		slog.Warn("parsing address range", "error", err)
-	} else {
-		node.NetworkRange = *cidr4
-	}
-	_, cidr6, err := net.ParseCIDR(legacy.NetworkSettings.AddressRange6)
-	if err != nil {
-		valid6 = false
-		slog.Warn("parsing address range6", "error", err)
-	} else {
-		node.NetworkRange6 = *cidr6
-	}
-	node.Server = servercfg.GetServer()
-	node.Connected = models.ParseBool(legacy.Connected)
-	if valid4 {
-		node.Address = net.IPNet{
-			IP:   net.ParseIP(legacy.Address),
-			Mask: cidr4.Mask,
+// legacyMigrationErrors - why each legacy node failed to migrate
+func legacyMigrationErrors(results []models.LegacyNodeMigration) string {
+	var failures []string
+	for _, result := range results {
+		if result.Error != "" {
+			failures = append(failures, result.NodeID+": "+result.Error)
 		}
 	}
-	if valid6 {
-		node.Address6 = net.IPNet{
-			IP:   net.ParseIP(legacy.Address6),
-			Mask: cidr6.Mask,
+	return strings.Join(failures, "; ")
+}
+
+// allLegacyPasswordsInvalid - checks if every legacy node failed on its password
+func allLegacyPasswordsInvalid(results []models.LegacyNodeMigration) bool {
+	for _, result := range results {
+		if result.Error != logic.ErrLegacyNodePassword.Error() {
+			return false
 		}
 	}
-	node.Action = models.NODE_NOOP
-	node.LocalAddress = net.IPNet{
-		IP: net.ParseIP(legacy.LocalAddress),
-	}
-	node.IsEgressGateway = models.ParseBool(legacy.IsEgressGateway)
-	node.EgressGatewayRanges = legacy.EgressGatewayRanges
-	node.IsIngressGateway = models.ParseBool(legacy.IsIngressGateway)
-	node.IsRelayed = false
-	node.IsRelay = false
-	node.RelayedNodes = []string{}
-	node.DNSOn = models.ParseBool(legacy.DNSOn)
-	node.PersistentKeepalive = time.Duration(int64(time.Second) * int64(legacy.PersistentKeepalive))
-	node.LastModified = time.Now()
-	node.ExpirationDateTime = time.Unix(legacy.ExpirationDateTime, 0)
-	node.EgressGatewayNatEnabled = models.ParseBool(legacy.EgressGatewayNatEnabled)
-	node.EgressGatewayRequest = legacy.EgressGatewayRequest
-	node.IngressGatewayRange = legacy.IngressGatewayRange
-	node.IngressGatewayRange6 = legacy.IngressGatewayRange6
-	node.DefaultACL = legacy.DefaultACL
-	node.OwnerID = legacy.OwnerID
-	node.FailoverNode, _ = uuid.Parse(legacy.FailoverNode)
-	node.Failover = models.ParseBool(legacy.Failover)
-	return node
+	return len(results) > 0
 }
@@ -5,13 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"golang.org/x/exp/slog"
 
 	"github.com/gravitl/netmaker/database"
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/logic/acls"
 	"github.com/gravitl/netmaker/models"
@@ -28,6 +29,7 @@ func networkHandlers(r *mux.Router) {
 	// ACLs
 	r.HandleFunc("/api/networks/{networkname}/acls", logic.SecurityCheck(true, http.HandlerFunc(updateNetworkACL))).Methods(http.MethodPut)
 	r.HandleFunc("/api/networks/{networkname}/acls", logic.SecurityCheck(true, http.HandlerFunc(getNetworkACL))).Methods(http.MethodGet)
+	r.HandleFunc("/api/networks/{networkname}/events", logic.SecurityCheck(false, http.HandlerFunc(getNetworkEvents))).Methods(http.MethodGet)
 }
 
 // swagger:route GET /api/networks networks getNetworks
@@ -44,8 +46,7 @@ func networkHandlers(r *mux.Router) {
 func getNetworks(w http.ResponseWriter, r *http.Request) {
 	networksSlice, marshalErr := getHeaderNetworks(r)
 	if marshalErr != nil {
-		logger.Log(0, r.Header.Get("user"), "error unmarshalling networks: ",
-			marshalErr.Error())
+		slog.ErrorCtx(r.Context(), "error unmarshalling networks", "user", r.Header.Get("user"), "error", marshalErr)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(marshalErr, "badrequest"))
 		return
 	}
@@ -54,7 +55,7 @@ func getNetworks(w http.ResponseWriter, r *http.Request) {
 	if len(networksSlice) > 0 && networksSlice[0] == logic.ALL_NETWORK_ACCESS {
 		allnetworks, err = logic.GetNetworks()
 		if err != nil && !database.IsEmptyRecord(err) {
-			logger.Log(0, r.Header.Get("user"), "failed to fetch networks: ", err.Error())
+			slog.ErrorCtx(r.Context(), "failed to fetch networks", "user", r.Header.Get("user"), "error", err)
 			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 			return
 		}
@@ -67,10 +68,9 @@ func getNetworks(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	logger.Log(2, r.Header.Get("user"), "fetched networks.")
+	slog.DebugCtx(r.Context(), "fetched networks.", "user", r.Header.Get("user"))
 	logic.SortNetworks(allnetworks[:])
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(allnetworks)
+	writeList(w, r, allnetworks)
 }
 
 // swagger:route GET /api/networks/{networkname} networks getNetwork
@@ -91,20 +91,21 @@ func getNetwork(w http.ResponseWriter, r *http.Request) {
 	netname := params["networkname"]
 	network, err := logic.GetNetwork(netname)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"), fmt.Sprintf("failed to fetch network [%s] info: %v",
-			netname, err))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("failed to fetch network [%s] info: %v",
+			netname, err), "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
 
-	logger.Log(2, r.Header.Get("user"), "fetched network", netname)
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(network)
+	slog.DebugCtx(r.Context(), "fetched network", "user", r.Header.Get("user"), "netname", netname)
+	logic.ReturnJSONResponse(w, r, network)
 }
 
 // swagger:route PUT /api/networks/{networkname}/acls networks updateNetworkACL
 //
-// Update a network ACL (Access Control List).
+// Update a network ACL (Access Control List). An update leaving no nodes allowed to talk to each other
+// is held for another admin to approve when change approval is on.
+// With dryRun=true, returns what the change would do without saving it.
 //
 //			Schemes: https
 //
@@ -117,38 +118,71 @@ func updateNetworkACL(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	var params = mux.Vars(r)
 	netname := params["networkname"]
-	var networkACLChange acls.ACLContainer
-	networkACLChange, err := networkACLChange.Get(acls.ContainerID(netname))
+	if logic.IsNetworkLocked(netname) {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(logic.ErrNetworkLocked, "conflict"))
+		return
+	}
+	var currentACL acls.ACLContainer
+	currentACL, err := currentACL.Get(acls.ContainerID(netname))
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("failed to fetch ACLs for network [%s]: %v", netname, err))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("failed to fetch ACLs for network [%s]: %v", netname, err), "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
+	// the current ACLs are cached, decoding replaces the ACL of each node in the body
+	// so a shallow copy keeps them intact until the change is saved
+	networkACLChange := acls.ACLContainer{}
+	for id, acl := range currentACL {
+		networkACLChange[id] = acl
+	}
 	err = json.NewDecoder(r.Body).Decode(&networkACLChange)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"), "error decoding request body: ",
-			err.Error())
+		slog.ErrorCtx(r.Context(), "error decoding request body", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
-	newNetACL, err := networkACLChange.Save(acls.ContainerID(netname))
+	if isDryRun(r) {
+		writeDryRun(w, r, models.DryRunResult{
+			Result:      networkACLChange,
+			PeerUpdates: logic.DryRunHosts(logic.GetNetworkHosts(netname)),
+		})
+		return
+	}
+	if logic.IsDenyAllACL(currentACL, networkACLChange) {
+		payload, err := json.Marshal(networkACLChange)
+		if err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+			return
+		}
+		if holdForApproval(w, r, &models.ApprovalRequest{Operation: models.ApprovalDenyAllACL, Target: netname, Payload: payload}) {
+			return
+		}
+	}
+	newNetACL, err := saveNetworkACL(netname, r.Header.Get("user"), networkACLChange)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("failed to update ACLs for network [%s]: %v", netname, err))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
-	logger.Log(1, r.Header.Get("user"), "updated ACLs for network", netname)
+	logic.ReturnJSONResponse(w, r, newNetACL)
+}
+
+// saveNetworkACL - saves the ACLs of a network and sends its peers the update
+func saveNetworkACL(netname, user string, networkACLChange acls.ACLContainer) (acls.ACLContainer, error) {
+	newNetACL, err := networkACLChange.Save(acls.ContainerID(netname))
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to update ACLs for network [%s]: %v", netname, err), "user", user)
+		return nil, err
+	}
+	slog.Info("updated ACLs for network", "user", user, "netname", netname)
+	logic.RecordNetworkEvent(netname, models.NetworkEventACL, nil, "acls updated by "+user)
 
 	// send peer updates
 	if servercfg.IsMessageQueueBackend() {
-		if err = mq.PublishPeerUpdate(); err != nil {
-			logger.Log(0, "failed to publish peer update after ACL update on", netname)
+		if err = mq.PublishNetworkPeerUpdate(netname); err != nil {
+			slog.Error("failed to publish peer update after ACL update", "netname", netname)
 		}
 	}
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(newNetACL)
+	return newNetACL, nil
 }
 
 // swagger:route GET /api/networks/{networkname}/acls networks getNetworkACL
@@ -171,23 +205,79 @@ func getNetworkACL(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		if database.IsEmptyRecord(err) {
 			networkACL = acls.ACLContainer{}
-			w.WriteHeader(http.StatusOK)
-			json.NewEncoder(w).Encode(networkACL)
+			logic.ReturnJSONResponse(w, r, networkACL)
 			return
 		}
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("failed to fetch ACLs for network [%s]: %v", netname, err))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("failed to fetch ACLs for network [%s]: %v", netname, err), "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
-	logger.Log(2, r.Header.Get("user"), "fetched acl for network", netname)
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(networkACL)
+	slog.DebugCtx(r.Context(), "fetched acl for network", "user", r.Header.Get("user"), "netname", netname)
+	logic.ReturnJSONResponse(w, r, networkACL)
+}
+
+// swagger:route GET /api/networks/{networkname}/events networks getNetworkEvents
+//
+// Lists the joins, leaves, gateway changes, acl edits and failovers of a network, oldest first.
+// Accepts from and to (RFC3339), kind (comma separated), offset and limit (at most 1000).
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: networkEventsResponse
+func getNetworkEvents(w http.ResponseWriter, r *http.Request) {
+	netname := mux.Vars(r)["networkname"]
+	if _, err := logic.GetNetwork(netname); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	query := r.URL.Query()
+	var filter models.NetworkEventFilter
+	var err error
+	if v := query.Get("from"); v != "" {
+		if filter.From, err = time.Parse(time.RFC3339, v); err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if filter.To, err = time.Parse(time.RFC3339, v); err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+	}
+	if v := query.Get("kind"); v != "" {
+		filter.Kinds = strings.Split(v, ",")
+	}
+	if v := query.Get("offset"); v != "" {
+		if filter.Offset, err = strconv.Atoi(v); err != nil || filter.Offset < 0 {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("invalid offset"), "badrequest"))
+			return
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit < 0 {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("invalid limit"), "badrequest"))
+			return
+		}
+	}
+	page, err := logic.GetNetworkEvents(netname, filter)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to fetch events of network", "user", r.Header.Get("user"), "netname", netname, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnJSONResponse(w, r, page)
 }
 
 // swagger:route DELETE /api/networks/{networkname} networks deleteNetwork
 //
 // Delete a network.  Will not delete if there are any nodes that belong to the network.
+// It can be restored from the trash until its retention is over.
+// Held for another admin to approve when change approval is on.
 //
 //			Schemes: https
 //
@@ -202,21 +292,37 @@ func deleteNetwork(w http.ResponseWriter, r *http.Request) {
 
 	var params = mux.Vars(r)
 	network := params["networkname"]
-	err := logic.DeleteNetwork(network)
+	if holdForApproval(w, r, &models.ApprovalRequest{Operation: models.ApprovalDeleteNetwork, Target: network}) {
+		return
+	}
+	err := deleteNetworkToTrash(network, r.Header.Get("user"))
 	if err != nil {
 		errtype := "badrequest"
 		if strings.Contains(err.Error(), "Node check failed") {
 			errtype = "forbidden"
 		}
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("failed to delete network [%s]: %v", network, err))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("failed to delete network [%s]: %v", network, err), "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, errtype))
 		return
 	}
 
-	logger.Log(1, r.Header.Get("user"), "deleted network", network)
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode("success")
+	slog.InfoCtx(r.Context(), "deleted network", "user", r.Header.Get("user"), "network", network)
+	logic.ReturnJSONResponse(w, r, "success")
+}
+
+// deleteNetworkToTrash - deletes a network and keeps it in the trash
+func deleteNetworkToTrash(netname, user string) error {
+	network, err := logic.GetNetwork(netname)
+	if err != nil {
+		return err
+	}
+	if err = logic.DeleteNetwork(netname); err != nil {
+		return err
+	}
+	if err = logic.TrashNetwork(&network, user); err != nil {
+		slog.Error("failed to trash deleted network", "network", netname, "error", err)
+	}
+	return nil
 }
 
 // swagger:route POST /api/networks networks createNetwork
@@ -239,63 +345,75 @@ func createNetwork(w http.ResponseWriter, r *http.Request) {
 	// we decode our body request params
 	err := json.NewDecoder(r.Body).Decode(&network)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"), "error decoding request body: ",
-			err.Error())
+		slog.ErrorCtx(r.Context(), "error decoding request body", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
 
 	if len(network.NetID) > 32 {
 		err := errors.New("network name shouldn't exceed 32 characters")
-		logger.Log(0, r.Header.Get("user"), "failed to create network: ",
-			err.Error())
+		slog.ErrorCtx(r.Context(), "failed to create network", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
 
 	if network.AddressRange == "" && network.AddressRange6 == "" {
 		err := errors.New("IPv4 or IPv6 CIDR required")
-		logger.Log(0, r.Header.Get("user"), "failed to create network: ",
-			err.Error())
+		slog.ErrorCtx(r.Context(), "failed to create network", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
 
+	// tenant admins always create networks within their own tenant
+	if tenant := r.Header.Get("tenant"); tenant != "" {
+		network.Tenant = tenant
+	} else if network.Tenant != "" {
+		if _, err := logic.GetTenant(network.Tenant); err != nil {
+			err = fmt.Errorf("unknown tenant %s", network.Tenant)
+			slog.ErrorCtx(r.Context(), "failed to create network", "user", r.Header.Get("user"), "error", err)
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+	}
+
 	network, err = logic.CreateNetwork(network)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"), "failed to create network: ",
-			err.Error())
+		slog.ErrorCtx(r.Context(), "failed to create network", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
 
+	// default hosts are shared by the whole server so they only join networks outside of tenants
 	defaultHosts := logic.GetDefaultHosts()
+	if network.Tenant != "" {
+		defaultHosts = nil
+	}
 	for i := range defaultHosts {
 		currHost := &defaultHosts[i]
 		newNode, err := logic.UpdateHostNetwork(currHost, network.NetID, true)
 		if err != nil {
-			logger.Log(0, r.Header.Get("user"), "failed to add host to network:", currHost.ID.String(), network.NetID, err.Error())
+			slog.ErrorCtx(r.Context(), "failed to add host to network", "user", r.Header.Get("user"), "host_id", currHost.ID.String(), "net_id", network.NetID, "error", err)
 			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 			return
 		}
-		logger.Log(1, "added new node", newNode.ID.String(), "to host", currHost.Name)
+		slog.InfoCtx(r.Context(), "added new node to host", "new_node_id", newNode.ID.String(), "host_name", currHost.Name)
 		if err = mq.HostUpdate(&models.HostUpdate{
 			Action: models.JoinHostToNetwork,
 			Host:   *currHost,
 			Node:   *newNode,
 		}); err != nil {
-			logger.Log(0, r.Header.Get("user"), "failed to add host to network:", currHost.ID.String(), network.NetID, err.Error())
+			slog.ErrorCtx(r.Context(), "failed to add host to network", "user", r.Header.Get("user"), "host_id", currHost.ID.String(), "net_id", network.NetID, "error", err)
 		}
 	}
 
-	logger.Log(1, r.Header.Get("user"), "created network", network.NetID)
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(network)
+	slog.InfoCtx(r.Context(), "created network", "user", r.Header.Get("user"), "net_id", network.NetID)
+	logic.ReturnJSONResponse(w, r, network)
 }
 
 // swagger:route PUT /api/networks networks updateNetwork
 //
-// Update pro settings for a network.
+// Update pro settings and LAN detection (landetection) for a network.
+// With dryRun=true, returns what the change would do without saving it.
 //
 //			Schemes: https
 //
@@ -313,28 +431,57 @@ func updateNetwork(w http.ResponseWriter, r *http.Request) {
 	// we decode our body request params
 	err := json.NewDecoder(r.Body).Decode(&payload)
 	if err != nil {
-		slog.Info("error decoding request body", "user", r.Header.Get("user"), "err", err)
+		slog.InfoCtx(r.Context(), "error decoding request body", "user", r.Header.Get("user"), "err", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
 
 	netOld1, err := logic.GetNetwork(payload.NetID)
 	if err != nil {
-		slog.Info("error fetching network", "user", r.Header.Get("user"), "err", err)
+		slog.InfoCtx(r.Context(), "error fetching network", "user", r.Header.Get("user"), "err", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
+	if tenant := r.Header.Get("tenant"); tenant != "" && netOld1.Tenant != tenant {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(logic.ErrTenantMismatch, "forbidden"))
+		return
+	}
 	// partial update
 	netOld2 := netOld1
 	netOld2.ProSettings = payload.ProSettings
+	if payload.LANDetection != "" {
+		netOld2.LANDetection = payload.LANDetection
+	}
+	if payload.AddressFamily != "" {
+		netOld2.AddressFamily = payload.AddressFamily
+	}
+	if isDryRun(r) {
+		if err = logic.ValidateNetwork(&netOld2, true); err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		result := models.DryRunResult{Result: netOld2, PeerUpdates: []models.DryRunHost{}}
+		if netOld2.LANDetection != netOld1.LANDetection || netOld2.AddressFamily != netOld1.AddressFamily {
+			result.PeerUpdates = logic.DryRunHosts(logic.GetNetworkHosts(netOld2.NetID))
+		}
+		writeDryRun(w, r, result)
+		return
+	}
 	_, _, _, _, _, err = logic.UpdateNetwork(&netOld1, &netOld2)
 	if err != nil {
-		slog.Info("failed to update network", "user", r.Header.Get("user"), "err", err)
+		slog.InfoCtx(r.Context(), "failed to update network", "user", r.Header.Get("user"), "err", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
+	if netOld2.LANDetection != netOld1.LANDetection || netOld2.AddressFamily != netOld1.AddressFamily {
+		// peers get their endpoints re-selected
+		go func() {
+			if err := mq.PublishNetworkPeerUpdate(payload.NetID); err != nil {
+				slog.Warn("failed to publish peer update after endpoint selection change", "network", payload.NetID, "error", err)
+			}
+		}()
+	}
 
-	slog.Info("updated network", "network", payload.NetID, "user", r.Header.Get("user"))
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(payload)
+	slog.InfoCtx(r.Context(), "updated network", "network", payload.NetID, "user", r.Header.Get("user"))
+	logic.ReturnJSONResponse(w, r, payload)
 }
@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/mq"
+	"golang.org/x/exp/slog"
+)
+
+// maxNetworkBundleSize - the largest network bundle accepted for import
+const maxNetworkBundleSize = 32 << 20
+
+func networkBundleHandlers(r *mux.Router) {
+	r.HandleFunc("/api/networks/{networkname}/export", logic.SecurityCheck(true, http.HandlerFunc(exportNetworkBundle))).Methods(http.MethodGet)
+	r.HandleFunc("/api/networks/import", logic.SecurityCheck(true, http.HandlerFunc(importNetworkBundle))).Methods(http.MethodPost)
+}
+
+// swagger:route GET /api/networks/{networkname}/export networks exportNetworkBundle
+//
+// Export a network as a bundle of its settings, nodes, ACLs, custom DNS and enrollment keys,
+// to import it on another server. The bundle holds the enrollment key values, keep it safe.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: networkBundleResponse
+func exportNetworkBundle(w http.ResponseWriter, r *http.Request) {
+	netname := mux.Vars(r)["networkname"]
+	network, err := logic.GetNetwork(netname)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	if tenant := r.Header.Get("tenant"); tenant != "" && network.Tenant != tenant {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(logic.ErrTenantMismatch, "forbidden"))
+		return
+	}
+	bundle, err := logic.ExportNetworkBundle(netname)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to export network", "user", r.Header.Get("user"), "network", netname, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "exported network", "user", r.Header.Get("user"), "network", netname, "nodes", len(bundle.Nodes))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.json", netname))
+	logic.ReturnJSONResponse(w, r, bundle)
+}
+
+// swagger:route POST /api/networks/import networks importNetworkBundle
+//
+// Import a network bundle exported from another server, renamed to name when set. Nodes whose hosts
+// are on this server, by id or name, join it with new ids, enrollment keys are recreated with new values.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: networkBundleImportResponse
+func importNetworkBundle(w http.ResponseWriter, r *http.Request) {
+	var bundle models.NetworkBundle
+	r.Body = http.MaxBytesReader(w, r.Body, maxNetworkBundleSize)
+	if err := json.NewDecoder(r.Body).Decode(&bundle); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	result, err := logic.ImportNetworkBundle(&bundle, r.URL.Query().Get("name"), r.Header.Get("tenant"))
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to import network", "user", r.Header.Get("user"), "network", result.Network, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "imported network", "user", r.Header.Get("user"), "network", result.Network, "from", bundle.Network.NetID)
+	logic.ReturnJSONResponse(w, r, result)
+	go publishImportedNodes(result.Network)
+}
+
+// publishImportedNodes - tells the hosts of the nodes brought back by an import to join the network
+func publishImportedNodes(network string) {
+	nodes, err := logic.GetNetworkNodes(network)
+	if err != nil {
+		return
+	}
+	for i := range nodes {
+		host, err := logic.GetHost(nodes[i].HostID.String())
+		if err != nil {
+			continue
+		}
+		if err = mq.HostUpdate(&models.HostUpdate{Action: models.JoinHostToNetwork, Host: *host, Node: nodes[i]}); err != nil {
+			slog.Error("failed to send imported node to its host", "node", nodes[i].ID, "host", host.ID, "error", err)
+		}
+	}
+	if err = mq.PublishNetworkPeerUpdate(network); err != nil {
+		slog.Error("failed to publish peer update after import", "network", network, "error", err)
+	}
+}
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logic"
+	"golang.org/x/exp/slog"
+)
+
+func networkFeatureHandlers(r *mux.Router) {
+	r.HandleFunc("/api/networks/{networkname}/features", logic.SecurityCheck(false, http.HandlerFunc(getNetworkFeatures))).Methods(http.MethodGet)
+	r.HandleFunc("/api/networks/{networkname}/features", logic.SecurityCheck(true, http.HandlerFunc(updateNetworkFeatures))).Methods(http.MethodPut)
+}
+
+// swagger:route GET /api/networks/{networkname}/features networks getNetworkFeatures
+//
+// Get the capabilities usable on a network.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: networkFeaturesResponse
+func getNetworkFeatures(w http.ResponseWriter, r *http.Request) {
+	network, err := logic.GetNetwork(mux.Vars(r)["networkname"])
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	logic.ReturnJSONResponse(w, r, logic.GetNetworkFeatures(&network))
+}
+
+// swagger:route PUT /api/networks/{networkname}/features networks updateNetworkFeatures
+//
+// Turn capabilities of a network on or off, the ones left out of the request keep their state.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: networkFeaturesResponse
+func updateNetworkFeatures(w http.ResponseWriter, r *http.Request) {
+	netID := mux.Vars(r)["networkname"]
+	network, err := logic.GetNetwork(netID)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	if tenant := r.Header.Get("tenant"); tenant != "" && network.Tenant != tenant {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(logic.ErrTenantMismatch, "forbidden"))
+		return
+	}
+	features := logic.GetNetworkFeatures(&network)
+	if err := json.NewDecoder(r.Body).Decode(&features); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	if _, err := logic.SetNetworkFeatures(netID, features); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to update network features", "user", r.Header.Get("user"), "network", netID, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "updated network features", "user", r.Header.Get("user"), "network", netID,
+		"extclients", features.ExtClients, "egress", features.Egress, "relays", features.Relays)
+	logic.ReturnJSONResponse(w, r, features)
+}
@@ -2,13 +2,13 @@ package controller
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/gravitl/netmaker/database"
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/logic/pro"
 	"github.com/gravitl/netmaker/models"
@@ -19,7 +19,7 @@ import (
 	"golang.org/x/exp/slog"
 )
 
-var hostIDHeader = "host-id"
+var hostIDHeader = logic.HostIDHeader
 
 func nodeHandlers(r *mux.Router) {
 
@@ -28,10 +28,13 @@ func nodeHandlers(r *mux.Router) {
 	r.HandleFunc("/api/nodes/{network}/{nodeid}", Authorize(true, true, "node", http.HandlerFunc(getNode))).Methods(http.MethodGet)
 	r.HandleFunc("/api/nodes/{network}/{nodeid}", Authorize(false, true, "node", http.HandlerFunc(updateNode))).Methods(http.MethodPut)
 	r.HandleFunc("/api/nodes/{network}/{nodeid}", Authorize(true, true, "node", http.HandlerFunc(deleteNode))).Methods(http.MethodDelete)
+	r.HandleFunc("/api/nodes/{network}/{nodeid}/name", Authorize(false, true, "node", http.HandlerFunc(renameNode))).Methods(http.MethodPut)
+	r.HandleFunc("/api/nodes/{network}/{nodeid}/uptime", Authorize(true, true, "node", http.HandlerFunc(getNodeUptime))).Methods(http.MethodGet)
 	r.HandleFunc("/api/nodes/{network}/{nodeid}/creategateway", Authorize(false, true, "user", checkFreeTierLimits(limitChoiceEgress, http.HandlerFunc(createEgressGateway)))).Methods(http.MethodPost)
 	r.HandleFunc("/api/nodes/{network}/{nodeid}/deletegateway", Authorize(false, true, "user", http.HandlerFunc(deleteEgressGateway))).Methods(http.MethodDelete)
 	r.HandleFunc("/api/nodes/{network}/{nodeid}/createingress", logic.SecurityCheck(false, checkFreeTierLimits(limitChoiceIngress, http.HandlerFunc(createIngressGateway)))).Methods(http.MethodPost)
 	r.HandleFunc("/api/nodes/{network}/{nodeid}/deleteingress", logic.SecurityCheck(false, http.HandlerFunc(deleteIngressGateway))).Methods(http.MethodDelete)
+	r.HandleFunc("/api/nodes/{network}/{nodeid}/deviceconfig", logic.SecurityCheck(true, http.HandlerFunc(getGatewayDeviceConfig))).Methods(http.MethodGet)
 	r.HandleFunc("/api/nodes/{network}/{nodeid}", Authorize(true, true, "node", http.HandlerFunc(updateNode))).Methods(http.MethodPost)
 	r.HandleFunc("/api/nodes/adm/{network}/authenticate", authenticate).Methods(http.MethodPost)
 	r.HandleFunc("/api/v1/nodes/migrate", migrate).Methods(http.MethodPost)
@@ -63,20 +66,19 @@ func authenticate(response http.ResponseWriter, request *http.Request) {
 	if decoderErr != nil {
 		errorResponse.Code = http.StatusBadRequest
 		errorResponse.Message = decoderErr.Error()
-		logger.Log(0, request.Header.Get("user"), "error decoding request body: ",
-			decoderErr.Error())
+		slog.ErrorCtx(request.Context(), "error decoding request body", "user", request.Header.Get("user"), "error", decoderErr)
 		logic.ReturnErrorResponse(response, request, errorResponse)
 		return
 	}
 	errorResponse.Code = http.StatusBadRequest
 	if authRequest.ID == "" {
 		errorResponse.Message = "W1R3: ID can't be empty"
-		logger.Log(0, request.Header.Get("user"), errorResponse.Message)
+		slog.ErrorCtx(request.Context(), errorResponse.Message, "user", request.Header.Get("user"))
 		logic.ReturnErrorResponse(response, request, errorResponse)
 		return
 	} else if authRequest.Password == "" {
 		errorResponse.Message = "W1R3: Password can't be empty"
-		logger.Log(0, request.Header.Get("user"), errorResponse.Message)
+		slog.ErrorCtx(request.Context(), errorResponse.Message, "user", request.Header.Get("user"))
 		logic.ReturnErrorResponse(response, request, errorResponse)
 		return
 	}
@@ -87,8 +89,7 @@ func authenticate(response http.ResponseWriter, request *http.Request) {
 		if err != nil {
 			errorResponse.Code = http.StatusBadRequest
 			errorResponse.Message = err.Error()
-			logger.Log(0, request.Header.Get("user"),
-				fmt.Sprintf("failed to get node info [%s]: %v", authRequest.ID, err))
+			slog.ErrorCtx(request.Context(), fmt.Sprintf("failed to get node info [%s]: %v", authRequest.ID, err), "user", request.Header.Get("user"))
 			logic.ReturnErrorResponse(response, request, errorResponse)
 			return
 		}
@@ -97,8 +98,7 @@ func authenticate(response http.ResponseWriter, request *http.Request) {
 	if err != nil {
 		errorResponse.Code = http.StatusBadRequest
 		errorResponse.Message = err.Error()
-		logger.Log(0, request.Header.Get("user"),
-			"error retrieving host: ", err.Error())
+		slog.ErrorCtx(request.Context(), "error retrieving host", "user", request.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(response, request, errorResponse)
 		return
 	}
@@ -106,8 +106,7 @@ func authenticate(response http.ResponseWriter, request *http.Request) {
 	if err != nil {
 		errorResponse.Code = http.StatusBadRequest
 		errorResponse.Message = err.Error()
-		logger.Log(0, request.Header.Get("user"),
-			"error validating user password: ", err.Error())
+		slog.ErrorCtx(request.Context(), "error validating user password", "user", request.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(response, request, errorResponse)
 		return
 	}
@@ -116,8 +115,7 @@ func authenticate(response http.ResponseWriter, request *http.Request) {
 	if tokenString == "" {
 		errorResponse.Code = http.StatusBadRequest
 		errorResponse.Message = "Could not create Token"
-		logger.Log(0, request.Header.Get("user"),
-			fmt.Sprintf("%s: %v", errorResponse.Message, err))
+		slog.InfoCtx(request.Context(), fmt.Sprintf("%s: %v", errorResponse.Message, err), "user", request.Header.Get("user"))
 		logic.ReturnErrorResponse(response, request, errorResponse)
 		return
 	}
@@ -135,8 +133,7 @@ func authenticate(response http.ResponseWriter, request *http.Request) {
 	if jsonError != nil {
 		errorResponse.Code = http.StatusBadRequest
 		errorResponse.Message = err.Error()
-		logger.Log(0, request.Header.Get("user"),
-			"error marshalling resp: ", err.Error())
+		slog.ErrorCtx(request.Context(), "error marshalling resp", "user", request.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(response, request, errorResponse)
 		return
 	}
@@ -157,6 +154,12 @@ func Authorize(hostAllowed, networkCheck bool, authNetwork string, next http.Han
 		var errorResponse = models.ErrorResponse{
 			Code: http.StatusForbidden, Message: logic.Forbidden_Msg,
 		}
+		r.Header.Del("ismasterkey")
+		r.Header.Set("ismaster", "no")
+		r.Header.Del("tenant")
+		r.Header.Del("user")
+		r.Header.Del(hostIDHeader)
+		r.Header.Del(logic.ImpersonatorHeader)
 
 		var params = mux.Vars(r)
 
@@ -169,6 +172,17 @@ func Authorize(hostAllowed, networkCheck bool, authNetwork string, next http.Han
 		} else {
 			w.Header().Set("Content-Type", "application/json")
 
+			// hosts present their client certificate instead of a token in certificate auth mode
+			if hostAllowed && servercfg.IsHostCertAuth() {
+				if cert := logic.HostCertFromRequest(r); cert != nil {
+					if hostID, err := logic.VerifyHostCertificate(cert); err == nil {
+						r.Header.Set(hostIDHeader, hostID)
+						logic.ServeAuthorized(next, w, r)
+						return
+					}
+				}
+			}
+
 			//get the auth token
 			bearerToken := r.Header.Get("Authorization")
 
@@ -185,13 +199,13 @@ func Authorize(hostAllowed, networkCheck bool, authNetwork string, next http.Han
 				return
 			}
 			// check if host instead of user
-			if hostAllowed {
+			if hostAllowed && !servercfg.IsHostCertAuth() {
 				// TODO --- should ensure that node is only operating on itself
 				if hostID, _, _, err := logic.VerifyHostToken(authToken); err == nil {
 					r.Header.Set(hostIDHeader, hostID)
 					// this indicates request is from a node
 					// used for failover - if a getNode comes from node, this will trigger a metrics wipe
-					next.ServeHTTP(w, r)
+					logic.ServeAuthorized(next, w, r)
 					return
 				}
 			}
@@ -203,12 +217,33 @@ func Authorize(hostAllowed, networkCheck bool, authNetwork string, next http.Han
 				logic.ReturnErrorResponse(w, r, errorResponse)
 				return
 			}
+			if err := logic.CheckAPIAllowlist(r, username); err != nil {
+				logic.ReturnErrorResponse(w, r, logic.FormatError(err, "forbidden"))
+				return
+			}
 
-			isnetadmin := isadmin
-			if errN == nil && isadmin {
-				nodeID = "mastermac"
-				isAuthorized = true
-				r.Header.Set("ismasterkey", "yes")
+			isnetadmin := false
+			if isadmin {
+				if tenant := logic.GetUserTenant(username); tenant == "" {
+					isnetadmin = true
+					nodeID = "mastermac"
+					isAuthorized = true
+					r.Header.Set("ismasterkey", "yes")
+					r.Header.Set("ismaster", "yes")
+				} else {
+					// tenant admins administer the networks, hosts and keys of their tenant and nothing else
+					if !logic.ResourcesInTenant(params, tenant) {
+						logic.ReturnErrorResponse(w, r, errorResponse)
+						return
+					}
+					tenantNetworks, err := logic.GetTenantNetworks(tenant)
+					if err != nil {
+						logic.ReturnErrorResponse(w, r, errorResponse)
+						return
+					}
+					isnetadmin = params["network"] == "" || logic.StringSliceContains(tenantNetworks, params["network"])
+					r.Header.Set("tenant", tenant)
+				}
 			}
 			if !isadmin && params["network"] != "" {
 				if logic.StringSliceContains(networks, params["network"]) && pro.IsUserNetAdmin(params["network"], username) {
@@ -260,7 +295,7 @@ func Authorize(hostAllowed, networkCheck bool, authNetwork string, next http.Han
 					username = "(user not found)"
 				}
 				r.Header.Set("user", username)
-				next.ServeHTTP(w, r)
+				logic.ServeAuthorized(next, w, r)
 			}
 		}
 	}
@@ -268,7 +303,8 @@ func Authorize(hostAllowed, networkCheck bool, authNetwork string, next http.Han
 
 // swagger:route GET /api/nodes/{network} nodes getNetworkNodes
 //
-// Gets all nodes associated with network including pending nodes.
+// Gets all nodes associated with network including pending nodes. Accepts fields to pick the fields
+// returned, and watch=true with resourceVersion (from the X-Resource-Version header) to wait for node changes instead.
 //
 //			Schemes: https
 //
@@ -281,24 +317,29 @@ func getNetworkNodes(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	var params = mux.Vars(r)
 	networkName := params["network"]
+	if isWatch(r) {
+		watchNodes(w, r, []string{networkName})
+		return
+	}
+	version := logic.CurrentResourceVersion()
 	nodes, err := logic.GetNetworkNodes(networkName)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("error fetching nodes on network %s: %v", networkName, err))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("error fetching nodes on network %s: %v", networkName, err), "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
 
 	// returns all the nodes in JSON/API format
 	apiNodes := logic.GetAllNodesAPI(nodes[:])
-	logger.Log(2, r.Header.Get("user"), "fetched nodes on network", networkName)
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(apiNodes)
+	slog.DebugCtx(r.Context(), "fetched nodes on network", "user", r.Header.Get("user"), "network_name", networkName)
+	setResourceVersion(w, version)
+	writeList(w, r, apiNodes)
 }
 
 // swagger:route GET /api/nodes nodes getAllNodes
 //
-// Get all nodes across all networks.
+// Get all nodes across all networks. Accepts fields to pick the fields returned, and watch=true
+// with resourceVersion (from the X-Resource-Version header) to wait for node changes instead.
 //
 //			Schemes: https
 //
@@ -313,34 +354,53 @@ func getAllNodes(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	user, err := logic.GetUser(r.Header.Get("user"))
 	if err != nil && r.Header.Get("ismasterkey") != "yes" {
-		logger.Log(0, r.Header.Get("user"),
-			"error fetching user info: ", err.Error())
+		slog.ErrorCtx(r.Context(), "error fetching user info", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
+	if isWatch(r) {
+		var networks []string
+		if user.IsAdmin && user.Tenant != "" {
+			if networks, err = logic.GetTenantNetworks(user.Tenant); err != nil {
+				logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+				return
+			}
+		} else if !user.IsAdmin && r.Header.Get("ismasterkey") != "yes" {
+			networks = append([]string{}, user.Networks...)
+		}
+		watchNodes(w, r, networks)
+		return
+	}
+	version := logic.CurrentResourceVersion()
 	var nodes []models.Node
-	if user.IsAdmin || r.Header.Get("ismasterkey") == "yes" {
+	if user.IsAdmin && user.Tenant != "" {
+		nodes, err = getTenantNodes(user.Tenant)
+		if err != nil {
+			slog.ErrorCtx(r.Context(), "error fetching nodes", "user", r.Header.Get("user"), "error", err)
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+			return
+		}
+	} else if user.IsAdmin || r.Header.Get("ismasterkey") == "yes" {
 		nodes, err = logic.GetAllNodes()
 		if err != nil {
-			logger.Log(0, "error fetching all nodes info: ", err.Error())
+			slog.ErrorCtx(r.Context(), "error fetching all nodes info", "error", err)
 			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 			return
 		}
 	} else {
 		nodes, err = getUsersNodes(*user)
 		if err != nil {
-			logger.Log(0, r.Header.Get("user"),
-				"error fetching nodes: ", err.Error())
+			slog.ErrorCtx(r.Context(), "error fetching nodes", "user", r.Header.Get("user"), "error", err)
 			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 			return
 		}
 	}
 	// return all the nodes in JSON/API format
 	apiNodes := logic.GetAllNodesAPI(nodes[:])
-	logger.Log(3, r.Header.Get("user"), "fetched all nodes they have access to")
+	slog.DebugCtx(r.Context(), "fetched all nodes they have access to", "user", r.Header.Get("user"))
 	logic.SortApiNodes(apiNodes[:])
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(apiNodes)
+	setResourceVersion(w, version)
+	writeList(w, r, apiNodes)
 }
 
 func getUsersNodes(user models.User) ([]models.Node, error) {
@@ -356,6 +416,14 @@ func getUsersNodes(user models.User) ([]models.Node, error) {
 	return nodes, err
 }
 
+func getTenantNodes(tenant string) ([]models.Node, error) {
+	networks, err := logic.GetTenantNetworks(tenant)
+	if err != nil {
+		return nil, err
+	}
+	return getUsersNodes(models.User{Networks: networks})
+}
+
 // swagger:route GET /api/nodes/{network}/{nodeid} nodes getNode
 //
 // Get an individual node.
@@ -382,22 +450,19 @@ func getNode(w http.ResponseWriter, r *http.Request) {
 	}
 	host, err := logic.GetHost(node.HostID.String())
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("error fetching host for node [ %s ] info: %v", nodeid, err))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("error fetching host for node [ %s ] info: %v", nodeid, err), "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
 	allNodes, err := logic.GetAllNodes()
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("error fetching wg peers config for host [ %s ]: %v", host.ID.String(), err))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("error fetching wg peers config for host [ %s ]: %v", host.ID.String(), err), "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
 	hostPeerUpdate, err := logic.GetPeerUpdateForHost(node.Network, host, allNodes, nil, nil)
 	if err != nil && !database.IsEmptyRecord(err) {
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("error fetching wg peers config for host [ %s ]: %v", host.ID.String(), err))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("error fetching wg peers config for host [ %s ]: %v", host.ID.String(), err), "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
@@ -417,20 +482,55 @@ func getNode(w http.ResponseWriter, r *http.Request) {
 
 	if servercfg.Is_EE && nodeRequest {
 		if err = logic.EnterpriseResetAllPeersFailovers(node.ID, node.Network); err != nil {
-			logger.Log(1, "failed to reset failover list during node config pull", node.ID.String(), node.Network)
+			slog.ErrorCtx(r.Context(), "failed to reset failover list during node config pull", "node_id", node.ID.String(), "network", node.Network)
 		}
 	}
 
-	logger.Log(2, r.Header.Get("user"), "fetched node", params["nodeid"])
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	slog.DebugCtx(r.Context(), "fetched node", "user", r.Header.Get("user"), "nodeid", params["nodeid"])
+	logic.ReturnJSONResponse(w, r, response)
 }
 
 // == EGRESS ==
 
+// swagger:route GET /api/nodes/{network}/{nodeid}/uptime nodes getNodeUptime
+//
+// Get a node's online/offline transitions and its uptime over comma separated windows, such as 24h,7d,30d.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: nodeUptimeResponse
+func getNodeUptime(w http.ResponseWriter, r *http.Request) {
+	var params = mux.Vars(r)
+	node, err := validateParams(params["nodeid"], params["network"])
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	var windows []string
+	if v := r.URL.Query().Get("windows"); v != "" {
+		windows = strings.Split(v, ",")
+	}
+	uptime, err := logic.GetNodeUptime(&node, windows)
+	if err != nil {
+		if errors.Is(err, logic.ErrInvalidUptimeWindow) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		slog.ErrorCtx(r.Context(), "failed to fetch uptime of node", "user", r.Header.Get("user"), "node_id", node.ID.String(), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnJSONResponse(w, r, uptime)
+}
+
 // swagger:route POST /api/nodes/{network}/{nodeid}/creategateway nodes createEgressGateway
 //
 // Create an egress gateway.
+// With dryRun=true, returns what the change would do without saving it.
 //
 //			Schemes: https
 //
@@ -449,34 +549,51 @@ func createEgressGateway(w http.ResponseWriter, r *http.Request) {
 	}
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewDecoder(r.Body).Decode(&gateway); err != nil {
-		logger.Log(0, r.Header.Get("user"), "error decoding request body: ", err.Error())
+		slog.ErrorCtx(r.Context(), "error decoding request body", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
 	gateway.NetID = params["network"]
 	gateway.NodeID = params["nodeid"]
+	if err := logic.CheckNetworkFeature(gateway.NetID, models.NetworkFeatureEgress); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "forbidden"))
+		return
+	}
+	if isDryRun(r) {
+		node, err = logic.PrepareEgressGateway(gateway)
+		if err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		writeDryRun(w, r, models.DryRunResult{
+			Result:      node.ConvertToAPINode(),
+			PeerUpdates: logic.DryRunHosts(logic.GetAffectedHosts(&node)),
+			Allocations: node.EgressGatewayRanges,
+		})
+		return
+	}
 	node, err = logic.CreateEgressGateway(gateway)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("failed to create egress gateway on node [%s] on network [%s]: %v",
-				gateway.NodeID, gateway.NetID, err))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("failed to create egress gateway on node [%s] on network [%s]: %v",
+			gateway.NodeID, gateway.NetID, err), "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
 
 	apiNode := node.ConvertToAPINode()
-	logger.Log(1, r.Header.Get("user"), "created egress gateway on node", gateway.NodeID, "on network", gateway.NetID)
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(apiNode)
+	slog.InfoCtx(r.Context(), "created egress gateway on node on network", "user", r.Header.Get("user"), "node_id", gateway.NodeID, "net_id", gateway.NetID)
+	logic.ReturnJSONResponse(w, r, apiNode)
 	go func() {
-		mq.PublishPeerUpdate()
+		mq.PublishNodePeerUpdate(&node)
 	}()
+	go syncCloudRoutes(node.Network)
 	runUpdates(&node, true)
 }
 
 // swagger:route DELETE /api/nodes/{network}/{nodeid}/deletegateway nodes deleteEgressGateway
 //
 // Delete an egress gateway.
+// With dryRun=true, returns what the change would do without saving it.
 //
 //			Schemes: https
 //
@@ -496,22 +613,29 @@ func deleteEgressGateway(w http.ResponseWriter, r *http.Request) {
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "bad request"))
 		return
 	}
+	if isDryRun(r) {
+		peerUpdates := logic.DryRunHosts(logic.GetAffectedHosts(&node))
+		node.IsEgressGateway = false
+		node.EgressGatewayRanges = []string{}
+		node.EgressGatewayRequest = models.EgressGatewayRequest{}
+		writeDryRun(w, r, models.DryRunResult{Result: node.ConvertToAPINode(), PeerUpdates: peerUpdates})
+		return
+	}
 	node, err = logic.DeleteEgressGateway(netid, nodeid)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("failed to delete egress gateway on node [%s] on network [%s]: %v",
-				nodeid, netid, err))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("failed to delete egress gateway on node [%s] on network [%s]: %v",
+			nodeid, netid, err), "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
 
 	apiNode := node.ConvertToAPINode()
-	logger.Log(1, r.Header.Get("user"), "deleted egress gateway on node", nodeid, "on network", netid)
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(apiNode)
+	slog.InfoCtx(r.Context(), "deleted egress gateway on node on network", "user", r.Header.Get("user"), "nodeid", nodeid, "netid", netid)
+	logic.ReturnJSONResponse(w, r, apiNode)
 	go func() {
-		mq.PublishPeerUpdate()
+		mq.PublishNodePeerUpdate(&node)
 	}()
+	go syncCloudRoutes(node.Network)
 	runUpdates(&node, true)
 }
 
@@ -538,27 +662,29 @@ func createIngressGateway(w http.ResponseWriter, r *http.Request) {
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "bad request"))
 		return
 	}
+	if err := logic.CheckNetworkFeature(netid, models.NetworkFeatureExtClients); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "forbidden"))
+		return
+	}
 	var request models.IngressRequest
 	json.NewDecoder(r.Body).Decode(&request)
 	node, err = logic.CreateIngressGateway(netid, nodeid, request)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("failed to create ingress gateway on node [%s] on network [%s]: %v",
-				nodeid, netid, err))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("failed to create ingress gateway on node [%s] on network [%s]: %v",
+			nodeid, netid, err), "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
 
 	if servercfg.Is_EE && request.Failover {
 		if err = logic.EnterpriseResetFailoverFunc(node.Network); err != nil {
-			logger.Log(1, "failed to reset failover list during failover create", node.ID.String(), node.Network)
+			slog.ErrorCtx(r.Context(), "failed to reset failover list during failover create", "node_id", node.ID.String(), "network", node.Network)
 		}
 	}
 
 	apiNode := node.ConvertToAPINode()
-	logger.Log(1, r.Header.Get("user"), "created ingress gateway on node", nodeid, "on network", netid)
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(apiNode)
+	slog.InfoCtx(r.Context(), "created ingress gateway on node on network", "user", r.Header.Get("user"), "nodeid", nodeid, "netid", netid)
+	logic.ReturnJSONResponse(w, r, apiNode)
 
 	runUpdates(&node, true)
 }
@@ -586,23 +712,21 @@ func deleteIngressGateway(w http.ResponseWriter, r *http.Request) {
 	}
 	node, wasFailover, removedClients, err := logic.DeleteIngressGateway(nodeid)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("failed to delete ingress gateway on node [%s] on network [%s]: %v",
-				nodeid, netid, err))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("failed to delete ingress gateway on node [%s] on network [%s]: %v",
+			nodeid, netid, err), "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
 
 	if servercfg.Is_EE && wasFailover {
 		if err = logic.EnterpriseResetFailoverFunc(node.Network); err != nil {
-			logger.Log(1, "failed to reset failover list during failover create", node.ID.String(), node.Network)
+			slog.ErrorCtx(r.Context(), "failed to reset failover list during failover create", "node_id", node.ID.String(), "network", node.Network)
 		}
 	}
 
 	apiNode := node.ConvertToAPINode()
-	logger.Log(1, r.Header.Get("user"), "deleted ingress gateway", nodeid)
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(apiNode)
+	slog.InfoCtx(r.Context(), "deleted ingress gateway", "user", r.Header.Get("user"), "nodeid", nodeid)
+	logic.ReturnJSONResponse(w, r, apiNode)
 
 	if len(removedClients) > 0 {
 		host, err := logic.GetHost(node.HostID.String())
@@ -623,6 +747,43 @@ func deleteIngressGateway(w http.ResponseWriter, r *http.Request) {
 	runUpdates(&node, true)
 }
 
+// swagger:route GET /api/nodes/{network}/{nodeid}/deviceconfig nodes getGatewayDeviceConfig
+//
+// Get the config of a gateway with the peers the server gives it, to run it on an existing firewall.
+// The format may be wireguard (the default), routeros, opnsense or pfsense.
+// The gateway's private key is left for its owner to fill in.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: successResponse
+func getGatewayDeviceConfig(w http.ResponseWriter, r *http.Request) {
+	var params = mux.Vars(r)
+	node, err := validateParams(params["nodeid"], params["network"])
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	config, err := logic.GatewayDeviceConfig(&node)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to render config of gateway", "user", r.Header.Get("user"), "node_id", node.ID.String(), "error", err)
+		if errors.Is(err, logic.ErrNotGateway) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	name := config.Name
+	if host, err := logic.GetHost(node.HostID.String()); err == nil {
+		name = logic.NodeName(&node, host)
+	}
+	writeDeviceConfig(w, r, name, &config)
+}
+
 // swagger:route PUT /api/nodes/{network}/{nodeid} nodes updateNode
 //
 // Update an individual node.
@@ -650,7 +811,7 @@ func updateNode(w http.ResponseWriter, r *http.Request) {
 	// we decode our body request params
 	err = json.NewDecoder(r.Body).Decode(&newData)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"), "error decoding request body: ", err.Error())
+		slog.ErrorCtx(r.Context(), "error decoding request body", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
@@ -669,23 +830,27 @@ func updateNode(w http.ResponseWriter, r *http.Request) {
 	}
 	host, err := logic.GetHost(newNode.HostID.String())
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("failed to get host for node  [ %s ] info: %v", nodeid, err))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("failed to get host for node  [ %s ] info: %v", nodeid, err), "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
 	ifaceDelta := logic.IfaceDelta(&currentNode, newNode)
 	aclUpdate := currentNode.DefaultACL != newNode.DefaultACL
+	// the node may have moved between zones
+	tagsUpdate := len(logic.StringDifference(currentNode.Tags, newNode.Tags)) > 0 || len(logic.StringDifference(newNode.Tags, currentNode.Tags)) > 0
 	if ifaceDelta && servercfg.Is_EE {
 		if err = logic.EnterpriseResetAllPeersFailovers(currentNode.ID, currentNode.Network); err != nil {
-			logger.Log(0, "failed to reset failover lists during node update for node", currentNode.ID.String(), currentNode.Network)
+			slog.ErrorCtx(r.Context(), "failed to reset failover lists during node update for node", "node_id", currentNode.ID.String(), "network", currentNode.Network)
 		}
 	}
 
 	err = logic.UpdateNode(&currentNode, newNode)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("failed to update node info [ %s ] info: %v", nodeid, err))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("failed to update node info [ %s ] info: %v", nodeid, err), "user", r.Header.Get("user"))
+		if errors.Is(err, logic.ErrStaleRevision) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "conflict"))
+			return
+		}
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
@@ -700,27 +865,96 @@ func updateNode(w http.ResponseWriter, r *http.Request) {
 	if servercfg.IsDNSMode() {
 		logic.SetDNS()
 	}
+	if tagsUpdate {
+		if result, err := logic.ApplyNetworkZones(newNode.Network, false); err != nil {
+			slog.ErrorCtx(r.Context(), "failed to compile zones of network after tagging node", "network", newNode.Network, "new_node_id", newNode.ID.String(), "error", err)
+		} else if result.Changed > 0 {
+			go func() {
+				if err := mq.PublishNetworkPeerUpdate(newNode.Network); err != nil {
+					slog.ErrorCtx(r.Context(), "failed to publish peer update after compiling zones of network", "network", newNode.Network, "error", err)
+				}
+			}()
+		}
+	}
 
 	apiNode := newNode.ConvertToAPINode()
-	logger.Log(1, r.Header.Get("user"), "updated node", currentNode.ID.String(), "on network", currentNode.Network)
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(apiNode)
+	slog.InfoCtx(r.Context(), "updated node on network", "user", r.Header.Get("user"), "node_id", currentNode.ID.String(), "network", currentNode.Network)
+	logic.ReturnJSONResponse(w, r, apiNode)
 	runUpdates(newNode, ifaceDelta)
 	go func(aclUpdate, relayupdate bool, newNode *models.Node) {
 		if aclUpdate || relayupdate {
-			if err := mq.PublishPeerUpdate(); err != nil {
-				logger.Log(0, "error during node ACL update for node", newNode.ID.String())
+			if err := mq.PublishNodePeerUpdate(newNode); err != nil {
+				slog.ErrorCtx(r.Context(), "error during node ACL update for node", "new_node_id", newNode.ID.String())
 			}
 		}
 		if err := mq.PublishReplaceDNS(&currentNode, newNode, host); err != nil {
-			logger.Log(1, "failed to publish dns update", err.Error())
+			slog.ErrorCtx(r.Context(), "failed to publish dns update", "error", err)
 		}
 	}(aclUpdate, relayupdate, newNode)
 }
 
+// swagger:route PUT /api/nodes/{network}/{nodeid}/name nodes renameNode
+//
+// Rename a node within its network, following the network's naming policy.
+// Its dns records and the names its peers know it by change along with it.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: nodeResponse
+func renameNode(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var params = mux.Vars(r)
+	node, err := validateParams(params["nodeid"], params["network"])
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "bad request"))
+		return
+	}
+	var request models.NodeNameRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		slog.ErrorCtx(r.Context(), "error decoding request body", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	oldName, err := logic.RenameNode(&node, request.Name)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to rename node", "user", r.Header.Get("user"), "node_id", node.ID.String(), "error", err)
+		switch {
+		case errors.Is(err, logic.ErrInvalidNodeName):
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		case errors.Is(err, logic.ErrNodeNameTaken):
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "conflict"))
+		default:
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		}
+		return
+	}
+	if servercfg.IsDNSMode() {
+		logic.SetDNS()
+	}
+	slog.InfoCtx(r.Context(), "renamed node on network", "user", r.Header.Get("user"), "node_id", node.ID.String(), "old_name", oldName, "node_name", node.Name, "network", node.Network)
+	logic.ReturnJSONResponse(w, r, node.ConvertToAPINode())
+	if oldName == node.Name {
+		return
+	}
+	go func() {
+		if err := mq.PublishNodeRenameDNS(node.Network, oldName, node.Name); err != nil {
+			slog.ErrorCtx(r.Context(), "failed to publish dns update", "error", err)
+		}
+		if err := mq.PublishNodePeerUpdate(&node); err != nil {
+			slog.ErrorCtx(r.Context(), "failed to publish peer update after node rename", "error", err)
+		}
+	}()
+}
+
 // swagger:route DELETE /api/nodes/{network}/{nodeid} nodes deleteNode
 //
 // Delete an individual node.
+// It can be restored from the trash until its retention is over.
 //
 //			Schemes: https
 //
@@ -774,24 +1008,30 @@ func deleteNode(w http.ResponseWriter, r *http.Request) {
 		logic.ReturnErrorResponse(w, r, logic.FormatError(fmt.Errorf("failed to delete node"), "internal"))
 		return
 	}
+	if err := logic.TrashNode(&node, r.Header.Get("user")); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to trash deleted node", "nodeid", nodeid, "error", err)
+	}
 
 	logic.ReturnSuccessResponse(w, r, nodeid+" deleted.")
-	logger.Log(1, r.Header.Get("user"), "Deleted node", nodeid, "from network", params["network"])
+	slog.InfoCtx(r.Context(), "Deleted node from network", "user", r.Header.Get("user"), "nodeid", nodeid, "network", params["network"])
 	if !fromNode { // notify node change
 		runUpdates(&node, false)
 	}
+	if node.IsEgressGateway {
+		go syncCloudRoutes(node.Network)
+	}
 	go func() { // notify of peer change
 		var err error
 		err = mq.PublishDeletedNodePeerUpdate(&node)
 		if err != nil {
-			logger.Log(1, "error publishing peer update ", err.Error())
+			slog.ErrorCtx(r.Context(), "error publishing peer update", "error", err)
 		}
 		host, err := logic.GetHost(node.HostID.String())
 		if err != nil {
-			logger.Log(1, "failed to retrieve host for node", node.ID.String(), err.Error())
+			slog.ErrorCtx(r.Context(), "failed to retrieve host for node", "node_id", node.ID.String(), "error", err)
 		}
 		if err := mq.PublishDNSDelete(&node, host); err != nil {
-			logger.Log(1, "error publishing dns update", err.Error())
+			slog.ErrorCtx(r.Context(), "error publishing dns update", "error", err)
 		}
 	}()
 }
@@ -800,7 +1040,7 @@ func runUpdates(node *models.Node, ifaceDelta bool) {
 	go func() { // don't block http response
 		// publish node update if not server
 		if err := mq.NodeUpdate(node); err != nil {
-			logger.Log(1, "error publishing node update to node", node.ID.String(), err.Error())
+			slog.Error("error publishing node update to node", "node_id", node.ID.String(), "error", err)
 		}
 	}()
 }
@@ -1,10 +1,15 @@
 package controller
 
 import (
+	"encoding/json"
+	"fmt"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 	"github.com/gravitl/netmaker/database"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/logic/acls"
@@ -73,6 +78,21 @@ func TestCreateEgressGateway(t *testing.T) {
 		assert.Equal(t, true, node.IsEgressGateway)
 		assert.Equal(t, gateway.Ranges, node.EgressGatewayRanges)
 	})
+	t.Run("DryRun", func(t *testing.T) {
+		var gateway models.EgressGatewayRequest
+		gateway.Ranges = []string{"10.100.101.0/24"}
+		gateway.NetID = "skynet"
+		deleteAllNodes()
+		testnode := createTestNode()
+		gateway.NodeID = testnode.ID.String()
+
+		node, err := logic.PrepareEgressGateway(gateway)
+		assert.Nil(t, err)
+		assert.True(t, node.IsEgressGateway)
+		saved, err := logic.GetNodeByID(testnode.ID.String())
+		assert.Nil(t, err)
+		assert.False(t, saved.IsEgressGateway)
+	})
 
 }
 func TestDeleteEgressGateway(t *testing.T) {
@@ -186,7 +206,7 @@ func TestNodeACLs(t *testing.T) {
 		currentACL.Save(acls.ContainerID(node1.Network))
 	})
 	t.Run("node acls correct after add new node not allowed", func(t *testing.T) {
-		node3 := createNodeWithParams("", "10.0.0.100/32")
+		node3 := createNodeWithParams("", "10.0.0.150/32")
 		createNodeHosts()
 		n, e := logic.GetNetwork(node3.Network)
 		assert.Nil(t, e)
@@ -216,6 +236,107 @@ func TestNodeACLs(t *testing.T) {
 	deleteAllNodes()
 }
 
+func TestAuthorizeTenantAdmin(t *testing.T) {
+	deleteAllNetworks()
+	createNet()
+	assert.Nil(t, logic.CreateTenant(&models.Tenant{ID: "acme"}))
+	defer logic.DeleteTenant("acme")
+	_, err := logic.CreateNetwork(models.Network{NetID: "acmenet", AddressRange: "10.20.0.0/24", Tenant: "acme"})
+	assert.Nil(t, err)
+	defer logic.DeleteNetwork("acmenet")
+	assert.Nil(t, logic.CreateUser(&models.User{UserName: "acmeadmin", Password: "password", IsAdmin: true, Tenant: "acme"}))
+	defer logic.DeleteUser("acmeadmin")
+	token, err := logic.CreateUserJWT("acmeadmin", nil, true)
+	assert.Nil(t, err)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/nodes/{network}", Authorize(false, true, "network", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "acme", r.Header.Get("tenant"))
+		assert.Empty(t, r.Header.Get("ismasterkey"))
+		w.WriteHeader(http.StatusOK)
+	})))
+	request := func(network string) int {
+		r := httptest.NewRequest(http.MethodGet, "/api/nodes/"+network, nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		// forged headers are dropped
+		r.Header.Set("ismasterkey", "yes")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, request("acmenet"))
+	assert.Equal(t, http.StatusForbidden, request("skynet"), "networks of other tenants are out of reach")
+}
+
+func TestAuthorizePolicy(t *testing.T) {
+	deleteAllNetworks()
+	createNet()
+	var received models.OPAInput
+	policy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Input models.OPAInput `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		received = body.Input
+		w.Write([]byte(fmt.Sprintf(`{"result": %t}`, received.Action != "delete")))
+	}))
+	defer policy.Close()
+	t.Setenv("OPA_URL", policy.URL)
+	assert.Nil(t, logic.CreateUser(&models.User{UserName: "policyadmin", Password: "password", IsAdmin: true}))
+	defer logic.DeleteUser("policyadmin")
+	token, err := logic.CreateUserJWT("policyadmin", nil, true)
+	assert.Nil(t, err)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/nodes/{network}", Authorize(false, true, "network", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+	request := func(method string) int {
+		r := httptest.NewRequest(method, "/api/nodes/skynet", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, request(http.MethodGet))
+	assert.Equal(t, "policyadmin", received.User)
+	assert.True(t, received.Admin)
+	assert.Equal(t, "/api/nodes/{network}", received.Route)
+	assert.Equal(t, http.StatusForbidden, request(http.MethodDelete))
+
+	t.Run("BreakGlass", func(t *testing.T) {
+		t.Setenv("OPA_URL", "http://127.0.0.1:1")
+		assert.Equal(t, http.StatusForbidden, request(http.MethodGet))
+		t.Setenv("OPA_BREAK_GLASS", "on")
+		assert.Equal(t, http.StatusOK, request(http.MethodGet))
+	})
+}
+
+func TestAuthorizeQuarantinedHost(t *testing.T) {
+	host := models.Host{ID: uuid.New(), Name: "authorizedhost"}
+	assert.Nil(t, logic.UpsertHost(&host))
+	defer logic.RemoveHostByID(host.ID.String())
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/host", Authorize(true, false, "host", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, host.ID.String(), r.Header.Get(hostIDHeader))
+		w.WriteHeader(http.StatusOK)
+	})))
+	request := func() int {
+		token, err := logic.CreateJWT(host.ID.String(), "", "")
+		assert.Nil(t, err)
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/host", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, request())
+	_, err := logic.QuarantineHost(host.ID.String(), "admin", &models.HostQuarantineRequest{Reason: "stolen"})
+	assert.Nil(t, err)
+	defer logic.ReleaseHostQuarantine(host.ID.String(), "admin")
+	assert.Equal(t, http.StatusForbidden, request())
+}
+
 func deleteAllNodes() {
 	logic.ClearNodeCache()
 	database.DeleteAllRecords(database.NODES_TABLE_NAME)
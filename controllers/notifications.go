@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+func notificationHandlers(r *mux.Router) {
+	r.HandleFunc("/api/notifications/channels", logic.SecurityCheck(true, http.HandlerFunc(getNotificationChannels))).Methods(http.MethodGet)
+	r.HandleFunc("/api/notifications/channels", logic.SecurityCheck(true, http.HandlerFunc(createNotificationChannel))).Methods(http.MethodPost)
+	r.HandleFunc("/api/notifications/channels/{channelid}", logic.SecurityCheck(true, http.HandlerFunc(deleteNotificationChannel))).Methods(http.MethodDelete)
+	r.HandleFunc("/api/notifications/channels/{channelid}/test", logic.SecurityCheck(true, http.HandlerFunc(testNotificationChannel))).Methods(http.MethodPost)
+}
+
+// swagger:route GET /api/notifications/channels notifications getNotificationChannels
+//
+// Lists the slack, teams, discord and webhook channels alerts are sent to, without their urls.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: notificationChannelsResponse
+func getNotificationChannels(w http.ResponseWriter, r *http.Request) {
+	channels, err := logic.GetNotificationChannels(r.Header.Get("tenant"))
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to fetch notification channels", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	writeList(w, r, channels)
+}
+
+// swagger:route POST /api/notifications/channels notifications createNotificationChannel
+//
+// Adds a slack, teams or discord incoming webhook, or a plain webhook, as a channel alerts are sent to.
+// The channel gets the events of the types it lists, and the alerts of the probes, egress health checks
+// and scheduled reports that name it.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: notificationChannelResponse
+func createNotificationChannel(w http.ResponseWriter, r *http.Request) {
+	var channel models.NotificationChannel
+	if err := json.NewDecoder(r.Body).Decode(&channel); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	channel.Tenant = r.Header.Get("tenant")
+	if err := logic.CreateNotificationChannel(&channel); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to create notification channel", "user", r.Header.Get("user"), "error", err)
+		var validationErrs validator.ValidationErrors
+		if errors.As(err, &validationErrs) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "created notification channel", "user", r.Header.Get("user"), "channel", channel.Name, "kind", channel.Kind)
+	logic.ReturnJSONResponse(w, r, channel)
+}
+
+// swagger:route DELETE /api/notifications/channels/{channelid} notifications deleteNotificationChannel
+//
+// Stops sending alerts to a channel.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: successResponse
+func deleteNotificationChannel(w http.ResponseWriter, r *http.Request) {
+	channel, ok := fetchNotificationChannel(w, r)
+	if !ok {
+		return
+	}
+	if err := logic.DeleteNotificationChannel(channel.ID); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to delete notification channel", "user", r.Header.Get("user"), "channel", channel.ID, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "deleted notification channel", "user", r.Header.Get("user"), "channel", channel.Name)
+	logic.ReturnSuccessResponse(w, r, "deleted notification channel "+channel.Name)
+}
+
+// swagger:route POST /api/notifications/channels/{channelid}/test notifications testNotificationChannel
+//
+// Sends a test message to a channel, failing when the channel doesn't accept it.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: successResponse
+func testNotificationChannel(w http.ResponseWriter, r *http.Request) {
+	channel, ok := fetchNotificationChannel(w, r)
+	if !ok {
+		return
+	}
+	notification := models.Notification{
+		Severity: models.NotificationInfo,
+		Title:    "netmaker test notification",
+		Text:     "alerts will be sent to the channel " + channel.Name,
+		Tenant:   channel.Tenant,
+		Fields:   []models.NotificationField{{Name: "requested by", Value: r.Header.Get("user")}},
+		Time:     time.Now().UTC(),
+	}
+	if err := logic.SendNotification(&channel, &notification); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to send test notification", "user", r.Header.Get("user"), "channel", channel.Name, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	logic.ReturnSuccessResponse(w, r, "sent test notification to "+channel.Name)
+}
+
+// fetchNotificationChannel - the channel of a request, checking a tenant admin's request is for a channel of their
+// tenant; writes the error response when it can't be used
+func fetchNotificationChannel(w http.ResponseWriter, r *http.Request) (models.NotificationChannel, bool) {
+	channel, err := logic.GetNotificationChannel(mux.Vars(r)["channelid"])
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+			return channel, false
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return channel, false
+	}
+	if tenant := r.Header.Get("tenant"); tenant != "" && channel.Tenant != tenant {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(logic.ErrTenantMismatch, "forbidden"))
+		return channel, false
+	}
+	return channel, true
+}
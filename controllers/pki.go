@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+// maxOCSPRequestSize - OCSP requests are small, larger bodies are refused
+const maxOCSPRequestSize = 10 * 1024
+
+func pkiHandlers(r *mux.Router) {
+	r.HandleFunc("/api/v1/pki/ca", http.HandlerFunc(getHostCA)).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/pki/crl", http.HandlerFunc(getHostCRL)).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/pki/ocsp", http.HandlerFunc(respondOCSP)).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/pki/ocsp/{request}", http.HandlerFunc(respondOCSP)).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/pki/certificates", logic.SuperAdminCheck(http.HandlerFunc(getPKICertificates))).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/pki/certificates", logic.SuperAdminCheck(http.HandlerFunc(issuePKICertificate))).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/pki/certificates/{serial}", logic.SuperAdminCheck(http.HandlerFunc(getPKICertificate))).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/pki/certificates/{serial}", logic.SuperAdminCheck(http.HandlerFunc(revokePKICertificate))).Methods(http.MethodDelete)
+	r.HandleFunc("/api/v1/pki/certificates/{serial}/renew", logic.SuperAdminCheck(http.HandlerFunc(renewPKICertificate))).Methods(http.MethodPost)
+}
+
+// swagger:route GET /api/v1/pki/certificates pki getPKICertificates
+//
+// List the certificates issued by the internal CA, newest first, of a kind (host, broker or server) if given.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: pkiCertificatesResponse
+func getPKICertificates(w http.ResponseWriter, r *http.Request) {
+	certs, err := logic.GetCertificates(r.URL.Query().Get("kind"))
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	writeList(w, r, certs)
+}
+
+// swagger:route GET /api/v1/pki/certificates/{serial} pki getPKICertificate
+//
+// Get a certificate issued by the internal CA.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: pkiCertificateResponse
+func getPKICertificate(w http.ResponseWriter, r *http.Request) {
+	cert, err := logic.GetCertificate(mux.Vars(r)["serial"])
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	logic.ReturnJSONResponse(w, r, cert)
+}
+
+// swagger:route POST /api/v1/pki/certificates pki issuePKICertificate
+//
+// Issue a host, broker or server certificate from the internal CA. The key pair is generated
+// and returned once when no certificate request is given.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: issuedCertificateResponse
+func issuePKICertificate(w http.ResponseWriter, r *http.Request) {
+	var request models.PKICertificateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	cert, err := logic.IssueCertificate(&request)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "issued certificate", "user", r.Header.Get("user"), "kind", request.Kind, "serial", cert.Serial, "subject", request.Subject)
+	logic.ReturnJSONResponse(w, r, cert)
+}
+
+// swagger:route POST /api/v1/pki/certificates/{serial}/renew pki renewPKICertificate
+//
+// Renew a certificate of the internal CA, for a new key if a certificate request is given.
+// The old certificate stays valid until it expires.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: issuedCertificateResponse
+func renewPKICertificate(w http.ResponseWriter, r *http.Request) {
+	serial := mux.Vars(r)["serial"]
+	var request models.PKIRenewRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	if _, err := logic.GetCertificate(serial); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	cert, err := logic.RenewCertificate(serial, &request)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "renewed certificate", "user", r.Header.Get("user"), "serial", serial, "serial2", cert.Serial)
+	logic.ReturnJSONResponse(w, r, cert)
+}
+
+// swagger:route DELETE /api/v1/pki/certificates/{serial} pki revokePKICertificate
+//
+// Revoke a certificate of the internal CA, it's listed in the CRL and OCSP answers from then on.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: pkiCertificateResponse
+func revokePKICertificate(w http.ResponseWriter, r *http.Request) {
+	serial := mux.Vars(r)["serial"]
+	reason := r.URL.Query().Get("reason")
+	if reason == "" {
+		reason = "revoked by " + r.Header.Get("user")
+	}
+	if _, err := logic.GetCertificate(serial); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	cert, err := logic.RevokeCertificate(serial, reason)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "revoked certificate", "user", r.Header.Get("user"), "serial", serial, "subject", cert.Subject)
+	logic.ReturnJSONResponse(w, r, cert)
+}
+
+// swagger:route POST /api/v1/pki/ocsp pki respondOCSP
+//
+// OCSP responder (RFC 6960) for the certificates of the internal CA, takes DER requests
+// in the body or base64 encoded in the path with GET.
+//
+//	Schemes: https
+//
+//	Responses:
+//		200: byteArrayResponse
+func respondOCSP(w http.ResponseWriter, r *http.Request) {
+	var request []byte
+	var err error
+	if encoded, ok := mux.Vars(r)["request"]; ok {
+		request, err = base64.StdEncoding.DecodeString(encoded)
+	} else {
+		request, err = io.ReadAll(io.LimitReader(r.Body, maxOCSPRequestSize))
+	}
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	w.WriteHeader(http.StatusOK)
+	w.Write(logic.RespondOCSP(request))
+}
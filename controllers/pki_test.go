@@ -0,0 +1,36 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKIHandlersSuperAdminOnly(t *testing.T) {
+	assert.Nil(t, logic.CreateTenant(&models.Tenant{ID: "pkitenant"}))
+	defer logic.DeleteTenant("pkitenant")
+	assert.Nil(t, logic.CreateUser(&models.User{UserName: "pkiadmin", Password: "password", IsAdmin: true, Tenant: "pkitenant"}))
+	defer logic.DeleteUser("pkiadmin")
+	token, err := logic.CreateUserJWT("pkiadmin", nil, true)
+	assert.Nil(t, err)
+	router := mux.NewRouter()
+	pkiHandlers(router)
+	for _, route := range []struct{ method, path string }{
+		{http.MethodGet, "/api/v1/pki/certificates"},
+		{http.MethodPost, "/api/v1/pki/certificates"},
+		{http.MethodGet, "/api/v1/pki/certificates/1"},
+		{http.MethodDelete, "/api/v1/pki/certificates/1"},
+		{http.MethodPost, "/api/v1/pki/certificates/1/renew"},
+	} {
+		r := httptest.NewRequest(route.method, route.path, nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusForbidden, w.Code, "a tenant admin can't use the CA of every tenant: %s %s", route.method, route.path)
+	}
+}
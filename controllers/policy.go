@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/mq"
+	"golang.org/x/exp/slog"
+)
+
+// maxPolicyFileSize - the largest policy file that can be validated or applied
+const maxPolicyFileSize = 1 << 20
+
+func policyHandlers(r *mux.Router) {
+	r.HandleFunc("/api/networks/{networkname}/policy", logic.SecurityCheck(true, http.HandlerFunc(exportNetworkPolicy))).Methods(http.MethodGet)
+	r.HandleFunc("/api/networks/{networkname}/policy", logic.SecurityCheck(true, http.HandlerFunc(applyNetworkPolicy))).Methods(http.MethodPut)
+	r.HandleFunc("/api/networks/{networkname}/policy/validate", logic.SecurityCheck(true, http.HandlerFunc(validateNetworkPolicy))).Methods(http.MethodPost)
+}
+
+// swagger:route GET /api/networks/{networkname}/policy networks exportNetworkPolicy
+//
+// Export the ACL policy of a network as an HCL policy file: the default ACL, the zones, the pairs of nodes whose ACL
+// differs from what those give them and what each ext client is denied. Nodes are named by node or host name.
+//
+//			Produces:
+//			- text/plain
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: stringJSONResponse
+func exportNetworkPolicy(w http.ResponseWriter, r *http.Request) {
+	netID := mux.Vars(r)["networkname"]
+	if !ipamNetworkInTenant(w, r, netID) {
+		return
+	}
+	policy, err := logic.ExportNetworkPolicy(netID)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to export acl policy", "user", r.Header.Get("user"), "network", netID, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, policy)
+}
+
+// swagger:route POST /api/networks/{networkname}/policy/validate networks validateNetworkPolicy
+//
+// Validate an edited policy file, sent as the body, against a network without applying it;
+// the result lists its syntax and semantic errors by line, or the changes applying it would make.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: policyPlanResponse
+func validateNetworkPolicy(w http.ResponseWriter, r *http.Request) {
+	netID := mux.Vars(r)["networkname"]
+	if !ipamNetworkInTenant(w, r, netID) {
+		return
+	}
+	text, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPolicyFileSize))
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	plan, err := logic.ApplyNetworkPolicy(netID, string(text), true)
+	if err != nil && !errors.Is(err, logic.ErrInvalidPolicy) {
+		slog.ErrorCtx(r.Context(), "failed to validate acl policy", "user", r.Header.Get("user"), "network", netID, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnJSONResponse(w, r, plan)
+}
+
+// swagger:route PUT /api/networks/{networkname}/policy networks applyNetworkPolicy
+//
+// Apply an edited policy file, sent as the body, setting the default ACL, zones, node ACLs and ext client ACLs
+// of a network to it; a file with errors changes nothing. Set dryrun to only see what would change.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: policyPlanResponse
+func applyNetworkPolicy(w http.ResponseWriter, r *http.Request) {
+	netID := mux.Vars(r)["networkname"]
+	if !ipamNetworkInTenant(w, r, netID) {
+		return
+	}
+	text, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPolicyFileSize))
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	plan, err := logic.ApplyNetworkPolicy(netID, string(text), isDryRun(r))
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to apply acl policy", "user", r.Header.Get("user"), "network", netID, "error", err)
+		if errors.Is(err, logic.ErrInvalidPolicy) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		if errors.Is(err, logic.ErrNetworkLocked) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "conflict"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	if !plan.DryRun {
+		slog.InfoCtx(r.Context(), "applied acl policy", "user", r.Header.Get("user"), "network", netID, "changes", len(plan.Changes))
+	}
+	logic.ReturnJSONResponse(w, r, plan)
+	if !plan.DryRun && len(plan.Changes) > 0 {
+		go func() {
+			if err := mq.PublishNetworkPeerUpdate(netID); err != nil {
+				slog.Warn("failed to publish peer update after applying acl policy", "network", netID, "error", err)
+			}
+		}()
+	}
+}
@@ -0,0 +1,186 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+func probeHandlers(r *mux.Router) {
+	r.HandleFunc("/api/probes", logic.SecurityCheck(true, http.HandlerFunc(getProbes))).Methods(http.MethodGet)
+	r.HandleFunc("/api/probes", logic.SecurityCheck(true, http.HandlerFunc(createProbe))).Methods(http.MethodPost)
+	r.HandleFunc("/api/probes/{probeid}", logic.SecurityCheck(true, http.HandlerFunc(getProbe))).Methods(http.MethodGet)
+	r.HandleFunc("/api/probes/{probeid}", logic.SecurityCheck(true, http.HandlerFunc(deleteProbe))).Methods(http.MethodDelete)
+}
+
+// swagger:route GET /api/probes probes getProbes
+//
+// Lists the ping probes, optionally of one network (?network=).
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: probesResponse
+func getProbes(w http.ResponseWriter, r *http.Request) {
+	probes, err := logic.GetProbes(r.URL.Query().Get("network"))
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to fetch probes", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	if tenant := r.Header.Get("tenant"); tenant != "" {
+		tenantProbes := []models.Probe{}
+		for _, probe := range probes {
+			if network, err := logic.GetNetwork(probe.Network); err == nil && network.Tenant == tenant {
+				tenantProbes = append(tenantProbes, probe)
+			}
+		}
+		probes = tenantProbes
+	}
+	writeList(w, r, probes)
+}
+
+// swagger:route POST /api/probes probes createProbe
+//
+// Schedules a probe where a node pings another node of its network or an external target
+// every interval, optionally alerting when loss or latency exceed the probe's thresholds.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: probeResponse
+func createProbe(w http.ResponseWriter, r *http.Request) {
+	var probe models.Probe
+	if err := json.NewDecoder(r.Body).Decode(&probe); err != nil {
+		slog.ErrorCtx(r.Context(), "error decoding request body", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	if tenant := r.Header.Get("tenant"); tenant != "" {
+		network, err := logic.GetNetwork(probe.Network)
+		if err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		if network.Tenant != tenant {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(logic.ErrTenantMismatch, "forbidden"))
+			return
+		}
+	}
+	if err := logic.CreateProbe(&probe); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to create probe", "user", r.Header.Get("user"), "network", probe.Network, "error", err)
+		var validationErrs validator.ValidationErrors
+		switch {
+		case errors.As(err, &validationErrs), errors.Is(err, logic.ErrProbeTarget), errors.Is(err, logic.ErrProbeNodeNetwork), errors.Is(err, logic.ErrUnknownNotificationChannel), database.IsEmptyRecord(err):
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		default:
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		}
+		return
+	}
+	slog.InfoCtx(r.Context(), "created probe", "user", r.Header.Get("user"), "probe", probe.Name, "network", probe.Network)
+	go publishProbeSource(probe)
+	logic.ReturnJSONResponse(w, r, probe)
+}
+
+// swagger:route GET /api/probes/{probeid} probes getProbe
+//
+// Fetches a probe with its recent results, availability, average latency and alert state.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: probeStatusResponse
+func getProbe(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["probeid"]
+	status, err := logic.GetProbeStatus(id)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	if !probeInTenant(w, r, status.Probe) {
+		return
+	}
+	logic.ReturnJSONResponse(w, r, status)
+}
+
+// swagger:route DELETE /api/probes/{probeid} probes deleteProbe
+//
+// Stops a probe and removes its results.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: successResponse
+func deleteProbe(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["probeid"]
+	probe, err := logic.GetProbe(id)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	if !probeInTenant(w, r, probe) {
+		return
+	}
+	if err = logic.DeleteProbe(id); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to delete probe", "user", r.Header.Get("user"), "probe", id, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "deleted probe", "user", r.Header.Get("user"), "probe", probe.Name, "network", probe.Network)
+	go publishProbeSource(probe)
+	logic.ReturnSuccessResponse(w, r, "deleted probe "+probe.Name)
+}
+
+// probeInTenant - checks a tenant admin's request is for a probe of their tenant, writing the error response when not
+func probeInTenant(w http.ResponseWriter, r *http.Request, probe models.Probe) bool {
+	tenant := r.Header.Get("tenant")
+	if tenant == "" {
+		return true
+	}
+	if network, err := logic.GetNetwork(probe.Network); err != nil || network.Tenant != tenant {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(logic.ErrTenantMismatch, "forbidden"))
+		return false
+	}
+	return true
+}
+
+// publishProbeSource - sends the host of a probe's source node its updated probes
+func publishProbeSource(probe models.Probe) {
+	node, err := logic.GetNodeByID(probe.SourceNodeID)
+	if err != nil {
+		return
+	}
+	host, err := logic.GetHost(node.HostID.String())
+	if err != nil {
+		return
+	}
+	publishHostEndpoints(host)
+}
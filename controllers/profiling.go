@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	rpprof "runtime/pprof"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+)
+
+// processStart - when the server process started, for the uptime in runtime stats
+var processStart = time.Now().UTC()
+
+func profilingHandlers(r *mux.Router) {
+	if !servercfg.IsProfilingEnabled() {
+		return
+	}
+	r.HandleFunc("/api/debug/runtime", logic.SuperAdminCheck(http.HandlerFunc(getRuntimeStats))).Methods(http.MethodGet)
+	r.HandleFunc("/api/debug/dump/{kind}", logic.SuperAdminCheck(http.HandlerFunc(getRuntimeDump))).Methods(http.MethodGet)
+	r.HandleFunc("/api/debug/pprof/", logic.SuperAdminCheck(http.HandlerFunc(pprof.Index))).Methods(http.MethodGet)
+	r.HandleFunc("/api/debug/pprof/cmdline", logic.SuperAdminCheck(http.HandlerFunc(pprof.Cmdline))).Methods(http.MethodGet)
+	r.HandleFunc("/api/debug/pprof/profile", logic.SuperAdminCheck(http.HandlerFunc(pprof.Profile))).Methods(http.MethodGet)
+	r.HandleFunc("/api/debug/pprof/symbol", logic.SuperAdminCheck(http.HandlerFunc(pprof.Symbol))).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/api/debug/pprof/trace", logic.SuperAdminCheck(http.HandlerFunc(pprof.Trace))).Methods(http.MethodGet)
+	r.HandleFunc("/api/debug/pprof/{profile}", logic.SuperAdminCheck(http.HandlerFunc(getProfile))).Methods(http.MethodGet)
+}
+
+// swagger:route GET /api/debug/runtime debug getRuntimeStats
+//
+// Get goroutine, memory and garbage collection stats of the server process, only served when profiling is on.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: runtimeStatsResponse
+func getRuntimeStats(w http.ResponseWriter, r *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	stats := models.RuntimeStats{
+		GoVersion:    runtime.Version(),
+		StartedAt:    processStart,
+		Uptime:       time.Since(processStart).Round(time.Second).String(),
+		NumCPU:       runtime.NumCPU(),
+		GOMAXPROCS:   runtime.GOMAXPROCS(0),
+		Goroutines:   runtime.NumGoroutine(),
+		HeapAlloc:    mem.HeapAlloc,
+		HeapInuse:    mem.HeapInuse,
+		HeapObjects:  mem.HeapObjects,
+		Sys:          mem.Sys,
+		NumGC:        mem.NumGC,
+		PauseTotalNs: mem.PauseTotalNs,
+	}
+	if mem.LastGC > 0 {
+		stats.LastGC = time.Unix(0, int64(mem.LastGC)).UTC()
+	}
+	logic.ReturnJSONResponse(w, r, stats)
+}
+
+// swagger:route GET /api/debug/dump/{kind} debug getRuntimeDump
+//
+// Download a dump of every goroutine's stack, or a heap profile to open with go tool pprof.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: successResponse
+func getRuntimeDump(w http.ResponseWriter, r *http.Request) {
+	kind := mux.Vars(r)["kind"]
+	var debug int
+	var filename string
+	switch kind {
+	case "goroutine":
+		// full stacks, the same as an unrecovered panic prints
+		debug, filename = 2, "goroutines.txt"
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	case "heap":
+		filename = "heap.pprof"
+		if r.URL.Query().Get("gc") == "true" {
+			runtime.GC()
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+	default:
+		logic.ReturnErrorResponse(w, r, logic.FormatError(fmt.Errorf("unknown dump %q, use goroutine or heap", kind), "badrequest"))
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s-%s", time.Now().UTC().Format("20060102T150405"), filename))
+	w.WriteHeader(http.StatusOK)
+	rpprof.Lookup(kind).WriteTo(w, debug)
+}
+
+// getProfile - serves a named runtime profile, such as heap, allocs, block or mutex
+func getProfile(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["profile"]
+	if rpprof.Lookup(name) == nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(fmt.Errorf("unknown profile %q", name), "notfound"))
+		return
+	}
+	pprof.Handler(name).ServeHTTP(w, r)
+}
@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfilingHandlers(t *testing.T) {
+	serve := func(r *mux.Router, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+	t.Run("Off", func(t *testing.T) {
+		t.Setenv("PROFILING", "off")
+		r := mux.NewRouter()
+		profilingHandlers(r)
+		assert.Equal(t, http.StatusNotFound, serve(r, "/api/debug/runtime").Code)
+		assert.Equal(t, http.StatusNotFound, serve(r, "/api/debug/pprof/").Code)
+	})
+	t.Run("SuperAdminOnly", func(t *testing.T) {
+		t.Setenv("PROFILING", "on")
+		r := mux.NewRouter()
+		profilingHandlers(r)
+		for _, path := range []string{"/api/debug/runtime", "/api/debug/dump/goroutine", "/api/debug/pprof/", "/api/debug/pprof/heap"} {
+			assert.Equal(t, http.StatusForbidden, serve(r, path).Code, path)
+		}
+	})
+	t.Run("RuntimeStats", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		getRuntimeStats(w, httptest.NewRequest(http.MethodGet, "/api/debug/runtime", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		var stats models.RuntimeStats
+		assert.Nil(t, json.NewDecoder(w.Body).Decode(&stats))
+		assert.Greater(t, stats.Goroutines, 0)
+		assert.Greater(t, stats.HeapAlloc, uint64(0))
+		assert.Equal(t, processStart, stats.StartedAt)
+	})
+	t.Run("Dump", func(t *testing.T) {
+		r := mux.NewRouter()
+		r.HandleFunc("/api/debug/dump/{kind}", getRuntimeDump)
+		w := serve(r, "/api/debug/dump/goroutine")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Disposition"), "goroutines.txt")
+		assert.Contains(t, w.Body.String(), "TestProfilingHandlers")
+		w = serve(r, "/api/debug/dump/heap")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/octet-stream", w.Header().Get("Content-Type"))
+		assert.Equal(t, http.StatusBadRequest, serve(r, "/api/debug/dump/threadcreate").Code)
+	})
+	t.Run("Profile", func(t *testing.T) {
+		r := mux.NewRouter()
+		r.HandleFunc("/api/debug/pprof/{profile}", getProfile)
+		assert.Equal(t, http.StatusOK, serve(r, "/api/debug/pprof/allocs").Code)
+		assert.Equal(t, http.StatusNotFound, serve(r, "/api/debug/pprof/nothing").Code)
+	})
+}
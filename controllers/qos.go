@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/mq"
+	"golang.org/x/exp/slog"
+)
+
+func qosHandlers(r *mux.Router) {
+	r.HandleFunc("/api/networks/{networkname}/qos", logic.SecurityCheck(true, http.HandlerFunc(setNetworkQoS))).Methods(http.MethodPut)
+	r.HandleFunc("/api/networks/{networkname}/qos", logic.SecurityCheck(true, http.HandlerFunc(deleteNetworkQoS))).Methods(http.MethodDelete)
+}
+
+// swagger:route PUT /api/networks/{networkname}/qos networks setNetworkQoS
+//
+// Set how the wireguard traffic of a network is marked with DSCP values.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: networkBodyResponse
+func setNetworkQoS(w http.ResponseWriter, r *http.Request) {
+	var policy models.QoSPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	updateNetworkQoS(w, r, &policy)
+}
+
+// swagger:route DELETE /api/networks/{networkname}/qos networks deleteNetworkQoS
+//
+// Stop marking the wireguard traffic of a network.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: networkBodyResponse
+func deleteNetworkQoS(w http.ResponseWriter, r *http.Request) {
+	updateNetworkQoS(w, r, nil)
+}
+
+func updateNetworkQoS(w http.ResponseWriter, r *http.Request, policy *models.QoSPolicy) {
+	netID := mux.Vars(r)["networkname"]
+	network, err := logic.GetNetwork(netID)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	if tenant := r.Header.Get("tenant"); tenant != "" && network.Tenant != tenant {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(logic.ErrTenantMismatch, "forbidden"))
+		return
+	}
+	network, err = logic.SetNetworkQoS(netID, policy)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to update qos policy", "user", r.Header.Get("user"), "network", netID, "error", err)
+		if errors.Is(err, logic.ErrInvalidQoSPolicy) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "updated qos policy", "user", r.Header.Get("user"), "network", netID, "enabled", policy != nil)
+	logic.ReturnJSONResponse(w, r, network)
+	go func() {
+		if err := mq.PublishNetworkPeerUpdate(netID); err != nil {
+			slog.Warn("failed to publish peer update after qos policy change", "network", netID, "error", err)
+		}
+	}()
+}
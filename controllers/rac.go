@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/auth"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/logic/pro"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/mq"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func racHandlers(r *mux.Router) {
+	r.HandleFunc("/api/v1/rac/device", auth.HandleRACDeviceAuth).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/rac/device/{session}", auth.HandleRACDeviceToken).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/rac/{network}/client", logic.NetUserSecurityCheck(false, true, http.HandlerFunc(provisionRACClient))).Methods(http.MethodPost)
+}
+
+// swagger:route POST /api/v1/rac/{network}/client rac provisionRACClient
+//
+// Creates the ext client of the signed in user's remote access client on a network, on the requested
+// ingress gateway or the least loaded one, or renews the one it has. The config expires, renew it after renew_after.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: racClientConfigResponse
+func provisionRACClient(w http.ResponseWriter, r *http.Request) {
+	network := mux.Vars(r)["network"]
+	username := r.Header.Get("user")
+	if r.Header.Get("ismaster") == "yes" {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("remote access clients are provisioned for users"), "badrequest"))
+		return
+	}
+	var request models.RACClientRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	if request.PublicKey != "" {
+		if _, err := wgtypes.ParseKey(request.PublicKey); err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(errInvalidExtClientPubKey, "badrequest"))
+			return
+		}
+	}
+	parentNetwork, err := logic.GetNetwork(network)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	_, exists := logic.GetRemoteAccessClient(username, network)
+	client, changed, err := logic.ProvisionRemoteAccessClient(username, network, &request)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to provision remote access client", "user", username, "network", network, "error", err)
+		if errors.Is(err, logic.ErrNoRACGateway) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	if !exists {
+		isAdmin, err := checkProClientAccess(username, client.ClientID, &parentNetwork)
+		if err != nil {
+			slog.ErrorCtx(r.Context(), "pro client access check failed", "user", username, "network", network, "error", err)
+			logic.DeleteExtClient(network, client.ClientID)
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "forbidden"))
+			return
+		}
+		if !isAdmin {
+			if err = pro.AssociateNetworkUserClient(username, network, client.ClientID); err != nil {
+				slog.Warn("failed to associate remote access client with its user", "user", username, "client", client.ClientID, "error", err)
+			}
+		}
+		slog.InfoCtx(r.Context(), "created remote access client", "user", username, "network", network, "clientid", client.ClientID)
+	}
+	config, err := extClientConfig(&client)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	result := models.RACClientConfig{
+		Client:     client,
+		Config:     config,
+		Expires:    time.Unix(client.LeaseExpiry, 0).UTC(),
+		RenewAfter: time.Unix(client.LeaseExpiry, 0).Add(-servercfg.GetRACClientTTL() / 3).UTC(),
+		Gateways:   []models.ExtClientGateway{},
+	}
+	// a short lived key has to be renewed before the lease, by asking again with a new public key
+	if client.KeyExpiry != 0 && client.KeyExpiry < client.LeaseExpiry {
+		result.Expires = time.Unix(client.KeyExpiry, 0).UTC()
+		result.RenewAfter = logic.ExtClientKeyRenewAfter(&client).UTC()
+	}
+	if gateways, err := logic.GetExtClientGateways(&client); err == nil {
+		result.Gateways = gateways.Gateways
+	}
+	logic.ReturnJSONResponse(w, r, result)
+	if changed {
+		go func() {
+			if err := mq.PublishNetworkPeerUpdate(network); err != nil {
+				slog.Error("failed to publish peer update for remote access client", "client", client.ClientID, "error", err)
+			}
+			if !exists && client.Enabled {
+				if err := mq.PublishExtCLientDNS(&client); err != nil {
+					slog.Error("failed to publish dns of remote access client", "client", client.ClientID, "error", err)
+				}
+			}
+			if servercfg.IsDNSMode() {
+				logic.SetDNS()
+			}
+		}()
+	}
+}
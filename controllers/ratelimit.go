@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/servercfg"
+)
+
+const (
+	// how long a client's bucket is kept after its last request
+	rateLimitIdleTimeout = 10 * time.Minute
+	// recoveryAttemptRate, recoveryAttemptBurst - the recovery attempts a client ip may make per second and at once,
+	// whatever the api rate limit
+	recoveryAttemptRate  = 1.0 / 60
+	recoveryAttemptBurst = 5
+)
+
+// tokenBucket - the request allowance of a single client
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+var (
+	rateLimitMutex sync.Mutex
+	rateLimits     = make(map[string]*tokenBucket)
+	lastPrune      time.Time
+)
+
+// rateLimitMiddleware - limits the API requests per second of each client ip,
+// reading the limit on every request so it can be changed at runtime
+func rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := servercfg.GetRateLimit()
+		if limit.RequestsPerSecond <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// keyed on the source the client can't forge, X-Forwarded-For is only read from trusted proxies
+		ip := r.RemoteAddr
+		if source := logic.APISourceIP(r); source != nil {
+			ip = source.String()
+		}
+		if !allowRequest(ip, limit.RequestsPerSecond, float64(limit.Burst)) {
+			w.Header().Set("Retry-After", "1")
+			logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("rate limit exceeded"), "toomanyrequests"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// resetRateLimits - drops the api request buckets so a changed limit applies to every client at once,
+// the recovery attempts made are kept
+func resetRateLimits() {
+	rateLimitMutex.Lock()
+	defer rateLimitMutex.Unlock()
+	for key := range rateLimits {
+		if !strings.HasPrefix(key, "recover:") {
+			delete(rateLimits, key)
+		}
+	}
+}
+
+// allowRequest - takes a token from the client's bucket, refilled at rate tokens per second up to burst
+func allowRequest(client string, rate, burst float64) bool {
+	if burst < 1 {
+		burst = 1
+	}
+	now := time.Now()
+	rateLimitMutex.Lock()
+	defer rateLimitMutex.Unlock()
+	if now.Sub(lastPrune) > rateLimitIdleTimeout {
+		for key, bucket := range rateLimits {
+			if now.Sub(bucket.lastSeen) > rateLimitIdleTimeout {
+				delete(rateLimits, key)
+			}
+		}
+		lastPrune = now
+	}
+	bucket, ok := rateLimits[client]
+	if !ok {
+		bucket = &tokenBucket{tokens: burst, lastSeen: now}
+		rateLimits[client] = bucket
+	}
+	bucket.tokens += now.Sub(bucket.lastSeen).Seconds() * rate
+	if bucket.tokens > burst {
+		bucket.tokens = burst
+	}
+	bucket.lastSeen = now
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	servercfg.SetSettings(&models.ServerSettings{RateLimit: models.RateLimitSettings{RequestsPerSecond: 0.001, Burst: 1}})
+	defer servercfg.SetSettings(nil)
+	defer resetRateLimits()
+	handler := rateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	request := func(remote, forwarded string) int {
+		r := httptest.NewRequest(http.MethodGet, "/api/networks", nil)
+		r.RemoteAddr = remote
+		if forwarded != "" {
+			r.Header.Set("X-Forwarded-For", forwarded)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	t.Run("ForgedForwardedFor", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, request("198.51.100.7:40000", "203.0.113.1"))
+		// a new forged address each time doesn't get a new bucket
+		assert.Equal(t, http.StatusTooManyRequests, request("198.51.100.7:40001", "203.0.113.2"))
+	})
+	t.Run("TrustedProxy", func(t *testing.T) {
+		t.Setenv("TRUSTED_PROXIES", "127.0.0.1/32")
+		assert.Equal(t, http.StatusOK, request("127.0.0.1:40000", "203.0.113.1"))
+		assert.Equal(t, http.StatusOK, request("127.0.0.1:40000", "203.0.113.2"))
+		assert.Equal(t, http.StatusTooManyRequests, request("127.0.0.1:40000", "203.0.113.2"))
+	})
+	t.Run("Reset", func(t *testing.T) {
+		allowRequest("recover:198.51.100.7", recoveryAttemptRate, 1)
+		resetRateLimits()
+		assert.Equal(t, http.StatusOK, request("198.51.100.7:40000", ""))
+		assert.False(t, allowRequest("recover:198.51.100.7", recoveryAttemptRate, 1), "recovery attempts are kept")
+	})
+}
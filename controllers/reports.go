@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+func reportHandlers(r *mux.Router) {
+	r.HandleFunc("/api/reports", logic.SecurityCheck(true, http.HandlerFunc(getUsageReport))).Methods(http.MethodGet)
+}
+
+// swagger:route GET /api/reports reports getUsageReport
+//
+// Reports node, user and ext client counts and data transfer per network or tenant over billing periods.
+// Accepts period (daily or monthly), group_by (network or tenant), from and to (YYYY-MM-DD) and format (json or csv).
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: usageReportResponse
+func getUsageReport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	period := query.Get("period")
+	if period == "" {
+		period = logic.TrafficUsageMonthly
+	}
+	groupBy := query.Get("group_by")
+	if groupBy == "" {
+		groupBy = logic.ReportGroupNetwork
+	}
+	to := time.Now()
+	from := to.AddDate(-1, 0, 0)
+	var err error
+	if v := query.Get("from"); v != "" {
+		if from, err = time.Parse(logger.TimeFormatDay, v); err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if to, err = time.Parse(logger.TimeFormatDay, v); err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+	}
+	format := query.Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("invalid format, must be json or csv"), "badrequest"))
+		return
+	}
+
+	report, err := logic.GetUsageReport(period, groupBy, from, to, r.Header.Get("tenant"))
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to build usage report", "user", r.Header.Get("user"), "error", err)
+		if errors.Is(err, logic.ErrInvalidUsagePeriod) || errors.Is(err, logic.ErrInvalidReportGroup) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.DebugCtx(r.Context(), "fetched usage report", "user", r.Header.Get("user"))
+	if format == "csv" {
+		writeUsageReportCSV(w, &report)
+		return
+	}
+	logic.ReturnJSONResponse(w, r, report)
+}
+
+// writeUsageReportCSV - writes the report rows as a csv attachment
+func writeUsageReportCSV(w http.ResponseWriter, report *models.UsageReport) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"usage-"+report.From+"-"+report.To+".csv\"")
+	w.WriteHeader(http.StatusOK)
+	out := csv.NewWriter(w)
+	out.Write([]string{"period", "tenant", "network", "nodes", "users", "ext_clients", "sent", "received"})
+	for _, row := range report.Rows {
+		out.Write([]string{
+			row.Period,
+			row.Tenant,
+			row.Network,
+			strconv.Itoa(row.Nodes),
+			strconv.Itoa(row.Users),
+			strconv.Itoa(row.ExtClients),
+			strconv.FormatInt(row.Sent, 10),
+			strconv.FormatInt(row.Received, 10),
+		})
+	}
+	out.Flush()
+}
@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/mq"
+	"golang.org/x/exp/slog"
+)
+
+func rolloutHandlers(r *mux.Router) {
+	r.HandleFunc("/api/networks/{networkname}/rollouts", logic.SecurityCheck(true, http.HandlerFunc(getRollouts))).Methods(http.MethodGet)
+	r.HandleFunc("/api/networks/{networkname}/rollouts", logic.SecurityCheck(true, http.HandlerFunc(startRollout))).Methods(http.MethodPost)
+	r.HandleFunc("/api/networks/{networkname}/rollouts/{id}", logic.SecurityCheck(true, http.HandlerFunc(getRollout))).Methods(http.MethodGet)
+	r.HandleFunc("/api/networks/{networkname}/rollouts/{id}/promote", logic.SecurityCheck(true, http.HandlerFunc(promoteRollout))).Methods(http.MethodPost)
+	r.HandleFunc("/api/networks/{networkname}/rollouts/{id}/rollback", logic.SecurityCheck(true, http.HandlerFunc(rollbackRollout))).Methods(http.MethodPost)
+}
+
+// swagger:route GET /api/networks/{networkname}/rollouts networks getRollouts
+//
+// Get the rollouts of a network, the latest first.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: rolloutsResponse
+func getRollouts(w http.ResponseWriter, r *http.Request) {
+	netID := mux.Vars(r)["networkname"]
+	if !ipamNetworkInTenant(w, r, netID) {
+		return
+	}
+	rollouts, err := logic.GetRollouts(netID)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to get rollouts", "user", r.Header.Get("user"), "network", netID, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnJSONResponse(w, r, rollouts)
+}
+
+// swagger:route POST /api/networks/{networkname}/rollouts networks startRollout
+//
+// Apply a policy file or join config to canary hosts of a network first; once the observation ends it is applied
+// to the network if the connectivity of the canaries held up, rolled back otherwise.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: rolloutResponse
+func startRollout(w http.ResponseWriter, r *http.Request) {
+	netID := mux.Vars(r)["networkname"]
+	if !ipamNetworkInTenant(w, r, netID) {
+		return
+	}
+	var request models.RolloutRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	rollout, err := logic.StartRollout(netID, r.Header.Get("user"), &request)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to start rollout", "user", r.Header.Get("user"), "network", netID, "error", err)
+		switch {
+		case errors.Is(err, logic.ErrInvalidRollout), errors.Is(err, logic.ErrInvalidPolicy):
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		case errors.Is(err, logic.ErrRolloutActive), errors.Is(err, logic.ErrNetworkLocked):
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "conflict"))
+		default:
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		}
+		return
+	}
+	slog.InfoCtx(r.Context(), "started rollout", "user", r.Header.Get("user"), "network", netID, "rollout", rollout.ID, "kind", rollout.Kind)
+	logic.ReturnJSONResponse(w, r, rollout)
+	go mq.PublishRollout(&rollout)
+}
+
+// swagger:route GET /api/networks/{networkname}/rollouts/{id} networks getRollout
+//
+// Get a rollout of a network.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: rolloutResponse
+func getRollout(w http.ResponseWriter, r *http.Request) {
+	rollout, ok := networkRollout(w, r)
+	if !ok {
+		return
+	}
+	logic.ReturnJSONResponse(w, r, rollout)
+}
+
+// swagger:route POST /api/networks/{networkname}/rollouts/{id}/promote networks promoteRollout
+//
+// Apply the change of a rollout to the whole network without waiting for its observation to end.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: rolloutResponse
+func promoteRollout(w http.ResponseWriter, r *http.Request) {
+	finishRollout(w, r, func(id, user string) (models.Rollout, error) {
+		return logic.PromoteRollout(id, user)
+	})
+}
+
+// swagger:route POST /api/networks/{networkname}/rollouts/{id}/rollback networks rollbackRollout
+//
+// Take the change of a rollout back from its canary hosts.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: rolloutResponse
+func rollbackRollout(w http.ResponseWriter, r *http.Request) {
+	finishRollout(w, r, func(id, user string) (models.Rollout, error) {
+		return logic.RollbackRollout(id, user, "rolled back by "+user)
+	})
+}
+
+func finishRollout(w http.ResponseWriter, r *http.Request, finish func(id, user string) (models.Rollout, error)) {
+	rollout, ok := networkRollout(w, r)
+	if !ok {
+		return
+	}
+	rollout, err := finish(rollout.ID, r.Header.Get("user"))
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to finish rollout", "user", r.Header.Get("user"), "network", rollout.Network, "rollout", rollout.ID, "error", err)
+		switch {
+		case errors.Is(err, logic.ErrInvalidPolicy):
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		case errors.Is(err, logic.ErrRolloutFinished), errors.Is(err, logic.ErrNetworkLocked):
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "conflict"))
+		default:
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		}
+		return
+	}
+	slog.InfoCtx(r.Context(), "finished rollout", "user", r.Header.Get("user"), "network", rollout.Network, "rollout", rollout.ID, "status", rollout.Status)
+	logic.ReturnJSONResponse(w, r, rollout)
+	go mq.PublishRollout(&rollout)
+}
+
+// networkRollout - the rollout of the request, if it's one of the request's network
+func networkRollout(w http.ResponseWriter, r *http.Request) (models.Rollout, bool) {
+	netID := mux.Vars(r)["networkname"]
+	if !ipamNetworkInTenant(w, r, netID) {
+		return models.Rollout{}, false
+	}
+	rollout, err := logic.GetRollout(mux.Vars(r)["id"])
+	if err == nil && rollout.Network != netID {
+		err = logic.ErrRolloutNotFound
+	}
+	if err != nil {
+		if errors.Is(err, logic.ErrRolloutNotFound) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+			return rollout, false
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return rollout, false
+	}
+	return rollout, true
+}
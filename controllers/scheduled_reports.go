@@ -0,0 +1,174 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+func scheduledReportHandlers(r *mux.Router) {
+	r.HandleFunc("/api/reports/scheduled", logic.SecurityCheck(true, http.HandlerFunc(getScheduledReports))).Methods(http.MethodGet)
+	r.HandleFunc("/api/reports/scheduled", logic.SecurityCheck(true, http.HandlerFunc(createScheduledReport))).Methods(http.MethodPost)
+	r.HandleFunc("/api/reports/scheduled/{reportid}", logic.SecurityCheck(true, http.HandlerFunc(deleteScheduledReport))).Methods(http.MethodDelete)
+	r.HandleFunc("/api/reports/scheduled/{reportid}/preview", logic.SecurityCheck(true, http.HandlerFunc(previewScheduledReport))).Methods(http.MethodGet)
+	r.HandleFunc("/api/reports/scheduled/{reportid}/send", logic.SecurityCheck(true, http.HandlerFunc(sendScheduledReport))).Methods(http.MethodPost)
+}
+
+// swagger:route GET /api/reports/scheduled reports getScheduledReports
+//
+// Lists the reports sent on a schedule, with when each was last sent.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: scheduledReportsResponse
+func getScheduledReports(w http.ResponseWriter, r *http.Request) {
+	reports, err := logic.GetScheduledReports(r.Header.Get("tenant"))
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to fetch scheduled reports", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	writeList(w, r, reports)
+}
+
+// swagger:route POST /api/reports/scheduled reports createScheduledReport
+//
+// Schedules a network health, user access or expiring keys and certificates report, sent daily, weekly
+// or monthly to email addresses through the smtp settings and posted to webhooks.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: scheduledReportResponse
+func createScheduledReport(w http.ResponseWriter, r *http.Request) {
+	var report models.ScheduledReport
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	report.Tenant = r.Header.Get("tenant")
+	report.CreatedBy = r.Header.Get("user")
+	if err := logic.CreateScheduledReport(&report); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to create scheduled report", "user", r.Header.Get("user"), "error", err)
+		var validationErrs validator.ValidationErrors
+		switch {
+		case errors.Is(err, logic.ErrTenantMismatch):
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "forbidden"))
+		case errors.As(err, &validationErrs), errors.Is(err, logic.ErrReportRecipients), errors.Is(err, logic.ErrUnknownNotificationChannel), database.IsEmptyRecord(err):
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		default:
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		}
+		return
+	}
+	slog.InfoCtx(r.Context(), "scheduled report", "user", r.Header.Get("user"), "report", report.Name, "kind", report.Kind, "frequency", report.Frequency)
+	logic.ReturnJSONResponse(w, r, report)
+}
+
+// swagger:route DELETE /api/reports/scheduled/{reportid} reports deleteScheduledReport
+//
+// Stops sending a scheduled report.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: successResponse
+func deleteScheduledReport(w http.ResponseWriter, r *http.Request) {
+	report, ok := fetchScheduledReport(w, r)
+	if !ok {
+		return
+	}
+	if err := logic.DeleteScheduledReport(report.ID); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to delete scheduled report", "user", r.Header.Get("user"), "report", report.ID, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "deleted scheduled report", "user", r.Header.Get("user"), "report", report.Name)
+	logic.ReturnSuccessResponse(w, r, "deleted scheduled report "+report.Name)
+}
+
+// swagger:route GET /api/reports/scheduled/{reportid}/preview reports previewScheduledReport
+//
+// Renders a scheduled report as it would be sent now, without sending it.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: renderedReportResponse
+func previewScheduledReport(w http.ResponseWriter, r *http.Request) {
+	report, ok := fetchScheduledReport(w, r)
+	if !ok {
+		return
+	}
+	rendered, err := logic.RenderScheduledReport(&report)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to render scheduled report", "user", r.Header.Get("user"), "report", report.ID, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnJSONResponse(w, r, rendered)
+}
+
+// swagger:route POST /api/reports/scheduled/{reportid}/send reports sendScheduledReport
+//
+// Sends a scheduled report now, the next one is sent a full period later.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: scheduledReportResponse
+func sendScheduledReport(w http.ResponseWriter, r *http.Request) {
+	report, ok := fetchScheduledReport(w, r)
+	if !ok {
+		return
+	}
+	if err := logic.SendScheduledReport(&report); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to send scheduled report", "user", r.Header.Get("user"), "report", report.ID, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "sent scheduled report", "user", r.Header.Get("user"), "report", report.Name)
+	logic.ReturnJSONResponse(w, r, report)
+}
+
+// fetchScheduledReport - the report of a request, checking a tenant admin's request is for a report of their tenant;
+// writes the error response when it can't be used
+func fetchScheduledReport(w http.ResponseWriter, r *http.Request) (models.ScheduledReport, bool) {
+	report, err := logic.GetScheduledReport(mux.Vars(r)["reportid"])
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+			return report, false
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return report, false
+	}
+	if tenant := r.Header.Get("tenant"); tenant != "" && report.Tenant != tenant {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(logic.ErrTenantMismatch, "forbidden"))
+		return report, false
+	}
+	return report, true
+}
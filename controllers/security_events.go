@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logic"
+)
+
+func securityEventHandlers(r *mux.Router) {
+	r.HandleFunc("/api/security/events", logic.SecurityCheck(true, http.HandlerFunc(getSecurityEvents))).Methods(http.MethodGet)
+}
+
+// swagger:route GET /api/security/events security getSecurityEvents
+//
+// Get the recent security alerts, newest first, such as revoked ext client or host keys
+// attempting handshakes with a gateway. Covers the last 7 days unless since (RFC 3339) is given.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: securityEventsResponse
+func getSecurityEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	since := time.Now().AddDate(0, 0, -7)
+	if v := query.Get("since"); v != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, v); err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+	}
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	events, err := logic.GetSecurityEvents(since, limit, r.Header.Get("tenant"))
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	writeList(w, r, events)
+}
@@ -2,17 +2,35 @@ package controller
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
 
+	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/auth"
 	"github.com/gravitl/netmaker/database"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/mq"
 	"github.com/gravitl/netmaker/servercfg"
+	"github.com/gravitl/netmaker/serverctl"
+	"golang.org/x/exp/slog"
 )
 
+func init() {
+	// settings stored on any server are applied on this one through the hook
+	logic.OnServerSettingsChange(func(previous models.ServerSettings) {
+		settings := servercfg.GetSettings()
+		if settings.OAuth != previous.OAuth {
+			auth.InitializeAuthProvider()
+		}
+		if settings.RateLimit != previous.RateLimit {
+			resetRateLimits()
+		}
+	})
+}
+
 func serverHandlers(r *mux.Router) {
 	// r.HandleFunc("/api/server/addnetwork/{network}", securityCheckServer(true, http.HandlerFunc(addNetwork))).Methods(http.MethodPost)
 	r.HandleFunc("/api/server/health", http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
@@ -23,6 +41,302 @@ func serverHandlers(r *mux.Router) {
 	r.HandleFunc("/api/server/getserverinfo", Authorize(true, false, "node", http.HandlerFunc(getServerInfo))).Methods(http.MethodGet)
 	r.HandleFunc("/api/server/status", http.HandlerFunc(getStatus)).Methods(http.MethodGet)
 	r.HandleFunc("/api/server/usage", Authorize(true, false, "user", http.HandlerFunc(getUsage))).Methods(http.MethodGet)
+	r.HandleFunc("/api/server/cache", logic.SuperAdminCheck(http.HandlerFunc(getCacheStats))).Methods(http.MethodGet)
+	r.HandleFunc("/api/server/extensions", logic.SuperAdminCheck(http.HandlerFunc(getExtensions))).Methods(http.MethodGet)
+	r.HandleFunc("/api/server/jobs", logic.SuperAdminCheck(http.HandlerFunc(getJobs))).Methods(http.MethodGet)
+	r.HandleFunc("/api/server/jobs/{job}", logic.SuperAdminCheck(http.HandlerFunc(getJob))).Methods(http.MethodGet)
+	r.HandleFunc("/api/server/jobs/{job}/run", logic.SuperAdminCheck(http.HandlerFunc(runJob))).Methods(http.MethodPost)
+	r.HandleFunc("/api/server/jobs/{job}/pause", logic.SuperAdminCheck(http.HandlerFunc(pauseJob))).Methods(http.MethodPost)
+	r.HandleFunc("/api/server/jobs/{job}/resume", logic.SuperAdminCheck(http.HandlerFunc(resumeJob))).Methods(http.MethodPost)
+	r.HandleFunc("/api/server/config/validate", logic.SuperAdminCheck(http.HandlerFunc(validateServerConfig))).Methods(http.MethodGet)
+	r.HandleFunc("/api/server/publishqueue", logic.SuperAdminCheck(http.HandlerFunc(getPublishQueueStatus))).Methods(http.MethodGet)
+	r.HandleFunc("/api/server/certificate", logic.SuperAdminCheck(http.HandlerFunc(getCertificateStatus))).Methods(http.MethodGet)
+	r.HandleFunc("/api/server/settings", logic.SuperAdminCheck(http.HandlerFunc(getServerSettings))).Methods(http.MethodGet)
+	r.HandleFunc("/api/server/settings", logic.SuperAdminCheck(http.HandlerFunc(updateServerSettings))).Methods(http.MethodPut)
+	r.HandleFunc("/api/server/consistency", logic.SuperAdminCheck(http.HandlerFunc(getConsistencyReport))).Methods(http.MethodGet)
+	r.HandleFunc("/api/server/consistency", logic.SuperAdminCheck(http.HandlerFunc(cleanOrphanedRecords))).Methods(http.MethodPost)
+}
+
+// swagger:route GET /api/server/consistency server getConsistencyReport
+//
+// Find orphaned records: nodes of deleted hosts or networks, hosts listing deleted nodes,
+// ext clients of deleted gateways and network users assigned deleted gateways or clients. Nothing is changed.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: consistencyReportResponse
+func getConsistencyReport(w http.ResponseWriter, r *http.Request) {
+	report, err := logic.CheckConsistency(true)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to check database consistency", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnJSONResponse(w, r, report)
+}
+
+// swagger:route POST /api/server/consistency server cleanOrphanedRecords
+//
+// Delete the orphaned records and remove stale references to deleted ones.
+// Set dryrun to only report what would be cleaned.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: consistencyReportResponse
+func cleanOrphanedRecords(w http.ResponseWriter, r *http.Request) {
+	report, err := logic.CheckConsistency(isDryRun(r))
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to clean orphaned records", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	if report.Cleaned > 0 {
+		slog.InfoCtx(r.Context(), "cleaned orphaned records", "user", r.Header.Get("user"), "cleaned", report.Cleaned, "failed", report.Failed)
+		go func() {
+			if err := mq.PublishPeerUpdate(); err != nil {
+				slog.Error("failed to publish peer update after cleaning orphaned records", "error", err)
+			}
+		}()
+	}
+	logic.ReturnJSONResponse(w, r, report)
+}
+
+// swagger:route GET /api/server/settings server getServerSettings
+//
+// Get the server settings that can be changed at runtime, secrets are not returned.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: serverSettingsResponse
+func getServerSettings(w http.ResponseWriter, r *http.Request) {
+	logic.ReturnJSONResponse(w, r, logic.GetServerSettings())
+}
+
+// swagger:route PUT /api/server/settings server updateServerSettings
+//
+// Update the server settings, changes are applied without a restart.
+// Secrets left empty keep their current value.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: serverSettingsResponse
+func updateServerSettings(w http.ResponseWriter, r *http.Request) {
+	var settings models.ServerSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	if !logic.IsAPISourceAllowed(settings.APIAllowlist, r) {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(logic.ErrAllowlistLockout, "badrequest"))
+		return
+	}
+	if origin := r.Header.Get("Origin"); origin != "" && len(settings.CORS.AllowedOrigins) > 0 && !logic.IsOriginAllowed(settings.CORS.AllowedOrigins, origin) {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("the allowed origins do not include the origin of this request"), "badrequest"))
+		return
+	}
+	if err := logic.UpdateServerSettings(&settings); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to update server settings", "user", r.Header.Get("user"), "error", err)
+		if _, ok := err.(validator.ValidationErrors); ok || errors.Is(err, logic.ErrSIEMCACert) || errors.Is(err, logic.ErrInvalidCORSSettings) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "updated server settings", "user", r.Header.Get("user"))
+	logic.ReturnJSONResponse(w, r, logic.GetServerSettings())
+}
+
+// swagger:route GET /api/server/certificate server getCertificateStatus
+//
+// Get the state of the server's ACME certificate.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: certificateStatusResponse
+func getCertificateStatus(w http.ResponseWriter, r *http.Request) {
+	logic.ReturnJSONResponse(w, r, serverctl.GetCertificateStatus())
+}
+
+// swagger:route GET /api/server/cache server getCacheStats
+//
+// Get the size and hit rate of the server's in memory caches.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: cacheStatsResponse
+func getCacheStats(w http.ResponseWriter, r *http.Request) {
+	logic.ReturnJSONResponse(w, r, logic.GetCacheStats())
+}
+
+// swagger:route GET /api/server/extensions server getExtensions
+//
+// Get the extensions registered with the server and the hooks each implements.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: extensionsResponse
+func getExtensions(w http.ResponseWriter, r *http.Request) {
+	logic.ReturnJSONResponse(w, r, logic.GetExtensions())
+}
+
+// swagger:route GET /api/server/jobs server getJobs
+//
+// Get the background jobs of the server, how often each runs and how its last run went.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: jobsResponse
+func getJobs(w http.ResponseWriter, r *http.Request) {
+	logic.ReturnJSONResponse(w, r, logic.GetJobs())
+}
+
+// swagger:route GET /api/server/jobs/{job} server getJob
+//
+// Get a background job with its latest failures.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: jobResponse
+func getJob(w http.ResponseWriter, r *http.Request) {
+	status, err := logic.GetJob(mux.Vars(r)["job"])
+	writeJob(w, r, status, err)
+}
+
+// swagger:route POST /api/server/jobs/{job}/run server runJob
+//
+// Run a background job now, even when it's paused.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: jobResponse
+func runJob(w http.ResponseWriter, r *http.Request) {
+	status, err := logic.TriggerJob(mux.Vars(r)["job"])
+	writeJob(w, r, status, err)
+}
+
+// swagger:route POST /api/server/jobs/{job}/pause server pauseJob
+//
+// Stop a background job running on its interval until it's resumed.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: jobResponse
+func pauseJob(w http.ResponseWriter, r *http.Request) {
+	status, err := logic.PauseJob(mux.Vars(r)["job"], true)
+	writeJob(w, r, status, err)
+}
+
+// swagger:route POST /api/server/jobs/{job}/resume server resumeJob
+//
+// Let a paused background job run on its interval again.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: jobResponse
+func resumeJob(w http.ResponseWriter, r *http.Request) {
+	status, err := logic.PauseJob(mux.Vars(r)["job"], false)
+	writeJob(w, r, status, err)
+}
+
+// writeJob - answers with the status of a job after an action on it
+func writeJob(w http.ResponseWriter, r *http.Request, status models.JobStatus, err error) {
+	if err != nil {
+		if errors.Is(err, logic.ErrJobNotFound) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	if r.Method != http.MethodGet {
+		slog.InfoCtx(r.Context(), "job action", "user", r.Header.Get("user"), "job", status.Name, "path", r.URL.Path)
+	}
+	logic.ReturnJSONResponse(w, r, status)
+}
+
+// swagger:route GET /api/server/config/validate server validateServerConfig
+//
+// Check the server's settings, database and broker connections, oauth settings and certificate files,
+// reporting every problem at once. The same checks run before startup with the --validate flag.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: configValidationResponse
+func validateServerConfig(w http.ResponseWriter, r *http.Request) {
+	logic.ReturnJSONResponse(w, r, serverctl.ValidateConfig(true))
+}
+
+// swagger:route GET /api/server/publishqueue server getPublishQueueStatus
+//
+// Get how many peer updates are waiting to reach the broker and the hosts whose updates keep failing.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: publishQueueStatusResponse
+func getPublishQueueStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := mq.GetPublishQueueStatus()
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnJSONResponse(w, r, status)
 }
 
 // TODO move to EE package? there is a function and a type there for that already
@@ -121,6 +435,10 @@ func allowUsers(next http.Handler) http.HandlerFunc {
 			logic.ReturnErrorResponse(w, r, errorResponse)
 			return
 		}
+		if err := logic.CheckAPIAllowlist(r, user); err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "forbidden"))
+			return
+		}
 		next.ServeHTTP(w, r)
 	}
 }
@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/mq"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
+)
+
+func sidecarHandlers(r *mux.Router) {
+	r.HandleFunc("/api/v1/sidecar/register/{token}", checkFreeTierLimits(limitChoiceMachines, http.HandlerFunc(registerSidecar))).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/sidecar/register/{token}/{clientid}", http.HandlerFunc(renewSidecarLease)).Methods(http.MethodPut)
+}
+
+// swagger:route POST /api/v1/sidecar/register/{token} sidecar registerSidecar
+//
+// Registers a sidecar container with an enrollment key, no netclient needed.
+// Returns the rendered wireguard config, or just the config file when format is conf.
+// The sidecar is removed when its lease runs out unless renewed.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: sidecarRegistrationResponse
+func registerSidecar(w http.ResponseWriter, r *http.Request) {
+	enrollmentKey, err := logic.DeTokenize(mux.Vars(r)["token"])
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "invalid enrollment key used by sidecar", "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	var request models.SidecarRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			slog.ErrorCtx(r.Context(), "error decoding sidecar request body", "error", err)
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+	}
+	if err := validateCustomExtClient(&models.CustomExtClient{ClientID: request.Name, PublicKey: request.PublicKey}, true); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	if !logic.TryToUseEnrollmentKey(enrollmentKey) {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("invalid enrollment key"), "badrequest"))
+		return
+	}
+	client, err := logic.RegisterSidecar(enrollmentKey, &request)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to register sidecar", "request_name", request.Name, "error", err)
+		if errors.Is(err, logic.ErrSidecarNetwork) || errors.Is(err, logic.ErrNoSidecarGateway) || errors.Is(err, logic.ErrInvalidSidecarLease) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "registered sidecar on network", "client_id", client.ClientID, "network", client.Network)
+	writeSidecarRegistration(w, r, &client)
+	go func() {
+		if err := mq.PublishNetworkPeerUpdate(client.Network); err != nil {
+			slog.ErrorCtx(r.Context(), "error setting peers of sidecar", "client_id", client.ClientID, "error", err)
+		}
+		if err := mq.PublishExtCLientDNS(&client); err != nil {
+			slog.ErrorCtx(r.Context(), "error publishing sidecar dns", "error", err)
+		}
+		if servercfg.IsDNSMode() {
+			logic.SetDNS()
+		}
+	}()
+}
+
+// swagger:route PUT /api/v1/sidecar/register/{token}/{clientid} sidecar renewSidecarLease
+//
+// Renews the lease of a sidecar registered with the same enrollment key.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: sidecarRegistrationResponse
+func renewSidecarLease(w http.ResponseWriter, r *http.Request) {
+	var params = mux.Vars(r)
+	enrollmentKey, err := logic.DeTokenize(params["token"])
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "invalid enrollment key used by sidecar", "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	// the uses of a key are spent registering, only its expiry ends renewals
+	if !enrollmentKey.Expiration.IsZero() && time.Now().After(enrollmentKey.Expiration) {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("enrollment key has expired"), "badrequest"))
+		return
+	}
+	var request models.SidecarRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			slog.ErrorCtx(r.Context(), "error decoding sidecar request body", "error", err)
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+	}
+	client, err := logic.RenewSidecarLease(enrollmentKey, params["clientid"], &request)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to renew lease of sidecar", "clientid", params["clientid"], "error", err)
+		switch {
+		case errors.Is(err, logic.ErrNotSidecar), errors.Is(err, logic.ErrSidecarNetwork):
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "forbidden"))
+		case errors.Is(err, logic.ErrInvalidSidecarLease):
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		default:
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		}
+		return
+	}
+	slog.DebugCtx(r.Context(), "renewed lease of sidecar", "client_id", client.ClientID)
+	writeSidecarRegistration(w, r, &client)
+}
+
+// writeSidecarRegistration - writes a sidecar's registration, or only its config file when format is conf
+func writeSidecarRegistration(w http.ResponseWriter, r *http.Request, client *models.ExtClient) {
+	config, err := extClientConfig(client)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to render config of sidecar", "client_id", client.ClientID, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	if r.URL.Query().Get("format") == "conf" {
+		w.Header().Set("Content-Type", "application/config")
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+client.ClientID+".conf\"")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, config)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.SidecarRegistration{
+		ClientID:    client.ClientID,
+		Network:     client.Network,
+		Address:     client.Address,
+		Address6:    client.Address6,
+		LeaseExpiry: time.Unix(client.LeaseExpiry, 0).UTC(),
+		Config:      config,
+	})
+}
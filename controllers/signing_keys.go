@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/mq"
+	"golang.org/x/exp/slog"
+)
+
+func signingKeyHandlers(r *mux.Router) {
+	r.HandleFunc("/api/v1/host/signingkeys", Authorize(true, false, "host", http.HandlerFunc(getSigningKeys))).Methods(http.MethodGet)
+	r.HandleFunc("/api/server/signingkeys", logic.SuperAdminCheck(http.HandlerFunc(getSigningKeys))).Methods(http.MethodGet)
+	r.HandleFunc("/api/server/signingkeys/rotate", logic.SuperAdminCheck(http.HandlerFunc(rotateSigningKey))).Methods(http.MethodPost)
+}
+
+// swagger:route GET /api/v1/host/signingkeys pull getHostSigningKeys
+//
+// Used by clients to get the keys the messages of the server are signed with, when one is signed with a key they don't know.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: signingKeysResponse
+
+// swagger:route GET /api/server/signingkeys server getSigningKeys
+//
+// Get the keys the server signs the messages it publishes to hosts with, the active key first.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: signingKeysResponse
+func getSigningKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := logic.GetSigningKeys()
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to get signing keys", "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnJSONResponse(w, r, keys)
+}
+
+// swagger:route POST /api/server/signingkeys/rotate server rotateSigningKey
+//
+// Create a new signing key, the active one still verifies for a week; hosts verifying signatures are asked to pull the new key.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: signingKeyResponse
+func rotateSigningKey(w http.ResponseWriter, r *http.Request) {
+	key, err := logic.RotateSigningKey()
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to rotate signing key", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "rotated signing key", "user", r.Header.Get("user"), "key", key.ID)
+	logic.ReturnJSONResponse(w, r, key)
+	go func() {
+		hosts, err := logic.GetAllHosts()
+		if err != nil {
+			return
+		}
+		for i := range hosts {
+			if !hosts[i].SignedUpdates {
+				continue
+			}
+			// signed with the new key, which the host doesn't know yet so it pulls
+			if err := mq.HostUpdate(&models.HostUpdate{Action: models.RequestPull, Host: hosts[i]}); err != nil {
+				slog.Warn("failed to request pull for signing key", "host", hosts[i].ID, "error", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/mq"
+	"golang.org/x/exp/slog"
+)
+
+func sourcePolicyHandlers(r *mux.Router) {
+	r.HandleFunc("/api/users/{username}/sourcepolicy", logic.SecurityCheck(true, http.HandlerFunc(getSourcePolicy))).Methods(http.MethodGet)
+	r.HandleFunc("/api/users/{username}/sourcepolicy", logic.SecurityCheck(true, http.HandlerFunc(setSourcePolicy))).Methods(http.MethodPut)
+	r.HandleFunc("/api/users/{username}/sourcepolicy", logic.SecurityCheck(true, http.HandlerFunc(deleteSourcePolicy))).Methods(http.MethodDelete)
+}
+
+// swagger:route GET /api/users/{username}/sourcepolicy user getSourcePolicy
+//
+// Get the source cidrs and countries a user's ext clients may connect from.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: sourcePolicyResponse
+func getSourcePolicy(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	policy, err := logic.GetSourcePolicy(username)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("user has no source policy"), "notfound"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnJSONResponse(w, r, policy)
+}
+
+// swagger:route PUT /api/users/{username}/sourcepolicy user setSourcePolicy
+//
+// Restrict the source cidrs and countries a user's ext clients may connect from.
+// Gateways report the sources they see, connections from elsewhere are recorded as network events
+// and with the block action the client is removed from its gateways until the policy is changed.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: sourcePolicyResponse
+func setSourcePolicy(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	if _, err := logic.GetUser(username); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	var policy models.SourcePolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	policy.User = username
+	networks, err := logic.SetSourcePolicy(&policy)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to set source policy of user", "user", r.Header.Get("user"), "username", username, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "set source policy of user", "user", r.Header.Get("user"), "username", username)
+	go publishSourcePolicyUnblocks(networks)
+	logic.ReturnJSONResponse(w, r, policy)
+}
+
+// swagger:route DELETE /api/users/{username}/sourcepolicy user deleteSourcePolicy
+//
+// Let a user's ext clients connect from anywhere again.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: successResponse
+func deleteSourcePolicy(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	networks, err := logic.DeleteSourcePolicy(username)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "deleted source policy of user", "user", r.Header.Get("user"), "username", username)
+	go publishSourcePolicyUnblocks(networks)
+	logic.ReturnSuccessResponse(w, r, "deleted source policy of "+username)
+}
+
+// publishSourcePolicyUnblocks - peers the ext clients let back in after a source policy changed with their gateways
+func publishSourcePolicyUnblocks(networks []string) {
+	for _, network := range networks {
+		if err := mq.PublishNetworkPeerUpdate(network); err != nil {
+			slog.Error("error publishing peer update after source policy change on network", "network", network, "error", err)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/mq"
+	"golang.org/x/exp/slog"
+)
+
+func staticPeerHandlers(r *mux.Router) {
+	r.HandleFunc("/api/networks/{networkname}/staticpeers", logic.SecurityCheck(false, http.HandlerFunc(getStaticPeers))).Methods(http.MethodGet)
+	r.HandleFunc("/api/networks/{networkname}/staticpeers", logic.SecurityCheck(true, checkFreeTierLimits(limitChoiceMachines, http.HandlerFunc(createStaticPeer)))).Methods(http.MethodPost)
+	r.HandleFunc("/api/networks/{networkname}/staticpeers/{peerid}", logic.SecurityCheck(false, http.HandlerFunc(getStaticPeer))).Methods(http.MethodGet)
+	r.HandleFunc("/api/networks/{networkname}/staticpeers/{peerid}/config", logic.SecurityCheck(true, http.HandlerFunc(getStaticPeerConfig))).Methods(http.MethodGet)
+	r.HandleFunc("/api/networks/{networkname}/staticpeers/{peerid}", logic.SecurityCheck(true, http.HandlerFunc(deleteStaticPeer))).Methods(http.MethodDelete)
+	r.HandleFunc("/api/networks/{networkname}/import", logic.SecurityCheck(true, checkFreeTierLimits(limitChoiceMachines, http.HandlerFunc(importWireGuardConfigs)))).Methods(http.MethodPost)
+}
+
+// maxWireGuardImportSize - the most config data an import may upload
+const maxWireGuardImportSize = 1 << 20
+
+// swagger:route GET /api/networks/{networkname}/staticpeers networks getStaticPeers
+//
+// Lists the wireguard devices peered with a network without netclient.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: staticPeersResponse
+func getStaticPeers(w http.ResponseWriter, r *http.Request) {
+	peers, err := logic.GetNetworkStaticPeers(mux.Vars(r)["networkname"])
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to fetch static peers", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	writeList(w, r, peers)
+}
+
+// swagger:route POST /api/networks/{networkname}/staticpeers networks createStaticPeer
+//
+// Registers a wireguard device (router, appliance) by public key and endpoint.
+// It is given addresses on the network and peered with every node the network ACL allows.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: staticPeerResponse
+func createStaticPeer(w http.ResponseWriter, r *http.Request) {
+	var peer models.StaticPeer
+	if err := json.NewDecoder(r.Body).Decode(&peer); err != nil {
+		slog.ErrorCtx(r.Context(), "error decoding request body", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	peer.Network = mux.Vars(r)["networkname"]
+	if err := logic.CreateStaticPeer(&peer); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to create static peer", "user", r.Header.Get("user"), "peer_name", peer.Name, "error", err)
+		var validationErrs validator.ValidationErrors
+		if errors.As(err, &validationErrs) || errors.Is(err, logic.ErrInvalidStaticPeerKey) || errors.Is(err, logic.ErrDuplicateStaticPeerKey) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "created static peer on network", "user", r.Header.Get("user"), "peer_name", peer.Name, "network", peer.Network)
+	logic.ReturnJSONResponse(w, r, peer)
+	go func() {
+		if err := mq.PublishNetworkPeerUpdate(peer.Network); err != nil {
+			slog.ErrorCtx(r.Context(), "failed to publish peer update after adding static peer", "peer_id", peer.ID, "error", err)
+		}
+	}()
+}
+
+// swagger:route GET /api/networks/{networkname}/staticpeers/{peerid} networks getStaticPeer
+//
+// Get a static peer of a network.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: staticPeerResponse
+func getStaticPeer(w http.ResponseWriter, r *http.Request) {
+	var params = mux.Vars(r)
+	peer, err := logic.GetStaticPeer(params["networkname"], params["peerid"])
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	logic.ReturnJSONResponse(w, r, peer)
+}
+
+// swagger:route GET /api/networks/{networkname}/staticpeers/{peerid}/config networks getStaticPeerConfig
+//
+// Get the config of a static peer, peered with the nodes it may reach.
+// The format may be wireguard (the default), routeros, opnsense or pfsense.
+// The device's private key is left for its owner to fill in.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: successResponse
+func getStaticPeerConfig(w http.ResponseWriter, r *http.Request) {
+	var params = mux.Vars(r)
+	peer, err := logic.GetStaticPeer(params["networkname"], params["peerid"])
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	config, err := logic.StaticPeerDeviceConfig(&peer)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to render config of static peer", "user", r.Header.Get("user"), "peer_id", peer.ID, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	writeDeviceConfig(w, r, peer.Name, &config)
+}
+
+// swagger:route DELETE /api/networks/{networkname}/staticpeers/{peerid} networks deleteStaticPeer
+//
+// Remove a static peer from a network.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: successResponse
+func deleteStaticPeer(w http.ResponseWriter, r *http.Request) {
+	var params = mux.Vars(r)
+	peer, err := logic.GetStaticPeer(params["networkname"], params["peerid"])
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	if err := logic.DeleteStaticPeer(&peer); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to delete static peer", "user", r.Header.Get("user"), "peer_id", peer.ID, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "deleted static peer from network", "user", r.Header.Get("user"), "peer_name", peer.Name, "network", peer.Network)
+	logic.ReturnSuccessResponse(w, r, "deleted static peer "+peer.Name)
+	go func() {
+		// nodes drop a peer by its public key, the same way as a deleted ext client
+		if err := mq.PublishDeletedClientPeerUpdate(&models.ExtClient{PublicKey: peer.PublicKey, Network: peer.Network}); err != nil {
+			slog.ErrorCtx(r.Context(), "failed to publish peer update after deleting static peer", "peer_id", peer.ID, "error", err)
+		}
+	}()
+}
+
+// writeDeviceConfig - writes a device config as a file in the format asked for
+func writeDeviceConfig(w http.ResponseWriter, r *http.Request, name string, config *models.DeviceConfig) {
+	format := r.URL.Query().Get("format")
+	rendered, err := logic.RenderDeviceConfig(config, format)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	contentType, extension := "application/config", ".conf"
+	switch format {
+	case logic.DeviceConfigRouterOS:
+		contentType, extension = "text/plain", ".rsc"
+	case logic.DeviceConfigOPNsense, logic.DeviceConfigPfSense:
+		contentType, extension = "application/xml", ".xml"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+name+extension+"\"")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, rendered)
+}
+
+// swagger:route POST /api/networks/{networkname}/import networks importWireGuardConfigs
+//
+// Imports hand managed wireguard devices from wg-quick config files as static peers of a network.
+// Upload the files as multipart form data, or send them as json. Every [Peer], and each [Interface]
+// whose private key is included, becomes a static peer with a new address on the network.
+// Allowed ips besides the old tunnel addresses are routed to the device, unless they overlap ranges already
+// routed in the network, which are reported as conflicts. Set dryrun to only report what would be imported.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: wireGuardImportResponse
+func importWireGuardConfigs(w http.ResponseWriter, r *http.Request) {
+	network := mux.Vars(r)["networkname"]
+	request, err := readWireGuardImportRequest(w, r)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "error reading wireguard configs", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	result, err := logic.ImportWireGuardConfigs(network, &request)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to import wireguard configs into network", "user", r.Header.Get("user"), "network", network, "error", err)
+		if errors.Is(err, logic.ErrNoWireGuardConfigs) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	if !result.DryRun {
+		slog.InfoCtx(r.Context(), fmt.Sprintf("imported %d static peers into network %s with %d conflicts", len(result.Created), network, len(result.Conflicts)), "user", r.Header.Get("user"))
+	}
+	logic.ReturnJSONResponse(w, r, result)
+	if !result.DryRun && len(result.Created) > 0 {
+		go func() {
+			if err := mq.PublishNetworkPeerUpdate(network); err != nil {
+				slog.ErrorCtx(r.Context(), "failed to publish peer update after importing static peers", "network", network, "error", err)
+			}
+		}()
+	}
+}
+
+// readWireGuardImportRequest - reads configs uploaded as multipart form files under any field, or sent as json
+func readWireGuardImportRequest(w http.ResponseWriter, r *http.Request) (models.WireGuardImportRequest, error) {
+	var request models.WireGuardImportRequest
+	r.Body = http.MaxBytesReader(w, r.Body, maxWireGuardImportSize)
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		err := json.NewDecoder(r.Body).Decode(&request)
+		return request, err
+	}
+	if err := r.ParseMultipartForm(maxWireGuardImportSize); err != nil {
+		return request, err
+	}
+	request.DryRun = r.FormValue("dryrun") == "true"
+	for _, files := range r.MultipartForm.File {
+		for _, header := range files {
+			file, err := header.Open()
+			if err != nil {
+				return request, err
+			}
+			content, err := io.ReadAll(file)
+			file.Close()
+			if err != nil {
+				return request, err
+			}
+			request.Configs = append(request.Configs, models.WireGuardConfigFile{Name: header.Filename, Content: string(content)})
+		}
+	}
+	return request, nil
+}
@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/mq"
+	"golang.org/x/exp/slog"
+)
+
+func tailnetHandlers(r *mux.Router) {
+	r.HandleFunc("/api/migrate/tailnet", logic.SuperAdminCheck(http.HandlerFunc(importTailnet))).Methods(http.MethodPost)
+}
+
+// swagger:route POST /api/migrate/tailnet networks importTailnet
+//
+// Migrates a Tailscale tailnet, from a dump of its API, or a Headscale server, from the json output of its cli.
+// The network is created if it doesn't exist, users are created with access to it, and devices become static peers
+// pending a netclient install, with their approved subnet routes. The ACL policy, if given, allows or denies
+// each pair of imported devices. The mappings report what each part became and what couldn't be converted.
+// Set dryrun to only get the report.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: tailnetImportResponse
+func importTailnet(w http.ResponseWriter, r *http.Request) {
+	var request models.TailnetImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		slog.ErrorCtx(r.Context(), "error decoding request body", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	result, err := logic.ImportTailnet(&request)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to import tailnet into network", "user", r.Header.Get("user"), "source", request.Source, "network", request.Network, "error", err)
+		if errors.Is(err, logic.ErrInvalidTailnetExport) || errors.Is(err, logic.ErrTailnetAddressRange) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	if !result.DryRun {
+		slog.InfoCtx(r.Context(), fmt.Sprintf("imported %s tailnet into network %s", request.Source, result.Network), "user", r.Header.Get("user"))
+	}
+	logic.ReturnJSONResponse(w, r, result)
+	if !result.DryRun {
+		go func() {
+			if err := mq.PublishNetworkPeerUpdate(result.Network); err != nil {
+				slog.ErrorCtx(r.Context(), "failed to publish peer update after importing tailnet", "network", result.Network, "error", err)
+			}
+		}()
+	}
+}
@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+func tenantHandlers(r *mux.Router) {
+	r.HandleFunc("/api/tenants", logic.SuperAdminCheck(http.HandlerFunc(getTenants))).Methods(http.MethodGet)
+	r.HandleFunc("/api/tenants", logic.SuperAdminCheck(http.HandlerFunc(createTenant))).Methods(http.MethodPost)
+	r.HandleFunc("/api/tenants/{tenantid}", logic.SuperAdminCheck(http.HandlerFunc(getTenant))).Methods(http.MethodGet)
+	r.HandleFunc("/api/tenants/{tenantid}", logic.SuperAdminCheck(http.HandlerFunc(deleteTenant))).Methods(http.MethodDelete)
+}
+
+// swagger:route GET /api/tenants tenants getTenants
+//
+// Lists all tenants.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: tenantsResponse
+func getTenants(w http.ResponseWriter, r *http.Request) {
+	tenants, err := logic.GetTenants()
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to fetch tenants", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	writeList(w, r, tenants)
+}
+
+// swagger:route GET /api/tenants/{tenantid} tenants getTenant
+//
+// Gets a tenant.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: tenantResponse
+func getTenant(w http.ResponseWriter, r *http.Request) {
+	tenant, err := logic.GetTenant(mux.Vars(r)["tenantid"])
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnJSONResponse(w, r, tenant)
+}
+
+// swagger:route POST /api/tenants tenants createTenant
+//
+// Creates a tenant, an organization whose networks, users and enrollment keys are isolated from other tenants.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: tenantResponse
+func createTenant(w http.ResponseWriter, r *http.Request) {
+	var tenant models.Tenant
+	if err := json.NewDecoder(r.Body).Decode(&tenant); err != nil {
+		slog.ErrorCtx(r.Context(), "error decoding request body", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	if err := logic.CreateTenant(&tenant); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to create tenant", "user", r.Header.Get("user"), "tenant", tenant.ID, "error", err)
+		var validationErrs validator.ValidationErrors
+		if errors.As(err, &validationErrs) || errors.Is(err, logic.ErrTenantExists) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "created tenant", "user", r.Header.Get("user"), "tenant", tenant.ID)
+	logic.ReturnJSONResponse(w, r, tenant)
+}
+
+// swagger:route DELETE /api/tenants/{tenantid} tenants deleteTenant
+//
+// Deletes a tenant that no longer has any networks or users.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: successResponse
+func deleteTenant(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["tenantid"]
+	if err := logic.DeleteTenant(id); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to delete tenant", "user", r.Header.Get("user"), "tenant", id, "error", err)
+		switch {
+		case database.IsEmptyRecord(err):
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		case errors.Is(err, logic.ErrTenantInUse):
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "conflict"))
+		default:
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		}
+		return
+	}
+	slog.InfoCtx(r.Context(), "deleted tenant", "user", r.Header.Get("user"), "tenant", id)
+	logic.ReturnSuccessResponse(w, r, "deleted tenant "+id)
+}
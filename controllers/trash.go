@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/mq"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
+)
+
+func trashHandlers(r *mux.Router) {
+	r.HandleFunc("/api/v1/trash", logic.SecurityCheck(true, http.HandlerFunc(getTrash))).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/trash/{id}/restore", logic.SecurityCheck(true, http.HandlerFunc(restoreTrashedResource))).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/trash/{id}", logic.SecurityCheck(true, http.HandlerFunc(purgeTrashedResource))).Methods(http.MethodDelete)
+}
+
+// swagger:route GET /api/v1/trash trash getTrash
+//
+// Lists the deleted networks, nodes and ext clients that can still be restored, latest deletion first.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: trashResponse
+func getTrash(w http.ResponseWriter, r *http.Request) {
+	trash, err := logic.GetTrash(r.Header.Get("tenant"))
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnJSONResponse(w, r, trash)
+}
+
+// swagger:route POST /api/v1/trash/{id}/restore trash restoreTrashedResource
+//
+// Restores a deleted network, node or ext client. A node is added back to its host with a new id
+// and without its gateway, relay or failover roles.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: successResponse
+func restoreTrashedResource(w http.ResponseWriter, r *http.Request) {
+	item, ok := getTenantTrashedResource(w, r)
+	if !ok {
+		return
+	}
+	var err error
+	switch item.Kind {
+	case models.TrashNetwork:
+		_, err = logic.RestoreNetwork(&item)
+	case models.TrashNode:
+		var node models.Node
+		var host *models.Host
+		if node, host, err = logic.RestoreNode(&item); err == nil {
+			go func() {
+				if err := mq.HostUpdate(&models.HostUpdate{Action: models.JoinHostToNetwork, Host: *host, Node: node}); err != nil {
+					slog.Error("failed to send restored node to its host", "node", node.ID, "host", host.ID, "error", err)
+				}
+				if err := mq.PublishNodePeerUpdate(&node); err != nil {
+					slog.Error("failed to publish peer update for restored node", "node", node.ID, "error", err)
+				}
+			}()
+		}
+	case models.TrashExtClient:
+		var client models.ExtClient
+		if client, err = logic.RestoreExtClient(&item); err == nil {
+			go func() {
+				if err := mq.PublishNetworkPeerUpdate(client.Network); err != nil {
+					slog.Error("failed to publish peer update for restored ext client", "client", client.ClientID, "error", err)
+				}
+				if err := mq.PublishExtCLientDNS(&client); err != nil {
+					slog.Error("failed to publish dns of restored ext client", "client", client.ClientID, "error", err)
+				}
+				if servercfg.IsDNSMode() {
+					logic.SetDNS()
+				}
+			}()
+		}
+	default:
+		err = fmt.Errorf("unknown kind %s", item.Kind)
+	}
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to restore from trash", "user", r.Header.Get("user"), "kind", item.Kind, "name", item.Name, "error", err)
+		if errors.Is(err, logic.ErrRestoreConflict) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "forbidden"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "restored from trash", "user", r.Header.Get("user"), "kind", item.Kind, "name", item.Name, "network", item.Network)
+	logic.ReturnSuccessResponse(w, r, fmt.Sprintf("restored %s %s", item.Kind, item.Name))
+}
+
+// swagger:route DELETE /api/v1/trash/{id} trash purgeTrashedResource
+//
+// Removes a deleted network, node or ext client from the trash for good.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: successResponse
+func purgeTrashedResource(w http.ResponseWriter, r *http.Request) {
+	item, ok := getTenantTrashedResource(w, r)
+	if !ok {
+		return
+	}
+	if err := logic.DeleteTrashedResource(item.ID); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "purged from trash", "user", r.Header.Get("user"), "kind", item.Kind, "name", item.Name, "network", item.Network)
+	logic.ReturnSuccessResponse(w, r, fmt.Sprintf("purged %s %s", item.Kind, item.Name))
+}
+
+// getTenantTrashedResource - fetches the trashed resource of the request, false if it answered with an error
+func getTenantTrashedResource(w http.ResponseWriter, r *http.Request) (models.TrashedResource, bool) {
+	item, err := logic.GetTrashedResource(mux.Vars(r)["id"])
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("not found in trash"), "notfound"))
+		return item, false
+	}
+	if tenant := r.Header.Get("tenant"); tenant != "" && item.Tenant != tenant {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(logic.ErrTenantMismatch, "forbidden"))
+		return item, false
+	}
+	return item, true
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
@@ -12,13 +14,18 @@ import (
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/mq"
 	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
 )
 
 var (
 	upgrader = websocket.Upgrader{}
 )
 
+// maxUserImportSize - the most user data an import may upload
+const maxUserImportSize = 5 << 20
+
 // verifyJWT makes logic.VerifyJWT fakeable/mockable in tests
 var verifyJWT = logic.VerifyJWT
 
@@ -27,12 +34,20 @@ func userHandlers(r *mux.Router) {
 	r.HandleFunc("/api/users/adm/hasadmin", hasAdmin).Methods(http.MethodGet)
 	r.HandleFunc("/api/users/adm/createadmin", createAdmin).Methods(http.MethodPost)
 	r.HandleFunc("/api/users/adm/authenticate", authenticateUser).Methods(http.MethodPost)
+	r.HandleFunc("/api/users/adm/recover", recoverSuperAdmin).Methods(http.MethodPost)
+	r.HandleFunc("/api/users/import", logic.SecurityCheck(true, http.HandlerFunc(importUsers))).Methods(http.MethodPost)
+	r.HandleFunc("/api/users/verify/{token}", verifyUserEmail).Methods(http.MethodGet)
+	r.HandleFunc("/api/users/{username}/verification", logic.SecurityCheck(true, http.HandlerFunc(resendUserVerification))).Methods(http.MethodPost)
 	r.HandleFunc("/api/users/{username}", logic.SecurityCheck(false, logic.ContinueIfUserMatch(http.HandlerFunc(updateUser)))).Methods(http.MethodPut)
 	r.HandleFunc("/api/users/networks/{username}", logic.SecurityCheck(true, http.HandlerFunc(updateUserNetworks))).Methods(http.MethodPut)
 	r.HandleFunc("/api/users/{username}/adm", logic.SecurityCheck(true, http.HandlerFunc(updateUserAdm))).Methods(http.MethodPut)
+	r.HandleFunc("/api/users/{username}/auditor", logic.SecurityCheck(true, http.HandlerFunc(updateUserAuditor))).Methods(http.MethodPut)
+	r.HandleFunc("/api/users/{username}/rename", logic.SecurityCheck(true, http.HandlerFunc(renameUser))).Methods(http.MethodPut)
 	r.HandleFunc("/api/users/{username}", logic.SecurityCheck(true, checkFreeTierLimits(limitChoiceUsers, http.HandlerFunc(createUser)))).Methods(http.MethodPost)
 	r.HandleFunc("/api/users/{username}", logic.SecurityCheck(true, http.HandlerFunc(deleteUser))).Methods(http.MethodDelete)
 	r.HandleFunc("/api/users/{username}", logic.SecurityCheck(false, logic.ContinueIfUserMatch(http.HandlerFunc(getUser)))).Methods(http.MethodGet)
+	r.HandleFunc("/api/users/{username}/activity", logic.SecurityCheck(true, http.HandlerFunc(getUserActivity))).Methods(http.MethodGet)
+	r.HandleFunc("/api/users/{username}/security", logic.SecurityCheck(false, logic.ContinueIfUserMatch(http.HandlerFunc(getUserSecurity)))).Methods(http.MethodGet)
 	r.HandleFunc("/api/users", logic.SecurityCheck(true, http.HandlerFunc(getUsers))).Methods(http.MethodGet)
 	r.HandleFunc("/api/oauth/login", auth.HandleAuthLogin).Methods(http.MethodGet)
 	r.HandleFunc("/api/oauth/callback", auth.HandleAuthCallback).Methods(http.MethodGet)
@@ -69,23 +84,22 @@ func authenticateUser(response http.ResponseWriter, request *http.Request) {
 	decoderErr := decoder.Decode(&authRequest)
 	defer request.Body.Close()
 	if decoderErr != nil {
-		logger.Log(0, "error decoding request body: ",
-			decoderErr.Error())
+		slog.ErrorCtx(request.Context(), "error decoding request body", "error", decoderErr)
 		logic.ReturnErrorResponse(response, request, errorResponse)
 		return
 	}
 	username := authRequest.UserName
 	jwt, err := logic.VerifyAuthRequest(authRequest)
+	logic.RecordAuthEvent(username, request, "basic auth", err == nil)
 	if err != nil {
-		logger.Log(0, username, "user validation failed: ",
-			err.Error())
+		slog.ErrorCtx(request.Context(), "user validation failed", "username", username, "error", err)
 		logic.ReturnErrorResponse(response, request, logic.FormatError(err, "badrequest"))
 		return
 	}
 
 	if jwt == "" {
 		// very unlikely that err is !nil and no jwt returned, but handle it anyways.
-		logger.Log(0, username, "jwt token is empty")
+		slog.InfoCtx(request.Context(), "jwt token is empty", "username", username)
 		logic.ReturnErrorResponse(response, request, logic.FormatError(errors.New("no token returned"), "internal"))
 		return
 	}
@@ -102,16 +116,75 @@ func authenticateUser(response http.ResponseWriter, request *http.Request) {
 	successJSONResponse, jsonError := json.Marshal(successResponse)
 
 	if jsonError != nil {
-		logger.Log(0, username,
-			"error marshalling resp: ", err.Error())
+		slog.ErrorCtx(request.Context(), "error marshalling resp", "username", username, "error", err)
 		logic.ReturnErrorResponse(response, request, errorResponse)
 		return
 	}
-	logger.Log(2, username, "was authenticated")
+	slog.DebugCtx(request.Context(), "user was authenticated", "username", username)
 	response.Header().Set("Content-Type", "application/json")
 	response.Write(successJSONResponse)
 }
 
+// swagger:route POST /api/users/adm/recover user recoverSuperAdmin
+//
+// Break-glass access: creates a superadmin, or resets a user to one, with the one-time recovery token
+// printed at startup, even when oauth is misconfigured and basic auth is disabled.
+// Refused unless recovery access is turned on with RECOVERY_ACCESS=on, the token is the only credential.
+//
+//	Schemes: https
+//
+//	Responses:
+//		200: successResponse
+func recoverSuperAdmin(w http.ResponseWriter, r *http.Request) {
+	// keyed on the source the client can't forge
+	if !allowRequest("recover:"+logic.APISourceIP(r).String(), recoveryAttemptRate, recoveryAttemptBurst) {
+		w.Header().Set("Retry-After", "60")
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("too many recovery attempts"), "toomanyrequests"))
+		return
+	}
+	var recovery models.RecoveryRequest
+	if err := json.NewDecoder(r.Body).Decode(&recovery); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	user, err := logic.RecoverSuperAdmin(&recovery)
+	status, errType := http.StatusOK, "badrequest"
+	if errors.Is(err, logic.ErrInvalidRecoveryToken) || errors.Is(err, logic.ErrRecoveryDisabled) {
+		status, errType = http.StatusUnauthorized, "unauthorized"
+	} else if err != nil {
+		status = http.StatusBadRequest
+	}
+	// every attempt is recorded, the audit middleware only sees authenticated requests
+	logic.RecordAudit(models.AuditEntry{
+		User:       recovery.UserName,
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Status:     status,
+		RemoteAddr: r.RemoteAddr,
+	})
+	logic.RecordAuthEvent(recovery.UserName, r, "recovery token", err == nil)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "recovery attempt failed", "user_name", recovery.UserName, "remote_addr", r.RemoteAddr, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, errType))
+		return
+	}
+	jwt, err := logic.CreateProUserJWT(user.UserName, user.Networks, user.Groups, user.IsAdmin)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "user was made a superadmin with the recovery token", "user_name", user.UserName, "remote_addr", r.RemoteAddr)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.SuccessResponse{
+		Code:    http.StatusOK,
+		Message: "W1R3: Device " + user.UserName + " Authorized",
+		Response: models.SuccessfulUserLoginResponse{
+			AuthToken: jwt,
+			UserName:  user.UserName,
+		},
+	})
+}
+
 // swagger:route GET /api/users/adm/hasadmin user hasAdmin
 //
 // Checks whether the server has an admin.
@@ -129,7 +202,7 @@ func hasAdmin(w http.ResponseWriter, r *http.Request) {
 
 	hasadmin, err := logic.HasAdmin()
 	if err != nil {
-		logger.Log(0, "failed to check for admin: ", err.Error())
+		slog.ErrorCtx(r.Context(), "failed to check for admin", "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
@@ -158,11 +231,11 @@ func getUser(w http.ResponseWriter, r *http.Request) {
 	user, err := logic.GetReturnUser(usernameFetched)
 
 	if err != nil {
-		logger.Log(0, usernameFetched, "failed to fetch user: ", err.Error())
+		slog.ErrorCtx(r.Context(), "failed to fetch user", "username_fetched", usernameFetched, "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
-	logger.Log(2, r.Header.Get("user"), "fetched user", usernameFetched)
+	slog.DebugCtx(r.Context(), "fetched user", "user", r.Header.Get("user"), "username_fetched", usernameFetched)
 	json.NewEncoder(w).Encode(user)
 }
 
@@ -184,14 +257,23 @@ func getUsers(w http.ResponseWriter, r *http.Request) {
 	users, err := logic.GetUsers()
 
 	if err != nil {
-		logger.Log(0, "failed to fetch users: ", err.Error())
+		slog.ErrorCtx(r.Context(), "failed to fetch users", "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
+	if tenant := r.Header.Get("tenant"); tenant != "" {
+		tenantUsers := []models.ReturnUser{}
+		for _, user := range users {
+			if user.Tenant == tenant {
+				tenantUsers = append(tenantUsers, user)
+			}
+		}
+		users = tenantUsers
+	}
 
 	logic.SortUsers(users[:])
-	logger.Log(2, r.Header.Get("user"), "fetched users")
-	json.NewEncoder(w).Encode(users)
+	slog.DebugCtx(r.Context(), "fetched users", "user", r.Header.Get("user"))
+	writeList(w, r, users)
 }
 
 // swagger:route POST /api/users/adm/createadmin user createAdmin
@@ -213,8 +295,7 @@ func createAdmin(w http.ResponseWriter, r *http.Request) {
 	err := json.NewDecoder(r.Body).Decode(&admin)
 	if err != nil {
 
-		logger.Log(0, admin.UserName, "error decoding request body: ",
-			err.Error())
+		slog.ErrorCtx(r.Context(), "error decoding request body", "user_name", admin.UserName, "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
@@ -226,13 +307,12 @@ func createAdmin(w http.ResponseWriter, r *http.Request) {
 
 	err = logic.CreateAdmin(&admin)
 	if err != nil {
-		logger.Log(0, admin.UserName, "failed to create admin: ",
-			err.Error())
+		slog.ErrorCtx(r.Context(), "failed to create admin", "user_name", admin.UserName, "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
 
-	logger.Log(1, admin.UserName, "was made a new admin")
+	slog.InfoCtx(r.Context(), "user was made a new admin", "user_name", admin.UserName)
 	json.NewEncoder(w).Encode(logic.ToReturnUser(admin))
 }
 
@@ -253,23 +333,126 @@ func createUser(w http.ResponseWriter, r *http.Request) {
 	var user models.User
 	err := json.NewDecoder(r.Body).Decode(&user)
 	if err != nil {
-		logger.Log(0, user.UserName, "error decoding request body: ",
-			err.Error())
+		slog.ErrorCtx(r.Context(), "error decoding request body", "user_name", user.UserName, "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
+	// tenant admins always create users within their own tenant
+	if tenant := r.Header.Get("tenant"); tenant != "" {
+		user.Tenant = tenant
+	} else if user.Tenant != "" {
+		if _, err = logic.GetTenant(user.Tenant); err != nil {
+			err = fmt.Errorf("unknown tenant %s", user.Tenant)
+			slog.ErrorCtx(r.Context(), "error creating new user", "user_name", user.UserName, "error", err)
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+	}
+	if user.Tenant != "" {
+		if err = logic.NetworksInTenant(user.Networks, user.Tenant); err != nil {
+			slog.ErrorCtx(r.Context(), "error creating new user", "user_name", user.UserName, "error", err)
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+	}
 
-	err = logic.CreateUser(&user)
+	err = logic.CreateLocalUser(&user)
 	if err != nil {
-		logger.Log(0, user.UserName, "error creating new user: ",
-			err.Error())
+		slog.ErrorCtx(r.Context(), "error creating new user", "user_name", user.UserName, "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
-	logger.Log(1, user.UserName, "was created")
+	slog.InfoCtx(r.Context(), "user was created", "user_name", user.UserName)
 	json.NewEncoder(w).Encode(logic.ToReturnUser(user))
 }
 
+// swagger:route POST /api/users/import user importUsers
+//
+// Creates many users at once, for onboarding large teams. Send a csv with a header row naming the columns
+// username, password, role (admin, auditor or user), networks, groups, gateways, tenant, display_name,
+// email and department, where lists are separated by semicolons, or the users as json. Users without
+// a password get a random one, for signing in with oauth. Every row is validated and created on its own
+// and reported in the results; set dryrun to only validate them.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: userImportResponse
+func importUsers(w http.ResponseWriter, r *http.Request) {
+	var request models.UserImportRequest
+	var err error
+	r.Body = http.MaxBytesReader(w, r.Body, maxUserImportSize)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+		request.Users, err = logic.ParseUserImportCSV(r.Body)
+	} else {
+		err = json.NewDecoder(r.Body).Decode(&request)
+	}
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "error reading users to import", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	request.DryRun = request.DryRun || isDryRun(r)
+	result, err := logic.ImportUsers(&request, r.Header.Get("tenant"))
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to import users", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	if !result.DryRun {
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("imported %d users, %d failed", result.Created, result.Failed), "user", r.Header.Get("user"))
+	}
+	logic.ReturnJSONResponse(w, r, result)
+}
+
+// swagger:route GET /api/users/verify/{token} user verifyUserEmail
+//
+// Verifies the email address of a new user with the link emailed to them, so they can sign in.
+//
+//	Schemes: https
+//
+//	Responses:
+//		200: successResponse
+func verifyUserEmail(w http.ResponseWriter, r *http.Request) {
+	user, err := logic.VerifyEmail(mux.Vars(r)["token"])
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "user verified their email address", "user_name", user.UserName)
+	logic.ReturnSuccessResponse(w, r, "email address of "+user.UserName+" verified, you can sign in now")
+}
+
+// swagger:route POST /api/users/{username}/verification user resendUserVerification
+//
+// Emails a new verification link to a user pending email verification, the links sent before stop working.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: successResponse
+func resendUserVerification(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	user, err := logic.GetUser(username)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	if err := logic.SendEmailVerification(user); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to send verification email", "user", r.Header.Get("user"), "username", username, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "sent a verification email", "user", r.Header.Get("user"), "username", username)
+	logic.ReturnSuccessResponse(w, r, "verification email sent to "+username)
+}
+
 // swagger:route PUT /api/users/networks/{username} user updateUserNetworks
 //
 // Updates the networks of the given user.
@@ -288,8 +471,7 @@ func updateUserNetworks(w http.ResponseWriter, r *http.Request) {
 	username := params["username"]
 	user, err := logic.GetUser(username)
 	if err != nil {
-		logger.Log(0, username,
-			"failed to update user networks: ", err.Error())
+		slog.ErrorCtx(r.Context(), "failed to update user networks", "username", username, "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
@@ -297,11 +479,17 @@ func updateUserNetworks(w http.ResponseWriter, r *http.Request) {
 	// we decode our body request params
 	err = json.NewDecoder(r.Body).Decode(userChange)
 	if err != nil {
-		logger.Log(0, username, "error decoding request body: ",
-			err.Error())
+		slog.ErrorCtx(r.Context(), "error decoding request body", "username", username, "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
+	if user.Tenant != "" {
+		if err = logic.NetworksInTenant(userChange.Networks, user.Tenant); err != nil {
+			slog.ErrorCtx(r.Context(), "failed to update user networks", "username", username, "error", err)
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+	}
 	err = logic.UpdateUserNetworks(userChange.Networks, userChange.Groups, userChange.IsAdmin, &models.ReturnUser{
 		Groups:   user.Groups,
 		IsAdmin:  user.IsAdmin,
@@ -310,16 +498,15 @@ func updateUserNetworks(w http.ResponseWriter, r *http.Request) {
 	})
 
 	if err != nil {
-		logger.Log(0, username,
-			"failed to update user networks: ", err.Error())
+		slog.ErrorCtx(r.Context(), "failed to update user networks", "username", username, "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
-	logger.Log(1, username, "status was updated")
+	slog.InfoCtx(r.Context(), "user status was updated", "username", username)
 	// re-read and return the new user struct
 	returnUser, err := logic.GetReturnUser(username)
 	if err != nil {
-		logger.Log(0, username, "failed to fetch user: ", err.Error())
+		slog.ErrorCtx(r.Context(), "failed to fetch user", "username", username, "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
@@ -343,26 +530,25 @@ func updateUser(w http.ResponseWriter, r *http.Request) {
 	// start here
 	jwtUser, _, isadmin, err := verifyJWT(r.Header.Get("Authorization"))
 	if err != nil {
-		logger.Log(0, "verifyJWT error", err.Error())
+		slog.ErrorCtx(r.Context(), "verifyJWT error", "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
 	username := params["username"]
 	if username != jwtUser && !isadmin {
-		logger.Log(0, "non-admin user", jwtUser, "attempted to update user", username)
+		slog.InfoCtx(r.Context(), "non-admin user attempted to update user", "jwt_user", jwtUser, "username", username)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("not authorizied"), "unauthorized"))
 		return
 	}
 	user, err := logic.GetUser(username)
 	if err != nil {
-		logger.Log(0, username,
-			"failed to update user info: ", err.Error())
+		slog.ErrorCtx(r.Context(), "failed to update user info", "username", username, "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
 	if auth.IsOauthUser(user) == nil {
 		err := fmt.Errorf("cannot update user info for oauth user %s", username)
-		logger.Log(0, err.Error())
+		slog.ErrorCtx(r.Context(), err.Error())
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "forbidden"))
 		return
 	}
@@ -370,25 +556,29 @@ func updateUser(w http.ResponseWriter, r *http.Request) {
 	// we decode our body request params
 	err = json.NewDecoder(r.Body).Decode(&userchange)
 	if err != nil {
-		logger.Log(0, username, "error decoding request body: ",
-			err.Error())
+		slog.ErrorCtx(r.Context(), "error decoding request body", "username", username, "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
 	if userchange.IsAdmin && !isadmin {
-		logger.Log(0, "non-admin user", jwtUser, "attempted get admin privilages")
+		slog.InfoCtx(r.Context(), "non-admin user attempted get admin privilages", "jwt_user", jwtUser)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("not authorizied"), "unauthorized"))
 		return
 	}
+	if userchange.IsAdmin && !user.IsAdmin && user.Tenant == "" {
+		// the rest of the change is dropped along with the request, an approval only grants the role
+		if holdForApproval(w, r, &models.ApprovalRequest{Operation: models.ApprovalGrantSuperAdmin, Target: username}) {
+			return
+		}
+	}
 	userchange.Networks = nil
 	user, err = logic.UpdateUser(&userchange, user)
 	if err != nil {
-		logger.Log(0, username,
-			"failed to update user info: ", err.Error())
+		slog.ErrorCtx(r.Context(), "failed to update user info", "username", username, "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
-	logger.Log(1, username, "was updated")
+	slog.InfoCtx(r.Context(), "user was updated", "username", username)
 	json.NewEncoder(w).Encode(logic.ToReturnUser(*user))
 }
 
@@ -415,7 +605,7 @@ func updateUserAdm(w http.ResponseWriter, r *http.Request) {
 	}
 	if auth.IsOauthUser(user) == nil {
 		err := fmt.Errorf("cannot update user info for oauth user %s", username)
-		logger.Log(0, err.Error())
+		slog.ErrorCtx(r.Context(), err.Error())
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "forbidden"))
 		return
 	}
@@ -423,26 +613,99 @@ func updateUserAdm(w http.ResponseWriter, r *http.Request) {
 	// we decode our body request params
 	err = json.NewDecoder(r.Body).Decode(&userchange)
 	if err != nil {
-		logger.Log(0, username, "error decoding request body: ",
-			err.Error())
+		slog.ErrorCtx(r.Context(), "error decoding request body", "username", username, "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
 	if !user.IsAdmin {
-		logger.Log(0, username, "not an admin user")
+		slog.InfoCtx(r.Context(), "user not an admin user", "username", username)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("not a admin user"), "badrequest"))
 	}
 	user, err = logic.UpdateUser(&userchange, user)
 	if err != nil {
-		logger.Log(0, username,
-			"failed to update user (admin) info: ", err.Error())
+		slog.ErrorCtx(r.Context(), "failed to update user (admin) info", "username", username, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "user was updated (admin)", "username", username)
+	json.NewEncoder(w).Encode(logic.ToReturnUser(*user))
+}
+
+// swagger:route PUT /api/users/{username}/auditor user updateUserAuditor
+//
+// Grants or revokes the read-only auditor role, which can view every network, node, acl, user
+// and the audit log of its tenant, and export them, but can't change anything.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: userBodyResponse
+func updateUserAuditor(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	username := mux.Vars(r)["username"]
+	user, err := logic.GetUser(username)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	var userchange models.User
+	if err = json.NewDecoder(r.Body).Decode(&userchange); err != nil {
+		slog.ErrorCtx(r.Context(), "error decoding request body", "username", username, "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
-	logger.Log(1, username, "was updated (admin)")
+	if err = logic.SetUserAuditor(user, userchange.IsAuditor); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to update auditor role", "username", username, "error", err)
+		if errors.Is(err, logic.ErrAuditorAdmin) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "set auditor role", "user", r.Header.Get("user"), "username", username, "is_auditor", user.IsAuditor)
 	json.NewEncoder(w).Encode(logic.ToReturnUser(*user))
 }
 
+// swagger:route PUT /api/users/{username}/rename user renameUser
+//
+// Changes the username of a user to the username in the body, rewriting the owner of their ext clients
+// and nodes, their network user entries, audit logs, pending approvals and other records referring to them.
+// Either every record is rewritten or none is. The user has to sign in again with the new name.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: userBodyResponse
+func renameUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	username := mux.Vars(r)["username"]
+	if _, err := logic.GetUser(username); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	var userchange models.User
+	if err := json.NewDecoder(r.Body).Decode(&userchange); err != nil {
+		slog.ErrorCtx(r.Context(), "error decoding request body", "username", username, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	user, err := logic.RenameUser(username, userchange.UserName)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to rename user", "user", r.Header.Get("user"), "username", username, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "renamed user", "user", r.Header.Get("user"), "username", username, "user_name", user.UserName)
+	json.NewEncoder(w).Encode(logic.ToReturnUser(user))
+}
+
 // swagger:route DELETE /api/users/{username} user deleteUser
 //
 // Delete a user.
@@ -465,31 +728,121 @@ func deleteUser(w http.ResponseWriter, r *http.Request) {
 
 	success, err := logic.DeleteUser(username)
 	if err != nil {
-		logger.Log(0, username,
-			"failed to delete user: ", err.Error())
+		slog.ErrorCtx(r.Context(), "failed to delete user", "username", username, "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	} else if !success {
 		err := errors.New("delete unsuccessful")
-		logger.Log(0, username, err.Error())
+		slog.ErrorCtx(r.Context(), "failed to delete user", "username", username, "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
 
-	logger.Log(1, username, "was deleted")
+	if err := logic.DeleteAPIAllowlist(username); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to delete api allowlist of deleted user", "username", username, "error", err)
+	}
+	if err := logic.DeleteUserSecurity(username); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to delete devices and security events of deleted user", "username", username, "error", err)
+	}
+	if _, err := logic.DeleteSourcePolicy(username); err != nil {
+		slog.ErrorCtx(r.Context(), "failed to delete source policy of deleted user", "username", username, "error", err)
+	}
+	// the gateways stop accepting the short lived keys of the user's clients right away
+	if networks := logic.ExpireUserExtClientKeys(username); len(networks) > 0 {
+		go func() {
+			for _, network := range networks {
+				if err := mq.PublishNetworkPeerUpdate(network); err != nil {
+					slog.ErrorCtx(r.Context(), "error publishing peer update after revoking keys", "username", username, "error", err)
+				}
+			}
+		}()
+	}
+	slog.InfoCtx(r.Context(), "user was deleted", "username", username)
 	json.NewEncoder(w).Encode(params["username"] + " deleted.")
 }
 
+// swagger:route GET /api/users/{username}/activity user getUserActivity
+//
+// Summarizes the connections of a user's ext clients for security reviews of remote access:
+// last handshake, transfer, connected time and source ips per gateway, and each session.
+// Covers the last 30 days unless from and to (YYYY-MM-DD) are given.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: userActivityResponse
+func getUserActivity(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	if _, err := logic.GetUser(username); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	query := r.URL.Query()
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -30)
+	var err error
+	if v := query.Get("from"); v != "" {
+		if from, err = time.Parse(logger.TimeFormatDay, v); err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if to, err = time.Parse(logger.TimeFormatDay, v); err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		// the whole of the last day
+		to = to.AddDate(0, 0, 1).Add(-time.Second)
+	}
+	activity, err := logic.GetUserActivity(username, from, to)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to build activity report of user", "user", r.Header.Get("user"), "username", username, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	slog.InfoCtx(r.Context(), "fetched activity report of user", "user", r.Header.Get("user"), "username", username)
+	logic.ReturnJSONResponse(w, r, activity)
+}
+
+// swagger:route GET /api/users/{username}/security user getUserSecurity
+//
+// The security page of a user: the devices they signed in from, logins from new devices and ext clients they enrolled.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: userSecurityResponse
+func getUserSecurity(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	if _, err := logic.GetUser(username); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	security, err := logic.GetUserSecurity(username)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnJSONResponse(w, r, security)
+}
+
 // Called when vpn client dials in to start the auth flow and first stage is to get register URL itself
 func socketHandler(w http.ResponseWriter, r *http.Request) {
 	// Upgrade our raw HTTP connection to a websocket based one
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		logger.Log(0, "error during connection upgrade for node sign-in:", err.Error())
+		slog.ErrorCtx(r.Context(), "error during connection upgrade for node sign-in", "error", err)
 		return
 	}
 	if conn == nil {
-		logger.Log(0, "failed to establish web-socket connection during node sign-in")
+		slog.ErrorCtx(r.Context(), "failed to establish web-socket connection during node sign-in")
 		return
 	}
 	// Start handling the session
@@ -433,3 +433,36 @@ func TestVerifyAuthRequest(t *testing.T) {
 		assert.NotNil(t, jwt)
 	})
 }
+
+func TestAuditorReadOnly(t *testing.T) {
+	deleteAllUsers(t)
+	auditor := models.User{UserName: "auditor", Password: "password", IsAuditor: true}
+	if err := logic.CreateUser(&auditor); err != nil {
+		t.Fatal(err)
+	}
+	token, err := logic.CreateUserJWT(auditor.UserName, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := logic.SecurityCheck(true, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Run("Read", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/networks", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+	t.Run("Change", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/networks", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+	t.Run("Admin", func(t *testing.T) {
+		admin := models.User{UserName: "admin", Password: "password", IsAdmin: true, IsAuditor: true}
+		assert.ErrorIs(t, logic.CreateUser(&admin), logic.ErrAuditorAdmin)
+	})
+}
@@ -0,0 +1,188 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+)
+
+const (
+	// how long a watch request waits for changes when no timeout is given
+	defaultWatchTimeout = 30 * time.Second
+	// the longest a watch request may be held open
+	maxWatchTimeout = 5 * time.Minute
+)
+
+// isWatch - checks if a list request asks to wait for changes instead (?watch=true)
+func isWatch(r *http.Request) bool {
+	watch, _ := strconv.ParseBool(r.URL.Query().Get("watch"))
+	return watch
+}
+
+// writeList - responds with a list, keeping only the fields picked with ?fields=a,b if given
+func writeList(w http.ResponseWriter, r *http.Request, list interface{}) {
+	redacted, err := logic.RedactSecrets(list)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	selected, err := selectFields(r, redacted)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnJSONResponse(w, r, selected)
+}
+
+// watchResources - long polls for node or host changes after ?resourceVersion=, answering as soon
+// as there are any or once ?timeout= expires; without a resourceVersion it answers straight away
+// with the current one. load returns the current object of a changed id and whether the caller may see it,
+// deleted whether the caller could see an object that was in the given networks
+func watchResources(w http.ResponseWriter, r *http.Request, kind string, load func(id string) (interface{}, bool), deleted func(networks []string) bool) {
+	query := r.URL.Query()
+	if query.Get("resourceVersion") == "" {
+		writeWatchResponse(w, r, &models.WatchResponse{ResourceVersion: logic.CurrentResourceVersion(), Events: []models.WatchEvent{}})
+		return
+	}
+	version, err := strconv.ParseInt(query.Get("resourceVersion"), 10, 64)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("invalid resourceVersion"), "badrequest"))
+		return
+	}
+	timeout := defaultWatchTimeout
+	if v := query.Get("timeout"); v != "" {
+		if timeout, err = time.ParseDuration(v); err != nil || timeout <= 0 {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("invalid timeout"), "badrequest"))
+			return
+		}
+		if timeout > maxWatchTimeout {
+			timeout = maxWatchTimeout
+		}
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	events, current, err := logic.WaitForChanges(ctx, kind, version)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, models.ErrorResponse{Code: http.StatusGone, Message: err.Error()})
+		return
+	}
+	response := models.WatchResponse{ResourceVersion: current, Events: []models.WatchEvent{}}
+	for _, event := range events {
+		if event.Type == string(database.ChangePut) {
+			object, ok := load(event.ID)
+			if !ok {
+				continue
+			}
+			if event.Object, err = selectFields(r, object); err != nil {
+				logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+				return
+			}
+		} else if !deleted(event.Networks) {
+			continue
+		}
+		response.Events = append(response.Events, event)
+	}
+	writeWatchResponse(w, r, &response)
+}
+
+// watchNodes - watches node changes, limited to the given networks unless networks is nil
+func watchNodes(w http.ResponseWriter, r *http.Request, networks []string) {
+	watchResources(w, r, logic.WatchKindNode, func(id string) (interface{}, bool) {
+		node, err := logic.GetNodeByID(id)
+		if err != nil || (networks != nil && !logic.StringSliceContains(networks, node.Network)) {
+			return nil, false
+		}
+		return node.ConvertToAPINode(), true
+	}, func(deletedFrom []string) bool {
+		return networks == nil || anyNetworkIn(deletedFrom, networks)
+	})
+}
+
+// watchHosts - watches host changes, limited to the hosts of the caller's tenant if any
+func watchHosts(w http.ResponseWriter, r *http.Request) {
+	tenant := r.Header.Get("tenant")
+	watchResources(w, r, logic.WatchKindHost, func(id string) (interface{}, bool) {
+		host, err := logic.GetHost(id)
+		if err != nil || (tenant != "" && !logic.HostInTenant(host, tenant)) {
+			return nil, false
+		}
+		return host.ConvertNMHostToAPI(), true
+	}, func(deletedFrom []string) bool {
+		if tenant == "" {
+			return true
+		}
+		tenantNetworks, err := logic.GetTenantNetworks(tenant)
+		return err == nil && anyNetworkIn(deletedFrom, tenantNetworks)
+	})
+}
+
+// anyNetworkIn - checks if any of the networks is one of allowed
+func anyNetworkIn(networks, allowed []string) bool {
+	for _, network := range networks {
+		if logic.StringSliceContains(allowed, network) {
+			return true
+		}
+	}
+	return false
+}
+
+func writeWatchResponse(w http.ResponseWriter, r *http.Request, response *models.WatchResponse) {
+	w.Header().Set("X-Resource-Version", strconv.FormatInt(response.ResourceVersion, 10))
+	logic.ReturnJSONResponse(w, r, response)
+}
+
+// setResourceVersion - tells the caller which version a list was read at, to start watching from
+func setResourceVersion(w http.ResponseWriter, version int64) {
+	w.Header().Set("X-Resource-Version", strconv.FormatInt(version, 10))
+}
+
+// selectFields - reduces the json form of v, or of each of its items, to the fields given with ?fields=
+func selectFields(r *http.Request, v interface{}) (interface{}, error) {
+	fields := r.URL.Query().Get("fields")
+	if fields == "" {
+		return v, nil
+	}
+	keep := make(map[string]bool)
+	for _, field := range strings.Split(fields, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			keep[field] = true
+		}
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+	switch value := decoded.(type) {
+	case []interface{}:
+		for _, item := range value {
+			pruneFields(item, keep)
+		}
+	default:
+		pruneFields(value, keep)
+	}
+	return decoded, nil
+}
+
+func pruneFields(v interface{}, keep map[string]bool) {
+	object, ok := v.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for key := range object {
+		if !keep[key] {
+			delete(object, key)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchNodesDeleted(t *testing.T) {
+	version := logic.CurrentResourceVersion()
+	node := models.Node{CommonNode: models.CommonNode{ID: uuid.New(), HostID: uuid.New(), Network: "secretnet"}}
+	data, _ := json.Marshal(&node)
+	assert.Nil(t, database.Insert(node.ID.String(), string(data), database.NODES_TABLE_NAME))
+	assert.Nil(t, database.DeleteRecord(database.NODES_TABLE_NAME, node.ID.String()))
+
+	watch := func(networks []string) models.WatchResponse {
+		r := httptest.NewRequest(http.MethodGet, "/api/nodes?watch=true&timeout=1ms&resourceVersion="+strconv.FormatInt(version, 10), nil)
+		w := httptest.NewRecorder()
+		watchNodes(w, r, networks)
+		var response models.WatchResponse
+		assert.Nil(t, json.NewDecoder(w.Body).Decode(&response))
+		return response
+	}
+	response := watch(nil)
+	assert.Equal(t, 1, len(response.Events))
+	assert.Equal(t, string(database.ChangeDelete), response.Events[0].Type)
+	assert.Equal(t, node.ID.String(), response.Events[0].ID)
+	// the delete of a node in another network isn't reported
+	response = watch([]string{"othernet"})
+	assert.Empty(t, response.Events)
+}
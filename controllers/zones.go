@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/mq"
+	"golang.org/x/exp/slog"
+)
+
+func zoneHandlers(r *mux.Router) {
+	r.HandleFunc("/api/networks/{networkname}/zones", logic.SecurityCheck(true, http.HandlerFunc(getNetworkZones))).Methods(http.MethodGet)
+	r.HandleFunc("/api/networks/{networkname}/zones", logic.SecurityCheck(true, http.HandlerFunc(setNetworkZones))).Methods(http.MethodPut)
+	r.HandleFunc("/api/networks/{networkname}/zones", logic.SecurityCheck(true, http.HandlerFunc(deleteNetworkZones))).Methods(http.MethodDelete)
+	r.HandleFunc("/api/networks/{networkname}/zones/presets/{preset}", logic.SecurityCheck(true, http.HandlerFunc(applyZonePreset))).Methods(http.MethodPost)
+}
+
+// swagger:route GET /api/networks/{networkname}/zones networks getNetworkZones
+//
+// Get the zones of a network, the nodes in each and what they compile to, without changing any ACL.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: zoneCompileResultResponse
+func getNetworkZones(w http.ResponseWriter, r *http.Request) {
+	netID := mux.Vars(r)["networkname"]
+	if !ipamNetworkInTenant(w, r, netID) {
+		return
+	}
+	result, err := logic.ApplyNetworkZones(netID, true)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to compile zones", "user", r.Header.Get("user"), "network", netID, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnJSONResponse(w, r, result)
+}
+
+// swagger:route PUT /api/networks/{networkname}/zones networks setNetworkZones
+//
+// Set the zones of a network, segments of nodes by tag, and the policies between them; the server compiles them
+// into the ACLs of the nodes and keeps them compiled as nodes are tagged. Set dryrun to only see what would change.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: zoneCompileResultResponse
+func setNetworkZones(w http.ResponseWriter, r *http.Request) {
+	var zones models.NetworkZones
+	if err := json.NewDecoder(r.Body).Decode(&zones); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	updateNetworkZones(w, r, &zones)
+}
+
+// swagger:route POST /api/networks/{networkname}/zones/presets/{preset} networks applyZonePreset
+//
+// Set the zones of a network to a preset: standard has prod, staging and user-devices zones, each of the nodes
+// tagged with its name, with prod and staging kept apart and user devices allowed to reach prod on 443.
+// Set dryrun to only see what would change.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: zoneCompileResultResponse
+func applyZonePreset(w http.ResponseWriter, r *http.Request) {
+	zones, err := logic.GetZonePreset(mux.Vars(r)["preset"])
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	updateNetworkZones(w, r, zones)
+}
+
+// swagger:route DELETE /api/networks/{networkname}/zones networks deleteNetworkZones
+//
+// Remove the zones of a network, the ACLs they compiled to are kept.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: zoneCompileResultResponse
+func deleteNetworkZones(w http.ResponseWriter, r *http.Request) {
+	updateNetworkZones(w, r, nil)
+}
+
+func updateNetworkZones(w http.ResponseWriter, r *http.Request, zones *models.NetworkZones) {
+	netID := mux.Vars(r)["networkname"]
+	if !ipamNetworkInTenant(w, r, netID) {
+		return
+	}
+	result, err := logic.SetNetworkZones(netID, zones, isDryRun(r))
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to update zones", "user", r.Header.Get("user"), "network", netID, "error", err)
+		if errors.Is(err, logic.ErrInvalidZones) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	if !result.DryRun {
+		slog.InfoCtx(r.Context(), "updated zones", "user", r.Header.Get("user"), "network", netID, "enabled", zones != nil, "changed", result.Changed)
+	}
+	logic.ReturnJSONResponse(w, r, result)
+	if !result.DryRun && result.Changed > 0 {
+		go func() {
+			if err := mq.PublishNetworkPeerUpdate(netID); err != nil {
+				slog.Warn("failed to publish peer update after zones change", "network", netID, "error", err)
+			}
+		}()
+	}
+}
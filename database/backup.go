@@ -0,0 +1,55 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
+)
+
+// Backup - a copy of every table, with sensitive fields still sealed as they are at rest
+type Backup struct {
+	Created time.Time                    `json:"created"`
+	Reason  string                       `json:"reason"`
+	Tables  map[string]map[string]string `json:"tables"`
+}
+
+// BackupTables - writes every table to a new file in the migration backup dir and returns its path
+func BackupTables(reason string) (string, error) {
+	backup := Backup{
+		Created: time.Now().UTC(),
+		Reason:  reason,
+		Tables:  map[string]map[string]string{},
+	}
+	dbMutex.RLock()
+	for _, table := range tables {
+		records, err := getCurrentDB()[FETCH_ALL].(func(string) (map[string]string, error))(table)
+		if err != nil {
+			if IsEmptyRecord(err) {
+				continue
+			}
+			dbMutex.RUnlock()
+			return "", fmt.Errorf("reading table %s: %w", table, err)
+		}
+		backup.Tables[table] = records
+	}
+	dbMutex.RUnlock()
+	data, err := json.Marshal(&backup)
+	if err != nil {
+		return "", err
+	}
+	dir := servercfg.GetMigrationBackupDir()
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("netmaker-%s.json", backup.Created.Format("20060102-150405")))
+	if err = os.WriteFile(path, data, 0600); err != nil {
+		return "", err
+	}
+	slog.Info("backed up the database", "path", path, "reason", reason)
+	return path, nil
+}
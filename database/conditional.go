@@ -0,0 +1,80 @@
+package database
+
+import (
+	"errors"
+)
+
+// ErrConditionalEncrypted - records with encrypted fields are stored sealed, so their value can't be compared
+var ErrConditionalEncrypted = errors.New("conditional deletes aren't supported on tables with encrypted fields")
+
+// Conditioner - implemented by stores that can make a write depend on the record atomically,
+// a store without it only keeps the servers of this process apart
+type Conditioner interface {
+	// InsertNew - stores the record unless the key exists; reports whether it was stored
+	InsertNew(key, value, tableName string) (bool, error)
+	// DeleteIf - removes the record if it holds value; reports whether it was removed
+	DeleteIf(tableName, key, value string) (bool, error)
+}
+
+// InsertNew - inserts a record unless one with the key exists, false if it does
+func InsertNew(key, value, tableName string) (bool, error) {
+	if key == "" || value == "" || !IsJSONString(value) {
+		return false, errors.New("invalid insert " + key + " : " + value)
+	}
+	sealed, err := sealRecord(tableName, value)
+	if err != nil {
+		return false, err
+	}
+	changeMutex.Lock()
+	defer changeMutex.Unlock()
+	dbMutex.Lock()
+	var inserted bool
+	if insertNew, ok := getCurrentDB()[INSERT_NEW].(func(string, string, string) (bool, error)); ok {
+		inserted, err = insertNew(key, sealed, tableName)
+	} else {
+		var records map[string]string
+		records, err = getCurrentDB()[FETCH_ALL].(func(string) (map[string]string, error))(tableName)
+		if err == nil || IsEmptyRecord(err) {
+			if _, exists := records[key]; !exists {
+				err = getCurrentDB()[INSERT].(func(string, string, string) error)(key, sealed, tableName)
+				inserted = err == nil
+			} else {
+				err = nil
+			}
+		}
+	}
+	dbMutex.Unlock()
+	if inserted {
+		notifyChange(Change{Type: ChangePut, Table: tableName, Key: key, Value: value})
+	}
+	return inserted, err
+}
+
+// DeleteIf - deletes a record only if it still holds value, false if it holds another or is gone
+func DeleteIf(tableName, key, value string) (bool, error) {
+	if len(encryptedFields[tableName]) > 0 {
+		return false, ErrConditionalEncrypted
+	}
+	changeMutex.Lock()
+	defer changeMutex.Unlock()
+	dbMutex.Lock()
+	var deleted bool
+	var err error
+	if deleteIf, ok := getCurrentDB()[DELETE_IF].(func(string, string, string) (bool, error)); ok {
+		deleted, err = deleteIf(tableName, key, value)
+	} else {
+		var records map[string]string
+		records, err = getCurrentDB()[FETCH_ALL].(func(string) (map[string]string, error))(tableName)
+		if err == nil && records[key] == value {
+			err = getCurrentDB()[DELETE].(func(string, string) error)(tableName, key)
+			deleted = err == nil
+		} else if IsEmptyRecord(err) {
+			err = nil
+		}
+	}
+	dbMutex.Unlock()
+	if deleted {
+		notifyChange(Change{Type: ChangeDelete, Table: tableName, Key: key})
+	}
+	return deleted, err
+}
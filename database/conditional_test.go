@@ -0,0 +1,39 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConditionalWrites(t *testing.T) {
+	InitializeDatabase()
+	defer CloseDB()
+	DeleteAllRecords(CLOUD_INSTANCES_TABLE_NAME)
+	t.Run("InsertNew", func(t *testing.T) {
+		inserted, err := InsertNew("i1", `"host1"`, CLOUD_INSTANCES_TABLE_NAME)
+		assert.Nil(t, err)
+		assert.True(t, inserted)
+		inserted, err = InsertNew("i1", `"host2"`, CLOUD_INSTANCES_TABLE_NAME)
+		assert.Nil(t, err)
+		assert.False(t, inserted)
+		value, err := FetchRecord(CLOUD_INSTANCES_TABLE_NAME, "i1")
+		assert.Nil(t, err)
+		assert.Equal(t, `"host1"`, value)
+	})
+	t.Run("DeleteIf", func(t *testing.T) {
+		deleted, err := DeleteIf(CLOUD_INSTANCES_TABLE_NAME, "i1", `"host2"`)
+		assert.Nil(t, err)
+		assert.False(t, deleted)
+		deleted, err = DeleteIf(CLOUD_INSTANCES_TABLE_NAME, "i1", `"host1"`)
+		assert.Nil(t, err)
+		assert.True(t, deleted)
+		deleted, err = DeleteIf(CLOUD_INSTANCES_TABLE_NAME, "i1", `"host1"`)
+		assert.Nil(t, err)
+		assert.False(t, deleted)
+	})
+	t.Run("Encrypted", func(t *testing.T) {
+		_, err := DeleteIf(ENROLLMENT_KEYS_TABLE_NAME, "k1", `{}`)
+		assert.ErrorIs(t, err, ErrConditionalEncrypted)
+	})
+}
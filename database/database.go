@@ -1,18 +1,23 @@
 package database
 
 import (
+	"context"
 	"crypto/rand"
+	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/netclient/ncutils"
 	"github.com/gravitl/netmaker/servercfg"
+	"github.com/gravitl/netmaker/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/crypto/nacl/box"
+	"golang.org/x/exp/slog"
 )
 
 const (
@@ -61,6 +66,90 @@ const (
 	ENROLLMENT_KEYS_TABLE_NAME = "enrollmentkeys"
 	// HOST_ACTIONS_TABLE_NAME - table name for enrollmentkeys
 	HOST_ACTIONS_TABLE_NAME = "hostactions"
+	// TRAFFIC_USAGE_TABLE_NAME - table for daily traffic usage records
+	TRAFFIC_USAGE_TABLE_NAME = "trafficusage"
+	// TENANTS_TABLE_NAME - table for the organizations sharing the server
+	TENANTS_TABLE_NAME = "tenants"
+	// USAGE_SNAPSHOTS_TABLE_NAME - table for daily node, user and ext client counts of each network
+	USAGE_SNAPSHOTS_TABLE_NAME = "usagesnapshots"
+	// CLOUD_ENROLLMENT_TABLE_NAME - table for the cloud accounts allowed to enroll instances
+	CLOUD_ENROLLMENT_TABLE_NAME = "cloudenrollment"
+	// CLOUD_INSTANCES_TABLE_NAME - table mapping enrolled cloud instances to their hosts
+	CLOUD_INSTANCES_TABLE_NAME = "cloudinstances"
+	// CLOUD_ROUTES_TABLE_NAME - table for the cloud route tables programmed for each network
+	CLOUD_ROUTES_TABLE_NAME = "cloudroutes"
+	// EXTERNAL_DNS_PROVIDERS_TABLE_NAME - table for the external zones records are published to
+	EXTERNAL_DNS_PROVIDERS_TABLE_NAME = "externaldnsproviders"
+	// EXTERNAL_DNS_RECORDS_TABLE_NAME - table for the records published to external zones
+	EXTERNAL_DNS_RECORDS_TABLE_NAME = "externaldnsrecords"
+	// ENDPOINT_OVERRIDES_TABLE_NAME - table for the endpoints admins pinned between pairs of hosts
+	ENDPOINT_OVERRIDES_TABLE_NAME = "endpointoverrides"
+	// PROBES_TABLE_NAME - table for the synthetic probes nodes run
+	PROBES_TABLE_NAME = "probes"
+	// PROBE_RESULTS_TABLE_NAME - table for the recent results and alert state of each probe
+	PROBE_RESULTS_TABLE_NAME = "proberesults"
+	// AUDIT_LOGS_TABLE_NAME - table for the record of changes users made through the api
+	AUDIT_LOGS_TABLE_NAME = "auditlogs"
+	// NETWORK_EVENTS_TABLE_NAME - table for the joins, leaves, gateway, acl and failover changes of each network
+	NETWORK_EVENTS_TABLE_NAME = "networkevents"
+	// STATIC_PEERS_TABLE_NAME - table for wireguard devices peered with networks without netclient
+	STATIC_PEERS_TABLE_NAME = "staticpeers"
+	// NODE_STATUS_TABLE_NAME - table for the online/offline transitions of each node
+	NODE_STATUS_TABLE_NAME = "nodestatus"
+	// PUBLISH_QUEUE_TABLE_NAME - table for the peer updates waiting to reach the broker
+	PUBLISH_QUEUE_TABLE_NAME = "publishqueue"
+	// SCHEMA_MIGRATIONS_TABLE_NAME - table for the data migrations applied to the database
+	SCHEMA_MIGRATIONS_TABLE_NAME = "schemamigrations"
+	// MAINTENANCE_WINDOWS_TABLE_NAME - table for the periods disruptive changes are applied to the hosts of a network
+	MAINTENANCE_WINDOWS_TABLE_NAME = "maintenancewindows"
+	// PENDING_CHANGES_TABLE_NAME - table for the disruptive changes waiting for a maintenance window
+	PENDING_CHANGES_TABLE_NAME = "pendingchanges"
+	// APPROVAL_REQUESTS_TABLE_NAME - table for the sensitive operations waiting for a second admin to approve them
+	APPROVAL_REQUESTS_TABLE_NAME = "approvalrequests"
+	// TRASH_TABLE_NAME - table for the deleted networks, nodes and ext clients that can still be restored
+	TRASH_TABLE_NAME = "trash"
+	// EGRESS_HEALTH_TABLE_NAME - table for the health of the egress ranges that have health checks
+	EGRESS_HEALTH_TABLE_NAME = "egresshealth"
+	// EXTCLIENT_SESSIONS_TABLE_NAME - table for the connections of ext clients to their gateways
+	EXTCLIENT_SESSIONS_TABLE_NAME = "extclientsessions"
+	// SOURCE_POLICIES_TABLE_NAME - table for the sources the ext clients of users may connect from
+	SOURCE_POLICIES_TABLE_NAME = "sourcepolicies"
+	// REVOKED_KEYS_TABLE_NAME - table for the public keys of deleted ext clients and hosts
+	REVOKED_KEYS_TABLE_NAME = "revokedkeys"
+	// SECURITY_EVENTS_TABLE_NAME - table for the security alerts raised by the server
+	SECURITY_EVENTS_TABLE_NAME = "securityevents"
+	// PKI_CERTS_TABLE_NAME - table for the certificates issued by the internal CA, by serial
+	PKI_CERTS_TABLE_NAME = "pkicerts"
+	// API_ALLOWLISTS_TABLE_NAME - table for the source cidrs each user may use the management api from
+	API_ALLOWLISTS_TABLE_NAME = "apiallowlists"
+	// USER_DEVICES_TABLE_NAME - table for the source ips and user agents each user signed in from
+	USER_DEVICES_TABLE_NAME = "userdevices"
+	// USER_SECURITY_EVENTS_TABLE_NAME - table for the new device logins and ext client enrollments of users
+	USER_SECURITY_EVENTS_TABLE_NAME = "usersecurityevents"
+	// EMAIL_VERIFICATIONS_TABLE_NAME - table for the pending email verifications of users, by token hash
+	EMAIL_VERIFICATIONS_TABLE_NAME = "emailverifications"
+	// ENDPOINT_OBSERVATIONS_TABLE_NAME - table for the endpoints each node last handshaked with its peers through, by node
+	ENDPOINT_OBSERVATIONS_TABLE_NAME = "endpointobservations"
+	// SCHEDULED_REPORTS_TABLE_NAME - table for the reports sent on a schedule
+	SCHEDULED_REPORTS_TABLE_NAME = "scheduledreports"
+	// NOTIFICATION_CHANNELS_TABLE_NAME - table for the slack, teams, discord and webhook channels alerts are sent to
+	NOTIFICATION_CHANNELS_TABLE_NAME = "notificationchannels"
+	// HOST_RESOURCES_TABLE_NAME - table for the cpu, memory and wireguard interface samples hosts check in with, by host
+	HOST_RESOURCES_TABLE_NAME = "hostresources"
+	// NETWORK_LOCKDOWNS_TABLE_NAME - table for the networks locked down and what they were before, by network
+	NETWORK_LOCKDOWNS_TABLE_NAME = "networklockdowns"
+	// HOST_QUARANTINES_TABLE_NAME - table for the hosts quarantined as compromised, by host
+	HOST_QUARANTINES_TABLE_NAME = "hostquarantines"
+	// LEASES_TABLE_NAME - table for the leases servers sharing the database take on jobs and critical sections, by name
+	LEASES_TABLE_NAME = "leases"
+	// SIGNING_KEYS_TABLE_NAME - table for the keys the server signs the messages it publishes to hosts with, by key id
+	SIGNING_KEYS_TABLE_NAME = "signingkeys"
+	// HOST_DRIFT_TABLE_NAME - table for how the config hosts applied compares to the expected one, by host
+	HOST_DRIFT_TABLE_NAME = "hostdrift"
+	// ROLLOUTS_TABLE_NAME - table for the canary rollouts of risky network changes, by id
+	ROLLOUTS_TABLE_NAME = "rollouts"
+	// FLOWS_TABLE_NAME - table for the most recent flows each gateway exported, by node id
+	FLOWS_TABLE_NAME = "flows"
 
 	// == ERROR CONSTS ==
 	// NO_RECORD - no singular result found
@@ -83,10 +172,30 @@ const (
 	DELETE_ALL = "deleteall"
 	// FETCH_ALL - fetch table contents const
 	FETCH_ALL = "fetchall"
+	// FETCH_PAGE - fetch a page of table contents const
+	FETCH_PAGE = "fetchpage"
+	// FETCH_BY - fetch table contents matching a json field const
+	FETCH_BY = "fetchby"
 	// CLOSE_DB - graceful close of db const
 	CLOSE_DB = "closedb"
 	// isconnected
 	isConnected = "isconnected"
+	// WATCH - stream table changes const
+	WATCH = "watch"
+	// REVISION - latest store wide revision const
+	REVISION = "revision"
+	// COMMIT_TX - apply several writes atomically const
+	COMMIT_TX = "committx"
+	// ACQUIRE_LEASE - take or renew a lease atomically const
+	ACQUIRE_LEASE = "acquirelease"
+	// RENEW_LEASE - extend a lease atomically while the same hold owns it const
+	RENEW_LEASE = "renewlease"
+	// RELEASE_LEASE - give up a lease const
+	RELEASE_LEASE = "releaselease"
+	// INSERT_NEW - insert a record unless the key exists const
+	INSERT_NEW = "insertnew"
+	// DELETE_IF - delete a record holding a value const
+	DELETE_IF = "deleteif"
 )
 
 var dbMutex sync.RWMutex
@@ -99,18 +208,23 @@ func getCurrentDB() map[string]interface{} {
 		return SQLITE_FUNCTIONS
 	case "postgres":
 		return PG_FUNCTIONS
+	case "etcd":
+		return ETCD_FUNCTIONS
 	default:
+		if functions, ok := getRegisteredStore(servercfg.GetDB()); ok {
+			return functions
+		}
 		return SQLITE_FUNCTIONS
 	}
 }
 
 // InitializeDatabase - initializes database
 func InitializeDatabase() error {
-	logger.Log(0, "connecting to", servercfg.GetDB())
+	slog.Info("connecting", "get_db", servercfg.GetDB())
 	tperiod := time.Now().Add(10 * time.Second)
 	for {
 		if err := getCurrentDB()[INIT_DB].(func() error)(); err != nil {
-			logger.Log(0, "unable to connect to db, retrying . . .")
+			slog.Error("unable to connect to db, retrying . . .")
 			if time.Now().After(tperiod) {
 				return err
 			}
@@ -120,30 +234,120 @@ func InitializeDatabase() error {
 		time.Sleep(2 * time.Second)
 	}
 	createTables()
+	if servercfg.GetDB() == "postgres" {
+		if err := pgMigrate(); err != nil {
+			return err
+		}
+	}
 	return initializeUUID()
 }
 
+// CheckConnection - connects to the configured database once, without retrying or creating tables,
+// to validate the config before starting
+func CheckConnection() error {
+	switch servercfg.GetDB() {
+	case "rqlite", "sqlite", "postgres", "etcd":
+	default:
+		if _, ok := getRegisteredStore(servercfg.GetDB()); !ok {
+			return fmt.Errorf("unknown database %q", servercfg.GetDB())
+		}
+	}
+	if err := getCurrentDB()[INIT_DB].(func() error)(); err != nil {
+		return err
+	}
+	defer CloseDB()
+	// sql stores only connect once used
+	switch servercfg.GetDB() {
+	case "sqlite":
+		if err := SqliteDB.Ping(); err != nil {
+			return err
+		}
+	case "postgres":
+		if err := PGDB.Ping(); err != nil {
+			return err
+		}
+	}
+	if !IsConnected() {
+		return errors.New("database is not responding")
+	}
+	return nil
+}
+
+// tables - every table of the server, created on startup
+var tables = []string{
+	NETWORKS_TABLE_NAME,
+	NODES_TABLE_NAME,
+	CERTS_TABLE_NAME,
+	DELETED_NODES_TABLE_NAME,
+	USERS_TABLE_NAME,
+	DNS_TABLE_NAME,
+	EXT_CLIENT_TABLE_NAME,
+	PEERS_TABLE_NAME,
+	SERVERCONF_TABLE_NAME,
+	SERVER_UUID_TABLE_NAME,
+	GENERATED_TABLE_NAME,
+	NODE_ACLS_TABLE_NAME,
+	SSO_STATE_CACHE,
+	METRICS_TABLE_NAME,
+	NETWORK_USER_TABLE_NAME,
+	USER_GROUPS_TABLE_NAME,
+	CACHE_TABLE_NAME,
+	HOSTS_TABLE_NAME,
+	ENROLLMENT_KEYS_TABLE_NAME,
+	HOST_ACTIONS_TABLE_NAME,
+	TRAFFIC_USAGE_TABLE_NAME,
+	TENANTS_TABLE_NAME,
+	USAGE_SNAPSHOTS_TABLE_NAME,
+	CLOUD_ENROLLMENT_TABLE_NAME,
+	CLOUD_INSTANCES_TABLE_NAME,
+	CLOUD_ROUTES_TABLE_NAME,
+	EXTERNAL_DNS_PROVIDERS_TABLE_NAME,
+	EXTERNAL_DNS_RECORDS_TABLE_NAME,
+	ENDPOINT_OVERRIDES_TABLE_NAME,
+	PROBES_TABLE_NAME,
+	PROBE_RESULTS_TABLE_NAME,
+	AUDIT_LOGS_TABLE_NAME,
+	NETWORK_EVENTS_TABLE_NAME,
+	STATIC_PEERS_TABLE_NAME,
+	NODE_STATUS_TABLE_NAME,
+	PUBLISH_QUEUE_TABLE_NAME,
+	SCHEMA_MIGRATIONS_TABLE_NAME,
+	MAINTENANCE_WINDOWS_TABLE_NAME,
+	PENDING_CHANGES_TABLE_NAME,
+	APPROVAL_REQUESTS_TABLE_NAME,
+	TRASH_TABLE_NAME,
+	EGRESS_HEALTH_TABLE_NAME,
+	EXTCLIENT_SESSIONS_TABLE_NAME,
+	SOURCE_POLICIES_TABLE_NAME,
+	REVOKED_KEYS_TABLE_NAME,
+	SECURITY_EVENTS_TABLE_NAME,
+	PKI_CERTS_TABLE_NAME,
+	API_ALLOWLISTS_TABLE_NAME,
+	USER_DEVICES_TABLE_NAME,
+	USER_SECURITY_EVENTS_TABLE_NAME,
+	EMAIL_VERIFICATIONS_TABLE_NAME,
+	ENDPOINT_OBSERVATIONS_TABLE_NAME,
+	SCHEDULED_REPORTS_TABLE_NAME,
+	NOTIFICATION_CHANNELS_TABLE_NAME,
+	HOST_RESOURCES_TABLE_NAME,
+	NETWORK_LOCKDOWNS_TABLE_NAME,
+	HOST_QUARANTINES_TABLE_NAME,
+	LEASES_TABLE_NAME,
+	SIGNING_KEYS_TABLE_NAME,
+	HOST_DRIFT_TABLE_NAME,
+	ROLLOUTS_TABLE_NAME,
+	FLOWS_TABLE_NAME,
+}
+
+// Tables - returns the names of every table of the server
+func Tables() []string {
+	return append([]string{}, tables...)
+}
+
 func createTables() {
-	createTable(NETWORKS_TABLE_NAME)
-	createTable(NODES_TABLE_NAME)
-	createTable(CERTS_TABLE_NAME)
-	createTable(DELETED_NODES_TABLE_NAME)
-	createTable(USERS_TABLE_NAME)
-	createTable(DNS_TABLE_NAME)
-	createTable(EXT_CLIENT_TABLE_NAME)
-	createTable(PEERS_TABLE_NAME)
-	createTable(SERVERCONF_TABLE_NAME)
-	createTable(SERVER_UUID_TABLE_NAME)
-	createTable(GENERATED_TABLE_NAME)
-	createTable(NODE_ACLS_TABLE_NAME)
-	createTable(SSO_STATE_CACHE)
-	createTable(METRICS_TABLE_NAME)
-	createTable(NETWORK_USER_TABLE_NAME)
-	createTable(USER_GROUPS_TABLE_NAME)
-	createTable(CACHE_TABLE_NAME)
-	createTable(HOSTS_TABLE_NAME)
-	createTable(ENROLLMENT_KEYS_TABLE_NAME)
-	createTable(HOST_ACTIONS_TABLE_NAME)
+	for _, table := range tables {
+		createTable(table)
+	}
 }
 
 func createTable(tableName string) error {
@@ -159,13 +363,29 @@ func IsJSONString(value string) bool {
 
 // Insert - inserts object into db
 func Insert(key string, value string, tableName string) error {
-	dbMutex.Lock()
-	defer dbMutex.Unlock()
-	if key != "" && value != "" && IsJSONString(value) {
-		return getCurrentDB()[INSERT].(func(string, string, string) error)(key, value, tableName)
-	} else {
+	return InsertCtx(context.Background(), key, value, tableName)
+}
+
+// InsertCtx - inserts object into db, tracing it as part of ctx
+func InsertCtx(ctx context.Context, key string, value string, tableName string) error {
+	_, span := tracing.Start(ctx, "database.Insert", attribute.String("db.table", tableName))
+	defer span.End()
+	if key == "" || value == "" || !IsJSONString(value) {
 		return errors.New("invalid insert " + key + " : " + value)
 	}
+	sealed, err := sealRecord(tableName, value)
+	if err != nil {
+		return err
+	}
+	changeMutex.Lock()
+	defer changeMutex.Unlock()
+	dbMutex.Lock()
+	err = getCurrentDB()[INSERT].(func(string, string, string) error)(key, sealed, tableName)
+	dbMutex.Unlock()
+	if err == nil {
+		notifyChange(Change{Type: ChangePut, Table: tableName, Key: key, Value: value})
+	}
+	return err
 }
 
 // InsertPeer - inserts peer into db
@@ -181,29 +401,51 @@ func InsertPeer(key string, value string) error {
 
 // DeleteRecord - deletes a record from db
 func DeleteRecord(tableName string, key string) error {
+	return DeleteRecordCtx(context.Background(), tableName, key)
+}
+
+// DeleteRecordCtx - deletes a record from db, tracing it as part of ctx
+func DeleteRecordCtx(ctx context.Context, tableName string, key string) error {
+	_, span := tracing.Start(ctx, "database.DeleteRecord", attribute.String("db.table", tableName))
+	defer span.End()
+	changeMutex.Lock()
+	defer changeMutex.Unlock()
 	dbMutex.Lock()
-	defer dbMutex.Unlock()
-	return getCurrentDB()[DELETE].(func(string, string) error)(tableName, key)
+	err := getCurrentDB()[DELETE].(func(string, string) error)(tableName, key)
+	dbMutex.Unlock()
+	if err == nil {
+		notifyChange(Change{Type: ChangeDelete, Table: tableName, Key: key})
+	}
+	return err
 }
 
 // DeleteAllRecords - removes a table and remakes
 func DeleteAllRecords(tableName string) error {
+	changeMutex.Lock()
+	defer changeMutex.Unlock()
 	dbMutex.Lock()
-	defer dbMutex.Unlock()
 	err := getCurrentDB()[DELETE_ALL].(func(string) error)(tableName)
 	if err != nil {
+		dbMutex.Unlock()
 		return err
 	}
 	err = createTable(tableName)
-	if err != nil {
-		return err
-	}
-	return nil
+	dbMutex.Unlock()
+	// an empty key stands for every record of the table
+	notifyChange(Change{Type: ChangeDelete, Table: tableName})
+	return err
 }
 
 // FetchRecord - fetches a record
 func FetchRecord(tableName string, key string) (string, error) {
-	results, err := FetchRecords(tableName)
+	return FetchRecordCtx(context.Background(), tableName, key)
+}
+
+// FetchRecordCtx - fetches a record, tracing it as part of ctx
+func FetchRecordCtx(ctx context.Context, tableName string, key string) (string, error) {
+	ctx, span := tracing.Start(ctx, "database.FetchRecord", attribute.String("db.table", tableName))
+	defer span.End()
+	results, err := FetchRecordsCtx(ctx, tableName)
 	if err != nil {
 		return "", err
 	}
@@ -215,9 +457,38 @@ func FetchRecord(tableName string, key string) (string, error) {
 
 // FetchRecords - fetches all records in given table
 func FetchRecords(tableName string) (map[string]string, error) {
+	return FetchRecordsCtx(context.Background(), tableName)
+}
+
+// FetchRecordsCtx - fetches all records in given table, tracing it as part of ctx
+func FetchRecordsCtx(ctx context.Context, tableName string) (map[string]string, error) {
+	_, span := tracing.Start(ctx, "database.FetchRecords", attribute.String("db.table", tableName))
+	defer span.End()
+	dbMutex.RLock()
+	defer dbMutex.RUnlock()
+	records, err := getCurrentDB()[FETCH_ALL].(func(string) (map[string]string, error))(tableName)
+	return openRecords(tableName, records, err)
+}
+
+// FetchRecordsPage - fetches up to limit records of a table ordered by key, skipping the first offset records
+func FetchRecordsPage(tableName string, offset, limit int) (map[string]string, error) {
+	_, span := tracing.Start(context.Background(), "database.FetchRecordsPage", attribute.String("db.table", tableName))
+	defer span.End()
 	dbMutex.RLock()
 	defer dbMutex.RUnlock()
-	return getCurrentDB()[FETCH_ALL].(func(string) (map[string]string, error))(tableName)
+	records, err := getCurrentDB()[FETCH_PAGE].(func(string, int, int) (map[string]string, error))(tableName, offset, limit)
+	return openRecords(tableName, records, err)
+}
+
+// FetchRecordsByField - fetches all records of a table whose json value has the given field value,
+// using an index where the database has one
+func FetchRecordsByField(tableName, field, value string) (map[string]string, error) {
+	_, span := tracing.Start(context.Background(), "database.FetchRecordsByField", attribute.String("db.table", tableName))
+	defer span.End()
+	dbMutex.RLock()
+	defer dbMutex.RUnlock()
+	records, err := getCurrentDB()[FETCH_BY].(func(string, string, string) (map[string]string, error))(tableName, field, value)
+	return openRecords(tableName, records, err)
 }
 
 // initializeUUID - create a UUID record for server if none exists
@@ -267,3 +538,19 @@ func CloseDB() {
 func IsConnected() bool {
 	return getCurrentDB()[isConnected].(func() bool)()
 }
+
+// scanRecords - reads key/value rows into a map, closing the rows
+func scanRecords(row *sql.Rows) (map[string]string, error) {
+	records := make(map[string]string)
+	defer row.Close()
+	for row.Next() { // Iterate and fetch the records from result cursor
+		var key string
+		var value string
+		row.Scan(&key, &value)
+		records[key] = value
+	}
+	if len(records) == 0 {
+		return nil, errors.New(NO_RECORDS)
+	}
+	return records, nil
+}
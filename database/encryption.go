@@ -0,0 +1,154 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// EncryptedPrefix - prefix of field values encrypted by a FieldCipher
+const EncryptedPrefix = "enc:"
+
+// FieldCipher - encrypts and decrypts the sensitive fields of records at rest
+type FieldCipher interface {
+	Encrypt(plaintext []byte) (string, error)
+	Decrypt(ciphertext string) ([]byte, error)
+}
+
+// encryptedFields - json fields of each table that are encrypted at rest
+var encryptedFields = map[string][]string{
+	EXT_CLIENT_TABLE_NAME:             {"privatekey"},
+	SERVER_UUID_TABLE_NAME:            {"traffickeypriv"},
+	SERVERCONF_TABLE_NAME:             {"privatekey", "smtp", "oauth"},
+	ENROLLMENT_KEYS_TABLE_NAME:        {"value", "token"},
+	CERTS_TABLE_NAME:                  {"private_key", "key"},
+	CLOUD_ROUTES_TABLE_NAME:           {"credentials"},
+	EXTERNAL_DNS_PROVIDERS_TABLE_NAME: {"credentials"},
+	SIGNING_KEYS_TABLE_NAME:           {"private_key"},
+}
+
+var (
+	cipherMutex sync.RWMutex
+	fieldCipher FieldCipher
+
+	errNoFieldCipher = errors.New("record is encrypted but no encryption key is configured")
+)
+
+// SetFieldCipher - sets the cipher used to encrypt sensitive fields, nil stores them in plain text
+func SetFieldCipher(c FieldCipher) {
+	cipherMutex.Lock()
+	defer cipherMutex.Unlock()
+	fieldCipher = c
+}
+
+// EncryptedTables - returns the tables that have fields encrypted at rest
+func EncryptedTables() []string {
+	tables := make([]string, 0, len(encryptedFields))
+	for table := range encryptedFields {
+		tables = append(tables, table)
+	}
+	return tables
+}
+
+// == private ==
+
+func getFieldCipher() FieldCipher {
+	cipherMutex.RLock()
+	defer cipherMutex.RUnlock()
+	return fieldCipher
+}
+
+// sealRecord - encrypts the sensitive fields of a record before it is written
+func sealRecord(tableName, value string) (string, error) {
+	fields, ok := encryptedFields[tableName]
+	c := getFieldCipher()
+	if !ok || c == nil {
+		return value, nil
+	}
+	var record map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(value), &record); err != nil {
+		// not an object, eg. a bare string, so there are no fields to encrypt
+		return value, nil
+	}
+	changed := false
+	for _, field := range fields {
+		raw, ok := record[field]
+		if !ok || isEmptyJSON(raw) || isSealed(raw) {
+			continue
+		}
+		sealed, err := c.Encrypt(raw)
+		if err != nil {
+			return "", err
+		}
+		if record[field], err = json.Marshal(sealed); err != nil {
+			return "", err
+		}
+		changed = true
+	}
+	if !changed {
+		return value, nil
+	}
+	data, err := json.Marshal(record)
+	return string(data), err
+}
+
+// openRecord - decrypts the sensitive fields of a record after it is read
+func openRecord(tableName, value string) (string, error) {
+	fields, ok := encryptedFields[tableName]
+	if !ok || !strings.Contains(value, EncryptedPrefix) {
+		return value, nil
+	}
+	var record map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(value), &record); err != nil {
+		return value, nil
+	}
+	changed := false
+	for _, field := range fields {
+		raw, ok := record[field]
+		if !ok || !isSealed(raw) {
+			continue
+		}
+		c := getFieldCipher()
+		if c == nil {
+			return "", errNoFieldCipher
+		}
+		var sealed string
+		if err := json.Unmarshal(raw, &sealed); err != nil {
+			return "", err
+		}
+		plain, err := c.Decrypt(sealed)
+		if err != nil {
+			return "", err
+		}
+		record[field] = plain
+		changed = true
+	}
+	if !changed {
+		return value, nil
+	}
+	data, err := json.Marshal(record)
+	return string(data), err
+}
+
+// openRecords - decrypts the sensitive fields of all fetched records
+func openRecords(tableName string, records map[string]string, err error) (map[string]string, error) {
+	if err != nil || len(encryptedFields[tableName]) == 0 {
+		return records, err
+	}
+	for key, value := range records {
+		if records[key], err = openRecord(tableName, value); err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+func isSealed(raw json.RawMessage) bool {
+	return strings.HasPrefix(string(raw), "\""+EncryptedPrefix)
+}
+
+func isEmptyJSON(raw json.RawMessage) bool {
+	s := string(raw)
+	return s == "null" || s == "\"\""
+}
@@ -0,0 +1,514 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gravitl/netmaker/servercfg"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// etcdPrefix - all records live under /netmaker/<table>/<key>
+const etcdPrefix = "/netmaker/"
+
+// etcdIndexPrefix - the indexes of the fields records are fetched by live under
+// /netmaker-index/<table>/<field>/<value>/<key>, with an empty value
+const etcdIndexPrefix = "/netmaker-index/"
+
+// etcdIndexVersionKey - marks the indexes as built for the records written before they existed
+const etcdIndexVersionKey = etcdIndexPrefix + "version"
+
+const (
+	etcdTimeout = 10 * time.Second
+	// etcdTxnRetries - attempts at a write whose record changed while its indexes were worked out
+	etcdTxnRetries = 5
+	// etcdTxnBatch - records read per transaction, below the default limit of 128 operations
+	etcdTxnBatch = 100
+)
+
+// etcdIndexedFields - the json fields indexed per table, the ones the servers fetch records by
+var etcdIndexedFields = map[string][]string{
+	NODES_TABLE_NAME:          {"hostid", "network"},
+	EXT_CLIENT_TABLE_NAME:     {"network", "ingressgatewayid", "ownerid"},
+	NETWORK_EVENTS_TABLE_NAME: {"network"},
+}
+
+// ETCD_FUNCTIONS - map of db functions for etcd
+var ETCD_FUNCTIONS = storeFunctions(&etcdStore{})
+
+// etcdStore - stores every table as a key prefix in etcd, so the server keeps no local state
+type etcdStore struct {
+	client *clientv3.Client
+}
+
+func (e *etcdStore) Init() error {
+	username, password := servercfg.GetEtcdCredentials()
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   servercfg.GetEtcdEndpoints(),
+		Username:    username,
+		Password:    password,
+		DialTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), etcdTimeout)
+	defer cancel()
+	if _, err = client.Get(ctx, etcdPrefix, clientv3.WithCountOnly()); err != nil {
+		client.Close()
+		return err
+	}
+	e.client = client
+	return e.buildIndexes()
+}
+
+// CreateTable - tables are key prefixes and don't need to be created
+func (e *etcdStore) CreateTable(tableName string) error {
+	return nil
+}
+
+func (e *etcdStore) Insert(key, value, tableName string) error {
+	if key == "" || value == "" || !IsJSONString(value) {
+		return errors.New("invalid insert " + key + " : " + value)
+	}
+	return e.Commit([]TxOp{{Table: tableName, Key: key, Value: value}})
+}
+
+func (e *etcdStore) Delete(tableName, key string) error {
+	return e.Commit([]TxOp{{Table: tableName, Key: key, Delete: true}})
+}
+
+func (e *etcdStore) DeleteAll(tableName string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdTimeout)
+	defer cancel()
+	_, err := e.client.Txn(ctx).Then(
+		clientv3.OpDelete(etcdKey(tableName, ""), clientv3.WithPrefix()),
+		clientv3.OpDelete(etcdIndexPrefix+tableName+"/", clientv3.WithPrefix()),
+	).Commit()
+	return err
+}
+
+func (e *etcdStore) FetchAll(tableName string) (map[string]string, error) {
+	kvs, err := e.fetch(tableName)
+	if err != nil {
+		return nil, err
+	}
+	return etcdRecords(tableName, kvs, func(string) bool { return true })
+}
+
+// FetchPage - reads only the keys before the page and the records on it
+func (e *etcdStore) FetchPage(tableName string, offset, limit int) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdTimeout)
+	defer cancel()
+	prefix := etcdKey(tableName, "")
+	from := prefix
+	if offset > 0 {
+		skipped, err := e.client.Get(ctx, prefix, clientv3.WithPrefix(), clientv3.WithKeysOnly(),
+			clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend), clientv3.WithLimit(int64(offset)))
+		if err != nil {
+			return nil, err
+		}
+		if len(skipped.Kvs) < offset {
+			return nil, errors.New(NO_RECORDS)
+		}
+		from = string(skipped.Kvs[len(skipped.Kvs)-1].Key) + "\x00"
+	}
+	if limit <= 0 {
+		return nil, errors.New(NO_RECORDS)
+	}
+	resp, err := e.client.Get(ctx, from, clientv3.WithRange(clientv3.GetPrefixRangeEnd(prefix)),
+		clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend), clientv3.WithLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	return etcdRecords(tableName, resp.Kvs, func(string) bool { return true })
+}
+
+// FetchBy - looks up the keys in the field's index when it has one, only reading the whole table for other fields,
+// the records are checked again as an index entry can be left behind by a write that raced the index build
+func (e *etcdStore) FetchBy(tableName, field, value string) (map[string]string, error) {
+	matches := func(record string) bool {
+		current, ok := etcdFieldValue(record, field)
+		return ok && current == value
+	}
+	if !etcdIsIndexed(tableName, field) {
+		kvs, err := e.fetch(tableName)
+		if err != nil {
+			return nil, err
+		}
+		return etcdRecords(tableName, kvs, matches)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), etcdTimeout)
+	defer cancel()
+	prefix := etcdIndexKey(tableName, field, value, "")
+	index, err := e.client.Get(ctx, prefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return nil, err
+	}
+	kvs := []*mvccpb.KeyValue{}
+	for start := 0; start < len(index.Kvs); start += etcdTxnBatch {
+		end := start + etcdTxnBatch
+		if end > len(index.Kvs) {
+			end = len(index.Kvs)
+		}
+		gets := make([]clientv3.Op, 0, end-start)
+		for _, kv := range index.Kvs[start:end] {
+			gets = append(gets, clientv3.OpGet(etcdKey(tableName, strings.TrimPrefix(string(kv.Key), prefix))))
+		}
+		txn, err := e.client.Txn(ctx).Then(gets...).Commit()
+		if err != nil {
+			return nil, err
+		}
+		for _, resp := range txn.Responses {
+			kvs = append(kvs, resp.GetResponseRange().Kvs...)
+		}
+	}
+	return etcdRecords(tableName, kvs, matches)
+}
+
+func (e *etcdStore) Close() {
+	if e.client != nil {
+		e.client.Close()
+	}
+}
+
+func (e *etcdStore) IsConnected() bool {
+	if e.client == nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), etcdTimeout)
+	defer cancel()
+	_, err := e.client.Get(ctx, etcdPrefix, clientv3.WithCountOnly())
+	return err == nil
+}
+
+// Commit - applies all ops and their index changes in a single etcd transaction, which only goes through
+// if none of the indexed records changed since they were read
+func (e *etcdStore) Commit(ops []TxOp) error {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdTimeout)
+	defer cancel()
+	for attempt := 0; attempt < etcdTxnRetries; attempt++ {
+		var conditions []clientv3.Cmp
+		var txnOps []clientv3.Op
+		for _, op := range ops {
+			key := etcdKey(op.Table, op.Key)
+			if op.Delete {
+				txnOps = append(txnOps, clientv3.OpDelete(key))
+			} else {
+				txnOps = append(txnOps, clientv3.OpPut(key, op.Value))
+			}
+			if len(etcdIndexedFields[op.Table]) == 0 {
+				continue
+			}
+			resp, err := e.client.Get(ctx, key)
+			if err != nil {
+				return err
+			}
+			previous, revision := "", int64(0)
+			if len(resp.Kvs) > 0 {
+				previous, revision = string(resp.Kvs[0].Value), resp.Kvs[0].ModRevision
+			}
+			conditions = append(conditions, clientv3.Compare(clientv3.ModRevision(key), "=", revision))
+			next := ""
+			if !op.Delete {
+				next = op.Value
+			}
+			puts, deletes := etcdIndexChanges(op.Table, op.Key, previous, next)
+			for _, indexKey := range puts {
+				txnOps = append(txnOps, clientv3.OpPut(indexKey, ""))
+			}
+			for _, indexKey := range deletes {
+				txnOps = append(txnOps, clientv3.OpDelete(indexKey))
+			}
+		}
+		txn, err := e.client.Txn(ctx).If(conditions...).Then(txnOps...).Commit()
+		if err != nil {
+			return err
+		}
+		if txn.Succeeded {
+			return nil
+		}
+	}
+	return errors.New("records kept changing during the transaction")
+}
+
+// InsertNew - puts the record and its index entries only if the key was never created, or was deleted since
+func (e *etcdStore) InsertNew(key, value, tableName string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdTimeout)
+	defer cancel()
+	recordKey := etcdKey(tableName, key)
+	txnOps := []clientv3.Op{clientv3.OpPut(recordKey, value)}
+	puts, _ := etcdIndexChanges(tableName, key, "", value)
+	for _, indexKey := range puts {
+		txnOps = append(txnOps, clientv3.OpPut(indexKey, ""))
+	}
+	txn, err := e.client.Txn(ctx).If(clientv3.Compare(clientv3.CreateRevision(recordKey), "=", 0)).Then(txnOps...).Commit()
+	if err != nil {
+		return false, err
+	}
+	return txn.Succeeded, nil
+}
+
+// DeleteIf - removes the record and its index entries unless it changed since it was read holding value
+func (e *etcdStore) DeleteIf(tableName, key, value string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdTimeout)
+	defer cancel()
+	recordKey := etcdKey(tableName, key)
+	resp, err := e.client.Get(ctx, recordKey)
+	if err != nil || len(resp.Kvs) == 0 || string(resp.Kvs[0].Value) != value {
+		return false, err
+	}
+	txnOps := []clientv3.Op{clientv3.OpDelete(recordKey)}
+	_, deletes := etcdIndexChanges(tableName, key, value, "")
+	for _, indexKey := range deletes {
+		txnOps = append(txnOps, clientv3.OpDelete(indexKey))
+	}
+	txn, err := e.client.Txn(ctx).If(clientv3.Compare(clientv3.ModRevision(recordKey), "=", resp.Kvs[0].ModRevision)).Then(txnOps...).Commit()
+	if err != nil {
+		return false, err
+	}
+	return txn.Succeeded, nil
+}
+
+// AcquireLease - compares the revision the lease was read at so two servers can't both take it
+func (e *etcdStore) AcquireLease(name, value, owner string, now int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdTimeout)
+	defer cancel()
+	key := etcdKey(LEASES_TABLE_NAME, name)
+	resp, err := e.client.Get(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	unchanged := clientv3.Compare(clientv3.CreateRevision(key), "=", 0)
+	if len(resp.Kvs) > 0 {
+		var lease Lease
+		if err := json.Unmarshal(resp.Kvs[0].Value, &lease); err == nil && lease.Owner != owner && lease.Expires >= now {
+			return false, nil
+		}
+		unchanged = clientv3.Compare(clientv3.ModRevision(key), "=", resp.Kvs[0].ModRevision)
+	}
+	txn, err := e.client.Txn(ctx).If(unchanged).Then(clientv3.OpPut(key, value)).Commit()
+	if err != nil {
+		return false, err
+	}
+	return txn.Succeeded, nil
+}
+
+// RenewLease - compares the revision the lease was read at so a hold taken over in between isn't renewed
+func (e *etcdStore) RenewLease(name, value, owner string, token int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdTimeout)
+	defer cancel()
+	key := etcdKey(LEASES_TABLE_NAME, name)
+	resp, err := e.client.Get(ctx, key)
+	if err != nil || len(resp.Kvs) == 0 {
+		return false, err
+	}
+	var lease Lease
+	if err := json.Unmarshal(resp.Kvs[0].Value, &lease); err != nil || lease.Owner != owner || lease.Token != token {
+		return false, nil
+	}
+	txn, err := e.client.Txn(ctx).If(clientv3.Compare(clientv3.ModRevision(key), "=", resp.Kvs[0].ModRevision)).Then(clientv3.OpPut(key, value)).Commit()
+	if err != nil {
+		return false, err
+	}
+	return txn.Succeeded, nil
+}
+
+// ReleaseLease - removes the lease unless another server took it since it was read
+func (e *etcdStore) ReleaseLease(name, owner string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdTimeout)
+	defer cancel()
+	key := etcdKey(LEASES_TABLE_NAME, name)
+	resp, err := e.client.Get(ctx, key)
+	if err != nil || len(resp.Kvs) == 0 {
+		return err
+	}
+	var lease Lease
+	if err := json.Unmarshal(resp.Kvs[0].Value, &lease); err != nil || lease.Owner != owner {
+		return err
+	}
+	_, err = e.client.Txn(ctx).If(clientv3.Compare(clientv3.ModRevision(key), "=", resp.Kvs[0].ModRevision)).Then(clientv3.OpDelete(key)).Commit()
+	return err
+}
+
+// Revision - returns the revision of the cluster, which every write moves on
+func (e *etcdStore) Revision(ctx context.Context) (int64, error) {
+	if e.client == nil {
+		return 0, errors.New("etcd not connected")
+	}
+	ctx, cancel := context.WithTimeout(ctx, etcdTimeout)
+	defer cancel()
+	resp, err := e.client.Get(ctx, etcdIndexVersionKey, clientv3.WithCountOnly())
+	if err != nil {
+		return 0, err
+	}
+	return resp.Header.Revision, nil
+}
+
+// Watch - streams the writes and deletes made to the tables by any server after the revision until ctx is done,
+// through a single watch so changes to different tables arrive in revision order
+func (e *etcdStore) Watch(ctx context.Context, after int64, tableNames ...string) (<-chan Change, error) {
+	if e.client == nil {
+		return nil, errors.New("etcd not connected")
+	}
+	watched := make(map[string]bool, len(tableNames))
+	for _, tableName := range tableNames {
+		watched[tableName] = true
+	}
+	options := []clientv3.OpOption{clientv3.WithPrefix()}
+	if after > 0 {
+		options = append(options, clientv3.WithRev(after+1))
+	}
+	changes := make(chan Change)
+	events := e.client.Watch(clientv3.WithRequireLeader(ctx), etcdPrefix, options...)
+	go func() {
+		defer close(changes)
+		for resp := range events {
+			if resp.Err() != nil {
+				return
+			}
+			for _, ev := range resp.Events {
+				tableName, key, ok := strings.Cut(strings.TrimPrefix(string(ev.Kv.Key), etcdPrefix), "/")
+				if !ok || !watched[tableName] {
+					continue
+				}
+				change := Change{
+					Type:     ChangePut,
+					Table:    tableName,
+					Key:      key,
+					Value:    string(ev.Kv.Value),
+					Revision: ev.Kv.ModRevision,
+				}
+				if ev.Type == clientv3.EventTypeDelete {
+					change.Type = ChangeDelete
+				}
+				select {
+				case changes <- change:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return changes, nil
+}
+
+// == private ==
+
+func (e *etcdStore) fetch(tableName string) ([]*mvccpb.KeyValue, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdTimeout)
+	defer cancel()
+	resp, err := e.client.Get(ctx, etcdKey(tableName, ""), clientv3.WithPrefix(), clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend))
+	if err != nil {
+		return nil, err
+	}
+	return resp.Kvs, nil
+}
+
+// buildIndexes - indexes the records written before the indexes existed, once for the cluster
+func (e *etcdStore) buildIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdTimeout)
+	defer cancel()
+	resp, err := e.client.Get(ctx, etcdIndexVersionKey, clientv3.WithCountOnly())
+	if err != nil || resp.Count > 0 {
+		return err
+	}
+	for tableName := range etcdIndexedFields {
+		kvs, err := e.fetch(tableName)
+		if err != nil {
+			return err
+		}
+		prefix := etcdKey(tableName, "")
+		var puts []clientv3.Op
+		for _, kv := range kvs {
+			indexKeys, _ := etcdIndexChanges(tableName, strings.TrimPrefix(string(kv.Key), prefix), "", string(kv.Value))
+			for _, indexKey := range indexKeys {
+				puts = append(puts, clientv3.OpPut(indexKey, ""))
+			}
+		}
+		for start := 0; start < len(puts); start += etcdTxnBatch {
+			end := start + etcdTxnBatch
+			if end > len(puts) {
+				end = len(puts)
+			}
+			if _, err := e.client.Txn(ctx).Then(puts[start:end]...).Commit(); err != nil {
+				return err
+			}
+		}
+	}
+	_, err = e.client.Put(ctx, etcdIndexVersionKey, "1")
+	return err
+}
+
+func etcdKey(tableName, key string) string {
+	return etcdPrefix + tableName + "/" + key
+}
+
+// etcdIndexKey - the index entry of a record, or the prefix of all records with the value when key is empty
+func etcdIndexKey(tableName, field, value, key string) string {
+	return etcdIndexPrefix + tableName + "/" + field + "/" + url.PathEscape(value) + "/" + key
+}
+
+func etcdIsIndexed(tableName, field string) bool {
+	for _, indexed := range etcdIndexedFields[tableName] {
+		if indexed == field {
+			return true
+		}
+	}
+	return false
+}
+
+// etcdFieldValue - the value of a json field of a record as a string, like postgres' ->> operator
+func etcdFieldValue(record, field string) (string, bool) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(record), &fields); err != nil {
+		return "", false
+	}
+	current, ok := fields[field]
+	if !ok || current == nil {
+		return "", false
+	}
+	return fmt.Sprint(current), true
+}
+
+// etcdIndexChanges - the index entries to add and remove when a record changes from previous to next,
+// an empty record has none and, as with the postgres columns, empty field values aren't indexed
+func etcdIndexChanges(tableName, key, previous, next string) (puts, deletes []string) {
+	for _, field := range etcdIndexedFields[tableName] {
+		before, hadBefore := etcdFieldValue(previous, field)
+		after, hasAfter := etcdFieldValue(next, field)
+		hadBefore = hadBefore && before != ""
+		hasAfter = hasAfter && after != ""
+		if hadBefore && hasAfter && before == after {
+			continue
+		}
+		if hadBefore {
+			deletes = append(deletes, etcdIndexKey(tableName, field, before, key))
+		}
+		if hasAfter {
+			puts = append(puts, etcdIndexKey(tableName, field, after, key))
+		}
+	}
+	return puts, deletes
+}
+
+// etcdRecords - converts key values of a table to records, keeping those matching filter
+func etcdRecords(tableName string, kvs []*mvccpb.KeyValue, filter func(string) bool) (map[string]string, error) {
+	records := make(map[string]string)
+	prefix := etcdKey(tableName, "")
+	for _, kv := range kvs {
+		if value := string(kv.Value); filter(value) {
+			records[strings.TrimPrefix(string(kv.Key), prefix)] = value
+		}
+	}
+	if len(records) == 0 {
+		return nil, errors.New(NO_RECORDS)
+	}
+	return records, nil
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEtcdIndexChanges(t *testing.T) {
+	t.Run("Added", func(t *testing.T) {
+		puts, deletes := etcdIndexChanges(NODES_TABLE_NAME, "n1", "", `{"hostid":"h1","network":"skynet"}`)
+		assert.ElementsMatch(t, []string{
+			"/netmaker-index/nodes/hostid/h1/n1",
+			"/netmaker-index/nodes/network/skynet/n1",
+		}, puts)
+		assert.Empty(t, deletes)
+	})
+	t.Run("Changed", func(t *testing.T) {
+		puts, deletes := etcdIndexChanges(NODES_TABLE_NAME, "n1", `{"hostid":"h1","network":"skynet"}`, `{"hostid":"h1","network":"othernet"}`)
+		assert.Equal(t, []string{"/netmaker-index/nodes/network/othernet/n1"}, puts)
+		assert.Equal(t, []string{"/netmaker-index/nodes/network/skynet/n1"}, deletes)
+	})
+	t.Run("Deleted", func(t *testing.T) {
+		puts, deletes := etcdIndexChanges(EXT_CLIENT_TABLE_NAME, "c1", `{"network":"skynet","ownerid":""}`, "")
+		assert.Empty(t, puts)
+		assert.Equal(t, []string{"/netmaker-index/extclients/network/skynet/c1"}, deletes)
+	})
+	t.Run("Escaped", func(t *testing.T) {
+		puts, _ := etcdIndexChanges(EXT_CLIENT_TABLE_NAME, "c1", "", `{"ownerid":"a/b"}`)
+		assert.Equal(t, []string{"/netmaker-index/extclients/ownerid/a%2Fb/c1"}, puts)
+	})
+	t.Run("NotIndexed", func(t *testing.T) {
+		puts, deletes := etcdIndexChanges(HOSTS_TABLE_NAME, "h1", "", `{"id":"h1"}`)
+		assert.Empty(t, puts)
+		assert.Empty(t, deletes)
+	})
+}
+
+// TestEtcdStore - runs against the etcd given by ETCD_ENDPOINTS
+func TestEtcdStore(t *testing.T) {
+	if os.Getenv("ETCD_ENDPOINTS") == "" {
+		t.Skip("ETCD_ENDPOINTS not set")
+	}
+	store := &etcdStore{}
+	assert.Nil(t, store.Init())
+	defer store.Close()
+	assert.Nil(t, store.DeleteAll(NODES_TABLE_NAME))
+	defer store.DeleteAll(NODES_TABLE_NAME)
+	for i := 0; i < 5; i++ {
+		network := "skynet"
+		if i%2 == 0 {
+			network = "othernet"
+		}
+		assert.Nil(t, store.Insert(fmt.Sprintf("node%d", i), fmt.Sprintf(`{"id":"node%d","network":"%s"}`, i, network), NODES_TABLE_NAME))
+	}
+	t.Run("FetchPage", func(t *testing.T) {
+		records, err := store.FetchPage(NODES_TABLE_NAME, 1, 2)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(records))
+		assert.Contains(t, records, "node1")
+		assert.Contains(t, records, "node2")
+		records, err = store.FetchPage(NODES_TABLE_NAME, 4, 2)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(records))
+		_, err = store.FetchPage(NODES_TABLE_NAME, 5, 2)
+		assert.True(t, IsEmptyRecord(err))
+	})
+	t.Run("FetchBy", func(t *testing.T) {
+		records, err := store.FetchBy(NODES_TABLE_NAME, "network", "skynet")
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(records))
+		assert.Nil(t, store.Insert("node1", `{"id":"node1","network":"othernet"}`, NODES_TABLE_NAME))
+		assert.Nil(t, store.Delete(NODES_TABLE_NAME, "node3"))
+		_, err = store.FetchBy(NODES_TABLE_NAME, "network", "skynet")
+		assert.True(t, IsEmptyRecord(err))
+		records, err = store.FetchBy(NODES_TABLE_NAME, "network", "othernet")
+		assert.Nil(t, err)
+		assert.Equal(t, 4, len(records))
+		records, err = store.FetchBy(NODES_TABLE_NAME, "id", "node4")
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(records))
+	})
+	t.Run("Watch", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		revision, err := store.Revision(ctx)
+		assert.Nil(t, err)
+		// written before the watch starts, but after the revision it watches from
+		assert.Nil(t, store.Insert("node5", `{"id":"node5"}`, NODES_TABLE_NAME))
+		changes, err := store.Watch(ctx, revision, NODES_TABLE_NAME)
+		assert.Nil(t, err)
+		assert.Nil(t, store.Delete(NODES_TABLE_NAME, "node5"))
+		change := <-changes
+		assert.Equal(t, Change{Type: ChangePut, Table: NODES_TABLE_NAME, Key: "node5", Value: `{"id":"node5"}`, Revision: change.Revision}, change)
+		assert.Greater(t, change.Revision, revision)
+		deleted := <-changes
+		assert.Equal(t, ChangeDelete, deleted.Type)
+		assert.Greater(t, deleted.Revision, change.Revision)
+	})
+}
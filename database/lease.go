@@ -0,0 +1,176 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Lease - a named lock held by one of the servers sharing the database until it expires,
+// so jobs and critical sections don't run on several servers at once
+type Lease struct {
+	// Name - the name of the lease, to read it by without reading every lease
+	Name  string `json:"name"`
+	Owner string `json:"owner"`
+	// Expires - unix milliseconds, by the clock of the owner
+	Expires int64 `json:"expires"`
+	// Token - the fencing token of a hold, the unix milliseconds it was taken at; renewing the hold keeps it
+	Token int64 `json:"token"`
+}
+
+// ErrLeaseLost - a lease is no longer held by the owner with the fencing token, it expired or was taken over
+var ErrLeaseLost = errors.New("lease lost")
+
+// Leaser - implemented by stores that can take a lease atomically, a store without it
+// only keeps the servers of this process apart
+type Leaser interface {
+	// AcquireLease - stores the lease when there is none, it's expired or owned by the same owner;
+	// reports whether it was stored
+	AcquireLease(name, value, owner string, now int64) (bool, error)
+	// RenewLease - stores the lease only if it's still owned by owner with the fencing token;
+	// reports whether it was stored
+	RenewLease(name, value, owner string, token int64) (bool, error)
+	// ReleaseLease - removes the lease if it's owned by owner
+	ReleaseLease(name, owner string) error
+}
+
+// AcquireLease - takes the lease of the given name for owner, or renews it, until ttl from now;
+// false when another owner holds it
+func AcquireLease(name, owner string, ttl time.Duration) (bool, error) {
+	if name == "" || owner == "" {
+		return false, errors.New("invalid lease " + name + " : " + owner)
+	}
+	now := time.Now()
+	data, err := json.Marshal(Lease{Name: name, Owner: owner, Expires: now.Add(ttl).UnixMilli(), Token: now.UnixMilli()})
+	if err != nil {
+		return false, err
+	}
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	if acquire, ok := getCurrentDB()[ACQUIRE_LEASE].(func(string, string, string, int64) (bool, error)); ok {
+		return acquire(name, string(data), owner, now.UnixMilli())
+	}
+	lease, err := fetchLease(name)
+	if err != nil && !IsEmptyRecord(err) {
+		return false, err
+	}
+	if err == nil && lease.Owner != owner && lease.Expires >= now.UnixMilli() {
+		return false, nil
+	}
+	if err := getCurrentDB()[INSERT].(func(string, string, string) error)(name, string(data), LEASES_TABLE_NAME); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RenewLease - extends the lease of the given name until ttl from now if owner still holds it with the fencing token;
+// false when the hold was lost, so a lease another owner held in between is never renewed
+func RenewLease(name, owner string, token int64, ttl time.Duration) (bool, error) {
+	data, err := json.Marshal(Lease{Name: name, Owner: owner, Expires: time.Now().Add(ttl).UnixMilli(), Token: token})
+	if err != nil {
+		return false, err
+	}
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	if renew, ok := getCurrentDB()[RENEW_LEASE].(func(string, string, string, int64) (bool, error)); ok {
+		return renew(name, string(data), owner, token)
+	}
+	lease, err := fetchLease(name)
+	if err != nil {
+		if IsEmptyRecord(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if lease.Owner != owner || lease.Token != token {
+		return false, nil
+	}
+	if err := getCurrentDB()[INSERT].(func(string, string, string) error)(name, string(data), LEASES_TABLE_NAME); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// CheckLease - ErrLeaseLost unless owner holds the unexpired lease of the given name with the fencing token
+func CheckLease(name, owner string, token int64) error {
+	lease, err := GetLease(name)
+	if err != nil {
+		if IsEmptyRecord(err) {
+			return ErrLeaseLost
+		}
+		return err
+	}
+	if lease.Owner != owner || lease.Token != token || lease.Expires < time.Now().UnixMilli() {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// ReleaseLease - gives up the lease of the given name if owner holds it
+func ReleaseLease(name, owner string) error {
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	if release, ok := getCurrentDB()[RELEASE_LEASE].(func(string, string) error); ok {
+		return release(name, owner)
+	}
+	lease, err := fetchLease(name)
+	if err != nil {
+		if IsEmptyRecord(err) {
+			return nil
+		}
+		return err
+	}
+	if lease.Owner != owner {
+		return nil
+	}
+	return getCurrentDB()[DELETE].(func(string, string) error)(LEASES_TABLE_NAME, name)
+}
+
+// GetLease - the lease of the given name, expired or not
+func GetLease(name string) (Lease, error) {
+	dbMutex.RLock()
+	defer dbMutex.RUnlock()
+	return fetchLease(name)
+}
+
+// GetLeases - every lease, expired or not, by name
+func GetLeases() (map[string]Lease, error) {
+	dbMutex.RLock()
+	records, err := getCurrentDB()[FETCH_ALL].(func(string) (map[string]string, error))(LEASES_TABLE_NAME)
+	dbMutex.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	leases := make(map[string]Lease, len(records))
+	for name, record := range records {
+		var lease Lease
+		if err := json.Unmarshal([]byte(record), &lease); err != nil {
+			continue
+		}
+		leases[name] = lease
+	}
+	return leases, nil
+}
+
+// == private ==
+
+// fetchLease - reads a lease, dbMutex must be held
+func fetchLease(name string) (Lease, error) {
+	_, lease, err := fetchLeaseRecord(name)
+	return lease, err
+}
+
+// fetchLeaseRecord - reads a lease and its record by its name rather than the whole table, dbMutex must be held
+func fetchLeaseRecord(name string) (string, Lease, error) {
+	var lease Lease
+	records, err := getCurrentDB()[FETCH_BY].(func(string, string, string) (map[string]string, error))(LEASES_TABLE_NAME, "name", name)
+	if err != nil {
+		return "", lease, err
+	}
+	record, ok := records[name]
+	if !ok {
+		return "", lease, errors.New(NO_RECORD)
+	}
+	err = json.Unmarshal([]byte(record), &lease)
+	return record, lease, err
+}
@@ -0,0 +1,273 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"golang.org/x/exp/slog"
+)
+
+// pgMigration - a versioned schema change applied once, in order, to a PostgreSQL database,
+// down reverts it for a downgrade
+type pgMigration struct {
+	version    int
+	name       string
+	statements []string
+	down       []string
+}
+
+// pgMigrations - moves the key/value tables of the core objects to a relational schema,
+// new migrations must be appended with the next version number
+var pgMigrations = []pgMigration{
+	{
+		version: 1,
+		name:    "jsonb values",
+		statements: []string{
+			"ALTER TABLE " + NETWORKS_TABLE_NAME + " ALTER COLUMN value TYPE JSONB USING value::jsonb",
+			"ALTER TABLE " + HOSTS_TABLE_NAME + " ALTER COLUMN value TYPE JSONB USING value::jsonb",
+			"ALTER TABLE " + NODES_TABLE_NAME + " ALTER COLUMN value TYPE JSONB USING value::jsonb",
+			"ALTER TABLE " + EXT_CLIENT_TABLE_NAME + " ALTER COLUMN value TYPE JSONB USING value::jsonb",
+			"ALTER TABLE " + USERS_TABLE_NAME + " ALTER COLUMN value TYPE JSONB USING value::jsonb",
+		},
+		down: []string{
+			"ALTER TABLE " + NETWORKS_TABLE_NAME + " ALTER COLUMN value TYPE TEXT USING value::text",
+			"ALTER TABLE " + HOSTS_TABLE_NAME + " ALTER COLUMN value TYPE TEXT USING value::text",
+			"ALTER TABLE " + NODES_TABLE_NAME + " ALTER COLUMN value TYPE TEXT USING value::text",
+			"ALTER TABLE " + EXT_CLIENT_TABLE_NAME + " ALTER COLUMN value TYPE TEXT USING value::text",
+			"ALTER TABLE " + USERS_TABLE_NAME + " ALTER COLUMN value TYPE TEXT USING value::text",
+		},
+	},
+	{
+		version: 2,
+		name:    "indexed columns",
+		statements: []string{
+			"ALTER TABLE " + NODES_TABLE_NAME + " ADD COLUMN host_id TEXT GENERATED ALWAYS AS (NULLIF(value->>'hostid', '')) STORED",
+			"ALTER TABLE " + NODES_TABLE_NAME + " ADD COLUMN network TEXT GENERATED ALWAYS AS (NULLIF(value->>'network', '')) STORED",
+			"CREATE INDEX nodes_host_id_idx ON " + NODES_TABLE_NAME + " (host_id)",
+			"CREATE INDEX nodes_network_idx ON " + NODES_TABLE_NAME + " (network)",
+			"ALTER TABLE " + EXT_CLIENT_TABLE_NAME + " ADD COLUMN network TEXT GENERATED ALWAYS AS (NULLIF(value->>'network', '')) STORED",
+			"ALTER TABLE " + EXT_CLIENT_TABLE_NAME + " ADD COLUMN ingress_gateway_id TEXT GENERATED ALWAYS AS (NULLIF(value->>'ingressgatewayid', '')) STORED",
+			"ALTER TABLE " + EXT_CLIENT_TABLE_NAME + " ADD COLUMN owner_id TEXT GENERATED ALWAYS AS (NULLIF(value->>'ownerid', '')) STORED",
+			"CREATE INDEX extclients_network_idx ON " + EXT_CLIENT_TABLE_NAME + " (network)",
+			"CREATE INDEX extclients_ingress_gateway_id_idx ON " + EXT_CLIENT_TABLE_NAME + " (ingress_gateway_id)",
+			"CREATE INDEX extclients_owner_id_idx ON " + EXT_CLIENT_TABLE_NAME + " (owner_id)",
+		},
+		// dropping the columns drops their indexes
+		down: []string{
+			"ALTER TABLE " + NODES_TABLE_NAME + " DROP COLUMN host_id",
+			"ALTER TABLE " + NODES_TABLE_NAME + " DROP COLUMN network",
+			"ALTER TABLE " + EXT_CLIENT_TABLE_NAME + " DROP COLUMN network",
+			"ALTER TABLE " + EXT_CLIENT_TABLE_NAME + " DROP COLUMN ingress_gateway_id",
+			"ALTER TABLE " + EXT_CLIENT_TABLE_NAME + " DROP COLUMN owner_id",
+		},
+	},
+	{
+		version: 3,
+		name:    "referential integrity",
+		// NOT VALID - existing rows are left alone so that stale records from older
+		// versions don't block the upgrade, new writes are checked
+		statements: []string{
+			"ALTER TABLE " + NODES_TABLE_NAME + " ADD CONSTRAINT nodes_host_id_fk FOREIGN KEY (host_id) REFERENCES " + HOSTS_TABLE_NAME + " (key) ON DELETE CASCADE NOT VALID",
+			"ALTER TABLE " + NODES_TABLE_NAME + " ADD CONSTRAINT nodes_network_fk FOREIGN KEY (network) REFERENCES " + NETWORKS_TABLE_NAME + " (key) ON DELETE CASCADE NOT VALID",
+			"ALTER TABLE " + EXT_CLIENT_TABLE_NAME + " ADD CONSTRAINT extclients_network_fk FOREIGN KEY (network) REFERENCES " + NETWORKS_TABLE_NAME + " (key) ON DELETE CASCADE NOT VALID",
+			"ALTER TABLE " + EXT_CLIENT_TABLE_NAME + " ADD CONSTRAINT extclients_ingress_gateway_id_fk FOREIGN KEY (ingress_gateway_id) REFERENCES " + NODES_TABLE_NAME + " (key) ON DELETE CASCADE NOT VALID",
+		},
+		down: []string{
+			"ALTER TABLE " + NODES_TABLE_NAME + " DROP CONSTRAINT nodes_host_id_fk",
+			"ALTER TABLE " + NODES_TABLE_NAME + " DROP CONSTRAINT nodes_network_fk",
+			"ALTER TABLE " + EXT_CLIENT_TABLE_NAME + " DROP CONSTRAINT extclients_network_fk",
+			"ALTER TABLE " + EXT_CLIENT_TABLE_NAME + " DROP CONSTRAINT extclients_ingress_gateway_id_fk",
+		},
+	},
+	{
+		version: 4,
+		name:    "drop referential integrity",
+		// the key/value callers write and delete objects one at a time and in no fixed order
+		// (a node may be saved before its host, a network deleted while its nodes are cleaned up),
+		// so the constraints of version 3 either failed those writes or cascaded deletes the
+		// callers still had to publish, the relations stay the callers' job
+		statements: []string{
+			"ALTER TABLE " + NODES_TABLE_NAME + " DROP CONSTRAINT IF EXISTS nodes_host_id_fk",
+			"ALTER TABLE " + NODES_TABLE_NAME + " DROP CONSTRAINT IF EXISTS nodes_network_fk",
+			"ALTER TABLE " + EXT_CLIENT_TABLE_NAME + " DROP CONSTRAINT IF EXISTS extclients_network_fk",
+			"ALTER TABLE " + EXT_CLIENT_TABLE_NAME + " DROP CONSTRAINT IF EXISTS extclients_ingress_gateway_id_fk",
+		},
+		down: []string{
+			"ALTER TABLE " + NODES_TABLE_NAME + " ADD CONSTRAINT nodes_host_id_fk FOREIGN KEY (host_id) REFERENCES " + HOSTS_TABLE_NAME + " (key) ON DELETE CASCADE NOT VALID",
+			"ALTER TABLE " + NODES_TABLE_NAME + " ADD CONSTRAINT nodes_network_fk FOREIGN KEY (network) REFERENCES " + NETWORKS_TABLE_NAME + " (key) ON DELETE CASCADE NOT VALID",
+			"ALTER TABLE " + EXT_CLIENT_TABLE_NAME + " ADD CONSTRAINT extclients_network_fk FOREIGN KEY (network) REFERENCES " + NETWORKS_TABLE_NAME + " (key) ON DELETE CASCADE NOT VALID",
+			"ALTER TABLE " + EXT_CLIENT_TABLE_NAME + " ADD CONSTRAINT extclients_ingress_gateway_id_fk FOREIGN KEY (ingress_gateway_id) REFERENCES " + NODES_TABLE_NAME + " (key) ON DELETE CASCADE NOT VALID",
+		},
+	},
+}
+
+// pgIndexedColumns - generated columns that can be queried instead of the json value, by table and json field
+var pgIndexedColumns = map[string]map[string]string{
+	NODES_TABLE_NAME: {
+		"hostid":  "host_id",
+		"network": "network",
+	},
+	EXT_CLIENT_TABLE_NAME: {
+		"network":          "network",
+		"ingressgatewayid": "ingress_gateway_id",
+		"ownerid":          "owner_id",
+	},
+}
+
+// PGMigrationStatus - returns the applied schema version and the latest known version
+func PGMigrationStatus() (current, latest int, err error) {
+	if err = pgCreateMigrationsTable(); err != nil {
+		return 0, 0, err
+	}
+	current, err = pgSchemaVersion()
+	return current, pgMigrations[len(pgMigrations)-1].version, err
+}
+
+// PGMigrateDown - reverts the migrations applied after the target version, newest first,
+// so the database can be used by an older release
+func PGMigrateDown(target int) error {
+	if err := pgCreateMigrationsTable(); err != nil {
+		return err
+	}
+	if err := pgVerifyMigrations(); err != nil {
+		return err
+	}
+	current, err := pgSchemaVersion()
+	if err != nil {
+		return err
+	}
+	if target >= current {
+		return nil
+	}
+	if _, err = BackupTables(fmt.Sprintf("reverting the schema from version %d to %d", current, target)); err != nil {
+		return fmt.Errorf("backing up before the downgrade: %w", err)
+	}
+	for i := len(pgMigrations) - 1; i >= 0; i-- {
+		m := pgMigrations[i]
+		if m.version <= target || m.version > current {
+			continue
+		}
+		slog.Info("reverting database migration", "version", m.version, "name", m.name)
+		if err = pgApply(m.down, "DELETE FROM schema_migrations WHERE version = $1", m.version); err != nil {
+			return fmt.Errorf("reverting migration %d (%s) failed: %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+// == private ==
+
+func pgCreateMigrationsTable() error {
+	if _, err := PGDB.Exec("CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at TIMESTAMPTZ NOT NULL DEFAULT now())"); err != nil {
+		return err
+	}
+	// migrations applied before checksums were recorded have an empty one until verified
+	_, err := PGDB.Exec("ALTER TABLE schema_migrations ADD COLUMN IF NOT EXISTS checksum TEXT NOT NULL DEFAULT ''")
+	return err
+}
+
+// pgChecksum - identifies the statements of a migration, so an applied one that was later edited is caught
+func pgChecksum(m pgMigration) string {
+	sum := sha256.Sum256([]byte(strings.Join(m.statements, ";\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// pgVerifyMigrations - checks every applied migration is known to this server and unchanged since it was applied
+func pgVerifyMigrations() error {
+	rows, err := PGDB.Query("SELECT version, checksum FROM schema_migrations ORDER BY version")
+	if err != nil {
+		return err
+	}
+	applied := map[int]string{}
+	for rows.Next() {
+		var version int
+		var checksum string
+		if err = rows.Scan(&version, &checksum); err != nil {
+			rows.Close()
+			return err
+		}
+		applied[version] = checksum
+	}
+	rows.Close()
+	known := map[int]pgMigration{}
+	for _, m := range pgMigrations {
+		known[m.version] = m
+	}
+	for version, checksum := range applied {
+		m, ok := known[version]
+		if !ok {
+			return fmt.Errorf("database schema migration %d is unknown to this server, it was applied by a newer release", version)
+		}
+		if checksum == "" {
+			if _, err = PGDB.Exec("UPDATE schema_migrations SET checksum = $1 WHERE version = $2", pgChecksum(m), version); err != nil {
+				return err
+			}
+			continue
+		}
+		if checksum != pgChecksum(m) {
+			return fmt.Errorf("database schema migration %d (%s) changed since it was applied", version, m.name)
+		}
+	}
+	return nil
+}
+
+// pgApply - runs the statements of a migration and records it in one transaction
+func pgApply(statements []string, record string, args ...any) error {
+	tx, err := PGDB.Begin()
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements {
+		if _, err = tx.Exec(statement); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if _, err = tx.Exec(record, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func pgSchemaVersion() (int, error) {
+	var version int
+	err := PGDB.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	return version, err
+}
+
+// pgMigrate - applies all pending migrations, each one in its own transaction
+func pgMigrate() error {
+	if err := pgCreateMigrationsTable(); err != nil {
+		return err
+	}
+	if err := pgVerifyMigrations(); err != nil {
+		return err
+	}
+	current, err := pgSchemaVersion()
+	if err != nil {
+		return err
+	}
+	latest := pgMigrations[len(pgMigrations)-1].version
+	if current >= latest {
+		return nil
+	}
+	// a fresh database has nothing worth keeping
+	if current > 0 {
+		if _, err = BackupTables(fmt.Sprintf("upgrading the schema from version %d to %d", current, latest)); err != nil {
+			return fmt.Errorf("backing up before the upgrade: %w", err)
+		}
+	}
+	for _, m := range pgMigrations {
+		if m.version <= current {
+			continue
+		}
+		slog.Info("applying database migration", "version", m.version, "name", m.name)
+		if err = pgApply(m.statements, "INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)", m.version, m.name, pgChecksum(m)); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
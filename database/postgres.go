@@ -14,15 +14,23 @@ var PGDB *sql.DB
 
 // PG_FUNCTIONS - map of db functions for PostGreSQL
 var PG_FUNCTIONS = map[string]interface{}{
-	INIT_DB:      initPGDB,
-	CREATE_TABLE: pgCreateTable,
-	INSERT:       pgInsert,
-	INSERT_PEER:  pgInsertPeer,
-	DELETE:       pgDeleteRecord,
-	DELETE_ALL:   pgDeleteAllRecords,
-	FETCH_ALL:    pgFetchRecords,
-	CLOSE_DB:     pgCloseDB,
-	isConnected:  pgIsConnected,
+	INIT_DB:       initPGDB,
+	CREATE_TABLE:  pgCreateTable,
+	INSERT:        pgInsert,
+	INSERT_PEER:   pgInsertPeer,
+	DELETE:        pgDeleteRecord,
+	DELETE_ALL:    pgDeleteAllRecords,
+	FETCH_ALL:     pgFetchRecords,
+	FETCH_PAGE:    pgFetchRecordsPage,
+	FETCH_BY:      pgFetchRecordsByField,
+	COMMIT_TX:     pgCommit,
+	ACQUIRE_LEASE: pgAcquireLease,
+	RENEW_LEASE:   pgRenewLease,
+	RELEASE_LEASE: pgReleaseLease,
+	INSERT_NEW:    pgInsertNew,
+	DELETE_IF:     pgDeleteIf,
+	CLOSE_DB:      pgCloseDB,
+	isConnected:   pgIsConnected,
 }
 
 func getPGConnString() string {
@@ -115,22 +123,87 @@ func pgDeleteAllRecords(tableName string) error {
 }
 
 func pgFetchRecords(tableName string) (map[string]string, error) {
-	row, err := PGDB.Query("SELECT * FROM " + tableName + " ORDER BY key")
+	row, err := PGDB.Query("SELECT key, value FROM " + tableName + " ORDER BY key")
 	if err != nil {
 		return nil, err
 	}
-	records := make(map[string]string)
-	defer row.Close()
-	for row.Next() { // Iterate and fetch the records from result cursor
-		var key string
-		var value string
-		row.Scan(&key, &value)
-		records[key] = value
+	return scanRecords(row)
+}
+
+func pgFetchRecordsPage(tableName string, offset, limit int) (map[string]string, error) {
+	row, err := PGDB.Query("SELECT key, value FROM "+tableName+" ORDER BY key LIMIT $1 OFFSET $2", limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return scanRecords(row)
+}
+
+func pgFetchRecordsByField(tableName, field, value string) (map[string]string, error) {
+	var row *sql.Rows
+	var err error
+	if column, ok := pgIndexedColumns[tableName][field]; ok {
+		row, err = PGDB.Query("SELECT key, value FROM "+tableName+" WHERE "+column+" = $1 ORDER BY key", value)
+	} else {
+		row, err = PGDB.Query("SELECT key, value FROM "+tableName+" WHERE value::jsonb->>$1 = $2 ORDER BY key", field, value)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return scanRecords(row)
+}
+
+func pgCommit(ops []TxOp) error {
+	return commitSQL(PGDB, ops,
+		func(tableName string) string {
+			return "INSERT INTO " + tableName + " (key, value) VALUES ($1, $2) ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value"
+		},
+		func(tableName string) string {
+			return "DELETE FROM " + tableName + " WHERE key = $1"
+		},
+	)
+}
+
+func pgAcquireLease(name, value, owner string, now int64) (bool, error) {
+	result, err := PGDB.Exec("INSERT INTO "+LEASES_TABLE_NAME+" (key, value) VALUES ($1, $2) ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value "+
+		"WHERE "+LEASES_TABLE_NAME+".value::jsonb->>'owner' = $3 OR ("+LEASES_TABLE_NAME+".value::jsonb->>'expires')::bigint < $4", name, value, owner, now)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func pgRenewLease(name, value, owner string, token int64) (bool, error) {
+	result, err := PGDB.Exec("UPDATE "+LEASES_TABLE_NAME+" SET value = $1 WHERE key = $2 AND value::jsonb->>'owner' = $3 AND (value::jsonb->>'token')::bigint = $4",
+		value, name, owner, token)
+	if err != nil {
+		return false, err
 	}
-	if len(records) == 0 {
-		return nil, errors.New(NO_RECORDS)
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func pgReleaseLease(name, owner string) error {
+	_, err := PGDB.Exec("DELETE FROM "+LEASES_TABLE_NAME+" WHERE key = $1 AND value::jsonb->>'owner' = $2", name, owner)
+	return err
+}
+
+func pgInsertNew(key, value, tableName string) (bool, error) {
+	result, err := PGDB.Exec("INSERT INTO "+tableName+" (key, value) VALUES ($1, $2) ON CONFLICT (key) DO NOTHING", key, value)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func pgDeleteIf(tableName, key, value string) (bool, error) {
+	result, err := PGDB.Exec("DELETE FROM "+tableName+" WHERE key = $1 AND value = $2", key, value)
+	if err != nil {
+		return false, err
 	}
-	return records, nil
+	affected, err := result.RowsAffected()
+	return affected > 0, err
 }
 
 func pgCloseDB() {
@@ -2,6 +2,8 @@ package database
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/gravitl/netmaker/servercfg"
 	"github.com/rqlite/gorqlite"
@@ -12,15 +14,23 @@ var RQliteDatabase gorqlite.Connection
 
 // RQLITE_FUNCTIONS - all the functions to run with rqlite
 var RQLITE_FUNCTIONS = map[string]interface{}{
-	INIT_DB:      initRqliteDatabase,
-	CREATE_TABLE: rqliteCreateTable,
-	INSERT:       rqliteInsert,
-	INSERT_PEER:  rqliteInsertPeer,
-	DELETE:       rqliteDeleteRecord,
-	DELETE_ALL:   rqliteDeleteAllRecords,
-	FETCH_ALL:    rqliteFetchRecords,
-	CLOSE_DB:     rqliteCloseDB,
-	isConnected:  rqliteConnected,
+	INIT_DB:       initRqliteDatabase,
+	CREATE_TABLE:  rqliteCreateTable,
+	INSERT:        rqliteInsert,
+	INSERT_PEER:   rqliteInsertPeer,
+	DELETE:        rqliteDeleteRecord,
+	DELETE_ALL:    rqliteDeleteAllRecords,
+	FETCH_ALL:     rqliteFetchRecords,
+	FETCH_PAGE:    rqliteFetchRecordsPage,
+	FETCH_BY:      rqliteFetchRecordsByField,
+	COMMIT_TX:     rqliteCommit,
+	ACQUIRE_LEASE: rqliteAcquireLease,
+	RENEW_LEASE:   rqliteRenewLease,
+	RELEASE_LEASE: rqliteReleaseLease,
+	INSERT_NEW:    rqliteInsertNew,
+	DELETE_IF:     rqliteDeleteIf,
+	CLOSE_DB:      rqliteCloseDB,
+	isConnected:   rqliteConnected,
 }
 
 func initRqliteDatabase() error {
@@ -85,7 +95,20 @@ func rqliteDeleteAllRecords(tableName string) error {
 }
 
 func rqliteFetchRecords(tableName string) (map[string]string, error) {
-	row, err := RQliteDatabase.QueryOne("SELECT * FROM " + tableName + " ORDER BY key")
+	return rqliteQueryRecords("SELECT * FROM " + tableName + " ORDER BY key")
+}
+
+func rqliteFetchRecordsPage(tableName string, offset, limit int) (map[string]string, error) {
+	return rqliteQueryRecords(fmt.Sprintf("SELECT key, value FROM %s ORDER BY key LIMIT %d OFFSET %d", tableName, limit, offset))
+}
+
+func rqliteFetchRecordsByField(tableName, field, value string) (map[string]string, error) {
+	return rqliteQueryRecords(fmt.Sprintf("SELECT key, value FROM %s WHERE json_extract(value, '$.%s') = '%s' ORDER BY key",
+		tableName, strings.ReplaceAll(field, "'", "''"), strings.ReplaceAll(value, "'", "''")))
+}
+
+func rqliteQueryRecords(query string) (map[string]string, error) {
+	row, err := RQliteDatabase.QueryOne(query)
 	if err != nil {
 		return nil, err
 	}
@@ -102,6 +125,64 @@ func rqliteFetchRecords(tableName string) (map[string]string, error) {
 	return records, nil
 }
 
+// rqliteCommit - rqlite runs all statements of a single write request in one transaction
+func rqliteCommit(ops []TxOp) error {
+	statements := make([]string, 0, len(ops))
+	for _, op := range ops {
+		if op.Delete {
+			statements = append(statements, "DELETE FROM "+op.Table+" WHERE key = '"+strings.ReplaceAll(op.Key, "'", "''")+"'")
+		} else {
+			statements = append(statements, "INSERT OR REPLACE INTO "+op.Table+" (key, value) VALUES ('"+
+				strings.ReplaceAll(op.Key, "'", "''")+"', '"+strings.ReplaceAll(op.Value, "'", "''")+"')")
+		}
+	}
+	_, err := RQliteDatabase.Write(statements)
+	return err
+}
+
+func rqliteAcquireLease(name, value, owner string, now int64) (bool, error) {
+	result, err := RQliteDatabase.WriteOne(fmt.Sprintf("INSERT INTO %[1]s (key, value) VALUES ('%[2]s', '%[3]s') ON CONFLICT (key) DO UPDATE SET value = excluded.value "+
+		"WHERE json_extract(%[1]s.value, '$.owner') = '%[4]s' OR json_extract(%[1]s.value, '$.expires') < %[5]d",
+		LEASES_TABLE_NAME, strings.ReplaceAll(name, "'", "''"), strings.ReplaceAll(value, "'", "''"), strings.ReplaceAll(owner, "'", "''"), now))
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func rqliteRenewLease(name, value, owner string, token int64) (bool, error) {
+	result, err := RQliteDatabase.WriteOne(fmt.Sprintf("UPDATE %[1]s SET value = '%[3]s' WHERE key = '%[2]s' AND json_extract(value, '$.owner') = '%[4]s' AND json_extract(value, '$.token') = %[5]d",
+		LEASES_TABLE_NAME, strings.ReplaceAll(name, "'", "''"), strings.ReplaceAll(value, "'", "''"), strings.ReplaceAll(owner, "'", "''"), token))
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func rqliteReleaseLease(name, owner string) error {
+	_, err := RQliteDatabase.WriteOne("DELETE FROM " + LEASES_TABLE_NAME + " WHERE key = '" + strings.ReplaceAll(name, "'", "''") +
+		"' AND json_extract(value, '$.owner') = '" + strings.ReplaceAll(owner, "'", "''") + "'")
+	return err
+}
+
+func rqliteInsertNew(key, value, tableName string) (bool, error) {
+	result, err := RQliteDatabase.WriteOne("INSERT INTO " + tableName + " (key, value) VALUES ('" + strings.ReplaceAll(key, "'", "''") +
+		"', '" + strings.ReplaceAll(value, "'", "''") + "') ON CONFLICT (key) DO NOTHING")
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func rqliteDeleteIf(tableName, key, value string) (bool, error) {
+	result, err := RQliteDatabase.WriteOne("DELETE FROM " + tableName + " WHERE key = '" + strings.ReplaceAll(key, "'", "''") +
+		"' AND value = '" + strings.ReplaceAll(value, "'", "''") + "'")
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected > 0, nil
+}
+
 func rqliteCloseDB() {
 	RQliteDatabase.Close()
 }
@@ -17,15 +17,23 @@ var SqliteDB *sql.DB
 
 // SQLITE_FUNCTIONS - contains a map of the functions for sqlite
 var SQLITE_FUNCTIONS = map[string]interface{}{
-	INIT_DB:      initSqliteDB,
-	CREATE_TABLE: sqliteCreateTable,
-	INSERT:       sqliteInsert,
-	INSERT_PEER:  sqliteInsertPeer,
-	DELETE:       sqliteDeleteRecord,
-	DELETE_ALL:   sqliteDeleteAllRecords,
-	FETCH_ALL:    sqliteFetchRecords,
-	CLOSE_DB:     sqliteCloseDB,
-	isConnected:  sqliteConnected,
+	INIT_DB:       initSqliteDB,
+	CREATE_TABLE:  sqliteCreateTable,
+	INSERT:        sqliteInsert,
+	INSERT_PEER:   sqliteInsertPeer,
+	DELETE:        sqliteDeleteRecord,
+	DELETE_ALL:    sqliteDeleteAllRecords,
+	FETCH_ALL:     sqliteFetchRecords,
+	FETCH_PAGE:    sqliteFetchRecordsPage,
+	FETCH_BY:      sqliteFetchRecordsByField,
+	COMMIT_TX:     sqliteCommit,
+	ACQUIRE_LEASE: sqliteAcquireLease,
+	RENEW_LEASE:   sqliteRenewLease,
+	RELEASE_LEASE: sqliteReleaseLease,
+	INSERT_NEW:    sqliteInsertNew,
+	DELETE_IF:     sqliteDeleteIf,
+	CLOSE_DB:      sqliteCloseDB,
+	isConnected:   sqliteConnected,
 }
 
 func initSqliteDB() error {
@@ -119,18 +127,77 @@ func sqliteFetchRecords(tableName string) (map[string]string, error) {
 	if err != nil {
 		return nil, err
 	}
-	records := make(map[string]string)
-	defer row.Close()
-	for row.Next() { // Iterate and fetch the records from result cursor
-		var key string
-		var value string
-		row.Scan(&key, &value)
-		records[key] = value
+	return scanRecords(row)
+}
+
+func sqliteFetchRecordsPage(tableName string, offset, limit int) (map[string]string, error) {
+	row, err := SqliteDB.Query("SELECT key, value FROM "+tableName+" ORDER BY key LIMIT ? OFFSET ?", limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return scanRecords(row)
+}
+
+func sqliteFetchRecordsByField(tableName, field, value string) (map[string]string, error) {
+	row, err := SqliteDB.Query("SELECT key, value FROM "+tableName+" WHERE json_extract(value, ?) = ? ORDER BY key", "$."+field, value)
+	if err != nil {
+		return nil, err
+	}
+	return scanRecords(row)
+}
+
+func sqliteCommit(ops []TxOp) error {
+	return commitSQL(SqliteDB, ops,
+		func(tableName string) string {
+			return "INSERT OR REPLACE INTO " + tableName + " (key, value) VALUES (?, ?)"
+		},
+		func(tableName string) string {
+			return "DELETE FROM " + tableName + " WHERE key = ?"
+		},
+	)
+}
+
+func sqliteAcquireLease(name, value, owner string, now int64) (bool, error) {
+	result, err := SqliteDB.Exec("INSERT INTO "+LEASES_TABLE_NAME+" (key, value) VALUES (?, ?) ON CONFLICT (key) DO UPDATE SET value = excluded.value "+
+		"WHERE json_extract("+LEASES_TABLE_NAME+".value, '$.owner') = ? OR json_extract("+LEASES_TABLE_NAME+".value, '$.expires') < ?", name, value, owner, now)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func sqliteRenewLease(name, value, owner string, token int64) (bool, error) {
+	result, err := SqliteDB.Exec("UPDATE "+LEASES_TABLE_NAME+" SET value = ? WHERE key = ? AND json_extract(value, '$.owner') = ? AND json_extract(value, '$.token') = ?",
+		value, name, owner, token)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func sqliteReleaseLease(name, owner string) error {
+	_, err := SqliteDB.Exec("DELETE FROM "+LEASES_TABLE_NAME+" WHERE key = ? AND json_extract(value, '$.owner') = ?", name, owner)
+	return err
+}
+
+func sqliteInsertNew(key, value, tableName string) (bool, error) {
+	result, err := SqliteDB.Exec("INSERT INTO "+tableName+" (key, value) VALUES (?, ?) ON CONFLICT (key) DO NOTHING", key, value)
+	if err != nil {
+		return false, err
 	}
-	if len(records) == 0 {
-		return nil, errors.New(NO_RECORDS)
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func sqliteDeleteIf(tableName, key, value string) (bool, error) {
+	result, err := SqliteDB.Exec("DELETE FROM "+tableName+" WHERE key = ? AND value = ?", key, value)
+	if err != nil {
+		return false, err
 	}
-	return records, nil
+	affected, err := result.RowsAffected()
+	return affected > 0, err
 }
 
 func sqliteCloseDB() {
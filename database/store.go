@@ -0,0 +1,174 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ChangeType - kind of change reported by a watch
+type ChangeType string
+
+const (
+	// ChangePut - a record was inserted or updated
+	ChangePut ChangeType = "put"
+	// ChangeDelete - a record was deleted
+	ChangeDelete ChangeType = "delete"
+)
+
+// ErrWatchNotSupported - returned by Watch when the configured backend can't report changes
+var ErrWatchNotSupported = errors.New("database backend does not support watching for changes")
+
+// Change - a change made to a record, by this or any other server sharing the database
+type Change struct {
+	Type  ChangeType
+	Table string
+	Key   string
+	Value string
+	// Revision - the store wide revision of a change streamed by Watch, the same on every server
+	Revision int64
+}
+
+// Store - a storage backend for the key/value tables of the server
+type Store interface {
+	Init() error
+	CreateTable(tableName string) error
+	Insert(key, value, tableName string) error
+	Delete(tableName, key string) error
+	DeleteAll(tableName string) error
+	FetchAll(tableName string) (map[string]string, error)
+	FetchPage(tableName string, offset, limit int) (map[string]string, error)
+	FetchBy(tableName, field, value string) (map[string]string, error)
+	Close()
+	IsConnected() bool
+}
+
+// Watcher - implemented by stores that can stream changes to their tables
+type Watcher interface {
+	// Revision - returns the store wide revision of the latest change
+	Revision(ctx context.Context) (int64, error)
+	// Watch - streams the changes made to the tables after the given revision, in revision order
+	Watch(ctx context.Context, after int64, tableNames ...string) (<-chan Change, error)
+}
+
+var (
+	storesMu    sync.RWMutex
+	stores      = make(map[string]map[string]interface{})
+	listenersMu sync.RWMutex
+	listeners   []func(Change)
+	// changeMutex - held by a write until its listeners are notified, so that listeners see
+	// the changes in the order they were written even though they run after dbMutex is released
+	changeMutex sync.Mutex
+)
+
+// RegisterStore - makes a storage backend available under the given DATABASE name
+func RegisterStore(name string, s Store) {
+	storesMu.Lock()
+	defer storesMu.Unlock()
+	stores[name] = storeFunctions(s)
+}
+
+// OnChange - registers fn to be called after every successful write made by this server, in write order,
+// fn may read the database but must not write to it
+func OnChange(fn func(Change)) {
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+	listeners = append(listeners, fn)
+}
+
+// Revision - returns the store wide revision of the latest change, to Watch the changes made after it
+func Revision(ctx context.Context) (int64, error) {
+	revision, ok := getCurrentDB()[REVISION].(func(context.Context) (int64, error))
+	if !ok {
+		return 0, ErrWatchNotSupported
+	}
+	return revision(ctx)
+}
+
+// Watch - streams the changes made to the tables after the given revision, in revision order, until ctx is done
+func Watch(ctx context.Context, after int64, tableNames ...string) (<-chan Change, error) {
+	w, ok := getCurrentDB()[WATCH].(func(context.Context, int64, ...string) (<-chan Change, error))
+	if !ok {
+		return nil, ErrWatchNotSupported
+	}
+	changes, err := w(ctx, after, tableNames...)
+	if err != nil {
+		return changes, err
+	}
+	opened := make(chan Change)
+	go func() {
+		defer close(opened)
+		for change := range changes {
+			value, err := openRecord(change.Table, change.Value)
+			if err != nil {
+				continue
+			}
+			change.Value = value
+			select {
+			case opened <- change:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return opened, nil
+}
+
+// CanWatch - checks if the configured backend supports Watch
+func CanWatch() bool {
+	_, ok := getCurrentDB()[WATCH]
+	return ok
+}
+
+// == private ==
+
+func notifyChange(change Change) {
+	listenersMu.RLock()
+	defer listenersMu.RUnlock()
+	for _, fn := range listeners {
+		fn(change)
+	}
+}
+
+func getRegisteredStore(name string) (map[string]interface{}, bool) {
+	storesMu.RLock()
+	defer storesMu.RUnlock()
+	functions, ok := stores[name]
+	return functions, ok
+}
+
+// storeFunctions - exposes a Store through the function map used for the built in backends
+func storeFunctions(s Store) map[string]interface{} {
+	functions := map[string]interface{}{
+		INIT_DB:      s.Init,
+		CREATE_TABLE: s.CreateTable,
+		INSERT:       s.Insert,
+		INSERT_PEER: func(key, value string) error {
+			return s.Insert(key, value, PEERS_TABLE_NAME)
+		},
+		DELETE:      s.Delete,
+		DELETE_ALL:  s.DeleteAll,
+		FETCH_ALL:   s.FetchAll,
+		FETCH_PAGE:  s.FetchPage,
+		FETCH_BY:    s.FetchBy,
+		CLOSE_DB:    s.Close,
+		isConnected: s.IsConnected,
+	}
+	if w, ok := s.(Watcher); ok {
+		functions[WATCH] = w.Watch
+		functions[REVISION] = w.Revision
+	}
+	if t, ok := s.(Transactor); ok {
+		functions[COMMIT_TX] = t.Commit
+	}
+	if c, ok := s.(Conditioner); ok {
+		functions[INSERT_NEW] = c.InsertNew
+		functions[DELETE_IF] = c.DeleteIf
+	}
+	if l, ok := s.(Leaser); ok {
+		functions[ACQUIRE_LEASE] = l.AcquireLease
+		functions[RENEW_LEASE] = l.RenewLease
+		functions[RELEASE_LEASE] = l.ReleaseLease
+	}
+	return functions
+}
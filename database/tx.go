@@ -0,0 +1,154 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/gravitl/netmaker/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// TxOp - a single write of a transaction
+type TxOp struct {
+	Delete bool
+	Table  string
+	Key    string
+	Value  string
+}
+
+// Tx - buffers writes to several tables so they are committed or rolled back together,
+// reads made while building a Tx don't see its writes
+type Tx struct {
+	ops []TxOp
+}
+
+// Transactor - implemented by stores that can apply several writes atomically
+type Transactor interface {
+	Commit(ops []TxOp) error
+}
+
+// BeginTx - starts a new transaction
+func BeginTx() *Tx {
+	return &Tx{}
+}
+
+// Insert - adds an insert or update of a record to the transaction
+func (tx *Tx) Insert(key, value, tableName string) error {
+	if key == "" || value == "" || !IsJSONString(value) {
+		return errors.New("invalid insert " + key + " : " + value)
+	}
+	tx.ops = append(tx.ops, TxOp{Table: tableName, Key: key, Value: value})
+	return nil
+}
+
+// Delete - adds the removal of a record to the transaction
+func (tx *Tx) Delete(tableName, key string) {
+	tx.ops = append(tx.ops, TxOp{Delete: true, Table: tableName, Key: key})
+}
+
+// Commit - applies all writes of the transaction, or none of them if one fails
+func (tx *Tx) Commit() error {
+	if len(tx.ops) == 0 {
+		return nil
+	}
+	_, span := tracing.Start(context.Background(), "database.Commit", attribute.Int("db.operations", len(tx.ops)))
+	defer span.End()
+	ops := make([]TxOp, len(tx.ops))
+	for i, op := range tx.ops {
+		ops[i] = op
+		if op.Delete {
+			continue
+		}
+		sealed, err := sealRecord(op.Table, op.Value)
+		if err != nil {
+			return err
+		}
+		ops[i].Value = sealed
+	}
+	changeMutex.Lock()
+	defer changeMutex.Unlock()
+	dbMutex.Lock()
+	var err error
+	if commit, ok := getCurrentDB()[COMMIT_TX].(func([]TxOp) error); ok {
+		err = commit(ops)
+	} else {
+		err = commitWithUndo(ops)
+	}
+	dbMutex.Unlock()
+	if err != nil {
+		return err
+	}
+	for _, op := range tx.ops {
+		change := Change{Type: ChangePut, Table: op.Table, Key: op.Key, Value: op.Value}
+		if op.Delete {
+			change.Type = ChangeDelete
+		}
+		notifyChange(change)
+	}
+	tx.ops = nil
+	return nil
+}
+
+// == private ==
+
+// commitSQL - applies ops in a transaction of a database/sql backend
+func commitSQL(db *sql.DB, ops []TxOp, insertSQL, deleteSQL func(tableName string) string) error {
+	sqlTx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, op := range ops {
+		if op.Delete {
+			_, err = sqlTx.Exec(deleteSQL(op.Table), op.Key)
+		} else {
+			_, err = sqlTx.Exec(insertSQL(op.Table), op.Key, op.Value)
+		}
+		if err != nil {
+			sqlTx.Rollback()
+			return err
+		}
+	}
+	return sqlTx.Commit()
+}
+
+// commitWithUndo - applies ops one by one for backends without transactions,
+// restoring the previous values of the records written so far if one fails
+func commitWithUndo(ops []TxOp) error {
+	functions := getCurrentDB()
+	fetch := functions[FETCH_ALL].(func(string) (map[string]string, error))
+	insert := functions[INSERT].(func(string, string, string) error)
+	remove := functions[DELETE].(func(string, string) error)
+	previous := make(map[string]map[string]string)
+	for _, op := range ops {
+		if _, ok := previous[op.Table]; ok {
+			continue
+		}
+		records, err := fetch(op.Table)
+		if err != nil && !IsEmptyRecord(err) {
+			return err
+		}
+		previous[op.Table] = records
+	}
+	for i, op := range ops {
+		var err error
+		if op.Delete {
+			err = remove(op.Table, op.Key)
+		} else {
+			err = insert(op.Key, op.Value, op.Table)
+		}
+		if err == nil {
+			continue
+		}
+		for j := i - 1; j >= 0; j-- {
+			undo := ops[j]
+			if value, ok := previous[undo.Table][undo.Key]; ok {
+				insert(undo.Key, value, undo.Table)
+			} else {
+				remove(undo.Table, undo.Key)
+			}
+		}
+		return err
+	}
+	return nil
+}
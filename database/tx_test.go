@@ -0,0 +1,66 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxCommit(t *testing.T) {
+	InitializeDatabase()
+	defer CloseDB()
+	DeleteAllRecords(NODES_TABLE_NAME)
+	DeleteAllRecords(HOSTS_TABLE_NAME)
+	assert.Nil(t, Insert("n1", `{"id":"n1","network":"old"}`, NODES_TABLE_NAME))
+	assert.Nil(t, Insert("n2", `{"id":"n2"}`, NODES_TABLE_NAME))
+	t.Run("AllOrNothing", func(t *testing.T) {
+		tx := BeginTx()
+		assert.Nil(t, tx.Insert("n1", `{"id":"n1","network":"new"}`, NODES_TABLE_NAME))
+		assert.Nil(t, tx.Insert("h1", `{"id":"h1"}`, HOSTS_TABLE_NAME))
+		tx.Delete(NODES_TABLE_NAME, "n2")
+		// the table doesn't exist, so the whole transaction fails
+		assert.Nil(t, tx.Insert("x", `{"id":"x"}`, "notatable"))
+		assert.NotNil(t, tx.Commit())
+		value, err := FetchRecord(NODES_TABLE_NAME, "n1")
+		assert.Nil(t, err)
+		assert.JSONEq(t, `{"id":"n1","network":"old"}`, value)
+		_, err = FetchRecord(NODES_TABLE_NAME, "n2")
+		assert.Nil(t, err)
+		_, err = FetchRecord(HOSTS_TABLE_NAME, "h1")
+		assert.True(t, IsEmptyRecord(err))
+	})
+	t.Run("Applied", func(t *testing.T) {
+		tx := BeginTx()
+		assert.Nil(t, tx.Insert("n1", `{"id":"n1","network":"new"}`, NODES_TABLE_NAME))
+		assert.Nil(t, tx.Insert("h1", `{"id":"h1"}`, HOSTS_TABLE_NAME))
+		tx.Delete(NODES_TABLE_NAME, "n2")
+		assert.Nil(t, tx.Commit())
+		value, err := FetchRecord(NODES_TABLE_NAME, "n1")
+		assert.Nil(t, err)
+		assert.JSONEq(t, `{"id":"n1","network":"new"}`, value)
+		_, err = FetchRecord(NODES_TABLE_NAME, "n2")
+		assert.True(t, IsEmptyRecord(err))
+		_, err = FetchRecord(HOSTS_TABLE_NAME, "h1")
+		assert.Nil(t, err)
+	})
+	t.Run("InvalidInsert", func(t *testing.T) {
+		assert.NotNil(t, BeginTx().Insert("n3", "not json", NODES_TABLE_NAME))
+	})
+	t.Run("UndoWithoutTransactions", func(t *testing.T) {
+		err := commitWithUndo([]TxOp{
+			{Table: NODES_TABLE_NAME, Key: "n1", Value: `{"id":"n1","network":"undone"}`},
+			{Table: NODES_TABLE_NAME, Key: "n4", Value: `{"id":"n4"}`},
+			{Delete: true, Table: HOSTS_TABLE_NAME, Key: "h1"},
+			// rejected by the store after the writes above went through
+			{Table: NODES_TABLE_NAME, Key: "n5", Value: "not json"},
+		})
+		assert.NotNil(t, err)
+		value, err := FetchRecord(NODES_TABLE_NAME, "n1")
+		assert.Nil(t, err)
+		assert.JSONEq(t, `{"id":"n1","network":"new"}`, value)
+		_, err = FetchRecord(NODES_TABLE_NAME, "n4")
+		assert.True(t, IsEmptyRecord(err))
+		_, err = FetchRecord(HOSTS_TABLE_NAME, "h1")
+		assert.Nil(t, err)
+	})
+}
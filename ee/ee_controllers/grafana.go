@@ -0,0 +1,83 @@
+package ee_controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+// GrafanaHandlers - the json datasource api grafana charts node latency and uptime with
+func GrafanaHandlers(r *mux.Router) {
+	r.HandleFunc("/api/grafana", logic.SecurityCheck(true, http.HandlerFunc(testGrafanaDatasource))).Methods(http.MethodGet)
+	r.HandleFunc("/api/grafana/search", logic.SecurityCheck(true, http.HandlerFunc(searchGrafanaTargets))).Methods(http.MethodPost)
+	r.HandleFunc("/api/grafana/query", logic.SecurityCheck(true, http.HandlerFunc(queryGrafana))).Methods(http.MethodPost)
+	r.HandleFunc("/api/grafana/annotations", logic.SecurityCheck(true, http.HandlerFunc(getGrafanaAnnotations))).Methods(http.MethodPost)
+}
+
+// answers grafana testing the datasource's connection
+func testGrafanaDatasource(w http.ResponseWriter, r *http.Request) {
+	logic.ReturnSuccessResponse(w, r, "netmaker datasource is working")
+}
+
+// list the targets matching the search's filter
+func searchGrafanaTargets(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var search models.GrafanaSearch
+	if err := json.NewDecoder(r.Body).Decode(&search); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	targets, err := logic.SearchGrafanaTargets(r.Header.Get("tenant"), search.Target)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to search grafana targets", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(targets)
+}
+
+// get the series of the query's targets over its range
+func queryGrafana(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var query models.GrafanaQuery
+	if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	series, err := logic.QueryGrafana(r.Header.Get("tenant"), &query)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to answer grafana query", "user", r.Header.Get("user"), "error", err)
+		if errors.Is(err, logic.ErrInvalidGrafanaRange) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(series)
+}
+
+// get the network events of the annotation's range
+func getGrafanaAnnotations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var query models.GrafanaAnnotationQuery
+	if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	annotations, err := logic.GetGrafanaAnnotations(r.Header.Get("tenant"), &query)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to fetch grafana annotations", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(annotations)
+}
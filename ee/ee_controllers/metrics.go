@@ -2,23 +2,63 @@ package ee_controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/gravitl/netmaker/database"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
 )
 
 // MetricHandlers - How we handle EE Metrics
 func MetricHandlers(r *mux.Router) {
+	r.HandleFunc("/api/metrics/usage", logic.SuperAdminCheck(http.HandlerFunc(getTrafficUsage))).Methods(http.MethodGet)
+	r.HandleFunc("/api/metrics/sd", logic.SecurityCheck(true, http.HandlerFunc(getPrometheusTargets))).Methods(http.MethodGet)
+	r.HandleFunc("/api/metrics/summary", logic.SuperAdminCheck(http.HandlerFunc(getMetricsSummaries))).Methods(http.MethodGet)
+	r.HandleFunc("/api/metrics/summary/{network}", logic.SecurityCheck(true, http.HandlerFunc(getNetworkMetricsSummary))).Methods(http.MethodGet)
 	r.HandleFunc("/api/metrics/{network}/{nodeid}", logic.SecurityCheck(true, http.HandlerFunc(getNodeMetrics))).Methods(http.MethodGet)
 	r.HandleFunc("/api/metrics/{network}", logic.SecurityCheck(true, http.HandlerFunc(getNetworkNodesMetrics))).Methods(http.MethodGet)
-	r.HandleFunc("/api/metrics", logic.SecurityCheck(true, http.HandlerFunc(getAllMetrics))).Methods(http.MethodGet)
+	r.HandleFunc("/api/metrics", logic.SuperAdminCheck(http.HandlerFunc(getAllMetrics))).Methods(http.MethodGet)
 	r.HandleFunc("/api/metrics-ext/{network}", logic.SecurityCheck(true, http.HandlerFunc(getNetworkExtMetrics))).Methods(http.MethodGet)
 }
 
+// get the node exporter endpoints of the mesh in prometheus http_sd format, filtered by network and by tags;
+// tags are repeated or comma separated and a node must have all of them
+func getPrometheusTargets(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	query := r.URL.Query()
+	port := logic.DefaultNodeExporterPort
+	if value := query.Get("port"); value != "" {
+		var err error
+		if port, err = strconv.Atoi(value); err != nil || port < 1 || port > 65535 {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("invalid port "+value), "badrequest"))
+			return
+		}
+	}
+	tags := []string{}
+	for _, value := range query["tag"] {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	targets, err := logic.GetPrometheusTargets(r.Header.Get("tenant"), query.Get("network"), tags, port)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to list prometheus targets", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(targets)
+}
+
 // get the metrics of a given node
 func getNodeMetrics(w http.ResponseWriter, r *http.Request) {
 	// set header.
@@ -27,19 +67,56 @@ func getNodeMetrics(w http.ResponseWriter, r *http.Request) {
 	var params = mux.Vars(r)
 	nodeID := params["nodeid"]
 
-	logger.Log(1, r.Header.Get("user"), "requested fetching metrics for node", nodeID, "on network", params["network"])
-	metrics, err := logic.GetMetrics(nodeID)
+	slog.InfoCtx(r.Context(), "requested fetching metrics for node on network", "user", r.Header.Get("user"), "node_id", nodeID, "network", params["network"])
+	metrics, err := logic.GetMetricsCtx(r.Context(), nodeID)
 	if err != nil {
-		logger.Log(1, r.Header.Get("user"), "failed to fetch metrics of node", nodeID, err.Error())
+		slog.ErrorCtx(r.Context(), "failed to fetch metrics of node", "user", r.Header.Get("user"), "node_id", nodeID, "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
 
-	logger.Log(1, r.Header.Get("user"), "fetched metrics for node", params["nodeid"])
+	slog.InfoCtx(r.Context(), "fetched metrics for node", "user", r.Header.Get("user"), "nodeid", params["nodeid"])
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(metrics)
 }
 
+// get the aggregated metrics of a network, so dashboards don't have to pull and aggregate raw metrics
+func getNetworkMetricsSummary(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	network := mux.Vars(r)["network"]
+	worst, _ := strconv.Atoi(r.URL.Query().Get("worst"))
+
+	slog.InfoCtx(r.Context(), "requested metrics summary of network", "user", r.Header.Get("user"), "network", network)
+	if _, err := logic.GetNetwork(network); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	summary, err := logic.GetNetworkMetricsSummary(network, worst)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to summarize metrics of network", "user", r.Header.Get("user"), "network", network, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(summary)
+}
+
+// get the aggregated metrics of every network
+func getMetricsSummaries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	worst, _ := strconv.Atoi(r.URL.Query().Get("worst"))
+
+	slog.InfoCtx(r.Context(), "requested metrics summaries of all networks", "user", r.Header.Get("user"))
+	summaries, err := logic.GetMetricsSummaries(worst)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to summarize metrics of all networks", "user", r.Header.Get("user"), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(summaries)
+}
+
 // get the metrics of all nodes in given network
 func getNetworkNodesMetrics(w http.ResponseWriter, r *http.Request) {
 	// set header.
@@ -48,10 +125,10 @@ func getNetworkNodesMetrics(w http.ResponseWriter, r *http.Request) {
 	var params = mux.Vars(r)
 	network := params["network"]
 
-	logger.Log(1, r.Header.Get("user"), "requested fetching network node metrics on network", network)
+	slog.InfoCtx(r.Context(), "requested fetching network node metrics on network", "user", r.Header.Get("user"), "network", network)
 	networkNodes, err := logic.GetNetworkNodes(network)
 	if err != nil {
-		logger.Log(1, r.Header.Get("user"), "failed to fetch metrics of all nodes in network", network, err.Error())
+		slog.ErrorCtx(r.Context(), "failed to fetch metrics of all nodes in network", "user", r.Header.Get("user"), "network", network, "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
@@ -61,15 +138,15 @@ func getNetworkNodesMetrics(w http.ResponseWriter, r *http.Request) {
 
 	for i := range networkNodes {
 		id := networkNodes[i].ID
-		metrics, err := logic.GetMetrics(id.String())
+		metrics, err := logic.GetMetricsCtx(r.Context(), id.String())
 		if err != nil {
-			logger.Log(1, r.Header.Get("user"), "failed to append metrics of node", id.String(), "during network metrics fetch", err.Error())
+			slog.ErrorCtx(r.Context(), "failed to append metrics of node during network metrics fetch", "user", r.Header.Get("user"), "id", id.String(), "error", err)
 			continue
 		}
 		networkMetrics.Nodes[id.String()] = *metrics
 	}
 
-	logger.Log(1, r.Header.Get("user"), "fetched metrics for network", network)
+	slog.InfoCtx(r.Context(), "fetched metrics for network", "user", r.Header.Get("user"), "network", network)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(networkMetrics)
 }
@@ -82,10 +159,10 @@ func getNetworkExtMetrics(w http.ResponseWriter, r *http.Request) {
 	var params = mux.Vars(r)
 	network := params["network"]
 
-	logger.Log(1, r.Header.Get("user"), "requested fetching external client metrics on network", network)
+	slog.InfoCtx(r.Context(), "requested fetching external client metrics on network", "user", r.Header.Get("user"), "network", network)
 	ingresses, err := logic.GetNetworkIngresses(network) // grab all the ingress gateways
 	if err != nil {
-		logger.Log(1, r.Header.Get("user"), "failed to fetch metrics of ext clients in network", network, err.Error())
+		slog.ErrorCtx(r.Context(), "failed to fetch metrics of ext clients in network", "user", r.Header.Get("user"), "network", network, "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
@@ -98,7 +175,7 @@ func getNetworkExtMetrics(w http.ResponseWriter, r *http.Request) {
 			json.NewEncoder(w).Encode(metrics)
 			return
 		}
-		logger.Log(1, r.Header.Get("user"), "failed to fetch metrics of ext clients in network", network, err.Error())
+		slog.ErrorCtx(r.Context(), "failed to fetch metrics of ext clients in network", "user", r.Header.Get("user"), "network", network, "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
@@ -108,9 +185,9 @@ func getNetworkExtMetrics(w http.ResponseWriter, r *http.Request) {
 
 	for i := range ingresses {
 		id := ingresses[i].ID
-		ingressMetrics, err := logic.GetMetrics(id.String())
+		ingressMetrics, err := logic.GetMetricsCtx(r.Context(), id.String())
 		if err != nil {
-			logger.Log(1, r.Header.Get("user"), "failed to append external client metrics from ingress node", id.String(), err.Error())
+			slog.ErrorCtx(r.Context(), "failed to append external client metrics from ingress node", "user", r.Header.Get("user"), "id", id.String(), "error", err)
 			continue
 		}
 		if ingressMetrics.Connectivity == nil {
@@ -127,7 +204,7 @@ func getNetworkExtMetrics(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	logger.Log(1, r.Header.Get("user"), "fetched ext client metrics for network", network)
+	slog.InfoCtx(r.Context(), "fetched ext client metrics for network", "user", r.Header.Get("user"), "network", network)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(networkMetrics.Connectivity)
 }
@@ -135,11 +212,11 @@ func getNetworkExtMetrics(w http.ResponseWriter, r *http.Request) {
 // get Metrics of all nodes on server, lots of data
 func getAllMetrics(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	logger.Log(1, r.Header.Get("user"), "requested fetching all metrics")
+	slog.InfoCtx(r.Context(), "requested fetching all metrics", "user", r.Header.Get("user"))
 
 	allNodes, err := logic.GetAllNodes()
 	if err != nil {
-		logger.Log(1, r.Header.Get("user"), "failed to fetch metrics of all nodes on server", err.Error())
+		slog.ErrorCtx(r.Context(), "failed to fetch metrics of all nodes on server", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
@@ -149,15 +226,58 @@ func getAllMetrics(w http.ResponseWriter, r *http.Request) {
 
 	for i := range allNodes {
 		id := allNodes[i].ID
-		metrics, err := logic.GetMetrics(id.String())
+		metrics, err := logic.GetMetricsCtx(r.Context(), id.String())
 		if err != nil {
-			logger.Log(1, r.Header.Get("user"), "failed to append metrics of node", id.String(), "during all nodes metrics fetch", err.Error())
+			slog.ErrorCtx(r.Context(), "failed to append metrics of node during all nodes metrics fetch", "user", r.Header.Get("user"), "id", id.String(), "error", err)
 			continue
 		}
 		networkMetrics.Nodes[id.String()] = *metrics
 	}
 
-	logger.Log(1, r.Header.Get("user"), "fetched metrics for all nodes on server")
+	slog.InfoCtx(r.Context(), "fetched metrics for all nodes on server", "user", r.Header.Get("user"))
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(networkMetrics)
 }
+
+// get usage reports (daily/monthly totals and top talkers) for chargeback and capacity planning
+func getTrafficUsage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	query := r.URL.Query()
+	period := query.Get("period")
+	if period == "" {
+		period = logic.TrafficUsageDaily
+	}
+	to := time.Now()
+	from := to.AddDate(0, 0, -30)
+	if period == logic.TrafficUsageMonthly {
+		from = to.AddDate(-1, 0, 0)
+	}
+	var err error
+	if v := query.Get("from"); v != "" {
+		if from, err = time.Parse(logger.TimeFormatDay, v); err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if to, err = time.Parse(logger.TimeFormatDay, v); err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+	}
+	top, _ := strconv.Atoi(query.Get("top"))
+
+	slog.InfoCtx(r.Context(), "requested traffic usage report", "user", r.Header.Get("user"))
+	report, err := logic.GetTrafficUsageReport(period, from, to, top)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to build traffic usage report", "user", r.Header.Get("user"), "error", err)
+		if err == logic.ErrInvalidUsagePeriod {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
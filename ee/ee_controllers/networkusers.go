@@ -7,15 +7,15 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/gravitl/netmaker/database"
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/logic/pro"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/models/promodels"
+	"golang.org/x/exp/slog"
 )
 
 func NetworkUsersHandlers(r *mux.Router) {
-	r.HandleFunc("/api/networkusers", logic.SecurityCheck(true, http.HandlerFunc(getAllNetworkUsers))).Methods(http.MethodGet)
+	r.HandleFunc("/api/networkusers", logic.SuperAdminCheck(http.HandlerFunc(getAllNetworkUsers))).Methods(http.MethodGet)
 	r.HandleFunc("/api/networkusers/{network}", logic.SecurityCheck(true, http.HandlerFunc(getNetworkUsers))).Methods(http.MethodGet)
 	r.HandleFunc("/api/networkusers/{network}/{networkuser}", logic.SecurityCheck(true, http.HandlerFunc(getNetworkUser))).Methods(http.MethodGet)
 	r.HandleFunc("/api/networkusers/{network}", logic.SecurityCheck(true, http.HandlerFunc(createNetworkUser))).Methods(http.MethodPost)
@@ -49,7 +49,7 @@ func getNetworkUserData(w http.ResponseWriter, r *http.Request) {
 
 	var params = mux.Vars(r)
 	networkUserName := params["networkuser"]
-	logger.Log(1, r.Header.Get("user"), "requested fetching network user data for user", networkUserName)
+	slog.InfoCtx(r.Context(), "requested fetching network user data for user", "user", r.Header.Get("user"), "network_user_name", networkUserName)
 
 	networks, err := logic.GetNetworks()
 	if err != nil {
@@ -103,7 +103,7 @@ func getNetworkUserData(w http.ResponseWriter, r *http.Request) {
 					if database.IsEmptyRecord(err) && netUser.AccessLevel == pro.NET_ADMIN {
 						newData.Networks = append(newData.Networks, networks[i])
 					} else {
-						logger.Log(0, "failed to retrieve nodes on network", netID, "for user", string(netUser.ID))
+						slog.ErrorCtx(r.Context(), "failed to retrieve nodes on network for user", "net_id", netID, "net_user_id", string(netUser.ID))
 					}
 				} else {
 					if netUser.AccessLevel <= pro.NODE_ACCESS { // handle nodes
@@ -152,7 +152,7 @@ func getNetworkUserData(w http.ResponseWriter, r *http.Request) {
 // returns a map of all network users mapped to each network
 func getAllNetworkUsers(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	logger.Log(1, r.Header.Get("user"), "requested fetching all network users")
+	slog.InfoCtx(r.Context(), "requested fetching all network users", "user", r.Header.Get("user"))
 	type allNetworkUsers = map[string][]promodels.NetworkUser
 
 	networks, err := logic.GetNetworks()
@@ -183,7 +183,7 @@ func getNetworkUsers(w http.ResponseWriter, r *http.Request) {
 
 	var params = mux.Vars(r)
 	netname := params["network"]
-	logger.Log(1, r.Header.Get("user"), "requested fetching network users for network", netname)
+	slog.InfoCtx(r.Context(), "requested fetching network users for network", "user", r.Header.Get("user"), "netname", netname)
 
 	_, err := logic.GetNetwork(netname)
 	if err != nil {
@@ -205,7 +205,7 @@ func getNetworkUser(w http.ResponseWriter, r *http.Request) {
 
 	var params = mux.Vars(r)
 	netname := params["network"]
-	logger.Log(1, r.Header.Get("user"), "requested fetching network user", params["networkuser"], "on network", netname)
+	slog.InfoCtx(r.Context(), "requested fetching network user on network", "user", r.Header.Get("user"), "networkuser", params["networkuser"], "netname", netname)
 
 	_, err := logic.GetNetwork(netname)
 	if err != nil {
@@ -232,7 +232,7 @@ func createNetworkUser(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	var params = mux.Vars(r)
 	netname := params["network"]
-	logger.Log(1, r.Header.Get("user"), "requested creating a network user on network", netname)
+	slog.InfoCtx(r.Context(), "requested creating a network user on network", "user", r.Header.Get("user"), "netname", netname)
 
 	network, err := logic.GetNetwork(netname)
 	if err != nil {
@@ -262,7 +262,7 @@ func updateNetworkUser(w http.ResponseWriter, r *http.Request) {
 
 	var params = mux.Vars(r)
 	netname := params["network"]
-	logger.Log(1, r.Header.Get("user"), "requested updating a network user on network", netname)
+	slog.InfoCtx(r.Context(), "requested updating a network user on network", "user", r.Header.Get("user"), "netname", netname)
 
 	network, err := logic.GetNetwork(netname)
 	if err != nil {
@@ -342,7 +342,7 @@ func deleteNetworkUser(w http.ResponseWriter, r *http.Request) {
 	var params = mux.Vars(r)
 	netname := params["network"]
 
-	logger.Log(1, r.Header.Get("user"), "requested deleting network user", params["networkuser"], "on network", netname)
+	slog.InfoCtx(r.Context(), "requested deleting network user on network", "user", r.Header.Get("user"), "networkuser", params["networkuser"], "netname", netname)
 
 	_, err := logic.GetNetwork(netname)
 	if err != nil {
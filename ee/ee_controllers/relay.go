@@ -7,10 +7,10 @@ import (
 
 	"github.com/gorilla/mux"
 	controller "github.com/gravitl/netmaker/controllers"
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/mq"
+	"golang.org/x/exp/slog"
 )
 
 // RelayHandlers - handle EE Relays
@@ -37,21 +37,24 @@ func createRelay(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	err := json.NewDecoder(r.Body).Decode(&relayRequest)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"), "error decoding request body: ", err.Error())
+		slog.ErrorCtx(r.Context(), "error decoding request body", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
 	relayRequest.NetID = params["network"]
 	relayRequest.NodeID = params["nodeid"]
+	if err := logic.CheckNetworkFeature(relayRequest.NetID, models.NetworkFeatureRelays); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "forbidden"))
+		return
+	}
 	_, relayNode, err := logic.CreateRelay(relayRequest)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("failed to create relay on node [%s] on network [%s]: %v", relayRequest.NodeID, relayRequest.NetID, err))
+		slog.ErrorCtx(r.Context(), fmt.Sprintf("failed to create relay on node [%s] on network [%s]: %v", relayRequest.NodeID, relayRequest.NetID, err), "user", r.Header.Get("user"))
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
-	go mq.PublishPeerUpdate()
-	logger.Log(1, r.Header.Get("user"), "created relay on node", relayRequest.NodeID, "on network", relayRequest.NetID)
+	go mq.PublishNodePeerUpdate(&relayNode)
+	slog.InfoCtx(r.Context(), "created relay on node on network", "user", r.Header.Get("user"), "node_id", relayRequest.NodeID, "net_id", relayRequest.NetID)
 	apiNode := relayNode.ConvertToAPINode()
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(apiNode)
@@ -75,16 +78,16 @@ func deleteRelay(w http.ResponseWriter, r *http.Request) {
 	netid := params["network"]
 	updateNodes, node, err := logic.DeleteRelay(netid, nodeid)
 	if err != nil {
-		logger.Log(0, r.Header.Get("user"), "error decoding request body: ", err.Error())
+		slog.ErrorCtx(r.Context(), "error decoding request body", "user", r.Header.Get("user"), "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
-	logger.Log(1, r.Header.Get("user"), "deleted relay server", nodeid, "on network", netid)
+	slog.InfoCtx(r.Context(), "deleted relay server on network", "user", r.Header.Get("user"), "nodeid", nodeid, "netid", netid)
 	go func() {
 		for _, relayedNode := range updateNodes {
 			err = mq.NodeUpdate(&relayedNode)
 			if err != nil {
-				logger.Log(1, "relayed node update ", relayedNode.ID.String(), "on network", relayedNode.Network, ": ", err.Error())
+				slog.ErrorCtx(r.Context(), "relayed node update on network", "relayed_node_id", relayedNode.ID.String(), "network", relayedNode.Network, "error", err)
 
 			}
 			h, err := logic.GetHost(relayedNode.HostID.String())
@@ -96,14 +99,14 @@ func deleteRelay(w http.ResponseWriter, r *http.Request) {
 					}
 					node.IsRelay = true // for iot update to recognise that it has to delete relay peer
 					if err = mq.PublishSingleHostPeerUpdate(h, nodes, &node, nil); err != nil {
-						logger.Log(1, "failed to publish peer update to host", h.ID.String(), ": ", err.Error())
+						slog.ErrorCtx(r.Context(), "failed to publish peer update to host", "h_id", h.ID.String(), "error", err)
 					}
 				}
 			}
 		}
-		mq.PublishPeerUpdate()
+		mq.PublishNodePeerUpdate(&node)
 	}()
-	logger.Log(1, r.Header.Get("user"), "deleted relay on node", node.ID.String(), "on network", node.Network)
+	slog.InfoCtx(r.Context(), "deleted relay on node on network", "user", r.Header.Get("user"), "node_id", node.ID.String(), "network", node.Network)
 	apiNode := node.ConvertToAPINode()
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(apiNode)
@@ -0,0 +1,84 @@
+package ee_controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/mq"
+	"golang.org/x/exp/slog"
+)
+
+// SuggestionHandlers - suggestions of relays and failovers from the metrics of a network
+func SuggestionHandlers(r *mux.Router) {
+	r.HandleFunc("/api/networks/{networkname}/suggestions", logic.SecurityCheck(true, http.HandlerFunc(getNetworkSuggestions))).Methods(http.MethodGet)
+	r.HandleFunc("/api/networks/{networkname}/suggestions/{suggestionid}/apply", logic.SecurityCheck(true, http.HandlerFunc(applySuggestion))).Methods(http.MethodPost)
+}
+
+// swagger:route GET /api/networks/{networkname}/suggestions networks getNetworkSuggestions
+//
+// Suggest relays and failovers for the nodes of a network that can't reach some of their peers.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: nodeSuggestionsResponse
+func getNetworkSuggestions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	network := mux.Vars(r)["networkname"]
+	if _, err := logic.GetNetwork(network); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	suggestions, err := logic.GetNetworkSuggestions(network)
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to get suggestions of network", "user", r.Header.Get("user"), "network", network, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(suggestions)
+}
+
+// swagger:route POST /api/networks/{networkname}/suggestions/{suggestionid}/apply networks applySuggestion
+//
+// Make the candidate of a suggestion the relay of its node, or a failover of the network.
+//
+//			Schemes: https
+//
+//			Security:
+//	  		oauth
+//
+//			Responses:
+//				200: nodeResponse
+func applySuggestion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var params = mux.Vars(r)
+	network := params["networkname"]
+	suggestion, node, err := logic.ApplySuggestion(network, params["suggestionid"])
+	if err != nil {
+		slog.ErrorCtx(r.Context(), "failed to apply suggestion on network", "user", r.Header.Get("user"), "suggestionid", params["suggestionid"], "network", network, "error", err)
+		switch {
+		case errors.Is(err, logic.ErrSuggestionNotFound):
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		case errors.Is(err, logic.ErrNetworkFeatureDisabled):
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "forbidden"))
+		default:
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		}
+		return
+	}
+	go func() {
+		if err := mq.PublishNetworkPeerUpdate(network); err != nil {
+			slog.ErrorCtx(r.Context(), "failed to publish peer update after applying suggestion", "suggestion_id", suggestion.ID, "error", err)
+		}
+	}()
+	slog.InfoCtx(r.Context(), "applied suggestion on network", "user", r.Header.Get("user"), "kind", suggestion.Kind, "candidate_name", suggestion.CandidateName, "node_name", suggestion.NodeName, "network", network)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(node.ConvertToAPINode())
+}
@@ -5,8 +5,8 @@ import (
 	"errors"
 	"net/http"
 
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
+	"golang.org/x/exp/slog"
 
 	"github.com/gorilla/mux"
 	"github.com/gravitl/netmaker/logic/pro"
@@ -14,14 +14,14 @@ import (
 )
 
 func UserGroupsHandlers(r *mux.Router) {
-	r.HandleFunc("/api/usergroups", logic.SecurityCheck(true, http.HandlerFunc(getUserGroups))).Methods(http.MethodGet)
-	r.HandleFunc("/api/usergroups/{usergroup}", logic.SecurityCheck(true, http.HandlerFunc(createUserGroup))).Methods(http.MethodPost)
-	r.HandleFunc("/api/usergroups/{usergroup}", logic.SecurityCheck(true, http.HandlerFunc(deleteUserGroup))).Methods(http.MethodDelete)
+	r.HandleFunc("/api/usergroups", logic.SuperAdminCheck(http.HandlerFunc(getUserGroups))).Methods(http.MethodGet)
+	r.HandleFunc("/api/usergroups/{usergroup}", logic.SuperAdminCheck(http.HandlerFunc(createUserGroup))).Methods(http.MethodPost)
+	r.HandleFunc("/api/usergroups/{usergroup}", logic.SuperAdminCheck(http.HandlerFunc(deleteUserGroup))).Methods(http.MethodDelete)
 }
 
 func getUserGroups(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	logger.Log(1, r.Header.Get("user"), "requested fetching user groups")
+	slog.InfoCtx(r.Context(), "requested fetching user groups", "user", r.Header.Get("user"))
 
 	userGroups, err := pro.GetUserGroups()
 	if err != nil {
@@ -38,7 +38,7 @@ func createUserGroup(w http.ResponseWriter, r *http.Request) {
 	var params = mux.Vars(r)
 	newGroup := params["usergroup"]
 
-	logger.Log(1, r.Header.Get("user"), "requested creating user group", newGroup)
+	slog.InfoCtx(r.Context(), "requested creating user group", "user", r.Header.Get("user"), "new_group", newGroup)
 
 	if newGroup == "" {
 		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("no group name provided"), "badrequest"))
@@ -57,7 +57,7 @@ func createUserGroup(w http.ResponseWriter, r *http.Request) {
 func deleteUserGroup(w http.ResponseWriter, r *http.Request) {
 	var params = mux.Vars(r)
 	groupToDelete := params["usergroup"]
-	logger.Log(1, r.Header.Get("user"), "requested deleting user group", groupToDelete)
+	slog.InfoCtx(r.Context(), "requested deleting user group", "user", r.Header.Get("user"), "group_to_delete", groupToDelete)
 
 	if groupToDelete == "" {
 		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("no group name provided"), "badrequest"))
@@ -28,6 +28,8 @@ func InitEE() {
 		ee_controllers.NetworkUsersHandlers,
 		ee_controllers.UserGroupsHandlers,
 		ee_controllers.RelayHandlers,
+		ee_controllers.SuggestionHandlers,
+		ee_controllers.GrafanaHandlers,
 	)
 	logic.EnterpriseCheckFuncs = append(logic.EnterpriseCheckFuncs, func() {
 		// == License Handling ==
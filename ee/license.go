@@ -34,10 +34,12 @@ type apiServerConf struct {
 // AddLicenseHooks - adds the validation and cache clear hooks
 func AddLicenseHooks() {
 	logic.HookManagerCh <- models.HookDetails{
+		Name:     "license_validation",
 		Hook:     ValidateLicense,
 		Interval: time.Hour,
 	}
 	logic.HookManagerCh <- models.HookDetails{
+		Name:     "license_cache",
 		Hook:     ClearLicenseCache,
 		Interval: time.Hour,
 	}
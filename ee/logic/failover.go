@@ -2,13 +2,18 @@ package logic
 
 import (
 	"github.com/google/uuid"
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
 )
 
 // SetFailover - finds a suitable failover candidate and sets it
 func SetFailover(node *models.Node) error {
+	unlock, err := logic.Lock(logic.GatewayLockName(node.Network))
+	if err != nil {
+		return err
+	}
+	defer unlock()
 	failoverNode := determineFailoverCandidate(node)
 	if failoverNode != nil {
 		return setFailoverNode(failoverNode, node)
@@ -26,11 +31,11 @@ func ResetFailover(network string) error {
 		node := node
 		err = SetFailover(&node)
 		if err != nil {
-			logger.Log(2, "error setting failover for node", node.ID.String(), ":", err.Error())
+			slog.Warn("error setting failover for node", "node_id", node.ID.String(), "error", err)
 		}
 		err = WipeFailover(node.ID.String())
 		if err != nil {
-			logger.Log(2, "error wiping failover for node", node.ID.String(), ":", err.Error())
+			slog.Warn("error wiping failover for node", "node_id", node.ID.String(), "error", err)
 		}
 	}
 	return nil
@@ -53,7 +58,7 @@ func determineFailoverCandidate(nodeToBeRelayed *models.Node) *models.Node {
 	minLatency := int64(9223372036854775807) // max signed int64 value
 	var fastestCandidate *models.Node
 	for i := range currentNetworkNodes {
-		if currentNetworkNodes[i].ID == nodeToBeRelayed.ID {
+		if currentNetworkNodes[i].ID == nodeToBeRelayed.ID || currentNetworkNodes[i].Ephemeral {
 			continue
 		}
 
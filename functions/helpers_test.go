@@ -7,10 +7,10 @@ import (
 	"testing"
 
 	"github.com/gravitl/netmaker/database"
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/models"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/slog"
 )
 
 var (
@@ -37,7 +37,7 @@ func TestMain(m *testing.M) {
 	go func() {
 		for update := range peerUpdate {
 			//do nothing
-			logger.Log(3, "received node update", update.Action)
+			slog.Debug("received node update", "action", update.Action)
 		}
 	}()
 	os.Exit(m.Run())
@@ -3,8 +3,8 @@ package functions
 import (
 	"os"
 
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
+	"golang.org/x/exp/slog"
 )
 
 // LINUX_APP_DATA_PATH - linux path
@@ -31,7 +31,7 @@ func SetDNSDir() error {
 		err = os.MkdirAll(dir+"/config/dnsconfig", 0744)
 	}
 	if err != nil {
-		logger.Log(0, "couldnt find or create /config/dnsconfig")
+		slog.Info("couldnt find or create /config/dnsconfig")
 		return err
 	}
 
@@ -39,7 +39,7 @@ func SetDNSDir() error {
 	if os.IsNotExist(err) {
 		err = logic.SetCorefile(".")
 		if err != nil {
-			logger.Log(0, err.Error())
+			slog.Error(err.Error())
 		}
 	}
 
@@ -47,7 +47,7 @@ func SetDNSDir() error {
 	if os.IsNotExist(err) {
 		_, err = os.Create(dir + "/config/dnsconfig/netmaker.hosts")
 		if err != nil {
-			logger.Log(0, err.Error())
+			slog.Error(err.Error())
 		}
 	}
 	return nil
@@ -35,7 +35,7 @@ func Log(verbosity int, message ...string) {
 	mu.Lock()
 	defer mu.Unlock()
 	var currentTime = time.Now()
-	var currentMessage = MakeString(" ", message...)
+	var currentMessage = RedactString(MakeString(" ", message...))
 
 	if getVerbose() >= 4 {
 		pc, file, line, ok := runtime.Caller(1)
@@ -55,7 +55,9 @@ func Log(verbosity int, message ...string) {
 			filepath.Base(file), line, fnName, currentMessage)
 	}
 
-	if int32(verbosity) <= getVerbose() && getVerbose() >= 0 {
+	if structured != nil {
+		logStructured(verbosity, currentMessage)
+	} else if int32(verbosity) <= getVerbose() && getVerbose() >= 0 {
 		fmt.Printf("[%s] %s %s \n", program, currentTime.Format(TimeFormat), currentMessage)
 	}
 
@@ -0,0 +1,36 @@
+package logger
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Redacted - what secrets are replaced with in logs
+const Redacted = "[redacted]"
+
+// secretNames - parts of field and attribute names that hold secrets
+var secretNames = []string{"password", "passwd", "secret", "privatekey", "private_key", "token", "hostpass", "apikey", "api_key"}
+
+var (
+	// jwtPattern - JSON web tokens, such as user and host auth tokens
+	jwtPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	// bearerPattern - credentials of an Authorization header
+	bearerPattern = regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9._~+/=-]+`)
+)
+
+// IsSecretName - whether a field or attribute name looks like it holds a secret
+func IsSecretName(name string) bool {
+	name = strings.ToLower(name)
+	for _, secret := range secretNames {
+		if strings.Contains(name, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactString - replaces the auth tokens in a log message
+func RedactString(message string) string {
+	message = jwtPattern.ReplaceAllString(message, Redacted)
+	return bearerPattern.ReplaceAllString(message, "$1 "+Redacted)
+}
@@ -0,0 +1,238 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/slog"
+)
+
+// DefaultComponent - name used to set the level of components without their own level
+const DefaultComponent = "default"
+
+type requestIDKey struct{}
+
+var (
+	levelsMu     sync.RWMutex
+	defaultLevel = &slog.LevelVar{}
+	levels       = make(map[string]*slog.LevelVar)
+	components   sync.Map // pc -> component name
+	structured   slog.Handler
+)
+
+// Setup - configures the default slog logger to write structured (JSON or text) records to w,
+// filtering them by the level of the component (package) that emitted them
+func Setup(w io.Writer, json bool, level slog.Level) {
+	replace := func(groups []string, a slog.Attr) slog.Attr {
+		if a.Key == slog.SourceKey {
+			a.Value = slog.StringValue(filepath.Base(a.Value.String()))
+		} else if IsSecretName(a.Key) {
+			a.Value = slog.StringValue(Redacted)
+		} else if a.Value.Kind() == slog.KindString {
+			a.Value = slog.StringValue(RedactString(a.Value.String()))
+		}
+		return a
+	}
+	opts := &slog.HandlerOptions{AddSource: true, ReplaceAttr: replace, Level: slog.LevelDebug}
+	var base slog.Handler
+	if json {
+		base = slog.NewJSONHandler(w, opts)
+	} else {
+		base = slog.NewTextHandler(w, opts)
+	}
+	defaultLevel.Set(level)
+	structured = &componentHandler{next: base}
+	slog.SetDefault(slog.New(structured))
+}
+
+// ParseLevel - parses a level name (debug, info, warn, error)
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(name))
+	return level, err
+}
+
+// SetLevel - sets the log level of a component at runtime, DefaultComponent sets the fallback level
+func SetLevel(component string, level slog.Level) {
+	if component == "" || component == DefaultComponent {
+		defaultLevel.Set(level)
+		return
+	}
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
+	if _, ok := levels[component]; !ok {
+		levels[component] = &slog.LevelVar{}
+	}
+	levels[component].Set(level)
+}
+
+// ResetLevel - removes the level override of a component so it uses the default level again
+func ResetLevel(component string) {
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
+	delete(levels, component)
+}
+
+// GetLevels - returns the current level of every component with an override, plus the default
+func GetLevels() map[string]string {
+	levelsMu.RLock()
+	defer levelsMu.RUnlock()
+	current := map[string]string{DefaultComponent: defaultLevel.Level().String()}
+	for component, level := range levels {
+		current[component] = level.Level().String()
+	}
+	return current
+}
+
+// SetLevels - applies a comma separated list of component=level pairs, eg. "mq=debug,logic=warn"
+func SetLevels(spec string) error {
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		component, name, found := strings.Cut(pair, "=")
+		if !found {
+			return fmt.Errorf("invalid log level %q, expected component=level", pair)
+		}
+		level, err := ParseLevel(strings.TrimSpace(name))
+		if err != nil {
+			return err
+		}
+		SetLevel(strings.TrimSpace(component), level)
+	}
+	return nil
+}
+
+// WithRequestID - returns a context that tags log records with the given request id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID - returns the request id stored in the context, if any
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// == private ==
+
+// componentHandler - filters records by the level of the package that emitted them
+// and adds request and trace ids from the context
+type componentHandler struct {
+	next slog.Handler
+}
+
+func (h *componentHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	// the emitting component is only known once the record is built
+	return level >= minLevel()
+}
+
+func (h *componentHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < componentLevel(componentOf(r.PC)) {
+		return nil
+	}
+	return h.handle(ctx, r)
+}
+
+// handle - writes the record without any level checks
+func (h *componentHandler) handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if ctx != nil {
+		if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+			r.AddAttrs(slog.String("trace_id", sc.TraceID().String()))
+		}
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &componentHandler{next: h.next.WithAttrs(attrs)}
+}
+
+func (h *componentHandler) WithGroup(name string) slog.Handler {
+	return &componentHandler{next: h.next.WithGroup(name)}
+}
+
+// logStructured - emits a logger.Log message through the structured handler,
+// components with an explicit level are filtered by it instead of the verbosity
+func logStructured(verbosity int, message string) {
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:]) // skip Callers, logStructured and Log
+	level := slog.LevelInfo
+	if verbosity >= 3 {
+		level = slog.LevelDebug
+	}
+	component := componentOf(pcs[0])
+	levelsMu.RLock()
+	override, ok := levels[component]
+	levelsMu.RUnlock()
+	if ok {
+		if level < override.Level() {
+			return
+		}
+	} else if int32(verbosity) > getVerbose() || getVerbose() < 0 {
+		return
+	}
+	r := slog.NewRecord(time.Now(), level, message, pcs[0])
+	structured.(*componentHandler).handle(context.Background(), r)
+}
+
+// minLevel - lowest level any component currently logs at
+func minLevel() slog.Level {
+	levelsMu.RLock()
+	defer levelsMu.RUnlock()
+	min := defaultLevel.Level()
+	for _, level := range levels {
+		if level.Level() < min {
+			min = level.Level()
+		}
+	}
+	return min
+}
+
+func componentLevel(component string) slog.Level {
+	levelsMu.RLock()
+	defer levelsMu.RUnlock()
+	if level, ok := levels[component]; ok {
+		return level.Level()
+	}
+	return defaultLevel.Level()
+}
+
+// componentOf - returns the name of the package a program counter belongs to, eg. "logic" or "mq"
+func componentOf(pc uintptr) string {
+	if pc == 0 {
+		return DefaultComponent
+	}
+	if component, ok := components.Load(pc); ok {
+		return component.(string)
+	}
+	component := DefaultComponent
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		component = packageName(fn.Name())
+	}
+	components.Store(pc, component)
+	return component
+}
+
+// packageName - extracts the last element of the package path from a fully qualified function name
+func packageName(funcName string) string {
+	name := funcName[strings.LastIndex(funcName, "/")+1:]
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
@@ -0,0 +1,174 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
+)
+
+// allowlistBypassDuration - how long a user recovered with the break-glass token may use the api from anywhere
+const allowlistBypassDuration = time.Hour
+
+var (
+	// ErrAPISourceNotAllowed - the request comes from outside the api allowlists of the server or the user
+	ErrAPISourceNotAllowed = errors.New("api access is not allowed from this address")
+	// ErrAllowlistLockout - an allowlist would leave out the address the change is made from
+	ErrAllowlistLockout = errors.New("the allowlist does not include the address of this request")
+
+	allowlistBypassMutex sync.Mutex
+	// allowlistBypasses - until when each user recovered with the break-glass token skips the allowlists
+	allowlistBypasses = map[string]time.Time{}
+)
+
+// GetAPIAllowlist - the api allowlist of a user, the error is a not found error when they have none
+func GetAPIAllowlist(username string) (models.APIAllowlist, error) {
+	var allowlist models.APIAllowlist
+	record, err := database.FetchRecord(database.API_ALLOWLISTS_TABLE_NAME, username)
+	if err != nil {
+		return allowlist, err
+	}
+	err = json.Unmarshal([]byte(record), &allowlist)
+	return allowlist, err
+}
+
+// SetAPIAllowlist - validates and stores the api allowlist of a user
+func SetAPIAllowlist(allowlist *models.APIAllowlist) error {
+	if err := validator.New().Struct(allowlist); err != nil {
+		return err
+	}
+	cidrs := []string{}
+	for _, cidr := range allowlist.CIDRs {
+		normalized, err := NormalizeCIDR(cidr)
+		if err != nil {
+			return err
+		}
+		cidrs = append(cidrs, normalized)
+	}
+	allowlist.CIDRs = cidrs
+	data, err := json.Marshal(allowlist)
+	if err != nil {
+		return err
+	}
+	return database.Insert(allowlist.User, string(data), database.API_ALLOWLISTS_TABLE_NAME)
+}
+
+// DeleteAPIAllowlist - lets a user use the api from anywhere the server's allowlist permits
+func DeleteAPIAllowlist(username string) error {
+	if err := database.DeleteRecord(database.API_ALLOWLISTS_TABLE_NAME, username); err != nil && !database.IsEmptyRecord(err) {
+		return err
+	}
+	return nil
+}
+
+// CheckAPIAllowlist - whether a user may use the api from the source of a request,
+// it has to be within the server's allowlist and the user's own when they are set
+func CheckAPIAllowlist(r *http.Request, username string) error {
+	if hasAllowlistBypass(username) {
+		return nil
+	}
+	source := APISourceIP(r)
+	if !cidrsContain(servercfg.GetAPIAllowlist(), source) {
+		slog.Warn("api request from outside the server allowlist", "user", username, "source", source.String(), "path", r.URL.Path)
+		return ErrAPISourceNotAllowed
+	}
+	if allowlist, err := GetAPIAllowlist(username); err == nil && !cidrsContain(allowlist.CIDRs, source) {
+		slog.Warn("api request from outside the user's allowlist", "user", username, "source", source.String(), "path", r.URL.Path)
+		return ErrAPISourceNotAllowed
+	}
+	return nil
+}
+
+// IsAPISourceAllowed - whether the source of a request is within cidrs, to refuse allowlists that lock out their author
+func IsAPISourceAllowed(cidrs []string, r *http.Request) bool {
+	return cidrsContain(cidrs, APISourceIP(r))
+}
+
+// GrantAllowlistBypass - lets a user recovered with the break-glass token past the api allowlists for a while,
+// so they can fix allowlists that locked everyone out
+func GrantAllowlistBypass(username string) {
+	allowlistBypassMutex.Lock()
+	defer allowlistBypassMutex.Unlock()
+	allowlistBypasses[username] = time.Now().Add(allowlistBypassDuration)
+	slog.Warn("api allowlists bypassed after recovery", "user", username, "until", allowlistBypasses[username])
+}
+
+// APISourceIP - the address an api request comes from; behind one of TRUSTED_PROXIES it's the last address of
+// X-Forwarded-For that isn't a trusted proxy, as each proxy appends the one it saw and the client can forge the rest
+func APISourceIP(r *http.Request) net.IP {
+	remote := peerIP(r)
+	if !isTrustedProxy(remote) {
+		return remote
+	}
+	proxies := servercfg.GetTrustedProxies()
+	forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if ip == nil {
+			break
+		}
+		remote = ip
+		if !cidrsContain(proxies, ip) {
+			break
+		}
+	}
+	return remote
+}
+
+// IsFromTrustedProxy - whether a request was made by one of TRUSTED_PROXIES, so the headers it set can be believed
+func IsFromTrustedProxy(r *http.Request) bool {
+	return isTrustedProxy(peerIP(r))
+}
+
+// == private ==
+
+// peerIP - the address of the connection a request came in on
+func peerIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// isTrustedProxy - whether ip is one of TRUSTED_PROXIES, never when none are set
+func isTrustedProxy(ip net.IP) bool {
+	proxies := servercfg.GetTrustedProxies()
+	return ip != nil && len(proxies) > 0 && cidrsContain(proxies, ip)
+}
+
+// cidrsContain - whether an ip is within one of cidrs, an empty list contains every ip
+func cidrsContain(cidrs []string, ip net.IP) bool {
+	if len(cidrs) == 0 {
+		return true
+	}
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range cidrs {
+		if _, network, err := net.ParseCIDR(cidr); err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAllowlistBypass(username string) bool {
+	allowlistBypassMutex.Lock()
+	defer allowlistBypassMutex.Unlock()
+	until, ok := allowlistBypasses[username]
+	if ok && time.Now().After(until) {
+		delete(allowlistBypasses, username)
+		return false
+	}
+	return ok
+}
@@ -0,0 +1,46 @@
+package logic
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckAPIAllowlist(t *testing.T) {
+	t.Setenv("API_ALLOWLIST", "203.0.113.0/24, 10.0.0.0/8")
+	request := httptest.NewRequest("GET", "/api/networks", nil)
+	request.RemoteAddr = "203.0.113.7:40000"
+	assert.Nil(t, CheckAPIAllowlist(request, "allowlisted"))
+
+	request.RemoteAddr = "198.51.100.7:40000"
+	assert.ErrorIs(t, CheckAPIAllowlist(request, "allowlisted"), ErrAPISourceNotAllowed)
+
+	t.Run("ForwardedByProxy", func(t *testing.T) {
+		t.Setenv("TRUSTED_PROXIES", "127.0.0.1/32")
+		proxied := httptest.NewRequest("GET", "/api/networks", nil)
+		proxied.RemoteAddr = "127.0.0.1:40000"
+		// the client forged the first address, the proxy appended the real one
+		proxied.Header.Set("X-Forwarded-For", "203.0.113.7, 198.51.100.7")
+		assert.ErrorIs(t, CheckAPIAllowlist(proxied, "allowlisted"), ErrAPISourceNotAllowed)
+		proxied.Header.Set("X-Forwarded-For", "198.51.100.7, 203.0.113.7")
+		assert.Nil(t, CheckAPIAllowlist(proxied, "allowlisted"))
+	})
+	t.Run("UntrustedPeer", func(t *testing.T) {
+		t.Setenv("TRUSTED_PROXIES", "127.0.0.1/32")
+		// a private address that isn't a trusted proxy, eg. a mesh peer, can't forge its source
+		forged := httptest.NewRequest("GET", "/api/networks", nil)
+		forged.RemoteAddr = "192.168.1.7:40000"
+		forged.Header.Set("X-Forwarded-For", "203.0.113.7")
+		assert.ErrorIs(t, CheckAPIAllowlist(forged, "allowlisted"), ErrAPISourceNotAllowed)
+	})
+	t.Run("UserAllowlist", func(t *testing.T) {
+		request.RemoteAddr = "203.0.113.7:40000"
+		assert.Nil(t, SetAPIAllowlist(&models.APIAllowlist{User: "allowlisted", CIDRs: []string{"203.0.113.128/25"}}))
+		assert.ErrorIs(t, CheckAPIAllowlist(request, "allowlisted"), ErrAPISourceNotAllowed)
+		GrantAllowlistBypass("allowlisted")
+		assert.Nil(t, CheckAPIAllowlist(request, "allowlisted"))
+		assert.Nil(t, DeleteAPIAllowlist("allowlisted"))
+	})
+}
@@ -0,0 +1,139 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic/acls"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
+)
+
+var (
+	// ErrSelfApproval - the admin who requested an operation can't approve it too
+	ErrSelfApproval = errors.New("an operation must be approved by another admin than the one who requested it")
+	// ErrApprovalDecided - the request was already approved, rejected or expired
+	ErrApprovalDecided = errors.New("approval request was already decided")
+)
+
+// RequestApproval - holds a sensitive operation until another admin approves it
+func RequestApproval(request *models.ApprovalRequest) error {
+	request.ID = uuid.New().String()
+	request.Requested = time.Now().UTC()
+	request.Expires = request.Requested.Add(servercfg.GetChangeApprovalExpiry())
+	request.Status = models.ApprovalPending
+	return saveApprovalRequest(request)
+}
+
+// GetApprovalRequest - fetches an approval request
+func GetApprovalRequest(id string) (models.ApprovalRequest, error) {
+	var request models.ApprovalRequest
+	data, err := database.FetchRecord(database.APPROVAL_REQUESTS_TABLE_NAME, id)
+	if err != nil {
+		return request, err
+	}
+	if err = json.Unmarshal([]byte(data), &request); err != nil {
+		return request, err
+	}
+	expireApprovalRequest(&request, time.Now().UTC())
+	return request, nil
+}
+
+// GetApprovalRequests - fetches the approval requests of a tenant, or of every tenant when empty, newest first
+func GetApprovalRequests(tenant string) ([]models.ApprovalRequest, error) {
+	requests := []models.ApprovalRequest{}
+	records, err := database.FetchRecords(database.APPROVAL_REQUESTS_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return requests, nil
+		}
+		return requests, err
+	}
+	now := time.Now().UTC()
+	for _, value := range records {
+		var request models.ApprovalRequest
+		if err := json.Unmarshal([]byte(value), &request); err != nil {
+			continue
+		}
+		if tenant != "" && request.Tenant != tenant {
+			continue
+		}
+		expireApprovalRequest(&request, now)
+		requests = append(requests, request)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].Requested.After(requests[j].Requested)
+	})
+	return requests, nil
+}
+
+// DecideApprovalRequest - approves or rejects a pending request, applying an approved one is up to the caller,
+// which records why it failed with SetApprovalError
+func DecideApprovalRequest(id, admin string, approve bool) (models.ApprovalRequest, error) {
+	request, err := GetApprovalRequest(id)
+	if err != nil {
+		return request, err
+	}
+	if request.Status != models.ApprovalPending {
+		return request, ErrApprovalDecided
+	}
+	// the requester may withdraw their own request
+	if approve && request.RequestedBy == admin {
+		return request, ErrSelfApproval
+	}
+	request.Status = models.ApprovalRejected
+	if approve {
+		request.Status = models.ApprovalApproved
+	}
+	request.DecidedBy = admin
+	request.Decided = time.Now().UTC()
+	return request, saveApprovalRequest(&request)
+}
+
+// SetApprovalError - records why an approved operation failed to apply
+func SetApprovalError(request *models.ApprovalRequest, applyErr error) error {
+	request.Error = applyErr.Error()
+	return saveApprovalRequest(request)
+}
+
+// IsDenyAllACL - checks an ACL update leaves no nodes allowed to talk to each other where some were before
+func IsDenyAllACL(current, change acls.ACLContainer) bool {
+	return aclsAllowAny(current) && !aclsAllowAny(change)
+}
+
+// == private ==
+
+func saveApprovalRequest(request *models.ApprovalRequest) error {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	return database.Insert(request.ID, string(data), database.APPROVAL_REQUESTS_TABLE_NAME)
+}
+
+// expireApprovalRequest - marks a pending request past its expiry as expired
+func expireApprovalRequest(request *models.ApprovalRequest, now time.Time) {
+	if request.Status != models.ApprovalPending || now.Before(request.Expires) {
+		return
+	}
+	request.Status = models.ApprovalExpired
+	if err := saveApprovalRequest(request); err != nil {
+		slog.Error("failed to expire approval request", "request_id", request.ID, "error", err)
+	}
+}
+
+func aclsAllowAny(container acls.ACLContainer) bool {
+	for _, acl := range container {
+		for _, value := range acl {
+			if value == acls.Allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic/acls"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApprovalRequests(t *testing.T) {
+	database.DeleteAllRecords(database.APPROVAL_REQUESTS_TABLE_NAME)
+	request := models.ApprovalRequest{Operation: models.ApprovalDeleteNetwork, Target: "skynet", RequestedBy: "alice"}
+	assert.Nil(t, RequestApproval(&request))
+	assert.Equal(t, models.ApprovalPending, request.Status)
+	t.Run("SelfApproval", func(t *testing.T) {
+		_, err := DecideApprovalRequest(request.ID, "alice", true)
+		assert.ErrorIs(t, err, ErrSelfApproval)
+	})
+	t.Run("Approve", func(t *testing.T) {
+		approved, err := DecideApprovalRequest(request.ID, "bob", true)
+		assert.Nil(t, err)
+		assert.Equal(t, models.ApprovalApproved, approved.Status)
+		assert.Equal(t, "bob", approved.DecidedBy)
+		_, err = DecideApprovalRequest(request.ID, "bob", false)
+		assert.ErrorIs(t, err, ErrApprovalDecided)
+	})
+	t.Run("Expired", func(t *testing.T) {
+		expired := models.ApprovalRequest{Operation: models.ApprovalDeleteNetwork, Target: "skynet", RequestedBy: "alice"}
+		assert.Nil(t, RequestApproval(&expired))
+		expired.Expires = time.Now().UTC().Add(-time.Minute)
+		assert.Nil(t, saveApprovalRequest(&expired))
+		_, err := DecideApprovalRequest(expired.ID, "bob", true)
+		assert.ErrorIs(t, err, ErrApprovalDecided)
+		requests, err := GetApprovalRequests("")
+		assert.Nil(t, err)
+		assert.Len(t, requests, 2)
+		assert.Equal(t, models.ApprovalExpired, requests[0].Status)
+	})
+}
+
+func TestIsDenyAllACL(t *testing.T) {
+	current := acls.ACLContainer{"a": acls.ACL{"b": acls.Allowed}, "b": acls.ACL{"a": acls.Allowed}}
+	denied := acls.ACLContainer{"a": acls.ACL{"b": acls.NotAllowed}, "b": acls.ACL{"a": acls.NotAllowed}}
+	assert.True(t, IsDenyAllACL(current, denied))
+	assert.False(t, IsDenyAllACL(denied, denied))
+	assert.False(t, IsDenyAllACL(current, acls.ACLContainer{"a": acls.ACL{"b": acls.NotAllowed}, "b": acls.ACL{"a": acls.Allowed}}))
+}
@@ -0,0 +1,171 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+// auditLogRetentionDays - how long the changes users made are kept
+const auditLogRetentionDays = 400
+
+// ErrAuditorAdmin - a user is either an admin or a read-only auditor
+var ErrAuditorAdmin = errors.New("an admin can not be an auditor")
+
+// IsAuditor - checks if a user has the read-only auditor role
+func IsAuditor(username string) bool {
+	user, err := GetUser(username)
+	if err != nil {
+		return false
+	}
+	return user.IsAuditor
+}
+
+// SetUserAuditor - grants or revokes the read-only auditor role of a user
+func SetUserAuditor(user *models.User, auditor bool) error {
+	if auditor && user.IsAdmin {
+		return ErrAuditorAdmin
+	}
+	user.IsAuditor = auditor
+	if auditor {
+		// auditors read every network of their tenant, they aren't given any to manage
+		user.Networks = nil
+	}
+	data, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	return database.Insert(user.UserName, string(data), database.USERS_TABLE_NAME)
+}
+
+// RecordAudit - stores a change a user made through the api
+func RecordAudit(entry models.AuditEntry) {
+	entry.ID = uuid.New().String()
+	entry.Path = RedactPath(entry.Path)
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if err := database.Insert(entry.ID, string(data), database.AUDIT_LOGS_TABLE_NAME); err != nil {
+		slog.Error("failed to record audit log", "user", entry.User, "path", entry.Path, "error", err)
+	}
+	name := entry.Method + " " + entry.Path
+	if entry.Impersonator != "" {
+		name += " by " + entry.Impersonator
+	}
+	outcome := "success"
+	if entry.Status >= http.StatusBadRequest {
+		outcome = "failure"
+	}
+	ShipSIEMEvent(models.SIEMEvent{
+		Time:    entry.Time,
+		Kind:    SIEMEventAudit,
+		Name:    name,
+		User:    entry.User,
+		Tenant:  entry.Tenant,
+		Source:  siemSource(entry.RemoteAddr),
+		Outcome: outcome,
+		Method:  entry.Method,
+		Path:    entry.Path,
+		Status:  entry.Status,
+	})
+}
+
+// GetAuditLogs - fetches the changes made between from and to, oldest first, limited to a tenant if one is given
+func GetAuditLogs(from, to time.Time, tenant string) ([]models.AuditEntry, error) {
+	entries := []models.AuditEntry{}
+	records, err := database.FetchRecords(database.AUDIT_LOGS_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return entries, nil
+		}
+		return nil, err
+	}
+	for _, value := range records {
+		var entry models.AuditEntry
+		if err := json.Unmarshal([]byte(value), &entry); err != nil {
+			continue
+		}
+		if entry.Time.Before(from) || entry.Time.After(to) {
+			continue
+		}
+		if tenant != "" && entry.Tenant != tenant {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	return entries, nil
+}
+
+// == private ==
+
+// isReadOnlyRequest - checks a request can't change anything, the only kind auditors may make
+func isReadOnlyRequest(r *http.Request) bool {
+	return r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions
+}
+
+// auditResponseWriter - keeps the status written so the audit log records whether a change succeeded
+type auditResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *auditResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// serveAudited - serves a request, recording it in the audit log unless it is read-only;
+// requests made acting as another user are always recorded
+func serveAudited(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	if isReadOnlyRequest(r) && r.Header.Get(ImpersonatorHeader) == "" {
+		next.ServeHTTP(w, r)
+		return
+	}
+	recorder := &auditResponseWriter{ResponseWriter: w, status: http.StatusOK}
+	next.ServeHTTP(recorder, r)
+	RecordAudit(models.AuditEntry{
+		User:         r.Header.Get("user"),
+		Host:         r.Header.Get(HostIDHeader),
+		Tenant:       r.Header.Get("tenant"),
+		Method:       r.Method,
+		Path:         r.URL.Path,
+		Status:       recorder.status,
+		RemoteAddr:   r.RemoteAddr,
+		Impersonator: r.Header.Get(ImpersonatorHeader),
+	})
+}
+
+// pruneAuditLogs - removes audit logs past their retention period, run by the daily hooks
+func pruneAuditLogs() error {
+	records, err := database.FetchRecords(database.AUDIT_LOGS_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return nil
+		}
+		return err
+	}
+	cutoff := time.Now().UTC().AddDate(0, 0, -auditLogRetentionDays)
+	for key, value := range records {
+		var entry models.AuditEntry
+		if err := json.Unmarshal([]byte(value), &entry); err != nil || entry.Time.Before(cutoff) {
+			if err := database.DeleteRecord(database.AUDIT_LOGS_TABLE_NAME, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
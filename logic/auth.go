@@ -8,9 +8,9 @@ import (
 
 	"github.com/go-playground/validator/v10"
 	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/exp/slog"
 
 	"github.com/gravitl/netmaker/database"
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic/pro"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/models/promodels"
@@ -76,6 +76,15 @@ func CreateUser(user *models.User) error {
 	if err != nil {
 		return err
 	}
+	if err = RunPreUserCreateHooks(user); err != nil {
+		return err
+	}
+	if user.IsAuditor {
+		if user.IsAdmin {
+			return ErrAuditorAdmin
+		}
+		user.Networks = nil
+	}
 
 	// encrypt that password so we never see it again
 	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), 5)
@@ -134,7 +143,7 @@ func CreateUser(user *models.User) error {
 		}
 		userErr := pro.CreateNetworkUser(&currentNets[i], &newUser)
 		if userErr != nil {
-			logger.Log(0, "failed to add network user data on network", currentNets[i].NetID, "for user", user.UserName)
+			slog.Error("failed to add network user data on network for user", "current_net_net_id", currentNets[i].NetID, "user_name", user.UserName)
 		}
 	}
 	// == END PRO ==
@@ -178,6 +187,9 @@ func VerifyAuthRequest(authRequest models.UserAuthParams) (string, error) {
 	if err = bcrypt.CompareHashAndPassword([]byte(result.Password), []byte(authRequest.Password)); err != nil {
 		return "", errors.New("incorrect credentials")
 	}
+	if result.Pending {
+		return "", ErrEmailNotVerified
+	}
 
 	// Create a new JWT for the node
 	tokenString, _ := CreateProUserJWT(authRequest.UserName, result.Networks, result.Groups, result.IsAdmin)
@@ -213,7 +225,7 @@ func UpdateUserNetworks(newNetworks, newGroups []string, isadmin bool, currentUs
 					if network.ProSettings != nil {
 						ok := pro.AssignAccessLvl(n, currentUser.UserName, network.ProSettings.DefaultAccessLevel)
 						if ok {
-							logger.Log(0, "changed", currentUser.UserName, "access level on network", network.NetID, "to", fmt.Sprintf("%d", network.ProSettings.DefaultAccessLevel))
+							slog.Info("changed access level on network", "user_name", currentUser.UserName, "net_id", network.NetID, "default_access_level", network.ProSettings.DefaultAccessLevel)
 						}
 					}
 				}
@@ -221,7 +233,7 @@ func UpdateUserNetworks(newNetworks, newGroups []string, isadmin bool, currentUs
 		}
 
 		if err := AdjustGroupPermissions(currentUser); err != nil {
-			logger.Log(0, "failed to update user", currentUser.UserName, "after group update", err.Error())
+			slog.Error("failed to update user after group update", "user_name", currentUser.UserName, "error", err)
 		}
 		// == END PRO ==
 
@@ -272,9 +284,28 @@ func UpdateUser(userchange, user *models.User) (*models.User, error) {
 		user.Password = userchange.Password
 	}
 
+	if userchange.DisplayName != "" {
+		user.DisplayName = userchange.DisplayName
+	}
+	if userchange.Email != "" {
+		user.Email = userchange.Email
+	}
+	if userchange.Department != "" {
+		user.Department = userchange.Department
+	}
+	if userchange.AvatarURL != "" {
+		user.AvatarURL = userchange.AvatarURL
+	}
+	if userchange.Metadata != nil {
+		user.Metadata = userchange.Metadata
+	}
+
 	if (userchange.IsAdmin != user.IsAdmin) && !user.IsAdmin {
 		user.IsAdmin = userchange.IsAdmin
 	}
+	if user.IsAdmin {
+		user.IsAuditor = false
+	}
 
 	err := ValidateUser(user)
 	if err != nil {
@@ -291,7 +322,7 @@ func UpdateUser(userchange, user *models.User) (*models.User, error) {
 	if err = database.Insert(user.UserName, string(data), database.USERS_TABLE_NAME); err != nil {
 		return &models.User{}, err
 	}
-	logger.Log(1, "updated user", queryUser)
+	slog.Info("updated user", "query_user", queryUser)
 	return user, nil
 }
 
@@ -307,7 +338,7 @@ func ValidateUser(user *models.User) error {
 
 	if err != nil {
 		for _, e := range err.(validator.ValidationErrors) {
-			logger.Log(2, e.Error())
+			slog.Warn(e.Error())
 		}
 	}
 
@@ -339,7 +370,7 @@ func DeleteUser(user string) (bool, error) {
 	for i := range currentNets {
 		netID := currentNets[i].NetID
 		if err = pro.DeleteNetworkUser(netID, user); err != nil {
-			logger.Log(0, "failed to remove", user, "as network user from network", netID, err.Error())
+			slog.Error("failed to remove as network user from network", "user", user, "net_id", netID, "error", err)
 		}
 	}
 
@@ -398,12 +429,12 @@ func SetState(state string) error {
 func IsStateValid(state string) (string, bool) {
 	s, err := GetState(state)
 	if err != nil {
-		logger.Log(2, "error retrieving oauth state:", err.Error())
+		slog.Warn("error retrieving oauth state", "error", err)
 		return "", false
 	}
 	if s.Value != "" {
 		if err = delState(state); err != nil {
-			logger.Log(2, "error deleting oauth state:", err.Error())
+			slog.Warn("error deleting oauth state", "error", err)
 			return "", false
 		}
 	}
@@ -0,0 +1,49 @@
+// Package awsauth signs requests to AWS APIs with signature version 4
+package awsauth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Sign - signs req, whose body is payload, for the service in region; the query of req
+// must already be in canonical form, sorted by key with spaces as %20
+func Sign(req *http.Request, payload []byte, region, service, accessKey, secretKey string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	day := now.UTC().Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	payloadHash := sha256.Sum256(payload)
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonical := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\nx-amz-date:" + amzDate + "\n",
+		"host;x-amz-date",
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	scope := day + "/" + region + "/" + service + "/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+	key := hmacSHA256([]byte("AWS4"+secretKey), day)
+	for _, part := range []string{region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=host;x-amz-date, Signature=%s",
+		accessKey, scope, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
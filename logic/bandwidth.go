@@ -0,0 +1,215 @@
+package logic
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+)
+
+// ErrInvalidBandwidthLimit - a limit can't be negative
+var ErrInvalidBandwidthLimit = errors.New("bandwidth limits can't be negative")
+
+// bandwidthRate - bits per second of a limited node or ext client between the two latest metrics reports
+type bandwidthRate struct {
+	upload   int64
+	download int64
+}
+
+var (
+	bandwidthMutex = &sync.Mutex{}
+	// bandwidthRates - kept in memory for the nodes and ext clients with a limit only
+	bandwidthRates = make(map[string]bandwidthRate)
+	// lastMetricsReports - when each node last reported metrics, to turn transfer deltas into rates
+	lastMetricsReports = make(map[string]time.Time)
+)
+
+// SetNodeBandwidthLimit - limits the bandwidth of a node, nil or zero limits remove it
+func SetNodeBandwidthLimit(nodeid string, limit *models.BandwidthLimit) (models.Node, error) {
+	if err := validateBandwidthLimit(limit); err != nil {
+		return models.Node{}, err
+	}
+	node, err := GetNodeByID(nodeid)
+	if err != nil {
+		return models.Node{}, err
+	}
+	if limit.IsUnlimited() {
+		limit = nil
+	}
+	node.BandwidthLimit = limit
+	if err = UpsertNode(&node); err != nil {
+		return models.Node{}, err
+	}
+	return node, nil
+}
+
+// SetExtClientBandwidthLimit - limits the bandwidth of an ext client, shaped by its ingress gateway,
+// nil or zero limits remove it
+func SetExtClientBandwidthLimit(clientid, network string, limit *models.BandwidthLimit) (models.ExtClient, error) {
+	if err := validateBandwidthLimit(limit); err != nil {
+		return models.ExtClient{}, err
+	}
+	client, err := GetExtClient(clientid, network)
+	if err != nil {
+		return models.ExtClient{}, err
+	}
+	if limit.IsUnlimited() {
+		limit = nil
+	}
+	client.BandwidthLimit = limit
+	if err = SaveExtClient(&client); err != nil {
+		return models.ExtClient{}, err
+	}
+	return client, nil
+}
+
+// GetHostBandwidthLimits - the limits a host shapes: those of its nodes and of the ext clients of its ingress gateways
+func GetHostBandwidthLimits(host *models.Host) []models.PeerBandwidthLimit {
+	limits := []models.PeerBandwidthLimit{}
+	for _, nodeID := range host.Nodes {
+		node, err := GetNodeByID(nodeID)
+		if err != nil {
+			continue
+		}
+		if !node.BandwidthLimit.IsUnlimited() {
+			limits = append(limits, models.PeerBandwidthLimit{
+				ID:             nodeID,
+				Kind:           models.TrafficUsageNode,
+				Network:        node.Network,
+				Addresses:      nodeAddresses(&node),
+				BandwidthLimit: *node.BandwidthLimit,
+			})
+		}
+		if !node.IsIngressGateway {
+			continue
+		}
+		clients, err := GetExtClientsByID(nodeID, node.Network)
+		if err != nil {
+			continue
+		}
+		for _, client := range clients {
+			if !client.Enabled || client.BandwidthLimit.IsUnlimited() {
+				continue
+			}
+			addresses := []string{}
+			for _, address := range []string{client.Address, client.Address6} {
+				if address != "" {
+					addresses = append(addresses, address)
+				}
+			}
+			limits = append(limits, models.PeerBandwidthLimit{
+				ID:             client.ClientID,
+				Kind:           models.TrafficUsageExtClient,
+				Network:        client.Network,
+				Addresses:      addresses,
+				BandwidthLimit: *client.BandwidthLimit,
+			})
+		}
+	}
+	return limits
+}
+
+// GetBandwidthUtilization - the current throughput of the limited nodes and ext clients of a network
+// against their limits, most utilized first
+func GetBandwidthUtilization(network string, nodes []models.Node) []models.BandwidthUtilization {
+	utilization := []models.BandwidthUtilization{}
+	for i := range nodes {
+		node := &nodes[i]
+		if node.Network != network || node.BandwidthLimit.IsUnlimited() {
+			continue
+		}
+		name := node.Name
+		if host, err := GetHost(node.HostID.String()); name == "" && err == nil {
+			name = host.Name
+		}
+		utilization = append(utilization, bandwidthUtilization(node.ID.String(), models.TrafficUsageNode, name, node.BandwidthLimit))
+	}
+	if clients, err := GetNetworkExtClients(network); err == nil {
+		for i := range clients {
+			if clients[i].BandwidthLimit.IsUnlimited() {
+				continue
+			}
+			utilization = append(utilization, bandwidthUtilization(clients[i].ClientID, models.TrafficUsageExtClient, clients[i].ClientID, clients[i].BandwidthLimit))
+		}
+	}
+	sort.SliceStable(utilization, func(i, j int) bool {
+		return maxPercent(utilization[i]) > maxPercent(utilization[j])
+	})
+	return utilization
+}
+
+// == private ==
+
+func validateBandwidthLimit(limit *models.BandwidthLimit) error {
+	if limit != nil && (limit.UploadKbps < 0 || limit.DownloadKbps < 0) {
+		return ErrInvalidBandwidthLimit
+	}
+	return nil
+}
+
+func nodeAddresses(node *models.Node) []string {
+	addresses := []string{}
+	if node.Address.IP != nil {
+		addresses = append(addresses, node.Address.String())
+	}
+	if node.Address6.IP != nil {
+		addresses = append(addresses, node.Address6.String())
+	}
+	return addresses
+}
+
+// sinceLastReport - how long since a node last reported metrics, 0 on its first report
+func sinceLastReport(nodeID string, now time.Time) time.Duration {
+	bandwidthMutex.Lock()
+	defer bandwidthMutex.Unlock()
+	last, ok := lastMetricsReports[nodeID]
+	lastMetricsReports[nodeID] = now
+	if !ok {
+		return 0
+	}
+	return now.Sub(last)
+}
+
+// setBandwidthRate - turns the bytes transferred since the last report into a rate, for limited objects only
+func setBandwidthRate(id string, limit *models.BandwidthLimit, uploaded, downloaded int64, elapsed time.Duration) {
+	bandwidthMutex.Lock()
+	defer bandwidthMutex.Unlock()
+	if limit.IsUnlimited() || elapsed <= 0 {
+		delete(bandwidthRates, id)
+		return
+	}
+	bandwidthRates[id] = bandwidthRate{
+		upload:   int64(float64(uploaded*8) / elapsed.Seconds()),
+		download: int64(float64(downloaded*8) / elapsed.Seconds()),
+	}
+}
+
+func bandwidthUtilization(id string, kind models.TrafficUsageKind, name string, limit *models.BandwidthLimit) models.BandwidthUtilization {
+	bandwidthMutex.Lock()
+	rate := bandwidthRates[id]
+	bandwidthMutex.Unlock()
+	utilization := models.BandwidthUtilization{
+		ID:          id,
+		Kind:        kind,
+		Name:        name,
+		Limit:       *limit,
+		UploadBps:   rate.upload,
+		DownloadBps: rate.download,
+	}
+	if limit.UploadKbps > 0 {
+		utilization.UploadPercent = float64(rate.upload) / float64(limit.UploadKbps*1000) * 100
+	}
+	if limit.DownloadKbps > 0 {
+		utilization.DownloadPercent = float64(rate.download) / float64(limit.DownloadKbps*1000) * 100
+	}
+	return utilization
+}
+
+func maxPercent(u models.BandwidthUtilization) float64 {
+	if u.UploadPercent > u.DownloadPercent {
+		return u.UploadPercent
+	}
+	return u.DownloadPercent
+}
@@ -0,0 +1,29 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBandwidthUtilization(t *testing.T) {
+	limit := &models.BandwidthLimit{UploadKbps: 1000}
+	// 1.25MB uploaded in 10s is 1Mbit/s
+	setBandwidthRate("limited", limit, 1250000, 250000, 10*time.Second)
+	utilization := bandwidthUtilization("limited", models.TrafficUsageNode, "limited", limit)
+	assert.Equal(t, int64(1000000), utilization.UploadBps)
+	assert.Equal(t, int64(200000), utilization.DownloadBps)
+	assert.Equal(t, float64(100), utilization.UploadPercent)
+	assert.Equal(t, float64(0), utilization.DownloadPercent)
+	t.Run("Removed", func(t *testing.T) {
+		setBandwidthRate("limited", nil, 1250000, 250000, 10*time.Second)
+		_, ok := bandwidthRates["limited"]
+		assert.False(t, ok)
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := SetNodeBandwidthLimit("any", &models.BandwidthLimit{UploadKbps: -1})
+		assert.ErrorIs(t, err, ErrInvalidBandwidthLimit)
+	})
+}
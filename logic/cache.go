@@ -0,0 +1,118 @@
+package logic
+
+import (
+	"encoding/json"
+	"sync/atomic"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+)
+
+// cacheStats - hit and miss counters of an in memory cache
+type cacheStats struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+func (c *cacheStats) record(hit bool) {
+	if hit {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+}
+
+func (c *cacheStats) get(name string, size int) models.CacheStats {
+	stats := models.CacheStats{
+		Name:   name,
+		Size:   size,
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
+
+func init() {
+	// keep the caches coherent with every write, whichever code path made it
+	database.OnChange(applyChange)
+}
+
+// GetCacheStats - returns the size and hit rate of the in memory caches
+func GetCacheStats() []models.CacheStats {
+	nodeCacheMutex.RLock()
+	nodes := len(nodesCacheMap)
+	nodeCacheMutex.RUnlock()
+	hostCacheMutex.RLock()
+	hosts := len(hostsCacheMap)
+	hostCacheMutex.RUnlock()
+	extClientCacheMutex.RLock()
+	extClients := len(extClientCacheMap)
+	extClientCacheMutex.RUnlock()
+	networkCacheMutex.RLock()
+	networks := len(networkCacheMap)
+	networkCacheMutex.RUnlock()
+	return []models.CacheStats{
+		nodeCacheStats.get("nodes", nodes),
+		hostCacheStats.get("hosts", hosts),
+		extClientCacheStats.get("extclients", extClients),
+		networkCacheStats.get("networks", networks),
+	}
+}
+
+// == private ==
+
+// applyChange - updates the cache of the changed table, an empty key clears the whole cache
+func applyChange(change database.Change) {
+	switch change.Table {
+	case database.HOSTS_TABLE_NAME:
+		if change.Key == "" {
+			clearHostCache()
+		} else if change.Type == database.ChangeDelete {
+			deleteHostFromCache(change.Key)
+		} else {
+			var host models.Host
+			if err := json.Unmarshal([]byte(change.Value), &host); err == nil {
+				storeHostInCache(host)
+			}
+		}
+	case database.NODES_TABLE_NAME:
+		if change.Key == "" {
+			ClearNodeCache()
+			hostPeerGraph.clear()
+		} else if change.Type == database.ChangeDelete {
+			deleteNodeFromCache(change.Key)
+			hostPeerGraph.deleteNode(change.Key)
+		} else {
+			var node models.Node
+			if err := json.Unmarshal([]byte(change.Value), &node); err == nil {
+				storeNodeInCache(node)
+				hostPeerGraph.storeNode(&node)
+			}
+		}
+	case database.EXT_CLIENT_TABLE_NAME:
+		if change.Key == "" {
+			clearExtClientCache()
+		} else if change.Type == database.ChangeDelete {
+			deleteExtClientFromCache(change.Key)
+		} else {
+			var client models.ExtClient
+			if err := json.Unmarshal([]byte(change.Value), &client); err == nil {
+				storeExtClientInCache(change.Key, client)
+			}
+		}
+	case database.NETWORKS_TABLE_NAME:
+		if change.Key == "" {
+			clearNetworkCache()
+		} else if change.Type == database.ChangeDelete {
+			deleteNetworkFromCache(change.Key)
+		} else {
+			var network models.Network
+			if err := json.Unmarshal([]byte(change.Value), &network); err == nil {
+				storeNetworkInCache(network)
+			}
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package logic
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeCacheCoherency(t *testing.T) {
+	database.InitializeDatabase()
+	ClearNodeCache()
+	before, err := GetAllNodes()
+	assert.Nil(t, err)
+
+	node := models.Node{CommonNode: models.CommonNode{ID: uuid.New(), Network: "cachetest"}}
+	data, err := json.Marshal(&node)
+	assert.Nil(t, err)
+	t.Run("WriteOutsideLogic", func(t *testing.T) {
+		err := database.Insert(node.ID.String(), string(data), database.NODES_TABLE_NAME)
+		assert.Nil(t, err)
+		nodes, err := GetAllNodes()
+		assert.Nil(t, err)
+		assert.Equal(t, len(before)+1, len(nodes))
+		cached, err := GetNodeByID(node.ID.String())
+		assert.Nil(t, err)
+		assert.Equal(t, "cachetest", cached.Network)
+	})
+	t.Run("Delete", func(t *testing.T) {
+		err := database.DeleteRecord(database.NODES_TABLE_NAME, node.ID.String())
+		assert.Nil(t, err)
+		nodes, err := GetAllNodes()
+		assert.Nil(t, err)
+		assert.Equal(t, len(before), len(nodes))
+	})
+	t.Run("PartialCacheIsNotAList", func(t *testing.T) {
+		ClearNodeCache()
+		storeNodeInCache(node)
+		assert.Empty(t, getNodesFromCache())
+	})
+	t.Run("StaleLoad", func(t *testing.T) {
+		ClearNodeCache()
+		version := getNodeCacheVersion()
+		// a write lands between reading the nodes and loading them
+		storeNodeInCache(node)
+		loadNodesIntoCache(map[string]models.Node{}, version)
+		assert.Empty(t, getNodesFromCache())
+	})
+	t.Run("ConcurrentWrites", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				written := node
+				written.Network = fmt.Sprintf("cachetest%d", i)
+				data, _ := json.Marshal(&written)
+				database.Insert(written.ID.String(), string(data), database.NODES_TABLE_NAME)
+			}(i)
+		}
+		wg.Wait()
+		defer database.DeleteRecord(database.NODES_TABLE_NAME, node.ID.String())
+		record, err := database.FetchRecord(database.NODES_TABLE_NAME, node.ID.String())
+		assert.Nil(t, err)
+		var stored models.Node
+		assert.Nil(t, json.Unmarshal([]byte(record), &stored))
+		cached, ok := getNodeFromCache(node.ID.String())
+		assert.True(t, ok)
+		assert.Equal(t, stored.Network, cached.Network)
+	})
+	t.Run("Stats", func(t *testing.T) {
+		var stats cacheStats
+		stats.record(true)
+		stats.record(true)
+		stats.record(false)
+		s := stats.get("test", 1)
+		assert.Equal(t, int64(2), s.Hits)
+		assert.InDelta(t, 0.666, s.HitRate, 0.01)
+	})
+}
@@ -0,0 +1,162 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+)
+
+// cloudClaimAttempts - how often a claim is retried while other enrollments of the instance race it
+const cloudClaimAttempts = 3
+
+var (
+	// ErrNoCloudEnrollmentRule - no rule lets the instance's cloud account enroll
+	ErrNoCloudEnrollmentRule = errors.New("cloud account is not allowed to enroll")
+	// ErrCloudInstanceEnrolled - the instance already enrolled as another host
+	ErrCloudInstanceEnrolled = errors.New("cloud instance is already enrolled as another host")
+)
+
+// CreateCloudEnrollmentRule - allows instances of a cloud account to join a network,
+// the rule belongs to the tenant of the network
+func CreateCloudEnrollmentRule(rule *models.CloudEnrollmentRule) error {
+	if err := validator.New().Struct(rule); err != nil {
+		return err
+	}
+	network, err := GetNetwork(rule.Network)
+	if err != nil {
+		return err
+	}
+	if rule.Tenant != "" && rule.Tenant != network.Tenant {
+		return ErrTenantMismatch
+	}
+	rule.Tenant = network.Tenant
+	if rule.Tags == nil {
+		rule.Tags = []string{}
+	}
+	rule.ID = uuid.New().String()
+	data, err := json.Marshal(rule)
+	if err != nil {
+		return err
+	}
+	return database.Insert(rule.ID, string(data), database.CLOUD_ENROLLMENT_TABLE_NAME)
+}
+
+// GetCloudEnrollmentRules - fetches all cloud enrollment rules
+func GetCloudEnrollmentRules() ([]models.CloudEnrollmentRule, error) {
+	rules := []models.CloudEnrollmentRule{}
+	records, err := database.FetchRecords(database.CLOUD_ENROLLMENT_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return rules, nil
+		}
+		return nil, err
+	}
+	for _, value := range records {
+		var rule models.CloudEnrollmentRule
+		if err := json.Unmarshal([]byte(value), &rule); err != nil {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// GetCloudEnrollmentRule - fetches a cloud enrollment rule
+func GetCloudEnrollmentRule(id string) (models.CloudEnrollmentRule, error) {
+	var rule models.CloudEnrollmentRule
+	record, err := database.FetchRecord(database.CLOUD_ENROLLMENT_TABLE_NAME, id)
+	if err != nil {
+		return rule, err
+	}
+	err = json.Unmarshal([]byte(record), &rule)
+	return rule, err
+}
+
+// DeleteCloudEnrollmentRule - stops a cloud account from enrolling instances,
+// hosts already enrolled stay
+func DeleteCloudEnrollmentRule(id string) error {
+	if _, err := GetCloudEnrollmentRule(id); err != nil {
+		return err
+	}
+	return database.DeleteRecord(database.CLOUD_ENROLLMENT_TABLE_NAME, id)
+}
+
+// CloudEnrollmentKey - builds a single use enrollment key for a verified instance from the rules
+// of its cloud account, joining it to the mapped networks with the rules' tags and the
+// instance's region and zone
+func CloudEnrollmentKey(identity *models.CloudIdentity) (*models.EnrollmentKey, error) {
+	rules, err := GetCloudEnrollmentRules()
+	if err != nil {
+		return nil, err
+	}
+	key := &models.EnrollmentKey{Networks: []string{}, Tags: []string{}, UsesRemaining: 1, Type: models.Uses}
+	for _, rule := range rules {
+		if rule.Provider != identity.Provider || rule.Account != identity.Account {
+			continue
+		}
+		// a host can only be part of one tenant
+		if len(key.Networks) > 0 && rule.Tenant != key.Tenant {
+			continue
+		}
+		key.Tenant = rule.Tenant
+		if !StringSliceContains(key.Networks, rule.Network) {
+			key.Networks = append(key.Networks, rule.Network)
+		}
+		for _, tag := range rule.Tags {
+			if !StringSliceContains(key.Tags, tag) {
+				key.Tags = append(key.Tags, tag)
+			}
+		}
+	}
+	if len(key.Networks) == 0 {
+		return nil, ErrNoCloudEnrollmentRule
+	}
+	for name, value := range map[string]string{"region": identity.Region, "zone": identity.Zone} {
+		if value != "" {
+			key.Tags = append(key.Tags, fmt.Sprintf("%s:%s=%s", identity.Provider, name, value))
+		}
+	}
+	return key, nil
+}
+
+// ClaimCloudInstance - binds a cloud instance to a host so the identity of an instance
+// can't be replayed to enroll other hosts, unless the host it enrolled before is gone
+func ClaimCloudInstance(identity *models.CloudIdentity, hostID string) error {
+	key := fmt.Sprintf("%s###%s###%s", identity.Provider, identity.Account, identity.InstanceID)
+	data, err := json.Marshal(hostID)
+	if err != nil {
+		return err
+	}
+	for attempt := 0; attempt < cloudClaimAttempts; attempt++ {
+		claimed, err := database.InsertNew(key, string(data), database.CLOUD_INSTANCES_TABLE_NAME)
+		if err != nil || claimed {
+			return err
+		}
+		record, err := database.FetchRecord(database.CLOUD_INSTANCES_TABLE_NAME, key)
+		if err != nil {
+			if database.IsEmptyRecord(err) {
+				continue
+			}
+			return err
+		}
+		var current string
+		if err := json.Unmarshal([]byte(record), &current); err == nil {
+			if current == hostID {
+				return nil
+			}
+			if _, err := GetHost(current); err == nil {
+				return ErrCloudInstanceEnrolled
+			}
+		}
+		// the host that claimed the instance is gone, its claim is dropped unless another enrollment replaced it
+		if _, err := database.DeleteIf(database.CLOUD_INSTANCES_TABLE_NAME, key, record); err != nil {
+			return err
+		}
+	}
+	return ErrCloudInstanceEnrolled
+}
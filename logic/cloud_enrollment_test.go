@@ -0,0 +1,31 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClaimCloudInstance(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	database.DeleteAllRecords(database.CLOUD_INSTANCES_TABLE_NAME)
+	identity := &models.CloudIdentity{Provider: "aws", Account: "123456789012", InstanceID: "i-0abc"}
+	enrolled := models.Host{ID: uuid.New()}
+	assert.Nil(t, UpsertHost(&enrolled))
+	defer RemoveHost(&enrolled, true)
+
+	assert.Nil(t, ClaimCloudInstance(identity, enrolled.ID.String()))
+	assert.Nil(t, ClaimCloudInstance(identity, enrolled.ID.String()), "the host enrolling again keeps its claim")
+	assert.ErrorIs(t, ClaimCloudInstance(identity, uuid.NewString()), ErrCloudInstanceEnrolled)
+
+	t.Run("HostGone", func(t *testing.T) {
+		gone := &models.CloudIdentity{Provider: "aws", Account: "123456789012", InstanceID: "i-0def"}
+		assert.Nil(t, ClaimCloudInstance(gone, uuid.NewString()))
+		assert.Nil(t, ClaimCloudInstance(gone, enrolled.ID.String()))
+		assert.ErrorIs(t, ClaimCloudInstance(gone, uuid.NewString()), ErrCloudInstanceEnrolled)
+	})
+}
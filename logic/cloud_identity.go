@@ -0,0 +1,266 @@
+package logic
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+)
+
+const (
+	googleCertsURL = "https://www.googleapis.com/oauth2/v3/certs"
+	azureKeysURL   = "https://login.microsoftonline.com/common/discovery/v2.0/keys"
+	// how long fetched signing keys are trusted before fetching them again
+	signingKeysTTL = time.Hour
+	// signingKeysRetry - how long after fetching, or failing to, an unknown key doesn't make the keys be fetched again,
+	// so tokens with made up key ids can't make every request reach the provider
+	signingKeysRetry = time.Minute
+)
+
+// ErrInvalidCloudIdentity - the identity document or token could not be verified
+var ErrInvalidCloudIdentity = errors.New("invalid cloud identity")
+
+type jsonWebKeys struct {
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+	// attempted - when the keys were last fetched or failed to be
+	attempted time.Time
+}
+
+var (
+	signingKeysMutex sync.Mutex
+	signingKeys      = make(map[string]*jsonWebKeys)
+)
+
+// VerifyCloudIdentity - verifies the signed identity an instance presents with the
+// signing keys of its cloud provider and returns who the instance is
+func VerifyCloudIdentity(req *models.CloudRegisterRequest) (*models.CloudIdentity, error) {
+	var (
+		identity *models.CloudIdentity
+		err      error
+	)
+	switch req.Provider {
+	case models.CloudProviderAWS:
+		identity, err = verifyAWSIdentity(req.Document, req.Signature)
+	case models.CloudProviderGCP:
+		identity, err = verifyGCPIdentity(req.Document)
+	case models.CloudProviderAzure:
+		identity, err = verifyAzureIdentity(req.Document)
+	default:
+		return nil, fmt.Errorf("%w: unknown provider %q", ErrInvalidCloudIdentity, req.Provider)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCloudIdentity, err)
+	}
+	if identity.Account == "" || identity.InstanceID == "" {
+		return nil, fmt.Errorf("%w: missing account or instance", ErrInvalidCloudIdentity)
+	}
+	return identity, nil
+}
+
+// == private ==
+
+// verifyAWSIdentity - checks the SHA256 RSA signature of an EC2 instance identity document
+// against the AWS certificates configured for the server's regions
+func verifyAWSIdentity(document, signature string) (*models.CloudIdentity, error) {
+	certFile := servercfg.GetAWSIdentityCertFile()
+	if certFile == "" {
+		return nil, errors.New("no AWS certificates configured")
+	}
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature))
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256([]byte(document))
+	verified := false
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+		if key, ok := cert.PublicKey.(*rsa.PublicKey); ok && rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], sig) == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, errors.New("identity document signature does not match")
+	}
+	var doc struct {
+		AccountID        string `json:"accountId"`
+		InstanceID       string `json:"instanceId"`
+		Region           string `json:"region"`
+		AvailabilityZone string `json:"availabilityZone"`
+	}
+	if err := json.Unmarshal([]byte(document), &doc); err != nil {
+		return nil, err
+	}
+	return &models.CloudIdentity{
+		Provider:   models.CloudProviderAWS,
+		Account:    doc.AccountID,
+		InstanceID: doc.InstanceID,
+		Region:     doc.Region,
+		Zone:       doc.AvailabilityZone,
+	}, nil
+}
+
+// verifyGCPIdentity - verifies a compute engine identity token, requested by the instance
+// with format=full and the server's enrollment audience
+func verifyGCPIdentity(token string) (*models.CloudIdentity, error) {
+	claims, err := parseCloudToken(token, googleCertsURL)
+	if err != nil {
+		return nil, err
+	}
+	if iss, _ := claims["iss"].(string); iss != "https://accounts.google.com" && iss != "accounts.google.com" {
+		return nil, fmt.Errorf("unexpected issuer %q", iss)
+	}
+	google, _ := claims["google"].(map[string]interface{})
+	instance, _ := google["compute_engine"].(map[string]interface{})
+	if instance == nil {
+		return nil, errors.New("token has no instance details, request it with format=full")
+	}
+	project, _ := instance["project_id"].(string)
+	id, _ := instance["instance_id"].(string)
+	name, _ := instance["instance_name"].(string)
+	zone, _ := instance["zone"].(string)
+	region := zone
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		region = zone[:i]
+	}
+	return &models.CloudIdentity{
+		Provider:   models.CloudProviderGCP,
+		Account:    project,
+		InstanceID: id,
+		Name:       name,
+		Region:     region,
+		Zone:       zone,
+	}, nil
+}
+
+// verifyAzureIdentity - verifies a managed identity access token of a virtual machine,
+// requested with the server's enrollment audience as resource
+func verifyAzureIdentity(token string) (*models.CloudIdentity, error) {
+	claims, err := parseCloudToken(token, azureKeysURL)
+	if err != nil {
+		return nil, err
+	}
+	if iss, _ := claims["iss"].(string); !strings.HasPrefix(iss, "https://sts.windows.net/") &&
+		!strings.HasPrefix(iss, "https://login.microsoftonline.com/") {
+		return nil, fmt.Errorf("unexpected issuer %q", iss)
+	}
+	// e.g. /subscriptions/{id}/resourcegroups/{group}/providers/Microsoft.Compute/virtualMachines/{name}
+	resourceID, _ := claims["xms_mirid"].(string)
+	parts := strings.Split(strings.Trim(resourceID, "/"), "/")
+	if len(parts) < 8 || !strings.EqualFold(parts[0], "subscriptions") ||
+		!strings.EqualFold(parts[5], "Microsoft.Compute") || !strings.EqualFold(parts[6], "virtualMachines") {
+		return nil, errors.New("token is not for a virtual machine")
+	}
+	return &models.CloudIdentity{
+		Provider:   models.CloudProviderAzure,
+		Account:    parts[1],
+		InstanceID: strings.ToLower(resourceID),
+		Name:       parts[7],
+	}, nil
+}
+
+// parseCloudToken - verifies an RS256 token with the provider's published keys and the server's audience
+func parseCloudToken(tokenString, keysURL string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return getSigningKey(keysURL, kid)
+	}, jwt.WithValidMethods([]string{"RS256"}))
+	if err != nil {
+		return nil, err
+	}
+	if !claims.VerifyAudience(servercfg.GetCloudEnrollmentAudience(), true) {
+		return nil, errors.New("token was issued for another audience")
+	}
+	return claims, nil
+}
+
+// getSigningKey - gets a key published by a provider, fetching them again when
+// they're stale or the key is unknown as providers rotate their keys
+func getSigningKey(keysURL, kid string) (*rsa.PublicKey, error) {
+	signingKeysMutex.Lock()
+	defer signingKeysMutex.Unlock()
+	cached := signingKeys[keysURL]
+	if cached == nil {
+		cached = &jsonWebKeys{}
+		signingKeys[keysURL] = cached
+	}
+	stale := time.Since(cached.fetched) > signingKeysTTL
+	if (stale || cached.keys[kid] == nil) && time.Since(cached.attempted) > signingKeysRetry {
+		cached.attempted = time.Now()
+		keys, err := fetchSigningKeys(keysURL)
+		if err != nil {
+			return nil, err
+		}
+		cached.keys, cached.fetched = keys, cached.attempted
+	} else if stale {
+		return nil, errors.New("signing keys are stale and could not be fetched")
+	}
+	key := cached.keys[kid]
+	if key == nil {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+func fetchSigningKeys(keysURL string) (map[string]*rsa.PublicKey, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	res, err := client.Get(keysURL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching signing keys returned %s", res.Status)
+	}
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	return keys, nil
+}
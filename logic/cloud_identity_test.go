@@ -0,0 +1,50 @@
+package logic
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSigningKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		fmt.Fprintf(w, `{"keys":[{"kid":"known","kty":"RSA","n":"%s","e":"%s"}]}`,
+			base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()))
+	}))
+	defer server.Close()
+
+	found, err := getSigningKey(server.URL, "known")
+	assert.Nil(t, err)
+	assert.Equal(t, key.N, found.N)
+	assert.Equal(t, 1, fetches)
+	t.Run("UnknownKeys", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			_, err = getSigningKey(server.URL, fmt.Sprintf("madeup%d", i))
+			assert.NotNil(t, err)
+		}
+		assert.Equal(t, 1, fetches, "unknown keys don't fetch the keys again right away")
+		_, err = getSigningKey(server.URL, "known")
+		assert.Nil(t, err)
+	})
+	t.Run("Rotated", func(t *testing.T) {
+		signingKeysMutex.Lock()
+		signingKeys[server.URL].attempted = time.Now().Add(-2 * signingKeysRetry)
+		signingKeysMutex.Unlock()
+		_, err = getSigningKey(server.URL, "rotated")
+		assert.NotNil(t, err)
+		assert.Equal(t, 2, fetches)
+	})
+}
@@ -0,0 +1,184 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic/cloudroutes"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+// cloudRoutesTimeout - how long syncing the cloud routes of a network may take
+const cloudRoutesTimeout = 2 * time.Minute
+
+var (
+	cloudRoutesMutex sync.Mutex
+	// newCloudRouter - creates the router programming a network's cloud route tables
+	newCloudRouter = cloudroutes.New
+)
+
+// GetCloudRoutes - fetches the cloud route tables configured for a network
+func GetCloudRoutes(network string) (models.CloudRoutes, error) {
+	var cfg models.CloudRoutes
+	record, err := database.FetchRecord(database.CLOUD_ROUTES_TABLE_NAME, network)
+	if err != nil {
+		return cfg, err
+	}
+	err = json.Unmarshal([]byte(record), &cfg)
+	return cfg, err
+}
+
+// SetCloudRoutes - configures the cloud route tables of a network and programs them,
+// keeping the stored credentials when none are given
+func SetCloudRoutes(cfg *models.CloudRoutes) error {
+	if err := validator.New().Struct(cfg); err != nil {
+		return err
+	}
+	if _, err := GetNetwork(cfg.Network); err != nil {
+		return err
+	}
+	cloudRoutesMutex.Lock()
+	current, err := GetCloudRoutes(cfg.Network)
+	if err != nil && !database.IsEmptyRecord(err) {
+		cloudRoutesMutex.Unlock()
+		return err
+	}
+	if cfg.Credentials == nil {
+		cfg.Credentials = current.Credentials
+	}
+	// routes already programmed are removed by the sync if they're no longer wanted
+	cfg.Routes = current.Routes
+	err = upsertCloudRoutes(cfg)
+	cloudRoutesMutex.Unlock()
+	if err != nil {
+		return err
+	}
+	return SyncCloudRoutes(cfg.Network)
+}
+
+// DeleteCloudRoutes - removes the routes programmed for a network and its cloud configuration
+func DeleteCloudRoutes(network string) error {
+	cloudRoutesMutex.Lock()
+	defer cloudRoutesMutex.Unlock()
+	cfg, err := GetCloudRoutes(network)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cloudRoutesTimeout)
+	defer cancel()
+	if router, err := newCloudRouter(ctx, &cfg); err == nil {
+		for _, route := range cfg.Routes {
+			if err := router.DeleteRoute(ctx, route.RouteTable, route.Destination); err != nil {
+				slog.Error("failed to remove cloud route", "network", network, "table", route.RouteTable, "destination", route.Destination, "error", err)
+			}
+		}
+	}
+	return database.DeleteRecord(database.CLOUD_ROUTES_TABLE_NAME, network)
+}
+
+// SyncCloudRoutes - points the network's range in its cloud route tables at an egress gateway
+// of the network, removing the routes when it has none; a no-op for networks without cloud route tables
+func SyncCloudRoutes(network string) error {
+	cloudRoutesMutex.Lock()
+	defer cloudRoutesMutex.Unlock()
+	cfg, err := GetCloudRoutes(network)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return nil
+		}
+		return err
+	}
+	desired, err := desiredCloudRoutes(&cfg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cloudRoutesTimeout)
+	defer cancel()
+	router, err := newCloudRouter(ctx, &cfg)
+	if err != nil {
+		return err
+	}
+	current := make(map[string]models.CloudRoute)
+	for _, route := range cfg.Routes {
+		current[route.RouteTable+"###"+route.Destination] = route
+	}
+	routes := []models.CloudRoute{}
+	for _, route := range desired {
+		key := route.RouteTable + "###" + route.Destination
+		existing, ok := current[key]
+		delete(current, key)
+		if ok && existing.NextHop == route.NextHop && existing.Error == "" {
+			routes = append(routes, existing)
+			continue
+		}
+		if err := router.SetRoute(ctx, route.RouteTable, route.Destination, net.ParseIP(route.NextHop)); err != nil {
+			slog.Error("failed to set cloud route", "network", network, "table", route.RouteTable, "destination", route.Destination, "error", err)
+			route.Error = err.Error()
+		}
+		route.UpdatedAt = time.Now()
+		routes = append(routes, route)
+	}
+	for _, route := range current {
+		if err := router.DeleteRoute(ctx, route.RouteTable, route.Destination); err != nil {
+			slog.Error("failed to remove cloud route", "network", network, "table", route.RouteTable, "destination", route.Destination, "error", err)
+			// keep it to retry on the next sync
+			route.Error = err.Error()
+			route.UpdatedAt = time.Now()
+			routes = append(routes, route)
+		}
+	}
+	cfg.Routes = routes
+	return upsertCloudRoutes(&cfg)
+}
+
+// == private ==
+
+// desiredCloudRoutes - routes the network's IPv4 range in each route table to the LAN address of
+// the network's first egress gateway, a cloud route has a single next hop
+func desiredCloudRoutes(cfg *models.CloudRoutes) ([]models.CloudRoute, error) {
+	network, err := GetNetwork(cfg.Network)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := GetNetworkNodes(cfg.Network)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].ID.String() < nodes[j].ID.String()
+	})
+	routes := []models.CloudRoute{}
+	if network.AddressRange == "" {
+		return routes, nil
+	}
+	for _, node := range nodes {
+		if !node.IsEgressGateway || node.PendingDelete || node.LocalAddress.IP.To4() == nil {
+			continue
+		}
+		for _, table := range cfg.RouteTables {
+			routes = append(routes, models.CloudRoute{
+				RouteTable:  table,
+				Destination: network.AddressRange,
+				NextHop:     node.LocalAddress.IP.String(),
+				NodeID:      node.ID.String(),
+			})
+		}
+		break
+	}
+	return routes, nil
+}
+
+func upsertCloudRoutes(cfg *models.CloudRoutes) error {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	return database.Insert(cfg.Network, string(data), database.CLOUD_ROUTES_TABLE_NAME)
+}
@@ -0,0 +1,99 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic/cloudroutes"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRouter - records the routes a sync programs instead of calling a cloud provider
+type fakeRouter struct {
+	routes map[string]string
+	fail   error
+}
+
+func (f *fakeRouter) SetRoute(ctx context.Context, table, destination string, nextHop net.IP) error {
+	if f.fail != nil {
+		return f.fail
+	}
+	f.routes[table+" "+destination] = nextHop.String()
+	return nil
+}
+
+func (f *fakeRouter) DeleteRoute(ctx context.Context, table, destination string) error {
+	if f.fail != nil {
+		return f.fail
+	}
+	delete(f.routes, table+" "+destination)
+	return nil
+}
+
+func TestSyncCloudRoutes(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	router := &fakeRouter{routes: make(map[string]string)}
+	defer func(create func(context.Context, *models.CloudRoutes) (cloudroutes.Router, error)) {
+		newCloudRouter = create
+	}(newCloudRouter)
+	newCloudRouter = func(context.Context, *models.CloudRoutes) (cloudroutes.Router, error) {
+		return router, nil
+	}
+	// fails adding the network users when there are none, after storing the network
+	CreateNetwork(models.Network{NetID: "routenet", AddressRange: "10.30.0.0/24"})
+	_, err := GetNetwork("routenet")
+	assert.Nil(t, err)
+	defer DeleteNetwork("routenet")
+	gateway := models.Node{CommonNode: models.CommonNode{ID: uuid.New(), Network: "routenet"}}
+	gateway.LocalAddress = net.IPNet{IP: net.ParseIP("172.31.0.10"), Mask: net.CIDRMask(20, 32)}
+	assert.Nil(t, UpsertNode(&gateway))
+	defer database.DeleteRecord(database.NODES_TABLE_NAME, gateway.ID.String())
+	cfg := models.CloudRoutes{Network: "routenet", Provider: models.CloudProviderAWS, Region: "us-east-1", RouteTables: []string{"rtb-1", "rtb-2"},
+		Credentials: &models.CloudCredentials{AccessKeyID: "id", SecretAccessKey: "secret"}}
+	assert.Nil(t, SetCloudRoutes(&cfg))
+	assert.Empty(t, router.routes, "nothing is routed without an egress gateway")
+
+	t.Run("EgressGateway", func(t *testing.T) {
+		gateway.IsEgressGateway = true
+		assert.Nil(t, UpsertNode(&gateway))
+		assert.Nil(t, SyncCloudRoutes("routenet"))
+		assert.Equal(t, map[string]string{"rtb-1 10.30.0.0/24": "172.31.0.10", "rtb-2 10.30.0.0/24": "172.31.0.10"}, router.routes)
+		stored, err := GetCloudRoutes("routenet")
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(stored.Routes))
+		assert.Equal(t, gateway.ID.String(), stored.Routes[0].NodeID)
+	})
+	t.Run("Failed", func(t *testing.T) {
+		router.fail = errors.New("throttled")
+		gateway.IsEgressGateway = false
+		assert.Nil(t, UpsertNode(&gateway))
+		assert.Nil(t, SyncCloudRoutes("routenet"))
+		stored, _ := GetCloudRoutes("routenet")
+		// kept to be removed on the next sync
+		assert.Equal(t, 2, len(stored.Routes))
+		assert.Equal(t, "throttled", stored.Routes[0].Error)
+	})
+	t.Run("Removed", func(t *testing.T) {
+		router.fail = nil
+		assert.Nil(t, SyncCloudRoutes("routenet"))
+		assert.Empty(t, router.routes)
+		stored, _ := GetCloudRoutes("routenet")
+		assert.Empty(t, stored.Routes)
+	})
+	t.Run("Deleted", func(t *testing.T) {
+		router.routes["rtb-1 10.30.0.0/24"] = "172.31.0.10"
+		stored, _ := GetCloudRoutes("routenet")
+		stored.Routes = []models.CloudRoute{{RouteTable: "rtb-1", Destination: "10.30.0.0/24", NextHop: "172.31.0.10"}}
+		assert.Nil(t, upsertCloudRoutes(&stored))
+		assert.Nil(t, DeleteCloudRoutes("routenet"))
+		assert.Empty(t, router.routes)
+		_, err := GetCloudRoutes("routenet")
+		assert.True(t, database.IsEmptyRecord(err))
+	})
+}
@@ -0,0 +1,155 @@
+package cloudroutes
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gravitl/netmaker/logic/awsauth"
+	"github.com/gravitl/netmaker/models"
+)
+
+const ec2APIVersion = "2016-11-15"
+
+type awsRouter struct {
+	region    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+type ec2Error struct {
+	Errors []struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	} `xml:"Errors>Error"`
+}
+
+func newAWSRouter(cfg *models.CloudRoutes) (Router, error) {
+	if cfg.Credentials.AccessKeyID == "" || cfg.Credentials.SecretAccessKey == "" {
+		return nil, ErrMissingCredentials
+	}
+	if cfg.Region == "" {
+		return nil, errors.New("missing AWS region")
+	}
+	return &awsRouter{
+		region:    cfg.Region,
+		accessKey: cfg.Credentials.AccessKeyID,
+		secretKey: cfg.Credentials.SecretAccessKey,
+		client:    &http.Client{Timeout: requestTimeout},
+	}, nil
+}
+
+// SetRoute - routes destination to the network interface holding nextHop, which needs
+// source/destination checks disabled to forward traffic for the mesh
+func (a *awsRouter) SetRoute(ctx context.Context, table, destination string, nextHop net.IP) error {
+	eni, err := a.findInterface(ctx, nextHop)
+	if err != nil {
+		return err
+	}
+	if _, err = a.call(ctx, "ModifyNetworkInterfaceAttribute", url.Values{
+		"NetworkInterfaceId":    {eni},
+		"SourceDestCheck.Value": {"false"},
+	}); err != nil {
+		return err
+	}
+	params := url.Values{
+		"RouteTableId":         {table},
+		"DestinationCidrBlock": {destination},
+		"NetworkInterfaceId":   {eni},
+	}
+	if _, err = a.call(ctx, "ReplaceRoute", params); err != nil {
+		if !isEC2Error(err, "InvalidRoute.NotFound") {
+			return err
+		}
+		_, err = a.call(ctx, "CreateRoute", params)
+	}
+	return err
+}
+
+// DeleteRoute - removes the route to destination from the route table
+func (a *awsRouter) DeleteRoute(ctx context.Context, table, destination string) error {
+	_, err := a.call(ctx, "DeleteRoute", url.Values{
+		"RouteTableId":         {table},
+		"DestinationCidrBlock": {destination},
+	})
+	if isEC2Error(err, "InvalidRoute.NotFound") {
+		return nil
+	}
+	return err
+}
+
+// findInterface - finds the network interface a private ip is assigned to
+func (a *awsRouter) findInterface(ctx context.Context, ip net.IP) (string, error) {
+	body, err := a.call(ctx, "DescribeNetworkInterfaces", url.Values{
+		"Filter.1.Name":    {"addresses.private-ip-address"},
+		"Filter.1.Value.1": {ip.String()},
+	})
+	if err != nil {
+		return "", err
+	}
+	var res struct {
+		Interfaces []struct {
+			ID string `xml:"networkInterfaceId"`
+		} `xml:"networkInterfaceSet>item"`
+	}
+	if err := xml.Unmarshal(body, &res); err != nil {
+		return "", err
+	}
+	if len(res.Interfaces) == 0 {
+		return "", fmt.Errorf("no network interface has address %s", ip)
+	}
+	return res.Interfaces[0].ID, nil
+}
+
+// call - calls an EC2 query API action signed with signature version 4
+func (a *awsRouter) call(ctx context.Context, action string, params url.Values) ([]byte, error) {
+	params.Set("Action", action)
+	params.Set("Version", ec2APIVersion)
+	host := "ec2." + a.region + ".amazonaws.com"
+	// Encode sorts by key as the canonical query requires, which also needs spaces as %20 rather than +
+	query := strings.ReplaceAll(params.Encode(), "+", "%20")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+host+"/?"+query, nil)
+	if err != nil {
+		return nil, err
+	}
+	awsauth.Sign(req, nil, a.region, "ec2", a.accessKey, a.secretKey, time.Now())
+	res, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, &apiError{status: res.StatusCode, body: string(body)}
+	}
+	return body, nil
+}
+
+// isEC2Error - checks if err is an EC2 error response with the given code
+func isEC2Error(err error, code string) bool {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	var res ec2Error
+	if xml.Unmarshal([]byte(apiErr.body), &res) != nil {
+		return false
+	}
+	for _, e := range res.Errors {
+		if e.Code == code {
+			return true
+		}
+	}
+	return false
+}
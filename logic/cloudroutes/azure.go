@@ -0,0 +1,67 @@
+package cloudroutes
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const azureNetworkAPIVersion = "2023-04-01"
+
+type azureRouter struct {
+	subscription  string
+	resourceGroup string
+	client        *http.Client
+}
+
+func newAzureRouter(ctx context.Context, cfg *models.CloudRoutes) (Router, error) {
+	creds := cfg.Credentials
+	if creds.TenantID == "" || creds.ClientID == "" || creds.ClientSecret == "" {
+		return nil, ErrMissingCredentials
+	}
+	if cfg.Subscription == "" || cfg.ResourceGroup == "" {
+		return nil, errors.New("missing Azure subscription or resource group")
+	}
+	tokenConfig := clientcredentials.Config{
+		ClientID:     creds.ClientID,
+		ClientSecret: creds.ClientSecret,
+		TokenURL:     "https://login.microsoftonline.com/" + creds.TenantID + "/oauth2/v2.0/token",
+		Scopes:       []string{"https://management.azure.com/.default"},
+	}
+	client := tokenConfig.Client(ctx)
+	client.Timeout = requestTimeout
+	return &azureRouter{subscription: cfg.Subscription, resourceGroup: cfg.ResourceGroup, client: client}, nil
+}
+
+// SetRoute - routes destination in the route table to nextHop as a virtual appliance,
+// whose network interface needs ip forwarding enabled
+func (a *azureRouter) SetRoute(ctx context.Context, table, destination string, nextHop net.IP) error {
+	return doJSON(ctx, a.client, http.MethodPut, a.routeURL(table, destination), map[string]interface{}{
+		"properties": map[string]string{
+			"addressPrefix":    destination,
+			"nextHopType":      "VirtualAppliance",
+			"nextHopIpAddress": nextHop.String(),
+		},
+	})
+}
+
+// DeleteRoute - removes the route to destination from the route table
+func (a *azureRouter) DeleteRoute(ctx context.Context, table, destination string) error {
+	err := doJSON(ctx, a.client, http.MethodDelete, a.routeURL(table, destination), nil)
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (a *azureRouter) routeURL(table, destination string) string {
+	return "https://management.azure.com/subscriptions/" + a.subscription +
+		"/resourceGroups/" + a.resourceGroup +
+		"/providers/Microsoft.Network/routeTables/" + table +
+		"/routes/" + routeName(table, destination) +
+		"?api-version=" + azureNetworkAPIVersion
+}
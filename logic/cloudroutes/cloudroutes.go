@@ -0,0 +1,100 @@
+// Package cloudroutes programs the route tables of cloud networks through the providers' APIs
+package cloudroutes
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+)
+
+// requestTimeout - how long a single call to a provider's API may take
+const requestTimeout = 30 * time.Second
+
+// ErrMissingCredentials - the credentials for the provider aren't set
+var ErrMissingCredentials = errors.New("missing cloud credentials")
+
+// Router - sets and removes routes in the route tables of a cloud network
+type Router interface {
+	// SetRoute - routes destination to nextHop in table, replacing a route to the same destination
+	SetRoute(ctx context.Context, table, destination string, nextHop net.IP) error
+	// DeleteRoute - removes the route to destination from table, if there is one
+	DeleteRoute(ctx context.Context, table, destination string) error
+}
+
+// New - creates a Router for the provider and credentials of cfg
+func New(ctx context.Context, cfg *models.CloudRoutes) (Router, error) {
+	if cfg.Credentials == nil {
+		return nil, ErrMissingCredentials
+	}
+	switch cfg.Provider {
+	case models.CloudProviderAWS:
+		return newAWSRouter(cfg)
+	case models.CloudProviderGCP:
+		return newGCPRouter(ctx, cfg)
+	case models.CloudProviderAzure:
+		return newAzureRouter(ctx, cfg)
+	default:
+		return nil, fmt.Errorf("unknown cloud provider %q", cfg.Provider)
+	}
+}
+
+// routeName - a stable name for the route to destination in table, for providers that name routes
+func routeName(table, destination string) string {
+	sum := sha256.Sum256([]byte(table + "/" + destination))
+	return "netmaker-" + hex.EncodeToString(sum[:8])
+}
+
+// apiError - an unexpected response from a provider's API
+type apiError struct {
+	status int
+	body   string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("cloud api returned %d: %s", e.status, e.body)
+}
+
+// isNotFound - checks if err is a provider's API answering that something doesn't exist
+func isNotFound(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.status == http.StatusNotFound
+}
+
+// doJSON - sends body as json to a provider's REST API with an authorized client
+func doJSON(ctx context.Context, client *http.Client, method, url string, body interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= http.StatusMultipleChoices {
+		data, _ := io.ReadAll(res.Body)
+		return &apiError{status: res.StatusCode, body: string(data)}
+	}
+	return nil
+}
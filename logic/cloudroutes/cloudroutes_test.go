@@ -0,0 +1,46 @@
+package cloudroutes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	_, err := New(context.Background(), &models.CloudRoutes{Provider: models.CloudProviderAWS})
+	assert.ErrorIs(t, err, ErrMissingCredentials)
+	_, err = New(context.Background(), &models.CloudRoutes{Provider: models.CloudProviderGCP, Credentials: &models.CloudCredentials{AccessKeyID: "id"}})
+	assert.ErrorIs(t, err, ErrMissingCredentials, "the credentials of another provider don't count")
+	_, err = New(context.Background(), &models.CloudRoutes{Provider: models.CloudProviderAWS, Region: "us-east-1",
+		Credentials: &models.CloudCredentials{AccessKeyID: "id", SecretAccessKey: "secret"}})
+	assert.Nil(t, err)
+}
+
+func TestRouteName(t *testing.T) {
+	assert.Equal(t, routeName("vpc-1", "10.0.0.0/24"), routeName("vpc-1", "10.0.0.0/24"))
+	assert.NotEqual(t, routeName("vpc-1", "10.0.0.0/24"), routeName("vpc-2", "10.0.0.0/24"))
+	assert.Regexp(t, "^netmaker-[0-9a-f]{16}$", routeName("vpc-1", "10.0.0.0/24"))
+}
+
+func TestDoJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			http.Error(w, "route not found", http.StatusNotFound)
+			return
+		}
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+	assert.Nil(t, doJSON(context.Background(), server.Client(), http.MethodPost, server.URL, map[string]string{"destRange": "10.0.0.0/24"}))
+	err := doJSON(context.Background(), server.Client(), http.MethodDelete, server.URL, nil)
+	assert.True(t, isNotFound(err))
+	assert.Contains(t, err.Error(), "route not found")
+}
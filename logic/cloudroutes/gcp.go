@@ -0,0 +1,59 @@
+package cloudroutes
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/oauth2/google"
+)
+
+const computeAPI = "https://compute.googleapis.com/compute/v1/projects/"
+
+type gcpRouter struct {
+	project string
+	client  *http.Client
+}
+
+func newGCPRouter(ctx context.Context, cfg *models.CloudRoutes) (Router, error) {
+	if cfg.Credentials.ServiceAccountKey == "" {
+		return nil, ErrMissingCredentials
+	}
+	if cfg.Project == "" {
+		return nil, errors.New("missing GCP project")
+	}
+	jwtConfig, err := google.JWTConfigFromJSON([]byte(cfg.Credentials.ServiceAccountKey), "https://www.googleapis.com/auth/compute")
+	if err != nil {
+		return nil, err
+	}
+	client := jwtConfig.Client(ctx)
+	client.Timeout = requestTimeout
+	return &gcpRouter{project: cfg.Project, client: client}, nil
+}
+
+// SetRoute - routes destination in the VPC network table to nextHop, whose instance needs
+// ip forwarding enabled; routes can't be changed so an existing one is replaced
+func (g *gcpRouter) SetRoute(ctx context.Context, table, destination string, nextHop net.IP) error {
+	if err := g.DeleteRoute(ctx, table, destination); err != nil {
+		return err
+	}
+	return doJSON(ctx, g.client, http.MethodPost, computeAPI+g.project+"/global/routes", map[string]interface{}{
+		"name":        routeName(table, destination),
+		"description": "netmaker egress route",
+		"network":     "projects/" + g.project + "/global/networks/" + table,
+		"destRange":   destination,
+		"nextHopIp":   nextHop.String(),
+		"priority":    1000,
+	})
+}
+
+// DeleteRoute - removes the route to destination from the VPC network table
+func (g *gcpRouter) DeleteRoute(ctx context.Context, table, destination string) error {
+	err := doJSON(ctx, g.client, http.MethodDelete, computeAPI+g.project+"/global/routes/"+routeName(table, destination), nil)
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
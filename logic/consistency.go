@@ -0,0 +1,285 @@
+package logic
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic/pro"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/models/promodels"
+	"golang.org/x/exp/slog"
+)
+
+// consistencySnapshot - the records a consistency check compares
+type consistencySnapshot struct {
+	networks     []models.Network
+	hosts        []models.Host
+	nodes        []models.Node
+	clients      []models.ExtClient
+	networkUsers map[string]promodels.NetworkUserMap
+}
+
+// CheckConsistency - finds records referring to others that no longer exist: nodes of deleted hosts or networks,
+// hosts listing deleted nodes, ext clients of deleted gateways and network users assigned deleted gateways or clients;
+// the orphans are cleaned up unless dryRun is set
+func CheckConsistency(dryRun bool) (models.ConsistencyReport, error) {
+	report := models.ConsistencyReport{Checked: time.Now().UTC(), DryRun: dryRun, Issues: []models.ConsistencyIssue{}}
+	snapshot, err := loadConsistencySnapshot()
+	if err != nil {
+		return report, err
+	}
+	report.Issues = findConsistencyIssues(snapshot)
+	if dryRun {
+		return report, nil
+	}
+	for i := range report.Issues {
+		issue := &report.Issues[i]
+		if err := cleanConsistencyIssue(issue); err != nil {
+			issue.Error = err.Error()
+			report.Failed++
+			continue
+		}
+		issue.Cleaned = true
+		report.Cleaned++
+	}
+	if report.Cleaned > 0 {
+		slog.Info("cleaned up orphaned records", "cleaned", report.Cleaned, "failed", report.Failed)
+	}
+	return report, nil
+}
+
+// == private ==
+
+// checkConsistency - logs the orphaned records of the database without cleaning them, run by the daily hooks
+func checkConsistency() error {
+	report, err := CheckConsistency(true)
+	if err != nil {
+		return err
+	}
+	for _, issue := range report.Issues {
+		slog.Warn("found orphaned record", "kind", issue.Kind, "table", issue.Table, "id", issue.ID, "network", issue.Network, "reference", issue.Reference)
+	}
+	return nil
+}
+
+func loadConsistencySnapshot() (consistencySnapshot, error) {
+	snapshot := consistencySnapshot{networkUsers: map[string]promodels.NetworkUserMap{}}
+	var err error
+	if snapshot.networks, err = GetNetworks(); err != nil && !database.IsEmptyRecord(err) {
+		return snapshot, err
+	}
+	if snapshot.hosts, err = GetAllHosts(); err != nil {
+		return snapshot, err
+	}
+	if snapshot.nodes, err = GetAllNodes(); err != nil {
+		return snapshot, err
+	}
+	if snapshot.clients, err = GetAllExtClients(); err != nil && !database.IsEmptyRecord(err) {
+		return snapshot, err
+	}
+	records, err := database.FetchRecords(database.NETWORK_USER_TABLE_NAME)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return snapshot, err
+	}
+	for network, value := range records {
+		var users promodels.NetworkUserMap
+		if err := json.Unmarshal([]byte(value), &users); err != nil {
+			continue
+		}
+		snapshot.networkUsers[network] = users
+	}
+	return snapshot, nil
+}
+
+// findConsistencyIssues - the orphaned records of a snapshot, in a stable order
+func findConsistencyIssues(snapshot consistencySnapshot) []models.ConsistencyIssue {
+	issues := []models.ConsistencyIssue{}
+	networks := map[string]bool{}
+	for _, network := range snapshot.networks {
+		networks[network.NetID] = true
+	}
+	hosts := map[string]bool{}
+	for _, host := range snapshot.hosts {
+		hosts[host.ID.String()] = true
+	}
+	nodes := map[string]models.Node{}
+	for _, node := range snapshot.nodes {
+		nodes[node.ID.String()] = node
+	}
+	clients := map[string]bool{}
+	for _, client := range snapshot.clients {
+		clients[client.Network+"/"+client.ClientID] = true
+	}
+	isGateway := func(id string) bool {
+		node, ok := nodes[id]
+		return ok && node.IsIngressGateway
+	}
+
+	for _, node := range snapshot.nodes {
+		switch {
+		case !networks[node.Network]:
+			issues = append(issues, models.ConsistencyIssue{
+				Kind:      models.ConsistencyNodeWithoutNetwork,
+				Table:     database.NODES_TABLE_NAME,
+				ID:        node.ID.String(),
+				Network:   node.Network,
+				Reference: node.Network,
+				Action:    "delete the node",
+			})
+		case !hosts[node.HostID.String()]:
+			issues = append(issues, models.ConsistencyIssue{
+				Kind:      models.ConsistencyNodeWithoutHost,
+				Table:     database.NODES_TABLE_NAME,
+				ID:        node.ID.String(),
+				Network:   node.Network,
+				Reference: node.HostID.String(),
+				Action:    "delete the node",
+			})
+		}
+	}
+	for _, host := range snapshot.hosts {
+		for _, nodeID := range host.Nodes {
+			if _, ok := nodes[nodeID]; !ok {
+				issues = append(issues, models.ConsistencyIssue{
+					Kind:      models.ConsistencyHostMissingNode,
+					Table:     database.HOSTS_TABLE_NAME,
+					ID:        host.ID.String(),
+					Reference: nodeID,
+					Action:    "remove the node from the host",
+				})
+			}
+		}
+	}
+	for _, client := range snapshot.clients {
+		if !isGateway(client.IngressGatewayID) {
+			issues = append(issues, models.ConsistencyIssue{
+				Kind:      models.ConsistencyClientWithoutGateway,
+				Table:     database.EXT_CLIENT_TABLE_NAME,
+				ID:        client.ClientID,
+				Network:   client.Network,
+				Reference: client.IngressGatewayID,
+				Action:    "delete the ext client",
+			})
+			continue
+		}
+		for _, gatewayID := range client.SecondaryGatewayIDs {
+			if !isGateway(gatewayID) {
+				issues = append(issues, models.ConsistencyIssue{
+					Kind:      models.ConsistencyStaleSecondaryGateway,
+					Table:     database.EXT_CLIENT_TABLE_NAME,
+					ID:        client.ClientID,
+					Network:   client.Network,
+					Reference: gatewayID,
+					Action:    "remove the secondary gateway from the ext client",
+				})
+			}
+		}
+	}
+	netIDs := []string{}
+	for network := range snapshot.networkUsers {
+		netIDs = append(netIDs, network)
+	}
+	sort.Strings(netIDs)
+	for _, network := range netIDs {
+		if !networks[network] {
+			issues = append(issues, models.ConsistencyIssue{
+				Kind:      models.ConsistencyUsersWithoutNetwork,
+				Table:     database.NETWORK_USER_TABLE_NAME,
+				ID:        network,
+				Network:   network,
+				Reference: network,
+				Action:    "delete the network users",
+			})
+			continue
+		}
+		users := snapshot.networkUsers[network]
+		userIDs := []string{}
+		for id := range users {
+			userIDs = append(userIDs, string(id))
+		}
+		sort.Strings(userIDs)
+		for _, userID := range userIDs {
+			user := users[promodels.NetworkUserID(userID)]
+			for _, nodeID := range user.Nodes {
+				if _, ok := nodes[nodeID]; !ok {
+					issues = append(issues, models.ConsistencyIssue{
+						Kind:      models.ConsistencyStaleUserGateway,
+						Table:     database.NETWORK_USER_TABLE_NAME,
+						ID:        userID,
+						Network:   network,
+						Reference: nodeID,
+						Action:    "remove the gateway from the network user",
+					})
+				}
+			}
+			for _, clientID := range user.Clients {
+				if !clients[network+"/"+clientID] {
+					issues = append(issues, models.ConsistencyIssue{
+						Kind:      models.ConsistencyStaleUserClient,
+						Table:     database.NETWORK_USER_TABLE_NAME,
+						ID:        userID,
+						Network:   network,
+						Reference: clientID,
+						Action:    "remove the ext client from the network user",
+					})
+				}
+			}
+		}
+	}
+	return issues
+}
+
+// cleanConsistencyIssue - deletes an orphaned record or removes its stale reference; records already gone,
+// eg. ext clients deleted along with their orphaned gateway, count as cleaned
+func cleanConsistencyIssue(issue *models.ConsistencyIssue) error {
+	err := func() error {
+		switch issue.Kind {
+		case models.ConsistencyNodeWithoutHost, models.ConsistencyNodeWithoutNetwork:
+			node, err := GetNodeByID(issue.ID)
+			if err != nil {
+				return err
+			}
+			return deleteNodeByID(&node)
+		case models.ConsistencyHostMissingNode:
+			host, err := GetHost(issue.ID)
+			if err != nil {
+				return err
+			}
+			host.Nodes = withoutString(host.Nodes, issue.Reference)
+			return UpsertHost(host)
+		case models.ConsistencyClientWithoutGateway:
+			return DeleteExtClient(issue.Network, issue.ID)
+		case models.ConsistencyStaleSecondaryGateway:
+			client, err := GetExtClient(issue.ID, issue.Network)
+			if err != nil {
+				return err
+			}
+			client.SecondaryGatewayIDs = withoutString(client.SecondaryGatewayIDs, issue.Reference)
+			return SaveExtClient(&client)
+		case models.ConsistencyUsersWithoutNetwork:
+			return pro.RemoveAllNetworkUsers(issue.Network)
+		case models.ConsistencyStaleUserGateway:
+			return pro.DissociateNetworkUserNode(issue.ID, issue.Network, issue.Reference)
+		case models.ConsistencyStaleUserClient:
+			return pro.DissociateNetworkUserClient(issue.ID, issue.Network, issue.Reference)
+		}
+		return nil
+	}()
+	if database.IsEmptyRecord(err) {
+		return nil
+	}
+	return err
+}
+
+// withoutString - the items of a slice other than item
+func withoutString(items []string, item string) []string {
+	kept := []string{}
+	for _, entry := range items {
+		if entry != item {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
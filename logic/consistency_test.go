@@ -0,0 +1,45 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/models/promodels"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindConsistencyIssues(t *testing.T) {
+	host := models.Host{ID: uuid.New()}
+	gateway := models.Node{CommonNode: models.CommonNode{ID: uuid.New(), HostID: host.ID, Network: "net", IsIngressGateway: true}}
+	orphan := models.Node{CommonNode: models.CommonNode{ID: uuid.New(), HostID: uuid.New(), Network: "net"}}
+	removedGateway := uuid.New().String()
+	host.Nodes = []string{gateway.ID.String(), removedGateway}
+	snapshot := consistencySnapshot{
+		networks: []models.Network{{NetID: "net"}},
+		hosts:    []models.Host{host},
+		nodes:    []models.Node{gateway, orphan},
+		clients: []models.ExtClient{
+			{ClientID: "ok", Network: "net", IngressGatewayID: gateway.ID.String()},
+			{ClientID: "standby", Network: "net", IngressGatewayID: gateway.ID.String(), SecondaryGatewayIDs: []string{orphan.ID.String()}},
+			{ClientID: "stale", Network: "net", IngressGatewayID: removedGateway},
+		},
+		networkUsers: map[string]promodels.NetworkUserMap{
+			"net": {"user": {ID: "user", Nodes: []string{gateway.ID.String(), removedGateway}, Clients: []string{"ok", "gone"}}},
+			"old": {},
+		},
+	}
+	kinds := map[string]string{}
+	for _, issue := range findConsistencyIssues(snapshot) {
+		kinds[issue.Kind] = issue.ID + " " + issue.Reference
+	}
+	assert.Equal(t, map[string]string{
+		models.ConsistencyNodeWithoutHost:       orphan.ID.String() + " " + orphan.HostID.String(),
+		models.ConsistencyHostMissingNode:       host.ID.String() + " " + removedGateway,
+		models.ConsistencyClientWithoutGateway:  "stale " + removedGateway,
+		models.ConsistencyStaleSecondaryGateway: "standby " + orphan.ID.String(),
+		models.ConsistencyStaleUserGateway:      "user " + removedGateway,
+		models.ConsistencyStaleUserClient:       "user gone",
+		models.ConsistencyUsersWithoutNetwork:   "old old",
+	}, kinds)
+}
@@ -0,0 +1,88 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/gravitl/netmaker/models"
+)
+
+// ErrInvalidCORSSettings - the cors or security header settings would produce invalid or unsafe headers
+var ErrInvalidCORSSettings = errors.New("invalid cors or security header settings")
+
+// ValidateCORSSettings - checks the allowed origins are * or scheme://host[:port] with an optional *. subdomain
+// wildcard, the headers are tokens and the security headers hold nothing that could split a response header
+func ValidateCORSSettings(cors *models.CORSSettings, headers *models.SecurityHeaders) error {
+	for _, origin := range cors.AllowedOrigins {
+		if origin == "*" {
+			if cors.AllowCredentials {
+				return fmt.Errorf("%w: credentials can't be allowed for any origin", ErrInvalidCORSSettings)
+			}
+			continue
+		}
+		if !isValidOrigin(origin) {
+			return fmt.Errorf("%w: invalid origin %s", ErrInvalidCORSSettings, origin)
+		}
+	}
+	for _, header := range cors.AllowedHeaders {
+		if !isHeaderToken(header) {
+			return fmt.Errorf("%w: invalid header %s", ErrInvalidCORSSettings, header)
+		}
+	}
+	if strings.ContainsAny(headers.ContentSecurityPolicy, "\r\n") {
+		return fmt.Errorf("%w: invalid content security policy", ErrInvalidCORSSettings)
+	}
+	if strings.Contains(headers.ContentSecurityPolicy, "frame-ancestors") {
+		return fmt.Errorf("%w: frame-ancestors are set through frame_ancestors", ErrInvalidCORSSettings)
+	}
+	for _, ancestor := range headers.FrameAncestors {
+		if ancestor != "'self'" && ancestor != "'none'" && !isValidOrigin(ancestor) {
+			return fmt.Errorf("%w: invalid frame ancestor %s", ErrInvalidCORSSettings, ancestor)
+		}
+	}
+	return nil
+}
+
+// IsOriginAllowed - whether a request origin matches one of the allowed origins
+func IsOriginAllowed(allowed []string, origin string) bool {
+	if origin == "" {
+		return false
+	}
+	origin = strings.ToLower(origin)
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(pattern)
+		if pattern == "*" || pattern == origin {
+			return true
+		}
+		scheme, domain, wildcard := strings.Cut(pattern, "://*.")
+		if wildcard && strings.HasPrefix(origin, scheme+"://") && strings.HasSuffix(origin, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// == private ==
+
+func isValidOrigin(origin string) bool {
+	u, err := url.Parse(strings.Replace(origin, "://*.", "://wildcard.", 1))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+	return u.Path == "" && u.RawQuery == "" && u.Fragment == "" && u.User == nil
+}
+
+// isHeaderToken - whether a header name only has the token characters of RFC 9110
+func isHeaderToken(header string) bool {
+	if header == "" {
+		return false
+	}
+	for _, c := range header {
+		if c > '~' || c <= ' ' || strings.ContainsRune("\"(),/:;<=>?@[\\]{}", c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,34 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCORSSettings(t *testing.T) {
+	headers := models.SecurityHeaders{FrameAncestors: []string{"'self'", "https://portal.example.com"}}
+	cors := models.CORSSettings{AllowedOrigins: []string{"https://dashboard.example.com", "https://*.example.org:8443"}, AllowedHeaders: []string{"Content-Type", "X-Request-ID"}, AllowCredentials: true}
+	assert.Nil(t, ValidateCORSSettings(&cors, &headers))
+
+	for _, origin := range []string{"*", "dashboard.example.com", "https://dashboard.example.com/app", "ftp://example.com"} {
+		cors := models.CORSSettings{AllowedOrigins: []string{origin}, AllowCredentials: true}
+		assert.ErrorIs(t, ValidateCORSSettings(&cors, &headers), ErrInvalidCORSSettings, origin)
+	}
+	cors = models.CORSSettings{AllowedHeaders: []string{"X-Evil\r\nSet-Cookie"}}
+	assert.ErrorIs(t, ValidateCORSSettings(&cors, &headers), ErrInvalidCORSSettings)
+	cors = models.CORSSettings{}
+	assert.ErrorIs(t, ValidateCORSSettings(&cors, &models.SecurityHeaders{ContentSecurityPolicy: "default-src 'self'; frame-ancestors *"}), ErrInvalidCORSSettings)
+}
+
+func TestIsOriginAllowed(t *testing.T) {
+	allowed := []string{"https://dashboard.example.com", "https://*.example.org"}
+	assert.True(t, IsOriginAllowed(allowed, "https://Dashboard.example.com"))
+	assert.True(t, IsOriginAllowed(allowed, "https://nm.example.org"))
+	assert.False(t, IsOriginAllowed(allowed, "https://example.org"))
+	assert.False(t, IsOriginAllowed(allowed, "http://nm.example.org"))
+	assert.False(t, IsOriginAllowed(allowed, "https://dashboard.example.com.evil.com"))
+	assert.False(t, IsOriginAllowed(allowed, ""))
+	assert.True(t, IsOriginAllowed([]string{"*"}, "https://anything.example.net"))
+}
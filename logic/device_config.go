@@ -0,0 +1,449 @@
+package logic
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+)
+
+const (
+	// DeviceConfigWireGuard - a wg-quick config file
+	DeviceConfigWireGuard = "wireguard"
+	// DeviceConfigRouterOS - a MikroTik RouterOS script
+	DeviceConfigRouterOS = "routeros"
+	// DeviceConfigOPNsense - the wireguard section of an OPNsense config.xml
+	DeviceConfigOPNsense = "opnsense"
+	// DeviceConfigPfSense - the wireguard section of a pfSense config.xml
+	DeviceConfigPfSense = "pfsense"
+
+	// devicePrivateKeyPlaceholder - the server never knows a device's private key
+	devicePrivateKeyPlaceholder = "[ENTER PRIVATE KEY]"
+)
+
+var (
+	// ErrInvalidDeviceConfigFormat - the config format asked for isn't one the server renders
+	ErrInvalidDeviceConfigFormat = errors.New("invalid config format, must be one of wireguard, routeros, opnsense or pfsense")
+	// ErrNotGateway - device configs are only exported for gateways
+	ErrNotGateway = errors.New("node is not an ingress, egress or relay gateway")
+)
+
+// StaticPeerDeviceConfig - the interface of a static peer, with every node of its network it may reach as a peer
+func StaticPeerDeviceConfig(peer *models.StaticPeer) (models.DeviceConfig, error) {
+	network, err := GetNetwork(peer.Network)
+	if err != nil {
+		return models.DeviceConfig{}, err
+	}
+	nodes, err := GetNetworkNodes(peer.Network)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return models.DeviceConfig{}, err
+	}
+	config := models.DeviceConfig{
+		Name:      deviceInterfaceName(peer.Network),
+		Addresses: []string{},
+		MTU:       servercfg.GetDefaultMTU(),
+		Peers:     []models.DeviceConfigPeer{},
+	}
+	if peer.Address != "" {
+		config.Addresses = append(config.Addresses, peer.Address+"/32")
+	}
+	if peer.Address6 != "" {
+		config.Addresses = append(config.Addresses, peer.Address6+"/128")
+	}
+	if _, port, err := net.SplitHostPort(peer.Endpoint); err == nil {
+		config.ListenPort, _ = strconv.Atoi(port)
+	}
+	keepalive := int(peer.PersistentKeepalive)
+	if keepalive == 0 && peer.Endpoint == "" {
+		// behind nat the device has to keep the tunnels open itself
+		keepalive = int(network.DefaultKeepalive)
+	}
+	for _, node := range nodes {
+		node := node
+		if node.PendingDelete || !node.Connected || !staticPeerAllowed(peer, &node) {
+			continue
+		}
+		host, err := GetHost(node.HostID.String())
+		if err != nil {
+			continue
+		}
+		devicePeer := models.DeviceConfigPeer{
+			Name:                NodeName(&node, host),
+			PublicKey:           host.PublicKey.String(),
+			AllowedIPs:          []string{},
+			PersistentKeepalive: keepalive,
+		}
+		for _, allowedIP := range getNodeAllowedIPs(&node, &node) {
+			devicePeer.AllowedIPs = append(devicePeer.AllowedIPs, allowedIP.String())
+		}
+		if host.EndpointIP != nil {
+			devicePeer.EndpointHost = host.EndpointIP.String()
+			devicePeer.EndpointPort = GetPeerListenPort(host)
+		}
+		config.Peers = append(config.Peers, devicePeer)
+	}
+	return config, nil
+}
+
+// GatewayDeviceConfig - the interface of a gateway node with the peers the server gives it,
+// for moving a gateway onto an existing firewall
+func GatewayDeviceConfig(node *models.Node) (models.DeviceConfig, error) {
+	if !node.IsIngressGateway && !node.IsEgressGateway && !node.IsRelay {
+		return models.DeviceConfig{}, ErrNotGateway
+	}
+	host, err := GetHost(node.HostID.String())
+	if err != nil {
+		return models.DeviceConfig{}, err
+	}
+	allNodes, err := GetAllNodes()
+	if err != nil {
+		return models.DeviceConfig{}, err
+	}
+	update, err := GetPeerUpdateForHost(node.Network, host, allNodes, nil, nil)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return models.DeviceConfig{}, err
+	}
+	config := models.DeviceConfig{
+		Name:       deviceInterfaceName(node.Network),
+		Addresses:  []string{},
+		ListenPort: host.ListenPort,
+		MTU:        host.MTU,
+		Peers:      []models.DeviceConfigPeer{},
+	}
+	if node.Address.IP != nil {
+		config.Addresses = append(config.Addresses, node.Address.String())
+	}
+	if node.Address6.IP != nil {
+		config.Addresses = append(config.Addresses, node.Address6.String())
+	}
+	for _, peer := range update.NodePeers {
+		if peer.Remove {
+			continue
+		}
+		devicePeer := models.DeviceConfigPeer{
+			Name:       peer.PublicKey.String(),
+			PublicKey:  peer.PublicKey.String(),
+			AllowedIPs: []string{},
+		}
+		if peerID, ok := update.PeerIDs[peer.PublicKey.String()]; ok && peerID.Name != "" {
+			devicePeer.Name = peerID.Name
+		}
+		for _, allowedIP := range peer.AllowedIPs {
+			devicePeer.AllowedIPs = append(devicePeer.AllowedIPs, allowedIP.String())
+		}
+		if peer.Endpoint != nil {
+			devicePeer.EndpointHost = peer.Endpoint.IP.String()
+			devicePeer.EndpointPort = peer.Endpoint.Port
+		}
+		if peer.PersistentKeepaliveInterval != nil {
+			devicePeer.PersistentKeepalive = int(peer.PersistentKeepaliveInterval.Seconds())
+		}
+		config.Peers = append(config.Peers, devicePeer)
+	}
+	return config, nil
+}
+
+// RenderDeviceConfig - renders a device config in a vendor's format,
+// the device's private key is left for its owner to fill in
+func RenderDeviceConfig(config *models.DeviceConfig, format string) (string, error) {
+	switch format {
+	case "", DeviceConfigWireGuard:
+		return renderWireGuardConfig(config), nil
+	case DeviceConfigRouterOS:
+		return renderRouterOSConfig(config), nil
+	case DeviceConfigOPNsense:
+		return renderOPNsenseConfig(config)
+	case DeviceConfigPfSense:
+		return renderPfSenseConfig(config)
+	default:
+		return "", ErrInvalidDeviceConfigFormat
+	}
+}
+
+// == private ==
+
+// deviceInterfaceName - the name given to the wireguard interface of a network on a device
+func deviceInterfaceName(network string) string {
+	name := "nm-" + network
+	if len(name) > 15 {
+		// linux based firewalls cap interface names at 15 characters
+		name = name[:15]
+	}
+	return name
+}
+
+func renderWireGuardConfig(config *models.DeviceConfig) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "[Interface]\nAddress = %s\nPrivateKey = %s\n", strings.Join(config.Addresses, ","), devicePrivateKeyPlaceholder)
+	if config.ListenPort > 0 {
+		fmt.Fprintf(&out, "ListenPort = %d\n", config.ListenPort)
+	}
+	if config.MTU > 0 {
+		fmt.Fprintf(&out, "MTU = %d\n", config.MTU)
+	}
+	for _, peer := range config.Peers {
+		fmt.Fprintf(&out, "\n# %s\n[Peer]\nPublicKey = %s\nAllowedIPs = %s\n", peer.Name, peer.PublicKey, strings.Join(peer.AllowedIPs, ","))
+		if peer.EndpointHost != "" {
+			fmt.Fprintf(&out, "Endpoint = %s\n", net.JoinHostPort(peer.EndpointHost, strconv.Itoa(peer.EndpointPort)))
+		}
+		if peer.PersistentKeepalive > 0 {
+			fmt.Fprintf(&out, "PersistentKeepalive = %d\n", peer.PersistentKeepalive)
+		}
+	}
+	return out.String()
+}
+
+// renderRouterOSConfig - a RouterOS v7 script, routes are added for the allowed ips
+// as RouterOS doesn't route them to the interface by itself
+func renderRouterOSConfig(config *models.DeviceConfig) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "# netmaker interface %s\n/interface wireguard\nadd name=%s private-key=%s", config.Name, config.Name, routerOSQuote(devicePrivateKeyPlaceholder))
+	if config.ListenPort > 0 {
+		fmt.Fprintf(&out, " listen-port=%d", config.ListenPort)
+	}
+	if config.MTU > 0 {
+		fmt.Fprintf(&out, " mtu=%d", config.MTU)
+	}
+	out.WriteString("\n")
+	var addresses, addresses6 []string
+	for _, address := range config.Addresses {
+		if strings.Contains(address, ":") {
+			addresses6 = append(addresses6, address)
+		} else {
+			addresses = append(addresses, address)
+		}
+	}
+	if len(addresses) > 0 {
+		out.WriteString("/ip address\n")
+		for _, address := range addresses {
+			fmt.Fprintf(&out, "add address=%s interface=%s\n", address, config.Name)
+		}
+	}
+	if len(addresses6) > 0 {
+		out.WriteString("/ipv6 address\n")
+		for _, address := range addresses6 {
+			fmt.Fprintf(&out, "add address=%s interface=%s advertise=no\n", address, config.Name)
+		}
+	}
+	if len(config.Peers) > 0 {
+		out.WriteString("/interface wireguard peers\n")
+	}
+	var routes, routes6 []string
+	routed := map[string]bool{}
+	for _, peer := range config.Peers {
+		fmt.Fprintf(&out, "add interface=%s public-key=%s allowed-address=%s comment=%s", config.Name, routerOSQuote(peer.PublicKey), strings.Join(peer.AllowedIPs, ","), routerOSQuote(peer.Name))
+		if peer.EndpointHost != "" {
+			fmt.Fprintf(&out, " endpoint-address=%s endpoint-port=%d", peer.EndpointHost, peer.EndpointPort)
+		}
+		if peer.PersistentKeepalive > 0 {
+			fmt.Fprintf(&out, " persistent-keepalive=%ds", peer.PersistentKeepalive)
+		}
+		out.WriteString("\n")
+		for _, allowedIP := range peer.AllowedIPs {
+			if routed[allowedIP] {
+				continue
+			}
+			routed[allowedIP] = true
+			if strings.Contains(allowedIP, ":") {
+				routes6 = append(routes6, allowedIP)
+			} else {
+				routes = append(routes, allowedIP)
+			}
+		}
+	}
+	if len(routes) > 0 {
+		out.WriteString("/ip route\n")
+		for _, route := range routes {
+			fmt.Fprintf(&out, "add dst-address=%s gateway=%s\n", route, config.Name)
+		}
+	}
+	if len(routes6) > 0 {
+		out.WriteString("/ipv6 route\n")
+		for _, route := range routes6 {
+			fmt.Fprintf(&out, "add dst-address=%s gateway=%s\n", route, config.Name)
+		}
+	}
+	return out.String()
+}
+
+// routerOSQuote - quotes a RouterOS script value, escaping what RouterOS would interpret
+func routerOSQuote(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`)
+	return `"` + replacer.Replace(value) + `"`
+}
+
+type opnsenseConfig struct {
+	XMLName   xml.Name          `xml:"OPNsense"`
+	WireGuard opnsenseWireGuard `xml:"wireguard"`
+}
+
+type opnsenseWireGuard struct {
+	Clients []opnsenseClient `xml:"client>clients>client"`
+	Servers []opnsenseServer `xml:"server>servers>server"`
+}
+
+type opnsenseClient struct {
+	UUID          string `xml:"uuid,attr"`
+	Enabled       int    `xml:"enabled"`
+	Name          string `xml:"name"`
+	PublicKey     string `xml:"pubkey"`
+	TunnelAddress string `xml:"tunneladdress"`
+	ServerAddress string `xml:"serveraddress"`
+	ServerPort    string `xml:"serverport"`
+	Keepalive     string `xml:"keepalive"`
+}
+
+type opnsenseServer struct {
+	UUID          string `xml:"uuid,attr"`
+	Enabled       int    `xml:"enabled"`
+	Name          string `xml:"name"`
+	PrivateKey    string `xml:"privkey"`
+	Port          string `xml:"port"`
+	MTU           string `xml:"mtu"`
+	TunnelAddress string `xml:"tunneladdress"`
+	DisableRoutes int    `xml:"disableroutes"`
+	Peers         string `xml:"peers"`
+}
+
+// renderOPNsenseConfig - the wireguard plugin section of an OPNsense config.xml, peers are linked
+// to the instance by uuids derived from their keys so exporting again updates rather than duplicates
+func renderOPNsenseConfig(config *models.DeviceConfig) (string, error) {
+	server := opnsenseServer{
+		UUID:          deviceConfigUUID(config.Name),
+		Enabled:       1,
+		Name:          config.Name,
+		PrivateKey:    devicePrivateKeyPlaceholder,
+		Port:          optionalInt(config.ListenPort),
+		MTU:           optionalInt(config.MTU),
+		TunnelAddress: strings.Join(config.Addresses, ","),
+	}
+	wireguard := opnsenseWireGuard{}
+	peerUUIDs := []string{}
+	for _, peer := range config.Peers {
+		client := opnsenseClient{
+			UUID:          deviceConfigUUID(peer.PublicKey),
+			Enabled:       1,
+			Name:          peer.Name,
+			PublicKey:     peer.PublicKey,
+			TunnelAddress: strings.Join(peer.AllowedIPs, ","),
+			ServerAddress: peer.EndpointHost,
+			Keepalive:     optionalInt(peer.PersistentKeepalive),
+		}
+		if peer.EndpointHost != "" {
+			client.ServerPort = optionalInt(peer.EndpointPort)
+		}
+		wireguard.Clients = append(wireguard.Clients, client)
+		peerUUIDs = append(peerUUIDs, client.UUID)
+	}
+	server.Peers = strings.Join(peerUUIDs, ",")
+	wireguard.Servers = []opnsenseServer{server}
+	return marshalDeviceXML(opnsenseConfig{WireGuard: wireguard})
+}
+
+type pfsenseConfig struct {
+	XMLName xml.Name        `xml:"wireguard"`
+	Tunnels []pfsenseTunnel `xml:"tunnels>item"`
+	Peers   []pfsensePeer   `xml:"peers>item"`
+}
+
+type pfsenseTunnel struct {
+	Name       string           `xml:"name"`
+	Enabled    string           `xml:"enabled"`
+	Descr      string           `xml:"descr"`
+	ListenPort string           `xml:"listenport"`
+	PrivateKey string           `xml:"privatekey"`
+	MTU        string           `xml:"mtu"`
+	Addresses  []pfsenseAddress `xml:"addresses>row"`
+}
+
+type pfsensePeer struct {
+	Enabled             string           `xml:"enabled"`
+	Tunnel              string           `xml:"tun"`
+	Descr               string           `xml:"descr"`
+	Dynamic             string           `xml:"dynamic"`
+	Endpoint            string           `xml:"endpoint"`
+	Port                string           `xml:"port"`
+	PersistentKeepalive string           `xml:"persistentkeepalive"`
+	PublicKey           string           `xml:"publickey"`
+	AllowedIPs          []pfsenseAddress `xml:"allowedips>row"`
+}
+
+type pfsenseAddress struct {
+	Address string `xml:"address"`
+	Mask    string `xml:"mask"`
+	Descr   string `xml:"descr"`
+}
+
+// renderPfSenseConfig - the wireguard package section of a pfSense config.xml, pfSense doesn't
+// route allowed ips by itself so the tunnel still has to be assigned and routed there
+func renderPfSenseConfig(config *models.DeviceConfig) (string, error) {
+	tunnel := pfsenseTunnel{
+		Name:       "tun_wg0",
+		Enabled:    "yes",
+		Descr:      config.Name,
+		ListenPort: optionalInt(config.ListenPort),
+		PrivateKey: devicePrivateKeyPlaceholder,
+		MTU:        optionalInt(config.MTU),
+		Addresses:  pfsenseAddresses(config.Addresses),
+	}
+	pfsense := pfsenseConfig{Tunnels: []pfsenseTunnel{tunnel}}
+	for _, peer := range config.Peers {
+		pfsensePeer := pfsensePeer{
+			Enabled:             "yes",
+			Tunnel:              tunnel.Name,
+			Descr:               peer.Name,
+			Dynamic:             "yes",
+			PersistentKeepalive: optionalInt(peer.PersistentKeepalive),
+			PublicKey:           peer.PublicKey,
+			AllowedIPs:          pfsenseAddresses(peer.AllowedIPs),
+		}
+		if peer.EndpointHost != "" {
+			pfsensePeer.Dynamic = "no"
+			pfsensePeer.Endpoint = peer.EndpointHost
+			pfsensePeer.Port = optionalInt(peer.EndpointPort)
+		}
+		pfsense.Peers = append(pfsense.Peers, pfsensePeer)
+	}
+	return marshalDeviceXML(pfsense)
+}
+
+// pfsenseAddresses - pfSense keeps cidrs as an address and a mask length
+func pfsenseAddresses(cidrs []string) []pfsenseAddress {
+	addresses := []pfsenseAddress{}
+	for _, cidr := range cidrs {
+		address, mask, found := strings.Cut(cidr, "/")
+		if !found {
+			continue
+		}
+		addresses = append(addresses, pfsenseAddress{Address: address, Mask: mask})
+	}
+	return addresses
+}
+
+func marshalDeviceXML(v any) (string, error) {
+	data, err := xml.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return "", err
+	}
+	return xml.Header + string(data) + "\n", nil
+}
+
+// deviceConfigUUID - a stable uuid for an exported interface or peer
+func deviceConfigUUID(name string) string {
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte("netmaker/"+name)).String()
+}
+
+// optionalInt - an int as an xml value, empty when unset
+func optionalInt(value int) string {
+	if value <= 0 {
+		return ""
+	}
+	return strconv.Itoa(value)
+}
@@ -0,0 +1,56 @@
+package logic
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderDeviceConfig(t *testing.T) {
+	config := models.DeviceConfig{
+		Name:       "nm-office",
+		Addresses:  []string{"10.0.0.9/32"},
+		ListenPort: 51820,
+		Peers: []models.DeviceConfigPeer{
+			{
+				Name:                "gateway",
+				PublicKey:           "cGVlcmtleQ==",
+				EndpointHost:        "203.0.113.1",
+				EndpointPort:        51821,
+				AllowedIPs:          []string{"10.0.0.1/32", "192.168.1.0/24"},
+				PersistentKeepalive: 20,
+			},
+		},
+	}
+	t.Run("WireGuard", func(t *testing.T) {
+		rendered, err := RenderDeviceConfig(&config, "")
+		assert.Nil(t, err)
+		assert.Contains(t, rendered, "ListenPort = 51820\n")
+		assert.Contains(t, rendered, "Endpoint = 203.0.113.1:51821\n")
+	})
+	t.Run("RouterOS", func(t *testing.T) {
+		rendered, err := RenderDeviceConfig(&config, DeviceConfigRouterOS)
+		assert.Nil(t, err)
+		assert.Contains(t, rendered, `add interface=nm-office public-key="cGVlcmtleQ==" allowed-address=10.0.0.1/32,192.168.1.0/24 comment="gateway" endpoint-address=203.0.113.1 endpoint-port=51821 persistent-keepalive=20s`)
+		assert.Contains(t, rendered, "add dst-address=192.168.1.0/24 gateway=nm-office\n")
+	})
+	t.Run("OPNsense", func(t *testing.T) {
+		rendered, err := RenderDeviceConfig(&config, DeviceConfigOPNsense)
+		assert.Nil(t, err)
+		assert.Contains(t, rendered, "<tunneladdress>10.0.0.1/32,192.168.1.0/24</tunneladdress>")
+		assert.Contains(t, rendered, "<peers>"+deviceConfigUUID("cGVlcmtleQ==")+"</peers>")
+	})
+	t.Run("PfSense", func(t *testing.T) {
+		rendered, err := RenderDeviceConfig(&config, DeviceConfigPfSense)
+		assert.Nil(t, err)
+		assert.Contains(t, rendered, "<address>192.168.1.0</address>")
+		assert.Contains(t, rendered, "<mask>24</mask>")
+		assert.True(t, strings.HasPrefix(rendered, "<?xml"))
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := RenderDeviceConfig(&config, "cisco")
+		assert.ErrorIs(t, err, ErrInvalidDeviceConfigFormat)
+	})
+}
@@ -7,11 +7,14 @@ import (
 
 	validator "github.com/go-playground/validator/v10"
 	"github.com/gravitl/netmaker/database"
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/models"
 	"github.com/txn2/txeh"
+	"golang.org/x/exp/slog"
 )
 
+// ExtClientDNSZone - the zone of a network ext clients are named in, such as laptop.clients.skynet
+const ExtClientDNSZone = "clients"
+
 // SetDNS - sets the dns on file
 func SetDNS() error {
 	hostfile := txeh.Hosts{}
@@ -59,8 +62,13 @@ func GetDNS(network string) ([]models.DNSEntry, error) {
 	if err != nil && !database.IsEmptyRecord(err) {
 		return dns, err
 	}
+	extclientdns, err := GetExtClientDNS(network)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return dns, err
+	}
 
 	dns = append(dns, customdns...)
+	dns = append(dns, extclientdns...)
 	return dns, nil
 }
 
@@ -83,7 +91,7 @@ func GetNodeDNS(network string) ([]models.DNSEntry, error) {
 			continue
 		}
 		var entry = models.DNSEntry{}
-		entry.Name = host.Name
+		entry.Name = NodeName(&node, host)
 		entry.Network = network
 		if node.Address.IP != nil {
 			entry.Address = node.Address.IP.String()
@@ -97,6 +105,36 @@ func GetNodeDNS(network string) ([]models.DNSEntry, error) {
 	return dns, nil
 }
 
+// GetExtClientDNS - gets the DNS of the enabled ext clients of a network, named in ExtClientDNSZone
+func GetExtClientDNS(network string) ([]models.DNSEntry, error) {
+
+	var dns []models.DNSEntry
+
+	clients, err := GetNetworkExtClients(network)
+	if err != nil {
+		return dns, err
+	}
+
+	for i := range clients {
+		if !clients[i].Enabled {
+			continue
+		}
+		dns = append(dns, models.DNSEntry{
+			Name:     ExtClientDNSName(&clients[i]),
+			Network:  network,
+			Address:  clients[i].Address,
+			Address6: clients[i].Address6,
+		})
+	}
+
+	return dns, nil
+}
+
+// ExtClientDNSName - the name of an ext client within its network
+func ExtClientDNSName(client *models.ExtClient) string {
+	return client.ClientID + "." + ExtClientDNSZone
+}
+
 // GetCustomDNS - gets the custom DNS of a network
 func GetCustomDNS(network string) ([]models.DNSEntry, error) {
 
@@ -132,7 +170,7 @@ func SetCorefile(domains string) error {
 		err = os.MkdirAll(dir+"/config/dnsconfig", 0744)
 	}
 	if err != nil {
-		logger.Log(0, "couldnt find or create /config/dnsconfig")
+		slog.Info("couldnt find or create /config/dnsconfig")
 		return err
 	}
 
@@ -216,7 +254,7 @@ func ValidateDNSCreate(entry models.DNSEntry) error {
 	err := v.Struct(entry)
 	if err != nil {
 		for _, e := range err.(validator.ValidationErrors) {
-			logger.Log(1, e.Error())
+			slog.Error(e.Error())
 		}
 	}
 	return err
@@ -244,7 +282,7 @@ func ValidateDNSUpdate(change models.DNSEntry, entry models.DNSEntry) error {
 
 	if err != nil {
 		for _, e := range err.(validator.ValidationErrors) {
-			logger.Log(1, e.Error())
+			slog.Error(e.Error())
 		}
 	}
 	return err
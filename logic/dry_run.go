@@ -0,0 +1,23 @@
+package logic
+
+import (
+	"sort"
+
+	"github.com/gravitl/netmaker/models"
+)
+
+// DryRunHosts - the hosts of the given ids a dry run change would be sent to, sorted by name
+func DryRunHosts(hostIDs []string) []models.DryRunHost {
+	hosts := []models.DryRunHost{}
+	for _, id := range hostIDs {
+		host, err := GetHost(id)
+		if err != nil {
+			continue
+		}
+		hosts = append(hosts, models.DryRunHost{ID: id, Name: host.Name})
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		return hosts[i].Name < hosts[j].Name
+	})
+	return hosts
+}
@@ -0,0 +1,339 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// defaultEgressHealthInterval - seconds between health checks of an egress range when none is set
+	defaultEgressHealthInterval = 30
+	// defaultEgressHealthFailures - consecutive failed checks before an egress range is unhealthy when none is set
+	defaultEgressHealthFailures = 3
+)
+
+var (
+	// ErrEgressHealthCheck - a health check has to target an address in one of the gateway's ranges
+	ErrEgressHealthCheck = errors.New("egress health check must target an address in one of the gateway's ranges")
+
+	egressHealthMutex sync.Mutex
+	// unhealthyEgressRanges - the egress ranges currently unhealthy, by node id and range
+	unhealthyEgressRanges map[string]bool
+)
+
+// GetEgressHealthChecksForHost - the health checks the egress nodes of a host run against their ranges
+func GetEgressHealthChecksForHost(host *models.Host) []models.EgressHealthCheck {
+	checks := []models.EgressHealthCheck{}
+	for _, nodeID := range host.Nodes {
+		node, err := GetNodeByID(nodeID)
+		if err != nil || !node.IsEgressGateway {
+			continue
+		}
+		for _, check := range node.EgressGatewayRequest.HealthChecks {
+			check.NodeID = nodeID
+			// the webhook and channels are only needed on the server
+			check.Webhook = ""
+			check.Channels = nil
+			checks = append(checks, check)
+		}
+	}
+	return checks
+}
+
+// StoreEgressHealthResults - keeps the results of the egress health checks a host reported through one of its nodes,
+// returns the networks where a range turned unhealthy or recovered
+func StoreEgressHealthResults(reporter *models.Node, results []models.EgressHealthResult) []string {
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Time.Before(results[j].Time)
+	})
+	egressHealthMutex.Lock()
+	defer egressHealthMutex.Unlock()
+	loadUnhealthyEgressRanges()
+	changed := []string{}
+	for _, result := range results {
+		node, err := GetNodeByID(result.NodeID)
+		if err != nil || node.HostID != reporter.HostID || !node.IsEgressGateway {
+			continue
+		}
+		check := egressHealthCheck(&node, result.Range)
+		if check == nil {
+			continue
+		}
+		health, err := getEgressRangeHealth(result.NodeID, result.Range)
+		if err != nil {
+			slog.Error("failed to fetch egress range health", "node", result.NodeID, "range", result.Range, "error", err)
+			continue
+		}
+		health.Network = node.Network
+		if !updateEgressRangeHealth(&health, check, &result) {
+			if err := saveEgressRangeHealth(&health); err != nil {
+				slog.Error("failed to store egress range health", "node", result.NodeID, "range", result.Range, "error", err)
+			}
+			continue
+		}
+		if err := saveEgressRangeHealth(&health); err != nil {
+			slog.Error("failed to store egress range health", "node", result.NodeID, "range", result.Range, "error", err)
+			continue
+		}
+		unhealthyEgressRanges[egressHealthKey(health.NodeID, health.Range)] = !health.Healthy
+		if health.Healthy {
+			slog.Info("egress range recovered", "node", health.NodeID, "network", health.Network, "range", health.Range)
+			RecordNetworkEvent(node.Network, models.NetworkEventEgressHealth, &node, fmt.Sprintf("egress range %s recovered", health.Range))
+		} else {
+			slog.Warn("egress range unhealthy", "node", health.NodeID, "network", health.Network, "range", health.Range, "error", health.Error)
+			RecordNetworkEvent(node.Network, models.NetworkEventEgressHealth, &node, fmt.Sprintf("egress range %s unhealthy: %s", health.Range, health.Error))
+		}
+		if check.Webhook != "" {
+			go postAlert(check.Webhook, "egress health", models.EgressHealthEvent{Health: health, Time: health.Since})
+		}
+		notification := models.Notification{
+			Event:    models.NotificationEventEgressHealth,
+			Severity: models.NotificationResolved,
+			Title:    fmt.Sprintf("egress range %s recovered", health.Range),
+			Network:  health.Network,
+			Fields:   []models.NotificationField{{Name: "network", Value: health.Network}, {Name: "gateway", Value: node.ID.String()}},
+			Time:     health.Since,
+		}
+		if !health.Healthy {
+			notification.Severity = models.NotificationAlert
+			notification.Title = fmt.Sprintf("egress range %s unhealthy", health.Range)
+			notification.Text = health.Error
+		}
+		go Notify(notification, check.Channels)
+		if !StringSliceContains(changed, node.Network) {
+			changed = append(changed, node.Network)
+		}
+	}
+	return changed
+}
+
+// GetEgressHealth - the health of the checked egress ranges of a network
+func GetEgressHealth(network string) ([]models.EgressRangeHealth, error) {
+	healths := []models.EgressRangeHealth{}
+	records, err := database.FetchRecords(database.EGRESS_HEALTH_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return healths, nil
+		}
+		return nil, err
+	}
+	for _, value := range records {
+		var health models.EgressRangeHealth
+		if err := json.Unmarshal([]byte(value), &health); err != nil || health.Network != network {
+			continue
+		}
+		node, err := GetNodeByID(health.NodeID)
+		if err != nil || egressHealthCheck(&node, health.Range) == nil {
+			continue
+		}
+		health.FailedOver = egressRangeFailedOver(&node, health.Range)
+		healths = append(healths, health)
+	}
+	sort.Slice(healths, func(i, j int) bool {
+		if healths[i].Range == healths[j].Range {
+			return healths[i].NodeID < healths[j].NodeID
+		}
+		return healths[i].Range < healths[j].Range
+	})
+	return healths, nil
+}
+
+// DeleteEgressHealth - forgets the health of the egress ranges of a node
+func DeleteEgressHealth(nodeID string) {
+	egressHealthMutex.Lock()
+	defer egressHealthMutex.Unlock()
+	loadUnhealthyEgressRanges()
+	records, err := database.FetchRecords(database.EGRESS_HEALTH_TABLE_NAME)
+	if err != nil {
+		return
+	}
+	for key, value := range records {
+		var health models.EgressRangeHealth
+		if err := json.Unmarshal([]byte(value), &health); err != nil || health.NodeID != nodeID {
+			continue
+		}
+		if err := database.DeleteRecord(database.EGRESS_HEALTH_TABLE_NAME, key); err != nil {
+			slog.Error("failed to delete egress range health", "node", nodeID, "range", health.Range, "error", err)
+			continue
+		}
+		delete(unhealthyEgressRanges, key)
+	}
+}
+
+// == private ==
+
+// validateEgressHealthChecks - checks the health checks of a gateway target its normalized ranges and fills in their defaults
+func validateEgressHealthChecks(gateway *models.EgressGatewayRequest) error {
+	for i := range gateway.HealthChecks {
+		check := &gateway.HealthChecks[i]
+		if err := validator.New().Struct(check); err != nil {
+			return err
+		}
+		normalized, err := NormalizeCIDR(check.Range)
+		if err != nil {
+			return err
+		}
+		check.Range = normalized
+		if !StringSliceContains(gateway.Ranges, check.Range) {
+			return ErrEgressHealthCheck
+		}
+		_, cidr, _ := net.ParseCIDR(check.Range)
+		if !cidr.Contains(net.ParseIP(check.Target)) {
+			return ErrEgressHealthCheck
+		}
+		if check.Protocol == models.EgressHealthTCP && check.Port == 0 {
+			return errors.New("tcp egress health checks need a port")
+		}
+		if check.Protocol == models.EgressHealthICMP {
+			check.Port = 0
+		}
+		if check.Interval == 0 {
+			check.Interval = defaultEgressHealthInterval
+		}
+		if check.Failures == 0 {
+			check.Failures = defaultEgressHealthFailures
+		}
+		if len(check.Channels) > 0 {
+			network, err := GetNetwork(gateway.NetID)
+			if err != nil {
+				return err
+			}
+			if err := ValidateNotificationChannels(check.Channels, network.Tenant); err != nil {
+				return err
+			}
+		}
+		check.NodeID = ""
+	}
+	return nil
+}
+
+// healthyEgressRanges - the egress ranges of a gateway, without the unhealthy ones another gateway took over
+func healthyEgressRanges(node *models.Node) []string {
+	ranges := []string{}
+	for _, iprange := range node.EgressGatewayRanges {
+		if egressRangeFailedOver(node, iprange) {
+			continue
+		}
+		ranges = append(ranges, iprange)
+	}
+	return ranges
+}
+
+// egressRangeFailedOver - whether an egress range of a gateway is unhealthy and another connected gateway
+// of the network serves it healthy, only then is the range routed away from the gateway
+func egressRangeFailedOver(node *models.Node, iprange string) bool {
+	if !isEgressRangeUnhealthy(node.ID.String(), iprange) {
+		return false
+	}
+	nodes, err := GetNetworkNodes(node.Network)
+	if err != nil {
+		return false
+	}
+	for _, other := range nodes {
+		if other.ID == node.ID || !other.IsEgressGateway || !other.Connected || other.PendingDelete {
+			continue
+		}
+		if StringSliceContains(other.EgressGatewayRanges, iprange) && !isEgressRangeUnhealthy(other.ID.String(), iprange) {
+			return true
+		}
+	}
+	return false
+}
+
+func isEgressRangeUnhealthy(nodeID, iprange string) bool {
+	egressHealthMutex.Lock()
+	defer egressHealthMutex.Unlock()
+	loadUnhealthyEgressRanges()
+	return unhealthyEgressRanges[egressHealthKey(nodeID, iprange)]
+}
+
+// loadUnhealthyEgressRanges - fills the unhealthy egress ranges from the database the first time they're needed,
+// the caller holds egressHealthMutex
+func loadUnhealthyEgressRanges() {
+	if unhealthyEgressRanges != nil {
+		return
+	}
+	unhealthyEgressRanges = make(map[string]bool)
+	records, err := database.FetchRecords(database.EGRESS_HEALTH_TABLE_NAME)
+	if err != nil {
+		return
+	}
+	for key, value := range records {
+		var health models.EgressRangeHealth
+		if err := json.Unmarshal([]byte(value), &health); err == nil && !health.Healthy {
+			unhealthyEgressRanges[key] = true
+		}
+	}
+}
+
+// egressHealthCheck - the health check of an egress range of a gateway, if it has one
+func egressHealthCheck(node *models.Node, iprange string) *models.EgressHealthCheck {
+	if !node.IsEgressGateway {
+		return nil
+	}
+	for i := range node.EgressGatewayRequest.HealthChecks {
+		if node.EgressGatewayRequest.HealthChecks[i].Range == iprange {
+			return &node.EgressGatewayRequest.HealthChecks[i]
+		}
+	}
+	return nil
+}
+
+// updateEgressRangeHealth - applies a check result to the health of a range, returns whether it turned unhealthy or recovered
+func updateEgressRangeHealth(health *models.EgressRangeHealth, check *models.EgressHealthCheck, result *models.EgressHealthResult) bool {
+	if result.Time.Before(health.LastCheck) {
+		return false
+	}
+	health.LastCheck = result.Time
+	health.Latency = result.Latency
+	health.Error = result.Error
+	wasHealthy := health.Healthy
+	if result.Healthy {
+		health.ConsecutiveFailures = 0
+		health.Healthy = true
+	} else {
+		health.ConsecutiveFailures++
+		if health.ConsecutiveFailures >= check.Failures {
+			health.Healthy = false
+		}
+	}
+	if health.Healthy == wasHealthy {
+		return false
+	}
+	health.Since = result.Time
+	return true
+}
+
+func getEgressRangeHealth(nodeID, iprange string) (models.EgressRangeHealth, error) {
+	health := models.EgressRangeHealth{NodeID: nodeID, Range: iprange, Healthy: true}
+	record, err := database.FetchRecord(database.EGRESS_HEALTH_TABLE_NAME, egressHealthKey(nodeID, iprange))
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return health, nil
+		}
+		return health, err
+	}
+	err = json.Unmarshal([]byte(record), &health)
+	return health, err
+}
+
+func saveEgressRangeHealth(health *models.EgressRangeHealth) error {
+	data, err := json.Marshal(health)
+	if err != nil {
+		return err
+	}
+	return database.Insert(egressHealthKey(health.NodeID, health.Range), string(data), database.EGRESS_HEALTH_TABLE_NAME)
+}
+
+func egressHealthKey(nodeID, iprange string) string {
+	return nodeID + "_" + iprange
+}
@@ -0,0 +1,60 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateEgressHealthChecks(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		gateway := models.EgressGatewayRequest{
+			Ranges:       []string{"10.50.0.0/24"},
+			HealthChecks: []models.EgressHealthCheck{{Range: "10.50.0.1/24", Protocol: models.EgressHealthTCP, Target: "10.50.0.10", Port: 443}},
+		}
+		assert.Nil(t, validateEgressHealthChecks(&gateway))
+		assert.Equal(t, "10.50.0.0/24", gateway.HealthChecks[0].Range)
+		assert.Equal(t, defaultEgressHealthInterval, gateway.HealthChecks[0].Interval)
+		assert.Equal(t, defaultEgressHealthFailures, gateway.HealthChecks[0].Failures)
+	})
+	t.Run("TargetOutsideRange", func(t *testing.T) {
+		gateway := models.EgressGatewayRequest{
+			Ranges:       []string{"10.50.0.0/24"},
+			HealthChecks: []models.EgressHealthCheck{{Range: "10.50.0.0/24", Protocol: models.EgressHealthICMP, Target: "10.60.0.1"}},
+		}
+		assert.ErrorIs(t, validateEgressHealthChecks(&gateway), ErrEgressHealthCheck)
+	})
+	t.Run("TCPWithoutPort", func(t *testing.T) {
+		gateway := models.EgressGatewayRequest{
+			Ranges:       []string{"10.50.0.0/24"},
+			HealthChecks: []models.EgressHealthCheck{{Range: "10.50.0.0/24", Protocol: models.EgressHealthTCP, Target: "10.50.0.10"}},
+		}
+		assert.NotNil(t, validateEgressHealthChecks(&gateway))
+	})
+}
+
+func TestUpdateEgressRangeHealth(t *testing.T) {
+	check := models.EgressHealthCheck{Failures: 2}
+	health := models.EgressRangeHealth{Healthy: true}
+	now := time.Now()
+	failed := models.EgressHealthResult{Time: now, Error: "timeout"}
+	assert.False(t, updateEgressRangeHealth(&health, &check, &failed))
+	assert.True(t, health.Healthy)
+	failed.Time = now.Add(time.Second)
+	assert.True(t, updateEgressRangeHealth(&health, &check, &failed))
+	assert.False(t, health.Healthy)
+	assert.Equal(t, failed.Time, health.Since)
+	t.Run("Stale", func(t *testing.T) {
+		stale := models.EgressHealthResult{Time: now, Healthy: true}
+		assert.False(t, updateEgressRangeHealth(&health, &check, &stale))
+		assert.False(t, health.Healthy)
+	})
+	t.Run("Recovered", func(t *testing.T) {
+		ok := models.EgressHealthResult{Time: now.Add(2 * time.Second), Healthy: true}
+		assert.True(t, updateEgressRangeHealth(&health, &check, &ok))
+		assert.True(t, health.Healthy)
+		assert.Zero(t, health.ConsecutiveFailures)
+	})
+}
@@ -0,0 +1,57 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+)
+
+// ErrSMTPNotConfigured - no mail server is set in the smtp settings
+var ErrSMTPNotConfigured = errors.New("smtp is not configured")
+
+// SendEmail - sends a plain text email through the mail server of the smtp settings,
+// upgrading to TLS when the server offers it
+func SendEmail(to, subject, body string) error {
+	settings := servercfg.GetSMTPSettings()
+	if settings.Host == "" || settings.From == "" {
+		return ErrSMTPNotConfigured
+	}
+	port := settings.Port
+	if port == 0 {
+		port = 587
+	}
+	var auth smtp.Auth
+	if settings.Username != "" {
+		auth = smtp.PlainAuth("", settings.Username, settings.Password, settings.Host)
+	}
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		settings.From, to, headerValue(subject), time.Now().Format(time.RFC1123Z), strings.ReplaceAll(body, "\n", "\r\n"))
+	return smtp.SendMail(net.JoinHostPort(settings.Host, strconv.Itoa(port)), auth, settings.From, []string{to}, []byte(message))
+}
+
+// UserEmail - the address a user is emailed at, the email of their profile or else their username
+// when it's an email address; users pending verification aren't emailed anything but the verification link
+func UserEmail(username string) (string, bool) {
+	user, err := GetUser(username)
+	if err != nil {
+		user = &models.User{UserName: username}
+	}
+	if user.Pending {
+		return "", false
+	}
+	return userAddress(user)
+}
+
+// == private ==
+
+// headerValue - a value with the line breaks that could add headers removed
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
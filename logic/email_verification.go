@@ -0,0 +1,181 @@
+package logic
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
+)
+
+// emailVerificationExpiry - how long a verification link can be visited
+const emailVerificationExpiry = 48 * time.Hour
+
+var (
+	// ErrEmailNotVerified - a pending user tried to sign in before verifying their email
+	ErrEmailNotVerified = errors.New("the email address of this account is not verified yet")
+	// ErrEmailVerificationLink - the verification link is unknown, expired or for an address the user no longer has
+	ErrEmailVerificationLink = errors.New("invalid or expired verification link")
+	// ErrEmailVerificationUnavailable - verification emails can't be sent with the current configuration
+	ErrEmailVerificationUnavailable = errors.New("email verification needs smtp settings and the api connection string")
+)
+
+// CreateLocalUser - creates a user through the api; with email verification on, a user with an email address
+// stays pending until they visit the link emailed to them
+func CreateLocalUser(user *models.User) error {
+	_, hasEmail := userAddress(user)
+	verify := servercfg.IsEmailVerificationEnabled() && hasEmail
+	if verify {
+		if servercfg.GetSMTPSettings().Host == "" || servercfg.GetAPIConnString() == "" {
+			return ErrEmailVerificationUnavailable
+		}
+		user.Pending = true
+	}
+	if err := CreateUser(user); err != nil {
+		return err
+	}
+	if verify {
+		if err := SendEmailVerification(user); err != nil {
+			// the user stays pending, an admin can send the link again
+			slog.Error("failed to send verification email", "user", user.UserName, "error", err)
+		}
+	}
+	return nil
+}
+
+// SendEmailVerification - emails a new verification link to a pending user, the links sent before stop working
+func SendEmailVerification(user *models.User) error {
+	if !user.Pending {
+		return errors.New("user " + user.UserName + " is not pending verification")
+	}
+	address, ok := userAddress(user)
+	if !ok {
+		return errors.New("user " + user.UserName + " has no email address")
+	}
+	if servercfg.GetAPIConnString() == "" {
+		return ErrEmailVerificationUnavailable
+	}
+	if err := deleteEmailVerifications(user.UserName); err != nil {
+		return err
+	}
+	token := RandomString(32)
+	verification := models.EmailVerification{
+		User:    user.UserName,
+		Email:   address,
+		Expires: time.Now().UTC().Add(emailVerificationExpiry),
+	}
+	data, err := json.Marshal(verification)
+	if err != nil {
+		return err
+	}
+	if err := database.Insert(verificationKey(token), string(data), database.EMAIL_VERIFICATIONS_TABLE_NAME); err != nil {
+		return err
+	}
+	link := "https://" + servercfg.GetAPIConnString() + "/api/users/verify/" + token
+	body := fmt.Sprintf("Hello %s,\n\nan account was created for you. Verify your email address by visiting\n\n%s\n\nThe link expires at %s.\n",
+		user.UserName, link, verification.Expires.Format(time.RFC1123))
+	return SendEmail(address, "Verify your email address", body)
+}
+
+// VerifyEmail - ends the pending state of the user a verification link was sent to
+func VerifyEmail(token string) (models.User, error) {
+	key := verificationKey(token)
+	record, err := database.FetchRecord(database.EMAIL_VERIFICATIONS_TABLE_NAME, key)
+	if err != nil {
+		return models.User{}, ErrEmailVerificationLink
+	}
+	var verification models.EmailVerification
+	if err := json.Unmarshal([]byte(record), &verification); err != nil {
+		return models.User{}, ErrEmailVerificationLink
+	}
+	if time.Now().After(verification.Expires) {
+		database.DeleteRecord(database.EMAIL_VERIFICATIONS_TABLE_NAME, key)
+		return models.User{}, ErrEmailVerificationLink
+	}
+	user, err := GetUser(verification.User)
+	if err != nil {
+		return models.User{}, ErrEmailVerificationLink
+	}
+	if address, _ := userAddress(user); address != verification.Email {
+		return models.User{}, ErrEmailVerificationLink
+	}
+	user.Pending = false
+	data, err := json.Marshal(user)
+	if err != nil {
+		return *user, err
+	}
+	if err := database.Insert(user.UserName, string(data), database.USERS_TABLE_NAME); err != nil {
+		return *user, err
+	}
+	if err := database.DeleteRecord(database.EMAIL_VERIFICATIONS_TABLE_NAME, key); err != nil {
+		slog.Error("failed to delete used email verification", "user", user.UserName, "error", err)
+	}
+	return *user, nil
+}
+
+// == private ==
+
+// userAddress - the email of a user's profile, or else their username when it's an email address
+func userAddress(user *models.User) (string, bool) {
+	if user.Email != "" {
+		return user.Email, true
+	}
+	address, err := mail.ParseAddress(user.UserName)
+	if err != nil || address.Address != user.UserName {
+		return "", false
+	}
+	return address.Address, true
+}
+
+// verificationKey - verification links are stored by the hash of their token, so the table can't be used to verify
+func verificationKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// deleteEmailVerifications - invalidates the verification links sent to a user
+func deleteEmailVerifications(username string) error {
+	records, err := database.FetchRecords(database.EMAIL_VERIFICATIONS_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return nil
+		}
+		return err
+	}
+	for key, value := range records {
+		var verification models.EmailVerification
+		if err := json.Unmarshal([]byte(value), &verification); err == nil && verification.User == username {
+			if err := database.DeleteRecord(database.EMAIL_VERIFICATIONS_TABLE_NAME, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func pruneEmailVerifications() error {
+	records, err := database.FetchRecords(database.EMAIL_VERIFICATIONS_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return nil
+		}
+		return err
+	}
+	now := time.Now()
+	for key, value := range records {
+		var verification models.EmailVerification
+		if err := json.Unmarshal([]byte(value), &verification); err != nil || now.After(verification.Expires) {
+			if err := database.DeleteRecord(database.EMAIL_VERIFICATIONS_TABLE_NAME, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package logic
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmailVerification(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	t.Setenv("SERVER_API_CONN_STRING", "api.example.com")
+	// nothing listens on the mail server, the verification email fails to send and the user stays pending
+	servercfg.SetSettings(&models.ServerSettings{EmailVerification: true, SMTP: models.SMTPSettings{Host: "127.0.0.1", Port: 1, From: "netmaker@example.com"}})
+	defer servercfg.SetSettings(nil)
+	user := models.User{UserName: "verifyme", Password: "password", Email: "verifyme@example.com"}
+	assert.Nil(t, CreateLocalUser(&user))
+	defer DeleteUser("verifyme")
+	_, err := VerifyAuthRequest(models.UserAuthParams{UserName: "verifyme", Password: "password"})
+	assert.ErrorIs(t, err, ErrEmailNotVerified)
+	_, ok := UserEmail("verifyme")
+	assert.False(t, ok, "pending users only get the verification link")
+
+	data, _ := json.Marshal(models.EmailVerification{User: "verifyme", Email: "typo@example.com", Expires: time.Now().Add(time.Hour)})
+	assert.Nil(t, database.Insert(verificationKey("stale"), string(data), database.EMAIL_VERIFICATIONS_TABLE_NAME))
+	_, err = VerifyEmail("stale")
+	assert.ErrorIs(t, err, ErrEmailVerificationLink)
+
+	data, _ = json.Marshal(models.EmailVerification{User: "verifyme", Email: "verifyme@example.com", Expires: time.Now().Add(time.Hour)})
+	assert.Nil(t, database.Insert(verificationKey("token"), string(data), database.EMAIL_VERIFICATIONS_TABLE_NAME))
+	verified, err := VerifyEmail("token")
+	assert.Nil(t, err)
+	assert.False(t, verified.Pending)
+	_, err = VerifyEmail("token")
+	assert.ErrorIs(t, err, ErrEmailVerificationLink, "links work once")
+	_, err = VerifyAuthRequest(models.UserAuthParams{UserName: "verifyme", Password: "password"})
+	assert.NotErrorIs(t, err, ErrEmailNotVerified)
+}
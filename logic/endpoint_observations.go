@@ -0,0 +1,112 @@
+package logic
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+)
+
+// endpointObservationRetention - how long the endpoint of a peer no longer handshaking is kept
+const endpointObservationRetention = 30 * 24 * time.Hour
+
+// RecordEndpointObservations - stores the endpoint a node is handshaking with each connected peer through,
+// counting the times it changed
+func RecordEndpointObservations(node *models.Node, metrics *models.Metrics) error {
+	observations, err := getEndpointObservations(node.ID.String())
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	changed := false
+	for peerID, metric := range metrics.Connectivity {
+		if !metric.Connected || metric.Endpoint == "" {
+			continue
+		}
+		observation, ok := observations.Peers[peerID]
+		if !ok {
+			observation = models.EndpointObservation{PeerID: peerID, Endpoint: metric.Endpoint, Since: now}
+		} else if observation.Endpoint != metric.Endpoint {
+			observation.Previous = observation.Endpoint
+			observation.Endpoint = metric.Endpoint
+			observation.Since = now
+			observation.Changes++
+		}
+		observation.PeerName = metric.NodeName
+		observation.LastSeen = now
+		observations.Peers[peerID] = observation
+		changed = true
+	}
+	for peerID, observation := range observations.Peers {
+		if now.Sub(observation.LastSeen) > endpointObservationRetention {
+			delete(observations.Peers, peerID)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	data, err := json.Marshal(observations)
+	if err != nil {
+		return err
+	}
+	return database.Insert(node.ID.String(), string(data), database.ENDPOINT_OBSERVATIONS_TABLE_NAME)
+}
+
+// GetEndpointObservations - the endpoints a node was last seen reaching its peers through, next to the ones
+// the server selected for them
+func GetEndpointObservations(node *models.Node) ([]models.EndpointObservation, error) {
+	observations, err := getEndpointObservations(node.ID.String())
+	if err != nil {
+		return nil, err
+	}
+	host, err := GetHost(node.HostID.String())
+	if err != nil {
+		return nil, err
+	}
+	list := make([]models.EndpointObservation, 0, len(observations.Peers))
+	for peerID, observation := range observations.Peers {
+		// ext clients connect to their gateway, the server doesn't select an endpoint for them
+		if peer, err := GetNodeByID(peerID); err == nil {
+			if peerHost, err := GetHost(peer.HostID.String()); err == nil {
+				observation.Selected = SelectPeerEndpoint(host, peerHost, node, &peer).Endpoint
+			}
+		}
+		list = append(list, observation)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].PeerName < list[j].PeerName
+	})
+	return list, nil
+}
+
+// DeleteEndpointObservations - removes the endpoints recorded for a node
+func DeleteEndpointObservations(nodeID string) error {
+	err := database.DeleteRecord(database.ENDPOINT_OBSERVATIONS_TABLE_NAME, nodeID)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return err
+	}
+	return nil
+}
+
+// == private ==
+
+func getEndpointObservations(nodeID string) (models.NodeEndpointObservations, error) {
+	observations := models.NodeEndpointObservations{NodeID: nodeID, Peers: map[string]models.EndpointObservation{}}
+	record, err := database.FetchRecord(database.ENDPOINT_OBSERVATIONS_TABLE_NAME, nodeID)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return observations, nil
+		}
+		return observations, err
+	}
+	if err := json.Unmarshal([]byte(record), &observations); err != nil {
+		return observations, err
+	}
+	if observations.Peers == nil {
+		observations.Peers = map[string]models.EndpointObservation{}
+	}
+	return observations, nil
+}
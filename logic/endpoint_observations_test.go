@@ -0,0 +1,39 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordEndpointObservations(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	node := &models.Node{}
+	node.ID = uuid.New()
+	defer DeleteEndpointObservations(node.ID.String())
+	report := func(endpoint string, connected bool) {
+		assert.Nil(t, RecordEndpointObservations(node, &models.Metrics{Connectivity: map[string]models.Metric{
+			"peer": {NodeName: "peer", Connected: connected, Endpoint: endpoint},
+		}}))
+	}
+	report("203.0.113.1:51821", true)
+	report("203.0.113.1:51821", true)
+	observations, err := getEndpointObservations(node.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, 0, observations.Peers["peer"].Changes)
+
+	// the peer's NAT gave it a new port
+	report("203.0.113.1:40000", true)
+	// disconnected peers keep the endpoint they were last reached through
+	report("198.51.100.1:51821", false)
+	observations, err = getEndpointObservations(node.ID.String())
+	assert.Nil(t, err)
+	observation := observations.Peers["peer"]
+	assert.Equal(t, "203.0.113.1:40000", observation.Endpoint)
+	assert.Equal(t, "203.0.113.1:51821", observation.Previous)
+	assert.Equal(t, 1, observation.Changes)
+}
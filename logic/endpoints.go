@@ -0,0 +1,516 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+// scores of the endpoint kinds, the highest scoring candidate is used
+const (
+	localEndpointScore = 300
+	// registeredEndpointScore - the score of a registered endpoint with priority 0, less its priority otherwise
+	registeredEndpointScore = 250
+	public6EndpointScore    = 200
+	public4EndpointScore    = 100
+	// unroutableEndpointScore - an IPv6 endpoint of the peer while the host has no public IPv6 address
+	unroutableEndpointScore = 50
+	// lanDisabledEndpointScore - a LAN endpoint on a network with LAN detection turned off
+	lanDisabledEndpointScore = 25
+
+	// addressFamilyFallback - how long IPv4 is used between two hosts after IPv6 was reported failing between them
+	addressFamilyFallback = time.Hour
+)
+
+var (
+	// ErrInvalidEndpoint - an endpoint override must be an ip and port or a kind of endpoint
+	ErrInvalidEndpoint = errors.New("endpoint must be an ip:port or one of local, public6 or public4, or a family of auto, ipv6 or ipv4")
+	// ErrNotAPeer - the hosts don't share a network
+	ErrNotAPeer = errors.New("hosts are not peers")
+	// ErrDuplicateHostEndpoint - a host registered the same endpoint twice
+	ErrDuplicateHostEndpoint = errors.New("duplicate host endpoint")
+
+	endpointOverridesMutex  = &sync.RWMutex{}
+	endpointOverrides       = make(map[string]models.EndpointOverrideRequest)
+	endpointOverridesLoaded bool
+
+	// familyFallbacks - until when host pairs that reported IPv6 failing use IPv4
+	familyFallbacks      = make(map[string]time.Time)
+	familyFallbacksMutex = &sync.Mutex{}
+
+	// lanIfacePrefixes - interfaces whose subnets repeat on every host, so sharing one says nothing about the LAN
+	lanIfacePrefixes = []string{models.WIREGUARD_INTERFACE, "nm-", "docker", "br-", "veth", "cni", "flannel", "cali", "virbr", "lo"}
+)
+
+// PeerEndpointCandidates - scores the endpoints host could reach peerHost through, best first;
+// a shared LAN beats the endpoints the peer registered, in order of priority, which beat IPv6 when
+// both hosts have a public IPv6 address, which beats IPv4. LAN endpoints drop below the public ones when lanDetection is off
+func PeerEndpointCandidates(host, peerHost *models.Host, node, peer *models.Node, lanDetection bool) []models.EndpointCandidate {
+	candidates := []models.EndpointCandidate{}
+	add := func(kind string, ip net.IP, port, score int) {
+		addr, ok := netip.AddrFromSlice(ip)
+		if !ok {
+			return
+		}
+		endpoint := netip.AddrPortFrom(addr.Unmap(), uint16(port)).String()
+		for _, candidate := range candidates {
+			if candidate.Endpoint == endpoint {
+				return
+			}
+		}
+		candidates = append(candidates, models.EndpointCandidate{Kind: kind, Endpoint: endpoint, Score: score})
+	}
+	localScore := localEndpointScore
+	if !lanDetection {
+		localScore = lanDisabledEndpointScore
+	}
+	if ip := SharedLANAddress(host, peerHost); ip != nil {
+		add(models.EndpointLocal, ip, peerHost.ListenPort, localScore)
+	}
+	// hosts behind the same public address are on the same network
+	if peerHost.EndpointIP != nil && host.EndpointIP.Equal(peerHost.EndpointIP) &&
+		node.LocalAddress.IP != nil && peer.LocalAddress.IP != nil &&
+		node.LocalAddress.String() != peer.LocalAddress.String() {
+		add(models.EndpointLocal, peer.LocalAddress.IP, peerHost.ListenPort, localScore)
+	}
+	hasIPv6 := hostPublicIPv6(host) != nil
+	for _, registered := range peerHost.Endpoints {
+		addrPort, err := netip.ParseAddrPort(registered.Endpoint)
+		if err != nil {
+			continue
+		}
+		score := registeredEndpointScore - registered.Priority
+		if addrPort.Addr().Unmap().Is6() && !hasIPv6 {
+			score = unroutableEndpointScore
+		}
+		add(models.EndpointRegistered, net.IP(addrPort.Addr().AsSlice()), int(addrPort.Port()), score)
+	}
+	public6Score := unroutableEndpointScore
+	if hasIPv6 {
+		public6Score = public6EndpointScore
+	}
+	if ip6 := hostPublicIPv6(peerHost); ip6 != nil {
+		port := peerHost.ListenPort
+		if ip6.Equal(peerHost.EndpointIP) {
+			port = GetPeerListenPort(peerHost)
+		}
+		add(models.EndpointPublic6, ip6, port, public6Score)
+	}
+	if peerHost.EndpointIP.To4() != nil {
+		add(models.EndpointPublic4, peerHost.EndpointIP, GetPeerListenPort(peerHost), public4EndpointScore)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	return candidates
+}
+
+// SharedLANAddress - the private address of peerHost on a subnet host also has an interface on, if any
+func SharedLANAddress(host, peerHost *models.Host) net.IP {
+	for _, iface := range host.Interfaces {
+		if !isLANIface(&iface) {
+			continue
+		}
+		for _, peerIface := range peerHost.Interfaces {
+			if !isLANIface(&peerIface) || peerIface.Address.IP.Equal(iface.Address.IP) {
+				continue
+			}
+			// both ends have to agree on the subnet
+			if iface.Address.Contains(peerIface.Address.IP) && peerIface.Address.Contains(iface.Address.IP) {
+				return peerIface.Address.IP
+			}
+		}
+	}
+	return nil
+}
+
+// SelectPeerEndpoint - picks the endpoint host uses for peerHost, an admin's override or the best candidate
+func SelectPeerEndpoint(host, peerHost *models.Host, node, peer *models.Node) models.EndpointSelection {
+	lanDetection := true
+	family := models.AddressFamilyAuto
+	if network, err := GetNetwork(node.Network); err == nil {
+		lanDetection = network.LANDetection != "no"
+		if network.AddressFamily != "" {
+			family = network.AddressFamily
+		}
+	}
+	override, hasOverride := getEndpointOverride(host.ID.String(), peerHost.ID.String())
+	if hasOverride && override.Family != "" {
+		family = override.Family
+	}
+	selection := models.EndpointSelection{
+		PeerHostID: peerHost.ID.String(),
+		PeerName:   peerHost.Name,
+		SharedLAN:  SharedLANAddress(host, peerHost) != nil,
+		Family:     family,
+	}
+	selection.Candidates, selection.FamilyFallback = applyAddressFamily(PeerEndpointCandidates(host, peerHost, node, peer, lanDetection),
+		family, host, node, peer)
+	if hasOverride {
+		if override.Endpoint != "" {
+			selection.Endpoint, selection.Kind, selection.Override = override.Endpoint, models.EndpointOverride, true
+			return selection
+		}
+		for _, candidate := range selection.Candidates {
+			if candidate.Kind == override.Kind {
+				selection.Endpoint, selection.Kind, selection.Override = candidate.Endpoint, candidate.Kind, true
+				return selection
+			}
+		}
+		// no candidate of that kind right now, fall back to the best one
+	}
+	if len(selection.Candidates) > 0 {
+		selection.Endpoint, selection.Kind = selection.Candidates[0].Endpoint, selection.Candidates[0].Kind
+	}
+	return selection
+}
+
+// GetEndpointSelections - the endpoints a host uses for each of its peer hosts
+func GetEndpointSelections(host *models.Host) ([]models.EndpointSelection, error) {
+	selections := []models.EndpointSelection{}
+	seen := make(map[string]struct{})
+	for _, nodeID := range host.Nodes {
+		node, err := GetNodeByID(nodeID)
+		if err != nil {
+			continue
+		}
+		peers, err := GetNetworkNodes(node.Network)
+		if err != nil {
+			return nil, err
+		}
+		// the endpoint the host reported using for each peer
+		metrics, err := GetMetrics(node.ID.String())
+		if err != nil {
+			metrics = &models.Metrics{}
+		}
+		for i := range peers {
+			peer := &peers[i]
+			if peer.HostID == host.ID || peer.PendingDelete {
+				continue
+			}
+			if _, ok := seen[peer.HostID.String()]; ok {
+				continue
+			}
+			peerHost, err := GetHost(peer.HostID.String())
+			if err != nil {
+				continue
+			}
+			seen[peer.HostID.String()] = struct{}{}
+			selection := SelectPeerEndpoint(host, peerHost, &node, peer)
+			selection.InUse = metrics.Connectivity[peer.ID.String()].Endpoint
+			selections = append(selections, selection)
+		}
+	}
+	sort.Slice(selections, func(i, j int) bool {
+		return selections[i].PeerName < selections[j].PeerName
+	})
+	return selections, nil
+}
+
+// SetEndpointOverride - pins the endpoint, or the kind of endpoint, host uses for peerHost
+func SetEndpointOverride(host, peerHost *models.Host, override models.EndpointOverrideRequest) error {
+	switch {
+	case override.Endpoint != "" && override.Kind == "":
+		addrPort, err := netip.ParseAddrPort(override.Endpoint)
+		if err != nil || addrPort.Port() == 0 {
+			return ErrInvalidEndpoint
+		}
+		override.Endpoint = addrPort.String()
+	case override.Endpoint == "" && (override.Kind == models.EndpointLocal ||
+		override.Kind == models.EndpointPublic6 || override.Kind == models.EndpointPublic4):
+	case override.Endpoint == "" && override.Kind == "" && (override.Family == models.AddressFamilyAuto ||
+		override.Family == models.AddressFamilyIPv6 || override.Family == models.AddressFamilyIPv4):
+	default:
+		return ErrInvalidEndpoint
+	}
+	if !HostsArePeers(host, peerHost) {
+		return ErrNotAPeer
+	}
+	data, err := json.Marshal(override)
+	if err != nil {
+		return err
+	}
+	key := endpointOverrideKey(host.ID.String(), peerHost.ID.String())
+	endpointOverridesMutex.Lock()
+	defer endpointOverridesMutex.Unlock()
+	if err := database.Insert(key, string(data), database.ENDPOINT_OVERRIDES_TABLE_NAME); err != nil {
+		return err
+	}
+	endpointOverrides[key] = override
+	return nil
+}
+
+// DeleteEndpointOverride - goes back to selecting the endpoint host uses for peerHost
+func DeleteEndpointOverride(hostID, peerHostID string) error {
+	key := endpointOverrideKey(hostID, peerHostID)
+	endpointOverridesMutex.Lock()
+	defer endpointOverridesMutex.Unlock()
+	if err := database.DeleteRecord(database.ENDPOINT_OVERRIDES_TABLE_NAME, key); err != nil {
+		return err
+	}
+	delete(endpointOverrides, key)
+	return nil
+}
+
+// ValidateHostEndpoints - checks the endpoints a host registered are distinct ip:ports,
+// normalizing them and ordering them by priority
+func ValidateHostEndpoints(endpoints []models.HostEndpoint) error {
+	v := validator.New()
+	seen := make(map[string]struct{}, len(endpoints))
+	for i := range endpoints {
+		if err := v.Struct(endpoints[i]); err != nil {
+			return err
+		}
+		addrPort, err := netip.ParseAddrPort(endpoints[i].Endpoint)
+		if err != nil || addrPort.Port() == 0 {
+			return fmt.Errorf("%w: %s", ErrInvalidEndpoint, endpoints[i].Endpoint)
+		}
+		endpoints[i].Endpoint = addrPort.String()
+		if _, ok := seen[endpoints[i].Endpoint]; ok {
+			return fmt.Errorf("%w: %s", ErrDuplicateHostEndpoint, endpoints[i].Endpoint)
+		}
+		seen[endpoints[i].Endpoint] = struct{}{}
+	}
+	sort.SliceStable(endpoints, func(i, j int) bool {
+		return endpoints[i].Priority < endpoints[j].Priority
+	})
+	return nil
+}
+
+// HostEndpointsChanged - checks if a host registered different endpoints or priorities
+func HostEndpointsChanged(reported, current []models.HostEndpoint) bool {
+	if len(reported) != len(current) {
+		return true
+	}
+	for i := range reported {
+		if reported[i] != current[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// HostsArePeers - checks if two hosts share a network
+func HostsArePeers(host, peerHost *models.Host) bool {
+	if host.ID == peerHost.ID {
+		return false
+	}
+	networks := make(map[string]struct{})
+	for _, nodeID := range host.Nodes {
+		if node, err := GetNodeByID(nodeID); err == nil {
+			networks[node.Network] = struct{}{}
+		}
+	}
+	for _, nodeID := range peerHost.Nodes {
+		if node, err := GetNodeByID(nodeID); err == nil {
+			if _, ok := networks[node.Network]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IfacesChanged - checks if a host reported interfaces or addresses that differ from the ones it had
+func IfacesChanged(reported, current []models.Iface) bool {
+	if len(reported) != len(current) {
+		return true
+	}
+	addrs := make(map[string]struct{}, len(current))
+	for _, iface := range current {
+		addrs[iface.Name+"/"+iface.Address.String()] = struct{}{}
+	}
+	for _, iface := range reported {
+		if _, ok := addrs[iface.Name+"/"+iface.Address.String()]; !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// == private ==
+
+// peerEndpoint - the udp address host reaches peerHost through and the ordered candidates
+// the host can fall back to when it stops working
+func peerEndpoint(host, peerHost *models.Host, node, peer *models.Node) (*net.UDPAddr, []models.EndpointCandidate) {
+	selection := SelectPeerEndpoint(host, peerHost, node, peer)
+	if addrPort, err := netip.ParseAddrPort(selection.Endpoint); err == nil {
+		return net.UDPAddrFromAddrPort(addrPort), selection.Candidates
+	}
+	return &net.UDPAddr{
+		IP:   peerHost.EndpointIP,
+		Port: GetPeerListenPort(peerHost),
+	}, selection.Candidates
+}
+
+// applyAddressFamily - orders the candidates by the address family preferred between two hosts, keeping LAN endpoints
+// first: ipv6 puts every IPv6 endpoint before the IPv4 ones and ipv4 drops the IPv6 ones while there is an IPv4 one.
+// IPv6 endpoints go after the IPv4 ones for a while once the host reported the peer unreachable over IPv6
+func applyAddressFamily(candidates []models.EndpointCandidate, family string, host *models.Host, node, peer *models.Node) ([]models.EndpointCandidate, bool) {
+	hasIPv4 := false
+	for _, candidate := range candidates {
+		if !isIPv6Endpoint(candidate.Endpoint) {
+			hasIPv4 = true
+		}
+	}
+	if !hasIPv4 {
+		return candidates, false
+	}
+	if family == models.AddressFamilyIPv4 {
+		ipv4 := []models.EndpointCandidate{}
+		for _, candidate := range candidates {
+			if !isIPv6Endpoint(candidate.Endpoint) {
+				ipv4 = append(ipv4, candidate)
+			}
+		}
+		return ipv4, false
+	}
+	fallback := false
+	rank := func(candidate models.EndpointCandidate) int {
+		switch {
+		case candidate.Kind == models.EndpointLocal:
+			return 0
+		case fallback:
+			// the fallback overrides a preference for IPv6
+			if isIPv6Endpoint(candidate.Endpoint) {
+				return 2
+			}
+			return 1
+		case family == models.AddressFamilyIPv6 && !isIPv6Endpoint(candidate.Endpoint):
+			return 2
+		}
+		return 1
+	}
+	byRank := func(i, j int) bool {
+		return rank(candidates[i]) < rank(candidates[j])
+	}
+	sort.SliceStable(candidates, byRank)
+	if fallback = ipv6Failing(host, node, peer, candidates); fallback {
+		sort.SliceStable(candidates, byRank)
+	}
+	return candidates, fallback
+}
+
+// ipv6Failing - whether IPv6 recently failed between the host of node and the host of peer: the host reported
+// the peer disconnected while using an IPv6 endpoint of it
+func ipv6Failing(host *models.Host, node, peer *models.Node, candidates []models.EndpointCandidate) bool {
+	key := host.ID.String() + "###" + peer.HostID.String()
+	familyFallbacksMutex.Lock()
+	defer familyFallbacksMutex.Unlock()
+	if until, ok := familyFallbacks[key]; ok {
+		if time.Now().Before(until) {
+			return true
+		}
+		delete(familyFallbacks, key)
+	}
+	if len(candidates) == 0 || !isIPv6Endpoint(candidates[0].Endpoint) {
+		return false
+	}
+	metrics, err := GetMetrics(node.ID.String())
+	if err != nil {
+		return false
+	}
+	metric, ok := metrics.Connectivity[peer.ID.String()]
+	if !ok || metric.Connected || !isIPv6Endpoint(metric.Endpoint) {
+		return false
+	}
+	slog.Warn("ipv6 reported failing between hosts, falling back to ipv4", "host", host.ID.String(), "peer", peer.HostID.String(), "endpoint", metric.Endpoint)
+	familyFallbacks[key] = time.Now().Add(addressFamilyFallback)
+	return true
+}
+
+func isIPv6Endpoint(endpoint string) bool {
+	addrPort, err := netip.ParseAddrPort(endpoint)
+	return err == nil && addrPort.Addr().Unmap().Is6()
+}
+
+// hostPublicIPv6 - a public IPv6 address of the host, its endpoint or one on its interfaces
+func hostPublicIPv6(host *models.Host) net.IP {
+	if host.EndpointIP != nil && host.EndpointIP.To4() == nil {
+		return host.EndpointIP
+	}
+	for _, iface := range host.Interfaces {
+		ip := iface.Address.IP
+		if ip.To4() == nil && ip.IsGlobalUnicast() && !ip.IsPrivate() {
+			return ip
+		}
+	}
+	return nil
+}
+
+// isLANIface - checks if an interface has a private address that could be shared with hosts on the same LAN
+func isLANIface(iface *models.Iface) bool {
+	for _, prefix := range lanIfacePrefixes {
+		if strings.HasPrefix(iface.Name, prefix) {
+			return false
+		}
+	}
+	ip := iface.Address.IP
+	return ip.IsPrivate() && !ip.IsLoopback()
+}
+
+func endpointOverrideKey(hostID, peerHostID string) string {
+	return hostID + "###" + peerHostID
+}
+
+func getEndpointOverride(hostID, peerHostID string) (models.EndpointOverrideRequest, bool) {
+	endpointOverridesMutex.RLock()
+	loaded := endpointOverridesLoaded
+	endpointOverridesMutex.RUnlock()
+	if !loaded {
+		loadEndpointOverrides()
+	}
+	endpointOverridesMutex.RLock()
+	defer endpointOverridesMutex.RUnlock()
+	override, ok := endpointOverrides[endpointOverrideKey(hostID, peerHostID)]
+	return override, ok
+}
+
+// deleteEndpointOverrides - removes the overrides from and to a host that was removed
+func deleteEndpointOverrides(hostID string) {
+	loadEndpointOverrides()
+	endpointOverridesMutex.Lock()
+	defer endpointOverridesMutex.Unlock()
+	for key := range endpointOverrides {
+		ids := strings.Split(key, "###")
+		if ids[0] != hostID && ids[len(ids)-1] != hostID {
+			continue
+		}
+		if err := database.DeleteRecord(database.ENDPOINT_OVERRIDES_TABLE_NAME, key); err != nil {
+			slog.Warn("failed to remove endpoint override", "key", key, "error", err)
+			continue
+		}
+		delete(endpointOverrides, key)
+	}
+}
+
+func loadEndpointOverrides() {
+	endpointOverridesMutex.Lock()
+	defer endpointOverridesMutex.Unlock()
+	if endpointOverridesLoaded {
+		return
+	}
+	records, err := database.FetchRecords(database.ENDPOINT_OVERRIDES_TABLE_NAME)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return
+	}
+	for key, value := range records {
+		var override models.EndpointOverrideRequest
+		if err := json.Unmarshal([]byte(value), &override); err != nil {
+			continue
+		}
+		endpointOverrides[key] = override
+	}
+	endpointOverridesLoaded = true
+}
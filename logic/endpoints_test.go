@@ -0,0 +1,128 @@
+package logic
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeerEndpointCandidates(t *testing.T) {
+	lan := func(ip string) net.IPNet {
+		return net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(24, 32)}
+	}
+	ipv6 := models.Iface{Name: "eth0", Address: net.IPNet{IP: net.ParseIP("2001:db8::1"), Mask: net.CIDRMask(64, 128)}}
+	peerIPv6 := models.Iface{Name: "eth0", Address: net.IPNet{IP: net.ParseIP("2001:db8::2"), Mask: net.CIDRMask(64, 128)}}
+	t.Run("Public", func(t *testing.T) {
+		host := &models.Host{EndpointIP: net.ParseIP("198.51.100.1")}
+		peerHost := &models.Host{EndpointIP: net.ParseIP("203.0.113.1"), ListenPort: 51821}
+		candidates := PeerEndpointCandidates(host, peerHost, &models.Node{}, &models.Node{}, true)
+		assert.Equal(t, 1, len(candidates))
+		assert.Equal(t, models.EndpointPublic4, candidates[0].Kind)
+		assert.Equal(t, "203.0.113.1:51821", candidates[0].Endpoint)
+	})
+	t.Run("SameLAN", func(t *testing.T) {
+		host := &models.Host{EndpointIP: net.ParseIP("198.51.100.1")}
+		peerHost := &models.Host{EndpointIP: net.ParseIP("198.51.100.1"), ListenPort: 51821}
+		node := &models.Node{CommonNode: models.CommonNode{LocalAddress: lan("192.168.1.10")}}
+		peer := &models.Node{CommonNode: models.CommonNode{LocalAddress: lan("192.168.1.20")}}
+		candidates := PeerEndpointCandidates(host, peerHost, node, peer, true)
+		assert.Equal(t, 2, len(candidates))
+		assert.Equal(t, models.EndpointLocal, candidates[0].Kind)
+		assert.Equal(t, "192.168.1.20:51821", candidates[0].Endpoint)
+	})
+	t.Run("SharedSubnet", func(t *testing.T) {
+		host := &models.Host{EndpointIP: net.ParseIP("198.51.100.1"), Interfaces: []models.Iface{{Name: "eth0", Address: lan("10.0.0.5")}}}
+		peerHost := &models.Host{EndpointIP: net.ParseIP("203.0.113.1"), ListenPort: 51821, Interfaces: []models.Iface{{Name: "eth0", Address: lan("10.0.0.6")}}}
+		candidates := PeerEndpointCandidates(host, peerHost, &models.Node{}, &models.Node{}, true)
+		assert.Equal(t, models.EndpointLocal, candidates[0].Kind)
+		assert.Equal(t, "10.0.0.6:51821", candidates[0].Endpoint)
+		// with LAN detection off the public endpoint wins
+		candidates = PeerEndpointCandidates(host, peerHost, &models.Node{}, &models.Node{}, false)
+		assert.Equal(t, models.EndpointPublic4, candidates[0].Kind)
+	})
+	t.Run("DockerBridge", func(t *testing.T) {
+		host := &models.Host{Interfaces: []models.Iface{{Name: "docker0", Address: lan("172.17.0.1")}}}
+		peerHost := &models.Host{Interfaces: []models.Iface{{Name: "docker0", Address: lan("172.17.0.2")}}}
+		assert.Nil(t, SharedLANAddress(host, peerHost))
+	})
+	t.Run("RegisteredEndpoints", func(t *testing.T) {
+		host := &models.Host{EndpointIP: net.ParseIP("198.51.100.1")}
+		peerHost := &models.Host{EndpointIP: net.ParseIP("203.0.113.1"), ListenPort: 51821, Endpoints: []models.HostEndpoint{
+			{Name: "lte", Endpoint: "192.0.2.7:51821", Priority: 10},
+			{Name: "fiber", Endpoint: "203.0.113.1:51821", Priority: 0},
+		}}
+		candidates := PeerEndpointCandidates(host, peerHost, &models.Node{}, &models.Node{}, true)
+		assert.Equal(t, 2, len(candidates))
+		assert.Equal(t, "203.0.113.1:51821", candidates[0].Endpoint)
+		assert.Equal(t, models.EndpointRegistered, candidates[0].Kind)
+		assert.Equal(t, "192.0.2.7:51821", candidates[1].Endpoint)
+	})
+	t.Run("IPv6Available", func(t *testing.T) {
+		host := &models.Host{EndpointIP: net.ParseIP("198.51.100.1"), Interfaces: []models.Iface{ipv6}}
+		peerHost := &models.Host{EndpointIP: net.ParseIP("203.0.113.1"), ListenPort: 51821, Interfaces: []models.Iface{peerIPv6}}
+		candidates := PeerEndpointCandidates(host, peerHost, &models.Node{}, &models.Node{}, true)
+		assert.Equal(t, 2, len(candidates))
+		assert.Equal(t, models.EndpointPublic6, candidates[0].Kind)
+		assert.Equal(t, "[2001:db8::2]:51821", candidates[0].Endpoint)
+	})
+	t.Run("IPv6OnlyOnPeer", func(t *testing.T) {
+		host := &models.Host{EndpointIP: net.ParseIP("198.51.100.1")}
+		peerHost := &models.Host{EndpointIP: net.ParseIP("203.0.113.1"), ListenPort: 51821, Interfaces: []models.Iface{peerIPv6}}
+		candidates := PeerEndpointCandidates(host, peerHost, &models.Node{}, &models.Node{}, true)
+		assert.Equal(t, models.EndpointPublic4, candidates[0].Kind)
+	})
+}
+
+func TestApplyAddressFamily(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	host := &models.Host{ID: uuid.New()}
+	node, peer := &models.Node{}, &models.Node{}
+	node.ID, peer.ID, peer.HostID = uuid.New(), uuid.New(), uuid.New()
+	candidates := func() []models.EndpointCandidate {
+		return []models.EndpointCandidate{
+			{Kind: models.EndpointRegistered, Endpoint: "192.0.2.7:51821", Score: 250},
+			{Kind: models.EndpointPublic6, Endpoint: "[2001:db8::2]:51821", Score: 200},
+			{Kind: models.EndpointPublic4, Endpoint: "203.0.113.1:51821", Score: 100},
+		}
+	}
+	ordered, _ := applyAddressFamily(candidates(), models.AddressFamilyAuto, host, node, peer)
+	assert.Equal(t, "192.0.2.7:51821", ordered[0].Endpoint)
+	ordered, _ = applyAddressFamily(candidates(), models.AddressFamilyIPv6, host, node, peer)
+	assert.Equal(t, models.EndpointPublic6, ordered[0].Kind)
+	ordered, _ = applyAddressFamily(candidates(), models.AddressFamilyIPv4, host, node, peer)
+	assert.Equal(t, 2, len(ordered))
+
+	// the host reported the peer unreachable over IPv6
+	assert.Nil(t, UpdateMetrics(node.ID.String(), &models.Metrics{Connectivity: map[string]models.Metric{
+		peer.ID.String(): {Connected: false, Endpoint: "[2001:db8::2]:51821"},
+	}}))
+	defer database.DeleteRecord(database.METRICS_TABLE_NAME, node.ID.String())
+	ordered, fallback := applyAddressFamily(candidates(), models.AddressFamilyIPv6, host, node, peer)
+	assert.True(t, fallback)
+	assert.Equal(t, "192.0.2.7:51821", ordered[0].Endpoint)
+	assert.Equal(t, models.EndpointPublic6, ordered[2].Kind)
+}
+
+func TestIfacesChanged(t *testing.T) {
+	ifaces := []models.Iface{{Name: "eth0", Address: net.IPNet{IP: net.ParseIP("192.168.1.10"), Mask: net.CIDRMask(24, 32)}}}
+	moved := []models.Iface{{Name: "eth0", Address: net.IPNet{IP: net.ParseIP("192.168.1.11"), Mask: net.CIDRMask(24, 32)}}}
+	assert.False(t, IfacesChanged(ifaces, ifaces))
+	assert.True(t, IfacesChanged(moved, ifaces))
+	assert.True(t, IfacesChanged(nil, ifaces))
+}
+
+func TestValidateHostEndpoints(t *testing.T) {
+	endpoints := []models.HostEndpoint{
+		{Name: "lte", Endpoint: "192.0.2.7:51821", Priority: 10},
+		{Name: "fiber", Endpoint: "[2001:db8::1]:51821", Priority: 0},
+	}
+	assert.Nil(t, ValidateHostEndpoints(endpoints))
+	assert.Equal(t, "fiber", endpoints[0].Name)
+	assert.ErrorIs(t, ValidateHostEndpoints([]models.HostEndpoint{{Endpoint: "192.0.2.7"}}), ErrInvalidEndpoint)
+	assert.ErrorIs(t, ValidateHostEndpoints([]models.HostEndpoint{{Endpoint: "192.0.2.7:1"}, {Endpoint: "192.0.2.7:1", Priority: 1}}), ErrDuplicateHostEndpoint)
+}
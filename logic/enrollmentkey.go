@@ -1,15 +1,19 @@
 package logic
 
 import (
+	"crypto/sha256"
 	b64 "encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"golang.org/x/exp/slices"
+	"sort"
 	"time"
 
 	"github.com/gravitl/netmaker/database"
 	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
 )
 
 // EnrollmentErrors - struct for holding EnrollmentKey error messages
@@ -20,6 +24,7 @@ var EnrollmentErrors = struct {
 	NoUsesRemaining    error
 	FailedToTokenize   error
 	FailedToDeTokenize error
+	InvalidCascade     error
 }{
 	InvalidCreate:      fmt.Errorf("invalid enrollment key created"),
 	NoKeyFound:         fmt.Errorf("no enrollmentkey found"),
@@ -27,10 +32,22 @@ var EnrollmentErrors = struct {
 	NoUsesRemaining:    fmt.Errorf("no uses remaining"),
 	FailedToTokenize:   fmt.Errorf("failed to tokenize"),
 	FailedToDeTokenize: fmt.Errorf("failed to detokenize"),
+	InvalidCascade:     fmt.Errorf("invalid cascade, must be quarantine or flag"),
 }
 
 // CreateEnrollmentKey - creates a new enrollment key in db
 func CreateEnrollmentKey(uses int, expiration time.Time, networks, tags []string, unlimited bool) (k *models.EnrollmentKey, err error) {
+	return CreateTenantEnrollmentKey("", uses, expiration, networks, tags, unlimited)
+}
+
+// CreateTenantEnrollmentKey - creates a new enrollment key owned by a tenant,
+// which may only join hosts to the networks of that tenant
+func CreateTenantEnrollmentKey(tenant string, uses int, expiration time.Time, networks, tags []string, unlimited bool) (k *models.EnrollmentKey, err error) {
+	if tenant != "" {
+		if err = NetworksInTenant(networks, tenant); err != nil {
+			return nil, err
+		}
+	}
 	newKeyID, err := getUniqueEnrollmentID()
 	if err != nil {
 		return nil, err
@@ -43,6 +60,7 @@ func CreateEnrollmentKey(uses int, expiration time.Time, networks, tags []string
 		Networks:      []string{},
 		Tags:          []string{},
 		Type:          models.Undefined,
+		Tenant:        tenant,
 	}
 	if uses > 0 {
 		k.UsesRemaining = uses
@@ -82,17 +100,28 @@ func GetAllEnrollmentKeys() ([]*models.EnrollmentKey, error) {
 	return currentKeysList, nil
 }
 
-// GetEnrollmentKey - fetches a single enrollment key
+// GetEnrollmentKey - fetches a single enrollment key by its value
 // returns nil and error if not found
 func GetEnrollmentKey(value string) (*models.EnrollmentKey, error) {
-	currentKeys, err := getEnrollmentKeysMap()
+	if value == "" {
+		return nil, EnrollmentErrors.NoKeyFound
+	}
+	record, err := database.FetchRecord(database.ENROLLMENT_KEYS_TABLE_NAME, models.EnrollmentKeyRecordKey(value))
 	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return nil, EnrollmentErrors.NoKeyFound
+		}
+		return nil, err
+	}
+	var key models.EnrollmentKey
+	if err = json.Unmarshal([]byte(record), &key); err != nil {
 		return nil, err
 	}
-	if key, ok := currentKeys[value]; ok {
-		return key, nil
+	// guards against a hash collision, however unlikely
+	if key.Value != value {
+		return nil, EnrollmentErrors.NoKeyFound
 	}
-	return nil, EnrollmentErrors.NoKeyFound
+	return &key, nil
 }
 
 // DeleteEnrollmentKey - delete's a given enrollment key by value
@@ -101,7 +130,119 @@ func DeleteEnrollmentKey(value string) error {
 	if err != nil {
 		return err
 	}
-	return database.DeleteRecord(database.ENROLLMENT_KEYS_TABLE_NAME, value)
+	return database.DeleteRecord(database.ENROLLMENT_KEYS_TABLE_NAME, models.EnrollmentKeyRecordKey(value))
+}
+
+// RotateEnrollmentKey - replaces the value of a key, which is its secret, keeping its config and the fingerprint
+// the hosts it enrolled know it by; the old value can't be used anymore
+func RotateEnrollmentKey(value string) (*models.EnrollmentKey, error) {
+	k, err := GetEnrollmentKey(value)
+	if err != nil {
+		return nil, err
+	}
+	newKeyID, err := getUniqueEnrollmentID()
+	if err != nil {
+		return nil, err
+	}
+	k.Fingerprint = EnrollmentKeyFingerprint(k)
+	k.Value = newKeyID
+	k.Token = ""
+	k.Rotated = time.Now().UTC()
+	if err = upsertEnrollmentKey(k); err != nil {
+		return nil, err
+	}
+	if err = database.DeleteRecord(database.ENROLLMENT_KEYS_TABLE_NAME, models.EnrollmentKeyRecordKey(value)); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// RevokeEnrollmentKey - deletes a compromised key; with a cascade the hosts enrolled with it are quarantined,
+// or flagged with a security alert each. Peers have to be sent the update when hosts were quarantined.
+// Hosts that registered before they recorded their key can't be attributed to it, those in its networks
+// are returned as unattributed for an admin to review instead.
+func RevokeEnrollmentKey(value, user, cascade string) (models.EnrollmentKeyRevocation, error) {
+	revocation := models.EnrollmentKeyRevocation{Cascade: cascade, Hosts: []string{}, Quarantined: []string{}, Unattributed: []string{}}
+	if cascade != "" && cascade != models.EnrollmentKeyCascadeQuarantine && cascade != models.EnrollmentKeyCascadeFlag {
+		return revocation, EnrollmentErrors.InvalidCascade
+	}
+	k, err := GetEnrollmentKey(value)
+	if err != nil {
+		return revocation, err
+	}
+	revocation.Fingerprint = EnrollmentKeyFingerprint(k)
+	if err = database.DeleteRecord(database.ENROLLMENT_KEYS_TABLE_NAME, models.EnrollmentKeyRecordKey(value)); err != nil {
+		return revocation, err
+	}
+	hosts, err := GetEnrollmentKeyHosts(revocation.Fingerprint)
+	if err != nil {
+		return revocation, err
+	}
+	for i := range hosts {
+		host := &hosts[i]
+		revocation.Hosts = append(revocation.Hosts, host.ID.String())
+		switch cascade {
+		case models.EnrollmentKeyCascadeQuarantine:
+			if IsHostQuarantined(host.ID.String()) {
+				continue
+			}
+			if _, err := QuarantineHost(host.ID.String(), user, &models.HostQuarantineRequest{Reason: "enrolled with revoked enrollment key " + revocation.Fingerprint}); err != nil {
+				slog.Error("failed to quarantine host of revoked enrollment key", "host", host.ID, "error", err)
+				continue
+			}
+			revocation.Quarantined = append(revocation.Quarantined, host.ID.String())
+		case models.EnrollmentKeyCascadeFlag:
+			RaiseSecurityEvent(models.SecurityEvent{
+				Kind:    models.SecurityEventEnrollmentKeyRevoked,
+				Message: fmt.Sprintf("host %s was enrolled with enrollment key %s, revoked by %s", host.Name, revocation.Fingerprint, user),
+			})
+		}
+	}
+	unattributed, err := getUnattributedHosts(k.Networks)
+	if err != nil {
+		return revocation, err
+	}
+	for _, host := range unattributed {
+		revocation.Unattributed = append(revocation.Unattributed, host.ID.String())
+	}
+	if len(revocation.Unattributed) > 0 && cascade != "" {
+		slog.Warn("hosts that may have been enrolled with revoked enrollment key were left out of the cascade", "fingerprint", revocation.Fingerprint, "count", len(revocation.Unattributed))
+	}
+	return revocation, nil
+}
+
+// GetEnrollmentKeyHosts - the hosts enrolled with the key of a fingerprint
+func GetEnrollmentKeyHosts(fingerprint string) ([]models.Host, error) {
+	enrolled := []models.Host{}
+	if fingerprint == "" {
+		return enrolled, nil
+	}
+	hosts, err := GetAllHosts()
+	if err != nil {
+		return nil, err
+	}
+	for _, host := range hosts {
+		if host.EnrolledWith == fingerprint {
+			enrolled = append(enrolled, host)
+		}
+	}
+	sort.Slice(enrolled, func(i, j int) bool {
+		return enrolled[i].Name < enrolled[j].Name
+	})
+	return enrolled, nil
+}
+
+// EnrollmentKeyFingerprint - identifies a key without its secret value, the same across rotations;
+// empty for the keys made up for a single registration that have no value
+func EnrollmentKeyFingerprint(k *models.EnrollmentKey) string {
+	if k.Fingerprint != "" {
+		return k.Fingerprint
+	}
+	if k.Value == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(k.Value))
+	return hex.EncodeToString(sum[:8])
 }
 
 // TryToUseEnrollmentKey - checks first if key can be decremented
@@ -162,6 +303,27 @@ func DeTokenize(b64Token string) (*models.EnrollmentKey, error) {
 
 // == private ==
 
+// getUnattributedHosts - the hosts with a node in one of the networks that don't record the key they enrolled with
+func getUnattributedHosts(networks []string) ([]models.Host, error) {
+	unattributed := []models.Host{}
+	hosts, err := GetAllHosts()
+	if err != nil {
+		return nil, err
+	}
+	for i := range hosts {
+		if hosts[i].EnrolledWith != "" {
+			continue
+		}
+		for _, node := range GetHostNodes(&hosts[i]) {
+			if StringSliceContains(networks, node.Network) {
+				unattributed = append(unattributed, hosts[i])
+				break
+			}
+		}
+	}
+	return unattributed, nil
+}
+
 // decrementEnrollmentKey - decrements the uses on a key if above 0 remaining
 func decrementEnrollmentKey(value string) (*models.EnrollmentKey, error) {
 	k, err := GetEnrollmentKey(value)
@@ -187,19 +349,20 @@ func upsertEnrollmentKey(k *models.EnrollmentKey) error {
 	if err != nil {
 		return err
 	}
-	return database.Insert(k.Value, string(data), database.ENROLLMENT_KEYS_TABLE_NAME)
+	return database.Insert(models.EnrollmentKeyRecordKey(k.Value), string(data), database.ENROLLMENT_KEYS_TABLE_NAME)
 }
 
 func getUniqueEnrollmentID() (string, error) {
-	currentKeys, err := getEnrollmentKeysMap()
-	if err != nil {
-		return "", err
-	}
-	newID := RandomString(models.EnrollmentKeyLength)
-	for _, ok := currentKeys[newID]; ok; {
-		newID = RandomString(models.EnrollmentKeyLength)
+	for {
+		newID := RandomString(models.EnrollmentKeyLength)
+		_, err := database.FetchRecord(database.ENROLLMENT_KEYS_TABLE_NAME, models.EnrollmentKeyRecordKey(newID))
+		if database.IsEmptyRecord(err) {
+			return newID, nil
+		}
+		if err != nil {
+			return "", err
+		}
 	}
-	return newID, nil
 }
 
 func getEnrollmentKeysMap() (map[string]*models.EnrollmentKey, error) {
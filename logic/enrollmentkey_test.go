@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gravitl/netmaker/database"
 	"github.com/gravitl/netmaker/models"
 	"github.com/stretchr/testify/assert"
@@ -55,6 +56,17 @@ func TestCreateEnrollmentKey(t *testing.T) {
 			assert.Equal(t, len(keys[i].Value), models.EnrollmentKeyLength)
 		}
 	})
+	t.Run("Value_Is_Not_The_Record_Key", func(t *testing.T) {
+		newKey, err := CreateEnrollmentKey(1, time.Time{}, nil, nil, false)
+		assert.Nil(t, err)
+		_, err = database.FetchRecord(database.ENROLLMENT_KEYS_TABLE_NAME, newKey.Value)
+		assert.True(t, database.IsEmptyRecord(err))
+		found, err := GetEnrollmentKey(newKey.Value)
+		assert.Nil(t, err)
+		assert.Equal(t, newKey.Value, found.Value)
+		_, err = GetEnrollmentKey(models.EnrollmentKeyRecordKey(newKey.Value))
+		assert.Equal(t, EnrollmentErrors.NoKeyFound, err)
+	})
 	removeAllEnrollments()
 }
 
@@ -79,6 +91,70 @@ func TestDelete_EnrollmentKey(t *testing.T) {
 	removeAllEnrollments()
 }
 
+func TestRotate_EnrollmentKey(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	newKey, _ := CreateEnrollmentKey(0, time.Time{}, []string{"mynet", "skynet"}, []string{"tag1"}, true)
+	oldValue := newKey.Value
+	fingerprint := EnrollmentKeyFingerprint(newKey)
+	t.Run("Rotate_Key", func(t *testing.T) {
+		rotated, err := RotateEnrollmentKey(oldValue)
+		assert.Nil(t, err)
+		assert.NotEqual(t, oldValue, rotated.Value)
+		assert.Equal(t, models.EnrollmentKeyLength, len(rotated.Value))
+		assert.Equal(t, fingerprint, EnrollmentKeyFingerprint(rotated))
+		assert.Equal(t, []string{"mynet", "skynet"}, rotated.Networks)
+		assert.Equal(t, []string{"tag1"}, rotated.Tags)
+		assert.True(t, rotated.Unlimited)
+		_, err = GetEnrollmentKey(oldValue)
+		assert.Equal(t, EnrollmentErrors.NoKeyFound, err)
+		current, err := GetEnrollmentKey(rotated.Value)
+		assert.Nil(t, err)
+		assert.Equal(t, fingerprint, current.Fingerprint)
+	})
+	t.Run("Rotate_Unknown_Key", func(t *testing.T) {
+		_, err := RotateEnrollmentKey(oldValue)
+		assert.Equal(t, EnrollmentErrors.NoKeyFound, err)
+	})
+	t.Run("Revoke_Invalid_Cascade", func(t *testing.T) {
+		_, err := RevokeEnrollmentKey(oldValue, "admin", "delete")
+		assert.Equal(t, EnrollmentErrors.InvalidCascade, err)
+	})
+	removeAllEnrollments()
+}
+
+func TestRevoke_EnrollmentKey(t *testing.T) {
+	database.InitializeDatabase()
+	t.Cleanup(database.CloseDB)
+	t.Cleanup(removeAllEnrollments)
+	t.Cleanup(func() { database.DeleteAllRecords(database.SECURITY_EVENTS_TABLE_NAME) })
+	key, err := CreateEnrollmentKey(0, time.Time{}, []string{"revokenet"}, nil, true)
+	assert.Nil(t, err)
+	fingerprint := EnrollmentKeyFingerprint(key)
+	host := func(name, network, enrolledWith string) *models.Host {
+		h := models.Host{ID: uuid.New(), Name: name, EnrolledWith: enrolledWith}
+		node := models.Node{CommonNode: models.CommonNode{ID: uuid.New(), HostID: h.ID, Network: network}}
+		assert.Nil(t, UpsertNode(&node))
+		h.Nodes = []string{node.ID.String()}
+		assert.Nil(t, UpsertHost(&h))
+		t.Cleanup(func() {
+			deleteNodeByID(&node)
+			RemoveHostByID(h.ID.String())
+		})
+		return &h
+	}
+	enrolled := host("enrolled", "revokenet", fingerprint)
+	legacy := host("legacy", "revokenet", "")
+	host("elsewhere", "othernet", "")
+
+	revocation, err := RevokeEnrollmentKey(key.Value, "admin", models.EnrollmentKeyCascadeFlag)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{enrolled.ID.String()}, revocation.Hosts)
+	assert.Equal(t, []string{legacy.ID.String()}, revocation.Unattributed, "hosts that don't record their key are listed for review")
+	_, err = GetEnrollmentKey(key.Value)
+	assert.Equal(t, EnrollmentErrors.NoKeyFound, err)
+}
+
 func TestDecrement_EnrollmentKey(t *testing.T) {
 	database.InitializeDatabase()
 	defer database.CloseDB()
@@ -0,0 +1,81 @@
+package logic
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// DefaultEphemeralTTL - how long an ephemeral node is kept after disconnecting when its key doesn't say
+	DefaultEphemeralTTL = 10 * time.Minute
+	// EphemeralCheckInterval - how often ephemeral nodes are checked for deletion
+	EphemeralCheckInterval = time.Minute
+)
+
+var (
+	// ErrEphemeralNode - ephemeral nodes come and go too quickly to relay or fail over for other nodes
+	ErrEphemeralNode = errors.New("an ephemeral node can not be a relay or failover")
+	// ErrInvalidEphemeralTTL - the time an ephemeral node is kept after disconnecting can't be negative
+	ErrInvalidEphemeralTTL = errors.New("invalid ephemeral ttl, must not be negative")
+)
+
+// SetEnrollmentKeyEphemeral - makes the nodes joined with a key ephemeral, deleted once they have been
+// disconnected for ttl (DefaultEphemeralTTL when zero)
+func SetEnrollmentKeyEphemeral(k *models.EnrollmentKey, ttl time.Duration) error {
+	if ttl < 0 {
+		return ErrInvalidEphemeralTTL
+	}
+	if ttl == 0 {
+		ttl = DefaultEphemeralTTL
+	}
+	k.Ephemeral = true
+	k.EphemeralTTL = int64(ttl.Seconds())
+	return upsertEnrollmentKey(k)
+}
+
+// IsEphemeralExpired - checks if an ephemeral node has been disconnected for longer than its ttl,
+// a node is disconnected once it goes offline
+func IsEphemeralExpired(node *models.Node, now time.Time) bool {
+	if !node.Ephemeral {
+		return false
+	}
+	disconnectedAt := nodeOfflineSince(node)
+	ttl := node.EphemeralTTL
+	if ttl <= 0 {
+		ttl = DefaultEphemeralTTL
+	}
+	return now.After(disconnectedAt.Add(ttl))
+}
+
+// DeleteEphemeralNodes - deletes ephemeral nodes past their ttl, along with their hosts once they have
+// no nodes left; returns the nodes deleted for their peers to be updated
+func DeleteEphemeralNodes() ([]models.Node, error) {
+	allnodes, err := GetAllNodes()
+	if err != nil {
+		return nil, err
+	}
+	deleted := []models.Node{}
+	now := time.Now()
+	for _, node := range allnodes {
+		if !IsEphemeralExpired(&node, now) {
+			continue
+		}
+		if err := DeleteNode(&node, true); err != nil {
+			slog.Error("error deleting ephemeral node", "nodeid", node.ID.String(), "error", err.Error())
+			continue
+		}
+		node.Action = models.NODE_DELETE
+		node.PendingDelete = true
+		deleted = append(deleted, node)
+		slog.Info("deleted ephemeral node", "nodeid", node.ID.String(), "network", node.Network)
+		if host, err := GetHost(node.HostID.String()); err == nil && len(host.Nodes) == 0 {
+			if err := RemoveHost(host, false); err != nil {
+				slog.Error("error deleting host of ephemeral node", "hostid", host.ID.String(), "error", err.Error())
+			}
+		}
+	}
+	return deleted, nil
+}
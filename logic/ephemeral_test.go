@@ -0,0 +1,35 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsEphemeralExpired(t *testing.T) {
+	now := time.Now()
+	node := models.Node{Ephemeral: true, EphemeralTTL: time.Minute}
+	node.Connected = true
+	t.Run("CheckingIn", func(t *testing.T) {
+		node.LastCheckIn = now.Add(-time.Minute)
+		assert.False(t, IsEphemeralExpired(&node, now))
+	})
+	t.Run("StoppedCheckingIn", func(t *testing.T) {
+		node.LastCheckIn = now.Add(-nodeOfflineAfter - 2*time.Minute)
+		assert.True(t, IsEphemeralExpired(&node, now))
+	})
+	t.Run("SetDisconnected", func(t *testing.T) {
+		node.Connected = false
+		node.LastCheckIn = now
+		node.LastModified = now.Add(-2 * time.Minute)
+		assert.True(t, IsEphemeralExpired(&node, now))
+		node.LastModified = now.Add(-30 * time.Second)
+		assert.False(t, IsEphemeralExpired(&node, now))
+	})
+	t.Run("NotEphemeral", func(t *testing.T) {
+		lasting := models.Node{LastCheckIn: now.Add(-24 * time.Hour)}
+		assert.False(t, IsEphemeralExpired(&lasting, now))
+	})
+}
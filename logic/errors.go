@@ -4,8 +4,8 @@ import (
 	"encoding/json"
 	"net/http"
 
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
 )
 
 // FormatError - takes ErrorResponse and uses correct code
@@ -23,6 +23,10 @@ func FormatError(err error, errType string) models.ErrorResponse {
 		status = http.StatusUnauthorized
 	case "forbidden":
 		status = http.StatusForbidden
+	case "conflict":
+		status = http.StatusConflict
+	case "toomanyrequests":
+		status = http.StatusTooManyRequests
 	default:
 		status = http.StatusInternalServerError
 	}
@@ -44,6 +48,19 @@ func ReturnSuccessResponse(response http.ResponseWriter, request *http.Request,
 	json.NewEncoder(response).Encode(httpResponse)
 }
 
+// ReturnJSONResponse - responds with a model as json, with the fields tagged redact:"true" removed;
+// a model with untagged secret-looking fields is refused with an internal error instead of being sent
+func ReturnJSONResponse(response http.ResponseWriter, request *http.Request, v any) {
+	redacted, err := RedactSecrets(v)
+	if err != nil {
+		ReturnErrorResponse(response, request, FormatError(err, "internal"))
+		return
+	}
+	response.Header().Set("Content-Type", "application/json")
+	response.WriteHeader(http.StatusOK)
+	json.NewEncoder(response).Encode(redacted)
+}
+
 // ReturnErrorResponse - processes error and adds header
 func ReturnErrorResponse(response http.ResponseWriter, request *http.Request, errorMessage models.ErrorResponse) {
 	httpResponse := &models.ErrorResponse{Code: errorMessage.Code, Message: errorMessage.Message}
@@ -51,7 +68,7 @@ func ReturnErrorResponse(response http.ResponseWriter, request *http.Request, er
 	if err != nil {
 		panic(err)
 	}
-	logger.Log(1, "processed request error:", errorMessage.Message)
+	slog.ErrorCtx(request.Context(), "processed request error", "message", errorMessage.Message)
 	response.Header().Set("Content-Type", "application/json")
 	response.WriteHeader(errorMessage.Code)
 	response.Write(jsonResponse)
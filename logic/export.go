@@ -0,0 +1,242 @@
+package logic
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic/acls"
+	"github.com/gravitl/netmaker/logic/acls/nodeacls"
+	"github.com/gravitl/netmaker/models"
+)
+
+// resources that can be exported
+const (
+	// ExportNetworks - networks and their address ranges and default ACL
+	ExportNetworks = "networks"
+	// ExportNodes - nodes with their addresses, gateways and last check in
+	ExportNodes = "nodes"
+	// ExportACLs - whether each pair of nodes in a network may talk
+	ExportACLs = "acls"
+	// ExportUsers - users and their roles, never their passwords
+	ExportUsers = "users"
+	// ExportAudit - the changes users made through the api
+	ExportAudit = "audit"
+)
+
+// ErrInvalidExport - the resource asked for can't be exported
+var ErrInvalidExport = errors.New("invalid export, must be networks, nodes, acls, users or audit")
+
+// exportColumns - the columns of each export, in the order they are written to csv
+var exportColumns = map[string][]string{
+	ExportNetworks: {"network", "tenant", "addressrange", "addressrange6", "defaultacl", "lastmodified"},
+	ExportNodes:    {"id", "network", "host", "address", "address6", "connected", "isrelay", "isrelayed", "isegressgateway", "isingressgateway", "lastcheckin"},
+	ExportACLs:     {"network", "node", "nodehost", "peer", "peerhost", "allowed"},
+	ExportUsers:    {"username", "isadmin", "isauditor", "tenant", "networks", "groups"},
+	ExportAudit:    {"time", "user", "tenant", "method", "path", "status", "remoteaddr"},
+}
+
+// GetExport - exports a resource as rows for compliance evidence, limited to a tenant if one is given,
+// from and to only apply to the audit log
+func GetExport(resource, tenant string, from, to time.Time) (models.Export, error) {
+	columns, ok := exportColumns[resource]
+	if !ok {
+		return models.Export{}, ErrInvalidExport
+	}
+	export := models.Export{
+		Resource:    resource,
+		GeneratedAt: time.Now().UTC(),
+		Columns:     columns,
+		Rows:        []map[string]string{},
+	}
+	var err error
+	switch resource {
+	case ExportNetworks:
+		export.Rows, err = exportNetworks(tenant)
+	case ExportNodes:
+		export.Rows, err = exportNodes(tenant)
+	case ExportACLs:
+		export.Rows, err = exportACLs(tenant)
+	case ExportUsers:
+		export.Rows, err = exportUsers(tenant)
+	case ExportAudit:
+		export.Rows, err = exportAuditLogs(from, to, tenant)
+	}
+	return export, err
+}
+
+// == private ==
+
+// exportedNetworks - the networks within a tenant, or every network when tenant is empty
+func exportedNetworks(tenant string) ([]models.Network, error) {
+	networks, err := GetNetworks()
+	if err != nil && !database.IsEmptyRecord(err) {
+		return nil, err
+	}
+	exported := []models.Network{}
+	for _, network := range networks {
+		if tenant == "" || network.Tenant == tenant {
+			exported = append(exported, network)
+		}
+	}
+	sort.Slice(exported, func(i, j int) bool {
+		return exported[i].NetID < exported[j].NetID
+	})
+	return exported, nil
+}
+
+// hostNames - the names of every host by ID
+func hostNames() map[string]string {
+	names := make(map[string]string)
+	hosts, err := GetAllHosts()
+	if err != nil {
+		return names
+	}
+	for _, host := range hosts {
+		names[host.ID.String()] = host.Name
+	}
+	return names
+}
+
+func exportNetworks(tenant string) ([]map[string]string, error) {
+	networks, err := exportedNetworks(tenant)
+	if err != nil {
+		return nil, err
+	}
+	rows := []map[string]string{}
+	for _, network := range networks {
+		rows = append(rows, map[string]string{
+			"network":       network.NetID,
+			"tenant":        network.Tenant,
+			"addressrange":  network.AddressRange,
+			"addressrange6": network.AddressRange6,
+			"defaultacl":    network.DefaultACL,
+			"lastmodified":  time.Unix(network.NetworkLastModified, 0).UTC().Format(time.RFC3339),
+		})
+	}
+	return rows, nil
+}
+
+func exportNodes(tenant string) ([]map[string]string, error) {
+	networks, err := exportedNetworks(tenant)
+	if err != nil {
+		return nil, err
+	}
+	allNodes, err := GetAllNodes()
+	if err != nil {
+		return nil, err
+	}
+	names := hostNames()
+	rows := []map[string]string{}
+	for _, network := range networks {
+		nodes := GetNetworkNodesMemory(allNodes, network.NetID)
+		for _, node := range nodes {
+			rows = append(rows, map[string]string{
+				"id":               node.ID.String(),
+				"network":          node.Network,
+				"host":             names[node.HostID.String()],
+				"address":          exportIP(node.Address.IP),
+				"address6":         exportIP(node.Address6.IP),
+				"connected":        strconv.FormatBool(node.Connected),
+				"isrelay":          strconv.FormatBool(node.IsRelay),
+				"isrelayed":        strconv.FormatBool(node.IsRelayed),
+				"isegressgateway":  strconv.FormatBool(node.IsEgressGateway),
+				"isingressgateway": strconv.FormatBool(node.IsIngressGateway),
+				"lastcheckin":      node.LastCheckIn.UTC().Format(time.RFC3339),
+			})
+		}
+	}
+	return rows, nil
+}
+
+func exportACLs(tenant string) ([]map[string]string, error) {
+	networks, err := exportedNetworks(tenant)
+	if err != nil {
+		return nil, err
+	}
+	allNodes, err := GetAllNodes()
+	if err != nil {
+		return nil, err
+	}
+	names := hostNames()
+	rows := []map[string]string{}
+	for _, network := range networks {
+		nodes := GetNetworkNodesMemory(allNodes, network.NetID)
+		networkACL, err := nodeacls.FetchAllACLs(nodeacls.NetworkID(network.NetID))
+		if err != nil {
+			if database.IsEmptyRecord(err) {
+				continue
+			}
+			return nil, err
+		}
+		for i, node := range nodes {
+			for _, peer := range nodes[i+1:] {
+				nodeID, peerID := acls.AclID(node.ID.String()), acls.AclID(peer.ID.String())
+				rows = append(rows, map[string]string{
+					"network":  network.NetID,
+					"node":     node.ID.String(),
+					"nodehost": names[node.HostID.String()],
+					"peer":     peer.ID.String(),
+					"peerhost": names[peer.HostID.String()],
+					"allowed":  strconv.FormatBool(networkACL[nodeID].IsAllowed(peerID) && networkACL[peerID].IsAllowed(nodeID)),
+				})
+			}
+		}
+	}
+	return rows, nil
+}
+
+func exportUsers(tenant string) ([]map[string]string, error) {
+	users, err := GetUsers()
+	if err != nil && !database.IsEmptyRecord(err) {
+		return nil, err
+	}
+	SortUsers(users)
+	rows := []map[string]string{}
+	for _, user := range users {
+		if tenant != "" && user.Tenant != tenant {
+			continue
+		}
+		rows = append(rows, map[string]string{
+			"username":  user.UserName,
+			"isadmin":   strconv.FormatBool(user.IsAdmin),
+			"isauditor": strconv.FormatBool(user.IsAuditor),
+			"tenant":    user.Tenant,
+			"networks":  strings.Join(user.Networks, " "),
+			"groups":    strings.Join(user.Groups, " "),
+		})
+	}
+	return rows, nil
+}
+
+func exportAuditLogs(from, to time.Time, tenant string) ([]map[string]string, error) {
+	entries, err := GetAuditLogs(from, to, tenant)
+	if err != nil {
+		return nil, err
+	}
+	rows := []map[string]string{}
+	for _, entry := range entries {
+		rows = append(rows, map[string]string{
+			"time":       entry.Time.UTC().Format(time.RFC3339),
+			"user":       entry.User,
+			"tenant":     entry.Tenant,
+			"method":     entry.Method,
+			"path":       entry.Path,
+			"status":     strconv.Itoa(entry.Status),
+			"remoteaddr": entry.RemoteAddr,
+		})
+	}
+	return rows, nil
+}
+
+// exportIP - an address, or nothing when the node doesn't have one
+func exportIP(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
@@ -0,0 +1,146 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gravitl/netmaker/models"
+)
+
+// ErrInvalidExtClientAccess - an ext client access setting is malformed or names a policy the network doesn't have
+var ErrInvalidExtClientAccess = errors.New("invalid ext client access")
+
+// SetNetworkExtClientAccess - sets the default access of a network's new ext clients and its named policies
+func SetNetworkExtClientAccess(netID string, settings *models.NetworkExtClientAccess) (models.Network, error) {
+	if err := validator.New().Struct(settings); err != nil {
+		return models.Network{}, fmt.Errorf("%w: %s", ErrInvalidExtClientAccess, err.Error())
+	}
+	network, err := GetNetwork(netID)
+	if err != nil {
+		return models.Network{}, err
+	}
+	if err := validateExtClientAccess(settings.Default, settings.Policies); err != nil {
+		return models.Network{}, err
+	}
+	nodes, err := GetNetworkNodes(netID)
+	if err != nil {
+		return models.Network{}, err
+	}
+	for _, node := range nodes {
+		if err := validateExtClientAccess(node.ExtClientAccess, settings.Policies); err != nil {
+			return models.Network{}, fmt.Errorf("%w, used by gateway %s", err, node.ID.String())
+		}
+	}
+	current := network
+	network.ExtClientAccess = settings.Default
+	network.ExtClientPolicies = settings.Policies
+	if _, _, _, _, _, err = UpdateNetwork(&current, &network); err != nil {
+		return models.Network{}, err
+	}
+	return network, nil
+}
+
+// SetGatewayExtClientAccess - sets the access of the ext clients created on an ingress gateway,
+// nil leaves it to the network's default
+func SetGatewayExtClientAccess(nodeID string, access *models.ExtClientAccess) (models.Node, error) {
+	node, err := GetNodeByID(nodeID)
+	if err != nil {
+		return models.Node{}, err
+	}
+	if !node.IsIngressGateway {
+		return models.Node{}, errors.New("node is not an ingress gateway")
+	}
+	network, err := GetNetwork(node.Network)
+	if err != nil {
+		return models.Node{}, err
+	}
+	if err := validateExtClientAccess(access, network.ExtClientPolicies); err != nil {
+		return models.Node{}, err
+	}
+	node.ExtClientAccess = access
+	if err = UpsertNode(&node); err != nil {
+		return models.Node{}, err
+	}
+	return node, nil
+}
+
+// ApplyExtClientAccess - gives a new ext client the access of its gateway, or else of its network, setting the
+// allowed ips of its config and denying it the nodes outside of them
+func ApplyExtClientAccess(client *models.ExtClient, gateway *models.Node) error {
+	network, err := GetNetwork(gateway.Network)
+	if err != nil {
+		return err
+	}
+	access := models.ExtClientAccess{Mode: models.ExtClientAccessNetwork}
+	if gateway.ExtClientAccess != nil {
+		access = *gateway.ExtClientAccess
+	} else if network.ExtClientAccess != nil {
+		access = *network.ExtClientAccess
+	}
+	switch access.Mode {
+	case models.ExtClientAccessNetwork:
+		client.AllowedRanges = nil
+		return nil
+	case models.ExtClientAccessGateway:
+		client.AllowedRanges = []string{}
+		if gateway.Address.IP != nil {
+			client.AllowedRanges = append(client.AllowedRanges, gateway.Address.IP.String()+"/32")
+		}
+		if gateway.Address6.IP != nil {
+			client.AllowedRanges = append(client.AllowedRanges, gateway.Address6.IP.String()+"/128")
+		}
+		if gateway.IsEgressGateway {
+			client.AllowedRanges = append(client.AllowedRanges, gateway.EgressGatewayRanges...)
+		}
+	case models.ExtClientAccessPolicy:
+		ranges, ok := network.ExtClientPolicies[access.Policy]
+		if !ok {
+			return fmt.Errorf("%w: unknown policy %s", ErrInvalidExtClientAccess, access.Policy)
+		}
+		client.AllowedRanges = append([]string{}, ranges...)
+	}
+	nodes, err := GetNetworkNodes(gateway.Network)
+	if err != nil {
+		return err
+	}
+	for i := range nodes {
+		if !nodeInRanges(&nodes[i], client.AllowedRanges) {
+			DenyClientNodeAccess(client, nodes[i].ID.String())
+		}
+	}
+	return nil
+}
+
+// == private ==
+
+// validateExtClientAccess - checks an access setting is well formed and its policy is one of the network's
+func validateExtClientAccess(access *models.ExtClientAccess, policies map[string][]string) error {
+	if access == nil {
+		return nil
+	}
+	if err := validator.New().Struct(access); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidExtClientAccess, err.Error())
+	}
+	if access.Mode == models.ExtClientAccessPolicy {
+		if _, ok := policies[access.Policy]; !ok {
+			return fmt.Errorf("%w: unknown policy %s", ErrInvalidExtClientAccess, access.Policy)
+		}
+	}
+	return nil
+}
+
+// nodeInRanges - whether one of the addresses of a node is within the ranges
+func nodeInRanges(node *models.Node, ranges []string) bool {
+	for _, cidr := range ranges {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		if (node.Address.IP != nil && ipNet.Contains(node.Address.IP)) || (node.Address6.IP != nil && ipNet.Contains(node.Address6.IP)) {
+			return true
+		}
+	}
+	return false
+}
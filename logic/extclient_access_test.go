@@ -0,0 +1,27 @@
+package logic
+
+import (
+	"net"
+	"testing"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateExtClientAccess(t *testing.T) {
+	policies := map[string][]string{"dmz": {"10.10.0.0/24"}}
+	assert.Nil(t, validateExtClientAccess(nil, nil))
+	assert.Nil(t, validateExtClientAccess(&models.ExtClientAccess{Mode: models.ExtClientAccessGateway}, nil))
+	assert.Nil(t, validateExtClientAccess(&models.ExtClientAccess{Mode: models.ExtClientAccessPolicy, Policy: "dmz"}, policies))
+	assert.ErrorIs(t, validateExtClientAccess(&models.ExtClientAccess{Mode: models.ExtClientAccessPolicy, Policy: "lab"}, policies), ErrInvalidExtClientAccess)
+	assert.ErrorIs(t, validateExtClientAccess(&models.ExtClientAccess{Mode: models.ExtClientAccessPolicy}, policies), ErrInvalidExtClientAccess)
+	assert.ErrorIs(t, validateExtClientAccess(&models.ExtClientAccess{Mode: "everything"}, policies), ErrInvalidExtClientAccess)
+}
+
+func TestNodeInRanges(t *testing.T) {
+	node := models.Node{}
+	node.Address = net.IPNet{IP: net.ParseIP("10.10.0.5"), Mask: net.CIDRMask(32, 32)}
+	assert.True(t, nodeInRanges(&node, []string{"10.10.0.0/24"}))
+	assert.False(t, nodeInRanges(&node, []string{"10.20.0.0/24", "bad"}))
+	assert.False(t, nodeInRanges(&node, []string{}))
+}
@@ -0,0 +1,160 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+// ErrSecondaryGateway - a secondary gateway has to be another ingress gateway of the client's network
+var ErrSecondaryGateway = errors.New("secondary gateways must be other ingress gateways of the client's network")
+
+// SetExtClientSecondaryGateways - sets the standby ingress gateways of an ext client after checking
+// they are ingress gateways of its network other than its primary
+func SetExtClientSecondaryGateways(client *models.ExtClient, gatewayIDs []string) error {
+	secondaries := []string{}
+	for _, id := range gatewayIDs {
+		if id == client.IngressGatewayID || StringSliceContains(secondaries, id) {
+			return ErrSecondaryGateway
+		}
+		gateway, err := GetNodeByID(id)
+		if err != nil || !gateway.IsIngressGateway || gateway.Network != client.Network {
+			return ErrSecondaryGateway
+		}
+		secondaries = append(secondaries, id)
+	}
+	client.SecondaryGatewayIDs = secondaries
+	return nil
+}
+
+// GetGatewayExtClients - the ext clients of a network an ingress gateway serves, as their primary or a secondary
+func GetGatewayExtClients(gatewayID, network string) ([]models.ExtClient, error) {
+	var result []models.ExtClient
+	clients, err := GetNetworkExtClients(network)
+	if err != nil {
+		return result, err
+	}
+	for i := range clients {
+		if extClientUsesGateway(&clients[i], gatewayID) {
+			result = append(result, clients[i])
+		}
+	}
+	return result, nil
+}
+
+// ActiveExtClientGateway - the gateway the network routes an ext client through: the first online gateway
+// the client has a handshake with, else the first online gateway, else the primary
+func ActiveExtClientGateway(client *models.ExtClient) string {
+	if len(client.SecondaryGatewayIDs) == 0 {
+		return client.IngressGatewayID
+	}
+	now := time.Now()
+	online := []string{}
+	for _, id := range client.GatewayIDs() {
+		gateway, err := GetNodeByID(id)
+		if err != nil || !gateway.IsIngressGateway || !IsNodeOnline(&gateway, now) {
+			continue
+		}
+		if metrics, err := GetMetrics(id); err == nil && metrics.Connectivity[client.ClientID].Connected {
+			return id
+		}
+		online = append(online, id)
+	}
+	if len(online) > 0 {
+		return online[0]
+	}
+	return client.IngressGatewayID
+}
+
+// GetExtClientGateways - the endpoint candidates of an ext client, the primary gateway first
+func GetExtClientGateways(client *models.ExtClient) (models.ExtClientGateways, error) {
+	result := models.ExtClientGateways{
+		ClientID: client.ClientID,
+		Network:  client.Network,
+		Gateways: []models.ExtClientGateway{},
+	}
+	active := ActiveExtClientGateway(client)
+	now := time.Now()
+	for _, id := range client.GatewayIDs() {
+		gateway, err := GetNodeByID(id)
+		if err != nil || !gateway.IsIngressGateway {
+			continue
+		}
+		host, err := GetHost(gateway.HostID.String())
+		if err != nil {
+			return result, err
+		}
+		result.Gateways = append(result.Gateways, models.ExtClientGateway{
+			GatewayID: id,
+			PublicKey: host.PublicKey.String(),
+			Endpoint:  ExtClientGatewayEndpoint(host),
+			Primary:   id == client.IngressGatewayID,
+			Online:    IsNodeOnline(&gateway, now),
+			Active:    id == active,
+		})
+	}
+	return result, nil
+}
+
+// ExtClientGatewayEndpoint - the endpoint ext clients dial to reach a gateway host
+func ExtClientGatewayEndpoint(host *models.Host) string {
+	if host.EndpointIP.To4() == nil {
+		return fmt.Sprintf("[%s]:%d", host.EndpointIP.String(), host.ListenPort)
+	}
+	return fmt.Sprintf("%s:%d", host.EndpointIP.String(), host.ListenPort)
+}
+
+// ExtClientsRoamed - whether a client on several gateways connected to or left a gateway between two of its metrics reports,
+// the network then routes the client through another gateway
+func ExtClientsRoamed(gateway *models.Node, oldMetrics, newMetrics *models.Metrics) bool {
+	if !gateway.IsIngressGateway || oldMetrics == nil || newMetrics == nil {
+		return false
+	}
+	clients, err := GetGatewayExtClients(gateway.ID.String(), gateway.Network)
+	if err != nil {
+		return false
+	}
+	for _, client := range clients {
+		if len(client.SecondaryGatewayIDs) == 0 {
+			continue
+		}
+		if oldMetrics.Connectivity[client.ClientID].Connected != newMetrics.Connectivity[client.ClientID].Connected {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveSecondaryGateway - drops a gateway that is going away from the secondaries of the ext clients of its network
+func RemoveSecondaryGateway(gatewayID, network string) {
+	clients, err := GetNetworkExtClients(network)
+	if err != nil {
+		return
+	}
+	for i := range clients {
+		client := &clients[i]
+		if !StringSliceContains(client.SecondaryGatewayIDs, gatewayID) {
+			continue
+		}
+		secondaries := []string{}
+		for _, id := range client.SecondaryGatewayIDs {
+			if id != gatewayID {
+				secondaries = append(secondaries, id)
+			}
+		}
+		client.SecondaryGatewayIDs = secondaries
+		if err := SaveExtClient(client); err != nil {
+			slog.Error("failed to remove secondary gateway of ext client", "client", client.ClientID, "gateway", gatewayID, "error", err)
+		}
+	}
+}
+
+// == private ==
+
+// extClientUsesGateway - whether an ext client is provisioned on a gateway, as its primary or a secondary
+func extClientUsesGateway(client *models.ExtClient, gatewayID string) bool {
+	return client.IngressGatewayID == gatewayID || StringSliceContains(client.SecondaryGatewayIDs, gatewayID)
+}
@@ -0,0 +1,26 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetExtClientSecondaryGateways(t *testing.T) {
+	primary := uuid.New().String()
+	client := models.ExtClient{ClientID: "hatest", Network: "skynet", IngressGatewayID: primary}
+	t.Run("Primary", func(t *testing.T) {
+		assert.ErrorIs(t, SetExtClientSecondaryGateways(&client, []string{primary}), ErrSecondaryGateway)
+	})
+	t.Run("Unknown", func(t *testing.T) {
+		assert.ErrorIs(t, SetExtClientSecondaryGateways(&client, []string{uuid.New().String()}), ErrSecondaryGateway)
+	})
+	t.Run("Clear", func(t *testing.T) {
+		assert.Nil(t, SetExtClientSecondaryGateways(&client, []string{}))
+		assert.Empty(t, client.SecondaryGatewayIDs)
+		assert.Equal(t, []string{primary}, client.GatewayIDs())
+		assert.Equal(t, primary, ActiveExtClientGateway(&client))
+	})
+}
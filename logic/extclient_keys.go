@@ -0,0 +1,109 @@
+package logic
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+var (
+	// ErrNotShortLivedKey - only the keys of ext clients that expire are renewed
+	ErrNotShortLivedKey = errors.New("ext client key does not expire")
+	// ErrSameExtClientKey - a renewal has to rotate the key
+	ErrSameExtClientKey = errors.New("renewed key must differ from the current one")
+)
+
+// SetExtClientKeyExpiry - starts the validity of the key of a user owned ext client when short lived keys are enabled
+func SetExtClientKeyExpiry(client *models.ExtClient) {
+	ttl := servercfg.GetExtClientKeyTTL()
+	if ttl == 0 || client.OwnerID == "" {
+		return
+	}
+	client.KeyExpiry = time.Now().Add(ttl).Unix()
+}
+
+// IsExtClientKeyExpired - whether the key of an ext client ran out, its gateways then drop it as a peer
+func IsExtClientKeyExpired(client *models.ExtClient) bool {
+	return client.KeyExpiry != 0 && client.KeyExpiry <= time.Now().Unix()
+}
+
+// ExtClientKeyRenewAfter - when a client should renew its key, a third of the key's validity before it expires
+func ExtClientKeyRenewAfter(client *models.ExtClient) time.Time {
+	return time.Unix(client.KeyExpiry, 0).Add(-servercfg.GetExtClientKeyTTL() / 3)
+}
+
+// RenewExtClientKey - rotates the key of a short lived ext client to the given public key, or a generated pair,
+// and starts its validity again
+func RenewExtClientKey(client *models.ExtClient, publicKey string) error {
+	if client.KeyExpiry == 0 || servercfg.GetExtClientKeyTTL() == 0 {
+		return ErrNotShortLivedKey
+	}
+	if publicKey == client.PublicKey {
+		return ErrSameExtClientKey
+	}
+	RevokeExtClientKey(client, "rotated")
+	if publicKey == "" {
+		privateKey, err := wgtypes.GeneratePrivateKey()
+		if err != nil {
+			return err
+		}
+		client.PrivateKey = privateKey.String()
+		client.PublicKey = privateKey.PublicKey().String()
+	} else {
+		client.PrivateKey = "[ENTER PRIVATE KEY]"
+		client.PublicKey = publicKey
+	}
+	SetExtClientKeyExpiry(client)
+	client.LastModified = time.Now().Unix()
+	return SaveExtClient(client)
+}
+
+// ExpiredExtClientKeys - the networks with ext client keys that ran out since a time, their gateways must drop them
+func ExpiredExtClientKeys(since time.Time) []string {
+	networks := []string{}
+	clients, err := GetAllExtClients()
+	if err != nil {
+		return networks
+	}
+	now := time.Now().Unix()
+	for _, client := range clients {
+		if client.KeyExpiry == 0 || client.KeyExpiry <= since.Unix() || client.KeyExpiry > now {
+			continue
+		}
+		slog.Info("ext client key expired", "clientid", client.ClientID, "network", client.Network, "user", client.OwnerID)
+		if !StringSliceContains(networks, client.Network) {
+			networks = append(networks, client.Network)
+		}
+	}
+	return networks
+}
+
+// ExpireUserExtClientKeys - ends the keys of a revoked user's short lived ext clients right away,
+// returns the networks whose gateways must drop them
+func ExpireUserExtClientKeys(username string) []string {
+	networks := []string{}
+	clients, err := GetAllExtClients()
+	if err != nil {
+		return networks
+	}
+	now := time.Now().Unix()
+	for i := range clients {
+		client := &clients[i]
+		if client.OwnerID != username || client.KeyExpiry == 0 || client.KeyExpiry <= now {
+			continue
+		}
+		client.KeyExpiry = now
+		if err := SaveExtClient(client); err != nil {
+			slog.Error("failed to expire ext client key of revoked user", "clientid", client.ClientID, "user", username, "error", err)
+			continue
+		}
+		if !StringSliceContains(networks, client.Network) {
+			networks = append(networks, client.Network)
+		}
+	}
+	return networks
+}
@@ -0,0 +1,22 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtClientKeyExpiry(t *testing.T) {
+	client := models.ExtClient{ClientID: "shortlived", OwnerID: "user"}
+	assert.False(t, IsExtClientKeyExpired(&client))
+	client.KeyExpiry = time.Now().Add(time.Hour).Unix()
+	assert.False(t, IsExtClientKeyExpired(&client))
+	client.KeyExpiry = time.Now().Add(-time.Minute).Unix()
+	assert.True(t, IsExtClientKeyExpired(&client))
+	t.Run("RenewNotShortLived", func(t *testing.T) {
+		client := models.ExtClient{ClientID: "longlived"}
+		assert.ErrorIs(t, RenewExtClientKey(&client, ""), ErrNotShortLivedKey)
+	})
+}
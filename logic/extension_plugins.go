@@ -0,0 +1,55 @@
+//go:build cgo && (linux || darwin)
+// +build cgo
+// +build linux darwin
+
+package logic
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"plugin"
+	"sort"
+)
+
+// LoadExtensionPlugins - registers the extension each Go plugin (.so) of a directory exports as Extension;
+// plugins have to be built with the same Go version and module versions as the server
+func LoadExtensionPlugins(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.so"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		p, err := plugin.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open extension plugin %s: %w", path, err)
+		}
+		symbol, err := p.Lookup("Extension")
+		if err != nil {
+			return fmt.Errorf("extension plugin %s doesn't export Extension: %w", path, err)
+		}
+		// an exported variable is looked up as a pointer to it
+		var e Extension
+		switch exported := symbol.(type) {
+		case *Extension:
+			e = *exported
+		case Extension:
+			e = exported
+		default:
+			return fmt.Errorf("the Extension of plugin %s is a %T, not an extension", path, symbol)
+		}
+		if err := RegisterExtension(e); err != nil {
+			return fmt.Errorf("failed to register extension plugin %s: %w", path, err)
+		}
+	}
+	if len(paths) == 0 {
+		if _, err := os.Stat(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
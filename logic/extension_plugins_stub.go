@@ -0,0 +1,14 @@
+//go:build !cgo || !(linux || darwin)
+// +build !cgo !linux,!darwin
+
+package logic
+
+import "errors"
+
+// LoadExtensionPlugins - Go plugins need cgo on linux or darwin, extensions have to be built into the server instead
+func LoadExtensionPlugins(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	return errors.New("extension plugins are not supported by this build, register extensions in the server instead")
+}
@@ -0,0 +1,162 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+// ErrRefusedByExtension - an extension refused a node joining or a user being created
+var ErrRefusedByExtension = errors.New("refused by extension")
+
+// Extension - business logic added to the server without forking it, registered with RegisterExtension from
+// an init of a file built with the server, or exported as Extension by a Go plugin in the extensions dir;
+// an extension implements any of the hook interfaces below
+type Extension interface {
+	// Name - names the extension in logs, errors and the path of its routes, unique among extensions
+	Name() string
+}
+
+// PreNodeJoinHook - runs before a node of a host is created on a network, an error refuses the join
+type PreNodeJoinHook interface {
+	PreNodeJoin(host *models.Host, node *models.Node) error
+}
+
+// PostPeerUpdateHook - runs after the peers of a host are calculated, before they are sent; may change the update
+type PostPeerUpdateHook interface {
+	PostPeerUpdate(host *models.Host, update *models.HostPeerUpdate)
+}
+
+// PreUserCreateHook - runs before a user is created, after it's validated; an error refuses the user
+type PreUserCreateHook interface {
+	PreUserCreate(user *models.User) error
+}
+
+// RouteExtension - adds API routes, on a router for /api/extensions/{name}; the routes take care of their own auth,
+// eg. with SecurityCheck
+type RouteExtension interface {
+	Routes(r *mux.Router)
+}
+
+var (
+	extensions     []Extension
+	extensionsLock sync.RWMutex
+)
+
+// RegisterExtension - adds an extension, its hooks run in the order extensions were registered
+func RegisterExtension(e Extension) error {
+	extensionsLock.Lock()
+	defer extensionsLock.Unlock()
+	if e == nil || e.Name() == "" {
+		return errors.New("extension has no name")
+	}
+	for _, registered := range extensions {
+		if registered.Name() == e.Name() {
+			return fmt.Errorf("extension %s is registered already", e.Name())
+		}
+	}
+	extensions = append(extensions, e)
+	slog.Info("registered server extension", "name", e.Name(), "hooks", extensionHooks(e))
+	return nil
+}
+
+// GetExtensions - the registered extensions and the hooks each implements
+func GetExtensions() []models.ExtensionInfo {
+	info := []models.ExtensionInfo{}
+	for _, e := range getExtensions() {
+		info = append(info, models.ExtensionInfo{Name: e.Name(), Hooks: extensionHooks(e)})
+	}
+	return info
+}
+
+// RouteExtensions - the registered extensions that add API routes
+func RouteExtensions() map[string]RouteExtension {
+	routes := map[string]RouteExtension{}
+	for _, e := range getExtensions() {
+		if route, ok := e.(RouteExtension); ok {
+			routes[e.Name()] = route
+		}
+	}
+	return routes
+}
+
+// RunPreNodeJoinHooks - runs the pre node join hooks, stopping at the first refusing the node
+func RunPreNodeJoinHooks(host *models.Host, node *models.Node) error {
+	for _, e := range getExtensions() {
+		hook, ok := e.(PreNodeJoinHook)
+		if !ok {
+			continue
+		}
+		if err := runExtensionHook(e, func() error { return hook.PreNodeJoin(host, node) }); err != nil {
+			return fmt.Errorf("%w %s: %s", ErrRefusedByExtension, e.Name(), err.Error())
+		}
+	}
+	return nil
+}
+
+// RunPostPeerUpdateHooks - runs the post peer update hooks on the peer update of a host
+func RunPostPeerUpdateHooks(host *models.Host, update *models.HostPeerUpdate) {
+	for _, e := range getExtensions() {
+		hook, ok := e.(PostPeerUpdateHook)
+		if !ok {
+			continue
+		}
+		if err := runExtensionHook(e, func() error { hook.PostPeerUpdate(host, update); return nil }); err != nil {
+			slog.Error("post peer update hook failed", "extension", e.Name(), "host", host.ID, "error", err)
+		}
+	}
+}
+
+// RunPreUserCreateHooks - runs the pre user create hooks, stopping at the first refusing the user
+func RunPreUserCreateHooks(user *models.User) error {
+	for _, e := range getExtensions() {
+		hook, ok := e.(PreUserCreateHook)
+		if !ok {
+			continue
+		}
+		if err := runExtensionHook(e, func() error { return hook.PreUserCreate(user) }); err != nil {
+			return fmt.Errorf("%w %s: %s", ErrRefusedByExtension, e.Name(), err.Error())
+		}
+	}
+	return nil
+}
+
+// == private ==
+
+func getExtensions() []Extension {
+	extensionsLock.RLock()
+	defer extensionsLock.RUnlock()
+	return append([]Extension{}, extensions...)
+}
+
+// runExtensionHook - runs a hook, turning a panic into an error so an extension can't take the server down
+func runExtensionHook(e Extension, hook func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("extension hook panicked", "extension", e.Name(), "panic", r)
+			err = fmt.Errorf("extension %s panicked: %v", e.Name(), r)
+		}
+	}()
+	return hook()
+}
+
+func extensionHooks(e Extension) []string {
+	hooks := []string{}
+	if _, ok := e.(PreNodeJoinHook); ok {
+		hooks = append(hooks, models.ExtensionHookPreNodeJoin)
+	}
+	if _, ok := e.(PostPeerUpdateHook); ok {
+		hooks = append(hooks, models.ExtensionHookPostPeerUpdate)
+	}
+	if _, ok := e.(PreUserCreateHook); ok {
+		hooks = append(hooks, models.ExtensionHookPreUserCreate)
+	}
+	if _, ok := e.(RouteExtension); ok {
+		hooks = append(hooks, models.ExtensionHookRoutes)
+	}
+	return hooks
+}
@@ -0,0 +1,80 @@
+package logic
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type testExtension struct {
+	name   string
+	refuse bool
+	panics bool
+}
+
+func (e *testExtension) Name() string {
+	return e.name
+}
+
+func (e *testExtension) PreNodeJoin(host *models.Host, node *models.Node) error {
+	if e.panics {
+		panic("bad extension")
+	}
+	if e.refuse {
+		return errors.New("network is full")
+	}
+	node.Tags = append(node.Tags, e.name)
+	return nil
+}
+
+type peerExtension struct{}
+
+func (peerExtension) Name() string {
+	return "peers"
+}
+
+func (peerExtension) PostPeerUpdate(host *models.Host, update *models.HostPeerUpdate) {
+	update.ServerVersion = "extended"
+}
+
+func TestExtensions(t *testing.T) {
+	defer func() {
+		extensions = nil
+	}()
+	host := &models.Host{Name: "host"}
+	t.Run("Register", func(t *testing.T) {
+		extensions = nil
+		assert.Nil(t, RegisterExtension(&testExtension{name: "tagger"}))
+		assert.Nil(t, RegisterExtension(peerExtension{}))
+		assert.NotNil(t, RegisterExtension(&testExtension{name: "tagger"}))
+		assert.NotNil(t, RegisterExtension(&testExtension{}))
+		assert.Equal(t, []models.ExtensionInfo{
+			{Name: "tagger", Hooks: []string{models.ExtensionHookPreNodeJoin}},
+			{Name: "peers", Hooks: []string{models.ExtensionHookPostPeerUpdate}},
+		}, GetExtensions())
+		assert.Empty(t, RouteExtensions())
+	})
+	t.Run("Hooks", func(t *testing.T) {
+		node := &models.Node{}
+		assert.Nil(t, RunPreNodeJoinHooks(host, node))
+		assert.Equal(t, []string{"tagger"}, node.Tags)
+		update := models.HostPeerUpdate{}
+		RunPostPeerUpdateHooks(host, &update)
+		assert.Equal(t, "extended", update.ServerVersion)
+		assert.Nil(t, RunPreUserCreateHooks(&models.User{UserName: "user"}))
+	})
+	t.Run("Refused", func(t *testing.T) {
+		assert.Nil(t, RegisterExtension(&testExtension{name: "quota", refuse: true}))
+		err := RunPreNodeJoinHooks(host, &models.Node{})
+		assert.True(t, errors.Is(err, ErrRefusedByExtension))
+		assert.Contains(t, err.Error(), "quota")
+	})
+	t.Run("Panic", func(t *testing.T) {
+		extensions = nil
+		assert.Nil(t, RegisterExtension(&testExtension{name: "broken", panics: true}))
+		err := RunPreNodeJoinHooks(host, &models.Node{})
+		assert.True(t, errors.Is(err, ErrRefusedByExtension))
+	})
+}
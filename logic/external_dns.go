@@ -0,0 +1,300 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic/externaldns"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// externalDNSSyncInterval - how often published records are checked even without host changes
+	externalDNSSyncInterval = 5 * time.Minute
+	// externalDNSTimeout - how long publishing the records may take
+	externalDNSTimeout = 2 * time.Minute
+	// defaultExternalDNSTTL - ttl of published records when none is set
+	defaultExternalDNSTTL = 300
+)
+
+var (
+	// ErrDNSProviderInUse - a dns provider can't be removed while records are published to it
+	ErrDNSProviderInUse = errors.New("dns provider still has records")
+	// ErrRecordOutsideZone - a record name must be within the provider's zone
+	ErrRecordOutsideZone = errors.New("record name is not within the provider's zone")
+
+	externalDNSMutex sync.Mutex
+	// externalDNSChanged - signals the sync worker that a host changed
+	externalDNSChanged = make(chan struct{}, 1)
+	// newDNSProvider - creates the client for a provider, replaced in tests
+	newDNSProvider = externaldns.New
+)
+
+func init() {
+	database.OnChange(func(change database.Change) {
+		if change.Table != database.HOSTS_TABLE_NAME {
+			return
+		}
+		select {
+		case externalDNSChanged <- struct{}{}:
+		default:
+		}
+	})
+}
+
+// CreateExternalDNSProvider - adds an external zone records can be published to
+func CreateExternalDNSProvider(provider *models.ExternalDNSProvider) error {
+	if err := validator.New().Struct(provider); err != nil {
+		return err
+	}
+	provider.ID = uuid.New().String()
+	return upsertExternalDNSProvider(provider)
+}
+
+// GetExternalDNSProviders - fetches all external dns providers
+func GetExternalDNSProviders() ([]models.ExternalDNSProvider, error) {
+	providers := []models.ExternalDNSProvider{}
+	records, err := database.FetchRecords(database.EXTERNAL_DNS_PROVIDERS_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return providers, nil
+		}
+		return nil, err
+	}
+	for _, value := range records {
+		var provider models.ExternalDNSProvider
+		if err := json.Unmarshal([]byte(value), &provider); err != nil {
+			continue
+		}
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
+
+// GetExternalDNSProvider - fetches an external dns provider
+func GetExternalDNSProvider(id string) (models.ExternalDNSProvider, error) {
+	var provider models.ExternalDNSProvider
+	record, err := database.FetchRecord(database.EXTERNAL_DNS_PROVIDERS_TABLE_NAME, id)
+	if err != nil {
+		return provider, err
+	}
+	err = json.Unmarshal([]byte(record), &provider)
+	return provider, err
+}
+
+// DeleteExternalDNSProvider - removes an external dns provider that has no records
+func DeleteExternalDNSProvider(id string) error {
+	if _, err := GetExternalDNSProvider(id); err != nil {
+		return err
+	}
+	records, err := GetExternalDNSRecords()
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if record.ProviderID == id {
+			return ErrDNSProviderInUse
+		}
+	}
+	return database.DeleteRecord(database.EXTERNAL_DNS_PROVIDERS_TABLE_NAME, id)
+}
+
+// CreateExternalDNSRecord - publishes the public endpoint of a node's host under a name
+func CreateExternalDNSRecord(record *models.ExternalDNSRecord) error {
+	if err := validator.New().Struct(record); err != nil {
+		return err
+	}
+	provider, err := GetExternalDNSProvider(record.ProviderID)
+	if err != nil {
+		return err
+	}
+	name, zone := strings.TrimSuffix(record.Name, "."), strings.TrimSuffix(provider.Zone, ".")
+	if name != zone && !strings.HasSuffix(name, "."+zone) {
+		return ErrRecordOutsideZone
+	}
+	if _, err := GetNodeByID(record.NodeID); err != nil {
+		return err
+	}
+	record.Name = name
+	if record.TTL == 0 {
+		record.TTL = defaultExternalDNSTTL
+	}
+	record.Type, record.Address, record.Error = "", "", ""
+	if err := upsertExternalDNSRecord(record); err != nil {
+		return err
+	}
+	return SyncExternalDNS()
+}
+
+// GetExternalDNSRecords - fetches all records published to external dns providers
+func GetExternalDNSRecords() ([]models.ExternalDNSRecord, error) {
+	records := []models.ExternalDNSRecord{}
+	values, err := database.FetchRecords(database.EXTERNAL_DNS_RECORDS_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return records, nil
+		}
+		return nil, err
+	}
+	for _, value := range values {
+		var record models.ExternalDNSRecord
+		if err := json.Unmarshal([]byte(value), &record); err != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// DeleteExternalDNSRecord - removes a record from its provider and stops publishing it
+func DeleteExternalDNSRecord(name string) error {
+	externalDNSMutex.Lock()
+	defer externalDNSMutex.Unlock()
+	value, err := database.FetchRecord(database.EXTERNAL_DNS_RECORDS_TABLE_NAME, name)
+	if err != nil {
+		return err
+	}
+	var record models.ExternalDNSRecord
+	if err := json.Unmarshal([]byte(value), &record); err != nil {
+		return err
+	}
+	if record.Type != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), externalDNSTimeout)
+		defer cancel()
+		provider, err := getDNSProvider(ctx, record.ProviderID)
+		if err != nil {
+			return err
+		}
+		if err := provider.DeleteRecord(ctx, record.Name, record.Type); err != nil {
+			return err
+		}
+	}
+	return database.DeleteRecord(database.EXTERNAL_DNS_RECORDS_TABLE_NAME, name)
+}
+
+// SyncExternalDNS - publishes the current endpoint of each record's host where it changed or failed before
+func SyncExternalDNS() error {
+	externalDNSMutex.Lock()
+	defer externalDNSMutex.Unlock()
+	records, err := GetExternalDNSRecords()
+	if err != nil || len(records) == 0 {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), externalDNSTimeout)
+	defer cancel()
+	providers := make(map[string]externaldns.Provider)
+	for i := range records {
+		record := &records[i]
+		recordType, address, err := externalDNSAddress(record.NodeID)
+		if err != nil {
+			// the node is gone, leave the record until an admin removes it
+			slog.Warn("no endpoint to publish", "record", record.Name, "node", record.NodeID, "error", err)
+			continue
+		}
+		if recordType == record.Type && address == record.Address && record.Error == "" {
+			continue
+		}
+		provider, ok := providers[record.ProviderID]
+		if !ok {
+			if provider, err = getDNSProvider(ctx, record.ProviderID); err != nil {
+				slog.Error("failed to load dns provider", "provider", record.ProviderID, "error", err)
+			}
+			providers[record.ProviderID] = provider
+		}
+		if provider == nil {
+			continue
+		}
+		err = provider.SetRecord(ctx, record.Name, recordType, address, record.TTL)
+		// an endpoint that moved between ipv4 and ipv6 leaves the old record behind
+		if err == nil && record.Type != "" && record.Type != recordType {
+			err = provider.DeleteRecord(ctx, record.Name, record.Type)
+		}
+		record.Error = ""
+		if err != nil {
+			slog.Error("failed to publish dns record", "record", record.Name, "error", err)
+			record.Error = err.Error()
+		} else {
+			record.Type, record.Address = recordType, address
+		}
+		record.UpdatedAt = time.Now()
+		if err := upsertExternalDNSRecord(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartExternalDNS - keeps published records up to date as host endpoints change until ctx is done
+func StartExternalDNS(ctx context.Context, wg *sync.WaitGroup) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			WorkerHeartbeat("externaldns", externalDNSSyncInterval)
+			select {
+			case <-ctx.Done():
+				StopWorker("externaldns")
+				return
+			case <-externalDNSChanged:
+			case <-time.After(externalDNSSyncInterval):
+			}
+			if err := SyncExternalDNS(); err != nil {
+				slog.Error("failed to sync external dns records", "error", err)
+			}
+		}
+	}()
+}
+
+// == private ==
+
+// externalDNSAddress - the record type and public endpoint of a node's host
+func externalDNSAddress(nodeID string) (string, string, error) {
+	node, err := GetNodeByID(nodeID)
+	if err != nil {
+		return "", "", err
+	}
+	host, err := GetHost(node.HostID.String())
+	if err != nil {
+		return "", "", err
+	}
+	if host.EndpointIP == nil {
+		return "", "", fmt.Errorf("host %s has no endpoint", host.Name)
+	}
+	if host.EndpointIP.To4() != nil {
+		return "A", host.EndpointIP.String(), nil
+	}
+	return "AAAA", host.EndpointIP.String(), nil
+}
+
+func getDNSProvider(ctx context.Context, id string) (externaldns.Provider, error) {
+	cfg, err := GetExternalDNSProvider(id)
+	if err != nil {
+		return nil, err
+	}
+	return newDNSProvider(ctx, &cfg)
+}
+
+func upsertExternalDNSProvider(provider *models.ExternalDNSProvider) error {
+	data, err := json.Marshal(provider)
+	if err != nil {
+		return err
+	}
+	return database.Insert(provider.ID, string(data), database.EXTERNAL_DNS_PROVIDERS_TABLE_NAME)
+}
+
+func upsertExternalDNSRecord(record *models.ExternalDNSRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return database.Insert(record.Name, string(data), database.EXTERNAL_DNS_RECORDS_TABLE_NAME)
+}
@@ -0,0 +1,101 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic/externaldns"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDNS - records the records a sync publishes instead of calling a dns provider
+type fakeDNS struct {
+	records map[string]string
+	fail    error
+}
+
+func (f *fakeDNS) SetRecord(ctx context.Context, name, recordType, address string, ttl int) error {
+	if f.fail != nil {
+		return f.fail
+	}
+	f.records[name+" "+recordType] = address
+	return nil
+}
+
+func (f *fakeDNS) DeleteRecord(ctx context.Context, name, recordType string) error {
+	if f.fail != nil {
+		return f.fail
+	}
+	delete(f.records, name+" "+recordType)
+	return nil
+}
+
+func TestSyncExternalDNS(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	dns := &fakeDNS{records: make(map[string]string)}
+	defer func(create func(context.Context, *models.ExternalDNSProvider) (externaldns.Provider, error)) {
+		newDNSProvider = create
+	}(newDNSProvider)
+	newDNSProvider = func(context.Context, *models.ExternalDNSProvider) (externaldns.Provider, error) {
+		return dns, nil
+	}
+	host := models.Host{ID: uuid.New(), Name: "dnshost", EndpointIP: net.ParseIP("203.0.113.5")}
+	assert.Nil(t, UpsertHost(&host))
+	defer database.DeleteRecord(database.HOSTS_TABLE_NAME, host.ID.String())
+	node := models.Node{CommonNode: models.CommonNode{ID: uuid.New(), HostID: host.ID, Network: "dnsnet"}}
+	assert.Nil(t, UpsertNode(&node))
+	defer database.DeleteRecord(database.NODES_TABLE_NAME, node.ID.String())
+	provider := models.ExternalDNSProvider{Type: models.ExternalDNSCloudflare, Zone: "example.com",
+		Credentials: &models.ExternalDNSCredentials{APIToken: "token"}}
+	assert.Nil(t, CreateExternalDNSProvider(&provider))
+	defer database.DeleteRecord(database.EXTERNAL_DNS_PROVIDERS_TABLE_NAME, provider.ID)
+
+	t.Run("OutsideZone", func(t *testing.T) {
+		err := CreateExternalDNSRecord(&models.ExternalDNSRecord{Name: "gw.example.org", ProviderID: provider.ID, NodeID: node.ID.String()})
+		assert.ErrorIs(t, err, ErrRecordOutsideZone)
+	})
+	t.Run("Published", func(t *testing.T) {
+		record := models.ExternalDNSRecord{Name: "gw.example.com.", ProviderID: provider.ID, NodeID: node.ID.String()}
+		assert.Nil(t, CreateExternalDNSRecord(&record))
+		assert.Equal(t, map[string]string{"gw.example.com A": "203.0.113.5"}, dns.records)
+		records, err := GetExternalDNSRecords()
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(records))
+		assert.Equal(t, defaultExternalDNSTTL, records[0].TTL)
+		assert.Equal(t, "A", records[0].Type)
+		assert.ErrorIs(t, DeleteExternalDNSProvider(provider.ID), ErrDNSProviderInUse)
+	})
+	t.Run("Failed", func(t *testing.T) {
+		dns.fail = errors.New("rate limited")
+		host.EndpointIP = net.ParseIP("203.0.113.6")
+		assert.Nil(t, UpsertHost(&host))
+		assert.Nil(t, SyncExternalDNS())
+		records, _ := GetExternalDNSRecords()
+		assert.Equal(t, "rate limited", records[0].Error)
+		// still the last address that was published
+		assert.Equal(t, "203.0.113.5", records[0].Address)
+	})
+	t.Run("MovedToIPv6", func(t *testing.T) {
+		dns.fail = nil
+		host.EndpointIP = net.ParseIP("2001:db8::5")
+		assert.Nil(t, UpsertHost(&host))
+		assert.Nil(t, SyncExternalDNS())
+		// the A record is replaced, not left behind
+		assert.Equal(t, map[string]string{"gw.example.com AAAA": "2001:db8::5"}, dns.records)
+		records, _ := GetExternalDNSRecords()
+		assert.Empty(t, records[0].Error)
+		assert.Equal(t, "AAAA", records[0].Type)
+	})
+	t.Run("Deleted", func(t *testing.T) {
+		assert.Nil(t, DeleteExternalDNSRecord("gw.example.com"))
+		assert.Empty(t, dns.records)
+		records, _ := GetExternalDNSRecords()
+		assert.Empty(t, records)
+	})
+}
@@ -0,0 +1,100 @@
+package externaldns
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gravitl/netmaker/models"
+)
+
+const cloudflareAPI = "https://api.cloudflare.com/client/v4"
+
+type cloudflare struct {
+	zone   string
+	zoneID string
+	header http.Header
+	client *http.Client
+}
+
+type cloudflareRecord struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl"`
+}
+
+func newCloudflare(cfg *models.ExternalDNSProvider) (Provider, error) {
+	if cfg.Credentials.APIToken == "" {
+		return nil, ErrMissingCredentials
+	}
+	return &cloudflare{
+		zone:   strings.TrimSuffix(cfg.Zone, "."),
+		zoneID: cfg.ZoneID,
+		header: http.Header{"Authorization": {"Bearer " + cfg.Credentials.APIToken}},
+		client: &http.Client{Timeout: requestTimeout},
+	}, nil
+}
+
+// SetRecord - updates the record of name if there is one, otherwise creates it
+func (c *cloudflare) SetRecord(ctx context.Context, name, recordType, address string, ttl int) error {
+	records, err := c.findRecords(ctx, name, recordType)
+	if err != nil {
+		return err
+	}
+	record := cloudflareRecord{Type: recordType, Name: strings.TrimSuffix(name, "."), Content: address, TTL: ttl}
+	if len(records) == 0 {
+		return doJSON(ctx, c.client, http.MethodPost, cloudflareAPI+"/zones/"+c.zoneID+"/dns_records", c.header, record, nil)
+	}
+	return doJSON(ctx, c.client, http.MethodPut, cloudflareAPI+"/zones/"+c.zoneID+"/dns_records/"+records[0].ID, c.header, record, nil)
+}
+
+// DeleteRecord - deletes the records of type for name
+func (c *cloudflare) DeleteRecord(ctx context.Context, name, recordType string) error {
+	records, err := c.findRecords(ctx, name, recordType)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		err := doJSON(ctx, c.client, http.MethodDelete, cloudflareAPI+"/zones/"+c.zoneID+"/dns_records/"+record.ID, c.header, nil, nil)
+		if err != nil && !isNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *cloudflare) findRecords(ctx context.Context, name, recordType string) ([]cloudflareRecord, error) {
+	if err := c.lookupZone(ctx); err != nil {
+		return nil, err
+	}
+	query := url.Values{"name": {strings.TrimSuffix(name, ".")}, "type": {recordType}}
+	var res struct {
+		Result []cloudflareRecord `json:"result"`
+	}
+	err := doJSON(ctx, c.client, http.MethodGet, cloudflareAPI+"/zones/"+c.zoneID+"/dns_records?"+query.Encode(), c.header, nil, &res)
+	return res.Result, err
+}
+
+// lookupZone - finds the id of the zone by its domain when it isn't configured
+func (c *cloudflare) lookupZone(ctx context.Context) error {
+	if c.zoneID != "" {
+		return nil
+	}
+	var res struct {
+		Result []struct {
+			ID string `json:"id"`
+		} `json:"result"`
+	}
+	if err := doJSON(ctx, c.client, http.MethodGet, cloudflareAPI+"/zones?name="+url.QueryEscape(c.zone), c.header, nil, &res); err != nil {
+		return err
+	}
+	if len(res.Result) == 0 {
+		return fmt.Errorf("cloudflare zone %s not found", c.zone)
+	}
+	c.zoneID = res.Result[0].ID
+	return nil
+}
@@ -0,0 +1,106 @@
+// Package externaldns publishes records to DNS zones hosted by external providers
+package externaldns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+)
+
+// requestTimeout - how long a single call to a provider's API may take
+const requestTimeout = 30 * time.Second
+
+// ErrMissingCredentials - the credentials for the provider aren't set
+var ErrMissingCredentials = errors.New("missing dns provider credentials")
+
+// Provider - sets and removes records in an external DNS zone
+type Provider interface {
+	// SetRecord - points name to address with a record of type (A or AAAA), replacing the current one
+	SetRecord(ctx context.Context, name, recordType, address string, ttl int) error
+	// DeleteRecord - removes the record of type for name, if there is one
+	DeleteRecord(ctx context.Context, name, recordType string) error
+}
+
+// New - creates a Provider for the zone and credentials of cfg
+func New(ctx context.Context, cfg *models.ExternalDNSProvider) (Provider, error) {
+	if cfg.Credentials == nil {
+		return nil, ErrMissingCredentials
+	}
+	switch cfg.Type {
+	case models.ExternalDNSRoute53:
+		return newRoute53(cfg)
+	case models.ExternalDNSCloudflare:
+		return newCloudflare(cfg)
+	case models.ExternalDNSGoogle:
+		return newGoogleDNS(ctx, cfg)
+	default:
+		return nil, fmt.Errorf("unknown dns provider %q", cfg.Type)
+	}
+}
+
+// fqdn - the name with the trailing dot zone files use
+func fqdn(name string) string {
+	return strings.TrimSuffix(name, ".") + "."
+}
+
+// apiError - an unexpected response from a provider's API
+type apiError struct {
+	status int
+	body   string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("dns api returned %d: %s", e.status, e.body)
+}
+
+// isNotFound - checks if err is a provider's API answering that something doesn't exist
+func isNotFound(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.status == http.StatusNotFound
+}
+
+// doJSON - sends body as json to a provider's REST API and decodes the answer into out if set
+func doJSON(ctx context.Context, client *http.Client, method, url string, header http.Header, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode >= http.StatusMultipleChoices {
+		return &apiError{status: res.StatusCode, body: string(data)}
+	}
+	if out != nil && len(data) > 0 {
+		return json.Unmarshal(data, out)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package externaldns
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	_, err := New(context.Background(), &models.ExternalDNSProvider{Type: models.ExternalDNSCloudflare})
+	assert.ErrorIs(t, err, ErrMissingCredentials)
+	_, err = New(context.Background(), &models.ExternalDNSProvider{Type: models.ExternalDNSCloudflare, Credentials: &models.ExternalDNSCredentials{}})
+	assert.ErrorIs(t, err, ErrMissingCredentials)
+	_, err = New(context.Background(), &models.ExternalDNSProvider{Type: "namecheap", Credentials: &models.ExternalDNSCredentials{}})
+	assert.ErrorContains(t, err, "unknown dns provider")
+	provider, err := New(context.Background(), &models.ExternalDNSProvider{Type: models.ExternalDNSCloudflare, Zone: "example.com.",
+		Credentials: &models.ExternalDNSCredentials{APIToken: "token"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "example.com", provider.(*cloudflare).zone)
+}
+
+func TestFQDN(t *testing.T) {
+	assert.Equal(t, "gw.example.com.", fqdn("gw.example.com"))
+	assert.Equal(t, "gw.example.com.", fqdn("gw.example.com."))
+}
+
+func TestDoJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.Error(w, "no such record", http.StatusNotFound)
+			return
+		}
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		json.NewEncoder(w).Encode(map[string]string{
+			"auth":         r.Header.Get("Authorization"),
+			"content_type": r.Header.Get("Content-Type"),
+			"name":         body["name"],
+		})
+	}))
+	defer server.Close()
+	header := http.Header{"Authorization": {"Bearer token"}}
+	var out map[string]string
+	err := doJSON(context.Background(), server.Client(), http.MethodPost, server.URL+"/records", header, map[string]string{"name": "gw"}, &out)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"auth": "Bearer token", "content_type": "application/json", "name": "gw"}, out)
+	err = doJSON(context.Background(), server.Client(), http.MethodDelete, server.URL+"/missing", header, nil, nil)
+	assert.True(t, isNotFound(err))
+	assert.ErrorContains(t, err, "no such record")
+}
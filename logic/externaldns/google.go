@@ -0,0 +1,83 @@
+package externaldns
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/oauth2/google"
+)
+
+const cloudDNSAPI = "https://dns.googleapis.com/dns/v1/projects/"
+
+type googleDNS struct {
+	zoneURL string
+	client  *http.Client
+}
+
+type googleRecordSet struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	TTL     int      `json:"ttl"`
+	Rrdatas []string `json:"rrdatas"`
+}
+
+func newGoogleDNS(ctx context.Context, cfg *models.ExternalDNSProvider) (Provider, error) {
+	if cfg.Credentials.ServiceAccountKey == "" {
+		return nil, ErrMissingCredentials
+	}
+	if cfg.Project == "" || cfg.ZoneID == "" {
+		return nil, errors.New("missing GCP project or managed zone")
+	}
+	jwtConfig, err := google.JWTConfigFromJSON([]byte(cfg.Credentials.ServiceAccountKey), "https://www.googleapis.com/auth/ndev.clouddns.readwrite")
+	if err != nil {
+		return nil, err
+	}
+	client := jwtConfig.Client(ctx)
+	client.Timeout = requestTimeout
+	return &googleDNS{zoneURL: cloudDNSAPI + cfg.Project + "/managedZones/" + cfg.ZoneID, client: client}, nil
+}
+
+// SetRecord - replaces the record set of name in a single change
+func (g *googleDNS) SetRecord(ctx context.Context, name, recordType, address string, ttl int) error {
+	current, err := g.getRecordSet(ctx, name, recordType)
+	if err != nil {
+		return err
+	}
+	change := map[string][]googleRecordSet{
+		"additions": {{Name: fqdn(name), Type: recordType, TTL: ttl, Rrdatas: []string{address}}},
+	}
+	if current != nil {
+		change["deletions"] = []googleRecordSet{*current}
+	}
+	return doJSON(ctx, g.client, http.MethodPost, g.zoneURL+"/changes", nil, change, nil)
+}
+
+// DeleteRecord - removes the record set of name
+func (g *googleDNS) DeleteRecord(ctx context.Context, name, recordType string) error {
+	current, err := g.getRecordSet(ctx, name, recordType)
+	if err != nil || current == nil {
+		return err
+	}
+	change := map[string][]googleRecordSet{"deletions": {*current}}
+	return doJSON(ctx, g.client, http.MethodPost, g.zoneURL+"/changes", nil, change, nil)
+}
+
+func (g *googleDNS) getRecordSet(ctx context.Context, name, recordType string) (*googleRecordSet, error) {
+	query := url.Values{"name": {fqdn(name)}, "type": {recordType}}
+	var res struct {
+		Rrsets []googleRecordSet `json:"rrsets"`
+	}
+	if err := doJSON(ctx, g.client, http.MethodGet, g.zoneURL+"/rrsets?"+query.Encode(), nil, nil, &res); err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(res.Rrsets) == 0 {
+		return nil, nil
+	}
+	return &res.Rrsets[0], nil
+}
@@ -0,0 +1,128 @@
+package externaldns
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gravitl/netmaker/logic/awsauth"
+	"github.com/gravitl/netmaker/models"
+)
+
+// route53 is a global service signed for us-east-1
+const route53Endpoint = "https://route53.amazonaws.com/2013-04-01/hostedzone/"
+
+type route53 struct {
+	zoneID    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+type route53Change struct {
+	XMLName xml.Name `xml:"ChangeResourceRecordSetsRequest"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Action  string   `xml:"ChangeBatch>Changes>Change>Action"`
+	Name    string   `xml:"ChangeBatch>Changes>Change>ResourceRecordSet>Name"`
+	Type    string   `xml:"ChangeBatch>Changes>Change>ResourceRecordSet>Type"`
+	TTL     int      `xml:"ChangeBatch>Changes>Change>ResourceRecordSet>TTL"`
+	Value   string   `xml:"ChangeBatch>Changes>Change>ResourceRecordSet>ResourceRecords>ResourceRecord>Value"`
+}
+
+func newRoute53(cfg *models.ExternalDNSProvider) (Provider, error) {
+	if cfg.Credentials.AccessKeyID == "" || cfg.Credentials.SecretAccessKey == "" {
+		return nil, ErrMissingCredentials
+	}
+	if cfg.ZoneID == "" {
+		return nil, errors.New("missing Route53 hosted zone id")
+	}
+	return &route53{
+		zoneID:    strings.TrimPrefix(cfg.ZoneID, "/hostedzone/"),
+		accessKey: cfg.Credentials.AccessKeyID,
+		secretKey: cfg.Credentials.SecretAccessKey,
+		client:    &http.Client{Timeout: requestTimeout},
+	}, nil
+}
+
+// SetRecord - upserts the record set of name
+func (r *route53) SetRecord(ctx context.Context, name, recordType, address string, ttl int) error {
+	return r.change(ctx, route53Change{Action: "UPSERT", Name: fqdn(name), Type: recordType, TTL: ttl, Value: address})
+}
+
+// DeleteRecord - deletes the record set of name, which route53 only does given its current values
+func (r *route53) DeleteRecord(ctx context.Context, name, recordType string) error {
+	current, err := r.getRecord(ctx, name, recordType)
+	if err != nil || current == nil {
+		return err
+	}
+	current.Action = "DELETE"
+	return r.change(ctx, *current)
+}
+
+func (r *route53) getRecord(ctx context.Context, name, recordType string) (*route53Change, error) {
+	query := url.Values{"maxitems": {"1"}, "name": {fqdn(name)}, "type": {recordType}}
+	body, err := r.call(ctx, http.MethodGet, "/rrset?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	var res struct {
+		Sets []struct {
+			Name   string   `xml:"Name"`
+			Type   string   `xml:"Type"`
+			TTL    int      `xml:"TTL"`
+			Values []string `xml:"ResourceRecords>ResourceRecord>Value"`
+		} `xml:"ResourceRecordSets>ResourceRecordSet"`
+	}
+	if err := xml.Unmarshal(body, &res); err != nil {
+		return nil, err
+	}
+	if len(res.Sets) == 0 || res.Sets[0].Name != fqdn(name) || res.Sets[0].Type != recordType || len(res.Sets[0].Values) == 0 {
+		return nil, nil
+	}
+	set := res.Sets[0]
+	return &route53Change{Name: set.Name, Type: set.Type, TTL: set.TTL, Value: set.Values[0]}, nil
+}
+
+func (r *route53) change(ctx context.Context, change route53Change) error {
+	change.Xmlns = "https://route53.amazonaws.com/doc/2013-04-01/"
+	body, err := xml.Marshal(change)
+	if err != nil {
+		return err
+	}
+	_, err = r.call(ctx, http.MethodPost, "/rrset/", body)
+	return err
+}
+
+func (r *route53) call(ctx context.Context, method, path string, payload []byte) ([]byte, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, route53Endpoint+r.zoneID+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/xml")
+	}
+	awsauth.Sign(req, payload, "us-east-1", "route53", r.accessKey, r.secretKey, time.Now())
+	res, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, &apiError{status: res.StatusCode, body: string(data)}
+	}
+	return data, nil
+}
@@ -13,22 +13,57 @@ import (
 )
 
 var (
-	extClientCacheMutex = &sync.RWMutex{}
-	extClientCacheMap   = make(map[string]models.ExtClient)
+	extClientCacheMutex  = &sync.RWMutex{}
+	extClientCacheMap    = make(map[string]models.ExtClient)
+	extClientCacheLoaded bool
+	// extClientCacheVersion - counts the changes made to the cache, see loadExtClientsIntoCache
+	extClientCacheVersion int64
+	extClientCacheStats   cacheStats
 )
 
-func getAllExtClientsFromCache() (extClients []models.ExtClient) {
+// getAllExtClientsFromCache - returns all ext clients, if the cache holds all of them
+func getAllExtClientsFromCache() (extClients []models.ExtClient, ok bool) {
 	extClientCacheMutex.RLock()
-	for _, extclient := range extClientCacheMap {
-		extClients = append(extClients, extclient)
+	if extClientCacheLoaded {
+		extClients = make([]models.ExtClient, 0, len(extClientCacheMap))
+		for _, extclient := range extClientCacheMap {
+			extClients = append(extClients, extclient)
+		}
 	}
+	ok = extClientCacheLoaded
 	extClientCacheMutex.RUnlock()
+	extClientCacheStats.record(ok)
 	return
 }
 
+func getExtClientCacheVersion() int64 {
+	extClientCacheMutex.RLock()
+	defer extClientCacheMutex.RUnlock()
+	return extClientCacheVersion
+}
+
+// loadExtClientsIntoCache - fills the cache with the ext clients read at version, unless a write changed the cache since
+func loadExtClientsIntoCache(clients map[string]models.ExtClient, version int64) {
+	extClientCacheMutex.Lock()
+	if extClientCacheVersion == version {
+		extClientCacheMap = clients
+		extClientCacheLoaded = true
+	}
+	extClientCacheMutex.Unlock()
+}
+
+func clearExtClientCache() {
+	extClientCacheMutex.Lock()
+	extClientCacheMap = make(map[string]models.ExtClient)
+	extClientCacheLoaded = false
+	extClientCacheVersion++
+	extClientCacheMutex.Unlock()
+}
+
 func deleteExtClientFromCache(key string) {
 	extClientCacheMutex.Lock()
 	delete(extClientCacheMap, key)
+	extClientCacheVersion++
 	extClientCacheMutex.Unlock()
 }
 
@@ -36,12 +71,14 @@ func getExtClientFromCache(key string) (extclient models.ExtClient, ok bool) {
 	extClientCacheMutex.RLock()
 	extclient, ok = extClientCacheMap[key]
 	extClientCacheMutex.RUnlock()
+	extClientCacheStats.record(ok)
 	return
 }
 
 func storeExtClientInCache(key string, extclient models.ExtClient) {
 	extClientCacheMutex.Lock()
 	extClientCacheMap[key] = extclient
+	extClientCacheVersion++
 	extClientCacheMutex.Unlock()
 }
 
@@ -59,7 +96,7 @@ func GetEgressRangesOnNetwork(client *models.ExtClient) ([]string, error) {
 		}
 		if currentNode.IsEgressGateway { // add the egress gateway range(s) to the result
 			if len(currentNode.EgressGatewayRanges) > 0 {
-				result = append(result, currentNode.EgressGatewayRanges...)
+				result = append(result, healthyEgressRanges(&currentNode)...)
 			}
 		}
 	}
@@ -84,8 +121,7 @@ func DeleteExtClient(network string, clientid string) error {
 // GetNetworkExtClients - gets the ext clients of given network
 func GetNetworkExtClients(network string) ([]models.ExtClient, error) {
 	var extclients []models.ExtClient
-	allextclients := getAllExtClientsFromCache()
-	if len(allextclients) != 0 {
+	if allextclients, ok := getAllExtClientsFromCache(); ok {
 		for _, extclient := range allextclients {
 			if extclient.Network == network {
 				extclients = append(extclients, extclient)
@@ -93,7 +129,8 @@ func GetNetworkExtClients(network string) ([]models.ExtClient, error) {
 		}
 		return extclients, nil
 	}
-	records, err := database.FetchRecords(database.EXT_CLIENT_TABLE_NAME)
+	// the cache isn't loaded, read only the network's records
+	records, err := database.FetchRecordsByField(database.EXT_CLIENT_TABLE_NAME, "network", network)
 	if err != nil {
 		if database.IsEmptyRecord(err) {
 			return extclients, nil
@@ -102,19 +139,40 @@ func GetNetworkExtClients(network string) ([]models.ExtClient, error) {
 	}
 	for _, value := range records {
 		var extclient models.ExtClient
-		err = json.Unmarshal([]byte(value), &extclient)
-		if err != nil {
+		if err := json.Unmarshal([]byte(value), &extclient); err != nil {
 			continue
 		}
-		key, err := GetRecordKey(extclient.ClientID, network)
-		if err == nil {
-			storeExtClientInCache(key, extclient)
+		extclients = append(extclients, extclient)
+	}
+	return extclients, nil
+}
+
+// getAllExtClients - returns the ext clients of all networks, loading the cache if needed
+func getAllExtClients() ([]models.ExtClient, error) {
+	if clients, ok := getAllExtClientsFromCache(); ok {
+		return clients, nil
+	}
+	clientsMap := make(map[string]models.ExtClient)
+	version := getExtClientCacheVersion()
+	records, err := database.FetchRecords(database.EXT_CLIENT_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			loadExtClientsIntoCache(clientsMap, version)
+			return []models.ExtClient{}, nil
 		}
-		if extclient.Network == network {
-			extclients = append(extclients, extclient)
+		return nil, err
+	}
+	clients := make([]models.ExtClient, 0, len(records))
+	for key, value := range records {
+		var extclient models.ExtClient
+		if err = json.Unmarshal([]byte(value), &extclient); err != nil {
+			continue
 		}
+		clientsMap[key] = extclient
+		clients = append(clients, extclient)
 	}
-	return extclients, err
+	loadExtClientsIntoCache(clientsMap, version)
+	return clients, nil
 }
 
 // GetExtClient - gets a single ext client on a network
@@ -155,8 +213,11 @@ func GetExtClientByPubKey(publicKey string, network string) (*models.ExtClient,
 // CreateExtClient - creates and saves an extclient
 func CreateExtClient(extclient *models.ExtClient) error {
 	// lock because we may need unique IPs and having it concurrent makes parallel calls result in same "unique" IPs
-	addressLock.Lock()
-	defer addressLock.Unlock()
+	unlock, err := Lock(addressLockName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	if len(extclient.PublicKey) == 0 {
 		privateKey, err := wgtypes.GeneratePrivateKey()
@@ -179,6 +240,7 @@ func CreateExtClient(extclient *models.ExtClient) error {
 			if err != nil {
 				return err
 			}
+			defer releaseAddress(extclient.Network, newAddress.String())
 			extclient.Address = newAddress.String()
 		}
 	}
@@ -189,6 +251,7 @@ func CreateExtClient(extclient *models.ExtClient) error {
 			if err != nil {
 				return err
 			}
+			defer releaseAddress(extclient.Network, addr6.String())
 			extclient.Address6 = addr6.String()
 		}
 	}
@@ -264,14 +327,18 @@ func GetAllExtClients() ([]models.ExtClient, error) {
 	} else if err != nil {
 		return clients, err
 	}
-
+	allClients, err := getAllExtClients()
+	if err != nil {
+		return clients, nil
+	}
+	networks := make(map[string]struct{}, len(currentNetworks))
 	for i := range currentNetworks {
-		netName := currentNetworks[i].NetID
-		netClients, err := GetNetworkExtClients(netName)
-		if err != nil {
-			continue
+		networks[currentNetworks[i].NetID] = struct{}{}
+	}
+	for i := range allClients {
+		if _, ok := networks[allClients[i].Network]; ok {
+			clients = append(clients, allClients[i])
 		}
-		clients = append(clients, netClients...)
 	}
 
 	return clients, nil
@@ -0,0 +1,148 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
+)
+
+// maxFlowsPerGateway - number of recent flows kept for each gateway
+const maxFlowsPerGateway = 1000
+
+var (
+	// ErrFlowExportNotGateway - flow export can only be enabled on gateways
+	ErrFlowExportNotGateway = errors.New("flow export can only be enabled on ingress or egress gateways")
+	// ErrNoFlowCollector - no collector has been configured on the server
+	ErrNoFlowCollector = errors.New("no flow collector configured, set FLOW_COLLECTOR")
+)
+
+// SetFlowExport - enables or disables flow export on a gateway node
+func SetFlowExport(nodeid string, enable bool) (models.Node, error) {
+	node, err := GetNodeByID(nodeid)
+	if err != nil {
+		return models.Node{}, err
+	}
+	if enable {
+		if !node.IsIngressGateway && !node.IsEgressGateway {
+			return models.Node{}, ErrFlowExportNotGateway
+		}
+		if servercfg.GetFlowCollector() == "" {
+			return models.Node{}, ErrNoFlowCollector
+		}
+	} else {
+		deleteFlows(nodeid)
+	}
+	node.FlowExport = enable
+	if err = UpsertNode(&node); err != nil {
+		return models.Node{}, err
+	}
+	return node, nil
+}
+
+// GetFlowExportConfig - returns the flow export settings for the nodes of a host
+func GetFlowExportConfig(host *models.Host) models.FlowExportConfig {
+	cfg := models.FlowExportConfig{
+		Collector: servercfg.GetFlowCollector(),
+		Protocol:  servercfg.GetFlowExportProtocol(),
+		Nodes:     []string{},
+	}
+	if cfg.Collector == "" {
+		return cfg
+	}
+	for _, nodeID := range host.Nodes {
+		node, err := GetNodeByID(nodeID)
+		if err != nil {
+			continue
+		}
+		if node.FlowExport && (node.IsIngressGateway || node.IsEgressGateway) {
+			cfg.Nodes = append(cfg.Nodes, nodeID)
+		}
+	}
+	cfg.Enabled = len(cfg.Nodes) > 0
+	return cfg
+}
+
+// StoreFlows - keeps the most recent flows reported by a gateway; they're stored rather than kept in memory
+// as the reports of a gateway can reach any server sharing the broker subscriptions
+func StoreFlows(node *models.Node, flows []models.FlowRecord) error {
+	if !node.FlowExport {
+		return nil
+	}
+	id := node.ID.String()
+	for i := range flows {
+		flows[i].NodeID = id
+		flows[i].Network = node.Network
+	}
+	return WithLock("flows:"+id, func(fence *Fence) error {
+		current, err := getGatewayFlows(id)
+		if err != nil {
+			return err
+		}
+		current = append(current, flows...)
+		if len(current) > maxFlowsPerGateway {
+			current = current[len(current)-maxFlowsPerGateway:]
+		}
+		data, err := json.Marshal(current)
+		if err != nil {
+			return err
+		}
+		if err := fence.Check(); err != nil {
+			return err
+		}
+		return database.Insert(id, string(data), database.FLOWS_TABLE_NAME)
+	})
+}
+
+// GetRecentFlows - returns the most recent flows of a network, optionally filtered by gateway, newest first
+func GetRecentFlows(network, nodeID string, limit int) ([]models.FlowRecord, error) {
+	flows := []models.FlowRecord{}
+	records, err := database.FetchRecords(database.FLOWS_TABLE_NAME)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return nil, err
+	}
+	for id, record := range records {
+		if nodeID != "" && id != nodeID {
+			continue
+		}
+		var gwFlows []models.FlowRecord
+		if err := json.Unmarshal([]byte(record), &gwFlows); err != nil {
+			continue
+		}
+		for _, flow := range gwFlows {
+			if flow.Network == network {
+				flows = append(flows, flow)
+			}
+		}
+	}
+	sort.Slice(flows, func(i, j int) bool {
+		return flows[i].EndTime.After(flows[j].EndTime)
+	})
+	if limit > 0 && len(flows) > limit {
+		flows = flows[:limit]
+	}
+	return flows, nil
+}
+
+func getGatewayFlows(nodeID string) ([]models.FlowRecord, error) {
+	record, err := database.FetchRecord(database.FLOWS_TABLE_NAME, nodeID)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return []models.FlowRecord{}, nil
+		}
+		return nil, err
+	}
+	var flows []models.FlowRecord
+	err = json.Unmarshal([]byte(record), &flows)
+	return flows, err
+}
+
+func deleteFlows(nodeID string) {
+	if err := database.DeleteRecord(database.FLOWS_TABLE_NAME, nodeID); err != nil && !database.IsEmptyRecord(err) {
+		slog.Error("failed to delete gateway flows", "node", nodeID, "error", err)
+	}
+}
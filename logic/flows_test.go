@@ -0,0 +1,59 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreFlows(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	gateway := models.Node{CommonNode: models.CommonNode{ID: uuid.New(), Network: "flows-net"}, FlowExport: true}
+	now := time.Now()
+	flow := func(dst string, ago time.Duration) models.FlowRecord {
+		return models.FlowRecord{SrcIP: "10.0.0.1", DstIP: dst, EndTime: now.Add(-ago)}
+	}
+	t.Run("NotExporting", func(t *testing.T) {
+		other := models.Node{CommonNode: models.CommonNode{ID: uuid.New(), Network: "flows-net"}}
+		assert.Nil(t, StoreFlows(&other, []models.FlowRecord{flow("10.0.0.9", 0)}))
+		flows, err := GetRecentFlows("flows-net", other.ID.String(), 0)
+		assert.Nil(t, err)
+		assert.Empty(t, flows)
+	})
+	t.Run("NewestFirst", func(t *testing.T) {
+		assert.Nil(t, StoreFlows(&gateway, []models.FlowRecord{flow("10.0.0.2", time.Minute)}))
+		assert.Nil(t, StoreFlows(&gateway, []models.FlowRecord{flow("10.0.0.3", 0)}))
+		flows, err := GetRecentFlows("flows-net", "", 0)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(flows))
+		assert.Equal(t, "10.0.0.3", flows[0].DstIP)
+		assert.Equal(t, gateway.ID.String(), flows[0].NodeID)
+		flows, err = GetRecentFlows("flows-net", "", 1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(flows))
+		flows, err = GetRecentFlows("other-net", "", 0)
+		assert.Nil(t, err)
+		assert.Empty(t, flows)
+	})
+	t.Run("Trimmed", func(t *testing.T) {
+		many := make([]models.FlowRecord, maxFlowsPerGateway+10)
+		for i := range many {
+			many[i] = flow("10.0.0.4", 0)
+		}
+		assert.Nil(t, StoreFlows(&gateway, many))
+		flows, err := GetRecentFlows("flows-net", gateway.ID.String(), 0)
+		assert.Nil(t, err)
+		assert.Equal(t, maxFlowsPerGateway, len(flows))
+	})
+	t.Run("Deleted", func(t *testing.T) {
+		deleteFlows(gateway.ID.String())
+		flows, err := GetRecentFlows("flows-net", gateway.ID.String(), 0)
+		assert.Nil(t, err)
+		assert.Empty(t, flows)
+	})
+}
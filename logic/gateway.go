@@ -3,12 +3,13 @@ package logic
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/gravitl/netmaker/database"
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
 )
 
 // GetAllIngresses - gets all the hosts that are ingresses
@@ -43,6 +44,25 @@ func GetAllEgresses() ([]models.Node, error) {
 
 // CreateEgressGateway - creates an egress gateway
 func CreateEgressGateway(gateway models.EgressGatewayRequest) (models.Node, error) {
+	unlock, err := Lock(GatewayLockName(gateway.NetID))
+	if err != nil {
+		return models.Node{}, err
+	}
+	defer unlock()
+	node, err := PrepareEgressGateway(gateway)
+	if err != nil {
+		return models.Node{}, err
+	}
+	node.SetLastModified()
+	if err = UpsertNode(&node); err != nil {
+		return models.Node{}, err
+	}
+	RecordNetworkEvent(node.Network, models.NetworkEventGateway, &node, "egress gateway created for "+strings.Join(node.EgressGatewayRanges, ", "))
+	return node, nil
+}
+
+// PrepareEgressGateway - validates an egress gateway request and returns the node as it would be, without saving it
+func PrepareEgressGateway(gateway models.EgressGatewayRequest) (models.Node, error) {
 	node, err := GetNodeByID(gateway.NodeID)
 	if err != nil {
 		return models.Node{}, err
@@ -80,14 +100,13 @@ func CreateEgressGateway(gateway models.EgressGatewayRequest) (models.Node, erro
 	if err != nil {
 		return models.Node{}, err
 	}
+	if err = validateEgressHealthChecks(&gateway); err != nil {
+		return models.Node{}, err
+	}
 	node.IsEgressGateway = true
 	node.EgressGatewayRanges = gateway.Ranges
 	node.EgressGatewayNatEnabled = models.ParseBool(gateway.NatEnabled)
 	node.EgressGatewayRequest = gateway // store entire request for use when preserving the egress gateway
-	node.SetLastModified()
-	if err = UpsertNode(&node); err != nil {
-		return models.Node{}, err
-	}
 	return node, nil
 }
 
@@ -111,15 +130,26 @@ func DeleteEgressGateway(network, nodeid string) (models.Node, error) {
 	node.IsEgressGateway = false
 	node.EgressGatewayRanges = []string{}
 	node.EgressGatewayRequest = models.EgressGatewayRequest{} // remove preserved request as the egress gateway is gone
+	if !node.IsIngressGateway {
+		node.FlowExport = false
+		deleteFlows(nodeid)
+	}
 	node.SetLastModified()
 	if err = UpsertNode(&node); err != nil {
 		return models.Node{}, err
 	}
+	DeleteEgressHealth(nodeid)
+	RecordNetworkEvent(node.Network, models.NetworkEventGateway, &node, "egress gateway removed")
 	return node, nil
 }
 
 // CreateIngressGateway - creates an ingress gateway
 func CreateIngressGateway(netid string, nodeid string, ingress models.IngressRequest) (models.Node, error) {
+	unlock, err := Lock(GatewayLockName(netid))
+	if err != nil {
+		return models.Node{}, err
+	}
+	defer unlock()
 
 	node, err := GetNodeByID(nodeid)
 	if err != nil {
@@ -149,12 +179,16 @@ func CreateIngressGateway(netid string, nodeid string, ingress models.IngressReq
 	node.IngressDNS = ingress.ExtclientDNS
 	node.SetLastModified()
 	if ingress.Failover && servercfg.Is_EE {
+		if node.Ephemeral {
+			return models.Node{}, ErrEphemeralNode
+		}
 		node.Failover = true
 	}
 	err = UpsertNode(&node)
 	if err != nil {
 		return models.Node{}, err
 	}
+	RecordNetworkEvent(node.Network, models.NetworkEventGateway, &node, "ingress gateway created")
 	err = SetNetworkNodesLastModified(netid)
 	return node, err
 }
@@ -177,16 +211,22 @@ func DeleteIngressGateway(nodeid string) (models.Node, bool, []models.ExtClient,
 	if err = DeleteGatewayExtClients(node.ID.String(), node.Network); err != nil {
 		return models.Node{}, false, removedClients, err
 	}
-	logger.Log(3, "deleting ingress gateway")
+	RemoveSecondaryGateway(node.ID.String(), node.Network)
+	slog.Debug("deleting ingress gateway")
 	wasFailover := node.Failover
 	node.LastModified = time.Now()
 	node.IsIngressGateway = false
 	node.IngressGatewayRange = ""
 	node.Failover = false
+	if !node.IsEgressGateway {
+		node.FlowExport = false
+		deleteFlows(nodeid)
+	}
 	err = UpsertNode(&node)
 	if err != nil {
 		return models.Node{}, wasFailover, removedClients, err
 	}
+	RecordNetworkEvent(node.Network, models.NetworkEventGateway, &node, fmt.Sprintf("ingress gateway removed with %d ext clients", len(removedClients)))
 	err = SetNetworkNodesLastModified(node.Network)
 	return node, wasFailover, removedClients, err
 }
@@ -203,7 +243,7 @@ func DeleteGatewayExtClients(gatewayID string, networkName string) error {
 	for _, extClient := range currentExtClients {
 		if extClient.IngressGatewayID == gatewayID {
 			if err = DeleteExtClient(networkName, extClient.ClientID); err != nil {
-				logger.Log(1, "failed to remove ext client", extClient.ClientID)
+				slog.Error("failed to remove ext client", "client_id", extClient.ClientID)
 				continue
 			}
 		}
@@ -0,0 +1,269 @@
+package logic
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+)
+
+const (
+	// defaultGrafanaDataPoints - the points of an uptime series when grafana doesn't ask for a number
+	defaultGrafanaDataPoints = 100
+	// maxGrafanaDataPoints - the most points of an uptime series
+	maxGrafanaDataPoints = 1000
+)
+
+// ErrInvalidGrafanaRange - a grafana query whose range ends before it starts
+var ErrInvalidGrafanaRange = errors.New("invalid time range")
+
+// grafanaTarget - what a target name of the grafana datasource refers to
+type grafanaTarget struct {
+	kind   string
+	nodeID string
+	peerID string
+}
+
+// SearchGrafanaTargets - the names of the series grafana can chart, containing filter when it's set:
+// network/node/latency/peer for the latency of every link a node reports, network/node/uptime and network/node/status
+func SearchGrafanaTargets(tenant, filter string) ([]string, error) {
+	targets, err := getGrafanaTargets(tenant)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for name := range targets {
+		if strings.Contains(name, filter) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// QueryGrafana - the series of a grafana query; latencies only have the latest measurement since metrics
+// are stored without history, uptime and status come from the node status history
+func QueryGrafana(tenant string, query *models.GrafanaQuery) ([]models.GrafanaSeries, error) {
+	if query.Range.To.Before(query.Range.From) {
+		return nil, ErrInvalidGrafanaRange
+	}
+	targets, err := getGrafanaTargets(tenant)
+	if err != nil {
+		return nil, err
+	}
+	points := query.MaxDataPoints
+	if points <= 0 {
+		points = defaultGrafanaDataPoints
+	}
+	if points > maxGrafanaDataPoints {
+		points = maxGrafanaDataPoints
+	}
+	now := time.Now().UTC()
+	series := []models.GrafanaSeries{}
+	for _, requested := range query.Targets {
+		target, ok := targets[requested.Target]
+		if !ok {
+			continue
+		}
+		result := models.GrafanaSeries{Target: requested.Target, Datapoints: [][2]float64{}}
+		switch target.kind {
+		case models.GrafanaLatency:
+			metrics, err := GetMetrics(target.nodeID)
+			if err != nil {
+				return nil, err
+			}
+			at := query.Range.To
+			if now.Before(at) {
+				at = now
+			}
+			if metric, ok := metrics.Connectivity[target.peerID]; ok && !at.Before(query.Range.From) {
+				result.Datapoints = append(result.Datapoints, [2]float64{float64(metric.Latency), float64(at.UnixMilli())})
+			}
+		case models.GrafanaUptime, models.GrafanaStatus:
+			history, err := getNodeStatusHistory(target.nodeID)
+			if err != nil && !database.IsEmptyRecord(err) {
+				return nil, err
+			}
+			to := query.Range.To
+			if now.Before(to) {
+				to = now
+			}
+			if target.kind == models.GrafanaUptime {
+				result.Datapoints = uptimeSeries(history.Changes, query.Range.From, to, points)
+			} else {
+				result.Datapoints = statusSeries(history.Changes, query.Range.From, to)
+			}
+		}
+		series = append(series, result)
+	}
+	return series, nil
+}
+
+// GetGrafanaAnnotations - the events of the network named by the annotation's query, or of every network
+// of the tenant when it's empty, in the query's range
+func GetGrafanaAnnotations(tenant string, query *models.GrafanaAnnotationQuery) ([]models.GrafanaAnnotationEvent, error) {
+	networks, err := grafanaNetworks(tenant)
+	if err != nil {
+		return nil, err
+	}
+	annotations := []models.GrafanaAnnotationEvent{}
+	for _, network := range networks {
+		if query.Annotation.Query != "" && network.NetID != strings.TrimSpace(query.Annotation.Query) {
+			continue
+		}
+		page, err := GetNetworkEvents(network.NetID, models.NetworkEventFilter{
+			From:  query.Range.From,
+			To:    query.Range.To,
+			Limit: maxNetworkEventLimit,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range page.Events {
+			annotations = append(annotations, models.GrafanaAnnotationEvent{
+				Annotation: query.Annotation,
+				Time:       event.Time.UnixMilli(),
+				Title:      event.Kind,
+				Text:       event.Message,
+				Tags:       []string{event.Network, event.Kind},
+			})
+		}
+	}
+	sort.Slice(annotations, func(i, j int) bool {
+		return annotations[i].Time < annotations[j].Time
+	})
+	return annotations, nil
+}
+
+// == private ==
+
+// grafanaNetworks - the networks of a tenant, every network when it isn't set
+func grafanaNetworks(tenant string) ([]models.Network, error) {
+	networks, err := GetNetworks()
+	if err != nil && !database.IsEmptyRecord(err) {
+		return nil, err
+	}
+	kept := []models.Network{}
+	for _, network := range networks {
+		if tenant == "" || network.Tenant == tenant {
+			kept = append(kept, network)
+		}
+	}
+	return kept, nil
+}
+
+// getGrafanaTargets - the series of the nodes of a tenant's networks by name, nodes named after their hosts
+func getGrafanaTargets(tenant string) (map[string]grafanaTarget, error) {
+	networks, err := grafanaNetworks(tenant)
+	if err != nil {
+		return nil, err
+	}
+	hosts, err := GetAllHosts()
+	if err != nil {
+		return nil, err
+	}
+	hostNames := map[string]string{}
+	for _, host := range hosts {
+		hostNames[host.ID.String()] = host.Name
+	}
+	targets := map[string]grafanaTarget{}
+	for _, network := range networks {
+		nodes, err := GetNetworkNodes(network.NetID)
+		if err != nil && !database.IsEmptyRecord(err) {
+			return nil, err
+		}
+		names := map[string]string{}
+		for _, node := range nodes {
+			names[node.ID.String()] = grafanaNodeName(&node, hostNames)
+		}
+		for _, node := range nodes {
+			id := node.ID.String()
+			prefix := network.NetID + "/" + names[id] + "/"
+			targets[prefix+models.GrafanaUptime] = grafanaTarget{kind: models.GrafanaUptime, nodeID: id}
+			targets[prefix+models.GrafanaStatus] = grafanaTarget{kind: models.GrafanaStatus, nodeID: id}
+			metrics, err := GetMetrics(id)
+			if err != nil {
+				continue
+			}
+			for peerID := range metrics.Connectivity {
+				peer, ok := names[peerID]
+				if !ok {
+					continue
+				}
+				targets[prefix+models.GrafanaLatency+"/"+peer] = grafanaTarget{kind: models.GrafanaLatency, nodeID: id, peerID: peerID}
+			}
+		}
+	}
+	return targets, nil
+}
+
+// grafanaNodeName - the name of a node in target names: its own name, its host's or its id
+func grafanaNodeName(node *models.Node, hostNames map[string]string) string {
+	name := node.Name
+	if name == "" {
+		name = hostNames[node.HostID.String()]
+	}
+	if name == "" {
+		name = node.ID.String()
+	}
+	// target names are separated by slashes
+	return strings.ReplaceAll(name, "/", "_")
+}
+
+// uptimeSeries - the percent of time a node was online in each of points intervals between from and to,
+// intervals it wasn't tracked for are left out
+func uptimeSeries(changes []models.NodeStatusChange, from, to time.Time, points int) [][2]float64 {
+	series := [][2]float64{}
+	if !to.After(from) || points <= 0 {
+		return series
+	}
+	step := to.Sub(from) / time.Duration(points)
+	if step < time.Minute {
+		step = time.Minute
+	}
+	for start := from; start.Before(to); start = start.Add(step) {
+		end := start.Add(step)
+		if end.After(to) {
+			end = to
+		}
+		// nodeUptime runs the last change it's given until the end of the interval
+		last := sort.Search(len(changes), func(i int) bool {
+			return !changes[i].Time.Before(end)
+		})
+		online, tracked, _ := nodeUptime(changes[:last], start, end)
+		if tracked <= 0 {
+			continue
+		}
+		series = append(series, [2]float64{float64(online) / float64(tracked) * 100, float64(end.UnixMilli())})
+	}
+	return series
+}
+
+// statusSeries - a point for the status of a node at from and for each of its changes until to,
+// online being 1 and offline 0
+func statusSeries(changes []models.NodeStatusChange, from, to time.Time) [][2]float64 {
+	series := [][2]float64{}
+	value := func(online bool) float64 {
+		if online {
+			return 1
+		}
+		return 0
+	}
+	for i, change := range changes {
+		if change.Time.After(to) {
+			break
+		}
+		if !change.Time.After(from) {
+			// the last change before the range is the status at its start
+			if i+1 == len(changes) || changes[i+1].Time.After(from) {
+				series = append(series, [2]float64{value(change.Online), float64(from.UnixMilli())})
+			}
+			continue
+		}
+		series = append(series, [2]float64{value(change.Online), float64(change.Time.UnixMilli())})
+	}
+	return series
+}
@@ -0,0 +1,35 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrafanaSeries(t *testing.T) {
+	from := time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+	changes := []models.NodeStatusChange{
+		{Time: from.Add(-time.Hour), Online: true},
+		{Time: from.Add(30 * time.Minute), Online: false},
+		{Time: from.Add(90 * time.Minute), Online: true},
+	}
+	t.Run("Uptime", func(t *testing.T) {
+		series := uptimeSeries(changes, from, from.Add(2*time.Hour), 2)
+		assert.Equal(t, [][2]float64{
+			{50, float64(from.Add(time.Hour).UnixMilli())},
+			{50, float64(from.Add(2 * time.Hour).UnixMilli())},
+		}, series)
+	})
+	t.Run("Status", func(t *testing.T) {
+		series := statusSeries(changes, from, from.Add(time.Hour))
+		assert.Equal(t, [][2]float64{
+			{1, float64(from.UnixMilli())},
+			{0, float64(from.Add(30 * time.Minute).UnixMilli())},
+		}, series)
+	})
+	t.Run("Untracked", func(t *testing.T) {
+		assert.Empty(t, uptimeSeries(changes[2:], from, from.Add(time.Hour), 4))
+	})
+}
@@ -0,0 +1,66 @@
+package logic
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+)
+
+// workerHeartbeat - when a background worker last reported in and how often it should
+type workerHeartbeat struct {
+	last     time.Time
+	interval time.Duration
+}
+
+var (
+	workersMutex sync.RWMutex
+	workers      = make(map[string]workerHeartbeat)
+)
+
+// WorkerHeartbeat - records that a background worker is alive, the worker is
+// considered stuck if it doesn't report again within twice its interval
+func WorkerHeartbeat(name string, interval time.Duration) {
+	workersMutex.Lock()
+	defer workersMutex.Unlock()
+	workers[name] = workerHeartbeat{last: time.Now(), interval: interval}
+}
+
+// StopWorker - removes a worker that exited on purpose from the liveness checks
+func StopWorker(name string) {
+	workersMutex.Lock()
+	defer workersMutex.Unlock()
+	delete(workers, name)
+}
+
+// CheckWorkers - returns the liveness of every background worker
+func CheckWorkers() []models.HealthCheck {
+	workersMutex.RLock()
+	defer workersMutex.RUnlock()
+	checks := make([]models.HealthCheck, 0, len(workers))
+	for name, beat := range workers {
+		check := models.HealthCheck{Name: name, Healthy: true, LastSeen: beat.last}
+		if time.Since(beat.last) > 2*beat.interval {
+			check.Healthy = false
+			check.Error = fmt.Sprintf("no heartbeat for %s", time.Since(beat.last).Round(time.Second))
+		}
+		checks = append(checks, check)
+	}
+	sort.Slice(checks, func(i, j int) bool {
+		return checks[i].Name < checks[j].Name
+	})
+	return checks
+}
+
+// CheckDNS - checks the files served by CoreDNS are in place
+func CheckDNS() error {
+	for _, file := range []string{"./config/dnsconfig/Corefile", "./config/dnsconfig/netmaker.hosts"} {
+		if _, err := os.Stat(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
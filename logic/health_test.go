@@ -0,0 +1,38 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckWorkers(t *testing.T) {
+	defer StopWorker("test-alive")
+	defer StopWorker("test-stuck")
+	WorkerHeartbeat("test-alive", time.Minute)
+	workersMutex.Lock()
+	workers["test-stuck"] = workerHeartbeat{last: time.Now().Add(-3 * time.Minute), interval: time.Minute}
+	workersMutex.Unlock()
+
+	checks := CheckWorkers()
+	var alive, stuck bool
+	for _, check := range checks {
+		switch check.Name {
+		case "test-alive":
+			alive = true
+			assert.True(t, check.Healthy)
+		case "test-stuck":
+			stuck = true
+			assert.False(t, check.Healthy)
+			assert.Contains(t, check.Error, "no heartbeat for 3m")
+		}
+	}
+	assert.True(t, alive)
+	assert.True(t, stuck)
+
+	StopWorker("test-stuck")
+	for _, check := range CheckWorkers() {
+		assert.NotEqual(t, "test-stuck", check.Name, "workers that exit on purpose aren't checked")
+	}
+}
@@ -0,0 +1,97 @@
+package logic
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/url"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+)
+
+// IssueHostCertificate - signs a client certificate for a host from its PEM CSR,
+// the common name is always the host's id so it's also its broker username
+func IssueHostCertificate(host *models.Host, csrPEM string) (models.IssuedCertificate, error) {
+	publicKey, err := parseCSR(csrPEM)
+	if err != nil {
+		return models.IssuedCertificate{}, err
+	}
+	record := models.PKICertificate{
+		Kind:    models.CertKindHost,
+		Subject: host.ID.String(),
+		HostID:  host.ID.String(),
+	}
+	return issueCertificate(&record, publicKey, 0)
+}
+
+// VerifyHostCertificate - checks a client certificate was issued by the internal CA to a host that still exists,
+// isn't quarantined and wasn't revoked, returns the host's id
+func VerifyHostCertificate(cert *x509.Certificate) (string, error) {
+	ca, _, err := getPKICA()
+	if err != nil {
+		return "", err
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	if _, err := cert.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}); err != nil {
+		return "", err
+	}
+	record, err := GetCertificate(cert.SerialNumber.String())
+	if err != nil {
+		return "", err
+	}
+	if record.Revoked || record.Kind != models.CertKindHost || record.HostID != cert.Subject.CommonName {
+		return "", ErrCertRevoked
+	}
+	if _, err := GetHost(record.HostID); err != nil {
+		return "", err
+	}
+	if err := CheckHostNotQuarantined(record.HostID); err != nil {
+		return "", err
+	}
+	return record.HostID, nil
+}
+
+// HostCertFromRequest - the client certificate a host presented, on the TLS connection
+// or forwarded by a TLS terminating proxy in the configured header, nil when none.
+// The header is only read from one of TRUSTED_PROXIES, anyone else could set it to any host's certificate.
+func HostCertFromRequest(r *http.Request) *x509.Certificate {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		return r.TLS.PeerCertificates[0]
+	}
+	header := servercfg.GetHostCertHeader()
+	if header == "" || r.Header.Get(header) == "" || !IsFromTrustedProxy(r) {
+		return nil
+	}
+	value, err := url.QueryUnescape(r.Header.Get(header))
+	if err != nil {
+		return nil
+	}
+	block, _ := pem.Decode([]byte(value))
+	if block == nil {
+		return nil
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil
+	}
+	return cert
+}
+
+// RevokeHostCertificates - revokes every client certificate issued to a host
+func RevokeHostCertificates(hostID, reason string) error {
+	records, err := GetCertificates(models.CertKindHost)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if record.HostID != hostID || record.Revoked {
+			continue
+		}
+		if _, err := RevokeCertificate(record.Serial, reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
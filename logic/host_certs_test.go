@@ -0,0 +1,73 @@
+package logic
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostCertificates(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	// the crl lists the revoked certificates of earlier runs too
+	database.DeleteAllRecords(database.PKI_CERTS_TABLE_NAME)
+	defer database.DeleteAllRecords(database.PKI_CERTS_TABLE_NAME)
+	host := models.Host{ID: uuid.New(), Name: "certhost"}
+	assert.Nil(t, UpsertHost(&host))
+	defer RemoveHostByID(host.ID.String())
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{}, key)
+	assert.Nil(t, err)
+
+	_, err = IssueHostCertificate(&host, "not a csr")
+	assert.ErrorIs(t, err, ErrInvalidCSR)
+
+	issued, err := IssueHostCertificate(&host, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})))
+	assert.Nil(t, err)
+	block, _ := pem.Decode([]byte(issued.Certificate))
+	cert, err := x509.ParseCertificate(block.Bytes)
+	assert.Nil(t, err)
+	hostID, err := VerifyHostCertificate(cert)
+	assert.Nil(t, err)
+	assert.Equal(t, host.ID.String(), hostID)
+
+	t.Run("Forwarded", func(t *testing.T) {
+		t.Setenv("HOST_CERT_HEADER", "X-Client-Cert")
+		t.Setenv("TRUSTED_PROXIES", "10.0.0.1/32")
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/host", nil)
+		r.Header.Set("X-Client-Cert", url.QueryEscape(issued.Certificate))
+		r.RemoteAddr = "203.0.113.7:40000"
+		assert.Nil(t, HostCertFromRequest(r), "anyone but the proxy could forward any host's certificate")
+		r.RemoteAddr = "10.0.0.1:40000"
+		forwarded := HostCertFromRequest(r)
+		if assert.NotNil(t, forwarded) {
+			assert.Equal(t, cert.SerialNumber, forwarded.SerialNumber)
+		}
+		t.Setenv("TRUSTED_PROXIES", "")
+		assert.Nil(t, HostCertFromRequest(r))
+	})
+
+	assert.Nil(t, RevokeHostCertificates(host.ID.String(), "host removed"))
+	_, err = VerifyHostCertificate(cert)
+	assert.ErrorIs(t, err, ErrCertRevoked)
+	record, err := GetCertificate(cert.SerialNumber.String())
+	assert.Nil(t, err)
+	assert.Equal(t, "host removed", record.RevocationReason)
+	crl, err := GetCRL()
+	assert.Nil(t, err)
+	block, _ = pem.Decode(crl)
+	list, err := x509.ParseRevocationList(block.Bytes)
+	assert.Nil(t, err)
+	assert.Len(t, list.RevokedCertificates, 1)
+}
@@ -0,0 +1,125 @@
+package logic
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/netclient/ncutils"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// driftGrace - how long the hashes may differ before a host counts as drifted, it takes a host
+	// a check-in or so to apply an update published to it
+	driftGrace = 2 * time.Minute
+	// driftCorrectionInterval - the least time between two pulls a drifted host is asked for
+	driftCorrectionInterval = 10 * time.Minute
+)
+
+// CheckHostDrift - compares the config hash a host checked in with to that of the config expected for it, recording
+// when they stopped matching; true when the host drifted and should be asked to pull, when DRIFT_AUTO_CORRECT is on
+func CheckHostDrift(host *models.Host, reported string) (bool, error) {
+	expected, err := expectedConfigHash(host)
+	if err != nil {
+		return false, err
+	}
+	drift, err := GetHostDrift(host.ID.String())
+	if err != nil && !database.IsEmptyRecord(err) {
+		return false, err
+	}
+	return applyHostDrift(&drift, host, reported, expected, time.Now().UTC()), saveHostDrift(&drift)
+}
+
+// GetHostDrift - how the config a host applied compares to the expected one, as of its last check-in
+func GetHostDrift(hostID string) (models.HostDrift, error) {
+	var drift models.HostDrift
+	record, err := database.FetchRecord(database.HOST_DRIFT_TABLE_NAME, hostID)
+	if err != nil {
+		return drift, err
+	}
+	err = json.Unmarshal([]byte(record), &drift)
+	return drift, err
+}
+
+// GetDriftedHosts - the hosts whose applied config drifted from the expected one, the longest drifted first
+func GetDriftedHosts() ([]models.HostDrift, error) {
+	drifted := []models.HostDrift{}
+	records, err := database.FetchRecords(database.HOST_DRIFT_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return drifted, nil
+		}
+		return nil, err
+	}
+	for _, value := range records {
+		var drift models.HostDrift
+		if err := json.Unmarshal([]byte(value), &drift); err != nil || !drift.Drifted {
+			continue
+		}
+		drifted = append(drifted, drift)
+	}
+	sort.Slice(drifted, func(i, j int) bool {
+		return drifted[i].Since.Before(drifted[j].Since)
+	})
+	return drifted, nil
+}
+
+// == private ==
+
+// applyHostDrift - updates the drift of a host with the hashes of a check-in, true when a pull should correct it
+func applyHostDrift(drift *models.HostDrift, host *models.Host, reported, expected string, now time.Time) bool {
+	drift.HostID = host.ID.String()
+	drift.HostName = host.Name
+	drift.ReportedHash = reported
+	drift.ExpectedHash = expected
+	drift.LastChecked = now
+	if reported == expected {
+		if drift.Drifted {
+			slog.Info("host config no longer drifted", "host", host.Name, "id", host.ID, "since", drift.Since)
+		}
+		drift.Drifted = false
+		drift.Since = time.Time{}
+		return false
+	}
+	if drift.Since.IsZero() {
+		drift.Since = now
+	}
+	if now.Sub(drift.Since) < driftGrace {
+		return false
+	}
+	if !drift.Drifted {
+		slog.Warn("host config drifted", "host", host.Name, "id", host.ID, "since", drift.Since)
+	}
+	drift.Drifted = true
+	if !servercfg.IsDriftAutoCorrect() || now.Sub(drift.LastCorrection) < driftCorrectionInterval {
+		return false
+	}
+	drift.Corrections++
+	drift.LastCorrection = now
+	return true
+}
+
+// expectedConfigHash - the hash of the peers and firewall config of the peer update the host should have applied
+func expectedConfigHash(host *models.Host) (string, error) {
+	allNodes, err := GetAllNodes()
+	if err != nil {
+		return "", err
+	}
+	update, err := GetPeerUpdateForHost("", host, allNodes, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	return ncutils.HashConfig(update.Peers, update.FwUpdate), nil
+}
+
+func saveHostDrift(drift *models.HostDrift) error {
+	data, err := json.Marshal(drift)
+	if err != nil {
+		return err
+	}
+	return database.Insert(drift.HostID, string(data), database.HOST_DRIFT_TABLE_NAME)
+}
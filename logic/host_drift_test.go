@@ -0,0 +1,64 @@
+package logic
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/netclient/ncutils"
+	"github.com/stretchr/testify/assert"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestHostDrift(t *testing.T) {
+	host := &models.Host{ID: uuid.New(), Name: "drifter"}
+	now := time.Now().UTC()
+	drift := models.HostDrift{}
+
+	assert.False(t, applyHostDrift(&drift, host, "abc", "abc", now))
+	assert.False(t, drift.Drifted)
+	assert.False(t, applyHostDrift(&drift, host, "old", "abc", now), "an update just published isn't applied yet")
+	assert.False(t, drift.Drifted)
+	assert.Equal(t, now, drift.Since)
+
+	t.Run("Drifted", func(t *testing.T) {
+		assert.False(t, applyHostDrift(&drift, host, "old", "abc", now.Add(driftGrace)), "no correction unless auto correct is on")
+		assert.True(t, drift.Drifted)
+		assert.Equal(t, now, drift.Since)
+	})
+	t.Run("AutoCorrect", func(t *testing.T) {
+		t.Setenv("DRIFT_AUTO_CORRECT", "on")
+		later := now.Add(driftGrace + time.Minute)
+		assert.True(t, applyHostDrift(&drift, host, "old", "abc", later))
+		assert.False(t, applyHostDrift(&drift, host, "old", "abc", later.Add(time.Minute)), "corrections are spaced out")
+		assert.Equal(t, 1, drift.Corrections)
+		assert.True(t, applyHostDrift(&drift, host, "old", "abc", later.Add(driftCorrectionInterval)))
+		assert.Equal(t, 2, drift.Corrections)
+	})
+	t.Run("Corrected", func(t *testing.T) {
+		assert.False(t, applyHostDrift(&drift, host, "abc", "abc", now.Add(time.Hour)))
+		assert.False(t, drift.Drifted)
+		assert.True(t, drift.Since.IsZero())
+	})
+}
+
+func TestHashConfig(t *testing.T) {
+	a, _ := wgtypes.GeneratePrivateKey()
+	b, _ := wgtypes.GeneratePrivateKey()
+	keepalive := 20 * time.Second
+	peers := []wgtypes.PeerConfig{
+		{PublicKey: a.PublicKey(), AllowedIPs: []net.IPNet{{IP: net.ParseIP("10.0.0.1"), Mask: net.CIDRMask(32, 32)}, {IP: net.ParseIP("10.0.0.2"), Mask: net.CIDRMask(32, 32)}}},
+		{PublicKey: b.PublicKey(), PersistentKeepaliveInterval: &keepalive, Endpoint: &net.UDPAddr{IP: net.ParseIP("1.2.3.4"), Port: 51821}},
+	}
+	reordered := []wgtypes.PeerConfig{peers[1], peers[0]}
+	reordered[1].AllowedIPs = []net.IPNet{peers[0].AllowedIPs[1], peers[0].AllowedIPs[0]}
+	reordered[0].Endpoint = &net.UDPAddr{IP: net.ParseIP("5.6.7.8"), Port: 51821}
+	fw := models.FwUpdate{}
+	assert.Equal(t, ncutils.HashConfig(peers, fw), ncutils.HashConfig(reordered, fw), "order and endpoints don't count")
+	removed := append(reordered, wgtypes.PeerConfig{PublicKey: a.PublicKey(), Remove: true})
+	assert.Equal(t, ncutils.HashConfig(peers, fw), ncutils.HashConfig(removed, fw))
+	assert.NotEqual(t, ncutils.HashConfig(peers, fw), ncutils.HashConfig(peers[:1], fw))
+	assert.NotEqual(t, ncutils.HashConfig(peers, fw), ncutils.HashConfig(peers, models.FwUpdate{IsEgressGw: true}))
+}
@@ -0,0 +1,131 @@
+package logic
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+)
+
+const (
+	// hostResourceInterval - the least time between two stored samples of a host, check-ins in between are skipped
+	hostResourceInterval = 5 * time.Minute
+	// hostResourceRetention - how long resource samples are kept
+	hostResourceRetention = 7 * 24 * time.Hour
+	// hostSaturatedPercent - the cpu or memory use a host counts as saturated at
+	hostSaturatedPercent = 90
+	// DefaultHostResourceWindow - the window of resource metrics returned when none is asked for
+	DefaultHostResourceWindow = "24h"
+)
+
+// RecordHostResources - adds the resources a host checked in with to its history, at most one sample
+// per interval, dropping samples past the retention
+func RecordHostResources(hostID string, resources *models.HostResources) error {
+	now := time.Now().UTC()
+	history, err := getHostResourceHistory(hostID)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return err
+	}
+	history.HostID = hostID
+	if n := len(history.Samples); n > 0 && now.Sub(history.Samples[n-1].Time) < hostResourceInterval {
+		return nil
+	}
+	sample := *resources
+	sample.Time = now
+	history.Samples = append(pruneHostResources(history.Samples, now.Add(-hostResourceRetention)), sample)
+	data, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+	return database.Insert(hostID, string(data), database.HOST_RESOURCES_TABLE_NAME)
+}
+
+// GetHostResourceMetrics - a host's resource samples over a window, a duration such as 12h or days such as 7d
+func GetHostResourceMetrics(hostID, window string) (models.HostResourceMetrics, error) {
+	if window == "" {
+		window = DefaultHostResourceWindow
+	}
+	metrics := models.HostResourceMetrics{HostID: hostID, Window: window, Samples: []models.HostResources{}}
+	duration, err := parseUptimeWindow(window)
+	if err != nil {
+		return metrics, err
+	}
+	history, err := getHostResourceHistory(hostID)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return metrics, nil
+		}
+		return metrics, err
+	}
+	summarizeHostResources(&metrics, history.Samples, time.Now().UTC().Add(-duration))
+	return metrics, nil
+}
+
+// == private ==
+
+// summarizeHostResources - fills in the samples since from, their peaks and the interface errors and drops
+// between them; a counter lower than the one before it was reset by the interface coming up again
+func summarizeHostResources(metrics *models.HostResourceMetrics, samples []models.HostResources, from time.Time) {
+	var previous *models.HostResources
+	for i := range samples {
+		sample := samples[i]
+		if sample.Time.Before(from) {
+			continue
+		}
+		metrics.Samples = append(metrics.Samples, sample)
+		if sample.CPUPercent > metrics.PeakCPUPercent {
+			metrics.PeakCPUPercent = sample.CPUPercent
+		}
+		if sample.MemoryTotal > 0 {
+			if percent := float64(sample.MemoryUsed) / float64(sample.MemoryTotal) * 100; percent > metrics.PeakMemoryPercent {
+				metrics.PeakMemoryPercent = percent
+			}
+		}
+		if previous != nil {
+			metrics.Errors += counterIncrease(previous.RxErrors, sample.RxErrors) + counterIncrease(previous.TxErrors, sample.TxErrors)
+			metrics.Dropped += counterIncrease(previous.RxDropped, sample.RxDropped) + counterIncrease(previous.TxDropped, sample.TxDropped)
+		}
+		previous = &metrics.Samples[len(metrics.Samples)-1]
+	}
+	if previous != nil {
+		latest := *previous
+		metrics.Latest = &latest
+	}
+	metrics.Saturated = metrics.PeakCPUPercent >= hostSaturatedPercent || metrics.PeakMemoryPercent >= hostSaturatedPercent ||
+		metrics.Errors > 0 || metrics.Dropped > 0
+}
+
+// counterIncrease - how much a counter grew between two readings
+func counterIncrease(before, after uint64) uint64 {
+	if after < before {
+		return after
+	}
+	return after - before
+}
+
+// pruneHostResources - drops the samples before cutoff
+func pruneHostResources(samples []models.HostResources, cutoff time.Time) []models.HostResources {
+	first := 0
+	for first < len(samples) && samples[first].Time.Before(cutoff) {
+		first++
+	}
+	return samples[first:]
+}
+
+func getHostResourceHistory(hostID string) (models.HostResourceHistory, error) {
+	var history models.HostResourceHistory
+	data, err := database.FetchRecord(database.HOST_RESOURCES_TABLE_NAME, hostID)
+	if err != nil {
+		return history, err
+	}
+	err = json.Unmarshal([]byte(data), &history)
+	return history, err
+}
+
+func deleteHostResources(hostID string) error {
+	if err := database.DeleteRecord(database.HOST_RESOURCES_TABLE_NAME, hostID); err != nil && !database.IsEmptyRecord(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarizeHostResources(t *testing.T) {
+	now := time.Now().UTC()
+	samples := []models.HostResources{
+		{Time: now.Add(-48 * time.Hour), CPUPercent: 99},
+		{Time: now.Add(-2 * time.Hour), CPUPercent: 20, MemoryTotal: 1000, MemoryUsed: 500, RxDropped: 10},
+		{Time: now.Add(-time.Hour), CPUPercent: 40, MemoryTotal: 1000, MemoryUsed: 250, RxDropped: 15, TxErrors: 2},
+		// the interface came up again
+		{Time: now, CPUPercent: 30, MemoryTotal: 1000, MemoryUsed: 300, RxDropped: 3},
+	}
+	metrics := models.HostResourceMetrics{}
+	summarizeHostResources(&metrics, samples, now.Add(-24*time.Hour))
+	assert.Equal(t, 3, len(metrics.Samples))
+	assert.Equal(t, float64(40), metrics.PeakCPUPercent, "samples before the window are left out")
+	assert.Equal(t, float64(50), metrics.PeakMemoryPercent)
+	assert.Equal(t, uint64(8), metrics.Dropped)
+	assert.Equal(t, uint64(2), metrics.Errors)
+	assert.True(t, metrics.Saturated)
+	assert.Equal(t, float64(30), metrics.Latest.CPUPercent)
+	assert.Equal(t, 2, len(pruneHostResources(samples, now.Add(-90*time.Minute))))
+}
@@ -0,0 +1,86 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestTuneHost(t *testing.T) {
+	database.InitializeDatabase()
+	// closed after the hosts are cleaned up
+	t.Cleanup(database.CloseDB)
+	host, peerHost, stranger := tuningHost(t, "tunenet"), tuningHost(t, "tunenet"), tuningHost(t, "othertunenet")
+
+	t.Run("Invalid", func(t *testing.T) {
+		var validationErrs validator.ValidationErrors
+		assert.ErrorAs(t, TuneHost(host, &models.HostTuning{ListenPort: 80}), &validationErrs)
+		assert.ErrorAs(t, TuneHost(host, &models.HostTuning{Stun: "maybe"}), &validationErrs)
+		assert.ErrorAs(t, TuneHost(host, &models.HostTuning{PeerKeepalives: map[string]int{peerHost.ID.String(): 0}}), &validationErrs)
+	})
+	t.Run("NotAPeer", func(t *testing.T) {
+		assert.ErrorIs(t, TuneHost(host, &models.HostTuning{PeerKeepalives: map[string]int{stranger.ID.String(): 20}}), ErrNotAPeer)
+		assert.ErrorIs(t, TuneHost(host, &models.HostTuning{PeerKeepalives: map[string]int{uuid.NewString(): 20}}), ErrNotAPeer)
+		assert.ErrorIs(t, TuneHost(host, &models.HostTuning{PeerKeepalives: map[string]int{host.ID.String(): 20}}), ErrNotAPeer)
+	})
+	t.Run("Applied", func(t *testing.T) {
+		tuning := models.HostTuning{ListenPort: 51900, Stun: "no", PeerKeepalives: map[string]int{peerHost.ID.String(): 15}}
+		assert.Nil(t, TuneHost(host, &tuning))
+		stored, err := GetHost(host.ID.String())
+		assert.Nil(t, err)
+		assert.Equal(t, 51900, stored.ListenPort)
+		assert.Equal(t, "no", stored.Stun)
+		assert.Equal(t, map[string]int{peerHost.ID.String(): 15}, stored.PeerKeepalives)
+	})
+	t.Run("PeerKeepalive", func(t *testing.T) {
+		nodes, err := GetAllNodes()
+		assert.Nil(t, err)
+		update, err := GetPeerUpdateForHost("tunenet", host, nodes, nil, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(update.Peers))
+		assert.Equal(t, peerHost.PublicKey, update.Peers[0].PublicKey)
+		assert.Equal(t, 15*time.Second, *update.Peers[0].PersistentKeepaliveInterval)
+		// the peer keeps the keepalive of the host's node
+		update, err = GetPeerUpdateForHost("tunenet", peerHost, nodes, nil, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(update.Peers))
+		assert.Equal(t, 20*time.Second, *update.Peers[0].PersistentKeepaliveInterval)
+	})
+	t.Run("LeftOutKept", func(t *testing.T) {
+		assert.Nil(t, TuneHost(host, &models.HostTuning{}))
+		stored, _ := GetHost(host.ID.String())
+		assert.Equal(t, 51900, stored.ListenPort)
+		assert.Equal(t, "no", stored.Stun)
+		assert.Equal(t, 1, len(stored.PeerKeepalives))
+		assert.Equal(t, models.HostTuning{ListenPort: 51900, Stun: "no", PeerKeepalives: stored.PeerKeepalives}, GetHostTuning(stored))
+	})
+	t.Run("Reset", func(t *testing.T) {
+		assert.Nil(t, TuneHost(host, &models.HostTuning{Stun: "default", PeerKeepalives: map[string]int{}}))
+		stored, _ := GetHost(host.ID.String())
+		assert.Empty(t, stored.Stun)
+		assert.Nil(t, stored.PeerKeepalives)
+		assert.Equal(t, "default", GetHostTuning(stored).Stun)
+	})
+}
+
+// tuningHost - stores a host with a node in network
+func tuningHost(t *testing.T, network string) *models.Host {
+	key, err := wgtypes.GeneratePrivateKey()
+	assert.Nil(t, err)
+	host := models.Host{ID: uuid.New(), ListenPort: 51821, PublicKey: key.PublicKey()}
+	node := models.Node{CommonNode: models.CommonNode{ID: uuid.New(), HostID: host.ID, Network: network, Connected: true, PersistentKeepalive: 20 * time.Second}}
+	host.Nodes = []string{node.ID.String()}
+	assert.Nil(t, UpsertNode(&node))
+	assert.Nil(t, UpsertHost(&host))
+	t.Cleanup(func() {
+		database.DeleteRecord(database.NODES_TABLE_NAME, node.ID.String())
+		database.DeleteRecord(database.HOSTS_TABLE_NAME, host.ID.String())
+	})
+	return &host
+}
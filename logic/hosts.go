@@ -10,19 +10,25 @@ import (
 	"sort"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/devilcove/httpclient"
+	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"github.com/gravitl/netmaker/database"
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/servercfg"
 	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/exp/slog"
 )
 
 var (
-	hostCacheMutex = &sync.RWMutex{}
-	hostsCacheMap  = make(map[string]models.Host)
+	hostCacheMutex   = &sync.RWMutex{}
+	hostsCacheMap    = make(map[string]models.Host)
+	hostsCacheLoaded bool
+	// hostsCacheVersion - counts the changes made to the cache, see loadHostsIntoCache
+	hostsCacheVersion int64
+	hostCacheStats    cacheStats
 )
 
 var (
@@ -32,19 +38,30 @@ var (
 	ErrInvalidHostID error = errors.New("invalid host id")
 )
 
+// getHostsFromCache - returns all hosts, if the cache holds all of them
 func getHostsFromCache() (hosts []models.Host) {
 	hostCacheMutex.RLock()
-	for _, host := range hostsCacheMap {
-		hosts = append(hosts, host)
+	if hostsCacheLoaded {
+		for _, host := range hostsCacheMap {
+			hosts = append(hosts, host)
+		}
 	}
 	hostCacheMutex.RUnlock()
+	hostCacheStats.record(len(hosts) != 0)
 	return
 }
 
+// getHostsMapFromCache - returns a copy of all hosts by id, if the cache holds all of them
 func getHostsMapFromCache() (hostsMap map[string]models.Host) {
 	hostCacheMutex.RLock()
-	hostsMap = hostsCacheMap
+	if hostsCacheLoaded {
+		hostsMap = make(map[string]models.Host, len(hostsCacheMap))
+		for id, host := range hostsCacheMap {
+			hostsMap[id] = host
+		}
+	}
 	hostCacheMutex.RUnlock()
+	hostCacheStats.record(len(hostsMap) != 0)
 	return
 }
 
@@ -52,23 +69,45 @@ func getHostFromCache(hostID string) (host models.Host, ok bool) {
 	hostCacheMutex.RLock()
 	host, ok = hostsCacheMap[hostID]
 	hostCacheMutex.RUnlock()
+	hostCacheStats.record(ok)
 	return
 }
 
 func storeHostInCache(h models.Host) {
 	hostCacheMutex.Lock()
 	hostsCacheMap[h.ID.String()] = h
+	hostsCacheVersion++
 	hostCacheMutex.Unlock()
 }
 
 func deleteHostFromCache(hostID string) {
 	hostCacheMutex.Lock()
 	delete(hostsCacheMap, hostID)
+	hostsCacheVersion++
+	hostCacheMutex.Unlock()
+}
+
+func getHostCacheVersion() int64 {
+	hostCacheMutex.RLock()
+	defer hostCacheMutex.RUnlock()
+	return hostsCacheVersion
+}
+
+// loadHostsIntoCache - fills the cache with the hosts read at version, unless a write changed the cache since
+func loadHostsIntoCache(hMap map[string]models.Host, version int64) {
+	hostCacheMutex.Lock()
+	if hostsCacheVersion == version {
+		hostsCacheMap = hMap
+		hostsCacheLoaded = true
+	}
 	hostCacheMutex.Unlock()
 }
-func loadHostsIntoCache(hMap map[string]models.Host) {
+
+func clearHostCache() {
 	hostCacheMutex.Lock()
-	hostsCacheMap = hMap
+	hostsCacheMap = make(map[string]models.Host)
+	hostsCacheLoaded = false
+	hostsCacheVersion++
 	hostCacheMutex.Unlock()
 }
 
@@ -84,12 +123,13 @@ func GetAllHosts() ([]models.Host, error) {
 	if len(currHosts) != 0 {
 		return currHosts, nil
 	}
+	version := getHostCacheVersion()
 	records, err := database.FetchRecords(database.HOSTS_TABLE_NAME)
 	if err != nil && !database.IsEmptyRecord(err) {
 		return nil, err
 	}
 	currHostsMap := make(map[string]models.Host)
-	defer loadHostsIntoCache(currHostsMap)
+	defer loadHostsIntoCache(currHostsMap, version)
 	for k := range records {
 		var h models.Host
 		err = json.Unmarshal([]byte(records[k]), &h)
@@ -119,12 +159,13 @@ func GetHostsMap() (map[string]models.Host, error) {
 	if len(hostsMap) != 0 {
 		return hostsMap, nil
 	}
+	version := getHostCacheVersion()
 	records, err := database.FetchRecords(database.HOSTS_TABLE_NAME)
 	if err != nil && !database.IsEmptyRecord(err) {
 		return nil, err
 	}
 	currHostMap := make(map[string]models.Host)
-	defer loadHostsIntoCache(currHostMap)
+	defer loadHostsIntoCache(currHostMap, version)
 	for k := range records {
 		var h models.Host
 		err = json.Unmarshal([]byte(records[k]), &h)
@@ -158,6 +199,14 @@ func GetHost(hostid string) (*models.Host, error) {
 
 // CreateHost - creates a host if not exist
 func CreateHost(h *models.Host) error {
+	if err := prepareNewHost(h); err != nil {
+		return err
+	}
+	return UpsertHost(h)
+}
+
+// prepareNewHost - checks a new host can be created and sets its defaults, without saving it
+func prepareNewHost(h *models.Host) error {
 	hosts, hErr := GetAllHosts()
 	clients, cErr := GetAllExtClients()
 	if (hErr != nil && !database.IsEmptyRecord(hErr)) ||
@@ -172,7 +221,7 @@ func CreateHost(h *models.Host) error {
 	if servercfg.IsUsingTurn() {
 		err = RegisterHostWithTurn(h.ID.String(), h.HostPass)
 		if err != nil {
-			logger.Log(0, "failed to register host with turn server: ", err.Error())
+			slog.Error("failed to register host with turn server", "error", err)
 		}
 	}
 
@@ -183,8 +232,11 @@ func CreateHost(h *models.Host) error {
 	}
 	h.HostPass = string(hash)
 	h.AutoUpdate = servercfg.AutoUpdateEnabled()
+	if h.MTU == 0 {
+		h.MTU = servercfg.GetDefaultMTU()
+	}
 	checkForZombieHosts(h)
-	return UpsertHost(h)
+	return nil
 }
 
 // UpdateHost - updates host data by field
@@ -199,6 +251,8 @@ func UpdateHost(newHost, currentHost *models.Host) {
 	newHost.Nodes = currentHost.Nodes
 	newHost.PublicKey = currentHost.PublicKey
 	newHost.TrafficKeyPublic = currentHost.TrafficKeyPublic
+	newHost.EnrolledWith = currentHost.EnrolledWith
+	newHost.SignedUpdates = currentHost.SignedUpdates
 
 	// changeable fields
 	if len(newHost.Version) == 0 {
@@ -219,6 +273,57 @@ func UpdateHost(newHost, currentHost *models.Host) {
 
 }
 
+// GetHostTuning - gets the WireGuard settings of a host that can be adjusted from the server
+func GetHostTuning(h *models.Host) models.HostTuning {
+	stun := h.Stun
+	if stun == "" {
+		stun = "default"
+	}
+	return models.HostTuning{
+		ListenPort:     h.ListenPort,
+		Stun:           stun,
+		PeerKeepalives: h.PeerKeepalives,
+	}
+}
+
+// ValidateHostTuning - checks the settings of tuning can be applied to a host
+func ValidateHostTuning(h *models.Host, tuning *models.HostTuning) error {
+	if err := validator.New().Struct(tuning); err != nil {
+		return err
+	}
+	for peerHostID := range tuning.PeerKeepalives {
+		peerHost, err := GetHost(peerHostID)
+		if err != nil || !HostsArePeers(h, peerHost) {
+			return fmt.Errorf("%w: %s", ErrNotAPeer, peerHostID)
+		}
+	}
+	return nil
+}
+
+// TuneHost - applies the WireGuard settings that are set in tuning to a host and saves it
+func TuneHost(h *models.Host, tuning *models.HostTuning) error {
+	if err := ValidateHostTuning(h, tuning); err != nil {
+		return err
+	}
+	if tuning.ListenPort != 0 {
+		h.ListenPort = tuning.ListenPort
+	}
+	switch tuning.Stun {
+	case "":
+	case "default":
+		h.Stun = ""
+	default:
+		h.Stun = tuning.Stun
+	}
+	if tuning.PeerKeepalives != nil {
+		h.PeerKeepalives = tuning.PeerKeepalives
+		if len(h.PeerKeepalives) == 0 {
+			h.PeerKeepalives = nil
+		}
+	}
+	return UpsertHost(h)
+}
+
 // UpdateHostFromClient - used for updating host on server with update recieved from client
 func UpdateHostFromClient(newHost, currHost *models.Host) (sendPeerUpdate bool) {
 
@@ -238,10 +343,21 @@ func UpdateHostFromClient(newHost, currHost *models.Host) (sendPeerUpdate bool)
 		currHost.EndpointIP = newHost.EndpointIP
 		sendPeerUpdate = true
 	}
+	if newHost.Interfaces != nil && IfacesChanged(newHost.Interfaces, currHost.Interfaces) {
+		currHost.Interfaces = newHost.Interfaces
+		sendPeerUpdate = true
+	}
+	// hosts that don't register endpoints keep the ones set through the API
+	if newHost.Endpoints != nil && ValidateHostEndpoints(newHost.Endpoints) == nil &&
+		HostEndpointsChanged(newHost.Endpoints, currHost.Endpoints) {
+		currHost.Endpoints = newHost.Endpoints
+		sendPeerUpdate = true
+	}
 	currHost.DaemonInstalled = newHost.DaemonInstalled
 	currHost.Debug = newHost.Debug
 	currHost.Verbosity = newHost.Verbosity
 	currHost.Version = newHost.Version
+	currHost.SignedUpdates = newHost.SignedUpdates
 	if newHost.Name != "" {
 		currHost.Name = newHost.Name
 	}
@@ -255,6 +371,11 @@ func UpdateHostFromClient(newHost, currHost *models.Host) (sendPeerUpdate bool)
 
 // UpsertHost - upserts into DB a given host model, does not check for existence*
 func UpsertHost(h *models.Host) error {
+	revisionMutex.Lock()
+	defer revisionMutex.Unlock()
+	if err := stampHostRevision(h); err != nil {
+		return err
+	}
 	data, err := json.Marshal(h)
 	if err != nil {
 		return err
@@ -287,8 +408,19 @@ func RemoveHost(h *models.Host, forceDelete bool) error {
 	if err != nil {
 		return err
 	}
+	RevokeHostKey(h)
+	if err := RevokeHostCertificates(h.ID.String(), "host removed"); err != nil {
+		slog.Error("failed to revoke certificates of host", "h_id", h.ID.String(), "error", err)
+	}
 
 	deleteHostFromCache(h.ID.String())
+	deleteEndpointOverrides(h.ID.String())
+	if err := deleteHostResources(h.ID.String()); err != nil {
+		slog.Error("unable to remove resource history from DB for host", "h_id", h.ID.String(), "error", err)
+	}
+	if err := database.DeleteRecord(database.HOST_DRIFT_TABLE_NAME, h.ID.String()); err != nil && !database.IsEmptyRecord(err) {
+		slog.Error("unable to remove config drift from DB for host", "h_id", h.ID.String(), "error", err)
+	}
 	return nil
 }
 
@@ -297,42 +429,82 @@ func RemoveHostByID(hostID string) error {
 	if servercfg.IsUsingTurn() {
 		DeRegisterHostWithTurn(hostID)
 	}
+	host, hostErr := GetHost(hostID)
 
 	err := database.DeleteRecord(database.HOSTS_TABLE_NAME, hostID)
 	if err != nil {
 		return err
 	}
+	if hostErr == nil {
+		RevokeHostKey(host)
+	}
+	if err := RevokeHostCertificates(hostID, "host removed"); err != nil {
+		slog.Error("failed to revoke certificates of host", "host_id", hostID, "error", err)
+	}
 	deleteHostFromCache(hostID)
+	deleteEndpointOverrides(hostID)
+	if err := deleteHostResources(hostID); err != nil {
+		slog.Error("unable to remove resource history from DB for host", "host_id", hostID, "error", err)
+	}
+	if err := database.DeleteRecord(database.HOST_DRIFT_TABLE_NAME, hostID); err != nil && !database.IsEmptyRecord(err) {
+		slog.Error("unable to remove config drift from DB for host", "host_id", hostID, "error", err)
+	}
 	return nil
 }
 
 // UpdateHostNetwork - adds/deletes host from a network
 func UpdateHostNetwork(h *models.Host, network string, add bool) (*models.Node, error) {
+	if add {
+		return AddHostToNetwork(h, network, nil)
+	}
 	for _, nodeID := range h.Nodes {
 		node, err := GetNodeByID(nodeID)
 		if err != nil || node.PendingDelete {
 			continue
 		}
 		if node.Network == network {
-			if !add {
-				return &node, nil
-			} else {
-				return nil, errors.New("host already part of network " + network)
-			}
+			return &node, nil
 		}
 	}
-	if !add {
-		return nil, errors.New("host not part of the network " + network)
-	} else {
-		newNode := models.Node{}
-		newNode.Server = servercfg.GetServer()
-		newNode.Network = network
-		newNode.HostID = h.ID
-		if err := AssociateNodeToHost(&newNode, h); err != nil {
-			return nil, err
+	return nil, errors.New("host not part of the network " + network)
+}
+
+// AddHostToNetwork - adds a host to a network, the node added takes the tags and
+// ephemeral class of the enrollment key the host registered with if any
+func AddHostToNetwork(h *models.Host, network string, key *models.EnrollmentKey) (*models.Node, error) {
+	for _, nodeID := range h.Nodes {
+		node, err := GetNodeByID(nodeID)
+		if err != nil || node.PendingDelete {
+			continue
+		}
+		if node.Network == network {
+			return nil, errors.New("host already part of network " + network)
+		}
+	}
+	newNode := models.Node{}
+	newNode.Server = servercfg.GetServer()
+	newNode.Network = network
+	newNode.HostID = h.ID
+	if key != nil {
+		// tagged as it's created so the network's naming policy can use the tags
+		if len(key.Tags) > 0 {
+			newNode.Tags = key.Tags
+		}
+		if key.Ephemeral {
+			newNode.Ephemeral = true
+			newNode.EphemeralTTL = time.Duration(key.EphemeralTTL) * time.Second
+		}
+	}
+	if err := AssociateNodeToHost(&newNode, h); err != nil {
+		return nil, err
+	}
+	if len(newNode.Tags) > 0 {
+		// the peers of the node are sent once it joins, with the ACLs of its zones
+		if _, err := ApplyNetworkZones(network, false); err != nil {
+			slog.Error("failed to compile zones of network for new node", "network", network, "new_node_id", newNode.ID.String(), "error", err)
 		}
-		return &newNode, nil
 	}
+	return &newNode, nil
 }
 
 // AssociateNodeToHost - associates and creates a node with a given host
@@ -342,17 +514,42 @@ func AssociateNodeToHost(n *models.Node, h *models.Host) error {
 		return ErrInvalidHostID
 	}
 	n.HostID = h.ID
-	err := createNode(n)
+	currentHost, err := GetHost(h.ID.String())
 	if err != nil {
 		return err
 	}
-	currentHost, err := GetHost(h.ID.String())
+	if err = checkHostTenant(currentHost, n.Network); err != nil {
+		return err
+	}
+	if err = RunPreNodeJoinHooks(currentHost, n); err != nil {
+		return err
+	}
+	unlock, err := Lock(addressLockName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	tx := database.BeginTx()
+	finish, err := createNodeInTx(tx, n)
 	if err != nil {
 		return err
 	}
 	h.HostPass = currentHost.HostPass
 	h.Nodes = append(currentHost.Nodes, n.ID.String())
-	return UpsertHost(h)
+	h.Revision = currentHost.Revision + 1
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	// the node and its host's node list are written together so neither is left dangling
+	if err = tx.Insert(h.ID.String(), string(data), database.HOSTS_TABLE_NAME); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	storeHostInCache(*h)
+	return finish()
 }
 
 // DissasociateNodeFromHost - deletes a node and removes from host nodes
@@ -390,11 +587,27 @@ func DissasociateNodeFromHost(n *models.Node, h *models.Host) error {
 			}
 		}
 	}()
-	if err := deleteNodeByID(n); err != nil {
+	if currentHost, err := GetHost(h.ID.String()); err == nil {
+		h.Revision = currentHost.Revision + 1
+	}
+	data, err := json.Marshal(h)
+	if err != nil {
 		return err
 	}
-
-	return UpsertHost(h)
+	// the node and its host's node list are written together so neither is left dangling
+	tx := database.BeginTx()
+	if err = deleteNodeInTx(tx, n); err != nil {
+		return err
+	}
+	if err = tx.Insert(h.ID.String(), string(data), database.HOSTS_TABLE_NAME); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	storeHostInCache(*h)
+	cleanupDeletedNode(n)
+	return nil
 }
 
 // DisassociateAllNodesFromHost - deletes all nodes of the host
@@ -406,14 +619,14 @@ func DisassociateAllNodesFromHost(hostID string) error {
 	for _, nodeID := range host.Nodes {
 		node, err := GetNodeByID(nodeID)
 		if err != nil {
-			logger.Log(0, "failed to get host node", err.Error())
+			slog.Error("failed to get host node", "error", err)
 			continue
 		}
 		if err := DeleteNode(&node, true); err != nil {
-			logger.Log(0, "failed to delete node", node.ID.String(), err.Error())
+			slog.Error("failed to delete node", "node_id", node.ID.String(), "error", err)
 			continue
 		}
-		logger.Log(3, "deleted node", node.ID.String(), "of host", host.ID.String())
+		slog.Debug("deleted node of host", "node_id", node.ID.String(), "host_id", host.ID.String())
 	}
 	host.Nodes = []string{}
 	return UpsertHost(host)
@@ -451,27 +664,38 @@ func GetHostNetworks(hostID string) []string {
 	return nets
 }
 
+// GetHostNamedNetworks - fetches the networks where a host's nodes go by the host's name
+func GetHostNamedNetworks(hostID string) []string {
+	currHost, err := GetHost(hostID)
+	if err != nil {
+		return nil
+	}
+	nets := []string{}
+	for i := range currHost.Nodes {
+		n, err := GetNodeByID(currHost.Nodes[i])
+		if err != nil {
+			return nil
+		}
+		if n.Name == "" {
+			nets = append(nets, n.Network)
+		}
+	}
+	return nets
+}
+
 // GetRelatedHosts - fetches related hosts of a given host
 func GetRelatedHosts(hostID string) []models.Host {
 	relatedHosts := []models.Host{}
-	networks := GetHostNetworks(hostID)
-	networkMap := make(map[string]struct{})
-	for _, network := range networks {
-		networkMap[network] = struct{}{}
+	host, err := GetHost(hostID)
+	if err != nil {
+		return relatedHosts
 	}
-	hosts, err := GetAllHosts()
-	if err == nil {
-		for _, host := range hosts {
-			if host.ID.String() == hostID {
-				continue
-			}
-			networks := GetHostNetworks(host.ID.String())
-			for _, network := range networks {
-				if _, ok := networkMap[network]; ok {
-					relatedHosts = append(relatedHosts, host)
-					break
-				}
-			}
+	for _, id := range GetHostAffectedHosts(host) {
+		if id == hostID {
+			continue
+		}
+		if related, err := GetHost(id); err == nil {
+			relatedHosts = append(relatedHosts, *related)
 		}
 	}
 	return relatedHosts
@@ -550,7 +774,7 @@ func RegisterHostWithTurn(hostID, hostPass string) error {
 	_, errData, err := api.GetJSON(models.SuccessResponse{}, models.ErrorResponse{})
 	if err != nil {
 		if errors.Is(err, httpclient.ErrStatus) {
-			logger.Log(1, "error server status", strconv.Itoa(errData.Code), errData.Message)
+			slog.Error("error server status", "code", strconv.Itoa(errData.Code), "message", errData.Message)
 		}
 		return err
 	}
@@ -571,7 +795,7 @@ func DeRegisterHostWithTurn(hostID string) error {
 	_, errData, err := api.GetJSON(models.SuccessResponse{}, models.ErrorResponse{})
 	if err != nil {
 		if errors.Is(err, httpclient.ErrStatus) {
-			logger.Log(1, "error server status", strconv.Itoa(errData.Code), errData.Message)
+			slog.Error("error server status", "code", strconv.Itoa(errData.Code), "message", errData.Message)
 		}
 		return err
 	}
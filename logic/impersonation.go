@@ -0,0 +1,42 @@
+package logic
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// ActAsHeader - names the user a superadmin's request is served as
+	ActAsHeader = "X-Act-As"
+	// ImpersonatorHeader - set on requests served as another user, to the superadmin who made them
+	ImpersonatorHeader = "impersonator"
+)
+
+// ErrImpersonation - a request asked to act as another user without being allowed to
+var ErrImpersonation = errors.New("only superadmins can act as another user, and only for read-only requests")
+
+// == private ==
+
+// impersonate - the user a request asking to act as another is served as; only superadmins may, and only
+// for read-only requests, so support can see what a user sees without changing anything in their name
+func impersonate(r *http.Request, username string) (models.User, error) {
+	target := r.Header.Get(ActAsHeader)
+	if username != master_uname {
+		impersonator, err := GetUser(username)
+		if err != nil || !IsSuperAdmin(impersonator) {
+			return models.User{}, ErrImpersonation
+		}
+	}
+	if !isReadOnlyRequest(r) {
+		return models.User{}, ErrImpersonation
+	}
+	user, err := GetUser(target)
+	if err != nil {
+		return models.User{}, errors.New("unknown user " + target)
+	}
+	slog.Warn("superadmin acting as user", "impersonator", username, "user", target, "method", r.Method, "path", r.URL.Path)
+	return *user, nil
+}
@@ -0,0 +1,37 @@
+package logic
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImpersonate(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	assert.Nil(t, CreateUser(&models.User{UserName: "support", Password: "password", IsAdmin: true}))
+	defer DeleteUser("support")
+	assert.Nil(t, CreateUser(&models.User{UserName: "customer", Password: "password"}))
+	defer DeleteUser("customer")
+
+	r := httptest.NewRequest(http.MethodGet, "/api/extclients", nil)
+	r.Header.Set(ActAsHeader, "customer")
+	user, err := impersonate(r, "support")
+	assert.Nil(t, err)
+	assert.Equal(t, "customer", user.UserName)
+	_, err = impersonate(r, "customer")
+	assert.ErrorIs(t, err, ErrImpersonation, "only superadmins act as others")
+
+	r.Header.Set(ActAsHeader, "nobody")
+	_, err = impersonate(r, "support")
+	assert.NotNil(t, err)
+
+	r = httptest.NewRequest(http.MethodDelete, "/api/extclients/net/client", nil)
+	r.Header.Set(ActAsHeader, "customer")
+	_, err = impersonate(r, "support")
+	assert.ErrorIs(t, err, ErrImpersonation, "acting as others is read-only")
+}
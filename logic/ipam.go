@@ -0,0 +1,340 @@
+package logic
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// ipamWarningPercent - the utilization of a range that raises a warning
+	ipamWarningPercent = 80
+	// ipamCriticalPercent - the utilization of a range that raises a critical warning
+	ipamCriticalPercent = 95
+	// addressReservationTTL - how long an address given out stays reserved unless released once its holder is written,
+	// in case the server giving it out goes away meanwhile
+	addressReservationTTL = 5 * time.Minute
+	// addressReservationPrefix - the leases reserving addresses are named ip:<network>/<address>
+	addressReservationPrefix = "ip:"
+)
+
+var (
+	addressReservationsMutex sync.Mutex
+	// addressReservations - the owners of the address reservations this server holds, by lease name
+	addressReservations = map[string]string{}
+)
+
+// GetNetworkIPAM - the used and free addresses of a network's ranges, the largest free block of each, how many
+// addresses nodes, ext clients and static peers hold and warnings for the ranges nearing exhaustion
+func GetNetworkIPAM(netID string) (models.NetworkIPAM, error) {
+	ipam := models.NetworkIPAM{Network: netID, Warnings: []models.IPAMRangeWarning{}}
+	network, err := GetNetwork(netID)
+	if err != nil {
+		return ipam, err
+	}
+	nodes, err := GetNetworkNodes(netID)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return ipam, err
+	}
+	clients, err := GetNetworkExtClients(netID)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return ipam, err
+	}
+	peers, err := GetNetworkStaticPeers(netID)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return ipam, err
+	}
+	reservations4, reservations6 := map[string][]string{}, map[string][]string{}
+	for _, node := range nodes {
+		if node.Address.IP != nil {
+			reservations4[models.IPAMReservationNodes] = append(reservations4[models.IPAMReservationNodes], node.Address.IP.String())
+		}
+		if node.Address6.IP != nil {
+			reservations6[models.IPAMReservationNodes] = append(reservations6[models.IPAMReservationNodes], node.Address6.IP.String())
+		}
+	}
+	for _, client := range clients {
+		reservations4[models.IPAMReservationExtClients] = append(reservations4[models.IPAMReservationExtClients], client.Address)
+		reservations6[models.IPAMReservationExtClients] = append(reservations6[models.IPAMReservationExtClients], client.Address6)
+	}
+	for _, peer := range peers {
+		reservations4[models.IPAMReservationStaticPeers] = append(reservations4[models.IPAMReservationStaticPeers], peer.Address)
+		reservations6[models.IPAMReservationStaticPeers] = append(reservations6[models.IPAMReservationStaticPeers], peer.Address6)
+	}
+	if network.IsIPv4 != "no" && network.AddressRange != "" {
+		if ipam.IPv4, err = rangeUtilization(network.AddressRange, reservations4); err != nil {
+			return ipam, err
+		}
+		ipam.Warnings = appendIPAMWarning(ipam.Warnings, "ipv4", ipam.IPv4)
+	}
+	if network.IsIPv6 == "yes" && network.AddressRange6 != "" {
+		if ipam.IPv6, err = rangeUtilization(network.AddressRange6, reservations6); err != nil {
+			return ipam, err
+		}
+		ipam.Warnings = appendIPAMWarning(ipam.Warnings, "ipv6", ipam.IPv6)
+	}
+	return ipam, nil
+}
+
+// PurgeAddressReservations - removes the expired reservations of addresses given out, returning how many
+func PurgeAddressReservations() (int, error) {
+	leases, err := database.GetLeases()
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	now := time.Now().UnixMilli()
+	purged := 0
+	for name, lease := range leases {
+		if !strings.HasPrefix(name, addressReservationPrefix) || lease.Expires >= now {
+			continue
+		}
+		// each reservation has an owner of its own that never renews it, so it's still the one read
+		if err := database.ReleaseLease(name, lease.Owner); err != nil {
+			return purged, err
+		}
+		addressReservationsMutex.Lock()
+		if addressReservations[name] == lease.Owner {
+			delete(addressReservations, name)
+		}
+		addressReservationsMutex.Unlock()
+		purged++
+	}
+	return purged, nil
+}
+
+// == private ==
+
+// reserveAddress - claims an address of a network for the holder about to be created with it, false when a
+// concurrent caller on any server claimed it first; the caller releases it once the holder is written or given up
+func reserveAddress(netID, address string) (bool, error) {
+	name := addressReservationPrefix + netID + "/" + address
+	owner := uuid.NewString()
+	reserved, err := database.AcquireLease(name, owner, addressReservationTTL)
+	if reserved {
+		addressReservationsMutex.Lock()
+		addressReservations[name] = owner
+		addressReservationsMutex.Unlock()
+	}
+	return reserved, err
+}
+
+// releaseAddress - drops the reservation of an address this server gave out once the record of its holder is
+// visible to IsIPUnique, until then the reservation is left to expire so the address isn't given out twice
+func releaseAddress(netID, address string) {
+	if !isAddressTaken(netID, address) {
+		slog.Debug("address not in use yet, leaving its reservation to expire", "network", netID, "address", address)
+		return
+	}
+	dropAddress(netID, address)
+}
+
+// dropAddress - drops the reservation of an address this server gave out right away, for an address
+// that wasn't given to a holder after all
+func dropAddress(netID, address string) {
+	name := addressReservationPrefix + netID + "/" + address
+	addressReservationsMutex.Lock()
+	owner, ok := addressReservations[name]
+	delete(addressReservations, name)
+	addressReservationsMutex.Unlock()
+	if !ok {
+		return
+	}
+	if err := database.ReleaseLease(name, owner); err != nil {
+		slog.Warn("failed to release address reservation", "network", netID, "address", address, "error", err)
+	}
+}
+
+// isAddressTaken - whether a node, ext client or static peer of the network holds the address
+func isAddressTaken(netID, address string) bool {
+	isIpv6 := net.ParseIP(address).To4() == nil
+	return !IsIPUnique(netID, address, database.NODES_TABLE_NAME, isIpv6) ||
+		!IsIPUnique(netID, address, database.EXT_CLIENT_TABLE_NAME, isIpv6) ||
+		!IsIPUnique(netID, address, database.STATIC_PEERS_TABLE_NAME, isIpv6)
+}
+
+// claimAddress - reserves an address chosen for a holder, then checks no holder has it meanwhile;
+// failing to check counts as taken
+func claimAddress(netID, address string) bool {
+	reserved, err := reserveAddress(netID, address)
+	if err != nil {
+		slog.Error("failed to reserve address", "network", netID, "address", address, "error", err)
+		return false
+	}
+	if !reserved {
+		return false
+	}
+	if isAddressTaken(netID, address) {
+		dropAddress(netID, address)
+		return false
+	}
+	return true
+}
+
+// checkIPAMUtilization - notifies the alerting channels of the network ranges nearing exhaustion, run by the daily hooks
+func checkIPAMUtilization() error {
+	networks, err := GetNetworks()
+	if err != nil && !database.IsEmptyRecord(err) {
+		return err
+	}
+	for _, network := range networks {
+		ipam, err := GetNetworkIPAM(network.NetID)
+		if err != nil {
+			slog.Error("failed to check address utilization", "network", network.NetID, "error", err)
+			continue
+		}
+		for _, warning := range ipam.Warnings {
+			go Notify(models.Notification{
+				Event:    models.NotificationEventIPAM,
+				Severity: models.NotificationAlert,
+				Title:    fmt.Sprintf("%s addresses of network %s nearing exhaustion", warning.Family, network.NetID),
+				Text:     warning.Message,
+				Network:  network.NetID,
+				Fields: []models.NotificationField{
+					{Name: "level", Value: warning.Level},
+					{Name: "free addresses", Value: fmt.Sprint(warning.Free)},
+				},
+			}, nil)
+		}
+	}
+	return nil
+}
+
+// appendIPAMWarning - adds a warning when a range is used past the warning or critical percentage
+func appendIPAMWarning(warnings []models.IPAMRangeWarning, family string, usage *models.IPAMRange) []models.IPAMRangeWarning {
+	level := ""
+	switch {
+	case usage.UsedPercent >= ipamCriticalPercent:
+		level = models.IPAMCritical
+	case usage.UsedPercent >= ipamWarningPercent:
+		level = models.IPAMWarning
+	default:
+		return warnings
+	}
+	return append(warnings, models.IPAMRangeWarning{
+		Family:      family,
+		Level:       level,
+		UsedPercent: usage.UsedPercent,
+		Free:        usage.Free,
+		Message:     fmt.Sprintf("%.1f%% of %s is used, %d addresses are left", usage.UsedPercent, usage.Range, usage.Free),
+	})
+}
+
+// rangeUtilization - the allocation of the usable addresses of a range, the first and last addresses aren't given out
+// unless the range is too small to spare them; addresses outside the range, eg. from before it changed, are ignored
+func rangeUtilization(cidr string, reservations map[string][]string) (*models.IPAMRange, error) {
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	ones, bits := ipnet.Mask.Size()
+	first := new(big.Int).SetBytes(ipnet.IP.To16())
+	if ipnet.IP.To4() != nil {
+		first.SetBytes(ipnet.IP.To4())
+	}
+	last := new(big.Int).Add(first, new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(bits-ones)), big.NewInt(1)))
+	if bits-ones > 1 {
+		first.Add(first, big.NewInt(1))
+		last.Sub(last, big.NewInt(1))
+	}
+	size := new(big.Int).Add(new(big.Int).Sub(last, first), big.NewInt(1))
+	usage := &models.IPAMRange{Range: ipnet.String(), Size: saturateUint64(size), Reservations: []models.IPAMReservation{}}
+
+	seen := map[string]bool{}
+	used := []*big.Int{}
+	kinds := []string{}
+	for kind := range reservations {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		reservation := models.IPAMReservation{Kind: kind}
+		reserved := map[string]bool{}
+		for _, address := range reservations[kind] {
+			ip := net.ParseIP(address)
+			if ip == nil || !ipnet.Contains(ip) {
+				continue
+			}
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+			}
+			value := new(big.Int).SetBytes(ip)
+			if value.Cmp(first) < 0 || value.Cmp(last) > 0 || reserved[value.String()] {
+				continue
+			}
+			reserved[value.String()] = true
+			reservation.Used++
+			// an address given to peers of two kinds is only used once
+			if !seen[value.String()] {
+				seen[value.String()] = true
+				used = append(used, value)
+			}
+		}
+		reservation.UsedPercent = percentOf(new(big.Int).SetUint64(reservation.Used), size)
+		usage.Reservations = append(usage.Reservations, reservation)
+	}
+	usage.Used = uint64(len(used))
+	usage.Free = saturateUint64(new(big.Int).Sub(size, big.NewInt(int64(len(used)))))
+	usage.UsedPercent = percentOf(big.NewInt(int64(len(used))), size)
+
+	// the largest free block lies between two used addresses, or before the first or after the last of them
+	sort.Slice(used, func(i, j int) bool {
+		return used[i].Cmp(used[j]) < 0
+	})
+	largest := new(big.Int)
+	var blockStart *big.Int
+	previous := new(big.Int).Sub(first, big.NewInt(1))
+	for _, value := range append(used, new(big.Int).Add(last, big.NewInt(1))) {
+		gap := new(big.Int).Sub(value, previous)
+		gap.Sub(gap, big.NewInt(1))
+		if gap.Cmp(largest) > 0 {
+			largest = gap
+			blockStart = new(big.Int).Add(previous, big.NewInt(1))
+		}
+		previous = value
+	}
+	if blockStart != nil {
+		blockEnd := new(big.Int).Add(blockStart, new(big.Int).Sub(largest, big.NewInt(1)))
+		usage.LargestFreeBlock = models.IPAMBlock{
+			Start: bigIntToIP(blockStart, bits).String(),
+			End:   bigIntToIP(blockEnd, bits).String(),
+			Size:  saturateUint64(largest),
+		}
+	}
+	return usage, nil
+}
+
+// percentOf - part as a percentage of whole
+func percentOf(part, whole *big.Int) float64 {
+	if whole.Sign() == 0 {
+		return 0
+	}
+	percent, _ := new(big.Float).Quo(new(big.Float).SetInt(part), new(big.Float).SetInt(whole)).Float64()
+	return math.Round(percent*10000) / 100
+}
+
+func saturateUint64(value *big.Int) uint64 {
+	if !value.IsUint64() {
+		return math.MaxUint64
+	}
+	return value.Uint64()
+}
+
+// bigIntToIP - the address of an integer, bits long
+func bigIntToIP(value *big.Int, bits int) net.IP {
+	ip := make(net.IP, bits/8)
+	value.FillBytes(ip)
+	return ip
+}
@@ -0,0 +1,258 @@
+package logic
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+// addressConflictInterval - how often the addresses of every network are checked for conflicts
+const addressConflictInterval = time.Hour
+
+var (
+	// knownAddressConflicts - the conflicts found by the last check, so only new ones are alerted on
+	knownAddressConflicts = map[string]bool{}
+	addressConflictsMutex sync.Mutex
+)
+
+// GetAddressConflicts - the duplicate addresses of a network's nodes and ext clients, and those outside its ranges
+func GetAddressConflicts(netID string) ([]models.IPAMConflict, error) {
+	network, err := GetNetwork(netID)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := GetNetworkNodes(netID)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return nil, err
+	}
+	clients, err := GetNetworkExtClients(netID)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return nil, err
+	}
+	return findAddressConflicts(&network, nodes, clients), nil
+}
+
+// RepairAddressConflicts - gives new addresses to the holders of out of range addresses and to every holder of
+// a duplicate but the first; reassigned ext clients need their config downloaded again
+func RepairAddressConflicts(netID string) ([]models.IPAMReassignment, error) {
+	conflicts, err := GetAddressConflicts(netID)
+	if err != nil {
+		return nil, err
+	}
+	network, err := GetNetwork(netID)
+	if err != nil {
+		return nil, err
+	}
+	reassignments := []models.IPAMReassignment{}
+	for _, conflict := range conflicts {
+		holders := conflict.Holders
+		if conflict.Kind == models.IPAMConflictDuplicate {
+			holders = holders[1:]
+		}
+		for _, holder := range holders {
+			reassignment := models.IPAMReassignment{Holder: holder, Family: conflict.Family, OldAddress: conflict.Address}
+			if reassignment.NewAddress, err = reassignAddress(&network, holder, conflict.Family); err != nil {
+				reassignment.Error = err.Error()
+				slog.Error("failed to reassign address", "network", netID, "holder", holder.ID, "address", conflict.Address, "error", err)
+			} else {
+				slog.Info("reassigned address", "network", netID, "holder", holder.ID, "old", conflict.Address, "new", reassignment.NewAddress)
+			}
+			reassignments = append(reassignments, reassignment)
+		}
+	}
+	if len(reassignments) > 0 {
+		if err := SetDNS(); err != nil {
+			slog.Error("failed to update dns after reassigning addresses", "network", netID, "error", err)
+		}
+	}
+	return reassignments, nil
+}
+
+// == private ==
+
+// checkAddressConflicts - looks for address conflicts, possible after migrations and restores, and alerts on the new ones
+func checkAddressConflicts() {
+	networks, err := GetNetworks()
+	if err != nil {
+		if !database.IsEmptyRecord(err) {
+			slog.Error("failed to fetch networks to check addresses", "error", err)
+		}
+		return
+	}
+	addressConflictsMutex.Lock()
+	defer addressConflictsMutex.Unlock()
+	found := map[string]bool{}
+	for _, network := range networks {
+		conflicts, err := GetAddressConflicts(network.NetID)
+		if err != nil {
+			slog.Error("failed to check addresses", "network", network.NetID, "error", err)
+			continue
+		}
+		for _, conflict := range conflicts {
+			key := network.NetID + "###" + conflict.Kind + "###" + conflict.Address
+			found[key] = true
+			if knownAddressConflicts[key] {
+				continue
+			}
+			slog.Warn("address conflict", "network", network.NetID, "kind", conflict.Kind, "address", conflict.Address, "holders", len(conflict.Holders))
+			go Notify(models.Notification{
+				Event:    models.NotificationEventIPAM,
+				Severity: models.NotificationAlert,
+				Title:    fmt.Sprintf("address conflict on network %s", network.NetID),
+				Text:     fmt.Sprintf("%s address %s (%s), held by %s", conflict.Family, conflict.Address, conflict.Kind, holderNames(conflict.Holders)),
+				Network:  network.NetID,
+			}, nil)
+		}
+	}
+	knownAddressConflicts = found
+}
+
+// findAddressConflicts - the duplicate and out of range addresses of a network's nodes and ext clients,
+// nodes are listed before ext clients so they keep a duplicate address
+func findAddressConflicts(network *models.Network, nodes []models.Node, clients []models.ExtClient) []models.IPAMConflict {
+	type held struct {
+		family  string
+		address string
+		holder  models.IPAMHolder
+	}
+	addresses := []held{}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].ID.String() < nodes[j].ID.String()
+	})
+	for _, node := range nodes {
+		holder := models.IPAMHolder{Kind: models.IPAMReservationNodes, ID: node.ID.String(), Name: node.ID.String()}
+		if host, err := GetHost(node.HostID.String()); err == nil {
+			holder.Name = host.Name
+		}
+		if node.Address.IP != nil {
+			addresses = append(addresses, held{"ipv4", node.Address.IP.String(), holder})
+		}
+		if node.Address6.IP != nil {
+			addresses = append(addresses, held{"ipv6", node.Address6.IP.String(), holder})
+		}
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].ClientID < clients[j].ClientID
+	})
+	for _, client := range clients {
+		holder := models.IPAMHolder{Kind: models.IPAMReservationExtClients, ID: client.ClientID, Name: client.ClientID}
+		if client.Address != "" {
+			addresses = append(addresses, held{"ipv4", client.Address, holder})
+		}
+		if client.Address6 != "" {
+			addresses = append(addresses, held{"ipv6", client.Address6, holder})
+		}
+	}
+	ranges := map[string]*net.IPNet{}
+	if _, cidr, err := net.ParseCIDR(network.AddressRange); err == nil {
+		ranges["ipv4"] = cidr
+	}
+	if _, cidr, err := net.ParseCIDR(network.AddressRange6); err == nil {
+		ranges["ipv6"] = cidr
+	}
+	conflicts := []models.IPAMConflict{}
+	duplicates := map[string]int{}
+	for _, a := range addresses {
+		ip := net.ParseIP(a.address)
+		if ip == nil {
+			continue
+		}
+		if cidr, ok := ranges[a.family]; ok && !cidr.Contains(ip) {
+			conflicts = append(conflicts, models.IPAMConflict{
+				Kind:    models.IPAMConflictOutOfRange,
+				Family:  a.family,
+				Address: ip.String(),
+				Holders: []models.IPAMHolder{a.holder},
+			})
+			continue
+		}
+		key := a.family + "###" + ip.String()
+		if i, ok := duplicates[key]; ok {
+			conflicts[i].Holders = append(conflicts[i].Holders, a.holder)
+			continue
+		}
+		duplicates[key] = len(conflicts)
+		conflicts = append(conflicts, models.IPAMConflict{
+			Kind:    models.IPAMConflictDuplicate,
+			Family:  a.family,
+			Address: ip.String(),
+			Holders: []models.IPAMHolder{a.holder},
+		})
+	}
+	result := []models.IPAMConflict{}
+	for _, conflict := range conflicts {
+		if conflict.Kind == models.IPAMConflictOutOfRange || len(conflict.Holders) > 1 {
+			result = append(result, conflict)
+		}
+	}
+	return result
+}
+
+// reassignAddress - gives a holder a free address of a network, returning it
+func reassignAddress(network *models.Network, holder models.IPAMHolder, family string) (string, error) {
+	var ip net.IP
+	var err error
+	addressRange := network.AddressRange
+	if family == "ipv6" {
+		addressRange = network.AddressRange6
+		ip, err = UniqueAddress6(network.NetID, holder.Kind == models.IPAMReservationExtClients)
+	} else {
+		ip, err = UniqueAddress(network.NetID, holder.Kind == models.IPAMReservationExtClients)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer releaseAddress(network.NetID, ip.String())
+	_, cidr, err := net.ParseCIDR(addressRange)
+	if err != nil {
+		return "", err
+	}
+	switch holder.Kind {
+	case models.IPAMReservationNodes:
+		node, err := GetNodeByID(holder.ID)
+		if err != nil {
+			return "", err
+		}
+		if family == "ipv6" {
+			node.Address6 = net.IPNet{IP: ip, Mask: cidr.Mask}
+		} else {
+			node.Address = net.IPNet{IP: ip, Mask: cidr.Mask}
+		}
+		node.SetLastModified()
+		if err := UpsertNode(&node); err != nil {
+			return "", err
+		}
+	default:
+		client, err := GetExtClient(holder.ID, network.NetID)
+		if err != nil {
+			return "", err
+		}
+		if family == "ipv6" {
+			client.Address6 = ip.String()
+		} else {
+			client.Address = ip.String()
+		}
+		client.LastModified = time.Now().Unix()
+		if err := SaveExtClient(&client); err != nil {
+			return "", err
+		}
+	}
+	return ip.String(), nil
+}
+
+func holderNames(holders []models.IPAMHolder) string {
+	names := ""
+	for i, holder := range holders {
+		if i > 0 {
+			names += ", "
+		}
+		names += holder.Name
+	}
+	return names
+}
@@ -0,0 +1,145 @@
+package logic
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRangeUtilization(t *testing.T) {
+	t.Run("IPv4", func(t *testing.T) {
+		usage, err := rangeUtilization("10.0.0.0/24", map[string][]string{
+			models.IPAMReservationNodes:      {"10.0.0.1", "10.0.0.2", "10.0.0.200"},
+			models.IPAMReservationExtClients: {"10.0.0.3", "192.168.0.1", ""},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(254), usage.Size, "the network and broadcast addresses aren't usable")
+		assert.Equal(t, uint64(4), usage.Used)
+		assert.Equal(t, uint64(250), usage.Free)
+		assert.Equal(t, models.IPAMBlock{Start: "10.0.0.4", End: "10.0.0.199", Size: 196}, usage.LargestFreeBlock)
+		assert.Equal(t, uint64(1), usage.Reservations[0].Used, "addresses outside the range are ignored")
+	})
+	t.Run("IPv6", func(t *testing.T) {
+		usage, err := rangeUtilization("fd00::/120", map[string][]string{models.IPAMReservationNodes: {"fd00::1"}})
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(254), usage.Size)
+		assert.Equal(t, "fd00::2", usage.LargestFreeBlock.Start)
+	})
+	t.Run("Exhausted", func(t *testing.T) {
+		usage, err := rangeUtilization("10.0.0.0/30", map[string][]string{models.IPAMReservationNodes: {"10.0.0.1", "10.0.0.2"}})
+		assert.Nil(t, err)
+		assert.Equal(t, float64(100), usage.UsedPercent)
+		assert.Equal(t, uint64(0), usage.LargestFreeBlock.Size)
+		warnings := appendIPAMWarning(nil, "ipv4", usage)
+		assert.Equal(t, models.IPAMCritical, warnings[0].Level)
+	})
+}
+
+func TestFindAddressConflicts(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	network := &models.Network{NetID: "conflicts", AddressRange: "10.0.0.0/24"}
+	nodes := []models.Node{
+		{CommonNode: models.CommonNode{ID: uuid.New(), Address: net.IPNet{IP: net.ParseIP("10.0.0.1"), Mask: net.CIDRMask(24, 32)}}},
+		{CommonNode: models.CommonNode{ID: uuid.New(), Address: net.IPNet{IP: net.ParseIP("10.1.0.1"), Mask: net.CIDRMask(24, 32)}}},
+	}
+	clients := []models.ExtClient{
+		{ClientID: "phone", Address: "10.0.0.1"},
+		{ClientID: "laptop", Address: "10.0.0.254"},
+	}
+	conflicts := findAddressConflicts(network, nodes, clients)
+	assert.Equal(t, 2, len(conflicts))
+	kinds := map[string]models.IPAMConflict{}
+	for _, conflict := range conflicts {
+		kinds[conflict.Kind] = conflict
+	}
+	duplicate := kinds[models.IPAMConflictDuplicate]
+	assert.Equal(t, "10.0.0.1", duplicate.Address)
+	assert.Equal(t, 2, len(duplicate.Holders))
+	assert.Equal(t, models.IPAMReservationNodes, duplicate.Holders[0].Kind, "nodes keep duplicate addresses")
+	assert.Equal(t, "phone", duplicate.Holders[1].ID)
+	assert.Equal(t, "10.1.0.1", kinds[models.IPAMConflictOutOfRange].Address)
+}
+
+func TestConcurrentAddressAllocation(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	// networks are created with their users, at least one has to exist
+	assert.Nil(t, CreateUser(&models.User{UserName: "ipamadmin", Password: "password", IsAdmin: true}))
+	defer DeleteUser("ipamadmin")
+	network, err := CreateNetwork(models.Network{NetID: "ipamloadtest", AddressRange: "10.205.0.0/24", AddressRange6: "fd05::/64", IsIPv6: "yes"})
+	assert.Nil(t, err)
+	defer DeleteNetwork(network.NetID)
+	// joins handled by several servers at once don't share the address lock of one
+	const joins = 100
+	var wg sync.WaitGroup
+	addresses := make(chan string, joins*2)
+	for i := 0; i < joins; i++ {
+		wg.Add(1)
+		go func(reverse bool) {
+			defer wg.Done()
+			ip, err := UniqueAddress(network.NetID, reverse)
+			assert.Nil(t, err)
+			addresses <- ip.String()
+			ip6, err := UniqueAddress6(network.NetID, reverse)
+			assert.Nil(t, err)
+			addresses <- ip6.String()
+		}(i%2 == 0)
+	}
+	wg.Wait()
+	close(addresses)
+	given := map[string]bool{}
+	for address := range addresses {
+		assert.False(t, given[address], "address given out twice: "+address)
+		given[address] = true
+	}
+	assert.Len(t, given, joins*2)
+	defer func() {
+		for address := range given {
+			dropAddress(network.NetID, address)
+		}
+	}()
+
+	t.Run("ChosenAddress", func(t *testing.T) {
+		ip, err := UniqueAddress(network.NetID, false)
+		assert.Nil(t, err)
+		assert.False(t, claimAddress(network.NetID, ip.String()), "an address given out can't be chosen")
+		releaseAddress(network.NetID, ip.String())
+		assert.False(t, claimAddress(network.NetID, ip.String()), "an address without a holder yet stays reserved")
+		dropAddress(network.NetID, ip.String())
+		assert.True(t, claimAddress(network.NetID, ip.String()), "a dropped address is free again")
+		dropAddress(network.NetID, ip.String())
+		// clear of the addresses given out from either end of the range
+		assert.True(t, claimAddress(network.NetID, "10.205.0.150"))
+		dropAddress(network.NetID, "10.205.0.150")
+	})
+	t.Run("ReleasedOnceVisible", func(t *testing.T) {
+		assert.True(t, claimAddress(network.NetID, "10.205.0.152"))
+		client := models.ExtClient{ClientID: "ipamholder", Network: network.NetID, Address: "10.205.0.152"}
+		assert.Nil(t, SaveExtClient(&client))
+		defer DeleteExtClient(network.NetID, client.ClientID)
+		releaseAddress(network.NetID, "10.205.0.152")
+		_, err := database.GetLease(addressReservationPrefix + network.NetID + "/10.205.0.152")
+		assert.True(t, database.IsEmptyRecord(err), "the reservation of an address held by a record is released")
+		// a holder written without a reservation is seen once the address is reserved
+		assert.False(t, claimAddress(network.NetID, "10.205.0.152"))
+		_, err = database.GetLease(addressReservationPrefix + network.NetID + "/10.205.0.152")
+		assert.True(t, database.IsEmptyRecord(err))
+	})
+	t.Run("Purge", func(t *testing.T) {
+		held, err := database.AcquireLease(addressReservationPrefix+network.NetID+"/10.205.0.151", "expired", -time.Second)
+		assert.Nil(t, err)
+		assert.True(t, held)
+		purged, err := PurgeAddressReservations()
+		assert.Nil(t, err)
+		assert.Equal(t, 1, purged)
+		assert.True(t, claimAddress(network.NetID, "10.205.0.151"))
+		dropAddress(network.NetID, "10.205.0.151")
+	})
+}
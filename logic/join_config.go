@@ -0,0 +1,153 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gravitl/netmaker/models"
+)
+
+// ErrInvalidJoinConfig - a join config has an invalid route, search domain, sysctl or value
+var ErrInvalidJoinConfig = errors.New("invalid join config")
+
+// SetNetworkJoinConfig - sets the join config of a network with a new version, nil removes it;
+// the hosts of the network have to be asked to pull
+func SetNetworkJoinConfig(netID string, config *models.JoinConfig) (*models.JoinConfig, error) {
+	if err := prepareJoinConfig(config); err != nil {
+		return nil, err
+	}
+	network, err := GetNetwork(netID)
+	if err != nil {
+		return nil, err
+	}
+	current := network
+	network.JoinConfig = config
+	if _, _, _, _, _, err = UpdateNetwork(&current, &network); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// SetEnrollmentKeyJoinConfig - sets the join config of an enrollment key with a new version, nil removes it;
+// the hosts enrolled with the key have to be asked to pull
+func SetEnrollmentKeyJoinConfig(k *models.EnrollmentKey, config *models.JoinConfig) error {
+	if err := prepareJoinConfig(config); err != nil {
+		return err
+	}
+	k.JoinConfig = config
+	return upsertEnrollmentKey(k)
+}
+
+// GetJoinConfigs - the join config of each of the networks that has one, with that of the key a host
+// joined them with on top; key may be nil
+func GetJoinConfigs(networks []string, key *models.EnrollmentKey) map[string]models.JoinConfig {
+	configs := map[string]models.JoinConfig{}
+	for _, netID := range networks {
+		network, err := GetNetwork(netID)
+		if err != nil {
+			continue
+		}
+		if config := mergeJoinConfigs(network.JoinConfig, keyJoinConfig(key, netID)); config != nil {
+			configs[netID] = *config
+		}
+	}
+	return configs
+}
+
+// GetHostJoinConfigs - the join configs of the networks of a host, with that of the key it enrolled with if it still exists;
+// a canary host of a join config rollout gets the config being rolled out
+func GetHostJoinConfigs(h *models.Host) map[string]models.JoinConfig {
+	networks := GetHostNetworks(h.ID.String())
+	key := getEnrollmentKeyByFingerprint(h.EnrolledWith)
+	configs := GetJoinConfigs(networks, key)
+	for _, netID := range networks {
+		rollout, err := getActiveRollout(netID)
+		if err != nil || rollout == nil || rollout.Kind != models.RolloutJoinConfig || !StringSliceContains(rollout.CanaryHosts, h.ID.String()) {
+			continue
+		}
+		if config := mergeJoinConfigs(rollout.JoinConfig, keyJoinConfig(key, netID)); config != nil {
+			configs[netID] = *config
+		}
+	}
+	return configs
+}
+
+// == private ==
+
+// prepareJoinConfig - validates a join config and gives it a new version
+func prepareJoinConfig(config *models.JoinConfig) error {
+	if config == nil {
+		return nil
+	}
+	if err := validator.New().Struct(config); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidJoinConfig, err.Error())
+	}
+	config.Version = time.Now().UnixNano()
+	return nil
+}
+
+// mergeJoinConfigs - the join config of a network with that of a key on top: routes and search domains of both,
+// sysctls and values of the key override those of the network; nil when neither has one
+func mergeJoinConfigs(network, key *models.JoinConfig) *models.JoinConfig {
+	if network == nil && key == nil {
+		return nil
+	}
+	merged := models.JoinConfig{}
+	for _, config := range []*models.JoinConfig{network, key} {
+		if config == nil {
+			continue
+		}
+		if config.Version > merged.Version {
+			merged.Version = config.Version
+		}
+		for _, route := range config.Routes {
+			if !StringSliceContains(merged.Routes, route) {
+				merged.Routes = append(merged.Routes, route)
+			}
+		}
+		for _, domain := range config.DNSSearch {
+			if !StringSliceContains(merged.DNSSearch, domain) {
+				merged.DNSSearch = append(merged.DNSSearch, domain)
+			}
+		}
+		for name, value := range config.Sysctls {
+			if merged.Sysctls == nil {
+				merged.Sysctls = map[string]string{}
+			}
+			merged.Sysctls[name] = value
+		}
+		for name, value := range config.Values {
+			if merged.Values == nil {
+				merged.Values = map[string]string{}
+			}
+			merged.Values[name] = value
+		}
+	}
+	return &merged
+}
+
+// keyJoinConfig - the join config of the key a host joined a network with, nil when it has none for the network
+func keyJoinConfig(key *models.EnrollmentKey, netID string) *models.JoinConfig {
+	if key == nil || !StringSliceContains(key.Networks, netID) {
+		return nil
+	}
+	return key.JoinConfig
+}
+
+func getEnrollmentKeyByFingerprint(fingerprint string) *models.EnrollmentKey {
+	if fingerprint == "" {
+		return nil
+	}
+	keys, err := GetAllEnrollmentKeys()
+	if err != nil {
+		return nil
+	}
+	for _, key := range keys {
+		if EnrollmentKeyFingerprint(key) == fingerprint {
+			return key
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package logic
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeJoinConfigs(t *testing.T) {
+	network := &models.JoinConfig{
+		Version:   1,
+		Routes:    []string{"10.20.0.0/16"},
+		Sysctls:   map[string]string{"net.ipv4.ip_forward": "1", "net.core.rmem_max": "2500000"},
+		DNSSearch: []string{"corp.internal"},
+		Values:    map[string]string{"site": "hq"},
+	}
+	key := &models.JoinConfig{
+		Version:   2,
+		Routes:    []string{"10.20.0.0/16", "192.168.50.0/24"},
+		Sysctls:   map[string]string{"net.ipv4.ip_forward": "0"},
+		DNSSearch: []string{"lab.corp.internal"},
+		Values:    map[string]string{"site": "lab", "role": "sensor"},
+	}
+	t.Run("Neither", func(t *testing.T) {
+		assert.Nil(t, mergeJoinConfigs(nil, nil))
+	})
+	t.Run("NetworkOnly", func(t *testing.T) {
+		assert.Equal(t, network, mergeJoinConfigs(network, nil))
+	})
+	t.Run("KeyOnTop", func(t *testing.T) {
+		merged := mergeJoinConfigs(network, key)
+		assert.Equal(t, int64(2), merged.Version)
+		assert.Equal(t, []string{"10.20.0.0/16", "192.168.50.0/24"}, merged.Routes)
+		assert.Equal(t, []string{"corp.internal", "lab.corp.internal"}, merged.DNSSearch)
+		assert.Equal(t, map[string]string{"net.ipv4.ip_forward": "0", "net.core.rmem_max": "2500000"}, merged.Sysctls)
+		assert.Equal(t, map[string]string{"site": "lab", "role": "sensor"}, merged.Values)
+		// the sources are left as they were
+		assert.Equal(t, "1", network.Sysctls["net.ipv4.ip_forward"])
+	})
+}
+
+func TestPrepareJoinConfig(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		config := &models.JoinConfig{Routes: []string{"fd00::/64"}, DNSSearch: []string{"corp.internal"}}
+		assert.Nil(t, prepareJoinConfig(config))
+		assert.NotZero(t, config.Version)
+	})
+	t.Run("InvalidRoute", func(t *testing.T) {
+		err := prepareJoinConfig(&models.JoinConfig{Routes: []string{"10.20.0.0"}})
+		assert.True(t, errors.Is(err, ErrInvalidJoinConfig))
+	})
+	t.Run("InvalidSearchDomain", func(t *testing.T) {
+		err := prepareJoinConfig(&models.JoinConfig{DNSSearch: []string{"corp internal"}})
+		assert.True(t, errors.Is(err, ErrInvalidJoinConfig))
+	})
+	t.Run("Removed", func(t *testing.T) {
+		assert.Nil(t, prepareJoinConfig(nil))
+	})
+}
@@ -15,6 +15,9 @@ import (
 
 var jwtSecretKey []byte
 
+// ErrHostTokenRevoked - a host token was issued before the host's tokens were revoked
+var ErrHostTokenRevoked = errors.New("host token revoked")
+
 // SetJWTSecret - sets the jwt secret on server startup
 func SetJWTSecret() {
 	currentSecret, jwtErr := FetchJWTSecret()
@@ -152,9 +155,33 @@ func VerifyHostToken(tokenString string) (hostID string, mac string, network str
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 		return jwtSecretKey, nil
 	})
+	if err != nil {
+		return "", "", "", err
+	}
+	if token == nil || !token.Valid {
+		return "", "", "", errors.New("invalid host token")
+	}
+	if err := checkHostTokenIssued(claims); err != nil {
+		return "", "", "", err
+	}
+	return claims.ID, claims.MacAddress, claims.Network, nil
+}
 
-	if token != nil {
-		return claims.ID, claims.MacAddress, claims.Network, nil
+// checkHostTokenIssued - refuses the token of a host, or of a node of a host, that is quarantined
+// or was issued before the host's tokens were revoked
+func checkHostTokenIssued(claims *models.Claims) error {
+	host, err := GetHost(claims.ID)
+	if err != nil {
+		node, nodeErr := GetNodeByID(claims.ID)
+		if nodeErr != nil {
+			return err
+		}
+		if host, err = GetHost(node.HostID.String()); err != nil {
+			return err
+		}
+	}
+	if claims.IssuedAt == nil || !claims.IssuedAt.After(host.TokensNotBefore) {
+		return ErrHostTokenRevoked
 	}
-	return "", "", "", err
+	return CheckHostNotQuarantined(host.ID.String())
 }
@@ -4,8 +4,8 @@ import (
 	"encoding/json"
 
 	"github.com/gravitl/netmaker/database"
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
 )
 
 // IsLegacyNode - checks if a node is legacy or not
@@ -39,7 +39,7 @@ func RemoveAllLegacyNodes() error {
 	}
 	for k := range records {
 		if CheckAndRemoveLegacyNode(k) {
-			logger.Log(0, "removed legacy node", k)
+			slog.Info("removed legacy node", "k", k)
 		}
 	}
 	return nil
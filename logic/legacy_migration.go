@@ -0,0 +1,223 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/exp/slog"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+var (
+	// ErrLegacyNodePassword - the password sent for a legacy node doesn't match it
+	ErrLegacyNodePassword = errors.New("invalid password")
+	// ErrNoLegacyNodesMigrated - none of the legacy nodes of a host could be migrated
+	ErrNoLegacyNodesMigrated = errors.New("no legacy nodes could be migrated")
+)
+
+// MigrateLegacyHost - converts the legacy nodes of a host into a host with their nodes. Every legacy node is
+// checked before anything is written, then the host and the nodes that passed are written in one transaction,
+// so a failure never leaves a host without its nodes. The outcome of each legacy node is returned in order.
+func MigrateLegacyHost(data *models.MigrationData) (models.Host, []models.Node, []models.LegacyNodeMigration, error) {
+	results := make([]models.LegacyNodeMigration, len(data.LegacyNodes))
+	stored := make([]models.LegacyNode, len(data.LegacyNodes))
+	var valid []int
+	seen := map[string]bool{}
+	for i := range data.LegacyNodes {
+		legacy := &data.LegacyNodes[i]
+		results[i] = models.LegacyNodeMigration{NodeID: legacy.ID, Network: legacy.Network}
+		if seen[legacy.ID] {
+			results[i].Error = "legacy node is listed more than once"
+			continue
+		}
+		seen[legacy.ID] = true
+		record, err := checkLegacyNode(legacy)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		stored[i] = record
+		valid = append(valid, i)
+	}
+	if len(valid) == 0 {
+		return models.Host{}, nil, results, ErrNoLegacyNodesMigrated
+	}
+	host, first := convertLegacyHostNode(data.LegacyNodes[valid[0]])
+	host.Name = data.HostName
+	host.HostPass = data.Password
+	host.OS = data.OS
+	host.Nodes = []string{}
+	if err := prepareNewHost(&host); err != nil {
+		for _, i := range valid {
+			results[i].Error = err.Error()
+		}
+		return models.Host{}, nil, results, err
+	}
+	nodes := []models.Node{first}
+	for _, i := range valid[1:] {
+		nodes = append(nodes, convertLegacyNode(stored[i], host.ID))
+	}
+
+	for i := range nodes {
+		host.Nodes = append(host.Nodes, nodes[i].ID.String())
+	}
+	tx := database.BeginTx()
+	revisionMutex.Lock()
+	err := func() error {
+		// the host goes first, the nodes reference it
+		if err := stampHostRevision(&host); err != nil {
+			return err
+		}
+		value, err := json.Marshal(&host)
+		if err != nil {
+			return err
+		}
+		if err := tx.Insert(host.ID.String(), string(value), database.HOSTS_TABLE_NAME); err != nil {
+			return err
+		}
+		for i := range nodes {
+			// the records under the node ids are legacy nodes, which have no revision to check
+			nodes[i].Revision = 1
+			nodes[i].SetLastModified()
+			value, err := json.Marshal(&nodes[i])
+			if err != nil {
+				return err
+			}
+			if err := tx.Insert(nodes[i].ID.String(), string(value), database.NODES_TABLE_NAME); err != nil {
+				return err
+			}
+		}
+		return tx.Commit()
+	}()
+	revisionMutex.Unlock()
+	for _, i := range valid {
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Migrated = true
+	}
+	if err != nil {
+		return models.Host{}, nil, results, err
+	}
+	return host, nodes, results, nil
+}
+
+// == private ==
+
+// checkLegacyNode - checks a legacy node exists, the password sent for it matches and it can be converted,
+// returning the stored legacy node
+func checkLegacyNode(legacy *models.LegacyNode) (models.LegacyNode, error) {
+	var stored models.LegacyNode
+	if _, err := uuid.Parse(legacy.ID); err != nil {
+		return stored, fmt.Errorf("invalid node id %q", legacy.ID)
+	}
+	record, err := database.FetchRecord(database.NODES_TABLE_NAME, legacy.ID)
+	if err != nil {
+		return stored, fmt.Errorf("legacy node not found: %w", err)
+	}
+	if err := json.Unmarshal([]byte(record), &stored); err != nil {
+		return stored, fmt.Errorf("decode legacy node: %w", err)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(stored.Password), []byte(legacy.Password)); err != nil {
+		return stored, ErrLegacyNodePassword
+	}
+	if stored.Network != legacy.Network {
+		return stored, fmt.Errorf("legacy node belongs to network %s", stored.Network)
+	}
+	if _, err := GetNetwork(legacy.Network); err != nil {
+		return stored, fmt.Errorf("network %s not found", legacy.Network)
+	}
+	return stored, nil
+}
+
+func convertLegacyHostNode(legacy models.LegacyNode) (models.Host, models.Node) {
+	//convert host
+	host := models.Host{}
+	host.ID = uuid.New()
+	host.IPForwarding = models.ParseBool(legacy.IPForwarding)
+	host.AutoUpdate = servercfg.AutoUpdateEnabled()
+	host.Interface = "netmaker"
+	host.ListenPort = int(legacy.ListenPort)
+	host.MTU = int(legacy.MTU)
+	host.PublicKey, _ = wgtypes.ParseKey(legacy.PublicKey)
+	host.MacAddress = net.HardwareAddr(legacy.MacAddress)
+	host.TrafficKeyPublic = legacy.TrafficKeys.Mine
+	host.Nodes = append([]string{}, legacy.ID)
+	host.Interfaces = legacy.Interfaces
+	//host.DefaultInterface = legacy.Defaul
+	host.EndpointIP = net.ParseIP(legacy.Endpoint)
+	host.IsDocker = models.ParseBool(legacy.IsDocker)
+	host.IsK8S = models.ParseBool(legacy.IsK8S)
+	host.IsStatic = models.ParseBool(legacy.IsStatic)
+	node := convertLegacyNode(legacy, host.ID)
+	return host, node
+}
+func convertLegacyNode(legacy models.LegacyNode, hostID uuid.UUID) models.Node {
+	//convert node
+	node := models.Node{}
+	node.ID, _ = uuid.Parse(legacy.ID)
+	node.HostID = hostID
+	node.Network = legacy.Network
+	valid4 := true
+	valid6 := true
+	_, cidr4, err := net.ParseCIDR(legacy.NetworkSettings.AddressRange)
+	if err != nil {
+		valid4 = false
+		slog.Warn("parsing address range", "error", err)
+	} else {
+		node.NetworkRange = *cidr4
+	}
+	_, cidr6, err := net.ParseCIDR(legacy.NetworkSettings.AddressRange6)
+	if err != nil {
+		valid6 = false
+		slog.Warn("parsing address range6", "error", err)
+	} else {
+		node.NetworkRange6 = *cidr6
+	}
+	node.Server = servercfg.GetServer()
+	node.Connected = models.ParseBool(legacy.Connected)
+	if valid4 {
+		node.Address = net.IPNet{
+			IP:   net.ParseIP(legacy.Address),
+			Mask: cidr4.Mask,
+		}
+	}
+	if valid6 {
+		node.Address6 = net.IPNet{
+			IP:   net.ParseIP(legacy.Address6),
+			Mask: cidr6.Mask,
+		}
+	}
+	node.Action = models.NODE_NOOP
+	node.LocalAddress = net.IPNet{
+		IP: net.ParseIP(legacy.LocalAddress),
+	}
+	node.IsEgressGateway = models.ParseBool(legacy.IsEgressGateway)
+	node.EgressGatewayRanges = legacy.EgressGatewayRanges
+	node.IsIngressGateway = models.ParseBool(legacy.IsIngressGateway)
+	node.IsRelayed = false
+	node.IsRelay = false
+	node.RelayedNodes = []string{}
+	node.DNSOn = models.ParseBool(legacy.DNSOn)
+	node.PersistentKeepalive = time.Duration(int64(time.Second) * int64(legacy.PersistentKeepalive))
+	node.LastModified = time.Now()
+	node.ExpirationDateTime = time.Unix(legacy.ExpirationDateTime, 0)
+	node.EgressGatewayNatEnabled = models.ParseBool(legacy.EgressGatewayNatEnabled)
+	node.EgressGatewayRequest = legacy.EgressGatewayRequest
+	node.IngressGatewayRange = legacy.IngressGatewayRange
+	node.IngressGatewayRange6 = legacy.IngressGatewayRange6
+	node.DefaultACL = legacy.DefaultACL
+	node.OwnerID = legacy.OwnerID
+	node.FailoverNode, _ = uuid.Parse(legacy.FailoverNode)
+	node.Failover = models.ParseBool(legacy.Failover)
+	return node
+}
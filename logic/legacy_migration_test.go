@@ -0,0 +1,57 @@
+package logic
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestMigrateLegacyHost(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	// networks are created with their users, at least one has to exist
+	assert.Nil(t, CreateUser(&models.User{UserName: "legacyadmin", Password: "password", IsAdmin: true}))
+	defer DeleteUser("legacyadmin")
+	network, err := CreateNetwork(models.Network{NetID: "legacytest", AddressRange: "10.201.0.0/24"})
+	assert.Nil(t, err)
+	defer database.DeleteRecord(database.NETWORKS_TABLE_NAME, network.NetID)
+	hash, _ := bcrypt.GenerateFromPassword([]byte("legacypassword"), 5)
+	legacy := models.LegacyNode{ID: uuid.NewString(), Network: network.NetID, Password: string(hash), Address: "10.201.0.5"}
+	data, _ := json.Marshal(&legacy)
+	assert.Nil(t, database.Insert(legacy.ID, string(data), database.NODES_TABLE_NAME))
+	defer database.DeleteRecord(database.NODES_TABLE_NAME, legacy.ID)
+
+	request := models.MigrationData{
+		HostName: "legacyhost",
+		Password: "hostpassword",
+		LegacyNodes: []models.LegacyNode{
+			{ID: legacy.ID, Network: network.NetID, Password: "legacypassword"},
+			{ID: legacy.ID, Network: network.NetID, Password: "legacypassword"},
+			{ID: uuid.NewString(), Network: network.NetID, Password: "legacypassword"},
+		},
+	}
+	t.Run("NoneValid", func(t *testing.T) {
+		wrong := models.MigrationData{LegacyNodes: []models.LegacyNode{{ID: legacy.ID, Network: network.NetID, Password: "wrong"}}}
+		_, _, results, err := MigrateLegacyHost(&wrong)
+		assert.ErrorIs(t, err, ErrNoLegacyNodesMigrated)
+		assert.Equal(t, ErrLegacyNodePassword.Error(), results[0].Error)
+	})
+	t.Run("PartlyValid", func(t *testing.T) {
+		host, nodes, results, err := MigrateLegacyHost(&request)
+		assert.Nil(t, err)
+		defer database.DeleteRecord(database.HOSTS_TABLE_NAME, host.ID.String())
+		assert.True(t, results[0].Migrated)
+		assert.False(t, results[1].Migrated)
+		assert.False(t, results[2].Migrated)
+		assert.Equal(t, 1, len(nodes))
+		assert.Equal(t, []string{legacy.ID}, host.Nodes)
+		stored, err := GetHost(host.ID.String())
+		assert.Nil(t, err)
+		assert.Equal(t, host.Nodes, stored.Nodes)
+	})
+}
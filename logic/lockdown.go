@@ -0,0 +1,256 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic/acls"
+	"github.com/gravitl/netmaker/logic/acls/nodeacls"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+var (
+	// ErrNetworkLocked - a network is locked down, its ACLs can't change until it's unlocked
+	ErrNetworkLocked = errors.New("network is locked down")
+	// ErrNetworkNotLocked - a network that isn't locked down is being unlocked
+	ErrNetworkNotLocked = errors.New("network is not locked down")
+	// ErrInvalidLockdown - a lockdown allowlist names nodes or ext clients the network doesn't have
+	ErrInvalidLockdown = errors.New("invalid lockdown")
+)
+
+// GetNetworkLockdown - the lockdown of a network, ErrNetworkNotLocked when it isn't locked down
+func GetNetworkLockdown(netID string) (models.NetworkLockdown, error) {
+	var lockdown models.NetworkLockdown
+	record, err := database.FetchRecord(database.NETWORK_LOCKDOWNS_TABLE_NAME, netID)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return lockdown, ErrNetworkNotLocked
+		}
+		return lockdown, err
+	}
+	err = json.Unmarshal([]byte(record), &lockdown)
+	return lockdown, err
+}
+
+// IsNetworkLocked - checks if a network is locked down
+func IsNetworkLocked(netID string) bool {
+	_, err := GetNetworkLockdown(netID)
+	return err == nil
+}
+
+// LockdownNetwork - denies every pair of a network's nodes and disables its ext clients, sparing the nodes and
+// ext clients of the allowlist, and denies nodes joining until the network is unlocked; the ACLs and clients
+// as they were are kept to restore them. Peers have to be sent the update.
+func LockdownNetwork(netID, user string, request *models.NetworkLockdownRequest) (models.NetworkLockdown, error) {
+	lockdown := models.NetworkLockdown{Network: netID, Time: time.Now().UTC(), User: user, Reason: request.Reason, Allowed: request.Allowed, DisabledClients: []string{}}
+	if lockdown.Allowed == nil {
+		lockdown.Allowed = []string{}
+	}
+	if err := validator.New().Struct(request); err != nil {
+		return lockdown, fmt.Errorf("%w: %s", ErrInvalidLockdown, err.Error())
+	}
+	if IsNetworkLocked(netID) {
+		return lockdown, ErrNetworkLocked
+	}
+	network, err := GetNetwork(netID)
+	if err != nil {
+		return lockdown, err
+	}
+	nodes, err := GetNetworkNodes(netID)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return lockdown, err
+	}
+	clients, err := GetNetworkExtClients(netID)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return lockdown, err
+	}
+	known := map[string]bool{}
+	for _, node := range nodes {
+		known[node.ID.String()] = true
+	}
+	for _, client := range clients {
+		known[client.ClientID] = true
+	}
+	for _, id := range lockdown.Allowed {
+		if !known[id] {
+			return lockdown, fmt.Errorf("%w: %s is not a node or ext client of network %s", ErrInvalidLockdown, id, netID)
+		}
+	}
+	container, err := nodeacls.FetchAllACLs(nodeacls.NetworkID(netID))
+	if err != nil && !database.IsEmptyRecord(err) {
+		return lockdown, err
+	}
+	lockdown.DefaultACL = network.DefaultACL
+	lockdown.ACLs = copyACLs(container)
+	// stored before anything changes so a lockdown failing halfway can still be unlocked
+	if err := saveNetworkLockdown(&lockdown); err != nil {
+		return lockdown, err
+	}
+
+	if lockdown.DeniedPairs = lockdownACLs(container, lockdown.Allowed); lockdown.DeniedPairs > 0 {
+		if _, err := container.Save(acls.ContainerID(netID)); err != nil {
+			return lockdown, err
+		}
+	}
+	if network.DefaultACL != "no" {
+		current := network
+		network.DefaultACL = "no"
+		if _, _, _, _, _, err = UpdateNetwork(&current, &network); err != nil {
+			return lockdown, err
+		}
+	}
+	for i := range clients {
+		client := clients[i]
+		if !client.Enabled || StringSliceContains(lockdown.Allowed, client.ClientID) {
+			continue
+		}
+		client.Enabled = false
+		if err := SaveExtClient(&client); err != nil {
+			slog.Error("failed to disable ext client for lockdown", "network", netID, "client", client.ClientID, "error", err)
+			continue
+		}
+		lockdown.DisabledClients = append(lockdown.DisabledClients, client.ClientID)
+	}
+	sort.Strings(lockdown.DisabledClients)
+	if err := saveNetworkLockdown(&lockdown); err != nil {
+		return lockdown, err
+	}
+	slog.Warn("network locked down", "network", netID, "user", user, "reason", lockdown.Reason, "denied_pairs", lockdown.DeniedPairs, "disabled_clients", len(lockdown.DisabledClients))
+	RecordNetworkEvent(netID, models.NetworkEventACL, nil, "network locked down by "+user)
+	go Notify(models.Notification{
+		Event:    models.NotificationEventSecurity,
+		Severity: models.NotificationAlert,
+		Title:    "network " + netID + " locked down",
+		Text:     fmt.Sprintf("%s locked the network down: %s", user, lockdown.Reason),
+		Network:  netID,
+		Fields: []models.NotificationField{
+			{Name: "allowed", Value: strings.Join(lockdown.Allowed, ", ")},
+			{Name: "denied pairs", Value: fmt.Sprint(lockdown.DeniedPairs)},
+			{Name: "disabled ext clients", Value: fmt.Sprint(len(lockdown.DisabledClients))},
+		},
+	}, nil)
+	return lockdown, nil
+}
+
+// UnlockNetwork - ends the lockdown of a network, restoring its default ACL, the ACLs of the nodes it had then and
+// the ext clients it disabled; zones are compiled again. Peers have to be sent the update.
+func UnlockNetwork(netID, user string) (models.NetworkLockdown, error) {
+	lockdown, err := GetNetworkLockdown(netID)
+	if err != nil {
+		return lockdown, err
+	}
+	network, err := GetNetwork(netID)
+	if err != nil {
+		return lockdown, err
+	}
+	container, err := nodeacls.FetchAllACLs(nodeacls.NetworkID(netID))
+	if err != nil && !database.IsEmptyRecord(err) {
+		return lockdown, err
+	}
+	if restoreLockdownACLs(container, lockdown.ACLs) > 0 {
+		if _, err := container.Save(acls.ContainerID(netID)); err != nil {
+			return lockdown, err
+		}
+	}
+	if network.DefaultACL != lockdown.DefaultACL {
+		current := network
+		network.DefaultACL = lockdown.DefaultACL
+		if _, _, _, _, _, err = UpdateNetwork(&current, &network); err != nil {
+			return lockdown, err
+		}
+	}
+	for _, clientID := range lockdown.DisabledClients {
+		client, err := GetExtClient(clientID, netID)
+		if err != nil || client.Enabled {
+			continue
+		}
+		client.Enabled = true
+		if err := SaveExtClient(&client); err != nil {
+			slog.Error("failed to enable ext client after lockdown", "network", netID, "client", clientID, "error", err)
+		}
+	}
+	if err := database.DeleteRecord(database.NETWORK_LOCKDOWNS_TABLE_NAME, netID); err != nil {
+		return lockdown, err
+	}
+	if network.Zones != nil {
+		if _, err := ApplyNetworkZones(netID, false); err != nil {
+			slog.Error("failed to compile zones after lockdown", "network", netID, "error", err)
+		}
+	}
+	slog.Warn("network unlocked", "network", netID, "user", user, "locked_since", lockdown.Time)
+	RecordNetworkEvent(netID, models.NetworkEventACL, nil, "network unlocked by "+user)
+	go Notify(models.Notification{
+		Event:    models.NotificationEventSecurity,
+		Severity: models.NotificationResolved,
+		Title:    "network " + netID + " unlocked",
+		Text:     fmt.Sprintf("%s unlocked the network, locked down since %s", user, lockdown.Time.Format(time.RFC3339)),
+		Network:  netID,
+	}, nil)
+	return lockdown, nil
+}
+
+// == private ==
+
+func saveNetworkLockdown(lockdown *models.NetworkLockdown) error {
+	data, err := json.Marshal(lockdown)
+	if err != nil {
+		return err
+	}
+	return database.Insert(lockdown.Network, string(data), database.NETWORK_LOCKDOWNS_TABLE_NAME)
+}
+
+// lockdownACLs - denies the pairs of nodes neither of which is allowed, returning how many were allowed before
+func lockdownACLs(container acls.ACLContainer, allowed []string) int {
+	denied := 0
+	for a, acl := range container {
+		if StringSliceContains(allowed, string(a)) {
+			continue
+		}
+		for b, value := range acl {
+			if StringSliceContains(allowed, string(b)) || value == acls.NotAllowed {
+				continue
+			}
+			acl[b] = acls.NotAllowed
+			// each pair is in the container both ways
+			if a < b {
+				denied++
+			}
+		}
+	}
+	return denied
+}
+
+// restoreLockdownACLs - puts back the ACLs saved by a lockdown, nodes that joined or left since keep theirs;
+// returns the number of entries changed
+func restoreLockdownACLs(container acls.ACLContainer, saved map[string]map[string]byte) int {
+	changed := 0
+	for a, acl := range container {
+		for b, value := range acl {
+			previous, ok := saved[string(a)][string(b)]
+			if !ok || previous == value {
+				continue
+			}
+			acl[b] = previous
+			changed++
+		}
+	}
+	return changed
+}
+
+func copyACLs(container acls.ACLContainer) map[string]map[string]byte {
+	copied := map[string]map[string]byte{}
+	for a, acl := range container {
+		copied[string(a)] = map[string]byte{}
+		for b, value := range acl {
+			copied[string(a)][string(b)] = value
+		}
+	}
+	return copied
+}
@@ -0,0 +1,40 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/gravitl/netmaker/logic/acls"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockdownACLs(t *testing.T) {
+	container := acls.ACLContainer{}
+	ids := []acls.AclID{"admin", "db", "web", "laptop"}
+	for _, a := range ids {
+		container[a] = acls.ACL{}
+		for _, b := range ids {
+			if a != b {
+				container[a][b] = acls.Allowed
+			}
+		}
+	}
+	container.ChangeAccess("db", "laptop", acls.NotAllowed)
+	saved := copyACLs(container)
+
+	// db-web and web-laptop were allowed, db-laptop already denied
+	assert.Equal(t, 2, lockdownACLs(container, []string{"admin"}))
+	assert.False(t, container["db"].IsAllowed("web"))
+	assert.False(t, container["web"].IsAllowed("db"))
+	assert.True(t, container["admin"].IsAllowed("web"), "the allowlist keeps its access")
+	assert.True(t, container["laptop"].IsAllowed("admin"))
+
+	t.Run("Restore", func(t *testing.T) {
+		// a node joining during the lockdown keeps its acl
+		container["phone"] = acls.ACL{"admin": acls.NotAllowed}
+		container["admin"]["phone"] = acls.NotAllowed
+		assert.Equal(t, 4, restoreLockdownACLs(container, saved))
+		assert.True(t, container["db"].IsAllowed("web"))
+		assert.False(t, container["db"].IsAllowed("laptop"))
+		assert.False(t, container["admin"].IsAllowed("phone"))
+	})
+}
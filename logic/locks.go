@@ -0,0 +1,209 @@
+package logic
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
+)
+
+var (
+	// lockTTL - how long a critical section holds its lease before another server may take it, unless renewed
+	lockTTL = 30 * time.Second
+	// lockRenewal - how often a critical section renews its lease while in it
+	lockRenewal = lockTTL / 3
+)
+
+const (
+	// lockWait - how long a critical section waits for the lease to be released
+	lockWait = 30 * time.Second
+	// lockRetry - how often a critical section waiting checks the lease again
+	lockRetry = 100 * time.Millisecond
+	// addressLockName - the lease held while giving out addresses and names, so no two servers give out the same
+	addressLockName = "ipam"
+)
+
+var (
+	// ErrLockTimeout - the lease of a critical section is held by another server for too long
+	ErrLockTimeout = errors.New("timed out waiting for lock")
+	// ErrLockLost - the lease of a critical section couldn't be renewed and may have been taken by another server
+	ErrLockLost = errors.New("lock lost")
+)
+
+// Fence - a critical section entered, whose lease is renewed until it's left. Writers check it right before
+// writing, so a server that lost the lease, paused past its ttl or cut off from the database, stops instead of
+// overwriting what the server that took the lease over wrote.
+type Fence struct {
+	name  string
+	token int64
+	local *sync.Mutex
+	// lost - closed once the lease couldn't be renewed
+	lost chan struct{}
+	// done - closed on leaving, stopped once the renewals did
+	done    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+}
+
+var (
+	leaseOwner     string
+	leaseOwnerOnce sync.Once
+	localLocksMu   sync.Mutex
+	localLocks     = map[string]*sync.Mutex{}
+)
+
+// LeaseOwner - identifies this server, and this run of it, as the owner of the leases it takes
+func LeaseOwner() string {
+	leaseOwnerOnce.Do(func() {
+		leaseOwner = servercfg.GetNodeID() + "/" + uuid.NewString()[:8]
+	})
+	return leaseOwner
+}
+
+// TryLock - takes the lease of the given name for this server until ttl from now, or renews it;
+// false when another server holds it
+func TryLock(name string, ttl time.Duration) (bool, error) {
+	return database.AcquireLease(name, LeaseOwner(), ttl)
+}
+
+// Lock - enters the critical section of the given name, waiting for the goroutines of this server and then for
+// the other servers to leave it; the returned function leaves it
+func Lock(name string) (func(), error) {
+	fence, err := LockWithFence(name)
+	if err != nil {
+		return nil, err
+	}
+	return fence.Unlock, nil
+}
+
+// LockWithFence - enters the critical section of the given name like Lock, handing out the fence of the
+// section for its writers to check
+func LockWithFence(name string) (*Fence, error) {
+	local := localLock(name)
+	local.Lock()
+	deadline := time.Now().Add(lockWait)
+	for {
+		held, err := TryLock(name, lockTTL)
+		if err != nil {
+			local.Unlock()
+			return nil, err
+		}
+		if held {
+			break
+		}
+		if time.Now().After(deadline) {
+			local.Unlock()
+			return nil, ErrLockTimeout
+		}
+		time.Sleep(lockRetry)
+	}
+	lease, err := database.GetLease(name)
+	if err == nil && lease.Owner != LeaseOwner() {
+		err = ErrLockLost
+	}
+	if err != nil {
+		local.Unlock()
+		return nil, err
+	}
+	fence := &Fence{
+		name:    name,
+		token:   lease.Token,
+		local:   local,
+		lost:    make(chan struct{}),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go fence.renew()
+	return fence, nil
+}
+
+// Token - the fencing token of the section, it changes every time the section is entered
+func (f *Fence) Token() int64 {
+	return f.token
+}
+
+// Check - ErrLockLost when the section's lease was lost, to be called right before writing
+func (f *Fence) Check() error {
+	select {
+	case <-f.lost:
+		return ErrLockLost
+	default:
+	}
+	if err := database.CheckLease(f.name, LeaseOwner(), f.token); err != nil {
+		if errors.Is(err, database.ErrLeaseLost) {
+			return ErrLockLost
+		}
+		return err
+	}
+	return nil
+}
+
+// Unlock - leaves the critical section
+func (f *Fence) Unlock() {
+	f.once.Do(func() {
+		close(f.done)
+		// a renewal in flight would store the lease again after it's released
+		<-f.stopped
+		if err := database.ReleaseLease(f.name, LeaseOwner()); err != nil {
+			slog.Warn("failed to release lock, it expires instead", "lock", f.name, "error", err)
+		}
+		f.local.Unlock()
+	})
+}
+
+// GatewayLockName - the lock held while assigning the gateways, relays and failovers of a network,
+// so two servers don't assign a node twice
+func GatewayLockName(network string) string {
+	return "gateways:" + network
+}
+
+// WithLock - runs fn in the critical section of the given name, fn checks the fence before writing
+func WithLock(name string, fn func(fence *Fence) error) error {
+	fence, err := LockWithFence(name)
+	if err != nil {
+		return err
+	}
+	defer fence.Unlock()
+	return fn(fence)
+}
+
+// == private ==
+
+// renew - renews the section's lease until it's left, marking it lost once it can't be
+func (f *Fence) renew() {
+	defer close(f.stopped)
+	ticker := time.NewTicker(lockRenewal)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.done:
+			return
+		case <-ticker.C:
+			renewed, err := database.RenewLease(f.name, LeaseOwner(), f.token, lockTTL)
+			if err != nil {
+				// retried on the next tick, the lease is kept as long as no other server took it
+				slog.Warn("failed to renew lock", "lock", f.name, "error", err)
+				continue
+			}
+			if !renewed {
+				slog.Error("lost lock, another server may be in its critical section", "lock", f.name)
+				close(f.lost)
+				return
+			}
+		}
+	}
+}
+
+// localLock - the mutex keeping the goroutines of this server apart, as they share its lease
+func localLock(name string) *sync.Mutex {
+	localLocksMu.Lock()
+	defer localLocksMu.Unlock()
+	if _, ok := localLocks[name]; !ok {
+		localLocks[name] = &sync.Mutex{}
+	}
+	return localLocks[name]
+}
@@ -0,0 +1,105 @@
+package logic
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocks(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.DeleteAllRecords(database.LEASES_TABLE_NAME)
+
+	t.Run("LeaseHeldByAnotherServer", func(t *testing.T) {
+		held, err := database.AcquireLease("test:held", "other-server", time.Minute)
+		assert.Nil(t, err)
+		assert.True(t, held)
+		held, err = TryLock("test:held", time.Minute)
+		assert.Nil(t, err)
+		assert.False(t, held)
+		// releasing a lease of another owner leaves it
+		assert.Nil(t, database.ReleaseLease("test:held", LeaseOwner()))
+		lease, err := database.GetLease("test:held")
+		assert.Nil(t, err)
+		assert.Equal(t, "other-server", lease.Owner)
+	})
+	t.Run("ExpiredLease", func(t *testing.T) {
+		held, err := database.AcquireLease("test:expired", "other-server", -time.Second)
+		assert.Nil(t, err)
+		assert.True(t, held)
+		held, err = TryLock("test:expired", time.Minute)
+		assert.Nil(t, err)
+		assert.True(t, held)
+		held, err = TryLock("test:expired", time.Minute)
+		assert.Nil(t, err)
+		assert.True(t, held, "the owner renews its lease")
+	})
+	t.Run("CriticalSection", func(t *testing.T) {
+		var wg sync.WaitGroup
+		inside, most := 0, 0
+		var mu sync.Mutex
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := WithLock("test:section", func(fence *Fence) error {
+					assert.Nil(t, fence.Check())
+					mu.Lock()
+					inside++
+					if inside > most {
+						most = inside
+					}
+					mu.Unlock()
+					time.Sleep(5 * time.Millisecond)
+					mu.Lock()
+					inside--
+					mu.Unlock()
+					return nil
+				})
+				assert.Nil(t, err)
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 1, most)
+		_, err := database.GetLease("test:section")
+		assert.True(t, database.IsEmptyRecord(err), "the lease is released on leaving")
+	})
+	t.Run("Renewed", func(t *testing.T) {
+		defer func(ttl, renewal time.Duration) { lockTTL, lockRenewal = ttl, renewal }(lockTTL, lockRenewal)
+		lockTTL, lockRenewal = 300*time.Millisecond, 50*time.Millisecond
+		fence, err := LockWithFence("test:renewed")
+		assert.Nil(t, err)
+		assert.NotZero(t, fence.Token())
+		time.Sleep(2 * lockTTL)
+		assert.Nil(t, fence.Check(), "the lease is renewed while held")
+		lease, err := database.GetLease("test:renewed")
+		assert.Nil(t, err)
+		assert.Equal(t, fence.Token(), lease.Token)
+		fence.Unlock()
+		_, err = database.GetLease("test:renewed")
+		assert.True(t, database.IsEmptyRecord(err))
+	})
+	t.Run("Lost", func(t *testing.T) {
+		defer func(ttl, renewal time.Duration) { lockTTL, lockRenewal = ttl, renewal }(lockTTL, lockRenewal)
+		lockTTL, lockRenewal = 300*time.Millisecond, 50*time.Millisecond
+		fence, err := LockWithFence("test:lost")
+		assert.Nil(t, err)
+		defer fence.Unlock()
+		// another server takes the lease over after it expired, while this one was paused
+		assert.Nil(t, database.Insert("test:lost", `{"name":"test:lost","owner":"other-server","expires":1,"token":1}`, database.LEASES_TABLE_NAME))
+		held, err := database.AcquireLease("test:lost", "other-server", time.Minute)
+		assert.Nil(t, err)
+		assert.True(t, held)
+		assert.ErrorIs(t, fence.Check(), ErrLockLost)
+		time.Sleep(2 * lockRenewal)
+		renewed, err := database.RenewLease("test:lost", LeaseOwner(), fence.Token(), time.Minute)
+		assert.Nil(t, err)
+		assert.False(t, renewed, "a lease another server holds isn't renewed")
+		lease, err := database.GetLease("test:lost")
+		assert.Nil(t, err)
+		assert.Equal(t, "other-server", lease.Owner)
+	})
+}
@@ -0,0 +1,237 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+)
+
+// maintenanceWeekdays - the days of a maintenance window by time.Weekday
+var maintenanceWeekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// ErrInvalidMaintenanceWindow - the schedule of a maintenance window is malformed
+var ErrInvalidMaintenanceWindow = errors.New("invalid maintenance window")
+
+// CreateMaintenanceWindow - adds a maintenance window to a network
+func CreateMaintenanceWindow(window *models.MaintenanceWindow) error {
+	if err := validateMaintenanceWindow(window); err != nil {
+		return err
+	}
+	if _, err := GetNetwork(window.Network); err != nil {
+		return err
+	}
+	window.ID = uuid.New().String()
+	data, err := json.Marshal(window)
+	if err != nil {
+		return err
+	}
+	return database.Insert(window.ID, string(data), database.MAINTENANCE_WINDOWS_TABLE_NAME)
+}
+
+// GetMaintenanceWindow - fetches a maintenance window
+func GetMaintenanceWindow(id string) (models.MaintenanceWindow, error) {
+	var window models.MaintenanceWindow
+	data, err := database.FetchRecord(database.MAINTENANCE_WINDOWS_TABLE_NAME, id)
+	if err != nil {
+		return window, err
+	}
+	err = json.Unmarshal([]byte(data), &window)
+	return window, err
+}
+
+// DeleteMaintenanceWindow - removes a maintenance window
+func DeleteMaintenanceWindow(id string) error {
+	return database.DeleteRecord(database.MAINTENANCE_WINDOWS_TABLE_NAME, id)
+}
+
+// GetNetworkMaintenanceWindows - fetches the maintenance windows of a network
+func GetNetworkMaintenanceWindows(network string) ([]models.MaintenanceWindow, error) {
+	windows := []models.MaintenanceWindow{}
+	records, err := database.FetchRecords(database.MAINTENANCE_WINDOWS_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return windows, nil
+		}
+		return windows, err
+	}
+	for _, value := range records {
+		var window models.MaintenanceWindow
+		if err := json.Unmarshal([]byte(value), &window); err != nil {
+			continue
+		}
+		if window.Network == network {
+			windows = append(windows, window)
+		}
+	}
+	sort.Slice(windows, func(i, j int) bool {
+		return windows[i].Name < windows[j].Name
+	})
+	return windows, nil
+}
+
+// GetMaintenanceStatus - whether a network is in a maintenance window, and when the next one opens if not,
+// a network without windows is always open
+func GetMaintenanceStatus(network string, now time.Time) (models.MaintenanceStatus, error) {
+	windows, err := GetNetworkMaintenanceWindows(network)
+	if err != nil {
+		return models.MaintenanceStatus{}, err
+	}
+	status := models.MaintenanceStatus{Network: network, Open: len(windows) == 0, Windows: windows}
+	for i := range windows {
+		if maintenanceWindowOpen(&windows[i], now) {
+			status.Open = true
+		}
+		if next := nextMaintenanceWindow(&windows[i], now); !next.IsZero() && (status.Next.IsZero() || next.Before(status.Next)) {
+			status.Next = next
+		}
+	}
+	return status, nil
+}
+
+// HostInMaintenanceWindow - checks every network of a host is in a maintenance window,
+// so a disruptive change to it can be applied
+func HostInMaintenanceWindow(host *models.Host, now time.Time) bool {
+	for _, nodeID := range host.Nodes {
+		node, err := GetNodeByID(nodeID)
+		if err != nil {
+			continue
+		}
+		status, err := GetMaintenanceStatus(node.Network, now)
+		if err != nil || !status.Open {
+			return false
+		}
+	}
+	return true
+}
+
+// QueuePendingChange - holds a disruptive change to a host until its next maintenance window,
+// replacing a change of the same kind already waiting
+func QueuePendingChange(change *models.PendingChange) error {
+	change.ID = change.HostID + "-" + string(change.Action)
+	change.Queued = time.Now().UTC()
+	data, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	return database.Insert(change.ID, string(data), database.PENDING_CHANGES_TABLE_NAME)
+}
+
+// GetPendingChanges - fetches the changes waiting for a maintenance window, oldest first
+func GetPendingChanges() ([]models.PendingChange, error) {
+	changes := []models.PendingChange{}
+	records, err := database.FetchRecords(database.PENDING_CHANGES_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return changes, nil
+		}
+		return changes, err
+	}
+	for _, value := range records {
+		var change models.PendingChange
+		if err := json.Unmarshal([]byte(value), &change); err != nil {
+			continue
+		}
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Queued.Before(changes[j].Queued)
+	})
+	return changes, nil
+}
+
+// GetPendingChange - fetches a change waiting for a maintenance window
+func GetPendingChange(id string) (models.PendingChange, error) {
+	var change models.PendingChange
+	record, err := database.FetchRecord(database.PENDING_CHANGES_TABLE_NAME, id)
+	if err != nil {
+		return change, err
+	}
+	err = json.Unmarshal([]byte(record), &change)
+	return change, err
+}
+
+// PendingChangeInTenant - checks the host of a change has joined a network of the tenant
+func PendingChangeInTenant(change *models.PendingChange, tenant string) bool {
+	host, err := GetHost(change.HostID)
+	if err != nil {
+		return false
+	}
+	return HostInTenant(host, tenant)
+}
+
+// DeletePendingChange - drops a change once it was applied
+func DeletePendingChange(id string) error {
+	if err := database.DeleteRecord(database.PENDING_CHANGES_TABLE_NAME, id); err != nil && !database.IsEmptyRecord(err) {
+		return err
+	}
+	return nil
+}
+
+// == private ==
+
+func validateMaintenanceWindow(window *models.MaintenanceWindow) error {
+	if err := validator.New().Struct(window); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidMaintenanceWindow, err.Error())
+	}
+	if _, err := time.Parse("15:04", window.Start); err != nil {
+		return fmt.Errorf("%w: start must be HH:MM", ErrInvalidMaintenanceWindow)
+	}
+	if _, err := time.LoadLocation(window.Timezone); err != nil {
+		return fmt.Errorf("%w: unknown timezone %s", ErrInvalidMaintenanceWindow, window.Timezone)
+	}
+	return nil
+}
+
+// maintenanceWindowStart - when the window opens on the day of t, in the window's timezone
+func maintenanceWindowStart(window *models.MaintenanceWindow, t time.Time) (time.Time, bool) {
+	start, err := time.Parse("15:04", window.Start)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if len(window.Days) > 0 && !StringSliceContains(window.Days, maintenanceWeekdays[t.Weekday()]) {
+		return time.Time{}, false
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), start.Hour(), start.Minute(), 0, 0, t.Location()), true
+}
+
+func maintenanceWindowLocation(window *models.MaintenanceWindow) *time.Location {
+	loc, err := time.LoadLocation(window.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// maintenanceWindowOpen - checks the window is open at now, it may have opened the day before
+func maintenanceWindowOpen(window *models.MaintenanceWindow, now time.Time) bool {
+	local := now.In(maintenanceWindowLocation(window))
+	for _, day := range []time.Time{local, local.AddDate(0, 0, -1)} {
+		start, ok := maintenanceWindowStart(window, day)
+		if !ok {
+			continue
+		}
+		if !local.Before(start) && local.Before(start.Add(time.Duration(window.Duration)*time.Minute)) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextMaintenanceWindow - when the window next opens after now, zero if it never does
+func nextMaintenanceWindow(window *models.MaintenanceWindow, now time.Time) time.Time {
+	local := now.In(maintenanceWindowLocation(window))
+	for i := 0; i <= 7; i++ {
+		start, ok := maintenanceWindowStart(window, local.AddDate(0, 0, i))
+		if ok && start.After(local) {
+			return start.UTC()
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,37 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaintenanceWindow(t *testing.T) {
+	// saturdays 23:00 to 01:00 in Berlin, UTC+2 in summer
+	window := &models.MaintenanceWindow{Name: "weekend", Days: []string{"sat"}, Start: "23:00", Duration: 120, Timezone: "Europe/Berlin"}
+	assert.Nil(t, validateMaintenanceWindow(window))
+	saturday := time.Date(2023, 7, 1, 21, 30, 0, 0, time.UTC)
+	assert.True(t, maintenanceWindowOpen(window, saturday))
+	t.Run("PastMidnight", func(t *testing.T) {
+		assert.True(t, maintenanceWindowOpen(window, saturday.Add(time.Hour)))
+		assert.False(t, maintenanceWindowOpen(window, saturday.Add(2*time.Hour)))
+	})
+	t.Run("Next", func(t *testing.T) {
+		assert.False(t, maintenanceWindowOpen(window, saturday.Add(-time.Hour)))
+		assert.Equal(t, time.Date(2023, 7, 1, 21, 0, 0, 0, time.UTC), nextMaintenanceWindow(window, saturday.Add(-time.Hour)))
+		assert.Equal(t, time.Date(2023, 7, 8, 21, 0, 0, 0, time.UTC), nextMaintenanceWindow(window, saturday))
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		for _, invalid := range []models.MaintenanceWindow{
+			{Name: "start", Start: "25:00", Duration: 60},
+			{Name: "day", Days: []string{"someday"}, Start: "01:00", Duration: 60},
+			{Name: "duration", Start: "01:00"},
+			{Name: "timezone", Start: "01:00", Duration: 60, Timezone: "Mars/Olympus"},
+		} {
+			invalid := invalid
+			assert.ErrorIs(t, validateMaintenanceWindow(&invalid), ErrInvalidMaintenanceWindow, invalid.Name)
+		}
+	})
+}
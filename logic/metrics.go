@@ -1,6 +1,7 @@
 package logic
 
 import (
+	"context"
 	"encoding/json"
 
 	"github.com/gravitl/netmaker/database"
@@ -9,8 +10,13 @@ import (
 
 // GetMetrics - gets the metrics
 func GetMetrics(nodeid string) (*models.Metrics, error) {
+	return GetMetricsCtx(context.Background(), nodeid)
+}
+
+// GetMetricsCtx - gets the metrics, tracing the fetch as part of ctx
+func GetMetricsCtx(ctx context.Context, nodeid string) (*models.Metrics, error) {
 	var metrics models.Metrics
-	record, err := database.FetchRecord(database.METRICS_TABLE_NAME, nodeid)
+	record, err := database.FetchRecordCtx(ctx, database.METRICS_TABLE_NAME, nodeid)
 	if err != nil {
 		if database.IsEmptyRecord(err) {
 			return &metrics, nil
@@ -26,11 +32,16 @@ func GetMetrics(nodeid string) (*models.Metrics, error) {
 
 // UpdateMetrics - updates the metrics of a given client
 func UpdateMetrics(nodeid string, metrics *models.Metrics) error {
+	return UpdateMetricsCtx(context.Background(), nodeid, metrics)
+}
+
+// UpdateMetricsCtx - updates the metrics of a given client, tracing the write as part of ctx
+func UpdateMetricsCtx(ctx context.Context, nodeid string, metrics *models.Metrics) error {
 	data, err := json.Marshal(metrics)
 	if err != nil {
 		return err
 	}
-	return database.Insert(nodeid, string(data), database.METRICS_TABLE_NAME)
+	return database.InsertCtx(ctx, nodeid, string(data), database.METRICS_TABLE_NAME)
 }
 
 // DeleteMetrics - deletes metrics of a given node
@@ -0,0 +1,186 @@
+package logic
+
+import (
+	"sort"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+)
+
+// defaultWorstLinks - number of worst links returned in a summary when none is asked for
+const defaultWorstLinks = 10
+
+// GetNetworkMetricsSummary - aggregates the latest metrics of a network's nodes server side:
+// latency percentiles over connected links, the worst links and the load on each gateway
+func GetNetworkMetricsSummary(network string, worst int) (models.NetworkMetricsSummary, error) {
+	nodes, err := GetNetworkNodes(network)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return models.NetworkMetricsSummary{}, err
+	}
+	now := time.Now().UTC()
+	usage, err := GetTrafficUsage(now, now)
+	if err != nil {
+		return models.NetworkMetricsSummary{}, err
+	}
+	summary := summarizeNetworkMetrics(network, nodes, usage, worst, now)
+	summary.Bandwidth = GetBandwidthUtilization(network, nodes)
+	return summary, nil
+}
+
+// GetMetricsSummaries - the metrics summaries of every network
+func GetMetricsSummaries(worst int) ([]models.NetworkMetricsSummary, error) {
+	networks, err := GetNetworks()
+	if err != nil && !database.IsEmptyRecord(err) {
+		return nil, err
+	}
+	summaries := []models.NetworkMetricsSummary{}
+	for _, network := range networks {
+		summary, err := GetNetworkMetricsSummary(network.NetID, worst)
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+// == private ==
+
+func summarizeNetworkMetrics(network string, nodes []models.Node, usage []models.TrafficUsage, worst int, now time.Time) models.NetworkMetricsSummary {
+	if worst <= 0 {
+		worst = defaultWorstLinks
+	}
+	summary := models.NetworkMetricsSummary{
+		Network:    network,
+		Nodes:      len(nodes),
+		WorstLinks: []models.LinkMetric{},
+		Gateways:   []models.GatewayLoad{},
+		Bandwidth:  []models.BandwidthUtilization{},
+	}
+	traffic := map[string]int64{}
+	var networkTraffic int64
+	for _, record := range usage {
+		if record.Network != network || record.Kind != models.TrafficUsageNode {
+			continue
+		}
+		traffic[record.ID] += record.Sent + record.Received
+		networkTraffic += record.Sent + record.Received
+	}
+	links := []models.LinkMetric{}
+	latencies := []int64{}
+	var percentUp float64
+	for i := range nodes {
+		node := &nodes[i]
+		metrics, err := GetMetrics(node.ID.String())
+		if err != nil {
+			continue
+		}
+		disconnected := 0
+		for peerID, metric := range metrics.Connectivity {
+			link := models.LinkMetric{
+				NodeID:    node.ID.String(),
+				NodeName:  metrics.NodeName,
+				PeerID:    peerID,
+				PeerName:  metric.NodeName,
+				Latency:   metric.Latency,
+				PercentUp: metric.PercentUp,
+				Connected: metric.Connected,
+			}
+			links = append(links, link)
+			percentUp += metric.PercentUp
+			if metric.Connected {
+				summary.ConnectedLinks++
+				latencies = append(latencies, metric.Latency)
+			} else {
+				disconnected++
+			}
+		}
+		if load, ok := gatewayLoad(node, metrics.NodeName, traffic[node.ID.String()], networkTraffic, now); ok {
+			load.DisconnectedPeers = disconnected
+			summary.Gateways = append(summary.Gateways, load)
+		}
+	}
+	summary.Links = len(links)
+	if len(links) > 0 {
+		summary.AvgPercentUp = percentUp / float64(len(links))
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	summary.MedianLatency = medianLatency(latencies)
+	summary.P95Latency = percentileLatency(latencies, 95)
+	sort.SliceStable(links, func(i, j int) bool {
+		if links[i].Connected != links[j].Connected {
+			return !links[i].Connected
+		}
+		if links[i].PercentUp != links[j].PercentUp {
+			return links[i].PercentUp < links[j].PercentUp
+		}
+		return links[i].Latency > links[j].Latency
+	})
+	if len(links) > worst {
+		links = links[:worst]
+	}
+	summary.WorstLinks = links
+	sort.Slice(summary.Gateways, func(i, j int) bool {
+		return summary.Gateways[i].TrafficToday > summary.Gateways[j].TrafficToday
+	})
+	return summary
+}
+
+// gatewayLoad - the load on a node if it is a gateway
+func gatewayLoad(node *models.Node, name string, traffic, networkTraffic int64, now time.Time) (models.GatewayLoad, bool) {
+	load := models.GatewayLoad{
+		NodeID:       node.ID.String(),
+		NodeName:     name,
+		Kinds:        []string{},
+		TrafficToday: traffic,
+	}
+	if node.IsIngressGateway {
+		load.Kinds = append(load.Kinds, "ingress")
+		if clients, err := GetExtClientsByID(node.ID.String(), node.Network); err == nil {
+			load.Peers += len(clients)
+		}
+	}
+	if node.IsEgressGateway {
+		load.Kinds = append(load.Kinds, "egress")
+	}
+	if node.IsRelay {
+		load.Kinds = append(load.Kinds, "relay")
+		load.Peers += len(node.RelayedNodes)
+	}
+	if len(load.Kinds) == 0 {
+		return load, false
+	}
+	midnight := now.Truncate(24 * time.Hour)
+	if elapsed := now.Sub(midnight).Seconds(); elapsed >= 1 {
+		load.Throughput = int64(float64(traffic*8) / elapsed)
+	}
+	if networkTraffic > 0 {
+		load.TrafficShare = float64(traffic) / float64(networkTraffic) * 100
+	}
+	return load, true
+}
+
+// medianLatency - the median of sorted latencies
+func medianLatency(sorted []int64) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+// percentileLatency - the nearest rank percentile of sorted latencies
+func percentileLatency(sorted []int64, percentile int) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (percentile*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
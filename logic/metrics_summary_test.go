@@ -0,0 +1,69 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarizeNetworkMetrics(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	relay := models.Node{}
+	relay.ID = uuid.New()
+	relay.Network = "skynet"
+	relay.IsRelay = true
+	relay.RelayedNodes = []string{"a", "b"}
+	node := models.Node{}
+	node.ID = uuid.New()
+	node.Network = "skynet"
+	assert.Nil(t, UpdateMetrics(relay.ID.String(), &models.Metrics{
+		NodeName: "relay",
+		Connectivity: map[string]models.Metric{
+			node.ID.String(): {NodeName: "node", Latency: 10, PercentUp: 100, Connected: true},
+			"gone":           {NodeName: "gone", Latency: 999, PercentUp: 20},
+		},
+	}))
+	assert.Nil(t, UpdateMetrics(node.ID.String(), &models.Metrics{
+		NodeName: "node",
+		Connectivity: map[string]models.Metric{
+			relay.ID.String(): {NodeName: "relay", Latency: 30, PercentUp: 90, Connected: true},
+		},
+	}))
+	defer DeleteMetrics(relay.ID.String())
+	defer DeleteMetrics(node.ID.String())
+	usage := []models.TrafficUsage{
+		{ID: relay.ID.String(), Kind: models.TrafficUsageNode, Network: "skynet", Sent: 600, Received: 150},
+		{ID: node.ID.String(), Kind: models.TrafficUsageNode, Network: "skynet", Sent: 150, Received: 100},
+	}
+	now := time.Now().UTC().Truncate(24 * time.Hour).Add(time.Hour)
+	summary := summarizeNetworkMetrics("skynet", []models.Node{relay, node}, usage, 2, now)
+	assert.Equal(t, 3, summary.Links)
+	assert.Equal(t, 2, summary.ConnectedLinks)
+	assert.Equal(t, int64(20), summary.MedianLatency)
+	assert.Equal(t, int64(30), summary.P95Latency)
+	assert.Equal(t, 2, len(summary.WorstLinks))
+	assert.Equal(t, "gone", summary.WorstLinks[0].PeerID)
+	assert.Equal(t, 1, len(summary.Gateways))
+	gateway := summary.Gateways[0]
+	assert.Equal(t, []string{"relay"}, gateway.Kinds)
+	assert.Equal(t, 2, gateway.Peers)
+	assert.Equal(t, 1, gateway.DisconnectedPeers)
+	assert.Equal(t, int64(750), gateway.TrafficToday)
+	assert.Equal(t, int64(750*8/3600), gateway.Throughput)
+	assert.Equal(t, float64(75), gateway.TrafficShare)
+}
+
+func TestPercentileLatency(t *testing.T) {
+	latencies := []int64{}
+	for i := int64(1); i <= 20; i++ {
+		latencies = append(latencies, i)
+	}
+	assert.Equal(t, int64(19), percentileLatency(latencies, 95))
+	assert.Equal(t, int64(10), medianLatency(latencies))
+	assert.Equal(t, int64(0), percentileLatency(nil, 95))
+}
@@ -0,0 +1,188 @@
+package logic
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gravitl/netmaker/models"
+)
+
+// scopes and collision handling of network naming policies
+const (
+	// NamingScopeNetwork - names are unique within a network
+	NamingScopeNetwork = "network"
+	// NamingScopeGlobal - names are unique across every network
+	NamingScopeGlobal = "global"
+	// NamingCollisionSuffix - a number is appended to a name that's taken
+	NamingCollisionSuffix = "suffix"
+	// NamingCollisionReject - a node whose name is taken can't join
+	NamingCollisionReject = "reject"
+)
+
+const (
+	// maxNodeNameLength - the longest name a node can have, the limit of a dns label
+	maxNodeNameLength = 63
+	// maxNodeNameSeq - the highest {seq} tried before a join is refused
+	maxNodeNameSeq = 9999
+)
+
+var (
+	// ErrNodeNameTaken - another node already has the name within the naming policy's scope
+	ErrNodeNameTaken = errors.New("node name is already taken")
+	// ErrInvalidNodeName - the name isn't allowed by the network's naming policy
+	ErrInvalidNodeName = errors.New("invalid node name, must be lowercase letters, digits and hyphens")
+)
+
+var nonDNSSafeChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// NodeName - the name of a node in its network, the host's name unless the node was given its own
+func NodeName(node *models.Node, host *models.Host) string {
+	if node.Name != "" {
+		return node.Name
+	}
+	return host.Name
+}
+
+// ApplyNamingPolicy - names a joining node after the template of its network's naming policy,
+// the address lock must be held so two joining nodes can't be given the same name
+func ApplyNamingPolicy(node *models.Node, host *models.Host, network *models.Network) error {
+	policy := network.NamingPolicy
+	if policy == nil || policy.Template == "" || node.Name != "" {
+		return nil
+	}
+	taken, err := takenNodeNames(node, policy)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(policy.Template, "{seq}") {
+		for seq := 1; seq <= maxNodeNameSeq; seq++ {
+			name := expandNameTemplate(policy, host, node, strconv.Itoa(seq))
+			if !taken[name] {
+				node.Name = name
+				return nil
+			}
+		}
+		return ErrNodeNameTaken
+	}
+	name := expandNameTemplate(policy, host, node, "")
+	if !taken[name] {
+		node.Name = name
+		return nil
+	}
+	if policy.OnCollision == NamingCollisionReject {
+		return ErrNodeNameTaken
+	}
+	for suffix := 2; ; suffix++ {
+		candidate := trimNodeName(name, len(strconv.Itoa(suffix))+1) + "-" + strconv.Itoa(suffix)
+		if !taken[candidate] {
+			node.Name = candidate
+			return nil
+		}
+	}
+}
+
+// RenameNode - gives a node a new name, checked against its network's naming policy,
+// and returns the name it had before
+func RenameNode(node *models.Node, name string) (string, error) {
+	host, err := GetHost(node.HostID.String())
+	if err != nil {
+		return "", err
+	}
+	network, err := GetNetwork(node.Network)
+	if err != nil {
+		return "", err
+	}
+	policy := network.NamingPolicy
+	if policy == nil {
+		policy = &models.NamingPolicy{}
+	}
+	if name == "" || len(name) > maxNodeNameLength || (policy.DNSSafe && dnsSafeName(name) != name) {
+		return "", ErrInvalidNodeName
+	}
+	oldName := NodeName(node, host)
+	if name == oldName {
+		return oldName, nil
+	}
+	// held like on join so a joining node can't be given the same name
+	unlock, err := Lock(addressLockName)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	taken, err := takenNodeNames(node, policy)
+	if err != nil {
+		return "", err
+	}
+	if taken[name] {
+		return "", ErrNodeNameTaken
+	}
+	node.Name = name
+	if err := UpsertNode(node); err != nil {
+		return "", err
+	}
+	return oldName, nil
+}
+
+// == private ==
+
+// takenNodeNames - the names of the other nodes within the naming policy's scope
+func takenNodeNames(node *models.Node, policy *models.NamingPolicy) (map[string]bool, error) {
+	taken := make(map[string]bool)
+	nodes, err := GetAllNodes()
+	if err != nil {
+		return nil, err
+	}
+	for i := range nodes {
+		other := nodes[i]
+		if other.ID == node.ID || other.PendingDelete {
+			continue
+		}
+		if policy.Scope != NamingScopeGlobal && other.Network != node.Network {
+			continue
+		}
+		host, err := GetHost(other.HostID.String())
+		if err != nil {
+			continue
+		}
+		taken[NodeName(&other, host)] = true
+	}
+	return taken, nil
+}
+
+// expandNameTemplate - fills in the placeholders of a naming policy's template,
+// {site} is taken from a node's "site:<name>" tag and falls back to the network
+func expandNameTemplate(policy *models.NamingPolicy, host *models.Host, node *models.Node, seq string) string {
+	site := node.Network
+	for _, tag := range node.Tags {
+		if strings.HasPrefix(tag, "site:") && len(tag) > len("site:") {
+			site = strings.TrimPrefix(tag, "site:")
+			break
+		}
+	}
+	name := strings.NewReplacer(
+		"{host}", host.Name,
+		"{network}", node.Network,
+		"{site}", site,
+		"{seq}", seq,
+	).Replace(policy.Template)
+	if policy.DNSSafe {
+		return dnsSafeName(name)
+	}
+	return trimNodeName(name, 0)
+}
+
+// dnsSafeName - lowercases a name and replaces anything but letters, digits and hyphens with a hyphen
+func dnsSafeName(name string) string {
+	name = nonDNSSafeChars.ReplaceAllString(strings.ToLower(name), "-")
+	return strings.Trim(trimNodeName(strings.Trim(name, "-"), 0), "-")
+}
+
+// trimNodeName - shortens a name so reserved characters can still be added within a dns label
+func trimNodeName(name string, reserved int) string {
+	if limit := maxNodeNameLength - reserved; len(name) > limit {
+		return name[:limit]
+	}
+	return name
+}
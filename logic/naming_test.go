@@ -0,0 +1,32 @@
+package logic
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandNameTemplate(t *testing.T) {
+	host := &models.Host{Name: "Build_Runner.local"}
+	node := &models.Node{CommonNode: models.CommonNode{Network: "skynet"}}
+	t.Run("Placeholders", func(t *testing.T) {
+		policy := &models.NamingPolicy{Template: "{site}-{host}-{seq}"}
+		assert.Equal(t, "skynet-Build_Runner.local-3", expandNameTemplate(policy, host, node, "3"))
+	})
+	t.Run("SiteTag", func(t *testing.T) {
+		tagged := &models.Node{CommonNode: models.CommonNode{Network: "skynet"}, Tags: []string{"ci", "site:ams1"}}
+		policy := &models.NamingPolicy{Template: "{site}-{seq}"}
+		assert.Equal(t, "ams1-1", expandNameTemplate(policy, host, tagged, "1"))
+	})
+	t.Run("DNSSafe", func(t *testing.T) {
+		policy := &models.NamingPolicy{Template: "_{host}_", DNSSafe: true}
+		assert.Equal(t, "build-runner-local", expandNameTemplate(policy, host, node, ""))
+	})
+	t.Run("Length", func(t *testing.T) {
+		long := &models.Host{Name: strings.Repeat("a", 100)}
+		policy := &models.NamingPolicy{Template: "{host}"}
+		assert.Len(t, expandNameTemplate(policy, long, node, ""), maxNodeNameLength)
+	})
+}
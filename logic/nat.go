@@ -0,0 +1,88 @@
+package logic
+
+import (
+	"sort"
+
+	"github.com/gravitl/netmaker/models"
+)
+
+// GetHostNatReport - reports a host's NAT type, public endpoint and whether it reached each of its peers
+// as of its last check-in, recommending a relay when hole punching failed behind NAT
+func GetHostNatReport(host *models.Host) (models.HostNatReport, error) {
+	report := models.HostNatReport{
+		HostID:             host.ID.String(),
+		Name:               host.Name,
+		NatType:            host.NatType,
+		EndpointIP:         host.EndpointIP.String(),
+		ListenPort:         host.ListenPort,
+		WgPublicListenPort: host.WgPublicListenPort,
+		Peers:              []models.PeerNatStatus{},
+	}
+	if host.TurnEndpoint != nil {
+		report.TurnEndpoint = host.TurnEndpoint.String()
+	}
+	for _, nodeID := range host.Nodes {
+		node, err := GetNodeByID(nodeID)
+		if err != nil {
+			continue
+		}
+		peers, err := GetNetworkNodes(node.Network)
+		if err != nil {
+			return report, err
+		}
+		metrics, err := GetMetrics(node.ID.String())
+		if err != nil {
+			metrics = &models.Metrics{}
+		}
+		for i := range peers {
+			peer := &peers[i]
+			if peer.HostID == host.ID || peer.PendingDelete {
+				continue
+			}
+			peerHost, err := GetHost(peer.HostID.String())
+			if err != nil {
+				continue
+			}
+			metric := metrics.Connectivity[peer.ID.String()]
+			status := models.PeerNatStatus{
+				PeerHostID:  peerHost.ID.String(),
+				PeerName:    peerHost.Name,
+				Network:     node.Network,
+				PeerNatType: peerHost.NatType,
+				Connection:  peerConnection(&node, peer, metrics),
+				Endpoint:    metric.Endpoint,
+				Latency:     metric.Latency,
+				PercentUp:   metric.PercentUp,
+			}
+			// both ends behind NAT failing to connect won't be fixed by retrying
+			if status.Connection == models.PeerConnectionFailed &&
+				host.NatType == models.NAT_Types.BehindNAT && peerHost.NatType == models.NAT_Types.BehindNAT {
+				report.RelayRecommended = true
+			}
+			report.Peers = append(report.Peers, status)
+		}
+	}
+	sort.Slice(report.Peers, func(i, j int) bool {
+		if report.Peers[i].Network != report.Peers[j].Network {
+			return report.Peers[i].Network < report.Peers[j].Network
+		}
+		return report.Peers[i].PeerName < report.Peers[j].PeerName
+	})
+	return report, nil
+}
+
+// peerConnection - how node reaches peer, from the metrics node reported
+func peerConnection(node, peer *models.Node, metrics *models.Metrics) string {
+	if (node.IsRelayed && node.RelayedBy != peer.ID.String()) || (peer.IsRelayed && peer.RelayedBy != node.ID.String()) ||
+		metrics.FailoverPeers[peer.ID.String()] != "" {
+		return models.PeerConnectionRelayed
+	}
+	metric, reported := metrics.Connectivity[peer.ID.String()]
+	if !reported {
+		return models.PeerConnectionUnknown
+	}
+	if metric.Connected {
+		return models.PeerConnectionDirect
+	}
+	return models.PeerConnectionFailed
+}
@@ -0,0 +1,75 @@
+package logic
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetHostNatReport(t *testing.T) {
+	database.InitializeDatabase()
+	// closed after the hosts are cleaned up
+	t.Cleanup(database.CloseDB)
+	host := natHost(t, "alpha", models.NAT_Types.BehindNAT)
+	direct, failed, relayed, silent := natHost(t, "bravo", models.NAT_Types.Public), natHost(t, "charlie", models.NAT_Types.BehindNAT),
+		natHost(t, "delta", models.NAT_Types.BehindNAT), natHost(t, "echo", models.NAT_Types.Public)
+	node, _ := GetNodeByID(host.Nodes[0])
+	nodeID := func(h *models.Host) string { return h.Nodes[0] }
+	assert.Nil(t, UpdateMetrics(node.ID.String(), &models.Metrics{
+		Connectivity: map[string]models.Metric{
+			nodeID(direct): {Connected: true, Endpoint: "198.51.100.2:51821", Latency: 12, PercentUp: 100},
+			nodeID(failed): {Connected: false},
+		},
+		FailoverPeers: map[string]string{nodeID(relayed): "failover"},
+	}))
+	defer database.DeleteRecord(database.METRICS_TABLE_NAME, node.ID.String())
+
+	t.Run("Peers", func(t *testing.T) {
+		report, err := GetHostNatReport(host)
+		assert.Nil(t, err)
+		assert.Equal(t, models.NAT_Types.BehindNAT, report.NatType)
+		assert.Equal(t, "198.51.100.1", report.EndpointIP)
+		connections := make(map[string]string)
+		for _, peer := range report.Peers {
+			connections[peer.PeerName] = peer.Connection
+		}
+		assert.Equal(t, map[string]string{
+			"bravo":   models.PeerConnectionDirect,
+			"charlie": models.PeerConnectionFailed,
+			"delta":   models.PeerConnectionRelayed,
+			"echo":    models.PeerConnectionUnknown,
+		}, connections)
+		// sorted by name
+		assert.Equal(t, "bravo", report.Peers[0].PeerName)
+		assert.Equal(t, "198.51.100.2:51821", report.Peers[0].Endpoint)
+		assert.Equal(t, int64(12), report.Peers[0].Latency)
+		assert.Equal(t, silent.ID.String(), report.Peers[3].PeerHostID)
+		// both behind NAT and failing
+		assert.True(t, report.RelayRecommended)
+	})
+	t.Run("PublicPeerFailed", func(t *testing.T) {
+		failed.NatType = models.NAT_Types.Public
+		assert.Nil(t, UpsertHost(failed))
+		report, err := GetHostNatReport(host)
+		assert.Nil(t, err)
+		assert.False(t, report.RelayRecommended)
+	})
+}
+
+// natHost - stores a host with the nat type and a node in the nat test network
+func natHost(t *testing.T, name, natType string) *models.Host {
+	host := models.Host{ID: uuid.New(), Name: name, NatType: natType, EndpointIP: net.ParseIP("198.51.100.1"), ListenPort: 51821}
+	node := models.Node{CommonNode: models.CommonNode{ID: uuid.New(), HostID: host.ID, Network: "natnet", Connected: true}}
+	host.Nodes = []string{node.ID.String()}
+	assert.Nil(t, UpsertNode(&node))
+	assert.Nil(t, UpsertHost(&host))
+	t.Cleanup(func() {
+		database.DeleteRecord(database.NODES_TABLE_NAME, node.ID.String())
+		database.DeleteRecord(database.HOSTS_TABLE_NAME, host.ID.String())
+	})
+	return &host
+}
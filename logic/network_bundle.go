@@ -0,0 +1,272 @@
+package logic
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic/acls"
+	"github.com/gravitl/netmaker/logic/acls/nodeacls"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+)
+
+// ExportNetworkBundle - bundles a network with its nodes, ACLs, custom DNS and enrollment keys
+func ExportNetworkBundle(netID string) (models.NetworkBundle, error) {
+	network, err := GetNetwork(netID)
+	if err != nil {
+		return models.NetworkBundle{}, err
+	}
+	bundle := models.NetworkBundle{
+		Version:        servercfg.GetVersion(),
+		Exported:       time.Now().UTC(),
+		Network:        network,
+		Nodes:          []models.BundleNode{},
+		ACLs:           map[string]map[string]byte{},
+		DNS:            []models.DNSEntry{},
+		EnrollmentKeys: []models.BundleEnrollmentKey{},
+	}
+	nodes, err := GetNetworkNodes(netID)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return bundle, err
+	}
+	names := hostNames()
+	for _, node := range nodes {
+		bundle.Nodes = append(bundle.Nodes, models.BundleNode{
+			ID:                  node.ID.String(),
+			HostID:              node.HostID.String(),
+			HostName:            names[node.HostID.String()],
+			Name:                node.Name,
+			Address:             exportIP(node.Address.IP),
+			Address6:            exportIP(node.Address6.IP),
+			Tags:                node.Tags,
+			DefaultACL:          node.DefaultACL,
+			IsEgressGateway:     node.IsEgressGateway,
+			EgressGatewayRanges: node.EgressGatewayRanges,
+			IsIngressGateway:    node.IsIngressGateway,
+			IsRelay:             node.IsRelay,
+		})
+	}
+	sort.Slice(bundle.Nodes, func(i, j int) bool {
+		return bundle.Nodes[i].HostName < bundle.Nodes[j].HostName
+	})
+	networkACL, err := nodeacls.FetchAllACLs(nodeacls.NetworkID(netID))
+	if err != nil && !database.IsEmptyRecord(err) {
+		return bundle, err
+	}
+	for nodeID, acl := range networkACL {
+		peers := map[string]byte{}
+		for peerID, value := range acl {
+			peers[string(peerID)] = value
+		}
+		bundle.ACLs[string(nodeID)] = peers
+	}
+	dns, err := GetCustomDNS(netID)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return bundle, err
+	}
+	bundle.DNS = append(bundle.DNS, dns...)
+	keys, err := GetAllEnrollmentKeys()
+	if err != nil && !database.IsEmptyRecord(err) {
+		return bundle, err
+	}
+	for _, key := range keys {
+		if !StringSliceContains(key.Networks, netID) || !key.IsValid() {
+			continue
+		}
+		bundle.EnrollmentKeys = append(bundle.EnrollmentKeys, models.BundleEnrollmentKey{
+			Value:         key.Value,
+			Type:          key.Type,
+			Expiration:    key.Expiration,
+			UsesRemaining: key.UsesRemaining,
+			Unlimited:     key.Unlimited,
+			Tags:          key.Tags,
+			Ephemeral:     key.Ephemeral,
+			EphemeralTTL:  key.EphemeralTTL,
+		})
+	}
+	return bundle, nil
+}
+
+// ImportNetworkBundle - creates the network of a bundle, named name when set and within tenant when set,
+// then brings back the nodes whose hosts are on this server under new ids, their ACLs, egress gateways,
+// custom DNS and enrollment keys, the latter with new values
+func ImportNetworkBundle(bundle *models.NetworkBundle, name, tenant string) (models.NetworkBundleImport, error) {
+	network := bundle.Network
+	if name != "" {
+		network.NetID = name
+	}
+	result := models.NetworkBundleImport{Network: network.NetID, Mappings: []models.BundleMapping{}}
+	if tenant != "" {
+		network.Tenant = tenant
+	} else if network.Tenant != "" {
+		if _, err := GetTenant(network.Tenant); err != nil {
+			result.Mappings = append(result.Mappings, models.BundleMapping{Kind: "tenant", Source: network.Tenant, Reason: "tenant is not on this server, the network is imported outside of tenants"})
+			network.Tenant = ""
+		}
+	}
+	if _, err := GetNetwork(network.NetID); err == nil {
+		return result, fmt.Errorf("network %s already exists", network.NetID)
+	}
+	network, err := CreateNetwork(network)
+	if err != nil {
+		return result, err
+	}
+	result.Mappings = append(result.Mappings, models.BundleMapping{Kind: "network", Source: bundle.Network.NetID, Target: network.NetID, Imported: true})
+
+	// old node id to new node id
+	imported := map[string]string{}
+	for i := range bundle.Nodes {
+		mapping, node := importBundleNode(&network, &bundle.Nodes[i])
+		result.Mappings = append(result.Mappings, mapping)
+		if node != nil {
+			imported[bundle.Nodes[i].ID] = node.ID.String()
+		}
+	}
+	if len(imported) > 0 {
+		result.Mappings = append(result.Mappings, importBundleACLs(network.NetID, bundle.ACLs, imported))
+	}
+	for _, entry := range bundle.DNS {
+		entry.Network = network.NetID
+		mapping := models.BundleMapping{Kind: "dns", Source: entry.Name, Target: entry.Name}
+		if err := ValidateDNSCreate(entry); err != nil {
+			mapping.Reason = err.Error()
+		} else if _, err := CreateDNS(entry); err != nil {
+			mapping.Reason = err.Error()
+		} else {
+			mapping.Imported = true
+		}
+		result.Mappings = append(result.Mappings, mapping)
+	}
+	for i := range bundle.EnrollmentKeys {
+		result.Mappings = append(result.Mappings, importBundleEnrollmentKey(network.NetID, network.Tenant, &bundle.EnrollmentKeys[i]))
+	}
+	return result, nil
+}
+
+// == private ==
+
+// importBundleNode - joins the host of a bundled node to the network when the host is on this server,
+// found by id or else by name, keeping its addresses, tags and egress ranges
+func importBundleNode(network *models.Network, bundled *models.BundleNode) (models.BundleMapping, *models.Node) {
+	mapping := models.BundleMapping{Kind: "node", Source: bundled.ID}
+	host := bundleNodeHost(bundled)
+	if host == nil {
+		mapping.Reason = fmt.Sprintf("host %s is not on this server, it can join with an imported enrollment key", bundled.HostName)
+		return mapping, nil
+	}
+	node := models.Node{}
+	node.Server = servercfg.GetServer()
+	node.Network = network.NetID
+	node.HostID = host.ID
+	node.Name = bundled.Name
+	node.Tags = bundled.Tags
+	node.DefaultACL = bundled.DefaultACL
+	if _, cidr, err := net.ParseCIDR(network.AddressRange); err == nil && bundled.Address != "" {
+		node.Address = net.IPNet{IP: net.ParseIP(bundled.Address), Mask: cidr.Mask}
+	}
+	if _, cidr, err := net.ParseCIDR(network.AddressRange6); err == nil && bundled.Address6 != "" {
+		node.Address6 = net.IPNet{IP: net.ParseIP(bundled.Address6), Mask: cidr.Mask}
+	}
+	if err := AssociateNodeToHost(&node, host); err != nil {
+		mapping.Reason = err.Error()
+		return mapping, nil
+	}
+	mapping.Target = node.ID.String()
+	mapping.Imported = true
+	if bundled.IsEgressGateway && len(bundled.EgressGatewayRanges) > 0 {
+		egress, err := CreateEgressGateway(models.EgressGatewayRequest{
+			NodeID: node.ID.String(),
+			NetID:  node.Network,
+			Ranges: bundled.EgressGatewayRanges,
+		})
+		if err != nil {
+			mapping.Reason = "egress gateway not imported: " + err.Error()
+		} else {
+			node = egress
+		}
+	}
+	if bundled.IsIngressGateway || bundled.IsRelay {
+		mapping.Reason = "ingress gateway and relay roles are not imported"
+	}
+	return mapping, &node
+}
+
+// bundleNodeHost - the host of a bundled node on this server, by id or else by name
+func bundleNodeHost(bundled *models.BundleNode) *models.Host {
+	if host, err := GetHost(bundled.HostID); err == nil {
+		return host
+	}
+	if bundled.HostName == "" {
+		return nil
+	}
+	hosts, err := GetAllHosts()
+	if err != nil {
+		return nil
+	}
+	for i := range hosts {
+		if hosts[i].Name == bundled.HostName {
+			return &hosts[i]
+		}
+	}
+	return nil
+}
+
+// importBundleACLs - applies the bundled ACLs between the nodes that were imported
+func importBundleACLs(network string, bundled map[string]map[string]byte, imported map[string]string) models.BundleMapping {
+	mapping := models.BundleMapping{Kind: "acls", Source: network, Target: network}
+	container, err := nodeacls.FetchAllACLs(nodeacls.NetworkID(network))
+	if err != nil {
+		mapping.Reason = err.Error()
+		return mapping
+	}
+	for oldID, peers := range bundled {
+		for oldPeerID, value := range peers {
+			newID, ok := imported[oldID]
+			newPeerID, peerOK := imported[oldPeerID]
+			if !ok || !peerOK || value == acls.NotPresent {
+				continue
+			}
+			container.ChangeAccess(acls.AclID(newID), acls.AclID(newPeerID), value)
+		}
+	}
+	if _, err = container.Save(acls.ContainerID(network)); err != nil {
+		mapping.Reason = err.Error()
+		return mapping
+	}
+	mapping.Imported = true
+	return mapping
+}
+
+// importBundleEnrollmentKey - recreates a bundled enrollment key for the network with a new value,
+// the target is its token
+func importBundleEnrollmentKey(network, tenant string, bundled *models.BundleEnrollmentKey) models.BundleMapping {
+	mapping := models.BundleMapping{Kind: "enrollmentkey", Source: bundled.Value}
+	uses := 0
+	if bundled.Type == models.Uses {
+		uses = bundled.UsesRemaining
+	}
+	expiration := time.Time{}
+	if bundled.Type == models.TimeExpiration {
+		expiration = bundled.Expiration
+	}
+	key, err := CreateTenantEnrollmentKey(tenant, uses, expiration, []string{network}, bundled.Tags, bundled.Unlimited)
+	if err != nil {
+		mapping.Reason = err.Error()
+		return mapping
+	}
+	if bundled.Ephemeral {
+		if err = SetEnrollmentKeyEphemeral(key, time.Duration(bundled.EphemeralTTL)*time.Second); err != nil {
+			mapping.Reason = "not ephemeral: " + err.Error()
+		}
+	}
+	if err = Tokenize(key, servercfg.GetAPIHost()); err != nil {
+		mapping.Target = key.Value
+	} else {
+		mapping.Target = key.Token
+	}
+	mapping.Imported = true
+	return mapping
+}
@@ -0,0 +1,56 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkBundle(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	// networks are created with their users, at least one has to exist
+	assert.Nil(t, CreateUser(&models.User{UserName: "bundleadmin", Password: "password", IsAdmin: true}))
+	defer DeleteUser("bundleadmin")
+	defer func() {
+		for _, netID := range []string{"bundletest", "bundlecopy"} {
+			DeleteDNS("nas", netID)
+			DeleteNetwork(netID)
+		}
+		keys, _ := GetAllEnrollmentKeys()
+		for _, key := range keys {
+			if StringSliceContains(key.Networks, "bundletest") || StringSliceContains(key.Networks, "bundlecopy") {
+				DeleteEnrollmentKey(key.Value)
+			}
+		}
+	}()
+	network, err := CreateNetwork(models.Network{NetID: "bundletest", AddressRange: "10.203.0.0/24"})
+	assert.Nil(t, err)
+	_, err = CreateDNS(models.DNSEntry{Name: "nas", Address: "10.203.0.50", Network: network.NetID})
+	assert.Nil(t, err)
+	_, err = CreateEnrollmentKey(0, time.Now().Add(time.Hour), []string{network.NetID}, nil, false)
+	assert.Nil(t, err)
+	bundle, err := ExportNetworkBundle(network.NetID)
+	assert.Nil(t, err)
+	assert.Equal(t, network.AddressRange, bundle.Network.AddressRange)
+	assert.Len(t, bundle.DNS, 1)
+	assert.Len(t, bundle.EnrollmentKeys, 1)
+	t.Run("Import", func(t *testing.T) {
+		result, err := ImportNetworkBundle(&bundle, "bundlecopy", "")
+		assert.Nil(t, err)
+		assert.Equal(t, "bundlecopy", result.Network)
+		for _, mapping := range result.Mappings {
+			assert.True(t, mapping.Imported, mapping.Kind, mapping.Reason)
+		}
+		dns, err := GetCustomDNS("bundlecopy")
+		assert.Nil(t, err)
+		assert.Len(t, dns, 1)
+	})
+	t.Run("Exists", func(t *testing.T) {
+		_, err := ImportNetworkBundle(&bundle, "", "")
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,143 @@
+package logic
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// networkEventRetentionDays - how long the events of a network are kept
+	networkEventRetentionDays = 90
+	// defaultNetworkEventLimit - events returned per page when no limit is asked for
+	defaultNetworkEventLimit = 100
+	// maxNetworkEventLimit - the most events returned per page
+	maxNetworkEventLimit = 1000
+	// networkEventPageSize - events read at a time when pruning
+	networkEventPageSize = 500
+)
+
+// RecordNetworkEvent - adds an event to the feed of a network, node is optional
+func RecordNetworkEvent(network, kind string, node *models.Node, message string) {
+	event := models.NetworkEvent{
+		ID:      uuid.New().String(),
+		Network: network,
+		Time:    time.Now().UTC(),
+		Kind:    kind,
+		Message: message,
+	}
+	if node != nil {
+		event.NodeID = node.ID.String()
+		if host, err := GetHost(node.HostID.String()); err == nil {
+			event.Host = host.Name
+		}
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	if err := database.Insert(event.ID, string(data), database.NETWORK_EVENTS_TABLE_NAME); err != nil {
+		slog.Error("failed to record network event", "network", network, "kind", kind, "error", err)
+	}
+}
+
+// GetNetworkEvents - fetches a page of a network's events matching filter, oldest first
+func GetNetworkEvents(network string, filter models.NetworkEventFilter) (models.NetworkEventPage, error) {
+	page := models.NetworkEventPage{Events: []models.NetworkEvent{}}
+	records, err := database.FetchRecordsByField(database.NETWORK_EVENTS_TABLE_NAME, "network", network)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return page, nil
+		}
+		return page, err
+	}
+	events := []models.NetworkEvent{}
+	for _, value := range records {
+		var event models.NetworkEvent
+		if err := json.Unmarshal([]byte(value), &event); err != nil {
+			continue
+		}
+		if (!filter.From.IsZero() && event.Time.Before(filter.From)) || (!filter.To.IsZero() && event.Time.After(filter.To)) {
+			continue
+		}
+		if len(filter.Kinds) > 0 && !StringSliceContains(filter.Kinds, event.Kind) {
+			continue
+		}
+		events = append(events, event)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultNetworkEventLimit
+	}
+	if limit > maxNetworkEventLimit {
+		limit = maxNetworkEventLimit
+	}
+	page.Total = len(events)
+	if filter.Offset >= len(events) {
+		return page, nil
+	}
+	end := filter.Offset + limit
+	if end < len(events) {
+		page.Next = end
+	} else {
+		end = len(events)
+	}
+	page.Events = events[filter.Offset:end]
+	return page, nil
+}
+
+// == private ==
+
+// deleteNetworkEvents - removes the events of a deleted network
+func deleteNetworkEvents(network string) error {
+	return removeNetworkEvents(func(event models.NetworkEvent) bool {
+		return event.Network == network
+	})
+}
+
+// pruneNetworkEvents - removes network events past their retention period, run by the daily hooks
+func pruneNetworkEvents() error {
+	cutoff := time.Now().UTC().AddDate(0, 0, -networkEventRetentionDays)
+	return removeNetworkEvents(func(event models.NetworkEvent) bool {
+		return event.Time.Before(cutoff)
+	})
+}
+
+// removeNetworkEvents - deletes the events matching remove, along with any that can't be read,
+// a page at a time so the whole feed is never held in memory
+func removeNetworkEvents(remove func(models.NetworkEvent) bool) error {
+	offset := 0
+	for {
+		records, err := database.FetchRecordsPage(database.NETWORK_EVENTS_TABLE_NAME, offset, networkEventPageSize)
+		if err != nil {
+			if database.IsEmptyRecord(err) {
+				return nil
+			}
+			return err
+		}
+		kept := 0
+		for key, value := range records {
+			var event models.NetworkEvent
+			if err := json.Unmarshal([]byte(value), &event); err != nil || remove(event) {
+				if err := database.DeleteRecord(database.NETWORK_EVENTS_TABLE_NAME, key); err != nil {
+					return err
+				}
+				continue
+			}
+			kept++
+		}
+		if len(records) < networkEventPageSize {
+			return nil
+		}
+		// the deleted events no longer take up places before the next page
+		offset += kept
+	}
+}
@@ -0,0 +1,62 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetNetworkEvents(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	database.DeleteAllRecords(database.NETWORK_EVENTS_TABLE_NAME)
+	for _, kind := range []string{models.NetworkEventJoin, models.NetworkEventGateway, models.NetworkEventJoin, models.NetworkEventLeave} {
+		RecordNetworkEvent("skynet", kind, nil, kind)
+	}
+	RecordNetworkEvent("othernet", models.NetworkEventJoin, nil, "join")
+	t.Run("Pages", func(t *testing.T) {
+		page, err := GetNetworkEvents("skynet", models.NetworkEventFilter{Limit: 3})
+		assert.Nil(t, err)
+		assert.Equal(t, 4, page.Total)
+		assert.Equal(t, 3, len(page.Events))
+		assert.Equal(t, 3, page.Next)
+		page, err = GetNetworkEvents("skynet", models.NetworkEventFilter{Offset: page.Next, Limit: 3})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(page.Events))
+		assert.Equal(t, models.NetworkEventLeave, page.Events[0].Kind)
+		assert.Equal(t, 0, page.Next)
+	})
+	t.Run("Kinds", func(t *testing.T) {
+		page, err := GetNetworkEvents("skynet", models.NetworkEventFilter{Kinds: []string{models.NetworkEventJoin}})
+		assert.Nil(t, err)
+		assert.Equal(t, 2, page.Total)
+	})
+	t.Run("Deleted", func(t *testing.T) {
+		assert.Nil(t, deleteNetworkEvents("skynet"))
+		page, err := GetNetworkEvents("skynet", models.NetworkEventFilter{})
+		assert.Nil(t, err)
+		assert.Equal(t, 0, page.Total)
+		page, err = GetNetworkEvents("othernet", models.NetworkEventFilter{})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, page.Total)
+	})
+	t.Run("DeletedAcrossPages", func(t *testing.T) {
+		database.DeleteAllRecords(database.NETWORK_EVENTS_TABLE_NAME)
+		for i := 0; i < networkEventPageSize+50; i++ {
+			network := "skynet"
+			if i%2 == 0 {
+				network = "othernet"
+			}
+			RecordNetworkEvent(network, models.NetworkEventJoin, nil, "join")
+		}
+		assert.Nil(t, deleteNetworkEvents("skynet"))
+		page, err := GetNetworkEvents("skynet", models.NetworkEventFilter{})
+		assert.Nil(t, err)
+		assert.Equal(t, 0, page.Total)
+		page, err = GetNetworkEvents("othernet", models.NetworkEventFilter{})
+		assert.Nil(t, err)
+		assert.Equal(t, (networkEventPageSize+50)/2, page.Total)
+	})
+}
@@ -0,0 +1,51 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gravitl/netmaker/models"
+)
+
+// ErrNetworkFeatureDisabled - a request uses a capability turned off on its network
+var ErrNetworkFeatureDisabled = errors.New("feature is disabled")
+
+// GetNetworkFeatures - the capabilities usable on a network
+func GetNetworkFeatures(network *models.Network) models.NetworkFeatures {
+	if network.Features == nil {
+		return models.NetworkFeatures{ExtClients: true, Egress: true, Relays: true}
+	}
+	return *network.Features
+}
+
+// SetNetworkFeatures - sets the capabilities usable on a network
+func SetNetworkFeatures(netID string, features models.NetworkFeatures) (models.Network, error) {
+	network, err := GetNetwork(netID)
+	if err != nil {
+		return models.Network{}, err
+	}
+	current := network
+	network.Features = &features
+	if _, _, _, _, _, err = UpdateNetwork(&current, &network); err != nil {
+		return models.Network{}, err
+	}
+	return network, nil
+}
+
+// CheckNetworkFeature - returns ErrNetworkFeatureDisabled when a capability is turned off on a network
+func CheckNetworkFeature(netID, feature string) error {
+	network, err := GetNetwork(netID)
+	if err != nil {
+		return err
+	}
+	features := GetNetworkFeatures(&network)
+	enabled := map[string]bool{
+		models.NetworkFeatureExtClients: features.ExtClients,
+		models.NetworkFeatureEgress:     features.Egress,
+		models.NetworkFeatureRelays:     features.Relays,
+	}
+	if !enabled[feature] {
+		return fmt.Errorf("%w: %s on network %s", ErrNetworkFeatureDisabled, feature, netID)
+	}
+	return nil
+}
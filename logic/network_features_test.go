@@ -0,0 +1,29 @@
+package logic
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckNetworkFeature(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	network := models.Network{NetID: "features", AddressRange: "10.90.0.0/24"}
+	data, _ := json.Marshal(network)
+	assert.Nil(t, database.Insert(network.NetID, string(data), database.NETWORKS_TABLE_NAME))
+	defer database.DeleteRecord(database.NETWORKS_TABLE_NAME, network.NetID)
+
+	for _, feature := range []string{models.NetworkFeatureExtClients, models.NetworkFeatureEgress, models.NetworkFeatureRelays} {
+		assert.Nil(t, CheckNetworkFeature(network.NetID, feature), "networks without features allow them all")
+	}
+	network.Features = &models.NetworkFeatures{ExtClients: true}
+	data, _ = json.Marshal(network)
+	assert.Nil(t, database.Insert(network.NetID, string(data), database.NETWORKS_TABLE_NAME))
+	assert.Nil(t, CheckNetworkFeature(network.NetID, models.NetworkFeatureExtClients))
+	assert.ErrorIs(t, CheckNetworkFeature(network.NetID, models.NetworkFeatureEgress), ErrNetworkFeatureDisabled)
+	assert.ErrorIs(t, CheckNetworkFeature(network.NetID, models.NetworkFeatureRelays), ErrNetworkFeatureDisabled)
+}
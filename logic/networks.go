@@ -12,23 +12,96 @@ import (
 	"github.com/c-robinson/iplib"
 	validator "github.com/go-playground/validator/v10"
 	"github.com/gravitl/netmaker/database"
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic/acls/nodeacls"
 	"github.com/gravitl/netmaker/logic/pro"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/validation"
+	"golang.org/x/exp/slog"
 )
 
+var (
+	networkCacheMutex  = &sync.RWMutex{}
+	networkCacheMap    = make(map[string]models.Network)
+	networkCacheLoaded bool
+	// networkCacheVersion - counts the changes made to the cache, see loadNetworksIntoCache
+	networkCacheVersion int64
+	networkCacheStats   cacheStats
+)
+
+// getNetworksFromCache - returns all networks, if the cache holds all of them
+func getNetworksFromCache() (networks []models.Network) {
+	networkCacheMutex.RLock()
+	if networkCacheLoaded {
+		for _, network := range networkCacheMap {
+			networks = append(networks, network)
+		}
+	}
+	networkCacheMutex.RUnlock()
+	networkCacheStats.record(len(networks) != 0)
+	return
+}
+
+func getNetworkFromCache(netID string) (network models.Network, ok bool) {
+	networkCacheMutex.RLock()
+	network, ok = networkCacheMap[netID]
+	networkCacheMutex.RUnlock()
+	networkCacheStats.record(ok)
+	return
+}
+
+func storeNetworkInCache(network models.Network) {
+	networkCacheMutex.Lock()
+	networkCacheMap[network.NetID] = network
+	networkCacheVersion++
+	networkCacheMutex.Unlock()
+}
+
+func deleteNetworkFromCache(netID string) {
+	networkCacheMutex.Lock()
+	delete(networkCacheMap, netID)
+	networkCacheVersion++
+	networkCacheMutex.Unlock()
+}
+
+func getNetworkCacheVersion() int64 {
+	networkCacheMutex.RLock()
+	defer networkCacheMutex.RUnlock()
+	return networkCacheVersion
+}
+
+// loadNetworksIntoCache - fills the cache with the networks read at version, unless a write changed the cache since
+func loadNetworksIntoCache(networks map[string]models.Network, version int64) {
+	networkCacheMutex.Lock()
+	if networkCacheVersion == version {
+		networkCacheMap = networks
+		networkCacheLoaded = true
+	}
+	networkCacheMutex.Unlock()
+}
+
+func clearNetworkCache() {
+	networkCacheMutex.Lock()
+	networkCacheMap = make(map[string]models.Network)
+	networkCacheLoaded = false
+	networkCacheVersion++
+	networkCacheMutex.Unlock()
+}
+
 // GetNetworks - returns all networks from database
 func GetNetworks() ([]models.Network, error) {
 	var networks []models.Network
+	if networks = getNetworksFromCache(); len(networks) != 0 {
+		return networks, nil
+	}
 
+	version := getNetworkCacheVersion()
 	collection, err := database.FetchRecords(database.NETWORKS_TABLE_NAME)
 
 	if err != nil {
 		return networks, err
 	}
 
+	networksMap := make(map[string]models.Network, len(collection))
 	for _, value := range collection {
 		var network models.Network
 		if err := json.Unmarshal([]byte(value), &network); err != nil {
@@ -36,7 +109,9 @@ func GetNetworks() ([]models.Network, error) {
 		}
 		// add network our array
 		networks = append(networks, network)
+		networksMap[network.NetID] = network
 	}
+	loadNetworksIntoCache(networksMap, version)
 
 	return networks, err
 }
@@ -46,13 +121,22 @@ func DeleteNetwork(network string) error {
 	// remove ACL for network
 	err := nodeacls.DeleteACLContainer(nodeacls.NetworkID(network))
 	if err != nil {
-		logger.Log(1, "failed to remove the node acls during network delete for network,", network)
+		slog.Error("failed to remove the node acls during network delete for network", "network", network)
 	}
 	nodeCount, err := GetNetworkNonServerNodeCount(network)
 	if nodeCount == 0 || database.IsEmptyRecord(err) {
 		// delete server nodes first then db records
 		if err = pro.RemoveAllNetworkUsers(network); err != nil {
-			logger.Log(0, "failed to remove network users on network delete for network", network, err.Error())
+			slog.Error("failed to remove network users on network delete for network", "network", network, "error", err)
+		}
+		if err = deleteNetworkEvents(network); err != nil {
+			slog.Error("failed to remove events on network delete for network", "network", network, "error", err)
+		}
+		if err = deleteNetworkStaticPeers(network); err != nil {
+			slog.Error("failed to remove static peers on network delete for network", "network", network, "error", err)
+		}
+		if err = database.DeleteRecord(database.NETWORK_LOCKDOWNS_TABLE_NAME, network); err != nil && !database.IsEmptyRecord(err) {
+			slog.Error("failed to remove lockdown on network delete for network", "network", network, "error", err)
 		}
 		return database.DeleteRecord(database.NETWORKS_TABLE_NAME, network)
 	}
@@ -123,6 +207,9 @@ func GetNetworkNonServerNodeCount(networkName string) (int, error) {
 // GetParentNetwork - get parent network
 func GetParentNetwork(networkname string) (models.Network, error) {
 
+	if network, ok := getNetworkFromCache(networkname); ok {
+		return network, nil
+	}
 	var network models.Network
 	networkData, err := database.FetchRecord(database.NETWORKS_TABLE_NAME, networkname)
 	if err != nil {
@@ -137,6 +224,9 @@ func GetParentNetwork(networkname string) (models.Network, error) {
 // GetParentNetwork - get parent network
 func GetNetworkSettings(networkname string) (models.Network, error) {
 
+	if network, ok := getNetworkFromCache(networkname); ok {
+		return network, nil
+	}
 	var network models.Network
 	networkData, err := database.FetchRecord(database.NETWORKS_TABLE_NAME, networkname)
 	if err != nil {
@@ -148,13 +238,13 @@ func GetNetworkSettings(networkname string) (models.Network, error) {
 	return network, nil
 }
 
-// UniqueAddress - get a unique ipv4 address
+// UniqueAddress - get a unique ipv4 address, reserved so no concurrent caller on any server is given it
 func UniqueAddress(networkName string, reverse bool) (net.IP, error) {
 	add := net.IP{}
 	var network models.Network
 	network, err := GetParentNetwork(networkName)
 	if err != nil {
-		logger.Log(0, "UniqueAddressServer encountered  an error")
+		slog.Error("UniqueAddressServer encountered  an error")
 		return add, err
 	}
 
@@ -163,7 +253,7 @@ func UniqueAddress(networkName string, reverse bool) (net.IP, error) {
 	}
 	//ensure AddressRange is valid
 	if _, _, err := net.ParseCIDR(network.AddressRange); err != nil {
-		logger.Log(0, "UniqueAddress encountered  an error")
+		slog.Error("UniqueAddress encountered  an error")
 		return add, err
 	}
 	net4 := iplib.Net4FromStr(network.AddressRange)
@@ -174,9 +264,16 @@ func UniqueAddress(networkName string, reverse bool) (net.IP, error) {
 	}
 
 	for {
-		if IsIPUnique(networkName, newAddrs.String(), database.NODES_TABLE_NAME, false) &&
-			IsIPUnique(networkName, newAddrs.String(), database.EXT_CLIENT_TABLE_NAME, false) {
-			return newAddrs, nil
+		// reserved before it's checked, so a holder written meanwhile by a caller that released it is seen
+		reserved, err := reserveAddress(networkName, newAddrs.String())
+		if err != nil {
+			return add, err
+		}
+		if reserved {
+			if !isAddressTaken(networkName, newAddrs.String()) {
+				return newAddrs, nil
+			}
+			dropAddress(networkName, newAddrs.String())
 		}
 		if reverse {
 			newAddrs, err = net4.PreviousIP(newAddrs)
@@ -230,12 +327,22 @@ func IsIPUnique(network string, ip string, tableName string, isIpv6 bool) bool {
 				}
 			}
 		}
+	} else if tableName == database.STATIC_PEERS_TABLE_NAME {
+		staticPeers, err := GetNetworkStaticPeers(network)
+		if err != nil {
+			return isunique
+		}
+		for _, staticPeer := range staticPeers {
+			if (isIpv6 && staticPeer.Address6 == ip) || (!isIpv6 && staticPeer.Address == ip) {
+				return false
+			}
+		}
 	}
 
 	return isunique
 }
 
-// UniqueAddress6 - see if ipv6 address is unique
+// UniqueAddress6 - get a unique ipv6 address, reserved so no concurrent caller on any server is given it
 func UniqueAddress6(networkName string, reverse bool) (net.IP, error) {
 	add := net.IP{}
 	var network models.Network
@@ -263,9 +370,16 @@ func UniqueAddress6(networkName string, reverse bool) (net.IP, error) {
 	}
 
 	for {
-		if IsIPUnique(networkName, newAddrs.String(), database.NODES_TABLE_NAME, true) &&
-			IsIPUnique(networkName, newAddrs.String(), database.EXT_CLIENT_TABLE_NAME, true) {
-			return newAddrs, nil
+		// reserved before it's checked, so a holder written meanwhile by a caller that released it is seen
+		reserved, err := reserveAddress(networkName, newAddrs.String())
+		if err != nil {
+			return add, err
+		}
+		if reserved {
+			if !isAddressTaken(networkName, newAddrs.String()) {
+				return newAddrs, nil
+			}
+			dropAddress(networkName, newAddrs.String())
 		}
 		if reverse {
 			newAddrs, err = net6.PreviousIP(newAddrs)
@@ -329,6 +443,9 @@ func UpdateNetwork(currentNetwork *models.Network, newNetwork *models.Network) (
 // GetNetwork - gets a network from database
 func GetNetwork(networkname string) (models.Network, error) {
 
+	if network, ok := getNetworkFromCache(networkname); ok {
+		return network, nil
+	}
 	var network models.Network
 	networkData, err := database.FetchRecord(database.NETWORKS_TABLE_NAME, networkname)
 	if err != nil {
@@ -423,7 +540,3 @@ func SortNetworks(unsortedNetworks []models.Network) {
 		return unsortedNetworks[i].NetID < unsortedNetworks[j].NetID
 	})
 }
-
-// == Private ==
-
-var addressLock = &sync.Mutex{}
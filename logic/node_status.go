@@ -0,0 +1,262 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// nodeOfflineAfter - how long a node may go without checking in before it counts as offline
+	nodeOfflineAfter = 5 * time.Minute
+	// nodeStatusInterval - how often node statuses are checked for transitions
+	nodeStatusInterval = time.Minute
+	// maxUptimeWindow - the longest uptime window, status history is kept this long
+	maxUptimeWindow = 90 * 24 * time.Hour
+)
+
+var (
+	// DefaultUptimeWindows - the uptime windows returned when none are asked for
+	DefaultUptimeWindows = []string{"24h", "7d", "30d"}
+	// ErrInvalidUptimeWindow - an uptime window is not a duration or days (7d) of at most 90 days
+	ErrInvalidUptimeWindow = errors.New("invalid uptime window, must be a duration such as 12h or days such as 7d, of at most 90d")
+)
+
+// IsNodeOnline - checks if a node has checked in recently and isn't set disconnected
+func IsNodeOnline(node *models.Node, now time.Time) bool {
+	return now.Before(nodeOfflineSince(node))
+}
+
+// GetNodeUptime - a node's status and how long it was online over each window, such as 24h or 7d,
+// time before its status was first recorded isn't counted
+func GetNodeUptime(node *models.Node, windows []string) (models.NodeUptime, error) {
+	if len(windows) == 0 {
+		windows = DefaultUptimeWindows
+	}
+	durations := make([]time.Duration, len(windows))
+	longest := time.Duration(0)
+	for i, window := range windows {
+		duration, err := parseUptimeWindow(window)
+		if err != nil {
+			return models.NodeUptime{}, err
+		}
+		durations[i] = duration
+		if duration > longest {
+			longest = duration
+		}
+	}
+	history, err := getNodeStatusHistory(node.ID.String())
+	if err != nil && !database.IsEmptyRecord(err) {
+		return models.NodeUptime{}, err
+	}
+	now := time.Now().UTC()
+	// count the time since a transition the tracker hasn't recorded yet
+	if change, changed := nextNodeStatusChange(node, history.Changes, now); changed {
+		history.Changes = append(history.Changes, change)
+	}
+	last := history.Changes[len(history.Changes)-1]
+	uptime := models.NodeUptime{
+		NodeID:  node.ID.String(),
+		Network: node.Network,
+		Online:  last.Online,
+		Since:   last.Time,
+		Windows: []models.NodeUptimeWindow{},
+		Changes: []models.NodeStatusChange{},
+	}
+	for i, window := range windows {
+		online, tracked, changes := nodeUptime(history.Changes, now.Add(-durations[i]), now)
+		result := models.NodeUptimeWindow{
+			Window:  window,
+			Tracked: int64(tracked.Seconds()),
+			Changes: changes,
+		}
+		if tracked > 0 {
+			result.Uptime = float64(online) / float64(tracked) * 100
+		}
+		uptime.Windows = append(uptime.Windows, result)
+	}
+	from := now.Add(-longest)
+	for _, change := range history.Changes {
+		if change.Time.After(from) {
+			uptime.Changes = append(uptime.Changes, change)
+		}
+	}
+	return uptime, nil
+}
+
+// == private ==
+
+// nodeOfflineSince - when a node went, or will go, offline: once it stops checking in or is set disconnected
+func nodeOfflineSince(node *models.Node) time.Time {
+	offlineAt := node.LastCheckIn.Add(nodeOfflineAfter)
+	if !node.Connected && node.LastModified.Before(offlineAt) {
+		offlineAt = node.LastModified
+	}
+	return offlineAt
+}
+
+// nextNodeStatusChange - the transition a node made since its last recorded change, if any,
+// dated by its check ins rather than when it was noticed
+func nextNodeStatusChange(node *models.Node, changes []models.NodeStatusChange, now time.Time) (models.NodeStatusChange, bool) {
+	online := IsNodeOnline(node, now)
+	if len(changes) == 0 {
+		return models.NodeStatusChange{Time: now, Online: online}, true
+	}
+	last := changes[len(changes)-1]
+	if last.Online == online {
+		return models.NodeStatusChange{}, false
+	}
+	at := nodeOfflineSince(node)
+	if online {
+		at = node.LastCheckIn
+	}
+	if at.Before(last.Time) {
+		at = last.Time
+	}
+	if at.After(now) {
+		at = now
+	}
+	return models.NodeStatusChange{Time: at.UTC(), Online: online}, true
+}
+
+// recordNodeStatuses - records the transitions of every node and drops the history of deleted nodes
+func recordNodeStatuses(now time.Time) error {
+	nodes, err := GetAllNodes()
+	if err != nil {
+		return err
+	}
+	histories, err := getNodeStatusHistories()
+	if err != nil {
+		return err
+	}
+	for i := range nodes {
+		node := &nodes[i]
+		history, ok := histories[node.ID.String()]
+		delete(histories, node.ID.String())
+		if node.PendingDelete {
+			continue
+		}
+		change, changed := nextNodeStatusChange(node, history.Changes, now)
+		if !changed {
+			continue
+		}
+		if !ok {
+			history = models.NodeStatusHistory{NodeID: node.ID.String(), Network: node.Network}
+		}
+		history.Changes = pruneNodeStatusChanges(append(history.Changes, change), now.Add(-maxUptimeWindow))
+		if err := saveNodeStatusHistory(&history); err != nil {
+			slog.Error("failed to record node status", "nodeid", node.ID.String(), "error", err)
+		}
+	}
+	for id := range histories {
+		if err := deleteNodeStatusHistory(id); err != nil {
+			slog.Error("failed to remove status history of deleted node", "nodeid", id, "error", err)
+		}
+	}
+	return nil
+}
+
+// pruneNodeStatusChanges - drops changes before cutoff, keeping the last of them as the status at cutoff
+func pruneNodeStatusChanges(changes []models.NodeStatusChange, cutoff time.Time) []models.NodeStatusChange {
+	first := 0
+	for i := 1; i < len(changes) && !changes[i].Time.After(cutoff); i++ {
+		first = i
+	}
+	return changes[first:]
+}
+
+// nodeUptime - how long a node was online and tracked between from and to,
+// and how many transitions it made in that time
+func nodeUptime(changes []models.NodeStatusChange, from, to time.Time) (online, tracked time.Duration, count int) {
+	for i, change := range changes {
+		start, end := change.Time, to
+		if i+1 < len(changes) {
+			end = changes[i+1].Time
+		}
+		if i > 0 && start.After(from) {
+			count++
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if !end.After(start) {
+			continue
+		}
+		tracked += end.Sub(start)
+		if change.Online {
+			online += end.Sub(start)
+		}
+	}
+	return online, tracked, count
+}
+
+// parseUptimeWindow - parses a window as days (7d) or a duration (12h)
+func parseUptimeWindow(window string) (time.Duration, error) {
+	var duration time.Duration
+	if strings.HasSuffix(window, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(window, "d"))
+		if err != nil {
+			return 0, ErrInvalidUptimeWindow
+		}
+		duration = time.Duration(days) * 24 * time.Hour
+	} else {
+		var err error
+		if duration, err = time.ParseDuration(window); err != nil {
+			return 0, ErrInvalidUptimeWindow
+		}
+	}
+	if duration <= 0 || duration > maxUptimeWindow {
+		return 0, ErrInvalidUptimeWindow
+	}
+	return duration, nil
+}
+
+func getNodeStatusHistory(nodeID string) (models.NodeStatusHistory, error) {
+	var history models.NodeStatusHistory
+	data, err := database.FetchRecord(database.NODE_STATUS_TABLE_NAME, nodeID)
+	if err != nil {
+		return history, err
+	}
+	err = json.Unmarshal([]byte(data), &history)
+	return history, err
+}
+
+func getNodeStatusHistories() (map[string]models.NodeStatusHistory, error) {
+	histories := map[string]models.NodeStatusHistory{}
+	records, err := database.FetchRecords(database.NODE_STATUS_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return histories, nil
+		}
+		return histories, err
+	}
+	for key, value := range records {
+		var history models.NodeStatusHistory
+		if err := json.Unmarshal([]byte(value), &history); err != nil {
+			continue
+		}
+		histories[key] = history
+	}
+	return histories, nil
+}
+
+func saveNodeStatusHistory(history *models.NodeStatusHistory) error {
+	data, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+	return database.Insert(history.NodeID, string(data), database.NODE_STATUS_TABLE_NAME)
+}
+
+func deleteNodeStatusHistory(nodeID string) error {
+	if err := database.DeleteRecord(database.NODE_STATUS_TABLE_NAME, nodeID); err != nil && !database.IsEmptyRecord(err) {
+		return err
+	}
+	return nil
+}
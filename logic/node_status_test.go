@@ -0,0 +1,60 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeUptime(t *testing.T) {
+	now := time.Now()
+	changes := []models.NodeStatusChange{
+		{Time: now.Add(-10 * time.Hour), Online: true},
+		{Time: now.Add(-4 * time.Hour), Online: false},
+		{Time: now.Add(-3 * time.Hour), Online: true},
+	}
+	t.Run("WithinHistory", func(t *testing.T) {
+		online, tracked, count := nodeUptime(changes, now.Add(-5*time.Hour), now)
+		assert.Equal(t, 5*time.Hour, tracked)
+		assert.Equal(t, 4*time.Hour, online)
+		assert.Equal(t, 2, count)
+	})
+	t.Run("BeforeHistory", func(t *testing.T) {
+		online, tracked, _ := nodeUptime(changes, now.Add(-24*time.Hour), now)
+		assert.Equal(t, 10*time.Hour, tracked)
+		assert.Equal(t, 9*time.Hour, online)
+	})
+	t.Run("Prune", func(t *testing.T) {
+		pruned := pruneNodeStatusChanges(changes, now.Add(-3*time.Hour-time.Minute))
+		assert.Equal(t, changes[1:], pruned)
+	})
+}
+
+func TestParseUptimeWindow(t *testing.T) {
+	window, err := parseUptimeWindow("7d")
+	assert.Nil(t, err)
+	assert.Equal(t, 7*24*time.Hour, window)
+	window, err = parseUptimeWindow("90m")
+	assert.Nil(t, err)
+	assert.Equal(t, 90*time.Minute, window)
+	for _, invalid := range []string{"", "0d", "91d", "-1h", "week"} {
+		_, err = parseUptimeWindow(invalid)
+		assert.ErrorIs(t, err, ErrInvalidUptimeWindow, invalid)
+	}
+}
+
+func TestNextNodeStatusChange(t *testing.T) {
+	now := time.Now()
+	node := models.Node{LastCheckIn: now.Add(-10 * time.Minute)}
+	node.Connected = true
+	online := []models.NodeStatusChange{{Time: now.Add(-time.Hour), Online: true}}
+	change, changed := nextNodeStatusChange(&node, online, now)
+	assert.True(t, changed)
+	assert.False(t, change.Online)
+	assert.Equal(t, node.LastCheckIn.Add(nodeOfflineAfter).UTC(), change.Time)
+	node.LastCheckIn = now
+	_, changed = nextNodeStatusChange(&node, online, now)
+	assert.False(t, changed)
+}
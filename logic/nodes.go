@@ -1,7 +1,6 @@
 package logic
 
 import (
-	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,7 +12,6 @@ import (
 	validator "github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"github.com/gravitl/netmaker/database"
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic/acls"
 	"github.com/gravitl/netmaker/logic/acls/nodeacls"
 	"github.com/gravitl/netmaker/logic/pro"
@@ -25,45 +23,72 @@ import (
 )
 
 var (
-	nodeCacheMutex = &sync.RWMutex{}
-	nodesCacheMap  = make(map[string]models.Node)
+	nodeCacheMutex   = &sync.RWMutex{}
+	nodesCacheMap    = make(map[string]models.Node)
+	nodesCacheLoaded bool
+	// nodesCacheVersion - counts the changes made to the cache, see loadNodesIntoCache
+	nodesCacheVersion int64
+	nodeCacheStats    cacheStats
 )
 
 func getNodeFromCache(nodeID string) (node models.Node, ok bool) {
 	nodeCacheMutex.RLock()
 	node, ok = nodesCacheMap[nodeID]
 	nodeCacheMutex.RUnlock()
+	nodeCacheStats.record(ok)
 	return
 }
+
+// getNodesFromCache - returns all nodes, if the cache holds all of them
 func getNodesFromCache() (nodes []models.Node) {
 	nodeCacheMutex.RLock()
-	for _, node := range nodesCacheMap {
-		nodes = append(nodes, node)
+	if nodesCacheLoaded {
+		for _, node := range nodesCacheMap {
+			nodes = append(nodes, node)
+		}
 	}
 	nodeCacheMutex.RUnlock()
+	nodeCacheStats.record(len(nodes) != 0)
 	return
 }
 
 func deleteNodeFromCache(nodeID string) {
 	nodeCacheMutex.Lock()
 	delete(nodesCacheMap, nodeID)
+	nodesCacheVersion++
 	nodeCacheMutex.Unlock()
 }
 
 func storeNodeInCache(node models.Node) {
 	nodeCacheMutex.Lock()
 	nodesCacheMap[node.ID.String()] = node
+	nodesCacheVersion++
 	nodeCacheMutex.Unlock()
 }
 
-func loadNodesIntoCache(nMap map[string]models.Node) {
+func getNodeCacheVersion() int64 {
+	nodeCacheMutex.RLock()
+	defer nodeCacheMutex.RUnlock()
+	return nodesCacheVersion
+}
+
+// loadNodesIntoCache - fills the cache with the nodes read at version, unless a write changed the cache
+// since, then the nodes read may be older than the write and the next read loads them again
+func loadNodesIntoCache(nMap map[string]models.Node, version int64) {
 	nodeCacheMutex.Lock()
-	nodesCacheMap = nMap
+	if nodesCacheVersion == version {
+		nodesCacheMap = nMap
+		nodesCacheLoaded = true
+	}
 	nodeCacheMutex.Unlock()
 }
+
+// ClearNodeCache - empties the node cache, the next read reloads it from the database
 func ClearNodeCache() {
 	nodeCacheMutex.Lock()
 	nodesCacheMap = make(map[string]models.Node)
+	nodesCacheLoaded = false
+	nodesCacheVersion++
 	nodeCacheMutex.Unlock()
 }
 
@@ -76,14 +101,28 @@ const (
 	NodePurgeCheckTime = time.Second * 30
 )
 
-// GetNetworkNodes - gets the nodes of a network
+// GetNetworkNodes - gets the nodes of a network, from the cache when it holds all nodes,
+// otherwise only the network's records are read from the database
 func GetNetworkNodes(network string) ([]models.Node, error) {
-	allnodes, err := GetAllNodes()
+	if allnodes := getNodesFromCache(); len(allnodes) != 0 {
+		return GetNetworkNodesMemory(allnodes, network), nil
+	}
+	nodes := []models.Node{}
+	records, err := database.FetchRecordsByField(database.NODES_TABLE_NAME, "network", network)
 	if err != nil {
-		return []models.Node{}, err
+		if database.IsEmptyRecord(err) {
+			return nodes, nil
+		}
+		return nodes, err
 	}
-
-	return GetNetworkNodesMemory(allnodes, network), nil
+	for _, value := range records {
+		var node models.Node
+		if err := json.Unmarshal([]byte(value), &node); err != nil {
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
 }
 
 // GetHostNodes - fetches all nodes part of the host
@@ -112,6 +151,11 @@ func GetNetworkNodesMemory(allNodes []models.Node, network string) []models.Node
 
 // UpdateNodeCheckin - updates the checkin time of a node
 func UpdateNodeCheckin(node *models.Node) error {
+	revisionMutex.Lock()
+	defer revisionMutex.Unlock()
+	if err := stampNodeRevision(node); err != nil {
+		return err
+	}
 	node.SetLastCheckIn()
 	data, err := json.Marshal(node)
 	if err != nil {
@@ -127,6 +171,11 @@ func UpdateNodeCheckin(node *models.Node) error {
 
 // UpsertNode - updates node in the DB
 func UpsertNode(newNode *models.Node) error {
+	revisionMutex.Lock()
+	defer revisionMutex.Unlock()
+	if err := stampNodeRevision(newNode); err != nil {
+		return err
+	}
 	newNode.SetLastModified()
 	data, err := json.Marshal(newNode)
 	if err != nil {
@@ -160,11 +209,16 @@ func UpdateNode(currentNode *models.Node, newNode *models.Node) error {
 	if newNode.ID == currentNode.ID {
 		if nodeACLDelta {
 			if err := updateProNodeACLS(newNode); err != nil {
-				logger.Log(1, "failed to apply node level ACLs during creation of node", newNode.ID.String(), "-", err.Error())
+				slog.Error("failed to apply node level ACLs during creation of node", "new_node_id", newNode.ID.String(), "error", err)
 				return err
 			}
 		}
 
+		revisionMutex.Lock()
+		defer revisionMutex.Unlock()
+		if err := stampNodeRevision(newNode); err != nil {
+			return err
+		}
 		newNode.SetLastModified()
 		if data, err := json.Marshal(newNode); err != nil {
 			return err
@@ -195,13 +249,13 @@ func DeleteNode(node *models.Node, purge bool) error {
 		return nil
 	}
 	if alreadyDeleted {
-		logger.Log(1, "forcibly deleting node", node.ID.String())
+		slog.Info("forcibly deleting node", "node_id", node.ID.String())
 	}
 	host, err := GetHost(node.HostID.String())
 	if err != nil {
-		logger.Log(1, "no host found for node", node.ID.String(), "deleting..")
+		slog.Info("no host found for node deleting..", "node_id", node.ID.String())
 		if delErr := deleteNodeByID(node); delErr != nil {
-			logger.Log(0, "failed to delete node", node.ID.String(), delErr.Error())
+			slog.Error("failed to delete node", "node_id", node.ID.String(), "error", delErr)
 		}
 		return err
 	}
@@ -210,7 +264,7 @@ func DeleteNode(node *models.Node, purge bool) error {
 	}
 	if servercfg.Is_EE {
 		if err := EnterpriseResetAllPeersFailovers(node.ID, node.Network); err != nil {
-			logger.Log(0, "failed to reset failover lists during node delete for node", host.Name, node.Network)
+			slog.Error("failed to reset failover lists during node delete for node", "host_name", host.Name, "network", node.Network)
 		}
 	}
 
@@ -219,19 +273,43 @@ func DeleteNode(node *models.Node, purge bool) error {
 
 // deleteNodeByID - deletes a node from database
 func deleteNodeByID(node *models.Node) error {
-	var err error
-	var key = node.ID.String()
+	tx := database.BeginTx()
+	if err := deleteNodeInTx(tx, node); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	cleanupDeletedNode(node)
+	return nil
+}
+
+// deleteNodeInTx - adds the removal of a node and the ext clients of its gateway to tx
+func deleteNodeInTx(tx *database.Tx, node *models.Node) error {
 	//delete any ext clients as required
 	if node.IsIngressGateway {
-		if err := DeleteGatewayExtClients(node.ID.String(), node.Network); err != nil {
-			logger.Log(0, "failed to deleted ext clients", err.Error())
-		}
-	}
-	if err = database.DeleteRecord(database.NODES_TABLE_NAME, key); err != nil {
-		if !database.IsEmptyRecord(err) {
+		clients, err := GetNetworkExtClients(node.Network)
+		if err != nil && !database.IsEmptyRecord(err) {
 			return err
 		}
+		for _, client := range clients {
+			if client.IngressGatewayID != node.ID.String() {
+				continue
+			}
+			key, err := GetRecordKey(client.ClientID, client.Network)
+			if err != nil {
+				return err
+			}
+			tx.Delete(database.EXT_CLIENT_TABLE_NAME, key)
+		}
 	}
+	tx.Delete(database.NODES_TABLE_NAME, node.ID.String())
+	return nil
+}
+
+// cleanupDeletedNode - removes the state kept for a node once its record is deleted
+func cleanupDeletedNode(node *models.Node) {
+	var err error
 	deleteNodeFromCache(node.ID.String())
 	if servercfg.IsDNSMode() {
 		SetDNS()
@@ -239,19 +317,28 @@ func deleteNodeByID(node *models.Node) error {
 	if node.OwnerID != "" {
 		err = pro.DissociateNetworkUserNode(node.OwnerID, node.Network, node.ID.String())
 		if err != nil {
-			logger.Log(0, "failed to dissasociate", node.OwnerID, "from node", node.ID.String(), ":", err.Error())
+			slog.Error("failed to dissasociate from node", "owner_id", node.OwnerID, "node_id", node.ID.String(), "error", err)
 		}
 	}
 	_, err = nodeacls.RemoveNodeACL(nodeacls.NetworkID(node.Network), nodeacls.NodeID(node.ID.String()))
 	if err != nil {
 		// ignoring for now, could hit a nil pointer if delete called twice
-		logger.Log(2, "attempted to remove node ACL for node", node.ID.String())
+		slog.Debug("attempted to remove node ACL for node", "node_id", node.ID.String())
 	}
 	// removeZombie <- node.ID
 	if err = DeleteMetrics(node.ID.String()); err != nil {
-		logger.Log(1, "unable to remove metrics from DB for node", node.ID.String(), err.Error())
+		slog.Error("unable to remove metrics from DB for node", "node_id", node.ID.String(), "error", err)
 	}
-	return nil
+	if err = deleteNodeStatusHistory(node.ID.String()); err != nil {
+		slog.Error("unable to remove status history from DB for node", "node_id", node.ID.String(), "error", err)
+	}
+	if err = DeleteEndpointObservations(node.ID.String()); err != nil {
+		slog.Error("unable to remove endpoint observations from DB for node", "node_id", node.ID.String(), "error", err)
+	}
+	if node.IsIngressGateway {
+		RemoveSecondaryGateway(node.ID.String(), node.Network)
+	}
+	RecordNetworkEvent(node.Network, models.NetworkEventLeave, node, "node left")
 }
 
 // IsNodeIDUnique - checks if node id is unique
@@ -303,7 +390,7 @@ func GetAllNodes() ([]models.Node, error) {
 		return nodes, nil
 	}
 	nodesMap := make(map[string]models.Node)
-	defer loadNodesIntoCache(nodesMap)
+	defer loadNodesIntoCache(nodesMap, getNodeCacheVersion())
 	collection, err := database.FetchRecords(database.NODES_TABLE_NAME)
 	if err != nil {
 		if database.IsEmptyRecord(err) {
@@ -316,7 +403,7 @@ func GetAllNodes() ([]models.Node, error) {
 		var node models.Node
 		// ignore legacy nodes in database
 		if err := json.Unmarshal([]byte(value), &node); err != nil {
-			logger.Log(3, "legacy node detected: ", err.Error())
+			slog.Warn("legacy node detected", "error", err)
 			continue
 		}
 		// add node to our array
@@ -415,7 +502,7 @@ func GetDeletedNodeByID(uuid string) (models.Node, error) {
 func FindRelay(node *models.Node) *models.Node {
 	relay, err := GetNodeByID(node.RelayedBy)
 	if err != nil {
-		logger.Log(0, "FindRelay: "+err.Error())
+		slog.Error("FindRelay: " + err.Error())
 		return nil
 	}
 	return &relay
@@ -446,33 +533,26 @@ func GetAllNodesAPI(nodes []models.Node) []models.ApiNode {
 	return apiNodes[:]
 }
 
-// DeleteExpiredNodes - goroutine which deletes nodes which are expired
-func DeleteExpiredNodes(ctx context.Context, peerUpdate chan *models.Node) {
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-time.After(time.Hour):
-			// Delete Expired Nodes Every Hour
-			allnodes, err := GetAllNodes()
-			if err != nil {
-				slog.Error("failed to retrieve all nodes", "error", err.Error())
-				return
-			}
-			for _, node := range allnodes {
-				if time.Now().After(node.ExpirationDateTime) {
-					if err := DeleteNode(&node, false); err != nil {
-						slog.Error("error deleting expired node", "nodeid", node.ID.String(), "error", err.Error())
-						continue
-					}
-					node.Action = models.NODE_DELETE
-					node.PendingDelete = true
-					peerUpdate <- &node
-					slog.Info("deleting expired node", "nodeid", node.ID.String())
-				}
+// DeleteExpiredNodes - deletes the nodes which are expired, returning them for their peers to be updated
+func DeleteExpiredNodes() ([]models.Node, error) {
+	allnodes, err := GetAllNodes()
+	if err != nil {
+		return nil, err
+	}
+	deleted := []models.Node{}
+	for _, node := range allnodes {
+		if time.Now().After(node.ExpirationDateTime) {
+			if err := DeleteNode(&node, false); err != nil {
+				slog.Error("error deleting expired node", "nodeid", node.ID.String(), "error", err.Error())
+				continue
 			}
+			node.Action = models.NODE_DELETE
+			node.PendingDelete = true
+			deleted = append(deleted, node)
+			slog.Info("deleting expired node", "nodeid", node.ID.String())
 		}
 	}
+	return deleted, nil
 }
 
 // == PRO ==
@@ -492,12 +572,29 @@ func updateProNodeACLS(node *models.Node) error {
 // createNode - creates a node in database
 func createNode(node *models.Node) error {
 	// lock because we need unique IPs and having it concurrent makes parallel calls result in same "unique" IPs
-	addressLock.Lock()
-	defer addressLock.Unlock()
+	unlock, err := Lock(addressLockName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	tx := database.BeginTx()
+	finish, err := createNodeInTx(tx, node)
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	return finish()
+}
 
+// createNodeInTx - validates a new node and adds its record to tx, the returned
+// function sets up the rest of the node's state and must be called once tx is committed,
+// the address lock must be held until then so the node's addresses stay unique
+func createNodeInTx(tx *database.Tx, node *models.Node) (finish func() error, err error) {
 	host, err := GetHost(node.HostID.String())
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if !node.DNSOn {
@@ -525,73 +622,97 @@ func createNode(node *models.Node) error {
 	if node.Address.IP == nil {
 		if parentNetwork.IsIPv4 == "yes" {
 			if node.Address.IP, err = UniqueAddress(node.Network, false); err != nil {
-				return err
+				return nil, err
 			}
 			_, cidr, err := net.ParseCIDR(parentNetwork.AddressRange)
 			if err != nil {
-				return err
+				return nil, err
 			}
 			node.Address.Mask = net.CIDRMask(cidr.Mask.Size())
 		}
-	} else if !IsIPUnique(node.Network, node.Address.String(), database.NODES_TABLE_NAME, false) {
-		return fmt.Errorf("invalid address: ipv4 " + node.Address.String() + " is not unique")
+	} else if !claimAddress(node.Network, node.Address.IP.String()) {
+		return nil, fmt.Errorf("invalid address: ipv4 " + node.Address.String() + " is not unique")
+	}
+	// the reservations are released once tx is committed, or right away when the node isn't added to it
+	reserved := []string{}
+	defer func() {
+		if err != nil {
+			for _, address := range reserved {
+				dropAddress(node.Network, address)
+			}
+		}
+	}()
+	if node.Address.IP != nil {
+		reserved = append(reserved, node.Address.IP.String())
 	}
 	if node.Address6.IP == nil {
 		if parentNetwork.IsIPv6 == "yes" {
 			if node.Address6.IP, err = UniqueAddress6(node.Network, false); err != nil {
-				return err
+				return nil, err
 			}
 			_, cidr, err := net.ParseCIDR(parentNetwork.AddressRange6)
 			if err != nil {
-				return err
+				return nil, err
 			}
 			node.Address6.Mask = net.CIDRMask(cidr.Mask.Size())
 		}
-	} else if !IsIPUnique(node.Network, node.Address6.String(), database.NODES_TABLE_NAME, true) {
-		return fmt.Errorf("invalid address: ipv6 " + node.Address6.String() + " is not unique")
+	} else if !claimAddress(node.Network, node.Address6.IP.String()) {
+		return nil, fmt.Errorf("invalid address: ipv6 " + node.Address6.String() + " is not unique")
+	}
+	if node.Address6.IP != nil {
+		reserved = append(reserved, node.Address6.IP.String())
+	}
+	if err = ApplyNamingPolicy(node, host, &parentNetwork); err != nil {
+		return nil, err
 	}
 	node.ID = uuid.New()
 	//Create a JWT for the node
 	tokenString, _ := CreateJWT(node.ID.String(), host.MacAddress.String(), node.Network)
 	if tokenString == "" {
-		//logic.ReturnErrorResponse(w, r, errorResponse)
-		return err
+		return nil, fmt.Errorf("failed to create token for node %s", node.ID.String())
 	}
 	err = ValidateNode(node, false)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	CheckZombies(node)
+	node.Revision = 1
 
 	nodebytes, err := json.Marshal(&node)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	err = database.Insert(node.ID.String(), string(nodebytes), database.NODES_TABLE_NAME)
-	if err != nil {
-		return err
-	}
-	storeNodeInCache(*node)
-	_, err = nodeacls.CreateNodeACL(nodeacls.NetworkID(node.Network), nodeacls.NodeID(node.ID.String()), defaultACLVal)
-	if err != nil {
-		logger.Log(1, "failed to create node ACL for node,", node.ID.String(), "err:", err.Error())
-		return err
+	if err = tx.Insert(node.ID.String(), string(nodebytes), database.NODES_TABLE_NAME); err != nil {
+		return nil, err
 	}
 
-	if err = updateProNodeACLS(node); err != nil {
-		logger.Log(1, "failed to apply node level ACLs during creation of node", node.ID.String(), "-", err.Error())
-		return err
-	}
+	return func() error {
+		storeNodeInCache(*node)
+		for _, address := range reserved {
+			releaseAddress(node.Network, address)
+		}
+		_, err = nodeacls.CreateNodeACL(nodeacls.NetworkID(node.Network), nodeacls.NodeID(node.ID.String()), defaultACLVal)
+		if err != nil {
+			slog.Error("failed to create node ACL for node", "node_id", node.ID.String(), "error", err)
+			return err
+		}
 
-	if err = UpdateMetrics(node.ID.String(), &models.Metrics{Connectivity: make(map[string]models.Metric)}); err != nil {
-		logger.Log(1, "failed to initialize metrics for node", node.ID.String(), err.Error())
-	}
+		if err = updateProNodeACLS(node); err != nil {
+			slog.Error("failed to apply node level ACLs during creation of node", "node_id", node.ID.String(), "error", err)
+			return err
+		}
 
-	SetNetworkNodesLastModified(node.Network)
-	if servercfg.IsDNSMode() {
-		err = SetDNS()
-	}
-	return err
+		if err = UpdateMetrics(node.ID.String(), &models.Metrics{Connectivity: make(map[string]models.Metric)}); err != nil {
+			slog.Error("failed to initialize metrics for node", "node_id", node.ID.String(), "error", err)
+		}
+
+		SetNetworkNodesLastModified(node.Network)
+		RecordNetworkEvent(node.Network, models.NetworkEventJoin, node, "node joined")
+		if servercfg.IsDNSMode() {
+			err = SetDNS()
+		}
+		return err
+	}, nil
 }
 
 // SortApiNodes - Sorts slice of ApiNodes by their ID alphabetically with numbers first
@@ -0,0 +1,49 @@
+package logic
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteNodeInTx(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	gateway := models.Node{CommonNode: models.CommonNode{ID: uuid.New(), Network: "txnet", IsIngressGateway: true}}
+	other := models.Node{CommonNode: models.CommonNode{ID: uuid.New(), Network: "txnet"}}
+	for _, node := range []models.Node{gateway, other} {
+		data, _ := json.Marshal(&node)
+		assert.Nil(t, database.Insert(node.ID.String(), string(data), database.NODES_TABLE_NAME))
+	}
+	clients := []models.ExtClient{
+		{ClientID: "txclient1", Network: "txnet", IngressGatewayID: gateway.ID.String()},
+		{ClientID: "txclient2", Network: "txnet", IngressGatewayID: other.ID.String()},
+	}
+	for _, client := range clients {
+		key, _ := GetRecordKey(client.ClientID, client.Network)
+		data, _ := json.Marshal(&client)
+		assert.Nil(t, database.Insert(key, string(data), database.EXT_CLIENT_TABLE_NAME))
+	}
+	defer database.DeleteRecord(database.NODES_TABLE_NAME, other.ID.String())
+	defer database.DeleteRecord(database.EXT_CLIENT_TABLE_NAME, "txclient2###txnet")
+
+	tx := database.BeginTx()
+	assert.Nil(t, deleteNodeInTx(tx, &gateway))
+	// nothing is written until the transaction is committed
+	_, err := database.FetchRecord(database.EXT_CLIENT_TABLE_NAME, "txclient1###txnet")
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Commit())
+
+	_, err = database.FetchRecord(database.NODES_TABLE_NAME, gateway.ID.String())
+	assert.True(t, database.IsEmptyRecord(err))
+	_, err = database.FetchRecord(database.EXT_CLIENT_TABLE_NAME, "txclient1###txnet")
+	assert.True(t, database.IsEmptyRecord(err))
+	_, err = database.FetchRecord(database.NODES_TABLE_NAME, other.ID.String())
+	assert.Nil(t, err)
+	_, err = database.FetchRecord(database.EXT_CLIENT_TABLE_NAME, "txclient2###txnet")
+	assert.Nil(t, err)
+}
@@ -0,0 +1,211 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+// maxNotificationText - the longest text sent to a channel, discord refusing longer embeds
+const maxNotificationText = 3500
+
+// ErrUnknownNotificationChannel - an alert rule names a channel that doesn't exist or belongs to another tenant
+var ErrUnknownNotificationChannel = errors.New("unknown notification channel")
+
+// notification colors by severity
+var notificationColors = map[string]int{
+	models.NotificationAlert:    0xd93025,
+	models.NotificationResolved: 0x1e8e3e,
+	models.NotificationInfo:     0x1a73e8,
+}
+
+// CreateNotificationChannel - adds a channel alerts can be sent to
+func CreateNotificationChannel(channel *models.NotificationChannel) error {
+	if err := validator.New().Struct(channel); err != nil {
+		return err
+	}
+	channel.ID = uuid.New().String()
+	data, err := json.Marshal(channel)
+	if err != nil {
+		return err
+	}
+	return database.Insert(channel.ID, string(data), database.NOTIFICATION_CHANNELS_TABLE_NAME)
+}
+
+// GetNotificationChannels - the notification channels, only those of a tenant when it's set
+func GetNotificationChannels(tenant string) ([]models.NotificationChannel, error) {
+	channels := []models.NotificationChannel{}
+	records, err := database.FetchRecords(database.NOTIFICATION_CHANNELS_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return channels, nil
+		}
+		return nil, err
+	}
+	for _, value := range records {
+		var channel models.NotificationChannel
+		if err := json.Unmarshal([]byte(value), &channel); err != nil {
+			continue
+		}
+		if tenant != "" && channel.Tenant != tenant {
+			continue
+		}
+		channels = append(channels, channel)
+	}
+	sort.Slice(channels, func(i, j int) bool {
+		return channels[i].Name < channels[j].Name
+	})
+	return channels, nil
+}
+
+// GetNotificationChannel - fetches a notification channel
+func GetNotificationChannel(id string) (models.NotificationChannel, error) {
+	var channel models.NotificationChannel
+	record, err := database.FetchRecord(database.NOTIFICATION_CHANNELS_TABLE_NAME, id)
+	if err != nil {
+		return channel, err
+	}
+	err = json.Unmarshal([]byte(record), &channel)
+	return channel, err
+}
+
+// DeleteNotificationChannel - stops sending alerts to a channel
+func DeleteNotificationChannel(id string) error {
+	return database.DeleteRecord(database.NOTIFICATION_CHANNELS_TABLE_NAME, id)
+}
+
+// ValidateNotificationChannels - checks the channels an alert rule names exist and can be used for a tenant's alerts
+func ValidateNotificationChannels(ids []string, tenant string) error {
+	for _, id := range ids {
+		channel, err := GetNotificationChannel(id)
+		if err != nil || (channel.Tenant != "" && channel.Tenant != tenant) {
+			return fmt.Errorf("%w: %s", ErrUnknownNotificationChannel, id)
+		}
+	}
+	return nil
+}
+
+// Notify - sends a notification to the channels subscribed to its event type and to the channels of the
+// alert rule that raised it; channels of a tenant only get the notifications of their tenant
+func Notify(notification models.Notification, channels []string) {
+	if notification.Time.IsZero() {
+		notification.Time = time.Now().UTC()
+	}
+	if notification.Tenant == "" && notification.Network != "" {
+		if network, err := GetNetwork(notification.Network); err == nil {
+			notification.Tenant = network.Tenant
+		}
+	}
+	all, err := GetNotificationChannels("")
+	if err != nil {
+		slog.Error("failed to fetch notification channels", "error", err)
+		return
+	}
+	for _, channel := range all {
+		if channel.Tenant != "" && channel.Tenant != notification.Tenant {
+			continue
+		}
+		if !StringSliceContains(channels, channel.ID) && !StringSliceContains(channel.Events, notification.Event) {
+			continue
+		}
+		if err := SendNotification(&channel, &notification); err != nil {
+			slog.Error("failed to send notification", "channel", channel.Name, "kind", channel.Kind, "event", notification.Event, "error", err)
+		}
+	}
+}
+
+// SendNotification - posts a notification to a channel in the channel's message format
+func SendNotification(channel *models.NotificationChannel, notification *models.Notification) error {
+	return postJSON(channel.URL, formatNotification(channel.Kind, notification))
+}
+
+// == private ==
+
+// formatNotification - the message a kind of channel expects for a notification
+func formatNotification(kind string, notification *models.Notification) any {
+	text := notification.Text
+	if len(text) > maxNotificationText {
+		text = text[:maxNotificationText] + "\n..."
+	}
+	// discord refuses fields without a value
+	fields := []models.NotificationField{}
+	for _, field := range notification.Fields {
+		if field.Value != "" {
+			fields = append(fields, field)
+		}
+	}
+	color := notificationColors[notification.Severity]
+	if color == 0 {
+		color = notificationColors[models.NotificationInfo]
+	}
+	switch kind {
+	case models.NotificationSlack:
+		text = slackEscape(text)
+		if notification.Preformatted {
+			text = "```" + text + "```"
+		}
+		slackFields := []map[string]any{}
+		for _, field := range fields {
+			slackFields = append(slackFields, map[string]any{"title": field.Name, "value": slackEscape(field.Value), "short": true})
+		}
+		return map[string]any{
+			"text": "*" + slackEscape(notification.Title) + "*",
+			"attachments": []map[string]any{{
+				"color":  fmt.Sprintf("#%06x", color),
+				"text":   text,
+				"fields": slackFields,
+				"ts":     notification.Time.Unix(),
+			}},
+		}
+	case models.NotificationTeams:
+		if notification.Preformatted {
+			text = "<pre>" + text + "</pre>"
+		}
+		facts := []map[string]any{}
+		for _, field := range fields {
+			facts = append(facts, map[string]any{"name": field.Name, "value": field.Value})
+		}
+		return map[string]any{
+			"@type":      "MessageCard",
+			"@context":   "https://schema.org/extensions",
+			"summary":    notification.Title,
+			"themeColor": fmt.Sprintf("%06x", color),
+			"title":      notification.Title,
+			"text":       text,
+			"sections":   []map[string]any{{"facts": facts}},
+		}
+	case models.NotificationDiscord:
+		if notification.Preformatted {
+			text = "```\n" + text + "```"
+		}
+		embedFields := []map[string]any{}
+		for _, field := range fields {
+			embedFields = append(embedFields, map[string]any{"name": field.Name, "value": field.Value, "inline": true})
+		}
+		return map[string]any{
+			"embeds": []map[string]any{{
+				"title":       notification.Title,
+				"description": text,
+				"color":       color,
+				"fields":      embedFields,
+				"timestamp":   notification.Time.Format(time.RFC3339),
+			}},
+		}
+	default:
+		return notification
+	}
+}
+
+// slackEscape - escapes the characters slack reads as markup
+func slackEscape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
@@ -0,0 +1,41 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatNotification(t *testing.T) {
+	notification := &models.Notification{
+		Event:    models.NotificationEventProbe,
+		Severity: models.NotificationAlert,
+		Title:    "probe <office> alert raised",
+		Text:     "50.0% loss",
+		Fields:   []models.NotificationField{{Name: "network", Value: "net1"}, {Name: "target", Value: ""}},
+		Time:     time.Date(2023, 8, 15, 12, 0, 0, 0, time.UTC),
+	}
+	t.Run("Slack", func(t *testing.T) {
+		message := formatNotification(models.NotificationSlack, notification).(map[string]any)
+		assert.Equal(t, "*probe &lt;office&gt; alert raised*", message["text"])
+		attachment := message["attachments"].([]map[string]any)[0]
+		assert.Equal(t, "#d93025", attachment["color"])
+		assert.Equal(t, 1, len(attachment["fields"].([]map[string]any)), "empty fields are left out")
+	})
+	t.Run("Teams", func(t *testing.T) {
+		message := formatNotification(models.NotificationTeams, notification).(map[string]any)
+		assert.Equal(t, "MessageCard", message["@type"])
+		assert.Equal(t, "d93025", message["themeColor"])
+	})
+	t.Run("Discord", func(t *testing.T) {
+		message := formatNotification(models.NotificationDiscord, notification).(map[string]any)
+		embed := message["embeds"].([]map[string]any)[0]
+		assert.Equal(t, 0xd93025, embed["color"])
+		assert.Equal(t, "2023-08-15T12:00:00Z", embed["timestamp"])
+	})
+	t.Run("Webhook", func(t *testing.T) {
+		assert.Equal(t, notification, formatNotification(models.NotificationWebhook, notification))
+	})
+}
@@ -0,0 +1,165 @@
+package logic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
+)
+
+// opaTimeout - how long an API request waits for the decision of the Open Policy Agent endpoint
+const opaTimeout = 2 * time.Second
+
+var (
+	// ErrOPADenied - the Open Policy Agent policy doesn't allow a request
+	ErrOPADenied = errors.New("denied by authorization policy")
+	// ErrOPAUnavailable - the Open Policy Agent endpoint couldn't decide on a request
+	ErrOPAUnavailable = errors.New("authorization policy unavailable")
+)
+
+// actions of API requests by method
+var opaActions = map[string]string{
+	http.MethodGet:    "read",
+	http.MethodHead:   "read",
+	http.MethodPost:   "create",
+	http.MethodPut:    "update",
+	http.MethodPatch:  "update",
+	http.MethodDelete: "delete",
+}
+
+// CheckOPAAuthorization - asks the Open Policy Agent endpoint, when one is set, whether the authenticated user of a
+// request may make it; requests are refused when it can't be reached unless the settings fail open.
+// As break glass, OPA_BREAK_GLASS=on lets super admins through while it can't be reached, to fix the endpoint or settings.
+func CheckOPAAuthorization(r *http.Request) error {
+	opa := servercfg.GetOPASettings()
+	if opa.URL == "" {
+		return nil
+	}
+	input := opaInput(r)
+	decision, err := queryOPA(&opa, &input)
+	if err != nil {
+		slog.Error("failed to query authorization policy", "url", opa.URL, "user", input.User, "route", input.Route, "fail_open", opa.FailOpen, "error", err)
+		if opa.FailOpen {
+			return nil
+		}
+		if servercfg.IsOPABreakGlassEnabled() && isSuperAdminRequest(r) {
+			slog.Warn("authorization policy unavailable, super admin let through by break glass", "user", input.User, "action", input.Action, "route", input.Route)
+			return nil
+		}
+		return fmt.Errorf("%w: %s", ErrOPAUnavailable, err.Error())
+	}
+	if !decision.Allow {
+		slog.Warn("api request denied by authorization policy", "user", input.User, "action", input.Action, "route", input.Route, "reason", decision.Reason)
+		if decision.Reason != "" {
+			return fmt.Errorf("%w: %s", ErrOPADenied, decision.Reason)
+		}
+		return ErrOPADenied
+	}
+	return nil
+}
+
+// == private ==
+
+// isSuperAdminRequest - checks a request is made by the master key or an admin of the whole server, as themselves
+func isSuperAdminRequest(r *http.Request) bool {
+	if r.Header.Get(HostIDHeader) != "" || r.Header.Get(ImpersonatorHeader) != "" {
+		return false
+	}
+	username := r.Header.Get("user")
+	if username == master_uname {
+		return true
+	}
+	user, err := GetUser(username)
+	return err == nil && user.IsAdmin && user.Tenant == ""
+}
+
+// opaInput - the input of the decision on a request, from the headers the security checks set
+func opaInput(r *http.Request) models.OPAInput {
+	input := models.OPAInput{
+		User:         r.Header.Get("user"),
+		Host:         r.Header.Get(HostIDHeader),
+		Tenant:       r.Header.Get("tenant"),
+		Admin:        r.Header.Get("ismaster") == "yes",
+		Impersonator: r.Header.Get(ImpersonatorHeader),
+		Action:       opaActions[r.Method],
+		Method:       r.Method,
+		Route:        r.URL.Path,
+		Path:         r.URL.Path,
+		Params:       mux.Vars(r),
+	}
+	if input.Params == nil {
+		input.Params = map[string]string{}
+	}
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			input.Route = template
+		}
+	}
+	if strings.HasPrefix(input.Route, "/api/") {
+		input.Resource = strings.Split(strings.TrimPrefix(input.Route, "/api/"), "/")[0]
+	}
+	if source := APISourceIP(r); source != nil {
+		input.SourceIP = source.String()
+	}
+	return input
+}
+
+// queryOPA - posts the input of a decision to the endpoint and reads its result, true or an object with
+// an allow field; an undefined result, from a policy without a rule for the input, is a deny
+func queryOPA(opa *models.OPASettings, input *models.OPAInput) (models.OPADecision, error) {
+	var decision models.OPADecision
+	data, err := json.Marshal(map[string]any{"input": input})
+	if err != nil {
+		return decision, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opaTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, opa.URL, bytes.NewReader(data))
+	if err != nil {
+		return decision, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if opa.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+opa.Token)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return decision, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return decision, fmt.Errorf("policy endpoint answered with status %d", res.StatusCode)
+	}
+	var response struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&response); err != nil {
+		return decision, err
+	}
+	return parseOPAResult(response.Result)
+}
+
+func parseOPAResult(result json.RawMessage) (models.OPADecision, error) {
+	var decision models.OPADecision
+	if len(result) == 0 {
+		decision.Reason = "no policy decision for the request"
+		return decision, nil
+	}
+	if err := json.Unmarshal(result, &decision.Allow); err == nil {
+		return decision, nil
+	}
+	if err := json.Unmarshal(result, &decision); err != nil {
+		return decision, fmt.Errorf("policy result is neither a boolean nor an object with allow: %w", err)
+	}
+	return decision, nil
+}
@@ -0,0 +1,75 @@
+package logic
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckOPAAuthorization(t *testing.T) {
+	var received models.OPAInput
+	policy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Input models.OPAInput `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		received = body.Input
+		switch {
+		case r.Header.Get("Authorization") != "Bearer opa-token":
+			w.WriteHeader(http.StatusUnauthorized)
+		case body.Input.Action == "delete":
+			w.Write([]byte(`{"result": {"allow": false, "reason": "deletes need a change ticket"}}`))
+		case body.Input.Resource == "hosts":
+			w.Write([]byte(`{}`))
+		default:
+			w.Write([]byte(`{"result": true}`))
+		}
+	}))
+	defer policy.Close()
+	t.Setenv("OPA_URL", policy.URL)
+	t.Setenv("OPA_TOKEN", "opa-token")
+
+	request := func(method, path string) *http.Request {
+		r := httptest.NewRequest(method, path, nil)
+		r.Header.Set("user", "alice")
+		r.Header.Set("ismaster", "yes")
+		return mux.SetURLVars(r, map[string]string{"networkname": "skynet"})
+	}
+	assert.Nil(t, CheckOPAAuthorization(request(http.MethodGet, "/api/networks/skynet")))
+	assert.Equal(t, "alice", received.User)
+	assert.True(t, received.Admin)
+	assert.Equal(t, "read", received.Action)
+	assert.Equal(t, "networks", received.Resource)
+	assert.Equal(t, "skynet", received.Params["networkname"])
+
+	err := CheckOPAAuthorization(request(http.MethodDelete, "/api/networks/skynet"))
+	assert.ErrorIs(t, err, ErrOPADenied)
+	assert.Contains(t, err.Error(), "change ticket")
+	assert.ErrorIs(t, CheckOPAAuthorization(request(http.MethodGet, "/api/hosts")), ErrOPADenied, "an undefined result is a deny")
+
+	t.Run("Unavailable", func(t *testing.T) {
+		t.Setenv("OPA_TOKEN", "wrong")
+		assert.ErrorIs(t, CheckOPAAuthorization(request(http.MethodGet, "/api/networks")), ErrOPAUnavailable)
+		t.Run("BreakGlass", func(t *testing.T) {
+			t.Setenv("OPA_BREAK_GLASS", "on")
+			// only super admins are let through
+			assert.ErrorIs(t, CheckOPAAuthorization(request(http.MethodGet, "/api/networks")), ErrOPAUnavailable)
+			r := request(http.MethodDelete, "/api/networks/skynet")
+			r.Header.Set("user", master_uname)
+			assert.Nil(t, CheckOPAAuthorization(r))
+			r.Header.Set(ImpersonatorHeader, "bob")
+			assert.ErrorIs(t, CheckOPAAuthorization(r), ErrOPAUnavailable)
+		})
+		t.Setenv("OPA_FAIL_OPEN", "on")
+		assert.Nil(t, CheckOPAAuthorization(request(http.MethodGet, "/api/networks")))
+	})
+	t.Run("Disabled", func(t *testing.T) {
+		t.Setenv("OPA_URL", "")
+		assert.Nil(t, CheckOPAAuthorization(request(http.MethodDelete, "/api/networks/skynet")))
+	})
+}
@@ -0,0 +1,192 @@
+package logic
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/gravitl/netmaker/models"
+)
+
+// peerGraph - which hosts a change reaches: a node is a peer of every node in its network,
+// so a change to it only needs to reach the hosts with a node in that network.
+// It narrows which hosts get an update, not how each update is built: every affected host
+// still has its whole peer list recomputed, so a change in a network of n nodes costs O(n²),
+// hosts of other networks are just left alone.
+// It is kept up to date with every node write, see applyChange.
+type peerGraph struct {
+	mutex  sync.RWMutex
+	loaded bool
+	// nodes - node id to the network and host of the node
+	nodes map[string]peerGraphNode
+	// networks - network to the ids of its hosts, with how many nodes each host has in it
+	networks map[string]map[string]int
+}
+
+type peerGraphNode struct {
+	network string
+	hostID  string
+}
+
+var hostPeerGraph = peerGraph{
+	nodes:    map[string]peerGraphNode{},
+	networks: map[string]map[string]int{},
+}
+
+// GetAffectedHosts - the ids of the hosts whose peers change when the given nodes change:
+// the hosts of the nodes and every host with a node in their networks, each of which
+// gets its full peer list rebuilt
+func GetAffectedHosts(nodes ...*models.Node) []string {
+	if !hostPeerGraph.load() {
+		return allHostIDs()
+	}
+	hostPeerGraph.mutex.RLock()
+	defer hostPeerGraph.mutex.RUnlock()
+	affected := map[string]struct{}{}
+	for _, node := range nodes {
+		affected[node.HostID.String()] = struct{}{}
+		for hostID := range hostPeerGraph.networks[node.Network] {
+			affected[hostID] = struct{}{}
+		}
+	}
+	return sortedHostIDs(affected)
+}
+
+// GetNetworkHosts - the ids of the hosts with a node in a network
+func GetNetworkHosts(network string) []string {
+	if !hostPeerGraph.load() {
+		return allHostIDs()
+	}
+	hostPeerGraph.mutex.RLock()
+	defer hostPeerGraph.mutex.RUnlock()
+	affected := map[string]struct{}{}
+	for hostID := range hostPeerGraph.networks[network] {
+		affected[hostID] = struct{}{}
+	}
+	return sortedHostIDs(affected)
+}
+
+// GetHostAffectedHosts - the ids of the hosts whose peers change when a host changes,
+// such as its endpoint, listen port or key
+func GetHostAffectedHosts(host *models.Host) []string {
+	if current, err := GetHost(host.ID.String()); err == nil {
+		// the nodes of a host may have changed since it was read
+		host = current
+	}
+	nodes := []*models.Node{}
+	for _, nodeID := range host.Nodes {
+		node, err := GetNodeByID(nodeID)
+		if err != nil {
+			continue
+		}
+		nodes = append(nodes, &node)
+	}
+	affected := GetAffectedHosts(nodes...)
+	if !StringSliceContains(affected, host.ID.String()) {
+		affected = append(affected, host.ID.String())
+	}
+	return affected
+}
+
+// == private ==
+
+// load - builds the graph from every node the first time it is used, false if the nodes can't be read
+func (g *peerGraph) load() bool {
+	g.mutex.RLock()
+	loaded := g.loaded
+	g.mutex.RUnlock()
+	if loaded {
+		return true
+	}
+	// hold the lock while reading so no write lands between the read and the build
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.loaded {
+		return true
+	}
+	nodes, err := GetAllNodes()
+	if err != nil {
+		return false
+	}
+	for _, node := range nodes {
+		g.add(node.ID.String(), peerGraphNode{network: node.Network, hostID: node.HostID.String()})
+	}
+	g.loaded = true
+	return true
+}
+
+// storeNode - moves a written node to its current network and host
+func (g *peerGraph) storeNode(node *models.Node) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if !g.loaded {
+		return
+	}
+	id := node.ID.String()
+	current := peerGraphNode{network: node.Network, hostID: node.HostID.String()}
+	if previous, ok := g.nodes[id]; ok {
+		if previous == current {
+			return
+		}
+		g.remove(id)
+	}
+	g.add(id, current)
+}
+
+// deleteNode - drops a deleted node from the graph
+func (g *peerGraph) deleteNode(id string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.remove(id)
+}
+
+// clear - forgets the graph, it is rebuilt on next use
+func (g *peerGraph) clear() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.loaded = false
+	g.nodes = map[string]peerGraphNode{}
+	g.networks = map[string]map[string]int{}
+}
+
+func (g *peerGraph) add(id string, node peerGraphNode) {
+	g.nodes[id] = node
+	if g.networks[node.network] == nil {
+		g.networks[node.network] = map[string]int{}
+	}
+	g.networks[node.network][node.hostID]++
+}
+
+func (g *peerGraph) remove(id string) {
+	node, ok := g.nodes[id]
+	if !ok {
+		return
+	}
+	delete(g.nodes, id)
+	hosts := g.networks[node.network]
+	hosts[node.hostID]--
+	if hosts[node.hostID] <= 0 {
+		delete(hosts, node.hostID)
+	}
+	if len(hosts) == 0 {
+		delete(g.networks, node.network)
+	}
+}
+
+// allHostIDs - every host is affected when the graph can't be built
+func allHostIDs() []string {
+	ids := map[string]struct{}{}
+	hosts, _ := GetAllHosts()
+	for _, host := range hosts {
+		ids[host.ID.String()] = struct{}{}
+	}
+	return sortedHostIDs(ids)
+}
+
+func sortedHostIDs(ids map[string]struct{}) []string {
+	sorted := make([]string, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
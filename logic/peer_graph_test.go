@@ -0,0 +1,49 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeerGraph(t *testing.T) {
+	graph := peerGraph{loaded: true, nodes: map[string]peerGraphNode{}, networks: map[string]map[string]int{}}
+	hostA, hostB := uuid.New(), uuid.New()
+	newNode := func(network string, host uuid.UUID) models.Node {
+		node := models.Node{}
+		node.ID = uuid.New()
+		node.Network = network
+		node.HostID = host
+		return node
+	}
+	skynetA, skynetB, othernetA := newNode("skynet", hostA), newNode("skynet", hostB), newNode("othernet", hostA)
+	for _, node := range []models.Node{skynetA, skynetB, othernetA} {
+		node := node
+		graph.storeNode(&node)
+	}
+	assert.Equal(t, map[string]int{hostA.String(): 1, hostB.String(): 1}, graph.networks["skynet"])
+	assert.Equal(t, map[string]int{hostA.String(): 1}, graph.networks["othernet"])
+	t.Run("Moved", func(t *testing.T) {
+		skynetB.HostID = hostA
+		graph.storeNode(&skynetB)
+		assert.Equal(t, map[string]int{hostA.String(): 2}, graph.networks["skynet"])
+	})
+	t.Run("Deleted", func(t *testing.T) {
+		graph.deleteNode(othernetA.ID.String())
+		graph.deleteNode(othernetA.ID.String())
+		_, ok := graph.networks["othernet"]
+		assert.False(t, ok)
+		graph.deleteNode(skynetA.ID.String())
+		assert.Equal(t, map[string]int{hostA.String(): 1}, graph.networks["skynet"])
+	})
+	t.Run("Cleared", func(t *testing.T) {
+		graph.clear()
+		assert.False(t, graph.loaded)
+		assert.Empty(t, graph.nodes)
+		skynetA.HostID = hostB
+		graph.storeNode(&skynetA)
+		assert.Empty(t, graph.nodes)
+	})
+}
@@ -4,9 +4,9 @@ import (
 	"errors"
 	"net"
 	"net/netip"
+	"time"
 
 	"github.com/gravitl/netmaker/database"
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic/acls/nodeacls"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/servercfg"
@@ -40,6 +40,12 @@ func GetPeerUpdateForHost(network string, host *models.Host, allNodes []models.N
 
 	// endpoint detection always comes from the server
 	hostPeerUpdate.EndpointDetection = servercfg.EndpointDetectionEnabled()
+	hostPeerUpdate.FlowExport = GetFlowExportConfig(host)
+	hostPeerUpdate.BandwidthLimits = GetHostBandwidthLimits(host)
+	hostPeerUpdate.QoS = GetHostQoS(host)
+	hostPeerUpdate.InMaintenanceWindow = HostInMaintenanceWindow(host, time.Now().UTC())
+	hostPeerUpdate.Probes = GetProbesForHost(host)
+	hostPeerUpdate.EgressHealthChecks = GetEgressHealthChecksForHost(host)
 	slog.Debug("peer update for host", "hostId", host.ID.String())
 	peerIndexMap := make(map[string]int)
 	for _, nodeID := range host.Nodes {
@@ -68,28 +74,7 @@ func GetPeerUpdateForHost(network string, host *models.Host, allNodes []models.N
 					ReplaceAllowedIPs:           true,
 					AllowedIPs:                  GetAllowedIPs(&node, &relayNode, nil),
 				}
-				uselocal := false
-				if host.EndpointIP.String() == relayHost.EndpointIP.String() {
-					// peer is on same network
-					// set to localaddress
-					uselocal = true
-					if node.LocalAddress.IP == nil {
-						// use public endpint
-						uselocal = false
-					}
-					if node.LocalAddress.String() == relayNode.LocalAddress.String() {
-						uselocal = false
-					}
-				}
-				relayPeer.Endpoint = &net.UDPAddr{
-					IP:   relayHost.EndpointIP,
-					Port: GetPeerListenPort(relayHost),
-				}
-
-				if uselocal {
-					relayPeer.Endpoint.IP = relayNode.LocalAddress.IP
-					relayPeer.Endpoint.Port = relayHost.ListenPort
-				}
+				relayPeer.Endpoint, _ = peerEndpoint(host, relayHost, &node, &relayNode)
 
 				hostPeerUpdate.Peers = append(hostPeerUpdate.Peers, relayPeer)
 			} else if deletedNode != nil && deletedNode.IsRelay {
@@ -110,14 +95,14 @@ func GetPeerUpdateForHost(network string, host *models.Host, allNodes []models.N
 		for _, peer := range currentPeers {
 			peer := peer
 			if peer.ID.String() == node.ID.String() {
-				logger.Log(2, "peer update, skipping self")
+				slog.Debug("peer update, skipping self")
 				//skip yourself
 				continue
 			}
 
 			peerHost, err := GetHost(peer.HostID.String())
 			if err != nil {
-				logger.Log(1, "no peer host", peer.HostID.String(), err.Error())
+				slog.Error("no peer host", "host_id", peer.HostID.String(), "error", err)
 				return models.HostPeerUpdate{}, err
 			}
 			peerConfig := wgtypes.PeerConfig{
@@ -125,10 +110,14 @@ func GetPeerUpdateForHost(network string, host *models.Host, allNodes []models.N
 				PersistentKeepaliveInterval: &peer.PersistentKeepalive,
 				ReplaceAllowedIPs:           true,
 			}
+			if keepalive, ok := host.PeerKeepalives[peerHost.ID.String()]; ok {
+				interval := time.Second * time.Duration(keepalive)
+				peerConfig.PersistentKeepaliveInterval = &interval
+			}
 			if peer.IsEgressGateway {
 				hostPeerUpdate.EgressRoutes = append(hostPeerUpdate.EgressRoutes, models.EgressNetworkRoutes{
 					NodeAddr:     node.PrimaryAddressIPNet(),
-					EgressRanges: peer.EgressGatewayRanges,
+					EgressRanges: healthyEgressRanges(&peer),
 				})
 			}
 			if (node.IsRelayed && node.RelayedBy != peer.ID.String()) || (peer.IsRelayed && peer.RelayedBy != node.ID.String()) {
@@ -142,28 +131,8 @@ func GetPeerUpdateForHost(network string, host *models.Host, allNodes []models.N
 				continue
 			}
 
-			uselocal := false
-			if host.EndpointIP.String() == peerHost.EndpointIP.String() {
-				// peer is on same network
-				// set to localaddress
-				uselocal = true
-				if node.LocalAddress.IP == nil {
-					// use public endpint
-					uselocal = false
-				}
-				if node.LocalAddress.String() == peer.LocalAddress.String() {
-					uselocal = false
-				}
-			}
-			peerConfig.Endpoint = &net.UDPAddr{
-				IP:   peerHost.EndpointIP,
-				Port: GetPeerListenPort(peerHost),
-			}
-
-			if uselocal {
-				peerConfig.Endpoint.IP = peer.LocalAddress.IP
-				peerConfig.Endpoint.Port = peerHost.ListenPort
-			}
+			var endpoints []models.EndpointCandidate
+			peerConfig.Endpoint, endpoints = peerEndpoint(host, peerHost, &node, &peer)
 			allowedips := GetAllowedIPs(&node, &peer, nil)
 			if peer.Action != models.NODE_DELETE &&
 				!peer.PendingDelete &&
@@ -181,6 +150,7 @@ func GetPeerUpdateForHost(network string, host *models.Host, allNodes []models.N
 				hostPeerUpdate.HostNetworkInfo[peerHost.PublicKey.String()] = models.HostNetworkInfo{
 					Interfaces: peerHost.Interfaces,
 					ListenPort: peerPort,
+					Endpoints:  endpoints,
 				}
 				nodePeer = peerConfig
 			} else {
@@ -191,6 +161,7 @@ func GetPeerUpdateForHost(network string, host *models.Host, allNodes []models.N
 				hostPeerUpdate.HostNetworkInfo[peerHost.PublicKey.String()] = models.HostNetworkInfo{
 					Interfaces: peerHost.Interfaces,
 					ListenPort: peerPort,
+					Endpoints:  endpoints,
 				}
 				nodePeer = hostPeerUpdate.Peers[peerIndexMap[peerHost.PublicKey.String()]]
 			}
@@ -199,7 +170,7 @@ func GetPeerUpdateForHost(network string, host *models.Host, allNodes []models.N
 				hostPeerUpdate.PeerIDs[peerHost.PublicKey.String()] = models.IDandAddr{
 					ID:         peer.ID.String(),
 					Address:    peer.PrimaryAddress(),
-					Name:       peerHost.Name,
+					Name:       NodeName(&peer, peerHost),
 					Network:    peer.Network,
 					ListenPort: peerHost.ListenPort,
 				}
@@ -220,9 +191,17 @@ func GetPeerUpdateForHost(network string, host *models.Host, allNodes []models.N
 					}
 				}
 			} else if !database.IsEmptyRecord(err) {
-				logger.Log(1, "error retrieving external clients:", err.Error())
+				slog.Error("error retrieving external clients", "error", err)
 			}
 		}
+		staticPeers, staticPeerIDAndAddrs := getStaticPeers(&node)
+		hostPeerUpdate.Peers = append(hostPeerUpdate.Peers, staticPeers...)
+		if node.Network == network {
+			for _, staticPeerIDAndAddr := range staticPeerIDAndAddrs {
+				hostPeerUpdate.PeerIDs[staticPeerIDAndAddr.ID] = staticPeerIDAndAddr
+			}
+			hostPeerUpdate.NodePeers = append(hostPeerUpdate.NodePeers, staticPeers...)
+		}
 		if node.IsEgressGateway && node.EgressGatewayRequest.NatEnabled == "yes" && len(node.EgressGatewayRequest.Ranges) > 0 {
 			hostPeerUpdate.FwUpdate.IsEgressGw = true
 			hostPeerUpdate.FwUpdate.EgressInfo[node.ID.String()] = models.EgressInfo{
@@ -279,6 +258,7 @@ func GetPeerUpdateForHost(network string, host *models.Host, allNodes []models.N
 		}
 	}
 
+	RunPostPeerUpdateHooks(host, &hostPeerUpdate)
 	return hostPeerUpdate, nil
 }
 
@@ -309,12 +289,16 @@ func getExtPeers(node, peer *models.Node) ([]wgtypes.PeerConfig, []models.IDandA
 		}
 		pubkey, err := wgtypes.ParseKey(extPeer.PublicKey)
 		if err != nil {
-			logger.Log(1, "error parsing ext pub key:", err.Error())
+			slog.Error("error parsing ext pub key", "error", err)
 			continue
 		}
 
 		if host.PublicKey.String() == extPeer.PublicKey ||
-			extPeer.IngressGatewayID != node.ID.String() || !extPeer.Enabled {
+			!extClientUsesGateway(&extPeer, node.ID.String()) || !extPeer.Enabled || IsExtClientKeyExpired(&extPeer) || extPeer.BlockedSource != "" {
+			continue
+		}
+		// other nodes reach a client on several gateways through the one it's using
+		if node.ID != peer.ID && len(extPeer.SecondaryGatewayIDs) > 0 && ActiveExtClientGateway(&extPeer) != node.ID.String() {
 			continue
 		}
 
@@ -370,7 +354,7 @@ func GetAllowedIPs(node, peer *models.Node, metrics *models.Metrics) []net.IPNet
 	if peer.IsIngressGateway {
 		extPeers, _, err := getExtPeers(peer, node)
 		if err != nil {
-			logger.Log(2, "could not retrieve ext peers for ", peer.ID.String(), err.Error())
+			slog.Warn("could not retrieve ext peers", "peer_id", peer.ID.String(), "error", err)
 		}
 		for _, extPeer := range extPeers {
 			allowedips = append(allowedips, extPeer.AllowedIPs...)
@@ -378,7 +362,7 @@ func GetAllowedIPs(node, peer *models.Node, metrics *models.Metrics) []net.IPNet
 		// if node is a failover node, add allowed ips from nodes it is handling
 		if metrics != nil && peer.Failover && metrics.FailoverPeers != nil {
 			// traverse through nodes that need handling
-			logger.Log(3, "peer", peer.ID.String(), "was found to be failover for", node.ID.String(), "checking failover peers...")
+			slog.Debug("peer was found to be failover checking failover peers...", "peer_id", peer.ID.String(), "node_id", node.ID.String())
 			for k := range metrics.FailoverPeers {
 				// if FailoverNode is me for this node, add allowedips
 				if metrics.FailoverPeers[k] == peer.ID.String() {
@@ -389,7 +373,7 @@ func GetAllowedIPs(node, peer *models.Node, metrics *models.Metrics) []net.IPNet
 						if err == nil && failoverNodeMetrics != nil {
 							if len(failoverNodeMetrics.NodeName) > 0 {
 								allowedips = append(allowedips, getNodeAllowedIPs(&nodeToFailover, peer)...)
-								logger.Log(0, "failing over node", nodeToFailover.ID.String(), nodeToFailover.PrimaryAddress(), "to failover node", peer.ID.String())
+								slog.Info("failing over node to failover node", "node_to_failover_id", nodeToFailover.ID.String(), "primary_address", nodeToFailover.PrimaryAddress(), "peer_id", peer.ID.String())
 							}
 						}
 					}
@@ -408,7 +392,7 @@ func getEgressIPs(peer *models.Node) []net.IPNet {
 
 	peerHost, err := GetHost(peer.HostID.String())
 	if err != nil {
-		logger.Log(0, "error retrieving host for peer", peer.ID.String(), err.Error())
+		slog.Error("error retrieving host for peer", "peer_id", peer.ID.String(), "error", err)
 	}
 
 	//check for internet gateway
@@ -417,24 +401,24 @@ func getEgressIPs(peer *models.Node) []net.IPNet {
 		internetGateway = true
 	}
 	allowedips := []net.IPNet{}
-	for _, iprange := range peer.EgressGatewayRanges { // go through each cidr for egress gateway
+	for _, iprange := range healthyEgressRanges(peer) { // go through each cidr for egress gateway
 		_, ipnet, err := net.ParseCIDR(iprange) // confirming it's valid cidr
 		if err != nil {
-			logger.Log(1, "could not parse gateway IP range. Not adding ", iprange)
+			slog.Error("could not parse gateway IP range. Not adding", "iprange", iprange)
 			continue // if can't parse CIDR
 		}
 		// getting the public ip of node
 		if ipnet.Contains(peerHost.EndpointIP) && !internetGateway { // ensuring egress gateway range does not contain endpoint of node
-			logger.Log(2, "egress IP range of ", iprange, " overlaps with ", peerHost.EndpointIP.String(), ", omitting")
+			slog.Debug("egress IP range overlaps, omitting", "iprange", iprange, "endpoint_ip", peerHost.EndpointIP.String())
 			continue // skip adding egress range if overlaps with node's ip
 		}
 		// TODO: Could put in a lot of great logic to avoid conflicts / bad routes
 		if ipnet.Contains(peer.LocalAddress.IP) && !internetGateway { // ensuring egress gateway range does not contain public ip of node
-			logger.Log(2, "egress IP range of ", iprange, " overlaps with ", peer.LocalAddress.String(), ", omitting")
+			slog.Debug("egress IP range overlaps, omitting", "iprange", iprange, "local_address", peer.LocalAddress.String())
 			continue // skip adding egress range if overlaps with node's local ip
 		}
 		if err != nil {
-			logger.Log(1, "error encountered when setting egress range", err.Error())
+			slog.Error("error encountered when setting egress range", "error", err)
 		} else {
 			allowedips = append(allowedips, *ipnet)
 		}
@@ -486,12 +470,12 @@ func getNodeAllowedIPs(peer, node *models.Node) []net.IPNet {
 // getAllowedIpsForRelayed - returns the peerConfig for a node relayed by relay
 func getAllowedIpsForRelayed(relayed, relay *models.Node) (allowedIPs []net.IPNet) {
 	if relayed.RelayedBy != relay.ID.String() {
-		logger.Log(0, "RelayedByRelay called with invalid parameters")
+		slog.Error("RelayedByRelay called with invalid parameters")
 		return
 	}
 	peers, err := GetNetworkNodes(relay.Network)
 	if err != nil {
-		logger.Log(0, "error getting network clients", err.Error())
+		slog.Error("error getting network clients", "error", err)
 		return
 	}
 	for _, peer := range peers {
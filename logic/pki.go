@@ -0,0 +1,448 @@
+package logic
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/crypto/ocsp"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// pkiCAName - name of the internal CA certificate in the certs table
+	pkiCAName = "pkica.pem"
+	// pkiCAKeyName - name of the internal CA private key in the certs table
+	pkiCAKeyName = "pkica.key"
+	// pkiCAValidityDays - how long the internal CA is valid for
+	pkiCAValidityDays = 3650
+	// pkiCertValidityDays - how long certificates are valid for unless asked otherwise, hosts renew theirs before
+	pkiCertValidityDays = 90
+	// pkiStatusValidity - how long a revocation list or OCSP response is valid for before it must be fetched again
+	pkiStatusValidity = time.Hour
+)
+
+var (
+	// ErrInvalidCSR - a certificate request isn't a valid PEM CSR
+	ErrInvalidCSR = errors.New("invalid certificate request")
+	// ErrCertRevoked - a certificate of the internal CA was revoked
+	ErrCertRevoked = errors.New("certificate revoked")
+
+	pkiCAMutex sync.Mutex
+)
+
+// IssueCertificate - issues a host, broker or server certificate from the internal CA,
+// with a generated key pair when the request has no CSR
+func IssueCertificate(request *models.PKICertificateRequest) (models.IssuedCertificate, error) {
+	if err := validator.New().Struct(request); err != nil {
+		return models.IssuedCertificate{}, err
+	}
+	record := models.PKICertificate{
+		Kind:        request.Kind,
+		Subject:     request.Subject,
+		DNSNames:    request.DNSNames,
+		IPAddresses: request.IPAddresses,
+	}
+	if request.Kind == models.CertKindHost {
+		host, err := GetHost(request.Subject)
+		if err != nil {
+			return models.IssuedCertificate{}, fmt.Errorf("host certificates are issued to a host id: %w", err)
+		}
+		record.HostID = host.ID.String()
+		record.DNSNames, record.IPAddresses = nil, nil
+	}
+	if request.CertificateRequest == "" {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return models.IssuedCertificate{}, err
+		}
+		keyBytes, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return models.IssuedCertificate{}, err
+		}
+		issued, err := issueCertificate(&record, key.Public(), request.Days)
+		if err != nil {
+			return issued, err
+		}
+		issued.PrivateKey = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes}))
+		return issued, nil
+	}
+	publicKey, err := parseCSR(request.CertificateRequest)
+	if err != nil {
+		return models.IssuedCertificate{}, err
+	}
+	return issueCertificate(&record, publicKey, request.Days)
+}
+
+// RenewCertificate - issues a certificate again with a new serial and validity, for the key of a CSR if one is given,
+// else for the same key; the old certificate stays valid until it expires so its holder can switch over
+func RenewCertificate(serial string, request *models.PKIRenewRequest) (models.IssuedCertificate, error) {
+	if err := validator.New().Struct(request); err != nil {
+		return models.IssuedCertificate{}, err
+	}
+	old, err := GetCertificate(serial)
+	if err != nil {
+		return models.IssuedCertificate{}, err
+	}
+	if old.Revoked {
+		return models.IssuedCertificate{}, ErrCertRevoked
+	}
+	var publicKey crypto.PublicKey
+	if request.CertificateRequest != "" {
+		if publicKey, err = parseCSR(request.CertificateRequest); err != nil {
+			return models.IssuedCertificate{}, err
+		}
+	} else {
+		block, _ := pem.Decode([]byte(old.Certificate))
+		if block == nil {
+			return models.IssuedCertificate{}, errors.New("certificate " + serial + " was not kept, renew it with a certificate request")
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return models.IssuedCertificate{}, err
+		}
+		publicKey = cert.PublicKey
+	}
+	record := models.PKICertificate{
+		Kind:        old.Kind,
+		Subject:     old.Subject,
+		HostID:      old.HostID,
+		DNSNames:    old.DNSNames,
+		IPAddresses: old.IPAddresses,
+	}
+	return issueCertificate(&record, publicKey, request.Days)
+}
+
+// RevokeCertificate - revokes a certificate of the internal CA, it's listed in the CRL and OCSP answers for it
+func RevokeCertificate(serial, reason string) (models.PKICertificate, error) {
+	record, err := GetCertificate(serial)
+	if err != nil {
+		return record, err
+	}
+	if record.Revoked {
+		return record, nil
+	}
+	record.Revoked = true
+	record.RevokedAt = time.Now().UTC()
+	record.RevocationReason = reason
+	if err := savePKICertificate(&record); err != nil {
+		return record, err
+	}
+	slog.Info("revoked certificate", "kind", record.Kind, "subject", record.Subject, "serial", serial, "reason", reason)
+	return record, nil
+}
+
+// GetCertificate - a certificate issued by the internal CA by its serial
+func GetCertificate(serial string) (models.PKICertificate, error) {
+	var record models.PKICertificate
+	value, err := database.FetchRecord(database.PKI_CERTS_TABLE_NAME, serial)
+	if err != nil {
+		return record, err
+	}
+	err = json.Unmarshal([]byte(value), &record)
+	return record, err
+}
+
+// GetCertificates - the certificates issued by the internal CA, of a kind when it's set, newest first
+func GetCertificates(kind string) ([]models.PKICertificate, error) {
+	records := []models.PKICertificate{}
+	values, err := database.FetchRecords(database.PKI_CERTS_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return records, nil
+		}
+		return nil, err
+	}
+	for _, value := range values {
+		var record models.PKICertificate
+		if err := json.Unmarshal([]byte(value), &record); err != nil {
+			continue
+		}
+		if kind != "" && record.Kind != kind {
+			continue
+		}
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].IssuedAt.After(records[j].IssuedAt)
+	})
+	return records, nil
+}
+
+// GetCA - the PEM certificate of the internal CA, for hosts and the broker to verify certificates with
+func GetCA() ([]byte, error) {
+	ca, _, err := getPKICA()
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), nil
+}
+
+// GetCRL - the PEM revocation list of the revoked certificates that haven't expired, signed by the internal CA
+func GetCRL() ([]byte, error) {
+	ca, caKey, err := getPKICA()
+	if err != nil {
+		return nil, err
+	}
+	records, err := GetCertificates("")
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	revoked := []pkix.RevokedCertificate{}
+	for _, record := range records {
+		if !record.Revoked || record.NotAfter.Before(now) {
+			continue
+		}
+		serial, ok := new(big.Int).SetString(record.Serial, 10)
+		if !ok {
+			continue
+		}
+		revoked = append(revoked, pkix.RevokedCertificate{SerialNumber: serial, RevocationTime: record.RevokedAt})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:              big.NewInt(now.Unix()),
+		ThisUpdate:          now,
+		NextUpdate:          now.Add(pkiStatusValidity),
+		RevokedCertificates: revoked,
+	}, ca, caKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), nil
+}
+
+// RespondOCSP - the DER OCSP response, signed by the internal CA, to a DER OCSP request
+func RespondOCSP(data []byte) []byte {
+	request, err := ocsp.ParseRequest(data)
+	if err != nil {
+		return ocsp.MalformedRequestErrorResponse
+	}
+	ca, caKey, err := getPKICA()
+	if err != nil {
+		return ocsp.InternalErrorErrorResponse
+	}
+	now := time.Now()
+	template := ocsp.Response{
+		Status:       ocsp.Unknown,
+		SerialNumber: request.SerialNumber,
+		ThisUpdate:   now,
+		NextUpdate:   now.Add(pkiStatusValidity),
+	}
+	if record, err := GetCertificate(request.SerialNumber.String()); err == nil {
+		template.Status = ocsp.Good
+		if record.Revoked {
+			template.Status = ocsp.Revoked
+			template.RevokedAt = record.RevokedAt
+			template.RevocationReason = ocsp.Unspecified
+		}
+	}
+	response, err := ocsp.CreateResponse(ca, ca, template, caKey)
+	if err != nil {
+		slog.Error("failed to create ocsp response", "serial", request.SerialNumber.String(), "error", err)
+		return ocsp.InternalErrorErrorResponse
+	}
+	return response
+}
+
+// == private ==
+
+// issueCertificate - signs a certificate for a public key with the internal CA and records it,
+// host certificates are for client auth, broker and server ones for server auth too
+func issueCertificate(record *models.PKICertificate, publicKey crypto.PublicKey, days int) (models.IssuedCertificate, error) {
+	ca, caKey, err := getPKICA()
+	if err != nil {
+		return models.IssuedCertificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return models.IssuedCertificate{}, err
+	}
+	if days == 0 {
+		days = pkiCertValidityDays
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: record.Subject},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.AddDate(0, 0, days),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              record.DNSNames,
+	}
+	if record.Kind != models.CertKindHost {
+		template.ExtKeyUsage = append(template.ExtKeyUsage, x509.ExtKeyUsageServerAuth)
+		if len(template.DNSNames) == 0 && net.ParseIP(record.Subject) == nil {
+			template.DNSNames = []string{record.Subject}
+		}
+	}
+	for _, address := range record.IPAddresses {
+		template.IPAddresses = append(template.IPAddresses, net.ParseIP(address))
+	}
+	if api := servercfg.GetAPIConnString(); api != "" {
+		template.OCSPServer = []string{"https://" + api + "/api/v1/pki/ocsp"}
+		template.CRLDistributionPoints = []string{"https://" + api + "/api/v1/pki/crl"}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, publicKey, caKey)
+	if err != nil {
+		return models.IssuedCertificate{}, err
+	}
+	record.Serial = serial.String()
+	record.Certificate = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	record.IssuedAt = now.UTC()
+	record.NotAfter = template.NotAfter.UTC()
+	if err := savePKICertificate(record); err != nil {
+		return models.IssuedCertificate{}, err
+	}
+	return models.IssuedCertificate{
+		Certificate: record.Certificate,
+		CA:          string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})),
+		Serial:      record.Serial,
+		NotAfter:    record.NotAfter,
+	}, nil
+}
+
+// parseCSR - the public key of a PEM certificate request with a valid signature
+func parseCSR(csrPEM string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(csrPEM))
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, ErrInvalidCSR
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil || csr.CheckSignature() != nil {
+		return nil, ErrInvalidCSR
+	}
+	return csr.PublicKey, nil
+}
+
+// getPKICA - the internal CA and its key, created the first time they're needed;
+// the key is ecdsa as OCSP responses can't be signed with ed25519
+func getPKICA() (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	pkiCAMutex.Lock()
+	defer pkiCAMutex.Unlock()
+	caPEM, certErr := readCertsTablePEM(pkiCAName)
+	keyPEM, keyErr := readCertsTablePEM(pkiCAKeyName)
+	if certErr == nil && keyErr == nil {
+		certBlock, _ := pem.Decode(caPEM)
+		keyBlock, _ := pem.Decode(keyPEM)
+		if certBlock == nil || keyBlock == nil {
+			return nil, nil, errors.New("invalid internal ca in database")
+		}
+		ca, err := x509.ParseCertificate(certBlock.Bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		key, err := x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		private, ok := key.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, nil, errors.New("internal ca key is not ecdsa")
+		}
+		return ca, private, nil
+	}
+	if certErr != nil && !database.IsEmptyRecord(certErr) {
+		return nil, nil, certErr
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "Netmaker Internal CA"},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.AddDate(0, 0, pkiCAValidityDays),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, nil, err
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := saveCertsTablePEM(pkiCAKeyName, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes})); err != nil {
+		return nil, nil, err
+	}
+	if err := saveCertsTablePEM(pkiCAName, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})); err != nil {
+		return nil, nil, err
+	}
+	slog.Info("created internal certificate authority")
+	return ca, key, nil
+}
+
+// readCertsTablePEM - reads PEM bytes stored in the certs table the way serverctl stores them
+func readCertsTablePEM(name string) ([]byte, error) {
+	record, err := database.FetchRecord(database.CERTS_TABLE_NAME, name)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	err = json.Unmarshal([]byte(record), &data)
+	return data, err
+}
+
+func saveCertsTablePEM(name string, data []byte) error {
+	value, err := json.Marshal(&data)
+	if err != nil {
+		return err
+	}
+	return database.Insert(name, string(value), database.CERTS_TABLE_NAME)
+}
+
+func savePKICertificate(record *models.PKICertificate) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return database.Insert(record.Serial, string(data), database.PKI_CERTS_TABLE_NAME)
+}
+
+// prunePKICertificates - forgets the certificates that expired, they no longer need to be revoked
+func prunePKICertificates() error {
+	records, err := GetCertificates("")
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, record := range records {
+		if record.NotAfter.Before(now) {
+			if err := database.DeleteRecord(database.PKI_CERTS_TABLE_NAME, record.Serial); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package logic
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestPKICertificateLifecycle(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	defer database.DeleteAllRecords(database.PKI_CERTS_TABLE_NAME)
+	_, err := IssueCertificate(&models.PKICertificateRequest{Kind: "client", Subject: "broker.example.com"})
+	assert.NotNil(t, err)
+
+	issued, err := IssueCertificate(&models.PKICertificateRequest{Kind: models.CertKindBroker, Subject: "broker.example.com"})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, issued.PrivateKey)
+	renewed, err := RenewCertificate(issued.Serial, &models.PKIRenewRequest{})
+	assert.Nil(t, err)
+	assert.NotEqual(t, issued.Serial, renewed.Serial)
+	assert.Empty(t, renewed.PrivateKey)
+
+	block, _ := pem.Decode([]byte(issued.CA))
+	ca, err := x509.ParseCertificate(block.Bytes)
+	assert.Nil(t, err)
+	block, _ = pem.Decode([]byte(issued.Certificate))
+	cert, err := x509.ParseCertificate(block.Bytes)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"broker.example.com"}, cert.DNSNames)
+	request, err := ocsp.CreateRequest(cert, ca, nil)
+	assert.Nil(t, err)
+	response, err := ocsp.ParseResponse(RespondOCSP(request), ca)
+	assert.Nil(t, err)
+	assert.Equal(t, ocsp.Good, response.Status)
+
+	_, err = RevokeCertificate(issued.Serial, "key compromise")
+	assert.Nil(t, err)
+	response, err = ocsp.ParseResponse(RespondOCSP(request), ca)
+	assert.Nil(t, err)
+	assert.Equal(t, ocsp.Revoked, response.Status)
+	_, err = RenewCertificate(issued.Serial, &models.PKIRenewRequest{})
+	assert.ErrorIs(t, err, ErrCertRevoked)
+}
@@ -0,0 +1,588 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic/acls"
+	"github.com/gravitl/netmaker/logic/acls/nodeacls"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+// ErrInvalidPolicy - a policy file doesn't parse or names nodes, zones or ext clients the network doesn't have
+var ErrInvalidPolicy = errors.New("invalid policy")
+
+// policyBlocks - the blocks a policy file may have, the number of labels each takes and its attributes,
+// true for those holding a list
+var policyBlocks = map[string]struct {
+	labels     int
+	attributes map[string]bool
+	required   []string
+}{
+	"network":     {labels: 1, attributes: map[string]bool{"default_acl": false}},
+	"zones":       {attributes: map[string]bool{"default": false}},
+	"zone":        {labels: 1, attributes: map[string]bool{"tags": true}, required: []string{"tags"}},
+	"zone_policy": {attributes: map[string]bool{"from": false, "to": false, "action": false, "ports": true}, required: []string{"from", "to", "action"}},
+	"node_rule":   {attributes: map[string]bool{"nodes": true, "action": false}, required: []string{"nodes", "action"}},
+	"ext_client":  {labels: 1, attributes: map[string]bool{"deny": true}, required: []string{"deny"}},
+}
+
+// policyState - the network a policy is exported from or applied to
+type policyState struct {
+	network models.Network
+	nodes   []models.Node
+	// names - the name of each node in policy files by id
+	names     map[string]string
+	container acls.ACLContainer
+	clients   []models.ExtClient
+}
+
+// ExportNetworkPolicy - the ACL policy of a network as a policy file: its default ACL and zones, the pairs of nodes
+// whose ACL differs from what those give them and what each ext client is denied
+func ExportNetworkPolicy(netID string) (string, error) {
+	state, err := loadPolicyState(netID)
+	if err != nil {
+		return "", err
+	}
+	return formatPolicy(exportPolicy(state)), nil
+}
+
+// ApplyNetworkPolicy - checks a policy file against a network and sets the network's default ACL, zones, node ACLs
+// and the denied ACLs of its ext clients to it; a dry run only plans the changes. An invalid file returns
+// ErrInvalidPolicy along with a plan holding its errors
+func ApplyNetworkPolicy(netID, text string, dryRun bool) (models.PolicyPlan, error) {
+	plan := models.PolicyPlan{Network: netID, DryRun: dryRun, Errors: []models.PolicyError{}, Changes: []string{}}
+	state, err := loadPolicyState(netID)
+	if err != nil {
+		return plan, err
+	}
+	policy, policyErrors := parsePolicy(text)
+	if len(policyErrors) == 0 {
+		policyErrors = checkPolicy(&policy, state)
+	}
+	if len(policyErrors) > 0 {
+		plan.Errors = policyErrors
+		messages := []string{}
+		for _, policyError := range policyErrors {
+			messages = append(messages, formatPolicyError(policyError))
+		}
+		return plan, fmt.Errorf("%w: %s", ErrInvalidPolicy, strings.Join(messages, "; "))
+	}
+	plan.Valid = true
+	access, clientDenies := planPolicy(&policy, state, &plan)
+	if dryRun || len(plan.Changes) == 0 {
+		return plan, nil
+	}
+	if IsNetworkLocked(netID) {
+		return plan, ErrNetworkLocked
+	}
+
+	network := state.network
+	if network.DefaultACL != policy.DefaultACL || formatZones(network.Zones) != formatZones(policy.Zones) {
+		network.DefaultACL = policy.DefaultACL
+		network.Zones = policy.Zones
+		if _, _, _, _, _, err = UpdateNetwork(&state.network, &network); err != nil {
+			return plan, err
+		}
+	}
+	changed := false
+	for pair, allowed := range access {
+		value := acls.NotAllowed
+		if allowed {
+			value = acls.Allowed
+		}
+		a, b := acls.AclID(pair[0]), acls.AclID(pair[1])
+		if current, ok := state.container[a][b]; ok && current != value {
+			state.container.ChangeAccess(a, b, value)
+			changed = true
+		}
+	}
+	if changed {
+		if _, err := state.container.Save(acls.ContainerID(netID)); err != nil {
+			return plan, err
+		}
+	}
+	for i := range state.clients {
+		client := state.clients[i]
+		denies, ok := clientDenies[client.ClientID]
+		if !ok {
+			continue
+		}
+		SetClientACLs(&client, denies)
+		if err := SaveExtClient(&client); err != nil {
+			return plan, err
+		}
+	}
+	slog.Info("applied acl policy", "network", netID, "changes", len(plan.Changes))
+	return plan, nil
+}
+
+// == private ==
+
+func loadPolicyState(netID string) (*policyState, error) {
+	network, err := GetNetwork(netID)
+	if err != nil {
+		return nil, err
+	}
+	state := &policyState{network: network}
+	if state.nodes, err = GetNetworkNodes(netID); err != nil && !database.IsEmptyRecord(err) {
+		return nil, err
+	}
+	if state.clients, err = GetNetworkExtClients(netID); err != nil && !database.IsEmptyRecord(err) {
+		return nil, err
+	}
+	if state.container, err = nodeacls.FetchAllACLs(nodeacls.NetworkID(netID)); err != nil {
+		return nil, err
+	}
+	hostNames := map[string]string{}
+	for _, node := range state.nodes {
+		if host, err := GetHost(node.HostID.String()); err == nil {
+			hostNames[node.HostID.String()] = host.Name
+		}
+	}
+	state.names = policyNodeNames(state.nodes, hostNames)
+	return state, nil
+}
+
+// policyNodeNames - the name of each node in policy files, its node or host name unless another node of the network
+// goes by it, its id otherwise
+func policyNodeNames(nodes []models.Node, hostNames map[string]string) map[string]string {
+	names := map[string]string{}
+	taken := map[string]int{}
+	for i := range nodes {
+		name := nodes[i].Name
+		if name == "" {
+			name = hostNames[nodes[i].HostID.String()]
+		}
+		names[nodes[i].ID.String()] = name
+		taken[name]++
+	}
+	for i := range nodes {
+		taken[nodes[i].ID.String()]++
+	}
+	for id, name := range names {
+		if name == "" || taken[name] > 1 {
+			names[id] = id
+		}
+	}
+	return names
+}
+
+// exportPolicy - the policy a network has now
+func exportPolicy(state *policyState) models.NetworkPolicy {
+	policy := models.NetworkPolicy{
+		Network:    state.network.NetID,
+		DefaultACL: state.network.DefaultACL,
+		Zones:      state.network.Zones,
+		NodeRules:  []models.PolicyNodeRule{},
+		ExtClients: []models.PolicyExtClient{},
+	}
+	if policy.DefaultACL != "no" {
+		policy.DefaultACL = "yes"
+	}
+	for pair, allowed := range policyAccess(policy.DefaultACL, policy.Zones, state.nodes) {
+		current, ok := state.container[acls.AclID(pair[0])][acls.AclID(pair[1])]
+		if !ok || (current == acls.Allowed) == allowed {
+			continue
+		}
+		rule := models.PolicyNodeRule{Nodes: [2]string{state.names[pair[0]], state.names[pair[1]]}, Action: models.ZoneDeny}
+		if current == acls.Allowed {
+			rule.Action = models.ZoneAllow
+		}
+		if rule.Nodes[0] > rule.Nodes[1] {
+			rule.Nodes[0], rule.Nodes[1] = rule.Nodes[1], rule.Nodes[0]
+		}
+		policy.NodeRules = append(policy.NodeRules, rule)
+	}
+	sort.Slice(policy.NodeRules, func(i, j int) bool {
+		a, b := policy.NodeRules[i].Nodes, policy.NodeRules[j].Nodes
+		return a[0] < b[0] || (a[0] == b[0] && a[1] < b[1])
+	})
+	for _, client := range state.clients {
+		if len(client.DeniedACLs) == 0 {
+			continue
+		}
+		denied := []string{}
+		for id := range client.DeniedACLs {
+			if name, ok := state.names[id]; ok {
+				id = name
+			}
+			denied = append(denied, id)
+		}
+		sort.Strings(denied)
+		policy.ExtClients = append(policy.ExtClients, models.PolicyExtClient{ClientID: client.ClientID, Deny: denied})
+	}
+	sort.Slice(policy.ExtClients, func(i, j int) bool {
+		return policy.ExtClients[i].ClientID < policy.ExtClients[j].ClientID
+	})
+	return policy
+}
+
+// policyAccess - whether each pair of nodes, by ids in order, may reach each other under a default ACL and zones
+func policyAccess(defaultACL string, zones *models.NetworkZones, nodes []models.Node) map[[2]string]bool {
+	ids := []string{}
+	for i := range nodes {
+		ids = append(ids, nodes[i].ID.String())
+	}
+	sort.Strings(ids)
+	access := map[[2]string]bool{}
+	for i, a := range ids {
+		for _, b := range ids[i+1:] {
+			access[[2]string{a, b}] = defaultACL != "no"
+		}
+	}
+	if zones != nil {
+		_, zoned, _ := compileZones(zones, nodes)
+		for pair, allowed := range zoned {
+			access[pair] = allowed
+		}
+	}
+	return access
+}
+
+// planPolicy - the node ACLs and ext client denies a valid policy sets, recording the changes they make in the plan;
+// only the ext clients whose denies change are returned
+func planPolicy(policy *models.NetworkPolicy, state *policyState, plan *models.PolicyPlan) (map[[2]string]bool, map[string]map[string]struct{}) {
+	current := state.network.DefaultACL
+	if current != "no" {
+		current = "yes"
+	}
+	if current != policy.DefaultACL {
+		plan.Changes = append(plan.Changes, fmt.Sprintf("default_acl %s -> %s", current, policy.DefaultACL))
+	}
+	if formatZones(state.network.Zones) != formatZones(policy.Zones) {
+		plan.Changes = append(plan.Changes, "zones changed")
+	}
+	ids := policyNodeIDs(state)
+	access := policyAccess(policy.DefaultACL, policy.Zones, state.nodes)
+	for _, rule := range policy.NodeRules {
+		a, b := ids[rule.Nodes[0]], ids[rule.Nodes[1]]
+		if a > b {
+			a, b = b, a
+		}
+		access[[2]string{a, b}] = rule.Action == models.ZoneAllow
+	}
+	aclChanges := []string{}
+	for pair, allowed := range access {
+		value, ok := state.container[acls.AclID(pair[0])][acls.AclID(pair[1])]
+		if !ok || (value == acls.Allowed) == allowed {
+			continue
+		}
+		action := models.ZoneDeny
+		if allowed {
+			action = models.ZoneAllow
+		}
+		names := []string{state.names[pair[0]], state.names[pair[1]]}
+		sort.Strings(names)
+		aclChanges = append(aclChanges, fmt.Sprintf("%s %s <-> %s", action, names[0], names[1]))
+	}
+	sort.Strings(aclChanges)
+	plan.Changes = append(plan.Changes, aclChanges...)
+
+	wanted := map[string][]string{}
+	for _, client := range policy.ExtClients {
+		wanted[client.ClientID] = client.Deny
+	}
+	clientDenies := map[string]map[string]struct{}{}
+	for _, client := range state.clients {
+		denies := map[string]struct{}{}
+		names := []string{}
+		for _, entry := range wanted[client.ClientID] {
+			id := entry
+			if nodeID, ok := ids[entry]; ok {
+				id = nodeID
+			}
+			denies[id] = struct{}{}
+			names = append(names, entry)
+		}
+		if deniesEqual(client.DeniedACLs, denies) {
+			continue
+		}
+		clientDenies[client.ClientID] = denies
+		sort.Strings(names)
+		plan.Changes = append(plan.Changes, fmt.Sprintf("ext client %s denies [%s]", client.ClientID, strings.Join(names, ", ")))
+	}
+	return access, clientDenies
+}
+
+// policyNodeIDs - the id of each node by its name and id in policy files
+func policyNodeIDs(state *policyState) map[string]string {
+	ids := map[string]string{}
+	for id, name := range state.names {
+		ids[name] = id
+		ids[id] = id
+	}
+	return ids
+}
+
+func deniesEqual(a, b map[string]struct{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for id := range a {
+		if _, ok := b[id]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// checkPolicy - the semantic errors of a parsed policy against the network it's applied to
+func checkPolicy(policy *models.NetworkPolicy, state *policyState) []models.PolicyError {
+	policyErrors := []models.PolicyError{}
+	if policy.Network != state.network.NetID {
+		policyErrors = append(policyErrors, models.PolicyError{Message: fmt.Sprintf("policy is for network %s, not %s", policy.Network, state.network.NetID)})
+	}
+	if policy.Zones != nil {
+		if err := validateZones(policy.Zones); err != nil {
+			policyErrors = append(policyErrors, models.PolicyError{Message: err.Error()})
+		}
+	}
+	ids := policyNodeIDs(state)
+	seen := map[[2]string]int{}
+	for _, rule := range policy.NodeRules {
+		a, okA := ids[rule.Nodes[0]]
+		b, okB := ids[rule.Nodes[1]]
+		switch {
+		case !okA || !okB:
+			unknown := rule.Nodes[0]
+			if okA {
+				unknown = rule.Nodes[1]
+			}
+			policyErrors = append(policyErrors, models.PolicyError{Line: rule.Line, Message: "unknown node " + unknown})
+			continue
+		case a == b:
+			policyErrors = append(policyErrors, models.PolicyError{Line: rule.Line, Message: "node_rule names the same node twice"})
+			continue
+		}
+		if a > b {
+			a, b = b, a
+		}
+		if line, ok := seen[[2]string{a, b}]; ok {
+			policyErrors = append(policyErrors, models.PolicyError{Line: rule.Line, Message: fmt.Sprintf("nodes %s and %s already have a rule on line %d", rule.Nodes[0], rule.Nodes[1], line)})
+		}
+		seen[[2]string{a, b}] = rule.Line
+	}
+	clients := map[string]bool{}
+	for _, client := range state.clients {
+		clients[client.ClientID] = true
+	}
+	listed := map[string]bool{}
+	for _, client := range policy.ExtClients {
+		switch {
+		case !clients[client.ClientID]:
+			policyErrors = append(policyErrors, models.PolicyError{Line: client.Line, Message: "unknown ext client " + client.ClientID})
+			continue
+		case listed[client.ClientID]:
+			policyErrors = append(policyErrors, models.PolicyError{Line: client.Line, Message: "ext client " + client.ClientID + " is listed twice"})
+		case !isEE && len(client.Deny) > 0:
+			policyErrors = append(policyErrors, models.PolicyError{Line: client.Line, Message: "ext client ACLs need the enterprise edition"})
+		}
+		listed[client.ClientID] = true
+		for _, entry := range client.Deny {
+			if _, ok := ids[entry]; !ok && (!clients[entry] || entry == client.ClientID) {
+				policyErrors = append(policyErrors, models.PolicyError{Line: client.Line, Message: fmt.Sprintf("ext client %s denies unknown node or ext client %s", client.ClientID, entry)})
+			}
+		}
+	}
+	return policyErrors
+}
+
+// parsePolicy - reads a policy file, reporting the syntax errors and the blocks and attributes it can't have
+func parsePolicy(text string) (models.NetworkPolicy, []models.PolicyError) {
+	policy := models.NetworkPolicy{NodeRules: []models.PolicyNodeRule{}, ExtClients: []models.PolicyExtClient{}}
+	blocks, err := parseHCL(text)
+	if err != nil {
+		var syntaxError *hclError
+		if errors.As(err, &syntaxError) {
+			return policy, []models.PolicyError{{Line: syntaxError.line, Message: syntaxError.message}}
+		}
+		return policy, []models.PolicyError{{Message: err.Error()}}
+	}
+	if len(blocks) != 1 || blocks[0].kind != "network" {
+		return policy, []models.PolicyError{{Message: "a policy file holds a single network block"}}
+	}
+	policyErrors := []models.PolicyError{}
+	network := blocks[0]
+	values := policyAttributes(network, &policyErrors)
+	if len(network.labels) == 1 {
+		policy.Network = network.labels[0]
+	}
+	policy.DefaultACL = "yes"
+	if defaultACL, ok := values["default_acl"]; ok {
+		policy.DefaultACL = defaultACL.values[0]
+		if policy.DefaultACL != "yes" && policy.DefaultACL != "no" {
+			policyErrors = append(policyErrors, models.PolicyError{Line: defaultACL.line, Message: "default_acl must be yes or no"})
+		}
+	}
+	zones := models.NetworkZones{Zones: []models.Zone{}, Policies: []models.ZonePolicy{}}
+	hasZones := false
+	for _, block := range network.blocks {
+		if block.kind == "network" {
+			policyErrors = append(policyErrors, models.PolicyError{Line: block.line, Message: "network blocks can't be nested"})
+			continue
+		}
+		values := policyAttributes(block, &policyErrors)
+		switch block.kind {
+		case "zones":
+			hasZones = true
+			if value, ok := values["default"]; ok {
+				zones.Default = value.values[0]
+			}
+		case "zone":
+			hasZones = true
+			if len(block.labels) == 1 {
+				zones.Zones = append(zones.Zones, models.Zone{Name: block.labels[0], Tags: values["tags"].values})
+			}
+		case "zone_policy":
+			hasZones = true
+			zonePolicy := models.ZonePolicy{
+				From:   values["from"].firstValue(),
+				To:     values["to"].firstValue(),
+				Action: values["action"].firstValue(),
+			}
+			if ports := values["ports"].values; len(ports) > 0 {
+				zonePolicy.Ports = ports
+			}
+			zones.Policies = append(zones.Policies, zonePolicy)
+		case "node_rule":
+			rule := models.PolicyNodeRule{Action: values["action"].firstValue(), Line: block.line}
+			if nodes, ok := values["nodes"]; ok && len(nodes.values) != 2 {
+				policyErrors = append(policyErrors, models.PolicyError{Line: nodes.line, Message: "node_rule names exactly two nodes"})
+				continue
+			} else if ok {
+				rule.Nodes = [2]string{nodes.values[0], nodes.values[1]}
+			}
+			if action, ok := values["action"]; ok && rule.Action != models.ZoneAllow && rule.Action != models.ZoneDeny {
+				policyErrors = append(policyErrors, models.PolicyError{Line: action.line, Message: "action must be allow or deny"})
+				continue
+			}
+			policy.NodeRules = append(policy.NodeRules, rule)
+		case "ext_client":
+			if len(block.labels) == 1 {
+				policy.ExtClients = append(policy.ExtClients, models.PolicyExtClient{ClientID: block.labels[0], Deny: values["deny"].values, Line: block.line})
+			}
+		}
+	}
+	if hasZones {
+		policy.Zones = &zones
+	}
+	return policy, policyErrors
+}
+
+// policyAttributes - the attributes of a block by name, reporting unknown blocks and attributes, wrong labels,
+// strings given for lists and the other way around and missing attributes
+func policyAttributes(block *hclBlock, policyErrors *[]models.PolicyError) map[string]hclAttribute {
+	values := map[string]hclAttribute{}
+	spec, ok := policyBlocks[block.kind]
+	if !ok {
+		*policyErrors = append(*policyErrors, models.PolicyError{Line: block.line, Message: "unknown block " + block.kind})
+		return values
+	}
+	if len(block.labels) != spec.labels {
+		*policyErrors = append(*policyErrors, models.PolicyError{Line: block.line, Message: fmt.Sprintf("block %s takes %d labels", block.kind, spec.labels)})
+	}
+	for _, attribute := range block.attributes {
+		list, ok := spec.attributes[attribute.name]
+		switch {
+		case !ok:
+			*policyErrors = append(*policyErrors, models.PolicyError{Line: attribute.line, Message: fmt.Sprintf("unknown attribute %s of %s", attribute.name, block.kind)})
+			continue
+		case list && !attribute.list:
+			*policyErrors = append(*policyErrors, models.PolicyError{Line: attribute.line, Message: attribute.name + " must be a list"})
+			continue
+		case !list && attribute.list:
+			*policyErrors = append(*policyErrors, models.PolicyError{Line: attribute.line, Message: attribute.name + " must be a string"})
+			continue
+		}
+		if _, ok := values[attribute.name]; ok {
+			*policyErrors = append(*policyErrors, models.PolicyError{Line: attribute.line, Message: attribute.name + " is set twice"})
+		}
+		values[attribute.name] = attribute
+	}
+	for _, name := range spec.required {
+		if _, ok := values[name]; !ok {
+			*policyErrors = append(*policyErrors, models.PolicyError{Line: block.line, Message: fmt.Sprintf("%s is missing %s", block.kind, name)})
+		}
+	}
+	if block.kind != "network" && len(block.blocks) > 0 {
+		*policyErrors = append(*policyErrors, models.PolicyError{Line: block.blocks[0].line, Message: fmt.Sprintf("block %s can't hold blocks", block.kind)})
+	}
+	return values
+}
+
+// firstValue - the value of a string attribute, empty when it isn't set
+func (attribute hclAttribute) firstValue() string {
+	if len(attribute.values) == 0 {
+		return ""
+	}
+	return attribute.values[0]
+}
+
+// formatPolicy - writes a policy as a policy file
+func formatPolicy(policy models.NetworkPolicy) string {
+	network := &hclBlock{
+		kind:       "network",
+		labels:     []string{policy.Network},
+		attributes: []hclAttribute{{name: "default_acl", values: []string{policy.DefaultACL}}},
+		blocks:     zoneBlocks(policy.Zones),
+	}
+	for _, rule := range policy.NodeRules {
+		network.blocks = append(network.blocks, &hclBlock{kind: "node_rule", attributes: []hclAttribute{
+			{name: "nodes", list: true, values: rule.Nodes[:]},
+			{name: "action", values: []string{rule.Action}},
+		}})
+	}
+	for _, client := range policy.ExtClients {
+		network.blocks = append(network.blocks, &hclBlock{kind: "ext_client", labels: []string{client.ClientID}, attributes: []hclAttribute{
+			{name: "deny", list: true, values: client.Deny},
+		}})
+	}
+	header := "acl policy of network " + policy.Network + "\n" +
+		"nodes may reach each other when default_acl is yes, unless their zones or a node_rule say otherwise"
+	return formatHCL([]*hclBlock{network}, header)
+}
+
+// zoneBlocks - the blocks of a network's zones in a policy file
+func zoneBlocks(zones *models.NetworkZones) []*hclBlock {
+	if zones == nil {
+		return nil
+	}
+	blocks := []*hclBlock{}
+	if zones.Default != "" {
+		blocks = append(blocks, &hclBlock{kind: "zones", attributes: []hclAttribute{{name: "default", values: []string{zones.Default}}}})
+	}
+	for _, zone := range zones.Zones {
+		blocks = append(blocks, &hclBlock{kind: "zone", labels: []string{zone.Name}, attributes: []hclAttribute{
+			{name: "tags", list: true, values: zone.Tags},
+		}})
+	}
+	for _, zonePolicy := range zones.Policies {
+		block := &hclBlock{kind: "zone_policy", attributes: []hclAttribute{
+			{name: "from", values: []string{zonePolicy.From}},
+			{name: "to", values: []string{zonePolicy.To}},
+			{name: "action", values: []string{zonePolicy.Action}},
+		}}
+		if len(zonePolicy.Ports) > 0 {
+			block.attributes = append(block.attributes, hclAttribute{name: "ports", list: true, values: zonePolicy.Ports})
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// formatZones - zones as written in a policy file, to compare them
+func formatZones(zones *models.NetworkZones) string {
+	return formatHCL(zoneBlocks(zones), "")
+}
+
+func formatPolicyError(policyError models.PolicyError) string {
+	if policyError.Line == 0 {
+		return policyError.Message
+	}
+	return fmt.Sprintf("line %d: %s", policyError.Line, policyError.Message)
+}
@@ -0,0 +1,263 @@
+package logic
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// the policy files are written in a subset of HCL: blocks with quoted labels, and attributes that are
+// a string or a list of strings; comments start with # or //
+
+// hclBlock - a block of a policy file and what it contains
+type hclBlock struct {
+	kind       string
+	labels     []string
+	line       int
+	attributes []hclAttribute
+	blocks     []*hclBlock
+}
+
+// hclAttribute - an attribute of a block, a list when written in brackets
+type hclAttribute struct {
+	name   string
+	line   int
+	list   bool
+	values []string
+}
+
+// hclError - a syntax error of a policy file and its line
+type hclError struct {
+	line    int
+	message string
+}
+
+func (e *hclError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.line, e.message)
+}
+
+type hclToken struct {
+	kind  byte // i ident, s string, or the punctuation itself
+	text  string
+	line  int
+	valid bool
+}
+
+// parseHCL - the top level blocks of a policy file
+func parseHCL(text string) ([]*hclBlock, error) {
+	tokens, err := tokenizeHCL(text)
+	if err != nil {
+		return nil, err
+	}
+	parser := &hclParser{tokens: tokens}
+	root := &hclBlock{}
+	if err := parser.body(root, true); err != nil {
+		return nil, err
+	}
+	if len(root.attributes) > 0 {
+		return nil, hclErrorf(root.attributes[0].line, "attribute %s must be within a block", root.attributes[0].name)
+	}
+	return root.blocks, nil
+}
+
+// formatHCL - writes blocks as a policy file, indented by two spaces per level
+func formatHCL(blocks []*hclBlock, header string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimSpace(header), "\n") {
+		if line != "" {
+			b.WriteString("# " + line + "\n")
+		}
+	}
+	for i, block := range blocks {
+		if i > 0 || header != "" {
+			b.WriteString("\n")
+		}
+		writeHCLBlock(&b, block, 0)
+	}
+	return b.String()
+}
+
+// == private ==
+
+type hclParser struct {
+	tokens []hclToken
+	pos    int
+}
+
+func (p *hclParser) next() hclToken {
+	if p.pos >= len(p.tokens) {
+		line := 1
+		if len(p.tokens) > 0 {
+			line = p.tokens[len(p.tokens)-1].line
+		}
+		return hclToken{line: line}
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+	return token
+}
+
+func (p *hclParser) peek() hclToken {
+	token := p.next()
+	if token.valid {
+		p.pos--
+	}
+	return token
+}
+
+// body - reads the attributes and blocks of a block up to its closing brace, or to the end of the file at the top
+func (p *hclParser) body(block *hclBlock, top bool) error {
+	for {
+		token := p.next()
+		switch {
+		case !token.valid && top:
+			return nil
+		case !token.valid:
+			return hclErrorf(token.line, "block %s opened on line %d is not closed", block.kind, block.line)
+		case token.kind == '}' && !top:
+			return nil
+		case token.kind != 'i':
+			return hclErrorf(token.line, "expected an attribute or block name, found %q", token.text)
+		}
+		if p.peek().kind == '=' {
+			p.next()
+			attribute, err := p.value(token)
+			if err != nil {
+				return err
+			}
+			block.attributes = append(block.attributes, attribute)
+			continue
+		}
+		child := &hclBlock{kind: token.text, line: token.line}
+		for p.peek().kind == 's' {
+			child.labels = append(child.labels, p.next().text)
+		}
+		if open := p.next(); open.kind != '{' {
+			return hclErrorf(open.line, "expected = or { after %s", token.text)
+		}
+		if err := p.body(child, false); err != nil {
+			return err
+		}
+		block.blocks = append(block.blocks, child)
+	}
+}
+
+// value - reads the string or list of strings an attribute is set to
+func (p *hclParser) value(name hclToken) (hclAttribute, error) {
+	attribute := hclAttribute{name: name.text, line: name.line}
+	token := p.next()
+	switch token.kind {
+	case 's':
+		attribute.values = []string{token.text}
+		return attribute, nil
+	case '[':
+		attribute.list = true
+		attribute.values = []string{}
+		for {
+			item := p.next()
+			if item.kind == ']' {
+				return attribute, nil
+			}
+			if item.kind != 's' {
+				return attribute, hclErrorf(item.line, "the list of %s may only hold quoted strings", name.text)
+			}
+			attribute.values = append(attribute.values, item.text)
+			switch separator := p.next(); separator.kind {
+			case ',':
+			case ']':
+				return attribute, nil
+			default:
+				return attribute, hclErrorf(separator.line, "expected , or ] in the list of %s", name.text)
+			}
+		}
+	default:
+		return attribute, hclErrorf(token.line, "%s must be a quoted string or a list of them", name.text)
+	}
+}
+
+func tokenizeHCL(text string) ([]hclToken, error) {
+	tokens := []hclToken{}
+	line := 1
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\n':
+			line++
+		case unicode.IsSpace(r):
+		case r == '#' || (r == '/' && i+1 < len(runes) && runes[i+1] == '/'):
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			i--
+		case strings.ContainsRune("{}[]=,", r):
+			tokens = append(tokens, hclToken{kind: byte(r), text: string(r), line: line, valid: true})
+		case r == '"':
+			start := i
+			for i++; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' {
+					i++
+				}
+				if i < len(runes) && runes[i] == '\n' {
+					return nil, hclErrorf(line, "string is not closed")
+				}
+			}
+			if i >= len(runes) {
+				return nil, hclErrorf(line, "string is not closed")
+			}
+			value, err := strconv.Unquote(string(runes[start : i+1]))
+			if err != nil {
+				return nil, hclErrorf(line, "invalid string %s", string(runes[start:i+1]))
+			}
+			tokens = append(tokens, hclToken{kind: 's', text: value, line: line, valid: true})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i+1 < len(runes) && (unicode.IsLetter(runes[i+1]) || unicode.IsDigit(runes[i+1]) || runes[i+1] == '_' || runes[i+1] == '-') {
+				i++
+			}
+			tokens = append(tokens, hclToken{kind: 'i', text: string(runes[start : i+1]), line: line, valid: true})
+		default:
+			return nil, hclErrorf(line, "unexpected character %q", r)
+		}
+	}
+	return tokens, nil
+}
+
+func hclErrorf(line int, format string, args ...any) error {
+	return &hclError{line: line, message: fmt.Sprintf(format, args...)}
+}
+
+func writeHCLBlock(b *strings.Builder, block *hclBlock, depth int) {
+	indent := strings.Repeat("  ", depth)
+	b.WriteString(indent + block.kind)
+	for _, label := range block.labels {
+		b.WriteString(" " + strconv.Quote(label))
+	}
+	b.WriteString(" {\n")
+	width := 0
+	for _, attribute := range block.attributes {
+		if len(attribute.name) > width {
+			width = len(attribute.name)
+		}
+	}
+	for _, attribute := range block.attributes {
+		b.WriteString(indent + "  " + attribute.name + strings.Repeat(" ", width-len(attribute.name)) + " = ")
+		if !attribute.list {
+			b.WriteString(strconv.Quote(attribute.values[0]) + "\n")
+			continue
+		}
+		quoted := make([]string, len(attribute.values))
+		for i, value := range attribute.values {
+			quoted[i] = strconv.Quote(value)
+		}
+		b.WriteString("[" + strings.Join(quoted, ", ") + "]\n")
+	}
+	for i, child := range block.blocks {
+		if i > 0 || len(block.attributes) > 0 {
+			b.WriteString("\n")
+		}
+		writeHCLBlock(b, child, depth+1)
+	}
+	b.WriteString(indent + "}\n")
+}
@@ -0,0 +1,86 @@
+package logic
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/logic/acls"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkPolicy(t *testing.T) {
+	zones, err := GetZonePreset(models.ZonePresetStandard)
+	assert.Nil(t, err)
+	node := func(name string, tags ...string) models.Node {
+		return models.Node{CommonNode: models.CommonNode{ID: uuid.New(), HostID: uuid.New(), Network: "skynet"}, Name: name, Tags: tags}
+	}
+	web, db, laptop := node("web", "prod"), node("", "staging"), node("laptop")
+	nodes := []models.Node{web, db, laptop}
+	container := acls.ACLContainer{}
+	for _, a := range nodes {
+		container[acls.AclID(a.ID.String())] = acls.ACL{}
+		for _, b := range nodes {
+			if a.ID != b.ID {
+				container[acls.AclID(a.ID.String())][acls.AclID(b.ID.String())] = acls.Allowed
+			}
+		}
+	}
+	container.ChangeAccess(acls.AclID(web.ID.String()), acls.AclID(db.ID.String()), acls.NotAllowed)
+	container.ChangeAccess(acls.AclID(web.ID.String()), acls.AclID(laptop.ID.String()), acls.NotAllowed)
+	state := &policyState{
+		network:   models.Network{NetID: "skynet", DefaultACL: "yes", Zones: zones},
+		nodes:     nodes,
+		names:     policyNodeNames(nodes, map[string]string{db.HostID.String(): "db"}),
+		container: container,
+		clients:   []models.ExtClient{{ClientID: "phone", Network: "skynet", DeniedACLs: map[string]struct{}{web.ID.String(): {}}}},
+	}
+	isEE = true
+	defer func() { isEE = false }()
+
+	text := formatPolicy(exportPolicy(state))
+	assert.Contains(t, text, `network "skynet" {`)
+	assert.Contains(t, text, `zone "prod" {`)
+	assert.Contains(t, text, `nodes  = ["laptop", "web"]`)
+	assert.Contains(t, text, `ext_client "phone" {`)
+	assert.NotContains(t, text, `"db", "web"`, "the zones already keep web and db apart")
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		policy, policyErrors := parsePolicy(text)
+		assert.Empty(t, policyErrors)
+		assert.Empty(t, checkPolicy(&policy, state))
+		assert.Equal(t, text, formatPolicy(policy))
+		plan := models.PolicyPlan{}
+		planPolicy(&policy, state, &plan)
+		assert.Empty(t, plan.Changes)
+	})
+	t.Run("Changes", func(t *testing.T) {
+		edited := strings.Replace(text, `default_acl = "yes"`, `default_acl = "no"`, 1)
+		edited = strings.Replace(edited, `deny = ["web"]`, `deny = []`, 1)
+		policy, policyErrors := parsePolicy(edited)
+		assert.Empty(t, policyErrors)
+		assert.Empty(t, checkPolicy(&policy, state))
+		plan := models.PolicyPlan{}
+		access, clientDenies := planPolicy(&policy, state, &plan)
+		assert.Equal(t, []string{"default_acl yes -> no", "deny db <-> laptop", "ext client phone denies []"}, plan.Changes)
+		assert.Empty(t, clientDenies["phone"])
+		assert.Len(t, access, 3)
+	})
+	t.Run("Errors", func(t *testing.T) {
+		policy, policyErrors := parsePolicy("network \"skynet\" {\n  node_rule {\n    nodes = [\"web\", \"nas\"]\n    action = \"drop\"\n  }\n  firewall {}\n}\n")
+		assert.Equal(t, []models.PolicyError{
+			{Line: 4, Message: "action must be allow or deny"},
+			{Line: 6, Message: "unknown block firewall"},
+		}, policyErrors)
+		policy.NodeRules = []models.PolicyNodeRule{{Nodes: [2]string{"web", "nas"}, Action: models.ZoneDeny, Line: 2}}
+		policy.Network = "other"
+		assert.Equal(t, []models.PolicyError{
+			{Message: "policy is for network other, not skynet"},
+			{Line: 2, Message: "unknown node nas"},
+		}, checkPolicy(&policy, state))
+
+		_, policyErrors = parsePolicy("network \"skynet\" {\n  default_acl = yes\n}\n")
+		assert.Equal(t, []models.PolicyError{{Line: 2, Message: "default_acl must be a quoted string or a list of them"}}, policyErrors)
+	})
+}
@@ -0,0 +1,339 @@
+package logic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// maxProbeResults - number of recent results kept for each probe
+	maxProbeResults = 120
+	// defaultProbeInterval - seconds between runs of a probe when none is set
+	defaultProbeInterval = 30
+	// defaultProbeCount - pings sent on each run of a probe when none is set
+	defaultProbeCount = 3
+	// defaultProbeAlertWindow - number of results alert thresholds are checked against when none is set
+	defaultProbeAlertWindow = 5
+	// alertWebhookTimeout - how long posting an alert to a webhook may take
+	alertWebhookTimeout = 10 * time.Second
+)
+
+var (
+	// ErrProbeTarget - a probe needs a target node or an external target, not both
+	ErrProbeTarget = errors.New("probe needs either a target node or a target address")
+	// ErrProbeNodeNetwork - the nodes of a probe have to be in its network
+	ErrProbeNodeNetwork = errors.New("probe nodes must be in the probe's network")
+
+	probeResultsMutex sync.Mutex
+)
+
+// CreateProbe - adds a probe for its source node to run
+func CreateProbe(probe *models.Probe) error {
+	if err := validator.New().Struct(probe); err != nil {
+		return err
+	}
+	if (probe.TargetNodeID == "") == (probe.Target == "") {
+		return ErrProbeTarget
+	}
+	network, err := GetNetwork(probe.Network)
+	if err != nil {
+		return err
+	}
+	if probe.Alert != nil {
+		if err := ValidateNotificationChannels(probe.Alert.Channels, network.Tenant); err != nil {
+			return err
+		}
+	}
+	source, err := GetNodeByID(probe.SourceNodeID)
+	if err != nil {
+		return err
+	}
+	if source.Network != probe.Network {
+		return ErrProbeNodeNetwork
+	}
+	if probe.TargetNodeID != "" {
+		target, err := GetNodeByID(probe.TargetNodeID)
+		if err != nil {
+			return err
+		}
+		if target.Network != probe.Network || target.ID == source.ID {
+			return ErrProbeNodeNetwork
+		}
+	}
+	if probe.Interval == 0 {
+		probe.Interval = defaultProbeInterval
+	}
+	if probe.Count == 0 {
+		probe.Count = defaultProbeCount
+	}
+	if probe.Alert != nil && probe.Alert.Window == 0 {
+		probe.Alert.Window = defaultProbeAlertWindow
+	}
+	probe.ID = uuid.New().String()
+	data, err := json.Marshal(probe)
+	if err != nil {
+		return err
+	}
+	return database.Insert(probe.ID, string(data), database.PROBES_TABLE_NAME)
+}
+
+// GetProbes - fetches the probes of a network, or of all networks when network is empty
+func GetProbes(network string) ([]models.Probe, error) {
+	probes := []models.Probe{}
+	records, err := database.FetchRecords(database.PROBES_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return probes, nil
+		}
+		return nil, err
+	}
+	for _, value := range records {
+		var probe models.Probe
+		if err := json.Unmarshal([]byte(value), &probe); err != nil {
+			continue
+		}
+		if network == "" || probe.Network == network {
+			probes = append(probes, probe)
+		}
+	}
+	sort.Slice(probes, func(i, j int) bool {
+		return probes[i].Name < probes[j].Name
+	})
+	return probes, nil
+}
+
+// GetProbe - fetches a probe
+func GetProbe(id string) (models.Probe, error) {
+	var probe models.Probe
+	record, err := database.FetchRecord(database.PROBES_TABLE_NAME, id)
+	if err != nil {
+		return probe, err
+	}
+	err = json.Unmarshal([]byte(record), &probe)
+	return probe, err
+}
+
+// DeleteProbe - removes a probe and its results
+func DeleteProbe(id string) error {
+	if _, err := GetProbe(id); err != nil {
+		return err
+	}
+	if err := database.DeleteRecord(database.PROBE_RESULTS_TABLE_NAME, id); err != nil && !database.IsEmptyRecord(err) {
+		return err
+	}
+	return database.DeleteRecord(database.PROBES_TABLE_NAME, id)
+}
+
+// GetProbeStatus - fetches a probe with its recent results, availability and alert state
+func GetProbeStatus(id string) (models.ProbeStatus, error) {
+	probe, err := GetProbe(id)
+	if err != nil {
+		return models.ProbeStatus{}, err
+	}
+	status, err := getProbeResults(id)
+	if err != nil {
+		return models.ProbeStatus{}, err
+	}
+	status.Probe = probe
+	return status, nil
+}
+
+// GetProbesForHost - the probes the nodes of a host run, with the address of their target nodes
+func GetProbesForHost(host *models.Host) []models.Probe {
+	probes := []models.Probe{}
+	if len(host.Nodes) == 0 {
+		return probes
+	}
+	all, err := GetProbes("")
+	if err != nil {
+		return probes
+	}
+	for _, probe := range all {
+		if !StringSliceContains(host.Nodes, probe.SourceNodeID) {
+			continue
+		}
+		if probe.TargetNodeID != "" {
+			target, err := GetNodeByID(probe.TargetNodeID)
+			if err != nil {
+				continue
+			}
+			probe.Target = target.PrimaryAddress()
+		}
+		// the webhook is only needed on the server
+		probe.Alert = nil
+		probes = append(probes, probe)
+	}
+	return probes
+}
+
+// StoreProbeResults - keeps the results a node reported for its probes and raises or clears their alerts
+func StoreProbeResults(node *models.Node, results []models.ProbeResult) {
+	byProbe := make(map[string][]models.ProbeResult)
+	for _, result := range results {
+		byProbe[result.ProbeID] = append(byProbe[result.ProbeID], result)
+	}
+	probeResultsMutex.Lock()
+	defer probeResultsMutex.Unlock()
+	for id, results := range byProbe {
+		probe, err := GetProbe(id)
+		if err != nil || probe.SourceNodeID != node.ID.String() {
+			continue
+		}
+		status, err := getProbeResults(id)
+		if err != nil {
+			slog.Error("failed to fetch probe results", "probe", id, "error", err)
+			continue
+		}
+		status.Results = append(status.Results, results...)
+		sort.SliceStable(status.Results, func(i, j int) bool {
+			return status.Results[i].Time.Before(status.Results[j].Time)
+		})
+		if len(status.Results) > maxProbeResults {
+			status.Results = status.Results[len(status.Results)-maxProbeResults:]
+		}
+		status.Availability, status.AvgLatency = summarizeProbeResults(status.Results)
+		checkProbeAlert(&probe, &status)
+		status.Probe = models.Probe{}
+		data, err := json.Marshal(status)
+		if err != nil {
+			continue
+		}
+		if err := database.Insert(id, string(data), database.PROBE_RESULTS_TABLE_NAME); err != nil {
+			slog.Error("failed to store probe results", "probe", id, "error", err)
+		}
+	}
+}
+
+// == private ==
+
+func getProbeResults(id string) (models.ProbeStatus, error) {
+	status := models.ProbeStatus{Results: []models.ProbeResult{}}
+	record, err := database.FetchRecord(database.PROBE_RESULTS_TABLE_NAME, id)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return status, nil
+		}
+		return status, err
+	}
+	err = json.Unmarshal([]byte(record), &status)
+	return status, err
+}
+
+// summarizeProbeResults - the percentage of pings answered and their average latency
+func summarizeProbeResults(results []models.ProbeResult) (float64, int64) {
+	var sent, received int
+	var latency int64
+	for _, result := range results {
+		sent += result.Sent
+		received += result.Received
+		latency += result.Latency * int64(result.Received)
+	}
+	if sent == 0 {
+		return 0, 0
+	}
+	availability := 100 * float64(received) / float64(sent)
+	if received == 0 {
+		return availability, 0
+	}
+	return availability, latency / int64(received)
+}
+
+// checkProbeAlert - raises or clears the alert of a probe from its most recent results
+func checkProbeAlert(probe *models.Probe, status *models.ProbeStatus) {
+	if probe.Alert == nil || len(status.Results) == 0 {
+		return
+	}
+	window := status.Results
+	if len(window) > probe.Alert.Window {
+		window = window[len(window)-probe.Alert.Window:]
+	}
+	availability, latency := summarizeProbeResults(window)
+	reason := ""
+	switch {
+	case probe.Alert.MaxLoss > 0 && 100-availability > probe.Alert.MaxLoss:
+		reason = fmt.Sprintf("%.1f%% loss over the last %d runs", 100-availability, len(window))
+	case probe.Alert.MaxLatency > 0 && latency > int64(probe.Alert.MaxLatency):
+		reason = fmt.Sprintf("%dms average latency over the last %d runs", latency, len(window))
+	}
+	alerting := reason != ""
+	if alerting == status.Alerting {
+		status.AlertReason = reason
+		return
+	}
+	now := time.Now()
+	status.Alerting, status.AlertReason, status.AlertingSince = alerting, reason, nil
+	if alerting {
+		status.AlertingSince = &now
+		slog.Warn("probe alert raised", "probe", probe.Name, "network", probe.Network, "reason", reason)
+	} else {
+		slog.Info("probe alert cleared", "probe", probe.Name, "network", probe.Network)
+	}
+	if probe.Alert.Webhook != "" {
+		event := models.ProbeAlertEvent{
+			Probe:    *probe,
+			Alerting: alerting,
+			Reason:   reason,
+			Time:     now,
+		}
+		event.Probe.Alert = nil
+		go postAlert(probe.Alert.Webhook, "probe "+probe.Name, event)
+	}
+	notification := models.Notification{
+		Event:    models.NotificationEventProbe,
+		Severity: models.NotificationResolved,
+		Title:    "probe " + probe.Name + " alert cleared",
+		Network:  probe.Network,
+		Fields:   []models.NotificationField{{Name: "network", Value: probe.Network}, {Name: "target", Value: probe.Target}},
+		Time:     now,
+	}
+	if alerting {
+		notification.Severity = models.NotificationAlert
+		notification.Title = "probe " + probe.Name + " alert raised"
+		notification.Text = reason
+	}
+	go Notify(notification, probe.Alert.Channels)
+}
+
+// postAlert - sends an alert event to a webhook, about names what raised it for the logs
+func postAlert(url, about string, event any) {
+	if err := postJSON(url, event); err != nil {
+		slog.Error("failed to post alert", "about", about, "error", err)
+	}
+}
+
+// postJSON - posts a value as json to a webhook, failing when the webhook doesn't accept it
+func postJSON(url string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), alertWebhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook answered with status %d", res.StatusCode)
+	}
+	return nil
+}
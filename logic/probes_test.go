@@ -0,0 +1,38 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckProbeAlert(t *testing.T) {
+	probe := &models.Probe{Name: "a-to-b", Alert: &models.ProbeAlert{MaxLoss: 20, MaxLatency: 100, Window: 2}}
+	healthy := models.ProbeResult{Sent: 3, Received: 3, Latency: 20}
+	t.Run("Healthy", func(t *testing.T) {
+		status := &models.ProbeStatus{Results: []models.ProbeResult{healthy, healthy}}
+		checkProbeAlert(probe, status)
+		assert.False(t, status.Alerting)
+		assert.Nil(t, status.AlertingSince)
+	})
+	t.Run("Loss", func(t *testing.T) {
+		status := &models.ProbeStatus{Results: []models.ProbeResult{healthy, {Sent: 3, Received: 1, Latency: 20}}}
+		checkProbeAlert(probe, status)
+		assert.True(t, status.Alerting)
+		assert.NotNil(t, status.AlertingSince)
+		assert.Contains(t, status.AlertReason, "loss")
+	})
+	t.Run("WindowClears", func(t *testing.T) {
+		status := &models.ProbeStatus{Results: []models.ProbeResult{{Sent: 3, Received: 0}, healthy, healthy}, Alerting: true}
+		checkProbeAlert(probe, status)
+		assert.False(t, status.Alerting)
+		assert.Empty(t, status.AlertReason)
+	})
+	t.Run("Latency", func(t *testing.T) {
+		status := &models.ProbeStatus{Results: []models.ProbeResult{{Sent: 3, Received: 3, Latency: 250}}}
+		checkProbeAlert(probe, status)
+		assert.True(t, status.Alerting)
+		assert.Contains(t, status.AlertReason, "latency")
+	})
+}
@@ -0,0 +1,108 @@
+package logic
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+)
+
+// DefaultNodeExporterPort - the port node exporter listens on unless another is asked for
+const DefaultNodeExporterPort = 9100
+
+// GetPrometheusTargets - the node exporter endpoints of the nodes, at their mesh addresses, as prometheus http
+// service discovery target groups; only nodes of the tenant's networks when it's set, of network when it's set
+// and having every one of tags
+func GetPrometheusTargets(tenant, network string, tags []string, port int) ([]models.PrometheusTargetGroup, error) {
+	networks, err := GetNetworks()
+	if err != nil && !database.IsEmptyRecord(err) {
+		return nil, err
+	}
+	allowed := map[string]bool{}
+	for _, n := range networks {
+		if (tenant == "" || n.Tenant == tenant) && (network == "" || n.NetID == network) {
+			allowed[n.NetID] = true
+		}
+	}
+	nodes, err := GetAllNodes()
+	if err != nil {
+		return nil, err
+	}
+	hosts, err := GetAllHosts()
+	if err != nil {
+		return nil, err
+	}
+	hostMap := map[string]models.Host{}
+	for _, host := range hosts {
+		hostMap[host.ID.String()] = host
+	}
+	kept := []models.Node{}
+	for _, node := range nodes {
+		if allowed[node.Network] {
+			kept = append(kept, node)
+		}
+	}
+	return prometheusTargets(kept, hostMap, tags, port), nil
+}
+
+// == private ==
+
+// prometheusTargets - a target group per node having every one of tags, labelled with its network, host and tags
+// the way prometheus names discovered metadata; nodes without a mesh address or being deleted are left out
+func prometheusTargets(nodes []models.Node, hosts map[string]models.Host, tags []string, port int) []models.PrometheusTargetGroup {
+	groups := []models.PrometheusTargetGroup{}
+	for _, node := range nodes {
+		if node.PendingDelete || !hasAllTags(node.Tags, tags) {
+			continue
+		}
+		address := ""
+		if node.Address.IP != nil {
+			address = node.Address.IP.String()
+		} else if node.Address6.IP != nil {
+			address = node.Address6.IP.String()
+		}
+		if address == "" {
+			continue
+		}
+		host := hosts[node.HostID.String()]
+		nodeTags := append([]string{}, node.Tags...)
+		sort.Strings(nodeTags)
+		labels := map[string]string{
+			"__meta_netmaker_network":   node.Network,
+			"__meta_netmaker_node_id":   node.ID.String(),
+			"__meta_netmaker_host_id":   node.HostID.String(),
+			"__meta_netmaker_host_name": host.Name,
+			"__meta_netmaker_os":        host.OS,
+			"__meta_netmaker_connected": strconv.FormatBool(node.Connected),
+			// surrounded by separators, like the tags of prometheus' own service discoveries, so regexes can match ,tag,
+			"__meta_netmaker_tags": "," + strings.Join(nodeTags, ",") + ",",
+		}
+		if node.Name != "" {
+			labels["__meta_netmaker_node_name"] = node.Name
+		}
+		groups = append(groups, models.PrometheusTargetGroup{
+			Targets: []string{net.JoinHostPort(address, strconv.Itoa(port))},
+			Labels:  labels,
+		})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Labels["__meta_netmaker_network"] != groups[j].Labels["__meta_netmaker_network"] {
+			return groups[i].Labels["__meta_netmaker_network"] < groups[j].Labels["__meta_netmaker_network"]
+		}
+		return groups[i].Targets[0] < groups[j].Targets[0]
+	})
+	return groups
+}
+
+// hasAllTags - checks every one of wanted is among tags
+func hasAllTags(tags, wanted []string) bool {
+	for _, tag := range wanted {
+		if !StringSliceContains(tags, tag) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,35 @@
+package logic
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheusTargets(t *testing.T) {
+	host := models.Host{ID: uuid.New(), Name: "db1", OS: "linux"}
+	tagged := models.Node{CommonNode: models.CommonNode{ID: uuid.New(), HostID: host.ID, Network: "net",
+		Address: net.IPNet{IP: net.ParseIP("10.0.0.2").To4()}}, Tags: []string{"prod", "db"}}
+	untagged := models.Node{CommonNode: models.CommonNode{ID: uuid.New(), HostID: host.ID, Network: "net",
+		Address: net.IPNet{IP: net.ParseIP("10.0.0.3").To4()}}}
+	v6 := models.Node{CommonNode: models.CommonNode{ID: uuid.New(), HostID: host.ID, Network: "six",
+		Address6: net.IPNet{IP: net.ParseIP("fd00::2")}}, Tags: []string{"prod"}}
+	hosts := map[string]models.Host{host.ID.String(): host}
+
+	t.Run("AllNodes", func(t *testing.T) {
+		groups := prometheusTargets([]models.Node{v6, untagged, tagged}, hosts, nil, 9100)
+		assert.Equal(t, 3, len(groups))
+		assert.Equal(t, []string{"10.0.0.2:9100"}, groups[0].Targets)
+		assert.Equal(t, ",db,prod,", groups[0].Labels["__meta_netmaker_tags"])
+		assert.Equal(t, "db1", groups[0].Labels["__meta_netmaker_host_name"])
+		assert.Equal(t, []string{"[fd00::2]:9100"}, groups[2].Targets)
+	})
+	t.Run("FilteredByTags", func(t *testing.T) {
+		groups := prometheusTargets([]models.Node{v6, untagged, tagged}, hosts, []string{"prod", "db"}, 9200)
+		assert.Equal(t, 1, len(groups))
+		assert.Equal(t, []string{"10.0.0.2:9200"}, groups[0].Targets)
+	})
+}
@@ -0,0 +1,130 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+)
+
+const (
+	// publishRetryBase - how long a failed publish waits before its first retry, doubled on each attempt
+	publishRetryBase = 2 * time.Second
+	// maxPublishRetryWait - the longest a failed publish waits between retries
+	maxPublishRetryWait = time.Minute
+)
+
+// ErrPublishReplaced - a queued publish was replaced by a newer one for the same host
+var ErrPublishReplaced = errors.New("queued publish was replaced by a newer one")
+
+// QueuePublish - stores a host's publish until it reaches the broker, replacing any queued for the host
+func QueuePublish(publish *models.QueuedPublish) error {
+	publish.Queued = time.Now().UTC()
+	publish.NextAttempt = publish.Queued
+	publish.Attempts = 0
+	publish.LastError = ""
+	return saveQueuedPublish(publish)
+}
+
+// GetQueuedPublish - fetches the publish queued for a host
+func GetQueuedPublish(hostID string) (models.QueuedPublish, error) {
+	var publish models.QueuedPublish
+	data, err := database.FetchRecord(database.PUBLISH_QUEUE_TABLE_NAME, hostID)
+	if err != nil {
+		return publish, err
+	}
+	err = json.Unmarshal([]byte(data), &publish)
+	return publish, err
+}
+
+// GetQueuedPublishes - fetches every queued publish, oldest first
+func GetQueuedPublishes() ([]models.QueuedPublish, error) {
+	publishes := []models.QueuedPublish{}
+	records, err := database.FetchRecords(database.PUBLISH_QUEUE_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return publishes, nil
+		}
+		return publishes, err
+	}
+	for _, value := range records {
+		var publish models.QueuedPublish
+		if err := json.Unmarshal([]byte(value), &publish); err != nil {
+			continue
+		}
+		publishes = append(publishes, publish)
+	}
+	sort.Slice(publishes, func(i, j int) bool {
+		return publishes[i].Queued.Before(publishes[j].Queued)
+	})
+	return publishes, nil
+}
+
+// CompleteQueuedPublish - removes a publish that reached the broker, unless a newer one replaced it meanwhile;
+// the record is only deleted if it still holds publish, so a replacement can't be lost in between
+func CompleteQueuedPublish(publish *models.QueuedPublish) error {
+	data, err := json.Marshal(publish)
+	if err != nil {
+		return err
+	}
+	deleted, err := database.DeleteIf(database.PUBLISH_QUEUE_TABLE_NAME, publish.HostID, string(data))
+	if err != nil || deleted {
+		return err
+	}
+	if _, err := GetQueuedPublish(publish.HostID); err != nil {
+		if database.IsEmptyRecord(err) {
+			return nil
+		}
+		return err
+	}
+	return ErrPublishReplaced
+}
+
+// RetryQueuedPublish - records a failed attempt and backs off the next one,
+// unless a newer publish replaced it meanwhile
+func RetryQueuedPublish(publish *models.QueuedPublish, publishErr error) error {
+	current, err := GetQueuedPublish(publish.HostID)
+	if err != nil {
+		return err
+	}
+	if !current.Queued.Equal(publish.Queued) {
+		return ErrPublishReplaced
+	}
+	publish.Attempts++
+	publish.LastError = publishErr.Error()
+	publish.NextAttempt = time.Now().UTC().Add(publishRetryWait(publish.Attempts))
+	return saveQueuedPublish(publish)
+}
+
+// DeleteQueuedPublish - drops the publish queued for a host, such as a deleted one
+func DeleteQueuedPublish(hostID string) error {
+	if err := database.DeleteRecord(database.PUBLISH_QUEUE_TABLE_NAME, hostID); err != nil && !database.IsEmptyRecord(err) {
+		return err
+	}
+	return nil
+}
+
+// == private ==
+
+func saveQueuedPublish(publish *models.QueuedPublish) error {
+	data, err := json.Marshal(publish)
+	if err != nil {
+		return err
+	}
+	return database.Insert(publish.HostID, string(data), database.PUBLISH_QUEUE_TABLE_NAME)
+}
+
+// publishRetryWait - exponential backoff after a number of failed attempts
+func publishRetryWait(attempts int) time.Duration {
+	wait := publishRetryBase
+	for i := 1; i < attempts && wait < maxPublishRetryWait; i++ {
+		wait *= 2
+	}
+	if wait > maxPublishRetryWait {
+		wait = maxPublishRetryWait
+	}
+	return wait
+}
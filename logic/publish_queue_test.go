@@ -0,0 +1,60 @@
+package logic
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueuedPublish(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	first := models.QueuedPublish{Topic: "peers/host/test/server", HostID: "test", Payload: []byte("first")}
+	assert.Nil(t, QueuePublish(&first))
+	defer DeleteQueuedPublish("test")
+	t.Run("Retry", func(t *testing.T) {
+		failed := first
+		assert.Nil(t, RetryQueuedPublish(&failed, errors.New("broker not connected")))
+		queued, err := GetQueuedPublish("test")
+		assert.Nil(t, err)
+		assert.Equal(t, 1, queued.Attempts)
+		assert.Equal(t, "broker not connected", queued.LastError)
+		assert.True(t, queued.NextAttempt.After(queued.Queued))
+	})
+	t.Run("Replaced", func(t *testing.T) {
+		time.Sleep(time.Millisecond)
+		second := models.QueuedPublish{Topic: first.Topic, HostID: "test", Payload: []byte("second")}
+		assert.Nil(t, QueuePublish(&second))
+		assert.ErrorIs(t, CompleteQueuedPublish(&first), ErrPublishReplaced)
+		queued, err := GetQueuedPublish("test")
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("second"), queued.Payload)
+		assert.Equal(t, 0, queued.Attempts)
+		assert.Nil(t, CompleteQueuedPublish(&second))
+		_, err = GetQueuedPublish("test")
+		assert.True(t, database.IsEmptyRecord(err))
+		// already removed
+		assert.Nil(t, CompleteQueuedPublish(&second))
+	})
+	t.Run("RetriedThenSent", func(t *testing.T) {
+		third := models.QueuedPublish{Topic: first.Topic, HostID: "test", Payload: []byte("third")}
+		assert.Nil(t, QueuePublish(&third))
+		assert.Nil(t, RetryQueuedPublish(&third, errors.New("timeout")))
+		// the worker sends what it read back from the queue
+		queued, err := GetQueuedPublish("test")
+		assert.Nil(t, err)
+		assert.Nil(t, CompleteQueuedPublish(&queued))
+		_, err = GetQueuedPublish("test")
+		assert.True(t, database.IsEmptyRecord(err))
+	})
+}
+
+func TestPublishRetryWait(t *testing.T) {
+	assert.Equal(t, 2*time.Second, publishRetryWait(1))
+	assert.Equal(t, 8*time.Second, publishRetryWait(3))
+	assert.Equal(t, time.Minute, publishRetryWait(10))
+}
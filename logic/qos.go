@@ -0,0 +1,80 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gravitl/netmaker/models"
+)
+
+// ErrInvalidQoSPolicy - the qos policy of a network is malformed
+var ErrInvalidQoSPolicy = errors.New("invalid qos policy")
+
+// SetNetworkQoS - sets how the wireguard traffic of a network is marked, nil removes the policy
+func SetNetworkQoS(netID string, policy *models.QoSPolicy) (models.Network, error) {
+	if policy != nil {
+		if err := validateQoSPolicy(policy); err != nil {
+			return models.Network{}, err
+		}
+	}
+	network, err := GetNetwork(netID)
+	if err != nil {
+		return models.Network{}, err
+	}
+	current := network
+	network.QoS = policy
+	if _, _, _, _, _, err = UpdateNetwork(&current, &network); err != nil {
+		return models.Network{}, err
+	}
+	return network, nil
+}
+
+// GetHostQoS - the qos policies of the networks a host has nodes in
+func GetHostQoS(host *models.Host) []models.NetworkQoS {
+	policies := []models.NetworkQoS{}
+	for _, nodeID := range host.Nodes {
+		node, err := GetNodeByID(nodeID)
+		if err != nil {
+			continue
+		}
+		network, err := GetNetwork(node.Network)
+		if err != nil || network.QoS == nil {
+			continue
+		}
+		policies = append(policies, models.NetworkQoS{Network: network.NetID, QoSPolicy: *network.QoS})
+	}
+	return policies
+}
+
+// == private ==
+
+func validateQoSPolicy(policy *models.QoSPolicy) error {
+	if err := validator.New().Struct(policy); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidQoSPolicy, err.Error())
+	}
+	for _, class := range policy.Classes {
+		for _, ports := range class.Ports {
+			if !validPortRange(ports) {
+				return fmt.Errorf("%w: class %s has invalid ports %q", ErrInvalidQoSPolicy, class.Name, ports)
+			}
+		}
+	}
+	return nil
+}
+
+// validPortRange - checks a port, or a range of ports such as 10000-20000
+func validPortRange(ports string) bool {
+	first, last, isRange := strings.Cut(ports, "-")
+	low, err := strconv.Atoi(first)
+	if err != nil || low < 1 || low > 65535 {
+		return false
+	}
+	if !isRange {
+		return true
+	}
+	high, err := strconv.Atoi(last)
+	return err == nil && high >= low && high <= 65535
+}
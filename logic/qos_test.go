@@ -0,0 +1,27 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateQoSPolicy(t *testing.T) {
+	voip := models.QoSClass{Name: "voip", Protocol: "udp", Ports: []string{"5060", "10000-20000"}, DSCP: 46}
+	assert.Nil(t, validateQoSPolicy(&models.QoSPolicy{Mode: "mark", DSCP: 10, Classes: []models.QoSClass{voip}}))
+	assert.Nil(t, validateQoSPolicy(&models.QoSPolicy{Mode: "inherit"}))
+	t.Run("InvalidMode", func(t *testing.T) {
+		assert.ErrorIs(t, validateQoSPolicy(&models.QoSPolicy{Mode: "copy"}), ErrInvalidQoSPolicy)
+	})
+	t.Run("InvalidDSCP", func(t *testing.T) {
+		assert.ErrorIs(t, validateQoSPolicy(&models.QoSPolicy{Mode: "mark", DSCP: 64}), ErrInvalidQoSPolicy)
+	})
+	t.Run("InvalidPorts", func(t *testing.T) {
+		for _, ports := range []string{"0", "20000-10000", "5060-", "sip", "70000"} {
+			class := voip
+			class.Ports = []string{ports}
+			assert.ErrorIs(t, validateQoSPolicy(&models.QoSPolicy{Mode: "mark", Classes: []models.QoSClass{class}}), ErrInvalidQoSPolicy, ports)
+		}
+	})
+}
@@ -0,0 +1,248 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+var (
+	// ErrHostQuarantined - a host is quarantined, or a host registering has the key or machine of one
+	ErrHostQuarantined = errors.New("host is quarantined")
+	// ErrHostNotQuarantined - a host that isn't quarantined is being released
+	ErrHostNotQuarantined = errors.New("host is not quarantined")
+)
+
+// DeleteHostBrokerCredentials - removes the broker user of a host, set by mq when the broker keeps one per host
+var DeleteHostBrokerCredentials = func(hostID string) error {
+	return nil
+}
+
+// QuarantineHost - cuts a compromised host off: disconnects its nodes so no peer keeps it, revokes its key,
+// certificates, tokens and broker credentials and refuses its id, key and machine on registration and
+// authentication; raises a security alert. Peers have to be sent the update.
+func QuarantineHost(hostID, user string, request *models.HostQuarantineRequest) (models.HostQuarantine, error) {
+	var quarantine models.HostQuarantine
+	if err := validator.New().Struct(request); err != nil {
+		return quarantine, err
+	}
+	host, err := GetHost(hostID)
+	if err != nil {
+		return quarantine, err
+	}
+	if IsHostQuarantined(hostID) {
+		return quarantine, ErrHostQuarantined
+	}
+	quarantine = models.HostQuarantine{
+		HostID:            hostID,
+		HostName:          host.Name,
+		Time:              time.Now().UTC(),
+		User:              user,
+		Reason:            request.Reason,
+		MacAddress:        machineAddress(host),
+		DisconnectedNodes: []string{},
+	}
+	if host.PublicKey != (wgtypes.Key{}) {
+		quarantine.PublicKey = host.PublicKey.String()
+	}
+	// stored first so the host is refused while its nodes are disconnected
+	if err := saveHostQuarantine(&quarantine); err != nil {
+		return quarantine, err
+	}
+	network := ""
+	for _, node := range GetHostNodes(host) {
+		network = node.Network
+		if !node.Connected {
+			continue
+		}
+		node.Connected = false
+		if err := UpsertNode(&node); err != nil {
+			slog.Error("failed to disconnect node of quarantined host", "host", hostID, "node", node.ID, "error", err)
+			continue
+		}
+		quarantine.DisconnectedNodes = append(quarantine.DisconnectedNodes, node.ID.String())
+	}
+	if err := saveHostQuarantine(&quarantine); err != nil {
+		return quarantine, err
+	}
+	revokeKey(models.RevokedKey{
+		PublicKey: quarantine.PublicKey,
+		Kind:      models.RevokedHostKey,
+		ID:        hostID,
+		Reason:    "quarantined",
+	})
+	if err := RevokeHostCertificates(hostID, "quarantined"); err != nil {
+		slog.Error("failed to revoke certificates of quarantined host", "host", hostID, "error", err)
+	}
+	// tokens issued so far stay refused once the host is released
+	host.TokensNotBefore = quarantine.Time
+	if err := UpsertHost(host); err != nil {
+		slog.Error("failed to revoke tokens of quarantined host", "host", hostID, "error", err)
+	}
+	if err := DeleteHostBrokerCredentials(hostID); err != nil {
+		slog.Error("failed to remove broker credentials of quarantined host", "host", hostID, "error", err)
+	}
+	message := fmt.Sprintf("host %s was quarantined by %s", host.Name, user)
+	if quarantine.Reason != "" {
+		message += ": " + quarantine.Reason
+	}
+	RaiseSecurityEvent(models.SecurityEvent{
+		Kind:    models.SecurityEventHostQuarantined,
+		Network: network,
+		Message: message,
+	})
+	return quarantine, nil
+}
+
+// ReleaseHostQuarantine - lets a host back in, connecting the nodes the quarantine disconnected.
+// Peers have to be sent the update; the host has to register again for new certificates and broker credentials.
+func ReleaseHostQuarantine(hostID, user string) (models.HostQuarantine, error) {
+	quarantine, err := GetHostQuarantine(hostID)
+	if err != nil {
+		return quarantine, err
+	}
+	for _, nodeID := range quarantine.DisconnectedNodes {
+		node, err := GetNodeByID(nodeID)
+		if err != nil || node.Connected {
+			continue
+		}
+		node.Connected = true
+		if err := UpsertNode(&node); err != nil {
+			slog.Error("failed to connect node of released host", "host", hostID, "node", nodeID, "error", err)
+		}
+	}
+	if quarantine.PublicKey != "" {
+		if err := database.DeleteRecord(database.REVOKED_KEYS_TABLE_NAME, quarantine.PublicKey); err != nil && !database.IsEmptyRecord(err) {
+			slog.Warn("failed to forget revoked key of released host", "host", hostID, "error", err)
+		}
+	}
+	if err := database.DeleteRecord(database.HOST_QUARANTINES_TABLE_NAME, hostID); err != nil {
+		return quarantine, err
+	}
+	slog.Warn("host released from quarantine", "host", hostID, "name", quarantine.HostName, "user", user)
+	return quarantine, nil
+}
+
+// GetHostQuarantine - the quarantine of a host, ErrHostNotQuarantined when it isn't quarantined
+func GetHostQuarantine(hostID string) (models.HostQuarantine, error) {
+	var quarantine models.HostQuarantine
+	record, err := database.FetchRecord(database.HOST_QUARANTINES_TABLE_NAME, hostID)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return quarantine, ErrHostNotQuarantined
+		}
+		return quarantine, err
+	}
+	err = json.Unmarshal([]byte(record), &quarantine)
+	return quarantine, err
+}
+
+// GetHostQuarantines - the quarantined hosts, the latest first
+func GetHostQuarantines() ([]models.HostQuarantine, error) {
+	quarantines := []models.HostQuarantine{}
+	records, err := database.FetchRecords(database.HOST_QUARANTINES_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return quarantines, nil
+		}
+		return nil, err
+	}
+	for _, value := range records {
+		var quarantine models.HostQuarantine
+		if err := json.Unmarshal([]byte(value), &quarantine); err != nil {
+			continue
+		}
+		quarantines = append(quarantines, quarantine)
+	}
+	sort.Slice(quarantines, func(i, j int) bool {
+		return quarantines[i].Time.After(quarantines[j].Time)
+	})
+	return quarantines, nil
+}
+
+// IsHostQuarantined - checks if a host is quarantined
+func IsHostQuarantined(hostID string) bool {
+	_, err := GetHostQuarantine(hostID)
+	return err == nil
+}
+
+// CheckHostNotQuarantined - refuses a host that is quarantined, and any host while the quarantines can't be read
+func CheckHostNotQuarantined(hostID string) error {
+	_, err := GetHostQuarantine(hostID)
+	if err == nil {
+		return ErrHostQuarantined
+	}
+	if errors.Is(err, ErrHostNotQuarantined) {
+		return nil
+	}
+	return err
+}
+
+// CheckHostQuarantine - refuses a host registering or authenticating that is quarantined or has the key or machine
+// of a quarantined host, raising a security alert; hosts are refused while the quarantines can't be read
+func CheckHostQuarantine(host *models.Host, r *http.Request) error {
+	quarantines, err := GetHostQuarantines()
+	if err != nil {
+		slog.Error("failed to check host quarantines", "host", host.ID, "error", err)
+		return err
+	}
+	for i := range quarantines {
+		match := quarantineMatch(&quarantines[i], host)
+		if match == "" {
+			continue
+		}
+		event := models.SecurityEvent{
+			Kind:    models.SecurityEventQuarantinedHostRegistration,
+			Message: fmt.Sprintf("host %s with the %s of quarantined host %s was refused", host.Name, match, quarantines[i].HostName),
+		}
+		if source := APISourceIP(r); source != nil {
+			event.SourceIP = source.String()
+			event.Message += " from " + event.SourceIP
+		}
+		RaiseSecurityEvent(event)
+		return ErrHostQuarantined
+	}
+	return nil
+}
+
+// == private ==
+
+func saveHostQuarantine(quarantine *models.HostQuarantine) error {
+	data, err := json.Marshal(quarantine)
+	if err != nil {
+		return err
+	}
+	return database.Insert(quarantine.HostID, string(data), database.HOST_QUARANTINES_TABLE_NAME)
+}
+
+// quarantineMatch - what a host shares with a quarantined one: its id, key or machine, empty when nothing
+func quarantineMatch(quarantine *models.HostQuarantine, host *models.Host) string {
+	switch {
+	case host.ID.String() == quarantine.HostID:
+		return "id"
+	case quarantine.PublicKey != "" && host.PublicKey != (wgtypes.Key{}) && host.PublicKey.String() == quarantine.PublicKey:
+		return "key"
+	case quarantine.MacAddress != "" && machineAddress(host) == quarantine.MacAddress:
+		return "machine"
+	}
+	return ""
+}
+
+// machineAddress - the mac address identifying the machine of a host, empty when it has none
+func machineAddress(host *models.Host) string {
+	for _, b := range host.MacAddress {
+		if b != 0 {
+			return host.MacAddress.String()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,125 @@
+package logic
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestQuarantineMatch(t *testing.T) {
+	private, err := wgtypes.GeneratePrivateKey()
+	assert.Nil(t, err)
+	mac, err := net.ParseMAC("02:42:ac:11:00:02")
+	assert.Nil(t, err)
+	quarantined := models.Host{ID: uuid.New(), Name: "compromised", PublicKey: private.PublicKey(), MacAddress: mac}
+	quarantine := models.HostQuarantine{
+		HostID:     quarantined.ID.String(),
+		HostName:   quarantined.Name,
+		PublicKey:  quarantined.PublicKey.String(),
+		MacAddress: machineAddress(&quarantined),
+	}
+	t.Run("SameHost", func(t *testing.T) {
+		assert.Equal(t, "id", quarantineMatch(&quarantine, &quarantined))
+	})
+	t.Run("SameKey", func(t *testing.T) {
+		host := models.Host{ID: uuid.New(), PublicKey: quarantined.PublicKey}
+		assert.Equal(t, "key", quarantineMatch(&quarantine, &host))
+	})
+	t.Run("SameMachine", func(t *testing.T) {
+		host := models.Host{ID: uuid.New(), MacAddress: mac}
+		assert.Equal(t, "machine", quarantineMatch(&quarantine, &host))
+	})
+	t.Run("OtherHost", func(t *testing.T) {
+		other, err := wgtypes.GeneratePrivateKey()
+		assert.Nil(t, err)
+		otherMac, err := net.ParseMAC("02:42:ac:11:00:03")
+		assert.Nil(t, err)
+		host := models.Host{ID: uuid.New(), PublicKey: other.PublicKey(), MacAddress: otherMac}
+		assert.Equal(t, "", quarantineMatch(&quarantine, &host))
+	})
+	t.Run("NoMachine", func(t *testing.T) {
+		quarantine := models.HostQuarantine{HostID: uuid.New().String()}
+		host := models.Host{ID: uuid.New(), MacAddress: net.HardwareAddr{0, 0, 0, 0, 0, 0}}
+		assert.Equal(t, "", machineAddress(&host))
+		assert.Equal(t, "", quarantineMatch(&quarantine, &host))
+	})
+}
+
+func TestQuarantineHost(t *testing.T) {
+	database.InitializeDatabase()
+	t.Cleanup(database.CloseDB)
+	database.DeleteAllRecords(database.PKI_CERTS_TABLE_NAME)
+	t.Cleanup(func() {
+		database.DeleteAllRecords(database.PKI_CERTS_TABLE_NAME)
+		database.DeleteAllRecords(database.SECURITY_EVENTS_TABLE_NAME)
+	})
+	host := models.Host{ID: uuid.New(), Name: "quarantinedhost"}
+	assert.Nil(t, UpsertHost(&host))
+	t.Cleanup(func() { RemoveHostByID(host.ID.String()) })
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{}, key)
+	assert.Nil(t, err)
+	issued, err := IssueHostCertificate(&host, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})))
+	assert.Nil(t, err)
+	token, err := CreateJWT(host.ID.String(), "", "")
+	assert.Nil(t, err)
+	_, _, _, err = VerifyHostToken(token)
+	assert.Nil(t, err)
+	defer func(remove func(string) error) { DeleteHostBrokerCredentials = remove }(DeleteHostBrokerCredentials)
+	removed := []string{}
+	DeleteHostBrokerCredentials = func(hostID string) error {
+		removed = append(removed, hostID)
+		return nil
+	}
+
+	_, err = QuarantineHost(host.ID.String(), "admin", &models.HostQuarantineRequest{Reason: "stolen"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{host.ID.String()}, removed)
+	record, err := GetCertificate(issued.Serial)
+	assert.Nil(t, err)
+	assert.True(t, record.Revoked)
+	assert.Equal(t, "quarantined", record.RevocationReason)
+	_, _, _, err = VerifyHostToken(token)
+	assert.ErrorIs(t, err, ErrHostTokenRevoked)
+
+	t.Run("TokenAfterRevocation", func(t *testing.T) {
+		revoked, err := GetHost(host.ID.String())
+		assert.Nil(t, err)
+		notBefore := revoked.TokensNotBefore
+		revoked.TokensNotBefore = time.Now().Add(-time.Hour)
+		assert.Nil(t, UpsertHost(revoked))
+		defer func() {
+			revoked.TokensNotBefore = notBefore
+			UpsertHost(revoked)
+		}()
+		token, err := CreateJWT(host.ID.String(), "", "")
+		assert.Nil(t, err)
+		_, _, _, err = VerifyHostToken(token)
+		assert.ErrorIs(t, err, ErrHostQuarantined)
+	})
+	t.Run("Unreadable", func(t *testing.T) {
+		database.CloseDB()
+		defer database.InitializeDatabase()
+		err := CheckHostQuarantine(&models.Host{ID: uuid.New()}, httptest.NewRequest(http.MethodPost, "/api/v1/host/register", nil))
+		assert.NotNil(t, err, "hosts are refused while the quarantines can't be read")
+	})
+
+	_, err = ReleaseHostQuarantine(host.ID.String(), "admin")
+	assert.Nil(t, err)
+	assert.Nil(t, CheckHostNotQuarantined(host.ID.String()))
+	_, _, _, err = VerifyHostToken(token)
+	assert.ErrorIs(t, err, ErrHostTokenRevoked, "tokens issued before the quarantine stay refused")
+}
@@ -0,0 +1,65 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoverSuperAdmin(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	defer DeleteUser("recovered")
+	t.Run("Disabled", func(t *testing.T) {
+		t.Setenv("RECOVERY_ACCESS", "")
+		_, err := GenerateRecoveryToken()
+		assert.ErrorIs(t, err, ErrRecoveryDisabled)
+		_, err = RecoverSuperAdmin(&models.RecoveryRequest{Token: "any", UserName: "recovered", Password: "password"})
+		assert.ErrorIs(t, err, ErrRecoveryDisabled)
+	})
+	t.Setenv("RECOVERY_ACCESS", "on")
+	token, err := GenerateRecoveryToken()
+	assert.Nil(t, err)
+	t.Run("WrongToken", func(t *testing.T) {
+		_, err := RecoverSuperAdmin(&models.RecoveryRequest{Token: "wrong", UserName: "recovered", Password: "password"})
+		assert.ErrorIs(t, err, ErrInvalidRecoveryToken)
+	})
+	t.Run("Created", func(t *testing.T) {
+		user, err := RecoverSuperAdmin(&models.RecoveryRequest{Token: token, UserName: "recovered", Password: "password"})
+		assert.Nil(t, err)
+		assert.True(t, IsSuperAdmin(user))
+	})
+	t.Run("Reused", func(t *testing.T) {
+		_, err := RecoverSuperAdmin(&models.RecoveryRequest{Token: token, UserName: "recovered", Password: "password"})
+		assert.ErrorIs(t, err, ErrInvalidRecoveryToken)
+	})
+	t.Run("Reset", func(t *testing.T) {
+		token, err := GenerateRecoveryToken()
+		assert.Nil(t, err)
+		user, err := RecoverSuperAdmin(&models.RecoveryRequest{Token: token, UserName: "recovered", Password: "newpassword"})
+		assert.Nil(t, err)
+		_, err = VerifyAuthRequest(models.UserAuthParams{UserName: user.UserName, Password: "newpassword"})
+		assert.Nil(t, err)
+	})
+	t.Run("UsedConcurrently", func(t *testing.T) {
+		token, err := GenerateRecoveryToken()
+		assert.Nil(t, err)
+		results := make(chan error, 5)
+		for i := 0; i < 5; i++ {
+			go func() {
+				results <- useRecoveryToken(token)
+			}()
+		}
+		used := 0
+		for i := 0; i < 5; i++ {
+			if err := <-results; err == nil {
+				used++
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidRecoveryToken)
+			}
+		}
+		assert.Equal(t, 1, used)
+	})
+}
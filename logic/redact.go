@@ -0,0 +1,172 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gravitl/netmaker/logger"
+	"golang.org/x/exp/slog"
+)
+
+// model fields are tagged redact:"true" when they hold a secret that never leaves the server,
+// or redact:"allow" when a response hands out the secret on purpose, such as a generated private key
+const (
+	redactTag   = "redact"
+	redactTrue  = "true"
+	redactAllow = "allow"
+)
+
+// ErrUntaggedSecret - a model has fields that look like they hold a secret but aren't tagged to be redacted or allowed
+var ErrUntaggedSecret = errors.New("model has secret fields that aren't tagged for redaction")
+
+// checkedSecretTypes - the types whose fields were already checked for untagged secrets, with the outcome
+var checkedSecretTypes sync.Map
+
+// RedactSecrets - a deep copy of a model, or slices and maps of them, with every field tagged redact:"true" zeroed,
+// for api responses, audit records and logs; the value passed in is left untouched.
+// A model with untagged secret-looking fields is refused, as it can't be told what is safe to send.
+func RedactSecrets(v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	value := reflect.ValueOf(v)
+	if err := checkSecretFields(value.Type()); err != nil {
+		return nil, err
+	}
+	return redactValue(value).Interface(), nil
+}
+
+// SecretFieldViolations - the fields of a model, and the models within it, whose names look like they hold
+// a secret but that aren't tagged to be redacted or allowed
+func SecretFieldViolations(v any) []string {
+	if v == nil {
+		return nil
+	}
+	return secretFieldViolations(reflect.TypeOf(v), map[reflect.Type]bool{})
+}
+
+// RedactPath - a request path with the tokens some routes take in the path replaced, for audit records
+func RedactPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i := 0; i < len(segments)-1; i++ {
+		if segments[i] == "register" && segments[i+1] != "" {
+			segments[i+1] = logger.Redacted
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// == private ==
+
+// checkSecretFields - the runtime guard on marshaling models, fails on untagged secret-looking fields of a type,
+// which are reported the first time the type is checked
+func checkSecretFields(t reflect.Type) error {
+	if checked, ok := checkedSecretTypes.Load(t); ok {
+		if err, failed := checked.(error); failed {
+			return err
+		}
+		return nil
+	}
+	violations := secretFieldViolations(t, map[reflect.Type]bool{})
+	if len(violations) == 0 {
+		checkedSecretTypes.Store(t, true)
+		return nil
+	}
+	for _, field := range violations {
+		slog.Error("model field looks like a secret but is not tagged for redaction", "field", field)
+	}
+	err := fmt.Errorf("%w: %s", ErrUntaggedSecret, strings.Join(violations, ", "))
+	checkedSecretTypes.Store(t, err)
+	return err
+}
+
+func secretFieldViolations(t reflect.Type, seen map[reflect.Type]bool) []string {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return nil
+	}
+	seen[t] = true
+	violations := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get(redactTag)
+		if tag == redactTrue || tag == redactAllow {
+			continue
+		}
+		if field.Type.Kind() == reflect.String && logger.IsSecretName(field.Name) {
+			violations = append(violations, t.String()+"."+field.Name)
+			continue
+		}
+		violations = append(violations, secretFieldViolations(field.Type, seen)...)
+	}
+	return violations
+}
+
+// redactValue - copies a value, zeroing the fields tagged redact:"true" of the structs within it
+func redactValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.New(v.Type().Elem())
+		copied.Elem().Set(redactValue(v.Elem()))
+		return copied
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(redactValue(v.Elem()))
+		return copied
+	case reflect.Struct:
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if field.Tag.Get(redactTag) == redactTrue {
+				copied.Field(i).Set(reflect.Zero(field.Type))
+				continue
+			}
+			copied.Field(i).Set(redactValue(v.Field(i)))
+		}
+		return copied
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(redactValue(v.Index(i)))
+		}
+		return copied
+	case reflect.Array:
+		copied := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(redactValue(v.Index(i)))
+		}
+		return copied
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			copied.SetMapIndex(iter.Key(), redactValue(iter.Value()))
+		}
+		return copied
+	}
+	return v
+}
@@ -0,0 +1,99 @@
+package logic
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactSecrets(t *testing.T) {
+	t.Run("ExtClients", func(t *testing.T) {
+		clients := []models.ExtClient{{ClientID: "laptop", PrivateKey: "wgprivatekey", PublicKey: "wgpublickey"}}
+		data, err := json.Marshal(redacted(t, clients))
+		assert.Nil(t, err)
+		assert.NotContains(t, string(data), "wgprivatekey")
+		assert.Contains(t, string(data), "wgpublickey")
+		// the original is left untouched
+		assert.Equal(t, "wgprivatekey", clients[0].PrivateKey)
+	})
+	t.Run("NestedPointers", func(t *testing.T) {
+		provider := models.ExternalDNSProvider{Credentials: &models.ExternalDNSCredentials{AccessKeyID: "AKIA", SecretAccessKey: "awssecret"}}
+		redacted := redacted(t, &provider).(*models.ExternalDNSProvider)
+		assert.Equal(t, "AKIA", redacted.Credentials.AccessKeyID)
+		assert.Empty(t, redacted.Credentials.SecretAccessKey)
+		assert.Equal(t, "awssecret", provider.Credentials.SecretAccessKey)
+	})
+	t.Run("AllowedSecrets", func(t *testing.T) {
+		issued := models.IssuedCertificate{PrivateKey: "generatedkey"}
+		assert.Equal(t, "generatedkey", redacted(t, issued).(models.IssuedCertificate).PrivateKey)
+	})
+	t.Run("Hosts", func(t *testing.T) {
+		host := models.Host{ID: uuid.New(), HostPass: "hostpassword"}
+		assert.Empty(t, redacted(t, map[string]models.Host{"host": host}).(map[string]models.Host)["host"].HostPass)
+	})
+	t.Run("UntaggedSecret", func(t *testing.T) {
+		type leaky struct {
+			APIToken string
+		}
+		_, err := RedactSecrets([]leaky{{APIToken: "token"}})
+		assert.ErrorIs(t, err, ErrUntaggedSecret)
+		// the outcome is kept, a type is refused every time
+		_, err = RedactSecrets(leaky{})
+		assert.ErrorIs(t, err, ErrUntaggedSecret)
+	})
+}
+
+func TestReturnJSONResponse(t *testing.T) {
+	t.Run("Redacted", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		ReturnJSONResponse(rec, httptest.NewRequest(http.MethodGet, "/api/extclients", nil), []models.ExtClient{{ClientID: "laptop", PrivateKey: "wgprivatekey"}})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.Contains(t, rec.Body.String(), "laptop")
+		assert.NotContains(t, rec.Body.String(), "wgprivatekey")
+	})
+	t.Run("UntaggedSecret", func(t *testing.T) {
+		type leaky struct {
+			Password string
+		}
+		rec := httptest.NewRecorder()
+		ReturnJSONResponse(rec, httptest.NewRequest(http.MethodGet, "/api/leaky", nil), &leaky{Password: "hunter2"})
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "hunter2")
+	})
+}
+
+func TestSecretFieldsAreTagged(t *testing.T) {
+	responses := []any{
+		models.ExtClient{}, models.Host{}, models.ApiHost{}, models.User{}, models.ReturnUser{},
+		models.EnrollmentKey{}, models.RegisterResponse{}, models.ServerSettings{}, models.ServerConfig{},
+		models.ExternalDNSProvider{}, models.CloudRoutes{}, models.IssuedCertificate{}, models.PKICertificate{},
+		models.ExtClientCredentials{}, models.RACDeviceToken{}, models.SuccessfulUserLoginResponse{},
+		models.SuccessfulLoginResponse{}, models.RecoveryRequest{}, models.MigrationData{}, models.AuditEntry{},
+	}
+	for _, response := range responses {
+		assert.Empty(t, SecretFieldViolations(response))
+	}
+	type leaky struct {
+		APIToken string
+	}
+	assert.Equal(t, []string{"logic.leaky.APIToken"}, SecretFieldViolations([]*leaky{}))
+}
+
+func TestRedactPath(t *testing.T) {
+	assert.Equal(t, "/api/v1/host/register/[redacted]", RedactPath("/api/v1/host/register/enrollmenttoken"))
+	assert.Equal(t, "/api/v1/sidecar/register/[redacted]/client", RedactPath("/api/v1/sidecar/register/token/client"))
+	assert.Equal(t, "/api/nodes/net", RedactPath("/api/nodes/net"))
+}
+
+// redacted - RedactSecrets of a model that is expected to be tagged
+func redacted(t *testing.T, v any) any {
+	redacted, err := RedactSecrets(v)
+	assert.Nil(t, err)
+	return redacted
+}
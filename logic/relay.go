@@ -5,14 +5,19 @@ import (
 	"fmt"
 	"net"
 
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
 )
 
 // CreateRelay - creates a relay
 func CreateRelay(relay models.RelayRequest) ([]models.Node, models.Node, error) {
 	var returnnodes []models.Node
 
+	unlock, err := Lock(GatewayLockName(relay.NetID))
+	if err != nil {
+		return returnnodes, models.Node{}, err
+	}
+	defer unlock()
 	node, err := GetNodeByID(relay.NodeID)
 	if err != nil {
 		return returnnodes, models.Node{}, err
@@ -24,6 +29,9 @@ func CreateRelay(relay models.RelayRequest) ([]models.Node, models.Node, error)
 	if host.OS != "linux" {
 		return returnnodes, models.Node{}, fmt.Errorf("only linux machines can be relay nodes")
 	}
+	if node.Ephemeral {
+		return returnnodes, models.Node{}, ErrEphemeralNode
+	}
 	err = ValidateRelay(relay)
 	if err != nil {
 		return returnnodes, models.Node{}, err
@@ -35,6 +43,7 @@ func CreateRelay(relay models.RelayRequest) ([]models.Node, models.Node, error)
 	if err != nil {
 		return returnnodes, node, err
 	}
+	RecordNetworkEvent(node.Network, models.NetworkEventGateway, &node, fmt.Sprintf("relay created for %d nodes", len(relay.RelayedNodes)))
 	returnnodes = SetRelayedNodes(true, relay.NodeID, relay.RelayedNodes)
 	return returnnodes, node, nil
 }
@@ -45,7 +54,7 @@ func SetRelayedNodes(setRelayed bool, relay string, relayed []string) []models.N
 	for _, id := range relayed {
 		node, err := GetNodeByID(id)
 		if err != nil {
-			logger.Log(0, "setRelayedNodes.GetNodebyID", err.Error())
+			slog.Error("setRelayedNodes.GetNodebyID", "error", err)
 			continue
 		}
 		node.IsRelayed = setRelayed
@@ -56,7 +65,7 @@ func SetRelayedNodes(setRelayed bool, relay string, relayed []string) []models.N
 		}
 		node.SetLastModified()
 		if err := UpsertNode(&node); err != nil {
-			logger.Log(0, "setRelayedNodes.Insert", err.Error())
+			slog.Error("setRelayedNodes.Insert", "error", err)
 			continue
 		}
 		returnnodes = append(returnnodes, node)
@@ -127,6 +136,7 @@ func DeleteRelay(network, nodeid string) ([]models.Node, models.Node, error) {
 	if err = UpsertNode(&node); err != nil {
 		return returnnodes, models.Node{}, err
 	}
+	RecordNetworkEvent(node.Network, models.NetworkEventGateway, &node, "relay removed")
 	return returnnodes, node, nil
 }
 
@@ -134,7 +144,7 @@ func getRelayedAddresses(id string) []net.IPNet {
 	addrs := []net.IPNet{}
 	node, err := GetNodeByID(id)
 	if err != nil {
-		logger.Log(0, "getRelayedAddresses: "+err.Error())
+		slog.Error("getRelayedAddresses: " + err.Error())
 		return addrs
 	}
 	if node.Address.IP != nil {
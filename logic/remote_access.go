@@ -0,0 +1,130 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+)
+
+// ErrNoRACGateway - remote access clients connect through an ingress gateway of the network
+var ErrNoRACGateway = errors.New("network has no ingress gateway for remote access clients to connect through")
+
+// GetRemoteAccessClient - the ext client a user's remote access client was given on a network, if any
+func GetRemoteAccessClient(username, network string) (models.ExtClient, bool) {
+	clients, err := GetNetworkExtClients(network)
+	if err != nil {
+		return models.ExtClient{}, false
+	}
+	for _, client := range clients {
+		if client.RemoteAccess && client.OwnerID == username {
+			return client, true
+		}
+	}
+	return models.ExtClient{}, false
+}
+
+// ProvisionRemoteAccessClient - creates the ext client of a user's remote access client on a network,
+// or renews the one it has, moving it to the requested gateway and key when they changed,
+// returns whether the client is new or its peers have to be updated
+func ProvisionRemoteAccessClient(username, network string, request *models.RACClientRequest) (models.ExtClient, bool, error) {
+	lease := time.Now().Add(servercfg.GetRACClientTTL()).Unix()
+	client, ok := GetRemoteAccessClient(username, network)
+	if !ok {
+		gateway, err := remoteAccessGateway(network, request.GatewayID)
+		if err != nil {
+			return client, false, err
+		}
+		client = models.ExtClient{
+			Network:                network,
+			PublicKey:              request.PublicKey,
+			IngressGatewayID:       gateway.ID.String(),
+			IngressGatewayEndpoint: gatewayEndpoint(&gateway),
+			OwnerID:                username,
+			RemoteAccess:           true,
+			LeaseExpiry:            lease,
+			Enabled:                true,
+		}
+		SetExtClientKeyExpiry(&client)
+		if parent, err := GetNetwork(network); err == nil {
+			client.Enabled = parent.DefaultACL == "yes"
+		}
+		if err := SetClientDefaultACLs(&client); err != nil {
+			return client, false, err
+		}
+		if err := CreateExtClient(&client); err != nil {
+			return client, false, err
+		}
+		return client, true, nil
+	}
+	changed := false
+	if request.GatewayID != "" && request.GatewayID != client.IngressGatewayID {
+		gateway, err := remoteAccessGateway(network, request.GatewayID)
+		if err != nil {
+			return client, false, err
+		}
+		client.IngressGatewayID = gateway.ID.String()
+		client.IngressGatewayEndpoint = gatewayEndpoint(&gateway)
+		changed = true
+	}
+	if request.PublicKey != "" && request.PublicKey != client.PublicKey {
+		RevokeExtClientKey(&client, "rotated")
+		client.PublicKey = request.PublicKey
+		client.PrivateKey = "[ENTER PRIVATE KEY]"
+		SetExtClientKeyExpiry(&client)
+		changed = true
+	}
+	client.LeaseExpiry = lease
+	client.LastModified = time.Now().Unix()
+	return client, changed, SaveExtClient(&client)
+}
+
+// == private ==
+
+// remoteAccessGateway - the requested ingress gateway of a network, or the one serving the fewest ext clients
+func remoteAccessGateway(network, gatewayID string) (models.Node, error) {
+	if gatewayID != "" {
+		gateway, err := GetNodeByID(gatewayID)
+		if err != nil || !gateway.IsIngressGateway || gateway.Network != network {
+			return models.Node{}, ErrNoRACGateway
+		}
+		return gateway, nil
+	}
+	nodes, err := GetNetworkNodes(network)
+	if err != nil {
+		return models.Node{}, err
+	}
+	clients, err := GetNetworkExtClients(network)
+	if err != nil {
+		return models.Node{}, err
+	}
+	load := map[string]int{}
+	for _, client := range clients {
+		load[client.IngressGatewayID]++
+	}
+	var assigned *models.Node
+	now := time.Now()
+	for i := range nodes {
+		if !nodes[i].IsIngressGateway || nodes[i].PendingDelete || !IsNodeOnline(&nodes[i], now) {
+			continue
+		}
+		if assigned == nil || load[nodes[i].ID.String()] < load[assigned.ID.String()] {
+			assigned = &nodes[i]
+		}
+	}
+	if assigned == nil {
+		return models.Node{}, ErrNoRACGateway
+	}
+	return *assigned, nil
+}
+
+// gatewayEndpoint - the endpoint an ext client dials to reach a gateway node
+func gatewayEndpoint(gateway *models.Node) string {
+	host, err := GetHost(gateway.HostID.String())
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", host.EndpointIP.String(), GetPeerListenPort(host))
+}
@@ -0,0 +1,30 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvisionRemoteAccessClient(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	// networks are created with their users, at least one has to exist
+	assert.Nil(t, CreateUser(&models.User{UserName: "racadmin", Password: "password", IsAdmin: true}))
+	defer DeleteUser("racadmin")
+	network, err := CreateNetwork(models.Network{NetID: "ractest", AddressRange: "10.204.0.0/24"})
+	assert.Nil(t, err)
+	defer DeleteNetwork(network.NetID)
+	t.Run("NoGateway", func(t *testing.T) {
+		_, _, err := ProvisionRemoteAccessClient("racuser", network.NetID, &models.RACClientRequest{})
+		assert.ErrorIs(t, err, ErrNoRACGateway)
+		_, ok := GetRemoteAccessClient("racuser", network.NetID)
+		assert.False(t, ok)
+	})
+	t.Run("UnknownGateway", func(t *testing.T) {
+		_, _, err := ProvisionRemoteAccessClient("racuser", network.NetID, &models.RACClientRequest{GatewayID: "missing"})
+		assert.ErrorIs(t, err, ErrNoRACGateway)
+	})
+}
@@ -0,0 +1,266 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/models"
+)
+
+const (
+	// ReportGroupNetwork - report usage per network
+	ReportGroupNetwork = "network"
+	// ReportGroupTenant - report usage per tenant
+	ReportGroupTenant = "tenant"
+)
+
+// ErrInvalidReportGroup - returned when a report is grouped by something other than network or tenant
+var ErrInvalidReportGroup = errors.New("invalid report grouping, must be network or tenant")
+
+// GetUsageReport - builds a usage report per network or tenant over daily or monthly billing periods,
+// only covering the networks of tenant when it is set
+func GetUsageReport(period, groupBy string, from, to time.Time, tenant string) (models.UsageReport, error) {
+	if period != TrafficUsageDaily && period != TrafficUsageMonthly {
+		return models.UsageReport{}, ErrInvalidUsagePeriod
+	}
+	if groupBy != ReportGroupNetwork && groupBy != ReportGroupTenant {
+		return models.UsageReport{}, ErrInvalidReportGroup
+	}
+	start, end := from.UTC().Format(logger.TimeFormatDay), to.UTC().Format(logger.TimeFormatDay)
+	snapshots, err := getUsageSnapshots(start, end)
+	if err != nil {
+		return models.UsageReport{}, err
+	}
+	// today's counts are only stored once the daily hooks run, so use the live ones until then
+	today := time.Now().UTC().Format(logger.TimeFormatDay)
+	if today >= start && today <= end && !hasUsageSnapshot(snapshots, today) {
+		live, err := takeUsageSnapshots(today)
+		if err != nil {
+			return models.UsageReport{}, err
+		}
+		snapshots = append(snapshots, live...)
+	}
+	traffic, err := GetTrafficUsage(from, to)
+	if err != nil {
+		return models.UsageReport{}, err
+	}
+	networkTenants := make(map[string]string)
+	if networks, err := GetNetworks(); err == nil {
+		for _, network := range networks {
+			networkTenants[network.NetID] = network.Tenant
+		}
+	}
+	report := aggregateUsageReport(snapshots, traffic, networkTenants, period, groupBy, tenant)
+	report.From, report.To = start, end
+	return report, nil
+}
+
+// == private ==
+
+// aggregateUsageReport - sums the snapshots and traffic of each billing period per network or tenant
+func aggregateUsageReport(snapshots []models.UsageSnapshot, traffic []models.TrafficUsage, networkTenants map[string]string, period, groupBy, tenant string) models.UsageReport {
+	rows := make(map[string]*models.UsageReportRow)
+	getRow := func(day, network, networkTenant string) *models.UsageReportRow {
+		bucket := day
+		if period == TrafficUsageMonthly && len(bucket) >= 7 {
+			bucket = bucket[:7]
+		}
+		key := bucket + "###" + networkTenant
+		if groupBy == ReportGroupNetwork {
+			key += "###" + network
+		}
+		row, ok := rows[key]
+		if !ok {
+			row = &models.UsageReportRow{Period: bucket, Tenant: networkTenant}
+			if groupBy == ReportGroupNetwork {
+				row.Network = network
+			}
+			rows[key] = row
+		}
+		return row
+	}
+	// counts are summed over the networks of a row for each day, the row keeps the peak day
+	daily := make(map[*models.UsageReportRow]map[string]*models.UsageSnapshot)
+	for i := range snapshots {
+		s := &snapshots[i]
+		if tenant != "" && s.Tenant != tenant {
+			continue
+		}
+		// tenant wide snapshots only hold user counts, which can't be summed from
+		// the networks as users may belong to several of them
+		tenantWide := s.Network == ""
+		if tenantWide && groupBy == ReportGroupNetwork {
+			continue
+		}
+		row := getRow(s.Day, s.Network, s.Tenant)
+		if daily[row] == nil {
+			daily[row] = make(map[string]*models.UsageSnapshot)
+		}
+		day := daily[row][s.Day]
+		if day == nil {
+			day = &models.UsageSnapshot{}
+			daily[row][s.Day] = day
+		}
+		day.Nodes += s.Nodes
+		day.ExtClients += s.ExtClients
+		if groupBy == ReportGroupNetwork || tenantWide {
+			day.Users += s.Users
+		}
+		if day.Nodes > row.Nodes {
+			row.Nodes = day.Nodes
+		}
+		if day.Users > row.Users {
+			row.Users = day.Users
+		}
+		if day.ExtClients > row.ExtClients {
+			row.ExtClients = day.ExtClients
+		}
+	}
+	for _, u := range traffic {
+		networkTenant := networkTenants[u.Network]
+		if tenant != "" && networkTenant != tenant {
+			continue
+		}
+		row := getRow(u.Day, u.Network, networkTenant)
+		row.Sent += u.Sent
+		row.Received += u.Received
+	}
+
+	report := models.UsageReport{
+		Period:  period,
+		GroupBy: groupBy,
+		Rows:    make([]models.UsageReportRow, 0, len(rows)),
+	}
+	for _, row := range rows {
+		report.Rows = append(report.Rows, *row)
+	}
+	sort.Slice(report.Rows, func(i, j int) bool {
+		a, b := report.Rows[i], report.Rows[j]
+		if a.Period != b.Period {
+			return a.Period < b.Period
+		}
+		if a.Tenant != b.Tenant {
+			return a.Tenant < b.Tenant
+		}
+		return a.Network < b.Network
+	})
+	return report
+}
+
+// recordUsageSnapshots - stores today's node, user and ext client counts, run by the daily hooks
+func recordUsageSnapshots() error {
+	snapshots, err := takeUsageSnapshots(time.Now().UTC().Format(logger.TimeFormatDay))
+	if err != nil {
+		return err
+	}
+	for _, s := range snapshots {
+		data, err := json.Marshal(&s)
+		if err != nil {
+			return err
+		}
+		key := fmt.Sprintf("%s###%s###%s", s.Network, s.Tenant, s.Day)
+		if err = database.Insert(key, string(data), database.USAGE_SNAPSHOTS_TABLE_NAME); err != nil {
+			return err
+		}
+	}
+	return pruneUsageSnapshots()
+}
+
+// takeUsageSnapshots - counts the nodes, users and ext clients of every network, plus
+// a tenant wide snapshot with the users of each tenant
+func takeUsageSnapshots(day string) ([]models.UsageSnapshot, error) {
+	networks, err := GetNetworks()
+	if err != nil && !database.IsEmptyRecord(err) {
+		return nil, err
+	}
+	users, err := GetUsers()
+	if err != nil && !database.IsEmptyRecord(err) {
+		return nil, err
+	}
+	snapshots := []models.UsageSnapshot{}
+	for _, network := range networks {
+		s := models.UsageSnapshot{Network: network.NetID, Tenant: network.Tenant, Day: day}
+		if nodes, err := GetNetworkNodes(network.NetID); err == nil {
+			for _, node := range nodes {
+				if !node.Ephemeral {
+					s.Nodes++
+				}
+			}
+		}
+		if clients, err := GetNetworkExtClients(network.NetID); err == nil {
+			s.ExtClients = len(clients)
+		}
+		for _, user := range users {
+			if StringSliceContains(user.Networks, network.NetID) {
+				s.Users++
+			}
+		}
+		snapshots = append(snapshots, s)
+	}
+	tenantUsers := make(map[string]int)
+	for _, user := range users {
+		tenantUsers[user.Tenant]++
+	}
+	for tenant, count := range tenantUsers {
+		snapshots = append(snapshots, models.UsageSnapshot{Tenant: tenant, Day: day, Users: count})
+	}
+	return snapshots, nil
+}
+
+// getUsageSnapshots - fetches the stored snapshots between start and end days (inclusive)
+func getUsageSnapshots(start, end string) ([]models.UsageSnapshot, error) {
+	records, err := database.FetchRecords(database.USAGE_SNAPSHOTS_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return []models.UsageSnapshot{}, nil
+		}
+		return nil, err
+	}
+	snapshots := []models.UsageSnapshot{}
+	for _, value := range records {
+		var s models.UsageSnapshot
+		if err := json.Unmarshal([]byte(value), &s); err != nil {
+			continue
+		}
+		if s.Day < start || s.Day > end {
+			continue
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, nil
+}
+
+func hasUsageSnapshot(snapshots []models.UsageSnapshot, day string) bool {
+	for i := range snapshots {
+		if snapshots[i].Day == day {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneUsageSnapshots - removes snapshots past the traffic usage retention period
+func pruneUsageSnapshots() error {
+	records, err := database.FetchRecords(database.USAGE_SNAPSHOTS_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return nil
+		}
+		return err
+	}
+	cutoff := time.Now().UTC().AddDate(0, 0, -trafficUsageRetentionDays).Format(logger.TimeFormatDay)
+	for key, value := range records {
+		var s models.UsageSnapshot
+		if err := json.Unmarshal([]byte(value), &s); err != nil || s.Day < cutoff {
+			if err := database.DeleteRecord(database.USAGE_SNAPSHOTS_TABLE_NAME, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregateUsageReport(t *testing.T) {
+	snapshots := []models.UsageSnapshot{
+		{Network: "net1", Tenant: "acme", Day: "2023-08-01", Nodes: 2, Users: 1, ExtClients: 1},
+		{Network: "net1", Tenant: "acme", Day: "2023-08-02", Nodes: 4, Users: 1, ExtClients: 0},
+		{Network: "net2", Tenant: "acme", Day: "2023-08-01", Nodes: 3, Users: 1, ExtClients: 2},
+		{Tenant: "acme", Day: "2023-08-01", Users: 1},
+		{Network: "net3", Tenant: "globex", Day: "2023-08-01", Nodes: 1},
+	}
+	traffic := []models.TrafficUsage{
+		{ID: "node1", Network: "net1", Day: "2023-08-01", Sent: 10, Received: 20},
+		{ID: "node2", Network: "net2", Day: "2023-08-02", Sent: 5, Received: 5},
+		{ID: "node3", Network: "net3", Day: "2023-08-01", Sent: 1, Received: 1},
+	}
+	tenants := map[string]string{"net1": "acme", "net2": "acme", "net3": "globex"}
+	t.Run("MonthlyPerNetwork", func(t *testing.T) {
+		report := aggregateUsageReport(snapshots, traffic, tenants, TrafficUsageMonthly, ReportGroupNetwork, "")
+		assert.Equal(t, 3, len(report.Rows))
+		assert.Equal(t, "net1", report.Rows[0].Network)
+		assert.Equal(t, 4, report.Rows[0].Nodes)
+		assert.Equal(t, int64(10), report.Rows[0].Sent)
+	})
+	t.Run("MonthlyPerTenant", func(t *testing.T) {
+		report := aggregateUsageReport(snapshots, traffic, tenants, TrafficUsageMonthly, ReportGroupTenant, "")
+		assert.Equal(t, 2, len(report.Rows))
+		acme := report.Rows[0]
+		assert.Equal(t, "acme", acme.Tenant)
+		// 2023-08-01 is the peak day with 2 + 3 nodes
+		assert.Equal(t, 5, acme.Nodes)
+		// users come from the tenant wide snapshot so they aren't counted per network
+		assert.Equal(t, 1, acme.Users)
+		assert.Equal(t, 3, acme.ExtClients)
+		assert.Equal(t, int64(25), acme.Received)
+	})
+	t.Run("SingleTenant", func(t *testing.T) {
+		report := aggregateUsageReport(snapshots, traffic, tenants, TrafficUsageDaily, ReportGroupNetwork, "globex")
+		assert.Equal(t, 1, len(report.Rows))
+		assert.Equal(t, "net3", report.Rows[0].Network)
+	})
+}
@@ -0,0 +1,51 @@
+package logic
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+)
+
+// ErrStaleRevision - returned when a node or host is written with a revision older than the stored one
+var ErrStaleRevision = errors.New("record has been modified since it was read, refresh and try again")
+
+// revisionMutex - serializes the revision check and write of nodes and hosts
+var revisionMutex sync.Mutex
+
+// stampNodeRevision - checks the revision of a node against the stored record and bumps it,
+// a revision of 0 skips the check (eg. clients unaware of revisions)
+func stampNodeRevision(node *models.Node) error {
+	current, err := GetNodeByID(node.ID.String())
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			node.Revision = 1
+			return nil
+		}
+		return err
+	}
+	if node.Revision != 0 && node.Revision != current.Revision {
+		return ErrStaleRevision
+	}
+	node.Revision = current.Revision + 1
+	return nil
+}
+
+// stampHostRevision - checks the revision of a host against the stored record and bumps it,
+// a revision of 0 skips the check (eg. clients unaware of revisions)
+func stampHostRevision(host *models.Host) error {
+	current, err := GetHost(host.ID.String())
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			host.Revision = 1
+			return nil
+		}
+		return err
+	}
+	if host.Revision != 0 && host.Revision != current.Revision {
+		return ErrStaleRevision
+	}
+	host.Revision = current.Revision + 1
+	return nil
+}
@@ -0,0 +1,438 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic/acls"
+	"github.com/gravitl/netmaker/logic/acls/nodeacls"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/netclient/ncutils"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	defaultCanaryPercent       = 10
+	defaultRolloutObservation  = 10 * time.Minute
+	defaultMaxConnectivityDrop = 10
+	// rolloutMetricsInterval - how often hosts report metrics, the metrics of the canary nodes count for the
+	// evaluation only from one interval after the start, earlier ones may predate the change
+	rolloutMetricsInterval = ncutils.CheckInInterval * time.Minute
+)
+
+var (
+	// ErrInvalidRollout - a rollout request is malformed or names hosts the network doesn't have
+	ErrInvalidRollout = errors.New("invalid rollout")
+	// ErrRolloutActive - a network already has a rollout on its canary hosts
+	ErrRolloutActive = errors.New("network has an active rollout")
+	// ErrRolloutNotFound - no rollout has the id
+	ErrRolloutNotFound = errors.New("rollout not found")
+	// ErrRolloutFinished - a rollout was already promoted or rolled back
+	ErrRolloutFinished = errors.New("rollout is finished")
+)
+
+// StartRollout - applies a policy file or join config to canary hosts of a network, picked by id or as a share of
+// its hosts, and records the connectivity of their nodes to compare with once the observation ends; peers of
+// a policy rollout have to be sent the update, the canary hosts of a join config rollout have to be asked to pull
+func StartRollout(netID, user string, request *models.RolloutRequest) (models.Rollout, error) {
+	rollout := models.Rollout{
+		ID:                  uuid.New().String(),
+		Network:             netID,
+		Kind:                request.Kind,
+		Status:              models.RolloutCanary,
+		User:                user,
+		Policy:              request.Policy,
+		JoinConfig:          request.JoinConfig,
+		Started:             time.Now().UTC(),
+		MaxConnectivityDrop: request.MaxConnectivityDrop,
+	}
+	if err := validator.New().Struct(request); err != nil {
+		return rollout, fmt.Errorf("%w: %s", ErrInvalidRollout, err.Error())
+	}
+	if rollout.MaxConnectivityDrop == 0 {
+		rollout.MaxConnectivityDrop = defaultMaxConnectivityDrop
+	}
+	observation := defaultRolloutObservation
+	if request.ObserveMinutes > 0 {
+		observation = time.Duration(request.ObserveMinutes) * time.Minute
+	}
+	rollout.ObserveUntil = rollout.Started.Add(observation)
+
+	unlock, err := Lock(rolloutLockName(netID))
+	if err != nil {
+		return rollout, err
+	}
+	defer unlock()
+	if active, err := getActiveRollout(netID); err != nil {
+		return rollout, err
+	} else if active != nil {
+		return rollout, fmt.Errorf("%w: %s", ErrRolloutActive, active.ID)
+	}
+	nodes, err := GetNetworkNodes(netID)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return rollout, err
+	}
+	if rollout.CanaryHosts, err = selectCanaryHosts(nodes, request.CanaryHosts, request.CanaryPercent); err != nil {
+		return rollout, err
+	}
+	rollout.CanaryNodes = []string{}
+	for i := range nodes {
+		if StringSliceContains(rollout.CanaryHosts, nodes[i].HostID.String()) {
+			rollout.CanaryNodes = append(rollout.CanaryNodes, nodes[i].ID.String())
+		}
+	}
+
+	switch rollout.Kind {
+	case models.RolloutPolicy:
+		if IsNetworkLocked(netID) {
+			return rollout, ErrNetworkLocked
+		}
+		if _, err := ApplyNetworkPolicy(netID, rollout.Policy, true); err != nil {
+			return rollout, err
+		}
+		state, err := loadPolicyState(netID)
+		if err != nil {
+			return rollout, err
+		}
+		policy, _ := parsePolicy(rollout.Policy)
+		access, _ := planPolicy(&policy, state, &models.PolicyPlan{})
+		rollout.BaselineConnectivity = rolloutConnectivity(rollout.CanaryNodes, access, time.Time{})
+		rollout.ACLs = applyCanaryACLs(state.container, access, rollout.CanaryNodes)
+		if len(rollout.ACLs) > 0 {
+			if _, err := state.container.Save(acls.ContainerID(netID)); err != nil {
+				return rollout, err
+			}
+		}
+	case models.RolloutJoinConfig:
+		if err := prepareJoinConfig(rollout.JoinConfig); err != nil {
+			return rollout, fmt.Errorf("%w: %s", ErrInvalidRollout, err.Error())
+		}
+		rollout.BaselineConnectivity = rolloutConnectivity(rollout.CanaryNodes, nil, time.Time{})
+	}
+	if err := saveRollout(&rollout); err != nil {
+		return rollout, err
+	}
+	slog.Info("started rollout", "network", netID, "rollout", rollout.ID, "kind", rollout.Kind, "user", user, "canaries", len(rollout.CanaryHosts))
+	RecordNetworkEvent(netID, models.NetworkEventRollout, nil, fmt.Sprintf("%s rollout %s started on %d canary hosts by %s", rollout.Kind, rollout.ID, len(rollout.CanaryHosts), user))
+	return rollout, nil
+}
+
+// GetRollout - a rollout by id
+func GetRollout(id string) (models.Rollout, error) {
+	var rollout models.Rollout
+	record, err := database.FetchRecord(database.ROLLOUTS_TABLE_NAME, id)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return rollout, ErrRolloutNotFound
+		}
+		return rollout, err
+	}
+	err = json.Unmarshal([]byte(record), &rollout)
+	return rollout, err
+}
+
+// GetRollouts - the rollouts of a network, all networks when netID is empty, the latest first
+func GetRollouts(netID string) ([]models.Rollout, error) {
+	rollouts := []models.Rollout{}
+	records, err := database.FetchRecords(database.ROLLOUTS_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return rollouts, nil
+		}
+		return nil, err
+	}
+	for _, value := range records {
+		var rollout models.Rollout
+		if err := json.Unmarshal([]byte(value), &rollout); err != nil {
+			continue
+		}
+		if netID != "" && rollout.Network != netID {
+			continue
+		}
+		rollouts = append(rollouts, rollout)
+	}
+	sort.Slice(rollouts, func(i, j int) bool {
+		return rollouts[i].Started.After(rollouts[j].Started)
+	})
+	return rollouts, nil
+}
+
+// PromoteRollout - applies the change of a rollout on its canary hosts to the whole network
+func PromoteRollout(id, user string) (models.Rollout, error) {
+	return finishRollout(id, func(rollout *models.Rollout) error {
+		return promoteRollout(rollout, user)
+	})
+}
+
+// RollbackRollout - takes the change of a rollout back from its canary hosts
+func RollbackRollout(id, user, reason string) (models.Rollout, error) {
+	return finishRollout(id, func(rollout *models.Rollout) error {
+		return rollbackRollout(rollout, user, reason)
+	})
+}
+
+// EvaluateRollouts - promotes the rollouts whose observation ended with the connectivity of their canary nodes
+// within the allowed drop, rolling back the others; a rollout whose canary nodes reported no metrics since the start
+// is observed once more, then rolled back. Returns the rollouts finished
+func EvaluateRollouts() ([]models.Rollout, error) {
+	rollouts, err := GetRollouts("")
+	if err != nil {
+		return nil, err
+	}
+	finished := []models.Rollout{}
+	now := time.Now().UTC()
+	for i := range rollouts {
+		if rollouts[i].Status != models.RolloutCanary || now.Before(rollouts[i].ObserveUntil) {
+			continue
+		}
+		rollout, err := finishRollout(rollouts[i].ID, func(rollout *models.Rollout) error {
+			var access map[[2]string]bool
+			if rollout.Kind == models.RolloutPolicy {
+				if state, err := loadPolicyState(rollout.Network); err == nil {
+					policy, _ := parsePolicy(rollout.Policy)
+					access, _ = planPolicy(&policy, state, &models.PolicyPlan{})
+				}
+			}
+			rollout.CanaryConnectivity = rolloutConnectivity(rollout.CanaryNodes, access, rollout.Started.Add(rolloutMetricsInterval))
+			if rollout.CanaryConnectivity < 0 && !rollout.Extended {
+				// the canary hosts may be slow to report, they're watched once more before it's rolled back
+				rollout.Extended = true
+				rollout.ObserveUntil = time.Now().UTC().Add(rollout.ObserveUntil.Sub(rollout.Started))
+				return nil
+			}
+			if reason := evaluateRollout(rollout); reason != "" {
+				return rollbackRollout(rollout, "", reason)
+			}
+			if err := promoteRollout(rollout, ""); err != nil {
+				return rollbackRollout(rollout, "", "promotion failed: "+err.Error())
+			}
+			return nil
+		})
+		if err != nil {
+			if !errors.Is(err, ErrRolloutFinished) {
+				slog.Error("failed to evaluate rollout", "network", rollouts[i].Network, "rollout", rollouts[i].ID, "error", err)
+			}
+			continue
+		}
+		if rollout.Status == models.RolloutCanary {
+			slog.Warn("extended rollout observation, canary nodes reported no metrics since the start", "network", rollout.Network, "rollout", rollout.ID, "until", rollout.ObserveUntil)
+			continue
+		}
+		finished = append(finished, rollout)
+	}
+	return finished, nil
+}
+
+// == private ==
+
+func rolloutLockName(netID string) string {
+	return "rollout:" + netID
+}
+
+// finishRollout - runs fn on a rollout still on its canary hosts under the network's rollout lock,
+// saving it when fn leaves it finished
+func finishRollout(id string, fn func(rollout *models.Rollout) error) (models.Rollout, error) {
+	rollout, err := GetRollout(id)
+	if err != nil {
+		return rollout, err
+	}
+	unlock, err := Lock(rolloutLockName(rollout.Network))
+	if err != nil {
+		return rollout, err
+	}
+	defer unlock()
+	// another server may have finished it meanwhile
+	if rollout, err = GetRollout(id); err != nil {
+		return rollout, err
+	}
+	if rollout.Status != models.RolloutCanary {
+		return rollout, fmt.Errorf("%w: %s", ErrRolloutFinished, rollout.Status)
+	}
+	if err := fn(&rollout); err != nil {
+		return rollout, err
+	}
+	return rollout, saveRollout(&rollout)
+}
+
+func promoteRollout(rollout *models.Rollout, user string) error {
+	switch rollout.Kind {
+	case models.RolloutPolicy:
+		if _, err := ApplyNetworkPolicy(rollout.Network, rollout.Policy, false); err != nil {
+			return err
+		}
+	case models.RolloutJoinConfig:
+		if _, err := SetNetworkJoinConfig(rollout.Network, rollout.JoinConfig); err != nil {
+			return err
+		}
+	}
+	rollout.Status = models.RolloutPromoted
+	rollout.Finished = time.Now().UTC()
+	if user == "" {
+		user = "connectivity check"
+	}
+	slog.Info("promoted rollout", "network", rollout.Network, "rollout", rollout.ID, "by", user)
+	RecordNetworkEvent(rollout.Network, models.NetworkEventRollout, nil, fmt.Sprintf("%s rollout %s promoted to the network by %s", rollout.Kind, rollout.ID, user))
+	return nil
+}
+
+func rollbackRollout(rollout *models.Rollout, user, reason string) error {
+	if rollout.Kind == models.RolloutPolicy && len(rollout.ACLs) > 0 {
+		if IsNetworkLocked(rollout.Network) {
+			return ErrNetworkLocked
+		}
+		container, err := nodeacls.FetchAllACLs(nodeacls.NetworkID(rollout.Network))
+		if err != nil {
+			return err
+		}
+		if restoreLockdownACLs(container, rollout.ACLs) > 0 {
+			if _, err := container.Save(acls.ContainerID(rollout.Network)); err != nil {
+				return err
+			}
+		}
+	}
+	rollout.Status = models.RolloutRolledBack
+	rollout.Finished = time.Now().UTC()
+	rollout.Reason = reason
+	if user == "" {
+		user = "connectivity check"
+	}
+	slog.Warn("rolled back rollout", "network", rollout.Network, "rollout", rollout.ID, "by", user, "reason", reason)
+	RecordNetworkEvent(rollout.Network, models.NetworkEventRollout, nil, fmt.Sprintf("%s rollout %s rolled back by %s: %s", rollout.Kind, rollout.ID, user, reason))
+	return nil
+}
+
+// evaluateRollout - why a rollout should be rolled back after its observation, empty when it can be promoted
+func evaluateRollout(rollout *models.Rollout) string {
+	if rollout.CanaryConnectivity < 0 {
+		return "canary nodes reported no metrics since the rollout started"
+	}
+	baseline := rollout.BaselineConnectivity
+	if baseline < 0 {
+		// nothing to compare with, the canaries should be about fully connected
+		baseline = 100
+	}
+	if drop := baseline - rollout.CanaryConnectivity; drop > rollout.MaxConnectivityDrop {
+		return fmt.Sprintf("connectivity of canary nodes dropped from %.1f%% to %.1f%%", baseline, rollout.CanaryConnectivity)
+	}
+	return ""
+}
+
+// selectCanaryHosts - the hosts of a network's nodes named by id, or the given percentage of them, at least one
+func selectCanaryHosts(nodes []models.Node, requested []string, percent int) ([]string, error) {
+	hosts := []string{}
+	for i := range nodes {
+		if hostID := nodes[i].HostID.String(); !StringSliceContains(hosts, hostID) {
+			hosts = append(hosts, hostID)
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("%w: network has no hosts", ErrInvalidRollout)
+	}
+	if len(requested) > 0 {
+		canaries := []string{}
+		for _, hostID := range requested {
+			if !StringSliceContains(hosts, hostID) {
+				return nil, fmt.Errorf("%w: host %s is not in the network", ErrInvalidRollout, hostID)
+			}
+			if !StringSliceContains(canaries, hostID) {
+				canaries = append(canaries, hostID)
+			}
+		}
+		return canaries, nil
+	}
+	if percent <= 0 {
+		percent = defaultCanaryPercent
+	}
+	sort.Strings(hosts)
+	count := int(math.Ceil(float64(len(hosts)*percent) / 100))
+	return hosts[:count], nil
+}
+
+// applyCanaryACLs - sets the pairs of nodes with a canary node to the access a policy gives them, returning
+// the entries changed as they were
+func applyCanaryACLs(container acls.ACLContainer, access map[[2]string]bool, canaries []string) map[string]map[string]byte {
+	previous := map[string]map[string]byte{}
+	for pair, allowed := range access {
+		if !StringSliceContains(canaries, pair[0]) && !StringSliceContains(canaries, pair[1]) {
+			continue
+		}
+		value := acls.NotAllowed
+		if allowed {
+			value = acls.Allowed
+		}
+		a, b := acls.AclID(pair[0]), acls.AclID(pair[1])
+		current, ok := container[a][b]
+		if !ok || current == value {
+			continue
+		}
+		for _, entry := range [][2]acls.AclID{{a, b}, {b, a}} {
+			if previous[string(entry[0])] == nil {
+				previous[string(entry[0])] = map[string]byte{}
+			}
+			previous[string(entry[0])][string(entry[1])] = container[entry[0]][entry[1]]
+		}
+		container.ChangeAccess(a, b, value)
+	}
+	return previous
+}
+
+// rolloutConnectivity - the percentage of the peers the nodes should reach they are connected to, by their metrics;
+// with access, only the peers it allows count, metrics reported before since are left out. -1 when none of the nodes
+// reported metrics of such peers
+func rolloutConnectivity(nodeIDs []string, access map[[2]string]bool, since time.Time) float64 {
+	peers, connected := 0, 0
+	for _, nodeID := range nodeIDs {
+		metrics, err := GetMetrics(nodeID)
+		if err != nil || metrics.Reported.Before(since) {
+			continue
+		}
+		for peerID, metric := range metrics.Connectivity {
+			if access != nil {
+				pair := [2]string{nodeID, peerID}
+				if pair[0] > pair[1] {
+					pair[0], pair[1] = pair[1], pair[0]
+				}
+				if allowed, ok := access[pair]; ok && !allowed {
+					continue
+				}
+			}
+			peers++
+			if metric.Connected {
+				connected++
+			}
+		}
+	}
+	if peers == 0 {
+		return -1
+	}
+	return float64(connected) * 100 / float64(peers)
+}
+
+// getActiveRollout - the rollout of a network on its canary hosts, nil when there's none
+func getActiveRollout(netID string) (*models.Rollout, error) {
+	rollouts, err := GetRollouts(netID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range rollouts {
+		if rollouts[i].Status == models.RolloutCanary {
+			return &rollouts[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func saveRollout(rollout *models.Rollout) error {
+	data, err := json.Marshal(rollout)
+	if err != nil {
+		return err
+	}
+	return database.Insert(rollout.ID, string(data), database.ROLLOUTS_TABLE_NAME)
+}
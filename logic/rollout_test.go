@@ -0,0 +1,127 @@
+package logic
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic/acls"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectCanaryHosts(t *testing.T) {
+	nodes := []models.Node{}
+	for i := 0; i < 12; i++ {
+		nodes = append(nodes, models.Node{CommonNode: models.CommonNode{ID: uuid.New(), HostID: uuid.New()}})
+	}
+	// a host with two nodes counts once
+	nodes = append(nodes, models.Node{CommonNode: models.CommonNode{ID: uuid.New(), HostID: nodes[0].HostID}})
+
+	canaries, err := selectCanaryHosts(nodes, nil, 0)
+	assert.Nil(t, err)
+	assert.Len(t, canaries, 2, "10% of 12 hosts rounds up")
+	again, _ := selectCanaryHosts(nodes, nil, 0)
+	assert.Equal(t, canaries, again)
+	canaries, err = selectCanaryHosts(nodes, nil, 100)
+	assert.Nil(t, err)
+	assert.Len(t, canaries, 12)
+
+	t.Run("Named", func(t *testing.T) {
+		hostID := nodes[3].HostID.String()
+		canaries, err := selectCanaryHosts(nodes, []string{hostID, hostID}, 50)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{hostID}, canaries)
+		_, err = selectCanaryHosts(nodes, []string{uuid.New().String()}, 0)
+		assert.True(t, errors.Is(err, ErrInvalidRollout))
+		_, err = selectCanaryHosts(nil, nil, 0)
+		assert.True(t, errors.Is(err, ErrInvalidRollout))
+	})
+}
+
+func TestApplyCanaryACLs(t *testing.T) {
+	container := acls.ACLContainer{}
+	ids := []acls.AclID{"a", "b", "c"}
+	for _, a := range ids {
+		container[a] = acls.ACL{}
+		for _, b := range ids {
+			if a != b {
+				container[a][b] = acls.Allowed
+			}
+		}
+	}
+	// the policy flips the network to deny
+	access := map[[2]string]bool{{"a", "b"}: false, {"a", "c"}: false, {"b", "c"}: false}
+	previous := applyCanaryACLs(container, access, []string{"a"})
+	assert.False(t, container["a"].IsAllowed("b"))
+	assert.False(t, container["c"].IsAllowed("a"))
+	assert.True(t, container["b"].IsAllowed("c"), "pairs without a canary keep their access")
+	assert.Len(t, previous, 3)
+
+	assert.Equal(t, 4, restoreLockdownACLs(container, previous))
+	assert.True(t, container["a"].IsAllowed("b"))
+	assert.True(t, container["c"].IsAllowed("a"))
+}
+
+func TestEvaluateRollout(t *testing.T) {
+	rollout := models.Rollout{MaxConnectivityDrop: 10, BaselineConnectivity: 95, CanaryConnectivity: 90}
+	assert.Empty(t, evaluateRollout(&rollout))
+	rollout.CanaryConnectivity = 80
+	assert.NotEmpty(t, evaluateRollout(&rollout))
+	rollout.CanaryConnectivity = -1
+	assert.NotEmpty(t, evaluateRollout(&rollout), "canaries without metrics are rolled back")
+
+	t.Run("NoBaseline", func(t *testing.T) {
+		rollout := models.Rollout{MaxConnectivityDrop: 10, BaselineConnectivity: -1, CanaryConnectivity: 100}
+		assert.Empty(t, evaluateRollout(&rollout))
+		rollout.CanaryConnectivity = 85
+		assert.NotEmpty(t, evaluateRollout(&rollout))
+	})
+}
+
+func TestEvaluateRollouts(t *testing.T) {
+	database.InitializeDatabase()
+	t.Cleanup(database.CloseDB)
+	nodeID := uuid.New().String()
+	rollout := models.Rollout{
+		ID:                   uuid.New().String(),
+		Network:              "rolloutnet",
+		Kind:                 models.RolloutJoinConfig,
+		Status:               models.RolloutCanary,
+		CanaryNodes:          []string{nodeID},
+		Started:              time.Now().UTC().Add(-20 * time.Minute),
+		ObserveUntil:         time.Now().UTC().Add(-10 * time.Minute),
+		MaxConnectivityDrop:  10,
+		BaselineConnectivity: 100,
+	}
+	assert.Nil(t, saveRollout(&rollout))
+	t.Cleanup(func() {
+		database.DeleteRecord(database.ROLLOUTS_TABLE_NAME, rollout.ID)
+		DeleteMetrics(nodeID)
+		database.DeleteAllRecords(database.NETWORK_EVENTS_TABLE_NAME)
+	})
+	// connected, but reported before the change could have reached the canary
+	assert.Nil(t, UpdateMetrics(nodeID, &models.Metrics{
+		Connectivity: map[string]models.Metric{uuid.New().String(): {Connected: true}},
+		Reported:     rollout.Started.Add(rolloutMetricsInterval / 2),
+	}))
+
+	finished, err := EvaluateRollouts()
+	assert.Nil(t, err)
+	assert.Empty(t, finished, "stale metrics never promote")
+	extended, err := GetRollout(rollout.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, models.RolloutCanary, extended.Status)
+	assert.True(t, extended.Extended)
+	assert.True(t, extended.ObserveUntil.After(time.Now()))
+
+	extended.ObserveUntil = time.Now().UTC().Add(-time.Minute)
+	assert.Nil(t, saveRollout(&extended))
+	finished, err = EvaluateRollouts()
+	assert.Nil(t, err)
+	assert.Len(t, finished, 1)
+	assert.Equal(t, models.RolloutRolledBack, finished[0].Status, "rolled back when still no fresh metrics")
+	assert.Contains(t, finished[0].Reason, "no metrics")
+}
@@ -0,0 +1,395 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// reportExpiryWindow - how far ahead expiring reports look
+	reportExpiryWindow = 30 * 24 * time.Hour
+	// reportScheduleSlack - the daily hooks run about a day apart, so a report due within this is sent
+	// now rather than a day late
+	reportScheduleSlack = time.Hour
+	// reportWorstLinks - the worst links listed per network in health reports
+	reportWorstLinks = 5
+)
+
+// ErrReportRecipients - a scheduled report without an email address, webhook or notification channel to send it to
+var ErrReportRecipients = errors.New("a report needs an email address, a webhook or a notification channel to be sent to")
+
+// CreateScheduledReport - saves a report to send on its schedule, the first time when the daily hooks next run
+func CreateScheduledReport(report *models.ScheduledReport) error {
+	if err := validator.New().Struct(report); err != nil {
+		return err
+	}
+	if len(report.Emails) == 0 && len(report.Webhooks) == 0 && len(report.Channels) == 0 {
+		return ErrReportRecipients
+	}
+	if err := ValidateNotificationChannels(report.Channels, report.Tenant); err != nil {
+		return err
+	}
+	if report.Tenant != "" {
+		if err := NetworksInTenant(report.Networks, report.Tenant); err != nil {
+			return err
+		}
+	} else {
+		for _, netID := range report.Networks {
+			if _, err := GetNetwork(netID); err != nil {
+				return fmt.Errorf("unknown network %s", netID)
+			}
+		}
+	}
+	report.ID = uuid.New().String()
+	report.LastSent = time.Time{}
+	report.LastError = ""
+	return saveScheduledReport(report)
+}
+
+// GetScheduledReports - the scheduled reports, only those of a tenant when it's set
+func GetScheduledReports(tenant string) ([]models.ScheduledReport, error) {
+	reports := []models.ScheduledReport{}
+	records, err := database.FetchRecords(database.SCHEDULED_REPORTS_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return reports, nil
+		}
+		return nil, err
+	}
+	for _, value := range records {
+		var report models.ScheduledReport
+		if err := json.Unmarshal([]byte(value), &report); err != nil {
+			continue
+		}
+		if tenant != "" && report.Tenant != tenant {
+			continue
+		}
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Name < reports[j].Name
+	})
+	return reports, nil
+}
+
+// GetScheduledReport - fetches a scheduled report
+func GetScheduledReport(id string) (models.ScheduledReport, error) {
+	var report models.ScheduledReport
+	record, err := database.FetchRecord(database.SCHEDULED_REPORTS_TABLE_NAME, id)
+	if err != nil {
+		return report, err
+	}
+	err = json.Unmarshal([]byte(record), &report)
+	return report, err
+}
+
+// DeleteScheduledReport - stops sending a report
+func DeleteScheduledReport(id string) error {
+	return database.DeleteRecord(database.SCHEDULED_REPORTS_TABLE_NAME, id)
+}
+
+// RenderScheduledReport - renders a report as plain text from the current state of its networks
+func RenderScheduledReport(report *models.ScheduledReport) (models.RenderedReport, error) {
+	now := time.Now().UTC()
+	rendered := models.RenderedReport{ReportID: report.ID, Name: report.Name, Kind: report.Kind, Generated: now}
+	networks, err := reportNetworks(report)
+	if err != nil {
+		return rendered, err
+	}
+	var body string
+	switch report.Kind {
+	case models.ScheduledReportNetworkHealth:
+		rendered.Subject = "Network health: " + report.Name
+		body, err = renderNetworkHealth(networks, now)
+	case models.ScheduledReportUserAccess:
+		rendered.Subject = "User access summary: " + report.Name
+		body, err = renderUserAccess(report, networks)
+	case models.ScheduledReportExpiring:
+		rendered.Subject = "Expiring keys and certificates: " + report.Name
+		body, err = renderExpiring(report, networks, now)
+	default:
+		return rendered, fmt.Errorf("unknown report kind %s", report.Kind)
+	}
+	rendered.Body = fmt.Sprintf("%s\ngenerated %s\n\n%s", rendered.Subject, now.Format(time.RFC1123), body)
+	return rendered, err
+}
+
+// SendScheduledReport - renders a report and delivers it to its email addresses and webhooks,
+// recording when it was sent and the last delivery error
+func SendScheduledReport(report *models.ScheduledReport) error {
+	rendered, err := RenderScheduledReport(report)
+	if err != nil {
+		return err
+	}
+	failures := []string{}
+	for _, address := range report.Emails {
+		if err := SendEmail(address, rendered.Subject, rendered.Body); err != nil {
+			slog.Error("failed to email scheduled report", "report", report.Name, "to", address, "error", err)
+			failures = append(failures, address+": "+err.Error())
+		}
+	}
+	for _, webhook := range report.Webhooks {
+		postAlert(webhook, "scheduled report "+report.Name, rendered)
+	}
+	Notify(models.Notification{
+		Event:        models.NotificationEventReport,
+		Severity:     models.NotificationInfo,
+		Title:        rendered.Subject,
+		Text:         rendered.Body,
+		Tenant:       report.Tenant,
+		Time:         rendered.Generated,
+		Preformatted: true,
+	}, report.Channels)
+	report.LastSent = rendered.Generated
+	report.LastError = strings.Join(failures, "; ")
+	if err := saveScheduledReport(report); err != nil {
+		return err
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to email report %s to %s", report.Name, report.LastError)
+	}
+	return nil
+}
+
+// == private ==
+
+func saveScheduledReport(report *models.ScheduledReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	return database.Insert(report.ID, string(data), database.SCHEDULED_REPORTS_TABLE_NAME)
+}
+
+// sendScheduledReports - sends the reports that are due, run by the daily hooks
+func sendScheduledReports() error {
+	reports, err := GetScheduledReports("")
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for i := range reports {
+		if !scheduledReportDue(&reports[i], now) {
+			continue
+		}
+		if err := SendScheduledReport(&reports[i]); err != nil {
+			slog.Error("failed to send scheduled report", "report", reports[i].Name, "error", err)
+		}
+	}
+	return nil
+}
+
+// scheduledReportDue - whether a report hasn't been sent for its frequency
+func scheduledReportDue(report *models.ScheduledReport, now time.Time) bool {
+	if report.LastSent.IsZero() {
+		return true
+	}
+	var next time.Time
+	switch report.Frequency {
+	case models.ReportFrequencyDaily:
+		next = report.LastSent.AddDate(0, 0, 1)
+	case models.ReportFrequencyWeekly:
+		next = report.LastSent.AddDate(0, 0, 7)
+	default:
+		next = report.LastSent.AddDate(0, 1, 0)
+	}
+	return !now.Before(next.Add(-reportScheduleSlack))
+}
+
+// reportNetworks - the networks a report covers
+func reportNetworks(report *models.ScheduledReport) ([]models.Network, error) {
+	networks, err := GetNetworks()
+	if err != nil && !database.IsEmptyRecord(err) {
+		return nil, err
+	}
+	scoped := []models.Network{}
+	for _, network := range networks {
+		if report.Tenant != "" && network.Tenant != report.Tenant {
+			continue
+		}
+		if len(report.Networks) > 0 && !StringSliceContains(report.Networks, network.NetID) {
+			continue
+		}
+		scoped = append(scoped, network)
+	}
+	return scoped, nil
+}
+
+// renderNetworkHealth - the nodes online and link quality of each network, with its worst links
+func renderNetworkHealth(networks []models.Network, now time.Time) (string, error) {
+	var b strings.Builder
+	for _, network := range networks {
+		nodes, err := GetNetworkNodes(network.NetID)
+		if err != nil && !database.IsEmptyRecord(err) {
+			return "", err
+		}
+		online := 0
+		for i := range nodes {
+			if IsNodeOnline(&nodes[i], now) {
+				online++
+			}
+		}
+		fmt.Fprintf(&b, "%s\n  nodes online: %d of %d\n", network.NetID, online, len(nodes))
+		summary, err := GetNetworkMetricsSummary(network.NetID, reportWorstLinks)
+		if err != nil {
+			return "", err
+		}
+		if summary.Links > 0 {
+			fmt.Fprintf(&b, "  links connected: %d of %d\n  latency: median %dms, p95 %dms\n  average uptime: %.1f%%\n",
+				summary.ConnectedLinks, summary.Links, summary.MedianLatency, summary.P95Latency, summary.AvgPercentUp)
+			b.WriteString("  worst links:\n")
+			for _, link := range summary.WorstLinks {
+				state := fmt.Sprintf("%dms, %.1f%% up", link.Latency, link.PercentUp)
+				if !link.Connected {
+					state = "disconnected"
+				}
+				fmt.Fprintf(&b, "    %s -> %s: %s\n", link.NodeName, link.PeerName, state)
+			}
+		}
+		b.WriteString("\n")
+	}
+	if len(networks) == 0 {
+		b.WriteString("no networks\n")
+	}
+	return b.String(), nil
+}
+
+// renderUserAccess - the users with access to the networks of a report, with their role and ext clients
+func renderUserAccess(report *models.ScheduledReport, networks []models.Network) (string, error) {
+	users, err := GetUsers()
+	if err != nil && !database.IsEmptyRecord(err) {
+		return "", err
+	}
+	clients, err := GetAllExtClients()
+	if err != nil && !database.IsEmptyRecord(err) {
+		return "", err
+	}
+	netIDs := []string{}
+	for _, network := range networks {
+		netIDs = append(netIDs, network.NetID)
+	}
+	clientCount := map[string]int{}
+	for _, client := range clients {
+		if StringSliceContains(netIDs, client.Network) {
+			clientCount[client.OwnerID]++
+		}
+	}
+	var b strings.Builder
+	admins, members := 0, 0
+	for _, user := range users {
+		if report.Tenant != "" && user.Tenant != report.Tenant {
+			continue
+		}
+		access := []string{}
+		for _, netID := range user.Networks {
+			if StringSliceContains(netIDs, netID) {
+				access = append(access, netID)
+			}
+		}
+		role := "user"
+		switch {
+		case user.IsAdmin:
+			role = "admin"
+			admins++
+		case user.IsAuditor:
+			role = "auditor"
+		}
+		if !user.IsAdmin && len(access) == 0 {
+			continue
+		}
+		members++
+		if user.IsAdmin {
+			access = []string{"all networks"}
+		}
+		line := fmt.Sprintf("%s (%s): %s, %d ext clients", user.UserName, role, strings.Join(access, ", "), clientCount[user.UserName])
+		if user.Pending {
+			line += ", pending email verification"
+		}
+		b.WriteString(line + "\n")
+	}
+	return fmt.Sprintf("%d users with access, %d of them admins\n\n%s", members, admins, b.String()), nil
+}
+
+// renderExpiring - the enrollment keys, ext client keys and certificates of a report's networks expiring within
+// the expiry window; certificates aren't tied to networks so only reports of every tenant list them
+func renderExpiring(report *models.ScheduledReport, networks []models.Network, now time.Time) (string, error) {
+	keys, err := GetAllEnrollmentKeys()
+	if err != nil && !database.IsEmptyRecord(err) {
+		return "", err
+	}
+	clients, err := GetAllExtClients()
+	if err != nil && !database.IsEmptyRecord(err) {
+		return "", err
+	}
+	certs := []models.PKICertificate{}
+	if report.Tenant == "" {
+		if certs, err = GetCertificates(""); err != nil {
+			return "", err
+		}
+	}
+	return expiringCredentials(networks, keys, clients, certs, now), nil
+}
+
+// expiringCredentials - lists what of the networks expires within the expiry window, soonest first
+func expiringCredentials(networks []models.Network, keys []*models.EnrollmentKey, clients []models.ExtClient, certs []models.PKICertificate, now time.Time) string {
+	type expiring struct {
+		at   time.Time
+		line string
+	}
+	netIDs := []string{}
+	for _, network := range networks {
+		netIDs = append(netIDs, network.NetID)
+	}
+	inScope := func(networks []string) bool {
+		for _, netID := range networks {
+			if StringSliceContains(netIDs, netID) {
+				return true
+			}
+		}
+		return false
+	}
+	until := now.Add(reportExpiryWindow)
+	soon := func(at time.Time) bool {
+		return at.After(now) && !at.After(until)
+	}
+	entries := []expiring{}
+	for _, key := range keys {
+		if key.Type == models.TimeExpiration && soon(key.Expiration) && inScope(key.Networks) {
+			entries = append(entries, expiring{key.Expiration, fmt.Sprintf("enrollment key for %s (tags %s)",
+				strings.Join(key.Networks, ", "), strings.Join(key.Tags, ", "))})
+		}
+	}
+	for _, client := range clients {
+		at := time.Unix(client.KeyExpiry, 0).UTC()
+		if client.KeyExpiry != 0 && soon(at) && inScope([]string{client.Network}) {
+			entries = append(entries, expiring{at, fmt.Sprintf("ext client key %s on %s of %s", client.ClientID, client.Network, client.OwnerID)})
+		}
+	}
+	for _, cert := range certs {
+		if !cert.Revoked && soon(cert.NotAfter) {
+			entries = append(entries, expiring{cert.NotAfter, fmt.Sprintf("%s certificate %s (serial %s)", cert.Kind, cert.Subject, cert.Serial)})
+		}
+	}
+	if len(entries) == 0 {
+		return "nothing expires in the next 30 days\n"
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].at.Before(entries[j].at)
+	})
+	var b strings.Builder
+	for _, entry := range entries {
+		fmt.Fprintf(&b, "%s  %s\n", entry.at.Format(logger.TimeFormatDay), entry.line)
+	}
+	return b.String()
+}
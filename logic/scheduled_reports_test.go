@@ -0,0 +1,45 @@
+package logic
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduledReportDue(t *testing.T) {
+	now := time.Date(2023, 8, 15, 12, 0, 0, 0, time.UTC)
+	assert.True(t, scheduledReportDue(&models.ScheduledReport{Frequency: models.ReportFrequencyWeekly}, now), "never sent")
+	daily := &models.ScheduledReport{Frequency: models.ReportFrequencyDaily, LastSent: now.Add(-23*time.Hour - 30*time.Minute)}
+	assert.True(t, scheduledReportDue(daily, now), "the hooks may run a little early")
+	weekly := &models.ScheduledReport{Frequency: models.ReportFrequencyWeekly, LastSent: now.AddDate(0, 0, -3)}
+	assert.False(t, scheduledReportDue(weekly, now))
+	monthly := &models.ScheduledReport{Frequency: models.ReportFrequencyMonthly, LastSent: now.AddDate(0, -1, 0)}
+	assert.True(t, scheduledReportDue(monthly, now))
+}
+
+func TestExpiringCredentials(t *testing.T) {
+	now := time.Date(2023, 8, 15, 12, 0, 0, 0, time.UTC)
+	networks := []models.Network{{NetID: "net1"}}
+	keys := []*models.EnrollmentKey{
+		{Type: models.TimeExpiration, Expiration: now.AddDate(0, 0, 10), Networks: []string{"net1"}, Tags: []string{"office"}},
+		{Type: models.TimeExpiration, Expiration: now.AddDate(0, 0, 10), Networks: []string{"net2"}, Tags: []string{"other"}},
+		{Type: models.TimeExpiration, Expiration: now.AddDate(0, 0, 60), Networks: []string{"net1"}, Tags: []string{"later"}},
+	}
+	clients := []models.ExtClient{
+		{ClientID: "laptop", Network: "net1", OwnerID: "alice", KeyExpiry: now.AddDate(0, 0, 2).Unix()},
+		{ClientID: "expired", Network: "net1", KeyExpiry: now.AddDate(0, 0, -2).Unix()},
+	}
+	certs := []models.PKICertificate{
+		{Kind: models.CertKindBroker, Subject: "broker", Serial: "1", NotAfter: now.AddDate(0, 0, 20)},
+		{Kind: models.CertKindHost, Subject: "revoked", Serial: "2", NotAfter: now.AddDate(0, 0, 5), Revoked: true},
+	}
+	lines := strings.Split(strings.TrimSpace(expiringCredentials(networks, keys, clients, certs, now)), "\n")
+	assert.Equal(t, 3, len(lines))
+	assert.Contains(t, lines[0], "laptop", "soonest first")
+	assert.Contains(t, lines[1], "office")
+	assert.Contains(t, lines[2], "broker")
+	assert.Contains(t, expiringCredentials(networks, nil, nil, nil, now), "nothing expires")
+}
@@ -0,0 +1,260 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
+)
+
+// jobFailuresKept - how many failures of a job are kept to inspect
+const jobFailuresKept = 10
+
+// ErrJobNotFound - no job of the name is registered
+var ErrJobNotFound = errors.New("job not found")
+
+// job - a background job and its status
+type job struct {
+	run     func(ctx context.Context) error
+	trigger chan struct{}
+	mutex   sync.Mutex
+	status  models.JobStatus
+	every   time.Duration
+}
+
+var (
+	jobsMutex sync.RWMutex
+	jobs      = map[string]*job{}
+	// jobsCtx, jobsWg - set once the scheduler started, jobs registered after start right away
+	jobsCtx context.Context
+	jobsWg  *sync.WaitGroup
+)
+
+// RegisterJobs - adds the background jobs of the server that need no message queue publishing
+func RegisterJobs() {
+	RegisterJob("node_status", nodeStatusInterval, func(ctx context.Context) error {
+		return recordNodeStatuses(time.Now().UTC())
+	})
+	RegisterJob("trash_purge", trashPurgeInterval, func(ctx context.Context) error {
+		return PurgeExpiredTrash()
+	})
+	RegisterJob("address_conflicts", addressConflictInterval, func(ctx context.Context) error {
+		checkAddressConflicts()
+		return nil
+	})
+	RegisterJob("address_reservations", time.Hour, func(ctx context.Context) error {
+		_, err := PurgeAddressReservations()
+		return err
+	})
+	// the daily hooks run once 24 hours passed since they last did, even across restarts
+	RegisterJob("daily_hooks", time.Hour, func(ctx context.Context) error {
+		return TimerCheckpoint()
+	})
+}
+
+// RegisterJob - adds a job the scheduler runs every interval, unless JOB_INTERVALS sets another for its name;
+// the job is started right away if the scheduler already is
+func RegisterJob(name string, interval time.Duration, run func(ctx context.Context) error) {
+	if configured, ok := servercfg.GetJobIntervals()[name]; ok {
+		interval = configured
+	}
+	j := &job{
+		run:     run,
+		trigger: make(chan struct{}, 1),
+		every:   interval,
+		status:  models.JobStatus{Name: name, Interval: interval.String(), RecentFailures: []models.JobFailure{}},
+	}
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+	if _, ok := jobs[name]; ok {
+		slog.Error("job is registered already", "job", name)
+		return
+	}
+	jobs[name] = j
+	if jobsCtx != nil {
+		jobsWg.Add(1)
+		go j.loop(jobsCtx, jobsWg)
+	}
+}
+
+// StartScheduler - runs the registered jobs on their intervals until ctx is done
+func StartScheduler(ctx context.Context, wg *sync.WaitGroup) {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+	jobsCtx, jobsWg = ctx, wg
+	for _, j := range jobs {
+		wg.Add(1)
+		go j.loop(ctx, wg)
+	}
+}
+
+// GetJobs - the status of every job, by name
+func GetJobs() []models.JobStatus {
+	jobsMutex.RLock()
+	statuses := make([]models.JobStatus, 0, len(jobs))
+	for _, j := range jobs {
+		statuses = append(statuses, j.getStatus())
+	}
+	jobsMutex.RUnlock()
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// GetJob - the status of a job
+func GetJob(name string) (models.JobStatus, error) {
+	j, err := getJob(name)
+	if err != nil {
+		return models.JobStatus{}, err
+	}
+	return j.getStatus(), nil
+}
+
+// TriggerJob - runs a job now, even when paused; a run already asked for isn't asked for twice
+func TriggerJob(name string) (models.JobStatus, error) {
+	j, err := getJob(name)
+	if err != nil {
+		return models.JobStatus{}, err
+	}
+	select {
+	case j.trigger <- struct{}{}:
+	default:
+	}
+	return j.getStatus(), nil
+}
+
+// PauseJob - stops a job running on its interval, or lets it run again; it can still be triggered
+func PauseJob(name string, paused bool) (models.JobStatus, error) {
+	j, err := getJob(name)
+	if err != nil {
+		return models.JobStatus{}, err
+	}
+	j.mutex.Lock()
+	j.status.Paused = paused
+	j.mutex.Unlock()
+	return j.getStatus(), nil
+}
+
+// == private ==
+
+func getJob(name string) (*job, error) {
+	jobsMutex.RLock()
+	defer jobsMutex.RUnlock()
+	j, ok := jobs[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+	return j, nil
+}
+
+func (j *job) getStatus() models.JobStatus {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	status := j.status
+	status.RecentFailures = append([]models.JobFailure{}, j.status.RecentFailures...)
+	return status
+}
+
+// loop - runs the job every interval and whenever it's triggered, reporting to the worker liveness checks
+func (j *job) loop(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	name := j.status.Name
+	for {
+		WorkerHeartbeat(name, j.every)
+		j.mutex.Lock()
+		j.status.NextRun = time.Now().Add(j.every).UTC()
+		j.mutex.Unlock()
+		select {
+		case <-ctx.Done():
+			StopWorker(name)
+			return
+		case <-j.trigger:
+			j.execute(ctx)
+		case <-time.After(j.every):
+			j.mutex.Lock()
+			paused := j.status.Paused
+			j.mutex.Unlock()
+			if !paused {
+				j.execute(ctx)
+			}
+		}
+	}
+}
+
+// execute - runs the job once, a panic counting as a failure; the run is skipped when another server
+// sharing the database ran the job within its interval
+func (j *job) execute(ctx context.Context) {
+	start := time.Now()
+	lease := "job:" + j.status.Name
+	held, err := TryLock(lease, j.every)
+	if err != nil || !held {
+		j.skip(lease, err)
+		return
+	}
+	// renewed while running so a run longer than the interval isn't started elsewhere
+	renewed := make(chan struct{})
+	defer close(renewed)
+	go func() {
+		for {
+			select {
+			case <-renewed:
+				return
+			case <-time.After(j.every / 2):
+				if _, err := TryLock(lease, j.every); err != nil {
+					slog.Warn("failed to renew job lease", "job", j.status.Name, "error", err)
+				}
+			}
+		}
+	}()
+	j.mutex.Lock()
+	j.status.Running = true
+	j.mutex.Unlock()
+	err = func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("job panicked: %v", r)
+			}
+		}()
+		return j.run(ctx)
+	}()
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.status.Running = false
+	j.status.Runs++
+	j.status.LastRun = start.UTC()
+	j.status.LastDuration = time.Since(start).Round(time.Millisecond).String()
+	j.status.LastError = ""
+	if err != nil {
+		slog.Error("job failed", "job", j.status.Name, "error", err)
+		j.status.Failures++
+		j.status.LastError = err.Error()
+		j.status.RecentFailures = append([]models.JobFailure{{Time: start.UTC(), Error: err.Error()}}, j.status.RecentFailures...)
+		if len(j.status.RecentFailures) > jobFailuresKept {
+			j.status.RecentFailures = j.status.RecentFailures[:jobFailuresKept]
+		}
+	}
+}
+
+// skip - records a run left to the server holding the job's lease, or failed to check it
+func (j *job) skip(lease string, err error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if err != nil {
+		slog.Error("failed to take job lease", "job", j.status.Name, "error", err)
+		j.status.Failures++
+		j.status.LastError = err.Error()
+		return
+	}
+	j.status.Skipped++
+	if current, err := database.GetLease(lease); err == nil {
+		j.status.HeldBy = current.Owner
+	}
+}
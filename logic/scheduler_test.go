@@ -0,0 +1,85 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduler(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	defer func() {
+		cancel()
+		wg.Wait()
+		jobsMutex.Lock()
+		jobs, jobsCtx, jobsWg = map[string]*job{}, nil, nil
+		jobsMutex.Unlock()
+	}()
+	RegisterJob("test_ok", time.Hour, func(ctx context.Context) error {
+		return nil
+	})
+	StartScheduler(ctx, wg)
+	// registered after the start, so started right away
+	RegisterJob("test_failing", time.Hour, func(ctx context.Context) error {
+		return errors.New("database unreachable")
+	})
+	waitForJob := func(name string, runCount int) models.JobStatus {
+		var status models.JobStatus
+		assert.Eventually(t, func() bool {
+			status, _ = GetJob(name)
+			return status.Runs == runCount && !status.Running
+		}, time.Second, 10*time.Millisecond)
+		return status
+	}
+	t.Run("List", func(t *testing.T) {
+		list := GetJobs()
+		assert.Equal(t, 2, len(list))
+		assert.Equal(t, "test_failing", list[0].Name)
+		assert.Equal(t, "1h0m0s", list[1].Interval)
+		assert.Zero(t, list[1].Runs)
+	})
+	t.Run("Trigger", func(t *testing.T) {
+		_, err := TriggerJob("test_ok")
+		assert.Nil(t, err)
+		status := waitForJob("test_ok", 1)
+		assert.Empty(t, status.LastError)
+		assert.False(t, status.LastRun.IsZero())
+	})
+	t.Run("Failures", func(t *testing.T) {
+		for i := 1; i <= jobFailuresKept+2; i++ {
+			_, err := TriggerJob("test_failing")
+			assert.Nil(t, err)
+			waitForJob("test_failing", i)
+		}
+		status, err := GetJob("test_failing")
+		assert.Nil(t, err)
+		assert.Equal(t, jobFailuresKept+2, status.Failures)
+		assert.Equal(t, "database unreachable", status.LastError)
+		assert.Equal(t, jobFailuresKept, len(status.RecentFailures))
+	})
+	t.Run("Pause", func(t *testing.T) {
+		status, err := PauseJob("test_ok", true)
+		assert.Nil(t, err)
+		assert.True(t, status.Paused)
+		// a paused job can still be run by hand
+		_, err = TriggerJob("test_ok")
+		assert.Nil(t, err)
+		waitForJob("test_ok", 2)
+		status, err = PauseJob("test_ok", false)
+		assert.Nil(t, err)
+		assert.False(t, status.Paused)
+	})
+	t.Run("Unknown", func(t *testing.T) {
+		_, err := TriggerJob("nope")
+		assert.True(t, errors.Is(err, ErrJobNotFound))
+	})
+}
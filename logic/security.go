@@ -22,6 +22,8 @@ const (
 	Forbidden_Err    = models.Error(Forbidden_Msg)
 	Unauthorized_Msg = "unauthorized"
 	Unauthorized_Err = models.Error(Unauthorized_Msg)
+	// HostIDHeader - set on requests authenticated as a host, to its id
+	HostIDHeader = "host-id"
 )
 
 // SecurityCheck - Check if user has appropriate permissions
@@ -32,6 +34,8 @@ func SecurityCheck(reqAdmin bool, next http.Handler) http.HandlerFunc {
 			Code: http.StatusForbidden, Message: Forbidden_Msg,
 		}
 		r.Header.Set("ismaster", "no")
+		r.Header.Set("tenant", "")
+		r.Header.Del(ImpersonatorHeader)
 
 		var params = mux.Vars(r)
 		bearerToken := r.Header.Get("Authorization")
@@ -54,18 +58,46 @@ func SecurityCheck(reqAdmin bool, next http.Handler) http.HandlerFunc {
 			ReturnErrorResponse(w, r, errorResponse)
 			return
 		}
+		if err := CheckAPIAllowlist(r, username); err != nil {
+			ReturnErrorResponse(w, r, FormatError(err, "forbidden"))
+			return
+		}
+		if r.Header.Get(ActAsHeader) != "" {
+			target, err := impersonate(r, username)
+			if err != nil {
+				ReturnErrorResponse(w, r, FormatError(err, "forbidden"))
+				return
+			}
+			// the request is served with the networks and rights of the user acted as
+			if networks, err = userPermissions(reqAdmin, networkName, target.UserName, target.Networks, target.IsAdmin); err != nil {
+				ReturnErrorResponse(w, r, errorResponse)
+				return
+			}
+			r.Header.Set(ImpersonatorHeader, username)
+			username = target.UserName
+		}
 		// detect masteradmin
 		if len(networks) > 0 && networks[0] == ALL_NETWORK_ACCESS {
 			r.Header.Set("ismaster", "yes")
 		}
+		tenant := GetUserTenant(username)
+		if tenant != "" && !ResourcesInTenant(params, tenant) {
+			ReturnErrorResponse(w, r, errorResponse)
+			return
+		}
+		if !isReadOnlyRequest(r) && IsAuditor(username) {
+			ReturnErrorResponse(w, r, errorResponse)
+			return
+		}
 		networksJson, err := json.Marshal(&networks)
 		if err != nil {
 			ReturnErrorResponse(w, r, errorResponse)
 			return
 		}
+		r.Header.Set("tenant", tenant)
 		r.Header.Set("user", username)
 		r.Header.Set("networks", string(networksJson))
-		next.ServeHTTP(w, r)
+		ServeAuthorized(next, w, r)
 	}
 }
 
@@ -76,6 +108,7 @@ func NetUserSecurityCheck(isNodes, isClients bool, next http.Handler) http.Handl
 			Code: http.StatusForbidden, Message: Forbidden_Msg,
 		}
 		r.Header.Set("ismaster", "no")
+		r.Header.Del(ImpersonatorHeader)
 
 		var params = mux.Vars(r)
 		var netUserName = params["networkuser"]
@@ -94,22 +127,46 @@ func NetUserSecurityCheck(isNodes, isClients bool, next http.Handler) http.Handl
 		}
 
 		isMasterAuthenticated := authenticateMaster(authToken)
-		if isMasterAuthenticated {
+		if isMasterAuthenticated && r.Header.Get(ActAsHeader) == "" {
+			if err := CheckAPIAllowlist(r, master_uname); err != nil {
+				ReturnErrorResponse(w, r, FormatError(err, "forbidden"))
+				return
+			}
 			r.Header.Set("user", "master token user")
 			r.Header.Set("ismaster", "yes")
-			next.ServeHTTP(w, r)
+			ServeAuthorized(next, w, r)
 			return
 		}
 
-		userName, _, isadmin, err := VerifyUserToken(authToken)
-		if err != nil {
-			ReturnErrorResponse(w, r, errorResponse)
+		userName, isadmin := master_uname, true
+		if !isMasterAuthenticated {
+			var err error
+			if userName, _, isadmin, err = VerifyUserToken(authToken); err != nil {
+				ReturnErrorResponse(w, r, errorResponse)
+				return
+			}
+		}
+		if err := CheckAPIAllowlist(r, userName); err != nil {
+			ReturnErrorResponse(w, r, FormatError(err, "forbidden"))
 			return
 		}
+		if r.Header.Get(ActAsHeader) != "" {
+			target, err := impersonate(r, userName)
+			if err != nil {
+				ReturnErrorResponse(w, r, FormatError(err, "forbidden"))
+				return
+			}
+			r.Header.Set(ImpersonatorHeader, userName)
+			userName, isadmin = target.UserName, target.IsAdmin
+		}
 		r.Header.Set("user", userName)
 
 		if isadmin {
-			next.ServeHTTP(w, r)
+			ServeAuthorized(next, w, r)
+			return
+		}
+		if !isReadOnlyRequest(r) && IsAuditor(userName) {
+			ReturnErrorResponse(w, r, errorResponse)
 			return
 		}
 
@@ -135,7 +192,7 @@ func NetUserSecurityCheck(isNodes, isClients bool, next http.Handler) http.Handl
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		ServeAuthorized(next, w, r)
 	}
 }
 
@@ -159,21 +216,91 @@ func UserPermissions(reqAdmin bool, netname string, token string) ([]string, str
 	if err != nil {
 		return nil, username, Unauthorized_Err
 	}
+	userNetworks, err = userPermissions(reqAdmin, netname, username, networks, isadmin)
+	if err != nil {
+		return nil, username, err
+	}
+	return userNetworks, username, nil
+}
+
+// userPermissions - the networks a user with the given networks and admin right may reach for a request,
+// Forbidden_Err when the request needs more
+func userPermissions(reqAdmin bool, netname, username string, networks []string, isadmin bool) ([]string, error) {
+	if !isadmin && IsAuditor(username) {
+		// auditors see what an admin of their tenant sees, SecurityCheck keeps them to read-only requests
+		isadmin = true
+	}
 	if !isadmin && reqAdmin {
-		return nil, username, Forbidden_Err
+		return nil, Forbidden_Err
 	}
-	userNetworks = networks
 	if isadmin {
-		return []string{ALL_NETWORK_ACCESS}, username, nil
+		tenant := GetUserTenant(username)
+		if tenant == "" {
+			return []string{ALL_NETWORK_ACCESS}, nil
+		}
+		// tenant admins manage every network of their tenant and nothing else
+		tenantNetworks, err := GetTenantNetworks(tenant)
+		if err != nil {
+			return nil, err
+		}
+		if len(netname) > 0 && !StringSliceContains(tenantNetworks, netname) {
+			return nil, Forbidden_Err
+		}
+		return tenantNetworks, nil
 	}
 	// check network admin access
-	if len(netname) > 0 && (len(userNetworks) == 0 || !authenticateNetworkUser(netname, userNetworks)) {
-		return nil, username, Forbidden_Err
+	if len(netname) > 0 && (len(networks) == 0 || !authenticateNetworkUser(netname, networks)) {
+		return nil, Forbidden_Err
 	}
 	if isEE && len(netname) > 0 && !pro.IsUserNetAdmin(netname, username) {
-		return nil, "", Forbidden_Err
+		return nil, Forbidden_Err
 	}
-	return userNetworks, username, nil
+	return networks, nil
+}
+
+// SuperAdminCheck - Check if user is an admin of the whole server rather than of a single tenant
+func SuperAdminCheck(next http.Handler) http.HandlerFunc {
+	return SecurityCheck(true, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("tenant") != "" {
+			ReturnErrorResponse(w, r, models.ErrorResponse{
+				Code: http.StatusForbidden, Message: Forbidden_Msg,
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
+
+// serveAuthorized - serves a request whose user passed the security checks once the authorization policy, if any, allows it
+func ServeAuthorized(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	if err := CheckOPAAuthorization(r); err != nil {
+		ReturnErrorResponse(w, r, FormatError(err, "forbidden"))
+		return
+	}
+	serveAudited(next, w, r)
+}
+
+// ResourcesInTenant - checks the host, user and enrollment key named in the route belong to the tenant
+func ResourcesInTenant(params map[string]string, tenant string) bool {
+	if hostID := params["hostid"]; hostID != "" {
+		host, err := GetHost(hostID)
+		if err != nil || !HostInTenant(host, tenant) {
+			return false
+		}
+	}
+	if username := params["username"]; username != "" {
+		// a user that doesn't exist yet is being created within the tenant
+		if user, err := GetUser(username); err == nil && user.Tenant != tenant {
+			return false
+		}
+	}
+	if keyID := params["keyID"]; keyID != "" {
+		key, err := GetEnrollmentKey(keyID)
+		if err != nil || key.Tenant != tenant {
+			return false
+		}
+	}
+	return true
 }
 
 // Consider a more secure way of setting master key
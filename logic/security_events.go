@@ -0,0 +1,261 @@
+package logic
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// securityEventRetentionDays - how long security alerts are kept around
+	securityEventRetentionDays = 90
+	// revokedKeyRetentionDays - how long the keys of deleted ext clients and hosts are watched for
+	revokedKeyRetentionDays = 365
+	// revokedKeyAlertInterval - how often the reuse of the same revoked key is alerted on
+	revokedKeyAlertInterval = time.Hour
+	// defaultSecurityEventLimit - how many security events are returned when no limit is asked for
+	defaultSecurityEventLimit = 100
+)
+
+var (
+	revokedKeyAlertMutex sync.Mutex
+	// revokedKeyAlerts - when the reuse of each revoked key was last alerted on
+	revokedKeyAlerts = map[string]time.Time{}
+)
+
+// RevokeExtClientKey - remembers the key of an ext client that was deleted, disabled or rotated away from
+func RevokeExtClientKey(client *models.ExtClient, reason string) {
+	revokeKey(models.RevokedKey{
+		PublicKey: client.PublicKey,
+		Kind:      models.RevokedExtClientKey,
+		ID:        client.ClientID,
+		Network:   client.Network,
+		OwnerID:   client.OwnerID,
+		Reason:    reason,
+	})
+}
+
+// RevokeHostKey - remembers the key of a host removed from the server
+func RevokeHostKey(host *models.Host) {
+	revokeKey(models.RevokedKey{
+		PublicKey: host.PublicKey.String(),
+		Kind:      models.RevokedHostKey,
+		ID:        host.ID.String(),
+		Reason:    "deleted",
+	})
+}
+
+// CheckRevokedKeyReuse - raises a security alert for each revoked key a gateway reports seeing a handshake from,
+// a key back in use by an ext client or host is no longer revoked
+func CheckRevokedKeyReuse(gateway *models.Node, metrics *models.Metrics) {
+	if metrics == nil {
+		return
+	}
+	seen := map[string]string{}
+	for key, endpoint := range metrics.UnknownPeers {
+		seen[key] = endpoint
+	}
+	for key, metric := range metrics.Connectivity {
+		if _, ok := seen[key]; !ok {
+			seen[key] = metric.Endpoint
+		}
+	}
+	for key, endpoint := range seen {
+		revoked, err := getRevokedKey(key)
+		if err != nil {
+			continue
+		}
+		if isPublicKeyInUse(key) {
+			if err := database.DeleteRecord(database.REVOKED_KEYS_TABLE_NAME, key); err != nil {
+				slog.Warn("failed to forget revoked key back in use", "kind", revoked.Kind, "id", revoked.ID, "error", err)
+			}
+			continue
+		}
+		if !shouldAlertRevokedKey(key) {
+			continue
+		}
+		event := models.SecurityEvent{
+			Kind:       models.SecurityEventRevokedKeyReuse,
+			Network:    gateway.Network,
+			GatewayID:  gateway.ID.String(),
+			SourceIP:   metricSourceIP(endpoint),
+			RevokedKey: &revoked,
+			Message:    fmt.Sprintf("%s %s %s on %s attempted a handshake", revoked.Reason, revoked.Kind, revoked.ID, gateway.Network),
+		}
+		if host, err := GetHost(gateway.HostID.String()); err == nil {
+			event.GatewayHost = host.Name
+			event.Message += " with " + host.Name
+		}
+		if event.SourceIP != "" {
+			event.Message += " from " + event.SourceIP
+		}
+		RaiseSecurityEvent(event)
+	}
+}
+
+// RaiseSecurityEvent - stores a security alert, ships it to the SIEM endpoint and posts it to the security webhook
+func RaiseSecurityEvent(event models.SecurityEvent) {
+	event.ID = uuid.New().String()
+	event.Time = time.Now().UTC()
+	if network, err := GetNetwork(event.Network); err == nil {
+		event.Tenant = network.Tenant
+	}
+	slog.Warn("security alert", "kind", event.Kind, "network", event.Network, "message", event.Message)
+	if data, err := json.Marshal(event); err == nil {
+		if err := database.Insert(event.ID, string(data), database.SECURITY_EVENTS_TABLE_NAME); err != nil {
+			slog.Error("failed to store security event", "kind", event.Kind, "error", err)
+		}
+	}
+	ShipSIEMEvent(models.SIEMEvent{
+		Time:    event.Time,
+		Kind:    SIEMEventSecurity,
+		Name:    event.Message,
+		Source:  event.SourceIP,
+		Tenant:  event.Tenant,
+		Outcome: "failure",
+	})
+	if webhook := servercfg.GetSecurityAlertWebhook(); webhook != "" {
+		go postAlert(webhook, "security event "+event.Kind, event)
+	}
+	go Notify(models.Notification{
+		Event:    models.NotificationEventSecurity,
+		Severity: models.NotificationAlert,
+		Title:    "security event " + event.Kind,
+		Text:     event.Message,
+		Network:  event.Network,
+		Tenant:   event.Tenant,
+		Fields:   []models.NotificationField{{Name: "network", Value: event.Network}, {Name: "source", Value: event.SourceIP}},
+		Time:     event.Time,
+	}, nil)
+}
+
+// GetSecurityEvents - the security alerts raised since a time, newest first, at most limit of them,
+// only those of a tenant's networks when tenant is set
+func GetSecurityEvents(since time.Time, limit int, tenant string) ([]models.SecurityEvent, error) {
+	events := []models.SecurityEvent{}
+	records, err := database.FetchRecords(database.SECURITY_EVENTS_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return events, nil
+		}
+		return nil, err
+	}
+	for _, value := range records {
+		var event models.SecurityEvent
+		if err := json.Unmarshal([]byte(value), &event); err != nil || event.Time.Before(since) {
+			continue
+		}
+		if tenant != "" && event.Tenant != tenant {
+			continue
+		}
+		events = append(events, event)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Time.After(events[j].Time)
+	})
+	if limit <= 0 {
+		limit = defaultSecurityEventLimit
+	}
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+// == private ==
+
+func revokeKey(revoked models.RevokedKey) {
+	if revoked.PublicKey == "" {
+		return
+	}
+	revoked.Revoked = time.Now().UTC()
+	data, err := json.Marshal(revoked)
+	if err != nil {
+		return
+	}
+	if err := database.Insert(revoked.PublicKey, string(data), database.REVOKED_KEYS_TABLE_NAME); err != nil {
+		slog.Error("failed to record revoked key", "kind", revoked.Kind, "id", revoked.ID, "error", err)
+	}
+}
+
+func getRevokedKey(publicKey string) (models.RevokedKey, error) {
+	var revoked models.RevokedKey
+	record, err := database.FetchRecord(database.REVOKED_KEYS_TABLE_NAME, publicKey)
+	if err != nil {
+		return revoked, err
+	}
+	err = json.Unmarshal([]byte(record), &revoked)
+	return revoked, err
+}
+
+// isPublicKeyInUse - whether an enabled ext client or a host currently has a public key
+func isPublicKeyInUse(publicKey string) bool {
+	if clients, err := GetAllExtClients(); err == nil {
+		for _, client := range clients {
+			if client.PublicKey == publicKey && client.Enabled {
+				return true
+			}
+		}
+	}
+	if hosts, err := GetAllHosts(); err == nil {
+		for _, host := range hosts {
+			// a quarantined host keeps its record but not its key
+			if host.PublicKey.String() == publicKey && !IsHostQuarantined(host.ID.String()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// shouldAlertRevokedKey - whether the reuse of a revoked key wasn't alerted on recently
+func shouldAlertRevokedKey(publicKey string) bool {
+	revokedKeyAlertMutex.Lock()
+	defer revokedKeyAlertMutex.Unlock()
+	if last, ok := revokedKeyAlerts[publicKey]; ok && time.Since(last) < revokedKeyAlertInterval {
+		return false
+	}
+	revokedKeyAlerts[publicKey] = time.Now()
+	return true
+}
+
+// pruneSecurityEvents - removes the security alerts and revoked keys past their retention periods
+func pruneSecurityEvents() error {
+	now := time.Now().UTC()
+	if records, err := database.FetchRecords(database.SECURITY_EVENTS_TABLE_NAME); err == nil {
+		cutoff := now.AddDate(0, 0, -securityEventRetentionDays)
+		for key, value := range records {
+			var event models.SecurityEvent
+			if err := json.Unmarshal([]byte(value), &event); err != nil || event.Time.Before(cutoff) {
+				if err := database.DeleteRecord(database.SECURITY_EVENTS_TABLE_NAME, key); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	records, err := database.FetchRecords(database.REVOKED_KEYS_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return nil
+		}
+		return err
+	}
+	cutoff := now.AddDate(0, 0, -revokedKeyRetentionDays)
+	for key, value := range records {
+		var revoked models.RevokedKey
+		if err := json.Unmarshal([]byte(value), &revoked); err != nil || revoked.Revoked.Before(cutoff) {
+			if err := database.DeleteRecord(database.REVOKED_KEYS_TABLE_NAME, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
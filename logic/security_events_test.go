@@ -0,0 +1,30 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckRevokedKeyReuse(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	revoked := models.ExtClient{ClientID: "stolen", Network: "securitytest", PublicKey: "revokedpublickey"}
+	RevokeExtClientKey(&revoked, "deleted")
+	gateway := models.Node{CommonNode: models.CommonNode{ID: uuid.New(), Network: "securitytest"}}
+	metrics := models.Metrics{UnknownPeers: map[string]string{"revokedpublickey": "203.0.113.4:51820", "neverseen": "203.0.113.5:51820"}}
+	since := time.Now().Add(-time.Second)
+	CheckRevokedKeyReuse(&gateway, &metrics)
+	// the same key is only alerted on once an interval
+	CheckRevokedKeyReuse(&gateway, &metrics)
+	events, err := GetSecurityEvents(since, 0, "")
+	assert.Nil(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, models.SecurityEventRevokedKeyReuse, events[0].Kind)
+	assert.Equal(t, "203.0.113.4", events[0].SourceIP)
+	assert.Equal(t, "stolen", events[0].RevokedKey.ID)
+}
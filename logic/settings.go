@@ -0,0 +1,125 @@
+package logic
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
+)
+
+// serverSettingsKey - key of the record holding the runtime server settings in the server conf table
+const serverSettingsKey = "nm-server-settings"
+
+// serverSettingsPollInterval - how often settings stored by other servers are read back when the
+// database can't stream changes
+const serverSettingsPollInterval = 30 * time.Second
+
+var (
+	settingsMutex sync.Mutex
+	// appliedSettings - the stored settings record in effect, so an unchanged one isn't applied again
+	appliedSettings string
+	settingsHooks   []func(previous models.ServerSettings)
+)
+
+// OnServerSettingsChange - registers fn to run once settings stored through the settings API are applied
+// on this server, whichever server stored them; fn is given the settings in effect before
+func OnServerSettingsChange(fn func(previous models.ServerSettings)) {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+	settingsHooks = append(settingsHooks, fn)
+}
+
+// LoadServerSettings - applies the server settings stored through the settings API, if any
+func LoadServerSettings() error {
+	record, err := database.FetchRecord(database.SERVERCONF_TABLE_NAME, serverSettingsKey)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return nil
+		}
+		return err
+	}
+	return applyServerSettings(record)
+}
+
+// GetServerSettings - returns the effective server settings, their secrets are removed when responded with
+func GetServerSettings() models.ServerSettings {
+	return servercfg.GetSettings()
+}
+
+// UpdateServerSettings - validates, stores and applies new server settings,
+// secrets left empty keep their current value
+func UpdateServerSettings(settings *models.ServerSettings) error {
+	current := servercfg.GetSettings()
+	if settings.SMTP.Password == "" {
+		settings.SMTP.Password = current.SMTP.Password
+	}
+	if settings.OAuth.ClientSecret == "" && settings.OAuth.Provider == current.OAuth.Provider {
+		settings.OAuth.ClientSecret = current.OAuth.ClientSecret
+	}
+	if settings.OPA.Token == "" && settings.OPA.URL == current.OPA.URL {
+		settings.OPA.Token = current.OPA.Token
+	}
+	if err := validator.New().Struct(settings); err != nil {
+		return err
+	}
+	if settings.SIEM.CACert != "" && !x509.NewCertPool().AppendCertsFromPEM([]byte(settings.SIEM.CACert)) {
+		return ErrSIEMCACert
+	}
+	if err := ValidateCORSSettings(&settings.CORS, &settings.SecurityHeaders); err != nil {
+		return err
+	}
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	if err = database.Insert(serverSettingsKey, string(data), database.SERVERCONF_TABLE_NAME); err != nil {
+		return err
+	}
+	return applyServerSettings(string(data))
+}
+
+// == private ==
+
+// applyServerSettings - puts a stored settings record in effect and runs the change hooks, unless it already is
+func applyServerSettings(record string) error {
+	var settings models.ServerSettings
+	if err := json.Unmarshal([]byte(record), &settings); err != nil {
+		return err
+	}
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+	if record == appliedSettings {
+		return nil
+	}
+	previous := servercfg.GetSettings()
+	servercfg.SetSettings(&settings)
+	appliedSettings = record
+	for _, fn := range settingsHooks {
+		fn(previous)
+	}
+	return nil
+}
+
+// pollServerSettings - applies the settings stored by other servers until ctx is done,
+// for databases that can't stream changes
+func pollServerSettings(ctx context.Context) {
+	ticker := time.NewTicker(serverSettingsPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := LoadServerSettings(); err != nil {
+				slog.Error("failed to reload server settings", "error", err)
+			}
+		}
+	}
+}
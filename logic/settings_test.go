@@ -0,0 +1,48 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyServerSettings(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	defer servercfg.SetSettings(nil)
+	defer func(hooks []func(models.ServerSettings)) {
+		settingsHooks = hooks
+		appliedSettings = ""
+	}(settingsHooks)
+	var changes []models.ServerSettings
+	OnServerSettingsChange(func(previous models.ServerSettings) {
+		changes = append(changes, previous)
+	})
+
+	t.Run("StoredByAnotherServer", func(t *testing.T) {
+		// written straight to the database, as another server would
+		record := `{"telemetry":"off","default_mtu":1400,"rate_limit":{"requests_per_second":5,"burst":10}}`
+		assert.Nil(t, database.Insert(serverSettingsKey, record, database.SERVERCONF_TABLE_NAME))
+		assert.Nil(t, LoadServerSettings())
+		assert.Equal(t, 1, len(changes))
+		assert.Equal(t, 5.0, servercfg.GetRateLimit().RequestsPerSecond)
+		// applied once, however often it's read back
+		assert.Nil(t, LoadServerSettings())
+		assert.Equal(t, 1, len(changes))
+	})
+	t.Run("Updated", func(t *testing.T) {
+		settings := servercfg.GetSettings()
+		settings.RateLimit.RequestsPerSecond = 20
+		assert.Nil(t, UpdateServerSettings(&settings))
+		assert.Equal(t, 2, len(changes))
+		assert.Equal(t, 5.0, changes[1].RateLimit.RequestsPerSecond)
+		assert.Equal(t, 20.0, servercfg.GetRateLimit().RequestsPerSecond)
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		assert.NotNil(t, applyServerSettings("not json"))
+		assert.Equal(t, 2, len(changes))
+	})
+}
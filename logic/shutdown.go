@@ -0,0 +1,55 @@
+package logic
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/exp/slog"
+)
+
+var (
+	draining atomic.Bool
+
+	shutdownHooksMutex sync.Mutex
+	shutdownHooks      []shutdownHook
+)
+
+// shutdownHook - a checkpoint run by a background job before the server exits
+type shutdownHook struct {
+	name string
+	hook func() error
+}
+
+// SetDraining - marks the server as shutting down so it stops reporting ready
+func SetDraining() {
+	draining.Store(true)
+}
+
+// IsDraining - reports whether the server is shutting down
+func IsDraining() bool {
+	return draining.Load()
+}
+
+// AddShutdownHook - registers a function that saves the state of a background job on shutdown
+func AddShutdownHook(name string, hook func() error) {
+	shutdownHooksMutex.Lock()
+	defer shutdownHooksMutex.Unlock()
+	shutdownHooks = append(shutdownHooks, shutdownHook{name: name, hook: hook})
+}
+
+// RunShutdownHooks - runs the registered shutdown hooks in order, stopping early if ctx expires
+func RunShutdownHooks(ctx context.Context) {
+	shutdownHooksMutex.Lock()
+	hooks := append([]shutdownHook{}, shutdownHooks...)
+	shutdownHooksMutex.Unlock()
+	for _, h := range hooks {
+		if ctx.Err() != nil {
+			slog.InfoCtx(ctx, "shutdown grace period expired before running hook", "name", h.name)
+			return
+		}
+		if err := h.hook(); err != nil {
+			slog.ErrorCtx(ctx, "shutdown hook failed", "name", h.name, "error", err)
+		}
+	}
+}
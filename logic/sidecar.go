@@ -0,0 +1,166 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gravitl/netmaker/logic/pro"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// DefaultSidecarLease - how long a sidecar registration lasts when no lease is asked for
+	DefaultSidecarLease = time.Hour
+	// MaxSidecarLease - the longest lease a sidecar can be given at once
+	MaxSidecarLease = 7 * 24 * time.Hour
+)
+
+var (
+	// ErrSidecarNetwork - sidecars may only join the networks of their enrollment key
+	ErrSidecarNetwork = errors.New("network is not one of the enrollment key's networks")
+	// ErrNoSidecarGateway - sidecars are unmanaged peers that connect through an ingress gateway
+	ErrNoSidecarGateway = errors.New("network has no ingress gateway for sidecars to connect through")
+	// ErrInvalidSidecarLease - the lease asked for is negative or too long
+	ErrInvalidSidecarLease = fmt.Errorf("invalid lease, must be at most %d seconds", int64(MaxSidecarLease.Seconds()))
+	// ErrNotSidecar - the ext client wasn't registered by a sidecar
+	ErrNotSidecar = errors.New("ext client is not a sidecar")
+)
+
+// RegisterSidecar - adds a sidecar container as an unmanaged peer of a network's ingress gateway,
+// it is deleted when its lease runs out unless renewed
+func RegisterSidecar(key *models.EnrollmentKey, request *models.SidecarRequest) (models.ExtClient, error) {
+	lease, err := sidecarLease(request.Lease)
+	if err != nil {
+		return models.ExtClient{}, err
+	}
+	network := request.Network
+	if network == "" && len(key.Networks) > 0 {
+		network = key.Networks[0]
+	}
+	if !StringSliceContains(key.Networks, network) {
+		return models.ExtClient{}, ErrSidecarNetwork
+	}
+	gateway, err := sidecarGateway(network)
+	if err != nil {
+		return models.ExtClient{}, err
+	}
+	host, err := GetHost(gateway.HostID.String())
+	if err != nil {
+		return models.ExtClient{}, err
+	}
+	client := models.ExtClient{
+		ClientID:               request.Name,
+		PublicKey:              request.PublicKey,
+		Network:                network,
+		IngressGatewayID:       gateway.ID.String(),
+		IngressGatewayEndpoint: fmt.Sprintf("%s:%d", host.EndpointIP.String(), GetPeerListenPort(host)),
+		Enabled:                true,
+		Sidecar:                true,
+		LeaseExpiry:            time.Now().Add(lease).Unix(),
+	}
+	if parentNetwork, err := GetNetwork(network); err == nil {
+		client.Enabled = parentNetwork.DefaultACL == "yes"
+	}
+	if err := SetClientDefaultACLs(&client); err != nil {
+		return models.ExtClient{}, err
+	}
+	if err := CreateExtClient(&client); err != nil {
+		return models.ExtClient{}, err
+	}
+	return client, nil
+}
+
+// RenewSidecarLease - extends the lease of a sidecar registered with key
+func RenewSidecarLease(key *models.EnrollmentKey, clientID string, request *models.SidecarRequest) (models.ExtClient, error) {
+	lease, err := sidecarLease(request.Lease)
+	if err != nil {
+		return models.ExtClient{}, err
+	}
+	client, err := GetExtClientByName(clientID)
+	if err != nil {
+		return models.ExtClient{}, err
+	}
+	if !client.Sidecar {
+		return models.ExtClient{}, ErrNotSidecar
+	}
+	if !StringSliceContains(key.Networks, client.Network) {
+		return models.ExtClient{}, ErrSidecarNetwork
+	}
+	client.LeaseExpiry = time.Now().Add(lease).Unix()
+	if err := SaveExtClient(&client); err != nil {
+		return models.ExtClient{}, err
+	}
+	return client, nil
+}
+
+// DeleteExpiredExtClients - deletes the sidecars and remote access clients whose lease ran out, returning them
+func DeleteExpiredExtClients() []models.ExtClient {
+	deleted := []models.ExtClient{}
+	clients, err := GetAllExtClients()
+	if err != nil {
+		return deleted
+	}
+	now := time.Now().Unix()
+	for _, client := range clients {
+		if !(client.Sidecar || client.RemoteAccess) || client.LeaseExpiry > now {
+			continue
+		}
+		if err := DeleteExtClient(client.Network, client.ClientID); err != nil {
+			slog.Error("failed to delete expired ext client", "clientid", client.ClientID, "network", client.Network, "error", err)
+			continue
+		}
+		RevokeExtClientKey(&client, "expired")
+		if client.OwnerID != "" {
+			if err := pro.DissociateNetworkUserClient(client.OwnerID, client.Network, client.ClientID); err != nil {
+				slog.Warn("failed to dissociate expired ext client from its user", "clientid", client.ClientID, "user", client.OwnerID, "error", err)
+			}
+		}
+		slog.Info("deleted ext client after its lease expired", "clientid", client.ClientID, "network", client.Network, "sidecar", client.Sidecar)
+		deleted = append(deleted, client)
+	}
+	if len(deleted) > 0 && servercfg.IsDNSMode() {
+		SetDNS()
+	}
+	return deleted
+}
+
+// == private ==
+
+// sidecarLease - the lease asked for in seconds, DefaultSidecarLease when zero
+func sidecarLease(seconds int64) (time.Duration, error) {
+	lease := time.Duration(seconds) * time.Second
+	if lease < 0 || lease > MaxSidecarLease {
+		return 0, ErrInvalidSidecarLease
+	}
+	if lease == 0 {
+		lease = DefaultSidecarLease
+	}
+	return lease, nil
+}
+
+// sidecarGateway - the ingress gateway sidecars of a network connect through, a connected one if possible
+func sidecarGateway(network string) (models.Node, error) {
+	nodes, err := GetNetworkNodes(network)
+	if err != nil {
+		return models.Node{}, err
+	}
+	var gateway *models.Node
+	for i := range nodes {
+		if !nodes[i].IsIngressGateway || nodes[i].PendingDelete {
+			continue
+		}
+		if nodes[i].Connected {
+			return nodes[i], nil
+		}
+		if gateway == nil {
+			gateway = &nodes[i]
+		}
+	}
+	if gateway == nil {
+		return models.Node{}, ErrNoSidecarGateway
+	}
+	return *gateway, nil
+}
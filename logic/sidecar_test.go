@@ -0,0 +1,21 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSidecarLease(t *testing.T) {
+	lease, err := sidecarLease(0)
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultSidecarLease, lease)
+	lease, err = sidecarLease(300)
+	assert.Nil(t, err)
+	assert.Equal(t, 5*time.Minute, lease)
+	_, err = sidecarLease(-1)
+	assert.ErrorIs(t, err, ErrInvalidSidecarLease)
+	_, err = sidecarLease(int64(MaxSidecarLease.Seconds()) + 1)
+	assert.ErrorIs(t, err, ErrInvalidSidecarLease)
+}
@@ -0,0 +1,258 @@
+package logic
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
+)
+
+// kinds of events shipped to the SIEM endpoint
+const (
+	// SIEMEventAudit - a change a user made through the api
+	SIEMEventAudit = "audit"
+	// SIEMEventAuth - a user signing in
+	SIEMEventAuth = "auth"
+	// SIEMEventSecurity - a security alert raised by the server
+	SIEMEventSecurity = "security"
+)
+
+const (
+	// siemQueueSize - events waiting to be shipped before new ones are dropped
+	siemQueueSize = 1000
+	// siemTimeout - how long connecting to or writing to the SIEM endpoint may take
+	siemTimeout = 10 * time.Second
+	// siemHeartbeatInterval - how often the shipper reports in while no events arrive
+	siemHeartbeatInterval = time.Minute
+	// syslog facilities (RFC 5424) of authentication and audit events
+	syslogFacilityAuthPriv = 10
+	syslogFacilityAudit    = 13
+	// syslog severities (RFC 5424) of successful and failed events
+	syslogSeverityInfo    = 6
+	syslogSeverityWarning = 4
+)
+
+// ErrSIEMCACert - the CA certificate of the SIEM endpoint isn't valid PEM
+var ErrSIEMCACert = errors.New("invalid siem ca certificate")
+
+var siemEvents = make(chan models.SIEMEvent, siemQueueSize)
+
+// ShipSIEMEvent - queues an event for the SIEM endpoint, it is dropped when shipping is off or the queue is full
+func ShipSIEMEvent(event models.SIEMEvent) {
+	if servercfg.GetSIEMSettings().Address == "" {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	select {
+	case siemEvents <- event:
+	default:
+		slog.Warn("siem queue is full, dropping event", "kind", event.Kind, "name", event.Name)
+	}
+}
+
+// RecordAuthEvent - ships a user's attempt to sign in with basic auth or an oauth provider,
+// successful ones are checked for coming from a new device
+func RecordAuthEvent(username string, r *http.Request, provider string, success bool) {
+	event := models.SIEMEvent{
+		Kind:    SIEMEventAuth,
+		Name:    "login via " + provider,
+		User:    username,
+		Tenant:  GetUserTenant(username),
+		Source:  siemSource(r.RemoteAddr),
+		Outcome: "success",
+	}
+	if !success {
+		event.Outcome = "failure"
+	}
+	ShipSIEMEvent(event)
+	if success {
+		go RecordUserLogin(username, sourceIPString(r), r.UserAgent(), provider)
+	}
+}
+
+// StartSIEMShipping - sends queued events to the SIEM endpoint until ctx is done,
+// reconnecting whenever the SIEM settings change
+func StartSIEMShipping(ctx context.Context, wg *sync.WaitGroup) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		var shipper siemShipper
+		defer shipper.close()
+		for {
+			WorkerHeartbeat("siem", siemHeartbeatInterval)
+			select {
+			case <-ctx.Done():
+				StopWorker("siem")
+				return
+			case event := <-siemEvents:
+				if err := shipper.ship(event); err != nil {
+					slog.Error("failed to ship event to siem", "kind", event.Kind, "name", event.Name, "error", err)
+				}
+			case <-time.After(siemHeartbeatInterval):
+			}
+		}
+	}()
+}
+
+// == private ==
+
+// siemShipper - the connection to the SIEM endpoint and the settings it was made with
+type siemShipper struct {
+	conn     net.Conn
+	settings models.SIEMSettings
+}
+
+// ship - writes an event to the SIEM endpoint, reconnecting once if the connection broke
+func (s *siemShipper) ship(event models.SIEMEvent) error {
+	settings := servercfg.GetSIEMSettings()
+	if settings.Address == "" {
+		s.close()
+		return nil
+	}
+	if s.conn != nil && settings != s.settings {
+		s.close()
+	}
+	message, err := formatSIEMEvent(settings, event)
+	if err != nil {
+		return err
+	}
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			if s.conn, err = dialSIEM(settings); err != nil {
+				return err
+			}
+			s.settings = settings
+		}
+		s.conn.SetWriteDeadline(time.Now().Add(siemTimeout))
+		if _, err = s.conn.Write(message); err == nil {
+			return nil
+		}
+		s.close()
+	}
+	return err
+}
+
+func (s *siemShipper) close() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// dialSIEM - connects to the SIEM endpoint over tls (the default), tcp or udp
+func dialSIEM(settings models.SIEMSettings) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: siemTimeout}
+	switch settings.Protocol {
+	case "tcp", "udp":
+		return dialer.Dial(settings.Protocol, settings.Address)
+	}
+	host, _, err := net.SplitHostPort(settings.Address)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	if settings.CACert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(settings.CACert)) {
+			return nil, ErrSIEMCACert
+		}
+		config.RootCAs = pool
+	}
+	return tls.DialWithDialer(dialer, "tcp", settings.Address, config)
+}
+
+// formatSIEMEvent - an RFC 5424 syslog message carrying the event as json, or as CEF when that format is set,
+// newline terminated on streams
+func formatSIEMEvent(settings models.SIEMSettings, event models.SIEMEvent) ([]byte, error) {
+	facility, severity := syslogFacilityAudit, syslogSeverityInfo
+	if event.Kind == SIEMEventAuth || event.Kind == SIEMEventSecurity {
+		facility = syslogFacilityAuthPriv
+	}
+	if event.Outcome != "success" {
+		severity = syslogSeverityWarning
+	}
+	var body string
+	if settings.Format == "cef" {
+		body = formatCEF(event)
+	} else {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return nil, err
+		}
+		body = string(data)
+	}
+	message := fmt.Sprintf("<%d>1 %s %s netmaker - %s - %s", facility*8+severity, event.Time.UTC().Format(time.RFC3339Nano), siemHostname(), event.Kind, body)
+	if settings.Protocol != "udp" {
+		message += "\n"
+	}
+	return []byte(message), nil
+}
+
+// formatCEF - an event in ArcSight Common Event Format
+func formatCEF(event models.SIEMEvent) string {
+	severity := 3
+	if event.Outcome != "success" {
+		severity = 7
+	}
+	extensions := []string{
+		"rt=" + strconv.FormatInt(event.Time.UnixMilli(), 10),
+		"suser=" + cefExtensionEscape(event.User),
+		"outcome=" + event.Outcome,
+	}
+	if event.Source != "" {
+		extensions = append(extensions, "src="+cefExtensionEscape(event.Source))
+	}
+	if event.Method != "" {
+		extensions = append(extensions, "requestMethod="+event.Method, "request="+cefExtensionEscape(event.Path))
+	}
+	if event.Status != 0 {
+		extensions = append(extensions, "cn1Label=status", "cn1="+strconv.Itoa(event.Status))
+	}
+	if event.Tenant != "" {
+		extensions = append(extensions, "cs1Label=tenant", "cs1="+cefExtensionEscape(event.Tenant))
+	}
+	return fmt.Sprintf("CEF:0|Netmaker|Netmaker|%s|%s|%s|%d|%s",
+		cefHeaderEscape(servercfg.GetVersion()), event.Kind, cefHeaderEscape(event.Name), severity, strings.Join(extensions, " "))
+}
+
+func cefHeaderEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `|`, `\|`).Replace(value)
+}
+
+func cefExtensionEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`).Replace(value)
+}
+
+// siemHostname - the HOSTNAME of syslog messages, the server name if one is set
+func siemHostname() string {
+	if server := servercfg.GetServer(); server != "" {
+		return server
+	}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		return hostname
+	}
+	return "-"
+}
+
+// siemSource - the ip of a request's remote address
+func siemSource(remoteAddr string) string {
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		return host
+	}
+	return remoteAddr
+}
@@ -0,0 +1,39 @@
+package logic
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatSIEMEvent(t *testing.T) {
+	event := models.SIEMEvent{
+		Time:    time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC),
+		Kind:    SIEMEventAudit,
+		Name:    "DELETE /api/networks/net|1",
+		User:    "admin",
+		Source:  "198.51.100.7",
+		Outcome: "failure",
+		Method:  "DELETE",
+		Path:    "/api/networks/net|1",
+		Status:  403,
+	}
+	t.Run("Syslog", func(t *testing.T) {
+		message, err := formatSIEMEvent(models.SIEMSettings{Protocol: "tcp"}, event)
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(string(message), "<108>1 2023-05-01T12:00:00Z "))
+		assert.Contains(t, string(message), `"user":"admin"`)
+		assert.True(t, strings.HasSuffix(string(message), "\n"))
+	})
+	t.Run("CEF", func(t *testing.T) {
+		message, err := formatSIEMEvent(models.SIEMSettings{Protocol: "udp", Format: "cef"}, event)
+		assert.Nil(t, err)
+		assert.Contains(t, string(message), `|audit|DELETE /api/networks/net\|1|7|`)
+		assert.Contains(t, string(message), "suser=admin outcome=failure src=198.51.100.7 requestMethod=DELETE")
+		assert.Contains(t, string(message), "cn1Label=status cn1=403")
+		assert.False(t, strings.HasSuffix(string(message), "\n"))
+	})
+}
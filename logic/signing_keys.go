@@ -0,0 +1,207 @@
+package logic
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/netclient/ncutils"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// signingKeyGrace - how long a retired signing key still verifies, so hosts that missed a rotation can pull
+	signingKeyGrace = 7 * 24 * time.Hour
+	// signingKeyRefresh - how often the active signing key is read again, to follow a rotation on another server
+	signingKeyRefresh   = time.Minute
+	signingKeysLockName = "signing_keys"
+)
+
+// signingKeyRecord - a signing key as stored, the private key is encrypted at rest
+type signingKeyRecord struct {
+	models.SigningKey
+	PrivateKey []byte `json:"private_key"`
+}
+
+var (
+	signingKeyMutex  sync.Mutex
+	signingKeyCache  *signingKeyRecord
+	signingKeyLoaded time.Time
+)
+
+// GetSigningKeys - the keys hosts verify the messages of the server with: the active key and the retired keys
+// that haven't expired, the latest first; the first key is created when there is none
+func GetSigningKeys() ([]models.SigningKey, error) {
+	if _, err := getActiveSigningKey(); err != nil {
+		return nil, err
+	}
+	records, err := getSigningKeyRecords()
+	if err != nil {
+		return nil, err
+	}
+	keys := []models.SigningKey{}
+	for _, record := range records {
+		if !signingKeyExpired(&record.SigningKey) {
+			keys = append(keys, record.SigningKey)
+		}
+	}
+	return keys, nil
+}
+
+// RotateSigningKey - creates a new active signing key, retiring the active one and removing the retired keys that
+// expired; hosts get the new key when they pull, which they do on a message signed with a key they don't know
+func RotateSigningKey() (models.SigningKey, error) {
+	var key models.SigningKey
+	err := WithLock(signingKeysLockName, func(fence *Fence) error {
+		records, err := getSigningKeyRecords()
+		if err != nil {
+			return err
+		}
+		if err := fence.Check(); err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		for i := range records {
+			if signingKeyExpired(&records[i].SigningKey) {
+				if err := database.DeleteRecord(database.SIGNING_KEYS_TABLE_NAME, records[i].ID); err != nil {
+					return err
+				}
+				continue
+			}
+			if records[i].Retired.IsZero() {
+				records[i].Retired = now
+				if err := saveSigningKey(&records[i]); err != nil {
+					return err
+				}
+			}
+		}
+		record, err := createSigningKey()
+		if err != nil {
+			return err
+		}
+		key = record.SigningKey
+		return nil
+	})
+	if err != nil {
+		return key, err
+	}
+	signingKeyMutex.Lock()
+	signingKeyCache = nil
+	signingKeyMutex.Unlock()
+	slog.Warn("rotated signing key", "key", key.ID)
+	return key, nil
+}
+
+// SignMessage - wraps a message published to a host on topic with the signature of the active signing key
+func SignMessage(topic string, payload []byte) ([]byte, error) {
+	record, err := getActiveSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	signed := models.SignedMessage{
+		KeyID:   record.ID,
+		Topic:   topic,
+		Time:    time.Now().UnixNano(),
+		Nonce:   hex.EncodeToString(nonce),
+		Payload: payload,
+	}
+	signed.Signature = ed25519.Sign(ed25519.PrivateKey(record.PrivateKey), ncutils.SigningInput(signed.Topic, signed.Time, signed.Nonce, signed.Payload))
+	return json.Marshal(&signed)
+}
+
+// == private ==
+
+// getActiveSigningKey - the key messages are signed with, created when there is none
+func getActiveSigningKey() (*signingKeyRecord, error) {
+	signingKeyMutex.Lock()
+	defer signingKeyMutex.Unlock()
+	if signingKeyCache != nil && time.Since(signingKeyLoaded) < signingKeyRefresh {
+		return signingKeyCache, nil
+	}
+	var active *signingKeyRecord
+	err := WithLock(signingKeysLockName, func(fence *Fence) error {
+		records, err := getSigningKeyRecords()
+		if err != nil {
+			return err
+		}
+		for i := range records {
+			if records[i].Retired.IsZero() {
+				active = &records[i]
+				return nil
+			}
+		}
+		if err := fence.Check(); err != nil {
+			return err
+		}
+		active, err = createSigningKey()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	signingKeyCache, signingKeyLoaded = active, time.Now()
+	return active, nil
+}
+
+// getSigningKeyRecords - every signing key, the latest first
+func getSigningKeyRecords() ([]signingKeyRecord, error) {
+	records := []signingKeyRecord{}
+	values, err := database.FetchRecords(database.SIGNING_KEYS_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return records, nil
+		}
+		return nil, err
+	}
+	for _, value := range values {
+		var record signingKeyRecord
+		if err := json.Unmarshal([]byte(value), &record); err != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Created.After(records[j].Created)
+	})
+	return records, nil
+}
+
+func createSigningKey() (*signingKeyRecord, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(public)
+	record := &signingKeyRecord{
+		SigningKey: models.SigningKey{
+			ID:        hex.EncodeToString(sum[:8]),
+			PublicKey: public,
+			Created:   time.Now().UTC(),
+		},
+		PrivateKey: private,
+	}
+	return record, saveSigningKey(record)
+}
+
+func saveSigningKey(record *signingKeyRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return database.Insert(record.ID, string(data), database.SIGNING_KEYS_TABLE_NAME)
+}
+
+func signingKeyExpired(key *models.SigningKey) bool {
+	return !key.Retired.IsZero() && time.Since(key.Retired) > signingKeyGrace
+}
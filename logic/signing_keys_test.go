@@ -0,0 +1,104 @@
+package logic
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/netclient/ncutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSigningKeys(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	database.DeleteAllRecords(database.SIGNING_KEYS_TABLE_NAME)
+	defer database.DeleteAllRecords(database.SIGNING_KEYS_TABLE_NAME)
+	signingKeyCache = nil
+	defer func() { signingKeyCache = nil }()
+	verifyKeys := func() map[string]ed25519.PublicKey {
+		keys, err := GetSigningKeys()
+		assert.Nil(t, err)
+		verify := map[string]ed25519.PublicKey{}
+		for _, key := range keys {
+			verify[key.ID] = key.PublicKey
+		}
+		return verify
+	}
+	keys := verifyKeys()
+	assert.Len(t, keys, 1)
+	const topic = "host/update/netmaker/h1"
+	msg, err := SignMessage(topic, []byte(`{"action":"pull"}`))
+	assert.Nil(t, err)
+	guard := ncutils.NewReplayGuard()
+	payload, err := ncutils.VerifySignedMessage(topic, msg, keys, guard)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"action":"pull"}`, string(payload))
+
+	t.Run("Replayed", func(t *testing.T) {
+		_, err := ncutils.VerifySignedMessage(topic, msg, keys, guard)
+		assert.ErrorIs(t, err, ncutils.ErrReplayedMessage)
+	})
+	t.Run("OutOfOrder", func(t *testing.T) {
+		first, err := SignMessage(topic, []byte(`{"action":"first"}`))
+		assert.Nil(t, err)
+		second, err := SignMessage(topic, []byte(`{"action":"second"}`))
+		assert.Nil(t, err)
+		_, err = ncutils.VerifySignedMessage(topic, second, keys, guard)
+		assert.Nil(t, err)
+		_, err = ncutils.VerifySignedMessage(topic, first, keys, guard)
+		assert.Nil(t, err, "an older message arriving late is accepted once")
+		_, err = ncutils.VerifySignedMessage(topic, first, keys, guard)
+		assert.ErrorIs(t, err, ncutils.ErrReplayedMessage)
+	})
+	t.Run("Stale", func(t *testing.T) {
+		record, err := getActiveSigningKey()
+		assert.Nil(t, err)
+		signed := models.SignedMessage{KeyID: record.ID, Topic: topic, Time: time.Now().Add(-2 * ncutils.ReplayWindow).UnixNano(), Nonce: "stale", Payload: []byte(`{}`)}
+		signed.Signature = ed25519.Sign(ed25519.PrivateKey(record.PrivateKey), ncutils.SigningInput(signed.Topic, signed.Time, signed.Nonce, signed.Payload))
+		stale, _ := json.Marshal(&signed)
+		_, err = ncutils.VerifySignedMessage(topic, stale, keys, ncutils.NewReplayGuard())
+		assert.ErrorIs(t, err, ncutils.ErrReplayedMessage, "a message older than the window can't be told from a replay")
+	})
+	t.Run("OtherTopic", func(t *testing.T) {
+		_, err := ncutils.VerifySignedMessage("host/update/netmaker/h2", msg, keys, ncutils.NewReplayGuard())
+		assert.ErrorIs(t, err, ncutils.ErrInvalidSignature)
+		var signed models.SignedMessage
+		assert.Nil(t, json.Unmarshal(msg, &signed))
+		signed.Topic = "host/update/netmaker/h2"
+		moved, _ := json.Marshal(&signed)
+		_, err = ncutils.VerifySignedMessage(signed.Topic, moved, keys, ncutils.NewReplayGuard())
+		assert.ErrorIs(t, err, ncutils.ErrInvalidSignature, "the signature covers the topic")
+	})
+	t.Run("Tampered", func(t *testing.T) {
+		var signed models.SignedMessage
+		assert.Nil(t, json.Unmarshal(msg, &signed))
+		signed.Payload = []byte(`{"action":"delete"}`)
+		tampered, _ := json.Marshal(&signed)
+		_, err := ncutils.VerifySignedMessage(topic, tampered, keys, ncutils.NewReplayGuard())
+		assert.ErrorIs(t, err, ncutils.ErrInvalidSignature)
+		_, err = ncutils.VerifySignedMessage(topic, []byte(`{"action":"delete"}`), keys, ncutils.NewReplayGuard())
+		assert.ErrorIs(t, err, ncutils.ErrInvalidSignature, "unsigned messages are refused")
+	})
+	t.Run("Rotate", func(t *testing.T) {
+		key, err := RotateSigningKey()
+		assert.Nil(t, err)
+		rotated, err := SignMessage(topic, []byte(`{}`))
+		assert.Nil(t, err)
+		_, err = ncutils.VerifySignedMessage(topic, rotated, keys, guard)
+		assert.ErrorIs(t, err, ncutils.ErrUnknownSigningKey, "hosts pull on a key they don't know")
+		current := verifyKeys()
+		assert.Len(t, current, 2, "the retired key still verifies")
+		_, err = ncutils.VerifySignedMessage(topic, rotated, current, guard)
+		assert.Nil(t, err)
+		_, err = ncutils.VerifySignedMessage(topic, msg, current, ncutils.NewReplayGuard())
+		assert.Nil(t, err)
+		all, err := GetSigningKeys()
+		assert.Nil(t, err)
+		assert.Equal(t, key.ID, all[0].ID)
+		assert.True(t, all[0].Retired.IsZero())
+	})
+}
@@ -0,0 +1,249 @@
+package logic
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
+)
+
+// ErrNoGeoIP - countries can only be allowed when the server has a geoip file to look them up in
+var ErrNoGeoIP = errors.New("source countries need a geoip file configured on the server")
+
+var (
+	sourcePolicyMutex sync.Mutex
+	// flaggedSources - the source last flagged for each ext client, so a connection is reported once
+	flaggedSources = map[string]string{}
+
+	geoIPMutex  sync.Mutex
+	geoIPFile   string
+	geoIPRanges []geoIPRange
+)
+
+type geoIPRange struct {
+	cidr    *net.IPNet
+	country string
+}
+
+// GetSourcePolicy - the source policy of a user, the error is a not found error when they have none
+func GetSourcePolicy(username string) (models.SourcePolicy, error) {
+	var policy models.SourcePolicy
+	record, err := database.FetchRecord(database.SOURCE_POLICIES_TABLE_NAME, username)
+	if err != nil {
+		return policy, err
+	}
+	err = json.Unmarshal([]byte(record), &policy)
+	return policy, err
+}
+
+// SetSourcePolicy - validates and stores the source policy of a user,
+// returns the networks whose peers change because the user's blocked ext clients were let back in
+func SetSourcePolicy(policy *models.SourcePolicy) ([]string, error) {
+	if err := validator.New().Struct(policy); err != nil {
+		return nil, err
+	}
+	cidrs := []string{}
+	for _, cidr := range policy.AllowedCIDRs {
+		normalized, err := NormalizeCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		cidrs = append(cidrs, normalized)
+	}
+	policy.AllowedCIDRs = cidrs
+	countries := []string{}
+	for _, country := range policy.AllowedCountries {
+		country = strings.ToUpper(strings.TrimSpace(country))
+		if len(country) != 2 {
+			return nil, fmt.Errorf("invalid country code %q, must be two letters", country)
+		}
+		countries = append(countries, country)
+	}
+	policy.AllowedCountries = countries
+	if len(countries) > 0 && servercfg.GetGeoIPFile() == "" {
+		return nil, ErrNoGeoIP
+	}
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return nil, err
+	}
+	if err := database.Insert(policy.User, string(data), database.SOURCE_POLICIES_TABLE_NAME); err != nil {
+		return nil, err
+	}
+	return unblockUserExtClients(policy.User), nil
+}
+
+// DeleteSourcePolicy - lets a user's ext clients connect from anywhere again,
+// returns the networks whose peers change because blocked clients were let back in
+func DeleteSourcePolicy(username string) ([]string, error) {
+	if err := database.DeleteRecord(database.SOURCE_POLICIES_TABLE_NAME, username); err != nil && !database.IsEmptyRecord(err) {
+		return nil, err
+	}
+	return unblockUserExtClients(username), nil
+}
+
+// CheckSourcePolicies - checks the sources a gateway sees the ext clients of users connect from against their policies,
+// records violations and blocks the clients of block policies, returns whether a client was blocked
+func CheckSourcePolicies(gateway *models.Node, metrics *models.Metrics) bool {
+	if !gateway.IsIngressGateway || metrics == nil {
+		return false
+	}
+	clients, err := GetGatewayExtClients(gateway.ID.String(), gateway.Network)
+	if err != nil {
+		return false
+	}
+	policies := map[string]*models.SourcePolicy{}
+	blocked := false
+	for i := range clients {
+		client := &clients[i]
+		metric, ok := metrics.Connectivity[client.ClientID]
+		if client.OwnerID == "" || client.BlockedSource != "" || !ok || !metric.Connected || metric.Endpoint == "" {
+			continue
+		}
+		policy, ok := policies[client.OwnerID]
+		if !ok {
+			if p, err := GetSourcePolicy(client.OwnerID); err == nil {
+				policy = &p
+			}
+			policies[client.OwnerID] = policy
+		}
+		if policy == nil {
+			continue
+		}
+		source := metricSourceIP(metric.Endpoint)
+		allowed, country := isSourceAllowed(policy, net.ParseIP(source))
+		if !markSourceFlagged(client.ClientID, source, allowed) {
+			continue
+		}
+		message := fmt.Sprintf("ext client %s of user %s connected from %s", client.ClientID, client.OwnerID, source)
+		if country != "" {
+			message += " (" + country + ")"
+		}
+		if policy.Action == models.SourcePolicyBlock {
+			client.BlockedSource = source
+			if err := SaveExtClient(client); err != nil {
+				slog.Error("failed to block ext client for its source", "clientid", client.ClientID, "source", source, "error", err)
+				continue
+			}
+			message += ", blocked"
+			blocked = true
+		}
+		slog.Warn("ext client connected from a source its user's policy doesn't allow", "clientid", client.ClientID, "user", client.OwnerID, "source", source, "country", country, "action", policy.Action)
+		RecordNetworkEvent(client.Network, models.NetworkEventSourcePolicy, gateway, message)
+	}
+	return blocked
+}
+
+// == private ==
+
+// isSourceAllowed - whether a source ip matches the cidrs or countries of a policy, with the country it's in if known
+func isSourceAllowed(policy *models.SourcePolicy, ip net.IP) (bool, string) {
+	if ip == nil {
+		return true, ""
+	}
+	for _, cidr := range policy.AllowedCIDRs {
+		if _, network, err := net.ParseCIDR(cidr); err == nil && network.Contains(ip) {
+			return true, ""
+		}
+	}
+	country := sourceCountry(ip)
+	return country != "" && StringSliceContains(policy.AllowedCountries, country), country
+}
+
+// markSourceFlagged - remembers the source flagged for a client, false when it's allowed or was already flagged
+func markSourceFlagged(clientID, source string, allowed bool) bool {
+	sourcePolicyMutex.Lock()
+	defer sourcePolicyMutex.Unlock()
+	if allowed {
+		delete(flaggedSources, clientID)
+		return false
+	}
+	if flaggedSources[clientID] == source {
+		return false
+	}
+	flaggedSources[clientID] = source
+	return true
+}
+
+// unblockUserExtClients - lets the blocked ext clients of a user back on their gateways after their policy changed
+func unblockUserExtClients(username string) []string {
+	networks := []string{}
+	clients, err := GetAllExtClients()
+	if err != nil {
+		return networks
+	}
+	for i := range clients {
+		client := &clients[i]
+		if client.OwnerID != username {
+			continue
+		}
+		sourcePolicyMutex.Lock()
+		delete(flaggedSources, client.ClientID)
+		sourcePolicyMutex.Unlock()
+		if client.BlockedSource == "" {
+			continue
+		}
+		client.BlockedSource = ""
+		if err := SaveExtClient(client); err != nil {
+			slog.Error("failed to unblock ext client", "clientid", client.ClientID, "user", username, "error", err)
+			continue
+		}
+		if !StringSliceContains(networks, client.Network) {
+			networks = append(networks, client.Network)
+		}
+	}
+	return networks
+}
+
+// sourceCountry - the country code of an ip in the geoip file, empty when unknown
+func sourceCountry(ip net.IP) string {
+	geoIPMutex.Lock()
+	defer geoIPMutex.Unlock()
+	if file := servercfg.GetGeoIPFile(); file != geoIPFile {
+		geoIPFile = file
+		geoIPRanges = loadGeoIPRanges(file)
+	}
+	for _, r := range geoIPRanges {
+		if r.cidr.Contains(ip) {
+			return r.country
+		}
+	}
+	return ""
+}
+
+// loadGeoIPRanges - reads the cidr,country code lines of a geoip file, skipping the ones it can't parse
+func loadGeoIPRanges(file string) []geoIPRange {
+	ranges := []geoIPRange{}
+	if file == "" {
+		return ranges
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		slog.Error("failed to open geoip file", "file", file, "error", err)
+		return ranges
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		cidr, country, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ",")
+		if !ok {
+			continue
+		}
+		_, network, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			continue
+		}
+		ranges = append(ranges, geoIPRange{cidr: network, country: strings.ToUpper(strings.TrimSpace(country))})
+	}
+	return ranges
+}
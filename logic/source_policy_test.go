@@ -0,0 +1,35 @@
+package logic
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsSourceAllowed(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "geoip.csv")
+	assert.Nil(t, os.WriteFile(file, []byte("203.0.113.0/24,de\n198.51.100.0/24,US\nnot a line\n"), 0600))
+	t.Setenv("GEOIP_FILE", file)
+	policy := models.SourcePolicy{AllowedCIDRs: []string{"192.0.2.0/24"}, AllowedCountries: []string{"DE"}, Action: models.SourcePolicyFlag}
+	allowed, _ := isSourceAllowed(&policy, net.ParseIP("192.0.2.10"))
+	assert.True(t, allowed)
+	allowed, country := isSourceAllowed(&policy, net.ParseIP("203.0.113.9"))
+	assert.True(t, allowed)
+	assert.Equal(t, "DE", country)
+	allowed, country = isSourceAllowed(&policy, net.ParseIP("198.51.100.1"))
+	assert.False(t, allowed)
+	assert.Equal(t, "US", country)
+	allowed, country = isSourceAllowed(&policy, net.ParseIP("100.64.0.1"))
+	assert.False(t, allowed)
+	assert.Empty(t, country)
+	t.Run("FlaggedOnce", func(t *testing.T) {
+		assert.True(t, markSourceFlagged("flagged", "198.51.100.1", false))
+		assert.False(t, markSourceFlagged("flagged", "198.51.100.1", false))
+		assert.False(t, markSourceFlagged("flagged", "192.0.2.10", true))
+		assert.True(t, markSourceFlagged("flagged", "198.51.100.1", false))
+	})
+}
@@ -0,0 +1,252 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"sort"
+	"time"
+
+	validator "github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic/acls"
+	"github.com/gravitl/netmaker/logic/acls/nodeacls"
+	"github.com/gravitl/netmaker/models"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+var (
+	// ErrInvalidStaticPeerKey - the public key of a static peer isn't a wireguard key
+	ErrInvalidStaticPeerKey = errors.New("invalid public key")
+	// ErrDuplicateStaticPeerKey - a host, ext client or static peer already uses the public key
+	ErrDuplicateStaticPeerKey = errors.New("public key is already in use")
+)
+
+// CreateStaticPeer - adds a wireguard device to a network, allocating its addresses,
+// nodes may reach it unless the network's default ACL denies them
+func CreateStaticPeer(peer *models.StaticPeer) error {
+	if err := validator.New().Struct(peer); err != nil {
+		return err
+	}
+	if _, err := wgtypes.ParseKey(peer.PublicKey); err != nil {
+		return ErrInvalidStaticPeerKey
+	}
+	network, err := GetNetwork(peer.Network)
+	if err != nil {
+		return err
+	}
+	if staticPeerKeyInUse(peer.PublicKey) {
+		return ErrDuplicateStaticPeerKey
+	}
+	// lock because addresses must be unique across nodes, ext clients and static peers
+	unlock, err := Lock(addressLockName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	peer.Address, peer.Address6 = "", ""
+	if network.IsIPv4 == "yes" {
+		address, err := UniqueAddress(peer.Network, false)
+		if err != nil {
+			return err
+		}
+		defer releaseAddress(peer.Network, address.String())
+		peer.Address = address.String()
+	}
+	if network.IsIPv6 == "yes" {
+		address6, err := UniqueAddress6(peer.Network, false)
+		if err != nil {
+			return err
+		}
+		defer releaseAddress(peer.Network, address6.String())
+		peer.Address6 = address6.String()
+	}
+	peer.ID = uuid.New().String()
+	peer.LastModified = time.Now().UTC()
+	if err := saveStaticPeer(peer); err != nil {
+		return err
+	}
+	defaultACL := acls.Allowed
+	if network.DefaultACL != "yes" {
+		defaultACL = acls.NotAllowed
+	}
+	if _, err := nodeacls.CreateNodeACL(nodeacls.NetworkID(peer.Network), nodeacls.NodeID(peer.ID), defaultACL); err != nil {
+		return err
+	}
+	RecordNetworkEvent(peer.Network, models.NetworkEventJoin, nil, "static peer "+peer.Name+" added")
+	return nil
+}
+
+// GetStaticPeer - fetches a static peer of a network
+func GetStaticPeer(network, id string) (models.StaticPeer, error) {
+	var peer models.StaticPeer
+	data, err := database.FetchRecord(database.STATIC_PEERS_TABLE_NAME, id)
+	if err != nil {
+		return peer, err
+	}
+	if err := json.Unmarshal([]byte(data), &peer); err != nil {
+		return peer, err
+	}
+	if peer.Network != network {
+		return models.StaticPeer{}, errors.New("static peer not found")
+	}
+	return peer, nil
+}
+
+// GetNetworkStaticPeers - fetches the static peers of a network, sorted by name
+func GetNetworkStaticPeers(network string) ([]models.StaticPeer, error) {
+	peers := []models.StaticPeer{}
+	records, err := database.FetchRecords(database.STATIC_PEERS_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return peers, nil
+		}
+		return peers, err
+	}
+	for _, value := range records {
+		var peer models.StaticPeer
+		if err := json.Unmarshal([]byte(value), &peer); err != nil || peer.Network != network {
+			continue
+		}
+		peers = append(peers, peer)
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].Name < peers[j].Name
+	})
+	return peers, nil
+}
+
+// DeleteStaticPeer - removes a static peer and its ACL from its network
+func DeleteStaticPeer(peer *models.StaticPeer) error {
+	if _, err := nodeacls.RemoveNodeACL(nodeacls.NetworkID(peer.Network), nodeacls.NodeID(peer.ID)); err != nil && !database.IsEmptyRecord(err) {
+		return err
+	}
+	if err := database.DeleteRecord(database.STATIC_PEERS_TABLE_NAME, peer.ID); err != nil {
+		return err
+	}
+	RecordNetworkEvent(peer.Network, models.NetworkEventLeave, nil, "static peer "+peer.Name+" removed")
+	return nil
+}
+
+// == private ==
+
+func saveStaticPeer(peer *models.StaticPeer) error {
+	data, err := json.Marshal(peer)
+	if err != nil {
+		return err
+	}
+	return database.Insert(peer.ID, string(data), database.STATIC_PEERS_TABLE_NAME)
+}
+
+// deleteNetworkStaticPeers - removes the static peers of a deleted network
+func deleteNetworkStaticPeers(network string) error {
+	peers, err := GetNetworkStaticPeers(network)
+	if err != nil {
+		return err
+	}
+	for _, peer := range peers {
+		if err := database.DeleteRecord(database.STATIC_PEERS_TABLE_NAME, peer.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// staticPeerKeyInUse - checks if a host, ext client or static peer already has a public key
+func staticPeerKeyInUse(publicKey string) bool {
+	if hosts, err := GetAllHosts(); err == nil {
+		for _, host := range hosts {
+			if host.PublicKey.String() == publicKey {
+				return true
+			}
+		}
+	}
+	if clients, err := GetAllExtClients(); err == nil {
+		for _, client := range clients {
+			if client.PublicKey == publicKey {
+				return true
+			}
+		}
+	}
+	if records, err := database.FetchRecords(database.STATIC_PEERS_TABLE_NAME); err == nil {
+		for _, value := range records {
+			var peer models.StaticPeer
+			if err := json.Unmarshal([]byte(value), &peer); err == nil && peer.PublicKey == publicKey {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// staticPeerAllowed - checks the network ACL lets a node and a static peer talk
+func staticPeerAllowed(peer *models.StaticPeer, node *models.Node) bool {
+	return nodeacls.AreNodesAllowed(nodeacls.NetworkID(peer.Network), nodeacls.NodeID(node.ID.String()), nodeacls.NodeID(peer.ID))
+}
+
+// getStaticPeers - the static peers of a node's network as wireguard peers of the node,
+// those the ACL denies are given no allowed ips so they get removed
+func getStaticPeers(node *models.Node) ([]wgtypes.PeerConfig, []models.IDandAddr) {
+	var peers []wgtypes.PeerConfig
+	var idsAndAddr []models.IDandAddr
+	staticPeers, err := GetNetworkStaticPeers(node.Network)
+	if err != nil {
+		return peers, idsAndAddr
+	}
+	for i := range staticPeers {
+		staticPeer := staticPeers[i]
+		if staticPeer.PendingNetclient {
+			continue
+		}
+		pubkey, err := wgtypes.ParseKey(staticPeer.PublicKey)
+		if err != nil {
+			continue
+		}
+		peer := wgtypes.PeerConfig{
+			PublicKey:         pubkey,
+			ReplaceAllowedIPs: true,
+		}
+		if staticPeer.Endpoint != "" {
+			if endpoint, err := net.ResolveUDPAddr("udp", staticPeer.Endpoint); err == nil {
+				peer.Endpoint = endpoint
+			}
+		}
+		if staticPeer.PersistentKeepalive > 0 {
+			keepalive := time.Duration(staticPeer.PersistentKeepalive) * time.Second
+			peer.PersistentKeepaliveInterval = &keepalive
+		}
+		if staticPeerAllowed(&staticPeer, node) {
+			peer.AllowedIPs = staticPeerAllowedIPs(&staticPeer)
+		}
+		peers = append(peers, peer)
+		primaryAddr := staticPeer.Address
+		if primaryAddr == "" {
+			primaryAddr = staticPeer.Address6
+		}
+		idsAndAddr = append(idsAndAddr, models.IDandAddr{
+			ID:      staticPeer.ID,
+			Name:    staticPeer.Name,
+			Address: primaryAddr,
+			Network: staticPeer.Network,
+		})
+	}
+	return peers, idsAndAddr
+}
+
+// staticPeerAllowedIPs - a static peer's addresses and the ranges behind it
+func staticPeerAllowedIPs(peer *models.StaticPeer) []net.IPNet {
+	var allowedips []net.IPNet
+	if ip := net.ParseIP(peer.Address); ip != nil {
+		allowedips = append(allowedips, net.IPNet{IP: ip, Mask: net.CIDRMask(32, 32)})
+	}
+	if ip := net.ParseIP(peer.Address6); ip != nil {
+		allowedips = append(allowedips, net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)})
+	}
+	for _, allowedIP := range peer.AllowedIPs {
+		if _, cidr, err := net.ParseCIDR(allowedIP); err == nil {
+			allowedips = append(allowedips, *cidr)
+		}
+	}
+	return allowedips
+}
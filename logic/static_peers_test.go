@@ -0,0 +1,21 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaticPeerAllowedIPs(t *testing.T) {
+	peer := models.StaticPeer{
+		Address:    "10.0.0.9",
+		Address6:   "fd00::9",
+		AllowedIPs: []string{"192.168.1.0/24", "not-a-range"},
+	}
+	allowedIPs := []string{}
+	for _, allowedIP := range staticPeerAllowedIPs(&peer) {
+		allowedIPs = append(allowedIPs, allowedIP.String())
+	}
+	assert.Equal(t, []string{"10.0.0.9/32", "fd00::9/128", "192.168.1.0/24"}, allowedIPs)
+}
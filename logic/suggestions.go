@@ -0,0 +1,179 @@
+package logic
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+)
+
+// ErrSuggestionNotFound - the suggestion to apply isn't suggested anymore, eg. the metrics it was based on changed
+var ErrSuggestionNotFound = errors.New("suggestion not found, the latest metrics may not support it anymore")
+
+// GetNetworkSuggestions - the relays and failovers that would help the nodes of a network that can't reach some of
+// their peers, from the latest metrics: the best candidate for a node reaches most of the peers the node can't,
+// and then has the lowest latency to it
+func GetNetworkSuggestions(network string) ([]models.NodeSuggestion, error) {
+	nodes, err := GetNetworkNodes(network)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return nil, err
+	}
+	metrics := map[string]*models.Metrics{}
+	linux := map[string]bool{}
+	for i := range nodes {
+		if m, err := GetMetrics(nodes[i].ID.String()); err == nil {
+			metrics[nodes[i].ID.String()] = m
+		}
+		if host, err := GetHost(nodes[i].HostID.String()); err == nil {
+			linux[nodes[i].ID.String()] = host.OS == "linux"
+		}
+	}
+	return suggestNodes(nodes, metrics, linux), nil
+}
+
+// ApplySuggestion - makes the candidate of a suggestion the relay of its node, or a failover of the network
+func ApplySuggestion(network, id string) (models.NodeSuggestion, models.Node, error) {
+	suggestions, err := GetNetworkSuggestions(network)
+	if err != nil {
+		return models.NodeSuggestion{}, models.Node{}, err
+	}
+	var suggestion *models.NodeSuggestion
+	for i := range suggestions {
+		if suggestions[i].ID == id {
+			suggestion = &suggestions[i]
+		}
+	}
+	if suggestion == nil {
+		return models.NodeSuggestion{}, models.Node{}, ErrSuggestionNotFound
+	}
+	candidate, err := GetNodeByID(suggestion.CandidateID)
+	if err != nil {
+		return *suggestion, models.Node{}, err
+	}
+	switch suggestion.Kind {
+	case models.SuggestionRelay:
+		if err := CheckNetworkFeature(network, models.NetworkFeatureRelays); err != nil {
+			return *suggestion, candidate, err
+		}
+		if !candidate.IsRelay {
+			_, candidate, err = CreateRelay(models.RelayRequest{NetID: network, NodeID: candidate.ID.String(), RelayedNodes: []string{suggestion.NodeID}})
+			return *suggestion, candidate, err
+		}
+		relayed := append(append([]string{}, candidate.RelayedNodes...), suggestion.NodeID)
+		UpdateRelayed(candidate.ID.String(), candidate.RelayedNodes, relayed)
+		candidate.RelayedNodes = relayed
+	case models.SuggestionFailover:
+		candidate.Failover = true
+	}
+	candidate.SetLastModified()
+	if err := UpsertNode(&candidate); err != nil {
+		return *suggestion, candidate, err
+	}
+	if suggestion.Kind == models.SuggestionFailover && EnterpriseResetFailoverFunc != nil {
+		if err := EnterpriseResetFailoverFunc(network); err != nil {
+			return *suggestion, candidate, err
+		}
+	}
+	RecordNetworkEvent(network, models.NetworkEventGateway, &candidate, "applied "+suggestion.Kind+" suggestion for "+suggestion.NodeName)
+	return *suggestion, candidate, nil
+}
+
+// == private ==
+
+// suggestNodes - the best relay and failover candidates of every node that can't reach some of its peers,
+// linux tells which nodes are on linux hosts, the only ones that can relay
+func suggestNodes(nodes []models.Node, metrics map[string]*models.Metrics, linux map[string]bool) []models.NodeSuggestion {
+	suggestions := []models.NodeSuggestion{}
+	for i := range nodes {
+		node := &nodes[i]
+		nodeMetrics, ok := metrics[node.ID.String()]
+		if !ok || node.PendingDelete {
+			continue
+		}
+		unreachable := []string{}
+		for peerID, metric := range nodeMetrics.Connectivity {
+			if !metric.Connected {
+				unreachable = append(unreachable, peerID)
+			}
+		}
+		if len(unreachable) == 0 {
+			continue
+		}
+		if !node.IsRelayed && !node.IsIngressGateway {
+			if suggestion, ok := bestCandidate(node, nodes, metrics, unreachable, func(candidate *models.Node) bool {
+				return !candidate.IsRelayed && !candidate.Ephemeral && linux[candidate.ID.String()]
+			}); ok {
+				suggestion.Kind = models.SuggestionRelay
+				suggestions = append(suggestions, suggestion)
+			}
+		}
+		if !hasConnectedFailover(nodeMetrics, nodes) {
+			if suggestion, ok := bestCandidate(node, nodes, metrics, unreachable, func(candidate *models.Node) bool {
+				return candidate.IsIngressGateway && !candidate.Failover && !candidate.Ephemeral
+			}); ok {
+				suggestion.Kind = models.SuggestionFailover
+				suggestions = append(suggestions, suggestion)
+			}
+		}
+	}
+	for i := range suggestions {
+		suggestions[i].ID = suggestions[i].Kind + "_" + suggestions[i].NodeID + "_" + suggestions[i].CandidateID
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Unreachable > suggestions[j].Unreachable
+	})
+	return suggestions
+}
+
+// bestCandidate - of the eligible nodes the node is connected to, the one reaching most of its unreachable peers,
+// then the closest to it
+func bestCandidate(node *models.Node, nodes []models.Node, metrics map[string]*models.Metrics, unreachable []string, eligible func(*models.Node) bool) (models.NodeSuggestion, bool) {
+	nodeMetrics := metrics[node.ID.String()]
+	var best models.NodeSuggestion
+	found := false
+	for i := range nodes {
+		candidate := &nodes[i]
+		if candidate.ID == node.ID || candidate.PendingDelete || !eligible(candidate) {
+			continue
+		}
+		link, ok := nodeMetrics.Connectivity[candidate.ID.String()]
+		candidateMetrics, hasMetrics := metrics[candidate.ID.String()]
+		if !ok || !link.Connected || !hasMetrics {
+			continue
+		}
+		reachable := 0
+		for _, peerID := range unreachable {
+			if peerID != candidate.ID.String() && candidateMetrics.Connectivity[peerID].Connected {
+				reachable++
+			}
+		}
+		if reachable == 0 {
+			continue
+		}
+		if found && (reachable < best.Reachable || (reachable == best.Reachable && link.Latency >= best.Latency)) {
+			continue
+		}
+		found = true
+		best = models.NodeSuggestion{
+			NodeID:        node.ID.String(),
+			NodeName:      nodeMetrics.NodeName,
+			CandidateID:   candidate.ID.String(),
+			CandidateName: link.NodeName,
+			Latency:       link.Latency,
+			Unreachable:   len(unreachable),
+			Reachable:     reachable,
+		}
+	}
+	return best, found
+}
+
+// hasConnectedFailover - whether the node is connected to a failover of its network already
+func hasConnectedFailover(nodeMetrics *models.Metrics, nodes []models.Node) bool {
+	for i := range nodes {
+		if nodes[i].Failover && nodeMetrics.Connectivity[nodes[i].ID.String()].Connected {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuggestNodes(t *testing.T) {
+	poor := models.Node{CommonNode: models.CommonNode{ID: uuid.New()}}
+	far := models.Node{CommonNode: models.CommonNode{ID: uuid.New()}}
+	near := models.Node{CommonNode: models.CommonNode{ID: uuid.New()}}
+	best := models.Node{CommonNode: models.CommonNode{ID: uuid.New(), IsIngressGateway: true}}
+	nodes := []models.Node{poor, far, near, best}
+	metrics := map[string]*models.Metrics{
+		poor.ID.String(): {NodeName: "poor", Connectivity: map[string]models.Metric{
+			far.ID.String():  {NodeName: "far", Connected: false},
+			near.ID.String(): {NodeName: "near", Connected: true, Latency: 5},
+			best.ID.String(): {NodeName: "best", Connected: true, Latency: 20},
+		}},
+		far.ID.String():  {Connectivity: map[string]models.Metric{poor.ID.String(): {Connected: false}}},
+		near.ID.String(): {Connectivity: map[string]models.Metric{far.ID.String(): {Connected: true}}},
+		best.ID.String(): {Connectivity: map[string]models.Metric{far.ID.String(): {Connected: true}}},
+	}
+	linux := map[string]bool{near.ID.String(): true, best.ID.String(): true}
+
+	t.Run("Closest", func(t *testing.T) {
+		suggestions := suggestNodes(nodes, metrics, linux)
+		assert.Equal(t, 2, len(suggestions))
+		assert.Equal(t, models.SuggestionRelay, suggestions[0].Kind)
+		assert.Equal(t, near.ID.String(), suggestions[0].CandidateID, "the closest relay reaching the peer wins")
+		assert.Equal(t, int64(5), suggestions[0].Latency)
+		assert.Equal(t, models.SuggestionFailover, suggestions[1].Kind)
+		assert.Equal(t, best.ID.String(), suggestions[1].CandidateID, "only ingress gateways can fail over")
+	})
+	t.Run("NotLinux", func(t *testing.T) {
+		suggestions := suggestNodes(nodes, metrics, map[string]bool{best.ID.String(): true})
+		assert.Equal(t, best.ID.String(), suggestions[0].CandidateID)
+	})
+	t.Run("ConnectedFailover", func(t *testing.T) {
+		withFailover := []models.Node{poor, far, near, best}
+		withFailover[3].Failover = true
+		suggestions := suggestNodes(withFailover, metrics, linux)
+		assert.Equal(t, 1, len(suggestions))
+		assert.Equal(t, models.SuggestionRelay, suggestions[0].Kind)
+	})
+	t.Run("Relayed", func(t *testing.T) {
+		relayed := []models.Node{poor, far, near, best}
+		relayed[0].IsRelayed = true
+		for _, suggestion := range suggestNodes(relayed, metrics, linux) {
+			assert.NotEqual(t, models.SuggestionRelay, suggestion.Kind)
+		}
+	})
+}
@@ -0,0 +1,397 @@
+package logic
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic/acls/nodeacls"
+	"github.com/gravitl/netmaker/models"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+var (
+	// ErrInvalidTailnetExport - the import has no export matching its source
+	ErrInvalidTailnetExport = errors.New("source must be tailscale or headscale, with the export of that source")
+	// ErrTailnetAddressRange - the network to import into doesn't exist and no address range was given to create it
+	ErrTailnetAddressRange = errors.New("addressrange is required to create the network")
+)
+
+// ImportTailnet - recreates a Tailscale or Headscale tailnet as a network: users become users of it,
+// devices become static peers pending a netclient install, approved subnet routes the ranges routed to them,
+// and the ACL policy allows or denies each pair of imported devices. Everything that couldn't be
+// converted as is gets a mapping saying why.
+func ImportTailnet(request *models.TailnetImportRequest) (models.TailnetImportResult, error) {
+	result := models.TailnetImportResult{
+		DryRun:   request.DryRun,
+		Network:  request.Network,
+		Mappings: []models.TailnetMapping{},
+	}
+	devices, users, mappings, err := readTailnetExport(request)
+	if err != nil {
+		return result, err
+	}
+	result.Mappings = append(result.Mappings, mappings...)
+	network, err := GetNetwork(request.Network)
+	if err != nil {
+		if !database.IsEmptyRecord(err) {
+			return result, err
+		}
+		if request.AddressRange == "" {
+			return result, ErrTailnetAddressRange
+		}
+		network = models.Network{NetID: request.Network, AddressRange: request.AddressRange}
+		if request.Policy != nil {
+			// like a tailnet with a policy, only what the policy accepts is allowed
+			network.DefaultACL = "no"
+		}
+		if !request.DryRun {
+			if network, err = CreateNetwork(network); err != nil {
+				return result, err
+			}
+		}
+		result.NetworkCreated = true
+		result.Mappings = append(result.Mappings, models.TailnetMapping{Kind: models.TailnetMappingNetwork, Source: request.Source, Target: network.NetID, Converted: true})
+	}
+	for _, user := range users {
+		result.Mappings = append(result.Mappings, importTailnetUser(network.NetID, user, request.DryRun))
+	}
+	routed := networkRoutedRanges(&network)
+	for _, device := range devices {
+		result.Mappings = append(result.Mappings, importTailnetDevice(network.NetID, device, routed, request.DryRun)...)
+	}
+	if request.Policy != nil {
+		result.Mappings = append(result.Mappings, applyTailnetPolicy(network.NetID, request.Policy, devices, request.DryRun)...)
+	}
+	return result, nil
+}
+
+// == private ==
+
+// tailnetDevice - a device of either source
+type tailnetDevice struct {
+	name      string
+	user      string
+	addresses []net.IP
+	tags      []string
+	nodeKey   string
+	routes    []string
+	// peer - the static peer the device became
+	peer *models.StaticPeer
+}
+
+type tailnetUser struct {
+	login string
+	admin bool
+}
+
+// readTailnetExport - the devices and users of the export of the request's source
+func readTailnetExport(request *models.TailnetImportRequest) ([]*tailnetDevice, []tailnetUser, []models.TailnetMapping, error) {
+	var devices []*tailnetDevice
+	var users []tailnetUser
+	var mappings []models.TailnetMapping
+	switch {
+	case request.Source == models.TailnetSourceTailscale && request.Tailscale != nil:
+		for _, user := range request.Tailscale.Users {
+			users = append(users, tailnetUser{login: user.LoginName, admin: user.Role == "owner" || user.Role == "admin"})
+		}
+		for _, d := range request.Tailscale.Devices {
+			device := &tailnetDevice{name: d.Hostname, user: d.User, tags: d.Tags, nodeKey: d.NodeKey, routes: d.EnabledRoutes}
+			if device.name == "" {
+				device.name, _, _ = strings.Cut(d.Name, ".")
+			}
+			device.addresses = parseTailnetAddresses(d.Addresses)
+			for _, route := range d.AdvertisedRoutes {
+				if !StringSliceContains(d.EnabledRoutes, route) {
+					mappings = append(mappings, models.TailnetMapping{Kind: models.TailnetMappingRoute, Source: device.name + " " + route, Reason: "advertised but never approved"})
+				}
+			}
+			devices = append(devices, device)
+		}
+	case request.Source == models.TailnetSourceHeadscale && request.Headscale != nil:
+		for _, user := range request.Headscale.Users {
+			users = append(users, tailnetUser{login: user.Name})
+		}
+		byID := map[uint64]*tailnetDevice{}
+		for _, n := range request.Headscale.Nodes {
+			device := &tailnetDevice{name: n.GivenName, user: n.User.Name, nodeKey: n.NodeKey}
+			if device.name == "" {
+				device.name = n.Name
+			}
+			device.tags = append(append(device.tags, n.ForcedTags...), n.ValidTags...)
+			device.addresses = parseTailnetAddresses(n.IPAddresses)
+			byID[n.ID] = device
+			devices = append(devices, device)
+		}
+		for _, route := range request.Headscale.Routes {
+			device, ok := byID[route.Node.ID]
+			if !ok || !route.Advertised {
+				continue
+			}
+			if !route.Enabled {
+				mappings = append(mappings, models.TailnetMapping{Kind: models.TailnetMappingRoute, Source: device.name + " " + route.Prefix, Reason: "advertised but never approved"})
+				continue
+			}
+			device.routes = append(device.routes, route.Prefix)
+		}
+	default:
+		return nil, nil, nil, ErrInvalidTailnetExport
+	}
+	return devices, users, mappings, nil
+}
+
+func parseTailnetAddresses(addresses []string) []net.IP {
+	var ips []net.IP
+	for _, address := range addresses {
+		if ip := net.ParseIP(address); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+// importTailnetUser - creates a user with access to the network, with a random password
+// so they log in through oauth or have an admin set one
+func importTailnetUser(network string, user tailnetUser, dryRun bool) models.TailnetMapping {
+	mapping := models.TailnetMapping{Kind: models.TailnetMappingUser, Source: user.login, Target: user.login}
+	if _, err := GetUser(user.login); err == nil {
+		mapping.Converted = true
+		mapping.Reason = "user already exists, its networks were left as they are"
+		return mapping
+	}
+	newUser := models.User{UserName: user.login, Password: RandomString(32), Networks: []string{network}, IsAdmin: user.admin}
+	if err := ValidateUser(&newUser); err != nil {
+		mapping.Target = ""
+		mapping.Reason = "not a valid username"
+		return mapping
+	}
+	if !dryRun {
+		if err := CreateUser(&newUser); err != nil {
+			mapping.Target = ""
+			mapping.Reason = err.Error()
+			return mapping
+		}
+	}
+	mapping.Converted = true
+	mapping.Reason = "created with a random password, log in through oauth or have an admin set one"
+	return mapping
+}
+
+// importTailnetDevice - creates the static peer holding a device's place until it joins with netclient
+func importTailnetDevice(network string, device *tailnetDevice, routed *routedRanges, dryRun bool) []models.TailnetMapping {
+	mapping := models.TailnetMapping{Kind: models.TailnetMappingDevice, Source: device.name}
+	mappings := []models.TailnetMapping{}
+	peer := models.StaticPeer{
+		Name:             staticPeerName(device.name),
+		Network:          network,
+		PublicKey:        tailnetPublicKey(device.nodeKey),
+		PendingNetclient: true,
+	}
+	for _, route := range device.routes {
+		routeMapping := models.TailnetMapping{Kind: models.TailnetMappingRoute, Source: device.name + " " + route}
+		cidr, err := parseAllowedIP(route)
+		if err != nil {
+			routeMapping.Reason = "not a cidr"
+		} else if ones, _ := cidr.Mask.Size(); ones == 0 {
+			routeMapping.Reason = "exit node routes aren't converted, use an internet gateway instead"
+		} else if reason := routed.clash(cidr); reason != "" {
+			routeMapping.Reason = reason
+		} else {
+			routed.add(cidr, "static peer "+peer.Name)
+			peer.AllowedIPs = append(peer.AllowedIPs, cidr.String())
+			routeMapping.Converted = true
+			routeMapping.Target = peer.Name
+		}
+		mappings = append(mappings, routeMapping)
+	}
+	if !dryRun {
+		if err := CreateStaticPeer(&peer); err != nil {
+			mapping.Reason = err.Error()
+			return append([]models.TailnetMapping{mapping}, mappings...)
+		}
+	}
+	device.peer = &peer
+	mapping.Converted = true
+	mapping.Target = peer.Name
+	if peer.ID != "" {
+		mapping.Target = peer.ID
+	}
+	mapping.Reason = "static peer pending netclient install, delete it once the device joins"
+	return append([]models.TailnetMapping{mapping}, mappings...)
+}
+
+// tailnetPublicKey - the wireguard key of a tailscale node key, such as nodekey:<hex>,
+// or a random one when it has none
+func tailnetPublicKey(nodeKey string) string {
+	if raw, err := hex.DecodeString(strings.TrimPrefix(nodeKey, "nodekey:")); err == nil && len(raw) == wgtypes.KeyLen {
+		if key, err := wgtypes.NewKey(raw); err == nil {
+			return key.String()
+		}
+	}
+	// only identifies the placeholder, it is never given to nodes
+	privateKey, _ := wgtypes.GeneratePrivateKey()
+	return privateKey.PublicKey().String()
+}
+
+// applyTailnetPolicy - allows each pair of imported devices an accept rule connects in either direction
+// and denies the rest, netmaker ACLs have neither direction nor ports
+func applyTailnetPolicy(network string, policy *models.TailnetPolicy, devices []*tailnetDevice, dryRun bool) []models.TailnetMapping {
+	mappings := []models.TailnetMapping{}
+	allowed := map[[2]int]bool{}
+	for _, rule := range policy.ACLs {
+		mapping := models.TailnetMapping{Kind: models.TailnetMappingACL, Source: strings.Join(rule.Src, ",") + " -> " + strings.Join(rule.Dst, ",")}
+		if rule.Action != "accept" {
+			mapping.Reason = "only accept rules can be converted"
+			mappings = append(mappings, mapping)
+			continue
+		}
+		var notes []string
+		var sources []int
+		for i, device := range devices {
+			for _, selector := range rule.Src {
+				matched, err := tailnetSelectorMatches(policy, selector, device)
+				if err != nil {
+					notes = appendUnique(notes, err.Error())
+				}
+				if matched {
+					sources = append(sources, i)
+					break
+				}
+			}
+		}
+		pairs := 0
+		for _, destination := range rule.Dst {
+			selector, ports := splitTailnetDestination(destination)
+			if ports != "*" {
+				notes = appendUnique(notes, "ports "+ports+" of "+selector+" widened to all ports")
+			}
+			for j, device := range devices {
+				var matched bool
+				var err error
+				if selector == "autogroup:self" {
+					matched = true
+				} else if matched, err = tailnetSelectorMatches(policy, selector, device); err != nil {
+					notes = appendUnique(notes, err.Error())
+				}
+				if !matched {
+					continue
+				}
+				for _, i := range sources {
+					if i == j || (selector == "autogroup:self" && devices[i].user != device.user) {
+						continue
+					}
+					pair := [2]int{i, j}
+					if j < i {
+						pair = [2]int{j, i}
+					}
+					if !allowed[pair] {
+						allowed[pair] = true
+						pairs++
+					}
+				}
+			}
+		}
+		mapping.Converted = true
+		mapping.Target = fmt.Sprintf("allows %d device pairs both ways", pairs)
+		mapping.Reason = strings.Join(notes, "; ")
+		mappings = append(mappings, mapping)
+	}
+	if len(policy.SSH) > 0 {
+		mappings = append(mappings, models.TailnetMapping{Kind: models.TailnetMappingACL, Source: "ssh", Reason: "tailscale ssh rules can't be converted"})
+	}
+	if dryRun {
+		return mappings
+	}
+	for i := range devices {
+		for j := i + 1; j < len(devices); j++ {
+			if devices[i].peer == nil || devices[j].peer == nil {
+				continue
+			}
+			a, b := nodeacls.NodeID(devices[i].peer.ID), nodeacls.NodeID(devices[j].peer.ID)
+			var err error
+			if allowed[[2]int{i, j}] {
+				_, err = nodeacls.AllowNodes(nodeacls.NetworkID(network), a, b)
+			} else {
+				_, err = nodeacls.DisallowNodes(nodeacls.NetworkID(network), a, b)
+			}
+			if err != nil {
+				mappings = append(mappings, models.TailnetMapping{Kind: models.TailnetMappingACL, Source: devices[i].name + " <-> " + devices[j].name, Reason: err.Error()})
+			}
+		}
+	}
+	return mappings
+}
+
+// tailnetSelectorMatches - checks if a src or dst selector of a policy matches a device,
+// erring for selectors that can't be converted
+func tailnetSelectorMatches(policy *models.TailnetPolicy, selector string, device *tailnetDevice) (bool, error) {
+	switch {
+	case selector == "*":
+		return true, nil
+	case selector == "autogroup:member":
+		return len(device.tags) == 0, nil
+	case selector == "autogroup:tagged":
+		return len(device.tags) > 0, nil
+	case strings.HasPrefix(selector, "autogroup:"):
+		return false, fmt.Errorf("%s can't be converted", selector)
+	case strings.HasPrefix(selector, "tag:"):
+		return StringSliceContains(device.tags, selector), nil
+	case strings.HasPrefix(selector, "group:"):
+		members, ok := policy.Groups[selector]
+		if !ok {
+			return false, fmt.Errorf("%s isn't defined", selector)
+		}
+		for _, member := range members {
+			if tailnetUserMatches(member, device.user) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	if host, ok := policy.Hosts[selector]; ok {
+		selector = host
+	}
+	if cidr, err := parseAllowedIP(selector); err == nil {
+		for _, address := range device.addresses {
+			if cidr.Contains(address) {
+				return true, nil
+			}
+		}
+		// a rule to a subnet allows the router of the subnet
+		for _, route := range device.routes {
+			if routeCIDR, err := parseAllowedIP(route); err == nil && (routeCIDR.Contains(cidr.IP) || cidr.Contains(routeCIDR.IP)) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return tailnetUserMatches(selector, device.user), nil
+}
+
+// tailnetUserMatches - headscale policies may name users with or without a trailing @
+func tailnetUserMatches(selector, user string) bool {
+	return user != "" && (selector == user || strings.TrimSuffix(selector, "@") == user)
+}
+
+// splitTailnetDestination - splits a dst such as tag:web:80,443 or [fd7a::1]:* into its selector and ports
+func splitTailnetDestination(destination string) (string, string) {
+	index := strings.LastIndex(destination, ":")
+	if index < 0 {
+		return destination, "*"
+	}
+	selector := strings.TrimSuffix(strings.TrimPrefix(destination[:index], "["), "]")
+	return selector, destination[index+1:]
+}
+
+func appendUnique(items []string, item string) []string {
+	if StringSliceContains(items, item) {
+		return items
+	}
+	items = append(items, item)
+	sort.Strings(items)
+	return items
+}
@@ -0,0 +1,61 @@
+package logic
+
+import (
+	"encoding/hex"
+	"net"
+	"testing"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestTailnetPublicKey(t *testing.T) {
+	privateKey, _ := wgtypes.GeneratePrivateKey()
+	publicKey := privateKey.PublicKey()
+	assert.Equal(t, publicKey.String(), tailnetPublicKey("nodekey:"+hex.EncodeToString(publicKey[:])))
+	placeholder := tailnetPublicKey("")
+	_, err := wgtypes.ParseKey(placeholder)
+	assert.Nil(t, err)
+}
+
+func TestApplyTailnetPolicy(t *testing.T) {
+	devices := []*tailnetDevice{
+		{name: "laptop", user: "alice@example.com", addresses: []net.IP{net.ParseIP("100.64.0.1")}},
+		{name: "phone", user: "alice@example.com", addresses: []net.IP{net.ParseIP("100.64.0.2")}},
+		{name: "web", tags: []string{"tag:web"}, addresses: []net.IP{net.ParseIP("100.64.0.3")}},
+		{name: "router", tags: []string{"tag:router"}, addresses: []net.IP{net.ParseIP("100.64.0.4")}, routes: []string{"192.168.1.0/24"}},
+		{name: "bob", user: "bob@example.com", addresses: []net.IP{net.ParseIP("100.64.0.5")}},
+	}
+	policy := &models.TailnetPolicy{
+		Groups: map[string][]string{"group:dev": {"bob@example.com"}},
+		ACLs: []models.TailnetACL{
+			{Action: "accept", Src: []string{"group:dev"}, Dst: []string{"tag:web:443"}},
+			{Action: "accept", Src: []string{"autogroup:member"}, Dst: []string{"autogroup:self:*"}},
+			{Action: "accept", Src: []string{"alice@example.com"}, Dst: []string{"192.168.1.10:22"}},
+			{Action: "accept", Src: []string{"autogroup:admin"}, Dst: []string{"*:*"}},
+		},
+	}
+	mappings := applyTailnetPolicy("skynet", policy, devices, true)
+	assert.Equal(t, 4, len(mappings))
+	assert.Equal(t, "allows 1 device pairs both ways", mappings[0].Target)
+	assert.Contains(t, mappings[0].Reason, "ports 443 of tag:web widened")
+	assert.Equal(t, "allows 1 device pairs both ways", mappings[1].Target)
+	assert.Equal(t, "allows 2 device pairs both ways", mappings[2].Target)
+	assert.Equal(t, "allows 0 device pairs both ways", mappings[3].Target)
+	assert.Contains(t, mappings[3].Reason, "autogroup:admin can't be converted")
+}
+
+func TestSplitTailnetDestination(t *testing.T) {
+	for destination, expected := range map[string][2]string{
+		"tag:web:80,443":   {"tag:web", "80,443"},
+		"*:*":              {"*", "*"},
+		"[fd7a::1]:*":      {"fd7a::1", "*"},
+		"10.0.0.0/8:22":    {"10.0.0.0/8", "22"},
+		"alice@example:*":  {"alice@example", "*"},
+		"group:dev:1-1024": {"group:dev", "1-1024"},
+	} {
+		selector, ports := splitTailnetDestination(destination)
+		assert.Equal(t, expected, [2]string{selector, ports}, destination)
+	}
+}
@@ -5,10 +5,10 @@ import (
 	"time"
 
 	"github.com/gravitl/netmaker/database"
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/servercfg"
 	"github.com/posthog/posthog-go"
+	"golang.org/x/exp/slog"
 )
 
 // flags to keep for telemetry
@@ -100,7 +100,7 @@ func fetchTelemetryData() (telemetryData, error) {
 func getServerCount() int {
 	data, err := database.FetchRecords(database.SERVER_UUID_TABLE_NAME)
 	if err != nil {
-		logger.Log(0, "errror retrieving server data", err.Error())
+		slog.Error("errror retrieving server data", "error", err)
 	}
 	return len(data)
 }
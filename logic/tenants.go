@@ -0,0 +1,177 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slices"
+)
+
+var (
+	// ErrTenantExists - a tenant with the same id already exists
+	ErrTenantExists = errors.New("tenant already exists")
+	// ErrTenantInUse - the tenant still owns networks or users
+	ErrTenantInUse = errors.New("tenant still has networks or users")
+	// ErrTenantMismatch - a resource was linked to a network of another tenant
+	ErrTenantMismatch = errors.New("resource belongs to a different tenant")
+)
+
+// CreateTenant - validates and stores a new tenant
+func CreateTenant(tenant *models.Tenant) error {
+	if err := validator.New().Struct(tenant); err != nil {
+		return err
+	}
+	if _, err := GetTenant(tenant.ID); err == nil {
+		return ErrTenantExists
+	}
+	tenant.CreatedAt = time.Now()
+	data, err := json.Marshal(tenant)
+	if err != nil {
+		return err
+	}
+	return database.Insert(tenant.ID, string(data), database.TENANTS_TABLE_NAME)
+}
+
+// GetTenant - fetches a tenant by id
+func GetTenant(id string) (models.Tenant, error) {
+	var tenant models.Tenant
+	record, err := database.FetchRecord(database.TENANTS_TABLE_NAME, id)
+	if err != nil {
+		return tenant, err
+	}
+	err = json.Unmarshal([]byte(record), &tenant)
+	return tenant, err
+}
+
+// GetTenants - fetches every tenant
+func GetTenants() ([]models.Tenant, error) {
+	tenants := []models.Tenant{}
+	records, err := database.FetchRecords(database.TENANTS_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return tenants, nil
+		}
+		return tenants, err
+	}
+	for _, record := range records {
+		var tenant models.Tenant
+		if err := json.Unmarshal([]byte(record), &tenant); err != nil {
+			continue
+		}
+		tenants = append(tenants, tenant)
+	}
+	return tenants, nil
+}
+
+// DeleteTenant - removes a tenant that no longer owns any networks or users
+func DeleteTenant(id string) error {
+	if _, err := GetTenant(id); err != nil {
+		return err
+	}
+	networks, err := GetTenantNetworks(id)
+	if err != nil {
+		return err
+	}
+	if len(networks) > 0 {
+		return ErrTenantInUse
+	}
+	users, err := GetUsers()
+	if err != nil && !database.IsEmptyRecord(err) {
+		return err
+	}
+	for _, user := range users {
+		if user.Tenant == id {
+			return ErrTenantInUse
+		}
+	}
+	return database.DeleteRecord(database.TENANTS_TABLE_NAME, id)
+}
+
+// GetTenantNetworks - returns the names of the networks owned by a tenant
+func GetTenantNetworks(tenant string) ([]string, error) {
+	names := []string{}
+	networks, err := GetNetworks()
+	if err != nil && !database.IsEmptyRecord(err) {
+		return names, err
+	}
+	for _, network := range networks {
+		if network.Tenant == tenant {
+			names = append(names, network.NetID)
+		}
+	}
+	return names, nil
+}
+
+// GetUserTenant - returns the tenant of a user, empty for users who can see every tenant
+func GetUserTenant(username string) string {
+	user, err := GetUser(username)
+	if err != nil {
+		return ""
+	}
+	return user.Tenant
+}
+
+// IsSuperAdmin - checks if a user is an admin of the whole server rather than of a single tenant
+func IsSuperAdmin(user *models.User) bool {
+	return user.IsAdmin && user.Tenant == ""
+}
+
+// NetworksInTenant - checks that every network exists and belongs to the tenant
+func NetworksInTenant(networks []string, tenant string) error {
+	for _, netID := range networks {
+		network, err := GetNetwork(netID)
+		if err != nil {
+			return err
+		}
+		if network.Tenant != tenant {
+			return ErrTenantMismatch
+		}
+	}
+	return nil
+}
+
+// HostInTenant - checks if a host has joined any network of the tenant
+func HostInTenant(host *models.Host, tenant string) bool {
+	networks, err := GetTenantNetworks(tenant)
+	if err != nil {
+		return false
+	}
+	for _, nodeID := range host.Nodes {
+		node, err := GetNodeByID(nodeID)
+		if err != nil {
+			continue
+		}
+		if slices.Contains(networks, node.Network) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkHostTenant - keeps a host within one tenant, so the per host MQTT topics
+// only ever carry the data of a single tenant
+func checkHostTenant(host *models.Host, network string) error {
+	target, err := GetNetwork(network)
+	if err != nil {
+		// an unknown network is rejected when the node is created
+		return nil
+	}
+	for _, nodeID := range host.Nodes {
+		node, err := GetNodeByID(nodeID)
+		if err != nil {
+			continue
+		}
+		current, err := GetNetwork(node.Network)
+		if err != nil {
+			continue
+		}
+		if current.Tenant != target.Tenant {
+			return ErrTenantMismatch
+		}
+	}
+	return nil
+}
@@ -47,7 +47,7 @@ func AddHook(ifaceToAdd interface{}) {
 	timeHooks = append(timeHooks, ifaceToAdd)
 }
 
-// StartHookManager - listens on `HookManagerCh` to run any hook
+// StartHookManager - listens on `HookManagerCh` to run any hook as a scheduled job
 func StartHookManager(ctx context.Context, wg *sync.WaitGroup) {
 	defer wg.Done()
 	for {
@@ -56,35 +56,32 @@ func StartHookManager(ctx context.Context, wg *sync.WaitGroup) {
 			slog.Error("## Stopping Hook Manager")
 			return
 		case newhook := <-HookManagerCh:
-			wg.Add(1)
-			go addHookWithInterval(ctx, wg, newhook.Hook, newhook.Interval)
+			hook := newhook.Hook
+			RegisterJob(newhook.Name, newhook.Interval, func(ctx context.Context) error {
+				return hook()
+			})
 		}
 	}
 }
 
-func addHookWithInterval(ctx context.Context, wg *sync.WaitGroup, hook func() error, interval time.Duration) {
-	defer wg.Done()
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			if err := hook(); err != nil {
-				slog.Error(err.Error())
-			}
-		}
-	}
-
-}
-
 // == private ==
 
 // timeHooks - functions to run once a day, functions must take no parameters
 var timeHooks = []interface{}{
 	loggerDump,
 	sendTelemetry,
+	pruneTrafficUsage,
+	recordUsageSnapshots,
+	pruneAuditLogs,
+	pruneNetworkEvents,
+	pruneExtClientSessions,
+	pruneSecurityEvents,
+	prunePKICertificates,
+	pruneUserSecurityEvents,
+	pruneEmailVerifications,
+	sendScheduledReports,
+	checkIPAMUtilization,
+	checkConsistency,
 }
 
 func loggerDump() error {
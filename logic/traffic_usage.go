@@ -0,0 +1,286 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/models"
+)
+
+const (
+	// TrafficUsageDaily - report usage in daily buckets
+	TrafficUsageDaily = "daily"
+	// TrafficUsageMonthly - report usage in monthly buckets
+	TrafficUsageMonthly = "monthly"
+
+	// how long daily usage records are kept around
+	trafficUsageRetentionDays = 400
+	// default amount of top talkers to return in a report
+	defaultTopTalkers = 10
+)
+
+var (
+	trafficCountersMutex = &sync.Mutex{}
+	// lastTrafficCounters - the transfer counters each node last reported, per peer, to turn them into deltas
+	lastTrafficCounters = make(map[string]map[string]models.Metric)
+)
+
+// ErrInvalidUsagePeriod - returned when an unknown report period is requested
+var ErrInvalidUsagePeriod = errors.New("invalid usage period, must be daily or monthly")
+
+// RecordTrafficUsage - stores the transfer since the previous report of a node, for the node itself and any
+// ext clients attached to it; reported holds the counters as the node sent them, before they're accumulated
+// into the node's metrics, and the first report a server sees of a node only sets the baseline
+func RecordTrafficUsage(node *models.Node, reported *models.Metrics) error {
+	// ephemeral nodes are too short lived to count towards usage
+	if node.Ephemeral || reported == nil || len(reported.Connectivity) == 0 {
+		return nil
+	}
+	previous, ok := swapTrafficCounters(node.ID.String(), reported.Connectivity)
+	if !ok {
+		return nil
+	}
+	var clients = make(map[string]models.ExtClient)
+	if node.IsIngressGateway {
+		extClients, err := GetExtClientsByID(node.ID.String(), node.Network)
+		if err == nil {
+			for i := range extClients {
+				// gateways report their ext clients by public key, server clients by id
+				clients[extClients[i].ClientID] = extClients[i]
+				clients[extClients[i].PublicKey] = extClients[i]
+			}
+		}
+	}
+	now := time.Now().UTC()
+	elapsed := sinceLastReport(node.ID.String(), now)
+	day := now.Format(logger.TimeFormatDay)
+	nodeUsage := models.TrafficUsage{
+		ID:      node.ID.String(),
+		Kind:    models.TrafficUsageNode,
+		Network: node.Network,
+		OwnerID: node.OwnerID,
+		Day:     day,
+	}
+	if host, err := GetHost(node.HostID.String()); err == nil {
+		nodeUsage.Name = host.Name
+	}
+	for peerID, metric := range reported.Connectivity {
+		oldMetric := previous[peerID]
+		sent := counterDelta(oldMetric.TotalSent, metric.TotalSent)
+		received := counterDelta(oldMetric.TotalReceived, metric.TotalReceived)
+		if client, ok := clients[peerID]; ok {
+			setBandwidthRate(client.ClientID, client.BandwidthLimit, received, sent, elapsed)
+		}
+		if sent == 0 && received == 0 {
+			continue
+		}
+		nodeUsage.Sent += sent
+		nodeUsage.Received += received
+		if client, ok := clients[peerID]; ok {
+			// traffic sent by the gateway to the client is received by the client
+			if err := addTrafficUsage(models.TrafficUsage{
+				ID:       client.ClientID,
+				Kind:     models.TrafficUsageExtClient,
+				Name:     client.ClientID,
+				Network:  client.Network,
+				OwnerID:  client.OwnerID,
+				Day:      day,
+				Sent:     received,
+				Received: sent,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	setBandwidthRate(nodeUsage.ID, node.BandwidthLimit, nodeUsage.Sent, nodeUsage.Received, elapsed)
+	if nodeUsage.Sent == 0 && nodeUsage.Received == 0 {
+		return nil
+	}
+	return addTrafficUsage(nodeUsage)
+}
+
+// GetTrafficUsage - fetches all daily usage records between from and to (inclusive)
+func GetTrafficUsage(from, to time.Time) ([]models.TrafficUsage, error) {
+	records, err := database.FetchRecords(database.TRAFFIC_USAGE_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return []models.TrafficUsage{}, nil
+		}
+		return nil, err
+	}
+	start, end := from.UTC().Format(logger.TimeFormatDay), to.UTC().Format(logger.TimeFormatDay)
+	usage := []models.TrafficUsage{}
+	for _, value := range records {
+		var record models.TrafficUsage
+		if err := json.Unmarshal([]byte(value), &record); err != nil {
+			continue
+		}
+		if record.Day < start || record.Day > end {
+			continue
+		}
+		usage = append(usage, record)
+	}
+	return usage, nil
+}
+
+// GetTrafficUsageReport - builds a usage report for the given period and time range
+func GetTrafficUsageReport(period string, from, to time.Time, top int) (models.TrafficUsageReport, error) {
+	if period != TrafficUsageDaily && period != TrafficUsageMonthly {
+		return models.TrafficUsageReport{}, ErrInvalidUsagePeriod
+	}
+	usage, err := GetTrafficUsage(from, to)
+	if err != nil {
+		return models.TrafficUsageReport{}, err
+	}
+	report := aggregateTrafficUsage(usage, period, top)
+	report.From = from.UTC().Format(logger.TimeFormatDay)
+	report.To = to.UTC().Format(logger.TimeFormatDay)
+	return report, nil
+}
+
+// == private ==
+
+func aggregateTrafficUsage(usage []models.TrafficUsage, period string, top int) models.TrafficUsageReport {
+	if top <= 0 {
+		top = defaultTopTalkers
+	}
+	buckets := make(map[string]*models.TrafficUsageTotal)
+	objects := make(map[string]*models.TrafficUsageTotal)
+	users := make(map[string]*models.TrafficUsageTotal)
+	add := func(m map[string]*models.TrafficUsageTotal, key string, u *models.TrafficUsage) *models.TrafficUsageTotal {
+		total, ok := m[key]
+		if !ok {
+			total = &models.TrafficUsageTotal{ID: key}
+			m[key] = total
+		}
+		total.Sent += u.Sent
+		total.Received += u.Received
+		total.Total += u.Sent + u.Received
+		return total
+	}
+	for i := range usage {
+		u := &usage[i]
+		bucket := u.Day
+		if period == TrafficUsageMonthly && len(bucket) >= 7 {
+			bucket = bucket[:7]
+		}
+		add(buckets, bucket, u)
+		obj := add(objects, string(u.Kind)+"/"+u.ID, u)
+		obj.Kind, obj.Name, obj.Network = u.Kind, u.Name, u.Network
+		if u.OwnerID != "" {
+			add(users, u.OwnerID, u)
+		}
+	}
+	report := models.TrafficUsageReport{
+		Period:     period,
+		Buckets:    sortedUsageTotals(buckets, false),
+		Nodes:      []models.TrafficUsageTotal{},
+		ExtClients: []models.TrafficUsageTotal{},
+		Users:      sortedUsageTotals(users, true),
+	}
+	all := sortedUsageTotals(objects, true)
+	for i := range all {
+		all[i].ID = all[i].ID[len(all[i].Kind)+1:]
+		switch all[i].Kind {
+		case models.TrafficUsageNode:
+			report.Nodes = append(report.Nodes, all[i])
+		case models.TrafficUsageExtClient:
+			report.ExtClients = append(report.ExtClients, all[i])
+		}
+	}
+	if len(all) > top {
+		report.TopTalkers = all[:top]
+	} else {
+		report.TopTalkers = all
+	}
+	return report
+}
+
+// sortedUsageTotals - flattens a map of totals, sorted by total traffic or by id
+func sortedUsageTotals(m map[string]*models.TrafficUsageTotal, byTotal bool) []models.TrafficUsageTotal {
+	totals := make([]models.TrafficUsageTotal, 0, len(m))
+	for _, t := range m {
+		totals = append(totals, *t)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if byTotal && totals[i].Total != totals[j].Total {
+			return totals[i].Total > totals[j].Total
+		}
+		return totals[i].ID < totals[j].ID
+	})
+	return totals
+}
+
+// counterDelta - returns the growth of a counter, handling counter resets
+func counterDelta(old, current int64) int64 {
+	if current < old {
+		return current
+	}
+	return current - old
+}
+
+// swapTrafficCounters - stores the counters a node reported and returns the ones it reported before,
+// false when this server hasn't seen a report of the node yet
+func swapTrafficCounters(nodeID string, connectivity map[string]models.Metric) (map[string]models.Metric, bool) {
+	current := make(map[string]models.Metric, len(connectivity))
+	for peerID, metric := range connectivity {
+		current[peerID] = models.Metric{TotalSent: metric.TotalSent, TotalReceived: metric.TotalReceived}
+	}
+	trafficCountersMutex.Lock()
+	defer trafficCountersMutex.Unlock()
+	previous, ok := lastTrafficCounters[nodeID]
+	lastTrafficCounters[nodeID] = current
+	return previous, ok
+}
+
+// trafficUsageKey - ids are only unique within a network, so the network is part of the key
+func trafficUsageKey(u *models.TrafficUsage) string {
+	return fmt.Sprintf("%s###%s###%s", u.ID, u.Network, u.Day)
+}
+
+func addTrafficUsage(u models.TrafficUsage) error {
+	key := trafficUsageKey(&u)
+	record, err := database.FetchRecord(database.TRAFFIC_USAGE_TABLE_NAME, key)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return err
+	}
+	if err == nil {
+		var current models.TrafficUsage
+		if err = json.Unmarshal([]byte(record), &current); err == nil {
+			u.Sent += current.Sent
+			u.Received += current.Received
+		}
+	}
+	data, err := json.Marshal(&u)
+	if err != nil {
+		return err
+	}
+	return database.Insert(key, string(data), database.TRAFFIC_USAGE_TABLE_NAME)
+}
+
+// pruneTrafficUsage - removes usage records past the retention period
+func pruneTrafficUsage() error {
+	records, err := database.FetchRecords(database.TRAFFIC_USAGE_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return nil
+		}
+		return err
+	}
+	cutoff := time.Now().UTC().AddDate(0, 0, -trafficUsageRetentionDays).Format(logger.TimeFormatDay)
+	for key, value := range records {
+		var record models.TrafficUsage
+		if err := json.Unmarshal([]byte(value), &record); err != nil || record.Day < cutoff {
+			if err := database.DeleteRecord(database.TRAFFIC_USAGE_TABLE_NAME, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregateTrafficUsage(t *testing.T) {
+	usage := []models.TrafficUsage{
+		{ID: "node1", Kind: models.TrafficUsageNode, OwnerID: "alice", Day: "2023-08-01", Sent: 10, Received: 20},
+		{ID: "node1", Kind: models.TrafficUsageNode, OwnerID: "alice", Day: "2023-08-02", Sent: 5, Received: 5},
+		{ID: "client1", Kind: models.TrafficUsageExtClient, OwnerID: "bob", Day: "2023-09-01", Sent: 100, Received: 0},
+	}
+	t.Run("Daily", func(t *testing.T) {
+		report := aggregateTrafficUsage(usage, TrafficUsageDaily, 0)
+		assert.Equal(t, 3, len(report.Buckets))
+		assert.Equal(t, "2023-08-01", report.Buckets[0].ID)
+		assert.Equal(t, 1, len(report.Nodes))
+		assert.Equal(t, int64(40), report.Nodes[0].Total)
+		assert.Equal(t, 1, len(report.ExtClients))
+		assert.Equal(t, "client1", report.TopTalkers[0].ID)
+		assert.Equal(t, "bob", report.Users[0].ID)
+	})
+	t.Run("Monthly", func(t *testing.T) {
+		report := aggregateTrafficUsage(usage, TrafficUsageMonthly, 1)
+		assert.Equal(t, 2, len(report.Buckets))
+		assert.Equal(t, "2023-08", report.Buckets[0].ID)
+		assert.Equal(t, int64(40), report.Buckets[0].Total)
+		assert.Equal(t, 1, len(report.TopTalkers))
+	})
+	t.Run("CounterReset", func(t *testing.T) {
+		assert.Equal(t, int64(5), counterDelta(10, 15))
+		assert.Equal(t, int64(3), counterDelta(10, 3))
+	})
+}
+
+func TestRecordTrafficUsage(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	node := models.Node{CommonNode: models.CommonNode{ID: uuid.New(), Network: "usage-net"}}
+	report := func(sent, received int64) *models.Metrics {
+		return &models.Metrics{Connectivity: map[string]models.Metric{"peer": {TotalSent: sent, TotalReceived: received}}}
+	}
+	usageOf := func() models.TrafficUsage {
+		usage, err := GetTrafficUsage(time.Now(), time.Now())
+		assert.Nil(t, err)
+		for _, u := range usage {
+			if u.ID == node.ID.String() {
+				return u
+			}
+		}
+		return models.TrafficUsage{}
+	}
+	t.Run("FirstReportIsBaseline", func(t *testing.T) {
+		assert.Nil(t, RecordTrafficUsage(&node, report(1000, 2000)))
+		assert.Equal(t, int64(0), usageOf().Sent)
+	})
+	t.Run("CountsReportedDelta", func(t *testing.T) {
+		assert.Nil(t, RecordTrafficUsage(&node, report(1500, 2100)))
+		assert.Nil(t, RecordTrafficUsage(&node, report(1600, 2300)))
+		usage := usageOf()
+		assert.Equal(t, int64(600), usage.Sent)
+		assert.Equal(t, int64(300), usage.Received)
+	})
+	t.Run("KeyedByNetwork", func(t *testing.T) {
+		a := models.TrafficUsage{ID: "client", Network: "a", Day: "2023-08-01"}
+		b := models.TrafficUsage{ID: "client", Network: "b", Day: "2023-08-01"}
+		assert.NotEqual(t, trafficUsageKey(&a), trafficUsageKey(&b))
+	})
+}
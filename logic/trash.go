@@ -0,0 +1,219 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
+)
+
+// trashPurgeInterval - how often expired resources are removed from the trash
+const trashPurgeInterval = time.Hour
+
+// ErrRestoreConflict - a resource can't be restored over one that exists
+var ErrRestoreConflict = errors.New("can not restore over an existing resource")
+
+// TrashNetwork - keeps a deleted network in the trash
+func TrashNetwork(network *models.Network, user string) error {
+	return trashResource(models.TrashNetwork, network.NetID, network.NetID, network.NetID, network.Tenant, user, network)
+}
+
+// TrashNode - keeps a deleted node in the trash
+func TrashNode(node *models.Node, user string) error {
+	name := node.Name
+	if host, err := GetHost(node.HostID.String()); name == "" && err == nil {
+		name = host.Name
+	}
+	return trashResource(models.TrashNode, node.ID.String(), name, node.Network, networkTenant(node.Network), user, node)
+}
+
+// TrashExtClient - keeps a deleted ext client in the trash
+func TrashExtClient(client *models.ExtClient, user string) error {
+	return trashResource(models.TrashExtClient, client.ClientID, client.ClientID, client.Network, networkTenant(client.Network), user, client)
+}
+
+// GetTrash - fetches the trash of a tenant, or of every tenant when empty, latest deletion first
+func GetTrash(tenant string) ([]models.TrashedResource, error) {
+	trash := []models.TrashedResource{}
+	records, err := database.FetchRecords(database.TRASH_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return trash, nil
+		}
+		return trash, err
+	}
+	for _, value := range records {
+		var item models.TrashedResource
+		if err := json.Unmarshal([]byte(value), &item); err != nil {
+			continue
+		}
+		if tenant != "" && item.Tenant != tenant {
+			continue
+		}
+		trash = append(trash, item)
+	}
+	sort.Slice(trash, func(i, j int) bool {
+		return trash[i].Deleted.After(trash[j].Deleted)
+	})
+	return trash, nil
+}
+
+// GetTrashedResource - fetches a resource from the trash
+func GetTrashedResource(id string) (models.TrashedResource, error) {
+	var item models.TrashedResource
+	data, err := database.FetchRecord(database.TRASH_TABLE_NAME, id)
+	if err != nil {
+		return item, err
+	}
+	err = json.Unmarshal([]byte(data), &item)
+	return item, err
+}
+
+// DeleteTrashedResource - removes a resource from the trash for good
+func DeleteTrashedResource(id string) error {
+	return database.DeleteRecord(database.TRASH_TABLE_NAME, id)
+}
+
+// RestoreNetwork - recreates a deleted network and takes it out of the trash
+func RestoreNetwork(item *models.TrashedResource) (models.Network, error) {
+	var network models.Network
+	if err := json.Unmarshal(item.Data, &network); err != nil {
+		return network, err
+	}
+	if _, err := GetNetwork(network.NetID); err == nil {
+		return network, fmt.Errorf("%w: network %s exists", ErrRestoreConflict, network.NetID)
+	}
+	network, err := CreateNetwork(network)
+	if err != nil {
+		return network, err
+	}
+	return network, DeleteTrashedResource(item.ID)
+}
+
+// RestoreNode - adds a deleted node back to its host and takes it out of the trash,
+// the node gets a new id and its gateway, relay and failover roles are not restored
+// as they depend on nodes and clients that may be gone
+func RestoreNode(item *models.TrashedResource) (models.Node, *models.Host, error) {
+	var node models.Node
+	if err := json.Unmarshal(item.Data, &node); err != nil {
+		return node, nil, err
+	}
+	if _, err := GetNodeByID(item.ResourceID); err == nil {
+		return node, nil, fmt.Errorf("%w: node %s is still being deleted", ErrRestoreConflict, item.ResourceID)
+	}
+	if _, err := GetNetwork(node.Network); err != nil {
+		return node, nil, fmt.Errorf("network %s of the node no longer exists", node.Network)
+	}
+	host, err := GetHost(node.HostID.String())
+	if err != nil {
+		return node, nil, fmt.Errorf("host %s of the node no longer exists", node.HostID.String())
+	}
+	node.Action = models.NODE_NOOP
+	node.PendingDelete = false
+	node.IsIngressGateway = false
+	node.IngressGatewayRange = ""
+	node.IngressGatewayRange6 = ""
+	node.IsEgressGateway = false
+	node.EgressGatewayRanges = nil
+	node.EgressGatewayRequest = models.EgressGatewayRequest{}
+	node.IsRelay = false
+	node.RelayedNodes = nil
+	node.IsRelayed = false
+	node.RelayedBy = ""
+	node.Failover = false
+	node.FailoverNode = uuid.Nil
+	if err = AssociateNodeToHost(&node, host); err != nil {
+		return node, nil, err
+	}
+	return node, host, DeleteTrashedResource(item.ID)
+}
+
+// RestoreExtClient - recreates a deleted ext client on its gateway and takes it out of the trash
+func RestoreExtClient(item *models.TrashedResource) (models.ExtClient, error) {
+	var client models.ExtClient
+	if err := json.Unmarshal(item.Data, &client); err != nil {
+		return client, err
+	}
+	if _, err := GetExtClient(client.ClientID, client.Network); err == nil {
+		return client, fmt.Errorf("%w: ext client %s exists", ErrRestoreConflict, client.ClientID)
+	}
+	gateway, err := GetNodeByID(client.IngressGatewayID)
+	if err != nil || !gateway.IsIngressGateway {
+		return client, fmt.Errorf("ingress gateway %s of the ext client no longer exists", client.IngressGatewayID)
+	}
+	if client.Address != "" && !IsIPUnique(client.Network, client.Address, database.EXT_CLIENT_TABLE_NAME, false) {
+		return client, fmt.Errorf("%w: address %s is taken", ErrRestoreConflict, client.Address)
+	}
+	if client.Address6 != "" && !IsIPUnique(client.Network, client.Address6, database.EXT_CLIENT_TABLE_NAME, true) {
+		return client, fmt.Errorf("%w: address %s is taken", ErrRestoreConflict, client.Address6)
+	}
+	if err = SaveExtClient(&client); err != nil {
+		return client, err
+	}
+	return client, DeleteTrashedResource(item.ID)
+}
+
+// PurgeExpiredTrash - removes resources from the trash once their retention is over
+func PurgeExpiredTrash() error {
+	trash, err := GetTrash("")
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	for _, item := range trash {
+		if now.Before(item.Expires) {
+			continue
+		}
+		if err := DeleteTrashedResource(item.ID); err != nil {
+			slog.Error("failed to purge trashed resource", "id", item.ID, "kind", item.Kind, "error", err)
+			continue
+		}
+		slog.Info("purged trashed resource", "kind", item.Kind, "name", item.Name, "network", item.Network)
+	}
+	return nil
+}
+
+// == private ==
+
+func trashResource(kind models.TrashKind, resourceID, name, network, tenant, user string, resource any) error {
+	retention := servercfg.GetTrashRetention()
+	if retention == 0 {
+		return nil
+	}
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return err
+	}
+	item := models.TrashedResource{
+		ID:         uuid.New().String(),
+		Kind:       kind,
+		ResourceID: resourceID,
+		Name:       name,
+		Network:    network,
+		Tenant:     tenant,
+		Data:       data,
+		DeletedBy:  user,
+		Deleted:    time.Now().UTC(),
+	}
+	item.Expires = item.Deleted.Add(retention)
+	data, err = json.Marshal(&item)
+	if err != nil {
+		return err
+	}
+	return database.Insert(item.ID, string(data), database.TRASH_TABLE_NAME)
+}
+
+func networkTenant(netID string) string {
+	network, err := GetNetwork(netID)
+	if err != nil {
+		return ""
+	}
+	return network.Tenant
+}
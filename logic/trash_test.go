@@ -0,0 +1,40 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrashNetwork(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	// networks are created with their users, at least one has to exist
+	assert.Nil(t, CreateUser(&models.User{UserName: "trashadmin", Password: "password", IsAdmin: true}))
+	defer DeleteUser("trashadmin")
+	database.DeleteAllRecords(database.TRASH_TABLE_NAME)
+	network, err := CreateNetwork(models.Network{NetID: "trashtest", AddressRange: "10.202.0.0/24"})
+	assert.Nil(t, err)
+	assert.Nil(t, DeleteNetwork(network.NetID))
+	assert.Nil(t, TrashNetwork(&network, "admin"))
+	trash, err := GetTrash("")
+	assert.Nil(t, err)
+	assert.Len(t, trash, 1)
+	item := trash[0]
+	assert.Equal(t, models.TrashNetwork, item.Kind)
+	assert.True(t, item.Expires.After(item.Deleted))
+	t.Run("Restore", func(t *testing.T) {
+		restored, err := RestoreNetwork(&item)
+		assert.Nil(t, err)
+		assert.Equal(t, network.AddressRange, restored.AddressRange)
+		_, err = GetTrashedResource(item.ID)
+		assert.NotNil(t, err)
+	})
+	t.Run("Conflict", func(t *testing.T) {
+		_, err := RestoreNetwork(&item)
+		assert.ErrorIs(t, err, ErrRestoreConflict)
+	})
+	assert.Nil(t, DeleteNetwork(network.NetID))
+}
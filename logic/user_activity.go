@@ -0,0 +1,258 @@
+package logic
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+// extClientSessionRetentionDays - how long the closed sessions of ext clients are kept around
+const extClientSessionRetentionDays = 90
+
+// RecordExtClientSessions - opens, updates and closes the sessions of the ext clients of a gateway from its metrics report,
+// a client changing source ip starts a new session
+func RecordExtClientSessions(gateway *models.Node, oldMetrics, newMetrics *models.Metrics) error {
+	if !gateway.IsIngressGateway || newMetrics == nil {
+		return nil
+	}
+	clients, err := GetGatewayExtClients(gateway.ID.String(), gateway.Network)
+	if err != nil {
+		return err
+	}
+	gatewayName := gateway.ID.String()
+	if host, err := GetHost(gateway.HostID.String()); err == nil {
+		gatewayName = host.Name
+	}
+	now := time.Now().UTC()
+	for _, client := range clients {
+		metric, reported := newMetrics.Connectivity[client.ClientID]
+		var oldMetric models.Metric
+		if oldMetrics != nil && oldMetrics.Connectivity != nil {
+			oldMetric = oldMetrics.Connectivity[client.ClientID]
+		}
+		session, open, err := getOpenExtClientSession(client.ClientID, gateway.ID.String())
+		if err != nil {
+			return err
+		}
+		sourceIP := metricSourceIP(metric.Endpoint)
+		if open && (!reported || !metric.Connected || (sourceIP != "" && sourceIP != session.SourceIP)) {
+			if err := closeExtClientSession(&session, now); err != nil {
+				return err
+			}
+			open = false
+		}
+		if !reported || !metric.Connected {
+			continue
+		}
+		if !open {
+			session = models.ExtClientSession{
+				ClientID:    client.ClientID,
+				Network:     client.Network,
+				OwnerID:     client.OwnerID,
+				GatewayID:   gateway.ID.String(),
+				GatewayName: gatewayName,
+				SourceIP:    sourceIP,
+				Start:       now,
+				Open:        true,
+			}
+		}
+		// traffic sent by the gateway to the client is received by the client
+		session.Sent += counterDelta(oldMetric.TotalReceived, metric.TotalReceived)
+		session.Received += counterDelta(oldMetric.TotalSent, metric.TotalSent)
+		session.LastSeen = now
+		if err := saveExtClientSession(openExtClientSessionKey(client.ClientID, gateway.ID.String()), &session); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetUserActivity - summarizes the sessions of a user's ext clients that were connected between from and to
+func GetUserActivity(username string, from, to time.Time) (models.UserActivity, error) {
+	activity := models.UserActivity{
+		User:      username,
+		From:      from,
+		To:        to,
+		SourceIPs: []string{},
+		Gateways:  []models.UserGatewayActivity{},
+	}
+	var err error
+	activity.Sessions, err = getExtClientSessions(from, to, func(session *models.ExtClientSession) bool {
+		return session.OwnerID == username
+	})
+	if err != nil {
+		return activity, err
+	}
+	gateways := map[string]*models.UserGatewayActivity{}
+	for _, session := range activity.Sessions {
+		gateway, ok := gateways[session.GatewayID]
+		if !ok {
+			gateway = &models.UserGatewayActivity{
+				GatewayID:   session.GatewayID,
+				GatewayName: session.GatewayName,
+				Network:     session.Network,
+				SourceIPs:   []string{},
+			}
+			gateways[session.GatewayID] = gateway
+		}
+		duration := int64(sessionDuration(&session).Seconds())
+		gateway.Sessions++
+		gateway.ConnectedSeconds += duration
+		gateway.Sent += session.Sent
+		gateway.Received += session.Received
+		if session.LastSeen.After(gateway.LastHandshake) {
+			gateway.LastHandshake = session.LastSeen
+		}
+		activity.ConnectedSeconds += duration
+		activity.Sent += session.Sent
+		activity.Received += session.Received
+		if session.SourceIP == "" {
+			continue
+		}
+		if !StringSliceContains(gateway.SourceIPs, session.SourceIP) {
+			gateway.SourceIPs = append(gateway.SourceIPs, session.SourceIP)
+		}
+		if !StringSliceContains(activity.SourceIPs, session.SourceIP) {
+			activity.SourceIPs = append(activity.SourceIPs, session.SourceIP)
+		}
+	}
+	for _, gateway := range gateways {
+		activity.Gateways = append(activity.Gateways, *gateway)
+	}
+	sort.Slice(activity.Gateways, func(i, j int) bool {
+		return activity.Gateways[i].LastHandshake.After(activity.Gateways[j].LastHandshake)
+	})
+	return activity, nil
+}
+
+// GetExtClientSessions - the sessions of an ext client with its gateways that were connected between from and to,
+// the latest first; they outlive the client for audits
+func GetExtClientSessions(network, clientID string, from, to time.Time) ([]models.ExtClientSession, error) {
+	return getExtClientSessions(from, to, func(session *models.ExtClientSession) bool {
+		return session.Network == network && session.ClientID == clientID
+	})
+}
+
+// == private ==
+
+// getExtClientSessions - the sessions matching match that were connected between from and to, the latest first
+func getExtClientSessions(from, to time.Time, match func(*models.ExtClientSession) bool) ([]models.ExtClientSession, error) {
+	sessions := []models.ExtClientSession{}
+	records, err := database.FetchRecords(database.EXTCLIENT_SESSIONS_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return sessions, nil
+		}
+		return sessions, err
+	}
+	for _, value := range records {
+		var session models.ExtClientSession
+		if err := json.Unmarshal([]byte(value), &session); err != nil || !match(&session) {
+			continue
+		}
+		if session.LastSeen.Before(from) || session.Start.After(to) {
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Start.After(sessions[j].Start)
+	})
+	return sessions, nil
+}
+
+// pruneExtClientSessions - removes the closed sessions of ext clients past the retention period
+func pruneExtClientSessions() error {
+	records, err := database.FetchRecords(database.EXTCLIENT_SESSIONS_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return nil
+		}
+		return err
+	}
+	now := time.Now().UTC()
+	cutoff := now.AddDate(0, 0, -extClientSessionRetentionDays)
+	for key, value := range records {
+		var session models.ExtClientSession
+		err := json.Unmarshal([]byte(value), &session)
+		// the sessions of clients or gateways that went away are never closed by a metrics report
+		if err == nil && session.Open && session.LastSeen.Before(now.AddDate(0, 0, -1)) {
+			if err := closeExtClientSession(&session, now); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil || (!session.Open && session.End.Before(cutoff)) {
+			if err := database.DeleteRecord(database.EXTCLIENT_SESSIONS_TABLE_NAME, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func getOpenExtClientSession(clientID, gatewayID string) (models.ExtClientSession, bool, error) {
+	var session models.ExtClientSession
+	record, err := database.FetchRecord(database.EXTCLIENT_SESSIONS_TABLE_NAME, openExtClientSessionKey(clientID, gatewayID))
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return session, false, nil
+		}
+		return session, false, err
+	}
+	if err := json.Unmarshal([]byte(record), &session); err != nil {
+		return session, false, err
+	}
+	return session, true, nil
+}
+
+// closeExtClientSession - ends an open session when the client was last seen, moving it out of the open key
+func closeExtClientSession(session *models.ExtClientSession, now time.Time) error {
+	session.Open = false
+	session.End = session.LastSeen
+	if session.End.IsZero() {
+		session.End = now
+	}
+	key := fmt.Sprintf("%s_%s_%d", session.ClientID, session.GatewayID, session.Start.UnixNano())
+	if err := saveExtClientSession(key, session); err != nil {
+		return err
+	}
+	if err := database.DeleteRecord(database.EXTCLIENT_SESSIONS_TABLE_NAME, openExtClientSessionKey(session.ClientID, session.GatewayID)); err != nil {
+		slog.Warn("failed to delete closed ext client session", "clientid", session.ClientID, "gateway", session.GatewayID, "error", err)
+	}
+	return nil
+}
+
+func saveExtClientSession(key string, session *models.ExtClientSession) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return database.Insert(key, string(data), database.EXTCLIENT_SESSIONS_TABLE_NAME)
+}
+
+func openExtClientSessionKey(clientID, gatewayID string) string {
+	return clientID + "_" + gatewayID
+}
+
+// sessionDuration - how long a session lasted, up to when the client was last seen while it's open
+func sessionDuration(session *models.ExtClientSession) time.Duration {
+	if session.Open {
+		return session.LastSeen.Sub(session.Start)
+	}
+	return session.End.Sub(session.Start)
+}
+
+// metricSourceIP - the ip of the endpoint a gateway sees a peer connect from
+func metricSourceIP(endpoint string) string {
+	if host, _, err := net.SplitHostPort(endpoint); err == nil {
+		return host
+	}
+	return endpoint
+}
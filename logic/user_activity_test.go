@@ -0,0 +1,38 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetUserActivity(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	now := time.Now().UTC()
+	sessions := []models.ExtClientSession{
+		{ClientID: "laptop", OwnerID: "reviewed", GatewayID: "gw1", SourceIP: "203.0.113.5", Start: now.Add(-2 * time.Hour), End: now.Add(-time.Hour), LastSeen: now.Add(-time.Hour), Sent: 10, Received: 20},
+		{ClientID: "laptop", OwnerID: "reviewed", GatewayID: "gw2", SourceIP: "198.51.100.7", Start: now.Add(-30 * time.Minute), LastSeen: now, Sent: 1, Received: 2, Open: true},
+		{ClientID: "other", OwnerID: "someoneelse", GatewayID: "gw1", Start: now.Add(-time.Hour), LastSeen: now, Open: true},
+	}
+	for i := range sessions {
+		assert.Nil(t, saveExtClientSession(sessions[i].ClientID+sessions[i].GatewayID, &sessions[i]))
+	}
+	activity, err := GetUserActivity("reviewed", now.AddDate(0, 0, -1), now.Add(time.Minute))
+	assert.Nil(t, err)
+	assert.Len(t, activity.Sessions, 2)
+	assert.Len(t, activity.Gateways, 2)
+	assert.Equal(t, "gw2", activity.Gateways[0].GatewayID)
+	assert.Equal(t, int64(11), activity.Sent)
+	assert.Equal(t, int64(22), activity.Received)
+	assert.Equal(t, int64(90*60), activity.ConnectedSeconds)
+	assert.ElementsMatch(t, []string{"203.0.113.5", "198.51.100.7"}, activity.SourceIPs)
+
+	clientSessions, err := GetExtClientSessions("", "laptop", now.AddDate(0, 0, -1), now.Add(-45*time.Minute))
+	assert.Nil(t, err)
+	assert.Len(t, clientSessions, 1, "the later session starts after the period")
+	assert.Equal(t, "gw1", clientSessions[0].GatewayID)
+}
@@ -0,0 +1,229 @@
+package logic
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic/pro"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/models/promodels"
+	"golang.org/x/exp/slog"
+)
+
+// maxUserImportRows - the most users a single import may create
+const maxUserImportRows = 1000
+
+// user import row statuses
+const (
+	userImportCreated = "created"
+	userImportValid   = "valid"
+	userImportFailed  = "failed"
+)
+
+// ErrNoUsersToImport - an import without any users
+var ErrNoUsersToImport = errors.New("no users to import")
+
+// ParseUserImportCSV - reads users from a csv with a header row naming the columns username, password, role,
+// networks, groups, gateways, tenant, display_name, email and department; lists within a cell are separated by semicolons
+func ParseUserImportCSV(r io.Reader) ([]models.UserImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, ErrNoUsersToImport
+		}
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "username", "password", "role", "networks", "groups", "gateways", "tenant", "display_name", "email", "department":
+			columns[name] = i
+		default:
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+	}
+	if _, ok := columns["username"]; !ok {
+		return nil, errors.New("missing username column")
+	}
+	rows := []models.UserImportRow{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		cell := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		rows = append(rows, models.UserImportRow{
+			UserName:    cell("username"),
+			Password:    cell("password"),
+			Role:        strings.ToLower(cell("role")),
+			Networks:    splitImportList(cell("networks")),
+			Groups:      splitImportList(cell("groups")),
+			Gateways:    splitImportList(cell("gateways")),
+			Tenant:      cell("tenant"),
+			DisplayName: cell("display_name"),
+			Email:       cell("email"),
+			Department:  cell("department"),
+		})
+	}
+	return rows, nil
+}
+
+// ImportUsers - validates and creates users row by row, a failing row doesn't stop the others;
+// a tenant admin's tenant, when given, replaces the tenant of every row
+func ImportUsers(request *models.UserImportRequest, tenant string) (models.UserImportResult, error) {
+	result := models.UserImportResult{DryRun: request.DryRun, Results: []models.UserImportRowResult{}}
+	if len(request.Users) == 0 {
+		return result, ErrNoUsersToImport
+	}
+	if len(request.Users) > maxUserImportRows {
+		return result, fmt.Errorf("at most %d users can be imported at once", maxUserImportRows)
+	}
+	existing, err := GetUsers()
+	if err != nil && !database.IsEmptyRecord(err) {
+		return result, err
+	}
+	userCount := len(existing)
+	seen := map[string]bool{}
+	for i := range request.Users {
+		row := request.Users[i]
+		if tenant != "" {
+			row.Tenant = tenant
+		}
+		rowResult := models.UserImportRowResult{Row: i + 1, UserName: row.UserName, Status: userImportValid}
+		var user models.User
+		var gateways []models.Node
+		if seen[row.UserName] {
+			err = errors.New("duplicate username in import")
+		} else if FreeTier && userCount >= UsersLimit {
+			err = errors.New("free tier limits exceeded on users")
+		} else {
+			user, gateways, err = validateUserImportRow(&row)
+		}
+		seen[row.UserName] = true
+		if err == nil && !request.DryRun {
+			err = CreateLocalUser(&user)
+		}
+		if err != nil {
+			rowResult.Status = userImportFailed
+			rowResult.Error = err.Error()
+			result.Failed++
+			result.Results = append(result.Results, rowResult)
+			continue
+		}
+		userCount++
+		if !request.DryRun {
+			rowResult.Status = userImportCreated
+			result.Created++
+			if err := AssignUserGateways(user.UserName, gateways); err != nil {
+				rowResult.Error = "user created, but assigning gateways failed: " + err.Error()
+			}
+		}
+		result.Results = append(result.Results, rowResult)
+	}
+	return result, nil
+}
+
+// AssignUserGateways - lets a user use ingress gateways by adding them to the nodes of the user on their networks
+func AssignUserGateways(username string, gateways []models.Node) error {
+	for _, gateway := range gateways {
+		netUser, err := pro.GetNetworkUser(gateway.Network, promodels.NetworkUserID(username))
+		if err != nil {
+			return fmt.Errorf("user is not on network %s: %w", gateway.Network, err)
+		}
+		if !StringSliceContains(netUser.Nodes, gateway.ID.String()) {
+			netUser.Nodes = append(netUser.Nodes, gateway.ID.String())
+		}
+		if netUser.AccessLevel > pro.CLIENT_ACCESS {
+			netUser.AccessLevel = pro.CLIENT_ACCESS
+		}
+		if err = pro.UpdateNetworkUser(gateway.Network, netUser); err != nil {
+			return err
+		}
+		slog.Info("assigned gateway on network to user", "gateway_id", gateway.ID.String(), "network", gateway.Network, "username", username)
+	}
+	return nil
+}
+
+// == private ==
+
+// validateUserImportRow - the user to create from a row and the gateways to assign to them
+func validateUserImportRow(row *models.UserImportRow) (models.User, []models.Node, error) {
+	user := models.User{
+		UserName:    row.UserName,
+		Password:    row.Password,
+		Networks:    row.Networks,
+		Groups:      row.Groups,
+		IsAdmin:     row.Role == models.UserRoleAdmin,
+		IsAuditor:   row.Role == models.UserRoleAuditor,
+		Tenant:      row.Tenant,
+		DisplayName: row.DisplayName,
+		Email:       row.Email,
+		Department:  row.Department,
+	}
+	if err := validator.New().Struct(row); err != nil {
+		return user, nil, err
+	}
+	if user.Password == "" {
+		user.Password = RandomString(32)
+	}
+	if err := ValidateUser(&user); err != nil {
+		return user, nil, err
+	}
+	if _, err := GetUser(user.UserName); err == nil {
+		return user, nil, errors.New("user exists")
+	}
+	if user.Tenant != "" {
+		if _, err := GetTenant(user.Tenant); err != nil {
+			return user, nil, fmt.Errorf("unknown tenant %s", user.Tenant)
+		}
+		if err := NetworksInTenant(user.Networks, user.Tenant); err != nil {
+			return user, nil, err
+		}
+	}
+	for _, netID := range user.Networks {
+		if _, err := GetNetwork(netID); err != nil {
+			return user, nil, fmt.Errorf("unknown network %s", netID)
+		}
+	}
+	gateways := []models.Node{}
+	for _, id := range row.Gateways {
+		gateway, err := GetNodeByID(id)
+		if err != nil || !gateway.IsIngressGateway {
+			return user, nil, fmt.Errorf("unknown ingress gateway %s", id)
+		}
+		if user.Tenant != "" {
+			if err := NetworksInTenant([]string{gateway.Network}, user.Tenant); err != nil {
+				return user, nil, fmt.Errorf("unknown ingress gateway %s", id)
+			}
+		}
+		gateways = append(gateways, gateway)
+	}
+	return user, gateways, nil
+}
+
+// splitImportList - the non empty entries of a semicolon separated csv cell
+func splitImportList(cell string) []string {
+	entries := []string{}
+	for _, entry := range strings.Split(cell, ";") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
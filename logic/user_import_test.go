@@ -0,0 +1,60 @@
+package logic
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseUserImportCSV(t *testing.T) {
+	rows, err := ParseUserImportCSV(strings.NewReader("Username, role, networks, groups\nalice@example.com, Admin, ,\nbob, user, net1; net2, ops\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, []models.UserImportRow{
+		{UserName: "alice@example.com", Role: "admin", Networks: []string{}, Groups: []string{}, Gateways: []string{}},
+		{UserName: "bob", Role: "user", Networks: []string{"net1", "net2"}, Groups: []string{"ops"}, Gateways: []string{}},
+	}, rows)
+
+	_, err = ParseUserImportCSV(strings.NewReader("username,shell\nbob,bash\n"))
+	assert.NotNil(t, err)
+	_, err = ParseUserImportCSV(strings.NewReader(""))
+	assert.ErrorIs(t, err, ErrNoUsersToImport)
+}
+
+func TestImportUsersDryRun(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	result, err := ImportUsers(&models.UserImportRequest{DryRun: true, Users: []models.UserImportRow{
+		{UserName: "importeduser", Role: models.UserRoleUser},
+		{UserName: "importeduser"},
+		{UserName: "auditor-import", Role: "root"},
+		{UserName: "netless", Networks: []string{"no-such-network"}},
+	}}, "")
+	assert.Nil(t, err)
+	assert.True(t, result.DryRun)
+	assert.Equal(t, 0, result.Created)
+	assert.Equal(t, 3, result.Failed)
+	assert.Equal(t, "valid", result.Results[0].Status)
+	assert.Equal(t, "duplicate username in import", result.Results[1].Error)
+	assert.Equal(t, "failed", result.Results[2].Status)
+	assert.Equal(t, "unknown network no-such-network", result.Results[3].Error)
+	_, err = GetUser("importeduser")
+	assert.NotNil(t, err)
+
+	_, err = ImportUsers(&models.UserImportRequest{}, "")
+	assert.ErrorIs(t, err, ErrNoUsersToImport)
+}
+
+func TestUserProfileValidation(t *testing.T) {
+	user := models.User{UserName: "profileuser", Password: "password", DisplayName: "Profile User", Email: "profile@example.com",
+		AvatarURL: "https://example.com/avatar.png", Metadata: map[string]string{"employee_id": "42"}}
+	assert.Nil(t, ValidateUser(&user))
+	assert.Equal(t, "Profile User", ToReturnUser(user).DisplayName)
+	user.AvatarURL = "javascript:alert(1)"
+	assert.NotNil(t, ValidateUser(&user))
+	user.AvatarURL = ""
+	user.Email = "not an email"
+	assert.NotNil(t, ValidateUser(&user))
+}
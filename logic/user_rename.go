@@ -0,0 +1,225 @@
+package logic
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+// userReferenceFields - the top level fields of records in each table that hold a username
+var userReferenceFields = map[string][]string{
+	database.EXT_CLIENT_TABLE_NAME:           {"ownerid"},
+	database.NODES_TABLE_NAME:                {"ownerid"},
+	database.AUDIT_LOGS_TABLE_NAME:           {"user"},
+	database.PENDING_CHANGES_TABLE_NAME:      {"user"},
+	database.APPROVAL_REQUESTS_TABLE_NAME:    {"requested_by", "decided_by"},
+	database.TRAFFIC_USAGE_TABLE_NAME:        {"owner_id"},
+	database.EXTCLIENT_SESSIONS_TABLE_NAME:   {"owner_id"},
+	database.REVOKED_KEYS_TABLE_NAME:         {"owner_id"},
+	database.USER_SECURITY_EVENTS_TABLE_NAME: {"user"},
+	database.EMAIL_VERIFICATIONS_TABLE_NAME:  {"user"},
+}
+
+// userKeyedTables - the tables whose records are keyed by username and hold it in their user field
+var userKeyedTables = []string{
+	database.API_ALLOWLISTS_TABLE_NAME,
+	database.SOURCE_POLICIES_TABLE_NAME,
+}
+
+// recordRewrite - a record changed by a rename, kept to undo it when a later write fails
+type recordRewrite struct {
+	table    string
+	oldKey   string
+	newKey   string
+	oldValue string
+	newValue string
+}
+
+// RenameUser - changes the username of a user along with every record referring to it: the owner of ext clients
+// and nodes, network user entries and allowed users of networks, audit logs, pending changes and approvals,
+// allowlists, source policies and security records. The writes are undone if any of them fails.
+// The tokens of the user carry the old name, so they have to sign in again.
+func RenameUser(oldName, newName string) (models.User, error) {
+	user, err := GetUser(oldName)
+	if err != nil {
+		return models.User{}, err
+	}
+	if oldName == newName {
+		return *user, errors.New("the new username is the same as the old one")
+	}
+	if _, err := GetUser(newName); err == nil {
+		return *user, errors.New("user exists")
+	}
+	renamed := *user
+	renamed.UserName = newName
+	if err := ValidateUser(&renamed); err != nil {
+		return *user, err
+	}
+	rewrites, err := planUserRename(oldName, newName, &renamed)
+	if err != nil {
+		return *user, err
+	}
+	for i, rewrite := range rewrites {
+		if err := applyRecordRewrite(rewrite); err != nil {
+			undoRecordRewrites(rewrites[:i+1])
+			return *user, fmt.Errorf("failed to rename user, no records were changed: %w", err)
+		}
+	}
+	slog.Info("renamed user", "old_name", oldName, "new_name", newName, "count", len(rewrites))
+	return renamed, nil
+}
+
+// == private ==
+
+// planUserRename - the record rewrites renaming a user takes, without writing any of them
+func planUserRename(oldName, newName string, renamed *models.User) ([]recordRewrite, error) {
+	oldValue, err := database.FetchRecord(database.USERS_TABLE_NAME, oldName)
+	if err != nil {
+		return nil, err
+	}
+	newValue, err := json.Marshal(renamed)
+	if err != nil {
+		return nil, err
+	}
+	rewrites := []recordRewrite{{
+		table:    database.USERS_TABLE_NAME,
+		oldKey:   oldName,
+		newKey:   newName,
+		oldValue: oldValue,
+		newValue: string(newValue),
+	}}
+	for table, fields := range userReferenceFields {
+		err := eachUserRecord(table, func(key string, record map[string]any) (string, bool) {
+			changed := false
+			for _, field := range fields {
+				if record[field] == oldName {
+					record[field] = newName
+					changed = true
+				}
+			}
+			if table == database.APPROVAL_REQUESTS_TABLE_NAME && record["operation"] == string(models.ApprovalGrantSuperAdmin) && record["target"] == oldName {
+				record["target"] = newName
+				changed = true
+			}
+			return key, changed
+		}, &rewrites)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, table := range userKeyedTables {
+		err := eachUserRecord(table, func(key string, record map[string]any) (string, bool) {
+			if key != oldName {
+				return key, false
+			}
+			record["user"] = newName
+			return newName, true
+		}, &rewrites)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = eachUserRecord(database.USER_DEVICES_TABLE_NAME, func(key string, record map[string]any) (string, bool) {
+		if record["user"] != oldName {
+			return key, false
+		}
+		record["user"] = newName
+		// the key of a device derives from its user
+		sourceIP, _ := record["source_ip"].(string)
+		userAgent, _ := record["user_agent"].(string)
+		record["id"] = userDeviceID(newName, sourceIP, userAgent)
+		return record["id"].(string), true
+	}, &rewrites)
+	if err != nil {
+		return nil, err
+	}
+	err = eachUserRecord(database.NETWORK_USER_TABLE_NAME, func(key string, record map[string]any) (string, bool) {
+		netUser, ok := record[oldName].(map[string]any)
+		if !ok {
+			return key, false
+		}
+		netUser["id"] = newName
+		delete(record, oldName)
+		record[newName] = netUser
+		return key, true
+	}, &rewrites)
+	if err != nil {
+		return nil, err
+	}
+	err = eachUserRecord(database.NETWORKS_TABLE_NAME, func(key string, record map[string]any) (string, bool) {
+		proSettings, ok := record["prosettings"].(map[string]any)
+		if !ok {
+			return key, false
+		}
+		allowed, _ := proSettings["allowedusers"].([]any)
+		changed := false
+		for i := range allowed {
+			if allowed[i] == oldName {
+				allowed[i] = newName
+				changed = true
+			}
+		}
+		return key, changed
+	}, &rewrites)
+	return rewrites, err
+}
+
+// eachUserRecord - plans a rewrite of every record of a table that rewrite changes, returning the record's new key;
+// numbers are kept as they were written so large counters don't lose precision
+func eachUserRecord(table string, rewrite func(key string, record map[string]any) (string, bool), rewrites *[]recordRewrite) error {
+	records, err := database.FetchRecords(table)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return nil
+		}
+		return err
+	}
+	for key, value := range records {
+		decoder := json.NewDecoder(bytes.NewReader([]byte(value)))
+		decoder.UseNumber()
+		var record map[string]any
+		if err := decoder.Decode(&record); err != nil {
+			continue
+		}
+		newKey, changed := rewrite(key, record)
+		if !changed {
+			continue
+		}
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		*rewrites = append(*rewrites, recordRewrite{table: table, oldKey: key, newKey: newKey, oldValue: value, newValue: string(data)})
+	}
+	return nil
+}
+
+func applyRecordRewrite(rewrite recordRewrite) error {
+	if err := database.Insert(rewrite.newKey, rewrite.newValue, rewrite.table); err != nil {
+		return err
+	}
+	if rewrite.newKey != rewrite.oldKey {
+		return database.DeleteRecord(rewrite.table, rewrite.oldKey)
+	}
+	return nil
+}
+
+// undoRecordRewrites - puts back the records of rewrites, the last first
+func undoRecordRewrites(rewrites []recordRewrite) {
+	for i := len(rewrites) - 1; i >= 0; i-- {
+		rewrite := rewrites[i]
+		if rewrite.newKey != rewrite.oldKey {
+			if err := database.DeleteRecord(rewrite.table, rewrite.newKey); err != nil && !database.IsEmptyRecord(err) {
+				slog.Error("failed to undo rename of record in", "new_key", rewrite.newKey, "table", rewrite.table, "error", err)
+			}
+		}
+		if err := database.Insert(rewrite.oldKey, rewrite.oldValue, rewrite.table); err != nil {
+			slog.Error("failed to undo rename of record in", "old_key", rewrite.oldKey, "table", rewrite.table, "error", err)
+		}
+	}
+}
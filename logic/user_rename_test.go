@@ -0,0 +1,42 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenameUser(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	assert.Nil(t, CreateUser(&models.User{UserName: "renameold", Password: "password"}))
+	defer DeleteUser("renamenew")
+	defer DeleteUser("renameold")
+	client := models.ExtClient{ClientID: "rename-client", Network: "renamenet", OwnerID: "renameold"}
+	// the network doesn't exist, so only the client record is of interest
+	SaveExtClient(&client)
+	defer DeleteExtClient(client.Network, client.ClientID)
+	assert.Nil(t, SetAPIAllowlist(&models.APIAllowlist{User: "renameold", CIDRs: []string{"10.0.0.0/8"}}))
+	defer DeleteAPIAllowlist("renamenew")
+
+	_, err := RenameUser("renameold", "re")
+	assert.NotNil(t, err, "the new name has to be a valid username")
+
+	user, err := RenameUser("renameold", "renamenew")
+	assert.Nil(t, err)
+	assert.Equal(t, "renamenew", user.UserName)
+	_, err = GetUser("renameold")
+	assert.NotNil(t, err)
+	_, err = GetUser("renamenew")
+	assert.Nil(t, err)
+	client, err = GetExtClient(client.ClientID, client.Network)
+	assert.Nil(t, err)
+	assert.Equal(t, "renamenew", client.OwnerID)
+	allowlist, err := GetAPIAllowlist("renamenew")
+	assert.Nil(t, err)
+	assert.Equal(t, "renamenew", allowlist.User)
+	_, err = GetAPIAllowlist("renameold")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,227 @@
+package logic
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// userSecurityEventRetentionDays - how long the security events of users are kept around
+	userSecurityEventRetentionDays = 90
+	// userDeviceRetentionDays - how long a device is remembered after its last login, it counts as new afterwards
+	userDeviceRetentionDays = 180
+)
+
+// userDeviceMutex - serializes logins so concurrent ones from the same new device raise a single event
+var userDeviceMutex sync.Mutex
+
+// RecordUserLogin - remembers the device a user signed in from; a device not seen before is recorded as a
+// security event and, with login notifications on, emailed to the user, except for the first device of a user
+func RecordUserLogin(username, sourceIP, userAgent, provider string) {
+	userDeviceMutex.Lock()
+	defer userDeviceMutex.Unlock()
+	devices, err := getUserDevices(username)
+	if err != nil {
+		slog.Error("failed to get devices of user", "user", username, "error", err)
+		return
+	}
+	now := time.Now().UTC()
+	device := models.UserDevice{
+		ID:        userDeviceID(username, sourceIP, userAgent),
+		User:      username,
+		SourceIP:  sourceIP,
+		UserAgent: userAgent,
+		FirstSeen: now,
+	}
+	known := false
+	for _, existing := range devices {
+		if existing.ID == device.ID {
+			device = existing
+			known = true
+			break
+		}
+	}
+	device.LastSeen = now
+	data, err := json.Marshal(device)
+	if err != nil {
+		return
+	}
+	if err := database.Insert(device.ID, string(data), database.USER_DEVICES_TABLE_NAME); err != nil {
+		slog.Error("failed to store device of user", "user", username, "error", err)
+	}
+	if known || len(devices) == 0 {
+		return
+	}
+	recordUserSecurityEvent(models.UserSecurityEvent{
+		User:      username,
+		Kind:      models.UserSecurityEventNewDevice,
+		SourceIP:  sourceIP,
+		UserAgent: userAgent,
+		Provider:  provider,
+		Message:   fmt.Sprintf("login via %s from a new device at %s (%s)", provider, sourceIP, userAgent),
+	})
+}
+
+// RecordExtClientEnrollment - records the ext client a user created with a request as a security event and,
+// with login notifications on, emails the user about it in the background
+func RecordExtClientEnrollment(username string, client *models.ExtClient, r *http.Request) {
+	sourceIP := sourceIPString(r)
+	go recordUserSecurityEvent(models.UserSecurityEvent{
+		User:      username,
+		Kind:      models.UserSecurityEventExtClient,
+		SourceIP:  sourceIP,
+		UserAgent: r.UserAgent(),
+		Network:   client.Network,
+		ClientID:  client.ClientID,
+		Message:   fmt.Sprintf("ext client %s enrolled on network %s from %s", client.ClientID, client.Network, sourceIP),
+	})
+}
+
+// GetUserSecurity - the devices a user signed in from and their security events, newest first
+func GetUserSecurity(username string) (models.UserSecurity, error) {
+	security := models.UserSecurity{User: username, Events: []models.UserSecurityEvent{}}
+	devices, err := getUserDevices(username)
+	if err != nil {
+		return security, err
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].LastSeen.After(devices[j].LastSeen)
+	})
+	security.Devices = devices
+	records, err := database.FetchRecords(database.USER_SECURITY_EVENTS_TABLE_NAME)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return security, err
+	}
+	for _, value := range records {
+		var event models.UserSecurityEvent
+		if err := json.Unmarshal([]byte(value), &event); err == nil && event.User == username {
+			security.Events = append(security.Events, event)
+		}
+	}
+	sort.Slice(security.Events, func(i, j int) bool {
+		return security.Events[i].Time.After(security.Events[j].Time)
+	})
+	return security, nil
+}
+
+// DeleteUserSecurity - forgets the devices and security events of a user
+func DeleteUserSecurity(username string) error {
+	security, err := GetUserSecurity(username)
+	if err != nil {
+		return err
+	}
+	for _, device := range security.Devices {
+		if err := database.DeleteRecord(database.USER_DEVICES_TABLE_NAME, device.ID); err != nil {
+			return err
+		}
+	}
+	for _, event := range security.Events {
+		if err := database.DeleteRecord(database.USER_SECURITY_EVENTS_TABLE_NAME, event.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// == private ==
+
+// recordUserSecurityEvent - stores a security event of a user, emailing them first when login notifications are on
+func recordUserSecurityEvent(event models.UserSecurityEvent) {
+	event.ID = uuid.New().String()
+	event.Time = time.Now().UTC()
+	if servercfg.IsLoginNotificationEnabled() {
+		if to, ok := UserEmail(event.User); ok {
+			body := fmt.Sprintf("Hello %s,\n\n%s at %s.\n\nIf this wasn't you, change your password and contact your administrator.\n",
+				event.User, event.Message, event.Time.Format(time.RFC1123))
+			if err := SendEmail(to, "Security notice: "+event.Message, body); err != nil {
+				slog.Error("failed to send security notice", "user", event.User, "kind", event.Kind, "error", err)
+			} else {
+				event.Notified = true
+			}
+		}
+	}
+	slog.Info("user security event", "user", event.User, "kind", event.Kind, "message", event.Message)
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	if err := database.Insert(event.ID, string(data), database.USER_SECURITY_EVENTS_TABLE_NAME); err != nil {
+		slog.Error("failed to store user security event", "user", event.User, "kind", event.Kind, "error", err)
+	}
+}
+
+func getUserDevices(username string) ([]models.UserDevice, error) {
+	devices := []models.UserDevice{}
+	records, err := database.FetchRecords(database.USER_DEVICES_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return devices, nil
+		}
+		return nil, err
+	}
+	for _, value := range records {
+		var device models.UserDevice
+		if err := json.Unmarshal([]byte(value), &device); err == nil && device.User == username {
+			devices = append(devices, device)
+		}
+	}
+	return devices, nil
+}
+
+// userDeviceID - the key of a device, a hash so user agents of any length and characters make a valid key
+func userDeviceID(username, sourceIP, userAgent string) string {
+	sum := sha256.Sum256([]byte(username + "\n" + sourceIP + "\n" + userAgent))
+	return hex.EncodeToString(sum[:16])
+}
+
+// sourceIPString - the address a request comes from, empty when it can't be told
+func sourceIPString(r *http.Request) string {
+	if ip := APISourceIP(r); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
+
+func pruneUserSecurityEvents() error {
+	now := time.Now().UTC()
+	if records, err := database.FetchRecords(database.USER_SECURITY_EVENTS_TABLE_NAME); err == nil {
+		cutoff := now.AddDate(0, 0, -userSecurityEventRetentionDays)
+		for key, value := range records {
+			var event models.UserSecurityEvent
+			if err := json.Unmarshal([]byte(value), &event); err != nil || event.Time.Before(cutoff) {
+				if err := database.DeleteRecord(database.USER_SECURITY_EVENTS_TABLE_NAME, key); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	records, err := database.FetchRecords(database.USER_DEVICES_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return nil
+		}
+		return err
+	}
+	cutoff := now.AddDate(0, 0, -userDeviceRetentionDays)
+	for key, value := range records {
+		var device models.UserDevice
+		if err := json.Unmarshal([]byte(value), &device); err != nil || device.LastSeen.Before(cutoff) {
+			if err := database.DeleteRecord(database.USER_DEVICES_TABLE_NAME, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
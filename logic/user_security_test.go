@@ -0,0 +1,47 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordUserLogin(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	username := "security-notice-user"
+	defer DeleteUserSecurity(username)
+
+	RecordUserLogin(username, "203.0.113.10", "firefox", "basic auth")
+	security, err := GetUserSecurity(username)
+	assert.Nil(t, err)
+	assert.Len(t, security.Devices, 1)
+	assert.Empty(t, security.Events, "the first device of a user isn't news")
+
+	RecordUserLogin(username, "203.0.113.10", "firefox", "basic auth")
+	RecordUserLogin(username, "198.51.100.7", "curl", "oidc")
+	security, err = GetUserSecurity(username)
+	assert.Nil(t, err)
+	assert.Len(t, security.Devices, 2)
+	assert.Len(t, security.Events, 1)
+	assert.Equal(t, models.UserSecurityEventNewDevice, security.Events[0].Kind)
+	assert.Equal(t, "198.51.100.7", security.Events[0].SourceIP)
+	assert.False(t, security.Events[0].Notified)
+
+	assert.Nil(t, DeleteUserSecurity(username))
+	security, err = GetUserSecurity(username)
+	assert.Nil(t, err)
+	assert.Empty(t, security.Devices)
+}
+
+func TestUserEmail(t *testing.T) {
+	address, ok := UserEmail("alice@example.com")
+	assert.True(t, ok)
+	assert.Equal(t, "alice@example.com", address)
+	_, ok = UserEmail("alice")
+	assert.False(t, ok)
+	_, ok = UserEmail("Alice <alice@example.com>")
+	assert.False(t, ok)
+}
@@ -5,10 +5,10 @@ import (
 	"sort"
 
 	"github.com/gravitl/netmaker/database"
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic/pro"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/models/promodels"
+	"golang.org/x/exp/slog"
 )
 
 // GetUser - gets a user
@@ -43,10 +43,18 @@ func GetReturnUser(username string) (models.ReturnUser, error) {
 // ToReturnUser - gets a user as a return user
 func ToReturnUser(user models.User) models.ReturnUser {
 	return models.ReturnUser{
-		UserName: user.UserName,
-		Networks: user.Networks,
-		IsAdmin:  user.IsAdmin,
-		Groups:   user.Groups,
+		UserName:    user.UserName,
+		Networks:    user.Networks,
+		IsAdmin:     user.IsAdmin,
+		IsAuditor:   user.IsAuditor,
+		Groups:      user.Groups,
+		Tenant:      user.Tenant,
+		DisplayName: user.DisplayName,
+		Email:       user.Email,
+		Department:  user.Department,
+		AvatarURL:   user.AvatarURL,
+		Metadata:    user.Metadata,
+		Pending:     user.Pending,
 	}
 }
 
@@ -91,7 +99,7 @@ func InitializeNetUsers(network *models.Network) error {
 		}
 
 		if err = pro.CreateNetworkUser(network, &newUser); err != nil {
-			logger.Log(0, "failed to add network user settings to user", string(newUser.ID), "on network", network.NetID)
+			slog.Error("failed to add network user settings to user on network", "new_user_id", string(newUser.ID), "net_id", network.NetID)
 		}
 	}
 	return nil
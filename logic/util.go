@@ -13,7 +13,7 @@ import (
 
 	"github.com/c-robinson/iplib"
 	"github.com/gravitl/netmaker/database"
-	"github.com/gravitl/netmaker/logger"
+	"golang.org/x/exp/slog"
 )
 
 // IsBase64 - checks if a string is in base64 format
@@ -73,7 +73,7 @@ func RandomString(length int) string {
 	randombytes := make([]byte, length)
 	_, err := rand.Read(randombytes)
 	if err != nil {
-		logger.Log(0, "random string", err.Error())
+		slog.Error("random string", "error", err)
 		return ""
 	}
 	return base32.StdEncoding.EncodeToString(randombytes)[:length]
@@ -0,0 +1,233 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// WatchKindNode - watch events for nodes
+	WatchKindNode = "node"
+	// WatchKindHost - watch events for hosts
+	WatchKindHost = "host"
+
+	// number of node and host changes kept for watch requests to catch up from
+	maxWatchEvents = 1000
+)
+
+// ErrResourceVersionExpired - the requested resource version is older than the changes kept, the caller should list again
+var ErrResourceVersionExpired = errors.New("resource version is too old, list again")
+
+var (
+	watchMutex      sync.Mutex
+	resourceVersion int64
+	// oldestVersion - watches from before this version may have missed changes no longer kept
+	oldestVersion int64
+	watchEvents   []models.WatchEvent
+	// watchNotify - closed and replaced whenever a change is recorded, to wake up waiting watches
+	watchNotify = make(chan struct{})
+	// nodeNetworks, hostNetworks - the networks nodes and hosts were last seen in,
+	// so deletes are only reported to watches that could see the deleted object
+	nodeNetworks = make(map[string]string)
+	hostNetworks = make(map[string][]string)
+)
+
+func init() {
+	database.OnChange(recordWatchEvent)
+}
+
+// CurrentResourceVersion - returns the version of the latest node or host change seen by this server,
+// the revision of the store when it streams changes so versions are the same on every server
+func CurrentResourceVersion() int64 {
+	watchMutex.Lock()
+	defer watchMutex.Unlock()
+	return resourceVersion
+}
+
+// WaitForChanges - returns the changes of the given kind made after version along with the
+// current version, waiting until ctx is done if there are none yet
+func WaitForChanges(ctx context.Context, kind string, version int64) ([]models.WatchEvent, int64, error) {
+	shared := database.CanWatch()
+	for {
+		watchMutex.Lock()
+		current := resourceVersion
+		// without shared versions, a version ahead of ours was handed out before this server restarted,
+		// with them it was handed out by a server that saw the change first
+		if (version > current && !shared) || version < oldestVersion {
+			watchMutex.Unlock()
+			return nil, current, ErrResourceVersionExpired
+		}
+		events := []models.WatchEvent{}
+		for _, event := range watchEvents {
+			if event.ResourceVersion > version && event.Kind == kind {
+				events = append(events, event)
+			}
+		}
+		notify := watchNotify
+		watchMutex.Unlock()
+		if len(events) > 0 {
+			return events, current, nil
+		}
+		if current > version {
+			version = current
+		}
+		select {
+		case <-ctx.Done():
+			return events, current, nil
+		case <-notify:
+		}
+	}
+}
+
+// WatchStore - keeps the in memory caches, watch events and server settings in sync with changes made
+// by other servers sharing the same database; without a backend that supports watching only the settings are, by polling
+func WatchStore(ctx context.Context) {
+	loadWatchScopes()
+	if !database.CanWatch() {
+		go pollServerSettings(ctx)
+		return
+	}
+	revision, err := database.Revision(ctx)
+	if err != nil {
+		slog.Error("failed to read the store revision", "error", err)
+		return
+	}
+	watchMutex.Lock()
+	resourceVersion, oldestVersion = revision, revision
+	watchMutex.Unlock()
+	changes, err := database.Watch(ctx, revision,
+		database.HOSTS_TABLE_NAME,
+		database.NODES_TABLE_NAME,
+		database.EXT_CLIENT_TABLE_NAME,
+		database.NETWORKS_TABLE_NAME,
+		database.SERVERCONF_TABLE_NAME,
+	)
+	if err != nil {
+		slog.Error("failed to watch the store", "error", err)
+		return
+	}
+	go func() {
+		for change := range changes {
+			if change.Table == database.SERVERCONF_TABLE_NAME {
+				if change.Key == serverSettingsKey && change.Type == database.ChangePut {
+					if err := applyServerSettings(change.Value); err != nil {
+						slog.Error("failed to apply server settings", "error", err)
+					}
+				}
+				continue
+			}
+			applyChange(change)
+			recordWatchEvent(change)
+		}
+		if ctx.Err() == nil {
+			slog.Warn("stopped watching the store")
+		}
+	}()
+}
+
+// recordWatchEvent - keeps node and host changes around for watch requests
+func recordWatchEvent(change database.Change) {
+	var kind string
+	switch change.Table {
+	case database.NODES_TABLE_NAME:
+		kind = WatchKindNode
+	case database.HOSTS_TABLE_NAME:
+		kind = WatchKindHost
+	default:
+		return
+	}
+	// stores that stream changes report this server's writes too, with the revision every server shares
+	if change.Revision == 0 && database.CanWatch() {
+		return
+	}
+	watchMutex.Lock()
+	defer watchMutex.Unlock()
+	if change.Revision > 0 {
+		resourceVersion = change.Revision
+	} else {
+		resourceVersion++
+	}
+	watchEvents = append(watchEvents, models.WatchEvent{
+		Type:            string(change.Type),
+		Kind:            kind,
+		ID:              change.Key,
+		ResourceVersion: resourceVersion,
+		Networks:        updateWatchScope(kind, change),
+	})
+	if len(watchEvents) > 2*maxWatchEvents {
+		oldestVersion = watchEvents[len(watchEvents)-maxWatchEvents-1].ResourceVersion
+		watchEvents = append([]models.WatchEvent{}, watchEvents[len(watchEvents)-maxWatchEvents:]...)
+	}
+	close(watchNotify)
+	watchNotify = make(chan struct{})
+}
+
+// updateWatchScope - tracks the networks of a changed node or host, returning those it is or was in
+func updateWatchScope(kind string, change database.Change) []string {
+	if change.Key == "" {
+		// every record of the table was removed
+		if kind == WatchKindNode {
+			nodeNetworks = make(map[string]string)
+		} else {
+			hostNetworks = make(map[string][]string)
+		}
+		return nil
+	}
+	if kind == WatchKindNode {
+		network := nodeNetworks[change.Key]
+		if change.Type == database.ChangeDelete {
+			delete(nodeNetworks, change.Key)
+			return []string{network}
+		}
+		var node models.Node
+		if err := json.Unmarshal([]byte(change.Value), &node); err != nil {
+			return []string{network}
+		}
+		nodeNetworks[change.Key] = node.Network
+		// a host keeps the networks it left, so its deletion still reaches the watches that saw it
+		hostID := node.HostID.String()
+		if !slices.Contains(hostNetworks[hostID], node.Network) {
+			hostNetworks[hostID] = append(hostNetworks[hostID], node.Network)
+		}
+		return []string{node.Network}
+	}
+	networks := hostNetworks[change.Key]
+	if change.Type == database.ChangeDelete {
+		delete(hostNetworks, change.Key)
+		return networks
+	}
+	var host models.Host
+	if err := json.Unmarshal([]byte(change.Value), &host); err == nil {
+		for _, nodeID := range host.Nodes {
+			if network, ok := nodeNetworks[nodeID]; ok && !slices.Contains(networks, network) {
+				networks = append(networks, network)
+			}
+		}
+		hostNetworks[change.Key] = networks
+	}
+	return networks
+}
+
+// loadWatchScopes - learns the networks of the nodes and hosts that exist before any change is seen
+func loadWatchScopes() {
+	nodes, err := GetAllNodes()
+	if err != nil {
+		return
+	}
+	watchMutex.Lock()
+	defer watchMutex.Unlock()
+	for _, node := range nodes {
+		nodeNetworks[node.ID.String()] = node.Network
+		hostID := node.HostID.String()
+		if !slices.Contains(hostNetworks[hostID], node.Network) {
+			hostNetworks[hostID] = append(hostNetworks[hostID], node.Network)
+		}
+	}
+}
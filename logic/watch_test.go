@@ -0,0 +1,48 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitForChanges(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	version := CurrentResourceVersion()
+	node := models.Node{CommonNode: models.CommonNode{ID: uuid.New(), HostID: uuid.New(), Network: "watchnet"}}
+	data, _ := json.Marshal(&node)
+	assert.Nil(t, database.Insert(node.ID.String(), string(data), database.NODES_TABLE_NAME))
+	host := models.Host{ID: node.HostID, Nodes: []string{node.ID.String()}}
+	data, _ = json.Marshal(&host)
+	assert.Nil(t, database.Insert(host.ID.String(), string(data), database.HOSTS_TABLE_NAME))
+	assert.Nil(t, database.DeleteRecord(database.NODES_TABLE_NAME, node.ID.String()))
+	assert.Nil(t, database.DeleteRecord(database.HOSTS_TABLE_NAME, host.ID.String()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	t.Run("Nodes", func(t *testing.T) {
+		events, current, err := WaitForChanges(ctx, WatchKindNode, version)
+		assert.Nil(t, err)
+		assert.Equal(t, version+4, current)
+		assert.Equal(t, 2, len(events))
+		assert.Equal(t, string(database.ChangeDelete), events[1].Type)
+		// the network of a deleted node is still known, to decide who may see the delete
+		assert.Equal(t, []string{"watchnet"}, events[1].Networks)
+	})
+	t.Run("Hosts", func(t *testing.T) {
+		events, _, err := WaitForChanges(ctx, WatchKindHost, version)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(events))
+		assert.Equal(t, []string{"watchnet"}, events[1].Networks, "hosts keep the networks they left")
+	})
+	t.Run("Expired", func(t *testing.T) {
+		_, _, err := WaitForChanges(ctx, WatchKindNode, version+5)
+		assert.ErrorIs(t, err, ErrResourceVersionExpired)
+	})
+}
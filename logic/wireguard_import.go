@@ -0,0 +1,340 @@
+package logic
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gravitl/netmaker/models"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// ErrNoWireGuardConfigs - an import had no config files
+var ErrNoWireGuardConfigs = errors.New("no wireguard configs to import")
+
+// ImportWireGuardConfigs - turns the devices of wg-quick configs into static peers of a network:
+// each [Peer] and, when its private key is present, the [Interface] of every file.
+// A device seen in several files is imported once. Allowed ips other than the device's old tunnel
+// address become the ranges routed to it, ranges that would clash with the network are reported and skipped.
+func ImportWireGuardConfigs(network string, request *models.WireGuardImportRequest) (models.WireGuardImportResult, error) {
+	result := models.WireGuardImportResult{
+		DryRun:    request.DryRun,
+		Created:   []models.StaticPeer{},
+		Conflicts: []models.WireGuardImportConflict{},
+	}
+	if len(request.Configs) == 0 {
+		return result, ErrNoWireGuardConfigs
+	}
+	current, err := GetNetwork(network)
+	if err != nil {
+		return result, err
+	}
+	var devices []*wgQuickDevice
+	byKey := map[string]*wgQuickDevice{}
+	for _, file := range request.Configs {
+		parsed, conflicts := parseWGQuickConfig(file)
+		result.Conflicts = append(result.Conflicts, conflicts...)
+		for _, device := range parsed {
+			if existing, ok := byKey[device.publicKey]; ok {
+				existing.merge(device)
+				continue
+			}
+			byKey[device.publicKey] = device
+			devices = append(devices, device)
+		}
+	}
+	// ranges already routed in the network, imported ranges are added as they are accepted
+	routed := networkRoutedRanges(&current)
+	for _, device := range devices {
+		if staticPeerKeyInUse(device.publicKey) {
+			result.Conflicts = append(result.Conflicts, device.conflict("", "public key is already used by a host, ext client or static peer"))
+			continue
+		}
+		peer := models.StaticPeer{
+			Name:                device.name,
+			Network:             network,
+			PublicKey:           device.publicKey,
+			Endpoint:            device.endpoint,
+			PersistentKeepalive: device.keepalive,
+		}
+		for _, allowedIP := range device.allowedIPs {
+			if reason := routed.clash(allowedIP); reason != "" {
+				result.Conflicts = append(result.Conflicts, device.conflict(allowedIP.String(), reason))
+				continue
+			}
+			routed.add(allowedIP, "static peer "+device.name)
+			peer.AllowedIPs = append(peer.AllowedIPs, allowedIP.String())
+		}
+		if !request.DryRun {
+			if err := CreateStaticPeer(&peer); err != nil {
+				result.Conflicts = append(result.Conflicts, device.conflict("", err.Error()))
+				continue
+			}
+		}
+		result.Created = append(result.Created, peer)
+	}
+	return result, nil
+}
+
+// == private ==
+
+// wgQuickDevice - a device found in a wg-quick config
+type wgQuickDevice struct {
+	file       string
+	name       string
+	publicKey  string
+	endpoint   string
+	keepalive  int32
+	allowedIPs []net.IPNet
+}
+
+func (d *wgQuickDevice) conflict(cidr, reason string) models.WireGuardImportConflict {
+	return models.WireGuardImportConflict{File: d.file, Peer: d.name, PublicKey: d.publicKey, Range: cidr, Reason: reason}
+}
+
+// merge - fills in what another file knows about the same device
+func (d *wgQuickDevice) merge(other *wgQuickDevice) {
+	if d.endpoint == "" {
+		d.endpoint = other.endpoint
+	}
+	if other.keepalive > d.keepalive {
+		d.keepalive = other.keepalive
+	}
+	for _, allowedIP := range other.allowedIPs {
+		found := false
+		for _, existing := range d.allowedIPs {
+			if existing.String() == allowedIP.String() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			d.allowedIPs = append(d.allowedIPs, allowedIP)
+		}
+	}
+}
+
+// parseWGQuickConfig - the devices of a wg-quick config, a "# name" comment above or inside a [Peer] names it
+func parseWGQuickConfig(file models.WireGuardConfigFile) ([]*wgQuickDevice, []models.WireGuardImportConflict) {
+	fileName := strings.TrimSuffix(path.Base(file.Name), ".conf")
+	if fileName == "" || fileName == "." || fileName == "/" {
+		fileName = "wireguard"
+	}
+	var conflicts []models.WireGuardImportConflict
+	var peers []*wgQuickDevice
+	var tunnels []net.IPNet
+	var iface *wgQuickDevice
+	var current *wgQuickDevice
+	section, comment := "", ""
+	scanner := bufio.NewScanner(strings.NewReader(file.Content))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		switch {
+		case text == "":
+			continue
+		case strings.HasPrefix(text, "#"):
+			comment = strings.TrimSpace(strings.TrimLeft(text, "#"))
+			if _, value, found := strings.Cut(comment, "="); found {
+				// such as "# Name = laptop" or "# friendly_name = laptop"
+				comment = strings.TrimSpace(value)
+			}
+			if section == "peer" && current != nil && current.name == "" {
+				// a name comment inside the [Peer]
+				current.name = comment
+			}
+			continue
+		case strings.HasPrefix(text, "["):
+			section = strings.ToLower(strings.Trim(text, "[] "))
+			current = nil
+			switch section {
+			case "interface":
+				iface = &wgQuickDevice{file: file.Name, name: fileName}
+				current = iface
+			case "peer":
+				current = &wgQuickDevice{file: file.Name, name: comment}
+				peers = append(peers, current)
+			}
+			comment = ""
+			continue
+		}
+		comment = ""
+		key, value, found := strings.Cut(text, "=")
+		if !found || current == nil {
+			conflicts = append(conflicts, models.WireGuardImportConflict{File: file.Name, Reason: fmt.Sprintf("line %d is not a setting of an [Interface] or [Peer]", line)})
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		switch {
+		case section == "interface" && key == "privatekey":
+			if privateKey, err := wgtypes.ParseKey(value); err == nil {
+				current.publicKey = privateKey.PublicKey().String()
+			} else {
+				conflicts = append(conflicts, models.WireGuardImportConflict{File: file.Name, Peer: current.name, Reason: "invalid private key, the interface is skipped"})
+			}
+		case section == "interface" && key == "address":
+			for _, address := range splitWGQuickList(value) {
+				if _, tunnel, err := net.ParseCIDR(address); err == nil {
+					tunnels = append(tunnels, *tunnel)
+				}
+			}
+		case section == "peer" && key == "publickey":
+			current.publicKey = value
+		case section == "peer" && key == "endpoint":
+			current.endpoint = value
+		case section == "peer" && key == "persistentkeepalive":
+			if keepalive, err := strconv.Atoi(value); err == nil && keepalive >= 0 && keepalive <= 1000 {
+				current.keepalive = int32(keepalive)
+			}
+		case section == "peer" && key == "allowedips":
+			for _, allowedIP := range splitWGQuickList(value) {
+				cidr, err := parseAllowedIP(allowedIP)
+				if err != nil {
+					conflicts = append(conflicts, current.conflict(allowedIP, "not an ip or cidr"))
+					continue
+				}
+				current.allowedIPs = append(current.allowedIPs, cidr)
+			}
+		}
+	}
+	var devices []*wgQuickDevice
+	if iface != nil && iface.publicKey != "" {
+		devices = append(devices, iface)
+	}
+	for i, peer := range peers {
+		if peer.name == "" {
+			peer.name = fmt.Sprintf("%s-peer%d", fileName, i+1)
+		}
+		peer.name = staticPeerName(peer.name)
+		if _, err := wgtypes.ParseKey(peer.publicKey); err != nil {
+			conflicts = append(conflicts, peer.conflict("", "missing or invalid public key, the peer is skipped"))
+			continue
+		}
+		if peer.endpoint != "" {
+			if _, port, err := net.SplitHostPort(peer.endpoint); err != nil || port == "" {
+				conflicts = append(conflicts, peer.conflict("", fmt.Sprintf("endpoint %q is not host:port, imported without it", peer.endpoint)))
+				peer.endpoint = ""
+			}
+		}
+		var routed []net.IPNet
+		for _, allowedIP := range peer.allowedIPs {
+			if inTunnel(allowedIP, tunnels) {
+				// the peer's address on the old tunnel, or the tunnel itself, replaced by the network's range
+				continue
+			}
+			routed = append(routed, allowedIP)
+		}
+		peer.allowedIPs = routed
+		devices = append(devices, peer)
+	}
+	return devices, conflicts
+}
+
+func splitWGQuickList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseAllowedIP - a cidr, a bare address being a single host
+func parseAllowedIP(value string) (net.IPNet, error) {
+	if !strings.Contains(value, "/") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return net.IPNet{}, fmt.Errorf("invalid ip %q", value)
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			return net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+		}
+		return net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+	}
+	_, cidr, err := net.ParseCIDR(value)
+	if err != nil {
+		return net.IPNet{}, err
+	}
+	return *cidr, nil
+}
+
+// inTunnel - checks if a range lies within one of the tunnel subnets of a config
+func inTunnel(cidr net.IPNet, tunnels []net.IPNet) bool {
+	for _, tunnel := range tunnels {
+		tunnelOnes, tunnelBits := tunnel.Mask.Size()
+		ones, bits := cidr.Mask.Size()
+		if bits == tunnelBits && ones >= tunnelOnes && tunnel.Contains(cidr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// staticPeerName - a name within the length static peer names are limited to
+func staticPeerName(name string) string {
+	name = strings.TrimSpace(name)
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return name
+}
+
+// routedRanges - the ranges already routed in a network and what routes them
+type routedRanges struct {
+	ranges []net.IPNet
+	owners []string
+}
+
+// networkRoutedRanges - a network's address ranges and the egress ranges of its nodes and static peers
+func networkRoutedRanges(network *models.Network) *routedRanges {
+	routed := &routedRanges{}
+	for _, addressRange := range []string{network.AddressRange, network.AddressRange6} {
+		if _, cidr, err := net.ParseCIDR(addressRange); err == nil {
+			routed.add(*cidr, "the network's address range")
+		}
+	}
+	if nodes, err := GetNetworkNodes(network.NetID); err == nil {
+		for _, node := range nodes {
+			if !node.IsEgressGateway {
+				continue
+			}
+			for _, egressRange := range node.EgressGatewayRanges {
+				if _, cidr, err := net.ParseCIDR(egressRange); err == nil {
+					routed.add(*cidr, "an egress gateway")
+				}
+			}
+		}
+	}
+	if peers, err := GetNetworkStaticPeers(network.NetID); err == nil {
+		for _, peer := range peers {
+			for _, allowedIP := range peer.AllowedIPs {
+				if _, cidr, err := net.ParseCIDR(allowedIP); err == nil {
+					routed.add(*cidr, "static peer "+peer.Name)
+				}
+			}
+		}
+	}
+	return routed
+}
+
+func (r *routedRanges) add(cidr net.IPNet, owner string) {
+	r.ranges = append(r.ranges, cidr)
+	r.owners = append(r.owners, owner)
+}
+
+// clash - why a range can't be routed to a static peer, empty if it can
+func (r *routedRanges) clash(cidr net.IPNet) string {
+	if ones, _ := cidr.Mask.Size(); ones == 0 {
+		return "a default route can't be routed to a static peer, use an internet gateway instead"
+	}
+	for i, existing := range r.ranges {
+		if existing.Contains(cidr.IP) || cidr.Contains(existing.IP) {
+			return "overlaps " + existing.String() + " of " + r.owners[i]
+		}
+	}
+	return ""
+}
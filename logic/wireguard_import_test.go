@@ -0,0 +1,49 @@
+package logic
+
+import (
+	"net"
+	"testing"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestParseWGQuickConfig(t *testing.T) {
+	privateKey, _ := wgtypes.GeneratePrivateKey()
+	laptopKey, _ := wgtypes.GeneratePrivateKey()
+	officeKey, _ := wgtypes.GeneratePrivateKey()
+	content := "[Interface]\nAddress = 10.8.0.1/24\nListenPort = 51820\nPrivateKey = " + privateKey.String() + "\n\n" +
+		"# laptop\n[Peer]\nPublicKey = " + laptopKey.PublicKey().String() + "\nAllowedIPs = 10.8.0.2/32\n\n" +
+		"[Peer]\n# Name = office\nPublicKey = " + officeKey.PublicKey().String() + "\nAllowedIPs = 10.8.0.3, 192.168.10.0/24\nEndpoint = office.example.com:51820\nPersistentKeepalive = 25\n\n" +
+		"[Peer]\nPublicKey = notakey\nAllowedIPs = 10.8.0.4/32\n"
+	devices, conflicts := parseWGQuickConfig(models.WireGuardConfigFile{Name: "/etc/wireguard/wg0.conf", Content: content})
+	assert.Equal(t, 3, len(devices))
+	assert.Equal(t, "wg0", devices[0].name)
+	assert.Equal(t, privateKey.PublicKey().String(), devices[0].publicKey)
+	assert.Equal(t, "laptop", devices[1].name)
+	assert.Empty(t, devices[1].allowedIPs)
+	assert.Equal(t, "office", devices[2].name)
+	assert.Equal(t, "office.example.com:51820", devices[2].endpoint)
+	assert.Equal(t, int32(25), devices[2].keepalive)
+	assert.Equal(t, 1, len(devices[2].allowedIPs))
+	assert.Equal(t, "192.168.10.0/24", devices[2].allowedIPs[0].String())
+	assert.Equal(t, 1, len(conflicts))
+	assert.Equal(t, "wg0-peer3", conflicts[0].Peer)
+}
+
+func TestRoutedRangesClash(t *testing.T) {
+	routed := &routedRanges{}
+	_, network, _ := net.ParseCIDR("10.10.0.0/16")
+	routed.add(*network, "the network's address range")
+	for cidr, clashes := range map[string]bool{
+		"10.10.5.0/24":   true,
+		"10.0.0.0/8":     true,
+		"0.0.0.0/0":      true,
+		"192.168.1.0/24": false,
+		"fd00::/64":      false,
+	} {
+		_, parsed, _ := net.ParseCIDR(cidr)
+		assert.Equal(t, clashes, routed.clash(*parsed) != "", cidr)
+	}
+}
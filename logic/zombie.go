@@ -5,8 +5,8 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
 )
 
 const (
@@ -29,7 +29,7 @@ var (
 func CheckZombies(newnode *models.Node) {
 	nodes, err := GetNetworkNodes(newnode.Network)
 	if err != nil {
-		logger.Log(1, "Failed to retrieve network nodes", newnode.Network, err.Error())
+		slog.Error("Failed to retrieve network nodes", "network", newnode.Network, "error", err)
 		return
 	}
 	for _, node := range nodes {
@@ -38,7 +38,7 @@ func CheckZombies(newnode *models.Node) {
 			continue
 		}
 		if node.HostID == newnode.HostID {
-			logger.Log(0, "adding ", node.ID.String(), " to zombie list")
+			slog.Info("adding to zombie list", "node_id", node.ID.String())
 			newZombie <- node.ID
 		}
 	}
@@ -49,7 +49,7 @@ func CheckZombies(newnode *models.Node) {
 func checkForZombieHosts(h *models.Host) {
 	hosts, err := GetAllHosts()
 	if err != nil {
-		logger.Log(3, "errror retrieving all hosts", err.Error())
+		slog.Warn("errror retrieving all hosts", "error", err)
 	}
 	for _, existing := range hosts {
 		if existing.ID == h.ID {
@@ -64,7 +64,7 @@ func checkForZombieHosts(h *models.Host) {
 			for _, node := range existing.Nodes {
 				id, err := uuid.Parse(node)
 				if err != nil {
-					logger.Log(3, "error parsing uuid from host.Nodes", err.Error())
+					slog.Warn("error parsing uuid from host.Nodes", "error", err)
 					continue
 				}
 				newHostZombie <- id
@@ -75,11 +75,13 @@ func checkForZombieHosts(h *models.Host) {
 
 // ManageZombies - goroutine which adds/removes/deletes nodes from the zombie node quarantine list
 func ManageZombies(ctx context.Context, peerUpdate chan *models.Node) {
-	logger.Log(2, "Zombie management started")
+	slog.DebugCtx(ctx, "Zombie management started")
 	InitializeZombies()
 	for {
+		WorkerHeartbeat("zombies", time.Hour*ZOMBIE_TIMEOUT)
 		select {
 		case <-ctx.Done():
+			StopWorker("zombies")
 			close(peerUpdate)
 			return
 		case id := <-newZombie:
@@ -87,41 +89,41 @@ func ManageZombies(ctx context.Context, peerUpdate chan *models.Node) {
 		case id := <-newHostZombie:
 			hostZombies = append(hostZombies, id)
 		case <-time.After(time.Hour * ZOMBIE_TIMEOUT): // run this check 4 times a day
-			logger.Log(3, "checking for zombie nodes")
+			slog.DebugCtx(ctx, "checking for zombie nodes")
 			if len(zombies) > 0 {
 				for i := len(zombies) - 1; i >= 0; i-- {
 					node, err := GetNodeByID(zombies[i].String())
 					if err != nil {
-						logger.Log(1, "error retrieving zombie node", zombies[i].String(), err.Error())
-						logger.Log(1, "deleting ", node.ID.String(), " from zombie list")
+						slog.ErrorCtx(ctx, "error retrieving zombie node", "zombie", zombies[i].String(), "error", err)
+						slog.InfoCtx(ctx, "deleting from zombie list", "node_id", node.ID.String())
 						zombies = append(zombies[:i], zombies[i+1:]...)
 						continue
 					}
 					if time.Since(node.LastCheckIn) > time.Minute*ZOMBIE_DELETE_TIME {
 						if err := DeleteNode(&node, true); err != nil {
-							logger.Log(1, "error deleting zombie node", zombies[i].String(), err.Error())
+							slog.ErrorCtx(ctx, "error deleting zombie node", "zombie", zombies[i].String(), "error", err)
 							continue
 						}
 						node.Action = models.NODE_DELETE
 						peerUpdate <- &node
-						logger.Log(1, "deleting zombie node", node.ID.String())
+						slog.InfoCtx(ctx, "deleting zombie node", "node_id", node.ID.String())
 						zombies = append(zombies[:i], zombies[i+1:]...)
 					}
 				}
 			}
 			if len(hostZombies) > 0 {
-				logger.Log(3, "checking host zombies")
+				slog.DebugCtx(ctx, "checking host zombies")
 				for i := len(hostZombies) - 1; i >= 0; i-- {
 					host, err := GetHost(hostZombies[i].String())
 					if err != nil {
-						logger.Log(1, "error retrieving zombie host", err.Error())
-						logger.Log(1, "deleting ", host.ID.String(), " from zombie list")
+						slog.ErrorCtx(ctx, "error retrieving zombie host", "error", err)
+						slog.InfoCtx(ctx, "deleting from zombie list", "host_id", host.ID.String())
 						zombies = append(zombies[:i], zombies[i+1:]...)
 						continue
 					}
 					if len(host.Nodes) == 0 {
 						if err := RemoveHost(host, true); err != nil {
-							logger.Log(0, "error deleting zombie host", host.ID.String(), err.Error())
+							slog.ErrorCtx(ctx, "error deleting zombie host", "host_id", host.ID.String(), "error", err)
 						}
 					}
 				}
@@ -134,13 +136,13 @@ func ManageZombies(ctx context.Context, peerUpdate chan *models.Node) {
 func InitializeZombies() {
 	nodes, err := GetAllNodes()
 	if err != nil {
-		logger.Log(1, "failed to retrieve nodes", err.Error())
+		slog.Error("failed to retrieve nodes", "error", err)
 		return
 	}
 	for _, node := range nodes {
 		othernodes, err := GetNetworkNodes(node.Network)
 		if err != nil {
-			logger.Log(1, "failled to retrieve nodes for network", node.Network, err.Error())
+			slog.Error("failled to retrieve nodes for network", "network", node.Network, "error", err)
 			continue
 		}
 		for _, othernode := range othernodes {
@@ -150,10 +152,10 @@ func InitializeZombies() {
 			if node.HostID == othernode.HostID {
 				if node.LastCheckIn.After(othernode.LastCheckIn) {
 					newZombie <- othernode.ID
-					logger.Log(1, "adding", othernode.ID.String(), "to zombie list")
+					slog.Info("adding to zombie list", "othernode_id", othernode.ID.String())
 				} else {
 					newZombie <- node.ID
-					logger.Log(1, "adding", node.ID.String(), "to zombie list")
+					slog.Info("adding to zombie list", "node_id", node.ID.String())
 				}
 			}
 		}
@@ -0,0 +1,241 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic/acls"
+	"github.com/gravitl/netmaker/logic/acls/nodeacls"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+var (
+	// ErrInvalidZones - the zones of a network are malformed or a policy names an unknown zone
+	ErrInvalidZones = errors.New("invalid zones")
+	// ErrUnknownZonePreset - no zone preset has the name asked for
+	ErrUnknownZonePreset = errors.New("unknown zone preset")
+)
+
+// zonePresets - the zones and policies networks can start from
+var zonePresets = map[string]models.NetworkZones{
+	models.ZonePresetStandard: {
+		Zones: []models.Zone{
+			{Name: "prod", Tags: []string{"prod"}},
+			{Name: "staging", Tags: []string{"staging"}},
+			{Name: "user-devices", Tags: []string{"user-devices"}},
+		},
+		Policies: []models.ZonePolicy{
+			{From: "prod", To: "staging", Action: models.ZoneDeny},
+			{From: "user-devices", To: "prod", Action: models.ZoneAllow, Ports: []string{"443"}},
+		},
+	},
+}
+
+// GetZonePreset - a copy of the zones and policies of a preset
+func GetZonePreset(name string) (*models.NetworkZones, error) {
+	preset, ok := zonePresets[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownZonePreset, name)
+	}
+	zones := models.NetworkZones{
+		Zones:    append([]models.Zone{}, preset.Zones...),
+		Policies: append([]models.ZonePolicy{}, preset.Policies...),
+		Default:  preset.Default,
+	}
+	return &zones, nil
+}
+
+// SetNetworkZones - sets the zones of a network and compiles them into the ACLs of its nodes, nil removes
+// the zones leaving the ACLs as they are; a dry run only reports what the zones compile to
+func SetNetworkZones(netID string, zones *models.NetworkZones, dryRun bool) (models.ZoneCompileResult, error) {
+	result := models.ZoneCompileResult{Network: netID, DryRun: dryRun, Zones: zones, Members: map[string][]string{}, Notes: []string{}}
+	if zones != nil {
+		if err := validateZones(zones); err != nil {
+			return result, err
+		}
+	}
+	network, err := GetNetwork(netID)
+	if err != nil {
+		return result, err
+	}
+	if !dryRun {
+		current := network
+		network.Zones = zones
+		if _, _, _, _, _, err = UpdateNetwork(&current, &network); err != nil {
+			return result, err
+		}
+	}
+	if zones == nil {
+		return result, nil
+	}
+	return compileNetworkZones(netID, zones, dryRun)
+}
+
+// ApplyNetworkZones - compiles the zones of a network into the ACLs of its nodes again, eg. after nodes were tagged;
+// without zones nothing changes
+func ApplyNetworkZones(netID string, dryRun bool) (models.ZoneCompileResult, error) {
+	network, err := GetNetwork(netID)
+	if err != nil {
+		return models.ZoneCompileResult{}, err
+	}
+	if network.Zones == nil {
+		return models.ZoneCompileResult{Network: netID, DryRun: dryRun, Members: map[string][]string{}, Notes: []string{}}, nil
+	}
+	return compileNetworkZones(netID, network.Zones, dryRun)
+}
+
+// == private ==
+
+func validateZones(zones *models.NetworkZones) error {
+	if err := validator.New().Struct(zones); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidZones, err.Error())
+	}
+	names := map[string]bool{}
+	for _, zone := range zones.Zones {
+		if names[zone.Name] {
+			return fmt.Errorf("%w: zone %s is defined twice", ErrInvalidZones, zone.Name)
+		}
+		names[zone.Name] = true
+	}
+	for _, policy := range zones.Policies {
+		for _, name := range []string{policy.From, policy.To} {
+			if !names[name] {
+				return fmt.Errorf("%w: policy names unknown zone %s", ErrInvalidZones, name)
+			}
+		}
+		for _, ports := range policy.Ports {
+			if !validPortRange(ports) {
+				return fmt.Errorf("%w: policy %s -> %s has invalid ports %q", ErrInvalidZones, policy.From, policy.To, ports)
+			}
+		}
+	}
+	return nil
+}
+
+// compileNetworkZones - sets the ACL of every pair of a network's nodes in zones to what the zones compile to
+func compileNetworkZones(netID string, zones *models.NetworkZones, dryRun bool) (models.ZoneCompileResult, error) {
+	result := models.ZoneCompileResult{Network: netID, DryRun: dryRun, Zones: zones}
+	nodes, err := GetNetworkNodes(netID)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return result, err
+	}
+	var access map[[2]string]bool
+	result.Members, access, result.Notes = compileZones(zones, nodes)
+	if IsNetworkLocked(netID) {
+		// the zones are compiled again on unlock
+		result.Notes = append(result.Notes, "network is locked down, its acls are left as they are")
+		return result, nil
+	}
+	container, err := nodeacls.FetchAllACLs(nodeacls.NetworkID(netID))
+	if err != nil {
+		return result, err
+	}
+	for pair, allowed := range access {
+		if allowed {
+			result.Allowed++
+		} else {
+			result.Denied++
+		}
+		value := acls.NotAllowed
+		if allowed {
+			value = acls.Allowed
+		}
+		a, b := acls.AclID(pair[0]), acls.AclID(pair[1])
+		current, ok := container[a][b]
+		if !ok {
+			// nodes without an ACL yet get theirs from the network default when they finish joining
+			continue
+		}
+		if current == value {
+			continue
+		}
+		result.Changed++
+		if !dryRun {
+			container.ChangeAccess(a, b, value)
+		}
+	}
+	if dryRun || result.Changed == 0 {
+		return result, nil
+	}
+	if _, err := container.Save(acls.ContainerID(netID)); err != nil {
+		return result, err
+	}
+	slog.Info("compiled zones into node acls", "network", netID, "changed", result.Changed)
+	return result, nil
+}
+
+// compileZones - the nodes of each zone, whether each pair of nodes in zones may reach each other and notes
+// on what the node ACLs can't express; nodes sharing a zone may, otherwise a deny between any of their zones
+// wins over an allow, and the default only applies when none of their zones have a policy between them
+func compileZones(zones *models.NetworkZones, nodes []models.Node) (map[string][]string, map[[2]string]bool, []string) {
+	members := map[string][]string{}
+	nodeZones := map[string][]string{}
+	ids := []string{}
+	for _, zone := range zones.Zones {
+		members[zone.Name] = []string{}
+	}
+	for _, node := range nodes {
+		id := node.ID.String()
+		for _, zone := range zones.Zones {
+			for _, tag := range zone.Tags {
+				if StringSliceContains(node.Tags, tag) {
+					members[zone.Name] = append(members[zone.Name], id)
+					nodeZones[id] = append(nodeZones[id], zone.Name)
+					break
+				}
+			}
+		}
+		if len(nodeZones[id]) > 0 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	policies := map[[2]string]string{}
+	notes := []string{}
+	for _, policy := range zones.Policies {
+		for _, pair := range [][2]string{{policy.From, policy.To}, {policy.To, policy.From}} {
+			if policies[pair] != models.ZoneDeny {
+				policies[pair] = policy.Action
+			}
+		}
+		if policy.From != policy.To {
+			notes = append(notes, fmt.Sprintf("%s %s -> %s applies both ways", policy.Action, policy.From, policy.To))
+		}
+		if policy.Action == models.ZoneAllow && len(policy.Ports) > 0 {
+			notes = append(notes, fmt.Sprintf("ports %s of %s -> %s widened to all ports", strings.Join(policy.Ports, ","), policy.From, policy.To))
+		}
+	}
+	access := map[[2]string]bool{}
+	for i, a := range ids {
+		for _, b := range ids[i+1:] {
+			access[[2]string{a, b}] = zonesMayConnect(nodeZones[a], nodeZones[b], policies, zones.Default)
+		}
+	}
+	return members, access, notes
+}
+
+// zonesMayConnect - checks if a node in zones a may reach a node in zones b
+func zonesMayConnect(a, b []string, policies map[[2]string]string, defaultAction string) bool {
+	for _, zone := range a {
+		if StringSliceContains(b, zone) {
+			return true
+		}
+	}
+	allowed := false
+	for _, za := range a {
+		for _, zb := range b {
+			switch policies[[2]string{za, zb}] {
+			case models.ZoneDeny:
+				return false
+			case models.ZoneAllow:
+				allowed = true
+			}
+		}
+	}
+	return allowed || defaultAction != models.ZoneDeny
+}
@@ -0,0 +1,53 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileZones(t *testing.T) {
+	zones, err := GetZonePreset(models.ZonePresetStandard)
+	assert.Nil(t, err)
+	assert.Nil(t, validateZones(zones))
+	node := func(tags ...string) models.Node {
+		return models.Node{CommonNode: models.CommonNode{ID: uuid.New()}, Tags: tags}
+	}
+	prod, prod2, staging, laptop, untagged := node("prod"), node("prod"), node("staging"), node("user-devices"), node()
+	members, access, notes := compileZones(zones, []models.Node{prod, prod2, staging, laptop, untagged})
+	allowed := func(a, b models.Node) (bool, bool) {
+		pair := [2]string{a.ID.String(), b.ID.String()}
+		if pair[1] < pair[0] {
+			pair = [2]string{pair[1], pair[0]}
+		}
+		value, ok := access[pair]
+		return value, ok
+	}
+
+	assert.ElementsMatch(t, []string{prod.ID.String(), prod2.ID.String()}, members["prod"])
+	for _, pair := range [][2]models.Node{{prod, prod2}, {laptop, prod}, {laptop, staging}} {
+		value, ok := allowed(pair[0], pair[1])
+		assert.True(t, ok && value)
+	}
+	value, ok := allowed(prod, staging)
+	assert.True(t, ok)
+	assert.False(t, value, "prod and staging are kept apart")
+	_, ok = allowed(prod, untagged)
+	assert.False(t, ok, "nodes of no zone keep their acls")
+	assert.Contains(t, notes, "ports 443 of user-devices -> prod widened to all ports")
+
+	t.Run("DefaultDeny", func(t *testing.T) {
+		zones.Default = models.ZoneDeny
+		_, access, _ = compileZones(zones, []models.Node{prod, staging, laptop})
+		value, _ := allowed(laptop, staging)
+		assert.False(t, value)
+		value, _ = allowed(laptop, prod)
+		assert.True(t, value, "an allow policy wins over the default")
+	})
+	t.Run("UnknownZone", func(t *testing.T) {
+		zones.Policies = append(zones.Policies, models.ZonePolicy{From: "prod", To: "dev", Action: models.ZoneDeny})
+		assert.ErrorIs(t, validateZones(zones), ErrInvalidZones)
+	})
+}
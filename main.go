@@ -2,15 +2,18 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"runtime/debug"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/gravitl/netmaker/auth"
 	"github.com/gravitl/netmaker/config"
@@ -24,8 +27,10 @@ import (
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/mq"
 	"github.com/gravitl/netmaker/netclient/ncutils"
+	"github.com/gravitl/netmaker/secrets"
 	"github.com/gravitl/netmaker/servercfg"
 	"github.com/gravitl/netmaker/serverctl"
+	"github.com/gravitl/netmaker/tracing"
 	"golang.org/x/exp/slog"
 )
 
@@ -34,13 +39,45 @@ var version = "v0.20.6"
 // Start DB Connection and start API Request Handler
 func main() {
 	absoluteConfigPath := flag.String("c", "", "absolute path to configuration file")
+	migrateOnly := flag.Bool("migrate", false, "apply pending database migrations and exit")
+	migrateDown := flag.Int("migrate-down", -1, "revert the data migrations applied after the given version and exit")
+	schemaDown := flag.Int("migrate-schema-down", -1, "revert the postgres schema migrations applied after the given version and exit")
+	reEncrypt := flag.Bool("reencrypt", false, "rotate the data encryption key, re-encrypt sensitive data and exit")
+	sealSecret := flag.Bool("seal", false, "encrypt a secret read from stdin for use in the config and exit")
+	recoveryToken := flag.Bool("recovery-token", false, "generate a one-time recovery token to create or reset a superadmin and exit")
+	validateOnly := flag.Bool("validate", false, "check the config, database, broker, oauth and certificate files, report every problem and exit")
 	flag.Parse()
 	setupConfig(*absoluteConfigPath)
 	servercfg.SetVersion(version)
+	if *validateOnly {
+		runValidation()
+		return
+	}
+	if *migrateOnly {
+		runMigrations()
+		return
+	}
+	if *migrateDown >= 0 || *schemaDown >= 0 {
+		runDowngrade(*migrateDown, *schemaDown)
+		return
+	}
+	if *recoveryToken {
+		runRecoveryToken()
+		return
+	}
+	if *reEncrypt || *sealSecret {
+		runEncryption(*reEncrypt)
+		return
+	}
 	fmt.Println(models.RetrieveLogo()) // print the logo
 	initialize()                       // initial db and acls
 	setGarbageCollection()
 	setVerbosity()
+	shutdownTracing, err := tracing.Init(context.Background())
+	if err != nil {
+		logger.FatalLog("error initializing tracing: ", err.Error())
+	}
+	defer shutdownTracing(context.Background())
 	if servercfg.DeployedByOperator() && !servercfg.Is_EE {
 		logic.SetFreeTierLimits()
 	}
@@ -50,25 +87,174 @@ func main() {
 	var waitGroup sync.WaitGroup
 	startControllers(&waitGroup, ctx) // start the api endpoint and mq and stun
 	<-ctx.Done()
-	waitGroup.Wait()
+	shutdown(&waitGroup)
+}
+
+// shutdown - stops serving and flushes pending work within the grace period,
+// so a rolling restart doesn't drop peer updates mid-flight
+func shutdown(wg *sync.WaitGroup) {
+	logic.SetDraining()
+	// the API keeps serving for the drain delay before its grace period starts
+	gracePeriod := servercfg.GetShutdownGracePeriod()
+	drainDelay := servercfg.GetShutdownDrainDelay()
+	slog.Info("shutting down, draining", "drain_delay", drainDelay.String(), "grace_period", gracePeriod.String())
+	drainCtx, cancel := context.WithTimeout(context.Background(), drainDelay+gracePeriod)
+	defer cancel()
+	stopped := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-drainCtx.Done():
+		slog.Info("grace period expired before the API and background jobs stopped")
+	}
+	if mq.IsConnected() {
+		if err := mq.Drain(drainCtx); err != nil {
+			slog.Error("failed to flush MQ publishes", "error", err)
+		}
+		mq.CloseClient()
+	}
+	logic.RunShutdownHooks(drainCtx)
 }
 
 func setupConfig(absoluteConfigPath string) {
 	if len(absoluteConfigPath) > 0 {
 		cfg, err := config.ReadConfig(absoluteConfigPath)
 		if err != nil {
-			logger.Log(0, fmt.Sprintf("failed parsing config at: %s", absoluteConfigPath))
+			slog.Error(fmt.Sprintf("failed parsing config at: %s", absoluteConfigPath))
 			return
 		}
 		config.Config = cfg
 	}
 }
 
+// runMigrations - brings the database schema and records up to date without starting the server
+func runMigrations() {
+	if err := database.InitializeDatabase(); err != nil {
+		logger.FatalLog("Error connecting to database: ", err.Error())
+	}
+	defer database.CloseDB()
+	if err := secrets.Init(); err != nil {
+		logger.FatalLog("error initializing encryption: ", err.Error())
+	}
+	if err := migrate.Run(); err != nil {
+		logger.FatalLog("error migrating database: ", err.Error())
+	}
+	printMigrationStatus()
+	fmt.Println("migrations complete")
+}
+
+// runDowngrade - reverts migrations so the database can be used by an older release, a negative version is left as is
+func runDowngrade(dataVersion, schemaVersion int) {
+	if err := database.InitializeDatabase(); err != nil {
+		logger.FatalLog("Error connecting to database: ", err.Error())
+	}
+	defer database.CloseDB()
+	if err := secrets.Init(); err != nil {
+		logger.FatalLog("error initializing encryption: ", err.Error())
+	}
+	if dataVersion >= 0 {
+		if err := migrate.Down(dataVersion); err != nil {
+			logger.FatalLog("error reverting data migrations: ", err.Error())
+		}
+	}
+	if schemaVersion >= 0 {
+		if servercfg.GetDB() != "postgres" {
+			logger.FatalLog("schema migrations only apply to postgres")
+		}
+		if err := database.PGMigrateDown(schemaVersion); err != nil {
+			logger.FatalLog("error reverting schema migrations: ", err.Error())
+		}
+	}
+	printMigrationStatus()
+	fmt.Println("downgrade complete")
+}
+
+func printMigrationStatus() {
+	current, latest, err := migrate.Status()
+	if err != nil {
+		logger.FatalLog("error reading data migrations: ", err.Error())
+	}
+	fmt.Printf("data migrations at version %d of %d\n", current, latest)
+	if servercfg.GetDB() == "postgres" {
+		current, latest, err := database.PGMigrationStatus()
+		if err != nil {
+			logger.FatalLog("error reading schema version: ", err.Error())
+		}
+		fmt.Printf("database schema at version %d of %d\n", current, latest)
+	}
+}
+
+// runRecoveryToken - prints a new recovery token without starting the server, replacing the one printed at startup
+func runRecoveryToken() {
+	if err := database.InitializeDatabase(); err != nil {
+		logger.FatalLog("Error connecting to database: ", err.Error())
+	}
+	defer database.CloseDB()
+	token, err := logic.GenerateRecoveryToken()
+	if err != nil {
+		logger.FatalLog("error generating recovery token: ", err.Error())
+	}
+	fmt.Println(token)
+}
+
+// runValidation - reports every problem with the config without starting the server,
+// exiting non-zero when the server couldn't start or serve with it
+func runValidation() {
+	validation := serverctl.ValidateConfig(false)
+	for _, problem := range validation.Problems {
+		level := "warning"
+		if problem.Fatal {
+			level = "error"
+		}
+		if problem.Setting != "" {
+			fmt.Printf("%s: [%s] %s: %s\n", level, problem.Check, problem.Setting, problem.Message)
+		} else {
+			fmt.Printf("%s: [%s] %s\n", level, problem.Check, problem.Message)
+		}
+	}
+	if !validation.Valid {
+		fmt.Println("config is invalid")
+		os.Exit(1)
+	}
+	fmt.Println("config is valid")
+}
+
+// runEncryption - re-encrypts sensitive data with a new data key, or seals a secret read from stdin
+func runEncryption(reEncrypt bool) {
+	if err := database.InitializeDatabase(); err != nil {
+		logger.FatalLog("Error connecting to database: ", err.Error())
+	}
+	defer database.CloseDB()
+	if err := secrets.Init(); err != nil {
+		logger.FatalLog("error initializing encryption: ", err.Error())
+	}
+	if reEncrypt {
+		count, err := secrets.ReEncrypt()
+		if err != nil {
+			logger.FatalLog("error re-encrypting data: ", err.Error())
+		}
+		fmt.Printf("re-encrypted %d records\n", count)
+		return
+	}
+	secret, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		logger.FatalLog("error reading secret: ", err.Error())
+	}
+	sealed, err := secrets.Seal(strings.TrimRight(secret, "\r\n"))
+	if err != nil {
+		logger.FatalLog("error encrypting secret: ", err.Error())
+	}
+	fmt.Println(sealed)
+}
+
 func initialize() { // Client Mode Prereq Check
 	var err error
 
 	if servercfg.GetMasterKey() == "" {
-		logger.Log(0, "warning: MASTER_KEY not set, this could make account recovery difficult")
+		slog.Info("warning: MASTER_KEY not set, this could make account recovery difficult")
 	}
 
 	if servercfg.GetNodeID() == "" {
@@ -78,26 +264,45 @@ func initialize() { // Client Mode Prereq Check
 	if err = database.InitializeDatabase(); err != nil {
 		logger.FatalLog("Error connecting to database: ", err.Error())
 	}
-	logger.Log(0, "database successfully connected")
-	migrate.Run()
+	slog.Info("database successfully connected")
+	if err = secrets.Init(); err != nil {
+		logger.FatalLog("error initializing encryption: ", err.Error())
+	}
+	if err = migrate.Run(); err != nil {
+		logger.FatalLog("error migrating database: ", err.Error())
+	}
+	if err = logic.LoadServerSettings(); err != nil {
+		slog.Error("failed to load server settings", "error", err)
+	}
 
 	logic.SetJWTSecret()
+	if servercfg.IsRecoveryAccessEnabled() {
+		if token, err := logic.GenerateRecoveryToken(); err != nil {
+			slog.Error("failed to generate recovery token", "error", err)
+		} else {
+			// never through the logger, its dump is served by the logs api
+			fmt.Fprintln(os.Stderr, "recovery token, valid once until the next restart:", token)
+		}
+	}
 
 	if err = pro.InitializeGroups(); err != nil {
-		logger.Log(0, "could not initialize default user group, \"*\"")
+		slog.Error("could not initialize default user group, \"*\"")
 	}
 
 	err = logic.TimerCheckpoint()
 	if err != nil {
-		logger.Log(1, "Timer error occurred: ", err.Error())
+		slog.Error("Timer error occurred", "error", err)
 	}
 	logic.EnterpriseCheck()
+	if err = logic.LoadExtensionPlugins(servercfg.GetExtensionsDir()); err != nil {
+		logger.FatalLog("error loading extensions: ", err.Error())
+	}
 
 	var authProvider = auth.InitializeAuthProvider()
 	if authProvider != "" {
-		logger.Log(0, "OAuth provider,", authProvider+",", "initialized")
+		slog.Info("OAuth provider initialized", "provider", authProvider)
 	} else {
-		logger.Log(0, "no OAuth provider found or not configured, continuing without OAuth")
+		slog.Info("no OAuth provider found or not configured, continuing without OAuth")
 	}
 
 	err = serverctl.SetDefaults()
@@ -114,7 +319,7 @@ func initialize() { // Client Mode Prereq Check
 
 	if servercfg.IsMessageQueueBackend() {
 		if err = mq.ServerStartNotify(); err != nil {
-			logger.Log(0, "error occurred when notifying nodes of startup", err.Error())
+			slog.Error("error occurred when notifying nodes of startup", "error", err)
 		}
 	}
 }
@@ -123,10 +328,12 @@ func startControllers(wg *sync.WaitGroup, ctx context.Context) {
 	if servercfg.IsDNSMode() {
 		err := logic.SetDNS()
 		if err != nil {
-			logger.Log(0, "error occurred initializing DNS: ", err.Error())
+			slog.ErrorCtx(ctx, "error occurred initializing DNS", "error", err)
 		}
 	}
 
+	serverctl.StartACME(ctx, wg)
+
 	//Run Rest Server
 	if servercfg.IsRestBackend() {
 		if !servercfg.DisableRemoteIPCheck() && servercfg.GetAPIHost() == "127.0.0.1" {
@@ -145,61 +352,66 @@ func startControllers(wg *sync.WaitGroup, ctx context.Context) {
 	}
 
 	if !servercfg.IsRestBackend() && !servercfg.IsMessageQueueBackend() {
-		logger.Log(0, "No Server Mode selected, so nothing is being served! Set Rest mode (REST_BACKEND) or MessageQueue (MESSAGEQUEUE_BACKEND) to 'true'.")
+		slog.InfoCtx(ctx, "No Server Mode selected, so nothing is being served! Set Rest mode (REST_BACKEND) or MessageQueue (MESSAGEQUEUE_BACKEND) to 'true'.")
 	}
 
 	wg.Add(1)
 	go logic.StartHookManager(ctx, wg)
+	logic.StartScheduler(ctx, wg)
+	logic.StartExternalDNS(ctx, wg)
+	logic.StartSIEMShipping(ctx, wg)
+	logic.AddShutdownHook("logs", func() error {
+		logger.DumpFile(fmt.Sprintf("data/netmaker.log.%s", time.Now().Format(logger.TimeFormatDay)))
+		return nil
+	})
+	logic.WatchStore(ctx)
 }
 
 // Should we be using a context vice a waitgroup????????????
 func runMessageQueue(wg *sync.WaitGroup, ctx context.Context) {
 	defer wg.Done()
 	brokerHost, _ := servercfg.GetMessageQueueEndpoint()
-	logger.Log(0, "connecting to mq broker at", brokerHost)
+	slog.InfoCtx(ctx, "connecting to mq broker", "broker_host", brokerHost)
 	mq.SetupMQTT()
 	if mq.IsConnected() {
-		logger.Log(0, "connected to MQ Broker")
+		slog.InfoCtx(ctx, "connected to MQ Broker")
 	} else {
 		logger.FatalLog("error connecting to MQ Broker")
 	}
-	defer mq.CloseClient()
 	go mq.Keepalive(ctx)
+	mq.StartPublishWorkers(ctx)
+	logic.RegisterJobs()
+	mq.RegisterJobs()
 	go func() {
 		peerUpdate := make(chan *models.Node)
 		go logic.ManageZombies(ctx, peerUpdate)
-		go logic.DeleteExpiredNodes(ctx, peerUpdate)
 		for nodeUpdate := range peerUpdate {
 			if err := mq.NodeUpdate(nodeUpdate); err != nil {
-				logger.Log(0, "failed to send peer update for deleted node: ", nodeUpdate.ID.String(), err.Error())
+				slog.ErrorCtx(ctx, "failed to send peer update for deleted node", "node_update_id", nodeUpdate.ID.String(), "error", err)
 			}
 		}
 	}()
 	<-ctx.Done()
-	logger.Log(0, "Message Queue shutting down")
+	slog.InfoCtx(ctx, "Message Queue shutting down")
 }
 
 func setVerbosity() {
 	verbose := int(servercfg.GetVerbosity())
 	logger.Verbosity = verbose
-	logLevel := &slog.LevelVar{}
-	replace := func(groups []string, a slog.Attr) slog.Attr {
-		if a.Key == slog.SourceKey {
-			a.Value = slog.StringValue(filepath.Base(a.Value.String()))
-		}
-		return a
-	}
-	logger := slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{AddSource: true, ReplaceAttr: replace, Level: logLevel}))
-	slog.SetDefault(logger)
+	var level slog.Level
 	switch verbose {
 	case 4:
-		logLevel.Set(slog.LevelDebug)
+		level = slog.LevelDebug
 	case 3:
-		logLevel.Set(slog.LevelInfo)
+		level = slog.LevelInfo
 	case 2:
-		logLevel.Set(slog.LevelWarn)
+		level = slog.LevelWarn
 	default:
-		logLevel.Set(slog.LevelError)
+		level = slog.LevelError
+	}
+	logger.Setup(os.Stderr, servercfg.IsJSONLogFormat(), level)
+	if err := logger.SetLevels(servercfg.GetLogLevels()); err != nil {
+		slog.Error("invalid log levels", "error", err)
 	}
 }
 
@@ -1,22 +1,198 @@
 package migrate
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gravitl/netmaker/database"
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
 )
 
-// Run - runs all migrations
-func Run() {
-	updateEnrollmentKeys()
+// Migration - a versioned change to the records of one or more tables, applied once and in order
+type Migration struct {
+	Version int
+	Name    string
+	// Tables - the tables the migration changes
+	Tables []string
+	Up     func() error
+	// Down - reverts Up for a downgrade, nil if the migration can't be reverted
+	Down func() error
 }
 
-func updateEnrollmentKeys() {
+// migrations - new migrations must be appended with the next version number,
+// an applied migration must not be renumbered, renamed or pointed at other tables
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "enrollment key types",
+		Tables:  []string{database.ENROLLMENT_KEYS_TABLE_NAME},
+		Up:      updateEnrollmentKeys,
+		Down:    resetEnrollmentKeys,
+	},
+	{
+		Version: 2,
+		Name:    "enrollment key record keys",
+		Tables:  []string{database.ENROLLMENT_KEYS_TABLE_NAME},
+		Up:      hashEnrollmentKeyRecords,
+		Down:    unhashEnrollmentKeyRecords,
+	},
+}
+
+// Run - applies all pending migrations in order, backing up the database first
+func Run() error {
+	applied, err := verifyMigrations()
+	if err != nil {
+		return err
+	}
+	pending := []Migration{}
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; !ok {
+			pending = append(pending, m)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	if _, err = database.BackupTables(fmt.Sprintf("applying %d data migrations", len(pending))); err != nil {
+		return fmt.Errorf("backing up before migrating: %w", err)
+	}
+	for _, m := range pending {
+		slog.Info("applying data migration", "version", m.Version, "name", m.Name)
+		if err = m.Up(); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+		if err = recordMigration(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Down - reverts the migrations applied after the target version, newest first,
+// so the database can be used by an older release
+func Down(target int) error {
+	applied, err := verifyMigrations()
+	if err != nil {
+		return err
+	}
+	reverting := []Migration{}
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok || m.Version <= target {
+			continue
+		}
+		if m.Down == nil {
+			return fmt.Errorf("migration %d (%s) can't be reverted", m.Version, m.Name)
+		}
+		reverting = append(reverting, m)
+	}
+	if len(reverting) == 0 {
+		return nil
+	}
+	if _, err = database.BackupTables(fmt.Sprintf("reverting %d data migrations", len(reverting))); err != nil {
+		return fmt.Errorf("backing up before the downgrade: %w", err)
+	}
+	for _, m := range reverting {
+		slog.Info("reverting data migration", "version", m.Version, "name", m.Name)
+		if err = m.Down(); err != nil {
+			return fmt.Errorf("reverting migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+		if err = database.DeleteRecord(database.SCHEMA_MIGRATIONS_TABLE_NAME, strconv.Itoa(m.Version)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Status - returns the latest applied data migration and the latest known one
+func Status() (current, latest int, err error) {
+	applied, err := appliedMigrations()
+	if err != nil {
+		return 0, 0, err
+	}
+	for version := range applied {
+		if version > current {
+			current = version
+		}
+	}
+	return current, migrations[len(migrations)-1].Version, nil
+}
+
+// == private ==
+
+// checksum - identifies a migration, so an applied one that was later renumbered or repurposed is caught
+func checksum(m Migration) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d:%s:%s", m.Version, m.Name, strings.Join(m.Tables, ","))))
+	return hex.EncodeToString(sum[:])
+}
+
+func appliedMigrations() (map[int]models.SchemaMigration, error) {
+	applied := map[int]models.SchemaMigration{}
+	records, err := database.FetchRecords(database.SCHEMA_MIGRATIONS_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return applied, nil
+		}
+		return applied, err
+	}
+	for _, value := range records {
+		var m models.SchemaMigration
+		if err = json.Unmarshal([]byte(value), &m); err != nil {
+			return applied, err
+		}
+		applied[m.Version] = m
+	}
+	return applied, nil
+}
+
+// verifyMigrations - checks every applied migration is known to this server and unchanged since it was applied
+func verifyMigrations() (map[int]models.SchemaMigration, error) {
+	applied, err := appliedMigrations()
+	if err != nil {
+		return applied, err
+	}
+	known := map[int]Migration{}
+	for _, m := range migrations {
+		known[m.Version] = m
+	}
+	for version, record := range applied {
+		m, ok := known[version]
+		if !ok {
+			return applied, fmt.Errorf("data migration %d (%s) is unknown to this server, it was applied by a newer release", version, record.Name)
+		}
+		if record.Checksum != checksum(m) {
+			return applied, fmt.Errorf("data migration %d (%s) changed since it was applied", version, m.Name)
+		}
+	}
+	return applied, nil
+}
+
+func recordMigration(m Migration) error {
+	data, err := json.Marshal(&models.SchemaMigration{
+		Version:   m.Version,
+		Name:      m.Name,
+		Checksum:  checksum(m),
+		AppliedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	return database.Insert(strconv.Itoa(m.Version), string(data), database.SCHEMA_MIGRATIONS_TABLE_NAME)
+}
+
+func updateEnrollmentKeys() error {
 	rows, err := database.FetchRecords(database.ENROLLMENT_KEYS_TABLE_NAME)
 	if err != nil {
-		return
+		if database.IsEmptyRecord(err) {
+			return nil
+		}
+		return err
 	}
 	for _, row := range rows {
 		var key models.EnrollmentKey
@@ -24,10 +200,10 @@ func updateEnrollmentKeys() {
 			continue
 		}
 		if key.Type != models.Undefined {
-			logger.Log(2, "migration: enrollment key type already set")
+			slog.Debug("migration: enrollment key type already set")
 			continue
 		} else {
-			logger.Log(2, "migration: updating enrollment key type")
+			slog.Debug("migration: updating enrollment key type")
 			if key.Unlimited {
 				key.Type = models.Unlimited
 			} else if key.UsesRemaining > 0 {
@@ -38,13 +214,84 @@ func updateEnrollmentKeys() {
 		}
 		data, err := json.Marshal(key)
 		if err != nil {
-			logger.Log(0, "migration: marshalling enrollment key: "+err.Error())
+			slog.Error("migration: marshalling enrollment key: " + err.Error())
 			continue
 		}
 		if err = database.Insert(key.Value, string(data), database.ENROLLMENT_KEYS_TABLE_NAME); err != nil {
-			logger.Log(0, "migration: inserting enrollment key: "+err.Error())
+			return fmt.Errorf("inserting enrollment key: %w", err)
+		}
+	}
+	return nil
+}
+
+// resetEnrollmentKeys - clears the key types for releases that predate them
+func resetEnrollmentKeys() error {
+	rows, err := database.FetchRecords(database.ENROLLMENT_KEYS_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return nil
+		}
+		return err
+	}
+	for _, row := range rows {
+		var key models.EnrollmentKey
+		if err = json.Unmarshal([]byte(row), &key); err != nil {
+			continue
+		}
+		key.Type = models.Undefined
+		data, err := json.Marshal(key)
+		if err != nil {
 			continue
 		}
+		if err = database.Insert(key.Value, string(data), database.ENROLLMENT_KEYS_TABLE_NAME); err != nil {
+			return fmt.Errorf("inserting enrollment key: %w", err)
+		}
+	}
+	return nil
+}
+
+// hashEnrollmentKeyRecords - moves enrollment keys from records keyed by their secret value
+// to records keyed by its hash
+func hashEnrollmentKeyRecords() error {
+	return rekeyEnrollmentKeys(func(key *models.EnrollmentKey) string {
+		return models.EnrollmentKeyRecordKey(key.Value)
+	})
+}
+
+// unhashEnrollmentKeyRecords - keys the enrollment key records by their value again for releases that predate the hash
+func unhashEnrollmentKeyRecords() error {
+	return rekeyEnrollmentKeys(func(key *models.EnrollmentKey) string {
+		return key.Value
+	})
+}
 
+// rekeyEnrollmentKeys - stores every enrollment key under the record key given by recordKey,
+// the new record and the removal of the old one are written together
+func rekeyEnrollmentKeys(recordKey func(*models.EnrollmentKey) string) error {
+	rows, err := database.FetchRecords(database.ENROLLMENT_KEYS_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return nil
+		}
+		return err
+	}
+	for current, row := range rows {
+		var key models.EnrollmentKey
+		if err = json.Unmarshal([]byte(row), &key); err != nil || key.Value == "" {
+			continue
+		}
+		next := recordKey(&key)
+		if next == current {
+			continue
+		}
+		tx := database.BeginTx()
+		if err = tx.Insert(next, row, database.ENROLLMENT_KEYS_TABLE_NAME); err != nil {
+			return fmt.Errorf("inserting enrollment key: %w", err)
+		}
+		tx.Delete(database.ENROLLMENT_KEYS_TABLE_NAME, current)
+		if err = tx.Commit(); err != nil {
+			return fmt.Errorf("moving enrollment key: %w", err)
+		}
 	}
+	return nil
 }
@@ -0,0 +1,169 @@
+package migrate
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecksum(t *testing.T) {
+	m := Migration{Version: 1, Name: "enrollment key types", Tables: []string{database.ENROLLMENT_KEYS_TABLE_NAME}}
+	assert.Equal(t, checksum(m), checksum(m))
+	renumbered, renamed, retargeted := m, m, m
+	renumbered.Version = 2
+	renamed.Name = "key types"
+	retargeted.Tables = []string{database.HOSTS_TABLE_NAME}
+	for _, changed := range []Migration{renumbered, renamed, retargeted} {
+		assert.NotEqual(t, checksum(m), checksum(changed))
+	}
+	// the code of a migration may be fixed after it was applied
+	fixed := m
+	fixed.Up = func() error { return nil }
+	assert.Equal(t, checksum(m), checksum(fixed))
+}
+
+func TestMigrations(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	database.DeleteAllRecords(database.SCHEMA_MIGRATIONS_TABLE_NAME)
+	defer database.DeleteAllRecords(database.SCHEMA_MIGRATIONS_TABLE_NAME)
+	dir := t.TempDir()
+	t.Setenv("MIGRATION_BACKUP_DIR", dir)
+	defer func(known []Migration) { migrations = known }(migrations)
+	ran := []string{}
+	step := func(name string) func() error {
+		return func() error {
+			ran = append(ran, name)
+			return nil
+		}
+	}
+	migrations = []Migration{
+		{Version: 1, Name: "first", Tables: []string{database.HOSTS_TABLE_NAME}, Up: step("up 1"), Down: step("down 1")},
+		{Version: 2, Name: "second", Tables: []string{database.NODES_TABLE_NAME}, Up: step("up 2"), Down: step("down 2")},
+	}
+
+	t.Run("Run", func(t *testing.T) {
+		assert.Nil(t, Run())
+		assert.Equal(t, []string{"up 1", "up 2"}, ran)
+		current, latest, err := Status()
+		assert.Nil(t, err)
+		assert.Equal(t, 2, current)
+		assert.Equal(t, 2, latest)
+		backup := onlyBackup(t, dir)
+		assert.Equal(t, "applying 2 data migrations", backup.Reason)
+		// nothing pending, nothing backed up
+		assert.Nil(t, Run())
+		assert.Equal(t, 2, len(ran))
+		onlyBackup(t, dir)
+	})
+	t.Run("Changed", func(t *testing.T) {
+		migrations[1].Name = "renamed"
+		defer func() { migrations[1].Name = "second" }()
+		assert.ErrorContains(t, Run(), "changed since it was applied")
+		assert.ErrorContains(t, Down(0), "changed since it was applied")
+	})
+	t.Run("AppliedByNewerRelease", func(t *testing.T) {
+		known := migrations
+		migrations = migrations[:1]
+		defer func() { migrations = known }()
+		assert.ErrorContains(t, Run(), "unknown to this server")
+	})
+	t.Run("Down", func(t *testing.T) {
+		clearBackups(t, dir)
+		ran = []string{}
+		assert.Nil(t, Down(1))
+		assert.Equal(t, []string{"down 2"}, ran)
+		current, _, _ := Status()
+		assert.Equal(t, 1, current)
+		assert.Equal(t, "reverting 1 data migrations", onlyBackup(t, dir).Reason)
+		// already at the target
+		assert.Nil(t, Down(1))
+		assert.Equal(t, 1, len(ran))
+	})
+	t.Run("Irreversible", func(t *testing.T) {
+		assert.Nil(t, Run())
+		migrations[0].Down = nil
+		defer func() { migrations[0].Down = step("down 1") }()
+		ran = []string{}
+		assert.ErrorContains(t, Down(0), "can't be reverted")
+		// checked before anything is reverted
+		assert.Empty(t, ran)
+		current, _, _ := Status()
+		assert.Equal(t, 2, current)
+	})
+	t.Run("Failed", func(t *testing.T) {
+		assert.Nil(t, Down(1))
+		migrations[1].Up = func() error { return errors.New("disk full") }
+		defer func() { migrations[1].Up = step("up 2") }()
+		assert.ErrorContains(t, Run(), "migration 2 (second) failed: disk full")
+		// retried on the next start
+		current, _, _ := Status()
+		assert.Equal(t, 1, current)
+	})
+	t.Run("BackupFailed", func(t *testing.T) {
+		// a file where the backup dir should be
+		blocked := dir + "/blocked"
+		assert.Nil(t, os.WriteFile(blocked, nil, 0600))
+		t.Setenv("MIGRATION_BACKUP_DIR", blocked)
+		ran = []string{}
+		assert.ErrorContains(t, Run(), "backing up before migrating")
+		assert.Empty(t, ran)
+	})
+}
+
+func TestEnrollmentKeyRecords(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	database.DeleteAllRecords(database.ENROLLMENT_KEYS_TABLE_NAME)
+	defer database.DeleteAllRecords(database.ENROLLMENT_KEYS_TABLE_NAME)
+	key := models.EnrollmentKey{Value: "migrationkeyvalue", Tags: []string{"migrate"}, Unlimited: true}
+	data, _ := json.Marshal(&key)
+	assert.Nil(t, database.Insert(key.Value, string(data), database.ENROLLMENT_KEYS_TABLE_NAME))
+
+	assert.Nil(t, updateEnrollmentKeys())
+	assert.Nil(t, hashEnrollmentKeyRecords())
+	records, err := database.FetchRecords(database.ENROLLMENT_KEYS_TABLE_NAME)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(records))
+	value, ok := records[models.EnrollmentKeyRecordKey(key.Value)]
+	assert.True(t, ok)
+	var migrated models.EnrollmentKey
+	assert.Nil(t, json.Unmarshal([]byte(value), &migrated))
+	assert.Equal(t, models.Unlimited, migrated.Type)
+	assert.Equal(t, key.Value, migrated.Value)
+	// applying it again changes nothing
+	assert.Nil(t, hashEnrollmentKeyRecords())
+
+	assert.Nil(t, unhashEnrollmentKeyRecords())
+	assert.Nil(t, resetEnrollmentKeys())
+	records, _ = database.FetchRecords(database.ENROLLMENT_KEYS_TABLE_NAME)
+	assert.Equal(t, 1, len(records))
+	assert.Nil(t, json.Unmarshal([]byte(records[key.Value]), &migrated))
+	assert.Equal(t, models.Undefined, migrated.Type)
+}
+
+// onlyBackup - reads the one backup written to dir
+func onlyBackup(t *testing.T, dir string) database.Backup {
+	entries, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	var backup database.Backup
+	if !assert.Equal(t, 1, len(entries)) {
+		return backup
+	}
+	data, err := os.ReadFile(dir + "/" + entries[0].Name())
+	assert.Nil(t, err)
+	assert.Nil(t, json.Unmarshal(data, &backup))
+	return backup
+}
+
+func clearBackups(t *testing.T, dir string) {
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		assert.Nil(t, os.Remove(dir+"/"+entry.Name()))
+	}
+}
@@ -0,0 +1,7 @@
+package models
+
+// APIAllowlist - the source cidrs a user may use the management api from, on top of the server's allowlist
+type APIAllowlist struct {
+	User  string   `json:"user"`
+	CIDRs []string `json:"cidrs" validate:"required,min=1,dive,cidr"`
+}
@@ -38,6 +38,16 @@ type ApiNode struct {
 	InternetGateway         string   `json:"internetgateway"`
 	Connected               bool     `json:"connected"`
 	PendingDelete           bool     `json:"pendingdelete"`
+	FlowExport              bool     `json:"flow_export"`
+	Revision                int64    `json:"revision"`
+	Tags                    []string `json:"tags,omitempty"`
+	Name                    string   `json:"name,omitempty"`
+	Ephemeral               bool     `json:"ephemeral,omitempty"`
+	EphemeralTTL            int64    `json:"ephemeralttl,omitempty"`
+	// BandwidthLimit - set with the bandwidth endpoint, ignored on update
+	BandwidthLimit *BandwidthLimit `json:"bandwidth_limit,omitempty"`
+	// ExtClientAccess - set with the extclientaccess endpoint, ignored on update
+	ExtClientAccess *ExtClientAccess `json:"extclientaccess,omitempty"`
 	// == PRO ==
 	DefaultACL string `json:"defaultacl,omitempty" validate:"checkyesornoorunset"`
 	Failover   bool   `json:"failover"`
@@ -68,6 +78,15 @@ func (a *ApiNode) ConvertToServerNode(currentNode *Node) *Node {
 	convertedNode.IngressDNS = a.IngressDns
 	convertedNode.EgressGatewayRequest = currentNode.EgressGatewayRequest
 	convertedNode.EgressGatewayNatEnabled = currentNode.EgressGatewayNatEnabled
+	convertedNode.FlowExport = currentNode.FlowExport
+	convertedNode.BandwidthLimit = currentNode.BandwidthLimit
+	convertedNode.Revision = a.Revision
+	convertedNode.Tags = a.Tags
+	// names only change through the rename endpoint so the network's naming policy is applied
+	convertedNode.Name = currentNode.Name
+	convertedNode.Ephemeral = currentNode.Ephemeral
+	convertedNode.EphemeralTTL = currentNode.EphemeralTTL
+	convertedNode.ExtClientAccess = currentNode.ExtClientAccess
 	convertedNode.PersistentKeepalive = time.Second * time.Duration(a.PersistentKeepalive)
 	convertedNode.RelayedNodes = a.RelayedNodes
 	convertedNode.DefaultACL = a.DefaultACL
@@ -162,6 +181,14 @@ func (nm *Node) ConvertToAPINode() *ApiNode {
 	}
 	apiNode.Connected = nm.Connected
 	apiNode.PendingDelete = nm.PendingDelete
+	apiNode.FlowExport = nm.FlowExport
+	apiNode.BandwidthLimit = nm.BandwidthLimit
+	apiNode.ExtClientAccess = nm.ExtClientAccess
+	apiNode.Tags = nm.Tags
+	apiNode.Name = nm.Name
+	apiNode.Ephemeral = nm.Ephemeral
+	apiNode.EphemeralTTL = int64(nm.EphemeralTTL.Seconds())
+	apiNode.Revision = nm.Revision
 	apiNode.DefaultACL = nm.DefaultACL
 	apiNode.Failover = nm.Failover
 	return &apiNode
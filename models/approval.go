@@ -0,0 +1,51 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ApprovalOperation - a sensitive operation that needs a second admin to approve it
+type ApprovalOperation string
+
+const (
+	// ApprovalDeleteNetwork - deleting a network
+	ApprovalDeleteNetwork ApprovalOperation = "delete_network"
+	// ApprovalDenyAllACL - an ACL update that leaves no nodes of a network allowed to talk to each other
+	ApprovalDenyAllACL ApprovalOperation = "deny_all_acl"
+	// ApprovalGrantSuperAdmin - making a user an admin of the whole server
+	ApprovalGrantSuperAdmin ApprovalOperation = "grant_superadmin"
+)
+
+// ApprovalStatus - the state of an approval request
+type ApprovalStatus string
+
+const (
+	// ApprovalPending - waiting for a second admin
+	ApprovalPending ApprovalStatus = "pending"
+	// ApprovalApproved - approved and applied
+	ApprovalApproved ApprovalStatus = "approved"
+	// ApprovalRejected - rejected, never applied
+	ApprovalRejected ApprovalStatus = "rejected"
+	// ApprovalExpired - nobody decided before it expired, never applied
+	ApprovalExpired ApprovalStatus = "expired"
+)
+
+// ApprovalRequest - a sensitive operation held until an admin other than the one who requested it approves it
+type ApprovalRequest struct {
+	ID        string            `json:"id"`
+	Operation ApprovalOperation `json:"operation"`
+	// Target - the network or user the operation applies to
+	Target string `json:"target"`
+	// Payload - what the operation needs to be applied, such as the new ACLs
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	Tenant      string          `json:"tenant,omitempty"`
+	RequestedBy string          `json:"requested_by"`
+	Requested   time.Time       `json:"requested"`
+	Expires     time.Time       `json:"expires"`
+	Status      ApprovalStatus  `json:"status"`
+	DecidedBy   string          `json:"decided_by,omitempty"`
+	Decided     time.Time       `json:"decided,omitempty"`
+	// Error - why an approved operation failed to apply
+	Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// AuditEntry - a change a user made through the api
+type AuditEntry struct {
+	ID           string    `json:"id"`
+	Time         time.Time `json:"time"`
+	User         string    `json:"user"`
+	Host         string    `json:"host,omitempty"`
+	Tenant       string    `json:"tenant,omitempty"`
+	Method       string    `json:"method"`
+	Path         string    `json:"path"`
+	Status       int       `json:"status"`
+	RemoteAddr   string    `json:"remote_addr"`
+	Impersonator string    `json:"impersonator,omitempty"`
+}
+
+// Export - rows of a resource exported as compliance evidence
+type Export struct {
+	Resource    string              `json:"resource"`
+	GeneratedAt time.Time           `json:"generated_at"`
+	Columns     []string            `json:"columns"`
+	Rows        []map[string]string `json:"rows"`
+}
+
+// SIEMEvent - an audit or authentication event shipped to the SIEM endpoint
+type SIEMEvent struct {
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind"`
+	Name    string    `json:"name"`
+	User    string    `json:"user"`
+	Tenant  string    `json:"tenant,omitempty"`
+	Source  string    `json:"source,omitempty"`
+	Outcome string    `json:"outcome"`
+	Method  string    `json:"method,omitempty"`
+	Path    string    `json:"path,omitempty"`
+	Status  int       `json:"status,omitempty"`
+}
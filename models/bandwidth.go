@@ -0,0 +1,37 @@
+package models
+
+// BandwidthLimit - the most a node or ext client may send and receive, in kilobits per second, 0 is unlimited
+type BandwidthLimit struct {
+	UploadKbps   int64 `json:"upload_kbps" bson:"upload_kbps" yaml:"upload_kbps"`
+	DownloadKbps int64 `json:"download_kbps" bson:"download_kbps" yaml:"download_kbps"`
+}
+
+// IsUnlimited - checks if neither direction is limited
+func (l *BandwidthLimit) IsUnlimited() bool {
+	return l == nil || (l.UploadKbps == 0 && l.DownloadKbps == 0)
+}
+
+// PeerBandwidthLimit - a limit sent to a host with its peer update for it to shape with tc or eBPF:
+// a node limit applies to the host's own traffic on the node's network,
+// an ext client limit to the client's addresses behind the host's ingress gateway
+type PeerBandwidthLimit struct {
+	ID        string           `json:"id"`
+	Kind      TrafficUsageKind `json:"kind"`
+	Network   string           `json:"network"`
+	Addresses []string         `json:"addresses"`
+	BandwidthLimit
+}
+
+// BandwidthUtilization - the current throughput of a limited node or ext client against its limit
+type BandwidthUtilization struct {
+	ID    string           `json:"id"`
+	Kind  TrafficUsageKind `json:"kind"`
+	Name  string           `json:"name"`
+	Limit BandwidthLimit   `json:"limit"`
+	// UploadBps, DownloadBps - bits per second between the two latest metrics reports
+	UploadBps   int64 `json:"upload_bps"`
+	DownloadBps int64 `json:"download_bps"`
+	// UploadPercent, DownloadPercent - of the limit, 0 when the direction is unlimited
+	UploadPercent   float64 `json:"upload_percent"`
+	DownloadPercent float64 `json:"download_percent"`
+}
@@ -0,0 +1,40 @@
+package models
+
+const (
+	// CloudProviderAWS - instances presenting an EC2 instance identity document and its signature
+	CloudProviderAWS = "aws"
+	// CloudProviderGCP - instances presenting a compute engine identity token
+	CloudProviderGCP = "gcp"
+	// CloudProviderAzure - instances presenting a managed identity access token
+	CloudProviderAzure = "azure"
+)
+
+// CloudEnrollmentRule - lets instances of a cloud account (AWS account, GCP project or
+// Azure subscription) join a network without an enrollment key
+type CloudEnrollmentRule struct {
+	ID       string   `json:"id"`
+	Provider string   `json:"provider" validate:"required,oneof=aws gcp azure"`
+	Account  string   `json:"account" validate:"required"`
+	Network  string   `json:"network" validate:"required"`
+	Tags     []string `json:"tags"`
+	Tenant   string   `json:"tenant,omitempty"`
+}
+
+// CloudIdentity - the verified identity of a cloud instance
+type CloudIdentity struct {
+	Provider   string `json:"provider"`
+	Account    string `json:"account"`
+	InstanceID string `json:"instance_id"`
+	Region     string `json:"region"`
+	Zone       string `json:"zone"`
+	Name       string `json:"name"`
+}
+
+// CloudRegisterRequest - sent by a host registering with its cloud identity,
+// Document holds the identity document for AWS and the identity token for GCP and Azure
+type CloudRegisterRequest struct {
+	Provider  string `json:"provider"`
+	Document  string `json:"document"`
+	Signature string `json:"signature,omitempty"`
+	Host      Host   `json:"host"`
+}
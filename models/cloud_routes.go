@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// CloudRoutes - the cloud route tables of a network that are programmed to send the network's
+// traffic to its egress gateways, so cloud subnets behind an egress gateway can route back into the mesh
+type CloudRoutes struct {
+	Network  string `json:"network"`
+	Provider string `json:"provider" validate:"required,oneof=aws gcp azure"`
+	// AWS region of the route tables
+	Region string `json:"region,omitempty"`
+	// GCP project of the VPC networks
+	Project string `json:"project,omitempty"`
+	// Azure subscription and resource group of the route tables
+	Subscription  string `json:"subscription,omitempty"`
+	ResourceGroup string `json:"resource_group,omitempty"`
+	// AWS route table ids, GCP VPC network names or Azure route table names
+	RouteTables []string          `json:"route_tables" validate:"required,min=1"`
+	Credentials *CloudCredentials `json:"credentials,omitempty"`
+	Routes      []CloudRoute      `json:"routes"`
+}
+
+// CloudCredentials - the credentials used to program cloud route tables, only the ones of the provider are set
+type CloudCredentials struct {
+	// AWS access key
+	AccessKeyID     string `json:"access_key_id,omitempty"`
+	SecretAccessKey string `json:"secret_access_key,omitempty" redact:"true"`
+	// GCP service account key in json
+	ServiceAccountKey string `json:"service_account_key,omitempty" redact:"true"`
+	// Azure service principal
+	TenantID     string `json:"tenant_id,omitempty"`
+	ClientID     string `json:"client_id,omitempty"`
+	ClientSecret string `json:"client_secret,omitempty" redact:"true"`
+}
+
+// CloudRoute - a route programmed into a cloud route table
+type CloudRoute struct {
+	RouteTable  string    `json:"route_table"`
+	Destination string    `json:"destination"`
+	NextHop     string    `json:"next_hop"`
+	NodeID      string    `json:"node_id"`
+	Error       string    `json:"error,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+// ConfigProblem - a setting the server can't start with, or won't work as intended with
+type ConfigProblem struct {
+	// Check - what was checked: settings, database, broker, oauth or certificates
+	Check   string `json:"check"`
+	Setting string `json:"setting,omitempty"`
+	Message string `json:"message"`
+	// Fatal - the server fails to start or serve with this problem, otherwise it is a warning
+	Fatal bool `json:"fatal"`
+}
+
+// ConfigValidation - every problem found in the server's config at once
+type ConfigValidation struct {
+	Valid    bool            `json:"valid"`
+	Problems []ConfigProblem `json:"problems"`
+}
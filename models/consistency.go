@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// kinds of consistency issues
+const (
+	// ConsistencyNodeWithoutHost - a node whose host was deleted
+	ConsistencyNodeWithoutHost = "node_without_host"
+	// ConsistencyNodeWithoutNetwork - a node of a deleted network
+	ConsistencyNodeWithoutNetwork = "node_without_network"
+	// ConsistencyHostMissingNode - a host listing a node that was deleted
+	ConsistencyHostMissingNode = "host_missing_node"
+	// ConsistencyClientWithoutGateway - an ext client whose ingress gateway was deleted or is no longer a gateway
+	ConsistencyClientWithoutGateway = "ext_client_without_gateway"
+	// ConsistencyStaleSecondaryGateway - an ext client standing by on a gateway that was deleted or is no longer a gateway
+	ConsistencyStaleSecondaryGateway = "stale_secondary_gateway"
+	// ConsistencyStaleUserGateway - a network user assigned a gateway that was deleted
+	ConsistencyStaleUserGateway = "stale_user_gateway"
+	// ConsistencyStaleUserClient - a network user owning an ext client that was deleted
+	ConsistencyStaleUserClient = "stale_user_client"
+	// ConsistencyUsersWithoutNetwork - the network users of a deleted network
+	ConsistencyUsersWithoutNetwork = "network_users_without_network"
+)
+
+// ConsistencyIssue - a record referring to another that no longer exists, with what cleaning it up does
+type ConsistencyIssue struct {
+	Kind    string `json:"kind"`
+	Table   string `json:"table"`
+	ID      string `json:"id"`
+	Network string `json:"network,omitempty"`
+	// Reference - the missing record the issue's record refers to
+	Reference string `json:"reference,omitempty"`
+	Action    string `json:"action"`
+	Cleaned   bool   `json:"cleaned"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ConsistencyReport - the orphaned records found by a consistency check, and cleaned unless it was a dry run
+type ConsistencyReport struct {
+	Checked time.Time          `json:"checked"`
+	DryRun  bool               `json:"dry_run"`
+	Issues  []ConsistencyIssue `json:"issues"`
+	Cleaned int                `json:"cleaned"`
+	Failed  int                `json:"failed"`
+}
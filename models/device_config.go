@@ -0,0 +1,22 @@
+package models
+
+// DeviceConfig - a wireguard interface and its peers, independent of the vendor format it is rendered to
+type DeviceConfig struct {
+	Name string `json:"name"`
+	// Addresses - the interface addresses in cidr notation
+	Addresses  []string           `json:"addresses"`
+	ListenPort int                `json:"listenport,omitempty"`
+	MTU        int                `json:"mtu,omitempty"`
+	Peers      []DeviceConfigPeer `json:"peers"`
+}
+
+// DeviceConfigPeer - a peer of a rendered wireguard interface
+type DeviceConfigPeer struct {
+	Name      string `json:"name"`
+	PublicKey string `json:"publickey"`
+	// EndpointHost - empty when the peer connects to the device
+	EndpointHost        string   `json:"endpointhost,omitempty"`
+	EndpointPort        int      `json:"endpointport,omitempty"`
+	AllowedIPs          []string `json:"allowedips"`
+	PersistentKeepalive int      `json:"persistentkeepalive,omitempty"`
+}
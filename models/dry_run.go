@@ -0,0 +1,22 @@
+package models
+
+// DryRunResult - what a change sent with dryRun=true would do, none of it is persisted
+type DryRunResult struct {
+	DryRun bool `json:"dryrun"`
+	// Result - the resource as it would be after the change
+	Result any `json:"result,omitempty"`
+	// PeerUpdates - the hosts that would be sent a peer update
+	PeerUpdates []DryRunHost `json:"peer_updates"`
+	// HostUpdates - the hosts that would be sent an update of their own, such as new keys
+	HostUpdates []DryRunHost `json:"host_updates,omitempty"`
+	// Held - the hosts whose update would wait for their next maintenance window
+	Held []DryRunHost `json:"held,omitempty"`
+	// Allocations - the addresses and ranges that would be allocated or routed
+	Allocations []string `json:"allocations,omitempty"`
+}
+
+// DryRunHost - a host a dry run change would be sent to
+type DryRunHost struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// protocols of an egress health check
+const (
+	// EgressHealthICMP - the target is pinged
+	EgressHealthICMP = "icmp"
+	// EgressHealthTCP - a tcp connection is opened to the port of the target
+	EgressHealthTCP = "tcp"
+)
+
+// EgressHealthCheck - how the gateway of an egress range checks the range is reachable
+type EgressHealthCheck struct {
+	// NodeID - the egress node of the range, filled in when the check is sent to its host
+	NodeID   string `json:"node_id,omitempty"`
+	Range    string `json:"range" validate:"required"`
+	Protocol string `json:"protocol" validate:"required,oneof=icmp tcp"`
+	// Target - the address checked, it has to be in the range
+	Target string `json:"target" validate:"required,ip"`
+	// Port - the port connected to, tcp checks only
+	Port int `json:"port,omitempty" validate:"omitempty,min=1,max=65535"`
+	// Interval - seconds between checks
+	Interval int `json:"interval" validate:"omitempty,min=5,max=3600"`
+	// Failures - consecutive failed checks before the range is marked unhealthy
+	Failures int `json:"failures" validate:"omitempty,min=1,max=20"`
+	// Webhook - url posted to when the range turns unhealthy or recovers
+	Webhook string `json:"webhook,omitempty" validate:"omitempty,url"`
+	// Channels - ids of the notification channels posted to when the range turns unhealthy or recovers
+	Channels []string `json:"channels,omitempty"`
+}
+
+// EgressHealthResult - the outcome of one health check of an egress range, reported by its gateway
+type EgressHealthResult struct {
+	NodeID  string    `json:"node_id"`
+	Range   string    `json:"range"`
+	Time    time.Time `json:"time"`
+	Healthy bool      `json:"healthy"`
+	// Latency - round trip or connect time, in milliseconds
+	Latency int64  `json:"latency_ms"`
+	Error   string `json:"error,omitempty"`
+}
+
+// EgressRangeHealth - the health of an egress range of a gateway
+type EgressRangeHealth struct {
+	NodeID              string    `json:"node_id"`
+	Network             string    `json:"network"`
+	Range               string    `json:"range"`
+	Healthy             bool      `json:"healthy"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastCheck           time.Time `json:"last_check"`
+	// Since - when the range last turned healthy or unhealthy
+	Since   time.Time `json:"since"`
+	Latency int64     `json:"latency_ms"`
+	Error   string    `json:"error,omitempty"`
+	// FailedOver - the range is routed through another healthy gateway of the network meanwhile
+	FailedOver bool `json:"failed_over"`
+}
+
+// EgressHealthEvent - posted to the webhook of an egress health check when its range turns unhealthy or recovers
+type EgressHealthEvent struct {
+	Health EgressRangeHealth `json:"health"`
+	Time   time.Time         `json:"time"`
+}
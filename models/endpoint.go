@@ -0,0 +1,87 @@
+package models
+
+import "time"
+
+// endpoint kinds a host can reach a peer host through
+const (
+	// EndpointLocal - the peer's address on a LAN both hosts are on
+	EndpointLocal = "local"
+	// EndpointPublic6 - the peer's public IPv6 address
+	EndpointPublic6 = "public6"
+	// EndpointPublic4 - the peer's public IPv4 address
+	EndpointPublic4 = "public4"
+	// EndpointRegistered - an endpoint the peer host registered itself, eg. one per uplink
+	EndpointRegistered = "registered"
+	// EndpointOverride - an endpoint set by an admin
+	EndpointOverride = "override"
+)
+
+// address families preferred for the endpoints between hosts
+const (
+	// AddressFamilyAuto - IPv6 is preferred over IPv4 when both hosts have a public IPv6 address, the default
+	AddressFamilyAuto = "auto"
+	// AddressFamilyIPv6 - IPv6 endpoints are preferred over any IPv4 endpoint, including the registered ones
+	AddressFamilyIPv6 = "ipv6"
+	// AddressFamilyIPv4 - only IPv4 endpoints are used while the peer has one
+	AddressFamilyIPv4 = "ipv4"
+)
+
+// HostEndpoint - an endpoint a host can be reached at, hosts with several uplinks register one per uplink
+type HostEndpoint struct {
+	Name     string `json:"name,omitempty" yaml:"name,omitempty"`
+	Endpoint string `json:"endpoint" yaml:"endpoint" validate:"required"`
+	// Priority - lower is preferred, between 0 and 99
+	Priority int `json:"priority" yaml:"priority" validate:"min=0,max=99"`
+}
+
+// EndpointCandidate - an endpoint a host could reach a peer host through
+type EndpointCandidate struct {
+	Kind     string `json:"kind"`
+	Endpoint string `json:"endpoint"`
+	Score    int    `json:"score"`
+}
+
+// EndpointSelection - the endpoint a host uses for a peer host and the candidates it was picked from
+type EndpointSelection struct {
+	PeerHostID string `json:"peer_host_id"`
+	PeerName   string `json:"peer_name"`
+	Endpoint   string `json:"endpoint"`
+	Kind       string `json:"kind"`
+	InUse      string `json:"in_use,omitempty"`
+	Override   bool   `json:"override"`
+	SharedLAN  bool   `json:"shared_lan"`
+	Family     string `json:"family"`
+	// FamilyFallback - IPv6 was reported failing between the hosts, so IPv4 is used for a while
+	FamilyFallback bool                `json:"family_fallback,omitempty"`
+	Candidates     []EndpointCandidate `json:"candidates"`
+}
+
+// EndpointOverrideRequest - pins the endpoint a host uses for a peer host, either a fixed
+// ip:port or the kind of candidate to use, eg. local to force or public4 to stop LAN routing,
+// or else the address family preferred between them instead of the network's
+type EndpointOverrideRequest struct {
+	Endpoint string `json:"endpoint,omitempty"`
+	Kind     string `json:"kind,omitempty"`
+	Family   string `json:"family,omitempty"`
+}
+
+// EndpointObservation - the endpoint a node last handshaked with a peer through, as reported by its host
+type EndpointObservation struct {
+	PeerID   string `json:"peer_id"`
+	PeerName string `json:"peer_name,omitempty"`
+	Endpoint string `json:"endpoint"`
+	// Since - when the node started reaching the peer through the endpoint
+	Since    time.Time `json:"since"`
+	LastSeen time.Time `json:"last_seen"`
+	// Previous - the endpoint used before, a peer whose port keeps changing is behind a NAT that rebinds
+	Previous string `json:"previous,omitempty"`
+	Changes  int    `json:"changes"`
+	// Selected - the endpoint the server selected for the peer, filled in when listed
+	Selected string `json:"selected,omitempty"`
+}
+
+// NodeEndpointObservations - the endpoints a node was seen reaching its peers through, by peer id
+type NodeEndpointObservations struct {
+	NodeID string                         `json:"node_id"`
+	Peers  map[string]EndpointObservation `json:"peers"`
+}
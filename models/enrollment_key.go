@@ -1,6 +1,8 @@
 package models
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"time"
 )
 
@@ -11,6 +13,16 @@ const (
 	Unlimited
 )
 
+const (
+	// EnrollmentKeyCascadeQuarantine - revoking a key quarantines the hosts enrolled with it
+	EnrollmentKeyCascadeQuarantine = "quarantine"
+	// EnrollmentKeyCascadeFlag - revoking a key raises a security alert for each host enrolled with it
+	EnrollmentKeyCascadeFlag = "flag"
+
+	// SecurityEventEnrollmentKeyRevoked - a host was enrolled with an enrollment key revoked as compromised
+	SecurityEventEnrollmentKeyRevoked = "enrollment_key_revoked"
+)
+
 // KeyType - the type of enrollment key
 type KeyType int
 
@@ -37,24 +49,55 @@ type EnrollmentKey struct {
 	Networks      []string  `json:"networks"`
 	Unlimited     bool      `json:"unlimited"`
 	Tags          []string  `json:"tags"`
-	Token         string    `json:"token,omitempty"` // B64 value of EnrollmentToken
+	Token         string    `json:"token,omitempty" redact:"allow"` // B64 value of EnrollmentToken
 	Type          KeyType   `json:"type"`
+	Tenant        string    `json:"tenant,omitempty"`
+	Ephemeral     bool      `json:"ephemeral,omitempty"`
+	EphemeralTTL  int64     `json:"ephemeral_ttl,omitempty"` // seconds an ephemeral node is kept after disconnecting
+	// Fingerprint - identifies the key across rotations of its value, kept by the hosts it enrolled
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	Rotated     time.Time `json:"rotated,omitempty"`
+	// JoinConfig - configuration for the hosts joining with the key, on top of that of the networks
+	JoinConfig *JoinConfig `json:"join_config,omitempty"`
 }
 
 // APIEnrollmentKey - used to create enrollment keys via API
 type APIEnrollmentKey struct {
-	Expiration    int64    `json:"expiration"`
-	UsesRemaining int      `json:"uses_remaining"`
-	Networks      []string `json:"networks"`
-	Unlimited     bool     `json:"unlimited"`
-	Tags          []string `json:"tags"`
-	Type          KeyType  `json:"type"`
+	Expiration    int64       `json:"expiration"`
+	UsesRemaining int         `json:"uses_remaining"`
+	Networks      []string    `json:"networks"`
+	Unlimited     bool        `json:"unlimited"`
+	Tags          []string    `json:"tags"`
+	Type          KeyType     `json:"type"`
+	Tenant        string      `json:"tenant,omitempty"`
+	Ephemeral     bool        `json:"ephemeral,omitempty"`
+	EphemeralTTL  int64       `json:"ephemeral_ttl,omitempty"`
+	JoinConfig    *JoinConfig `json:"join_config,omitempty"`
+}
+
+// EnrollmentKeyRevocation - an enrollment key revoked as compromised and what became of the hosts enrolled with it
+type EnrollmentKeyRevocation struct {
+	Fingerprint string `json:"fingerprint"`
+	Cascade     string `json:"cascade,omitempty"`
+	// Hosts - the hosts enrolled with the key
+	Hosts []string `json:"hosts"`
+	// Quarantined - the hosts the revocation quarantined, the others were quarantined already
+	Quarantined []string `json:"quarantined"`
+	// Unattributed - hosts in the key's networks that don't record the key they enrolled with, as they registered
+	// before hosts recorded it or without a key; they may have been enrolled with it but are left out of the cascade
+	Unattributed []string `json:"unattributed"`
 }
 
 // RegisterResponse - the response to a successful enrollment register
 type RegisterResponse struct {
 	ServerConf    ServerConfig `json:"server_config"`
 	RequestedHost Host         `json:"requested_host"`
+	// HostCertificate - the client certificate of the host when the server uses certificate auth
+	HostCertificate *IssuedCertificate `json:"host_certificate,omitempty"`
+	// JoinConfigs - the join config of each network the host is joining that has one
+	JoinConfigs map[string]JoinConfig `json:"join_configs,omitempty"`
+	// SigningKeys - the keys to verify the messages the server publishes with
+	SigningKeys []SigningKey `json:"signing_keys,omitempty"`
 }
 
 // EnrollmentKey.IsValid - checks if the key is still valid to use
@@ -75,6 +118,13 @@ func (k *EnrollmentKey) IsValid() bool {
 	return k.Unlimited
 }
 
+// EnrollmentKeyRecordKey - the database key of an enrollment key, a hash of its value
+// so the secret isn't stored in the clear where the record itself is encrypted
+func EnrollmentKeyRecordKey(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
 // EnrollmentKey.Validate - validate's an EnrollmentKey
 // should be used during creation
 func (k *EnrollmentKey) Validate() bool {
@@ -1,9 +1,11 @@
 package models
 
+import "time"
+
 // ExtClient - struct for external clients
 type ExtClient struct {
 	ClientID               string              `json:"clientid" bson:"clientid"`
-	PrivateKey             string              `json:"privatekey" bson:"privatekey"`
+	PrivateKey             string              `json:"privatekey" bson:"privatekey" redact:"true"`
 	PublicKey              string              `json:"publickey" bson:"publickey"`
 	Network                string              `json:"network" bson:"network"`
 	DNS                    string              `json:"dns" bson:"dns"`
@@ -16,6 +18,38 @@ type ExtClient struct {
 	Enabled                bool                `json:"enabled" bson:"enabled"`
 	OwnerID                string              `json:"ownerid" bson:"ownerid"`
 	DeniedACLs             map[string]struct{} `json:"deniednodeacls" bson:"acls,omitempty"`
+	Sidecar                bool                `json:"sidecar,omitempty" bson:"sidecar,omitempty"`
+	LeaseExpiry            int64               `json:"lease_expiry,omitempty" bson:"lease_expiry,omitempty"`
+	BandwidthLimit         *BandwidthLimit     `json:"bandwidth_limit,omitempty" bson:"bandwidth_limit,omitempty"`
+	// SecondaryGatewayIDs - standby ingress gateways of the network the client is also provisioned on, with the same address
+	SecondaryGatewayIDs []string `json:"secondary_gateway_ids,omitempty" bson:"secondary_gateway_ids,omitempty"`
+	// RemoteAccess - provisioned by a remote access client, deleted when its lease isn't renewed
+	RemoteAccess bool `json:"remote_access,omitempty" bson:"remote_access,omitempty"`
+	// KeyExpiry - when the client's key stops being accepted by its gateways unless renewed, zero never
+	KeyExpiry int64 `json:"key_expiry,omitempty" bson:"key_expiry,omitempty"`
+	// BlockedSource - the source ip the client was removed from its gateways for, by its user's source policy
+	BlockedSource string `json:"blocked_source,omitempty" bson:"blocked_source,omitempty"`
+	// AllowedRanges - the allowed ips of the client's config, set by the access it was created with;
+	// the whole network and its egress ranges when empty
+	AllowedRanges []string `json:"allowed_ranges,omitempty" bson:"allowed_ranges,omitempty"`
+}
+
+// GatewayIDs - the ingress gateways the client is provisioned on, the primary first
+func (client *ExtClient) GatewayIDs() []string {
+	return append([]string{client.IngressGatewayID}, client.SecondaryGatewayIDs...)
+}
+
+// ExtClientKeyRenewal - asks for a new key of a short lived ext client, the server generates one when no public key is given
+type ExtClientKeyRenewal struct {
+	PublicKey string `json:"publickey,omitempty"`
+}
+
+// ExtClientCredentials - the renewed key of an ext client with its wireguard config, renewed again after renew_after
+type ExtClientCredentials struct {
+	Client     ExtClient `json:"client"`
+	Config     string    `json:"config"`
+	KeyExpiry  time.Time `json:"key_expiry"`
+	RenewAfter time.Time `json:"renew_after"`
 }
 
 // CustomExtClient - struct for CustomExtClient params
@@ -26,4 +60,24 @@ type CustomExtClient struct {
 	ExtraAllowedIPs []string            `json:"extraallowedips,omitempty"`
 	Enabled         bool                `json:"enabled,omitempty"`
 	DeniedACLs      map[string]struct{} `json:"deniednodeacls" bson:"acls,omitempty"`
+	// SecondaryGatewayIDs - standby ingress gateways to provision the client on, nil leaves them unchanged
+	SecondaryGatewayIDs []string `json:"secondary_gateway_ids,omitempty"`
+}
+
+// ExtClientGateway - an ingress gateway an ext client can connect through
+type ExtClientGateway struct {
+	GatewayID string `json:"gateway_id"`
+	PublicKey string `json:"public_key"`
+	Endpoint  string `json:"endpoint"`
+	Primary   bool   `json:"primary"`
+	Online    bool   `json:"online"`
+	// Active - the gateway the network routes the client through
+	Active bool `json:"active"`
+}
+
+// ExtClientGateways - the endpoint candidates of an ext client, the primary gateway first
+type ExtClientGateways struct {
+	ClientID string             `json:"clientid"`
+	Network  string             `json:"network"`
+	Gateways []ExtClientGateway `json:"gateways"`
 }
@@ -0,0 +1,26 @@
+package models
+
+// the access newly created ext clients can be given
+const (
+	// ExtClientAccessNetwork - the whole network and its egress ranges
+	ExtClientAccessNetwork = "network"
+	// ExtClientAccessGateway - only the ingress gateway the client is created on and its egress ranges
+	ExtClientAccessGateway = "gateway"
+	// ExtClientAccessPolicy - the ranges of one of the network's named ext client policies
+	ExtClientAccessPolicy = "policy"
+)
+
+// ExtClientAccess - the access an ext client is given when it's created
+type ExtClientAccess struct {
+	Mode string `json:"mode" bson:"mode" yaml:"mode" validate:"required,oneof=network gateway policy"`
+	// Policy - the name of the network's policy, with the policy mode
+	Policy string `json:"policy,omitempty" bson:"policy,omitempty" yaml:"policy,omitempty" validate:"required_if=Mode policy,max=32"`
+}
+
+// NetworkExtClientAccess - the default access of a network's new ext clients and the named policies it can use;
+// an ingress gateway's own access, when set, takes precedence over the default
+type NetworkExtClientAccess struct {
+	Default *ExtClientAccess `json:"default,omitempty" validate:"omitempty"`
+	// Policies - named sets of cidrs ext clients can be given access to
+	Policies map[string][]string `json:"policies,omitempty" validate:"omitempty,max=64,dive,keys,required,max=32,endkeys,required,dive,cidr"`
+}
@@ -0,0 +1,15 @@
+package models
+
+// server extension hooks
+const (
+	ExtensionHookPreNodeJoin    = "pre_node_join"
+	ExtensionHookPostPeerUpdate = "post_peer_update"
+	ExtensionHookPreUserCreate  = "pre_user_create"
+	ExtensionHookRoutes         = "routes"
+)
+
+// ExtensionInfo - a registered server extension and the hooks it implements
+type ExtensionInfo struct {
+	Name  string   `json:"name"`
+	Hooks []string `json:"hooks"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+const (
+	// ExternalDNSRoute53 - records published to an AWS Route53 hosted zone
+	ExternalDNSRoute53 = "route53"
+	// ExternalDNSCloudflare - records published to a Cloudflare zone
+	ExternalDNSCloudflare = "cloudflare"
+	// ExternalDNSGoogle - records published to a Google Cloud DNS managed zone
+	ExternalDNSGoogle = "google"
+)
+
+// ExternalDNSProvider - a DNS zone hosted by an external provider that netmaker publishes records to
+type ExternalDNSProvider struct {
+	ID   string `json:"id"`
+	Type string `json:"type" validate:"required,oneof=route53 cloudflare google"`
+	// domain of the zone, eg. company.com
+	Zone string `json:"zone" validate:"required,fqdn"`
+	// Route53 hosted zone id or Google managed zone name, Cloudflare zones are looked up by domain
+	ZoneID string `json:"zone_id,omitempty"`
+	// GCP project of a Google managed zone
+	Project     string                  `json:"project,omitempty"`
+	Credentials *ExternalDNSCredentials `json:"credentials,omitempty"`
+}
+
+// ExternalDNSCredentials - the credentials used to publish records, only the ones of the provider are set
+type ExternalDNSCredentials struct {
+	// AWS access key
+	AccessKeyID     string `json:"access_key_id,omitempty"`
+	SecretAccessKey string `json:"secret_access_key,omitempty" redact:"true"`
+	// Cloudflare API token
+	APIToken string `json:"api_token,omitempty" redact:"true"`
+	// GCP service account key in json
+	ServiceAccountKey string `json:"service_account_key,omitempty" redact:"true"`
+}
+
+// ExternalDNSRecord - publishes the public endpoint of a node's host under a name in an external zone
+type ExternalDNSRecord struct {
+	Name       string `json:"name" validate:"required,fqdn"`
+	ProviderID string `json:"provider_id" validate:"required"`
+	NodeID     string `json:"node_id" validate:"required"`
+	TTL        int    `json:"ttl" validate:"omitempty,min=30"`
+	// the record type and address last published
+	Type      string    `json:"type"`
+	Address   string    `json:"address"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+const (
+	// FlowProtocolNetflow - export flows as NetFlow v9
+	FlowProtocolNetflow = "netflow"
+	// FlowProtocolIPFIX - export flows as IPFIX
+	FlowProtocolIPFIX = "ipfix"
+)
+
+// FlowExportConfig - flow export settings sent to a host with its peer update
+type FlowExportConfig struct {
+	Enabled   bool     `json:"enabled"`
+	Collector string   `json:"collector"`
+	Protocol  string   `json:"protocol"`
+	Nodes     []string `json:"nodes"`
+}
+
+// FlowRecord - a single flow traversing a gateway
+type FlowRecord struct {
+	NodeID    string    `json:"node_id"`
+	Network   string    `json:"network"`
+	SrcIP     string    `json:"src_ip"`
+	DstIP     string    `json:"dst_ip"`
+	SrcPort   int       `json:"src_port"`
+	DstPort   int       `json:"dst_port"`
+	Protocol  string    `json:"protocol"`
+	Bytes     int64     `json:"bytes"`
+	Packets   int64     `json:"packets"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+}
+
+// FlowReport - batch of recent flows reported by a gateway over mq
+type FlowReport struct {
+	Flows []FlowRecord `json:"flows"`
+}
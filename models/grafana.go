@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// kinds of grafana targets
+const (
+	// GrafanaLatency - the latest latency in milliseconds a node measured to a peer
+	GrafanaLatency = "latency"
+	// GrafanaUptime - the percent of time a node was online, per interval
+	GrafanaUptime = "uptime"
+	// GrafanaStatus - a node being online (1) or offline (0)
+	GrafanaStatus = "status"
+)
+
+// GrafanaRange - the time range of a grafana query
+type GrafanaRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// GrafanaSearch - asks for the targets matching a filter
+type GrafanaSearch struct {
+	Target string `json:"target"`
+}
+
+// GrafanaTarget - a series asked for by a grafana query
+type GrafanaTarget struct {
+	Target string `json:"target"`
+	RefID  string `json:"refId,omitempty"`
+}
+
+// GrafanaQuery - a grafana json datasource query
+type GrafanaQuery struct {
+	Range         GrafanaRange    `json:"range"`
+	Targets       []GrafanaTarget `json:"targets"`
+	MaxDataPoints int             `json:"maxDataPoints"`
+}
+
+// GrafanaSeries - a series answering a grafana query, each datapoint a value and a unix time in milliseconds
+type GrafanaSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// GrafanaAnnotation - an annotation of a grafana dashboard, its query names the network whose events to show
+type GrafanaAnnotation struct {
+	Name   string `json:"name"`
+	Query  string `json:"query"`
+	Enable bool   `json:"enable"`
+}
+
+// GrafanaAnnotationQuery - asks for the annotations of a time range
+type GrafanaAnnotationQuery struct {
+	Range      GrafanaRange      `json:"range"`
+	Annotation GrafanaAnnotation `json:"annotation"`
+}
+
+// GrafanaAnnotationEvent - a network event shown as an annotation, at a unix time in milliseconds
+type GrafanaAnnotationEvent struct {
+	Annotation GrafanaAnnotation `json:"annotation"`
+	Time       int64             `json:"time"`
+	Title      string            `json:"title"`
+	Text       string            `json:"text"`
+	Tags       []string          `json:"tags"`
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"net"
 	"net/netip"
+	"time"
 
 	"github.com/google/uuid"
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
@@ -44,7 +45,7 @@ type Host struct {
 	IPForwarding       bool             `json:"ipforwarding" yaml:"ipforwarding"`
 	DaemonInstalled    bool             `json:"daemoninstalled" yaml:"daemoninstalled"`
 	AutoUpdate         bool             `json:"autoupdate" yaml:"autoupdate"`
-	HostPass           string           `json:"hostpass" yaml:"hostpass"`
+	HostPass           string           `json:"hostpass" yaml:"hostpass" redact:"true"`
 	Name               string           `json:"name" yaml:"name"`
 	OS                 string           `json:"os" yaml:"os"`
 	Interface          string           `json:"interface" yaml:"interface"`
@@ -65,6 +66,30 @@ type Host struct {
 	IsDefault          bool             `json:"isdefault" yaml:"isdefault"`
 	NatType            string           `json:"nat_type,omitempty" yaml:"nat_type,omitempty"`
 	TurnEndpoint       *netip.AddrPort  `json:"turn_endpoint,omitempty" yaml:"turn_endpoint,omitempty"`
+	Revision           int64            `json:"revision" yaml:"revision"`
+	Endpoints          []HostEndpoint   `json:"endpoints,omitempty" yaml:"endpoints,omitempty"`
+	Stun               string           `json:"stun,omitempty" yaml:"stun,omitempty"`
+	PeerKeepalives     map[string]int   `json:"peer_keepalives,omitempty" yaml:"peer_keepalives,omitempty"`
+	EnrolledWith       string           `json:"enrolled_with,omitempty" yaml:"enrolled_with,omitempty"` // fingerprint of the enrollment key the host registered with
+	// ConfigHash - the hash of the WireGuard and firewall config the host applied, sent on check-in and never stored
+	ConfigHash string `json:"config_hash,omitempty" yaml:"-"`
+	// SignedUpdates - the host verifies the messages the server publishes to it, which are then signed
+	SignedUpdates bool `json:"signed_updates,omitempty" yaml:"signed_updates,omitempty"`
+	// CertificateRequest - the PEM CSR a host sends when registering with certificate auth, never stored
+	CertificateRequest string `json:"certificate_request,omitempty" yaml:"-"`
+	// Resources - the cpu, memory and wireguard interface counters a host checks in with, kept in its resource history
+	Resources *HostResources `json:"resources,omitempty" yaml:"-"`
+	// TokensNotBefore - host tokens issued before it are refused, set when the host is quarantined
+	TokensNotBefore time.Time `json:"tokens_not_before,omitempty" yaml:"-"`
+}
+
+// HostTuning - the WireGuard settings of a host that can be adjusted from the server
+type HostTuning struct {
+	ListenPort int `json:"listenport" validate:"omitempty,min=1024,max=65535"`
+	// Stun - yes or no to have the host discover its public endpoint through STUN, default to follow the server
+	Stun string `json:"stun" validate:"omitempty,oneof=yes no default"`
+	// PeerKeepalives - keepalive in seconds by peer host id, overriding the keepalive of the peer's nodes
+	PeerKeepalives map[string]int `json:"peer_keepalives" validate:"omitempty,dive,min=1,max=1000"`
 }
 
 // FormatBool converts a boolean to a [yes|no] string
@@ -132,7 +157,7 @@ type HostUpdate struct {
 // HostTurnRegister - struct for host turn registration
 type HostTurnRegister struct {
 	HostID       string `json:"host_id"`
-	HostPassHash string `json:"host_pass_hash"`
+	HostPassHash string `json:"host_pass_hash" redact:"true"`
 }
 
 // Signal - struct for signalling peer
@@ -151,6 +176,6 @@ type RegisterMsg struct {
 	RegisterHost Host   `json:"host"`
 	Network      string `json:"network,omitempty"`
 	User         string `json:"user,omitempty"`
-	Password     string `json:"password,omitempty"`
+	Password     string `json:"password,omitempty" redact:"true"`
 	JoinAll      bool   `json:"join_all,omitempty"`
 }
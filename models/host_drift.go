@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// HostDrift - how the WireGuard and firewall config a host applied compares to the one the server expects it to have
+type HostDrift struct {
+	HostID   string `json:"host_id"`
+	HostName string `json:"host_name"`
+	// Drifted - the hashes haven't matched for longer than a host takes to apply an update
+	Drifted      bool   `json:"drifted"`
+	ReportedHash string `json:"reported_hash"`
+	ExpectedHash string `json:"expected_hash"`
+	// Since - when the hashes stopped matching, zero while they match
+	Since       time.Time `json:"since,omitempty"`
+	LastChecked time.Time `json:"last_checked"`
+	// Corrections - how many times the host was asked to pull to correct the drift
+	Corrections    int       `json:"corrections"`
+	LastCorrection time.Time `json:"last_correction,omitempty"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// SecurityEventHostQuarantined - a host was quarantined as compromised
+const SecurityEventHostQuarantined = "host_quarantined"
+
+// SecurityEventQuarantinedHostRegistration - a quarantined host, key or machine tried to register or authenticate
+const SecurityEventQuarantinedHostRegistration = "quarantined_host_registration"
+
+// HostQuarantine - a host cut off as compromised: its nodes are disconnected from every peer and its id, key and
+// machine can't register or authenticate again; the host is kept for forensics and the quarantine outlives it
+type HostQuarantine struct {
+	HostID   string    `json:"host_id"`
+	HostName string    `json:"host_name"`
+	Time     time.Time `json:"time"`
+	User     string    `json:"user"`
+	Reason   string    `json:"reason,omitempty"`
+	// PublicKey, MacAddress - the identity of the host when it was quarantined
+	PublicKey  string `json:"public_key"`
+	MacAddress string `json:"mac_address,omitempty"`
+	// DisconnectedNodes - the nodes of the host the quarantine disconnected, connected again on release
+	DisconnectedNodes []string `json:"disconnected_nodes"`
+}
+
+// HostQuarantineRequest - quarantines a host
+type HostQuarantineRequest struct {
+	Reason string `json:"reason" validate:"max=256"`
+}
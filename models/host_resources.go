@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// HostResources - a sample of a host's load and of the error counters of its wireguard interface,
+// the counters are totals since the interface came up
+type HostResources struct {
+	// Time - when the server received the sample
+	Time        time.Time `json:"time"`
+	CPUPercent  float64   `json:"cpu_percent"`
+	Load1       float64   `json:"load1"`
+	MemoryTotal uint64    `json:"memory_total"`
+	MemoryUsed  uint64    `json:"memory_used"`
+	RxErrors    uint64    `json:"rx_errors"`
+	TxErrors    uint64    `json:"tx_errors"`
+	RxDropped   uint64    `json:"rx_dropped"`
+	TxDropped   uint64    `json:"tx_dropped"`
+}
+
+// HostResourceHistory - the resource samples of a host, oldest first
+type HostResourceHistory struct {
+	HostID  string          `json:"host_id"`
+	Samples []HostResources `json:"samples"`
+}
+
+// HostResourceMetrics - a host's resource samples over a window, with their peaks and how many packets its
+// wireguard interface dropped or failed on in that time
+type HostResourceMetrics struct {
+	HostID            string          `json:"host_id"`
+	Window            string          `json:"window"`
+	Latest            *HostResources  `json:"latest,omitempty"`
+	PeakCPUPercent    float64         `json:"peak_cpu_percent"`
+	PeakMemoryPercent float64         `json:"peak_memory_percent"`
+	Errors            uint64          `json:"errors"`
+	Dropped           uint64          `json:"dropped"`
+	Saturated         bool            `json:"saturated"`
+	Samples           []HostResources `json:"samples"`
+}
@@ -2,7 +2,7 @@ package models
 
 type IntClient struct {
 	ClientID             string `json:"clientid" bson:"clientid"`
-	PrivateKey           string `json:"privatekey" bson:"privatekey"`
+	PrivateKey           string `json:"privatekey" bson:"privatekey" redact:"true"`
 	PublicKey            string `json:"publickey" bson:"publickey"`
 	AccessKey            string `json:"accesskey" bson:"accesskey"`
 	Address              string `json:"address" bson:"address"`
@@ -0,0 +1,90 @@
+package models
+
+// kinds of address reservations
+const (
+	IPAMReservationNodes       = "nodes"
+	IPAMReservationExtClients  = "ext_clients"
+	IPAMReservationStaticPeers = "static_peers"
+)
+
+// levels of ipam warnings
+const (
+	IPAMWarning  = "warning"
+	IPAMCritical = "critical"
+)
+
+// IPAMBlock - a run of consecutive addresses of a range
+type IPAMBlock struct {
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+	Size  uint64 `json:"size"`
+}
+
+// IPAMReservation - the addresses of a range given to one kind of peer
+type IPAMReservation struct {
+	Kind        string  `json:"kind"`
+	Used        uint64  `json:"used"`
+	UsedPercent float64 `json:"used_percent"`
+}
+
+// IPAMRange - the allocation of the usable addresses of a network range, sizes of large ipv6 ranges saturate
+// at the largest uint64
+type IPAMRange struct {
+	Range            string            `json:"range"`
+	Size             uint64            `json:"size"`
+	Used             uint64            `json:"used"`
+	Free             uint64            `json:"free"`
+	UsedPercent      float64           `json:"used_percent"`
+	LargestFreeBlock IPAMBlock         `json:"largest_free_block"`
+	Reservations     []IPAMReservation `json:"reservations"`
+}
+
+// IPAMRangeWarning - a range of a network nearing exhaustion
+type IPAMRangeWarning struct {
+	Family      string  `json:"family"`
+	Level       string  `json:"level"`
+	UsedPercent float64 `json:"used_percent"`
+	Free        uint64  `json:"free"`
+	Message     string  `json:"message"`
+}
+
+// NetworkIPAM - the address allocation of a network's ipv4 and ipv6 ranges
+type NetworkIPAM struct {
+	Network  string             `json:"network"`
+	IPv4     *IPAMRange         `json:"ipv4,omitempty"`
+	IPv6     *IPAMRange         `json:"ipv6,omitempty"`
+	Warnings []IPAMRangeWarning `json:"warnings"`
+}
+
+// kinds of address conflicts
+const (
+	// IPAMConflictDuplicate - an address held by more than one node or ext client
+	IPAMConflictDuplicate = "duplicate"
+	// IPAMConflictOutOfRange - an address outside its network's range
+	IPAMConflictOutOfRange = "out_of_range"
+)
+
+// IPAMHolder - a node or ext client holding an address
+type IPAMHolder struct {
+	Kind string `json:"kind"`
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// IPAMConflict - an address held more than once or outside its range; the first holder of a duplicate keeps it
+// on repair
+type IPAMConflict struct {
+	Kind    string       `json:"kind"`
+	Family  string       `json:"family"`
+	Address string       `json:"address"`
+	Holders []IPAMHolder `json:"holders"`
+}
+
+// IPAMReassignment - a new address given to a holder by a repair
+type IPAMReassignment struct {
+	Holder     IPAMHolder `json:"holder"`
+	Family     string     `json:"family"`
+	OldAddress string     `json:"old_address"`
+	NewAddress string     `json:"new_address,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
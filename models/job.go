@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// JobFailure - a failed run of a scheduled job
+type JobFailure struct {
+	Time  time.Time `json:"time"`
+	Error string    `json:"error"`
+}
+
+// JobStatus - a background job run by the scheduler on an interval
+type JobStatus struct {
+	Name string `json:"name"`
+	// Interval - how often the job runs, eg. 1h0m0s; set per job with JOB_INTERVALS
+	Interval string    `json:"interval"`
+	Paused   bool      `json:"paused"`
+	Running  bool      `json:"running"`
+	NextRun  time.Time `json:"next_run,omitempty"`
+	LastRun  time.Time `json:"last_run,omitempty"`
+	// LastDuration - how long the last run took, eg. 1.5s
+	LastDuration string `json:"last_duration,omitempty"`
+	LastError    string `json:"last_error,omitempty"`
+	Runs         int    `json:"runs"`
+	Failures     int    `json:"failures"`
+	// Skipped - runs left to another server sharing the database that held the job
+	Skipped int `json:"skipped"`
+	// HeldBy - the server that held the job when a run was last skipped
+	HeldBy string `json:"held_by,omitempty"`
+	// RecentFailures - the latest failures, the latest first
+	RecentFailures []JobFailure `json:"recent_failures"`
+}
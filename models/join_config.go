@@ -0,0 +1,16 @@
+package models
+
+// JoinConfig - configuration netclient applies after joining, set on a network or an enrollment key and delivered
+// with the register response and every pull; netclient applies it again whenever its version differs from the one it applied
+type JoinConfig struct {
+	// Version - changes every time the config is set, the latest of the network and the key when both have one
+	Version int64 `json:"version" bson:"version" yaml:"version"`
+	// Routes - CIDRs to route through the network's interface
+	Routes []string `json:"routes,omitempty" bson:"routes,omitempty" yaml:"routes,omitempty" validate:"omitempty,dive,cidr"`
+	// Sysctls - kernel parameters the host should have, eg. net.ipv4.ip_forward: 1
+	Sysctls map[string]string `json:"sysctls,omitempty" bson:"sysctls,omitempty" yaml:"sysctls,omitempty" validate:"omitempty,dive,keys,required,max=128,endkeys,max=256"`
+	// DNSSearch - search domains to add to the host's resolver
+	DNSSearch []string `json:"dns_search,omitempty" bson:"dns_search,omitempty" yaml:"dns_search,omitempty" validate:"omitempty,dive,hostname_rfc1123"`
+	// Values - arbitrary key/values for scripts run on the host
+	Values map[string]string `json:"values,omitempty" bson:"values,omitempty" yaml:"values,omitempty" validate:"omitempty,dive,keys,required,max=128,endkeys,max=4096"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// NetworkLockdown - a network cut off for incident response: its nodes are denied each other and its ext clients
+// disabled, except those of the allowlist; what they were is kept to restore them on unlock
+type NetworkLockdown struct {
+	Network string    `json:"network"`
+	Time    time.Time `json:"time"`
+	User    string    `json:"user"`
+	Reason  string    `json:"reason,omitempty"`
+	// Allowed - ids of the nodes and ext clients that keep their access, eg. those admins respond from
+	Allowed []string `json:"allowed"`
+	// DefaultACL - the default ACL of the network before the lockdown, nodes joining while it lasts are denied
+	DefaultACL string `json:"default_acl"`
+	// ACLs - the node ACLs of the network before the lockdown
+	ACLs map[string]map[string]byte `json:"acls,omitempty"`
+	// DisabledClients - the ext clients the lockdown disabled
+	DisabledClients []string `json:"disabled_clients"`
+	// DeniedPairs - the pairs of nodes the lockdown denied
+	DeniedPairs int `json:"denied_pairs"`
+}
+
+// NetworkLockdownRequest - locks a network down, sparing the nodes and ext clients of the allowlist
+type NetworkLockdownRequest struct {
+	Reason  string   `json:"reason" validate:"max=256"`
+	Allowed []string `json:"allowed"`
+}
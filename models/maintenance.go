@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// MaintenanceWindow - a weekly period of a network during which disruptive changes to its hosts are applied
+type MaintenanceWindow struct {
+	ID      string `json:"id"`
+	Network string `json:"network"`
+	Name    string `json:"name" validate:"required,max=64"`
+	// Days - the days the window opens on, every day when empty
+	Days []string `json:"days,omitempty" validate:"omitempty,dive,oneof=sun mon tue wed thu fri sat"`
+	// Start - the time of day the window opens, as HH:MM
+	Start string `json:"start" validate:"required"`
+	// Duration - how many minutes the window stays open
+	Duration int `json:"duration" validate:"min=1,max=1440"`
+	// Timezone - the IANA time zone of Start, UTC when empty
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// MaintenanceStatus - whether a network is in a maintenance window
+type MaintenanceStatus struct {
+	Network string              `json:"network"`
+	Open    bool                `json:"open"`
+	Next    time.Time           `json:"next,omitempty"`
+	Windows []MaintenanceWindow `json:"windows"`
+}
+
+// PendingChange - a disruptive change to a host held until every network of the host is in a maintenance window
+type PendingChange struct {
+	ID     string       `json:"id"`
+	HostID string       `json:"hostid"`
+	Action HostMqAction `json:"action"`
+	// Tuning - the settings to apply for an UpdateHost
+	Tuning *HostTuning `json:"tuning,omitempty"`
+	User   string      `json:"user"`
+	Queued time.Time   `json:"queued"`
+}
@@ -11,6 +11,10 @@ type Metrics struct {
 	NodeName      string            `json:"node_name" bson:"node_name" yaml:"node_name"`
 	Connectivity  map[string]Metric `json:"connectivity" bson:"connectivity" yaml:"connectivity"`
 	FailoverPeers map[string]string `json:"needsfailover" bson:"needsfailover" yaml:"needsfailover"`
+	// UnknownPeers - public keys the host saw handshakes from without having them as peers, with the endpoint they came from
+	UnknownPeers map[string]string `json:"unknown_peers,omitempty" bson:"unknown_peers,omitempty" yaml:"unknown_peers,omitempty"`
+	// Reported - when the host reported the metrics, zero for metrics the server wrote itself
+	Reported time.Time `json:"reported,omitempty" bson:"reported,omitempty" yaml:"reported,omitempty"`
 }
 
 // Metric - holds a metric for data between nodes
@@ -24,6 +28,7 @@ type Metric struct {
 	ActualUptime  time.Duration `json:"actualuptime" bson:"actualuptime" yaml:"actualuptime"`
 	PercentUp     float64       `json:"percentup" bson:"percentup" yaml:"percentup"`
 	Connected     bool          `json:"connected" bson:"connected" yaml:"connected"`
+	Endpoint      string        `json:"endpoint,omitempty" bson:"endpoint,omitempty" yaml:"endpoint,omitempty"`
 }
 
 // IDandAddr - struct to hold ID and primary Address
@@ -42,8 +47,9 @@ type HostInfoMap map[string]HostNetworkInfo
 
 // HostNetworkInfo - holds info related to host networking (used for client side peer calculations)
 type HostNetworkInfo struct {
-	Interfaces []Iface `json:"interfaces" yaml:"interfaces"`
-	ListenPort int     `json:"listen_port" yaml:"listen_port"`
+	Interfaces []Iface             `json:"interfaces" yaml:"interfaces"`
+	ListenPort int                 `json:"listen_port" yaml:"listen_port"`
+	Endpoints  []EndpointCandidate `json:"endpoints,omitempty" yaml:"endpoints,omitempty"`
 }
 
 // PeerMap - peer map for ids and addresses in metrics
@@ -56,3 +62,51 @@ type MetricsMap map[string]Metrics
 type NetworkMetrics struct {
 	Nodes MetricsMap `json:"nodes" bson:"nodes" yaml:"nodes"`
 }
+
+// LinkMetric - the metric one node reported for its link to a peer
+type LinkMetric struct {
+	NodeID    string  `json:"node_id"`
+	NodeName  string  `json:"node_name"`
+	PeerID    string  `json:"peer_id"`
+	PeerName  string  `json:"peer_name"`
+	Latency   int64   `json:"latency"`
+	PercentUp float64 `json:"percentup"`
+	Connected bool    `json:"connected"`
+}
+
+// GatewayLoad - how much of a network's traffic a gateway carries and for how many peers
+type GatewayLoad struct {
+	NodeID   string `json:"node_id"`
+	NodeName string `json:"node_name"`
+	// Kinds - ingress, egress and/or relay
+	Kinds             []string `json:"kinds"`
+	Peers             int      `json:"peers"`
+	DisconnectedPeers int      `json:"disconnected_peers"`
+	// TrafficToday - bytes sent and received since midnight UTC
+	TrafficToday int64 `json:"traffic_today"`
+	// Throughput - average bits per second since midnight UTC
+	Throughput int64 `json:"throughput_bps"`
+	// TrafficShare - percent of the network's traffic today that went through the gateway
+	TrafficShare float64 `json:"traffic_share_percent"`
+}
+
+// NetworkMetricsSummary - aggregates of the metrics reported by the nodes of a network
+type NetworkMetricsSummary struct {
+	Network        string        `json:"network"`
+	Nodes          int           `json:"nodes"`
+	Links          int           `json:"links"`
+	ConnectedLinks int           `json:"connected_links"`
+	MedianLatency  int64         `json:"median_latency"`
+	P95Latency     int64         `json:"p95_latency"`
+	AvgPercentUp   float64       `json:"avg_percentup"`
+	WorstLinks     []LinkMetric  `json:"worst_links"`
+	Gateways       []GatewayLoad `json:"gateways"`
+	// Bandwidth - utilization of the nodes and ext clients with a bandwidth limit
+	Bandwidth []BandwidthUtilization `json:"bandwidth"`
+}
+
+// PrometheusTargetGroup - a target group of prometheus http service discovery
+type PrometheusTargetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
@@ -3,7 +3,21 @@ package models
 // MigrationData struct needed to create new v0.18.0 node from v.0.17.X node
 type MigrationData struct {
 	HostName    string
-	Password    string
+	Password    string `redact:"true"`
 	OS          string
 	LegacyNodes []LegacyNode
 }
+
+// LegacyNodeMigration - whether a legacy node was migrated, and why not
+type LegacyNodeMigration struct {
+	NodeID   string `json:"nodeid"`
+	Network  string `json:"network"`
+	Migrated bool   `json:"migrated"`
+	Error    string `json:"error,omitempty"`
+}
+
+// MigrationResponse - the host a legacy host was migrated to, with the outcome of each of its legacy nodes
+type MigrationResponse struct {
+	HostPull
+	Results []LegacyNodeMigration `json:"results"`
+}
@@ -20,6 +20,15 @@ type HostPeerUpdate struct {
 	HostNetworkInfo   HostInfoMap           `json:"host_network_info,omitempty" bson:"host_network_info,omitempty" yaml:"host_network_info,omitempty"`
 	EgressRoutes      []EgressNetworkRoutes `json:"egress_network_routes"`
 	FwUpdate          FwUpdate              `json:"fw_update"`
+	FlowExport        FlowExportConfig      `json:"flow_export"`
+	BandwidthLimits   []PeerBandwidthLimit  `json:"bandwidth_limits,omitempty"`
+	QoS               []NetworkQoS          `json:"qos,omitempty"`
+	Probes            []Probe               `json:"probes,omitempty"`
+	TraceContext      map[string]string     `json:"trace_context,omitempty"`
+	// InMaintenanceWindow - every network of the host is in a maintenance window, netclient only auto-updates then
+	InMaintenanceWindow bool `json:"in_maintenance_window"`
+	// EgressHealthChecks - the health checks the host runs against the ranges of its egress nodes
+	EgressHealthChecks []EgressHealthCheck `json:"egress_health_checks,omitempty"`
 }
 
 // IngressInfo - struct for ingress info
@@ -0,0 +1,38 @@
+package models
+
+// peer connection states in a nat report
+const (
+	// PeerConnectionDirect - the host reaches the peer directly, hole punching worked if either is behind NAT
+	PeerConnectionDirect = "direct"
+	// PeerConnectionRelayed - traffic to the peer goes through a relay or failover node
+	PeerConnectionRelayed = "relayed"
+	// PeerConnectionFailed - the host reported it can't reach the peer
+	PeerConnectionFailed = "failed"
+	// PeerConnectionUnknown - the host hasn't reported on the peer yet
+	PeerConnectionUnknown = "unknown"
+)
+
+// HostNatReport - the server's view of a host's NAT and how it reaches its peers
+type HostNatReport struct {
+	HostID             string          `json:"host_id"`
+	Name               string          `json:"name"`
+	NatType            string          `json:"nat_type"`
+	EndpointIP         string          `json:"endpoint_ip"`
+	ListenPort         int             `json:"listen_port"`
+	WgPublicListenPort int             `json:"wg_public_listen_port"`
+	TurnEndpoint       string          `json:"turn_endpoint,omitempty"`
+	RelayRecommended   bool            `json:"relay_recommended"`
+	Peers              []PeerNatStatus `json:"peers"`
+}
+
+// PeerNatStatus - whether a host reached a peer as of its last check-in
+type PeerNatStatus struct {
+	PeerHostID  string  `json:"peer_host_id"`
+	PeerName    string  `json:"peer_name"`
+	Network     string  `json:"network"`
+	PeerNatType string  `json:"peer_nat_type"`
+	Connection  string  `json:"connection"`
+	Endpoint    string  `json:"endpoint,omitempty"`
+	Latency     int64   `json:"latency"`
+	PercentUp   float64 `json:"percent_up"`
+}
@@ -25,7 +25,29 @@ type Network struct {
 	DefaultUDPHolePunch string                `json:"defaultudpholepunch" bson:"defaultudpholepunch" validate:"checkyesorno"`
 	DefaultMTU          int32                 `json:"defaultmtu" bson:"defaultmtu"`
 	DefaultACL          string                `json:"defaultacl" bson:"defaultacl" yaml:"defaultacl" validate:"checkyesorno"`
+	LANDetection        string                `json:"landetection,omitempty" bson:"landetection,omitempty" yaml:"landetection,omitempty" validate:"omitempty,checkyesorno"`
+	AddressFamily       string                `json:"addressfamily,omitempty" bson:"addressfamily,omitempty" yaml:"addressfamily,omitempty" validate:"omitempty,oneof=auto ipv6 ipv4"`
 	ProSettings         *promodels.ProNetwork `json:"prosettings,omitempty" bson:"prosettings,omitempty" yaml:"prosettings,omitempty"`
+	Tenant              string                `json:"tenant,omitempty" bson:"tenant,omitempty" yaml:"tenant,omitempty"`
+	NamingPolicy        *NamingPolicy         `json:"namingpolicy,omitempty" bson:"namingpolicy,omitempty" yaml:"namingpolicy,omitempty"`
+	QoS                 *QoSPolicy            `json:"qos,omitempty" bson:"qos,omitempty" yaml:"qos,omitempty"`
+	Features            *NetworkFeatures      `json:"features,omitempty" bson:"features,omitempty" yaml:"features,omitempty"`
+	ExtClientAccess     *ExtClientAccess      `json:"extclientaccess,omitempty" bson:"extclientaccess,omitempty" yaml:"extclientaccess,omitempty"`
+	ExtClientPolicies   map[string][]string   `json:"extclientpolicies,omitempty" bson:"extclientpolicies,omitempty" yaml:"extclientpolicies,omitempty"`
+	Zones               *NetworkZones         `json:"zones,omitempty" bson:"zones,omitempty" yaml:"zones,omitempty"`
+	JoinConfig          *JoinConfig           `json:"joinconfig,omitempty" bson:"joinconfig,omitempty" yaml:"joinconfig,omitempty"`
+}
+
+// NamingPolicy - how the nodes joining a network are named
+type NamingPolicy struct {
+	// Template - the name given to new nodes, may use {host}, {network}, {site} and {seq}
+	Template string `json:"template" bson:"template" yaml:"template" validate:"required,max=63"`
+	// DNSSafe - names are lowercased and limited to letters, digits and hyphens
+	DNSSafe bool `json:"dnssafe" bson:"dnssafe" yaml:"dnssafe"`
+	// Scope - where names must be unique, network (the default) or global
+	Scope string `json:"scope,omitempty" bson:"scope,omitempty" yaml:"scope,omitempty" validate:"omitempty,oneof=network global"`
+	// OnCollision - suffix (the default) appends a number to a name that's taken, reject refuses the join
+	OnCollision string `json:"oncollision,omitempty" bson:"oncollision,omitempty" yaml:"oncollision,omitempty" validate:"omitempty,oneof=suffix reject"`
 }
 
 // SaveData - sensitive fields of a network that should be kept the same
@@ -83,4 +105,8 @@ func (network *Network) SetDefaults() {
 	if network.DefaultACL == "" {
 		network.DefaultACL = "yes"
 	}
+
+	if network.LANDetection == "" {
+		network.LANDetection = "yes"
+	}
 }
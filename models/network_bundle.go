@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// NetworkBundle - a network with everything needed to move it to another server
+type NetworkBundle struct {
+	// Version - the version of the server it was exported from
+	Version  string       `json:"version"`
+	Exported time.Time    `json:"exported"`
+	Network  Network      `json:"network"`
+	Nodes    []BundleNode `json:"nodes"`
+	// ACLs - whether each pair of nodes may talk, by node id, 1 is not allowed and 2 allowed
+	ACLs           map[string]map[string]byte `json:"acls"`
+	DNS            []DNSEntry                 `json:"dns"`
+	EnrollmentKeys []BundleEnrollmentKey      `json:"enrollment_keys"`
+}
+
+// BundleNode - the metadata of a node in a bundle, its host has to be on the importing server to bring it back
+type BundleNode struct {
+	ID       string   `json:"id"`
+	HostID   string   `json:"host_id"`
+	HostName string   `json:"host_name"`
+	Name     string   `json:"name,omitempty"`
+	Address  string   `json:"address,omitempty"`
+	Address6 string   `json:"address6,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	// DefaultACL - the node's own default, yes, no or unset
+	DefaultACL          string   `json:"defaultacl,omitempty"`
+	IsEgressGateway     bool     `json:"isegressgateway"`
+	EgressGatewayRanges []string `json:"egressgatewayranges,omitempty"`
+	IsIngressGateway    bool     `json:"isingressgateway"`
+	IsRelay             bool     `json:"isrelay"`
+}
+
+// BundleEnrollmentKey - an enrollment key of a network in a bundle, recreated with a new value on import
+type BundleEnrollmentKey struct {
+	Value         string    `json:"value"`
+	Type          KeyType   `json:"type"`
+	Expiration    time.Time `json:"expiration"`
+	UsesRemaining int       `json:"uses_remaining"`
+	Unlimited     bool      `json:"unlimited"`
+	Tags          []string  `json:"tags"`
+	Ephemeral     bool      `json:"ephemeral,omitempty"`
+	EphemeralTTL  int64     `json:"ephemeral_ttl,omitempty"`
+}
+
+// BundleMapping - what a part of a bundle became on import, or why it couldn't be imported
+type BundleMapping struct {
+	Kind     string `json:"kind"`
+	Source   string `json:"source"`
+	Target   string `json:"target,omitempty"`
+	Imported bool   `json:"imported"`
+	// Reason - why it wasn't imported, or what was lost importing it
+	Reason string `json:"reason,omitempty"`
+}
+
+// NetworkBundleImport - the report of a network bundle import
+type NetworkBundleImport struct {
+	Network  string          `json:"network"`
+	Mappings []BundleMapping `json:"mappings"`
+}
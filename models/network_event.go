@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// kinds of events in a network's feed
+const (
+	// NetworkEventJoin - a node joined the network
+	NetworkEventJoin = "join"
+	// NetworkEventLeave - a node left the network
+	NetworkEventLeave = "leave"
+	// NetworkEventGateway - an egress, ingress or relay gateway was created or removed
+	NetworkEventGateway = "gateway"
+	// NetworkEventACL - the network's ACLs were edited
+	NetworkEventACL = "acl"
+	// NetworkEventFailover - a failover node started relaying traffic between two nodes
+	NetworkEventFailover = "failover"
+	// NetworkEventEgressHealth - an egress range turned unhealthy or recovered
+	NetworkEventEgressHealth = "egress_health"
+	// NetworkEventSourcePolicy - an ext client connected from a source its user's policy doesn't allow
+	NetworkEventSourcePolicy = "source_policy"
+	// NetworkEventRollout - a canary rollout started, was promoted or was rolled back
+	NetworkEventRollout = "rollout"
+)
+
+// NetworkEvent - something that changed the shape of a network
+type NetworkEvent struct {
+	ID      string    `json:"id"`
+	Network string    `json:"network"`
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind"`
+	NodeID  string    `json:"node_id,omitempty"`
+	Host    string    `json:"host,omitempty"`
+	Message string    `json:"message"`
+}
+
+// NetworkEventFilter - which of a network's events to return, a zero From or To leaves that end open
+type NetworkEventFilter struct {
+	From   time.Time
+	To     time.Time
+	Kinds  []string
+	Offset int
+	Limit  int
+}
+
+// NetworkEventPage - a page of a network's events, oldest first, Next is the offset of the following page
+type NetworkEventPage struct {
+	Events []NetworkEvent `json:"events"`
+	Total  int            `json:"total"`
+	Next   int            `json:"next,omitempty"`
+}
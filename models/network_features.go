@@ -0,0 +1,19 @@
+package models
+
+// the capabilities that can be turned off on a network
+const (
+	NetworkFeatureExtClients = "extclients"
+	NetworkFeatureEgress     = "egress"
+	NetworkFeatureRelays     = "relays"
+)
+
+// NetworkFeatures - the capabilities usable on a network, a network without any set allows all of them;
+// turning one off stops new uses of it, gateways and clients that exist already keep working
+type NetworkFeatures struct {
+	// ExtClients - ingress gateways and ext clients can be created
+	ExtClients bool `json:"extclients" bson:"extclients" yaml:"extclients"`
+	// Egress - egress gateways can be created
+	Egress bool `json:"egress" bson:"egress" yaml:"egress"`
+	// Relays - relays can be created
+	Relays bool `json:"relays" bson:"relays" yaml:"relays"`
+}
@@ -90,6 +90,14 @@ type Node struct {
 	EgressGatewayRequest    EgressGatewayRequest `json:"egressgatewayrequest" bson:"egressgatewayrequest" yaml:"egressgatewayrequest"`
 	IngressGatewayRange     string               `json:"ingressgatewayrange" bson:"ingressgatewayrange" yaml:"ingressgatewayrange"`
 	IngressGatewayRange6    string               `json:"ingressgatewayrange6" bson:"ingressgatewayrange6" yaml:"ingressgatewayrange6"`
+	FlowExport              bool                 `json:"flow_export" bson:"flow_export" yaml:"flow_export"`
+	BandwidthLimit          *BandwidthLimit      `json:"bandwidth_limit,omitempty" bson:"bandwidth_limit,omitempty" yaml:"bandwidth_limit,omitempty"`
+	Revision                int64                `json:"revision" bson:"revision" yaml:"revision"`
+	Tags                    []string             `json:"tags,omitempty" bson:"tags,omitempty" yaml:"tags,omitempty"`
+	Name                    string               `json:"name,omitempty" bson:"name,omitempty" yaml:"name,omitempty"`
+	Ephemeral               bool                 `json:"ephemeral,omitempty" bson:"ephemeral,omitempty" yaml:"ephemeral,omitempty"`
+	EphemeralTTL            time.Duration        `json:"ephemeralttl,omitempty" bson:"ephemeralttl,omitempty" yaml:"ephemeralttl,omitempty"`
+	ExtClientAccess         *ExtClientAccess     `json:"extclientaccess,omitempty" bson:"extclientaccess,omitempty" yaml:"extclientaccess,omitempty"`
 	// == PRO ==
 	DefaultACL   string    `json:"defaultacl,omitempty" bson:"defaultacl,omitempty" yaml:"defaultacl,omitempty" validate:"checkyesornoorunset"`
 	OwnerID      string    `json:"ownerid,omitempty" bson:"ownerid,omitempty" yaml:"ownerid,omitempty"`
@@ -120,7 +128,7 @@ type LegacyNode struct {
 	LastPeerUpdate          int64                `json:"lastpeerupdate" bson:"lastpeerupdate" yaml:"lastpeerupdate"`
 	LastCheckIn             int64                `json:"lastcheckin" bson:"lastcheckin" yaml:"lastcheckin"`
 	MacAddress              string               `json:"macaddress" bson:"macaddress" yaml:"macaddress"`
-	Password                string               `json:"password" bson:"password" yaml:"password" validate:"required,min=6"`
+	Password                string               `json:"password" bson:"password" yaml:"password" validate:"required,min=6" redact:"true"`
 	Network                 string               `json:"network" bson:"network" yaml:"network" validate:"network_exists"`
 	IsRelayed               string               `json:"isrelayed" bson:"isrelayed" yaml:"isrelayed"`
 	IsPending               string               `json:"ispending" bson:"ispending" yaml:"ispending"`
@@ -423,6 +431,19 @@ func (newNode *Node) Fill(currentNode *Node, isEE bool) { // TODO add new field
 	if newNode.Failover != currentNode.Failover {
 		newNode.Failover = currentNode.Failover
 	}
+	if newNode.FlowExport != currentNode.FlowExport {
+		newNode.FlowExport = currentNode.FlowExport
+	}
+	newNode.BandwidthLimit = currentNode.BandwidthLimit
+	if newNode.Tags == nil {
+		newNode.Tags = currentNode.Tags
+	}
+	if newNode.Name == "" {
+		newNode.Name = currentNode.Name
+	}
+	// a node is ephemeral or not from the moment it joins
+	newNode.Ephemeral = currentNode.Ephemeral
+	newNode.EphemeralTTL = currentNode.EphemeralTTL
 }
 
 // StringWithCharset - returns random string inside defined charset
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// NodeStatusChange - a node going online or offline
+type NodeStatusChange struct {
+	Time   time.Time `json:"time"`
+	Online bool      `json:"online"`
+}
+
+// NodeStatusHistory - the online/offline transitions of a node, oldest first
+type NodeStatusHistory struct {
+	NodeID  string             `json:"nodeid"`
+	Network string             `json:"network"`
+	Changes []NodeStatusChange `json:"changes"`
+}
+
+// NodeUptimeWindow - how long a node was online over a window ending now
+type NodeUptimeWindow struct {
+	Window string `json:"window"`
+	// Uptime - percent of the tracked time the node was online
+	Uptime float64 `json:"uptime_percent"`
+	// Tracked - seconds of the window the node's status is known for, less than the window for new nodes
+	Tracked int64 `json:"tracked_seconds"`
+	Changes int   `json:"changes"`
+}
+
+// NodeUptime - a node's current status, its uptime over each window asked for
+// and its transitions over the longest one
+type NodeUptime struct {
+	NodeID  string             `json:"nodeid"`
+	Network string             `json:"network"`
+	Online  bool               `json:"online"`
+	Since   time.Time          `json:"since"`
+	Windows []NodeUptimeWindow `json:"windows"`
+	Changes []NodeStatusChange `json:"changes"`
+}
@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// kinds of notification channels
+const (
+	NotificationSlack   = "slack"
+	NotificationTeams   = "teams"
+	NotificationDiscord = "discord"
+	// NotificationWebhook - the notification is posted as is
+	NotificationWebhook = "webhook"
+)
+
+// types of events sent to notification channels
+const (
+	NotificationEventProbe        = "probe"
+	NotificationEventEgressHealth = "egress_health"
+	NotificationEventSecurity     = "security"
+	NotificationEventReport       = "report"
+	NotificationEventIPAM         = "ipam"
+)
+
+// severities of notifications
+const (
+	NotificationAlert    = "alert"
+	NotificationResolved = "resolved"
+	NotificationInfo     = "info"
+)
+
+// NotificationChannel - a slack, teams or discord incoming webhook, or a plain webhook, alerts are sent to
+type NotificationChannel struct {
+	ID   string `json:"id"`
+	Name string `json:"name" validate:"required,max=64"`
+	Kind string `json:"kind" validate:"required,oneof=slack teams discord webhook"`
+	URL  string `json:"url,omitempty" validate:"required,url,startswith=https://|startswith=http://" redact:"true"`
+	// Events - the types of events sent to the channel, alert rules naming the channel send theirs as well
+	Events []string `json:"events,omitempty" validate:"dive,oneof=probe egress_health security report ipam"`
+	Tenant string   `json:"tenant,omitempty"`
+}
+
+// NotificationField - a named value shown with a notification
+type NotificationField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Notification - an alert, or a report, as it's sent to notification channels
+type Notification struct {
+	Event    string              `json:"event"`
+	Severity string              `json:"severity"`
+	Title    string              `json:"title"`
+	Text     string              `json:"text,omitempty"`
+	Network  string              `json:"network,omitempty"`
+	Tenant   string              `json:"tenant,omitempty"`
+	Fields   []NotificationField `json:"fields,omitempty"`
+	Time     time.Time           `json:"time"`
+	// Preformatted - the text is laid out in columns, so it's shown in a fixed width font
+	Preformatted bool `json:"preformatted,omitempty"`
+}
@@ -0,0 +1,30 @@
+package models
+
+// OPAInput - the API request an Open Policy Agent policy decides on, sent as its input
+type OPAInput struct {
+	User string `json:"user"`
+	// Host - the host making the request, when it authenticated as a host instead of a user
+	Host   string `json:"host,omitempty"`
+	Tenant string `json:"tenant,omitempty"`
+	// Admin - the user may manage every network of the server, or of their tenant
+	Admin bool `json:"admin"`
+	// Impersonator - the admin acting as the user, if any
+	Impersonator string `json:"impersonator,omitempty"`
+	// Action - read, create, update or delete, from the method of the request
+	Action string `json:"action"`
+	Method string `json:"method"`
+	// Route - the route template of the request, eg. /api/networks/{networkname}
+	Route string `json:"route"`
+	Path  string `json:"path"`
+	// Resource - the kind of resource the route is about, the segment following /api, eg. networks
+	Resource string `json:"resource"`
+	// Params - the variables of the route, eg. networkname
+	Params   map[string]string `json:"params"`
+	SourceIP string            `json:"source_ip"`
+}
+
+// OPADecision - the result of an Open Policy Agent policy, either a boolean or an object with an allow field
+type OPADecision struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason,omitempty"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// kinds of certificates issued by the internal CA
+const (
+	// CertKindHost - a client certificate a host authenticates with
+	CertKindHost = "host"
+	// CertKindBroker - a certificate of the mq broker
+	CertKindBroker = "broker"
+	// CertKindServer - a certificate of the api server
+	CertKindServer = "server"
+)
+
+// IssuedCertificate - a certificate issued by the server's internal CA, PEM encoded,
+// with its private key when the server generated the key pair
+type IssuedCertificate struct {
+	Certificate string    `json:"certificate"`
+	CA          string    `json:"ca"`
+	PrivateKey  string    `json:"private_key,omitempty" redact:"allow"`
+	Serial      string    `json:"serial"`
+	NotAfter    time.Time `json:"not_after"`
+}
+
+// HostCertificateRequest - a host asking for a new client certificate before its current one expires
+type HostCertificateRequest struct {
+	CertificateRequest string `json:"certificate_request"`
+}
+
+// PKICertificate - a certificate issued by the internal CA, kept to list, renew and revoke it
+type PKICertificate struct {
+	Serial           string    `json:"serial"`
+	Kind             string    `json:"kind"`
+	Subject          string    `json:"subject"`
+	HostID           string    `json:"host_id,omitempty"`
+	DNSNames         []string  `json:"dns_names,omitempty"`
+	IPAddresses      []string  `json:"ip_addresses,omitempty"`
+	Certificate      string    `json:"certificate"`
+	IssuedAt         time.Time `json:"issued_at"`
+	NotAfter         time.Time `json:"not_after"`
+	Revoked          bool      `json:"revoked"`
+	RevokedAt        time.Time `json:"revoked_at,omitempty"`
+	RevocationReason string    `json:"revocation_reason,omitempty"`
+}
+
+// PKICertificateRequest - an operator asking the internal CA for a certificate, the server generates
+// the key pair when no PEM certificate request is given
+type PKICertificateRequest struct {
+	Kind               string   `json:"kind" validate:"required,oneof=host broker server"`
+	Subject            string   `json:"subject" validate:"required"`
+	CertificateRequest string   `json:"certificate_request"`
+	DNSNames           []string `json:"dns_names"`
+	IPAddresses        []string `json:"ip_addresses" validate:"dive,ip"`
+	Days               int      `json:"days" validate:"omitempty,min=1,max=825"`
+}
+
+// PKIRenewRequest - renews a certificate for a new key when a PEM certificate request is given, else for the same key
+type PKIRenewRequest struct {
+	CertificateRequest string `json:"certificate_request"`
+	Days               int    `json:"days" validate:"omitempty,min=1,max=825"`
+}
@@ -0,0 +1,42 @@
+package models
+
+// NetworkPolicy - the ACL policy of a network as read from a policy file
+type NetworkPolicy struct {
+	Network string
+	// DefaultACL - whether nodes may reach each other unless a rule or zone says otherwise, yes or no
+	DefaultACL string
+	Zones      *NetworkZones
+	// NodeRules - pairs of nodes whose ACL differs from what the default and zones give them
+	NodeRules  []PolicyNodeRule
+	ExtClients []PolicyExtClient
+}
+
+// PolicyNodeRule - whether two nodes, by name or id, may reach each other
+type PolicyNodeRule struct {
+	Nodes  [2]string
+	Action string
+	Line   int
+}
+
+// PolicyExtClient - the nodes, by name or id, and ext clients an ext client is denied
+type PolicyExtClient struct {
+	ClientID string
+	Deny     []string
+	Line     int
+}
+
+// PolicyError - a syntax or semantic error of a policy file
+type PolicyError struct {
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+// PolicyPlan - the result of validating or applying a policy file: its errors, or the changes applying it
+// makes to the network; a dry run doesn't make them
+type PolicyPlan struct {
+	Network string        `json:"network"`
+	DryRun  bool          `json:"dry_run"`
+	Valid   bool          `json:"valid"`
+	Errors  []PolicyError `json:"errors"`
+	Changes []string      `json:"changes"`
+}
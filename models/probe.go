@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// Probe - a synthetic ping run by a node on a schedule, to another node or to an external target
+type Probe struct {
+	ID           string `json:"id"`
+	Name         string `json:"name" validate:"required,max=64"`
+	Network      string `json:"network" validate:"required"`
+	SourceNodeID string `json:"source_node_id" validate:"required"`
+	// TargetNodeID - the node pinged over the mesh, leave empty to ping Target instead
+	TargetNodeID string `json:"target_node_id,omitempty"`
+	// Target - the address pinged, filled in from the target node when one is set
+	Target string `json:"target,omitempty" validate:"omitempty,hostname_rfc1123|ip"`
+	// Interval - seconds between runs
+	Interval int `json:"interval" validate:"omitempty,min=5,max=3600"`
+	// Count - pings sent on each run
+	Count int         `json:"count" validate:"omitempty,min=1,max=20"`
+	Alert *ProbeAlert `json:"alert,omitempty"`
+}
+
+// ProbeAlert - raises an alert when the recent results of a probe breach a threshold, 0 ignores a threshold
+type ProbeAlert struct {
+	MaxLatency int     `json:"max_latency_ms" validate:"omitempty,min=1"`
+	MaxLoss    float64 `json:"max_loss_percent" validate:"omitempty,min=0,max=100"`
+	// Window - number of recent results the thresholds are checked against
+	Window int `json:"window" validate:"omitempty,min=1,max=100"`
+	// Webhook - url the alert is posted to when it's raised or cleared
+	Webhook string `json:"webhook,omitempty" validate:"omitempty,url"`
+	// Channels - ids of the notification channels the alert is sent to
+	Channels []string `json:"channels,omitempty"`
+}
+
+// ProbeResult - the outcome of one run of a probe
+type ProbeResult struct {
+	ProbeID  string    `json:"probe_id"`
+	Time     time.Time `json:"time"`
+	Sent     int       `json:"sent"`
+	Received int       `json:"received"`
+	// Latency - average round trip time of the pings answered, in milliseconds
+	Latency int64  `json:"latency_ms"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ProbeReport - batch of probe results reported by a node over mq
+type ProbeReport struct {
+	Results []ProbeResult `json:"results"`
+	// EgressHealth - results of the health checks of the node's host egress ranges
+	EgressHealth []EgressHealthResult `json:"egress_health,omitempty"`
+}
+
+// ProbeStatus - a probe with its recent results and alert state
+type ProbeStatus struct {
+	Probe
+	Results       []ProbeResult `json:"results"`
+	Availability  float64       `json:"availability_percent"`
+	AvgLatency    int64         `json:"avg_latency_ms"`
+	Alerting      bool          `json:"alerting"`
+	AlertReason   string        `json:"alert_reason,omitempty"`
+	AlertingSince *time.Time    `json:"alerting_since,omitempty"`
+}
+
+// ProbeAlertEvent - posted to the webhook of a probe when its alert is raised or cleared
+type ProbeAlertEvent struct {
+	Probe    Probe     `json:"probe"`
+	Alerting bool      `json:"alerting"`
+	Reason   string    `json:"reason,omitempty"`
+	Time     time.Time `json:"time"`
+}
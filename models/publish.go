@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// QueuedPublish - a peer update waiting to reach the broker, a newer update for the same host replaces it
+type QueuedPublish struct {
+	Topic  string `json:"topic"`
+	HostID string `json:"hostid"`
+	// Payload - the message, already encrypted for the host
+	Payload     []byte    `json:"payload"`
+	Queued      time.Time `json:"queued"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"nextattempt"`
+	LastError   string    `json:"lasterror,omitempty"`
+}
+
+// HostPublishStatus - whether the latest peer update of a host reached the broker
+type HostPublishStatus struct {
+	HostID        string    `json:"hostid"`
+	Pending       bool      `json:"pending"`
+	Attempts      int       `json:"attempts"`
+	LastQueued    time.Time `json:"last_queued"`
+	LastPublished time.Time `json:"last_published"`
+	LastError     string    `json:"last_error,omitempty"`
+	LastErrorTime time.Time `json:"last_error_time"`
+}
+
+// PublishQueueStatus - the state of the queue peer updates are published from
+type PublishQueueStatus struct {
+	Workers         int  `json:"workers"`
+	BrokerConnected bool `json:"broker_connected"`
+	// Depth - peer updates waiting to reach the broker
+	Depth int `json:"depth"`
+	// Failing - hosts whose latest peer update failed to publish
+	Failing []HostPublishStatus `json:"failing"`
+}
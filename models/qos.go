@@ -0,0 +1,28 @@
+package models
+
+// QoSPolicy - how the wireguard traffic of a network is marked with DSCP values,
+// so constrained WAN links can prioritize it, eg. voip
+type QoSPolicy struct {
+	// Mode - mark sets the DSCP of every outer packet, inherit copies the DSCP of the inner packet to the outer one
+	Mode string `json:"mode" bson:"mode" yaml:"mode" validate:"required,oneof=mark inherit"`
+	// DSCP - the value outer packets are marked with, in inherit mode only those whose inner packet has none
+	DSCP int `json:"dscp" bson:"dscp" yaml:"dscp" validate:"min=0,max=63"`
+	// Classes - inner traffic marked with its own value, the first matching class wins
+	Classes []QoSClass `json:"classes,omitempty" bson:"classes,omitempty" yaml:"classes,omitempty" validate:"dive"`
+}
+
+// QoSClass - inner traffic matched by protocol and destination ports
+type QoSClass struct {
+	Name string `json:"name" bson:"name" yaml:"name" validate:"required,max=32"`
+	// Protocol - tcp or udp, any when empty
+	Protocol string `json:"protocol,omitempty" bson:"protocol,omitempty" yaml:"protocol,omitempty" validate:"omitempty,oneof=tcp udp"`
+	// Ports - single ports or ranges such as 10000-20000, any when empty
+	Ports []string `json:"ports,omitempty" bson:"ports,omitempty" yaml:"ports,omitempty"`
+	DSCP  int      `json:"dscp" bson:"dscp" yaml:"dscp" validate:"min=0,max=63"`
+}
+
+// NetworkQoS - the qos policy of one of a host's networks, sent with its peer update
+type NetworkQoS struct {
+	Network string `json:"network"`
+	QoSPolicy
+}
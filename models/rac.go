@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// RACDeviceAuth - a device sign in started for a remote access client, the user approves it on the identity provider
+type RACDeviceAuth struct {
+	// Session - polled by the client until the user approved the sign in
+	Session                 string `json:"session"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	// ExpiresIn - seconds the sign in can be approved for
+	ExpiresIn int `json:"expires_in"`
+	// Interval - seconds the client waits between polls
+	Interval int `json:"interval"`
+}
+
+// RACDeviceToken - the outcome of polling a device sign in, the token is set once the user approved it
+type RACDeviceToken struct {
+	Status string `json:"status"`
+	Token  string `json:"token,omitempty" redact:"allow"`
+	User   string `json:"user,omitempty"`
+}
+
+// RACClientRequest - asks for the ext client of the signed in user on a network, created or renewed
+type RACClientRequest struct {
+	// PublicKey - the client's own key, the server generates one when empty
+	PublicKey string `json:"public_key,omitempty"`
+	// GatewayID - the ingress gateway to connect through, assigned by the server when empty
+	GatewayID string `json:"gateway_id,omitempty"`
+}
+
+// RACClientConfig - the ext client of a remote access client with its wireguard config, renewed before it expires
+type RACClientConfig struct {
+	Client     ExtClient          `json:"client"`
+	Config     string             `json:"config"`
+	Expires    time.Time          `json:"expires"`
+	RenewAfter time.Time          `json:"renew_after"`
+	Gateways   []ExtClientGateway `json:"gateways"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// RecoveryToken - the hash of the one-time token that grants break-glass access
+type RecoveryToken struct {
+	Hash    string    `json:"hash"`
+	Created time.Time `json:"created"`
+}
+
+// RecoveryRequest - creates a superadmin, or resets an existing user to one, with a recovery token
+type RecoveryRequest struct {
+	Token    string `json:"token" redact:"true"`
+	UserName string `json:"username"`
+	Password string `json:"password" redact:"true"`
+}
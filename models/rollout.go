@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+// kinds of changes a canary rollout stages
+const (
+	// RolloutPolicy - an ACL policy file, eg. flipping the default ACL to deny
+	RolloutPolicy = "policy"
+	// RolloutJoinConfig - the join config of the network, its routes, sysctls and DNS search domains
+	RolloutJoinConfig = "join_config"
+)
+
+// statuses of a canary rollout
+const (
+	// RolloutCanary - the change is applied to the canary hosts, which are being watched
+	RolloutCanary = "canary"
+	// RolloutPromoted - the change is applied to the whole network
+	RolloutPromoted = "promoted"
+	// RolloutRolledBack - the change was taken back from the canary hosts
+	RolloutRolledBack = "rolled_back"
+)
+
+// RolloutRequest - a risky change of a network to apply to a subset of its hosts first
+type RolloutRequest struct {
+	Kind       string      `json:"kind" validate:"required,oneof=policy join_config"`
+	Policy     string      `json:"policy,omitempty" validate:"required_if=Kind policy"`
+	JoinConfig *JoinConfig `json:"join_config,omitempty" validate:"required_if=Kind join_config"`
+	// CanaryHosts - the hosts to apply the change to first, by id; CanaryPercent picks them when empty
+	CanaryHosts []string `json:"canary_hosts,omitempty"`
+	// CanaryPercent - the share of the network's hosts to apply the change to first, default 10
+	CanaryPercent int `json:"canary_percent,omitempty" validate:"omitempty,min=1,max=100"`
+	// ObserveMinutes - how long the canary hosts are watched before the change is applied to the network, default 10
+	ObserveMinutes int `json:"observe_minutes,omitempty" validate:"omitempty,min=1,max=1440"`
+	// MaxConnectivityDrop - how many percentage points the connectivity of the canary hosts may drop before the change
+	// is rolled back, default 10
+	MaxConnectivityDrop float64 `json:"max_connectivity_drop,omitempty" validate:"omitempty,min=0,max=100"`
+}
+
+// Rollout - a risky change of a network applied to canary hosts first, then to the network or rolled back
+// depending on how the connectivity of the canary hosts held up
+type Rollout struct {
+	ID         string      `json:"id"`
+	Network    string      `json:"network"`
+	Kind       string      `json:"kind"`
+	Status     string      `json:"status"`
+	User       string      `json:"user"`
+	Policy     string      `json:"policy,omitempty"`
+	JoinConfig *JoinConfig `json:"join_config,omitempty"`
+	// CanaryHosts, CanaryNodes - the hosts the change is applied to first and their nodes in the network
+	CanaryHosts         []string  `json:"canary_hosts"`
+	CanaryNodes         []string  `json:"canary_nodes"`
+	Started             time.Time `json:"started"`
+	ObserveUntil        time.Time `json:"observe_until"`
+	Finished            time.Time `json:"finished,omitempty"`
+	MaxConnectivityDrop float64   `json:"max_connectivity_drop"`
+	// Extended - the observation was extended once because the canary nodes reported no metrics since the start
+	Extended bool `json:"extended,omitempty"`
+	// BaselineConnectivity, CanaryConnectivity - the percentage of the peers the canary nodes should reach that they
+	// were connected to before the change and when it was evaluated, -1 when they reported no metrics; only metrics
+	// reported a metrics interval after the start count for the evaluation
+	BaselineConnectivity float64 `json:"baseline_connectivity"`
+	CanaryConnectivity   float64 `json:"canary_connectivity"`
+	// Reason - why the rollout was rolled back
+	Reason string `json:"reason,omitempty"`
+	// ACLs - the node ACLs the canary stage changed, as they were, to roll back
+	ACLs map[string]map[string]byte `json:"acls,omitempty"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// RuntimeStats - a snapshot of the server process's go runtime
+type RuntimeStats struct {
+	GoVersion  string    `json:"go_version"`
+	StartedAt  time.Time `json:"started_at"`
+	Uptime     string    `json:"uptime"`
+	NumCPU     int       `json:"num_cpu"`
+	GOMAXPROCS int       `json:"gomaxprocs"`
+	Goroutines int       `json:"goroutines"`
+	// HeapAlloc - bytes of allocated heap objects
+	HeapAlloc   uint64 `json:"heap_alloc"`
+	HeapInuse   uint64 `json:"heap_inuse"`
+	HeapObjects uint64 `json:"heap_objects"`
+	// Sys - bytes of memory obtained from the OS
+	Sys          uint64    `json:"sys"`
+	NumGC        uint32    `json:"num_gc"`
+	LastGC       time.Time `json:"last_gc"`
+	PauseTotalNs uint64    `json:"pause_total_ns"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// kinds of scheduled reports
+const (
+	// ScheduledReportNetworkHealth - nodes online and the link quality of networks
+	ScheduledReportNetworkHealth = "network_health"
+	// ScheduledReportUserAccess - the users with access to networks and their roles
+	ScheduledReportUserAccess = "user_access"
+	// ScheduledReportExpiring - the enrollment keys, ext client keys and certificates expiring soon
+	ScheduledReportExpiring = "expiring"
+)
+
+// how often scheduled reports are sent
+const (
+	ReportFrequencyDaily   = "daily"
+	ReportFrequencyWeekly  = "weekly"
+	ReportFrequencyMonthly = "monthly"
+)
+
+// ScheduledReport - a report rendered by the server on a schedule and delivered by email or to webhooks
+type ScheduledReport struct {
+	ID        string `json:"id"`
+	Name      string `json:"name" validate:"required,max=64"`
+	Kind      string `json:"kind" validate:"required,oneof=network_health user_access expiring"`
+	Frequency string `json:"frequency" validate:"required,oneof=daily weekly monthly"`
+	// Networks - the networks the report covers, all of them (of the tenant) when empty
+	Networks []string `json:"networks,omitempty"`
+	Tenant   string   `json:"tenant,omitempty"`
+	Emails   []string `json:"emails,omitempty" validate:"max=20,dive,email"`
+	// Webhooks - urls the rendered report is posted to as json
+	Webhooks []string `json:"webhooks,omitempty" validate:"max=5,dive,url"`
+	// Channels - ids of the notification channels the report is sent to
+	Channels  []string  `json:"channels,omitempty"`
+	CreatedBy string    `json:"created_by,omitempty"`
+	LastSent  time.Time `json:"last_sent,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// RenderedReport - a scheduled report as it's emailed, and posted to webhooks
+type RenderedReport struct {
+	ReportID  string    `json:"report_id"`
+	Name      string    `json:"name"`
+	Kind      string    `json:"kind"`
+	Subject   string    `json:"subject"`
+	Body      string    `json:"body"`
+	Generated time.Time `json:"generated"`
+}
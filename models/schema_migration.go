@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// SchemaMigration - a data migration applied to the database
+type SchemaMigration struct {
+	Version   int       `json:"version"`
+	Name      string    `json:"name"`
+	Checksum  string    `json:"checksum"`
+	AppliedAt time.Time `json:"applied_at"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+const (
+	// RevokedExtClientKey - the key of an ext client that was deleted, disabled or rotated
+	RevokedExtClientKey = "extclient"
+	// RevokedHostKey - the key of a host that was removed from the server
+	RevokedHostKey = "host"
+
+	// SecurityEventRevokedKeyReuse - a gateway saw a handshake from a revoked key
+	SecurityEventRevokedKeyReuse = "revoked_key_reuse"
+)
+
+// RevokedKey - a public key that is no longer allowed on any network, kept to catch it being reused
+type RevokedKey struct {
+	PublicKey string    `json:"public_key"`
+	Kind      string    `json:"kind"`
+	ID        string    `json:"id"`
+	Network   string    `json:"network,omitempty"`
+	OwnerID   string    `json:"owner_id,omitempty"`
+	Reason    string    `json:"reason"`
+	Revoked   time.Time `json:"revoked"`
+}
+
+// SecurityEvent - a security alert raised by the server
+type SecurityEvent struct {
+	ID          string      `json:"id"`
+	Time        time.Time   `json:"time"`
+	Kind        string      `json:"kind"`
+	Network     string      `json:"network,omitempty"`
+	Tenant      string      `json:"tenant,omitempty"`
+	GatewayID   string      `json:"gateway_id,omitempty"`
+	GatewayHost string      `json:"gateway_host,omitempty"`
+	SourceIP    string      `json:"source_ip,omitempty"`
+	RevokedKey  *RevokedKey `json:"revoked_key,omitempty"`
+	Message     string      `json:"message"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// SidecarRequest - a sidecar container asking to join a network, or to renew its lease
+type SidecarRequest struct {
+	// Name - the client id to register as, generated when empty
+	Name string `json:"name,omitempty"`
+	// Network - one of the enrollment key's networks, its first when empty
+	Network string `json:"network,omitempty"`
+	// PublicKey - the container's wireguard public key, a key pair is generated when empty
+	PublicKey string `json:"publickey,omitempty"`
+	// Lease - seconds until the registration expires unless renewed
+	Lease int64 `json:"lease,omitempty"`
+}
+
+// SidecarRegistration - a registered sidecar and the wireguard config it should bring up
+type SidecarRegistration struct {
+	ClientID    string    `json:"clientid"`
+	Network     string    `json:"network"`
+	Address     string    `json:"address,omitempty"`
+	Address6    string    `json:"address6,omitempty"`
+	LeaseExpiry time.Time `json:"lease_expiry"`
+	Config      string    `json:"config"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// SigningKey - an ed25519 key the server signs the messages it publishes to hosts with,
+// so a host can tell them from messages injected at the broker
+type SigningKey struct {
+	// ID - the key id messages are signed under, the hex of the first bytes of the sha256 of the public key
+	ID        string    `json:"id"`
+	PublicKey []byte    `json:"public_key"`
+	Created   time.Time `json:"created"`
+	// Retired - when the key stopped signing, zero for the active key; a retired key still verifies until it expires
+	Retired time.Time `json:"retired,omitempty"`
+}
+
+// SignedMessage - a message published to a host with the signature of the server over its topic, time, nonce
+// and payload; hosts drop a message outside of the replay window or with a nonce they accepted, so it can't be
+// replayed, and one received on another topic than it was signed for
+type SignedMessage struct {
+	KeyID string `json:"kid"`
+	Topic string `json:"topic"`
+	Time  int64  `json:"time"`
+	// Nonce - random hex unique to the message
+	Nonce     string `json:"nonce"`
+	Payload   []byte `json:"payload"`
+	Signature []byte `json:"signature"`
+}
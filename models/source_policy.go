@@ -0,0 +1,17 @@
+package models
+
+const (
+	// SourcePolicyFlag - connections from outside the allowed sources are recorded
+	SourcePolicyFlag = "flag"
+	// SourcePolicyBlock - connections from outside the allowed sources are recorded and the ext client is removed from its gateways
+	SourcePolicyBlock = "block"
+)
+
+// SourcePolicy - the source cidrs and countries a user's ext clients may connect to their gateways from,
+// a source matching either list is allowed
+type SourcePolicy struct {
+	User             string   `json:"user"`
+	AllowedCIDRs     []string `json:"allowed_cidrs"`
+	AllowedCountries []string `json:"allowed_countries"`
+	Action           string   `json:"action" validate:"required,oneof=flag block"`
+}
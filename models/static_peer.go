@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// StaticPeer - a wireguard device that doesn't run netclient, such as a router or appliance,
+// peered directly with every node of a network
+type StaticPeer struct {
+	ID        string `json:"id"`
+	Name      string `json:"name" validate:"required,min=1,max=63"`
+	Network   string `json:"network"`
+	PublicKey string `json:"publickey" validate:"required"`
+	// Endpoint - where nodes reach the device, nodes wait for it to connect when empty
+	Endpoint string `json:"endpoint,omitempty" validate:"omitempty,hostname_port"`
+	Address  string `json:"address,omitempty"`
+	Address6 string `json:"address6,omitempty"`
+	// AllowedIPs - ranges behind the device that are routed to it
+	AllowedIPs          []string `json:"allowedips,omitempty" validate:"omitempty,dive,cidr"`
+	PersistentKeepalive int32    `json:"persistentkeepalive,omitempty" validate:"omitempty,min=0,max=1000"`
+	// PendingNetclient - a placeholder for a device migrated from another VPN until it joins with netclient,
+	// it isn't given to nodes as a peer
+	PendingNetclient bool      `json:"pendingnetclient,omitempty"`
+	LastModified     time.Time `json:"lastmodified"`
+}
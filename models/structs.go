@@ -19,30 +19,49 @@ const (
 type AuthParams struct {
 	MacAddress string `json:"macaddress"`
 	ID         string `json:"id"`
-	Password   string `json:"password"`
+	Password   string `json:"password" redact:"true"`
 }
 
 // User struct - struct for Users
 type User struct {
-	UserName string   `json:"username" bson:"username" validate:"min=3,max=40,in_charset|email"`
-	Password string   `json:"password" bson:"password" validate:"required,min=5"`
-	Networks []string `json:"networks" bson:"networks"`
-	IsAdmin  bool     `json:"isadmin" bson:"isadmin"`
-	Groups   []string `json:"groups" bson:"groups" yaml:"groups"`
+	UserName  string   `json:"username" bson:"username" validate:"min=3,max=40,in_charset|email"`
+	Password  string   `json:"password" bson:"password" validate:"required,min=5" redact:"true"`
+	Networks  []string `json:"networks" bson:"networks"`
+	IsAdmin   bool     `json:"isadmin" bson:"isadmin"`
+	IsAuditor bool     `json:"isauditor,omitempty" bson:"isauditor,omitempty" yaml:"isauditor,omitempty"`
+	Groups    []string `json:"groups" bson:"groups" yaml:"groups"`
+	Tenant    string   `json:"tenant,omitempty" bson:"tenant,omitempty" yaml:"tenant,omitempty"`
+	// profile shown on the dashboard and in reports instead of the bare username
+	DisplayName string `json:"display_name,omitempty" bson:"display_name,omitempty" yaml:"display_name,omitempty" validate:"max=100"`
+	Email       string `json:"email,omitempty" bson:"email,omitempty" yaml:"email,omitempty" validate:"omitempty,email"`
+	Department  string `json:"department,omitempty" bson:"department,omitempty" yaml:"department,omitempty" validate:"max=100"`
+	AvatarURL   string `json:"avatar_url,omitempty" bson:"avatar_url,omitempty" yaml:"avatar_url,omitempty" validate:"omitempty,max=2048,url,startswith=https://|startswith=http://"`
+	// Metadata - free-form attributes, such as an employee id or cost center
+	Metadata map[string]string `json:"metadata,omitempty" bson:"metadata,omitempty" yaml:"metadata,omitempty" validate:"max=32,dive,keys,min=1,max=64,endkeys,max=512"`
+	// Pending - the user can't sign in until they visit the link emailed to verify their address
+	Pending bool `json:"pending,omitempty" bson:"pending,omitempty" yaml:"pending,omitempty"`
 }
 
 // ReturnUser - return user struct
 type ReturnUser struct {
-	UserName string   `json:"username" bson:"username"`
-	Networks []string `json:"networks" bson:"networks"`
-	IsAdmin  bool     `json:"isadmin" bson:"isadmin"`
-	Groups   []string `json:"groups" bson:"groups"`
+	UserName    string            `json:"username" bson:"username"`
+	Networks    []string          `json:"networks" bson:"networks"`
+	IsAdmin     bool              `json:"isadmin" bson:"isadmin"`
+	IsAuditor   bool              `json:"isauditor,omitempty" bson:"isauditor,omitempty"`
+	Groups      []string          `json:"groups" bson:"groups"`
+	Tenant      string            `json:"tenant,omitempty" bson:"tenant,omitempty"`
+	DisplayName string            `json:"display_name,omitempty" bson:"display_name,omitempty"`
+	Email       string            `json:"email,omitempty" bson:"email,omitempty"`
+	Department  string            `json:"department,omitempty" bson:"department,omitempty"`
+	AvatarURL   string            `json:"avatar_url,omitempty" bson:"avatar_url,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty" bson:"metadata,omitempty"`
+	Pending     bool              `json:"pending,omitempty" bson:"pending,omitempty"`
 }
 
 // UserAuthParams - user auth params struct
 type UserAuthParams struct {
 	UserName string `json:"username"`
-	Password string `json:"password"`
+	Password string `json:"password" redact:"true"`
 }
 
 // UserClaims - user claims struct
@@ -57,7 +76,7 @@ type UserClaims struct {
 // SuccessfulUserLoginResponse - successlogin struct
 type SuccessfulUserLoginResponse struct {
 	UserName  string
-	AuthToken string
+	AuthToken string `redact:"allow"`
 }
 
 // Claims is  a struct that will be encoded to a JWT.
@@ -72,7 +91,7 @@ type Claims struct {
 // SuccessfulLoginResponse is struct to send the request response
 type SuccessfulLoginResponse struct {
 	ID        string
-	AuthToken string
+	AuthToken string `redact:"allow"`
 }
 
 // ErrorResponse is struct for error
@@ -84,7 +103,7 @@ type ErrorResponse struct {
 // NodeAuth - struct for node auth
 type NodeAuth struct {
 	Network    string
-	Password   string
+	Password   string `redact:"true"`
 	MacAddress string // Depricated
 	ID         string
 }
@@ -150,6 +169,8 @@ type EgressGatewayRequest struct {
 	NetID      string   `json:"netid" bson:"netid"`
 	NatEnabled string   `json:"natenabled" bson:"natenabled"`
 	Ranges     []string `json:"ranges" bson:"ranges"`
+	// HealthChecks - optional checks the gateway runs against its ranges
+	HealthChecks []EgressHealthCheck `json:"health_checks,omitempty" bson:"health_checks,omitempty"`
 }
 
 // RelayRequest - relay request struct
@@ -171,6 +192,11 @@ type IngressRequest struct {
 	Failover     bool   `json:"failover"`
 }
 
+// NodeNameRequest - struct for renaming a node
+type NodeNameRequest struct {
+	Name string `json:"name" validate:"required,max=63"`
+}
+
 // ServerUpdateData - contains data to configure server
 // and if it should set peers
 type ServerUpdateData struct {
@@ -201,11 +227,14 @@ type TrafficKeys struct {
 
 // HostPull - response of a host's pull
 type HostPull struct {
-	Host         Host                 `json:"host" yaml:"host"`
-	Nodes        []Node               `json:"nodes" yaml:"nodes"`
-	Peers        []wgtypes.PeerConfig `json:"peers" yaml:"peers"`
-	ServerConfig ServerConfig         `json:"server_config" yaml:"server_config"`
-	PeerIDs      PeerMap              `json:"peer_ids,omitempty" yaml:"peer_ids,omitempty"`
+	Host         Host                  `json:"host" yaml:"host"`
+	Nodes        []Node                `json:"nodes" yaml:"nodes"`
+	Peers        []wgtypes.PeerConfig  `json:"peers" yaml:"peers"`
+	ServerConfig ServerConfig          `json:"server_config" yaml:"server_config"`
+	PeerIDs      PeerMap               `json:"peer_ids,omitempty" yaml:"peer_ids,omitempty"`
+	JoinConfigs  map[string]JoinConfig `json:"join_configs,omitempty" yaml:"join_configs,omitempty"`
+	// SigningKeys - the keys to verify the messages the server publishes with
+	SigningKeys []SigningKey `json:"signing_keys,omitempty" yaml:"signing_keys,omitempty"`
 }
 
 // NodeGet - struct for a single node get response
@@ -235,7 +264,7 @@ type ServerConfig struct {
 	Version     string       `yaml:"version"`
 	MQPort      string       `yaml:"mqport"`
 	MQUserName  string       `yaml:"mq_username"`
-	MQPassword  string       `yaml:"mq_password"`
+	MQPassword  string       `yaml:"mq_password" redact:"allow"`
 	Server      string       `yaml:"server"`
 	Broker      string       `yaml:"broker"`
 	Is_EE       bool         `yaml:"isee"`
@@ -278,6 +307,8 @@ type StunServer struct {
 
 // HookDetails - struct to hold hook info
 type HookDetails struct {
+	// Name - names the job the hook is run as
+	Name     string
 	Hook     func() error
 	Interval time.Duration
 }
@@ -290,3 +321,116 @@ type LicenseLimits struct {
 	Clients  int `json:"clients"`
 	Networks int `json:"networks"`
 }
+
+// CacheStats - size and hit rate of an in memory cache
+type CacheStats struct {
+	Name    string  `json:"name"`
+	Size    int     `json:"size"`
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+// CertificateStatus - state of the certificate issued to the server through ACME
+type CertificateStatus struct {
+	Enabled     bool      `json:"enabled"`
+	Domains     []string  `json:"domains"`
+	Challenge   string    `json:"challenge"`
+	Issuer      string    `json:"issuer,omitempty"`
+	NotBefore   time.Time `json:"not_before,omitempty"`
+	NotAfter    time.Time `json:"not_after,omitempty"`
+	LastRenewal time.Time `json:"last_renewal,omitempty"`
+	NextRenewal time.Time `json:"next_renewal,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// ServerSettings - server config values that can be changed at runtime through the settings API
+type ServerSettings struct {
+	Telemetry           string            `json:"telemetry" validate:"oneof=on off"`
+	NetclientAutoUpdate bool              `json:"netclient_auto_update"`
+	DefaultMTU          int               `json:"default_mtu" validate:"min=1280,max=9000"`
+	RateLimit           RateLimitSettings `json:"rate_limit"`
+	SMTP                SMTPSettings      `json:"smtp"`
+	OAuth               OAuthSettings     `json:"oauth"`
+	SIEM                SIEMSettings      `json:"siem"`
+	APIAllowlist        []string          `json:"api_allowlist" validate:"dive,cidr"`
+	CORS                CORSSettings      `json:"cors"`
+	SecurityHeaders     SecurityHeaders   `json:"security_headers"`
+	LoginNotifications  bool              `json:"login_notifications"`
+	EmailVerification   bool              `json:"email_verification"`
+	OPA                 OPASettings       `json:"opa"`
+}
+
+// OPASettings - an Open Policy Agent endpoint asked to allow every authorized API request, off without a url
+type OPASettings struct {
+	// URL - the decision endpoint of a policy, eg. http://opa:8181/v1/data/netmaker/authz/allow
+	URL   string `json:"url" validate:"omitempty,url"`
+	Token string `json:"token,omitempty" redact:"true"`
+	// FailOpen - lets requests through when the endpoint can't be reached or answers with an error, they are refused otherwise
+	FailOpen bool `json:"fail_open"`
+}
+
+// CORSSettings - the origins browsers may call the API from, such as dashboards on custom domains;
+// empty lists use the defaults
+type CORSSettings struct {
+	AllowedOrigins   []string `json:"allowed_origins"`
+	AllowedHeaders   []string `json:"allowed_headers"`
+	AllowedMethods   []string `json:"allowed_methods" validate:"dive,oneof=GET HEAD POST PUT PATCH DELETE"`
+	AllowCredentials bool     `json:"allow_credentials"`
+	MaxAge           int      `json:"max_age" validate:"min=0,max=86400"`
+}
+
+// SecurityHeaders - security headers set on every API response, empty values use the defaults
+type SecurityHeaders struct {
+	ContentSecurityPolicy string   `json:"content_security_policy"`
+	FrameAncestors        []string `json:"frame_ancestors"`
+	ReferrerPolicy        string   `json:"referrer_policy" validate:"omitempty,oneof=no-referrer no-referrer-when-downgrade origin origin-when-cross-origin same-origin strict-origin strict-origin-when-cross-origin unsafe-url"`
+	HSTSMaxAge            int      `json:"hsts_max_age" validate:"min=0"`
+}
+
+// RateLimitSettings - per client limits of API requests, a rate of 0 disables rate limiting
+type RateLimitSettings struct {
+	RequestsPerSecond float64 `json:"requests_per_second" validate:"min=0"`
+	Burst             int     `json:"burst" validate:"min=0"`
+}
+
+// SMTPSettings - mail server used to send emails
+type SMTPSettings struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port" validate:"min=0,max=65535"`
+	Username string `json:"username"`
+	Password string `json:"password,omitempty" redact:"true"`
+	From     string `json:"from" validate:"omitempty,email"`
+}
+
+// OAuthSettings - the OAuth provider users can sign in with
+type OAuthSettings struct {
+	Provider     string `json:"provider" validate:"omitempty,oneof=google azure-ad github oidc"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret,omitempty" redact:"true"`
+	OIDCIssuer   string `json:"oidc_issuer"`
+	AzureTenant  string `json:"azure_tenant"`
+}
+
+// SIEMSettings - remote syslog endpoint audit and authentication events are shipped to,
+// shipping is off without an address
+type SIEMSettings struct {
+	Address  string `json:"address" validate:"omitempty,hostname_port"`
+	Protocol string `json:"protocol" validate:"omitempty,oneof=tls tcp udp"`
+	Format   string `json:"format" validate:"omitempty,oneof=syslog cef"`
+	CACert   string `json:"ca_cert,omitempty"`
+}
+
+// HealthCheck - result of checking a single dependency or background worker of the server
+type HealthCheck struct {
+	Name     string    `json:"name"`
+	Healthy  bool      `json:"healthy"`
+	Error    string    `json:"error,omitempty"`
+	LastSeen time.Time `json:"last_seen,omitempty"`
+}
+
+// HealthReport - results of the health or readiness checks of the server
+type HealthReport struct {
+	Healthy bool          `json:"healthy"`
+	Checks  []HealthCheck `json:"checks"`
+}
@@ -0,0 +1,25 @@
+package models
+
+// kinds of node suggestions
+const (
+	// SuggestionRelay - the candidate would relay the node
+	SuggestionRelay = "relay"
+	// SuggestionFailover - the candidate would be a failover of the network, which the node's traffic fails over to
+	SuggestionFailover = "failover"
+)
+
+// NodeSuggestion - a node that would help a node that can't reach some of its peers, as its relay or as a failover
+type NodeSuggestion struct {
+	ID            string `json:"id"`
+	Kind          string `json:"kind"`
+	NodeID        string `json:"node_id"`
+	NodeName      string `json:"node_name"`
+	CandidateID   string `json:"candidate_id"`
+	CandidateName string `json:"candidate_name"`
+	// Latency - between the node and the candidate, as the node reported it
+	Latency int64 `json:"latency"`
+	// Unreachable - the peers the node can't reach
+	Unreachable int `json:"unreachable"`
+	// Reachable - the peers the node can't reach that the candidate reaches
+	Reachable int `json:"reachable"`
+}
@@ -0,0 +1,126 @@
+package models
+
+// TailnetSourceTailscale - an import from a Tailscale API dump
+const TailnetSourceTailscale = "tailscale"
+
+// TailnetSourceHeadscale - an import from a Headscale export
+const TailnetSourceHeadscale = "headscale"
+
+// kinds of what a tailnet import maps
+const (
+	TailnetMappingNetwork = "network"
+	TailnetMappingUser    = "user"
+	TailnetMappingDevice  = "device"
+	TailnetMappingRoute   = "route"
+	TailnetMappingACL     = "acl"
+)
+
+// TailnetImportRequest - a Tailscale or Headscale tailnet to recreate as a netmaker network
+type TailnetImportRequest struct {
+	// Source - tailscale or headscale, with the export of that source set
+	Source string `json:"source"`
+	// Network - the network to import into, created with AddressRange if it doesn't exist
+	Network      string `json:"network"`
+	AddressRange string `json:"addressrange,omitempty"`
+	// Tailscale - the responses of the Tailscale API's devices and users endpoints
+	Tailscale *TailscaleExport `json:"tailscale,omitempty"`
+	// Headscale - the json output of headscale nodes list, users list and routes list
+	Headscale *HeadscaleExport `json:"headscale,omitempty"`
+	// Policy - the tailnet's ACL policy file as plain json
+	Policy *TailnetPolicy `json:"policy,omitempty"`
+	DryRun bool           `json:"dryrun"`
+}
+
+// TailscaleExport - devices and users as returned by the Tailscale API
+type TailscaleExport struct {
+	Devices []TailscaleDevice `json:"devices"`
+	Users   []TailscaleUser   `json:"users"`
+}
+
+// TailscaleDevice - a device of GET /api/v2/tailnet/{tailnet}/devices?fields=all
+type TailscaleDevice struct {
+	ID               string   `json:"id"`
+	Name             string   `json:"name"`
+	Hostname         string   `json:"hostname"`
+	User             string   `json:"user"`
+	Addresses        []string `json:"addresses"`
+	NodeKey          string   `json:"nodeKey"`
+	Tags             []string `json:"tags"`
+	AdvertisedRoutes []string `json:"advertisedRoutes"`
+	EnabledRoutes    []string `json:"enabledRoutes"`
+}
+
+// TailscaleUser - a user of GET /api/v2/tailnet/{tailnet}/users
+type TailscaleUser struct {
+	ID          string `json:"id"`
+	LoginName   string `json:"loginName"`
+	DisplayName string `json:"displayName"`
+	Role        string `json:"role"`
+}
+
+// HeadscaleExport - the json output of the headscale cli
+type HeadscaleExport struct {
+	Nodes  []HeadscaleNode  `json:"nodes"`
+	Users  []HeadscaleUser  `json:"users"`
+	Routes []HeadscaleRoute `json:"routes"`
+}
+
+// HeadscaleNode - a node of headscale nodes list -o json
+type HeadscaleNode struct {
+	ID          uint64        `json:"id"`
+	Name        string        `json:"name"`
+	GivenName   string        `json:"given_name"`
+	NodeKey     string        `json:"node_key"`
+	IPAddresses []string      `json:"ip_addresses"`
+	User        HeadscaleUser `json:"user"`
+	ForcedTags  []string      `json:"forced_tags"`
+	ValidTags   []string      `json:"valid_tags"`
+}
+
+// HeadscaleUser - a user of headscale users list -o json
+type HeadscaleUser struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// HeadscaleRoute - a route of headscale routes list -o json
+type HeadscaleRoute struct {
+	Node       HeadscaleNode `json:"node"`
+	Prefix     string        `json:"prefix"`
+	Advertised bool          `json:"advertised"`
+	Enabled    bool          `json:"enabled"`
+}
+
+// TailnetPolicy - the parts of a tailnet ACL policy that can be converted
+type TailnetPolicy struct {
+	Groups map[string][]string `json:"groups"`
+	Hosts  map[string]string   `json:"hosts"`
+	ACLs   []TailnetACL        `json:"acls"`
+	// SSH - tailscale ssh rules, reported as not converted
+	SSH []any `json:"ssh,omitempty"`
+}
+
+// TailnetACL - an ACL rule of a tailnet policy
+type TailnetACL struct {
+	Action string   `json:"action"`
+	Src    []string `json:"src"`
+	Dst    []string `json:"dst"`
+}
+
+// TailnetMapping - what a part of a tailnet became, or why it couldn't be converted
+type TailnetMapping struct {
+	Kind      string `json:"kind"`
+	Source    string `json:"source"`
+	Target    string `json:"target,omitempty"`
+	Converted bool   `json:"converted"`
+	// Reason - why it wasn't converted, or what was lost converting it
+	Reason string `json:"reason,omitempty"`
+}
+
+// TailnetImportResult - the report of a tailnet import
+type TailnetImportResult struct {
+	DryRun         bool             `json:"dryrun"`
+	Network        string           `json:"network"`
+	NetworkCreated bool             `json:"networkcreated"`
+	Mappings       []TailnetMapping `json:"mappings"`
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// Tenant - an organization whose networks, users and enrollment keys are isolated from other tenants
+type Tenant struct {
+	ID        string    `json:"id" validate:"required,min=1,max=32,alphanum"`
+	Name      string    `json:"name" validate:"max=128"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,78 @@
+package models
+
+// TrafficUsageKind - the type of object a traffic usage record belongs to
+type TrafficUsageKind string
+
+const (
+	// TrafficUsageNode - usage recorded for a node
+	TrafficUsageNode TrafficUsageKind = "node"
+	// TrafficUsageExtClient - usage recorded for an ext client
+	TrafficUsageExtClient TrafficUsageKind = "extclient"
+)
+
+// TrafficUsage - daily transfer totals for a node or ext client
+type TrafficUsage struct {
+	ID       string           `json:"id"`
+	Kind     TrafficUsageKind `json:"kind"`
+	Name     string           `json:"name"`
+	Network  string           `json:"network"`
+	OwnerID  string           `json:"owner_id"`
+	Day      string           `json:"day"`
+	Sent     int64            `json:"sent"`
+	Received int64            `json:"received"`
+}
+
+// TrafficUsageTotal - aggregated transfer totals for a single object or time bucket
+type TrafficUsageTotal struct {
+	ID       string           `json:"id"`
+	Kind     TrafficUsageKind `json:"kind,omitempty"`
+	Name     string           `json:"name,omitempty"`
+	Network  string           `json:"network,omitempty"`
+	Sent     int64            `json:"sent"`
+	Received int64            `json:"received"`
+	Total    int64            `json:"total"`
+}
+
+// TrafficUsageReport - usage report returned by the usage API
+type TrafficUsageReport struct {
+	Period     string              `json:"period"`
+	From       string              `json:"from"`
+	To         string              `json:"to"`
+	Buckets    []TrafficUsageTotal `json:"buckets"`
+	Nodes      []TrafficUsageTotal `json:"nodes"`
+	ExtClients []TrafficUsageTotal `json:"ext_clients"`
+	Users      []TrafficUsageTotal `json:"users"`
+	TopTalkers []TrafficUsageTotal `json:"top_talkers"`
+}
+
+// UsageSnapshot - the number of nodes, users and ext clients of a network on a given day
+type UsageSnapshot struct {
+	Network    string `json:"network"`
+	Tenant     string `json:"tenant,omitempty"`
+	Day        string `json:"day"`
+	Nodes      int    `json:"nodes"`
+	Users      int    `json:"users"`
+	ExtClients int    `json:"ext_clients"`
+}
+
+// UsageReportRow - usage of a network or tenant over one billing period,
+// counts are the peak seen during the period
+type UsageReportRow struct {
+	Period     string `json:"period"`
+	Network    string `json:"network,omitempty"`
+	Tenant     string `json:"tenant,omitempty"`
+	Nodes      int    `json:"nodes"`
+	Users      int    `json:"users"`
+	ExtClients int    `json:"ext_clients"`
+	Sent       int64  `json:"sent"`
+	Received   int64  `json:"received"`
+}
+
+// UsageReport - usage report returned by the reports API
+type UsageReport struct {
+	Period  string           `json:"period"`
+	GroupBy string           `json:"group_by"`
+	From    string           `json:"from"`
+	To      string           `json:"to"`
+	Rows    []UsageReportRow `json:"rows"`
+}
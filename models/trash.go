@@ -0,0 +1,34 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// TrashKind - the kind of a deleted resource kept in the trash
+type TrashKind string
+
+const (
+	// TrashNetwork - a deleted network
+	TrashNetwork TrashKind = "network"
+	// TrashNode - a deleted node
+	TrashNode TrashKind = "node"
+	// TrashExtClient - a deleted ext client
+	TrashExtClient TrashKind = "extclient"
+)
+
+// TrashedResource - a deleted network, node or ext client kept until it expires so it can be restored
+type TrashedResource struct {
+	ID   string    `json:"id"`
+	Kind TrashKind `json:"kind"`
+	// ResourceID - the id the resource had before it was deleted
+	ResourceID string `json:"resource_id"`
+	Name       string `json:"name"`
+	Network    string `json:"network"`
+	Tenant     string `json:"tenant,omitempty"`
+	// Data - the resource as it was when deleted
+	Data      json.RawMessage `json:"data"`
+	DeletedBy string          `json:"deleted_by"`
+	Deleted   time.Time       `json:"deleted"`
+	Expires   time.Time       `json:"expires"`
+}
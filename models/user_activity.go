@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// ExtClientSession - a connection of an ext client to one of its gateways, open until the gateway stops seeing its handshakes
+type ExtClientSession struct {
+	ClientID    string    `json:"clientid"`
+	Network     string    `json:"network"`
+	OwnerID     string    `json:"owner_id,omitempty"`
+	GatewayID   string    `json:"gateway_id"`
+	GatewayName string    `json:"gateway_name"`
+	SourceIP    string    `json:"source_ip,omitempty"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end,omitempty"`
+	// LastSeen - the last metrics report of the gateway with a recent handshake of the client
+	LastSeen time.Time `json:"last_seen"`
+	Sent     int64     `json:"sent"`
+	Received int64     `json:"received"`
+	Open     bool      `json:"open"`
+}
+
+// UserGatewayActivity - the connections of a user's ext clients through one gateway
+type UserGatewayActivity struct {
+	GatewayID     string    `json:"gateway_id"`
+	GatewayName   string    `json:"gateway_name"`
+	Network       string    `json:"network"`
+	LastHandshake time.Time `json:"last_handshake"`
+	Sessions      int       `json:"sessions"`
+	// ConnectedSeconds - the summed duration of the sessions
+	ConnectedSeconds int64    `json:"connected_seconds"`
+	Sent             int64    `json:"sent"`
+	Received         int64    `json:"received"`
+	SourceIPs        []string `json:"source_ips"`
+}
+
+// UserActivity - the remote access usage of a user over a period, for security reviews
+type UserActivity struct {
+	User             string                `json:"user"`
+	From             time.Time             `json:"from"`
+	To               time.Time             `json:"to"`
+	ConnectedSeconds int64                 `json:"connected_seconds"`
+	Sent             int64                 `json:"sent"`
+	Received         int64                 `json:"received"`
+	SourceIPs        []string              `json:"source_ips"`
+	Gateways         []UserGatewayActivity `json:"gateways"`
+	Sessions         []ExtClientSession    `json:"sessions"`
+}
@@ -0,0 +1,50 @@
+package models
+
+const (
+	// UserRoleAdmin - a server admin
+	UserRoleAdmin = "admin"
+	// UserRoleAuditor - a read only user of every network
+	UserRoleAuditor = "auditor"
+	// UserRoleUser - a user of the networks and gateways they are given
+	UserRoleUser = "user"
+)
+
+// UserImportRow - a user to create with a bulk import
+type UserImportRow struct {
+	UserName string `json:"username"`
+	// Password - left empty for users who sign in with oauth, a random one is set
+	Password string   `json:"password,omitempty" redact:"true"`
+	Role     string   `json:"role" validate:"omitempty,oneof=admin auditor user"`
+	Networks []string `json:"networks"`
+	Groups   []string `json:"groups"`
+	// Gateways - ids of the ingress gateways the user may use
+	Gateways    []string `json:"gateways"`
+	Tenant      string   `json:"tenant,omitempty"`
+	DisplayName string   `json:"display_name,omitempty"`
+	Email       string   `json:"email,omitempty"`
+	Department  string   `json:"department,omitempty"`
+}
+
+// UserImportRequest - users to create at once, for onboarding large teams
+type UserImportRequest struct {
+	Users []UserImportRow `json:"users"`
+	// DryRun - only validate the users
+	DryRun bool `json:"dryrun"`
+}
+
+// UserImportRowResult - the outcome of importing a user, rows count from 1
+type UserImportRowResult struct {
+	Row      int    `json:"row"`
+	UserName string `json:"username"`
+	// Status - created, valid on a dry run, or failed
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// UserImportResult - the outcome of a bulk user import, row by row
+type UserImportResult struct {
+	DryRun  bool                  `json:"dryrun"`
+	Created int                   `json:"created"`
+	Failed  int                   `json:"failed"`
+	Results []UserImportRowResult `json:"results"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+const (
+	// UserSecurityEventNewDevice - a user signed in from a source ip and user agent not seen before
+	UserSecurityEventNewDevice = "new_login_device"
+	// UserSecurityEventExtClient - a user enrolled a new ext client
+	UserSecurityEventExtClient = "ext_client_enrolled"
+)
+
+// UserDevice - a source ip and user agent a user signed in from
+type UserDevice struct {
+	ID        string    `json:"id"`
+	User      string    `json:"user"`
+	SourceIP  string    `json:"source_ip"`
+	UserAgent string    `json:"user_agent"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// UserSecurityEvent - a login from a new device or a new ext client of a user, shown on their security page
+type UserSecurityEvent struct {
+	ID        string    `json:"id"`
+	User      string    `json:"user"`
+	Time      time.Time `json:"time"`
+	Kind      string    `json:"kind"`
+	SourceIP  string    `json:"source_ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Provider  string    `json:"provider,omitempty"`
+	Network   string    `json:"network,omitempty"`
+	ClientID  string    `json:"clientid,omitempty"`
+	Message   string    `json:"message"`
+	// Notified - whether the user was emailed about it
+	Notified bool `json:"notified"`
+}
+
+// UserSecurity - the devices a user signed in from and their recent security events, newest first
+type UserSecurity struct {
+	User    string              `json:"user"`
+	Devices []UserDevice        `json:"devices"`
+	Events  []UserSecurityEvent `json:"events"`
+}
+
+// EmailVerification - a link emailed to a new user, visiting it before it expires verifies their address
+type EmailVerification struct {
+	User    string    `json:"user"`
+	Email   string    `json:"email"`
+	Expires time.Time `json:"expires"`
+}
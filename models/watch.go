@@ -0,0 +1,19 @@
+package models
+
+// WatchEvent - a change to a node or host reported to a watch request
+type WatchEvent struct {
+	Type            string      `json:"type"`
+	Kind            string      `json:"kind"`
+	ID              string      `json:"id"`
+	ResourceVersion int64       `json:"resourceVersion"`
+	Object          interface{} `json:"object,omitempty"`
+	// Networks - the networks the node or host is or was in, to decide who may see the event
+	Networks []string `json:"-"`
+}
+
+// WatchResponse - the changes seen since the requested resource version,
+// the next watch should pass ResourceVersion to pick up where this one left off
+type WatchResponse struct {
+	ResourceVersion int64        `json:"resourceVersion"`
+	Events          []WatchEvent `json:"events"`
+}
@@ -0,0 +1,31 @@
+package models
+
+// WireGuardConfigFile - a wg-quick config file, such as wg0.conf
+type WireGuardConfigFile struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+// WireGuardImportRequest - wg-quick config files whose devices should become static peers of a network
+type WireGuardImportRequest struct {
+	Configs []WireGuardConfigFile `json:"configs"`
+	// DryRun - only report what would be created and the conflicts
+	DryRun bool `json:"dryrun"`
+}
+
+// WireGuardImportConflict - a device or range that couldn't be imported as is
+type WireGuardImportConflict struct {
+	File      string `json:"file"`
+	Peer      string `json:"peer,omitempty"`
+	PublicKey string `json:"publickey,omitempty"`
+	// Range - set when only this allowed ip was skipped and the device was still imported
+	Range  string `json:"range,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// WireGuardImportResult - the static peers created from imported wg-quick configs
+type WireGuardImportResult struct {
+	DryRun    bool                      `json:"dryrun"`
+	Created   []StaticPeer              `json:"created"`
+	Conflicts []WireGuardImportConflict `json:"conflicts"`
+}
@@ -0,0 +1,51 @@
+package models
+
+// zone policy actions
+const (
+	ZoneAllow = "allow"
+	ZoneDeny  = "deny"
+)
+
+// ZonePresetStandard - prod, staging and user-devices zones, keeping prod and staging apart and letting user devices reach prod on 443
+const ZonePresetStandard = "standard"
+
+// Zone - a named segment of a network, the nodes having any of its tags
+type Zone struct {
+	Name string `json:"name" bson:"name" yaml:"name" validate:"required,max=32"`
+	// Tags - nodes having any of these tags are in the zone
+	Tags []string `json:"tags" bson:"tags" yaml:"tags" validate:"required,min=1,dive,required"`
+}
+
+// ZonePolicy - whether the nodes of one zone may reach the nodes of another; node ACLs have neither direction nor ports,
+// so a policy applies both ways and an allow with ports allows every port
+type ZonePolicy struct {
+	From   string   `json:"from" bson:"from" yaml:"from" validate:"required"`
+	To     string   `json:"to" bson:"to" yaml:"to" validate:"required"`
+	Action string   `json:"action" bson:"action" yaml:"action" validate:"required,oneof=allow deny"`
+	Ports  []string `json:"ports,omitempty" bson:"ports,omitempty" yaml:"ports,omitempty"`
+}
+
+// NetworkZones - the zones of a network and the policies between them, compiled into the ACLs of their nodes;
+// nodes of the same zone may reach each other and nodes of no zone keep their ACLs
+type NetworkZones struct {
+	Zones    []Zone       `json:"zones" bson:"zones" yaml:"zones" validate:"required,min=1,dive"`
+	Policies []ZonePolicy `json:"policies" bson:"policies" yaml:"policies" validate:"dive"`
+	// Default - whether nodes of zones without a policy between them may reach each other, allow unless set to deny
+	Default string `json:"default,omitempty" bson:"default,omitempty" yaml:"default,omitempty" validate:"omitempty,oneof=allow deny"`
+}
+
+// ZoneCompileResult - the node ACLs compiled from a network's zones
+type ZoneCompileResult struct {
+	Network string        `json:"network"`
+	DryRun  bool          `json:"dry_run"`
+	Zones   *NetworkZones `json:"zones,omitempty"`
+	// Members - the ids of the nodes of each zone
+	Members map[string][]string `json:"members"`
+	// Allowed - the pairs of nodes in zones that may reach each other
+	Allowed int `json:"allowed"`
+	// Denied - the pairs of nodes in zones that may not reach each other
+	Denied int `json:"denied"`
+	// Changed - the pairs whose ACL was, or on a dry run would be, changed
+	Changed int      `json:"changed"`
+	Notes   []string `json:"notes"`
+}
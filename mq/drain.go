@@ -0,0 +1,33 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// pendingPublishes - number of publishes currently being built or sent to the broker
+var pendingPublishes atomic.Int64
+
+// trackPublish - counts a publish as pending until the returned function is called
+func trackPublish() func() {
+	pendingPublishes.Add(1)
+	return func() {
+		pendingPublishes.Add(-1)
+	}
+}
+
+// Drain - waits for pending publishes to reach the broker, giving up when ctx expires
+func Drain(ctx context.Context) error {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for pendingPublishes.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d publishes still pending: %w", pendingPublishes.Load(), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
@@ -6,10 +6,13 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
 
+	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
 )
 
 const already_exists = "ALREADY_EXISTS"
@@ -135,6 +138,39 @@ func DeleteEmqxUser(username string) error {
 	return nil
 }
 
+// emqxTLSListeners - the EMQX listeners serving the certificate of the server's cert dir
+var emqxTLSListeners = []string{"ssl:default", "wss:default"}
+
+// ReloadBrokerCertificates - makes the broker load a renewed certificate, EMQX reads it from disk
+// when its TLS listeners restart; mosquitto is served through the proxy, which reloads certificates itself
+func ReloadBrokerCertificates() error {
+	if servercfg.GetBrokerType() != servercfg.EmqxBrokerType {
+		return nil
+	}
+	token, err := getEmqxAuthToken()
+	if err != nil {
+		return err
+	}
+	for _, listener := range emqxTLSListeners {
+		req, err := http.NewRequest(http.MethodPost, servercfg.GetEmqxRestEndpoint()+"/api/v5/listeners/"+url.PathEscape(listener)+"/restart", nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Add("authorization", "Bearer "+token)
+		resp, err := (&http.Client{}).Do(req)
+		if err != nil {
+			return err
+		}
+		msg, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		// a listener that isn't configured has nothing to reload
+		if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+			return fmt.Errorf("error restarting EMQX listener %s: %v", listener, string(msg))
+		}
+	}
+	return nil
+}
+
 // CreateEmqxDefaultAuthenticator - creates a default authenticator based on password and using EMQX's built in database as storage
 func CreateEmqxDefaultAuthenticator() error {
 	token, err := getEmqxAuthToken()
@@ -343,7 +379,59 @@ func AppendNodeUpdateACL(hostID, nodeNetwork, nodeID, serverName string) error {
 	if err != nil {
 		return err
 	}
-	aclObject.Rules = append(aclObject.Rules, []aclRule{
+	aclObject.Rules = append(aclObject.Rules, nodeACLRules(nodeNetwork, nodeID, serverName)...)
+	return putUserACL(hostID, aclObject, token)
+}
+
+// MigrateNodeACLs - adds the node topics added since a host's nodes were created to its ACL,
+// so existing nodes can publish on them too
+func MigrateNodeACLs(serverName string) error {
+	hosts, err := logic.GetAllHosts()
+	if err != nil {
+		return err
+	}
+	nodeAclMux.Lock()
+	defer nodeAclMux.Unlock()
+	token, err := getEmqxAuthToken()
+	if err != nil {
+		return err
+	}
+	for _, host := range hosts {
+		aclObject, err := GetUserACL(host.ID.String())
+		if err != nil {
+			slog.Error("failed to fetch ACL of host", "host", host.ID, "error", err)
+			continue
+		}
+		existing := make(map[string]struct{}, len(aclObject.Rules))
+		for _, rule := range aclObject.Rules {
+			existing[rule.Topic] = struct{}{}
+		}
+		added := false
+		for _, nodeID := range host.Nodes {
+			node, err := logic.GetNodeByID(nodeID)
+			if err != nil {
+				continue
+			}
+			for _, rule := range nodeACLRules(node.Network, nodeID, serverName) {
+				if _, ok := existing[rule.Topic]; !ok {
+					aclObject.Rules = append(aclObject.Rules, rule)
+					added = true
+				}
+			}
+		}
+		if !added {
+			continue
+		}
+		if err := putUserACL(host.ID.String(), aclObject, token); err != nil {
+			slog.Error("failed to migrate ACL of host", "host", host.ID, "error", err)
+		}
+	}
+	return nil
+}
+
+// nodeACLRules - the topics a host may use for one of its nodes
+func nodeACLRules(nodeNetwork, nodeID, serverName string) []aclRule {
+	return []aclRule{
 		{
 			Topic:      fmt.Sprintf("node/update/%s/%s", nodeNetwork, nodeID),
 			Permission: "allow",
@@ -369,12 +457,25 @@ func AppendNodeUpdateACL(hostID, nodeNetwork, nodeID, serverName string) error {
 			Permission: "allow",
 			Action:     "all",
 		},
-	}...)
+		{
+			Topic:      fmt.Sprintf("flows/%s/%s", serverName, nodeID),
+			Permission: "allow",
+			Action:     "all",
+		},
+		{
+			Topic:      fmt.Sprintf("probes/%s/%s", serverName, nodeID),
+			Permission: "allow",
+			Action:     "all",
+		},
+	}
+}
+
+func putUserACL(username string, aclObject *aclObject, token string) error {
 	payload, err := json.Marshal(aclObject)
 	if err != nil {
 		return err
 	}
-	req, err := http.NewRequest(http.MethodPut, servercfg.GetEmqxRestEndpoint()+"/api/v5/authorization/sources/built_in_database/username/"+hostID, bytes.NewReader(payload))
+	req, err := http.NewRequest(http.MethodPut, servercfg.GetEmqxRestEndpoint()+"/api/v5/authorization/sources/built_in_database/username/"+username, bytes.NewReader(payload))
 	if err != nil {
 		return err
 	}
@@ -390,7 +491,7 @@ func AppendNodeUpdateACL(hostID, nodeNetwork, nodeID, serverName string) error {
 		if err != nil {
 			return err
 		}
-		return fmt.Errorf("error adding ACL Rules for user %s Error: %v", hostID, string(msg))
+		return fmt.Errorf("error adding ACL Rules for user %s Error: %v", username, string(msg))
 	}
 	return nil
 }
@@ -1,6 +1,7 @@
 package mq
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"math"
@@ -35,6 +36,10 @@ func UpdateNode(client mqtt.Client, msg mqtt.Message) {
 		slog.Error("error getting node", "id", id, "error", err)
 		return
 	}
+	if logic.IsHostQuarantined(currentNode.HostID.String()) {
+		slog.Warn("ignoring node update of quarantined host", "id", id, "host", currentNode.HostID)
+		return
+	}
 	decrypted, decryptErr := decryptMsg(&currentNode, msg.Payload())
 	if decryptErr != nil {
 		slog.Error("failed to decrypt message for node", "id", id, "error", decryptErr)
@@ -58,7 +63,7 @@ func UpdateNode(client mqtt.Client, msg mqtt.Message) {
 		return
 	}
 	if ifaceDelta { // reduce number of unneeded updates, by only sending on iface changes
-		if err = PublishPeerUpdate(); err != nil {
+		if err = PublishNodePeerUpdate(&newNode); err != nil {
 			slog.Warn("error updating peers when node informed the server of an interface change", "nodeid", currentNode.ID, "error", err)
 		}
 	}
@@ -78,6 +83,10 @@ func UpdateHost(client mqtt.Client, msg mqtt.Message) {
 		slog.Error("error getting host", "id", id, "error", err)
 		return
 	}
+	if logic.IsHostQuarantined(id) {
+		slog.Warn("ignoring host update of quarantined host", "id", id)
+		return
+	}
 	decrypted, decryptErr := decryptMsgWithHost(currentHost, msg.Payload())
 	if decryptErr != nil {
 		slog.Error("failed to decrypt message for host", "id", id, "error", decryptErr)
@@ -165,7 +174,10 @@ func UpdateHost(client mqtt.Client, msg mqtt.Message) {
 			slog.Error("failed to delete host", "id", currentHost.ID, "error", err)
 			return
 		}
-		sendPeerUpdate = true
+		// the networks of a deleted host went with its nodes, so every host is updated
+		if err := PublishPeerUpdate(); err != nil {
+			slog.Error("failed to publish peer update", "error", err)
+		}
 	case models.RegisterWithTurn:
 		if servercfg.IsUsingTurn() {
 			err = logic.RegisterHostWithTurn(hostUpdate.Host.ID.String(), hostUpdate.Host.HostPass)
@@ -178,64 +190,95 @@ func UpdateHost(client mqtt.Client, msg mqtt.Message) {
 	}
 
 	if sendPeerUpdate {
-		err := PublishPeerUpdate()
+		err := PublishHostPeerUpdate(currentHost)
 		if err != nil {
 			slog.Error("failed to publish peer update", "error", err)
 		}
 	}
 	// if servercfg.Is_EE && ifaceDelta {
 	// 	if err = logic.EnterpriseResetAllPeersFailovers(currentHost.ID.String(), currentHost.Network); err != nil {
-	// 		logger.Log(1, "failed to reset failover list during node update", currentHost.ID.String(), currentHost.Network)
+	// 		slog.ErrorCtx(messageContext(msg), "failed to reset failover list during node update", "host_id", currentHost.ID.String(), "network", currentHost.Network)
 	// 	}
 	// }
 }
 
 // UpdateMetrics  message Handler -- handles updates from client nodes for metrics
 func UpdateMetrics(client mqtt.Client, msg mqtt.Message) {
+	ctx := messageContext(msg)
 	if servercfg.Is_EE {
 		id, err := getID(msg.Topic())
 		if err != nil {
-			slog.Error("error getting ID sent on ", "topic", msg.Topic(), "error", err)
+			slog.ErrorCtx(ctx, "error getting ID sent on ", "topic", msg.Topic(), "error", err)
 			return
 		}
 		currentNode, err := logic.GetNodeByID(id)
 		if err != nil {
-			slog.Error("error getting node", "id", id, "error", err)
+			slog.ErrorCtx(ctx, "error getting node", "id", id, "error", err)
 			return
 		}
 		decrypted, decryptErr := decryptMsg(&currentNode, msg.Payload())
 		if decryptErr != nil {
-			slog.Error("failed to decrypt message for node", "id", id, "error", decryptErr)
+			slog.ErrorCtx(ctx, "failed to decrypt message for node", "id", id, "error", decryptErr)
 			return
 		}
 
 		var newMetrics models.Metrics
 		if err := json.Unmarshal(decrypted, &newMetrics); err != nil {
-			slog.Error("error unmarshaling payload", "error", err)
+			slog.ErrorCtx(ctx, "error unmarshaling payload", "error", err)
 			return
 		}
 
-		shouldUpdate := updateNodeMetrics(&currentNode, &newMetrics)
+		oldMetrics, err := logic.GetMetricsCtx(ctx, id)
+		if err != nil {
+			slog.WarnCtx(ctx, "failed to fetch previous metrics for traffic accounting", "id", id, "error", err)
+		}
+		// usage is counted from the counters as reported, before they're accumulated into the totals
+		if err = logic.RecordTrafficUsage(&currentNode, &newMetrics); err != nil {
+			slog.ErrorCtx(ctx, "failed to record traffic usage", "id", id, "error", err)
+		}
+		newMetrics.Reported = time.Now().UTC()
+		shouldUpdate := updateNodeMetrics(ctx, &currentNode, &newMetrics)
+		if err = logic.RecordExtClientSessions(&currentNode, oldMetrics, &newMetrics); err != nil {
+			slog.ErrorCtx(ctx, "failed to record ext client sessions", "id", id, "error", err)
+		}
 
-		if err = logic.UpdateMetrics(id, &newMetrics); err != nil {
-			slog.Error("failed to update node metrics", "id", id, "error", err)
+		if err = logic.UpdateMetricsCtx(ctx, id, &newMetrics); err != nil {
+			slog.ErrorCtx(ctx, "failed to update node metrics", "id", id, "error", err)
 			return
 		}
+		logic.CheckRevokedKeyReuse(&currentNode, &newMetrics)
+		if err = logic.RecordEndpointObservations(&currentNode, &newMetrics); err != nil {
+			slog.ErrorCtx(ctx, "failed to record endpoint observations", "id", id, "error", err)
+		}
 		if servercfg.IsMetricsExporter() {
 			if err := pushMetricsToExporter(newMetrics); err != nil {
-				slog.Error("failed to push node metrics to exporter", "id", currentNode.ID, "error", err)
+				slog.ErrorCtx(ctx, "failed to push node metrics to exporter", "id", currentNode.ID, "error", err)
+			}
+		}
+
+		if logic.CheckSourcePolicies(&currentNode, &newMetrics) {
+			slog.InfoCtx(ctx, "updating peers after blocking ext clients for their source", "id", currentNode.ID, "network", currentNode.Network)
+			if err = PublishNetworkPeerUpdate(currentNode.Network); err != nil {
+				slog.WarnCtx(ctx, "failed to publish update after blocking ext clients", "network", currentNode.Network, "error", err)
+			}
+		}
+
+		if logic.ExtClientsRoamed(&currentNode, oldMetrics, &newMetrics) {
+			slog.InfoCtx(ctx, "updating peers after ext clients moved between gateways", "id", currentNode.ID, "network", currentNode.Network)
+			if err = PublishNetworkPeerUpdate(currentNode.Network); err != nil {
+				slog.WarnCtx(ctx, "failed to publish update after ext clients moved between gateways", "network", currentNode.Network, "error", err)
 			}
 		}
 
 		if newMetrics.Connectivity != nil {
 			err := logic.EnterpriseFailoverFunc(&currentNode)
 			if err != nil {
-				slog.Error("failed to failover for node", "id", currentNode.ID, "network", currentNode.Network, "error", err)
+				slog.ErrorCtx(ctx, "failed to failover for node", "id", currentNode.ID, "network", currentNode.Network, "error", err)
 			}
 		}
 
 		if shouldUpdate {
-			slog.Info("updating peers after node detected connectivity issues", "id", currentNode.ID, "network", currentNode.Network)
+			slog.InfoCtx(ctx, "updating peers after node detected connectivity issues", "id", currentNode.ID, "network", currentNode.Network)
 			host, err := logic.GetHost(currentNode.HostID.String())
 			if err == nil {
 				nodes, err := logic.GetAllNodes()
@@ -243,11 +286,75 @@ func UpdateMetrics(client mqtt.Client, msg mqtt.Message) {
 					return
 				}
 				if err = PublishSingleHostPeerUpdate(host, nodes, nil, nil); err != nil {
-					slog.Warn("failed to publish update after failover peer change for node", "id", currentNode.ID, "network", currentNode.Network, "error", err)
+					slog.WarnCtx(ctx, "failed to publish update after failover peer change for node", "id", currentNode.ID, "network", currentNode.Network, "error", err)
 				}
 			}
 		}
-		slog.Debug("updated node metrics", "id", id)
+		slog.DebugCtx(ctx, "updated node metrics", "id", id)
+	}
+}
+
+// UpdateFlows  message handler -- handles recent flow reports from gateway nodes
+func UpdateFlows(client mqtt.Client, msg mqtt.Message) {
+	id, err := getID(msg.Topic())
+	if err != nil {
+		slog.Error("error getting node.ID sent on ", "topic", msg.Topic(), "error", err)
+		return
+	}
+	currentNode, err := logic.GetNodeByID(id)
+	if err != nil {
+		slog.Error("error getting node", "id", id, "error", err)
+		return
+	}
+	decrypted, decryptErr := decryptMsg(&currentNode, msg.Payload())
+	if decryptErr != nil {
+		slog.Error("failed to decrypt message for node", "id", id, "error", decryptErr)
+		return
+	}
+	var report models.FlowReport
+	if err := json.Unmarshal(decrypted, &report); err != nil {
+		slog.Error("error unmarshaling payload", "error", err)
+		return
+	}
+	if err := logic.StoreFlows(&currentNode, report.Flows); err != nil {
+		slog.Error("failed to store gateway flows", "id", id, "error", err)
+		return
+	}
+	slog.Debug("stored gateway flows", "id", id, "count", len(report.Flows))
+}
+
+// UpdateProbes  message handler -- stores the results of the probes a node ran
+func UpdateProbes(client mqtt.Client, msg mqtt.Message) {
+	id, err := getID(msg.Topic())
+	if err != nil {
+		slog.Error("error getting node.ID sent on ", "topic", msg.Topic(), "error", err)
+		return
+	}
+	currentNode, err := logic.GetNodeByID(id)
+	if err != nil {
+		slog.Error("error getting node", "id", id, "error", err)
+		return
+	}
+	decrypted, decryptErr := decryptMsg(&currentNode, msg.Payload())
+	if decryptErr != nil {
+		slog.Error("failed to decrypt message for node", "id", id, "error", decryptErr)
+		return
+	}
+	var report models.ProbeReport
+	if err := json.Unmarshal(decrypted, &report); err != nil {
+		slog.Error("error unmarshaling payload", "error", err)
+		return
+	}
+	logic.StoreProbeResults(&currentNode, report.Results)
+	slog.Debug("stored probe results", "id", id, "count", len(report.Results))
+	if len(report.EgressHealth) == 0 {
+		return
+	}
+	// a range that turned unhealthy or recovered moves to or from another gateway of its network
+	for _, network := range logic.StoreEgressHealthResults(&currentNode, report.EgressHealth) {
+		if err := PublishNetworkPeerUpdate(network); err != nil {
+			slog.Error("failed to publish peer update after egress health change", "network", network, "error", err)
+		}
 	}
 }
 
@@ -272,7 +379,7 @@ func ClientPeerUpdate(client mqtt.Client, msg mqtt.Message) {
 	case ncutils.ACK:
 		// do we still need this
 	case ncutils.DONE:
-		if err = PublishPeerUpdate(); err != nil {
+		if err = PublishNodePeerUpdate(&currentNode); err != nil {
 			slog.Error("error publishing peer update for node", "id", currentNode.ID, "error", err)
 			return
 		}
@@ -281,13 +388,13 @@ func ClientPeerUpdate(client mqtt.Client, msg mqtt.Message) {
 	slog.Info("sent peer updates after signal received from", "id", id)
 }
 
-func updateNodeMetrics(currentNode *models.Node, newMetrics *models.Metrics) bool {
+func updateNodeMetrics(ctx context.Context, currentNode *models.Node, newMetrics *models.Metrics) bool {
 	if newMetrics.FailoverPeers == nil {
 		newMetrics.FailoverPeers = make(map[string]string)
 	}
-	oldMetrics, err := logic.GetMetrics(currentNode.ID.String())
+	oldMetrics, err := logic.GetMetricsCtx(ctx, currentNode.ID.String())
 	if err != nil {
-		slog.Error("error finding old metrics for node", "id", currentNode.ID, "error", err)
+		slog.ErrorCtx(ctx, "error finding old metrics for node", "id", currentNode.ID, "error", err)
 		return false
 	}
 	if oldMetrics.FailoverPeers == nil {
@@ -296,7 +403,7 @@ func updateNodeMetrics(currentNode *models.Node, newMetrics *models.Metrics) boo
 
 	var attachedClients []models.ExtClient
 	if currentNode.IsIngressGateway {
-		clients, err := logic.GetExtClientsByID(currentNode.ID.String(), currentNode.Network)
+		clients, err := logic.GetGatewayExtClients(currentNode.ID.String(), currentNode.Network)
 		if err == nil {
 			attachedClients = clients
 		}
@@ -361,6 +468,10 @@ func updateNodeMetrics(currentNode *models.Node, newMetrics *models.Metrics) boo
 			len(node.FailoverNode) > 0 &&
 			!node.Failover {
 			newMetrics.FailoverPeers[node.ID.String()] = node.FailoverNode.String()
+			if oldMetrics.FailoverPeers[node.ID.String()] == "" {
+				logic.RecordNetworkEvent(node.Network, models.NetworkEventFailover, currentNode,
+					fmt.Sprintf("lost direct connection to node %s, now relayed by failover %s", node.ID.String(), node.FailoverNode.String()))
+			}
 		}
 	}
 	shouldUpdate := len(oldMetrics.FailoverPeers) == 0 && len(newMetrics.FailoverPeers) > 0
@@ -383,7 +494,7 @@ func updateNodeMetrics(currentNode *models.Node, newMetrics *models.Metrics) boo
 func handleNewNodeDNS(host *models.Host, node *models.Node) error {
 	dns := models.DNSUpdate{
 		Action: models.DNSInsert,
-		Name:   host.Name + "." + node.Network,
+		Name:   logic.NodeName(node, host) + "." + node.Network,
 	}
 	if node.Address.IP != nil {
 		dns.Address = node.Address.IP.String()
@@ -427,6 +538,22 @@ func handleHostCheckin(h, currentHost *models.Host) bool {
 		}
 	}
 
+	if h.Resources != nil {
+		if err := logic.RecordHostResources(currentHost.ID.String(), h.Resources); err != nil {
+			slog.Warn("failed to record host resources on checkin", "host", currentHost.Name, "hostid", currentHost.ID, "error", err)
+		}
+	}
+	if h.ConfigHash != "" {
+		correct, err := logic.CheckHostDrift(currentHost, h.ConfigHash)
+		if err != nil {
+			slog.Warn("failed to check host config drift on checkin", "host", currentHost.Name, "hostid", currentHost.ID, "error", err)
+		} else if correct {
+			if err := HostUpdate(&models.HostUpdate{Action: models.RequestPull, Host: *currentHost}); err != nil {
+				slog.Warn("failed to request pull to correct host config drift", "host", currentHost.Name, "hostid", currentHost.ID, "error", err)
+			}
+		}
+	}
+
 	for i := range h.Interfaces {
 		h.Interfaces[i].AddressString = h.Interfaces[i].Address.String()
 	}
@@ -439,16 +566,21 @@ func handleHostCheckin(h, currentHost *models.Host) bool {
 			return false
 		}
 	}
-	ifaceDelta := len(h.Interfaces) != len(currentHost.Interfaces) ||
+	// new addresses, interfaces losing carrier or IPv6 coming up change the endpoints peers are given
+	ifaceDelta := logic.IfacesChanged(h.Interfaces, currentHost.Interfaces) ||
 		!h.EndpointIP.Equal(currentHost.EndpointIP) ||
 		(len(h.NatType) > 0 && h.NatType != currentHost.NatType) ||
 		h.DefaultInterface != currentHost.DefaultInterface ||
+		(h.Endpoints != nil && logic.ValidateHostEndpoints(h.Endpoints) == nil && logic.HostEndpointsChanged(h.Endpoints, currentHost.Endpoints)) ||
 		(h.ListenPort != 0 && h.ListenPort != currentHost.ListenPort) || (h.WgPublicListenPort != 0 && h.WgPublicListenPort != currentHost.WgPublicListenPort)
 	if ifaceDelta { // only save if something changes
 		currentHost.EndpointIP = h.EndpointIP
 		currentHost.Interfaces = h.Interfaces
 		currentHost.DefaultInterface = h.DefaultInterface
 		currentHost.NatType = h.NatType
+		if h.Endpoints != nil && logic.ValidateHostEndpoints(h.Endpoints) == nil {
+			currentHost.Endpoints = h.Endpoints
+		}
 		if h.ListenPort != 0 {
 			currentHost.ListenPort = h.ListenPort
 		}
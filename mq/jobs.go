@@ -0,0 +1,78 @@
+package mq
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+// RegisterJobs - adds the background jobs that publish to hosts
+func RegisterJobs() {
+	open := map[string]bool{}
+	logic.RegisterJob("maintenance_windows", maintenanceInterval, func(ctx context.Context) error {
+		return applyMaintenanceWindows(open)
+	})
+	logic.RegisterJob("expired_nodes", time.Hour, func(ctx context.Context) error {
+		deleted, err := logic.DeleteExpiredNodes()
+		publishDeletedNodes(deleted)
+		return err
+	})
+	logic.RegisterJob("ephemeral_nodes", logic.EphemeralCheckInterval, func(ctx context.Context) error {
+		deleted, err := logic.DeleteEphemeralNodes()
+		publishDeletedNodes(deleted)
+		return err
+	})
+	logic.RegisterJob("rollouts", time.Minute, func(ctx context.Context) error {
+		finished, err := logic.EvaluateRollouts()
+		for i := range finished {
+			PublishRollout(&finished[i])
+		}
+		return err
+	})
+}
+
+// publishDeletedNodes - tells the hosts of deleted nodes and their peers
+func publishDeletedNodes(nodes []models.Node) {
+	for i := range nodes {
+		if err := NodeUpdate(&nodes[i]); err != nil {
+			slog.Error("failed to send peer update for deleted node", "nodeid", nodes[i].ID, "error", err)
+		}
+	}
+}
+
+// PublishRollout - sends the change of a rollout, or that it was taken back, to the hosts it concerns: the peers of
+// a policy rollout are sent an update, the canary hosts of a join config rollout are asked to pull, all the hosts
+// of the network once it's promoted
+func PublishRollout(rollout *models.Rollout) {
+	if rollout.Kind == models.RolloutPolicy {
+		if err := PublishNetworkPeerUpdate(rollout.Network); err != nil {
+			slog.Error("failed to publish peer update for rollout", "network", rollout.Network, "rollout", rollout.ID, "error", err)
+		}
+		return
+	}
+	hostIDs := rollout.CanaryHosts
+	if rollout.Status == models.RolloutPromoted {
+		hostIDs = []string{}
+		nodes, err := logic.GetNetworkNodes(rollout.Network)
+		if err != nil {
+			return
+		}
+		for i := range nodes {
+			if !logic.StringSliceContains(hostIDs, nodes[i].HostID.String()) {
+				hostIDs = append(hostIDs, nodes[i].HostID.String())
+			}
+		}
+	}
+	for _, hostID := range hostIDs {
+		host, err := logic.GetHost(hostID)
+		if err != nil {
+			continue
+		}
+		if err := HostUpdate(&models.HostUpdate{Action: models.RequestPull, Host: *host}); err != nil {
+			slog.Warn("failed to request pull for rollout", "host", hostID, "rollout", rollout.ID, "error", err)
+		}
+	}
+}
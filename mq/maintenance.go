@@ -0,0 +1,88 @@
+package mq
+
+import (
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+// maintenanceInterval - how often pending changes are checked against the maintenance windows
+const maintenanceInterval = time.Minute
+
+// applyMaintenanceWindows - applies the changes held for a maintenance window once their host is in one,
+// and tells the hosts of a network when its window opens or closes, since they only auto-update in one;
+// open tracks the networks whose window is open between runs
+func applyMaintenanceWindows(open map[string]bool) error {
+	now := time.Now().UTC()
+	publishMaintenanceTransitions(open, now)
+	changes, err := logic.GetPendingChanges()
+	if err != nil {
+		return err
+	}
+	for i := range changes {
+		change := &changes[i]
+		host, err := logic.GetHost(change.HostID)
+		if err != nil {
+			if database.IsEmptyRecord(err) {
+				logic.DeletePendingChange(change.ID)
+			}
+			continue
+		}
+		if !logic.HostInMaintenanceWindow(host, now) {
+			continue
+		}
+		if err = ApplyPendingChange(change, host); err != nil {
+			slog.Error("failed to apply pending change", "host", host.ID, "action", change.Action, "error", err)
+			continue
+		}
+		slog.Info("applied pending change in maintenance window", "host", host.ID, "action", change.Action, "user", change.User)
+		if err = logic.DeletePendingChange(change.ID); err != nil {
+			slog.Error("failed to delete pending change", "id", change.ID, "error", err)
+		}
+	}
+	return nil
+}
+
+// ApplyPendingChange - sends a disruptive change to its host
+func ApplyPendingChange(change *models.PendingChange, host *models.Host) error {
+	switch change.Action {
+	case models.UpdateHost:
+		if change.Tuning != nil {
+			if err := logic.TuneHost(host, change.Tuning); err != nil {
+				return err
+			}
+		}
+		if err := HostUpdate(&models.HostUpdate{Action: models.UpdateHost, Host: *host}); err != nil {
+			return err
+		}
+		// peers need the new listen port, the host its keepalives
+		return PublishHostPeerUpdate(host)
+	default:
+		return HostUpdate(&models.HostUpdate{Action: change.Action, Host: *host})
+	}
+}
+
+// publishMaintenanceTransitions - sends a peer update to the hosts of the networks whose window opened or closed
+func publishMaintenanceTransitions(open map[string]bool, now time.Time) {
+	networks, err := logic.GetNetworks()
+	if err != nil {
+		return
+	}
+	for _, network := range networks {
+		status, err := logic.GetMaintenanceStatus(network.NetID, now)
+		if err != nil {
+			continue
+		}
+		wasOpen, known := open[network.NetID]
+		open[network.NetID] = status.Open
+		if !known || wasOpen == status.Open {
+			continue
+		}
+		if err = PublishNetworkPeerUpdate(network.NetID); err != nil {
+			slog.Warn("failed to publish maintenance window change", "network", network.NetID, "error", err)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package mq
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -10,6 +11,7 @@ import (
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
 )
 
 // KEEPALIVE_TIMEOUT - time in seconds for timeout
@@ -22,6 +24,9 @@ const MQ_TIMEOUT = 30
 
 var peer_force_send = 0
 
+// lastKeyExpiryCheck - when sendPeers last looked for ext client keys that ran out
+var lastKeyExpiryCheck time.Time
+
 var mqclient mqtt.Client
 
 func setMqOptions(user, password string, opts *mqtt.ClientOptions) {
@@ -42,9 +47,10 @@ func setMqOptions(user, password string, opts *mqtt.ClientOptions) {
 func SetupMQTT() {
 	if servercfg.GetBrokerType() == servercfg.EmqxBrokerType {
 		time.Sleep(10 * time.Second) // wait for the REST endpoint to be ready
+		logic.DeleteHostBrokerCredentials = DeleteEmqxUser
 		// setup authenticator and create admin user
 		if err := CreateEmqxDefaultAuthenticator(); err != nil {
-			logger.Log(0, err.Error())
+			slog.Error(err.Error())
 		}
 		DeleteEmqxUser(servercfg.GetMqUserName())
 		if err := CreateEmqxUser(servercfg.GetMqUserName(), servercfg.GetMqPassword(), true); err != nil {
@@ -52,32 +58,49 @@ func SetupMQTT() {
 		}
 		// create an ACL authorization source for the built in EMQX MNESIA database
 		if err := CreateEmqxDefaultAuthorizer(); err != nil {
-			logger.Log(0, err.Error())
+			slog.Error(err.Error())
 		}
 		// create a default deny ACL to all topics for all users
 		if err := CreateDefaultDenyRule(); err != nil {
 			log.Fatal(err)
 		}
+		if err := MigrateNodeACLs(servercfg.GetServer()); err != nil {
+			slog.Error("failed to migrate host ACLs", "error", err)
+		}
+	}
+	if group := servercfg.GetMQSharedGroup(); group != "" {
+		slog.Info("consuming host messages through shared subscription group", "group", group)
+	}
+	if partition, partitions := servercfg.GetMQPartition(); partitions > 1 {
+		slog.Info(fmt.Sprintf("handling host messages of partition %d of %d", partition, partitions))
 	}
 	opts := mqtt.NewClientOptions()
 	setMqOptions(servercfg.GetMqUserName(), servercfg.GetMqPassword(), opts)
 	opts.SetOnConnectHandler(func(client mqtt.Client) {
 		serverName := servercfg.GetServer()
-		if token := client.Subscribe(fmt.Sprintf("update/%s/#", serverName), 0, mqtt.MessageHandler(UpdateNode)); token.WaitTimeout(MQ_TIMEOUT*time.Second) && token.Error() != nil {
+		if token := client.Subscribe(sharedTopic(fmt.Sprintf("update/%s/#", serverName)), 0, partitionHandler(traceHandler("UpdateNode", UpdateNode))); token.WaitTimeout(MQ_TIMEOUT*time.Second) && token.Error() != nil {
+			client.Disconnect(240)
+			slog.Error("node update subscription failed")
+		}
+		if token := client.Subscribe(sharedTopic(fmt.Sprintf("host/serverupdate/%s/#", serverName)), 0, partitionHandler(traceHandler("UpdateHost", UpdateHost))); token.WaitTimeout(MQ_TIMEOUT*time.Second) && token.Error() != nil {
+			client.Disconnect(240)
+			slog.Error("host update subscription failed")
+		}
+		if token := client.Subscribe(sharedTopic(fmt.Sprintf("signal/%s/#", serverName)), 0, partitionHandler(traceHandler("ClientPeerUpdate", ClientPeerUpdate))); token.WaitTimeout(MQ_TIMEOUT*time.Second) && token.Error() != nil {
 			client.Disconnect(240)
-			logger.Log(0, "node update subscription failed")
+			slog.Error("node client subscription failed")
 		}
-		if token := client.Subscribe(fmt.Sprintf("host/serverupdate/%s/#", serverName), 0, mqtt.MessageHandler(UpdateHost)); token.WaitTimeout(MQ_TIMEOUT*time.Second) && token.Error() != nil {
+		if token := client.Subscribe(sharedTopic(fmt.Sprintf("metrics/%s/#", serverName)), 0, partitionHandler(traceHandler("UpdateMetrics", UpdateMetrics))); token.WaitTimeout(MQ_TIMEOUT*time.Second) && token.Error() != nil {
 			client.Disconnect(240)
-			logger.Log(0, "host update subscription failed")
+			slog.Error("node metrics subscription failed")
 		}
-		if token := client.Subscribe(fmt.Sprintf("signal/%s/#", serverName), 0, mqtt.MessageHandler(ClientPeerUpdate)); token.WaitTimeout(MQ_TIMEOUT*time.Second) && token.Error() != nil {
+		if token := client.Subscribe(sharedTopic(fmt.Sprintf("flows/%s/#", serverName)), 0, partitionHandler(traceHandler("UpdateFlows", UpdateFlows))); token.WaitTimeout(MQ_TIMEOUT*time.Second) && token.Error() != nil {
 			client.Disconnect(240)
-			logger.Log(0, "node client subscription failed")
+			slog.Error("gateway flows subscription failed")
 		}
-		if token := client.Subscribe(fmt.Sprintf("metrics/%s/#", serverName), 0, mqtt.MessageHandler(UpdateMetrics)); token.WaitTimeout(MQ_TIMEOUT*time.Second) && token.Error() != nil {
+		if token := client.Subscribe(sharedTopic(fmt.Sprintf("probes/%s/#", serverName)), 0, partitionHandler(traceHandler("UpdateProbes", UpdateProbes))); token.WaitTimeout(MQ_TIMEOUT*time.Second) && token.Error() != nil {
 			client.Disconnect(240)
-			logger.Log(0, "node metrics subscription failed")
+			slog.Error("probe results subscription failed")
 		}
 
 		opts.SetOrderMatters(false)
@@ -87,7 +110,7 @@ func SetupMQTT() {
 	tperiod := time.Now().Add(10 * time.Second)
 	for {
 		if token := mqclient.Connect(); !token.WaitTimeout(MQ_TIMEOUT*time.Second) || token.Error() != nil {
-			logger.Log(2, "unable to connect to broker, retrying ...")
+			slog.Warn("unable to connect to broker, retrying ...")
 			if time.Now().After(tperiod) {
 				if token.Error() == nil {
 					logger.FatalLog("could not connect to broker, token timeout, exiting ...")
@@ -105,8 +128,10 @@ func SetupMQTT() {
 // Keepalive -- periodically pings all nodes to let them know server is still alive and doing well
 func Keepalive(ctx context.Context) {
 	for {
+		logic.WorkerHeartbeat("mq_keepalive", time.Second*KEEPALIVE_TIMEOUT)
 		select {
 		case <-ctx.Done():
+			logic.StopWorker("mq_keepalive")
 			return
 		case <-time.After(time.Second * KEEPALIVE_TIMEOUT):
 			sendPeers()
@@ -114,6 +139,29 @@ func Keepalive(ctx context.Context) {
 	}
 }
 
+// CheckBrokerConnection - checks the server's broker credentials without starting the server,
+// with EMQX by logging in to its REST API since the server creates its own MQTT user there
+func CheckBrokerConnection() error {
+	if servercfg.GetBrokerType() == servercfg.EmqxBrokerType {
+		_, err := getEmqxAuthToken()
+		return err
+	}
+	opts := mqtt.NewClientOptions()
+	setMqOptions(servercfg.GetMqUserName(), servercfg.GetMqPassword(), opts)
+	opts.SetAutoReconnect(false)
+	opts.SetConnectRetry(false)
+	client := mqtt.NewClient(opts)
+	token := client.Connect()
+	if !token.WaitTimeout(MQ_TIMEOUT * time.Second) {
+		return errors.New("timed out connecting to broker")
+	}
+	if token.Error() != nil {
+		return token.Error()
+	}
+	client.Disconnect(MQ_DISCONNECT)
+	return nil
+}
+
 // IsConnected - function for determining if the mqclient is connected or not
 func IsConnected() bool {
 	return mqclient != nil && mqclient.IsConnected()
@@ -1,111 +1,177 @@
 package mq
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
 
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/servercfg"
+	"github.com/gravitl/netmaker/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/exp/slog"
 )
 
 // PublishPeerUpdate --- determines and publishes a peer update to all the hosts
 func PublishPeerUpdate() error {
+	defer trackPublish()()
 	if !servercfg.IsMessageQueueBackend() {
 		return nil
 	}
+	ctx, span := tracing.Start(context.Background(), "mq.PublishPeerUpdate")
+	defer span.End()
 
-	hosts, err := logic.GetAllHosts()
+	hosts, err := getAllHosts(ctx)
 	if err != nil {
-		logger.Log(1, "err getting all hosts", err.Error())
+		slog.Error("err getting all hosts", "error", err)
 		return err
 	}
-	allNodes, err := logic.GetAllNodes()
+	allNodes, err := getAllNodes(ctx)
 	if err != nil {
 		return err
 	}
 	for _, host := range hosts {
 		host := host
-		if err = PublishSingleHostPeerUpdate(&host, allNodes, nil, nil); err != nil {
-			logger.Log(1, "failed to publish peer update to host", host.ID.String(), ": ", err.Error())
+		if err = publishSingleHostPeerUpdate(ctx, &host, allNodes, nil, nil); err != nil {
+			slog.Error("failed to publish peer update to host", "host_id", host.ID.String(), "error", err)
 		}
 	}
 	return err
 }
 
-// PublishDeletedNodePeerUpdate --- determines and publishes a peer update
-// to all the hosts with a deleted node to account for
-func PublishDeletedNodePeerUpdate(delNode *models.Node) error {
+// PublishNodePeerUpdate --- determines and publishes a peer update to the hosts
+// whose peers change with the given nodes, rather than to every host
+func PublishNodePeerUpdate(nodes ...*models.Node) error {
+	defer trackPublish()()
 	if !servercfg.IsMessageQueueBackend() {
 		return nil
 	}
+	ctx, span := tracing.Start(context.Background(), "mq.PublishNodePeerUpdate")
+	defer span.End()
+	return publishPeerUpdateToHosts(ctx, logic.GetAffectedHosts(nodes...), nil, nil)
+}
 
-	hosts, err := logic.GetAllHosts()
-	if err != nil {
-		logger.Log(1, "err getting all hosts", err.Error())
-		return err
+// PublishNetworkPeerUpdate --- determines and publishes a peer update to the hosts of a network
+func PublishNetworkPeerUpdate(network string) error {
+	defer trackPublish()()
+	if !servercfg.IsMessageQueueBackend() {
+		return nil
 	}
-	allNodes, err := logic.GetAllNodes()
-	if err != nil {
-		return err
+	ctx, span := tracing.Start(context.Background(), "mq.PublishNetworkPeerUpdate", attribute.String("network", network))
+	defer span.End()
+	return publishPeerUpdateToHosts(ctx, logic.GetNetworkHosts(network), nil, nil)
+}
+
+// PublishHostPeerUpdate --- determines and publishes a peer update to the hosts
+// whose peers change with a host, the host itself and those sharing a network with it
+func PublishHostPeerUpdate(host *models.Host) error {
+	defer trackPublish()()
+	if !servercfg.IsMessageQueueBackend() {
+		return nil
 	}
-	for _, host := range hosts {
-		host := host
-		if err = PublishSingleHostPeerUpdate(&host, allNodes, delNode, nil); err != nil {
-			logger.Log(1, "failed to publish peer update to host", host.ID.String(), ": ", err.Error())
-		}
+	ctx, span := tracing.Start(context.Background(), "mq.PublishHostPeerUpdate", attribute.String("host.id", host.ID.String()))
+	defer span.End()
+	return publishPeerUpdateToHosts(ctx, logic.GetHostAffectedHosts(host), nil, nil)
+}
+
+// PublishDeletedNodePeerUpdate --- determines and publishes a peer update
+// to the hosts of a deleted node's network to account for it
+func PublishDeletedNodePeerUpdate(delNode *models.Node) error {
+	defer trackPublish()()
+	if !servercfg.IsMessageQueueBackend() {
+		return nil
 	}
-	return err
+	ctx, span := tracing.Start(context.Background(), "mq.PublishDeletedNodePeerUpdate")
+	defer span.End()
+	return publishPeerUpdateToHosts(ctx, logic.GetNetworkHosts(delNode.Network), delNode, nil)
 }
 
 // PublishDeletedClientPeerUpdate --- determines and publishes a peer update
-// to all the hosts with a deleted ext client to account for
+// to the hosts of a deleted ext client's network to account for it
 func PublishDeletedClientPeerUpdate(delClient *models.ExtClient) error {
+	defer trackPublish()()
 	if !servercfg.IsMessageQueueBackend() {
 		return nil
 	}
-
-	hosts, err := logic.GetAllHosts()
-	if err != nil {
-		logger.Log(1, "err getting all hosts", err.Error())
-		return err
-	}
-	nodes, err := logic.GetAllNodes()
-	if err != nil {
-		return err
-	}
-	for _, host := range hosts {
-		host := host
-		if err = PublishSingleHostPeerUpdate(&host, nodes, nil, []models.ExtClient{*delClient}); err != nil {
-			logger.Log(1, "failed to publish peer update to host", host.ID.String(), ": ", err.Error())
-		}
-	}
-	return err
+	ctx, span := tracing.Start(context.Background(), "mq.PublishDeletedClientPeerUpdate")
+	defer span.End()
+	return publishPeerUpdateToHosts(ctx, logic.GetNetworkHosts(delClient.Network), nil, []models.ExtClient{*delClient})
 }
 
 // PublishSingleHostPeerUpdate --- determines and publishes a peer update to one host
 func PublishSingleHostPeerUpdate(host *models.Host, allNodes []models.Node, deletedNode *models.Node, deletedClients []models.ExtClient) error {
+	defer trackPublish()()
+	return publishSingleHostPeerUpdate(context.Background(), host, allNodes, deletedNode, deletedClients)
+}
+
+func publishSingleHostPeerUpdate(ctx context.Context, host *models.Host, allNodes []models.Node, deletedNode *models.Node, deletedClients []models.ExtClient) (err error) {
+	ctx, span := tracing.Start(ctx, "mq.PublishSingleHostPeerUpdate", attribute.String("host.id", host.ID.String()))
+	defer func() { tracing.End(span, err) }()
 
+	_, peerSpan := tracing.Start(ctx, "logic.GetPeerUpdateForHost")
 	peerUpdate, err := logic.GetPeerUpdateForHost("", host, allNodes, deletedNode, deletedClients)
+	tracing.End(peerSpan, err)
 	if err != nil {
 		return err
 	}
 	if len(peerUpdate.Peers) == 0 { // no peers to send
 		return nil
 	}
+	span.SetAttributes(attribute.Int("peers", len(peerUpdate.Peers)))
+	peerUpdate.TraceContext = tracing.Inject(ctx)
 
 	data, err := json.Marshal(&peerUpdate)
 	if err != nil {
 		return err
 	}
-	return publish(host, fmt.Sprintf("peers/host/%s/%s", host.ID.String(), servercfg.GetServer()), data)
+	_, pubSpan := tracing.Start(ctx, "mq.queuePublish")
+	err = queuePublish(host, fmt.Sprintf("peers/host/%s/%s", host.ID.String(), servercfg.GetServer()), data)
+	tracing.End(pubSpan, err)
+	return err
+}
+
+// publishPeerUpdateToHosts - recomputes and publishes the full peer list of only the given hosts
+func publishPeerUpdateToHosts(ctx context.Context, hostIDs []string, deletedNode *models.Node, deletedClients []models.ExtClient) error {
+	allNodes, err := getAllNodes(ctx)
+	if err != nil {
+		return err
+	}
+	var publishErr error
+	for _, hostID := range hostIDs {
+		host, err := logic.GetHost(hostID)
+		if err != nil {
+			continue
+		}
+		if err = publishSingleHostPeerUpdate(ctx, host, allNodes, deletedNode, deletedClients); err != nil {
+			slog.ErrorCtx(ctx, "failed to publish peer update to host", "host_id", hostID, "error", err)
+			publishErr = err
+		}
+	}
+	return publishErr
+}
+
+// getAllHosts - fetches all hosts as a child span of ctx
+func getAllHosts(ctx context.Context) ([]models.Host, error) {
+	_, span := tracing.Start(ctx, "logic.GetAllHosts")
+	hosts, err := logic.GetAllHosts()
+	tracing.End(span, err)
+	return hosts, err
+}
+
+// getAllNodes - fetches all nodes as a child span of ctx
+func getAllNodes(ctx context.Context) ([]models.Node, error) {
+	_, span := tracing.Start(ctx, "logic.GetAllNodes")
+	nodes, err := logic.GetAllNodes()
+	tracing.End(span, err)
+	return nodes, err
 }
 
 // NodeUpdate -- publishes a node update
 func NodeUpdate(node *models.Node) error {
+	defer trackPublish()()
 	host, err := logic.GetHost(node.HostID.String())
 	if err != nil {
 		return nil
@@ -113,7 +179,7 @@ func NodeUpdate(node *models.Node) error {
 	if !servercfg.IsMessageQueueBackend() {
 		return nil
 	}
-	logger.Log(3, "publishing node update to "+node.ID.String())
+	slog.Debug("publishing node update to " + node.ID.String())
 
 	//if len(node.NetworkSettings.AccessKeys) > 0 {
 	//node.NetworkSettings.AccessKeys = []models.AccessKey{} // not to be sent (don't need to spread access keys around the network; we need to know how to reach other nodes, not become them)
@@ -121,11 +187,11 @@ func NodeUpdate(node *models.Node) error {
 
 	data, err := json.Marshal(node)
 	if err != nil {
-		logger.Log(2, "error marshalling node update ", err.Error())
+		slog.Warn("error marshalling node update", "error", err)
 		return err
 	}
 	if err = publish(host, fmt.Sprintf("node/update/%s/%s", node.Network, node.ID), data); err != nil {
-		logger.Log(2, "error publishing node update to peer ", node.ID.String(), err.Error())
+		slog.Warn("error publishing node update to peer", "node_id", node.ID.String(), "error", err)
 		return err
 	}
 
@@ -134,18 +200,19 @@ func NodeUpdate(node *models.Node) error {
 
 // HostUpdate -- publishes a host update to clients
 func HostUpdate(hostUpdate *models.HostUpdate) error {
+	defer trackPublish()()
 	if !servercfg.IsMessageQueueBackend() {
 		return nil
 	}
-	logger.Log(3, "publishing host update to "+hostUpdate.Host.ID.String())
+	slog.Debug("publishing host update to " + hostUpdate.Host.ID.String())
 
 	data, err := json.Marshal(hostUpdate)
 	if err != nil {
-		logger.Log(2, "error marshalling node update ", err.Error())
+		slog.Warn("error marshalling node update", "error", err)
 		return err
 	}
 	if err = publish(&hostUpdate.Host, fmt.Sprintf("host/update/%s/%s", hostUpdate.Host.ID.String(), servercfg.GetServer()), data); err != nil {
-		logger.Log(2, "error publishing host update to", hostUpdate.Host.ID.String(), err.Error())
+		slog.Warn("error publishing host update", "host_id", hostUpdate.Host.ID.String(), "error", err)
 		return err
 	}
 
@@ -161,7 +228,7 @@ func ServerStartNotify() error {
 	for i := range nodes {
 		nodes[i].Action = models.NODE_FORCE_UPDATE
 		if err = NodeUpdate(&nodes[i]); err != nil {
-			logger.Log(1, "error when notifying node", nodes[i].ID.String(), "of a server startup")
+			slog.Error("error when notifying node of a server startup", "node_id", nodes[i].ID.String())
 		}
 	}
 	return nil
@@ -176,18 +243,18 @@ func PublishDNSUpdate(network string, dns models.DNSUpdate) error {
 	for _, node := range nodes {
 		host, err := logic.GetHost(node.HostID.String())
 		if err != nil {
-			logger.Log(0, "error retrieving host for dns update", node.HostID.String(), err.Error())
+			slog.Error("error retrieving host for dns update", "host_id", node.HostID.String(), "error", err)
 			continue
 		}
 		data, err := json.Marshal(dns)
 		if err != nil {
-			logger.Log(0, "failed to encode dns data for node", node.ID.String(), err.Error())
+			slog.Error("failed to encode dns data for node", "node_id", node.ID.String(), "error", err)
 		}
 		if err := publish(host, "dns/update/"+host.ID.String()+"/"+servercfg.GetServer(), data); err != nil {
-			logger.Log(0, "error publishing dns update to host", host.ID.String(), err.Error())
+			slog.Error("error publishing dns update to host", "host_id", host.ID.String(), "error", err)
 			continue
 		}
-		logger.Log(3, "published dns update to host", host.ID.String())
+		slog.Debug("published dns update to host", "host_id", host.ID.String())
 	}
 	return nil
 }
@@ -209,7 +276,7 @@ func PublishAllDNS(newnode *models.Node) error {
 	if err := publish(newnodeHost, "dns/all/"+newnodeHost.ID.String()+"/"+servercfg.GetServer(), data); err != nil {
 		return fmt.Errorf("error publishing full dns update to %s, %w", newnodeHost.ID.String(), err)
 	}
-	logger.Log(3, "published full dns update to %s", newnodeHost.ID.String())
+	slog.Debug("published full dns update", "host_id", newnodeHost.ID.String())
 	return nil
 }
 
@@ -217,7 +284,7 @@ func PublishAllDNS(newnode *models.Node) error {
 func PublishDNSDelete(node *models.Node, host *models.Host) error {
 	dns := models.DNSUpdate{
 		Action: models.DNSDeleteByIP,
-		Name:   host.Name + "." + node.Network,
+		Name:   logic.NodeName(node, host) + "." + node.Network,
 	}
 	if node.Address.IP != nil {
 		dns.Address = node.Address.IP.String()
@@ -238,7 +305,7 @@ func PublishDNSDelete(node *models.Node, host *models.Host) error {
 func PublishReplaceDNS(oldNode, newNode *models.Node, host *models.Host) error {
 	dns := models.DNSUpdate{
 		Action: models.DNSReplaceIP,
-		Name:   host.Name + "." + oldNode.Network,
+		Name:   logic.NodeName(oldNode, host) + "." + oldNode.Network,
 	}
 	if !oldNode.Address.IP.Equal(newNode.Address.IP) {
 		dns.Address = oldNode.Address.IP.String()
@@ -262,7 +329,7 @@ func PublishExtCLientDNS(client *models.ExtClient) error {
 	errMsgs := models.DNSError{}
 	dns := models.DNSUpdate{
 		Action:  models.DNSInsert,
-		Name:    client.ClientID + "." + client.Network,
+		Name:    logic.ExtClientDNSName(client) + "." + client.Network,
 		Address: client.Address,
 	}
 	if client.Address != "" {
@@ -288,8 +355,8 @@ func PublishExtCLientDNS(client *models.ExtClient) error {
 func PublishExtClientDNSUpdate(old, new models.ExtClient, network string) error {
 	dns := models.DNSUpdate{
 		Action:  models.DNSReplaceName,
-		Name:    old.ClientID + "." + network,
-		NewName: new.ClientID + "." + network,
+		Name:    logic.ExtClientDNSName(&old) + "." + network,
+		NewName: logic.ExtClientDNSName(&new) + "." + network,
 	}
 	if err := PublishDNSUpdate(network, dns); err != nil {
 		return err
@@ -301,7 +368,7 @@ func PublishExtClientDNSUpdate(old, new models.ExtClient, network string) error
 func PublishDeleteExtClientDNS(client *models.ExtClient) error {
 	dns := models.DNSUpdate{
 		Action: models.DNSDeleteByName,
-		Name:   client.ClientID + "." + client.Network,
+		Name:   logic.ExtClientDNSName(client) + "." + client.Network,
 	}
 	if err := PublishDNSUpdate(client.Network, dns); err != nil {
 		return err
@@ -342,8 +409,17 @@ func PublishHostDNSUpdate(old, new *models.Host, networks []string) error {
 	return nil
 }
 
+// PublishNodeRenameDNS publishes dns update on node name change
+func PublishNodeRenameDNS(network, oldName, newName string) error {
+	return PublishDNSUpdate(network, models.DNSUpdate{
+		Action:  models.DNSReplaceName,
+		Name:    oldName + "." + network,
+		NewName: newName + "." + network,
+	})
+}
+
 func pushMetricsToExporter(metrics models.Metrics) error {
-	logger.Log(2, "----> Pushing metrics to exporter")
+	slog.Debug("> Pushing metrics to exporter")
 	data, err := json.Marshal(metrics)
 	if err != nil {
 		return errors.New("failed to marshal metrics: " + err.Error())
@@ -365,16 +441,16 @@ func getNodeDNS(network string) []models.DNSUpdate {
 	dns := models.DNSUpdate{}
 	nodes, err := logic.GetNetworkNodes(network)
 	if err != nil {
-		logger.Log(0, "error retreiving network nodes for network", network, err.Error())
+		slog.Error("error retreiving network nodes for network", "network", network, "error", err)
 	}
 	for _, node := range nodes {
 		host, err := logic.GetHost(node.HostID.String())
 		if err != nil {
-			logger.Log(0, "error retrieving host for dns update", node.HostID.String(), err.Error())
+			slog.Error("error retrieving host for dns update", "host_id", node.HostID.String(), "error", err)
 			continue
 		}
 		dns.Action = models.DNSInsert
-		dns.Name = host.Name + "." + node.Network
+		dns.Name = logic.NodeName(&node, host) + "." + node.Network
 		if node.Address.IP != nil {
 			dns.Address = node.Address.IP.String()
 			alldns = append(alldns, dns)
@@ -392,17 +468,20 @@ func getExtClientDNS(network string) []models.DNSUpdate {
 	dns := models.DNSUpdate{}
 	clients, err := logic.GetNetworkExtClients(network)
 	if err != nil {
-		logger.Log(0, "error retrieving extclients", err.Error())
+		slog.Error("error retrieving extclients", "error", err)
 	}
 	for _, client := range clients {
+		if !client.Enabled {
+			continue
+		}
 		dns.Action = models.DNSInsert
-		dns.Name = client.ClientID + "." + client.Network
+		dns.Name = logic.ExtClientDNSName(&client) + "." + client.Network
 		if client.Address != "" {
 			dns.Address = client.Address
 			alldns = append(alldns, dns)
 		}
 		if client.Address6 != "" {
-			dns.Address = client.Address
+			dns.Address = client.Address6
 			alldns = append(alldns, dns)
 		}
 	}
@@ -414,7 +493,7 @@ func getCustomDNS(network string) []models.DNSUpdate {
 	dns := models.DNSUpdate{}
 	customdns, err := logic.GetCustomDNS(network)
 	if err != nil {
-		logger.Log(0, "error retrieving custom dns entries", err.Error())
+		slog.Error("error retrieving custom dns entries", "error", err)
 	}
 	for _, custom := range customdns {
 		dns.Action = models.DNSInsert
@@ -430,7 +509,7 @@ func sendPeers() {
 
 	hosts, err := logic.GetAllHosts()
 	if err != nil && len(hosts) > 0 {
-		logger.Log(1, "error retrieving networks for keepalive", err.Error())
+		slog.Error("error retrieving networks for keepalive", "error", err)
 	}
 	nodes, err := logic.GetAllNodes()
 	if err != nil {
@@ -442,19 +521,28 @@ func sendPeers() {
 		servercfg.SetHost()
 		force = true
 		peer_force_send = 0
-		err := logic.TimerCheckpoint() // run telemetry & log dumps if 24 hours has passed..
-		if err != nil {
-			logger.Log(3, "error occurred on timer,", err.Error())
+	}
+	// gateways drop the peers of expired sidecars and remote access clients right away
+	if expired := logic.DeleteExpiredExtClients(); len(expired) > 0 {
+		force = true
+		for i := range expired {
+			if err := PublishDeleteExtClientDNS(&expired[i]); err != nil {
+				slog.Error("error publishing dns update for expired ext client", "error", err)
+			}
 		}
-
-		//collectServerMetrics(networks[:])
 	}
+	// as do they the peers of ext clients whose short lived key ran out
+	now := time.Now()
+	if expired := logic.ExpiredExtClientKeys(lastKeyExpiryCheck); len(expired) > 0 {
+		force = true
+	}
+	lastKeyExpiryCheck = now
 	if force {
 		for _, host := range hosts {
 			host := host
-			logger.Log(2, "sending scheduled peer update (5 min)")
+			slog.Debug("sending scheduled peer update (5 min)")
 			if err = PublishSingleHostPeerUpdate(&host, nodes, nil, nil); err != nil {
-				logger.Log(1, "error publishing peer updates for host: ", host.ID.String(), " Err: ", err.Error())
+				slog.Error("error publishing peer updates for host", "host_id", host.ID.String(), "error", err)
 			}
 		}
 	}
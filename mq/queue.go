@@ -0,0 +1,237 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// publishQueueSize - hosts that can wait for a worker, the rest are picked up by the retry sweep
+	publishQueueSize = 1000
+	// publishRetryInterval - how often queued publishes that are due are handed back to the workers
+	publishRetryInterval = 5 * time.Second
+)
+
+var errBrokerNotConnected = errors.New("broker not connected")
+
+var (
+	// publishQueue - ids of hosts with a queued publish waiting for a worker
+	publishQueue   = make(chan string, publishQueueSize)
+	publishWorkers int
+	publishMutex   sync.Mutex
+	// enqueuedHosts - hosts already waiting in publishQueue, so a burst of updates is sent once
+	enqueuedHosts = map[string]struct{}{}
+	// inflightHosts - hosts a worker is sending to, true when a publish was queued meanwhile
+	// and has to be sent once the worker is done
+	inflightHosts = map[string]bool{}
+	// publishStatuses - the outcome of the last publish attempt to each host
+	publishStatuses = map[string]models.HostPublishStatus{}
+)
+
+// StartPublishWorkers - starts the workers that send queued peer updates to the broker and
+// the sweep that retries failed ones, including any left queued by a previous run
+func StartPublishWorkers(ctx context.Context) {
+	publishMutex.Lock()
+	publishWorkers = servercfg.GetPublishWorkers()
+	publishMutex.Unlock()
+	for i := 0; i < publishWorkers; i++ {
+		// workers outlive ctx so Drain can still flush the queue on shutdown
+		go func() {
+			for hostID := range publishQueue {
+				sendQueuedPublish(hostID)
+			}
+		}()
+	}
+	go retryQueuedPublishes(ctx)
+}
+
+// GetHostPublishStatus - whether the latest peer update of a host reached the broker
+func GetHostPublishStatus(hostID string) models.HostPublishStatus {
+	publishMutex.Lock()
+	status, ok := publishStatuses[hostID]
+	publishMutex.Unlock()
+	if !ok {
+		status.HostID = hostID
+	}
+	if queued, err := logic.GetQueuedPublish(hostID); err == nil {
+		applyQueuedPublish(&status, &queued)
+	}
+	return status
+}
+
+// GetPublishQueueStatus - how many peer updates are waiting to reach the broker and which hosts are failing
+func GetPublishQueueStatus() (models.PublishQueueStatus, error) {
+	publishMutex.Lock()
+	status := models.PublishQueueStatus{
+		Workers:         publishWorkers,
+		BrokerConnected: IsConnected(),
+		Failing:         []models.HostPublishStatus{},
+	}
+	publishMutex.Unlock()
+	queued, err := logic.GetQueuedPublishes()
+	if err != nil {
+		return status, err
+	}
+	status.Depth = len(queued)
+	for i := range queued {
+		if queued[i].Attempts == 0 {
+			continue
+		}
+		status.Failing = append(status.Failing, GetHostPublishStatus(queued[i].HostID))
+	}
+	return status, nil
+}
+
+// == private ==
+
+// queuePublish - encrypts a message for a host and queues it for the workers,
+// replacing any earlier message to the host that hasn't been sent yet
+func queuePublish(host *models.Host, dest string, msg []byte) error {
+	encrypted, err := encryptMsg(host, dest, msg)
+	if err != nil {
+		return err
+	}
+	if err := logic.QueuePublish(&models.QueuedPublish{
+		Topic:   dest,
+		HostID:  host.ID.String(),
+		Payload: encrypted,
+	}); err != nil {
+		return err
+	}
+	enqueuePublish(host.ID.String())
+	return nil
+}
+
+// enqueuePublish - hands a host to the workers unless it is already waiting for one,
+// when the queue is full the retry sweep picks it up instead
+func enqueuePublish(hostID string) {
+	publishMutex.Lock()
+	defer publishMutex.Unlock()
+	if _, ok := enqueuedHosts[hostID]; ok {
+		return
+	}
+	if _, ok := inflightHosts[hostID]; ok {
+		// a second worker would race the first, so wait for it to finish
+		inflightHosts[hostID] = true
+		return
+	}
+	select {
+	case publishQueue <- hostID:
+		enqueuedHosts[hostID] = struct{}{}
+		pendingPublishes.Add(1)
+	default:
+	}
+}
+
+// sendQueuedPublish - sends the publish queued for a host, backing it off when it fails
+func sendQueuedPublish(hostID string) {
+	defer pendingPublishes.Add(-1)
+	publishMutex.Lock()
+	// a publish queued from here on needs another worker pass, after this one
+	delete(enqueuedHosts, hostID)
+	inflightHosts[hostID] = false
+	publishMutex.Unlock()
+	defer finishPublish(hostID)
+
+	queued, err := logic.GetQueuedPublish(hostID)
+	if err != nil {
+		// already sent by an earlier pass
+		return
+	}
+	if _, err := logic.GetHost(hostID); err != nil {
+		if err := logic.DeleteQueuedPublish(hostID); err != nil {
+			slog.Error("failed to drop publish queued for deleted host", "host", hostID, "error", err)
+		}
+		publishMutex.Lock()
+		delete(publishStatuses, hostID)
+		publishMutex.Unlock()
+		return
+	}
+	publishErr := errBrokerNotConnected
+	if IsConnected() {
+		publishErr = sendPublish(queued.Topic, queued.Payload)
+	}
+	now := time.Now().UTC()
+	if publishErr == nil {
+		if err := logic.CompleteQueuedPublish(&queued); err != nil && !errors.Is(err, logic.ErrPublishReplaced) {
+			slog.Error("failed to remove sent publish from queue", "host", hostID, "error", err)
+		}
+		setPublishStatus(hostID, func(status *models.HostPublishStatus) {
+			status.LastPublished = now
+		})
+		return
+	}
+	slog.Warn("failed to publish peer update, will retry", "host", hostID, "attempts", queued.Attempts+1, "error", publishErr)
+	if err := logic.RetryQueuedPublish(&queued, publishErr); err != nil && !errors.Is(err, logic.ErrPublishReplaced) {
+		slog.Error("failed to requeue publish", "host", hostID, "error", err)
+	}
+	setPublishStatus(hostID, func(status *models.HostPublishStatus) {
+		status.LastError = publishErr.Error()
+		status.LastErrorTime = now
+	})
+}
+
+// finishPublish - lets other workers send to a host again, handing it back to them
+// when a publish was queued while it was being sent
+func finishPublish(hostID string) {
+	publishMutex.Lock()
+	again := inflightHosts[hostID]
+	delete(inflightHosts, hostID)
+	publishMutex.Unlock()
+	if again {
+		enqueuePublish(hostID)
+	}
+}
+
+// retryQueuedPublishes - hands queued publishes that are due back to the workers while the broker is up
+func retryQueuedPublishes(ctx context.Context) {
+	for {
+		logic.WorkerHeartbeat("publish_queue", publishRetryInterval)
+		select {
+		case <-ctx.Done():
+			logic.StopWorker("publish_queue")
+			return
+		case <-time.After(publishRetryInterval):
+			if !IsConnected() {
+				continue
+			}
+			queued, err := logic.GetQueuedPublishes()
+			if err != nil {
+				slog.Error("failed to read publish queue", "error", err)
+				continue
+			}
+			now := time.Now().UTC()
+			for i := range queued {
+				if !queued[i].NextAttempt.After(now) {
+					enqueuePublish(queued[i].HostID)
+				}
+			}
+		}
+	}
+}
+
+func setPublishStatus(hostID string, update func(status *models.HostPublishStatus)) {
+	publishMutex.Lock()
+	defer publishMutex.Unlock()
+	status := publishStatuses[hostID]
+	status.HostID = hostID
+	update(&status)
+	publishStatuses[hostID] = status
+}
+
+// applyQueuedPublish - fills in the pending part of a host's status from its queued publish
+func applyQueuedPublish(status *models.HostPublishStatus, queued *models.QueuedPublish) {
+	status.Pending = true
+	status.Attempts = queued.Attempts
+	status.LastQueued = queued.Queued
+	if queued.LastError != "" && status.LastError == "" {
+		status.LastError = queued.LastError
+	}
+}
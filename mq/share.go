@@ -0,0 +1,41 @@
+package mq
+
+import (
+	"hash/fnv"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/gravitl/netmaker/servercfg"
+)
+
+// sharedTopic - subscribes through a shared subscription when server instances share a group,
+// so the broker hands each host message to only one of them
+func sharedTopic(topic string) string {
+	group := servercfg.GetMQSharedGroup()
+	if group == "" {
+		return topic
+	}
+	return "$share/" + group + "/" + topic
+}
+
+// partitionHandler - for brokers without shared subscriptions, every instance receives each host
+// message and only handles those whose host or node is hashed to its partition
+func partitionHandler(handler mqtt.MessageHandler) mqtt.MessageHandler {
+	partition, partitions := servercfg.GetMQPartition()
+	if partitions == 1 {
+		return handler
+	}
+	return func(client mqtt.Client, msg mqtt.Message) {
+		id, err := getID(msg.Topic())
+		if err != nil || topicPartition(id, partitions) != partition {
+			return
+		}
+		handler(client, msg)
+	}
+}
+
+// topicPartition - the partition the messages of a host or node are handled by
+func topicPartition(id string, partitions int) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(id))
+	return int(hash.Sum32() % uint32(partitions))
+}
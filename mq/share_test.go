@@ -0,0 +1,72 @@
+package mq
+
+import (
+	"fmt"
+	"testing"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/stretchr/testify/assert"
+)
+
+// testMessage - a host message as delivered by the broker
+type testMessage struct {
+	topic string
+}
+
+func (m *testMessage) Duplicate() bool   { return false }
+func (m *testMessage) Qos() byte         { return 0 }
+func (m *testMessage) Retained() bool    { return false }
+func (m *testMessage) Topic() string     { return m.topic }
+func (m *testMessage) MessageID() uint16 { return 0 }
+func (m *testMessage) Payload() []byte   { return nil }
+func (m *testMessage) Ack()              {}
+
+func TestSharedTopic(t *testing.T) {
+	t.Setenv("MQ_SHARED_GROUP", "")
+	assert.Equal(t, "update/netmaker/#", sharedTopic("update/netmaker/#"))
+	t.Setenv("MQ_SHARED_GROUP", "servers")
+	assert.Equal(t, "$share/servers/update/netmaker/#", sharedTopic("update/netmaker/#"))
+}
+
+func TestPartitionHandler(t *testing.T) {
+	t.Run("Single", func(t *testing.T) {
+		t.Setenv("MQ_PARTITIONS", "")
+		t.Setenv("MQ_PARTITION", "")
+		handled := 0
+		handler := partitionHandler(func(mqtt.Client, mqtt.Message) { handled++ })
+		handler(nil, &testMessage{topic: "update/netmaker/node1"})
+		assert.Equal(t, 1, handled)
+	})
+	t.Run("InvalidPartition", func(t *testing.T) {
+		// falls back to handling everything rather than nothing
+		t.Setenv("MQ_PARTITIONS", "3")
+		t.Setenv("MQ_PARTITION", "3")
+		handled := 0
+		partitionHandler(func(mqtt.Client, mqtt.Message) { handled++ })(nil, &testMessage{topic: "update/netmaker/node1"})
+		assert.Equal(t, 1, handled)
+	})
+	t.Run("EachMessageOnce", func(t *testing.T) {
+		handled := map[string]int{}
+		for partition := 0; partition < 3; partition++ {
+			t.Setenv("MQ_PARTITIONS", "3")
+			t.Setenv("MQ_PARTITION", fmt.Sprint(partition))
+			handler := partitionHandler(func(_ mqtt.Client, msg mqtt.Message) { handled[msg.Topic()]++ })
+			for i := 0; i < 30; i++ {
+				handler(nil, &testMessage{topic: fmt.Sprintf("update/netmaker/node%d", i)})
+			}
+			// a topic without an id isn't handled by anyone
+			handler(nil, &testMessage{topic: "update"})
+		}
+		assert.Equal(t, 30, len(handled))
+		for topic, count := range handled {
+			assert.Equal(t, 1, count, topic)
+		}
+	})
+	t.Run("Spread", func(t *testing.T) {
+		partitions := map[int]struct{}{}
+		for i := 0; i < 40; i++ {
+			partitions[topicPartition(fmt.Sprintf("host%d", i), 4)] = struct{}{}
+		}
+		assert.Equal(t, 4, len(partitions))
+	})
+}
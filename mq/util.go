@@ -1,14 +1,18 @@
 package mq
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
 	"time"
 
+	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/netclient/ncutils"
+	"github.com/gravitl/netmaker/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func decryptMsgWithHost(host *models.Host, msg []byte) ([]byte, error) {
@@ -44,7 +48,16 @@ func decryptMsg(node *models.Node, msg []byte) ([]byte, error) {
 	return decryptMsgWithHost(host, msg)
 }
 
-func encryptMsg(host *models.Host, msg []byte) ([]byte, error) {
+// encryptMsg - encrypts a message published to a host on dest, signing it first when the host verifies
+// signatures so a message injected at the broker can't pass for one of the server
+func encryptMsg(host *models.Host, dest string, msg []byte) ([]byte, error) {
+	if host.SignedUpdates {
+		signed, err := logic.SignMessage(dest, msg)
+		if err != nil {
+			return nil, err
+		}
+		msg = signed
+	}
 	if host.OS == models.OS_Types.IoT {
 		return msg, nil
 	}
@@ -73,10 +86,16 @@ func encryptMsg(host *models.Host, msg []byte) ([]byte, error) {
 }
 
 func publish(host *models.Host, dest string, msg []byte) error {
-	encrypted, encryptErr := encryptMsg(host, msg)
+	defer trackPublish()()
+	encrypted, encryptErr := encryptMsg(host, dest, msg)
 	if encryptErr != nil {
 		return encryptErr
 	}
+	return sendPublish(dest, encrypted)
+}
+
+// sendPublish - sends an already encrypted message to the broker
+func sendPublish(dest string, encrypted []byte) error {
 	if mqclient == nil {
 		return errors.New("cannot publish ... mqclient not connected")
 	}
@@ -92,6 +111,33 @@ func publish(host *models.Host, dest string, msg []byte) error {
 	return nil
 }
 
+// tracedMessage - a received message carrying the context of its span, so handlers trace their work under it
+type tracedMessage struct {
+	mqtt.Message
+	ctx context.Context
+}
+
+// traceHandler - wraps a subscription handler so each received message is recorded as a span,
+// handlers get its context through messageContext
+func traceHandler(name string, handler mqtt.MessageHandler) mqtt.MessageHandler {
+	return func(client mqtt.Client, msg mqtt.Message) {
+		ctx, span := tracing.Start(context.Background(), "mq."+name,
+			attribute.String("mqtt.topic", msg.Topic()),
+			attribute.Int("mqtt.payload_size", len(msg.Payload())),
+		)
+		defer span.End()
+		handler(client, &tracedMessage{Message: msg, ctx: ctx})
+	}
+}
+
+// messageContext - the context of the span a message is handled in
+func messageContext(msg mqtt.Message) context.Context {
+	if traced, ok := msg.(*tracedMessage); ok {
+		return traced.ctx
+	}
+	return context.Background()
+}
+
 // decodes a message queue topic and returns the embedded node.ID
 func getID(topic string) (string, error) {
 	parts := strings.Split(topic, "/")
@@ -0,0 +1,41 @@
+package ncutils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gravitl/netmaker/models"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// HashConfig - the hash a host checks in with of the WireGuard peers and firewall config it applied, compared by
+// the server to that of the peer update it expects the host to have; endpoints are left out as hosts may reach
+// peers elsewhere than where the server sees them
+func HashConfig(peers []wgtypes.PeerConfig, fw models.FwUpdate) string {
+	lines := make([]string, 0, len(peers)+1)
+	for _, peer := range peers {
+		if peer.Remove {
+			continue
+		}
+		allowed := make([]string, 0, len(peer.AllowedIPs))
+		for _, ip := range peer.AllowedIPs {
+			allowed = append(allowed, ip.String())
+		}
+		sort.Strings(allowed)
+		keepalive := ""
+		if peer.PersistentKeepaliveInterval != nil {
+			keepalive = peer.PersistentKeepaliveInterval.String()
+		}
+		lines = append(lines, fmt.Sprintf("peer %s %s %s", peer.PublicKey, strings.Join(allowed, ","), keepalive))
+	}
+	sort.Strings(lines)
+	// maps are marshaled with sorted keys
+	firewall, _ := json.Marshal(&fw)
+	lines = append(lines, "fw "+string(firewall))
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,84 @@
+package ncutils
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+)
+
+// ReplayWindow - how far the time of a message may be from the clock of the host, messages outside of it are
+// refused and the nonces of the ones inside it are remembered
+const ReplayWindow = 5 * time.Minute
+
+var (
+	// ErrUnknownSigningKey - a message is signed with a key the host doesn't know, it pulls to get the keys of the server
+	ErrUnknownSigningKey = errors.New("message signed with an unknown key")
+	// ErrInvalidSignature - a message isn't signed by the server, was changed after or published to another topic
+	ErrInvalidSignature = errors.New("invalid message signature")
+	// ErrReplayedMessage - a message was accepted before or is outside of the replay window
+	ErrReplayedMessage = errors.New("message replayed")
+)
+
+// ReplayGuard - the nonces of the messages a host accepted within the replay window,
+// so messages arriving out of order are each accepted once
+type ReplayGuard struct {
+	mutex sync.Mutex
+	seen  map[string]int64
+}
+
+// NewReplayGuard - a guard that hasn't accepted any message yet
+func NewReplayGuard() *ReplayGuard {
+	return &ReplayGuard{seen: map[string]int64{}}
+}
+
+// Accept - records the nonce of a message of the given unix nanoseconds,
+// false when it's outside of the replay window or was accepted before
+func (g *ReplayGuard) Accept(nonce string, timestamp int64) bool {
+	now := time.Now()
+	if nonce == "" || timestamp < now.Add(-ReplayWindow).UnixNano() || timestamp > now.Add(ReplayWindow).UnixNano() {
+		return false
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	for seen, at := range g.seen {
+		if at < now.Add(-ReplayWindow).UnixNano() {
+			delete(g.seen, seen)
+		}
+	}
+	if _, ok := g.seen[nonce]; ok {
+		return false
+	}
+	g.seen[nonce] = timestamp
+	return true
+}
+
+// SigningInput - the bytes the signature of a message covers, its topic, time, nonce and payload
+func SigningInput(topic string, timestamp int64, nonce string, payload []byte) []byte {
+	return append([]byte(strconv.Quote(topic)+"."+strconv.FormatInt(timestamp, 10)+"."+nonce+"."), payload...)
+}
+
+// VerifySignedMessage - checks a decrypted message received on topic was signed for it by the server with one
+// of keys, by key id, and wasn't accepted by guard before; returns its payload
+func VerifySignedMessage(topic string, msg []byte, keys map[string]ed25519.PublicKey, guard *ReplayGuard) ([]byte, error) {
+	var signed models.SignedMessage
+	if err := json.Unmarshal(msg, &signed); err != nil || signed.KeyID == "" {
+		return nil, ErrInvalidSignature
+	}
+	key, ok := keys[signed.KeyID]
+	if !ok {
+		return nil, ErrUnknownSigningKey
+	}
+	if signed.Topic != topic || len(key) != ed25519.PublicKeySize ||
+		!ed25519.Verify(key, SigningInput(signed.Topic, signed.Time, signed.Nonce, signed.Payload), signed.Signature) {
+		return nil, ErrInvalidSignature
+	}
+	if !guard.Accept(signed.Nonce, signed.Time) {
+		return nil, ErrReplayedMessage
+	}
+	return signed.Payload, nil
+}
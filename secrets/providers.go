@@ -0,0 +1,203 @@
+package secrets
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gravitl/netmaker/servercfg"
+)
+
+// KeyProvider - wraps and unwraps data keys with a master key that never leaves the provider
+type KeyProvider interface {
+	// ID - identifies the master key, stored alongside the data keys it wrapped
+	ID() string
+	Wrap(key []byte) ([]byte, error)
+	Unwrap(wrapped []byte) ([]byte, error)
+}
+
+// OpenProvider - returns the key provider for a master key uri:
+// base64:<key> and file:///path/to/key use a local 32 byte key,
+// vault://host:port/<mount>/<key> (or vault+http://) uses a vault transit key
+func OpenProvider(uri string) (KeyProvider, error) {
+	switch {
+	case strings.HasPrefix(uri, "base64:"):
+		return newLocalProvider(strings.TrimPrefix(uri, "base64:"))
+	case strings.HasPrefix(uri, "file://"):
+		data, err := os.ReadFile(strings.TrimPrefix(uri, "file://"))
+		if err != nil {
+			return nil, err
+		}
+		return newLocalProvider(strings.TrimSpace(string(data)))
+	case strings.HasPrefix(uri, "vault://"), strings.HasPrefix(uri, "vault+http://"):
+		return newVaultProvider(uri)
+	}
+	return nil, fmt.Errorf("unsupported encryption key uri %q", uri)
+}
+
+// == local master key ==
+
+type localProvider struct {
+	aead cipher.AEAD
+	id   string
+}
+
+func newLocalProvider(encoded string) (*localProvider, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid master key: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, errors.New("invalid master key: must be 32 bytes")
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint := sha256.Sum256(key)
+	return &localProvider{aead: aead, id: "local:" + hex.EncodeToString(fingerprint[:8])}, nil
+}
+
+func (p *localProvider) ID() string {
+	return p.id
+}
+
+func (p *localProvider) Wrap(key []byte) ([]byte, error) {
+	return seal(p.aead, key)
+}
+
+func (p *localProvider) Unwrap(wrapped []byte) ([]byte, error) {
+	return unseal(p.aead, wrapped)
+}
+
+// == vault transit ==
+
+type vaultProvider struct {
+	address string
+	mount   string
+	key     string
+	client  *http.Client
+}
+
+func newVaultProvider(uri string) (*vaultProvider, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	mount, key, found := strings.Cut(strings.Trim(u.Path, "/"), "/")
+	if !found || mount == "" || key == "" {
+		return nil, errors.New("invalid vault uri, expected vault://host:port/<mount>/<key>")
+	}
+	scheme := "https"
+	if u.Scheme == "vault+http" {
+		scheme = "http"
+	}
+	if servercfg.GetVaultToken() == "" {
+		return nil, errors.New("VAULT_TOKEN must be set to use a vault master key")
+	}
+	return &vaultProvider{
+		address: scheme + "://" + u.Host,
+		mount:   mount,
+		key:     key,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (p *vaultProvider) ID() string {
+	return "vault:" + p.mount + "/" + p.key
+}
+
+func (p *vaultProvider) Wrap(key []byte) ([]byte, error) {
+	var resp struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}
+	err := p.call("encrypt", map[string]string{"plaintext": base64.StdEncoding.EncodeToString(key)}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(resp.Data.Ciphertext), nil
+}
+
+func (p *vaultProvider) Unwrap(wrapped []byte) ([]byte, error) {
+	var resp struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+	err := p.call("decrypt", map[string]string{"ciphertext": string(wrapped)}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(resp.Data.Plaintext)
+}
+
+// call - posts a request to the transit endpoint (encrypt or decrypt) of the key
+func (p *vaultProvider) call(operation string, body, result any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	endpoint := fmt.Sprintf("%s/v1/%s/%s/%s", p.address, p.mount, operation, p.key)
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Add("content-type", "application/json")
+	req.Header.Add("X-Vault-Token", servercfg.GetVaultToken())
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("vault %s failed: %s", operation, string(msg))
+	}
+	return json.Unmarshal(msg, result)
+}
+
+// == aes-gcm helpers ==
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal - encrypts plaintext, prefixing the result with a random nonce
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// unseal - decrypts data produced by seal
+func unseal(aead cipher.AEAD, data []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
@@ -0,0 +1,310 @@
+// Package secrets encrypts sensitive data at rest with envelope encryption:
+// records are encrypted with data keys, which are stored wrapped by a master key
+// that is either local to the server or held by an external KMS
+package secrets
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
+)
+
+// keyringKey - key of the record holding the wrapped data keys in the server conf table
+const keyringKey = "nm-data-keys"
+
+// sealedPrefix - prefix of values sealed by this version of the envelope format
+const sealedPrefix = database.EncryptedPrefix + "v1:"
+
+// ErrNotEnabled - returned when encryption is used without a configured master key
+var ErrNotEnabled = errors.New("encryption at rest is not enabled, set ENCRYPTION_KEY_URI")
+
+// dataKey - a data key as stored in the database, wrapped by a master key
+type dataKey struct {
+	ID       string `json:"id"`
+	Provider string `json:"provider"`
+	Wrapped  []byte `json:"wrapped"`
+}
+
+// keyring - the data keys in use, values are sealed with the active one
+type keyring struct {
+	Active string    `json:"active"`
+	Keys   []dataKey `json:"keys"`
+}
+
+// envelope - implements database.FieldCipher with the unwrapped data keys
+type envelope struct {
+	mu       sync.RWMutex
+	provider KeyProvider
+	ring     keyring
+	keys     map[string]cipher.AEAD
+}
+
+var (
+	envelopeMutex sync.Mutex
+	current       *envelope
+)
+
+// Init - enables encryption of sensitive fields when a master key is configured,
+// on first use a data key is created and existing records are encrypted with it
+func Init() error {
+	envelopeMutex.Lock()
+	defer envelopeMutex.Unlock()
+	uri := servercfg.GetEncryptionKeyURI()
+	if uri == "" {
+		return nil
+	}
+	provider, err := OpenProvider(uri)
+	if err != nil {
+		return err
+	}
+	providers := map[string]KeyProvider{provider.ID(): provider}
+	if prevURI := servercfg.GetPrevEncryptionKeyURI(); prevURI != "" {
+		prev, err := OpenProvider(prevURI)
+		if err != nil {
+			return err
+		}
+		providers[prev.ID()] = prev
+	}
+	env := &envelope{provider: provider, keys: make(map[string]cipher.AEAD)}
+	ring, err := fetchKeyring()
+	if err != nil && !database.IsEmptyRecord(err) {
+		return err
+	}
+	firstUse := err != nil
+	if !firstUse {
+		for _, k := range ring.Keys {
+			p, ok := providers[k.Provider]
+			if !ok {
+				return fmt.Errorf("data key %s is wrapped by unknown master key %s", k.ID, k.Provider)
+			}
+			if err = env.load(p, k); err != nil {
+				return fmt.Errorf("failed to unwrap data key %s: %w", k.ID, err)
+			}
+		}
+		env.ring.Active = ring.Active
+	} else if err = env.addKey(); err != nil {
+		return err
+	}
+	current = env
+	database.SetFieldCipher(env)
+	servercfg.SetSecretOpener(Open)
+	if firstUse {
+		count, err := encryptAll()
+		if err != nil {
+			return err
+		}
+		slog.Info("encryption at rest enabled, encrypted records", "count", count)
+	}
+	return nil
+}
+
+// ReEncrypt - rotates the data key and re-encrypts every sensitive field with it,
+// data keys are rewrapped with the current master key and the old ones dropped,
+// other servers sharing the database must be restarted to load the new data key
+func ReEncrypt() (int, error) {
+	envelopeMutex.Lock()
+	defer envelopeMutex.Unlock()
+	env := current
+	if env == nil {
+		return 0, ErrNotEnabled
+	}
+	if err := env.addKey(); err != nil {
+		return 0, err
+	}
+	count, err := encryptAll()
+	if err != nil {
+		return count, err
+	}
+	env.mu.Lock()
+	for _, k := range env.ring.Keys {
+		if k.ID != env.ring.Active {
+			delete(env.keys, k.ID)
+		}
+	}
+	env.ring.Keys = env.ring.Keys[len(env.ring.Keys)-1:]
+	ring := env.ring
+	env.mu.Unlock()
+	return count, storeKeyring(ring)
+}
+
+// Seal - encrypts a secret, eg. to be set as MQ_PASSWORD in its encrypted form
+func Seal(value string) (string, error) {
+	if current == nil {
+		return "", ErrNotEnabled
+	}
+	return current.Encrypt([]byte(value))
+}
+
+// Open - decrypts a secret made by Seal, values that are not encrypted are returned as is
+func Open(value string) (string, error) {
+	if !strings.HasPrefix(value, database.EncryptedPrefix) {
+		return value, nil
+	}
+	if current == nil {
+		return "", ErrNotEnabled
+	}
+	plain, err := current.Decrypt(value)
+	return string(plain), err
+}
+
+// Encrypt - seals plaintext with the active data key as enc:v1:<key id>:<base64 nonce and ciphertext>
+func (e *envelope) Encrypt(plaintext []byte) (string, error) {
+	e.mu.RLock()
+	id := e.ring.Active
+	aead := e.keys[id]
+	e.mu.RUnlock()
+	if aead == nil {
+		return "", errors.New("no active data key")
+	}
+	data, err := seal(aead, plaintext)
+	if err != nil {
+		return "", err
+	}
+	return sealedPrefix + id + ":" + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// Decrypt - opens a value sealed by Encrypt with any of the loaded data keys
+func (e *envelope) Decrypt(ciphertext string) ([]byte, error) {
+	id, encoded, found := strings.Cut(strings.TrimPrefix(ciphertext, sealedPrefix), ":")
+	if !strings.HasPrefix(ciphertext, sealedPrefix) || !found {
+		return nil, errors.New("unsupported encrypted value")
+	}
+	e.mu.RLock()
+	aead := e.keys[id]
+	e.mu.RUnlock()
+	if aead == nil {
+		return nil, fmt.Errorf("unknown data key %s", id)
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	return unseal(aead, data)
+}
+
+// == private ==
+
+// addKey - generates a new active data key, rewraps all keys with the current master key and stores the keyring
+func (e *envelope) addKey() error {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+	id := uuid.NewString()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	keys := make([]dataKey, 0, len(e.ring.Keys)+1)
+	for _, k := range e.ring.Keys {
+		if k.Provider != e.provider.ID() {
+			plain, err := e.unwrapWith(k)
+			if err != nil {
+				return err
+			}
+			if k.Wrapped, err = e.provider.Wrap(plain); err != nil {
+				return err
+			}
+			k.Provider = e.provider.ID()
+		}
+		keys = append(keys, k)
+	}
+	wrapped, err := e.provider.Wrap(key)
+	if err != nil {
+		return err
+	}
+	keys = append(keys, dataKey{ID: id, Provider: e.provider.ID(), Wrapped: wrapped})
+	ring := keyring{Active: id, Keys: keys}
+	if err = storeKeyring(ring); err != nil {
+		return err
+	}
+	e.ring = ring
+	e.keys[id] = aead
+	return nil
+}
+
+// load - unwraps a stored data key with the master key that wrapped it
+func (e *envelope) load(p KeyProvider, k dataKey) error {
+	plain, err := p.Unwrap(k.Wrapped)
+	if err != nil {
+		return err
+	}
+	aead, err := newAEAD(plain)
+	if err != nil {
+		return err
+	}
+	e.keys[k.ID] = aead
+	e.ring.Keys = append(e.ring.Keys, k)
+	return nil
+}
+
+// unwrapWith - unwraps a data key wrapped by a previous master key, which must be configured
+func (e *envelope) unwrapWith(k dataKey) ([]byte, error) {
+	prevURI := servercfg.GetPrevEncryptionKeyURI()
+	if prevURI == "" {
+		return nil, fmt.Errorf("data key %s is wrapped by %s, set PREV_ENCRYPTION_KEY_URI", k.ID, k.Provider)
+	}
+	prev, err := OpenProvider(prevURI)
+	if err != nil {
+		return nil, err
+	}
+	if prev.ID() != k.Provider {
+		return nil, fmt.Errorf("data key %s is not wrapped by the previous master key", k.ID)
+	}
+	return prev.Unwrap(k.Wrapped)
+}
+
+// encryptAll - rewrites every record with sensitive fields so they are sealed with the active data key
+func encryptAll() (int, error) {
+	count := 0
+	for _, table := range database.EncryptedTables() {
+		records, err := database.FetchRecords(table)
+		if err != nil {
+			if database.IsEmptyRecord(err) {
+				continue
+			}
+			return count, err
+		}
+		for key, value := range records {
+			if key == keyringKey {
+				continue
+			}
+			// the fetched values are decrypted, inserting them seals them again with the active key
+			if err = database.Insert(key, value, table); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	return count, nil
+}
+
+func fetchKeyring() (keyring, error) {
+	var ring keyring
+	record, err := database.FetchRecord(database.SERVERCONF_TABLE_NAME, keyringKey)
+	if err != nil {
+		return ring, err
+	}
+	err = json.Unmarshal([]byte(record), &ring)
+	return ring, err
+}
+
+func storeKeyring(ring keyring) error {
+	data, err := json.Marshal(&ring)
+	if err != nil {
+		return err
+	}
+	return database.Insert(keyringKey, string(data), database.SERVERCONF_TABLE_NAME)
+}
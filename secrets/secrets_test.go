@@ -0,0 +1,43 @@
+package secrets
+
+import (
+	"crypto/cipher"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvelope(t *testing.T) {
+	provider, err := OpenProvider("base64:" + base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	assert.Nil(t, err)
+	t.Run("WrapUnwrap", func(t *testing.T) {
+		key := []byte("0123456789abcdef0123456789abcdef")
+		wrapped, err := provider.Wrap(key)
+		assert.Nil(t, err)
+		assert.NotEqual(t, key, wrapped)
+		unwrapped, err := provider.Unwrap(wrapped)
+		assert.Nil(t, err)
+		assert.Equal(t, key, unwrapped)
+	})
+	t.Run("EncryptDecrypt", func(t *testing.T) {
+		aead, err := newAEAD(make([]byte, 32))
+		assert.Nil(t, err)
+		env := &envelope{provider: provider, ring: keyring{Active: "k1"}, keys: map[string]cipher.AEAD{"k1": aead}}
+		sealed, err := env.Encrypt([]byte(`"secret"`))
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(sealed, sealedPrefix+"k1:"))
+		plain, err := env.Decrypt(sealed)
+		assert.Nil(t, err)
+		assert.Equal(t, `"secret"`, string(plain))
+		_, err = env.Decrypt(sealedPrefix + "k2:" + strings.Split(sealed, ":")[3])
+		assert.NotNil(t, err)
+	})
+	t.Run("InvalidURI", func(t *testing.T) {
+		_, err := OpenProvider("kms://unknown")
+		assert.NotNil(t, err)
+		_, err = OpenProvider("base64:c2hvcnQ=")
+		assert.NotNil(t, err)
+	})
+}
@@ -3,8 +3,10 @@ package servercfg
 import (
 	"errors"
 	"io"
+	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -21,6 +23,8 @@ var (
 	Version              = "dev"
 	Is_EE                = false
 	ErrLicenseValidation error
+	// secretOpener - decrypts configured secrets stored in their encrypted form, set once encryption is initialized
+	secretOpener func(string) (string, error)
 )
 
 // SetHost - sets the host ip
@@ -372,6 +376,21 @@ func IsMetricsExporter() bool {
 	return export
 }
 
+// IsProfilingEnabled - checks if the pprof and runtime debug endpoints are on or off
+func IsProfilingEnabled() bool {
+	profiling := false
+	if os.Getenv("PROFILING") != "" {
+		if os.Getenv("PROFILING") == "on" {
+			profiling = true
+		}
+	} else if config.Config.Server.Profiling != "" {
+		if config.Config.Server.Profiling == "on" {
+			profiling = true
+		}
+	}
+	return profiling
+}
+
 // IsMessageQueueBackend - checks if message queue is on or off
 func IsMessageQueueBackend() bool {
 	ismessagequeue := true
@@ -389,6 +408,9 @@ func IsMessageQueueBackend() bool {
 
 // Telemetry - checks if telemetry data should be sent
 func Telemetry() string {
+	if s := getSettings(); s != nil {
+		return s.Telemetry
+	}
 	telemetry := "on"
 	if os.Getenv("TELEMETRY") == "off" {
 		telemetry = "off"
@@ -427,9 +449,29 @@ func GetVerbosity() int32 {
 	return int32(verbosity)
 }
 
+// IsJSONLogFormat - checks if logs should be written as JSON (default) or plain text
+func IsJSONLogFormat() bool {
+	format := os.Getenv("LOG_FORMAT")
+	if format == "" {
+		format = config.Config.Server.LogFormat
+	}
+	return format != "text"
+}
+
+// GetLogLevels - gets the initial per component log levels, eg. "mq=debug,logic=warn"
+func GetLogLevels() string {
+	if levels := os.Getenv("LOG_LEVELS"); levels != "" {
+		return levels
+	}
+	return config.Config.Server.LogLevels
+}
+
 // AutoUpdateEnabled returns a boolean indicating whether netclient auto update is enabled or disabled
 // default is enabled
 func AutoUpdateEnabled() bool {
+	if s := getSettings(); s != nil {
+		return s.NetclientAutoUpdate
+	}
 	if os.Getenv("NETCLIENT_AUTO_UPDATE") == "disabled" {
 		return false
 	} else if config.Config.Server.NetclientAutoUpdate == "disabled" {
@@ -558,6 +600,284 @@ func GetSQLConn() string {
 	return sqlconn
 }
 
+// GetEtcdEndpoints - gets the etcd endpoints to use when DATABASE is etcd
+func GetEtcdEndpoints() []string {
+	endpoints := "http://127.0.0.1:2379"
+	if os.Getenv("ETCD_ENDPOINTS") != "" {
+		endpoints = os.Getenv("ETCD_ENDPOINTS")
+	} else if config.Config.Server.EtcdEndpoints != "" {
+		endpoints = config.Config.Server.EtcdEndpoints
+	}
+	return strings.Split(endpoints, ",")
+}
+
+// GetEtcdCredentials - gets the username and password used to authenticate with etcd, if any
+func GetEtcdCredentials() (string, string) {
+	username, password := os.Getenv("ETCD_USERNAME"), os.Getenv("ETCD_PASSWORD")
+	if username == "" {
+		username, password = config.Config.Server.EtcdUsername, config.Config.Server.EtcdPassword
+	}
+	return username, password
+}
+
+// GetEncryptionKeyURI - gets the uri of the master key used to encrypt sensitive data at rest,
+// eg. base64:<key>, file:///path/to/key or vault://host:8200/transit/netmaker
+func GetEncryptionKeyURI() string {
+	if os.Getenv("ENCRYPTION_KEY_URI") != "" {
+		return os.Getenv("ENCRYPTION_KEY_URI")
+	}
+	return config.Config.Server.EncryptionKeyURI
+}
+
+// GetPrevEncryptionKeyURI - gets the uri of the previous master key, used while rotating master keys
+func GetPrevEncryptionKeyURI() string {
+	if os.Getenv("PREV_ENCRYPTION_KEY_URI") != "" {
+		return os.Getenv("PREV_ENCRYPTION_KEY_URI")
+	}
+	return config.Config.Server.PrevEncryptionKeyURI
+}
+
+// IsACMEEnabled - checks if certificates should be issued through ACME, enabled by setting an ACME email
+func IsACMEEnabled() bool {
+	return GetACMEEmail() != ""
+}
+
+// GetACMEEmail - gets the email of the ACME account
+func GetACMEEmail() string {
+	if os.Getenv("ACME_EMAIL") != "" {
+		return os.Getenv("ACME_EMAIL")
+	}
+	return config.Config.Server.ACMEEmail
+}
+
+// GetACMEDomains - gets the domains to issue certificates for,
+// defaults to the hosts of the api and broker endpoints
+func GetACMEDomains() []string {
+	domains := os.Getenv("ACME_DOMAINS")
+	if domains == "" {
+		domains = config.Config.Server.ACMEDomains
+	}
+	if domains != "" {
+		return strings.Split(domains, ",")
+	}
+	var defaults []string
+	for _, endpoint := range []string{GetAPIConnString(), GetPublicBrokerEndpoint()} {
+		if _, host, found := strings.Cut(endpoint, "://"); found {
+			endpoint = host
+		}
+		host := strings.Split(endpoint, ":")[0]
+		if host != "" && net.ParseIP(host) == nil {
+			defaults = append(defaults, host)
+		}
+	}
+	return defaults
+}
+
+// GetACMEChallenge - gets the ACME challenge type to use, http-01 (default) or dns-01
+func GetACMEChallenge() string {
+	challenge := "http-01"
+	if os.Getenv("ACME_CHALLENGE") != "" {
+		challenge = os.Getenv("ACME_CHALLENGE")
+	} else if config.Config.Server.ACMEChallenge != "" {
+		challenge = config.Config.Server.ACMEChallenge
+	}
+	return challenge
+}
+
+// GetACMEDNSProvider - gets the name of the DNS provider used for dns-01 challenges, eg. cloudflare or route53,
+// the provider's credentials are read from its own environment variables
+func GetACMEDNSProvider() string {
+	if os.Getenv("ACME_DNS_PROVIDER") != "" {
+		return os.Getenv("ACME_DNS_PROVIDER")
+	}
+	return config.Config.Server.ACMEDNSProvider
+}
+
+// GetACMECADirectory - gets the directory url of the ACME CA, defaults to Let's Encrypt
+func GetACMECADirectory() string {
+	directory := "https://acme-v02.api.letsencrypt.org/directory"
+	if os.Getenv("ACME_CA_DIRECTORY") != "" {
+		directory = os.Getenv("ACME_CA_DIRECTORY")
+	} else if config.Config.Server.ACMECADirectory != "" {
+		directory = config.Config.Server.ACMECADirectory
+	}
+	return directory
+}
+
+// GetACMECertDir - gets the directory issued certificates are written to, eg. for the broker to use
+func GetACMECertDir() string {
+	dir := "/etc/netmaker/certs"
+	if os.Getenv("ACME_CERT_DIR") != "" {
+		dir = os.Getenv("ACME_CERT_DIR")
+	} else if config.Config.Server.ACMECertDir != "" {
+		dir = config.Config.Server.ACMECertDir
+	}
+	return dir
+}
+
+// GetMigrationBackupDir - gets the directory the database is backed up to before migrations are applied
+func GetMigrationBackupDir() string {
+	dir := filepath.Join("data", "backups")
+	if os.Getenv("MIGRATION_BACKUP_DIR") != "" {
+		dir = os.Getenv("MIGRATION_BACKUP_DIR")
+	} else if config.Config.Server.MigrationBackupDir != "" {
+		dir = config.Config.Server.MigrationBackupDir
+	}
+	return dir
+}
+
+// GetExtensionsDir - gets the directory of Go plugins loaded as server extensions, empty when none are
+func GetExtensionsDir() string {
+	dir := ""
+	if os.Getenv("EXTENSIONS_DIR") != "" {
+		dir = os.Getenv("EXTENSIONS_DIR")
+	} else if config.Config.Server.ExtensionsDir != "" {
+		dir = config.Config.Server.ExtensionsDir
+	}
+	return dir
+}
+
+// GetJobIntervals - gets how often the background jobs of the names set run instead of their defaults,
+// entries that aren't name=duration are ignored
+func GetJobIntervals() map[string]time.Duration {
+	list := config.Config.Server.JobIntervals
+	if os.Getenv("JOB_INTERVALS") != "" {
+		list = os.Getenv("JOB_INTERVALS")
+	}
+	intervals := map[string]time.Duration{}
+	for _, entry := range splitList(list) {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		if interval, err := time.ParseDuration(strings.TrimSpace(value)); err == nil && interval > 0 {
+			intervals[strings.TrimSpace(name)] = interval
+		}
+	}
+	return intervals
+}
+
+// IsDriftAutoCorrect - checks if hosts whose applied config drifted are asked to pull it again
+func IsDriftAutoCorrect() bool {
+	value := os.Getenv("DRIFT_AUTO_CORRECT")
+	if value == "" {
+		value = config.Config.Server.DriftAutoCorrect
+	}
+	return value == "on"
+}
+
+// GetVaultToken - gets the token used to authenticate with a vault master key
+func GetVaultToken() string {
+	return os.Getenv("VAULT_TOKEN")
+}
+
+// GetShutdownGracePeriod - gets how long the server has to drain requests and updates on shutdown
+func GetShutdownGracePeriod() time.Duration {
+	seconds := 30
+	if os.Getenv("SHUTDOWN_GRACE_PERIOD") != "" {
+		if value, err := strconv.Atoi(os.Getenv("SHUTDOWN_GRACE_PERIOD")); err == nil && value > 0 {
+			seconds = value
+		}
+	} else if config.Config.Server.ShutdownGracePeriod > 0 {
+		seconds = config.Config.Server.ShutdownGracePeriod
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// GetShutdownDrainDelay - gets how long the server keeps serving after it stops reporting ready on shutdown,
+// so load balancers take it out of rotation before it refuses connections, 0 stops serving at once
+func GetShutdownDrainDelay() time.Duration {
+	seconds := 5
+	if os.Getenv("SHUTDOWN_DRAIN_DELAY") != "" {
+		if value, err := strconv.Atoi(os.Getenv("SHUTDOWN_DRAIN_DELAY")); err == nil && value >= 0 {
+			seconds = value
+		}
+	} else if config.Config.Server.ShutdownDrainDelay > 0 {
+		seconds = config.Config.Server.ShutdownDrainDelay
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// GetPublishWorkers - gets how many peer updates are published to the broker at once
+func GetPublishWorkers() int {
+	workers := 10
+	if os.Getenv("PUBLISH_WORKERS") != "" {
+		if value, err := strconv.Atoi(os.Getenv("PUBLISH_WORKERS")); err == nil && value > 0 {
+			workers = value
+		}
+	} else if config.Config.Server.PublishWorkers > 0 {
+		workers = config.Config.Server.PublishWorkers
+	}
+	return workers
+}
+
+// GetMQSharedGroup - gets the shared subscription group server instances consume host messages through,
+// empty when a single instance consumes them all
+func GetMQSharedGroup() string {
+	if os.Getenv("MQ_SHARED_GROUP") != "" {
+		return os.Getenv("MQ_SHARED_GROUP")
+	}
+	return config.Config.Server.MQSharedGroup
+}
+
+// GetMQPartition - gets which of how many partitions of hosts and nodes this instance handles
+// messages for, 0 of 1 when it handles them all
+func GetMQPartition() (partition, partitions int) {
+	partitions = config.Config.Server.MQPartitions
+	partition = config.Config.Server.MQPartition
+	if os.Getenv("MQ_PARTITIONS") != "" {
+		if value, err := strconv.Atoi(os.Getenv("MQ_PARTITIONS")); err == nil {
+			partitions = value
+		}
+	}
+	if os.Getenv("MQ_PARTITION") != "" {
+		if value, err := strconv.Atoi(os.Getenv("MQ_PARTITION")); err == nil {
+			partition = value
+		}
+	}
+	if partitions < 1 || partition < 0 || partition >= partitions {
+		return 0, 1
+	}
+	return partition, partitions
+}
+
+// GetCloudEnrollmentAudience - gets the audience cloud identity tokens must be issued for,
+// defaults to the server's api endpoint
+func GetCloudEnrollmentAudience() string {
+	if os.Getenv("CLOUD_ENROLLMENT_AUDIENCE") != "" {
+		return os.Getenv("CLOUD_ENROLLMENT_AUDIENCE")
+	} else if config.Config.Server.CloudEnrollmentAudience != "" {
+		return config.Config.Server.CloudEnrollmentAudience
+	}
+	return "https://" + GetAPIConnString()
+}
+
+// GetAWSIdentityCertFile - gets the path of the pem file holding the AWS certificates
+// that instance identity documents are verified against
+func GetAWSIdentityCertFile() string {
+	if os.Getenv("AWS_IDENTITY_CERT_FILE") != "" {
+		return os.Getenv("AWS_IDENTITY_CERT_FILE")
+	}
+	return config.Config.Server.AWSIdentityCertFile
+}
+
+// SetSecretOpener - sets the function used to decrypt secrets configured in their encrypted form
+func SetSecretOpener(opener func(string) (string, error)) {
+	secretOpener = opener
+}
+
+// openSecret - decrypts a configured secret if it is encrypted, otherwise returns it as is
+func openSecret(value string) string {
+	if secretOpener == nil || value == "" {
+		return value
+	}
+	plain, err := secretOpener(value)
+	if err != nil {
+		return value
+	}
+	return plain
+}
+
 // GetNodeID - gets the node id
 func GetNodeID() string {
 	var id string
@@ -582,6 +902,9 @@ func SetNodeID(id string) {
 
 // GetAuthProviderInfo = gets the oauth provider info
 func GetAuthProviderInfo() (pi []string) {
+	if s := getSettings(); s != nil {
+		return settingsAuthProviderInfo(s.OAuth)
+	}
 	var authProvider = ""
 
 	defer func() {
@@ -614,6 +937,9 @@ func GetAuthProviderInfo() (pi []string) {
 
 // GetAzureTenant - retrieve the azure tenant ID from env variable or config file
 func GetAzureTenant() string {
+	if s := getSettings(); s != nil {
+		return s.OAuth.AzureTenant
+	}
 	var azureTenant = ""
 	if os.Getenv("AZURE_TENANT") != "" {
 		azureTenant = os.Getenv("AZURE_TENANT")
@@ -631,7 +957,7 @@ func GetMqPassword() string {
 	} else if config.Config.Server.MQPassword != "" {
 		password = config.Config.Server.MQPassword
 	}
-	return password
+	return openSecret(password)
 }
 
 // GetMqUserName - fetches the MQ username
@@ -661,6 +987,136 @@ func IsBasicAuthEnabled() bool {
 	return enabled
 }
 
+// IsRecoveryAccessEnabled - checks if a recovery token may be used to create or reset a superadmin,
+// off unless turned on
+func IsRecoveryAccessEnabled() bool {
+	recovery := false
+	if os.Getenv("RECOVERY_ACCESS") != "" {
+		if os.Getenv("RECOVERY_ACCESS") == "on" {
+			recovery = true
+		}
+	} else if config.Config.Server.RecoveryAccess != "" {
+		if config.Config.Server.RecoveryAccess == "on" {
+			recovery = true
+		}
+	}
+	return recovery
+}
+
+// IsOPABreakGlassEnabled - checks if super admins may make API requests while the Open Policy Agent endpoint
+// can't be reached and fails closed, off unless turned on
+func IsOPABreakGlassEnabled() bool {
+	breakGlass := false
+	if os.Getenv("OPA_BREAK_GLASS") != "" {
+		if os.Getenv("OPA_BREAK_GLASS") == "on" {
+			breakGlass = true
+		}
+	} else if config.Config.Server.OPABreakGlass != "" {
+		if config.Config.Server.OPABreakGlass == "on" {
+			breakGlass = true
+		}
+	}
+	return breakGlass
+}
+
+// IsChangeApprovalEnabled - checks if sensitive operations need a second admin to approve them
+func IsChangeApprovalEnabled() bool {
+	var enabled = false //default
+	if os.Getenv("CHANGE_APPROVAL") != "" {
+		enabled = os.Getenv("CHANGE_APPROVAL") == "on"
+	} else if config.Config.Server.ChangeApproval != "" {
+		enabled = config.Config.Server.ChangeApproval == "on"
+	}
+	return enabled
+}
+
+// GetChangeApprovalExpiry - gets how long a sensitive operation waits for approval before it expires
+func GetChangeApprovalExpiry() time.Duration {
+	hours := 24
+	if os.Getenv("CHANGE_APPROVAL_EXPIRY") != "" {
+		if value, err := strconv.Atoi(os.Getenv("CHANGE_APPROVAL_EXPIRY")); err == nil && value > 0 {
+			hours = value
+		}
+	} else if config.Config.Server.ChangeApprovalExpiry > 0 {
+		hours = config.Config.Server.ChangeApprovalExpiry
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// GetTrashRetention - gets how many days deleted networks, nodes and ext clients can be restored for,
+// 0 deletes them right away
+func GetTrashRetention() time.Duration {
+	days := 7
+	retention := os.Getenv("TRASH_RETENTION")
+	if retention == "" {
+		retention = config.Config.Server.TrashRetention
+	}
+	if value, err := strconv.Atoi(retention); err == nil && value >= 0 {
+		days = value
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// GetRACClientTTL - gets how many hours the ext client config of a remote access client lasts before it must be renewed
+func GetRACClientTTL() time.Duration {
+	hours := 24
+	ttl := os.Getenv("RAC_CLIENT_TTL")
+	if ttl == "" {
+		ttl = config.Config.Server.RACClientTTL
+	}
+	if value, err := strconv.Atoi(ttl); err == nil && value > 0 {
+		hours = value
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// GetExtClientKeyTTL - gets how many hours the keys of user owned ext clients stay valid before they must be renewed,
+// zero when they never expire
+func GetExtClientKeyTTL() time.Duration {
+	ttl := os.Getenv("EXTCLIENT_KEY_TTL")
+	if ttl == "" {
+		ttl = config.Config.Server.ExtClientKeyTTL
+	}
+	hours, err := strconv.Atoi(ttl)
+	if err != nil || hours < 0 {
+		return 0
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// GetGeoIPFile - gets the csv of cidr,country code lines source countries are looked up in
+func GetGeoIPFile() string {
+	if file := os.Getenv("GEOIP_FILE"); file != "" {
+		return file
+	}
+	return config.Config.Server.GeoIPFile
+}
+
+// GetSecurityAlertWebhook - gets the url security alerts are posted to
+func GetSecurityAlertWebhook() string {
+	if url := os.Getenv("SECURITY_ALERT_WEBHOOK"); url != "" {
+		return url
+	}
+	return config.Config.Server.SecurityAlertWebhook
+}
+
+// IsHostCertAuth - checks if hosts authenticate with client certificates instead of auth tokens
+func IsHostCertAuth() bool {
+	mode := os.Getenv("HOST_AUTH_MODE")
+	if mode == "" {
+		mode = config.Config.Server.HostAuthMode
+	}
+	return mode == "certificate"
+}
+
+// GetHostCertHeader - gets the header a TLS terminating proxy forwards host client certificates in, empty when none
+func GetHostCertHeader() string {
+	if header := os.Getenv("HOST_CERT_HEADER"); header != "" {
+		return header
+	}
+	return config.Config.Server.HostCertHeader
+}
+
 // GetLicenseKey - retrieves pro license value from env or conf files
 func GetLicenseKey() string {
 	licenseKeyValue := os.Getenv("LICENSE_KEY")
@@ -796,6 +1252,60 @@ func GetEnvironment() string {
 	return ""
 }
 
+// GetFlowCollector - gets the address (host:port) of the NetFlow/IPFIX collector gateways export flows to
+func GetFlowCollector() string {
+	if collector := os.Getenv("FLOW_COLLECTOR"); collector != "" {
+		return collector
+	}
+	return config.Config.Server.FlowCollector
+}
+
+// GetFlowExportProtocol - gets the protocol gateways use to export flows (netflow or ipfix)
+func GetFlowExportProtocol() string {
+	protocol := os.Getenv("FLOW_EXPORT_PROTOCOL")
+	if protocol == "" {
+		protocol = config.Config.Server.FlowExportProtocol
+	}
+	if protocol != models.FlowProtocolNetflow {
+		protocol = models.FlowProtocolIPFIX
+	}
+	return protocol
+}
+
+// GetOTLPEndpoint - gets the address (host:port) of the OTLP collector traces are exported to
+func GetOTLPEndpoint() string {
+	if endpoint := os.Getenv("OTLP_ENDPOINT"); endpoint != "" {
+		return endpoint
+	}
+	return config.Config.Server.OTLPEndpoint
+}
+
+// IsTracingEnabled - checks if traces should be exported
+func IsTracingEnabled() bool {
+	return GetOTLPEndpoint() != ""
+}
+
+// IsOTLPInsecure - checks if the OTLP exporter should skip TLS
+func IsOTLPInsecure() bool {
+	if os.Getenv("OTLP_INSECURE") != "" {
+		return os.Getenv("OTLP_INSECURE") == "true"
+	}
+	return config.Config.Server.OTLPInsecure
+}
+
+// GetTracingSampleRatio - gets the fraction of traces to sample, defaults to all
+func GetTracingSampleRatio() float64 {
+	ratio := os.Getenv("TRACING_SAMPLE_RATIO")
+	if ratio == "" {
+		ratio = config.Config.Server.TracingSampleRatio
+	}
+	value, err := strconv.ParseFloat(ratio, 64)
+	if err != nil || value <= 0 || value > 1 {
+		return 1
+	}
+	return value
+}
+
 // parseStunList - turn string into slice of StunServers
 func parseStunList(stunString string) ([]models.StunServer, error) {
 	var err error
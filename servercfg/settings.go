@@ -0,0 +1,282 @@
+package servercfg
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gravitl/netmaker/config"
+	"github.com/gravitl/netmaker/models"
+)
+
+var (
+	settingsMutex sync.RWMutex
+	// settings - values changed at runtime, which take precedence over the config file and environment
+	settings *models.ServerSettings
+)
+
+// SetSettings - applies settings changed at runtime, nil reverts to the config file and environment
+func SetSettings(s *models.ServerSettings) {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+	if s == nil {
+		settings = nil
+		return
+	}
+	copied := *s
+	settings = &copied
+}
+
+// HasSettings - checks if settings changed at runtime replace those of the config file and environment
+func HasSettings() bool {
+	return getSettings() != nil
+}
+
+// GetSettings - returns the effective values of the settings that can be changed at runtime
+func GetSettings() models.ServerSettings {
+	authInfo := GetAuthProviderInfo()
+	s := models.ServerSettings{
+		Telemetry:           Telemetry(),
+		NetclientAutoUpdate: AutoUpdateEnabled(),
+		DefaultMTU:          GetDefaultMTU(),
+		RateLimit:           GetRateLimit(),
+		SMTP:                GetSMTPSettings(),
+		SIEM:                GetSIEMSettings(),
+		APIAllowlist:        GetAPIAllowlist(),
+		CORS:                GetCORSSettings(),
+		SecurityHeaders:     GetSecurityHeaders(),
+		LoginNotifications:  IsLoginNotificationEnabled(),
+		EmailVerification:   IsEmailVerificationEnabled(),
+		OPA:                 GetOPASettings(),
+		OAuth: models.OAuthSettings{
+			Provider:     authInfo[0],
+			ClientID:     authInfo[1],
+			ClientSecret: authInfo[2],
+			AzureTenant:  GetAzureTenant(),
+		},
+	}
+	if len(authInfo) > 3 {
+		s.OAuth.OIDCIssuer = authInfo[3]
+	}
+	return s
+}
+
+// GetDefaultMTU - gets the MTU given to hosts and ext clients that don't set their own
+func GetDefaultMTU() int {
+	if s := getSettings(); s != nil {
+		return s.DefaultMTU
+	}
+	mtu := 1420
+	if os.Getenv("DEFAULT_MTU") != "" {
+		if value, err := strconv.Atoi(os.Getenv("DEFAULT_MTU")); err == nil {
+			mtu = value
+		}
+	} else if config.Config.Server.DefaultMTU != 0 {
+		mtu = config.Config.Server.DefaultMTU
+	}
+	return mtu
+}
+
+// GetRateLimit - gets the number of API requests per second allowed per client, 0 disables rate limiting
+func GetRateLimit() models.RateLimitSettings {
+	if s := getSettings(); s != nil {
+		return s.RateLimit
+	}
+	var limit models.RateLimitSettings
+	rate := os.Getenv("RATE_LIMIT")
+	if rate == "" {
+		rate = config.Config.Server.RateLimit
+	}
+	limit.RequestsPerSecond, _ = strconv.ParseFloat(rate, 64)
+	limit.Burst = config.Config.Server.RateLimitBurst
+	if os.Getenv("RATE_LIMIT_BURST") != "" {
+		limit.Burst, _ = strconv.Atoi(os.Getenv("RATE_LIMIT_BURST"))
+	}
+	if limit.Burst == 0 {
+		limit.Burst = int(limit.RequestsPerSecond) * 2
+	}
+	return limit
+}
+
+// GetSMTPSettings - gets the mail server used to send emails
+func GetSMTPSettings() models.SMTPSettings {
+	if s := getSettings(); s != nil {
+		return s.SMTP
+	}
+	smtp := models.SMTPSettings{
+		Host:     config.Config.Server.SMTPHost,
+		Port:     config.Config.Server.SMTPPort,
+		Username: config.Config.Server.SMTPUsername,
+		Password: config.Config.Server.SMTPPassword,
+		From:     config.Config.Server.SMTPFrom,
+	}
+	if os.Getenv("SMTP_HOST") != "" {
+		smtp.Host = os.Getenv("SMTP_HOST")
+		smtp.Port, _ = strconv.Atoi(os.Getenv("SMTP_PORT"))
+		smtp.Username = os.Getenv("SMTP_USERNAME")
+		smtp.Password = os.Getenv("SMTP_PASSWORD")
+		smtp.From = os.Getenv("SMTP_FROM")
+	}
+	smtp.Password = openSecret(smtp.Password)
+	return smtp
+}
+
+// GetSIEMSettings - gets the syslog endpoint audit and authentication events are shipped to
+func GetSIEMSettings() models.SIEMSettings {
+	if s := getSettings(); s != nil {
+		return s.SIEM
+	}
+	siem := models.SIEMSettings{
+		Address:  config.Config.Server.SIEMAddress,
+		Protocol: config.Config.Server.SIEMProtocol,
+		Format:   config.Config.Server.SIEMFormat,
+		CACert:   config.Config.Server.SIEMCACert,
+	}
+	if os.Getenv("SIEM_ADDRESS") != "" {
+		siem.Address = os.Getenv("SIEM_ADDRESS")
+		siem.Protocol = os.Getenv("SIEM_PROTOCOL")
+		siem.Format = os.Getenv("SIEM_FORMAT")
+		siem.CACert = os.Getenv("SIEM_CA_CERT")
+	}
+	return siem
+}
+
+// GetAPIAllowlist - gets the source cidrs users may use the management api from, empty allows any
+func GetAPIAllowlist() []string {
+	if s := getSettings(); s != nil {
+		return s.APIAllowlist
+	}
+	allowlist := os.Getenv("API_ALLOWLIST")
+	if allowlist == "" {
+		allowlist = config.Config.Server.APIAllowlist
+	}
+	return splitList(allowlist)
+}
+
+// GetTrustedProxies - gets the cidrs of the reverse proxies whose X-Forwarded-For is trusted, empty trusts none
+func GetTrustedProxies() []string {
+	proxies := os.Getenv("TRUSTED_PROXIES")
+	if proxies == "" {
+		proxies = config.Config.Server.TrustedProxies
+	}
+	return splitList(proxies)
+}
+
+// GetCORSSettings - gets the origins, headers and methods browsers may use to call the API
+func GetCORSSettings() models.CORSSettings {
+	var cors models.CORSSettings
+	if s := getSettings(); s != nil {
+		cors = s.CORS
+	}
+	if len(cors.AllowedOrigins) == 0 {
+		cors.AllowedOrigins = splitList(GetAllowedOrigin())
+	}
+	if len(cors.AllowedHeaders) == 0 {
+		cors.AllowedHeaders = []string{"Access-Control-Allow-Origin", "X-Requested-With", "Content-Type", "authorization", "X-Request-ID", "X-Act-As"}
+	}
+	if len(cors.AllowedMethods) == 0 {
+		cors.AllowedMethods = []string{"GET", "PUT", "POST", "DELETE"}
+	}
+	return cors
+}
+
+// GetSecurityHeaders - gets the security headers set on API responses, which by default
+// forbid loading anything from a response and embedding it in a frame
+func GetSecurityHeaders() models.SecurityHeaders {
+	var headers models.SecurityHeaders
+	if s := getSettings(); s != nil {
+		headers = s.SecurityHeaders
+	}
+	if headers.ContentSecurityPolicy == "" {
+		headers.ContentSecurityPolicy = "default-src 'none'"
+		if os.Getenv("CONTENT_SECURITY_POLICY") != "" {
+			headers.ContentSecurityPolicy = os.Getenv("CONTENT_SECURITY_POLICY")
+		} else if config.Config.Server.ContentSecurityPolicy != "" {
+			headers.ContentSecurityPolicy = config.Config.Server.ContentSecurityPolicy
+		}
+	}
+	if len(headers.FrameAncestors) == 0 {
+		ancestors := os.Getenv("FRAME_ANCESTORS")
+		if ancestors == "" {
+			ancestors = config.Config.Server.FrameAncestors
+		}
+		headers.FrameAncestors = splitList(ancestors)
+	}
+	if headers.ReferrerPolicy == "" {
+		headers.ReferrerPolicy = "no-referrer"
+	}
+	return headers
+}
+
+// IsLoginNotificationEnabled - checks if users are emailed about logins from new devices and new ext clients
+func IsLoginNotificationEnabled() bool {
+	if s := getSettings(); s != nil {
+		return s.LoginNotifications
+	}
+	if os.Getenv("LOGIN_NOTIFICATIONS") != "" {
+		return os.Getenv("LOGIN_NOTIFICATIONS") == "on"
+	}
+	return config.Config.Server.LoginNotifications == "on"
+}
+
+// IsEmailVerificationEnabled - checks if users created through the api stay pending until they verify their email
+func IsEmailVerificationEnabled() bool {
+	if s := getSettings(); s != nil {
+		return s.EmailVerification
+	}
+	if os.Getenv("EMAIL_VERIFICATION") != "" {
+		return os.Getenv("EMAIL_VERIFICATION") == "on"
+	}
+	return config.Config.Server.EmailVerification == "on"
+}
+
+// GetOPASettings - gets the Open Policy Agent endpoint consulted on API requests, unset when there is none
+func GetOPASettings() models.OPASettings {
+	if s := getSettings(); s != nil {
+		return s.OPA
+	}
+	opa := models.OPASettings{
+		URL:      config.Config.Server.OPAURL,
+		Token:    config.Config.Server.OPAToken,
+		FailOpen: config.Config.Server.OPAFailOpen == "on",
+	}
+	if os.Getenv("OPA_URL") != "" {
+		opa.URL = os.Getenv("OPA_URL")
+		opa.Token = os.Getenv("OPA_TOKEN")
+		opa.FailOpen = os.Getenv("OPA_FAIL_OPEN") == "on"
+	}
+	opa.Token = openSecret(opa.Token)
+	return opa
+}
+
+// == private ==
+
+// splitList - the non empty entries of a comma separated list
+func splitList(list string) []string {
+	entries := []string{}
+	for _, entry := range strings.Split(list, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// settingsAuthProviderInfo - formats the oauth settings like GetAuthProviderInfo
+func settingsAuthProviderInfo(oauth models.OAuthSettings) []string {
+	if oauth.Provider == "" || oauth.ClientID == "" || oauth.ClientSecret == "" {
+		return []string{"", "", ""}
+	}
+	info := []string{oauth.Provider, oauth.ClientID, oauth.ClientSecret}
+	if oauth.Provider == "oidc" {
+		info = append(info, oauth.OIDCIssuer)
+	}
+	return info
+}
+
+func getSettings() *models.ServerSettings {
+	settingsMutex.RLock()
+	defer settingsMutex.RUnlock()
+	return settings
+}
@@ -0,0 +1,367 @@
+package serverctl
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	ssl "crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/certificate"
+	"github.com/go-acme/lego/v4/challenge"
+	"github.com/go-acme/lego/v4/challenge/http01"
+	"github.com/go-acme/lego/v4/lego"
+	"github.com/go-acme/lego/v4/providers/dns/cloudflare"
+	"github.com/go-acme/lego/v4/providers/dns/digitalocean"
+	"github.com/go-acme/lego/v4/providers/dns/gcloud"
+	"github.com/go-acme/lego/v4/providers/dns/rfc2136"
+	"github.com/go-acme/lego/v4/providers/dns/route53"
+	"github.com/go-acme/lego/v4/registration"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/mq"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// ACME_CERT_NAME - name of the ACME certificate chain written to the cert dir
+	ACME_CERT_NAME = "fullchain.pem"
+	// ACME_KEY_NAME - name of the ACME certificate private key written to the cert dir
+	ACME_KEY_NAME = "privkey.pem"
+
+	acmeAccountRecord = "acme-account"
+	acmeCertRecord    = "acme-certificate"
+	// renew certificates when they expire within this period
+	acmeRenewBefore   = 30 * 24 * time.Hour
+	acmeCheckInterval = 12 * time.Hour
+	acmeRetryInterval = 10 * time.Minute
+)
+
+// acmeUser - the ACME account of the server, as stored in the certs table
+type acmeUser struct {
+	Email        string                 `json:"email"`
+	Registration *registration.Resource `json:"registration"`
+	Key          []byte                 `json:"key"`
+	privateKey   crypto.PrivateKey
+}
+
+func (u *acmeUser) GetEmail() string                        { return u.Email }
+func (u *acmeUser) GetRegistration() *registration.Resource { return u.Registration }
+func (u *acmeUser) GetPrivateKey() crypto.PrivateKey        { return u.privateKey }
+
+// acmeCertificate - an issued certificate, as stored in the certs table so all servers share it
+type acmeCertificate struct {
+	Domains     []string `json:"domains"`
+	CertURL     string   `json:"cert_url"`
+	Certificate []byte   `json:"certificate"`
+	PrivateKey  []byte   `json:"private_key"`
+}
+
+var (
+	acmeMutex  sync.RWMutex
+	acmeCert   *ssl.Certificate
+	acmeStatus models.CertificateStatus
+	// reloadBroker - makes the broker pick up a renewed certificate from the cert dir
+	reloadBroker = mq.ReloadBrokerCertificates
+)
+
+// acmeDNSProviders - the providers dns-01 challenges can be answered with, each one pulls in
+// its vendor's SDK so only these are built in rather than every provider lego knows
+var acmeDNSProviders = map[string]func() (challenge.Provider, error){
+	"cloudflare":   func() (challenge.Provider, error) { return cloudflare.NewDNSProvider() },
+	"digitalocean": func() (challenge.Provider, error) { return digitalocean.NewDNSProvider() },
+	"gcloud":       func() (challenge.Provider, error) { return gcloud.NewDNSProvider() },
+	"rfc2136":      func() (challenge.Provider, error) { return rfc2136.NewDNSProvider() },
+	"route53":      func() (challenge.Provider, error) { return route53.NewDNSProvider() },
+}
+
+// StartACME - issues a certificate for the server's domains through ACME and keeps renewing it until ctx is done
+func StartACME(ctx context.Context, wg *sync.WaitGroup) {
+	if !servercfg.IsACMEEnabled() {
+		return
+	}
+	acmeMutex.Lock()
+	acmeStatus = models.CertificateStatus{
+		Enabled:   true,
+		Domains:   servercfg.GetACMEDomains(),
+		Challenge: servercfg.GetACMEChallenge(),
+	}
+	acmeMutex.Unlock()
+	// the first certificate is issued before serving so the API doesn't start without one
+	err := ensureCertificate()
+	if err != nil {
+		slog.ErrorCtx(ctx, "failed to issue ACME certificate", "error", err)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			logic.WorkerHeartbeat("acme", acmeCheckInterval)
+			interval := acmeCheckInterval
+			if err != nil {
+				interval = acmeRetryInterval
+			}
+			select {
+			case <-ctx.Done():
+				logic.StopWorker("acme")
+				return
+			case <-time.After(interval):
+				if err = ensureCertificate(); err != nil {
+					slog.ErrorCtx(ctx, "failed to renew ACME certificate", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// GetACMECertificate - serves the ACME certificate during TLS handshakes
+func GetACMECertificate(*ssl.ClientHelloInfo) (*ssl.Certificate, error) {
+	acmeMutex.RLock()
+	defer acmeMutex.RUnlock()
+	if acmeCert == nil {
+		return nil, fmt.Errorf("no ACME certificate issued yet")
+	}
+	return acmeCert, nil
+}
+
+// GetCertificateStatus - returns the state of the server's ACME certificate
+func GetCertificateStatus() models.CertificateStatus {
+	acmeMutex.RLock()
+	defer acmeMutex.RUnlock()
+	return acmeStatus
+}
+
+// == private ==
+
+// ensureCertificate - loads the stored certificate, obtaining or renewing it first if needed
+func ensureCertificate() error {
+	domains := servercfg.GetACMEDomains()
+	if len(domains) == 0 {
+		return setACMEError(fmt.Errorf("no domains to issue a certificate for, set ACME_DOMAINS"))
+	}
+	stored, err := readACMECertificate()
+	if err != nil && !database.IsEmptyRecord(err) {
+		return setACMEError(err)
+	}
+	if stored != nil && sameDomains(stored.Domains, domains) {
+		leaf, err := certcrypto.ParsePEMCertificate(stored.Certificate)
+		if err == nil && time.Until(leaf.NotAfter) > acmeRenewBefore {
+			return installACMECertificate(stored, leaf, false)
+		}
+	}
+	client, err := newACMEClient()
+	if err != nil {
+		return setACMEError(err)
+	}
+	var resource *certificate.Resource
+	if stored != nil && sameDomains(stored.Domains, domains) {
+		resource, err = client.Certificate.Renew(certificate.Resource{
+			Domain:      domains[0],
+			CertURL:     stored.CertURL,
+			Certificate: stored.Certificate,
+			PrivateKey:  stored.PrivateKey,
+		}, true, false, "")
+	} else {
+		resource, err = client.Certificate.Obtain(certificate.ObtainRequest{Domains: domains, Bundle: true})
+	}
+	if err != nil {
+		return setACMEError(err)
+	}
+	issued := &acmeCertificate{
+		Domains:     domains,
+		CertURL:     resource.CertURL,
+		Certificate: resource.Certificate,
+		PrivateKey:  resource.PrivateKey,
+	}
+	leaf, err := certcrypto.ParsePEMCertificate(issued.Certificate)
+	if err != nil {
+		return setACMEError(err)
+	}
+	if err = saveACMERecord(acmeCertRecord, issued); err != nil {
+		return setACMEError(err)
+	}
+	slog.Info("issued ACME certificate", "domains", strings.Join(domains, ","), "not_after", leaf.NotAfter.String())
+	return installACMECertificate(issued, leaf, true)
+}
+
+// installACMECertificate - serves the certificate and writes it to the cert dir for the broker
+func installACMECertificate(c *acmeCertificate, leaf *x509.Certificate, renewed bool) error {
+	keyPair, err := ssl.X509KeyPair(c.Certificate, c.PrivateKey)
+	if err != nil {
+		return setACMEError(err)
+	}
+	dir := servercfg.GetACMECertDir()
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return setACMEError(err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, ACME_CERT_NAME), c.Certificate, 0644); err != nil {
+		return setACMEError(err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, ACME_KEY_NAME), c.PrivateKey, 0600); err != nil {
+		return setACMEError(err)
+	}
+	acmeMutex.Lock()
+	acmeCert = &keyPair
+	acmeStatus.Domains = c.Domains
+	acmeStatus.Issuer = leaf.Issuer.CommonName
+	acmeStatus.NotBefore = leaf.NotBefore
+	acmeStatus.NotAfter = leaf.NotAfter
+	acmeStatus.NextRenewal = leaf.NotAfter.Add(-acmeRenewBefore)
+	acmeStatus.LastError = ""
+	if renewed {
+		acmeStatus.LastRenewal = time.Now()
+	}
+	acmeMutex.Unlock()
+	if !renewed {
+		return nil
+	}
+	// the certificate is installed either way, the broker keeps serving the old one until reloaded
+	if err = reloadBroker(); err != nil {
+		slog.Error("failed to reload the broker with the renewed ACME certificate", "error", err)
+	}
+	return nil
+}
+
+// newACMEClient - creates a client for the configured CA and challenge, registering the account if needed
+func newACMEClient() (*lego.Client, error) {
+	user, err := readACMEUser()
+	if err != nil {
+		return nil, err
+	}
+	cfg := lego.NewConfig(user)
+	cfg.CADirURL = servercfg.GetACMECADirectory()
+	cfg.Certificate.KeyType = certcrypto.EC256
+	client, err := lego.NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	switch challenge := servercfg.GetACMEChallenge(); challenge {
+	case "http-01":
+		// challenges are answered on port 80, which must be reachable from the internet
+		if err = client.Challenge.SetHTTP01Provider(http01.NewProviderServer("", "")); err != nil {
+			return nil, err
+		}
+	case "dns-01":
+		provider, err := newACMEDNSProvider(servercfg.GetACMEDNSProvider())
+		if err != nil {
+			return nil, err
+		}
+		if err = client.Challenge.SetDNS01Provider(provider); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported ACME challenge %q, must be http-01 or dns-01", challenge)
+	}
+	if user.Registration == nil {
+		if user.Registration, err = client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true}); err != nil {
+			return nil, err
+		}
+		if err = saveACMERecord(acmeAccountRecord, user); err != nil {
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// readACMEUser - loads the ACME account, generating a new account key if there is none for the configured email
+func readACMEUser() (*acmeUser, error) {
+	var user acmeUser
+	record, err := database.FetchRecord(database.CERTS_TABLE_NAME, acmeAccountRecord)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err = json.Unmarshal([]byte(record), &user); err != nil {
+			return nil, err
+		}
+	}
+	if user.Email == servercfg.GetACMEEmail() && len(user.Key) > 0 {
+		if user.privateKey, err = certcrypto.ParsePEMPrivateKey(user.Key); err != nil {
+			return nil, err
+		}
+		return &user, nil
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return &acmeUser{
+		Email:      servercfg.GetACMEEmail(),
+		Key:        pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}),
+		privateKey: key,
+	}, nil
+}
+
+// newACMEDNSProvider - creates a built in dns-01 provider, configured from its own environment variables
+func newACMEDNSProvider(name string) (challenge.Provider, error) {
+	newProvider, ok := acmeDNSProviders[name]
+	if !ok {
+		supported := make([]string, 0, len(acmeDNSProviders))
+		for provider := range acmeDNSProviders {
+			supported = append(supported, provider)
+		}
+		sort.Strings(supported)
+		return nil, fmt.Errorf("unsupported ACME DNS provider %q, must be one of %s", name, strings.Join(supported, ", "))
+	}
+	return newProvider()
+}
+
+func readACMECertificate() (*acmeCertificate, error) {
+	record, err := database.FetchRecord(database.CERTS_TABLE_NAME, acmeCertRecord)
+	if err != nil {
+		return nil, err
+	}
+	var c acmeCertificate
+	if err = json.Unmarshal([]byte(record), &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func saveACMERecord(key string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return database.Insert(key, string(data), database.CERTS_TABLE_NAME)
+}
+
+func setACMEError(err error) error {
+	acmeMutex.Lock()
+	defer acmeMutex.Unlock()
+	acmeStatus.LastError = err.Error()
+	return err
+}
+
+func sameDomains(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = append([]string{}, a...), append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package serverctl
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewACMEDNSProvider(t *testing.T) {
+	_, err := newACMEDNSProvider("namecheap")
+	assert.ErrorContains(t, err, "unsupported ACME DNS provider")
+	assert.ErrorContains(t, err, "cloudflare, digitalocean, gcloud, rfc2136, route53")
+	// a built in provider is only missing its credentials
+	t.Setenv("CLOUDFLARE_DNS_API_TOKEN", "")
+	_, err = newACMEDNSProvider("cloudflare")
+	assert.NotNil(t, err)
+	assert.NotContains(t, err.Error(), "unsupported")
+}
+
+func TestInstallACMECertificate(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("ACME_CERT_DIR", dir)
+	defer func(reload func() error) { reloadBroker = reload }(reloadBroker)
+	reloads := 0
+	reloadBroker = func() error {
+		reloads++
+		return nil
+	}
+	issued, leaf := testACMECertificate(t)
+
+	t.Run("Loaded", func(t *testing.T) {
+		assert.Nil(t, installACMECertificate(issued, leaf, false))
+		assert.Equal(t, 0, reloads)
+		written, err := os.ReadFile(filepath.Join(dir, ACME_CERT_NAME))
+		assert.Nil(t, err)
+		assert.Equal(t, issued.Certificate, written)
+		info, err := os.Stat(filepath.Join(dir, ACME_KEY_NAME))
+		assert.Nil(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+		status := GetCertificateStatus()
+		assert.Equal(t, leaf.NotAfter.Add(-acmeRenewBefore), status.NextRenewal)
+		assert.True(t, status.LastRenewal.IsZero())
+		cert, err := GetACMECertificate(nil)
+		assert.Nil(t, err)
+		assert.NotNil(t, cert)
+	})
+	t.Run("Renewed", func(t *testing.T) {
+		assert.Nil(t, installACMECertificate(issued, leaf, true))
+		assert.Equal(t, 1, reloads)
+		assert.False(t, GetCertificateStatus().LastRenewal.IsZero())
+	})
+	t.Run("BadKeyPair", func(t *testing.T) {
+		other, _ := testACMECertificate(t)
+		mismatched := *issued
+		mismatched.PrivateKey = other.PrivateKey
+		assert.NotNil(t, installACMECertificate(&mismatched, leaf, true))
+		assert.Equal(t, 1, reloads)
+		assert.NotEmpty(t, GetCertificateStatus().LastError)
+	})
+}
+
+func TestSameDomains(t *testing.T) {
+	assert.True(t, sameDomains([]string{"a.example.com", "b.example.com"}, []string{"b.example.com", "a.example.com"}))
+	assert.False(t, sameDomains([]string{"a.example.com"}, []string{"a.example.com", "b.example.com"}))
+	assert.False(t, sameDomains([]string{"a.example.com"}, []string{"c.example.com"}))
+}
+
+// testACMECertificate - a self signed certificate standing in for one issued by a CA
+func testACMECertificate(t *testing.T) (*acmeCertificate, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "netmaker.example.com"},
+		DNSNames:     []string{"netmaker.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+	return &acmeCertificate{
+		Domains:     []string{"netmaker.example.com"},
+		Certificate: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		PrivateKey:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}, leaf
+}
@@ -4,11 +4,11 @@ import (
 	"strings"
 
 	"github.com/gravitl/netmaker/database"
-	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/logic/acls"
 	"github.com/gravitl/netmaker/logic/acls/nodeacls"
 	"github.com/gravitl/netmaker/logic/pro"
+	"golang.org/x/exp/slog"
 )
 
 const (
@@ -45,7 +45,7 @@ func setNodeDefaults() error {
 		currentNodeACL, err := nodeacls.FetchNodeACL(nodeacls.NetworkID(nodes[i].Network), nodeacls.NodeID(nodes[i].ID.String()))
 		if (err != nil && (database.IsEmptyRecord(err) || strings.Contains(err.Error(), "no node ACL present"))) || currentNodeACL == nil {
 			if _, err = nodeacls.CreateNodeACL(nodeacls.NetworkID(nodes[i].Network), nodeacls.NodeID(nodes[i].ID.String()), acls.Allowed); err != nil {
-				logger.Log(1, "could not create a default ACL for node", nodes[i].ID.String())
+				slog.Error("could not create a default ACL for node", "node_id", nodes[i].ID.String())
 			}
 		}
 	}
@@ -60,7 +60,7 @@ func setNetworkDefaults() error {
 	}
 	for _, network := range networks {
 		if err = pro.InitializeNetworkUsers(network.NetID); err != nil {
-			logger.Log(0, "could not initialize NetworkUsers on network", network.NetID)
+			slog.Error("could not initialize NetworkUsers on network", "net_id", network.NetID)
 		}
 		pro.AddProNetDefaults(&network)
 		update := false
@@ -78,16 +78,16 @@ func setNetworkDefaults() error {
 		if update {
 			newNet.SetDefaults()
 			if err := logic.SaveNetwork(&newNet); err != nil {
-				logger.Log(0, "error saving networks during initial update:", err.Error())
+				slog.Error("error saving networks during initial update", "error", err)
 			}
 			if err := logic.DeleteNetwork(network.NetID); err != nil {
-				logger.Log(0, "error deleting old network:", err.Error())
+				slog.Error("error deleting old network", "error", err)
 			}
 		} else {
 			network.SetDefaults()
 			_, _, _, _, _, err = logic.UpdateNetwork(&network, &network)
 			if err != nil {
-				logger.Log(0, "could not set defaults on network", network.NetID)
+				slog.Error("could not set defaults on network", "net_id", network.NetID)
 			}
 		}
 	}
@@ -102,13 +102,13 @@ func setUserDefaults() error {
 	for _, user := range users {
 		updateUser, err := logic.GetUser(user.UserName)
 		if err != nil {
-			logger.Log(0, "could not update user", updateUser.UserName)
+			slog.Error("could not update user", "user_name", updateUser.UserName)
 		}
 		logic.SetUserDefaults(updateUser)
 		copyUser := updateUser
 		copyUser.Password = ""
 		if _, err = logic.UpdateUser(copyUser, updateUser); err != nil {
-			logger.Log(0, "could not update user", updateUser.UserName)
+			slog.Error("could not update user", "user_name", updateUser.UserName)
 		}
 	}
 	return nil
@@ -0,0 +1,210 @@
+package serverctl
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gravitl/netmaker/config"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/mq"
+	"github.com/gravitl/netmaker/servercfg"
+)
+
+// defaultMasterKey - the master key of the example configs, which must not be used in production
+const defaultMasterKey = "secretkey"
+
+// configValidator - collects every problem instead of stopping at the first
+type configValidator struct {
+	problems []models.ConfigProblem
+}
+
+// ValidateConfig - checks the server's settings, database, broker, oauth and certificate files,
+// reporting all problems at once. When running, the live database and broker connections are checked,
+// otherwise each is connected to once.
+func ValidateConfig(running bool) models.ConfigValidation {
+	v := &configValidator{}
+	v.checkSettings()
+	v.checkDatabase(running)
+	v.checkBroker(running)
+	v.checkOAuth()
+	v.checkCertificates()
+	validation := models.ConfigValidation{Valid: true, Problems: v.problems}
+	if validation.Problems == nil {
+		validation.Problems = []models.ConfigProblem{}
+	}
+	for _, problem := range validation.Problems {
+		if problem.Fatal {
+			validation.Valid = false
+		}
+	}
+	return validation
+}
+
+// == private ==
+
+func (v *configValidator) fail(check, setting, format string, args ...any) {
+	v.problems = append(v.problems, models.ConfigProblem{Check: check, Setting: setting, Message: fmt.Sprintf(format, args...), Fatal: true})
+}
+
+func (v *configValidator) warn(check, setting, format string, args ...any) {
+	v.problems = append(v.problems, models.ConfigProblem{Check: check, Setting: setting, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *configValidator) checkSettings() {
+	if servercfg.GetServer() == "" {
+		v.fail("settings", "SERVER_NAME", "server name is not set, hosts can't tell which server their topics belong to")
+	}
+	if servercfg.GetAPIConnString() == "" {
+		v.warn("settings", "SERVER_API_CONN_STRING", "api endpoint is not set, hosts are told to use the server's public ip")
+	}
+	switch servercfg.GetMasterKey() {
+	case "":
+		v.warn("settings", "MASTER_KEY", "master key is not set, the api can only be used with user tokens")
+	case defaultMasterKey:
+		v.fail("settings", "MASTER_KEY", "master key is the example default, anyone can use the api as super admin")
+	}
+	if port, err := strconv.Atoi(servercfg.GetAPIPort()); err != nil || port < 1 || port > 65535 {
+		v.fail("settings", "API_PORT", "api port %q is not a port number", servercfg.GetAPIPort())
+	}
+	for _, setting := range []string{"VERBOSITY", "PUBLISH_WORKERS", "MQ_PARTITIONS", "MQ_PARTITION"} {
+		if value := os.Getenv(setting); value != "" {
+			if _, err := strconv.Atoi(value); err != nil {
+				v.fail("settings", setting, "%q is not a number", value)
+			}
+		}
+	}
+	if value := os.Getenv("TRACING_SAMPLE_RATIO"); value != "" {
+		if ratio, err := strconv.ParseFloat(value, 64); err != nil || ratio <= 0 || ratio > 1 {
+			v.warn("settings", "TRACING_SAMPLE_RATIO", "%q is not a ratio between 0 and 1, every trace is sampled", value)
+		}
+	}
+	if os.Getenv("MQ_PARTITIONS") != "" || config.Config.Server.MQPartitions > 1 {
+		if _, partitions := servercfg.GetMQPartition(); partitions == 1 {
+			v.fail("settings", "MQ_PARTITION", "partition must be from 0 to one less than MQ_PARTITIONS, this instance would handle every host")
+		}
+	}
+	switch servercfg.GetBrokerType() {
+	case "mosquitto", servercfg.EmqxBrokerType:
+	default:
+		v.fail("settings", "BROKER_TYPE", "unknown broker type %q, use mosquitto or emqx", servercfg.GetBrokerType())
+	}
+	endpoint, _ := servercfg.GetMessageQueueEndpoint()
+	if u, err := url.Parse(endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+		v.fail("settings", "SERVER_BROKER_ENDPOINT", "broker endpoint %q is not a url such as ws://broker:1883", endpoint)
+	}
+	if servercfg.GetMqUserName() == "" || servercfg.GetMqPassword() == "" {
+		v.fail("settings", "MQ_USERNAME", "broker username and password must both be set")
+	}
+}
+
+func (v *configValidator) checkDatabase(running bool) {
+	if running {
+		if !database.IsConnected() {
+			v.fail("database", "DATABASE", "%s database is not responding", servercfg.GetDB())
+		}
+		return
+	}
+	if err := database.CheckConnection(); err != nil {
+		v.fail("database", "DATABASE", "could not connect to %s database: %v", servercfg.GetDB(), err)
+	}
+}
+
+func (v *configValidator) checkBroker(running bool) {
+	if !servercfg.IsMessageQueueBackend() {
+		return
+	}
+	if running && mq.IsConnected() {
+		return
+	}
+	if err := mq.CheckBrokerConnection(); err != nil {
+		v.fail("broker", "MQ_USERNAME", "could not connect to %s broker with the configured credentials: %v", servercfg.GetBrokerType(), err)
+	}
+}
+
+func (v *configValidator) checkOAuth() {
+	configured := ""
+	if !servercfg.HasSettings() {
+		// saved settings replace the provider of the env vars and config file
+		configured = os.Getenv("AUTH_PROVIDER")
+		if configured == "" {
+			configured = config.Config.Server.AuthProvider
+		}
+	}
+	info := servercfg.GetAuthProviderInfo()
+	provider := info[0]
+	if configured != "" && provider == "" {
+		switch strings.ToLower(configured) {
+		case "google", "azure-ad", "github", "oidc":
+			v.fail("oauth", "CLIENT_ID", "%s is set as auth provider but its client id or secret is missing, or the oidc issuer", configured)
+		default:
+			v.fail("oauth", "AUTH_PROVIDER", "unknown auth provider %q, use google, azure-ad, github or oidc", configured)
+		}
+		return
+	}
+	if provider == "" {
+		return
+	}
+	if servercfg.GetFrontendURL() == "" {
+		v.fail("oauth", "FRONTEND_URL", "frontend url is not set, users can't be redirected back after logging in with %s", provider)
+	}
+	if provider == "oidc" {
+		if info[3] == "" {
+			v.fail("oauth", "OIDC_ISSUER", "oidc issuer is not set")
+		} else if u, err := url.Parse(info[3]); err != nil || u.Scheme != "https" {
+			v.warn("oauth", "OIDC_ISSUER", "oidc issuer %q is not an https url", info[3])
+		}
+	}
+	if provider == "azure-ad" && servercfg.GetAzureTenant() == "" {
+		v.warn("oauth", "AZURE_TENANT", "azure tenant is not set, users of any tenant can log in")
+	}
+}
+
+func (v *configValidator) checkCertificates() {
+	if certFile := servercfg.GetAWSIdentityCertFile(); certFile != "" {
+		if err := checkPEMCertificates(certFile); err != nil {
+			v.fail("certificates", "AWS_IDENTITY_CERT_FILE", "%v", err)
+		}
+	}
+	if servercfg.IsACMEEnabled() {
+		if len(servercfg.GetACMEDomains()) == 0 {
+			v.fail("certificates", "ACME_DOMAINS", "acme is enabled but there are no domains to issue a certificate for")
+		}
+		dir := servercfg.GetACMECertDir()
+		if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+			v.fail("certificates", "ACME_CERT_DIR", "%s is not a directory", dir)
+		}
+	}
+}
+
+// checkPEMCertificates - checks a file can be read and holds at least one certificate
+func checkPEMCertificates(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", path, err)
+	}
+	found := false
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return fmt.Errorf("%s holds an invalid certificate: %w", path, err)
+		}
+		found = true
+	}
+	if !found {
+		return fmt.Errorf("%s holds no pem certificates", path)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package serverctl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateConfig(t *testing.T) {
+	valid := func(t *testing.T) {
+		for setting, value := range map[string]string{
+			"SERVER_NAME":            "netmaker.example.com",
+			"SERVER_API_CONN_STRING": "api.netmaker.example.com:443",
+			"MASTER_KEY":             "a-long-random-key",
+			"API_PORT":               "8081",
+			"BROKER_TYPE":            "mosquitto",
+			"SERVER_BROKER_ENDPOINT": "wss://broker.netmaker.example.com",
+			"MQ_USERNAME":            "netmaker",
+			"MQ_PASSWORD":            "password",
+			"MESSAGEQUEUE_BACKEND":   "off",
+			"DATABASE":               "sqlite",
+			"AUTH_PROVIDER":          "",
+			"CLIENT_ID":              "",
+			"CLIENT_SECRET":          "",
+			"VERBOSITY":              "",
+			"MQ_PARTITIONS":          "",
+			"MQ_PARTITION":           "",
+			"AWS_IDENTITY_CERT_FILE": "",
+		} {
+			t.Setenv(setting, value)
+		}
+	}
+	// problems - whether each setting with a problem is fatal
+	problems := func(validation models.ConfigValidation) map[string]bool {
+		found := map[string]bool{}
+		for _, problem := range validation.Problems {
+			found[problem.Setting] = problem.Fatal
+		}
+		return found
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		valid(t)
+		validation := ValidateConfig(false)
+		assert.True(t, validation.Valid)
+		assert.Empty(t, validation.Problems)
+	})
+	t.Run("EveryProblemAtOnce", func(t *testing.T) {
+		valid(t)
+		t.Setenv("SERVER_NAME", "")
+		t.Setenv("MASTER_KEY", defaultMasterKey)
+		t.Setenv("API_PORT", "http")
+		t.Setenv("VERBOSITY", "loud")
+		t.Setenv("BROKER_TYPE", "rabbitmq")
+		validation := ValidateConfig(false)
+		assert.False(t, validation.Valid)
+		assert.Equal(t, map[string]bool{"SERVER_NAME": true, "MASTER_KEY": true, "API_PORT": true, "VERBOSITY": true, "BROKER_TYPE": true},
+			problems(validation))
+	})
+	t.Run("WarningsOnly", func(t *testing.T) {
+		valid(t)
+		t.Setenv("SERVER_API_CONN_STRING", "")
+		t.Setenv("TRACING_SAMPLE_RATIO", "2")
+		validation := ValidateConfig(false)
+		assert.True(t, validation.Valid)
+		assert.Equal(t, map[string]bool{"SERVER_API_CONN_STRING": false, "TRACING_SAMPLE_RATIO": false}, problems(validation))
+	})
+	t.Run("Partition", func(t *testing.T) {
+		valid(t)
+		t.Setenv("MQ_PARTITIONS", "3")
+		t.Setenv("MQ_PARTITION", "3")
+		assert.Equal(t, map[string]bool{"MQ_PARTITION": true}, problems(ValidateConfig(false)))
+	})
+	t.Run("OAuth", func(t *testing.T) {
+		valid(t)
+		t.Setenv("AUTH_PROVIDER", "github")
+		assert.Equal(t, map[string]bool{"CLIENT_ID": true}, problems(ValidateConfig(false)))
+		t.Setenv("AUTH_PROVIDER", "okta")
+		t.Setenv("CLIENT_ID", "id")
+		t.Setenv("CLIENT_SECRET", "secret")
+		assert.Equal(t, map[string]bool{"AUTH_PROVIDER": true}, problems(ValidateConfig(false)))
+	})
+	t.Run("Database", func(t *testing.T) {
+		valid(t)
+		t.Setenv("DATABASE", "mongodb")
+		assert.Equal(t, map[string]bool{"DATABASE": true}, problems(ValidateConfig(false)))
+	})
+	t.Run("Certificates", func(t *testing.T) {
+		valid(t)
+		dir := t.TempDir()
+		issued, _ := testACMECertificate(t)
+		certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+		assert.Nil(t, os.WriteFile(certFile, issued.Certificate, 0600))
+		assert.Nil(t, os.WriteFile(keyFile, issued.PrivateKey, 0600))
+		t.Setenv("AWS_IDENTITY_CERT_FILE", certFile)
+		assert.Empty(t, problems(ValidateConfig(false)))
+		t.Setenv("AWS_IDENTITY_CERT_FILE", keyFile)
+		assert.Equal(t, map[string]bool{"AWS_IDENTITY_CERT_FILE": true}, problems(ValidateConfig(false)))
+		t.Setenv("AWS_IDENTITY_CERT_FILE", filepath.Join(dir, "missing.pem"))
+		assert.Equal(t, map[string]bool{"AWS_IDENTITY_CERT_FILE": true}, problems(ValidateConfig(false)))
+	})
+}